@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/sandwich-labs/puck/internal/agent"
+)
+
+func main() {
+	socket := flag.String("socket", agent.SocketPath, "socket puckd listens on for reports")
+	flag.Parse()
+
+	// puckd starts the agent whenever it stops hearing from it, so a copy
+	// already running is left to carry on
+	lock, err := os.OpenFile(filepath.Join(os.TempDir(), "puck-agent.lock"), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		os.Exit(1)
+	}
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := agent.Run(ctx, *socket); err != nil {
+		os.Exit(1)
+	}
+}
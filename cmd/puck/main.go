@@ -3,11 +3,17 @@ package main
 import (
 	"os"
 
+	"github.com/containers/storage/pkg/reexec"
 	"github.com/sandwich-labs/puck/internal/cli"
 )
 
 func main() {
+	// The router process of a daemon started with puck daemon start
+	if reexec.Init() {
+		return
+	}
+
 	if err := cli.Execute(); err != nil {
-		os.Exit(1)
+		os.Exit(cli.ExitCode(err))
 	}
 }
@@ -7,10 +7,16 @@ import (
 	"syscall"
 
 	"github.com/charmbracelet/log"
+	"github.com/containers/storage/pkg/reexec"
 	"github.com/sandwich-labs/puck/internal/daemon"
 )
 
 func main() {
+	// The router process, when router_process is child
+	if reexec.Init() {
+		return
+	}
+
 	log.Info("Starting puckd daemon")
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -0,0 +1,196 @@
+// Package agent is the small program puckd can put in pucks to report
+// what runs inside them: whether the puck has finished booting, the TCP
+// ports listening in it and its running services. It writes reports to
+// a socket the daemon listens on in the puck's etc volume, so each puck
+// can only ever report for itself. It uses nothing but the standard
+// library, to keep the binary small.
+package agent
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"slices"
+	"time"
+)
+
+// Where the agent and its socket are inside a puck, in the etc volume
+const (
+	BinaryPath = "/etc/puck/agent"
+	SocketPath = "/etc/puck/agent.sock"
+)
+
+// File names of the agent and its socket in the etc volume on the host
+const (
+	BinaryFile = "agent"
+	SocketFile = "agent.sock"
+)
+
+const (
+	// Interval is how often the agent looks at the puck; a report is
+	// sent when something changed, or every Heartbeat regardless
+	Interval  = 2 * time.Second
+	Heartbeat = 30 * time.Second
+	// StaleAfter is how long a report counts once received
+	StaleAfter = 2 * Heartbeat
+)
+
+// Report is what the agent saw in a puck
+type Report struct {
+	// Ready is the puck having finished booting: systemd reaching
+	// running or degraded, or at once without systemd
+	Ready bool `json:"ready"`
+	// Ports are the TCP ports listening in the puck, and AppPort the one
+	// it most likely serves HTTP on, or zero
+	Ports   []Port `json:"ports,omitempty"`
+	AppPort int    `json:"app_port,omitempty"`
+	// Services are the running systemd services, or without systemd the
+	// names of the running processes
+	Services []string `json:"services,omitempty"`
+	// When the daemon received the report; not sent by the agent
+	Updated time.Time `json:"updated"`
+}
+
+// Port is a TCP port listening in a puck
+type Port struct {
+	Port    int    `json:"port"`
+	Address string `json:"address"` // e.g. 0.0.0.0, ::, 127.0.0.1
+}
+
+// Loopback reports whether only the puck itself can reach the port
+func (p Port) Loopback() bool {
+	ip := net.ParseIP(p.Address)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Fresh reports whether the report is recent enough to go by
+func (r *Report) Fresh() bool {
+	return r != nil && time.Since(r.Updated) < StaleAfter
+}
+
+// equal reports whether two reports say the same, ignoring when they
+// were received
+func (r Report) equal(other Report) bool {
+	return r.Ready == other.Ready && r.AppPort == other.AppPort &&
+		slices.Equal(r.Ports, other.Ports) && slices.Equal(r.Services, other.Services)
+}
+
+// nonHTTPPorts are ports apps commonly listen on that don't speak HTTP,
+// passed over when guessing the app's port
+var nonHTTPPorts = []int{22, 25, 53, 111, 2049, 3306, 5432, 6379, 11211, 27017}
+
+// AppPort guesses which of the ports the puck's app serves HTTP on: 80 if
+// it listens there, otherwise the lowest port reachable from outside the
+// puck that isn't a well-known non-HTTP one. Zero is no guess.
+func AppPort(ports []Port) int {
+	var candidates []int
+	for _, p := range ports {
+		if p.Loopback() || slices.Contains(nonHTTPPorts, p.Port) {
+			continue
+		}
+		if p.Port == 80 {
+			return 80
+		}
+		candidates = append(candidates, p.Port)
+	}
+	if len(candidates) == 0 {
+		return 0
+	}
+	return slices.Min(candidates)
+}
+
+// Collect looks at the puck the agent runs in, with /proc at procRoot
+func Collect(ctx context.Context, procRoot string) Report {
+	ports := ListeningPorts(procRoot)
+	var r Report
+	r.Ports = ports
+	r.AppPort = AppPort(ports)
+	r.Ready, r.Services = services(ctx, procRoot)
+	return r
+}
+
+// Run reports on the puck to the daemon's socket at path until ctx is
+// done, reconnecting whenever the daemon goes away
+func Run(ctx context.Context, path string) error {
+	var (
+		conn     net.Conn
+		last     Report
+		lastSent time.Time
+	)
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	ticker := time.NewTicker(Interval)
+	defer ticker.Stop()
+	for {
+		if conn == nil {
+			c, err := net.Dial("unix", path)
+			if err == nil {
+				conn, lastSent = c, time.Time{}
+			}
+		}
+		if conn != nil {
+			r := Collect(ctx, "/proc")
+			if !r.equal(last) || time.Since(lastSent) >= Heartbeat {
+				if err := json.NewEncoder(conn).Encode(r); err != nil {
+					conn.Close()
+					conn = nil
+				} else {
+					last, lastSent = r, time.Now()
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Listen opens the socket a puck's agent reports to, at path on the
+// daemon's host. Anyone may connect, as the agent runs as whichever user
+// the puck's root maps to. Closing it leaves the socket file, which may
+// by then belong to another listener; the next Listen replaces it.
+func Listen(path string) (net.Listener, error) {
+	os.Remove(path)
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := os.Chmod(path, 0666); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// Serve passes each report sent to ln to fn, stamped with when it
+// arrived, until ln is closed
+func Serve(ln net.Listener, fn func(Report)) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			scanner := bufio.NewScanner(conn)
+			for scanner.Scan() {
+				var r Report
+				if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+					return
+				}
+				r.Updated = time.Now()
+				fn(r)
+			}
+		}()
+	}
+}
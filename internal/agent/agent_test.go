@@ -0,0 +1,114 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeProc lays out the parts of /proc the agent reads under dir
+func writeProc(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+}
+
+func TestListeningPorts(t *testing.T) {
+	proc := t.TempDir()
+	writeProc(t, proc, map[string]string{
+		"net/tcp": `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:0BB8 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1 1 0 100 0 0 10 0
+   1: 0100007F:1F90 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 2 1 0 100 0 0 10 0
+   2: 0A000002:0BB8 0A000001:C350 01 00000000:00000000 00:00000000 00000000     0        0 3 1 0 20 4 30 10 -1
+`,
+		"net/tcp6": `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000000000000:1538 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 4 1 0 100 0 0 10 0
+   1: 00000000000000000000000001000000:0BB8 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 5 1 0 100 0 0 10 0
+`,
+	})
+
+	assert.Equal(t, []Port{
+		{Port: 3000, Address: "0.0.0.0"},
+		{Port: 3000, Address: "::1"},
+		{Port: 5432, Address: "::"},
+		{Port: 8080, Address: "127.0.0.1"},
+	}, ListeningPorts(proc), "established connections are left out")
+
+	assert.Empty(t, ListeningPorts(t.TempDir()))
+}
+
+func TestAppPort(t *testing.T) {
+	for name, tc := range map[string]struct {
+		ports []Port
+		want  int
+	}{
+		"80 wins":             {[]Port{{Port: 22, Address: "0.0.0.0"}, {Port: 80, Address: "::"}, {Port: 3000, Address: "0.0.0.0"}}, 80},
+		"lowest other":        {[]Port{{Port: 8080, Address: "0.0.0.0"}, {Port: 3000, Address: "::"}}, 3000},
+		"skips databases":     {[]Port{{Port: 5432, Address: "0.0.0.0"}, {Port: 8000, Address: "0.0.0.0"}}, 8000},
+		"skips loopback only": {[]Port{{Port: 3000, Address: "127.0.0.1"}}, 0},
+		"nothing":             {nil, 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, AppPort(tc.ports))
+		})
+	}
+}
+
+func TestProcessNames(t *testing.T) {
+	proc := t.TempDir()
+	writeProc(t, proc, map[string]string{
+		"1/comm":     "tini\n",
+		"7/comm":     "node\n",
+		"9/comm":     "node\n",
+		"self/comm":  "ignored\n",
+		"net/tcp":    "",
+		"12/cmdline": "",
+	})
+	assert.Equal(t, []string{"node", "tini"}, processNames(proc))
+}
+
+func TestRunReportsToServe(t *testing.T) {
+	path := filepath.Join(t.TempDir(), SocketFile)
+	ln, err := Listen(path)
+	require.NoError(t, err)
+	defer ln.Close()
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0666), info.Mode().Perm(), "the puck's root may be any host user")
+
+	reports := make(chan Report, 1)
+	go Serve(ln, func(r Report) {
+		select {
+		case reports <- r:
+		default:
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Run(ctx, path)
+
+	select {
+	case r := <-reports:
+		assert.True(t, r.Fresh())
+		assert.WithinDuration(t, time.Now(), r.Updated, 5*time.Second)
+	case <-time.After(10 * time.Second):
+		t.Fatal("no report arrived")
+	}
+}
+
+func TestFresh(t *testing.T) {
+	var none *Report
+	assert.False(t, none.Fresh())
+	assert.True(t, (&Report{Updated: time.Now()}).Fresh())
+	assert.False(t, (&Report{Updated: time.Now().Add(-StaleAfter)}).Fresh())
+}
@@ -0,0 +1,128 @@
+package agent
+
+import (
+	"context"
+	"encoding/hex"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// tcpListen is the LISTEN state in /proc/net/tcp
+const tcpListen = "0A"
+
+// ListeningPorts reads the TCP ports listening in the puck from
+// /proc/net/tcp and tcp6 under procRoot, lowest first. A port listening
+// on several addresses is listed once for each.
+func ListeningPorts(procRoot string) []Port {
+	var tables []byte
+	for _, file := range []string{"tcp", "tcp6"} {
+		if data, err := os.ReadFile(filepath.Join(procRoot, "net", file)); err == nil {
+			tables = append(tables, data...)
+		}
+	}
+	return ParsePorts(tables)
+}
+
+// ParsePorts reads the listening ports from the contents of /proc/net/tcp
+// and tcp6, one after the other, lowest first
+func ParsePorts(tables []byte) []Port {
+	var ports []Port
+	for _, line := range strings.Split(string(tables), "\n") {
+		// Headers and connections in other states are skipped
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[3] != tcpListen {
+			continue
+		}
+		if p, ok := parseAddress(fields[1]); ok && !slices.Contains(ports, p) {
+			ports = append(ports, p)
+		}
+	}
+	slices.SortFunc(ports, func(a, b Port) int {
+		if a.Port != b.Port {
+			return a.Port - b.Port
+		}
+		return strings.Compare(a.Address, b.Address)
+	})
+	return ports
+}
+
+// parseAddress reads a local_address from /proc/net/tcp, such as
+// 0100007F:1F90, whose IP is in host byte order four bytes at a time
+func parseAddress(s string) (Port, bool) {
+	hexIP, hexPort, ok := strings.Cut(s, ":")
+	if !ok {
+		return Port{}, false
+	}
+	port, err := strconv.ParseUint(hexPort, 16, 16)
+	if err != nil {
+		return Port{}, false
+	}
+	raw, err := hex.DecodeString(hexIP)
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		return Port{}, false
+	}
+	ip := make(net.IP, len(raw))
+	for i := 0; i < len(raw); i += 4 {
+		ip[i], ip[i+1], ip[i+2], ip[i+3] = raw[i+3], raw[i+2], raw[i+1], raw[i]
+	}
+	return Port{Port: int(port), Address: ip.String()}, true
+}
+
+// services reports whether the puck has finished booting, and what runs
+// in it: systemd's running services where systemd is PID 1, otherwise
+// the names of the running processes
+func services(ctx context.Context, procRoot string) (bool, []string) {
+	if _, err := os.Stat("/run/systemd/system"); err == nil {
+		return systemdServices(ctx)
+	}
+	return true, processNames(procRoot)
+}
+
+// systemdServices asks systemd whether it is up, and for its running
+// services
+func systemdServices(ctx context.Context) (bool, []string) {
+	state, _ := exec.CommandContext(ctx, "systemctl", "is-system-running").Output()
+	ready := slices.Contains([]string{"running", "degraded"}, strings.TrimSpace(string(state)))
+
+	out, err := exec.CommandContext(ctx, "systemctl", "list-units", "--type=service", "--state=running", "--no-legend", "--plain").Output()
+	if err != nil {
+		return ready, nil
+	}
+	var names []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			names = append(names, strings.TrimSuffix(fields[0], ".service"))
+		}
+	}
+	return ready, names
+}
+
+// processNames lists the names of the processes running under procRoot,
+// leaving out the agent itself
+func processNames(procRoot string) []string {
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return nil
+	}
+	self := strconv.Itoa(os.Getpid())
+	var names []string
+	for _, e := range entries {
+		if _, err := strconv.Atoi(e.Name()); err != nil || e.Name() == self {
+			continue
+		}
+		comm, err := os.ReadFile(filepath.Join(procRoot, e.Name(), "comm"))
+		if err != nil {
+			continue
+		}
+		if name := strings.TrimSpace(string(comm)); name != "" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
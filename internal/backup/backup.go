@@ -0,0 +1,562 @@
+// Package backup saves and restores a whole puck installation: its
+// database, configuration, volume directories and, optionally, snapshots.
+// Containers are not saved; restored pucks get new ones from their images
+// when they are next started.
+package backup
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/containers/storage/pkg/archive"
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// formatVersion is the backup layout this package writes
+const formatVersion = 1
+
+const (
+	manifestName = "manifest.json"
+	databaseName = "data/puck.db"
+	// Volume directories outside the data directory are kept under
+	// volumes/<puck name>
+	volumesName = "volumes"
+)
+
+// Paths locates what a backup holds on this host
+type Paths struct {
+	ConfigFile      string
+	ContextsFile    string
+	HooksDir        string
+	LandingTemplate string
+	MOTDTemplate    string
+	DataDir         string
+	// Database is the SQLite file, or empty when the store is Postgres,
+	// which is left to its own backup tools
+	Database string
+	// DatabaseURL is the Postgres store, if that is where pucks are kept
+	DatabaseURL string
+}
+
+// PathsFor returns where cfg keeps things, with configFile as the config
+// file in use
+func PathsFor(cfg *config.Config, configFile string) Paths {
+	if configFile == "" {
+		configFile = config.ConfigPath()
+	}
+	p := Paths{
+		ConfigFile:      configFile,
+		ContextsFile:    config.ContextsPath(),
+		HooksDir:        cfg.HooksDir,
+		LandingTemplate: cfg.LandingTemplate,
+		MOTDTemplate:    cfg.MOTDTemplate,
+		DataDir:         cfg.DataDir,
+	}
+	if cfg.DatabaseURL == "" {
+		p.Database = cfg.DatabasePath()
+	} else {
+		p.DatabaseURL = cfg.DatabaseURL
+	}
+	return p
+}
+
+// entries maps the top-level names in a backup to where they live. Data
+// directory entries are relative to it.
+func (p Paths) entries() []struct{ name, path string } {
+	return []struct{ name, path string }{
+		{"config/config.yaml", p.ConfigFile},
+		{"config/contexts.yaml", p.ContextsFile},
+		{"config/landing.html", p.LandingTemplate},
+		{"config/motd.tmpl", p.MOTDTemplate},
+		{"config/hooks.d", p.HooksDir},
+		{"data/share.key", filepath.Join(p.DataDir, "share.key")},
+		{"data/pucks", filepath.Join(p.DataDir, "pucks")},
+		{"data/snapshots", filepath.Join(p.DataDir, "snapshots")},
+	}
+}
+
+// Manifest describes a backup. It is the first entry in the archive.
+type Manifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Host      string    `json:"host"`
+	DataDir   string    `json:"data_dir"`
+	Database  bool      `json:"database"`
+	Snapshots bool      `json:"snapshots"`
+	// Volume directories of pucks kept outside the data directory, by
+	// puck name. They are restored under the data directory's pucks, as
+	// an archive must not choose which host directories get written.
+	Volumes map[string]string `json:"volumes,omitempty"`
+}
+
+// Options controls what Create includes
+type Options struct {
+	Snapshots bool
+}
+
+// Report summarizes a backup written or restored
+type Report struct {
+	Manifest *Manifest
+	Files    int
+	Size     int64
+	// Files that could not be read, such as volume files owned by
+	// another user in the container's namespace
+	Skipped []string
+}
+
+// Create writes a backup of everything in paths to w as a tar archive.
+// The database is copied consistently even while the daemon is running;
+// volume directories are read as they are, so stopping or snapshotting
+// busy pucks first gives a cleaner copy.
+func Create(ctx context.Context, w io.Writer, paths Paths, opts Options) (*Report, error) {
+	volumes, err := externalVolumes(ctx, paths)
+	if err != nil {
+		return nil, err
+	}
+	host, _ := os.Hostname()
+	report := &Report{Manifest: &Manifest{
+		Version:   formatVersion,
+		CreatedAt: time.Now().UTC(),
+		Host:      host,
+		DataDir:   paths.DataDir,
+		Database:  paths.Database != "",
+		Snapshots: opts.Snapshots,
+		Volumes:   volumes,
+	}}
+
+	tw := tar.NewWriter(w)
+	manifest, _ := json.MarshalIndent(report.Manifest, "", "  ")
+	hdr := &tar.Header{Name: manifestName, Mode: 0644, Size: int64(len(manifest)), ModTime: report.Manifest.CreatedAt}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return nil, err
+	}
+	if _, err := tw.Write(manifest); err != nil {
+		return nil, err
+	}
+
+	if paths.Database != "" {
+		if err := addDatabase(ctx, tw, paths.Database, opts.Snapshots, report); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, e := range paths.entries() {
+		if e.name == "data/snapshots" && !opts.Snapshots {
+			continue
+		}
+		if err := addTree(ctx, tw, e.name, e.path, report); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(volumes)) {
+		if err := addTree(ctx, tw, volumesName+"/"+name, volumes[name], report); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// externalVolumes returns the volume directories of pucks given their own
+// data directory, by puck name
+func externalVolumes(ctx context.Context, paths Paths) (map[string]string, error) {
+	dsn := paths.DatabaseURL
+	if paths.Database != "" {
+		if _, err := os.Stat(paths.Database); err != nil {
+			return nil, nil
+		}
+		dsn = paths.Database
+	}
+	if dsn == "" {
+		return nil, nil
+	}
+	db, err := store.OpenDSN(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
+	defer db.Close()
+	pucks, err := db.ListPucks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pucksDir := filepath.Join(paths.DataDir, "pucks") + string(os.PathSeparator)
+	var volumes map[string]string
+	for _, p := range pucks {
+		if p.VolumeDir == "" || strings.HasPrefix(p.VolumeDir, pucksDir) {
+			continue
+		}
+		if volumes == nil {
+			volumes = make(map[string]string)
+		}
+		volumes[p.Name] = p.VolumeDir
+	}
+	return volumes, nil
+}
+
+// addDatabase adds a consistent copy of the database, without snapshot
+// records unless the snapshots are included too
+func addDatabase(ctx context.Context, tw *tar.Writer, dbPath string, snapshots bool, report *Report) error {
+	db, err := store.Open(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tmp, err := os.MkdirTemp("", "puck-backup-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	copyPath := filepath.Join(tmp, "puck.db")
+	if err := db.BackupTo(ctx, copyPath); err != nil {
+		return err
+	}
+	if !snapshots {
+		copied, err := store.Open(copyPath)
+		if err != nil {
+			return err
+		}
+		err = copied.DropAllSnapshots(ctx)
+		if cerr := copied.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	info, err := os.Stat(copyPath)
+	if err != nil {
+		return err
+	}
+	return addFile(tw, databaseName, copyPath, info, report)
+}
+
+// addTree adds the file or directory at src under name, if it exists
+func addTree(ctx context.Context, tw *tar.Writer, name, src string, report *Report) error {
+	if src == "" {
+		return nil
+	}
+	if _, err := os.Lstat(src); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrPermission) {
+				report.Skipped = append(report.Skipped, p)
+				if d != nil && d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		entry := name
+		if rel != "." {
+			entry = path.Join(name, filepath.ToSlash(rel))
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return addFile(tw, entry, p, info, report)
+	})
+}
+
+// addFile writes one file, directory or symlink to the archive
+func addFile(tw *tar.Writer, name, src string, info fs.FileInfo, report *Report) error {
+	var link string
+	if info.Mode()&fs.ModeSymlink != 0 {
+		var err error
+		if link, err = os.Readlink(src); err != nil {
+			return err
+		}
+	}
+	if !info.Mode().IsRegular() && !info.IsDir() && link == "" {
+		// Sockets, fifos and devices don't survive a move between hosts
+		return nil
+	}
+
+	var f *os.File
+	if info.Mode().IsRegular() {
+		var err error
+		if f, err = os.Open(src); err != nil {
+			if errors.Is(err, fs.ErrPermission) {
+				report.Skipped = append(report.Skipped, src)
+				return nil
+			}
+			return err
+		}
+		defer f.Close()
+	}
+
+	hdr, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	// Ownership is the restoring user's; IDs from another host mean nothing
+	hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if f != nil {
+		n, err := io.Copy(tw, f)
+		if err != nil {
+			return fmt.Errorf("archiving %s: %w", src, err)
+		}
+		report.Files++
+		report.Size += n
+	}
+	return nil
+}
+
+// RestoreOptions controls Restore
+type RestoreOptions struct {
+	// Overwrite an existing installation
+	Force bool
+}
+
+// Restore unpacks a backup from r into paths. The daemon must not be
+// running. Paths under the backed up data directory are moved to this
+// one, and pucks are left stopped, or checkpointed, without containers.
+func Restore(ctx context.Context, r io.Reader, paths Paths, opts RestoreOptions) (*Report, error) {
+	rc, err := archive.DecompressStream(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading backup: %w", err)
+	}
+	defer rc.Close()
+	tr := tar.NewReader(rc)
+
+	manifest, err := readManifest(tr)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Database {
+		if paths.Database == "" {
+			return nil, fmt.Errorf("the backup holds a SQLite database but this host is configured for Postgres; unset database_url to restore it")
+		}
+		if _, err := os.Stat(paths.Database); err == nil && !opts.Force {
+			return nil, fmt.Errorf("%s already exists; use --force to replace this installation", paths.Database)
+		}
+	}
+
+	report := &Report{Manifest: manifest}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading backup: %w", err)
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		root, target, err := paths.target(manifest, hdr.Name)
+		if err != nil {
+			return nil, err
+		}
+		if target == "" {
+			continue
+		}
+		// An earlier entry may have been a symlink out of the directory
+		if err := checkParents(root, target); err != nil {
+			return nil, fmt.Errorf("backup entry %q: %w", hdr.Name, err)
+		}
+		if hdr.Name == databaseName {
+			// Leftover WAL files belong to the database being replaced
+			os.Remove(target + "-wal")
+			os.Remove(target + "-shm")
+		}
+		if err := extract(tr, hdr, target, report); err != nil {
+			return nil, err
+		}
+	}
+
+	if manifest.Database {
+		if err := settleDatabase(ctx, paths, manifest); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// readManifest reads and checks the first entry of a backup
+func readManifest(tr *tar.Reader) (*Manifest, error) {
+	hdr, err := tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("reading backup: %w", err)
+	}
+	if hdr.Name != manifestName {
+		return nil, fmt.Errorf("not a puck backup: missing %s", manifestName)
+	}
+	var m Manifest
+	if err := json.NewDecoder(tr).Decode(&m); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", manifestName, err)
+	}
+	if m.Version > formatVersion {
+		return nil, fmt.Errorf("backup format %d is newer than this puck understands (%d)", m.Version, formatVersion)
+	}
+	return &m, nil
+}
+
+// target returns where a backup entry is restored to, and the file or
+// directory of this host it is restored into, or empty strings for
+// entries that have no place on this host
+func (p Paths) target(m *Manifest, name string) (string, string, error) {
+	clean := path.Clean(name)
+	if clean == databaseName {
+		return p.Database, p.Database, nil
+	}
+	entries := p.entries()
+	for puck := range m.Volumes {
+		dir, err := p.volumeDir(puck)
+		if err != nil {
+			return "", "", err
+		}
+		entries = append(entries, struct{ name, path string }{volumesName + "/" + puck, dir})
+	}
+	for _, e := range entries {
+		if e.path == "" {
+			continue
+		}
+		if clean == e.name {
+			return e.path, e.path, nil
+		}
+		if rest, ok := strings.CutPrefix(clean, e.name+"/"); ok {
+			target := filepath.Join(e.path, filepath.FromSlash(rest))
+			// Refuse entries that would land outside their directory
+			if !strings.HasPrefix(target, filepath.Clean(e.path)+string(os.PathSeparator)) {
+				return "", "", fmt.Errorf("backup entry %q escapes %s", name, e.path)
+			}
+			return e.path, target, nil
+		}
+	}
+	return "", "", nil
+}
+
+// volumeDir returns where the volumes a backup kept outside its data
+// directory are restored to on this host
+func (p Paths) volumeDir(puck string) (string, error) {
+	if puck == "" || puck == "." || puck == ".." || strings.ContainsAny(puck, `/\`) {
+		return "", fmt.Errorf("backup has volumes of an invalid puck name %q", puck)
+	}
+	return filepath.Join(p.DataDir, "pucks", puck), nil
+}
+
+// checkParents refuses targets whose directories under root include a
+// symlink, which would have the entry written wherever the link points
+func checkParents(root, target string) error {
+	rel, err := filepath.Rel(root, filepath.Dir(target))
+	if err != nil || rel == "." {
+		return nil
+	}
+	dir := root
+	for _, part := range strings.Split(rel, string(os.PathSeparator)) {
+		dir = filepath.Join(dir, part)
+		info, err := os.Lstat(dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			// The rest is made by extract
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return fmt.Errorf("%s is a symlink", dir)
+		}
+	}
+	return nil
+}
+
+// extract writes one backup entry to target
+func extract(r io.Reader, hdr *tar.Header, target string, report *Report) error {
+	mode := os.FileMode(hdr.Mode).Perm()
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		return os.MkdirAll(target, mode|0700)
+	case tar.TypeSymlink:
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		os.Remove(target)
+		return os.Symlink(hdr.Linkname, target)
+	case tar.TypeReg:
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		// Replace a symlink rather than writing through it
+		if info, err := os.Lstat(target); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+			os.Remove(target)
+		}
+		f, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+		if err != nil {
+			return err
+		}
+		n, err := io.Copy(f, r)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return fmt.Errorf("restoring %s: %w", target, err)
+		}
+		report.Files++
+		report.Size += n
+		return os.Chtimes(target, hdr.ModTime, hdr.ModTime)
+	}
+	return nil
+}
+
+// settleDatabase points a restored database at this host: paths move to
+// its data directory, as do volumes that were kept outside it, and the
+// old host's containers are forgotten
+func settleDatabase(ctx context.Context, paths Paths, m *Manifest) error {
+	db, err := store.Open(paths.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := db.RebaseDataDir(ctx, m.DataDir, paths.DataDir); err != nil {
+		return err
+	}
+	for puck := range m.Volumes {
+		dir, err := paths.volumeDir(puck)
+		if err != nil {
+			return err
+		}
+		if err := db.UpdatePuckVolumeDir(ctx, puck, dir); err != nil && !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+	}
+	return db.DetachContainers(ctx)
+}
@@ -0,0 +1,278 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupInstall lays out a puck installation under root and returns its
+// paths
+func setupInstall(t *testing.T, root string) Paths {
+	t.Helper()
+	paths := Paths{
+		ConfigFile:      filepath.Join(root, "config", "config.yaml"),
+		ContextsFile:    filepath.Join(root, "config", "contexts.yaml"),
+		HooksDir:        filepath.Join(root, "config", "hooks.d"),
+		LandingTemplate: filepath.Join(root, "config", "landing.html"),
+		MOTDTemplate:    filepath.Join(root, "config", "motd.tmpl"),
+		DataDir:         filepath.Join(root, "data"),
+	}
+	paths.Database = filepath.Join(paths.DataDir, "puck.db")
+	return paths
+}
+
+// writeFile creates a file and its directory
+func writeFile(t *testing.T, path, content string, mode os.FileMode) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(content), mode))
+}
+
+func TestBackupRestore(t *testing.T) {
+	ctx := context.Background()
+	src := setupInstall(t, t.TempDir())
+
+	writeFile(t, src.ConfigFile, "router_domain: desk.test\n", 0644)
+	writeFile(t, src.ContextsFile, "current: default\n", 0644)
+	writeFile(t, filepath.Join(src.HooksDir, "notify.sh"), "#!/bin/sh\n", 0755)
+	writeFile(t, filepath.Join(src.DataDir, "share.key"), "secret", 0600)
+	volumeDir := filepath.Join(src.DataDir, "pucks", "desk")
+	writeFile(t, filepath.Join(volumeDir, "home", "notes.txt"), "hello", 0644)
+	snapshotPath := filepath.Join(src.DataDir, "snapshots", "desk", "nightly.tar.gz")
+	writeFile(t, snapshotPath, "checkpoint-data", 0644)
+
+	db, err := store.Open(src.Database)
+	require.NoError(t, err)
+	now := time.Now()
+	p := &store.Puck{
+		ID: "puck-id", ContainerID: "old-container", Name: "desk", Image: "fedora:latest",
+		Status: store.StatusRunning, VolumeDir: volumeDir, HostPort: 9000, CreatedAt: now, UpdatedAt: now,
+	}
+	require.NoError(t, db.CreatePuck(ctx, p))
+	require.NoError(t, db.CreateSnapshot(ctx, &store.Snapshot{
+		ID: "snap-id", PuckID: p.ID, PuckName: p.Name, Name: "nightly", Path: snapshotPath, CreatedAt: now,
+	}))
+	require.NoError(t, db.Close())
+
+	t.Run("restores onto a new host", func(t *testing.T) {
+		var buf bytes.Buffer
+		report, err := Create(ctx, &buf, src, Options{Snapshots: true})
+		require.NoError(t, err)
+		assert.True(t, report.Manifest.Database)
+		assert.True(t, report.Manifest.Snapshots)
+		assert.Empty(t, report.Skipped)
+
+		dst := setupInstall(t, t.TempDir())
+		restored, err := Restore(ctx, &buf, dst, RestoreOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, src.DataDir, restored.Manifest.DataDir)
+
+		for path, want := range map[string]string{
+			dst.ConfigFile:                           "router_domain: desk.test\n",
+			dst.ContextsFile:                         "current: default\n",
+			filepath.Join(dst.HooksDir, "notify.sh"): "#!/bin/sh\n",
+			filepath.Join(dst.DataDir, "share.key"):  "secret",
+			filepath.Join(dst.DataDir, "pucks", "desk", "home", "notes.txt"):  "hello",
+			filepath.Join(dst.DataDir, "snapshots", "desk", "nightly.tar.gz"): "checkpoint-data",
+		} {
+			data, err := os.ReadFile(path)
+			require.NoError(t, err, path)
+			assert.Equal(t, want, string(data), path)
+		}
+		info, err := os.Stat(filepath.Join(dst.HooksDir, "notify.sh"))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+
+		db, err := store.Open(dst.Database)
+		require.NoError(t, err)
+		defer db.Close()
+		got, err := db.GetPuck(ctx, "desk")
+		require.NoError(t, err)
+		assert.Equal(t, "puck-id", got.ID)
+		assert.Equal(t, filepath.Join(dst.DataDir, "pucks", "desk"), got.VolumeDir)
+		assert.Empty(t, got.ContainerID)
+		assert.Equal(t, store.StatusStopped, got.Status)
+		s, err := db.GetSnapshot(ctx, "puck-id", "nightly")
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(dst.DataDir, "snapshots", "desk", "nightly.tar.gz"), s.Path)
+
+		// A second restore would replace what is there now
+		_, err = Restore(ctx, bytes.NewReader(nil), dst, RestoreOptions{})
+		assert.Error(t, err)
+	})
+
+	t.Run("leaves snapshots out unless asked", func(t *testing.T) {
+		var buf bytes.Buffer
+		_, err := Create(ctx, &buf, src, Options{})
+		require.NoError(t, err)
+
+		dst := setupInstall(t, t.TempDir())
+		_, err = Restore(ctx, &buf, dst, RestoreOptions{})
+		require.NoError(t, err)
+		assert.NoDirExists(t, filepath.Join(dst.DataDir, "snapshots"))
+
+		db, err := store.Open(dst.Database)
+		require.NoError(t, err)
+		defer db.Close()
+		snapshots, err := db.ListAllSnapshots(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, snapshots)
+
+		// The source keeps its snapshots
+		srcDB, err := store.Open(src.Database)
+		require.NoError(t, err)
+		defer srcDB.Close()
+		snapshots, err = srcDB.ListAllSnapshots(ctx)
+		require.NoError(t, err)
+		assert.Len(t, snapshots, 1)
+	})
+
+	t.Run("refuses to overwrite without force", func(t *testing.T) {
+		var buf bytes.Buffer
+		_, err := Create(ctx, &buf, src, Options{})
+		require.NoError(t, err)
+		archive := buf.Bytes()
+
+		dst := setupInstall(t, t.TempDir())
+		writeFile(t, dst.Database, "", 0644)
+		_, err = Restore(ctx, bytes.NewReader(archive), dst, RestoreOptions{})
+		assert.ErrorContains(t, err, "--force")
+
+		_, err = Restore(ctx, bytes.NewReader(archive), dst, RestoreOptions{Force: true})
+		assert.NoError(t, err)
+	})
+
+	t.Run("rejects other archives", func(t *testing.T) {
+		dst := setupInstall(t, t.TempDir())
+		_, err := Restore(ctx, bytes.NewReader([]byte("not a tarball")), dst, RestoreOptions{})
+		assert.Error(t, err)
+	})
+}
+
+func TestBackupExternalVolumes(t *testing.T) {
+	ctx := context.Background()
+	src := setupInstall(t, t.TempDir())
+	scratch := filepath.Join(t.TempDir(), "scratch", "fast")
+	writeFile(t, filepath.Join(scratch, "home", "build.log"), "fast", 0644)
+
+	db, err := store.Open(src.Database)
+	require.NoError(t, err)
+	now := time.Now()
+	require.NoError(t, db.CreatePuck(ctx, &store.Puck{
+		ID: "fast-id", Name: "fast", Image: "fedora:latest", Status: store.StatusStopped,
+		VolumeDir: scratch, HostPort: 9001, CreatedAt: now, UpdatedAt: now,
+	}))
+	require.NoError(t, db.Close())
+
+	var buf bytes.Buffer
+	report, err := Create(ctx, &buf, src, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"fast": scratch}, report.Manifest.Volumes)
+
+	// Restored under the new data directory, not to the path the
+	// archive names
+	require.NoError(t, os.RemoveAll(scratch))
+	dst := setupInstall(t, t.TempDir())
+	_, err = Restore(ctx, &buf, dst, RestoreOptions{})
+	require.NoError(t, err)
+	assert.NoDirExists(t, scratch)
+
+	volumeDir := filepath.Join(dst.DataDir, "pucks", "fast")
+	data, err := os.ReadFile(filepath.Join(volumeDir, "home", "build.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "fast", string(data))
+
+	restored, err := store.Open(dst.Database)
+	require.NoError(t, err)
+	defer restored.Close()
+	p, err := restored.GetPuck(ctx, "fast")
+	require.NoError(t, err)
+	assert.Equal(t, volumeDir, p.VolumeDir)
+}
+
+// craftArchive returns a backup holding manifest and then entries
+func craftArchive(t *testing.T, manifest Manifest, entries ...*tar.Header) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	data, err := json.Marshal(manifest)
+	require.NoError(t, err)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: manifestName, Mode: 0644, Size: int64(len(data))}))
+	_, err = tw.Write(data)
+	require.NoError(t, err)
+	for _, hdr := range entries {
+		require.NoError(t, tw.WriteHeader(hdr))
+		if hdr.Size > 0 {
+			_, err = tw.Write(bytes.Repeat([]byte("x"), int(hdr.Size)))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, tw.Close())
+	return &buf
+}
+
+func TestRestoreCraftedArchive(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("refuses to write through a symlink it restored", func(t *testing.T) {
+		dst := setupInstall(t, t.TempDir())
+		outside := t.TempDir()
+		buf := craftArchive(t, Manifest{Version: formatVersion},
+			&tar.Header{Name: "data/pucks/desk/link", Typeflag: tar.TypeSymlink, Linkname: outside},
+			&tar.Header{Name: "data/pucks/desk/link/authorized_keys", Typeflag: tar.TypeReg, Mode: 0644, Size: 4},
+		)
+
+		_, err := Restore(ctx, buf, dst, RestoreOptions{})
+		assert.ErrorContains(t, err, "symlink")
+		assert.NoFileExists(t, filepath.Join(outside, "authorized_keys"))
+	})
+
+	t.Run("replaces a symlink with a file of the same name", func(t *testing.T) {
+		dst := setupInstall(t, t.TempDir())
+		outside := filepath.Join(t.TempDir(), "bashrc")
+		writeFile(t, outside, "mine", 0644)
+		buf := craftArchive(t, Manifest{Version: formatVersion},
+			&tar.Header{Name: "data/pucks/desk/.bashrc", Typeflag: tar.TypeSymlink, Linkname: outside},
+			&tar.Header{Name: "data/pucks/desk/.bashrc", Typeflag: tar.TypeReg, Mode: 0644, Size: 4},
+		)
+
+		_, err := Restore(ctx, buf, dst, RestoreOptions{})
+		require.NoError(t, err)
+		data, err := os.ReadFile(outside)
+		require.NoError(t, err)
+		assert.Equal(t, "mine", string(data))
+	})
+
+	t.Run("restores volumes under the data directory whatever the manifest says", func(t *testing.T) {
+		dst := setupInstall(t, t.TempDir())
+		elsewhere := t.TempDir()
+		buf := craftArchive(t, Manifest{Version: formatVersion, Volumes: map[string]string{"fast": elsewhere}},
+			&tar.Header{Name: "volumes/fast/notes.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: 4},
+		)
+
+		_, err := Restore(ctx, buf, dst, RestoreOptions{})
+		require.NoError(t, err)
+		assert.NoFileExists(t, filepath.Join(elsewhere, "notes.txt"))
+		assert.FileExists(t, filepath.Join(dst.DataDir, "pucks", "fast", "notes.txt"))
+	})
+
+	t.Run("rejects volumes of invalid puck names", func(t *testing.T) {
+		dst := setupInstall(t, t.TempDir())
+		buf := craftArchive(t, Manifest{Version: formatVersion, Volumes: map[string]string{"..": t.TempDir()}},
+			&tar.Header{Name: "volumes/../notes.txt", Typeflag: tar.TypeReg, Mode: 0644, Size: 4},
+		)
+
+		_, err := Restore(ctx, buf, dst, RestoreOptions{})
+		assert.ErrorContains(t, err, "invalid puck name")
+	})
+}
@@ -0,0 +1,267 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// MoveOptions controls Move
+type MoveOptions struct {
+	// Copy rather than move, leaving the old data directory as it was
+	KeepOld bool
+}
+
+// Move relocates the data directory in paths to dir: volume directories,
+// snapshots, the database and everything else in it. The daemon must not
+// be running, and pucks should be stopped so their volumes are at rest.
+// Entries are renamed when dir is on the same filesystem and copied
+// otherwise; if any fails, those already moved are put back. Recorded
+// paths are then rewritten, and every puck's container is recreated on
+// its next start so its mounts follow. Pucks given their own data
+// directory stay where they are. The report counts files copied.
+func Move(ctx context.Context, paths Paths, dir string, opts MoveOptions) (*Report, error) {
+	from := filepath.Clean(paths.DataDir)
+	to, err := MoveTarget(from, dir)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(from)
+	if err != nil {
+		return nil, fmt.Errorf("reading data directory: %w", err)
+	}
+	if err := os.MkdirAll(to, 0755); err != nil {
+		return nil, fmt.Errorf("creating %s: %w", to, err)
+	}
+
+	report := &Report{}
+	var renamed, copied []string
+	undo := func() {
+		for _, name := range renamed {
+			os.Rename(filepath.Join(to, name), filepath.Join(from, name))
+		}
+		for _, name := range copied {
+			os.RemoveAll(filepath.Join(to, name))
+		}
+	}
+	for _, e := range entries {
+		src, dst := filepath.Join(from, e.Name()), filepath.Join(to, e.Name())
+		if err := ctx.Err(); err != nil {
+			undo()
+			return nil, err
+		}
+		if !opts.KeepOld {
+			err := os.Rename(src, dst)
+			if err == nil {
+				renamed = append(renamed, e.Name())
+				continue
+			}
+			if !errors.Is(err, syscall.EXDEV) {
+				undo()
+				return nil, fmt.Errorf("moving %s: %w", src, err)
+			}
+		}
+		copied = append(copied, e.Name())
+		if err := copyTree(ctx, src, dst, report); err != nil {
+			undo()
+			return nil, fmt.Errorf("copying %s: %w", src, err)
+		}
+	}
+
+	dsn := paths.DatabaseURL
+	if paths.Database != "" {
+		dsn = rebasePath(paths.Database, from, to)
+	}
+	if dsn != "" {
+		if err := settleMove(ctx, dsn, from, to); err != nil {
+			undo()
+			return nil, err
+		}
+	}
+
+	// Only now is the old copy of anything safe to drop
+	if !opts.KeepOld {
+		for _, name := range copied {
+			if err := os.RemoveAll(filepath.Join(from, name)); err != nil {
+				report.Skipped = append(report.Skipped, filepath.Join(from, name))
+			}
+		}
+		os.Remove(from)
+	}
+	return report, nil
+}
+
+// MoveTarget checks where the data directory from may be moved to: an
+// absolute path outside it that is missing or an empty directory. It
+// returns the cleaned path.
+func MoveTarget(from, dir string) (string, error) {
+	if !filepath.IsAbs(dir) {
+		return "", fmt.Errorf("%q must be an absolute path", dir)
+	}
+	to := filepath.Clean(dir)
+	if to == from {
+		return "", fmt.Errorf("%s is already the data directory", to)
+	}
+	sep := string(os.PathSeparator)
+	if strings.HasPrefix(to, from+sep) || strings.HasPrefix(from, strings.TrimSuffix(to, sep)+sep) {
+		return "", fmt.Errorf("%s and the data directory %s must not be inside one another", to, from)
+	}
+	if _, err := os.Stat(from); err != nil {
+		return "", fmt.Errorf("data directory: %w", err)
+	}
+
+	info, err := os.Stat(to)
+	if errors.Is(err, fs.ErrNotExist) {
+		return to, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", to)
+	}
+	entries, err := os.ReadDir(to)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) > 0 {
+		return "", fmt.Errorf("%s is not empty", to)
+	}
+	return to, nil
+}
+
+// rebasePath returns where a path under from ends up under to
+func rebasePath(path, from, to string) string {
+	rel, err := filepath.Rel(from, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+		return path
+	}
+	return filepath.Join(to, rel)
+}
+
+// settleMove points the moved installation's records at its new data
+// directory. Containers are kept, so they can be removed when replaced.
+func settleMove(ctx context.Context, dsn, from, to string) error {
+	db, err := store.OpenDSN(dsn)
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	defer db.Close()
+
+	if err := db.RebaseDataDir(ctx, from, to); err != nil {
+		return err
+	}
+	return db.RecreateContainers(ctx)
+}
+
+// copyTree copies the file or directory at src to dst, keeping modes,
+// times, symlinks and, where allowed, ownership. Sockets and other special
+// files are left out.
+func copyTree(ctx context.Context, src, dst string, report *Report) error {
+	// Directories get their mode and times once their contents are in
+	var dirs []string
+	err := filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case info.IsDir():
+			if err := os.Mkdir(target, 0700); err != nil {
+				return err
+			}
+			dirs = append(dirs, rel)
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			if err := os.Symlink(link, target); err != nil {
+				return err
+			}
+		case info.Mode().IsRegular():
+			return copyFile(p, target, info, report)
+		default:
+			return nil
+		}
+		chown(target, info)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, rel := range slices.Backward(dirs) {
+		info, err := os.Stat(filepath.Join(src, rel))
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if err := os.Chmod(target, info.Mode()&(fs.ModePerm|fs.ModeSetgid|fs.ModeSticky)); err != nil {
+			return err
+		}
+		os.Chtimes(target, info.ModTime(), info.ModTime())
+	}
+	return nil
+}
+
+// copyFile copies one regular file
+func copyFile(src, dst string, info fs.FileInfo, report *Report) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_EXCL, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	report.Files++
+	report.Size += n
+
+	// The create mode is masked by the umask, and setuid and friends are
+	// cleared by chown, so the mode is set last
+	chown(dst, info)
+	if err := os.Chmod(dst, info.Mode()&(fs.ModePerm|fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky)); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+// chown gives a copy its original's owner. Only root, or a user inside
+// Podman's user namespace, may do so for files owned by others; anyone
+// else gets copies they own.
+func chown(path string, info fs.FileInfo) {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		os.Lchown(path, int(st.Uid), int(st.Gid))
+	}
+}
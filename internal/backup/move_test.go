@@ -0,0 +1,124 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupMove lays out a data directory holding one puck with a snapshot
+func setupMove(t *testing.T) Paths {
+	t.Helper()
+	ctx := context.Background()
+	paths := setupInstall(t, t.TempDir())
+
+	volumeDir := filepath.Join(paths.DataDir, "pucks", "desk")
+	writeFile(t, filepath.Join(volumeDir, "home", "notes.txt"), "hello", 0600)
+	writeFile(t, filepath.Join(volumeDir, "home", "run.sh"), "#!/bin/sh\n", 0755)
+	require.NoError(t, os.Symlink("notes.txt", filepath.Join(volumeDir, "home", "latest")))
+	snapshotPath := filepath.Join(paths.DataDir, "snapshots", "desk", "nightly.tar.gz")
+	writeFile(t, snapshotPath, "checkpoint-data", 0644)
+
+	db, err := store.Open(paths.Database)
+	require.NoError(t, err)
+	now := time.Now()
+	require.NoError(t, db.CreatePuck(ctx, &store.Puck{
+		ID: "puck-id", ContainerID: "container", Name: "desk", Image: "fedora:latest",
+		Status: store.StatusStopped, VolumeDir: volumeDir, HostPort: 9000, CreatedAt: now, UpdatedAt: now,
+	}))
+	require.NoError(t, db.CreateSnapshot(ctx, &store.Snapshot{
+		ID: "snap-id", PuckID: "puck-id", PuckName: "desk", Name: "nightly", Path: snapshotPath, CreatedAt: now,
+	}))
+	require.NoError(t, db.Close())
+	return paths
+}
+
+// checkMoved checks that the installation in paths now lives in dir
+func checkMoved(t *testing.T, dir string) {
+	t.Helper()
+	ctx := context.Background()
+
+	home := filepath.Join(dir, "pucks", "desk", "home")
+	data, err := os.ReadFile(filepath.Join(home, "notes.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+	info, err := os.Stat(filepath.Join(home, "run.sh"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+	link, err := os.Readlink(filepath.Join(home, "latest"))
+	require.NoError(t, err)
+	assert.Equal(t, "notes.txt", link)
+
+	db, err := store.Open(filepath.Join(dir, "puck.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	p, err := db.GetPuck(ctx, "desk")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "pucks", "desk"), p.VolumeDir)
+	assert.Equal(t, "container", p.ContainerID, "kept so it can be replaced")
+	assert.True(t, p.Resources.Pending, "recreated on next start")
+	s, err := db.GetSnapshot(ctx, "puck-id", "nightly")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "snapshots", "desk", "nightly.tar.gz"), s.Path)
+}
+
+func TestMove(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("moves the data directory", func(t *testing.T) {
+		paths := setupMove(t)
+		to := filepath.Join(t.TempDir(), "big", "puck")
+
+		_, err := Move(ctx, paths, to, MoveOptions{})
+		require.NoError(t, err)
+		checkMoved(t, to)
+		assert.NoDirExists(t, paths.DataDir)
+	})
+
+	t.Run("copies and keeps the old directory", func(t *testing.T) {
+		paths := setupMove(t)
+		to := t.TempDir()
+
+		report, err := Move(ctx, paths, to, MoveOptions{KeepOld: true})
+		require.NoError(t, err)
+		checkMoved(t, to)
+		assert.Positive(t, report.Files)
+		assert.FileExists(t, filepath.Join(paths.DataDir, "pucks", "desk", "home", "notes.txt"))
+	})
+
+	t.Run("copies across filesystems", func(t *testing.T) {
+		paths := setupMove(t)
+		dst := t.TempDir()
+		report := &Report{}
+		require.NoError(t, copyTree(ctx, paths.DataDir, filepath.Join(dst, "data"), report))
+		assert.Equal(t, 4, report.Files, "the database, two volume files and the snapshot")
+
+		info, err := os.Stat(filepath.Join(dst, "data", "pucks", "desk", "home", "notes.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	})
+
+	t.Run("rejects unusable targets", func(t *testing.T) {
+		paths := setupMove(t)
+		full := t.TempDir()
+		writeFile(t, filepath.Join(full, "other"), "", 0644)
+
+		for _, dir := range []string{
+			"relative/puck",
+			paths.DataDir,
+			filepath.Join(paths.DataDir, "inner"),
+			filepath.Dir(paths.DataDir),
+			full,
+		} {
+			_, err := Move(ctx, paths, dir, MoveOptions{})
+			assert.Error(t, err, dir)
+		}
+		assert.FileExists(t, paths.Database, "nothing moved")
+	})
+}
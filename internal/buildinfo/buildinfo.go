@@ -0,0 +1,39 @@
+// Package buildinfo describes the build puck and puckd were made from.
+package buildinfo
+
+import "runtime/debug"
+
+// Release builds set these with -ldflags -X. Other builds from a git
+// checkout take the commit and its time from the Go toolchain's stamp.
+var (
+	// Version is the puck release
+	Version = "0.1.0"
+	// Commit is the git commit built
+	Commit string
+	// Date is when the build was made, or the commit's time
+	Date string
+)
+
+func init() {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	for _, s := range info.Settings {
+		switch {
+		case s.Key == "vcs.revision" && Commit == "":
+			Commit = s.Value
+		case s.Key == "vcs.time" && Date == "":
+			Date = s.Value
+		}
+	}
+}
+
+// ShortCommit returns the first 12 characters of the commit, or "" if
+// it is unknown
+func ShortCommit() string {
+	if len(Commit) > 12 {
+		return Commit[:12]
+	}
+	return Commit
+}
@@ -0,0 +1,288 @@
+// Package chunkstore keeps files as content-addressed chunks, so files
+// that share most of their data, such as successive checkpoints of the
+// same puck, share most of their chunks on disk.
+//
+// Files are split with content-defined chunking: a boundary falls where a
+// rolling hash of the last bytes matches a pattern, so an insertion only
+// changes the chunks around it instead of shifting every later one. Each
+// chunk is stored once, gzipped, under its SHA-256, and a file is kept as
+// a manifest listing its chunks in order.
+package chunkstore
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Chunk sizes. Boundaries are looked for between minChunk and maxChunk
+// bytes, and fall every avgChunk bytes on average.
+const (
+	minChunk = 256 << 10
+	avgChunk = 1 << 20
+	maxChunk = 4 << 20
+)
+
+// boundaryMask picks boundaries one in avgChunk hashes apart
+const boundaryMask = avgChunk - 1
+
+// manifestVersion is written into manifests so the format can change
+const manifestVersion = 1
+
+// Manifest lists the chunks of a stored file, in order
+type Manifest struct {
+	Version int     `json:"version"`
+	Size    int64   `json:"size"`
+	Chunks  []Chunk `json:"chunks"`
+}
+
+// Chunk is a piece of a stored file
+type Chunk struct {
+	Hash string `json:"hash"` // hex SHA-256 of the uncompressed data
+	Size int64  `json:"size"`
+}
+
+// PutStats counts what storing a file wrote
+type PutStats struct {
+	Chunks  int   `json:"chunks"`
+	New     int   `json:"new"`     // chunks not already stored
+	Written int64 `json:"written"` // compressed bytes written for new chunks
+}
+
+// Store is a directory of chunks
+type Store struct {
+	dir string
+}
+
+// New returns the store of chunks in dir, which is created when the first
+// chunk is written
+func New(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Put splits r into chunks, writing the ones not already stored, and
+// returns the manifest to read it back with. Chunks already stored are
+// touched, so a Sweep racing with Put leaves them alone.
+func (s *Store) Put(r io.Reader) (*Manifest, PutStats, error) {
+	m := &Manifest{Version: manifestVersion, Chunks: []Chunk{}}
+	var stats PutStats
+
+	br := bufio.NewReader(r)
+	buf := make([]byte, 0, maxChunk)
+	for {
+		chunk, err := nextChunk(br, buf[:0])
+		if len(chunk) > 0 {
+			sum := sha256.Sum256(chunk)
+			hash := hex.EncodeToString(sum[:])
+			written, werr := s.writeChunk(hash, chunk)
+			if werr != nil {
+				return nil, stats, werr
+			}
+			stats.Chunks++
+			if written > 0 {
+				stats.New++
+				stats.Written += written
+			}
+			m.Chunks = append(m.Chunks, Chunk{Hash: hash, Size: int64(len(chunk))})
+			m.Size += int64(len(chunk))
+		}
+		if err == io.EOF {
+			return m, stats, nil
+		}
+		if err != nil {
+			return nil, stats, err
+		}
+	}
+}
+
+// nextChunk reads the next content-defined chunk from r into buf
+func nextChunk(r *bufio.Reader, buf []byte) ([]byte, error) {
+	var hash uint64
+	for len(buf) < maxChunk {
+		b, err := r.ReadByte()
+		if err != nil {
+			return buf, err
+		}
+		buf = append(buf, b)
+		hash = hash<<1 + gear[b]
+		if len(buf) >= minChunk && hash&boundaryMask == 0 {
+			return buf, nil
+		}
+	}
+	return buf, nil
+}
+
+// path is where a chunk is kept, fanned out by the first byte of its hash
+func (s *Store) path(hash string) string {
+	return filepath.Join(s.dir, hash[:2], hash)
+}
+
+// writeChunk stores a chunk unless it is already stored, returning the
+// bytes written
+func (s *Store) writeChunk(hash string, data []byte) (int64, error) {
+	path := s.path(hash)
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err == nil {
+		return 0, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, fmt.Errorf("creating chunk directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), hash+".*.tmp")
+	if err != nil {
+		return 0, fmt.Errorf("writing chunk: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	zw := gzip.NewWriter(tmp)
+	if _, err := zw.Write(data); err != nil {
+		tmp.Close()
+		return 0, fmt.Errorf("writing chunk: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		tmp.Close()
+		return 0, fmt.Errorf("writing chunk: %w", err)
+	}
+	info, err := tmp.Stat()
+	if err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, fmt.Errorf("writing chunk: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, fmt.Errorf("writing chunk: %w", err)
+	}
+	return info.Size(), nil
+}
+
+// Open returns the file a manifest describes. A chunk that is missing or
+// doesn't match its hash fails the read.
+func (s *Store) Open(m *Manifest) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(s.copyChunks(pw, m))
+	}()
+	return pr
+}
+
+// copyChunks writes a manifest's chunks to w in order, checking each
+func (s *Store) copyChunks(w io.Writer, m *Manifest) error {
+	for _, c := range m.Chunks {
+		if err := s.copyChunk(w, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyChunk writes one chunk to w
+func (s *Store) copyChunk(w io.Writer, c Chunk) error {
+	f, err := os.Open(s.path(c.Hash))
+	if err != nil {
+		return fmt.Errorf("reading chunk %s: %w", c.Hash, err)
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("reading chunk %s: %w", c.Hash, err)
+	}
+	sum := sha256.New()
+	n, err := io.Copy(io.MultiWriter(w, sum), zr)
+	if err != nil {
+		return fmt.Errorf("reading chunk %s: %w", c.Hash, err)
+	}
+	if n != c.Size || hex.EncodeToString(sum.Sum(nil)) != c.Hash {
+		return fmt.Errorf("chunk %s is corrupt", c.Hash)
+	}
+	return nil
+}
+
+// Sweep removes the chunks none of the manifests in keep use, returning
+// the bytes freed. Chunks written or touched within grace are kept, as a
+// Put may still be writing the manifest that uses them.
+func (s *Store) Sweep(keep []*Manifest, grace time.Duration) (int64, error) {
+	used := make(map[string]bool)
+	for _, m := range keep {
+		for _, c := range m.Chunks {
+			used[c.Hash] = true
+		}
+	}
+
+	cutoff := time.Now().Add(-grace)
+	var freed int64
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || used[d.Name()] {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		freed += info.Size()
+		return nil
+	})
+	return freed, err
+}
+
+// WriteManifest saves a manifest to path
+func WriteManifest(path string, m *Manifest) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("writing chunk manifest: %w", err)
+	}
+	return nil
+}
+
+// ReadManifest loads a manifest saved with WriteManifest
+func ReadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading chunk manifest: %w", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parsing chunk manifest %s: %w", path, err)
+	}
+	if m.Version != manifestVersion {
+		return nil, fmt.Errorf("chunk manifest %s has unknown version %d", path, m.Version)
+	}
+	return &m, nil
+}
+
+// gear maps each byte to a random 64-bit value for the rolling hash. It
+// is fixed, since changing it would move every boundary and stop new
+// files sharing chunks with stored ones.
+var gear = func() [256]uint64 {
+	var table [256]uint64
+	for i := range table {
+		sum := sha256.Sum256([]byte{byte(i)})
+		table[i] = binary.LittleEndian.Uint64(sum[:8])
+	}
+	return table
+}()
@@ -0,0 +1,116 @@
+package chunkstore
+
+import (
+	"bytes"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// randomData returns n bytes that are the same for the same seed
+func randomData(seed int64, n int) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+// readAll reads back a stored file
+func readAll(t *testing.T, s *Store, m *Manifest) []byte {
+	t.Helper()
+	rc := s.Open(m)
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	return data
+}
+
+func TestPut(t *testing.T) {
+	t.Run("reads back what was stored", func(t *testing.T) {
+		s := New(t.TempDir())
+		data := randomData(1, 5<<20)
+
+		m, stats, err := s.Put(bytes.NewReader(data))
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(data)), m.Size)
+		assert.Greater(t, len(m.Chunks), 1)
+		assert.Equal(t, len(m.Chunks), stats.New)
+		assert.Equal(t, data, readAll(t, s, m))
+	})
+
+	t.Run("stores empty files", func(t *testing.T) {
+		s := New(t.TempDir())
+
+		m, _, err := s.Put(bytes.NewReader(nil))
+		require.NoError(t, err)
+		assert.Empty(t, m.Chunks)
+		assert.Empty(t, readAll(t, s, m))
+	})
+
+	t.Run("shares chunks between files with an insertion", func(t *testing.T) {
+		s := New(t.TempDir())
+		data := randomData(2, 8<<20)
+		_, _, err := s.Put(bytes.NewReader(data))
+		require.NoError(t, err)
+
+		// A few bytes inserted near the start shift everything after them
+		changed := append(append(append([]byte{}, data[:1000]...), []byte("inserted")...), data[1000:]...)
+		m, stats, err := s.Put(bytes.NewReader(changed))
+		require.NoError(t, err)
+		assert.LessOrEqual(t, stats.New, 2)
+		assert.Greater(t, stats.Chunks, stats.New)
+		assert.Equal(t, changed, readAll(t, s, m))
+	})
+
+	t.Run("fails reads of corrupt chunks", func(t *testing.T) {
+		s := New(t.TempDir())
+		m, _, err := s.Put(bytes.NewReader(randomData(3, 1<<20)))
+		require.NoError(t, err)
+		require.NoError(t, os.Remove(s.path(m.Chunks[0].Hash)))
+
+		_, err = io.ReadAll(s.Open(m))
+		assert.ErrorContains(t, err, "reading chunk")
+	})
+}
+
+func TestSweep(t *testing.T) {
+	s := New(t.TempDir())
+	kept, _, err := s.Put(bytes.NewReader(randomData(4, 2<<20)))
+	require.NoError(t, err)
+	dropped, _, err := s.Put(bytes.NewReader(randomData(5, 2<<20)))
+	require.NoError(t, err)
+
+	t.Run("keeps recently written chunks", func(t *testing.T) {
+		freed, err := s.Sweep([]*Manifest{kept}, time.Hour)
+		require.NoError(t, err)
+		assert.Zero(t, freed)
+		assert.FileExists(t, s.path(dropped.Chunks[0].Hash))
+	})
+
+	t.Run("removes chunks no manifest uses", func(t *testing.T) {
+		freed, err := s.Sweep([]*Manifest{kept}, 0)
+		require.NoError(t, err)
+		assert.Positive(t, freed)
+		assert.NoFileExists(t, s.path(dropped.Chunks[0].Hash))
+		assert.Equal(t, randomData(4, 2<<20), readAll(t, s, kept))
+	})
+}
+
+func TestManifest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snap.chunks")
+	m := &Manifest{Version: manifestVersion, Size: 3, Chunks: []Chunk{{Hash: "abc", Size: 3}}}
+	require.NoError(t, WriteManifest(path, m))
+
+	read, err := ReadManifest(path)
+	require.NoError(t, err)
+	assert.Equal(t, m, read)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"version":99}`), 0644))
+	_, err = ReadManifest(path)
+	assert.ErrorContains(t, err, "unknown version")
+}
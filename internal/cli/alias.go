@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+var routeAliasCmd = &cobra.Command{
+	Use:   "alias <path> <name>",
+	Short: "Serve a puck at an extra router path",
+	Long: `Serve a puck at an extra path on the router, alongside /<name>.
+
+Pointing an existing alias at another puck swaps which puck serves it, so
+consumers of the path don't need to change their URLs. Aliases take
+precedence over puck routes and only work while their puck is running.
+Removing a puck removes its aliases.
+
+Examples:
+  puck route alias /api backend-v1
+  puck route alias /api backend-v2     # swap without changing the URL
+  puck route alias list
+  puck route alias remove /api`,
+	Args: cobra.ExactArgs(2),
+	RunE: runRouteAlias,
+}
+
+var routeAliasListCmd = &cobra.Command{
+	Use:     "list [puck]",
+	Aliases: []string{"ls"},
+	Short:   "List route aliases",
+	Args:    cobra.MaximumNArgs(1),
+	RunE:    runRouteAliasList,
+}
+
+var routeAliasRemoveCmd = &cobra.Command{
+	Use:     "remove <path>",
+	Aliases: []string{"rm"},
+	Short:   "Remove a route alias",
+	Args:    cobra.ExactArgs(1),
+	RunE:    runRouteAliasRemove,
+}
+
+var routeAliasFormat string
+
+func init() {
+	addFormatFlag(routeAliasListCmd, &routeAliasFormat)
+
+	routeAliasCmd.AddCommand(routeAliasListCmd)
+	routeAliasCmd.AddCommand(routeAliasRemoveCmd)
+
+	routeCmd.AddCommand(routeAliasCmd)
+}
+
+func runRouteAlias(cmd *cobra.Command, args []string) error {
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	alias, err := client.AliasSet(args[0], args[1])
+	if err != nil {
+		return err
+	}
+
+	infof("Routing %s to puck '%s'", alias.Path, alias.PuckName)
+	return nil
+}
+
+func runRouteAliasList(cmd *cobra.Command, args []string) error {
+	var name string
+	if len(args) > 0 {
+		name = args[0]
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	aliases, err := client.AliasList(name)
+	if err != nil {
+		return err
+	}
+
+	if routeAliasFormat != "" {
+		return printFormatted(routeAliasFormat, aliases, nil)
+	}
+	if len(aliases) == 0 {
+		infof("No route aliases. Create one with: puck route alias <path> <name>")
+		return nil
+	}
+	if quiet {
+		for _, a := range aliases {
+			fmt.Println(a.Path)
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PATH\tPUCK\tSET\tSET BY")
+	for _, a := range aliases {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", a.Path, a.PuckName, humanize.Time(a.CreatedAt), a.CreatedBy)
+	}
+	return w.Flush()
+}
+
+func runRouteAliasRemove(cmd *cobra.Command, args []string) error {
+	path := args[0]
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	if err := client.AliasRemove(path); err != nil {
+		return err
+	}
+
+	infof("Removed route alias %s", path)
+	return nil
+}
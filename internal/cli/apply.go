@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"errors"
+	"path/filepath"
+
+	"github.com/charmbracelet/log"
+	"github.com/sandwich-labs/puck/internal/project"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Create the puck described by puck.yaml",
+	Long: `Create the puck described by a puck.yaml (see 'puck init'), or start it if
+it exists but is stopped. Mount sources are relative to the file's
+directory.
+
+An existing puck is not changed to match the file; use 'puck set' or
+'puck recreate' for that.`,
+	Args: cobra.NoArgs,
+	RunE: runApply,
+}
+
+var applyFileFlag string
+
+func init() {
+	applyCmd.Flags().StringVarP(&applyFileFlag, "file", "f", project.FileName, "project file to apply")
+}
+
+func runApply(cmd *cobra.Command, args []string) error {
+	return applyFile(applyFileFlag)
+}
+
+// applyFile creates or starts the puck a project file describes
+func applyFile(file string) error {
+	spec, err := project.Load(file)
+	if err != nil {
+		return err
+	}
+	opts, err := spec.CreateOptions(filepath.Dir(file))
+	if err != nil {
+		return err
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	p, err := client.Get(spec.Name)
+	if err == nil {
+		if p.Status.Up() {
+			infof("Puck '%s' already exists and is %s", p.Name, p.Status)
+			return nil
+		}
+		if err := client.Start(p.Name); err != nil {
+			return err
+		}
+		infof("Started puck '%s'", p.Name)
+		return nil
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+
+	log.Info("Creating puck", "name", opts.Name, "image", opts.Image)
+	endProgress := showPullProgress(client)
+	p, err = client.Create(opts)
+	endProgress()
+	if err != nil {
+		return err
+	}
+
+	printCreated(client, p)
+	return nil
+}
@@ -0,0 +1,164 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dustin/go-humanize"
+	"github.com/sandwich-labs/puck/internal/backup"
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var backupAllCmd = &cobra.Command{
+	Use:   "backup-all",
+	Short: "Back up the whole puck installation",
+	Long: `Back up the puck installation on this host to a tar archive: the
+database, config files, hooks, landing page, share key and every puck's
+volume directory. With --snapshots the snapshot archives are included too.
+
+Containers are not backed up; restored pucks get new ones from their
+images when they are next started. The daemon may keep running, but busy
+pucks are best stopped or snapshotted first so their volumes are copied
+at rest. A Postgres database is not included; back it up with pg_dump.
+
+Examples:
+  puck backup-all -o backup.tar
+  puck backup-all -o backup.tar --snapshots`,
+	Args: cobra.NoArgs,
+	RunE: runBackupAll,
+}
+
+var restoreAllCmd = &cobra.Command{
+	Use:   "restore-all <backup.tar>",
+	Short: "Restore a whole puck installation from a backup",
+	Long: `Restore a backup made with 'puck backup-all', such as on a new machine.
+
+The daemon must be stopped. Volume and snapshot paths are moved to this
+host's data directory, and every puck comes back stopped, or checkpointed
+if its snapshot was included; 'puck start' builds a new container for it.
+An existing installation is only replaced with --force.
+
+Examples:
+  puck restore-all backup.tar
+  ssh oldbox puck backup-all -o - | puck restore-all -`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRestoreAll,
+}
+
+var (
+	backupOutput    string
+	backupSnapshots bool
+	restoreForce    bool
+)
+
+func init() {
+	backupAllCmd.Flags().StringVarP(&backupOutput, "output", "o", "", "file to write the backup to, or - for stdout")
+	backupAllCmd.Flags().BoolVar(&backupSnapshots, "snapshots", false, "include snapshot archives")
+	backupAllCmd.MarkFlagRequired("output")
+
+	restoreAllCmd.Flags().BoolVar(&restoreForce, "force", false, "replace an existing installation")
+}
+
+// localPaths returns where this host's installation lives. Backups work on
+// files directly, so they only make sense for the local daemon.
+func localPaths(action string) (backup.Paths, error) {
+	active, err := config.ActiveContext()
+	if err != nil {
+		return backup.Paths{}, err
+	}
+	if active.Type != config.ContextUnix {
+		return backup.Paths{}, fmt.Errorf("%s works on this host's files; run it on %s instead", action, active.Endpoint())
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return backup.Paths{}, err
+	}
+	return backup.PathsFor(cfg, viper.ConfigFileUsed()), nil
+}
+
+func runBackupAll(cmd *cobra.Command, args []string) error {
+	paths, err := localPaths("backup-all")
+	if err != nil {
+		return err
+	}
+	if paths.Database == "" {
+		fmt.Fprintln(os.Stderr, "Warning: the Postgres database is not included; back it up with pg_dump")
+	}
+
+	if backupOutput == "-" {
+		report, err := backup.Create(context.Background(), os.Stdout, paths, backup.Options{Snapshots: backupSnapshots})
+		if err != nil {
+			return fmt.Errorf("writing backup: %w", err)
+		}
+		printSkipped(report)
+		return nil
+	}
+
+	// Write beside the destination and move into place once complete
+	tmp, err := os.CreateTemp(filepath.Dir(backupOutput), ".puck-backup-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	report, err := backup.Create(context.Background(), tmp, paths, backup.Options{Snapshots: backupSnapshots})
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("writing backup: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), backupOutput); err != nil {
+		return err
+	}
+
+	printSkipped(report)
+	infof("Backed up %d files (%s) to %s", report.Files, humanize.Bytes(uint64(report.Size)), backupOutput)
+	return nil
+}
+
+// printSkipped warns about files a backup had to leave out
+func printSkipped(report *backup.Report) {
+	for _, path := range report.Skipped {
+		fmt.Fprintf(os.Stderr, "Warning: could not read %s\n", path)
+	}
+}
+
+func runRestoreAll(cmd *cobra.Command, args []string) error {
+	paths, err := localPaths("restore-all")
+	if err != nil {
+		return err
+	}
+
+	// The daemon holds the database open and would overwrite what is
+	// restored
+	if client, err := newClient(); err == nil && client.Ping() == nil {
+		return fmt.Errorf("the daemon is running; stop it first (systemctl --user stop puckd)")
+	}
+
+	// "-" reads the backup from stdin, as puck migrate-host sends it
+	in := os.Stdin
+	if args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	report, err := backup.Restore(context.Background(), in, paths, backup.RestoreOptions{Force: restoreForce})
+	if err != nil {
+		return err
+	}
+
+	m := report.Manifest
+	infof("Restored %d files (%s) from %s, backed up %s", report.Files, humanize.Bytes(uint64(report.Size)), valueOr(m.Host, "unknown host"), m.CreatedAt.Local().Format("2006-01-02 15:04"))
+	infof("Start the daemon with 'puck daemon start', then 'puck start <name>' to bring each puck back.")
+	return nil
+}
@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"sync"
+
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/sandwich-labs/puck/internal/daemon"
+	"github.com/spf13/viper"
+)
+
+// activeClients caches the clients newClient made by --context, so a
+// command that makes several clients loads the config, the contexts and
+// a TLS context's certificates once
+var (
+	activeClientsMu sync.Mutex
+	activeClients   = map[string]*daemon.Client{}
+)
+
+// newClient creates a client for the active context: the one chosen with
+// --context, else the current context, else the local daemon
+func newClient() (*daemon.Client, error) {
+	override := viper.GetString("context")
+	activeClientsMu.Lock()
+	defer activeClientsMu.Unlock()
+
+	client, ok := activeClients[override]
+	if !ok {
+		c, err := config.ActiveContext()
+		if err != nil {
+			return nil, err
+		}
+		if client, err = daemon.NewClientForContext(c); err != nil {
+			return nil, err
+		}
+		activeClients[override] = client
+	}
+	// Callers set their own progress reporting on the client
+	copied := *client
+	return &copied, nil
+}
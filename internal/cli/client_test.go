@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewClientReusesContext(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	viper.Set("context", "box")
+	t.Cleanup(func() {
+		viper.Set("context", "")
+		activeClientsMu.Lock()
+		delete(activeClients, "box")
+		activeClientsMu.Unlock()
+	})
+
+	write := func(socket string) {
+		cs := &config.Contexts{Contexts: map[string]config.Context{"box": {Type: config.ContextUnix, Socket: socket}}}
+		require.NoError(t, cs.Save(config.ContextsPath()))
+	}
+	write("/run/first.sock")
+	first, err := newClient()
+	require.NoError(t, err)
+	assert.ErrorContains(t, first.Ping(), "/run/first.sock")
+
+	// A second client doesn't read the contexts again, but is its own
+	write("/run/second.sock")
+	second, err := newClient()
+	require.NoError(t, err)
+	assert.NotSame(t, first, second)
+	assert.ErrorContains(t, second.Ping(), "/run/first.sock")
+}
@@ -0,0 +1,213 @@
+package cli
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/sandwich-labs/puck/internal/project"
+	"github.com/sandwich-labs/puck/internal/sshconfig"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var codeCmd = &cobra.Command{
+	Use:   "code <name>",
+	Short: "Open a puck in VS Code",
+	Long: `Start a puck if needed and open it in VS Code over Remote - SSH.
+
+An entry for the puck is written to ~/.ssh/puck_config, which is included
+from ~/.ssh/config, so 'ssh <name>.puck' works too. The connection runs
+sshd inside the puck over the puck's exec session, installing
+openssh-server on first use if the image lacks it, and logs in as root
+with a key generated in ~/.ssh/puck_ed25519.
+
+With --container the puck is attached with the Dev Containers extension
+instead, which needs it to be configured for podman (dev.containers.dockerPath).
+
+Without the 'code' command on PATH, or with --print, the folder URI is
+printed instead of launching VS Code.
+
+Examples:
+  puck code api
+  puck code api --path /srv/app
+  puck code api --print`,
+	Args: cobra.ExactArgs(1),
+	RunE: runCode,
+}
+
+var sshProxyCmd = &cobra.Command{
+	Use:    "ssh-proxy <name>",
+	Short:  "Serve an ssh connection to a puck over stdin and stdout",
+	Long:   `Serve an ssh connection to a puck over stdin and stdout. Used as the ProxyCommand of the ssh entries puck code writes.`,
+	Hidden: true,
+	Args:   cobra.ExactArgs(1),
+	RunE:   runSSHProxy,
+}
+
+var (
+	codePath      string
+	codePrint     bool
+	codeContainer bool
+
+	sshProxyKey string
+)
+
+func init() {
+	codeCmd.Flags().StringVar(&codePath, "path", "", "folder to open (default: /workspace if mounted, else /root)")
+	codeCmd.Flags().BoolVar(&codePrint, "print", false, "print the folder URI instead of launching VS Code")
+	codeCmd.Flags().BoolVar(&codeContainer, "container", false, "attach with Dev Containers instead of Remote - SSH")
+
+	sshProxyCmd.Flags().StringVar(&sshProxyKey, "authorized-key", "", "public key to allow (default: ~/.ssh/puck_ed25519.pub)")
+}
+
+func runCode(cmd *cobra.Command, args []string) error {
+	name, err := selectContext(args[0])
+	if err != nil {
+		return err
+	}
+
+	active, err := config.ActiveContext()
+	if err != nil {
+		return err
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	p, err := client.Get(name)
+	if err != nil {
+		return err
+	}
+	if !p.Status.Up() {
+		if err := client.Start(name); err != nil {
+			return err
+		}
+		infof("Started puck '%s'", name)
+	}
+
+	folder := codePath
+	if folder == "" {
+		folder = codeFolder(p)
+	}
+
+	var uri string
+	if codeContainer {
+		if active.Type != config.ContextUnix {
+			return fmt.Errorf("--container needs the puck on this machine; leave it out to connect over ssh")
+		}
+		target, _ := json.Marshal(map[string]string{"containerName": "/" + p.Name})
+		uri = "vscode-remote://attached-container+" + hex.EncodeToString(target) + folder
+	} else {
+		host, err := writeSSHEntry(active, name)
+		if err != nil {
+			return err
+		}
+		infof("Wrote ssh entry '%s' to %s", host, filepath.Join(sshconfig.DefaultDir(), sshconfig.FileName))
+		uri = "vscode-remote://ssh-remote+" + host + folder
+	}
+
+	if _, err := exec.LookPath("code"); err != nil || codePrint {
+		fmt.Println(uri)
+		if !codePrint {
+			infof("Open it with: code --folder-uri %s", uri)
+		}
+		return nil
+	}
+
+	code := exec.Command("code", "--folder-uri", uri)
+	code.Stdout = os.Stdout
+	code.Stderr = os.Stderr
+	return code.Run()
+}
+
+// codeFolder returns the folder to open in a puck: the project mounted by
+// puck init, or root's home
+func codeFolder(p *store.Puck) string {
+	for _, m := range p.Spec.Mounts {
+		if m.Target == project.WorkspaceDir {
+			return m.Target
+		}
+	}
+	return "/root"
+}
+
+// writeSSHEntry writes the ssh config entry that reaches a puck in the
+// given context and returns its host name
+func writeSSHEntry(active config.Context, name string) (string, error) {
+	e, err := sshEntry(active, name)
+	if err != nil {
+		return "", err
+	}
+	return e.Host, sshconfig.Write(sshconfig.DefaultDir(), e)
+}
+
+// sshEntry returns the ssh config entry that reaches a puck in the given
+// context, generating puck's key if there isn't one yet
+func sshEntry(active config.Context, name string) (sshconfig.Entry, error) {
+	key, err := sshconfig.EnsureKey(sshconfig.DefaultDir())
+	if err != nil {
+		return sshconfig.Entry{}, err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return sshconfig.Entry{}, err
+	}
+
+	host := name + ".puck"
+	if active.Name != config.DefaultContext {
+		host = name + "." + active.Name + ".puck"
+	}
+	return sshconfig.Entry{
+		Host:         host,
+		User:         "root",
+		IdentityFile: key,
+		ProxyCommand: fmt.Sprintf("%q --context %s ssh-proxy %s", exe, active.Name, name),
+	}, nil
+}
+
+func runSSHProxy(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	key := sshProxyKey
+	if key == "" {
+		var err error
+		if key, err = sshconfig.PublicKey(sshconfig.DefaultDir()); err != nil {
+			return err
+		}
+	}
+
+	active, err := config.ActiveContext()
+	if err != nil {
+		return err
+	}
+	switch active.Type {
+	case config.ContextSSH:
+		// The key is quoted for the remote shell; keys have no single quotes
+		ssh := exec.Command("ssh", "-T", active.Host, "--", "puck", "ssh-proxy", name,
+			"--authorized-key", "'"+strings.ReplaceAll(key, "'", "")+"'")
+		ssh.Stdin = os.Stdin
+		ssh.Stdout = os.Stdout
+		ssh.Stderr = os.Stderr
+		return ssh.Run()
+	case config.ContextTCP, config.ContextTailnet:
+		return fmt.Errorf("ssh is not available over %s contexts; add an ssh context for %s", active.Type, active.Address)
+	}
+
+	ctx := context.Background()
+	mgr, closeMgr, err := localManager(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeMgr()
+
+	return mgr.SSHProxy(ctx, name, key)
+}
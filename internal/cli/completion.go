@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/sandwich-labs/puck/internal/sshconfig"
+	"github.com/spf13/cobra"
+)
+
+var sshConfigCompletionCmd = &cobra.Command{
+	Use:   "ssh-config",
+	Short: "Print ssh host entries for every puck",
+	Long: `Print an ssh config Host entry for every puck in the active context,
+named as puck code names them: <name>.puck, or <name>.<context>.puck
+outside the default context. Shells complete ssh hosts from the ssh
+config, so with the entries written 'ssh <TAB>' offers your pucks.
+
+With --write the entries are added to ~/.ssh/puck_config, which is
+included from ~/.ssh/config, instead of printed. Run it again after
+creating pucks to pick them up.
+
+Examples:
+  puck completion ssh-config --write
+  puck completion ssh-config --context staging >> ~/.ssh/config`,
+	Args: cobra.NoArgs,
+	RunE: runSSHConfigCompletion,
+}
+
+var sshConfigWrite bool
+
+func init() {
+	sshConfigCompletionCmd.Flags().BoolVar(&sshConfigWrite, "write", false, "add the entries to ~/.ssh/puck_config instead of printing them")
+}
+
+func runSSHConfigCompletion(cmd *cobra.Command, args []string) error {
+	active, err := config.ActiveContext()
+	if err != nil {
+		return err
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	pucks, err := client.List()
+	if err != nil {
+		return err
+	}
+
+	for i, p := range pucks {
+		e, err := sshEntry(active, p.Name)
+		if err != nil {
+			return err
+		}
+		if sshConfigWrite {
+			if err := sshconfig.Write(sshconfig.DefaultDir(), e); err != nil {
+				return err
+			}
+			continue
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Print(e.String())
+	}
+
+	if sshConfigWrite {
+		infof("Wrote %d ssh entries to %s", len(pucks), filepath.Join(sshconfig.DefaultDir(), sshconfig.FileName))
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage puck configuration",
+}
+
+var configSharesCmd = &cobra.Command{
+	Use:   "shares",
+	Short: "Manage host directories shared with new pucks",
+	Long: `Manage host directories that are mounted into every new puck, so common
+project data is available without repeating mount options.
+
+Shared paths are stored under shared_paths in the config file and are
+picked up by the local daemon without a restart. Existing pucks keep the
+mounts they were created with; recreate a puck to pick up changes. Paths
+are mounted at /mnt/<name> unless --target says otherwise.
+
+Examples:
+  puck config shares add ~/src
+  puck config shares add ~/Downloads --read-only
+  puck config shares add ~/work/datasets --target /data
+  puck config shares rm ~/Downloads`,
+	Args: cobra.NoArgs,
+	RunE: runConfigSharesList,
+}
+
+var configSharesAddCmd = &cobra.Command{
+	Use:   "add <path>",
+	Short: "Share a host directory with new pucks",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runConfigSharesAdd,
+}
+
+var configSharesRemoveCmd = &cobra.Command{
+	Use:     "remove <path>",
+	Aliases: []string{"rm"},
+	Short:   "Stop sharing a host directory with new pucks",
+	Args:    cobra.ExactArgs(1),
+	RunE:    runConfigSharesRemove,
+}
+
+var (
+	sharesTarget   string
+	sharesReadOnly bool
+)
+
+func init() {
+	configSharesAddCmd.Flags().StringVar(&sharesTarget, "target", "", "path inside pucks (default: /mnt/<name>)")
+	configSharesAddCmd.Flags().BoolVar(&sharesReadOnly, "read-only", false, "mount read-only")
+
+	configSharesCmd.AddCommand(configSharesAddCmd)
+	configSharesCmd.AddCommand(configSharesRemoveCmd)
+	configCmd.AddCommand(configSharesCmd)
+}
+
+// configFile returns the config file in use, or where one would be created
+func configFile() string {
+	if f := viper.ConfigFileUsed(); f != "" {
+		return f
+	}
+	return config.ConfigPath()
+}
+
+// absSharedPath resolves a host path given on the command line
+func absSharedPath(p string) (string, error) {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return "", fmt.Errorf("resolving %s: %w", p, err)
+	}
+	return abs, nil
+}
+
+func runConfigSharesList(cmd *cobra.Command, args []string) error {
+	shared, err := config.ReadSharedPaths(configFile())
+	if err != nil {
+		return err
+	}
+	if len(shared) == 0 {
+		infof("No shared paths. Add one with: puck config shares add <path>")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PATH\tTARGET\tMODE")
+	for _, s := range shared {
+		mode := "read-write"
+		if s.ReadOnly {
+			mode = "read-only"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", s.Path, s.Destination(), mode)
+	}
+	return w.Flush()
+}
+
+func runConfigSharesAdd(cmd *cobra.Command, args []string) error {
+	path, err := absSharedPath(args[0])
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", path)
+	}
+
+	file := configFile()
+	shared, err := config.ReadSharedPaths(file)
+	if err != nil {
+		return err
+	}
+	s := config.SharedPath{Path: path, Target: sharesTarget, ReadOnly: sharesReadOnly}
+	shared, err = config.AddSharedPath(shared, s)
+	if err != nil {
+		return err
+	}
+	if err := config.WriteSharedPaths(file, shared); err != nil {
+		return err
+	}
+
+	infof("Sharing %s at %s in new pucks", path, s.Destination())
+	return nil
+}
+
+func runConfigSharesRemove(cmd *cobra.Command, args []string) error {
+	path, err := absSharedPath(args[0])
+	if err != nil {
+		return err
+	}
+
+	file := configFile()
+	shared, err := config.ReadSharedPaths(file)
+	if err != nil {
+		return err
+	}
+	shared, err = config.RemoveSharedPath(shared, path)
+	if err != nil {
+		return err
+	}
+	if err := config.WriteSharedPaths(file, shared); err != nil {
+		return err
+	}
+
+	infof("Stopped sharing %s with new pucks", path)
+	return nil
+}
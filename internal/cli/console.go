@@ -3,6 +3,8 @@ package cli
 import (
 	"context"
 	"fmt"
+	"os"
+	"os/exec"
 
 	"github.com/spf13/cobra"
 	"github.com/sandwich-labs/puck/internal/config"
@@ -22,32 +24,71 @@ var consoleCmd = &cobra.Command{
 var consoleShell string
 
 func init() {
-	consoleCmd.Flags().StringVarP(&consoleShell, "shell", "s", "/bin/bash", "shell to use")
+	consoleCmd.Flags().StringVarP(&consoleShell, "shell", "s", "", "shell to use (default the puck's, /bin/bash, or /bin/sh for pucks without systemd)")
 }
 
 func runConsole(cmd *cobra.Command, args []string) error {
-	name := args[0]
+	name, err := selectContext(args[0])
+	if err != nil {
+		return err
+	}
+
+	active, err := config.ActiveContext()
+	if err != nil {
+		return err
+	}
+	switch active.Type {
+	case config.ContextSSH:
+		return execStatus(remoteConsole(active.Host, name))
+	case config.ContextTCP, config.ContextTailnet:
+		return fmt.Errorf("console is not available over %s contexts; add an ssh context for %s", active.Type, active.Address)
+	}
 
 	// Console needs direct access to podman for interactive exec
 	// so we bypass the daemon for this command
-	cfg, err := config.Load()
+	ctx := context.Background()
+	mgr, closeMgr, err := localManager(ctx)
 	if err != nil {
 		return err
 	}
+	defer closeMgr()
+
+	// Exit with the shell's status, as podman exec would
+	return execStatus(mgr.Console(ctx, name, consoleShell))
+}
+
+// localManager opens podman and the database directly, for commands
+// that need the terminal or stdio of an exec session the daemon can't
+// hand over. The returned func closes the database.
+func localManager(ctx context.Context) (*puck.Manager, func(), error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, nil, err
+	}
 
-	ctx := context.Background()
 	pc, err := podman.NewClient(ctx, cfg.PodmanSocket)
 	if err != nil {
-		return fmt.Errorf("connecting to podman: %w", err)
+		return nil, nil, fmt.Errorf("connecting to podman: %w", err)
 	}
 
-	db, err := store.Open(cfg.DatabasePath())
+	db, err := store.OpenDSN(cfg.DatabaseDSN())
 	if err != nil {
-		return fmt.Errorf("opening database: %w", err)
+		return nil, nil, fmt.Errorf("opening database: %w", err)
 	}
-	defer db.Close()
 
-	mgr := puck.NewManager(cfg, pc, db)
+	return puck.NewManager(cfg, pc, db), func() { db.Close() }, nil
+}
 
-	return mgr.Console(ctx, name, consoleShell)
+// remoteConsole runs the console on the context's host over an ssh session
+// with a terminal
+func remoteConsole(host, name string) error {
+	sshArgs := []string{"-t", host, "--", "puck", "console", name}
+	if consoleShell != "" {
+		sshArgs = append(sshArgs, "--shell", consoleShell)
+	}
+	ssh := exec.Command("ssh", sshArgs...)
+	ssh.Stdin = os.Stdin
+	ssh.Stdout = os.Stdout
+	ssh.Stderr = os.Stderr
+	return ssh.Run()
 }
@@ -0,0 +1,270 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var contextCmd = &cobra.Command{
+	Use:   "context",
+	Short: "Manage daemon contexts",
+	Long: `Manage named daemons the CLI can talk to, so one CLI can drive pucks on
+several machines.
+
+The "default" context is the local daemon socket. Other contexts reach a
+daemon over ssh (the remote host needs puck installed), over TCP with
+mutual TLS (the daemon needs daemon_listen configured), or at its own
+tailnet node (the daemon needs tailnet configured). Any command can
+target a context with --context, or PUCK_CONTEXT. start, stop and console
+also accept context-qualified names such as homelab/web.
+
+Examples:
+  puck context add homelab --ssh me@homelab
+  puck context add desktop --tcp desktop:7443 --ca ca.pem --cert me.pem --key me-key.pem
+  puck context add office --tailnet puck-api.tail1234.ts.net
+  puck context use homelab
+  puck list --context default
+  puck start homelab/web
+  puck list --all-contexts`,
+}
+
+var contextListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List contexts",
+	Args:    cobra.NoArgs,
+	RunE:    runContextList,
+}
+
+var contextShowCmd = &cobra.Command{
+	Use:   "show [name]",
+	Short: "Show the active context, or a named one",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  runContextShow,
+}
+
+var contextUseCmd = &cobra.Command{
+	Use:   "use <name>",
+	Short: "Set the current context",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runContextUse,
+}
+
+var contextAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Add or replace a context",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runContextAdd,
+}
+
+var contextRemoveCmd = &cobra.Command{
+	Use:     "remove <name>",
+	Aliases: []string{"rm"},
+	Short:   "Remove a context",
+	Args:    cobra.ExactArgs(1),
+	RunE:    runContextRemove,
+}
+
+var (
+	contextSocket string
+	contextSSH    string
+	contextTCP    string
+	contextTS     string
+	contextCA     string
+	contextCert   string
+	contextKey    string
+)
+
+func init() {
+	contextAddCmd.Flags().StringVar(&contextSocket, "socket", "", "path to a daemon socket on this machine")
+	contextAddCmd.Flags().StringVar(&contextSSH, "ssh", "", "reach the daemon over ssh, e.g. me@homelab")
+	contextAddCmd.Flags().StringVar(&contextTCP, "tcp", "", "reach the daemon's TLS listener at host:port")
+	contextAddCmd.Flags().StringVar(&contextTS, "tailnet", "", "reach the daemon's tailnet node, e.g. puck-api.tail1234.ts.net")
+	contextAddCmd.Flags().StringVar(&contextCA, "ca", "", "CA certificate for the daemon (tcp; default system roots)")
+	contextAddCmd.Flags().StringVar(&contextCert, "cert", "", "client certificate (tcp)")
+	contextAddCmd.Flags().StringVar(&contextKey, "key", "", "client key (tcp)")
+	contextAddCmd.MarkFlagsMutuallyExclusive("socket", "ssh", "tcp", "tailnet")
+	contextAddCmd.MarkFlagsOneRequired("socket", "ssh", "tcp", "tailnet")
+
+	contextCmd.AddCommand(contextListCmd)
+	contextCmd.AddCommand(contextShowCmd)
+	contextCmd.AddCommand(contextUseCmd)
+	contextCmd.AddCommand(contextAddCmd)
+	contextCmd.AddCommand(contextRemoveCmd)
+}
+
+// loadContexts reads the contexts file alongside the config needed for
+// the default context
+func loadContexts() (*config.Contexts, *config.Config, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, nil, err
+	}
+	cs, err := config.LoadContexts(config.ContextsPath())
+	if err != nil {
+		return nil, nil, err
+	}
+	return cs, cfg, nil
+}
+
+func runContextList(cmd *cobra.Command, args []string) error {
+	cs, cfg, err := loadContexts()
+	if err != nil {
+		return err
+	}
+
+	if quiet {
+		for _, name := range cs.Names() {
+			fmt.Println(name)
+		}
+		return nil
+	}
+
+	active := cs.ActiveName()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTYPE\tENDPOINT")
+	for _, name := range cs.Names() {
+		c, err := cs.Get(name, cfg)
+		if err != nil {
+			return err
+		}
+		marker := ""
+		if name == active {
+			marker = " *"
+		}
+		fmt.Fprintf(w, "%s%s\t%s\t%s\n", name, marker, c.Type, c.Endpoint())
+	}
+
+	return w.Flush()
+}
+
+func runContextShow(cmd *cobra.Command, args []string) error {
+	cs, cfg, err := loadContexts()
+	if err != nil {
+		return err
+	}
+
+	name := cs.ActiveName()
+	if len(args) > 0 {
+		name = args[0]
+	}
+	c, err := cs.Get(name, cfg)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Name:\t%s\n", c.Name)
+	fmt.Fprintf(w, "Type:\t%s\n", c.Type)
+	fmt.Fprintf(w, "Endpoint:\t%s\n", c.Endpoint())
+	if c.Type == config.ContextTCP {
+		fmt.Fprintf(w, "CA:\t%s\n", valueOr(c.CACert, "(system roots)"))
+		fmt.Fprintf(w, "Certificate:\t%s\n", c.ClientCert)
+		fmt.Fprintf(w, "Key:\t%s\n", c.ClientKey)
+	}
+
+	return w.Flush()
+}
+
+func runContextUse(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	cs, err := config.LoadContexts(config.ContextsPath())
+	if err != nil {
+		return err
+	}
+	if err := cs.Use(name); err != nil {
+		return err
+	}
+	if err := cs.Save(config.ContextsPath()); err != nil {
+		return err
+	}
+
+	infof("Now using context '%s'", name)
+	return nil
+}
+
+func runContextAdd(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	c := config.Context{Type: config.ContextUnix, Socket: contextSocket}
+	switch {
+	case contextSSH != "":
+		c = config.Context{Type: config.ContextSSH, Host: contextSSH}
+	case contextTCP != "":
+		c = config.Context{
+			Type:       config.ContextTCP,
+			Address:    contextTCP,
+			CACert:     contextCA,
+			ClientCert: contextCert,
+			ClientKey:  contextKey,
+		}
+	case contextTS != "":
+		c = config.Context{Type: config.ContextTailnet, Address: contextTS}
+	}
+
+	cs, err := config.LoadContexts(config.ContextsPath())
+	if err != nil {
+		return err
+	}
+	if err := cs.Add(name, c); err != nil {
+		return err
+	}
+	if err := cs.Save(config.ContextsPath()); err != nil {
+		return err
+	}
+
+	infof("Added context '%s' (%s)", name, c.Endpoint())
+	infof("Use it with: puck context use %s", name)
+	return nil
+}
+
+func runContextRemove(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	cs, err := config.LoadContexts(config.ContextsPath())
+	if err != nil {
+		return err
+	}
+	if err := cs.Remove(name); err != nil {
+		return err
+	}
+	if err := cs.Save(config.ContextsPath()); err != nil {
+		return err
+	}
+
+	infof("Removed context '%s'", name)
+	return nil
+}
+
+// selectContext accepts a context-qualified puck name such as homelab/web,
+// switching the command to that context and returning the bare name
+func selectContext(arg string) (string, error) {
+	ctxName, name, ok := strings.Cut(arg, "/")
+	if !ok {
+		return arg, nil
+	}
+	if ctxName == "" || name == "" {
+		return "", fmt.Errorf("invalid puck name %q (expected <context>/<name>)", arg)
+	}
+	if current := viper.GetString("context"); current != "" && current != ctxName {
+		return "", fmt.Errorf("puck %q conflicts with --context %s", arg, current)
+	}
+
+	viper.Set("context", ctxName)
+	return name, nil
+}
+
+// valueOr returns v, or fallback when v is empty
+func valueOr(v, fallback string) string {
+	if v == "" {
+		return fallback
+	}
+	return v
+}
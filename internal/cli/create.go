@@ -1,85 +1,526 @@
 package cli
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/sandwich-labs/puck/internal/daemon"
+	"github.com/sandwich-labs/puck/internal/podman"
 	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/sandwich-labs/puck/internal/store"
+	"golang.org/x/term"
 )
 
 var createCmd = &cobra.Command{
-	Use:   "create [name]",
+	Use:   "create [name] [-- command...]",
 	Short: "Create a new puck",
-	Long:  `Create a new persistent container (puck) with the given name.`,
-	Args:  cobra.MaximumNArgs(1),
-	RunE:  runCreate,
+	Long: `Create a new persistent container (puck) with the given name. Without
+one, a free name is generated from name_pattern in the config, by
+default "%adjective%-%noun%".
+
+--init chooses what runs as PID 1:
+  systemd  the image's systemd (default), for full-OS images like fedora
+  tini     a minimal init that runs the image's command and reaps zombies
+  none     the image's entrypoint itself, for images without an init
+
+Arguments after -- replace the image's command, and --entrypoint its
+entrypoint, so application images can run as long-lived pucks:
+
+  puck create api --image node:22 --init tini -- npm run dev
+  puck create box --image alpine --entrypoint /bin/sh -- -c "sleep infinity"
+
+--from-checkpoint restores a checkpoint archive exported by podman
+(podman container checkpoint --export), from this machine or another, as
+the new puck, with its processes already running. The puck's volumes
+start out empty, as checkpoints don't carry them, and the image is the
+one the checkpoint was taken from. For a remote context, the path is on
+the daemon's host.
+
+  puck create seeded --from-checkpoint ~/Downloads/web.tar.gz
+
+--data-dir keeps the puck's volumes in <dir>/<name> instead of the data
+directory, such as on a fast scratch disk. The directory must already
+exist on the daemon's host; destroying the puck removes its own volumes
+from it, and backups include them.
+
+  puck create build --data-dir /mnt/nvme/pucks
+
+A name already taken fails with a free one to use instead. --replace
+destroys a puck of the same name, losing its volumes, so it can be
+recreated from scratch. It is put back if the new one can't be made:
+
+  puck create web --image nginx --replace
+
+The defaults setting in the config gives new pucks an image, a shell for
+puck console and a user for exec sessions by project, label or profile,
+where they aren't given here. --profile picks the rules naming a profile:
+
+  puck create tools --profile go
+
+--requires names pucks this one depends on. They are started before it,
+including now, and it is stopped before them:
+
+  puck create api --image node:22 --requires db --requires cache
+
+--template creates the puck from a template (see 'puck template'), asking
+for its variables unless given with --var, and runs its provisioning
+scripts in the new puck. Flags given on the command line win over the
+template's settings:
+
+  puck create api --template gh:me/puck-node --var NODE_VERSION=20
+
+--from-pool hands out a puck a pool made from a template ahead of time
+(see 'puck pool'), resumed with its app already running. It has the name
+the pool gave it, so no name is taken:
+
+  puck create --from-pool node`,
+	Args: createArgs,
+	RunE: runCreate,
 }
 
 var (
-	createImage string
-	createPorts []string
+	createImage   string
+	createPorts   []string
+	createInit    string
+	createEntry   string
+	createHost    string
+	createDNS     []string
+	createHosts   []string
+	createSysctls []string
+	createUlimits []string
+	createUserNS  string
+	createUIDMap  []string
+	createGIDMap  []string
+	createSeccomp string
+	createArmor   string
+	createSandbox string
+	createEgress  string
+	createAllow   []string
+	createProject string
+	createStop    int
+	createReqs    []string
+	createTmpl    string
+	createVars    []string
+	createHPort   int
+	createEndpts  []string
+	createPubAll  bool
+	createFromCP  string
+	createRepo    string
+	createRepoBr  string
+	createRepoDir string
+	createRepoTok string
+	createDataDir string
+	createVolumes []string
+	createMkdirs  bool
+	createReplace bool
+	createProfile string
+	createShell   string
+	createUser    string
+	createPool    string
+	createTTL     time.Duration
+	createTTLSnap bool
 )
 
 func init() {
-	createCmd.Flags().StringVarP(&createImage, "image", "i", "fedora:latest", "base image to use")
-	createCmd.Flags().StringSliceVarP(&createPorts, "port", "p", nil, "ports to expose (e.g., 8080:80)")
+	createCmd.Flags().StringVarP(&createImage, "image", "i", "", "base image to use (default: a defaults rule's, or default_image from the config)")
+	createCmd.Flags().StringVar(&createProfile, "profile", "", "apply the defaults rules for this profile from the config")
+	createCmd.Flags().StringVar(&createShell, "shell", "", "shell puck console opens (default: a defaults rule's, or /bin/bash)")
+	createCmd.Flags().StringVarP(&createUser, "user", "u", "", "user exec and console sessions run as (default: a defaults rule's, or the image's)")
+	createCmd.Flags().StringSliceVarP(&createPorts, "port", "p", nil, "ports to expose, e.g. 8080:80, 5353:5353/udp or 7000-7010:7000-7010")
+	createCmd.Flags().BoolVarP(&createPubAll, "publish-all", "P", false, "publish the image's exposed ports on host ports podman picks (see puck inspect)")
+	createCmd.Flags().StringArrayVar(&createEndpts, "endpoint", nil, "serve another container port through the router at /<name>/<endpoint>, as endpoint:port (repeatable)")
+	createCmd.Flags().IntVar(&createHPort, "host-port", 0, "pin the host port the router reaches the puck on instead of letting puck pick one")
+	createCmd.Flags().StringVar(&createEntry, "entrypoint", "", `override the image's entrypoint, as a command or a JSON array like '["/bin/sh", "-c"]'`)
+	createCmd.Flags().StringVar(&createHost, "hostname", "", "hostname inside the puck (default: the puck's name)")
+	createCmd.Flags().StringSliceVar(&createDNS, "dns", nil, "nameserver IP to use instead of the host's (repeatable)")
+	createCmd.Flags().StringSliceVar(&createHosts, "add-host", nil, "add a host:ip entry to /etc/hosts (repeatable)")
+	createCmd.Flags().StringArrayVar(&createSysctls, "sysctl", nil, "set a kernel parameter, e.g. net.ipv4.ip_forward=1 (repeatable)")
+	createCmd.Flags().StringArrayVar(&createUlimits, "ulimit", nil, "set a resource limit as name=soft[:hard], e.g. nofile=65536 (repeatable)")
+	createCmd.Flags().StringVar(&createUserNS, "userns", "", "user namespace: keep-id, auto, or nomap, with options like keep-id:uid=1000")
+	createCmd.Flags().StringArrayVar(&createUIDMap, "uidmap", nil, "map container UIDs to host UIDs as container:host:size (repeatable)")
+	createCmd.Flags().StringArrayVar(&createGIDMap, "gidmap", nil, "map container GIDs to host GIDs as container:host:size (default: same as --uidmap)")
+	createCmd.Flags().StringVar(&createSeccomp, "seccomp", "", "seccomp profile: an absolute path to a JSON profile, or unconfined (e.g. for ptrace or bpf)")
+	createCmd.Flags().StringVar(&createArmor, "apparmor", "", "AppArmor profile name, or unconfined")
+	createCmd.Flags().StringVar(&createSandbox, "sandbox", "", "restrict the puck for untrusted code: strict (no network, read-only root, no capabilities, capped resources)")
+	createCmd.Flags().StringVar(&createEgress, "egress", "", "limit outbound connections: all, none, tailnet, or allowlist (see puck egress)")
+	createCmd.Flags().StringSliceVar(&createAllow, "egress-allow", nil, "CIDR, IP or domain the puck may connect to; implies --egress allowlist (repeatable)")
+	createCmd.Flags().StringVar(&createProject, "project", "", "project the puck counts against for quotas (see puck project status)")
+	createCmd.Flags().IntVar(&createStop, "stop-timeout", 0, "seconds the puck gets to exit when stopped before it is killed (default: stop_timeout from the config)")
+	createCmd.Flags().StringSliceVar(&createReqs, "requires", nil, "puck to start before this one and stop after it (repeatable)")
+	createCmd.Flags().StringVar(&createTmpl, "template", "", "create from a template: a registered name, gh:user/repo, a git URL or a path")
+	createCmd.Flags().StringArrayVar(&createVars, "var", nil, "set a template variable as NAME=value (repeatable)")
+	createCmd.Flags().StringVar(&createInit, "init", string(store.InitSystemd), "init to run as PID 1: systemd, tini, or none")
+	createCmd.Flags().StringVar(&createRepo, "repo", "", "git repository to clone into the new puck, e.g. https://github.com/org/app")
+	createCmd.Flags().StringVar(&createRepoBr, "repo-branch", "", "branch or tag of --repo to check out (default: the repository's default branch)")
+	createCmd.Flags().StringVar(&createRepoDir, "repo-dir", "", "where to clone --repo inside the puck (default "+puck.DefaultRepoDir+")")
+	createCmd.Flags().StringVar(&createRepoTok, "repo-token-env", "", "local environment variable holding a token to clone a private HTTPS --repo with")
+	createCmd.Flags().StringVar(&createDataDir, "data-dir", "", "directory on the daemon's host to keep the puck's volumes in, e.g. on a faster disk (default: the data directory)")
+	createCmd.Flags().StringArrayVar(&createVolumes, "volume", nil, "mount a directory on the daemon's host as host:container[:ro]; ~ and relative paths are expanded for the local daemon (repeatable)")
+	createCmd.Flags().BoolVar(&createMkdirs, "create-host-dirs", false, "create --volume directories missing on the daemon's host")
+	createCmd.Flags().BoolVar(&createReplace, "replace", false, "destroy a puck of the same name once the new one is made, without asking, instead of failing")
+	createCmd.Flags().StringVar(&createFromCP, "from-checkpoint", "", "restore a checkpoint archive exported by podman, from any machine, as the new puck")
+	createCmd.Flags().StringVar(&createPool, "from-pool", "", "hand out a ready puck from one of your pools (see puck pool) instead of making one")
+	createCmd.Flags().DurationVar(&createTTL, "ttl", 0, "destroy the puck this long after it is created, e.g. 4h (see puck set --ttl)")
+	createCmd.Flags().BoolVar(&createTTLSnap, "ttl-snapshot", false, "archive the puck's volumes and a checkpoint before its TTL destroys it")
+}
+
+// createArgs allows an optional name, plus a command after --
+func createArgs(cmd *cobra.Command, args []string) error {
+	if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+		args = args[:dash]
+	}
+	return cobra.MaximumNArgs(1)(cmd, args)
 }
 
 func runCreate(cmd *cobra.Command, args []string) error {
+	var command []string
+	if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+		command = args[dash:]
+		args = args[:dash]
+		if len(command) == 0 {
+			return fmt.Errorf("no command after --")
+		}
+	}
+
 	name := ""
 	if len(args) > 0 {
 		name = args[0]
+	}
+
+	if createPool != "" {
+		return createFromPool(cmd, name, command)
+	}
+
+	entrypoint, err := parseEntrypoint(createEntry)
+	if err != nil {
+		return err
+	}
+
+	var stopTimeout *int
+	if cmd.Flags().Changed("stop-timeout") {
+		stopTimeout = &createStop
+	}
+
+	fromCheckpoint := ""
+	if createFromCP != "" {
+		if cmd.Flags().Changed("image") || createTmpl != "" || len(command) > 0 {
+			return fmt.Errorf("--from-checkpoint restores the checkpoint's image and command, so it can't be combined with --image, --template or a command")
+		}
+		if fromCheckpoint, err = hostPath(createFromCP); err != nil {
+			return fmt.Errorf("--from-checkpoint: %w", err)
+		}
+	}
+
+	dataDir := ""
+	if createDataDir != "" {
+		if dataDir, err = hostPath(createDataDir); err != nil {
+			return fmt.Errorf("--data-dir: %w", err)
+		}
+	}
+
+	mounts, err := parseVolumes(createVolumes)
+	if err != nil {
+		return err
+	}
+
+	var endpoints []puck.EndpointSpec
+	for _, s := range createEndpts {
+		e, err := puck.ParseEndpoint(s)
+		if err != nil {
+			return err
+		}
+		endpoints = append(endpoints, e)
+	}
+
+	var sysctls map[string]string
+	for _, s := range createSysctls {
+		key, value, ok := strings.Cut(s, "=")
+		if !ok {
+			return fmt.Errorf("invalid --sysctl %q; use key=value", s)
+		}
+		if sysctls == nil {
+			sysctls = make(map[string]string)
+		}
+		sysctls[key] = value
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	if name == "" {
+		name, err = puck.GenerateName(viper.GetString("name_pattern"), func(name string) (bool, error) {
+			_, err := client.Get(name)
+			if errors.Is(err, store.ErrNotFound) {
+				return false, nil
+			}
+			return err == nil, err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	opts := puck.CreateOptions{
+		Name:        name,
+		Image:       createImage,
+		Ports:       createPorts,
+		Init:        store.InitMode(createInit),
+		Entrypoint:  entrypoint,
+		Command:     command,
+		Hostname:    createHost,
+		DNS:         createDNS,
+		AddHosts:    createHosts,
+		Sysctls:     sysctls,
+		Ulimits:     createUlimits,
+		UserNS:      createUserNS,
+		UIDMap:      createUIDMap,
+		GIDMap:      createGIDMap,
+		Seccomp:     createSeccomp,
+		AppArmor:    createArmor,
+		Sandbox:     store.SandboxMode(createSandbox),
+		Egress:      store.EgressPolicy{Mode: store.EgressMode(createEgress), Allow: createAllow},
+		StopTimeout: stopTimeout,
+		Requires:    createReqs,
+		Project:     createProject,
+		HostPort:    createHPort,
+		Endpoints:   endpoints,
+		PublishAll:  createPubAll,
+		DataDir:     dataDir,
+		Mounts:      mounts,
+
+		FromCheckpoint:  fromCheckpoint,
+		CreateMountDirs: createMkdirs,
+		Replace:         createReplace,
+		Profile:         createProfile,
+		Shell:           createShell,
+		User:            createUser,
+		TTL:             createTTL,
+		TTLSnapshot:     createTTLSnap,
+	}
+	if len(createAllow) > 0 && createEgress == "" {
+		opts.Egress.Mode = store.EgressAllowlist
+	}
+	// Sandboxes pick their own init unless asked for one
+	if createSandbox != "" && !cmd.Flags().Changed("init") {
+		opts.Init = ""
+	}
+	if createTmpl != "" {
+		if err := applyTemplate(cmd, createTmpl, createVars, &opts); err != nil {
+			return err
+		}
+	} else if len(createVars) > 0 {
+		return fmt.Errorf("--var needs --template")
+	}
+	if err := applyRepoFlags(cmd, &opts); err != nil {
+		return err
+	}
+
+	if opts.FromCheckpoint != "" {
+		log.Info("Creating puck", "name", name, "checkpoint", opts.FromCheckpoint)
+	} else if opts.Image != "" {
+		log.Info("Creating puck", "name", name, "image", opts.Image)
 	} else {
-		name = generatePuckName()
+		log.Info("Creating puck", "name", name)
+	}
+	if opts.Repo != nil {
+		log.Info("Cloning once it is created", "repo", opts.Repo.URL)
 	}
+	if len(opts.Provision) > 0 {
+		log.Info("Provisioning runs once it is created", "scripts", len(opts.Provision))
+	}
+
+	endProgress := showPullProgress(client)
+	p, err := client.Create(opts)
+	endProgress()
 
-	client, err := daemon.NewClient()
 	if err != nil {
 		return err
 	}
 
-	// Check daemon is running
-	if err := client.Ping(); err != nil {
-		return fmt.Errorf("daemon not running: %w\nStart with: puck daemon start", err)
+	printCreated(client, p)
+	return nil
+}
+
+// createFromPool hands out a puck waiting in one of the caller's pools
+func createFromPool(cmd *cobra.Command, name string, command []string) error {
+	if name != "" {
+		return fmt.Errorf("pooled pucks are named when the pool makes them, so --from-pool takes no name")
+	}
+	for _, flag := range []string{"image", "template", "from-checkpoint", "replace"} {
+		if cmd.Flags().Changed(flag) {
+			return fmt.Errorf("--from-pool hands out a puck already made from the pool's template, so it can't be combined with --%s", flag)
+		}
+	}
+	if len(command) > 0 {
+		return fmt.Errorf("--from-pool hands out a puck already made from the pool's template, so it can't be combined with a command")
 	}
 
-	log.Info("Creating puck", "name", name, "image", createImage)
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
 
-	p, err := client.Create(puck.CreateOptions{
-		Name:  name,
-		Image: createImage,
-		Ports: createPorts,
-	})
+	log.Info("Taking a puck from pool", "pool", createPool)
+	endProgress := showPullProgress(client)
+	p, err := client.Create(puck.CreateOptions{FromPool: createPool, TTL: createTTL, TTLSnapshot: createTTLSnap})
+	endProgress()
 	if err != nil {
 		return err
 	}
 
-	// Show access URLs
-	port := viper.GetInt("router_port")
-	if port == 0 {
-		port = 8080
+	printCreated(client, p)
+	return nil
+}
+
+// printCreated shows where a new puck can be reached, or just its name
+// with --quiet
+func printCreated(client *daemon.Client, p *store.Puck) {
+	for _, w := range p.Warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+	}
+	if quiet {
+		fmt.Println(p.Name)
+		return
 	}
+	router := localRouter(client)
 	tailnet := viper.GetString("tailnet")
 
 	fmt.Printf("Created puck '%s'\n", p.Name)
-	fmt.Printf("  Local:  http://localhost:%d/%s\n", port, p.Name)
+	if url := router.url(p); url != "" {
+		fmt.Printf("  Local:  %s\n", url)
+	}
+	if router.disabled {
+		return
+	}
 	if tailnet != "" {
 		fmt.Printf("  Remote: https://puck.%s/%s\n", tailnet, p.Name)
 	}
-	return nil
+	if p.Status == store.StatusStarting {
+		infof("The route goes live once the app answers on port 80 (see: puck list)")
+	}
 }
 
-func generatePuckName() string {
-	adjectives := []string{"swift", "brave", "calm", "eager", "fair", "glad", "keen", "neat", "wise", "bold"}
-	nouns := []string{"fox", "owl", "elk", "bee", "ant", "bat", "cat", "dog", "eel", "jay"}
+// routerAddr is where a context's router serves pucks from this machine
+type routerAddr struct {
+	port     int
+	disabled bool
+}
 
-	t := time.Now().UnixNano()
-	adj := adjectives[int(t)%len(adjectives)]
-	noun := nouns[int(t/10)%len(nouns)]
+// localRouter asks the daemon where its router listens
+func localRouter(client *daemon.Client) routerAddr {
+	r := routerAddr{port: viper.GetInt("router_port")}
+	if r.port == 0 {
+		r.port = 8080
+	}
+	// The router may have fallen back to another port if this one was busy
+	st, err := client.RouterStatus()
+	if err == nil && st.Running {
+		r.port = st.Port
+	}
+	r.disabled = err == nil && st.Disabled
+	return r
+}
 
-	return fmt.Sprintf("%s-%s", adj, noun)
+// url is where a puck is reached from this machine. Without the router
+// that is only its own port, if it has one.
+func (r routerAddr) url(p *store.Puck) string {
+	if !r.disabled {
+		return fmt.Sprintf("http://localhost:%d/%s", r.port, p.Name)
+	}
+	if p.HostPort > 0 {
+		return fmt.Sprintf("http://localhost:%d/", p.HostPort)
+	}
+	return ""
+}
+
+// parseEntrypoint reads --entrypoint as a JSON array, like a Containerfile
+// ENTRYPOINT, or else as a single executable
+func parseEntrypoint(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(strings.TrimSpace(s), "[") {
+		return []string{s}, nil
+	}
+	var entrypoint []string
+	if err := json.Unmarshal([]byte(s), &entrypoint); err != nil {
+		return nil, fmt.Errorf("invalid --entrypoint %q: %w", s, err)
+	}
+	if len(entrypoint) == 0 {
+		return nil, fmt.Errorf("--entrypoint must not be an empty array")
+	}
+	return entrypoint, nil
+}
+
+// showPullProgress reports the client's image pulls on stderr: a status
+// line updated in place on a terminal, or a line per pull otherwise. The
+// returned func ends a status line a failed pull left open.
+// applyRepoFlags sets the repository to clone from --repo, or adjusts a
+// template's with --repo-branch, --repo-dir and --repo-token-env
+func applyRepoFlags(cmd *cobra.Command, opts *puck.CreateOptions) error {
+	if createRepo != "" {
+		opts.Repo = &puck.RepoClone{URL: createRepo}
+	}
+	flags := cmd.Flags()
+	if opts.Repo == nil {
+		if flags.Changed("repo-branch") || flags.Changed("repo-dir") || flags.Changed("repo-token-env") {
+			return fmt.Errorf("--repo-branch, --repo-dir and --repo-token-env need --repo or a template with a repo")
+		}
+		return nil
+	}
+	if flags.Changed("repo-branch") {
+		opts.Repo.Branch = createRepoBr
+	}
+	if flags.Changed("repo-dir") {
+		opts.Repo.Dir = createRepoDir
+	}
+	if createRepoTok != "" {
+		opts.Repo.Token = os.Getenv(createRepoTok)
+		if opts.Repo.Token == "" {
+			return fmt.Errorf("--repo-token-env: %s is not set", createRepoTok)
+		}
+	}
+	return nil
+}
+
+func showPullProgress(client *daemon.Client) func() {
+	if quiet {
+		return func() {}
+	}
+	tty := term.IsTerminal(int(os.Stderr.Fd())) && !plainOutput()
+	pulling := false
+	client.SetPullProgress(func(p podman.PullProgress) {
+		switch {
+		case p.Done:
+			if tty {
+				fmt.Fprint(os.Stderr, "\r\033[K")
+			}
+			fmt.Fprintf(os.Stderr, "Pulled %s (%d layers)\n", p.Image, p.Layers)
+			pulling = false
+		case tty:
+			status := p.Status
+			if len(status) > 40 {
+				status = status[:37] + "..."
+			}
+			fmt.Fprintf(os.Stderr, "\r\033[KPulling %s: %d layers, %s", p.Image, p.Layers, status)
+			pulling = true
+		case !pulling:
+			fmt.Fprintf(os.Stderr, "Pulling %s...\n", p.Image)
+			pulling = true
+		}
+	})
+	return func() {
+		if pulling && tty {
+			fmt.Fprintln(os.Stderr)
+		}
+	}
 }
@@ -1,15 +1,21 @@
 package cli
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
+	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/spf13/cobra"
+	"github.com/sandwich-labs/puck/internal/config"
 	"github.com/sandwich-labs/puck/internal/daemon"
+	"github.com/sandwich-labs/puck/internal/network"
 	"github.com/sandwich-labs/puck/internal/systemd"
 )
 
@@ -33,6 +39,29 @@ var daemonStatusCmd = &cobra.Command{
 	RunE:  runDaemonStatus,
 }
 
+var daemonLogsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Show the daemon's logs",
+	Long: `Show the logs of the daemon on this machine: from the user journal when
+puckd is installed as a systemd service, otherwise from the log file the
+daemon writes when started with puck daemon start.
+
+Examples:
+  puck daemon logs
+  puck daemon logs -f -n 200`,
+	Args: cobra.NoArgs,
+	RunE: runDaemonLogs,
+}
+
+var daemonDialStdioCmd = &cobra.Command{
+	Use:    "dial-stdio",
+	Short:  "Proxy stdin and stdout to the local daemon socket",
+	Long:   `Proxy stdin and stdout to the local daemon socket. Used over ssh by ssh contexts.`,
+	Hidden: true,
+	Args:   cobra.NoArgs,
+	RunE:   runDaemonDialStdio,
+}
+
 var daemonInstallCmd = &cobra.Command{
 	Use:   "install",
 	Short: "Install puckd as a systemd user service",
@@ -43,7 +72,13 @@ This will:
 - Create a systemd user service file
 - Enable the service to start on login
 
-Use --now to also start the service immediately.`,
+Use --now to also start the service immediately.
+
+The service starts after podman.socket and pulls it in, but still starts
+if the socket fails, as the daemon waits for Podman by itself. Use
+--podman-socket requires to stop the daemon along with the socket, or
+none for a Podman that isn't the user's socket. --with-podman-socket
+enables the podman user socket if it isn't already.`,
 	RunE: runDaemonInstall,
 }
 
@@ -56,14 +91,22 @@ This will:
 - Stop the service if running
 - Disable the service
 - Remove the service file
+- Log the daemon's tailnet nodes out and remove their state, with tailnet
+  set in the config
 
-Use --remove-binary to also remove the puckd binary.`,
+Use --remove-binary to also remove the puckd binary, and --keep-tailnet to
+leave the tailnet nodes registered for a later install.`,
 	RunE: runDaemonUninstall,
 }
 
 var (
-	installNow       bool
-	uninstallBinary  bool
+	installNow          bool
+	installPodmanDep    string
+	installEnableSocket bool
+	uninstallBinary     bool
+	uninstallKeepTS     bool
+	daemonLogLines      int
+	daemonLogFollow     bool
 )
 
 func init() {
@@ -71,9 +114,17 @@ func init() {
 	daemonCmd.AddCommand(daemonStatusCmd)
 	daemonCmd.AddCommand(daemonInstallCmd)
 	daemonCmd.AddCommand(daemonUninstallCmd)
+	daemonCmd.AddCommand(daemonLogsCmd)
+	daemonCmd.AddCommand(daemonMaintenanceCmd)
+	daemonCmd.AddCommand(daemonDialStdioCmd)
 
 	daemonInstallCmd.Flags().BoolVar(&installNow, "now", false, "Start the service immediately after installation")
+	daemonInstallCmd.Flags().StringVar(&installPodmanDep, "podman-socket", systemd.PodmanSocketWants, "How the service depends on podman.socket: wants, requires or none")
+	daemonInstallCmd.Flags().BoolVar(&installEnableSocket, "with-podman-socket", false, "Enable and start the podman user socket if it isn't already")
 	daemonUninstallCmd.Flags().BoolVar(&uninstallBinary, "remove-binary", false, "Also remove the puckd binary")
+	daemonUninstallCmd.Flags().BoolVar(&uninstallKeepTS, "keep-tailnet", false, "Leave the daemon's tailnet nodes logged in")
+	daemonLogsCmd.Flags().IntVarP(&daemonLogLines, "lines", "n", 50, "Number of lines to show")
+	daemonLogsCmd.Flags().BoolVarP(&daemonLogFollow, "follow", "f", false, "Keep printing new lines as they are logged")
 }
 
 func runDaemonStart(cmd *cobra.Command, args []string) error {
@@ -110,15 +161,20 @@ func runDaemonStatus(cmd *cobra.Command, args []string) error {
 	if systemd.IsInstalled() {
 		if systemd.IsRunning() {
 			fmt.Println("Daemon is running (systemd user service)")
+			if client, err := newClient(); err == nil {
+				if m, err := client.Maintenance(nil, ""); err == nil && m.Enabled {
+					fmt.Println(maintenanceLine(m))
+				}
+			}
 		} else {
 			fmt.Println("Daemon is installed but not running")
-			fmt.Println("Start with: systemctl --user start puckd")
+			infof("Start with: systemctl --user start puckd")
 		}
 		return nil
 	}
 
 	// Fall back to direct ping check
-	client, err := daemon.NewClient()
+	client, err := newClient()
 	if err != nil {
 		return err
 	}
@@ -129,14 +185,113 @@ func runDaemonStatus(cmd *cobra.Command, args []string) error {
 	}
 
 	fmt.Println("Daemon is running")
+	if st, err := client.PodmanStatus(); err == nil && !st.Available {
+		fmt.Printf("Podman is unavailable, retrying: %s\n", st.Error)
+	}
+	if m, err := client.Maintenance(nil, ""); err == nil && m.Enabled {
+		fmt.Println(maintenanceLine(m))
+	}
+	if st, err := client.RouterStatus(); err == nil {
+		fmt.Println(routerStatusLine(st))
+	}
 	return nil
 }
 
-func runDaemonInstall(cmd *cobra.Command, args []string) error {
-	// Find the puckd binary - look next to current executable first
+func runDaemonLogs(cmd *cobra.Command, args []string) error {
+	if daemonLogLines < 0 {
+		return fmt.Errorf("--lines cannot be negative")
+	}
+	if systemd.IsInstalled() {
+		journal := systemd.JournalCommand(daemonLogLines, daemonLogFollow)
+		journal.Stdout, journal.Stderr = os.Stdout, os.Stderr
+		return journal.Run()
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	path := cfg.DaemonLogPath()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("no daemon log at %s; the daemon writes it when started with puck daemon start", path)
+	}
+	if err != nil {
+		return err
+	}
+	os.Stdout.Write(lastLines(data, daemonLogLines))
+	if !daemonLogFollow {
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return followLog(ctx, path, int64(len(data)))
+}
+
+// lastLines returns the last n lines of data
+func lastLines(data []byte, n int) []byte {
+	if n == 0 {
+		return nil
+	}
+	// A final newline ends the last line rather than starting another
+	start := len(data)
+	if start > 0 && data[start-1] == '\n' {
+		start--
+	}
+	for i := 0; i < n; i++ {
+		nl := bytes.LastIndexByte(data[:start], '\n')
+		if nl < 0 {
+			return data
+		}
+		start = nl
+	}
+	return data[start+1:]
+}
+
+// followLog prints what is appended to a log file from offset on, until
+// ctx is done, starting over when the file is rotated or truncated
+func followLog(ctx context.Context, path string, offset int64) error {
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			// Between the rotation and the daemon opening a new file
+			continue
+		}
+		if info, err := f.Stat(); err == nil && info.Size() < offset {
+			offset = 0
+		}
+		n, err := io.Copy(os.Stdout, io.NewSectionReader(f, offset, 1<<62))
+		f.Close()
+		offset += n
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func runDaemonDialStdio(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	return daemon.DialStdio(cfg.DaemonSocket)
+}
+
+// findPuckd finds the puckd binary to install: next to this executable,
+// or else in the current directory
+func findPuckd() (string, error) {
 	execPath, err := os.Executable()
 	if err != nil {
-		return fmt.Errorf("finding executable path: %w", err)
+		return "", fmt.Errorf("finding executable path: %w", err)
 	}
 
 	// Look for puckd in the same directory as puck
@@ -145,64 +300,128 @@ func runDaemonInstall(cmd *cobra.Command, args []string) error {
 		// Try looking in current directory
 		puckdPath = "puckd"
 		if _, err := os.Stat(puckdPath); os.IsNotExist(err) {
-			return fmt.Errorf("puckd binary not found - build it first with 'go build ./cmd/puckd'")
+			return "", fmt.Errorf("puckd binary not found - build it first with 'go build ./cmd/puckd'")
 		}
 	}
+	return puckdPath, nil
+}
+
+func runDaemonInstall(cmd *cobra.Command, args []string) error {
+	puckdPath, err := findPuckd()
+	if err != nil {
+		return err
+	}
 
 	// Check if already installed
 	if systemd.IsInstalled() {
-		fmt.Println("puckd is already installed as a systemd service")
-		fmt.Println("Uninstall first with: puck daemon uninstall")
+		infof("puckd is already installed as a systemd service")
+		infof("Uninstall first with: puck daemon uninstall")
 		return nil
 	}
 
-	fmt.Printf("Installing puckd from %s...\n", puckdPath)
+	if installEnableSocket && installPodmanDep == systemd.PodmanSocketNone {
+		return fmt.Errorf("--with-podman-socket needs the service to depend on it; drop --podman-socket none")
+	}
+
+	// The service keeps the data directory configured here, which may
+	// have been moved from the default
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	infof("Installing puckd from %s...", puckdPath)
 
-	if err := systemd.Install(puckdPath); err != nil {
+	if err := systemd.Install(puckdPath, systemd.InstallOptions{PodmanSocket: installPodmanDep, DataDir: cfg.DataDir}); err != nil {
 		return fmt.Errorf("installation failed: %w", err)
 	}
 
+	if installEnableSocket && !systemd.PodmanSocketEnabled() {
+		infof("Enabling podman.socket...")
+		if err := systemd.EnablePodmanSocket(); err != nil {
+			return fmt.Errorf("failed to enable podman.socket: %w", err)
+		}
+	}
+
 	servicePath, _ := systemd.ServiceFilePath()
 	binaryPath, _ := systemd.BinaryInstallPath()
 
-	fmt.Println("Installation complete!")
-	fmt.Printf("  Binary: %s\n", binaryPath)
-	fmt.Printf("  Service: %s\n", servicePath)
+	infof("Installation complete!")
+	infof("  Binary: %s", binaryPath)
+	infof("  Service: %s", servicePath)
 
 	if installNow {
-		fmt.Println("Starting service...")
+		infof("Starting service...")
 		if err := systemd.Start(); err != nil {
 			return fmt.Errorf("failed to start service: %w", err)
 		}
-		fmt.Println("Service started successfully")
+		infof("Service started successfully")
 	} else {
-		fmt.Println("\nTo start the daemon:")
-		fmt.Println("  systemctl --user start puckd")
-		fmt.Println("\nTo view logs:")
-		fmt.Println("  journalctl --user -u puckd -f")
+		infof("\nTo start the daemon:")
+		infof("  systemctl --user start puckd")
+		infof("\nTo view logs:")
+		infof("  journalctl --user -u puckd -f")
 	}
 
 	return nil
 }
 
+// logoutTailnetNodes logs the stopped daemon's tailnet nodes out and
+// removes their state, so they don't linger on the tailnet
+func logoutTailnetNodes() {
+	cfg, err := config.Load()
+	if err != nil || cfg.Tailnet == "" {
+		return
+	}
+
+	dirs := map[string]string{} // state directory -> hostname
+	if cfg.DaemonTailnetName != "" {
+		dirs[filepath.Join(cfg.TailscaleDir(), cfg.DaemonTailnetName)] = cfg.DaemonTailnetName
+	}
+	states, err := network.TailscaleNodeStates(cfg.RouterTailscaleDir())
+	if err != nil {
+		log.Warn("Failed to read tailnet state", "error", err)
+	}
+	for _, st := range states {
+		dirs[st.Dir] = st.Name
+	}
+
+	for dir, hostname := range dirs {
+		if !network.TailscaleLoggedIn(dir) {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		err := network.LogoutTailscaleNode(ctx, dir, hostname)
+		cancel()
+		if err != nil {
+			log.Warn("Failed to log out tailnet node; remove it in the Tailscale admin console", "dir", dir, "error", err)
+			continue
+		}
+		infof("Logged out tailnet node %s", filepath.Base(dir))
+	}
+}
+
 func runDaemonUninstall(cmd *cobra.Command, args []string) error {
 	if !systemd.IsInstalled() {
-		fmt.Println("puckd is not installed as a systemd service")
+		infof("puckd is not installed as a systemd service")
 		return nil
 	}
 
-	fmt.Println("Uninstalling puckd service...")
+	infof("Uninstalling puckd service...")
 
 	if err := systemd.Uninstall(uninstallBinary); err != nil {
 		return fmt.Errorf("uninstallation failed: %w", err)
 	}
 
-	fmt.Println("Service uninstalled successfully")
+	infof("Service uninstalled successfully")
+	if !uninstallKeepTS {
+		logoutTailnetNodes()
+	}
 	if uninstallBinary {
-		fmt.Println("Binary removed")
+		infof("Binary removed")
 	} else {
 		binaryPath, _ := systemd.BinaryInstallPath()
-		fmt.Printf("Binary still available at: %s\n", binaryPath)
+		infof("Binary still available at: %s", binaryPath)
 	}
 
 	return nil
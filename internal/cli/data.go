@@ -0,0 +1,192 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/sandwich-labs/puck/internal/backup"
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/sandwich-labs/puck/internal/systemd"
+	"github.com/spf13/cobra"
+)
+
+var dataCmd = &cobra.Command{
+	Use:   "data",
+	Short: "Manage puck's data directory",
+}
+
+var dataMoveCmd = &cobra.Command{
+	Use:   "move <new-dir>",
+	Short: "Move volumes, snapshots and the database to another directory",
+	Long: `Move this host's data directory, with every puck's volumes, the
+snapshots and the database, to another directory, such as on a bigger disk.
+
+Running pucks are stopped and the daemon, which must be run by systemd, is
+stopped while the data moves. Everything is renamed when the new directory
+is on the same filesystem and copied otherwise; the old directory is then
+removed unless --keep-old is given. Recorded paths are rewritten, data_dir
+is set in the config file and the systemd service, and each puck gets a
+new container, mounting its volumes from the new place, when next started.
+Finally the daemon and the pucks that were running are started again.
+
+The new directory must not exist or be empty. Suspended and checkpointed
+pucks resume from images that mount the old paths, so start or stop them
+first. Copying volume files owned by users inside containers needs their
+user namespace; run the move as 'podman unshare puck data move ...'.
+
+Examples:
+  puck data move /mnt/big/puck
+  puck data move /mnt/big/puck --keep-old`,
+	Args: cobra.ExactArgs(1),
+	RunE: runDataMove,
+}
+
+var dataMoveKeepOld bool
+
+// dataStartTimeout bounds how long the daemon may take to answer once it
+// is started again
+const dataStartTimeout = 30 * time.Second
+
+func init() {
+	dataMoveCmd.Flags().BoolVar(&dataMoveKeepOld, "keep-old", false, "copy, leaving the old data directory as it was")
+	dataCmd.AddCommand(dataMoveCmd)
+}
+
+func runDataMove(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	paths, err := localPaths("data move")
+	if err != nil {
+		return err
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	to, err := filepath.Abs(args[0])
+	if err != nil {
+		return err
+	}
+	if to, err = backup.MoveTarget(paths.DataDir, to); err != nil {
+		return err
+	}
+
+	db, err := store.OpenDSN(cfg.DatabaseDSN())
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	pucks, err := db.ListPucks(ctx)
+	db.Close()
+	if err != nil {
+		return err
+	}
+	for _, p := range pucks {
+		if p.Status == store.StatusSuspended || p.Status == store.StatusCheckpointed {
+			return fmt.Errorf("puck '%s' is %s and would resume with its volumes in the old place; start or stop it first", p.Name, p.Status)
+		}
+	}
+
+	// The daemon stops the pucks that are up, then is stopped itself so
+	// nothing holds the data directory
+	var restart []string
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	daemonStopped := false
+	if client.Ping() == nil {
+		if !systemd.IsInstalled() || !systemd.IsRunning() {
+			return fmt.Errorf("the daemon is not run by systemd; stop it and run this again")
+		}
+		for _, p := range pucks {
+			if !p.Status.Up() {
+				continue
+			}
+			infof("Stopping %s...", p.Name)
+			if err := client.Stop(p.Name); err != nil {
+				return fmt.Errorf("stopping %s: %w", p.Name, err)
+			}
+			restart = append(restart, p.Name)
+		}
+		infof("Stopping the daemon...")
+		if err := systemd.Stop(); err != nil {
+			return fmt.Errorf("stopping the daemon: %w", err)
+		}
+		daemonStopped = true
+	}
+
+	infof("Moving %s to %s...", paths.DataDir, to)
+	report, err := backup.Move(ctx, paths, to, backup.MoveOptions{KeepOld: dataMoveKeepOld})
+	if err != nil {
+		if daemonStopped {
+			systemd.Start()
+		}
+		if errors.Is(err, fs.ErrPermission) {
+			err = fmt.Errorf("%w\nFiles owned by container users are copied with: podman unshare puck data move %s", err, to)
+		}
+		return fmt.Errorf("nothing was moved: %w", err)
+	}
+	for _, path := range report.Skipped {
+		fmt.Fprintf(os.Stderr, "Warning: could not remove %s\n", path)
+	}
+	if report.Files > 0 {
+		infof("Copied %d files (%s)", report.Files, humanize.Bytes(uint64(report.Size)))
+	}
+
+	if err := config.WriteDataDir(paths.ConfigFile, to); err != nil {
+		return fmt.Errorf("setting data_dir in %s: %w", paths.ConfigFile, err)
+	}
+	if env := os.Getenv("PUCK_DATA_DIR"); env != "" && env != to {
+		fmt.Fprintf(os.Stderr, "Warning: PUCK_DATA_DIR is set to %s and overrides the config file; point it at %s\n", env, to)
+	}
+	if systemd.IsInstalled() {
+		if err := systemd.SetDataDir(to); err != nil {
+			return err
+		}
+		if err := systemd.DaemonReload(); err != nil {
+			return fmt.Errorf("reloading systemd: %w", err)
+		}
+	}
+
+	if !daemonStopped {
+		infof("Moved the data directory to %s. Start the daemon with 'puck daemon start'.", to)
+		return nil
+	}
+
+	infof("Starting the daemon...")
+	if err := systemd.Start(); err != nil {
+		return fmt.Errorf("starting the daemon: %w", err)
+	}
+	deadline := time.Now().Add(dataStartTimeout)
+	for {
+		err := client.Ping()
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("the daemon did not come up: %w", err)
+		}
+		time.Sleep(time.Second)
+	}
+
+	failed := 0
+	for _, name := range restart {
+		infof("Starting %s...", name)
+		if err := client.Start(name); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: starting %s: %v\n", name, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d pucks did not start; see 'puck start <name>'", failed)
+	}
+	infof("Moved the data directory to %s", to)
+	return nil
+}
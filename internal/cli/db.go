@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/spf13/cobra"
+)
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Inspect and repair puck's state database",
+}
+
+var dbCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check snapshot records against pucks and files",
+	Long: `Check that snapshot records agree with their pucks and with the files
+in the snapshots directory. Reports:
+
+  stale-puck-id   snapshot recorded against an ID its puck no longer has
+  orphaned        snapshot of a puck that no longer exists
+  missing-file    snapshot whose archive is gone
+  untracked-file  file in the snapshots directory no snapshot refers to
+
+With --fix, snapshots are pointed back at their puck by name, records of
+missing archives or missing pucks are dropped, and untracked files are
+removed.
+
+Examples:
+  puck db check
+  puck db check --fix`,
+	Args: cobra.NoArgs,
+	RunE: runDBCheck,
+}
+
+var dbCheckFix bool
+
+func init() {
+	dbCheckCmd.Flags().BoolVar(&dbCheckFix, "fix", false, "repair the inconsistencies found")
+	dbCmd.AddCommand(dbCheckCmd)
+}
+
+func runDBCheck(cmd *cobra.Command, args []string) error {
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	report, err := client.DBCheck(puck.CheckOptions{Fix: dbCheckFix})
+	if err != nil {
+		return err
+	}
+
+	for _, msg := range report.Errors {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", msg)
+	}
+
+	if len(report.Issues) == 0 {
+		infof("No problems found.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ISSUE\tPUCK\tSNAPSHOT\tDETAIL\tFIXED")
+	fixed := 0
+	for _, issue := range report.Issues {
+		detail := valueOr(issue.Detail, issue.Path)
+		status := "no"
+		if issue.Fixed {
+			status = "yes"
+			fixed++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", issue.Kind, issue.Puck, valueOr(issue.Snapshot, "-"), valueOr(detail, "-"), status)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if !dbCheckFix {
+		infof("\nFound %d problems. Run with --fix to repair them.", len(report.Issues))
+		return nil
+	}
+	infof("\nFixed %d of %d problems.", fixed, len(report.Issues))
+	return nil
+}
@@ -2,6 +2,9 @@ package cli
 
 import (
 	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/sandwich-labs/puck/internal/daemon"
@@ -11,9 +14,14 @@ var destroyCmd = &cobra.Command{
 	Use:     "destroy [name]",
 	Aliases: []string{"rm", "remove"},
 	Short:   "Destroy a puck",
-	Long:    `Destroy a puck and remove all its data. Use --all to destroy all pucks.`,
-	Args:    cobra.MaximumNArgs(1),
-	RunE:    runDestroy,
+	Long: `Destroy a puck and remove all its data. Use --all to destroy all pucks.
+
+A puck that may still be in use is refused without --force: one used in
+the last destroy_guard minutes (30 by default), one that has run since
+its last snapshot, so its changes may not be saved, or one other pucks
+require.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runDestroy,
 }
 
 var (
@@ -22,33 +30,18 @@ var (
 )
 
 func init() {
-	destroyCmd.Flags().BoolVarP(&destroyForce, "force", "f", false, "force removal even if running")
+	destroyCmd.Flags().BoolVarP(&destroyForce, "force", "f", false, "destroy even if in use, without stopping it first")
 	destroyCmd.Flags().BoolVar(&destroyAll, "all", false, "destroy all pucks")
 }
 
 func runDestroy(cmd *cobra.Command, args []string) error {
-	client, err := daemon.NewClient()
+	client, err := newClient()
 	if err != nil {
 		return err
 	}
 
-	if err := client.Ping(); err != nil {
-		return fmt.Errorf("daemon not running: %w\nStart with: puck daemon start", err)
-	}
-
 	if destroyAll {
-		destroyed, err := client.DestroyAll(destroyForce)
-		if err != nil {
-			return err
-		}
-		if len(destroyed) == 0 {
-			fmt.Println("No pucks to destroy")
-		} else {
-			for _, name := range destroyed {
-				fmt.Printf("Destroyed puck '%s'\n", name)
-			}
-		}
-		return nil
+		return runDestroyAll(client)
 	}
 
 	if len(args) == 0 {
@@ -60,6 +53,41 @@ func runDestroy(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	fmt.Printf("Destroyed puck '%s'\n", name)
+	infof("Destroyed puck '%s'", name)
 	return nil
 }
+
+func runDestroyAll(client *daemon.Client) error {
+	results, err := client.DestroyAll(destroyForce)
+	if err != nil {
+		return err
+	}
+
+	if len(results) == 0 {
+		infof("No pucks to destroy")
+		return nil
+	}
+
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if !quiet {
+		fmt.Fprintln(w, "PUCK\tRESULT\tTOOK")
+	}
+	for _, r := range results {
+		took := r.Duration.Round(100 * time.Millisecond)
+		switch {
+		case r.Error != "":
+			failed++
+			if !quiet {
+				fmt.Fprintf(w, "%s\tfailed: %s\t%s\n", r.Puck, r.Error, took)
+			}
+		case quiet:
+			fmt.Fprintln(w, r.Puck)
+		default:
+			fmt.Fprintf(w, "%s\tdestroyed\t%s\n", r.Puck, took)
+		}
+	}
+	w.Flush()
+
+	return batchStatus("destroy", failed, len(results))
+}
@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var egressCmd = &cobra.Command{
+	Use:   "egress <name> [all|none|tailnet|allowlist <cidr|domain>...]",
+	Short: "Show or change where a puck may connect to",
+	Long: `Show or change a puck's egress policy, which limits the outbound
+connections it may open:
+
+  all        no restrictions (the default)
+  none       no outbound connections at all
+  tailnet    only addresses on the tailnet (100.64.0.0/10, fd7a:115c:a1e0::/48)
+  allowlist  only the given CIDRs, IPs and domains, plus DNS
+
+Domains are resolved when the policy is applied, i.e. now and each time
+the puck starts. Replies to inbound connections, such as traffic from
+routes and published ports, are always allowed.
+
+The policy is applied to a running puck immediately. It needs nft and
+nsenter on the host.
+
+Examples:
+  puck egress web
+  puck egress web none
+  puck egress web tailnet
+  puck egress web allowlist 10.0.0.0/8 github.com proxy.golang.org
+  puck egress web all`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runEgress,
+}
+
+func runEgress(cmd *cobra.Command, args []string) error {
+	name, err := selectContext(args[0])
+	if err != nil {
+		return err
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 1 {
+		p, err := client.Get(name)
+		if err != nil {
+			return err
+		}
+		fmt.Println(p.Egress.String())
+		return nil
+	}
+
+	p, err := client.EgressSet(name, store.EgressPolicy{Mode: store.EgressMode(args[1]), Allow: args[2:]})
+	if err != nil {
+		return err
+	}
+	infof("Set egress for puck '%s': %s", p.Name, p.Egress.String())
+	return nil
+}
@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/spf13/cobra"
+)
+
+var routeEndpointCmd = &cobra.Command{
+	Use:   "endpoint <name> <endpoint>:<port>",
+	Short: "Serve another of a puck's container ports through the router",
+	Long: `Serve another container port of a puck through the router, at
+/<name>/<endpoint> alongside the puck's own /<name>, which reaches port 80.
+
+Each endpoint gets a host port of its own, and uses the puck's route
+settings (see 'puck route set'). Endpoint paths take precedence over the
+same paths of the puck's own route. The puck's container is recreated to
+publish the port, so the puck must be stopped to add or remove endpoints.
+Endpoints can also be given when the puck is created, with --endpoint.
+
+Examples:
+  puck route endpoint web api:8000
+  puck route endpoint list web
+  puck route endpoint remove web api`,
+	Args: cobra.ExactArgs(2),
+	RunE: runRouteEndpoint,
+}
+
+var routeEndpointListCmd = &cobra.Command{
+	Use:     "list <name>",
+	Aliases: []string{"ls"},
+	Short:   "List a puck's endpoints",
+	Args:    cobra.ExactArgs(1),
+	RunE:    runRouteEndpointList,
+}
+
+var routeEndpointRemoveCmd = &cobra.Command{
+	Use:     "remove <name> <endpoint>",
+	Aliases: []string{"rm"},
+	Short:   "Stop serving a puck's endpoint",
+	Args:    cobra.ExactArgs(2),
+	RunE:    runRouteEndpointRemove,
+}
+
+var routeEndpointFormat string
+
+func init() {
+	addFormatFlag(routeEndpointListCmd, &routeEndpointFormat)
+
+	routeEndpointCmd.AddCommand(routeEndpointListCmd)
+	routeEndpointCmd.AddCommand(routeEndpointRemoveCmd)
+
+	routeCmd.AddCommand(routeEndpointCmd)
+}
+
+func runRouteEndpoint(cmd *cobra.Command, args []string) error {
+	name, err := selectContext(args[0])
+	if err != nil {
+		return err
+	}
+	spec, err := puck.ParseEndpoint(args[1])
+	if err != nil {
+		return err
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	e, err := client.EndpointAdd(name, spec)
+	if err != nil {
+		return err
+	}
+
+	infof("Routing /%s/%s to port %d of puck '%s'", e.PuckName, e.Name, e.ContainerPort, e.PuckName)
+	return nil
+}
+
+func runRouteEndpointList(cmd *cobra.Command, args []string) error {
+	name, err := selectContext(args[0])
+	if err != nil {
+		return err
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	endpoints, err := client.EndpointList(name)
+	if err != nil {
+		return err
+	}
+
+	if routeEndpointFormat != "" {
+		return printFormatted(routeEndpointFormat, endpoints, nil)
+	}
+	if len(endpoints) == 0 {
+		infof("No endpoints. Add one with: puck route endpoint %s <endpoint>:<port>", name)
+		return nil
+	}
+	if quiet {
+		for _, e := range endpoints {
+			fmt.Println(e.Name)
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ENDPOINT\tPATH\tPORT\tHOST PORT")
+	for _, e := range endpoints {
+		hostPort := "-"
+		if e.HostPort > 0 {
+			hostPort = fmt.Sprint(e.HostPort)
+		}
+		fmt.Fprintf(w, "%s\t/%s/%s\t%d\t%s\n", e.Name, e.PuckName, e.Name, e.ContainerPort, hostPort)
+	}
+	return w.Flush()
+}
+
+func runRouteEndpointRemove(cmd *cobra.Command, args []string) error {
+	name, err := selectContext(args[0])
+	if err != nil {
+		return err
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	if err := client.EndpointRemove(name, args[1]); err != nil {
+		return err
+	}
+
+	infof("Removed endpoint %s of puck '%s'", args[1], name)
+	return nil
+}
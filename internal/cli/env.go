@@ -0,0 +1,214 @@
+package cli
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sandwich-labs/puck/internal/daemon"
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var envCmd = &cobra.Command{
+	Use:   "env",
+	Short: "Manage a puck's environment variables",
+	Long: `Manage the environment variables set in a puck.
+
+Variables are kept with the puck and written to /etc/puck/environment
+inside it, which systemd units can read with:
+
+  EnvironmentFile=/etc/puck/environment
+
+The file changes right away. The container itself gets the variables when
+it is next started, as it is created anew then, or recreated.
+
+Values are masked in output when set with --secret or when the name looks
+secret, such as API_KEY, GITHUB_TOKEN or DB_PASSWORD; list them with
+--show-secrets.
+
+Examples:
+  puck env set web PORT=3000 LOG_LEVEL=debug
+  puck env set web --secret STRIPE_KEY=sk_live_...
+  puck env set web GITHUB_TOKEN     # takes the value from your environment
+  puck env list web
+  puck env unset web LOG_LEVEL`,
+}
+
+var envListCmd = &cobra.Command{
+	Use:     "list <puck>",
+	Aliases: []string{"ls"},
+	Short:   "List a puck's environment variables",
+	Args:    cobra.ExactArgs(1),
+	RunE:    runEnvList,
+}
+
+var envSetCmd = &cobra.Command{
+	Use:   "set <puck> KEY=VALUE...",
+	Short: "Set environment variables; KEY alone takes your value",
+	Args:  cobra.MinimumNArgs(2),
+	RunE:  runEnvSet,
+}
+
+var envUnsetCmd = &cobra.Command{
+	Use:   "unset <puck> KEY...",
+	Short: "Remove environment variables",
+	Args:  cobra.MinimumNArgs(2),
+	RunE:  runEnvUnset,
+}
+
+var (
+	envShowSecrets bool
+	envJSON        bool
+	envFormat      string
+	envSecret      bool
+)
+
+// envMask stands in for secret values
+const envMask = "********"
+
+func init() {
+	envListCmd.Flags().BoolVar(&envShowSecrets, "show-secrets", false, "show secret values")
+	envListCmd.Flags().BoolVar(&envJSON, "json", false, "print the variables as JSON")
+	addFormatFlag(envListCmd, &envFormat)
+	envSetCmd.Flags().BoolVar(&envSecret, "secret", false, "mask these values in output")
+
+	envCmd.AddCommand(envListCmd)
+	envCmd.AddCommand(envSetCmd)
+	envCmd.AddCommand(envUnsetCmd)
+}
+
+func runEnvList(cmd *cobra.Command, args []string) error {
+	client, name, err := envClient(args[0])
+	if err != nil {
+		return err
+	}
+
+	p, err := client.Get(name)
+	if err != nil {
+		return err
+	}
+
+	env := make(map[string]string, len(p.Spec.Env))
+	for key := range p.Spec.Env {
+		env[key] = envValue(p.Spec, key, envShowSecrets)
+	}
+	if envJSON {
+		envFormat = "json"
+	}
+	if envFormat != "" {
+		return printFormatted(envFormat, env, nil)
+	}
+	if len(env) == 0 {
+		infof("Puck '%s' has no environment variables. Set some with: puck env set %s KEY=VALUE", p.Name, p.Name)
+		return nil
+	}
+
+	keys := slices.Sorted(maps.Keys(env))
+	if quiet {
+		for _, key := range keys {
+			fmt.Println(key)
+		}
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tVALUE")
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s\t%s\n", key, env[key])
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if p.Resources.Pending && p.Status.Up() {
+		infof("\nChanges apply when the puck is next started: puck stop %s && puck start %s", p.Name, p.Name)
+	}
+	return nil
+}
+
+func runEnvSet(cmd *cobra.Command, args []string) error {
+	set := make(map[string]string, len(args)-1)
+	for _, arg := range args[1:] {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			if value, ok = os.LookupEnv(key); !ok {
+				return fmt.Errorf("%s isn't set in your environment; use %s=VALUE", key, key)
+			}
+		}
+		set[key] = value
+	}
+
+	client, name, err := envClient(args[0])
+	if err != nil {
+		return err
+	}
+	p, err := client.EnvSet(puck.EnvOptions{Name: name, Set: set, Secret: envSecret})
+	if err != nil {
+		return err
+	}
+
+	infof("Set %s on puck '%s'", strings.Join(slices.Sorted(maps.Keys(set)), ", "), p.Name)
+	envApplyHint(p)
+	return nil
+}
+
+func runEnvUnset(cmd *cobra.Command, args []string) error {
+	client, name, err := envClient(args[0])
+	if err != nil {
+		return err
+	}
+	p, err := client.EnvSet(puck.EnvOptions{Name: name, Unset: args[1:]})
+	if err != nil {
+		return err
+	}
+
+	infof("Unset %s on puck '%s'", strings.Join(args[1:], ", "), p.Name)
+	envApplyHint(p)
+	return nil
+}
+
+// envClient connects to the daemon for the puck named by arg
+func envClient(arg string) (*daemon.Client, string, error) {
+	name, err := selectContext(arg)
+	if err != nil {
+		return nil, "", err
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return nil, "", err
+	}
+	return client, name, nil
+}
+
+// envApplyHint says how a running puck picks up its new environment
+func envApplyHint(p *store.Puck) {
+	if p.Status.Up() {
+		infof("/etc/puck/environment is updated; the container gets the change when next started: puck stop %s && puck start %s", p.Name, p.Name)
+	}
+}
+
+// envValue returns a variable's value for output, masked if secret
+func envValue(spec store.Spec, key string, showSecrets bool) string {
+	if !showSecrets && spec.IsSecretEnv(key) {
+		return envMask
+	}
+	return spec.Env[key]
+}
+
+// maskedPuck returns a copy of p with its secret environment values
+// masked, for printing the whole puck with --format
+func maskedPuck(p *store.Puck) *store.Puck {
+	if len(p.Spec.Env) == 0 {
+		return p
+	}
+	masked := *p
+	masked.Spec.Env = make(map[string]string, len(p.Spec.Env))
+	for key := range p.Spec.Env {
+		masked.Spec.Env[key] = envValue(p.Spec, key, false)
+	}
+	return &masked
+}
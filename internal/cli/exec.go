@@ -0,0 +1,252 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var execCmd = &cobra.Command{
+	Use:   "exec <name> [--] <command> [args...] | --script <file> <name> [-- args...]",
+	Short: "Run a command in a running puck",
+	Long: `Run a command in a running puck, like podman exec.
+
+puck exits with the command's status, so scripts can check it. As with
+podman exec, 125 means puck itself failed, 126 that the command couldn't
+be invoked and 127 that it wasn't found.
+
+Piped input is passed to the command without -i, and -t is ignored when
+stdin or stdout isn't a terminal, so puck exec works in pipelines and
+scripts. Without a terminal, input and output are streamed through the
+daemon byte for byte; terminals need podman on this host or an ssh
+context.
+
+--script runs a local script in the puck instead, with any arguments
+after the name, so a multi-line script needs no quoting: the script is
+copied into the puck's /tmp, run and removed. It runs with the
+interpreter its #! line names, or with --interpreter, or /bin/sh. With
+--script -, the script is read from stdin, which the script then doesn't
+get.
+
+Examples:
+  puck exec web -- systemctl status nginx
+  puck exec -it web -- htop
+  puck exec -w /workspace -e GOFLAGS=-mod=mod web -- go test ./...
+  cat data.sql | puck exec db -- psql -U postgres
+  puck exec db -- pg_dump -Fc app > app.dump
+  puck exec --script provision.sh web -- --with-node
+  puck exec --script - --interpreter python3 web <<'EOF'
+  print("hello from", __import__("socket").gethostname())
+  EOF`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runExec,
+}
+
+var (
+	execInteractive bool
+	execTTY         bool
+	execWorkDir     string
+	execEnv         []string
+	execUser        string
+	execScript      string
+	execInterpreter string
+)
+
+// maxExecScript bounds the scripts --script runs. They're passed in an
+// argument, which Linux caps at 128 KiB.
+const maxExecScript = 128<<10 - 1
+
+// scriptRunner is the shell script --script runs in the puck: it writes
+// the script given as $1 to a file and runs it with the interpreter in
+// $2, or as an executable when that's empty, passing the arguments after
+// them and exiting with the script's status
+const scriptRunner = `f=$(mktemp "${TMPDIR:-/tmp}/puck-script.XXXXXX") || exit 125
+trap 'rm -f "$f"' EXIT
+printf '%s' "$1" > "$f" && chmod 700 "$f" || exit 125
+interpreter=$2
+shift 2
+$interpreter "$f" "$@"`
+
+func init() {
+	execCmd.Flags().BoolVarP(&execInteractive, "interactive", "i", false, "keep stdin open")
+	execCmd.Flags().BoolVarP(&execTTY, "tty", "t", false, "allocate a terminal")
+	execCmd.Flags().StringVarP(&execWorkDir, "workdir", "w", "", "directory to run the command in")
+	execCmd.Flags().StringArrayVarP(&execEnv, "env", "e", nil, "set an environment variable as NAME=value (repeatable)")
+	execCmd.Flags().StringVarP(&execUser, "user", "u", "", "user to run the command as")
+	execCmd.Flags().StringVar(&execScript, "script", "", "run a local script in the puck (- reads it from stdin)")
+	execCmd.Flags().StringVar(&execInterpreter, "interpreter", "", "run --script with this interpreter, e.g. bash or python3 (default: its #! line, else /bin/sh)")
+	// Flags after the name belong to the command
+	execCmd.Flags().SetInterspersed(false)
+}
+
+func runExec(cmd *cobra.Command, args []string) error {
+	name, err := selectContext(args[0])
+	if err != nil {
+		return execStatus(err)
+	}
+	command := args[1:]
+	if len(command) > 0 && command[0] == "--" {
+		command = command[1:]
+	}
+
+	// Piped input goes to the command without -i, and a terminal is only
+	// allocated when there is one on both ends
+	stdinTerm := term.IsTerminal(int(os.Stdin.Fd()))
+	interactive := execInteractive || !stdinTerm
+
+	switch {
+	case execScript != "":
+		if command, err = scriptCommand(execScript, execInterpreter, command); err != nil {
+			return execStatus(err)
+		}
+		// The script was read from stdin, so there is none left for it
+		if execScript == "-" {
+			interactive = false
+		}
+	case execInterpreter != "":
+		return execStatus(fmt.Errorf("--interpreter needs --script"))
+	case len(command) == 0:
+		return execStatus(fmt.Errorf("no command given"))
+	}
+	tty := execTTY && stdinTerm && term.IsTerminal(int(os.Stdout.Fd()))
+
+	active, err := config.ActiveContext()
+	if err != nil {
+		return execStatus(err)
+	}
+	if active.Type == config.ContextSSH {
+		return execStatus(remoteExec(active.Host, name, command, interactive, tty))
+	}
+	if !tty {
+		return execStatus(streamExec(name, command, interactive))
+	}
+	if active.Type != config.ContextUnix {
+		return execStatus(fmt.Errorf("-t is not available over %s contexts; add an ssh context for %s", active.Type, active.Address))
+	}
+
+	// Terminals need podman directly, as for puck console
+	ctx := context.Background()
+	mgr, closeMgr, err := localManager(ctx)
+	if err != nil {
+		return execStatus(err)
+	}
+	defer closeMgr()
+
+	return execStatus(mgr.ExecStdio(ctx, name, podman.ExecOptions{
+		Cmd:         command,
+		Interactive: interactive,
+		TTY:         true,
+		WorkDir:     execWorkDir,
+		Env:         execEnv,
+		User:        execUser,
+	}))
+}
+
+// scriptCommand returns the command that runs the script in file, or on
+// stdin for -, with args in a puck
+func scriptCommand(file, interpreter string, args []string) ([]string, error) {
+	var script []byte
+	var err error
+	if file == "-" {
+		script, err = io.ReadAll(io.LimitReader(os.Stdin, maxExecScript+1))
+	} else {
+		script, err = os.ReadFile(file)
+	}
+	switch {
+	case err != nil:
+		return nil, fmt.Errorf("reading script: %w", err)
+	case len(script) > maxExecScript:
+		return nil, fmt.Errorf("script is over 128 KiB, too big to pass to the puck")
+	case bytes.IndexByte(script, 0) >= 0:
+		return nil, fmt.Errorf("%s is not a text script", file)
+	}
+
+	if interpreter == "" && !bytes.HasPrefix(script, []byte("#!")) {
+		interpreter = "/bin/sh"
+	}
+	return append([]string{"/bin/sh", "-c", scriptRunner, "sh", string(script), interpreter}, args...), nil
+}
+
+// remoteExec runs puck exec on the context's host over ssh, which exits
+// with the remote command's status
+func remoteExec(host, name string, command []string, interactive, tty bool) error {
+	var sshArgs []string
+	switch {
+	case tty:
+		sshArgs = []string{"-t"}
+	case interactive:
+		sshArgs = []string{"-T"}
+	default:
+		sshArgs = []string{"-T", "-n"}
+	}
+	sshArgs = append(sshArgs, host, "--", "puck", "exec")
+	if tty {
+		// The remote end sees a terminal, so pass on what was asked for
+		if interactive {
+			sshArgs = append(sshArgs, "-i")
+		}
+		sshArgs = append(sshArgs, "-t")
+	}
+	if execWorkDir != "" {
+		sshArgs = append(sshArgs, "-w", shellQuote(execWorkDir))
+	}
+	for _, env := range execEnv {
+		sshArgs = append(sshArgs, "-e", shellQuote(env))
+	}
+	if execUser != "" {
+		sshArgs = append(sshArgs, "-u", shellQuote(execUser))
+	}
+	sshArgs = append(sshArgs, name, "--")
+	for _, arg := range command {
+		sshArgs = append(sshArgs, shellQuote(arg))
+	}
+
+	ssh := exec.Command("ssh", sshArgs...)
+	ssh.Stdin = os.Stdin
+	ssh.Stdout = os.Stdout
+	ssh.Stderr = os.Stderr
+	return ssh.Run()
+}
+
+// streamExec runs the command through the daemon, streaming stdin when
+// interactive and the output as it comes
+func streamExec(name string, command []string, interactive bool) error {
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	var stdin io.Reader
+	if interactive {
+		stdin = os.Stdin
+	}
+	code, err := client.ExecStream(puck.ExecOptions{
+		Name:    name,
+		Cmd:     command,
+		WorkDir: execWorkDir,
+		Env:     execEnv,
+		User:    execUser,
+	}, stdin, os.Stdout, os.Stderr)
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return &podman.ExitError{Code: code}
+	}
+	return nil
+}
+
+// shellQuote quotes s for the remote shell ssh runs commands with
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
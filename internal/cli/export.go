@@ -0,0 +1,245 @@
+package cli
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/daemon"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var eventsCmd = &cobra.Command{
+	Use:   "events",
+	Short: "Work with pucks' lifecycle events",
+}
+
+var eventsExportCmd = &cobra.Command{
+	Use:   "export [name]",
+	Short: "Export lifecycle events to CSV or SQLite",
+	Long: `Export the lifecycle events of a puck, or of every puck you can see,
+for analysis outside puck without access to the live database.
+
+Events are written as CSV, to stdout unless -o is given, or as an events
+table in a SQLite file when the output ends in .db, .sqlite or .sqlite3
+or --format sqlite is given. A SQLite file may already exist, such as one
+stats were exported to, but must not have an events table yet. Times are
+RFC 3339 in UTC.
+
+Examples:
+  puck events export --since 168h -o events.csv
+  puck events export myapp
+  puck events export -o puck-data.db`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runEventsExport,
+}
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Work with the resource use samples the daemon takes",
+}
+
+var statsExportCmd = &cobra.Command{
+	Use:   "export [name]",
+	Short: "Export resource use samples to CSV or SQLite",
+	Long: `Export the samples of a puck's resource use, or of every puck you can
+see, that the daemon takes every 15 minutes for puck report.
+
+Each sample has whether the puck was up, its CPU (percent of one CPU) and
+memory (bytes) while up, the bytes its volumes and snapshots take, and
+hits: the requests the router has served it since the router started, so
+they count up and drop back to zero when the router restarts.
+
+Output works as for puck events export, with a stats table in SQLite
+files.
+
+Examples:
+  puck stats export --since 24h -o stats.csv
+  puck stats export -o puck-data.db`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runStatsExport,
+}
+
+var (
+	exportSince  time.Duration
+	exportOutput string
+	exportFormat string
+)
+
+// Export formats
+const (
+	exportCSV    = "csv"
+	exportSQLite = "sqlite"
+)
+
+func init() {
+	for _, cmd := range []*cobra.Command{eventsExportCmd, statsExportCmd} {
+		cmd.Flags().DurationVar(&exportSince, "since", 0, "only export from this long ago (e.g. 168h)")
+		cmd.Flags().StringVarP(&exportOutput, "output", "o", "-", "file to write to, or - for stdout")
+		cmd.Flags().StringVar(&exportFormat, "format", "", "csv or sqlite (default from the output's extension, else csv)")
+	}
+	eventsCmd.AddCommand(eventsExportCmd)
+	statsCmd.AddCommand(statsExportCmd)
+}
+
+func runEventsExport(cmd *cobra.Command, args []string) error {
+	format, err := exportOutputFormat()
+	if err != nil {
+		return err
+	}
+	client, name, err := exportClient(args)
+	if err != nil {
+		return err
+	}
+	events, err := client.ExportEvents(name, exportStart())
+	if err != nil {
+		return err
+	}
+
+	if format == exportSQLite {
+		if err := store.ExportEvents(context.Background(), exportOutput, events); err != nil {
+			return err
+		}
+	} else {
+		header := []string{"id", "puck", "type", "detail", "time"}
+		err := writeExportCSV(header, len(events), func(i int) []string {
+			e := events[i]
+			return []string{strconv.FormatInt(e.ID, 10), e.PuckName, string(e.Type), e.Detail, e.CreatedAt.UTC().Format(store.ExportTimeFormat)}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	exported(len(events), "events")
+	return nil
+}
+
+func runStatsExport(cmd *cobra.Command, args []string) error {
+	format, err := exportOutputFormat()
+	if err != nil {
+		return err
+	}
+	client, name, err := exportClient(args)
+	if err != nil {
+		return err
+	}
+	stats, err := client.ExportStats(name, exportStart())
+	if err != nil {
+		return err
+	}
+
+	if format == exportSQLite {
+		if err := store.ExportStats(context.Background(), exportOutput, stats); err != nil {
+			return err
+		}
+	} else {
+		header := []string{"id", "puck", "time", "up", "cpu", "memory", "disk", "hits"}
+		err := writeExportCSV(header, len(stats), func(i int) []string {
+			s := stats[i]
+			return []string{
+				strconv.FormatInt(s.ID, 10),
+				s.PuckName,
+				s.CreatedAt.UTC().Format(store.ExportTimeFormat),
+				strconv.FormatBool(s.Up),
+				strconv.FormatFloat(s.CPU, 'f', -1, 64),
+				strconv.FormatInt(s.Memory, 10),
+				strconv.FormatInt(s.Disk, 10),
+				strconv.FormatUint(s.Hits, 10),
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	exported(len(stats), "samples")
+	return nil
+}
+
+// exportOutputFormat works out what to write from --format and the
+// output's extension
+func exportOutputFormat() (string, error) {
+	format := exportFormat
+	if format == "" {
+		format = exportCSV
+		switch filepath.Ext(exportOutput) {
+		case ".db", ".sqlite", ".sqlite3":
+			format = exportSQLite
+		}
+	}
+	switch format {
+	case exportCSV:
+		return format, nil
+	case exportSQLite:
+		if exportOutput == "-" {
+			return "", fmt.Errorf("SQLite output needs a file; give one with -o")
+		}
+		return format, nil
+	}
+	return "", fmt.Errorf("invalid format %q (expected csv or sqlite)", exportFormat)
+}
+
+// exportClient connects to the daemon and returns the puck named in
+// args, if any
+func exportClient(args []string) (*daemon.Client, string, error) {
+	var name string
+	if len(args) > 0 {
+		var err error
+		if name, err = selectContext(args[0]); err != nil {
+			return nil, "", err
+		}
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return nil, "", err
+	}
+	return client, name, nil
+}
+
+// exportStart is the time --since reaches back to, or zero for all
+func exportStart() time.Time {
+	if exportSince <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(-exportSince)
+}
+
+// writeExportCSV writes a header and n records to the output
+func writeExportCSV(header []string, n int, record func(i int) []string) error {
+	if exportOutput == "-" {
+		return writeCSV(os.Stdout, header, n, record)
+	}
+
+	f, err := os.Create(exportOutput)
+	if err != nil {
+		return err
+	}
+	err = writeCSV(f, header, n, record)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func writeCSV(out io.Writer, header []string, n int, record func(i int) []string) error {
+	w := csv.NewWriter(out)
+	w.Write(header)
+	for i := range n {
+		w.Write(record(i))
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// exported reports what was written to a file
+func exported(n int, what string) {
+	if exportOutput != "-" {
+		infof("Exported %d %s to %s", n, what, exportOutput)
+	}
+}
@@ -0,0 +1,343 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/spf13/cobra"
+)
+
+// formatUsage is the help for a read command's --format flag
+const formatUsage = `print with a Go template, "table <template>", "json" or "jsonpath=<expr>"`
+
+// addFormatFlag gives a read command --format. Commands with a --json
+// flag keep it as a shorthand for --format json.
+func addFormatFlag(cmd *cobra.Command, format *string) {
+	cmd.Flags().StringVar(format, "format", "", formatUsage)
+}
+
+// formatFuncs are the template functions --format offers, as podman's
+// and docker's do
+var formatFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// formatField matches a field reference in a --format template
+var formatField = regexp.MustCompile(`\{\{\s*\.(\w+)\s*\}\}`)
+
+// printFormatted prints a read command's result v as --format asks:
+//
+//   - "json" prints it as indented JSON
+//   - "jsonpath=<expr>" picks values out of that JSON, as kubectl does
+//   - anything else is a Go template, run once for each item when v is a
+//     slice and once otherwise
+//   - "table <template>" aligns the template's columns under a header
+//     naming the fields, or their titles in headers
+func printFormatted(format string, v any, headers map[string]string) error {
+	switch {
+	case format == "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(nonNilSlice(v))
+	case strings.HasPrefix(format, "jsonpath="):
+		return printJSONPath(os.Stdout, strings.TrimPrefix(format, "jsonpath="), v)
+	}
+
+	// Like podman, accept a literal \t or \n typed into the shell
+	format = strings.NewReplacer(`\t`, "\t", `\n`, "\n").Replace(format)
+
+	table := strings.HasPrefix(format, "table")
+	if table {
+		format = strings.TrimSpace(strings.TrimPrefix(format, "table"))
+	}
+	tmpl, err := template.New("format").Funcs(formatFuncs).Parse(format + "\n")
+	if err != nil {
+		return fmt.Errorf("invalid --format: %w", err)
+	}
+
+	items := []any{v}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice {
+		items = make([]any, rv.Len())
+		for i := range items {
+			items[i] = rv.Index(i).Interface()
+		}
+	}
+
+	var out io.Writer = os.Stdout
+	var w *tabwriter.Writer
+	if table {
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		out = w
+		fmt.Fprintln(w, formatField.ReplaceAllStringFunc(format, func(ref string) string {
+			field := formatField.FindStringSubmatch(ref)[1]
+			if header, ok := headers[field]; ok {
+				return header
+			}
+			return strings.ToUpper(field)
+		}))
+	}
+	for _, item := range items {
+		if err := tmpl.Execute(out, item); err != nil {
+			return fmt.Errorf("invalid --format: %w", err)
+		}
+	}
+	if w != nil {
+		return w.Flush()
+	}
+	return nil
+}
+
+// nonNilSlice turns a nil slice into an empty one, so it prints as []
+// rather than null
+func nonNilSlice(v any) any {
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice && rv.IsNil() {
+		return reflect.MakeSlice(rv.Type(), 0, 0).Interface()
+	}
+	return v
+}
+
+// jsonPathNode is a piece of a JSONPath template: literal text, a path
+// whose values are printed, or a range over a path's values
+type jsonPathNode struct {
+	text   string
+	path   []jsonPathStep
+	body   []jsonPathNode // for {range}
+	isPath bool
+	isRng  bool
+}
+
+// jsonPathStep is one step of a path: a field (* for all of them), an
+// index, or [*] for every element
+type jsonPathStep struct {
+	field string
+	index int
+	isIdx bool
+	all   bool
+}
+
+// printJSONPath prints a kubectl-style JSONPath template over v as JSON.
+// Text in {} is a path such as {.spec.env} or {[*].name}, where [n] picks
+// an element and [*] all of them; {range <path>}...{end} repeats for each
+// value and {"\n"} prints a literal. A template without braces is a path.
+// Fields that are missing print nothing.
+func printJSONPath(out io.Writer, expr string, v any) error {
+	nodes, err := parseJSONPath(expr)
+	if err != nil {
+		return fmt.Errorf("invalid --format: %w", err)
+	}
+
+	data, err := json.Marshal(nonNilSlice(v))
+	if err != nil {
+		return err
+	}
+	var doc any
+	dec := json.NewDecoder(strings.NewReader(string(data)))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	writeJSONPath(&b, nodes, doc)
+	s := b.String()
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		s += "\n"
+	}
+	_, err = io.WriteString(out, s)
+	return err
+}
+
+// parseJSONPath parses a JSONPath template into its nodes
+func parseJSONPath(expr string) ([]jsonPathNode, error) {
+	if !strings.Contains(expr, "{") {
+		expr = "{" + expr + "}"
+	}
+	nodes, _, err := parseJSONPathNodes(expr, false)
+	return nodes, err
+}
+
+// parseJSONPathNodes parses nodes up to the end of s, or inside a range
+// up to its {end}, returning what follows
+func parseJSONPathNodes(s string, inRange bool) ([]jsonPathNode, string, error) {
+	var nodes []jsonPathNode
+	for s != "" {
+		open := strings.IndexByte(s, '{')
+		if open < 0 {
+			nodes = append(nodes, jsonPathNode{text: s})
+			break
+		}
+		if open > 0 {
+			nodes = append(nodes, jsonPathNode{text: s[:open]})
+		}
+		end := strings.IndexByte(s[open:], '}')
+		if end < 0 {
+			return nil, "", fmt.Errorf("unclosed { in %q", s[open:])
+		}
+		expr := strings.TrimSpace(s[open+1 : open+end])
+		s = s[open+end+1:]
+
+		switch {
+		case expr == "end":
+			if !inRange {
+				return nil, "", fmt.Errorf("{end} without {range}")
+			}
+			return nodes, s, nil
+		case strings.HasPrefix(expr, "range "):
+			path, err := parseJSONPathSteps(strings.TrimPrefix(expr, "range "))
+			if err != nil {
+				return nil, "", err
+			}
+			body, rest, err := parseJSONPathNodes(s, true)
+			if err != nil {
+				return nil, "", err
+			}
+			nodes = append(nodes, jsonPathNode{path: path, body: body, isPath: true, isRng: true})
+			s = rest
+		case strings.HasPrefix(expr, `"`):
+			text, err := strconv.Unquote(expr)
+			if err != nil {
+				return nil, "", fmt.Errorf("invalid literal %s", expr)
+			}
+			nodes = append(nodes, jsonPathNode{text: text})
+		default:
+			path, err := parseJSONPathSteps(expr)
+			if err != nil {
+				return nil, "", err
+			}
+			nodes = append(nodes, jsonPathNode{path: path, isPath: true})
+		}
+	}
+	if inRange {
+		return nil, "", fmt.Errorf("{range} without {end}")
+	}
+	return nodes, "", nil
+}
+
+// parseJSONPathSteps parses a path such as $.spec.env or [*].name. An
+// empty path, or ., is the current value.
+func parseJSONPathSteps(expr string) ([]jsonPathStep, error) {
+	orig := expr
+	expr = strings.TrimPrefix(strings.TrimSpace(expr), "$")
+	var steps []jsonPathStep
+	for expr != "" {
+		switch expr[0] {
+		case '.':
+			expr = expr[1:]
+			n := strings.IndexAny(expr, ".[")
+			if n < 0 {
+				n = len(expr)
+			}
+			if n > 0 {
+				steps = append(steps, jsonPathStep{field: expr[:n], all: expr[:n] == "*"})
+			}
+			expr = expr[n:]
+		case '[':
+			n := strings.IndexByte(expr, ']')
+			if n < 0 {
+				return nil, fmt.Errorf("unclosed [ in %q", orig)
+			}
+			sel := expr[1:n]
+			expr = expr[n+1:]
+			switch {
+			case sel == "*":
+				steps = append(steps, jsonPathStep{all: true})
+			case len(sel) >= 2 && sel[0] == '\'' && sel[len(sel)-1] == '\'':
+				steps = append(steps, jsonPathStep{field: sel[1 : len(sel)-1]})
+			default:
+				i, err := strconv.Atoi(sel)
+				if err != nil {
+					return nil, fmt.Errorf("invalid index [%s] in %q", sel, orig)
+				}
+				steps = append(steps, jsonPathStep{index: i, isIdx: true})
+			}
+		default:
+			return nil, fmt.Errorf("invalid path %q (expected it to start with . or [)", orig)
+		}
+	}
+	return steps, nil
+}
+
+// evalJSONPath returns the values a path picks out of v
+func evalJSONPath(path []jsonPathStep, v any) []any {
+	values := []any{v}
+	for _, step := range path {
+		var next []any
+		for _, cur := range values {
+			switch cur := cur.(type) {
+			case map[string]any:
+				switch {
+				case step.all:
+					for _, k := range slices.Sorted(maps.Keys(cur)) {
+						next = append(next, cur[k])
+					}
+				case !step.isIdx:
+					if val, ok := cur[step.field]; ok {
+						next = append(next, val)
+					}
+				}
+			case []any:
+				switch {
+				case step.all:
+					next = append(next, cur...)
+				case step.isIdx:
+					i := step.index
+					if i < 0 {
+						i += len(cur)
+					}
+					if i >= 0 && i < len(cur) {
+						next = append(next, cur[i])
+					}
+				}
+			}
+		}
+		values = next
+	}
+	return values
+}
+
+// writeJSONPath writes the nodes evaluated against v. Several values from
+// one path are separated by spaces.
+func writeJSONPath(b *strings.Builder, nodes []jsonPathNode, v any) {
+	for _, n := range nodes {
+		switch {
+		case n.isRng:
+			for _, item := range evalJSONPath(n.path, v) {
+				writeJSONPath(b, n.body, item)
+			}
+		case n.isPath:
+			for i, val := range evalJSONPath(n.path, v) {
+				if i > 0 {
+					b.WriteByte(' ')
+				}
+				b.WriteString(jsonPathString(val))
+			}
+		default:
+			b.WriteString(n.text)
+		}
+	}
+}
+
+// jsonPathString prints a JSON value: strings bare, anything else as JSON
+func jsonPathString(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
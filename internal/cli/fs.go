@@ -0,0 +1,196 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dustin/go-humanize"
+	"github.com/sandwich-labs/puck/internal/daemon"
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/spf13/cobra"
+)
+
+var fsCmd = &cobra.Command{
+	Use:   "fs",
+	Short: "Browse the files in a running puck",
+	Long: `Browse the files in a running puck, read-only, through the daemon.
+
+Paths are given as <puck>:<path>. Relative paths are from the container's
+working directory, and ls without a path lists /.
+
+Examples:
+  puck fs ls web:/etc/nginx
+  puck fs stat web:/etc/puck/environment
+  puck fs cat web:/var/log/app.log | less`,
+}
+
+var fsLsCmd = &cobra.Command{
+	Use:   "ls <puck>[:<path>]",
+	Short: "List a directory in a puck",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runFSLs,
+}
+
+var fsStatCmd = &cobra.Command{
+	Use:   "stat <puck>:<path>",
+	Short: "Show a file's details",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runFSStat,
+}
+
+var fsCatCmd = &cobra.Command{
+	Use:   "cat <puck>:<path>",
+	Short: "Print a file",
+	Long: fmt.Sprintf(`Print a file from a puck to stdout.
+
+Files are read through the daemon up to %d MiB; copy larger ones with:
+
+  puck exec <puck> -- cat <path> > file`, puck.MaxFileRead>>20),
+	Args: cobra.ExactArgs(1),
+	RunE: runFSCat,
+}
+
+var (
+	fsAll    bool
+	fsJSON   bool
+	fsFormat string
+)
+
+func init() {
+	fsLsCmd.Flags().BoolVarP(&fsAll, "all", "a", false, "include entries starting with .")
+	fsLsCmd.Flags().BoolVar(&fsJSON, "json", false, "print the entries as JSON")
+	fsStatCmd.Flags().BoolVar(&fsJSON, "json", false, "print the details as JSON")
+	addFormatFlag(fsLsCmd, &fsFormat)
+	addFormatFlag(fsStatCmd, &fsFormat)
+
+	fsCmd.AddCommand(fsLsCmd)
+	fsCmd.AddCommand(fsStatCmd)
+	fsCmd.AddCommand(fsCatCmd)
+}
+
+func runFSLs(cmd *cobra.Command, args []string) error {
+	client, opts, err := fsClient(args[0], "/")
+	if err != nil {
+		return err
+	}
+	files, err := client.FSList(opts)
+	if err != nil {
+		return err
+	}
+
+	if !fsAll {
+		shown := files[:0]
+		for _, f := range files {
+			if !strings.HasPrefix(f.Name, ".") {
+				shown = append(shown, f)
+			}
+		}
+		files = shown
+	}
+	if fsJSON {
+		fsFormat = "json"
+	}
+	if fsFormat != "" {
+		return printFormatted(fsFormat, files, nil)
+	}
+	if quiet {
+		for _, f := range files {
+			fmt.Println(f.Name)
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MODE\tOWNER\tGROUP\tSIZE\tMODIFIED\tNAME")
+	for _, f := range files {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			f.Mode, f.Owner, f.Group, humanize.Bytes(uint64(f.Size)), humanize.Time(f.ModTime), fsName(f))
+	}
+	return w.Flush()
+}
+
+func runFSStat(cmd *cobra.Command, args []string) error {
+	client, opts, err := fsClient(args[0], "")
+	if err != nil {
+		return err
+	}
+	info, err := client.FSStat(opts)
+	if err != nil {
+		return err
+	}
+
+	if fsJSON {
+		fsFormat = "json"
+	}
+	if fsFormat != "" {
+		return printFormatted(fsFormat, info, nil)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Path:\t%s\n", info.Path)
+	fmt.Fprintf(w, "Type:\t%s\n", info.Type)
+	if info.Target != "" {
+		fmt.Fprintf(w, "Target:\t%s\n", info.Target)
+	}
+	fmt.Fprintf(w, "Mode:\t%s (%04o)\n", info.Mode, info.Mode.Perm())
+	fmt.Fprintf(w, "Owner:\t%s:%s\n", info.Owner, info.Group)
+	fmt.Fprintf(w, "Size:\t%s (%d bytes)\n", humanize.Bytes(uint64(info.Size)), info.Size)
+	fmt.Fprintf(w, "Modified:\t%s (%s)\n", info.ModTime.Local().Format("2006-01-02 15:04:05"), humanize.Time(info.ModTime))
+	return w.Flush()
+}
+
+func runFSCat(cmd *cobra.Command, args []string) error {
+	client, opts, err := fsClient(args[0], "")
+	if err != nil {
+		return err
+	}
+	content, err := client.FSRead(opts)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Stdout.Write(content.Data); err != nil {
+		return err
+	}
+	if content.Truncated {
+		return fmt.Errorf("%s is larger than %d MiB, so only the start was printed; copy it with: puck exec %s -- cat %s > file",
+			opts.Path, puck.MaxFileRead>>20, opts.Name, opts.Path)
+	}
+	return nil
+}
+
+// fsClient connects to the daemon for the puck and path named by a
+// <puck>:<path> argument. Without a path it uses defaultPath, or fails
+// when that is empty.
+func fsClient(arg, defaultPath string) (*daemon.Client, puck.FSOptions, error) {
+	name, path, _ := strings.Cut(arg, ":")
+	if path == "" {
+		path = defaultPath
+	}
+	if name == "" || path == "" {
+		return nil, puck.FSOptions{}, fmt.Errorf("invalid path %q (expected <puck>:<path>)", arg)
+	}
+	name, err := selectContext(name)
+	if err != nil {
+		return nil, puck.FSOptions{}, err
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return nil, puck.FSOptions{}, err
+	}
+	return client, puck.FSOptions{Name: name, Path: path}, nil
+}
+
+// fsName is how ls shows an entry's name, marking directories and
+// symlinks as ls -F and ls -l do
+func fsName(f puck.FileInfo) string {
+	switch f.Type {
+	case "dir":
+		return f.Name + "/"
+	case "symlink":
+		return f.Name + " -> " + f.Target
+	}
+	return f.Name
+}
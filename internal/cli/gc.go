@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/docker/go-units"
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/spf13/cobra"
+)
+
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Remove unused images and build cache",
+	Long: `Remove images that no puck or container uses, to reclaim disk space.
+
+This removes dangling images, the build cache, and tagged images that no
+puck was created from and no container uses. Snapshot images are kept;
+they are removed with their snapshots. So are images in warm_images. Use --keep-last to keep the newest
+unused images of each repository, e.g. to roll back to a previous build.
+
+Examples:
+  puck gc --dry-run
+  puck gc --keep-last 2`,
+	Args: cobra.NoArgs,
+	RunE: runGC,
+}
+
+var (
+	gcKeepLast int
+	gcDryRun   bool
+)
+
+func init() {
+	gcCmd.Flags().IntVar(&gcKeepLast, "keep-last", 0, "newest unused images to keep per repository")
+	gcCmd.Flags().BoolVar(&gcDryRun, "dry-run", false, "show what would be removed without removing it")
+}
+
+func runGC(cmd *cobra.Command, args []string) error {
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	report, err := client.GC(puck.GCOptions{KeepLast: gcKeepLast, DryRun: gcDryRun})
+	if err != nil {
+		return err
+	}
+
+	for _, msg := range report.Errors {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", msg)
+	}
+
+	if len(report.Removed) == 0 {
+		infof("Nothing to remove.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "IMAGE\tNAME\tSIZE\tREASON")
+	for _, img := range report.Removed {
+		size := "-"
+		if img.Size > 0 {
+			size = units.HumanSize(float64(img.Size))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", shortID(img.ID), valueOr(img.Name, "<none>"), size, img.Reason)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	verb := "Reclaimed"
+	if gcDryRun {
+		verb = "Would reclaim"
+	}
+	infof("\n%s %s from %d images.", verb, units.HumanSize(float64(report.Reclaimed)), len(report.Removed))
+	return nil
+}
+
+// shortID abbreviates an image ID the way podman images does
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
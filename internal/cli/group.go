@@ -0,0 +1,166 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dustin/go-humanize"
+	"github.com/sandwich-labs/puck/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var groupCmd = &cobra.Command{
+	Use:   "group",
+	Short: "Name sets of pucks to act on together",
+	Long: `Name a set of pucks, such as the ones making up a demo, so commands can
+act on all of them at once by giving @<group> in place of a puck name:
+
+  puck start @demo
+  puck stop @demo
+  puck snapshot create @demo before-upgrade
+  puck list --group demo
+
+Unlike a stack (see 'puck create --requires'), a group says nothing about
+how its pucks depend on each other; its pucks are acted on one after
+another, and one failing doesn't stop the rest. A puck can be in any
+number of groups, and destroying it takes it out of them. Groups are your
+own: another user's @demo is a different group.
+
+Examples:
+  puck group add demo web api db
+  puck group list
+  puck group remove demo db
+  puck group remove demo`,
+}
+
+var groupAddCmd = &cobra.Command{
+	Use:   "add <group> <puck>...",
+	Short: "Add pucks to a group, creating it if needed",
+	Args:  cobra.MinimumNArgs(2),
+	RunE:  runGroupAdd,
+}
+
+var groupRemoveCmd = &cobra.Command{
+	Use:     "remove <group> [puck...]",
+	Aliases: []string{"rm"},
+	Short:   "Remove pucks from a group, or the whole group",
+	Long: `Remove pucks from a group. Without pucks the whole group is removed;
+the pucks themselves are left alone.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runGroupRemove,
+}
+
+var groupListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List your groups and their pucks",
+	Args:    cobra.NoArgs,
+	RunE:    runGroupList,
+}
+
+var groupFormat string
+
+func init() {
+	addFormatFlag(groupListCmd, &groupFormat)
+
+	groupCmd.AddCommand(groupAddCmd)
+	groupCmd.AddCommand(groupRemoveCmd)
+	groupCmd.AddCommand(groupListCmd)
+}
+
+func runGroupAdd(cmd *cobra.Command, args []string) error {
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	g, err := client.GroupAdd(strings.TrimPrefix(args[0], "@"), args[1:])
+	if err != nil {
+		return err
+	}
+
+	infof("Group @%s: %s", g.Name, strings.Join(g.Members, ", "))
+	return nil
+}
+
+func runGroupRemove(cmd *cobra.Command, args []string) error {
+	name := strings.TrimPrefix(args[0], "@")
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	g, err := client.GroupRemove(name, args[1:])
+	if err != nil {
+		return err
+	}
+
+	if g == nil {
+		infof("Removed group @%s", name)
+		return nil
+	}
+	infof("Group @%s: %s", g.Name, strings.Join(g.Members, ", "))
+	return nil
+}
+
+func runGroupList(cmd *cobra.Command, args []string) error {
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	groups, err := client.GroupList()
+	if err != nil {
+		return err
+	}
+
+	if groupFormat != "" {
+		return printFormatted(groupFormat, groups, nil)
+	}
+	if len(groups) == 0 {
+		infof("No groups. Create one with: puck group add <group> <puck>...")
+		return nil
+	}
+	if quiet {
+		for _, g := range groups {
+			fmt.Println(g.Name)
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "GROUP\tPUCKS\tCREATED")
+	for _, g := range groups {
+		fmt.Fprintf(w, "@%s\t%s\t%s\n", g.Name, strings.Join(g.Members, ", "), humanize.Time(g.CreatedAt))
+	}
+	return w.Flush()
+}
+
+// groupName returns the group a puck argument names as @<group>
+func groupName(arg string) (string, bool) {
+	return strings.CutPrefix(arg, "@")
+}
+
+// forEachInGroup runs fn on each puck of one of the caller's groups in
+// turn, reporting how each went with done, e.g. "Started". One puck
+// failing doesn't stop the rest, but fails the command.
+func forEachInGroup(client *daemon.Client, group, action, done string, fn func(name string) error) error {
+	g, err := client.Group(group)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, name := range g.Members {
+		if err := fn(name); err != nil {
+			failed++
+			infof("Failed to %s puck '%s': %v", action, name, err)
+			continue
+		}
+		infof("%s puck '%s'", done, name)
+	}
+	return batchStatus(action, failed, len(g.Members))
+}
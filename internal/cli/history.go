@@ -0,0 +1,124 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history <name>",
+	Short: "Show a puck's lifecycle history",
+	Long: `Show when a puck was created, started, stopped, updated, snapshotted,
+restored, or found crashed, oldest first.
+
+Events that change whether the puck is running show how long that state
+lasted, so gaps in uptime are easy to spot.
+
+Examples:
+  puck history myapp
+  puck history myapp --since 24h`,
+	Args: cobra.ExactArgs(1),
+	RunE: runHistory,
+}
+
+var (
+	historySince  time.Duration
+	historyFormat string
+)
+
+func init() {
+	historyCmd.Flags().DurationVar(&historySince, "since", 0, "only show events from this long ago (e.g. 24h)")
+	addFormatFlag(historyCmd, &historyFormat)
+}
+
+func runHistory(cmd *cobra.Command, args []string) error {
+	name, err := selectContext(args[0])
+	if err != nil {
+		return err
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	var since time.Time
+	if historySince > 0 {
+		since = time.Now().Add(-historySince)
+	}
+
+	events, err := client.History(name, since)
+	if err != nil {
+		return err
+	}
+
+	if historyFormat != "" {
+		return printFormatted(historyFormat, events, nil)
+	}
+	if len(events) == 0 {
+		infof("No history for puck '%s'", name)
+		return nil
+	}
+
+	durations := stateDurations(events, time.Now())
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tEVENT\tDETAIL\tLASTED")
+	for i, e := range events {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+			e.CreatedAt.Local().Format("2006-01-02 15:04:05"),
+			e.Type,
+			valueOr(e.Detail, "-"),
+			durations[i],
+		)
+	}
+	w.Flush()
+
+	return nil
+}
+
+// stateDurations describes how long the state entered by each event lasted,
+// up to the next event that changed it or now
+func stateDurations(events []*store.Event, now time.Time) []string {
+	durations := make([]string, len(events))
+	for i, e := range events {
+		durations[i] = "-"
+		if _, ok := e.Type.RunningAfter(); !ok {
+			continue
+		}
+
+		end, ongoing := now, true
+		for _, next := range events[i+1:] {
+			if _, ok := next.Type.RunningAfter(); ok {
+				end, ongoing = next.CreatedAt, false
+				break
+			}
+		}
+
+		d := formatDuration(end.Sub(e.CreatedAt))
+		if ongoing {
+			d += " (ongoing)"
+		}
+		durations[i] = d
+	}
+	return durations
+}
+
+// formatDuration renders d to the two most significant units, e.g. "3h12m"
+func formatDuration(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh%dm", int(d.Hours()), int(d.Minutes())%60)
+	default:
+		return fmt.Sprintf("%dd%dh", int(d.Hours())/24, int(d.Hours())%24)
+	}
+}
@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/dustin/go-humanize"
+	"github.com/sandwich-labs/puck/internal/hooks"
+	"github.com/spf13/cobra"
+)
+
+var hooksCmd = &cobra.Command{
+	Use:   "hooks",
+	Short: "List event hooks and their last results",
+	Long: `List the executables the daemon runs on puck events.
+
+Every executable in the hooks directory (default ~/.config/puck/hooks.d)
+runs once per event with a JSON payload on stdin. PUCK_EVENT and
+PUCK_NAME are also set in the environment. Events:
+
+  puck.created, puck.started, puck.stopped, puck.recreated, puck.destroyed,
+  puck.checkpointed, puck.port_changed, puck.promoted, snapshot.created,
+  snapshot.restored
+
+Hooks that exit non-zero or exceed hook_timeout are reported here and in
+the daemon log; they never block the operation that triggered them.`,
+	Args: cobra.NoArgs,
+	RunE: runHooks,
+}
+
+func runHooks(cmd *cobra.Command, args []string) error {
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	st, err := client.Hooks()
+	if err != nil {
+		return err
+	}
+
+	if len(st.Hooks) == 0 {
+		infof("No hooks found. Add executables to: %s", st.Dir)
+		return nil
+	}
+
+	last := make(map[string]hooks.Result, len(st.Results))
+	for _, r := range st.Results {
+		last[r.Hook] = r
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "HOOK\tLAST EVENT\tRESULT\tWHEN")
+
+	for _, name := range st.Hooks {
+		r, ok := last[name]
+		if !ok {
+			fmt.Fprintf(w, "%s\t-\t-\t-\n", name)
+			continue
+		}
+		result := "ok"
+		if r.Failed() {
+			result = "failed: " + r.Error
+		}
+		fmt.Fprintf(w, "%s\t%s %s\t%s\t%s\n", name, r.Event, r.Puck, result, humanize.Time(r.Time))
+	}
+
+	return w.Flush()
+}
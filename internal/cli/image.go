@@ -0,0 +1,222 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dustin/go-humanize"
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/spf13/cobra"
+)
+
+var imageCmd = &cobra.Command{
+	Use:   "image",
+	Short: "Inspect the images pucks are created from",
+}
+
+var imageListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List local images and the warm image cache",
+	Long: `List the images in local storage, with how many pucks were created from
+each, and the images kept warm.
+
+Images named in warm_images in the config are kept pulled by the daemon,
+so creating pucks from them doesn't wait on a pull: missing ones are
+pulled as soon as the daemon notices, and every one is pulled again once
+a day during warm_window (local time, by default 02:00-06:00). Their
+STATUS shows when the daemon last pulled them and any pull that failed.
+puck gc leaves them alone.
+
+Examples:
+  puck image list
+  puck image list --json`,
+	Args: cobra.NoArgs,
+	RunE: runImageList,
+}
+
+var imageTreeCmd = &cobra.Command{
+	Use:   "tree",
+	Short: "Show which layers the images of pucks share and what each image costs",
+	Long: `Show how the images pucks are created from share layers on disk, to
+find pucks that could move onto a common base.
+
+Images built on the same base share its layers in local storage, so the
+tree branches where images stop sharing. Each node is a run of layers
+with the space they take, and names the images that end there. The table
+then gives, for each image, its pucks, its size, what of that it shares
+with the images of other pucks and its marginal cost: the layers only it
+has, which is the space its pucks would save by moving to another image.
+
+Sizes are of the uncompressed layers, as podman image inspect and podman
+image history report them. A layer is only shared when the layers below
+it are the same too.
+
+Examples:
+  puck image tree
+  puck image tree --json`,
+	Args: cobra.NoArgs,
+	RunE: runImageTree,
+}
+
+var (
+	imageListJSON   bool
+	imageListFormat string
+	imageTreeJSON   bool
+	imageTreeFormat string
+)
+
+func init() {
+	imageListCmd.Flags().BoolVar(&imageListJSON, "json", false, "print the images as JSON")
+	addFormatFlag(imageListCmd, &imageListFormat)
+	imageCmd.AddCommand(imageListCmd)
+
+	imageTreeCmd.Flags().BoolVar(&imageTreeJSON, "json", false, "print the tree and the images as JSON")
+	addFormatFlag(imageTreeCmd, &imageTreeFormat)
+	imageCmd.AddCommand(imageTreeCmd)
+}
+
+func runImageList(cmd *cobra.Command, args []string) error {
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	images, err := client.Images()
+	if err != nil {
+		return err
+	}
+
+	if imageListJSON {
+		imageListFormat = "json"
+	}
+	if imageListFormat != "" {
+		return printFormatted(imageListFormat, images, nil)
+	}
+	if len(images) == 0 {
+		infof("No images.")
+		return nil
+	}
+	if quiet {
+		for _, img := range images {
+			fmt.Println(img.Name)
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "IMAGE\tID\tSIZE\tCREATED\tPUCKS\tSTATUS")
+	for _, img := range images {
+		id, size, created := "-", "-", "-"
+		if img.Present {
+			id = img.ID[:min(12, len(img.ID))]
+			size = humanize.Bytes(uint64(img.Size))
+			created = humanize.Time(img.Created)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", img.Name, id, size, created, img.Pucks, imageStatus(img))
+	}
+	return w.Flush()
+}
+
+// imageStatus describes where an image stands in the warm cache
+func imageStatus(img puck.ImageInfo) string {
+	if !img.Warm {
+		return "-"
+	}
+	var parts []string
+	switch {
+	case img.Pulling:
+		parts = append(parts, "warm, pulling")
+	case !img.Present:
+		parts = append(parts, "warm, missing")
+	case img.Refreshed != nil:
+		parts = append(parts, "warm, pulled "+humanize.Time(*img.Refreshed))
+	default:
+		parts = append(parts, "warm")
+	}
+	if img.Error != "" {
+		parts = append(parts, "last pull failed: "+img.Error)
+	}
+	return strings.Join(parts, "; ")
+}
+
+func runImageTree(cmd *cobra.Command, args []string) error {
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	tree, err := client.ImageTree()
+	if err != nil {
+		return err
+	}
+
+	if imageTreeJSON {
+		imageTreeFormat = "json"
+	}
+	if imageTreeFormat != "" {
+		return printFormatted(imageTreeFormat, tree, nil)
+	}
+	for _, ref := range tree.Missing {
+		infof("Image %s of some pucks is not in local storage", ref)
+	}
+	if len(tree.Images) == 0 {
+		infof("No puck images in local storage.")
+		return nil
+	}
+
+	fmt.Printf("local storage (%s)\n", humanize.Bytes(uint64(tree.Total)))
+	printLayerNodes(tree.Roots, "")
+	fmt.Println()
+
+	var sum int64
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "IMAGE\tPUCKS\tLAYERS\tSIZE\tSHARED\tMARGINAL")
+	for _, img := range tree.Images {
+		sum += img.Size
+		pucks := img.Pucks
+		if img.Others > 0 {
+			pucks = append(pucks, fmt.Sprintf("%d of other users", img.Others))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\n", img.Image, strings.Join(pucks, ", "), img.Layers,
+			humanize.Bytes(uint64(img.Size)), humanize.Bytes(uint64(img.Shared)), humanize.Bytes(uint64(img.Marginal)))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if saved := sum - tree.Total; saved > 0 {
+		infof("\nSharing saves %s: the images take %s, not %s", humanize.Bytes(uint64(saved)), humanize.Bytes(uint64(tree.Total)), humanize.Bytes(uint64(sum)))
+	}
+	return nil
+}
+
+// printLayerNodes draws one level of the layer tree below prefix
+func printLayerNodes(nodes []*puck.LayerNode, prefix string) {
+	for i, n := range nodes {
+		connector, indent := "├── ", "│   "
+		if i == len(nodes)-1 {
+			connector, indent = "└── ", "    "
+		}
+
+		layers := "1 layer"
+		if n.Layers != 1 {
+			layers = fmt.Sprintf("%d layers", n.Layers)
+		}
+		line := fmt.Sprintf("%s  %s, %s", shortDigest(n.Digest), humanize.Bytes(uint64(n.Size)), layers)
+		if len(n.Images) > 0 {
+			line += ": " + strings.Join(n.Images, ", ")
+		}
+		fmt.Println(prefix + connector + line)
+
+		printLayerNodes(n.Children, prefix+indent)
+	}
+}
+
+// shortDigest cuts a layer digest to the 12 characters podman shows
+func shortDigest(digest string) string {
+	digest = strings.TrimPrefix(digest, "sha256:")
+	return digest[:min(12, len(digest))]
+}
@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sandwich-labs/puck/internal/project"
+	"github.com/spf13/cobra"
+)
+
+var initCmd = &cobra.Command{
+	Use:   "init [dir]",
+	Short: "Suggest a puck for a project and write it to puck.yaml",
+	Long: `Look at a project directory (default: the current one) and write a
+puck.yaml describing a puck to develop it in: an image for its language
+and version, or its devcontainer's, the port its dev server usually uses,
+and the project mounted at /workspace.
+
+Go, Node.js, Python, Rust, Ruby and Java projects are recognized by their
+manifests and lockfiles. Services a compose file runs next to the app are
+listed so they can become pucks of their own.
+
+Review puck.yaml, then create the puck with 'puck apply', or pass --apply
+to do it right away.
+
+Examples:
+  puck init
+  puck init ~/src/api --name api --apply`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runInit,
+}
+
+var (
+	initName  string
+	initForce bool
+	initApply bool
+)
+
+func init() {
+	initCmd.Flags().StringVar(&initName, "name", "", "puck name (default: the directory's name)")
+	initCmd.Flags().BoolVar(&initForce, "force", false, "overwrite an existing puck.yaml")
+	initCmd.Flags().BoolVar(&initApply, "apply", false, "create the puck afterwards, as 'puck apply' does")
+}
+
+func runInit(cmd *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	file := filepath.Join(dir, project.FileName)
+	if _, err := os.Stat(file); err == nil && !initForce {
+		return fmt.Errorf("%s already exists; use --force to replace it", file)
+	}
+
+	d, err := project.Detect(dir)
+	if err != nil {
+		return err
+	}
+	if initName != "" {
+		d.Spec.Name = initName
+	}
+
+	header := "# Generated by puck init"
+	if len(d.Found) > 0 {
+		header += " from " + strings.Join(d.Found, ", ")
+	}
+	header += "\n# Create the puck with: puck apply\n"
+	if err := d.Spec.Write(file, header); err != nil {
+		return err
+	}
+
+	infof("Wrote %s for puck '%s' (%s)", file, d.Spec.Name, d.Spec.Image)
+	for _, note := range d.Notes {
+		infof("  Note: %s", note)
+	}
+
+	if !initApply {
+		infof("Review it, then create the puck with: puck apply")
+		return nil
+	}
+	return applyFile(file)
+}
@@ -0,0 +1,235 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dustin/go-humanize"
+	"github.com/sandwich-labs/puck/internal/agent"
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect <name>",
+	Short: "Show details of a puck",
+	Long: `Show a puck's configuration and state.
+
+--format json prints the whole puck, which a Go template or
+jsonpath=<expr> can pick fields out of.
+
+Examples:
+  puck inspect web
+  puck inspect web --format '{{.Status}} {{.Image}}'
+  puck inspect web --format 'jsonpath={.spec.env}'`,
+	Args: cobra.ExactArgs(1),
+	RunE: runInspect,
+}
+
+var inspectFormat string
+
+func init() {
+	addFormatFlag(inspectCmd, &inspectFormat)
+}
+
+func runInspect(cmd *cobra.Command, args []string) error {
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	p, err := client.Get(args[0])
+	if err != nil {
+		return err
+	}
+	if inspectFormat != "" {
+		return printFormatted(inspectFormat, maskedPuck(p), nil)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Name:\t%s\n", p.Name)
+	if p.Notes != "" {
+		fmt.Fprintf(w, "Notes:\t%s\n", strings.ReplaceAll(p.Notes, "\n", "\n\t"))
+	}
+	fmt.Fprintf(w, "Status:\t%s\n", p.Status)
+	if p.Health != "" {
+		fmt.Fprintf(w, "Health:\t%s\n", p.Health)
+	}
+	if r := p.Agent; r != nil {
+		state := "booting"
+		if r.Ready {
+			state = "booted"
+		}
+		fmt.Fprintf(w, "Agent:\t%s, reported %s\n", state, humanize.Time(r.Updated))
+		fmt.Fprintf(w, "Listening:\t%s\n", valueOr(agentPorts(r), "nothing"))
+		if len(r.Services) > 0 {
+			fmt.Fprintf(w, "Services:\t%s\n", strings.Join(r.Services, ", "))
+		}
+	}
+	switch {
+	case p.AppPortRouted:
+		fmt.Fprintf(w, "App port:\t%d (routed)\n", p.AppPort)
+	case p.AppPort != 0 && p.AppPort != 80:
+		fmt.Fprintf(w, "App port:\t%d, but the router goes to port 80; %s\n", p.AppPort, puck.AppPortHint(p.Name, p.AppPort))
+	case p.AppPort != 0:
+		fmt.Fprintf(w, "App port:\t%d\n", p.AppPort)
+	}
+	fmt.Fprintf(w, "Image:\t%s\n", p.Image)
+	fmt.Fprintf(w, "Init:\t%s\n", p.Spec.InitMode())
+	if p.Spec.Entrypoint != nil {
+		fmt.Fprintf(w, "Entrypoint:\t%s\n", strings.Join(p.Spec.Entrypoint, " "))
+	}
+	if p.Spec.Command != nil {
+		fmt.Fprintf(w, "Command:\t%s\n", strings.Join(p.Spec.Command, " "))
+	}
+	if p.Spec.Hostname != "" {
+		fmt.Fprintf(w, "Hostname:\t%s\n", p.Spec.Hostname)
+	}
+	if len(p.Spec.DNS) > 0 {
+		fmt.Fprintf(w, "DNS:\t%s\n", strings.Join(p.Spec.DNS, ", "))
+	}
+	if len(p.Spec.AddHosts) > 0 {
+		fmt.Fprintf(w, "Extra hosts:\t%s\n", strings.Join(p.Spec.AddHosts, ", "))
+	}
+	if len(p.Spec.Sysctls) > 0 {
+		sysctls := make([]string, 0, len(p.Spec.Sysctls))
+		for key, value := range p.Spec.Sysctls {
+			sysctls = append(sysctls, key+"="+value)
+		}
+		sort.Strings(sysctls)
+		fmt.Fprintf(w, "Sysctls:\t%s\n", strings.Join(sysctls, ", "))
+	}
+	if len(p.Spec.Ulimits) > 0 {
+		fmt.Fprintf(w, "Ulimits:\t%s\n", strings.Join(p.Spec.Ulimits, ", "))
+	}
+	if len(p.Spec.Env) > 0 {
+		env := make([]string, 0, len(p.Spec.Env))
+		for key := range p.Spec.Env {
+			env = append(env, key+"="+envValue(p.Spec, key, false))
+		}
+		sort.Strings(env)
+		fmt.Fprintf(w, "Environment:\t%s\n", strings.Join(env, ", "))
+	}
+	if len(p.Spec.Labels) > 0 {
+		labels := make([]string, 0, len(p.Spec.Labels))
+		for key, value := range p.Spec.Labels {
+			labels = append(labels, key+"="+value)
+		}
+		sort.Strings(labels)
+		fmt.Fprintf(w, "Labels:\t%s\n", strings.Join(labels, ", "))
+	}
+	fmt.Fprintf(w, "User namespace:\t%s\n", userNSSummary(p.Spec))
+	if notes := p.Spec.SecurityNotes(); len(notes) > 0 {
+		fmt.Fprintf(w, "Security:\tWARNING: %s\n", strings.Join(notes, ", "))
+	} else {
+		fmt.Fprintf(w, "Security:\tdefault\n")
+	}
+	if p.Spec.Sandbox != "" {
+		fmt.Fprintf(w, "Sandbox:\t%s\n", p.Spec.Sandbox)
+	}
+	fmt.Fprintf(w, "Egress:\t%s\n", p.Egress.String())
+	for i, mnt := range p.Spec.Mounts {
+		label := ""
+		if i == 0 {
+			label = "Shared paths:"
+		}
+		mode := ""
+		if mnt.ReadOnly {
+			mode = " (read-only)"
+		}
+		if mnt.Sync {
+			mode += " (synced)"
+		}
+		fmt.Fprintf(w, "%s\t%s -> %s%s\n", label, mnt.Source, mnt.Target, mode)
+	}
+	if p.Owner != "" {
+		fmt.Fprintf(w, "Owner:\t%s\n", p.Owner)
+	}
+	if p.Project != "" {
+		fmt.Fprintf(w, "Project:\t%s\n", p.Project)
+	}
+	if len(p.Requires) > 0 {
+		fmt.Fprintf(w, "Requires:\t%s\n", strings.Join(p.Requires, ", "))
+	}
+	fmt.Fprintf(w, "Created:\t%s\n", p.CreatedAt.Format("2006-01-02 15:04"))
+	if !p.ExpiresAt.IsZero() {
+		final := ""
+		if p.ExpirySnapshot {
+			final = ", archived first"
+		}
+		fmt.Fprintf(w, "Expires:\t%s (in %s%s)\n", p.ExpiresAt.Format("2006-01-02 15:04"), formatTTL(p.ExpiresAt), final)
+	}
+	fmt.Fprintf(w, "ID:\t%s\n", p.ID)
+	fmt.Fprintf(w, "Container:\t%s\n", p.ContainerID)
+	if p.ContainerIP != "" {
+		fmt.Fprintf(w, "Container IP:\t%s\n", p.ContainerIP)
+	}
+	if p.HostPort > 0 {
+		fmt.Fprintf(w, "Host port:\t%d\n", p.HostPort)
+	}
+	if len(p.Ports) > 0 {
+		fmt.Fprintf(w, "Ports:\t%s\n", strings.Join(p.Ports, ", "))
+	}
+	if p.Spec.PublishAll {
+		fmt.Fprintf(w, "Published:\t%s\n", valueOr(strings.Join(p.Published, ", "), "exposed ports, once started"))
+	}
+	fmt.Fprintf(w, "Volumes:\t%s\n", p.VolumeDir)
+	fmt.Fprintf(w, "Memory:\t%s\n", formatMemory(p.Resources.Memory))
+	fmt.Fprintf(w, "CPUs:\t%s\n", formatCPUs(p.Resources.CPUs))
+	if p.Resources.Pending {
+		fmt.Fprintf(w, "Container:\trecreated on next start\n")
+	}
+	if p.Tailnet != nil {
+		fmt.Fprintf(w, "Tailnet:\t%s\n", tailnetURL(p.Name))
+		tags := "(none)"
+		if len(p.Tailnet.Tags) > 0 {
+			tags = strings.Join(p.Tailnet.Tags, ", ")
+		}
+		fmt.Fprintf(w, "Tailnet tags:\t%s\n", tags)
+	}
+
+	return w.Flush()
+}
+
+// agentPorts lists the ports a puck's agent found listening, marking the
+// one routed to as the app's and those only the puck can reach
+func agentPorts(r *agent.Report) string {
+	var ports []string
+	seen := make(map[int]bool)
+	for _, p := range r.Ports {
+		if seen[p.Port] {
+			continue
+		}
+		seen[p.Port] = true
+		port := strconv.Itoa(p.Port)
+		switch {
+		case p.Port == r.AppPort:
+			port += " (app)"
+		case !slices.ContainsFunc(r.Ports, func(other agent.Port) bool { return other.Port == p.Port && !other.Loopback() }):
+			port += " (loopback)"
+		}
+		ports = append(ports, port)
+	}
+	return strings.Join(ports, ", ")
+}
+
+// userNSSummary describes a puck's user namespace for inspect
+func userNSSummary(spec store.Spec) string {
+	if len(spec.UIDMap) == 0 && len(spec.GIDMap) == 0 {
+		return valueOr(spec.UserNS, "default")
+	}
+	uidMap, gidMap := spec.UIDMap, spec.GIDMap
+	if len(gidMap) == 0 {
+		gidMap = uidMap
+	}
+	if len(uidMap) == 0 {
+		uidMap = gidMap
+	}
+	return fmt.Sprintf("custom (uid %s; gid %s)", strings.Join(uidMap, ", "), strings.Join(gidMap, ", "))
+}
@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var killCmd = &cobra.Command{
+	Use:   "kill <name>",
+	Short: "Kill a puck immediately",
+	Long: `Send SIGKILL to a puck, stopping it without waiting for it to shut down.
+
+Use --signal to send a different signal, by name (with or without SIG)
+or number. Only SIGKILL stops the puck; other signals are just delivered
+to its PID 1, such as HUP to have a daemon reload its config without
+opening a console:
+
+  puck kill web
+  puck kill web --signal HUP`,
+	Args: cobra.ExactArgs(1),
+	RunE: runKill,
+}
+
+var killSignal string
+
+func init() {
+	killCmd.Flags().StringVarP(&killSignal, "signal", "s", "SIGKILL", "signal to send, e.g. SIGTERM, HUP, or 9")
+}
+
+func runKill(cmd *cobra.Command, args []string) error {
+	name, err := selectContext(args[0])
+	if err != nil {
+		return err
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	if err := client.Kill(name, killSignal); err != nil {
+		return err
+	}
+
+	infof("Sent %s to puck '%s'", killSignal, args[0])
+	return nil
+}
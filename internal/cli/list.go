@@ -2,52 +2,635 @@ package cli
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"text/tabwriter"
+	"time"
 
+	"github.com/charmbracelet/log"
+	"github.com/docker/go-units"
 	"github.com/spf13/cobra"
+	"github.com/sandwich-labs/puck/internal/config"
 	"github.com/sandwich-labs/puck/internal/daemon"
+	"github.com/sandwich-labs/puck/internal/store"
+	"golang.org/x/term"
 )
 
 var listCmd = &cobra.Command{
 	Use:     "list",
 	Aliases: []string{"ls"},
 	Short:   "List all pucks",
-	Long:    `List all pucks managed by puck.`,
-	RunE:    runList,
+	Long: `List all pucks managed by puck.
+
+On a terminal, statuses are colored and marked with an icon; piped
+output stays plain. --wide adds each puck's host port, its current CPU
+and memory use, with a total for the running pucks, and its notes.
+--columns picks the columns instead, from name, status, image, url,
+port, cpu, memory, created, expires and notes. EXPIRES counts down to
+when the daemon destroys a puck created with --ttl.
+
+With --tree, pucks are shown under the pucks that require them, so a
+puck's requirements appear beneath it. --group lists only the pucks of
+one of your groups (see 'puck group').
+
+--watch keeps the list up to date until interrupted, redrawing it as
+soon as pucks change and every --interval besides, for health and usage
+podman doesn't announce.
+
+--format prints each puck with a Go template over the fields puck
+inspect --format json shows, the list as JSON with json, or values
+picked out of it with jsonpath=<expr>.
+
+Examples:
+  puck list
+  puck list --wide
+  puck list --columns name,status,memory
+  puck list --group demo
+  puck list --watch
+  puck list --format '{{.Name}} {{.Status}} {{.HostPort}}'
+  puck list --format 'jsonpath={[*].name}'`,
+	RunE: runList,
+}
+
+var (
+	listAllUsers    bool
+	listAllContexts bool
+	listTree        bool
+	listWide        bool
+	listColumns     []string
+	listWatch       bool
+	listInterval    time.Duration
+	listFormat      string
+	listGroup       string
+)
+
+func init() {
+	listCmd.Flags().BoolVar(&listAllUsers, "all-users", false, "list every user's pucks (admins only)")
+	listCmd.Flags().BoolVar(&listAllContexts, "all-contexts", false, "list pucks from every configured context")
+	listCmd.Flags().BoolVar(&listTree, "tree", false, "show which pucks require which")
+	listCmd.Flags().StringVar(&listGroup, "group", "", "only list the pucks of a group (see puck group)")
+	listCmd.Flags().BoolVarP(&listWide, "wide", "w", false, "also show host ports, CPU and memory use, and notes")
+	listCmd.Flags().StringSliceVar(&listColumns, "columns", nil, "columns to show, comma-separated (name, status, image, url, port, cpu, memory, created, expires, notes)")
+	listCmd.Flags().BoolVar(&listWatch, "watch", false, "keep the list up to date until interrupted")
+	listCmd.Flags().DurationVar(&listInterval, "interval", 2*time.Second, "with --watch, how often to redraw when nothing changed")
+	addFormatFlag(listCmd, &listFormat)
+}
+
+// listColumn is a column puck list can show
+type listColumn struct {
+	header string
+	value  func(p *store.Puck, router routerAddr) string
+}
+
+// listColumnSet holds the columns --columns chooses from
+var listColumnSet = map[string]listColumn{
+	"name":   {"NAME", func(p *store.Puck, _ routerAddr) string { return p.Name }},
+	"status": {"STATUS", func(p *store.Puck, _ routerAddr) string { return listStatus(p) }},
+	"image":  {"IMAGE", func(p *store.Puck, _ routerAddr) string { return p.Image }},
+	"url": {"URL", func(p *store.Puck, router routerAddr) string {
+		if url := router.url(p); url != "" {
+			return url
+		}
+		return "-"
+	}},
+	"port": {"PORT", func(p *store.Puck, _ routerAddr) string {
+		if p.HostPort == 0 {
+			return "-"
+		}
+		return strconv.Itoa(p.HostPort)
+	}},
+	"cpu": {"CPU", func(p *store.Puck, _ routerAddr) string {
+		if p.Usage == nil {
+			return "-"
+		}
+		return fmt.Sprintf("%.1f%%", p.Usage.CPU)
+	}},
+	"memory": {"MEMORY", func(p *store.Puck, _ routerAddr) string {
+		if p.Usage == nil {
+			return "-"
+		}
+		used := units.BytesSize(float64(p.Usage.Memory))
+		if p.Resources.Memory > 0 {
+			return used + " / " + units.BytesSize(float64(p.Resources.Memory))
+		}
+		return used
+	}},
+	"notes": {"NOTES", func(p *store.Puck, _ routerAddr) string {
+		if p.Notes == "" {
+			return "-"
+		}
+		return shortNotes(p.Notes)
+	}},
+	"created": {"CREATED", func(p *store.Puck, _ routerAddr) string { return p.CreatedAt.Format("2006-01-02 15:04") }},
+	"expires": {"EXPIRES", func(p *store.Puck, _ routerAddr) string {
+		if p.ExpiresAt.IsZero() {
+			return "-"
+		}
+		return formatTTL(p.ExpiresAt)
+	}},
+}
+
+var (
+	listDefaultColumns = []string{"name", "status", "image", "url", "created", "expires"}
+	listWideColumns    = []string{"name", "status", "image", "url", "port", "cpu", "memory", "created", "expires", "notes"}
+)
+
+// formatTTL counts down to when a puck's TTL runs out, to the minute,
+// e.g. 3h12m
+func formatTTL(expires time.Time) string {
+	left := time.Until(expires).Round(time.Minute)
+	if left < time.Minute {
+		return "<1m"
+	}
+	s := strings.TrimSuffix(left.String(), "0s")
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// listNotesWidth is how much of a puck's notes fits in a list column
+const listNotesWidth = 40
+
+// shortNotes returns the first line of notes, cut to fit a list column
+func shortNotes(notes string) string {
+	line, _, more := strings.Cut(notes, "\n")
+	if r := []rune(line); len(r) > listNotesWidth {
+		line, more = string(r[:listNotesWidth-3]), true
+	}
+	if more {
+		line += "..."
+	}
+	return line
+}
+
+// chosenColumns returns the columns to show, from --columns or --wide
+func chosenColumns() ([]string, error) {
+	if len(listColumns) == 0 {
+		if listWide {
+			return listWideColumns, nil
+		}
+		return listDefaultColumns, nil
+	}
+	columns := make([]string, 0, len(listColumns))
+	for _, c := range listColumns {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if _, ok := listColumnSet[c]; !ok {
+			return nil, fmt.Errorf("unknown column %q (choose from %s)", c, strings.Join(listWideColumns, ", "))
+		}
+		columns = append(columns, c)
+	}
+	return columns, nil
+}
+
+// showsUsage reports whether any of the columns needs the pucks' CPU and
+// memory use, which the daemon only reads from podman when asked
+func showsUsage(columns []string) bool {
+	return slices.Contains(columns, "cpu") || slices.Contains(columns, "memory")
 }
 
 func runList(cmd *cobra.Command, args []string) error {
-	client, err := daemon.NewClient()
+	columns, err := chosenColumns()
+	if err != nil {
+		return err
+	}
+	if listWatch && (listTree || listAllContexts) {
+		return fmt.Errorf("--watch can't be combined with --tree or --all-contexts")
+	}
+	if listFormat != "" && (listWatch || listTree || listAllContexts) {
+		return fmt.Errorf("--format can't be combined with --watch, --tree or --all-contexts")
+	}
+	if listGroup != "" && listAllContexts {
+		return fmt.Errorf("--group can't be combined with --all-contexts")
+	}
+	if listAllContexts {
+		return runListAllContexts(columns)
+	}
+
+	client, err := newClient()
 	if err != nil {
 		return err
 	}
 
-	if err := client.Ping(); err != nil {
-		return fmt.Errorf("daemon not running: %w\nStart with: puck daemon start", err)
+	if listWatch {
+		return watchList(client, columns)
 	}
 
-	pucks, err := client.List()
+	usage := showsUsage(columns) && !quiet && !listTree
+	if listFormat != "" {
+		// Usage costs a round of podman stats, so only when it's printed
+		usage = strings.Contains(strings.ToLower(listFormat), "usage")
+	}
+	pucks, err := listPucks(client, usage)
 	if err != nil {
 		return err
 	}
+	if listFormat != "" {
+		for i, p := range pucks {
+			pucks[i] = maskedPuck(p)
+		}
+		return printFormatted(listFormat, pucks, nil)
+	}
 
 	if len(pucks) == 0 {
-		fmt.Println("No pucks found. Create one with: puck create <name>")
+		infof("No pucks found. Create one with: puck create <name>")
 		return nil
 	}
 
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "NAME\tSTATUS\tIMAGE\tCREATED")
+	if quiet {
+		for _, p := range pucks {
+			fmt.Println(p.Name)
+		}
+		return nil
+	}
+	if listTree {
+		printTree(pucks)
+		return nil
+	}
 
-	for _, p := range pucks {
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
-			p.Name,
-			p.Status,
-			p.Image,
-			p.CreatedAt.Format("2006-01-02 15:04"),
-		)
+	router := localRouter(client)
+	rows := make([]listRow, len(pucks))
+	for i, p := range pucks {
+		rows[i] = listRow{puck: p, router: router}
+	}
+	if err := printListTable(os.Stdout, rows, columns, false); err != nil {
+		return err
 	}
+	printUsageSummary(pucks, columns)
+	printSecurityLegend(pucks)
+	return nil
+}
 
+// listPucks lists the caller's pucks, or everyone's with --all-users,
+// with their CPU and memory use if usage is set, and only those of a
+// group with --group
+func listPucks(client *daemon.Client, usage bool) ([]*store.Puck, error) {
+	var pucks []*store.Puck
+	var err error
+	switch {
+	case usage:
+		pucks, err = client.ListUsage(listAllUsers)
+	case listAllUsers:
+		pucks, err = client.ListAllUsers()
+	default:
+		pucks, err = client.List()
+	}
+	if err != nil || listGroup == "" {
+		return pucks, err
+	}
+
+	g, err := client.Group(strings.TrimPrefix(listGroup, "@"))
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(pucks, func(p *store.Puck) bool {
+		return !slices.Contains(g.Members, p.Name)
+	}), nil
+}
+
+// watchSettle is how long a watch waits after a change before listing, so
+// a burst of changes, as while a puck is created, is drawn once
+const watchSettle = 200 * time.Millisecond
+
+// watchList redraws the list whenever the daemon says pucks changed, and
+// every --interval besides. Daemons that can't be watched are polled. On
+// a terminal the list is redrawn in place; otherwise each list that
+// differs from the last is printed after it.
+func watchList(client *daemon.Client, columns []string) error {
+	if listInterval < time.Second {
+		return fmt.Errorf("--interval must be at least 1s")
+	}
+	tty := term.IsTerminal(int(os.Stdout.Fd())) && !plainOutput()
+	router := localRouter(client)
+
+	var gen uint64
+	var last string
+	polling := false
+	for {
+		pucks, err := listPucks(client, showsUsage(columns))
+		if err != nil {
+			return err
+		}
+		out, err := renderWatchedList(pucks, columns, router)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case tty:
+			how := "as pucks change and every " + listInterval.String()
+			if polling {
+				how = "every " + listInterval.String()
+			}
+			fmt.Printf("\x1b[H\x1b[2JUpdated %s, %s (Ctrl-C to stop)\n\n%s", time.Now().Format("15:04:05"), how, out)
+		case out != last:
+			if last != "" {
+				fmt.Println()
+			}
+			fmt.Print(out)
+		}
+		last = out
+
+		if !polling {
+			next, err := client.WaitChange(gen, listInterval)
+			if err == nil {
+				if next != gen {
+					time.Sleep(watchSettle)
+				}
+				gen = next
+				continue
+			}
+			log.Debug("Daemon can't be watched, polling instead", "error", err)
+			polling = true
+		}
+		time.Sleep(listInterval)
+	}
+}
+
+// renderWatchedList renders a list as runList prints it, notes included,
+// for watchList to draw at once
+func renderWatchedList(pucks []*store.Puck, columns []string, router routerAddr) (string, error) {
+	if len(pucks) == 0 {
+		return "No pucks found. Create one with: puck create <name>\n", nil
+	}
+	rows := make([]listRow, len(pucks))
+	for i, p := range pucks {
+		rows[i] = listRow{puck: p, router: router}
+	}
+	var b strings.Builder
+	if err := printListTable(&b, rows, columns, false); err != nil {
+		return "", err
+	}
+	if summary := usageSummary(pucks, columns); summary != "" {
+		fmt.Fprintf(&b, "\n%s\n", summary)
+	}
+	if legend := securityLegend(pucks); legend != "" {
+		fmt.Fprintf(&b, "\n%s\n", legend)
+	}
+	return b.String(), nil
+}
+
+// contextPucks is one context's answer to a fleet-wide list
+type contextPucks struct {
+	context string
+	pucks   []*store.Puck
+	router  routerAddr
+	err     error
+}
+
+// runListAllContexts queries every context concurrently and prints the
+// merged list, warning about contexts that could not be reached
+func runListAllContexts(columns []string) error {
+	contexts, err := config.AllContexts()
+	if err != nil {
+		return err
+	}
+
+	results := make([]contextPucks, len(contexts))
+	var wg sync.WaitGroup
+	for i, c := range contexts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = contextPucks{context: c.Name}
+			client, err := daemon.NewClientForContext(c)
+			if err != nil {
+				results[i].err = err
+				return
+			}
+			switch {
+			case showsUsage(columns):
+				results[i].pucks, results[i].err = client.ListUsage(listAllUsers)
+			case listAllUsers:
+				results[i].pucks, results[i].err = client.ListAllUsers()
+			default:
+				results[i].pucks, results[i].err = client.List()
+			}
+			if slices.Contains(columns, "url") {
+				results[i].router = localRouter(client)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var rows []listRow
+	var all []*store.Puck
+	for _, r := range results {
+		if r.err != nil {
+			log.Warn("Skipping unreachable context", "context", r.context, "error", r.err)
+			continue
+		}
+		for _, p := range r.pucks {
+			rows = append(rows, listRow{context: r.context, puck: p, router: r.router})
+		}
+		all = append(all, r.pucks...)
+	}
+
+	if err := printListTable(os.Stdout, rows, columns, true); err != nil {
+		return err
+	}
+	printUsageSummary(all, columns)
+	printSecurityLegend(all)
+	return nil
+}
+
+// listRow is a puck in a listing, with where its context routes it
+type listRow struct {
+	context string
+	puck    *store.Puck
+	router  routerAddr
+}
+
+// printListTable prints the rows in the chosen columns, after a context
+// column for fleet-wide lists and an owner column with --all-users. On a
+// terminal, statuses are colored and marked with an icon.
+func printListTable(out io.Writer, rows []listRow, columns []string, withContext bool) error {
+	color := term.IsTerminal(int(os.Stdout.Fd())) && !plainOutput()
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	var header []string
+	if withContext {
+		header = append(header, "CONTEXT")
+	}
+	if listAllUsers {
+		header = append(header, "OWNER")
+	}
+	for _, c := range columns {
+		h := listColumnSet[c].header
+		if c == "status" && color {
+			h = paint(colorDefault, h)
+		}
+		header = append(header, h)
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+
+	for _, r := range rows {
+		var cells []string
+		if withContext {
+			cells = append(cells, r.context)
+		}
+		if listAllUsers {
+			cells = append(cells, r.puck.Owner)
+		}
+		for _, c := range columns {
+			v := listColumnSet[c].value(r.puck, r.router)
+			if c == "status" && color {
+				v = paintStatus(r.puck, v)
+			}
+			cells = append(cells, v)
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
 	return w.Flush()
 }
+
+// printUsageSummary totals what the running pucks use, when the columns
+// show it
+func printUsageSummary(pucks []*store.Puck, columns []string) {
+	if summary := usageSummary(pucks, columns); summary != "" {
+		infof("\n%s", summary)
+	}
+}
+
+// usageSummary is printUsageSummary's line, or "" when there is none
+func usageSummary(pucks []*store.Puck, columns []string) string {
+	if !showsUsage(columns) {
+		return ""
+	}
+	var running int
+	var total store.Usage
+	for _, p := range pucks {
+		if p.Usage == nil {
+			continue
+		}
+		running++
+		total.CPU += p.Usage.CPU
+		total.Memory += p.Usage.Memory
+	}
+	if running == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d running, using %.1f%% CPU and %s memory", running, total.CPU, units.BytesSize(float64(total.Memory)))
+}
+
+// Terminal colors. tabwriter counts escape codes as text, so every code
+// has the same length and a painted column is painted in every row,
+// header included, to keep the columns after it aligned.
+const (
+	colorDefault = "39"
+	colorGreen   = "32"
+	colorYellow  = "33"
+	colorRed     = "31"
+	colorCyan    = "36"
+	colorGray    = "90"
+)
+
+// paint wraps s in a terminal color
+func paint(color, s string) string {
+	return "\x1b[" + color + "m" + s + "\x1b[0m"
+}
+
+// paintStatus colors a puck's status cell and marks it with an icon
+func paintStatus(p *store.Puck, status string) string {
+	color, icon := colorGray, "○"
+	switch p.Status {
+	case store.StatusRunning:
+		color, icon = colorGreen, "●"
+	case store.StatusStarting, store.StatusCreating:
+		color, icon = colorYellow, "◐"
+	case store.StatusCheckpointed, store.StatusSuspended:
+		color, icon = colorCyan, "◌"
+	case store.StatusError:
+		color, icon = colorRed, "✗"
+	}
+	if p.Health == "unhealthy" {
+		color = colorRed
+	}
+	return paint(color, icon+" "+status)
+}
+
+// listStatus adds the health of pucks whose image has a healthcheck, or
+// that their agent finds still booting, and marks pucks whose security
+// profiles differ from the defaults
+func listStatus(p *store.Puck) string {
+	status := string(p.Status)
+	switch {
+	case p.Health != "":
+		status += " (" + p.Health + ")"
+	case p.Agent != nil && !p.Agent.Ready:
+		status += " (booting)"
+	}
+	if len(p.Spec.SecurityNotes()) > 0 {
+		return status + " !"
+	}
+	return status
+}
+
+// printSecurityLegend explains the marker from listStatus when any puck has it
+func printSecurityLegend(pucks []*store.Puck) {
+	if legend := securityLegend(pucks); legend != "" {
+		infof("\n%s", legend)
+	}
+}
+
+// securityLegend is printSecurityLegend's note, or "" when no puck needs it
+func securityLegend(pucks []*store.Puck) string {
+	for _, p := range pucks {
+		if len(p.Spec.SecurityNotes()) > 0 {
+			return "! custom or unconfined security profile; see puck inspect"
+		}
+	}
+	return ""
+}
+
+// printTree prints each puck nobody requires with its requirements
+// beneath it. A puck required by several appears under each of them.
+func printTree(pucks []*store.Puck) {
+	byName := make(map[string]*store.Puck, len(pucks))
+	required := make(map[string]bool)
+	for _, p := range pucks {
+		byName[p.Name] = p
+		for _, req := range p.Requires {
+			required[req] = true
+		}
+	}
+
+	var printNode func(name, prefix, branch string, path []string)
+	printNode = func(name, prefix, branch string, path []string) {
+		p, ok := byName[name]
+		switch {
+		case !ok:
+			fmt.Printf("%s%s%s (not found)\n", prefix, branch, name)
+			return
+		case slices.Contains(path, name):
+			fmt.Printf("%s%s%s (cycle)\n", prefix, branch, name)
+			return
+		}
+		fmt.Printf("%s%s%s (%s)\n", prefix, branch, name, listStatus(p))
+
+		switch branch {
+		case "├── ":
+			prefix += "│   "
+		case "└── ":
+			prefix += "    "
+		}
+		for i, req := range p.Requires {
+			next := "├── "
+			if i == len(p.Requires)-1 {
+				next = "└── "
+			}
+			printNode(req, prefix, next, append(path, name))
+		}
+	}
+
+	for _, p := range pucks {
+		if !required[p.Name] {
+			printNode(p.Name, "", "", nil)
+		}
+	}
+	printSecurityLegend(pucks)
+}
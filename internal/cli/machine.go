@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/docker/go-units"
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/spf13/cobra"
+)
+
+var machineCmd = &cobra.Command{
+	Use:   "machine",
+	Short: "Manage the Podman Machine pucks run in",
+	Long: `On macOS and Windows, Podman runs containers in a Podman Machine VM,
+and every puck shares its CPUs, memory and disk.`,
+}
+
+var machineResourcesCmd = &cobra.Command{
+	Use:   "resources",
+	Short: "Show or change the Podman Machine's CPUs, memory and disk",
+	Long: `Show the Podman Machine's CPUs, memory and disk next to the limits of
+the running pucks, with warnings where the machine is too small for them.
+
+With --cpus, --memory or --disk-size the machine is resized with podman
+machine set. The machine has to be stopped for that, which stops every
+puck, and its disk can only grow.
+
+Examples:
+  puck machine resources
+  podman machine stop && puck machine resources --memory 8g --cpus 4 && podman machine start`,
+	Args: cobra.NoArgs,
+	RunE: runMachineResources,
+}
+
+var (
+	machineCPUs     uint64
+	machineMemory   string
+	machineDiskSize string
+)
+
+func init() {
+	machineResourcesCmd.Flags().Uint64Var(&machineCPUs, "cpus", 0, "number of CPUs")
+	machineResourcesCmd.Flags().StringVar(&machineMemory, "memory", "", "memory, e.g. 8g")
+	machineResourcesCmd.Flags().StringVar(&machineDiskSize, "disk-size", "", "disk size, e.g. 100g; it can only grow")
+	machineCmd.AddCommand(machineResourcesCmd)
+}
+
+func runMachineResources(cmd *cobra.Command, args []string) error {
+	var res podman.MachineResources
+	var err error
+	res.CPUs = machineCPUs
+	if machineMemory != "" {
+		if res.Memory, err = units.RAMInBytes(machineMemory); err != nil {
+			return fmt.Errorf("invalid memory %q: %w", machineMemory, err)
+		}
+	}
+	if machineDiskSize != "" {
+		if res.DiskSize, err = units.RAMInBytes(machineDiskSize); err != nil {
+			return fmt.Errorf("invalid disk size %q: %w", machineDiskSize, err)
+		}
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	var status *puck.MachineStatus
+	if res == (podman.MachineResources{}) {
+		status, err = client.MachineResources()
+	} else {
+		status, err = client.SetMachineResources(res)
+	}
+	if err != nil {
+		return err
+	}
+
+	mi := status.Machine
+	size := mi.Resources
+	fmt.Printf("Machine:  %s (%s, %s)\n", mi.Name, valueOr(mi.VMType, "unknown"), valueOr(mi.State, "unknown"))
+	fmt.Printf("CPUs:     %d, %g reserved by %d running pucks\n", size.CPUs, status.Reserved.CPUs, status.Running)
+	fmt.Printf("Memory:   %s, %s reserved\n", units.BytesSize(float64(size.Memory)), units.BytesSize(float64(status.Reserved.Memory)))
+	fmt.Printf("Disk:     %s\n", units.BytesSize(float64(size.DiskSize)))
+
+	for _, msg := range status.Warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", msg)
+	}
+	return nil
+}
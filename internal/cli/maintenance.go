@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/dustin/go-humanize"
+	"github.com/sandwich-labs/puck/internal/daemon"
+	"github.com/spf13/cobra"
+)
+
+var daemonMaintenanceCmd = &cobra.Command{
+	Use:   "maintenance [on|off]",
+	Short: "Put the daemon in read-only mode for backups and upgrades",
+	Long: `Turn the daemon's maintenance mode on or off, or show whether it is on.
+
+In maintenance the daemon only serves commands that read, such as puck
+list, logs, history and snapshot list, and refuses everything that would
+change a puck, a snapshot or the database with an error saying who turned
+maintenance on and why. Scheduled snapshots and moves to cold storage wait
+until it is off, so a backup of the data directory or a host upgrade can
+run against files that hold still. Maintenance lasts across daemon
+restarts, until turned off.
+
+Turning maintenance on or off requires an admin.
+
+Examples:
+  puck daemon maintenance on --reason "nightly backup"
+  puck daemon maintenance
+  puck daemon maintenance off`,
+	Args:      cobra.MaximumNArgs(1),
+	ValidArgs: []string{"on", "off"},
+	RunE:      runDaemonMaintenance,
+}
+
+var maintenanceReason string
+
+func init() {
+	daemonMaintenanceCmd.Flags().StringVar(&maintenanceReason, "reason", "", "why, shown to anyone whose command is refused")
+}
+
+func runDaemonMaintenance(cmd *cobra.Command, args []string) error {
+	var enabled *bool
+	if len(args) == 1 {
+		switch args[0] {
+		case "on", "off":
+			on := args[0] == "on"
+			enabled = &on
+		default:
+			return fmt.Errorf("invalid argument %q (expected on or off)", args[0])
+		}
+	}
+	if maintenanceReason != "" && (enabled == nil || !*enabled) {
+		return fmt.Errorf("--reason is only used with on")
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	m, err := client.Maintenance(enabled, maintenanceReason)
+	if err != nil {
+		return err
+	}
+	if !m.Enabled {
+		fmt.Println("Maintenance mode is off")
+		return nil
+	}
+	fmt.Println(maintenanceLine(m))
+	infof("End it with: puck daemon maintenance off")
+	return nil
+}
+
+// maintenanceLine describes the daemon's maintenance mode in a line
+func maintenanceLine(m *daemon.Maintenance) string {
+	line := "Maintenance mode is on, refusing changes"
+	if m.Reason != "" {
+		line += ": " + m.Reason
+	}
+	line += " (since " + humanize.Time(m.Since)
+	if m.By != "" {
+		line += " by " + m.By
+	}
+	return line + ")"
+}
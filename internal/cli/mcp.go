@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/sandwich-labs/puck/internal/buildinfo"
+	"github.com/sandwich-labs/puck/internal/mcp"
+	"github.com/spf13/cobra"
+)
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Serve puck to AI agents over the Model Context Protocol",
+}
+
+var mcpServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run an MCP server on stdin and stdout",
+	Long: `Run a Model Context Protocol server on stdin and stdout so coding agents
+can create pucks, run commands in them, read their logs and snapshot them.
+
+Every call goes through the daemon as your user, so agents get the same
+permissions you do and no more. Use --tools to offer fewer tools, e.g.
+only puck_list and puck_logs for an agent that should just look.
+
+Tools: ` + strings.Join(mcp.ToolNames(), ", ") + `
+
+Register it with your agent as a stdio server running 'puck mcp serve'.`,
+	Args: cobra.NoArgs,
+	RunE: runMCPServe,
+}
+
+var mcpTools []string
+
+func init() {
+	mcpServeCmd.Flags().StringSliceVar(&mcpTools, "tools", nil, "tools to offer (default: all)")
+	mcpCmd.AddCommand(mcpServeCmd)
+}
+
+func runMCPServe(cmd *cobra.Command, args []string) error {
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	server, err := mcp.NewServer(client, buildinfo.Version, mcpTools)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return server.Serve(ctx, os.Stdin, os.Stdout)
+}
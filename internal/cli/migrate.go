@@ -0,0 +1,248 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/backup"
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/sandwich-labs/puck/internal/daemon"
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var migrateHostCmd = &cobra.Command{
+	Use:   "migrate-host --to ssh://<host>",
+	Short: "Move this host's pucks to another machine",
+	Long: `Move the puck installation on this host to another machine over ssh.
+
+This backs up the installation, streams it to the new host and restores it
+there, then starts the new host's daemon and brings each puck back: pucks
+running here get a freshly pulled image and a new container, checkpointed
+pucks resume from their snapshot if --snapshots is given, and stopped
+pucks stay stopped. Finally the status of every puck is checked.
+
+The new host needs puck installed with its daemon set up as a systemd user
+service ('puck daemon install'). Its daemon is stopped for the restore.
+An existing installation there is only replaced with --force. Pucks on
+this host are left as they are; destroy them once the new host is working.
+
+--to takes ssh://[user@]host or the name of an ssh context.
+
+Examples:
+  puck migrate-host --to ssh://me@newbox
+  puck migrate-host --to newbox --snapshots`,
+	Args: cobra.NoArgs,
+	RunE: runMigrateHost,
+}
+
+var (
+	migrateTo        string
+	migrateSnapshots bool
+	migrateForce     bool
+)
+
+// migrateStartTimeout bounds how long the new host's daemon may take to
+// answer after it is started
+const migrateStartTimeout = 30 * time.Second
+
+func init() {
+	migrateHostCmd.Flags().StringVar(&migrateTo, "to", "", "new host, as ssh://[user@]host or an ssh context name")
+	migrateHostCmd.Flags().BoolVar(&migrateSnapshots, "snapshots", false, "bring snapshots along")
+	migrateHostCmd.Flags().BoolVar(&migrateForce, "force", false, "replace an existing installation on the new host")
+	migrateHostCmd.MarkFlagRequired("to")
+}
+
+// migrateHost resolves --to to an ssh destination
+func migrateHost(to string) (string, error) {
+	if host, ok := strings.CutPrefix(to, "ssh://"); ok {
+		if host == "" {
+			return "", fmt.Errorf("--to %q names no host", to)
+		}
+		if err := config.ValidateSSHHost(host); err != nil {
+			return "", fmt.Errorf("--to: %w", err)
+		}
+		return host, nil
+	}
+
+	cs, err := config.LoadContexts(config.ContextsPath())
+	if err != nil {
+		return "", err
+	}
+	c, ok := cs.Contexts[to]
+	if !ok {
+		return "", fmt.Errorf("--to takes ssh://[user@]host or an ssh context name; no context '%s'", to)
+	}
+	if c.Type != config.ContextSSH {
+		return "", fmt.Errorf("context '%s' is not an ssh context", to)
+	}
+	if err := c.Validate(); err != nil {
+		return "", fmt.Errorf("context '%s': %w", to, err)
+	}
+	return c.Host, nil
+}
+
+// sshCommand runs puck's tooling on host
+func sshCommand(host string, args ...string) *exec.Cmd {
+	return exec.Command("ssh", append([]string{"-T", host, "--"}, args...)...)
+}
+
+// sshRun runs a command on host, returning its error output on failure
+func sshRun(host string, args ...string) error {
+	out, err := sshCommand(host, args...).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%s: %s", strings.Join(args, " "), msg)
+		}
+		return fmt.Errorf("%s: %w", strings.Join(args, " "), err)
+	}
+	return nil
+}
+
+func runMigrateHost(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+
+	host, err := migrateHost(migrateTo)
+	if err != nil {
+		return err
+	}
+	paths, err := localPaths("migrate-host")
+	if err != nil {
+		return err
+	}
+	if paths.Database == "" {
+		return fmt.Errorf("pucks in a Postgres database are shared between hosts already; nothing to migrate")
+	}
+
+	// What each puck should be doing once it has moved
+	db, err := store.Open(paths.Database)
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	pucks, err := db.ListPucks(ctx)
+	db.Close()
+	if err != nil {
+		return err
+	}
+
+	infof("Checking %s...", host)
+	if err := sshRun(host, "puck", "version"); err != nil {
+		return fmt.Errorf("puck is not usable on %s: %w", host, err)
+	}
+	if err := sshRun(host, "systemctl", "--user", "cat", "puckd.service"); err != nil {
+		return fmt.Errorf("the daemon is not installed on %s; run 'puck daemon install' there first", host)
+	}
+
+	infof("Stopping the daemon on %s...", host)
+	if err := sshRun(host, "systemctl", "--user", "stop", "puckd"); err != nil {
+		return err
+	}
+
+	infof("Copying %d pucks to %s...", len(pucks), host)
+	restoreArgs := []string{"puck", "restore-all", "-"}
+	if migrateForce {
+		restoreArgs = append(restoreArgs, "--force")
+	}
+	restore := sshCommand(host, restoreArgs...)
+	restore.Stdout = os.Stdout
+	restore.Stderr = os.Stderr
+	stdin, err := restore.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := restore.Start(); err != nil {
+		return fmt.Errorf("running ssh: %w", err)
+	}
+	report, err := backup.Create(ctx, stdin, paths, backup.Options{Snapshots: migrateSnapshots})
+	stdin.Close()
+	if werr := restore.Wait(); werr != nil {
+		return fmt.Errorf("restoring on %s: %w", host, werr)
+	}
+	if err != nil {
+		return fmt.Errorf("sending backup: %w", err)
+	}
+	printSkipped(report)
+
+	infof("Starting the daemon on %s...", host)
+	if err := sshRun(host, "systemctl", "--user", "start", "puckd"); err != nil {
+		return err
+	}
+	remote, err := daemon.NewClientForContext(config.Context{Name: host, Type: config.ContextSSH, Host: host})
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(migrateStartTimeout)
+	for {
+		err := remote.Ping()
+		if err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("the daemon on %s did not come up: %w", host, err)
+		}
+		time.Sleep(time.Second)
+	}
+
+	// Bring pucks back as they were here
+	expected := make(map[string]store.Status, len(pucks))
+	failures := make(map[string]string)
+	for _, p := range pucks {
+		switch {
+		case p.Status.Up():
+			infof("Recreating %s from %s...", p.Name, p.Image)
+			expected[p.Name] = store.StatusRunning
+			if _, err := remote.Recreate(puck.RecreateOptions{Name: p.Name, NoSnapshot: true}); err != nil {
+				failures[p.Name] = err.Error()
+			}
+		case (p.Status == store.StatusCheckpointed || p.Status == store.StatusSuspended) && migrateSnapshots:
+			infof("Resuming %s from its checkpoint...", p.Name)
+			expected[p.Name] = store.StatusRunning
+			if err := remote.Start(p.Name); err != nil {
+				failures[p.Name] = err.Error()
+			}
+		default:
+			expected[p.Name] = store.StatusStopped
+		}
+	}
+
+	// Check every puck arrived in the state expected
+	infof("")
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PUCK\tHERE\tTHERE\tRESULT")
+	failed := 0
+	for _, p := range pucks {
+		result := "ok"
+		status := "-"
+		got, err := remote.Get(p.Name)
+		switch {
+		case failures[p.Name] != "":
+			result = "failed: " + failures[p.Name]
+		case err != nil:
+			result = "failed: " + err.Error()
+		case got.Status != expected[p.Name]:
+			result = fmt.Sprintf("failed: expected %s", expected[p.Name])
+		}
+		if got != nil {
+			status = string(got.Status)
+		}
+		if result != "ok" {
+			failed++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", p.Name, p.Status, status, result)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if err := batchStatus("migrate cleanly", failed, len(pucks)); err != nil {
+		return err
+	}
+	infof("\nAll pucks are on %s. Those here are untouched; destroy them once you're happy.", host)
+	return nil
+}
@@ -0,0 +1,23 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateHost(t *testing.T) {
+	host, err := migrateHost("ssh://me@newbox")
+	require.NoError(t, err)
+	assert.Equal(t, "me@newbox", host)
+
+	for _, to := range []string{
+		"ssh://",
+		"ssh://-oProxyCommand=touch /tmp/pwned",
+		"ssh://newbox -oProxyCommand=sh",
+	} {
+		_, err := migrateHost(to)
+		assert.Error(t, err, to)
+	}
+}
@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/log"
+	"github.com/muesli/termenv"
+)
+
+// Output modes. Results go to stdout and everything else (progress,
+// confirmations, hints) to stderr, so stdout can be piped. --quiet drops
+// the rest and cuts results down to names or IDs.
+var (
+	quiet   bool
+	noColor bool
+)
+
+// infof tells the user what a command is doing or did, on stderr unless
+// --quiet is given
+func infof(format string, args ...any) {
+	if quiet {
+		return
+	}
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+}
+
+// plainOutput reports whether output should have no colors or terminal
+// escapes: --no-color, NO_COLOR (https://no-color.org) or a CI run
+func plainOutput() bool {
+	return noColor || os.Getenv("NO_COLOR") != "" || os.Getenv("CI") != ""
+}
+
+// setupOutput applies the output modes to the logger
+func setupOutput() {
+	if plainOutput() {
+		log.SetColorProfile(termenv.Ascii)
+	}
+	if quiet && !verbose {
+		log.SetLevel(log.WarnLevel)
+	}
+}
@@ -0,0 +1,181 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dustin/go-humanize"
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var poolCmd = &cobra.Command{
+	Use:   "pool",
+	Short: "Keep pucks made from a template ready to hand out",
+	Long: `Keep a pool of pucks made ahead of time from a template, so
+'puck create --from-pool' hands one out in about a second instead of
+pulling, creating and provisioning a new one.
+
+The daemon creates each pooled puck, clones its repo, runs its provisioning
+scripts, waits for its app to answer, then suspends it to a checkpoint
+(see 'puck stop --checkpoint'). Handing one out resumes it with its
+processes already running and routes it, and the daemon makes another in
+the background to take its place. An empty pool creates a puck as usual.
+
+Pooled pucks are named from name_pattern when made, as {{PUCK_NAME}} is
+filled in then too, so create --from-pool takes no name. They don't show
+in puck list until handed out, and need CRIU, like any checkpoint. Pools
+are your own: another user's node pool is a different pool.
+
+Examples:
+  puck pool create node --size 3 --var NODE_VERSION=22
+  puck create --from-pool node
+  puck pool list
+  puck pool remove node`,
+}
+
+var poolCreateCmd = &cobra.Command{
+	Use:   "create <template>",
+	Short: "Create a pool from a template, or resize it",
+	Long: `Create a pool of pucks made from a template, named after the template
+unless given --name. Its variables are asked for now, as for 'puck create
+--template', and every puck in the pool gets the same values.
+
+Creating a pool that exists replaces its settings and size. Pucks already
+waiting keep the settings they were made with; surplus ones are destroyed.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPoolCreate,
+}
+
+var poolListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List your pools and how many pucks each has ready",
+	Args:    cobra.NoArgs,
+	RunE:    runPoolList,
+}
+
+var poolRemoveCmd = &cobra.Command{
+	Use:     "remove <pool>",
+	Aliases: []string{"rm"},
+	Short:   "Remove a pool, destroying the pucks waiting in it",
+	Long: `Remove a pool and destroy the pucks waiting in it. Pucks already handed
+out are left alone.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPoolRemove,
+}
+
+var (
+	poolSize   int
+	poolName   string
+	poolVars   []string
+	poolFormat string
+)
+
+func init() {
+	poolCreateCmd.Flags().IntVar(&poolSize, "size", 2, fmt.Sprintf("how many pucks to keep ready (at most %d)", puck.MaxPoolSize))
+	poolCreateCmd.Flags().StringVar(&poolName, "name", "", "name of the pool (default: the template's name)")
+	poolCreateCmd.Flags().StringArrayVar(&poolVars, "var", nil, "set a template variable as NAME=value (repeatable)")
+	addFormatFlag(poolListCmd, &poolFormat)
+
+	poolCmd.AddCommand(poolCreateCmd)
+	poolCmd.AddCommand(poolListCmd)
+	poolCmd.AddCommand(poolRemoveCmd)
+}
+
+func runPoolCreate(cmd *cobra.Command, args []string) error {
+	name := poolName
+	if name == "" {
+		name = templatePoolName(args[0])
+	}
+
+	// The daemon names each puck as it makes it
+	opts := puck.CreateOptions{Name: puck.PoolNamePlaceholder}
+	if err := applyTemplate(cmd, args[0], poolVars, &opts); err != nil {
+		return err
+	}
+	options, err := json.Marshal(opts)
+	if err != nil {
+		return err
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	p, err := client.PoolCreate(&store.Pool{
+		Name:        name,
+		Size:        poolSize,
+		NamePattern: viper.GetString("name_pattern"),
+		Options:     options,
+	})
+	if err != nil {
+		return err
+	}
+
+	infof("Pool '%s' keeps %d pucks ready (%d now); use it with: puck create --from-pool %s", p.Name, p.Size, p.Ready, p.Name)
+	return nil
+}
+
+// templatePoolName names a pool after the template it is made from, e.g.
+// puck-node for gh:me/puck-node#main
+func templatePoolName(ref string) string {
+	ref, _, _ = strings.Cut(ref, "#")
+	if i := strings.LastIndex(ref, ":"); i >= 0 {
+		ref = ref[i+1:]
+	}
+	return strings.TrimSuffix(path.Base(strings.TrimRight(ref, "/")), ".git")
+}
+
+func runPoolList(cmd *cobra.Command, args []string) error {
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	pools, err := client.PoolList()
+	if err != nil {
+		return err
+	}
+
+	if poolFormat != "" {
+		return printFormatted(poolFormat, pools, nil)
+	}
+	if len(pools) == 0 {
+		infof("No pools. Create one with: puck pool create <template> --size <n>")
+		return nil
+	}
+	if quiet {
+		for _, p := range pools {
+			fmt.Println(p.Name)
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "POOL\tREADY\tFILLING\tSIZE\tCREATED")
+	for _, p := range pools {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\n", p.Name, p.Ready, p.Filling, p.Size, humanize.Time(p.CreatedAt))
+	}
+	return w.Flush()
+}
+
+func runPoolRemove(cmd *cobra.Command, args []string) error {
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	if err := client.PoolRemove(args[0]); err != nil {
+		return err
+	}
+	infof("Removed pool '%s'", args[0])
+	return nil
+}
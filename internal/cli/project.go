@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/docker/go-units"
+	"github.com/spf13/cobra"
+)
+
+var projectCmd = &cobra.Command{
+	Use:   "project",
+	Short: "Show per-project usage and quotas",
+}
+
+var projectStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show each project's usage against its limits",
+	Long: `Show how many pucks each project has, how many are running and how
+much disk they use, next to the limits set under projects: in the config.
+
+Pucks join a project with puck create --project. Limits are checked when
+a puck is created or started.
+
+Examples:
+  puck project status`,
+	Args: cobra.NoArgs,
+	RunE: runProjectStatus,
+}
+
+func init() {
+	projectCmd.AddCommand(projectStatusCmd)
+}
+
+func runProjectStatus(cmd *cobra.Command, args []string) error {
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	statuses, err := client.ProjectStatus()
+	if err != nil {
+		return err
+	}
+
+	if len(statuses) == 0 {
+		infof("No projects. Create a puck with --project to start one.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PROJECT\tPUCKS\tRUNNING\tDISK\tMEMBERS")
+	for _, s := range statuses {
+		disk := units.BytesSize(float64(s.Disk))
+		if s.Limits.MaxDisk > 0 {
+			disk += " / " + units.BytesSize(float64(s.Limits.MaxDisk))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			s.Name,
+			usageOf(s.Pucks, s.Limits.MaxPucks),
+			usageOf(s.Running, s.Limits.MaxRunning),
+			disk,
+			valueOr(strings.Join(s.Members, ", "), "-"))
+	}
+	return w.Flush()
+}
+
+// usageOf formats a count against its limit, where 0 means no limit
+func usageOf(n, limit int) string {
+	if limit == 0 {
+		return fmt.Sprint(n)
+	}
+	return fmt.Sprintf("%d / %d", n, limit)
+}
@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/spf13/cobra"
+)
+
+var promoteCmd = &cobra.Command{
+	Use:   "promote <new> --replace <old>",
+	Short: "Swap a puck in for another without downtime",
+	Long: `Point another puck's route and aliases at a puck in one router reload,
+so pucks and people using /<old> are served by the new puck without
+changing their URLs. The new puck must be running.
+
+/<old> becomes an alias of the new puck; remove it with 'puck route alias
+remove /<old>' to give the old puck its path back. With --stop the old
+puck is stopped once nothing is routed to it.
+
+Examples:
+  puck promote backend-v2 --replace backend-v1
+  puck promote backend-v2 --replace backend-v1 --stop`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPromote,
+}
+
+var (
+	promoteReplace string
+	promoteStop    bool
+)
+
+func init() {
+	promoteCmd.Flags().StringVar(&promoteReplace, "replace", "", "puck whose route and aliases to take over")
+	promoteCmd.Flags().BoolVar(&promoteStop, "stop", false, "stop the replaced puck afterwards")
+	promoteCmd.MarkFlagRequired("replace")
+}
+
+func runPromote(cmd *cobra.Command, args []string) error {
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	result, err := client.Promote(puck.PromoteOptions{Name: args[0], Replace: promoteReplace, StopOld: promoteStop})
+	if err != nil {
+		return err
+	}
+
+	infof("Puck '%s' now serves %s", result.Puck, strings.Join(result.Paths, ", "))
+	switch {
+	case result.Stopped:
+		infof("Stopped puck '%s'", result.Replaced)
+	case promoteStop:
+		fmt.Fprintf(os.Stderr, "Warning: could not stop puck '%s'; see the daemon log\n", result.Replaced)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/spf13/cobra"
+)
+
+var promptCmd = &cobra.Command{
+	Use:   "prompt",
+	Short: "Print a shell prompt segment for the current puck or context",
+	Long: `Print a short segment for a shell prompt saying which environment the
+terminal is attached to: puck:<name> in a puck console, puck@<context>
+on a host using a context other than the default, and nothing
+otherwise. It reads no more than the contexts file, so it is cheap to
+run on every prompt.
+
+puck console sets PUCK_CONSOLE to the puck's name, so prompts inside a
+puck can show it without the puck command. 'puck prompt init' prints a
+snippet that does both for your shell or prompt.
+
+With --json the puck and context are printed as an object instead, for
+prompts that do their own formatting.
+
+Examples:
+  puck prompt
+  puck prompt --json
+  puck prompt init starship >> ~/.config/starship.toml
+  eval "$(puck prompt init bash)"`,
+	Args: cobra.NoArgs,
+	RunE: runPrompt,
+}
+
+var promptInitCmd = &cobra.Command{
+	Use:       "init <bash|zsh|pure|starship>",
+	Short:     "Print prompt integration for a shell or prompt",
+	Long:      `Print the snippet that adds the puck segment to a shell's prompt, or the modules for starship.toml. For pure, eval it after 'prompt pure'.`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: []string{"bash", "zsh", "pure", "starship"},
+	RunE:      runPromptInit,
+}
+
+var promptJSON bool
+
+func init() {
+	promptCmd.Flags().BoolVar(&promptJSON, "json", false, "print the puck and context as JSON")
+	promptCmd.AddCommand(promptInitCmd)
+}
+
+// promptInfo is what a terminal is attached to
+type promptInfo struct {
+	Puck    string `json:"puck,omitempty"`
+	Context string `json:"context"`
+}
+
+// segment is the prompt text for the info: the puck in a console, or a
+// context other than the default
+func (i promptInfo) segment() string {
+	switch {
+	case i.Puck != "":
+		return "puck:" + i.Puck
+	case i.Context != config.DefaultContext:
+		return "puck@" + i.Context
+	}
+	return ""
+}
+
+func runPrompt(cmd *cobra.Command, args []string) error {
+	cs, err := config.LoadContexts(config.ContextsPath())
+	if err != nil {
+		return err
+	}
+	info := promptInfo{Puck: os.Getenv(puck.ConsoleEnv), Context: cs.ActiveName()}
+
+	if promptJSON {
+		data, err := json.Marshal(info)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	if s := info.segment(); s != "" {
+		fmt.Println(s)
+	}
+	return nil
+}
+
+// promptSnippets show PUCK_CONSOLE directly, since pucks don't have the
+// puck command, and ask puck prompt for the context on the host
+var promptSnippets = map[string]string{
+	"bash": `# puck: show the puck a console is attached to, or the active context
+__puck_ps1() {
+  if [ -n "$PUCK_CONSOLE" ]; then
+    printf '[puck:%s] ' "$PUCK_CONSOLE"
+  elif command -v puck >/dev/null 2>&1; then
+    local s
+    s=$(puck prompt 2>/dev/null) && [ -n "$s" ] && printf '[%s] ' "$s"
+  fi
+}
+PS1='$(__puck_ps1)'"$PS1"
+`,
+	"zsh": `# puck: show the puck a console is attached to, or the active context
+setopt prompt_subst
+__puck_prompt() {
+  if [[ -n $PUCK_CONSOLE ]]; then
+    print -n "%F{cyan}puck:$PUCK_CONSOLE%f "
+  elif (( $+commands[puck] )); then
+    local s=$(puck prompt 2>/dev/null)
+    [[ -n $s ]] && print -n "%F{cyan}$s%f "
+  fi
+}
+PROMPT='$(__puck_prompt)'"$PROMPT"
+`,
+	"starship": `# puck: show the puck a console is attached to
+[env_var.PUCK_CONSOLE]
+format = '[puck:$env_value]($style) '
+style = 'bold cyan'
+
+# puck: show the active context on the host, unless it's the default
+[custom.puck]
+command = 'puck prompt'
+when = 'test -z "$PUCK_CONSOLE" && command -v puck'
+shell = ['sh']
+format = '([$output]($style) )'
+style = 'bold cyan'
+`,
+}
+
+func runPromptInit(cmd *cobra.Command, args []string) error {
+	shell := args[0]
+	if shell == "pure" {
+		// pure keeps zsh's PROMPT, so the zsh snippet works once pure is set up
+		shell = "zsh"
+	}
+	snippet, ok := promptSnippets[shell]
+	if !ok {
+		return &usageError{err: fmt.Errorf("unknown shell %q (want bash, zsh, pure or starship)", args[0])}
+	}
+	fmt.Print(snippet)
+	return nil
+}
@@ -0,0 +1,214 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/sandwich-labs/puck/internal/netproxy"
+	"github.com/spf13/cobra"
+)
+
+var proxyCmd = &cobra.Command{
+	Use:   "proxy <name>",
+	Short: "Proxy host tools into a puck's network",
+	Long: `Start a SOCKS5 and HTTP proxy whose connections originate inside a
+puck's network namespace, until interrupted.
+
+Browsers and tools like curl pointed at the proxy reach what the puck
+reaches, as the puck: its localhost, the other pucks and hosts it can
+resolve, and only what its egress policy allows. The same port speaks
+both protocols. Use socks5h:// so names are resolved by the puck too.
+
+The puck is started if it isn't running. Over an ssh context the proxy
+runs on the remote host and its port is forwarded to this machine.
+
+Examples:
+  puck proxy web
+  curl -x socks5h://127.0.0.1:1080 http://localhost:3000/
+  curl -x http://127.0.0.1:1080 http://api:8080/health
+  puck proxy web --listen 127.0.0.1:8888`,
+	Args: cobra.ExactArgs(1),
+	RunE: runProxy,
+}
+
+var proxyServeCmd = &cobra.Command{
+	Use:    "proxy-serve",
+	Short:  "Serve proxy connections on a unix socket",
+	Long:   `Serve SOCKS5 and HTTP proxy connections on a unix socket until stdin closes. puck proxy runs it inside a puck's network namespace.`,
+	Hidden: true,
+	Args:   cobra.NoArgs,
+	RunE:   runProxyServe,
+}
+
+var (
+	proxyListen string
+	proxySocket string
+)
+
+func init() {
+	proxyCmd.Flags().StringVar(&proxyListen, "listen", "127.0.0.1:1080", "address for the proxy to listen on")
+	proxyServeCmd.Flags().StringVar(&proxySocket, "socket", "", "unix socket to listen on")
+	proxyServeCmd.MarkFlagRequired("socket")
+}
+
+func runProxy(cmd *cobra.Command, args []string) error {
+	name, err := selectContext(args[0])
+	if err != nil {
+		return err
+	}
+
+	_, port, err := net.SplitHostPort(proxyListen)
+	if err != nil {
+		return fmt.Errorf("invalid listen address %q: %w", proxyListen, err)
+	}
+
+	active, err := config.ActiveContext()
+	if err != nil {
+		return err
+	}
+	switch active.Type {
+	case config.ContextSSH:
+		ssh := exec.Command("ssh", "-T", "-L", proxyListen+":127.0.0.1:"+port, active.Host,
+			"--", "puck", "proxy", name, "--listen", "127.0.0.1:"+port)
+		ssh.Stdin = os.Stdin
+		ssh.Stdout = os.Stdout
+		ssh.Stderr = os.Stderr
+		return ssh.Run()
+	case config.ContextTCP, config.ContextTailnet:
+		return fmt.Errorf("proxy is not available over %s contexts; add an ssh context for %s", active.Type, active.Address)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	mgr, closeMgr, err := localManager(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeMgr()
+
+	dir, err := os.MkdirTemp("", "puck-proxy-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "proxy.sock")
+
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	server, err := mgr.NetnsCommand(ctx, name, self, "proxy-serve", "--socket", socket)
+	if err != nil {
+		return err
+	}
+	// The server exits when its stdin closes, which also reaches it
+	// through podman unshare where a signal to the wrapper wouldn't
+	stdin, err := server.StdinPipe()
+	if err != nil {
+		return err
+	}
+	defer stdin.Close()
+	server.Stderr = os.Stderr
+
+	ln, err := net.Listen("tcp", proxyListen)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+
+	if err := server.Start(); err != nil {
+		return fmt.Errorf("starting proxy in puck '%s': %w", name, err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- server.Wait() }()
+
+	if err := waitForSocket(socket, exited); err != nil {
+		return err
+	}
+
+	infof("Proxying into puck '%s' on %s (SOCKS5 and HTTP)", name, ln.Addr())
+	infof("Try: curl -x socks5h://%s http://localhost/", ln.Addr())
+	infof("Press Ctrl-C to stop")
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-exited:
+		}
+		ln.Close()
+	}()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("proxy in puck '%s' stopped", name)
+		}
+		go forwardToSocket(conn, socket)
+	}
+}
+
+// waitForSocket waits for the proxy server to listen, or to fail
+func waitForSocket(socket string, exited <-chan error) error {
+	deadline := time.After(30 * time.Second)
+	for {
+		if _, err := os.Stat(socket); err == nil {
+			return nil
+		}
+		select {
+		case err := <-exited:
+			return fmt.Errorf("proxy server exited: %v", err)
+		case <-deadline:
+			return fmt.Errorf("timed out waiting for the proxy server")
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// forwardToSocket relays a client connection to the proxy server
+func forwardToSocket(conn net.Conn, socket string) {
+	defer conn.Close()
+
+	upstream, err := net.Dial("unix", socket)
+	if err != nil {
+		return
+	}
+	defer upstream.Close()
+
+	done := make(chan struct{})
+	go func() {
+		io.Copy(upstream, conn)
+		upstream.(*net.UnixConn).CloseWrite()
+		close(done)
+	}()
+	io.Copy(conn, upstream)
+	conn.(*net.TCPConn).CloseWrite()
+	<-done
+}
+
+func runProxyServe(cmd *cobra.Command, args []string) error {
+	ln, err := net.Listen("unix", proxySocket)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+
+	go func() {
+		io.Copy(io.Discard, os.Stdin)
+		ln.Close()
+	}()
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	return (&netproxy.Server{Dial: dialer.DialContext}).Serve(ln)
+}
@@ -0,0 +1,173 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var psCmd = &cobra.Command{
+	Use:   "ps",
+	Short: "List pucks in the style of podman ps",
+	Long: `List pucks with the columns and flags of podman ps, for scripts and
+habits carried over from podman. Only running pucks are shown unless
+--all is given.
+
+--format takes a Go template over the fields ID, Image, Command,
+CreatedAt, CreatedHuman, Status, State, Ports, Names (or Name) and
+Project, as podman does. Prefix it with "table" for aligned columns
+under a header, or pass "json" for a JSON array or "jsonpath=<expr>".
+
+Examples:
+  puck ps
+  puck ps -a
+  puck ps -q
+  puck ps --format '{{.Name}} {{.Status}}'
+  puck ps -a --format 'table {{.Names}}\t{{.State}}'`,
+	Args: cobra.NoArgs,
+	RunE: runPs,
+}
+
+var (
+	psAll     bool
+	psNoTrunc bool
+	psFormat  string
+)
+
+func init() {
+	psCmd.Flags().BoolVarP(&psAll, "all", "a", false, "show all pucks, not just running ones")
+	psCmd.Flags().BoolVar(&psNoTrunc, "no-trunc", false, "don't truncate container IDs and commands")
+	addFormatFlag(psCmd, &psFormat)
+}
+
+// psRow is one puck as podman ps would describe its container
+type psRow struct {
+	ID           string
+	Image        string
+	Command      string
+	CreatedAt    string
+	CreatedHuman string
+	Status       string
+	State        string
+	Ports        string
+	Names        string
+	Name         string
+	Project      string
+}
+
+// psHeaders are the column titles podman ps uses for each field
+var psHeaders = map[string]string{
+	"ID":           "CONTAINER ID",
+	"CreatedAt":    "CREATED AT",
+	"CreatedHuman": "CREATED",
+}
+
+// psDefaultFormat matches podman ps's default columns
+const psDefaultFormat = `table {{.ID}}\t{{.Image}}\t{{.Command}}\t{{.CreatedHuman}}\t{{.Status}}\t{{.Ports}}\t{{.Names}}`
+
+func runPs(cmd *cobra.Command, args []string) error {
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	pucks, err := client.List()
+	if err != nil {
+		return err
+	}
+
+	var rows []psRow
+	for _, p := range pucks {
+		if psAll || p.Status == store.StatusRunning || p.Status == store.StatusStarting {
+			rows = append(rows, newPsRow(p, psNoTrunc))
+		}
+	}
+
+	if quiet {
+		for _, r := range rows {
+			fmt.Println(r.ID)
+		}
+		return nil
+	}
+
+	format := psFormat
+	if format == "" {
+		format = psDefaultFormat
+	}
+	return printFormatted(format, rows, psHeaders)
+}
+
+// newPsRow describes a puck the way podman ps describes a container
+func newPsRow(p *store.Puck, noTrunc bool) psRow {
+	id := valueOr(p.ContainerID, p.ID)
+	command := strings.Join(append(append([]string{}, p.Spec.Entrypoint...), p.Spec.Command...), " ")
+	if !noTrunc {
+		if len(id) > 12 {
+			id = id[:12]
+		}
+		if len(command) > 20 {
+			command = command[:17] + "..."
+		}
+	}
+
+	ports := make([]string, 0, len(p.Ports)+len(p.Published))
+	for _, port := range append(append([]string{}, p.Ports...), p.Published...) {
+		ports = append(ports, psPort(port))
+	}
+
+	var state, status string
+	switch p.Status {
+	case store.StatusRunning, store.StatusStarting:
+		state, status = "running", "Up"
+		if p.Health != "" {
+			status += " (" + p.Health + ")"
+		}
+	case store.StatusCreating:
+		state, status = "created", "Created"
+	case store.StatusCheckpointed:
+		state, status = "exited", "Exited (checkpointed)"
+	case store.StatusSuspended:
+		state, status = "exited", "Exited (suspended)"
+	case store.StatusError:
+		state, status = "exited", "Error"
+	default:
+		state, status = "exited", "Exited"
+	}
+
+	return psRow{
+		ID:           id,
+		Image:        p.Image,
+		Command:      command,
+		CreatedAt:    p.CreatedAt.Format("2006-01-02 15:04:05 -0700 MST"),
+		CreatedHuman: humanize.Time(p.CreatedAt),
+		Status:       status,
+		State:        state,
+		Ports:        strings.Join(ports, ", "),
+		Names:        p.Name,
+		Name:         p.Name,
+		Project:      p.Project,
+	}
+}
+
+// psPort renders a -p style mapping like 8080:80 the way podman ps
+// does, as 0.0.0.0:8080->80/tcp
+func psPort(mapping string) string {
+	proto := "tcp"
+	if i := strings.LastIndex(mapping, "/"); i >= 0 {
+		mapping, proto = mapping[:i], mapping[i+1:]
+	}
+
+	parts := strings.Split(mapping, ":")
+	switch len(parts) {
+	case 1:
+		return fmt.Sprintf("%s/%s", parts[0], proto)
+	case 2:
+		return fmt.Sprintf("0.0.0.0:%s->%s/%s", parts[0], parts[1], proto)
+	default:
+		host := strings.Join(parts[:len(parts)-2], ":")
+		return fmt.Sprintf("%s:%s->%s/%s", host, parts[len(parts)-2], parts[len(parts)-1], proto)
+	}
+}
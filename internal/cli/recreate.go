@@ -0,0 +1,54 @@
+package cli
+
+import (
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/spf13/cobra"
+)
+
+var recreateCmd = &cobra.Command{
+	Use:   "recreate <name>",
+	Short: "Recreate a puck from the latest image",
+	Long: `Pull the latest version of a puck's image and replace its container.
+
+The puck keeps its volumes, ports, route settings, and snapshots; only
+the container is rebuilt. Use --image to switch to a different image.
+
+A running puck is snapshotted first so 'puck rollback' can return it to
+its state before the recreate. Use --no-snapshot to skip this, or set
+snapshot_before_recreate: false in the config to turn it off entirely.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRecreate,
+}
+
+var (
+	recreateImage      string
+	recreateNoSnapshot bool
+)
+
+func init() {
+	recreateCmd.Flags().StringVarP(&recreateImage, "image", "i", "", "image to recreate from (default: the puck's current image)")
+	recreateCmd.Flags().BoolVar(&recreateNoSnapshot, "no-snapshot", false, "don't snapshot the puck for rollback first")
+}
+
+func runRecreate(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	endProgress := showPullProgress(client)
+	p, err := client.Recreate(puck.RecreateOptions{
+		Name:       name,
+		Image:      recreateImage,
+		NoSnapshot: recreateNoSnapshot,
+	})
+	endProgress()
+	if err != nil {
+		return err
+	}
+
+	infof("Recreated puck '%s' from %s", p.Name, p.Image)
+	return nil
+}
@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/dustin/go-humanize"
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/spf13/cobra"
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Sum up what each puck did over the last week",
+	Long: `Sum up, for each puck, what it did over a period (the last week by
+default): how long it ran, the requests the router served it, its
+average CPU and memory while up, how much its volumes and snapshots grew,
+and the snapshots taken.
+
+Uptime and snapshots come from the pucks' history. The rest comes from
+samples the daemon takes every 15 minutes and keeps for 90 days, so it
+covers only the time the daemon was running. Pucks that served no
+requests and weren't used through puck (console, exec, start and the
+like) in the whole period are marked idle, as candidates to destroy.
+
+Examples:
+  puck report
+  puck report --since 720h
+  puck report --json`,
+	Args: cobra.NoArgs,
+	RunE: runReport,
+}
+
+var (
+	reportSince  time.Duration
+	reportJSON   bool
+	reportFormat string
+)
+
+func init() {
+	reportCmd.Flags().DurationVar(&reportSince, "since", 7*24*time.Hour, "how far back the report goes (e.g. 24h)")
+	reportCmd.Flags().BoolVar(&reportJSON, "json", false, "print the report as JSON")
+	addFormatFlag(reportCmd, &reportFormat)
+}
+
+func runReport(cmd *cobra.Command, args []string) error {
+	if reportSince <= 0 {
+		return fmt.Errorf("--since must be positive")
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	report, err := client.Report(time.Now().Add(-reportSince))
+	if err != nil {
+		return err
+	}
+
+	if reportJSON {
+		reportFormat = "json"
+	}
+	if reportFormat != "" {
+		return printFormatted(reportFormat, report, nil)
+	}
+	if len(report.Pucks) == 0 {
+		infof("No pucks.")
+		return nil
+	}
+
+	infof("Since %s (%s)", report.Since.Local().Format("2006-01-02 15:04"), formatDuration(report.Until.Sub(report.Since)))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSTATUS\tUPTIME\tREQUESTS\tCPU\tMEMORY\tDISK\tGROWTH\tSNAPSHOTS\tLAST USED")
+	var idle []string
+	for _, r := range report.Pucks {
+		name := r.Name
+		if r.Idle {
+			name += " (idle)"
+			idle = append(idle, r.Name)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\t%s\t%s\t%d\t%s\n",
+			name,
+			r.Status,
+			formatDuration(r.Uptime),
+			r.Requests,
+			reportUsage(r, fmt.Sprintf("%.1f%%", r.CPU)),
+			reportUsage(r, units.BytesSize(float64(r.Memory))),
+			reportDisk(r, units.BytesSize(float64(r.Disk))),
+			reportDisk(r, diskGrowth(r.DiskGrowth)),
+			r.Snapshots,
+			lastUsed(r),
+		)
+	}
+	w.Flush()
+
+	if len(idle) > 0 {
+		infof("\nIdle over the whole period: %s", strings.Join(idle, ", "))
+	}
+	return nil
+}
+
+// reportUsage shows a usage average, or "-" for pucks never sampled up
+func reportUsage(r puck.PuckReport, s string) string {
+	if r.CPU == 0 && r.Memory == 0 {
+		return "-"
+	}
+	return s
+}
+
+// reportDisk shows a disk figure, or "-" for pucks never sampled
+func reportDisk(r puck.PuckReport, s string) string {
+	if r.Samples == 0 {
+		return "-"
+	}
+	return s
+}
+
+// diskGrowth shows a change in size with its sign
+func diskGrowth(n int64) string {
+	switch {
+	case n > 0:
+		return "+" + units.BytesSize(float64(n))
+	case n < 0:
+		return "-" + units.BytesSize(float64(-n))
+	}
+	return "0B"
+}
+
+// lastUsed shows when a puck was last used through puck
+func lastUsed(r puck.PuckReport) string {
+	if r.LastUsed == nil {
+		return "never"
+	}
+	return humanize.Time(*r.LastUsed)
+}
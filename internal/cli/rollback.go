@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback <name>",
+	Short: "Undo the last recreate of a puck, or go back in time",
+	Long: `Restore a puck from the snapshot taken automatically before it was last
+recreated, bringing back its previous image and running state.
+
+The rollback point is the snapshot tagged 'rollback'; see
+'puck snapshot list <name>'.
+
+With --ago, restore the newest snapshot taken at least that long ago
+instead, to undo a mishap. To always have one from a few minutes back,
+have the daemon keep a rolling window of snapshots, say one every 10
+minutes for the last hour:
+
+  puck snapshot config <name> --schedule 10m --retain 6
+
+Examples:
+  puck rollback web
+  puck rollback web --ago 20m`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRollback,
+}
+
+var rollbackAgo time.Duration
+
+func init() {
+	rollbackCmd.Flags().DurationVar(&rollbackAgo, "ago", 0, "restore the newest snapshot at least this old (e.g. 20m)")
+}
+
+func runRollback(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	if rollbackAgo < 0 {
+		return fmt.Errorf("--ago must be positive")
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	var snapshot *store.Snapshot
+	if rollbackAgo > 0 {
+		snapshot, err = client.RollbackTo(name, time.Now().Add(-rollbackAgo))
+	} else {
+		snapshot, err = client.Rollback(name)
+	}
+	if err != nil {
+		return err
+	}
+
+	if rollbackAgo > 0 {
+		infof("Rolled back puck '%s' to snapshot '%s' from %s", name, snapshot.Name, humanize.Time(snapshot.CreatedAt))
+	} else if snapshot.Image != "" {
+		infof("Rolled back puck '%s' to snapshot '%s' (%s)", name, snapshot.Name, snapshot.Image)
+	} else {
+		infof("Rolled back puck '%s' to snapshot '%s'", name, snapshot.Name)
+	}
+	return nil
+}
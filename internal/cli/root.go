@@ -1,18 +1,24 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 
 	"github.com/charmbracelet/log"
+	"github.com/sandwich-labs/puck/internal/daemon"
+	"github.com/sandwich-labs/puck/internal/podman"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var (
-	cfgFile string
-	verbose bool
+	cfgFile     string
+	verbose     bool
+	contextName string
 )
 
 var rootCmd = &cobra.Command{
@@ -29,29 +35,243 @@ checkpoint and restore - your dev environment persists.`,
 func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default: ~/.config/puck/config.yaml)")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "verbose output")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "print only names or IDs, without progress or confirmations")
+	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "disable colors and terminal escapes (also NO_COLOR or CI)")
+
+	rootCmd.PersistentFlags().StringVar(&contextName, "context", "", "daemon context to use (default: current context, see puck context)")
 
 	viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose"))
+	viper.BindPFlag("context", rootCmd.PersistentFlags().Lookup("context"))
 
 	// Add subcommands
+	rootCmd.AddCommand(setupCmd)
+	rootCmd.AddCommand(initCmd)
+	rootCmd.AddCommand(applyCmd)
 	rootCmd.AddCommand(createCmd)
 	rootCmd.AddCommand(consoleCmd)
+	rootCmd.AddCommand(execCmd)
+	rootCmd.AddCommand(fsCmd)
+	rootCmd.AddCommand(codeCmd)
+	rootCmd.AddCommand(sshProxyCmd)
+	rootCmd.AddCommand(proxyCmd)
+	rootCmd.AddCommand(proxyServeCmd)
 	rootCmd.AddCommand(listCmd)
+	rootCmd.AddCommand(psCmd)
+	rootCmd.AddCommand(inspectCmd)
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(reportCmd)
+	rootCmd.AddCommand(usageCmd)
+	rootCmd.AddCommand(eventsCmd)
+	rootCmd.AddCommand(statsCmd)
+	rootCmd.AddCommand(setCmd)
+	rootCmd.AddCommand(groupCmd)
+	rootCmd.AddCommand(poolCmd)
+	rootCmd.AddCommand(egressCmd)
+	rootCmd.AddCommand(envCmd)
+	rootCmd.AddCommand(projectCmd)
+	rootCmd.AddCommand(syncCmd)
 	rootCmd.AddCommand(destroyCmd)
 	rootCmd.AddCommand(startCmd)
 	rootCmd.AddCommand(stopCmd)
+	rootCmd.AddCommand(killCmd)
+	rootCmd.AddCommand(recreateCmd)
+	rootCmd.AddCommand(rollbackCmd)
+	rootCmd.AddCommand(promoteCmd)
 	rootCmd.AddCommand(snapshotCmd)
+	rootCmd.AddCommand(templateCmd)
+	rootCmd.AddCommand(routeCmd)
+	rootCmd.AddCommand(routerCmd)
+	rootCmd.AddCommand(tailnetCmd)
+	rootCmd.AddCommand(shareCmd)
+	rootCmd.AddCommand(hooksCmd)
+	rootCmd.AddCommand(mcpCmd)
+	rootCmd.AddCommand(imageCmd)
+	rootCmd.AddCommand(scanCmd)
+	rootCmd.AddCommand(gcCmd)
+	rootCmd.AddCommand(dbCmd)
+	rootCmd.AddCommand(dataCmd)
+	rootCmd.AddCommand(machineCmd)
+	rootCmd.AddCommand(backupAllCmd)
+	rootCmd.AddCommand(restoreAllCmd)
+	rootCmd.AddCommand(migrateHostCmd)
 	rootCmd.AddCommand(daemonCmd)
+	rootCmd.AddCommand(contextCmd)
+	rootCmd.AddCommand(configCmd)
+	rootCmd.AddCommand(selfUpdateCmd)
+	rootCmd.AddCommand(selftestCmd)
+	rootCmd.AddCommand(promptCmd)
 	rootCmd.AddCommand(versionCmd)
+
+	// Add ssh host entries to cobra's completion command
+	rootCmd.InitDefaultCompletionCmd()
+	if completionCmd, _, err := rootCmd.Find([]string{"completion"}); err == nil {
+		completionCmd.AddCommand(sshConfigCompletionCmd)
+	}
 }
 
 func Execute() error {
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return &usageError{err: err}
+	})
+	markUsageErrors(rootCmd)
+
 	if err := rootCmd.Execute(); err != nil {
-		log.Error(err.Error())
+		// A command that ran and failed has already said why
+		switch err.(type) {
+		case *podman.ExitError, *exec.ExitError:
+		default:
+			log.Error(err.Error())
+			if hint := errorHint(err); hint != "" {
+				fmt.Fprintln(os.Stderr, hint)
+			}
+			offerSetup(err)
+		}
 		return err
 	}
 	return nil
 }
 
+// Exit statuses, so scripts and CI can react to how a command failed
+// without parsing its output. puck exec and console exit with the
+// command's own status instead.
+const (
+	exitFailure         = 1 // anything without a status of its own
+	exitUsage           = 2 // bad flags or arguments
+	exitDaemonDown      = 3 // the daemon isn't answering
+	exitNotFound        = 4
+	exitSomeFailed      = 5 // some of the pucks a command acted on failed
+	exitExists          = 6
+	exitPodmanDown      = 7
+	exitCRIUUnsupported = 8
+	exitPortsExhausted  = 9
+)
+
+// codeExits are the exit statuses for daemon errors with a code
+var codeExits = map[string]int{
+	daemon.CodeNotFound:          exitNotFound,
+	daemon.CodeAlreadyExists:     exitExists,
+	daemon.CodePodmanUnavailable: exitPodmanDown,
+	daemon.CodeCRIUUnsupported:   exitCRIUUnsupported,
+	daemon.CodePortExhausted:     exitPortsExhausted,
+}
+
+// ExitCode returns the status to exit with after Execute fails: that of
+// the command run in a puck or over ssh, or the one for how puck failed
+func ExitCode(err error) int {
+	var status interface{ ExitCode() int }
+	if errors.As(err, &status) && status.ExitCode() > 0 {
+		return status.ExitCode()
+	}
+	var daemonErr *daemon.Error
+	if errors.As(err, &daemonErr) && codeExits[daemonErr.Code] > 0 {
+		return codeExits[daemonErr.Code]
+	}
+	var connectErr *daemon.ConnectError
+	if errors.As(err, &connectErr) {
+		return exitDaemonDown
+	}
+	return exitFailure
+}
+
+// usageError is a command given bad flags or arguments
+type usageError struct {
+	err error
+}
+
+func (e *usageError) Error() string { return e.err.Error() }
+func (e *usageError) Unwrap() error { return e.err }
+func (e *usageError) ExitCode() int { return exitUsage }
+
+// markUsageErrors makes the argument errors of cmd and its subcommands
+// usageErrors
+func markUsageErrors(cmd *cobra.Command) {
+	if validate := cmd.Args; validate != nil {
+		cmd.Args = func(cmd *cobra.Command, args []string) error {
+			if err := validate(cmd, args); err != nil {
+				return &usageError{err: err}
+			}
+			return nil
+		}
+	}
+	for _, sub := range cmd.Commands() {
+		markUsageErrors(sub)
+	}
+}
+
+// codeHints say what to do about daemon errors with a code
+var codeHints = map[string]string{
+	daemon.CodeNotFound:        "List your pucks with: puck list",
+	daemon.CodeAlreadyExists:   "Pick another name, or destroy the existing one first",
+	daemon.CodeCRIUUnsupported: "Snapshot without CRIU with: puck snapshot create --mode image",
+	daemon.CodePortExhausted:   "Free host ports by destroying pucks you no longer need",
+}
+
+// errorHint returns what to do about err, or "" if there's nothing to add
+func errorHint(err error) string {
+	var connectErr *daemon.ConnectError
+	if errors.As(err, &connectErr) {
+		if connectErr.Context != "" {
+			return "Check the context with: puck context list"
+		}
+		return "Start with: puck daemon start"
+	}
+	var daemonErr *daemon.Error
+	if !errors.As(err, &daemonErr) {
+		return ""
+	}
+	if len(daemonErr.Hints) > 0 {
+		return strings.Join(daemonErr.Hints, "\n")
+	}
+	return codeHints[daemonErr.Code]
+}
+
+// execFailure is puck failing to run a command in a puck, which exits
+// 125 as podman exec does, so scripts can tell it from the command's own
+// statuses
+type execFailure struct {
+	err error
+}
+
+func (e *execFailure) Error() string { return e.err.Error() }
+func (e *execFailure) Unwrap() error { return e.err }
+func (e *execFailure) ExitCode() int { return 125 }
+
+// execStatus passes on a command's exit status and marks any other error
+// as an execFailure
+func execStatus(err error) error {
+	switch err.(type) {
+	case nil, *podman.ExitError, *exec.ExitError:
+		return err
+	}
+	return &execFailure{err: err}
+}
+
+// batchFailure is a command acting on several pucks failing for some or
+// all of them. Each puck's result has already been printed.
+type batchFailure struct {
+	failed, total int
+	action        string // e.g. "destroy", as in "failed to destroy"
+}
+
+func (e *batchFailure) Error() string {
+	return fmt.Sprintf("%d of %d pucks failed to %s", e.failed, e.total, e.action)
+}
+
+func (e *batchFailure) ExitCode() int {
+	if e.failed < e.total {
+		return exitSomeFailed
+	}
+	return exitFailure
+}
+
+// batchStatus returns a batchFailure when any of total pucks failed
+func batchStatus(action string, failed, total int) error {
+	if failed == 0 {
+		return nil
+	}
+	return &batchFailure{failed: failed, total: total, action: action}
+}
+
 func initConfig(cmd *cobra.Command, args []string) error {
 	if cfgFile != "" {
 		viper.SetConfigFile(cfgFile)
@@ -84,6 +304,7 @@ func initConfig(cmd *cobra.Command, args []string) error {
 	if verbose {
 		log.SetLevel(log.DebugLevel)
 	}
+	setupOutput()
 
 	return nil
 }
@@ -91,7 +312,9 @@ func initConfig(cmd *cobra.Command, args []string) error {
 var versionCmd = &cobra.Command{
 	Use:   "version",
 	Short: "Print version information",
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("puck version 0.1.0")
-	},
+	Long: `Print puck's version. With --verbose, also print the commit and date it
+was built from, and ask the daemon for its own version, data directory,
+the Podman and CRIU it drives and the optional features it has turned on.`,
+	Args: cobra.NoArgs,
+	RunE: runVersion,
 }
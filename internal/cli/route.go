@@ -0,0 +1,285 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/spf13/cobra"
+)
+
+var routeCmd = &cobra.Command{
+	Use:   "route",
+	Short: "Manage puck HTTP routes",
+	Long:  `Configure how the HTTP router proxies requests to pucks.`,
+}
+
+var routeSetCmd = &cobra.Command{
+	Use:   "set <name>",
+	Short: "Change router settings for a puck",
+	Long: `Change how the router proxies requests to a puck.
+
+Only the flags you pass are changed; other settings are kept. Durations
+accept Go syntax such as 500ms, 30s, or 10m. A flush interval of 0
+(the default) or -1ns streams responses immediately, which SSE
+endpoints and hot-reloading dev servers rely on.
+
+Use --protocol h2c for gRPC services that speak cleartext HTTP/2.
+
+Headers are given as Name=Value and may be repeated; an empty value
+removes the header. Rewrites are given as find=replace, where find is a
+regular expression applied to the path after the puck prefix is stripped.
+
+Rate limits are given as requests/window (e.g. 60/1m) and apply per
+client IP; use 0 to disable. Body sizes accept units such as 10MB.
+
+--max-conns and --rate cap the route as a whole, across all clients,
+which keeps a shared demo from swamping the puck. Requests beyond the
+connection limit get 503 Service Unavailable. The rate is in bytes per
+second (e.g. 1MB or 512KB/s) and paces request and response bodies;
+WebSocket traffic isn't throttled.
+
+Examples:
+  puck route set web --forwarded-prefix
+  puck route set web --response-header Cache-Control=no-store
+  puck route set api --rewrite '^/v1/(.*)=/api/$1'
+  puck route set api --rate-limit 60/1m --max-body 10MB
+  puck route set demo --max-conns 20 --rate 1MB`,
+	Args: cobra.ExactArgs(1),
+	RunE: runRouteSet,
+}
+
+var routeListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List the routes the router is serving",
+	Long: `List the routes the router is serving right now: where each puck's
+requests go, the paths and hosts it is reached on, the limits guarding it,
+and how many requests it has had since the router started.
+
+--json prints the full route table, including endpoints and domains, for
+scripts and debugging.`,
+	Args: cobra.NoArgs,
+	RunE: runRouteList,
+}
+
+var (
+	routeListJSON   bool
+	routeListFormat string
+)
+
+var (
+	routeProtocol         string
+	routeFlushInterval    time.Duration
+	routeReadTimeout      time.Duration
+	routeStreamTimeout    time.Duration
+	routeStreamCloseDelay time.Duration
+	routeForwardedPrefix  bool
+	routeRequestHeaders   []string
+	routeResponseHeaders  []string
+	routeRewrites         []string
+	routeClearRewrites    bool
+	routeRateLimit        string
+	routeMaxBody          string
+	routeMaxConns         int
+	routeBandwidth        string
+)
+
+func init() {
+	routeSetCmd.Flags().StringVar(&routeProtocol, "protocol", "", "upstream protocol: http or h2c")
+	routeSetCmd.Flags().DurationVar(&routeFlushInterval, "flush-interval", 0, "response flush interval (-1ns flushes immediately)")
+	routeSetCmd.Flags().DurationVar(&routeReadTimeout, "read-timeout", 0, "upstream read timeout (0 for no limit)")
+	routeSetCmd.Flags().DurationVar(&routeStreamTimeout, "stream-timeout", 0, "maximum lifetime of WebSocket connections (0 for no limit)")
+	routeSetCmd.Flags().DurationVar(&routeStreamCloseDelay, "stream-close-delay", 0, "keep WebSocket connections open this long across router reloads")
+
+	routeSetCmd.Flags().BoolVar(&routeForwardedPrefix, "forwarded-prefix", false, "send X-Forwarded-Prefix with the puck path prefix")
+	routeSetCmd.Flags().StringArrayVar(&routeRequestHeaders, "request-header", nil, "header to set on proxied requests (Name=Value)")
+	routeSetCmd.Flags().StringArrayVar(&routeResponseHeaders, "response-header", nil, "header to set on responses (Name=Value)")
+	routeSetCmd.Flags().StringArrayVar(&routeRewrites, "rewrite", nil, "path rewrite rule (find=replace)")
+	routeSetCmd.Flags().BoolVar(&routeClearRewrites, "clear-rewrites", false, "remove all existing rewrite rules")
+	routeSetCmd.Flags().StringVar(&routeRateLimit, "rate-limit", "", "requests per client IP, as count/window (0 disables)")
+	routeSetCmd.Flags().StringVar(&routeMaxBody, "max-body", "", "maximum request body size (0 disables)")
+	routeSetCmd.Flags().IntVar(&routeMaxConns, "max-conns", 0, "concurrent requests across all clients (0 disables)")
+	routeSetCmd.Flags().StringVar(&routeBandwidth, "rate", "", "bandwidth across all clients in bytes per second, e.g. 1MB (0 disables)")
+
+	routeListCmd.Flags().BoolVar(&routeListJSON, "json", false, "print the route table as JSON")
+	addFormatFlag(routeListCmd, &routeListFormat)
+
+	routeCmd.AddCommand(routeSetCmd)
+	routeCmd.AddCommand(routeListCmd)
+}
+
+func runRouteList(cmd *cobra.Command, args []string) error {
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	routes, err := client.Routes()
+	if err != nil {
+		return err
+	}
+
+	if routeListJSON {
+		routeListFormat = "json"
+	}
+	if routeListFormat != "" {
+		return printFormatted(routeListFormat, routes, nil)
+	}
+	if len(routes) == 0 {
+		infof("No routes. Pucks are routed while they are running.")
+		return nil
+	}
+	if quiet {
+		for _, r := range routes {
+			fmt.Println(r.Name)
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTARGET\tPATHS\tPROTOCOL\tHITS\tLAST REQUEST\tPROTECTIONS")
+	for _, r := range routes {
+		last := "-"
+		if r.LastRequest != nil {
+			last = humanize.Time(*r.LastRequest)
+		}
+		protections := strings.Join(r.Protections, ", ")
+		if protections == "" {
+			protections = "-"
+		}
+		target := r.Target
+		if r.Asleep {
+			target += " (asleep)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", r.Name, target, strings.Join(r.Paths, " "), r.Protocol, r.Hits, last, protections)
+	}
+	return w.Flush()
+}
+
+func runRouteSet(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	p, err := client.Get(name)
+	if err != nil {
+		return err
+	}
+
+	rc := p.Route
+	flags := cmd.Flags()
+	if flags.Changed("protocol") {
+		rc.Protocol = routeProtocol
+	}
+	if flags.Changed("flush-interval") {
+		rc.FlushInterval = routeFlushInterval
+	}
+	if flags.Changed("read-timeout") {
+		rc.ReadTimeout = routeReadTimeout
+	}
+	if flags.Changed("stream-timeout") {
+		rc.StreamTimeout = routeStreamTimeout
+	}
+	if flags.Changed("stream-close-delay") {
+		rc.StreamCloseDelay = routeStreamCloseDelay
+	}
+
+	if flags.Changed("forwarded-prefix") {
+		rc.ForwardedPrefix = routeForwardedPrefix
+	}
+	if rc.RequestHeaders, err = applyHeaderFlags(rc.RequestHeaders, routeRequestHeaders); err != nil {
+		return err
+	}
+	if rc.ResponseHeaders, err = applyHeaderFlags(rc.ResponseHeaders, routeResponseHeaders); err != nil {
+		return err
+	}
+	if routeClearRewrites {
+		rc.Rewrites = nil
+	}
+	for _, spec := range routeRewrites {
+		find, replace, ok := strings.Cut(spec, "=")
+		if !ok || find == "" {
+			return fmt.Errorf("invalid rewrite %q (expected find=replace)", spec)
+		}
+		rc.Rewrites = append(rc.Rewrites, store.Rewrite{Find: find, Replace: replace})
+	}
+
+	if flags.Changed("rate-limit") {
+		if rc.RateLimit, rc.RateLimitWindow, err = parseRateLimit(routeRateLimit); err != nil {
+			return err
+		}
+	}
+	if flags.Changed("max-body") {
+		size, err := humanize.ParseBytes(routeMaxBody)
+		if err != nil {
+			return fmt.Errorf("invalid body size %q: %w", routeMaxBody, err)
+		}
+		rc.MaxBodySize = int64(size)
+	}
+	if flags.Changed("max-conns") {
+		rc.MaxConns = routeMaxConns
+	}
+	if flags.Changed("rate") {
+		rate, err := humanize.ParseBytes(strings.TrimSuffix(routeBandwidth, "/s"))
+		if err != nil {
+			return fmt.Errorf("invalid rate %q: %w", routeBandwidth, err)
+		}
+		rc.Bandwidth = int64(rate)
+	}
+
+	if _, err := client.RouteSet(name, rc); err != nil {
+		return err
+	}
+
+	infof("Updated route for puck '%s'", name)
+	return nil
+}
+
+// applyHeaderFlags merges Name=Value specs into headers; empty values delete
+func applyHeaderFlags(headers map[string]string, specs []string) (map[string]string, error) {
+	for _, spec := range specs {
+		name, value, ok := strings.Cut(spec, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid header %q (expected Name=Value)", spec)
+		}
+		if headers == nil {
+			headers = make(map[string]string)
+		}
+		if value == "" {
+			delete(headers, name)
+			continue
+		}
+		headers[name] = value
+	}
+	return headers, nil
+}
+
+// parseRateLimit parses "count/window" such as "60/1m"; "0" disables
+func parseRateLimit(spec string) (int, time.Duration, error) {
+	if spec == "0" || spec == "" {
+		return 0, 0, nil
+	}
+
+	countStr, windowStr, ok := strings.Cut(spec, "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid rate limit %q (expected count/window, e.g. 60/1m)", spec)
+	}
+	count, err := strconv.Atoi(countStr)
+	if err != nil || count <= 0 {
+		return 0, 0, fmt.Errorf("invalid rate limit count %q", countStr)
+	}
+	window, err := time.ParseDuration(windowStr)
+	if err != nil || window <= 0 {
+		return 0, 0, fmt.Errorf("invalid rate limit window %q", windowStr)
+	}
+	return count, window, nil
+}
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/network"
+	"github.com/spf13/cobra"
+)
+
+var routerCmd = &cobra.Command{
+	Use:   "router",
+	Short: "Manage the HTTP router",
+	Long:  `Inspect and control the daemon's HTTP router.`,
+}
+
+var routerStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show HTTP router status",
+	Long:  `Show whether the HTTP router is serving and which port it is listening on.`,
+	Args:  cobra.NoArgs,
+	RunE:  runRouterStatus,
+}
+
+var routerRestartCmd = &cobra.Command{
+	Use:   "restart",
+	Short: "Restart the HTTP router",
+	Long: `Restart the HTTP router without restarting the daemon.
+
+The configured port is tried again, so this moves the router back after
+it fell back to another port, or starts it if the port was busy when the
+daemon started.`,
+	Args: cobra.NoArgs,
+	RunE: runRouterRestart,
+}
+
+func init() {
+	routerCmd.AddCommand(routerStatusCmd)
+	routerCmd.AddCommand(routerRestartCmd)
+}
+
+func runRouterStatus(cmd *cobra.Command, args []string) error {
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	st, err := client.RouterStatus()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(routerStatusLine(st))
+	return nil
+}
+
+func runRouterRestart(cmd *cobra.Command, args []string) error {
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	st, err := client.RouterRestart()
+	if err != nil {
+		return fmt.Errorf("restarting router: %w", err)
+	}
+
+	infof("Router restarted")
+	fmt.Println(routerStatusLine(st))
+	return nil
+}
+
+// routerStatusLine describes the router state, calling out port fallbacks
+func routerStatusLine(st *network.RouterStatus) string {
+	if st.Disabled {
+		return "Router: disabled (router_enabled: false); pucks are reached on their host ports"
+	}
+	if !st.Running {
+		if st.Error != "" {
+			return fmt.Sprintf("Router: not running (%s)\nRetry with: puck router restart", st.Error)
+		}
+		return "Router: not running"
+	}
+	line := fmt.Sprintf("Router: listening on port %d", st.Port)
+	if st.Port != st.RequestedPort {
+		line += fmt.Sprintf(" (port %d was busy)", st.RequestedPort)
+	}
+	if st.PID != 0 {
+		line += fmt.Sprintf("\nRouter process: pid %d", st.PID)
+		if st.Restarts > 0 {
+			line += fmt.Sprintf(", restarted %d times", st.Restarts)
+		}
+	}
+	if r := st.Reloads; r.Loads > 0 {
+		line += fmt.Sprintf("\nReloads: %d changes in %d loads, %d failed; last took %s",
+			r.Changes, r.Loads, r.Failed, r.LastDuration.Round(time.Millisecond))
+		if r.Pending {
+			line += ", another is pending"
+		}
+	}
+	return line
+}
@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sandwich-labs/puck/internal/scan"
+	"github.com/spf13/cobra"
+)
+
+var scanCmd = &cobra.Command{
+	Use:   "scan <name|image>",
+	Short: "Scan a puck's image for known vulnerabilities",
+	Long: `Scan the image a puck was created from, or any image, for known
+vulnerabilities, listing them most severe first.
+
+The daemon scans with Trivy or Grype when either is installed on its
+host, and otherwise runs Trivy in a container (scan_image in the config,
+by default docker.io/aquasec/trivy:latest), which needs nothing but
+podman. Set scanner in the config to trivy, grype or container to pick
+one. The first scan downloads the scanner's vulnerability database, so
+takes a while.
+
+With scan_on_create set to warn, puck create scans the image first and
+warns when it has vulnerabilities of scan_severity (by default critical)
+or worse; with refuse, it won't create the puck.
+
+Image references are taken as given, so name pucks in other contexts
+with --context rather than <context>/<name>.
+
+Examples:
+  puck scan myapp
+  puck scan docker.io/library/nginx:latest
+  puck scan myapp --severity high
+  puck scan myapp --fail-on critical`,
+	Args: cobra.ExactArgs(1),
+	RunE: runScan,
+}
+
+var (
+	scanJSON     bool
+	scanFormat   string
+	scanSeverity string
+	scanFailOn   string
+)
+
+func init() {
+	scanCmd.Flags().BoolVar(&scanJSON, "json", false, "print the findings as JSON")
+	addFormatFlag(scanCmd, &scanFormat)
+	scanCmd.Flags().StringVar(&scanSeverity, "severity", "", "only list findings this severe or worse (critical, high, medium, low)")
+	scanCmd.Flags().StringVar(&scanFailOn, "fail-on", "", "exit non-zero when there are findings this severe or worse")
+}
+
+func runScan(cmd *cobra.Command, args []string) error {
+	for _, sev := range []string{scanSeverity, scanFailOn} {
+		if sev != "" && !slices.Contains(scan.Severities, strings.ToUpper(sev)) {
+			return fmt.Errorf("invalid severity %q (expected critical, high, medium, low or unknown)", sev)
+		}
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	infof("Scanning %s (this can take a few minutes)...", args[0])
+	result, err := client.Scan(args[0])
+	if err != nil {
+		return err
+	}
+
+	if scanSeverity != "" {
+		result.Findings = slices.DeleteFunc(result.Findings, func(f scan.Finding) bool {
+			return scan.Rank(f.Severity) > scan.Rank(scanSeverity)
+		})
+	}
+
+	if scanJSON {
+		scanFormat = "json"
+	}
+	if scanFormat != "" {
+		if err := printFormatted(scanFormat, result, nil); err != nil {
+			return err
+		}
+	} else if err := printScan(result); err != nil {
+		return err
+	}
+
+	if scanFailOn != "" {
+		if n := result.AtLeast(scanFailOn); n > 0 {
+			return fmt.Errorf("%s has %d %s severity vulnerabilities or worse", result.Image, n, strings.ToLower(scanFailOn))
+		}
+	}
+	return nil
+}
+
+// printScan lists a scan's findings in a table under a summary
+func printScan(result *scan.Result) error {
+	infof("Scanned %s with %s: %s", result.Image, result.Scanner, result.Summary())
+	if len(result.Findings) == 0 {
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SEVERITY\tID\tPACKAGE\tINSTALLED\tFIXED\tTITLE")
+	for _, f := range result.Findings {
+		fixed := f.FixedIn
+		if fixed == "" {
+			fixed = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			strings.ToLower(f.Severity), f.ID, f.Package, f.Installed, fixed, scanTitle(f.Title))
+	}
+	return w.Flush()
+}
+
+// scanTitle shortens a finding's title to the first line, to fit a table
+func scanTitle(title string) string {
+	title, _, _ = strings.Cut(strings.TrimSpace(title), "\n")
+	if len(title) > 60 {
+		return title[:57] + "..."
+	}
+	return title
+}
@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/sandwich-labs/puck/internal/selftest"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var selftestCmd = &cobra.Command{
+	Use:   "selftest",
+	Short: "Check that pucks work on this machine",
+	Long: `Put a throwaway puck through its lifecycle against this machine's
+Podman: create, exec, stop and start, an image snapshot and restore, a
+checkpoint snapshot and restore, and destroy. Checkpoints are skipped
+where they can't be taken: without CRIU, with rootless Podman, or on a
+Podman machine.
+
+selftest doesn't go through the daemon. It runs with a data directory and
+database of its own in a temporary directory, so the daemon's pucks, ports
+and settings are left alone, and it works whether or not the daemon is
+running. It exits non-zero if any step fails.
+
+Examples:
+  puck selftest
+  puck selftest --image docker.io/library/debian:stable-slim
+  puck selftest --keep`,
+	Args: cobra.NoArgs,
+	RunE: runSelftest,
+}
+
+var (
+	selftestImage string
+	selftestKeep  bool
+	selftestJSON  bool
+)
+
+func init() {
+	selftestCmd.Flags().StringVar(&selftestImage, "image", selftest.DefaultImage, "image to create the puck from; it needs sh and sleep")
+	selftestCmd.Flags().BoolVar(&selftestKeep, "keep", false, "leave the puck and its temporary data directory behind")
+	selftestCmd.Flags().BoolVar(&selftestJSON, "json", false, "print the report as JSON")
+}
+
+func runSelftest(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	color := term.IsTerminal(int(os.Stdout.Fd())) && !plainOutput()
+	progress := func(s selftest.Step) { printSelftestStep(s, color) }
+	if selftestJSON {
+		progress = nil
+	}
+	report, err := selftest.Run(cmd.Context(), selftest.Options{
+		PodmanSocket: cfg.PodmanSocket,
+		Image:        selftestImage,
+		Keep:         selftestKeep,
+	}, progress)
+	if err != nil {
+		return err
+	}
+
+	if selftestJSON {
+		if err := printFormatted("json", report, nil); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("\n%s\n", report.Summary())
+		if report.Dir != "" {
+			infof("Kept the puck's data in %s", report.Dir)
+		}
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("selftest failed")
+	}
+	return nil
+}
+
+// printSelftestStep prints a step as it finishes
+func printSelftestStep(s selftest.Step, color bool) {
+	mark, markColor, detail := "✓", colorGreen, s.Detail
+	switch {
+	case s.Skipped != "":
+		mark, markColor, detail = "-", colorGray, "skipped: "+s.Skipped
+	case s.Error != "":
+		mark, markColor, detail = "✗", colorRed, s.Error
+	}
+	if color {
+		mark = paint(markColor, mark)
+	}
+	line := fmt.Sprintf("%s %-20s", mark, s.Name)
+	if s.Skipped == "" {
+		line += fmt.Sprintf(" %6s", s.Duration.Round(100*time.Millisecond))
+	}
+	if detail != "" {
+		line += "  " + detail
+	}
+	fmt.Println(line)
+}
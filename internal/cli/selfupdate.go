@@ -0,0 +1,191 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/sandwich-labs/puck/internal/buildinfo"
+	"github.com/sandwich-labs/puck/internal/systemd"
+	"github.com/sandwich-labs/puck/internal/update"
+	"github.com/spf13/cobra"
+)
+
+var selfUpdateCmd = &cobra.Command{
+	Use:   "self-update",
+	Short: "Update puck and puckd to the latest release",
+	Long: `Update puck and puckd to the latest release on GitHub.
+
+The release archive for this platform is checked against the release's
+checksums, and release builds also check the checksums' signature. puck is
+replaced where it runs from, and puckd where it is installed: the systemd
+service's binary, and any puckd next to puck or on PATH. A running
+systemd service is restarted onto the new puckd; a daemon started with
+puck daemon start has to be restarted by hand.
+
+Set GITHUB_TOKEN to get past GitHub's rate limit for anonymous requests.
+
+Examples:
+  puck self-update --check
+  puck self-update
+  puck self-update --version 0.3.1`,
+	Args: cobra.NoArgs,
+	RunE: runSelfUpdate,
+}
+
+var (
+	selfUpdateCheck   bool
+	selfUpdateVersion string
+)
+
+func init() {
+	selfUpdateCmd.Flags().BoolVar(&selfUpdateCheck, "check", false, "Only report whether a newer release is out, and its changelog")
+	selfUpdateCmd.Flags().StringVar(&selfUpdateVersion, "version", "", "Install this release rather than the latest, e.g. to go back")
+}
+
+func runSelfUpdate(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	var rel *update.Release
+	var err error
+	if selfUpdateVersion != "" {
+		rel, err = update.Tagged(ctx, selfUpdateVersion)
+	} else {
+		rel, err = update.Latest(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	if selfUpdateVersion == "" && !update.Newer(rel.Version(), buildinfo.Version) {
+		fmt.Printf("puck %s is up to date\n", buildinfo.Version)
+		return nil
+	}
+	if rel.Version() == buildinfo.Version {
+		fmt.Printf("puck %s is already installed\n", buildinfo.Version)
+		return nil
+	}
+
+	fmt.Printf("puck %s is available (this is %s)\n", rel.Version(), buildinfo.Version)
+	if notes := strings.TrimSpace(rel.Notes); notes != "" {
+		fmt.Printf("\n%s\n\n", notes)
+	}
+	if rel.URL != "" {
+		fmt.Printf("Release notes: %s\n", rel.URL)
+	}
+	if selfUpdateCheck {
+		infof("Update with: puck self-update")
+		return nil
+	}
+
+	targets, err := updateTargets()
+	if err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "puck-update-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	infof("Downloading puck %s...", rel.Version())
+	dl, err := update.Fetch(ctx, rel, dir)
+	if err != nil {
+		return err
+	}
+	if !dl.Signed {
+		fmt.Fprintln(os.Stderr, "Warning: this build has no release signing key, so only checksums were checked")
+	}
+
+	// Replace everything before restarting, so the service comes back
+	// on the new puckd
+	replacedDaemon := false
+	for _, t := range targets {
+		if dl.Paths[t.name] == "" {
+			// An optional binary this platform's archive doesn't ship
+			continue
+		}
+		if err := update.Replace(dl.Paths[t.name], t.path); err != nil {
+			return err
+		}
+		infof("Updated %s", t.path)
+		replacedDaemon = replacedDaemon || t.name == "puckd"
+	}
+
+	if replacedDaemon {
+		switch {
+		case systemd.IsInstalled() && systemd.IsRunning():
+			infof("Restarting the puckd service...")
+			if err := systemd.Restart(); err != nil {
+				return fmt.Errorf("restarting the puckd service: %w\nRestart it with: systemctl --user restart puckd", err)
+			}
+		case systemd.IsInstalled():
+			infof("The puckd service will run the new version when it next starts")
+		default:
+			infof("If puckd is running, restart it to run the new version")
+		}
+	}
+
+	infof("puck is now %s", rel.Version())
+	return nil
+}
+
+// updateTarget is an installed binary self-update replaces
+type updateTarget struct {
+	name string // puck, puckd or puck-agent
+	path string
+}
+
+// updateTargets finds the installed binaries: this puck, the service's
+// puckd and any puckd next to puck or on PATH, and the puck-agent next to
+// each puckd or on PATH that puckd puts in pucks
+func updateTargets() ([]updateTarget, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("finding puck: %w", err)
+	}
+	if self, err = filepath.EvalSymlinks(self); err != nil {
+		return nil, fmt.Errorf("finding puck: %w", err)
+	}
+	targets := []updateTarget{{"puck", self}}
+
+	var daemons []string
+	if systemd.IsInstalled() {
+		if p, err := systemd.BinaryInstallPath(); err == nil {
+			daemons = append(daemons, p)
+		}
+	}
+	daemons = append(daemons, filepath.Join(filepath.Dir(self), "puckd"))
+	if p, err := exec.LookPath("puckd"); err == nil {
+		daemons = append(daemons, p)
+	}
+
+	seen := make(map[string]bool)
+	var agents []string
+	for _, p := range daemons {
+		p, err := filepath.EvalSymlinks(p)
+		if err != nil || seen[p] {
+			continue
+		}
+		seen[p] = true
+		targets = append(targets, updateTarget{"puckd", p})
+		agents = append(agents, filepath.Join(filepath.Dir(p), "puck-agent"))
+	}
+
+	// The agent is only replaced where it is installed already
+	if p, err := exec.LookPath("puck-agent"); err == nil {
+		agents = append(agents, p)
+	}
+	for _, p := range agents {
+		p, err := filepath.EvalSymlinks(p)
+		if err != nil || seen[p] {
+			continue
+		}
+		seen[p] = true
+		targets = append(targets, updateTarget{"puck-agent", p})
+	}
+	return targets, nil
+}
@@ -0,0 +1,173 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/spf13/cobra"
+)
+
+var setCmd = &cobra.Command{
+	Use:   "set <name>",
+	Short: "Change a puck's CPU and memory limits, host port, notes or TTL",
+	Long: `Change the CPU and memory limits, the host port, the notes or the TTL
+of a puck.
+
+Only the flags you pass are changed; use 0 to remove a limit. Memory
+accepts units such as 512m or 2g. CPUs may be fractional, e.g. 1.5.
+
+The limits are applied to the running container in place where podman
+supports it (cgroup v2). Otherwise they are saved and the puck's container
+is recreated with them the next time it starts.
+
+--host-port pins the host port the router reaches the puck on. A pinned
+puck keeps its port rather than being moved off it when the port is taken,
+so it won't start until the port is free. The puck must be stopped to move
+it to another port; 0 unpins it and leaves it where it is.
+
+--notes says what the puck is for, so a fleet of similar pucks stays
+understandable weeks later. puck inspect and puck list --wide show them;
+--notes "" clears them.
+
+--ttl has the daemon destroy the puck that long from now, replacing any
+TTL it was created with; 0 keeps it for good. With --ttl-snapshot it
+archives the puck first (see puck create --ttl-snapshot). puck list counts
+down to it.
+
+Examples:
+  puck set web --memory 2g
+  puck set web --cpus 1.5
+  puck set web --memory 0
+  puck set web --host-port 9123
+  puck set db-staging --notes "staging clone of the prod db"
+  puck set scratch --ttl 2h
+  puck set scratch --ttl 0`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSet,
+}
+
+var (
+	setMemory  string
+	setCPUs    float64
+	setPort    int
+	setNotes   string
+	setTTL     time.Duration
+	setTTLSnap bool
+)
+
+func init() {
+	setCmd.Flags().StringVar(&setMemory, "memory", "", "memory limit, e.g. 512m or 2g (0 removes the limit)")
+	setCmd.Flags().Float64Var(&setCPUs, "cpus", 0, "number of CPUs (0 removes the limit)")
+	setCmd.Flags().IntVar(&setPort, "host-port", 0, "pin the puck to a host port (0 unpins it)")
+	setCmd.Flags().StringVar(&setNotes, "notes", "", "what the puck is for (\"\" clears them)")
+	setCmd.Flags().DurationVar(&setTTL, "ttl", 0, "destroy the puck this long from now, e.g. 4h (0 keeps it)")
+	setCmd.Flags().BoolVar(&setTTLSnap, "ttl-snapshot", false, "archive the puck before its TTL destroys it (with --ttl)")
+}
+
+func runSet(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	if !flags.Changed("memory") && !flags.Changed("cpus") && !flags.Changed("host-port") && !flags.Changed("notes") && !flags.Changed("ttl") {
+		return fmt.Errorf("nothing to change; pass --memory, --cpus, --host-port, --notes or --ttl")
+	}
+	if flags.Changed("ttl-snapshot") && !flags.Changed("ttl") {
+		return fmt.Errorf("--ttl-snapshot needs --ttl")
+	}
+
+	name, err := selectContext(args[0])
+	if err != nil {
+		return err
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	if flags.Changed("notes") {
+		p, err := client.SetNotes(name, setNotes)
+		if err != nil {
+			return err
+		}
+		if p.Notes == "" {
+			infof("Cleared the notes of puck '%s'", p.Name)
+		} else {
+			infof("Set the notes of puck '%s'", p.Name)
+		}
+	}
+
+	if flags.Changed("ttl") {
+		var snapshot *bool
+		if flags.Changed("ttl-snapshot") {
+			snapshot = &setTTLSnap
+		}
+		p, err := client.SetTTL(name, setTTL, snapshot)
+		if err != nil {
+			return err
+		}
+		if p.ExpiresAt.IsZero() {
+			infof("Puck '%s' no longer expires", p.Name)
+		} else {
+			infof("Puck '%s' expires in %s", p.Name, formatTTL(p.ExpiresAt))
+		}
+	}
+
+	if flags.Changed("host-port") {
+		p, err := client.SetHostPort(name, setPort)
+		if err != nil {
+			return err
+		}
+		if p.HostPortPinned {
+			infof("Pinned puck '%s' to host port %d", p.Name, p.HostPort)
+		} else {
+			infof("Unpinned puck '%s' from host port %d", p.Name, p.HostPort)
+		}
+	}
+	if !flags.Changed("memory") && !flags.Changed("cpus") {
+		return nil
+	}
+
+	p, err := client.Get(name)
+	if err != nil {
+		return err
+	}
+
+	res := p.Resources
+	if flags.Changed("memory") {
+		if setMemory == "0" {
+			res.Memory = 0
+		} else if res.Memory, err = units.RAMInBytes(setMemory); err != nil {
+			return fmt.Errorf("invalid memory limit %q: %w", setMemory, err)
+		}
+	}
+	if flags.Changed("cpus") {
+		res.CPUs = setCPUs
+	}
+
+	p, err = client.SetResources(name, res)
+	if err != nil {
+		return err
+	}
+
+	infof("Set limits for puck '%s': memory %s, cpus %s", p.Name, formatMemory(p.Resources.Memory), formatCPUs(p.Resources.CPUs))
+	if p.Resources.Pending {
+		infof("The container could not be updated in place; the limits apply when the puck next starts.")
+	}
+	return nil
+}
+
+// formatMemory renders a memory limit, where zero means unlimited
+func formatMemory(bytes int64) string {
+	if bytes == 0 {
+		return "unlimited"
+	}
+	return units.BytesSize(float64(bytes))
+}
+
+// formatCPUs renders a CPU limit, where zero means unlimited
+func formatCPUs(cpus float64) string {
+	if cpus == 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%g", cpus)
+}
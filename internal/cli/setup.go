@@ -0,0 +1,358 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/sandwich-labs/puck/internal/daemon"
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/sandwich-labs/puck/internal/systemd"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+var setupCmd = &cobra.Command{
+	Use:   "setup",
+	Short: "Set up puck on this machine",
+	Long: `Walk through setting puck up on this machine:
+
+- Check that Podman is installed, and on macOS and Windows that a Podman
+  machine is running
+- Enable the Podman user socket
+- Pick the default image for new pucks and the router's port, and write
+  them to the config file
+- Install the daemon as a systemd user service and start it
+- Create a demo puck to try
+
+Each step asks first. --yes takes the defaults without asking, for
+provisioning scripts.
+
+puck offers this the first time a command can't reach a daemon, if there
+is no config file yet.`,
+	Args: cobra.NoArgs,
+	RunE: runSetup,
+}
+
+var (
+	setupYes        bool
+	setupImage      string
+	setupRouterPort int
+	setupNoDemo     bool
+)
+
+// setupDemoName is the puck setup offers to create
+const setupDemoName = "demo"
+
+// setupWait bounds how long setup waits for a daemon it started to answer
+const setupWait = 30 * time.Second
+
+func init() {
+	setupCmd.Flags().BoolVarP(&setupYes, "yes", "y", false, "take the defaults without asking")
+	setupCmd.Flags().StringVar(&setupImage, "image", "", "default image for new pucks (default from the config, else fedora:latest)")
+	setupCmd.Flags().IntVar(&setupRouterPort, "router-port", 0, "port for the router (default: 8080, or the next free one)")
+	setupCmd.Flags().BoolVar(&setupNoDemo, "no-demo", false, "don't create a demo puck")
+}
+
+func runSetup(cmd *cobra.Command, args []string) error {
+	if !setupYes && !term.IsTerminal(int(os.Stdin.Fd())) {
+		return fmt.Errorf("puck setup asks its questions on a terminal; pass --yes to take the defaults")
+	}
+	return setup(newPrompter(setupYes))
+}
+
+// setup runs the setup steps, asking p before each
+func setup(p *prompter) error {
+	version, err := podmanVersion()
+	if err != nil {
+		return err
+	}
+	infof("Found Podman %s", version)
+
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		if err := setupMachine(p); err != nil {
+			return err
+		}
+	}
+	userSystemd := runtime.GOOS == "linux" && hasSystemctl()
+	if userSystemd && !systemd.PodmanSocketEnabled() {
+		if p.confirm("Enable the Podman user socket (podman.socket) for the daemon?", true) {
+			if err := systemd.EnablePodmanSocket(); err != nil {
+				return fmt.Errorf("enabling podman.socket: %w", err)
+			}
+			infof("Enabled podman.socket")
+		}
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	file := configFile()
+	image := setupImage
+	if image == "" {
+		image = p.ask("Default image for new pucks", cfg.DefaultImage)
+	}
+	port := setupRouterPort
+	if port == 0 {
+		answer := p.ask("Port for the router", strconv.Itoa(freeRouterPort(cfg.RouterPort)))
+		if port, err = strconv.Atoi(answer); err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("invalid port %q", answer)
+		}
+	}
+	if err := config.WriteDefaultImage(file, image); err != nil {
+		return fmt.Errorf("writing %s: %w", file, err)
+	}
+	if err := config.WriteRouterPort(file, port); err != nil {
+		return fmt.Errorf("writing %s: %w", file, err)
+	}
+	viper.Set("default_image", image)
+	viper.Set("router_port", port)
+	infof("Wrote the defaults to %s", file)
+
+	client, err := setupDaemon(p, userSystemd, cfg)
+	if err != nil || client == nil {
+		return err
+	}
+
+	if !setupNoDemo && p.confirm(fmt.Sprintf("Create a puck named '%s' from %s to try?", setupDemoName, image), true) {
+		if _, err := client.Get(setupDemoName); err == nil {
+			infof("Puck '%s' already exists", setupDemoName)
+		} else {
+			endProgress := showPullProgress(client)
+			created, err := client.Create(puck.CreateOptions{Name: setupDemoName, Image: image})
+			endProgress()
+			if err != nil {
+				return fmt.Errorf("creating the demo puck: %w", err)
+			}
+			printCreated(client, created)
+			infof("\nOpen a shell in it with: puck console %s", setupDemoName)
+		}
+	}
+	infof("\nPuck is set up. See what's running with: puck list")
+	return nil
+}
+
+// setupDaemon makes sure a daemon is running, installing it as a systemd
+// user service where there is one. It returns nil when the user is left
+// to start the daemon themselves.
+func setupDaemon(p *prompter, userSystemd bool, cfg *config.Config) (*daemon.Client, error) {
+	client, err := newClient()
+	if err != nil {
+		return nil, err
+	}
+	if client.Ping() == nil {
+		infof("The daemon is already running; restart it to use a new router port")
+		return client, nil
+	}
+
+	switch {
+	case !userSystemd:
+		infof("\nStart the daemon with: puck daemon start")
+		return nil, nil
+	case systemd.IsInstalled():
+		if !p.confirm("Start the puckd service?", true) {
+			infof("\nStart it with: systemctl --user start puckd")
+			return nil, nil
+		}
+	default:
+		if !p.confirm("Install the daemon as a systemd user service and start it?", true) {
+			infof("\nStart the daemon with: puck daemon start, or install it with: puck daemon install --now")
+			return nil, nil
+		}
+		puckdPath, err := findPuckd()
+		if err != nil {
+			return nil, err
+		}
+		if err := systemd.Install(puckdPath, systemd.InstallOptions{PodmanSocket: systemd.PodmanSocketWants, DataDir: cfg.DataDir}); err != nil {
+			return nil, fmt.Errorf("installing the service: %w", err)
+		}
+		infof("Installed the puckd service")
+	}
+	if err := systemd.Start(); err != nil {
+		return nil, fmt.Errorf("starting the service: %w", err)
+	}
+
+	deadline := time.Now().Add(setupWait)
+	for client.Ping() != nil {
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("the daemon didn't answer within %s; see: puck daemon logs", setupWait)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	infof("The daemon is running")
+	return client, nil
+}
+
+// podmanVersion returns the installed Podman's version
+func podmanVersion() (string, error) {
+	path, err := exec.LookPath("podman")
+	if err != nil {
+		return "", fmt.Errorf("podman isn't installed; puck runs pucks with it. Install it (https://podman.io/docs/installation), then run: puck setup")
+	}
+	out, err := exec.Command(path, "version", "--format", "{{.Client.Version}}").Output()
+	if err != nil {
+		return "", fmt.Errorf("running podman version: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// setupMachine makes sure a Podman machine is running, creating one if
+// there is none
+func setupMachine(p *prompter) error {
+	out, err := exec.Command("podman", "machine", "list", "--format", "{{.Name}} {{.Running}}").Output()
+	if err != nil {
+		return fmt.Errorf("listing Podman machines: %w", err)
+	}
+	machines := strings.Fields(string(out))
+	for i := 1; i < len(machines); i += 2 {
+		if machines[i] == "true" {
+			return nil
+		}
+	}
+
+	var steps [][]string
+	if len(machines) == 0 {
+		if !p.confirm("Podman needs a machine to run containers on this OS. Create and start one?", true) {
+			return fmt.Errorf("puck needs a running Podman machine; create one with: podman machine init --now")
+		}
+		steps = [][]string{{"machine", "init"}, {"machine", "start"}}
+	} else {
+		if !p.confirm("Start the Podman machine?", true) {
+			return fmt.Errorf("puck needs a running Podman machine; start it with: podman machine start")
+		}
+		steps = [][]string{{"machine", "start"}}
+	}
+	for _, args := range steps {
+		c := exec.Command("podman", args...)
+		c.Stdout, c.Stderr = os.Stderr, os.Stderr
+		if err := c.Run(); err != nil {
+			return fmt.Errorf("podman %s: %w", strings.Join(args, " "), err)
+		}
+	}
+	return nil
+}
+
+// hasSystemctl reports whether there is a systemd user manager to install
+// the daemon under
+func hasSystemctl() bool {
+	if _, err := exec.LookPath("systemctl"); err != nil {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(os.Getenv("XDG_RUNTIME_DIR"), "systemd"))
+	return os.Getenv("XDG_RUNTIME_DIR") != "" && err == nil
+}
+
+// freeRouterPort returns port, or the first free one of the ten after it
+func freeRouterPort(port int) int {
+	for p := port; p < port+10; p++ {
+		ln, err := net.Listen("tcp", fmt.Sprintf(":%d", p))
+		if err == nil {
+			ln.Close()
+			return p
+		}
+	}
+	return port
+}
+
+// prompter asks the user questions on the terminal, or with yes takes
+// each default without asking
+type prompter struct {
+	in  *bufio.Reader
+	yes bool
+}
+
+func newPrompter(yes bool) *prompter {
+	return &prompter{in: bufio.NewReader(os.Stdin), yes: yes}
+}
+
+// ask asks a question, returning the answer or def when there is none
+func (p *prompter) ask(question, def string) string {
+	if p.yes {
+		return def
+	}
+	fmt.Fprintf(os.Stderr, "%s [%s]: ", question, def)
+	line, _ := p.in.ReadString('\n')
+	if answer := strings.TrimSpace(line); answer != "" {
+		return answer
+	}
+	return def
+}
+
+// confirm asks a yes or no question
+func (p *prompter) confirm(question string, def bool) bool {
+	if p.yes {
+		return def
+	}
+	hint := "Y/n"
+	if !def {
+		hint = "y/N"
+	}
+	for {
+		fmt.Fprintf(os.Stderr, "%s [%s] ", question, hint)
+		line, err := p.in.ReadString('\n')
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "":
+			if err != nil {
+				return false
+			}
+			return def
+		case "y", "yes":
+			return true
+		case "n", "no":
+			return false
+		}
+	}
+}
+
+// setupOfferedPath is where puck notes it has offered setup, so it only
+// asks once
+func setupOfferedPath() string {
+	return filepath.Join(filepath.Dir(config.ConfigPath()), ".setup-offered")
+}
+
+// offerSetup offers to run puck setup after a command failed to reach a
+// daemon on what looks like a first run: on a terminal, with no config
+// file or contexts yet, and not offered before
+func offerSetup(err error) {
+	var connectErr *daemon.ConnectError
+	if !errors.As(err, &connectErr) || quiet || cfgFile != "" || contextName != "" || viper.ConfigFileUsed() != "" {
+		return
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stderr.Fd())) {
+		return
+	}
+	for _, path := range []string{setupOfferedPath(), config.ContextsPath()} {
+		if _, err := os.Stat(path); err == nil {
+			return
+		}
+	}
+	marker := setupOfferedPath()
+	if os.MkdirAll(filepath.Dir(marker), 0755) != nil || os.WriteFile(marker, nil, 0644) != nil {
+		return
+	}
+
+	p := newPrompter(false)
+	fmt.Fprintln(os.Stderr)
+	if !p.confirm("puck doesn't seem to be set up on this machine yet. Set it up now?", true) {
+		infof("Set it up any time with: puck setup")
+		return
+	}
+	if err := setup(p); err != nil {
+		fmt.Fprintf(os.Stderr, "Setup failed: %v\nTry again with: puck setup\n", err)
+		return
+	}
+	if client, err := newClient(); err == nil && client.Ping() == nil {
+		infof("Now run your command again")
+	}
+}
@@ -0,0 +1,156 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+var shareCmd = &cobra.Command{
+	Use:   "share <name>",
+	Short: "Create an expiring public link to a puck",
+	Long: `Create a signed link that serves a puck publicly until it expires.
+
+Links are served by the router under /_share/<token>/ on the public base
+URL: share_url if set (e.g. a Tailscale Funnel URL pointing at share_port,
+where the router serves share links and nothing else), otherwise that of
+the tunnel share_tunnel opens. A link only works while its puck is running.
+
+share_tunnel gives links a public URL without Tailscale: while there are
+links, the daemon keeps a tunnel open in front of the router's share
+listener with funnel (Tailscale Funnel as a node of its own), cloudflared
+(Cloudflare quick tunnels, no account needed), localtunnel (the lt
+client), or any client run by share_tunnel_command that prints its public
+URL. Quick
+tunnels get a new URL each time they open, so links are listed with the
+current one.
+
+Examples:
+  puck share web                 # expires after share_ttl (default 1h)
+  puck share web --expires 24h
+  puck share list
+  puck share revoke 1a2b3c4d`,
+	Args: cobra.ExactArgs(1),
+	RunE: runShare,
+}
+
+var shareListCmd = &cobra.Command{
+	Use:     "list [puck]",
+	Aliases: []string{"ls"},
+	Short:   "List active share links",
+	Args:    cobra.MaximumNArgs(1),
+	RunE:    runShareList,
+}
+
+var shareRevokeCmd = &cobra.Command{
+	Use:   "revoke <id>",
+	Short: "Revoke a share link",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runShareRevoke,
+}
+
+var (
+	shareExpires    time.Duration
+	shareListFormat string
+)
+
+func init() {
+	shareCmd.Flags().DurationVar(&shareExpires, "expires", 0, "how long the link stays valid, e.g. 30m or 24h (default share_ttl)")
+
+	addFormatFlag(shareListCmd, &shareListFormat)
+
+	shareCmd.AddCommand(shareListCmd)
+	shareCmd.AddCommand(shareRevokeCmd)
+}
+
+func runShare(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	if shareExpires < 0 {
+		return fmt.Errorf("--expires must be positive")
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	share, err := client.ShareCreate(name, shareExpires)
+	if err != nil {
+		return err
+	}
+
+	if quiet {
+		fmt.Println(share.URL)
+		return nil
+	}
+	fmt.Printf("Shared puck '%s'\n", share.PuckName)
+	fmt.Printf("  URL:     %s\n", share.URL)
+	fmt.Printf("  Expires: %s (%s)\n", share.ExpiresAt.Local().Format("2006-01-02 15:04"), humanize.Time(share.ExpiresAt))
+	fmt.Printf("  Revoke:  puck share revoke %s\n", share.ID)
+	return nil
+}
+
+func runShareList(cmd *cobra.Command, args []string) error {
+	var name string
+	if len(args) > 0 {
+		name = args[0]
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	shares, err := client.ShareList(name)
+	if err != nil {
+		return err
+	}
+
+	if shareListFormat != "" {
+		return printFormatted(shareListFormat, shares, nil)
+	}
+	if len(shares) == 0 {
+		infof("No share links. Create one with: puck share <name>")
+		return nil
+	}
+	if quiet {
+		for _, s := range shares {
+			fmt.Println(s.ID)
+		}
+		return nil
+	}
+
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tPUCK\tEXPIRES\tCREATED BY\tURL")
+	for _, s := range shares {
+		expires := humanize.Time(s.ExpiresAt)
+		if s.Expired(now) {
+			expires = "expired"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", s.ID, s.PuckName, expires, s.CreatedBy, s.URL)
+	}
+
+	return w.Flush()
+}
+
+func runShareRevoke(cmd *cobra.Command, args []string) error {
+	id := args[0]
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	if err := client.ShareRevoke(id); err != nil {
+		return err
+	}
+
+	infof("Revoked share link %s", id)
+	return nil
+}
@@ -3,11 +3,15 @@ package cli
 import (
 	"fmt"
 	"os"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/dustin/go-humanize"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 	"github.com/sandwich-labs/puck/internal/daemon"
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/sandwich-labs/puck/internal/store"
 )
 
 var snapshotCmd = &cobra.Command{
@@ -17,14 +21,42 @@ var snapshotCmd = &cobra.Command{
 }
 
 var snapshotCreateCmd = &cobra.Command{
-	Use:   "create <puck> <name>",
+	Use:   "create <puck | @group> <name> | --all --name <name>",
 	Short: "Create a snapshot of a puck",
-	Long: `Create a CRIU checkpoint snapshot of a running puck.
-
-This captures the complete state of the container including memory, processes,
-and network connections. The snapshot can later be restored to bring the
-puck back to this exact state.`,
-	Args: cobra.ExactArgs(2),
+	Long: `Create a snapshot of a puck.
+
+By default this is a CRIU checkpoint of a running puck, capturing the
+complete state of the container including memory, processes, and network
+connections. The snapshot can later be restored to bring the puck back to
+this exact state.
+
+With --mode image the container is instead committed to an image and its
+volumes are archived. This works on hosts without CRIU and on stopped
+pucks, and leaves the puck running, but restoring it starts fresh
+processes rather than resuming them.
+
+With --all every running puck you own is snapshotted under the same name,
+a few at a time, and the result for each is reported. With @<group> in
+place of the puck, each puck of the group (see 'puck group') is
+snapshotted under the name in turn.
+
+With --stack the puck and every puck it requires (see 'puck create
+--requires') are snapshotted together under the same name, all at once so
+their states line up. The snapshots are recorded as a stack snapshot only
+if every member succeeds, and can then be restored as a unit with
+'puck snapshot restore --stack'.
+
+Checkpoints include established TCP connections unless
+--tcp-established=false is given, in which case CRIU refuses to checkpoint
+a puck with connections open; restoring connections fails once their
+peers have gone. --file-locks also checkpoints file locks. The options are
+recorded with the snapshot and used again to restore it.
+
+Progress is shown as the snapshot is written. Interrupting the command
+cancels the snapshot and removes what was written; a checkpoint that was
+stopping the puck is first allowed to finish, and the puck is restored
+from it so it keeps running as before.`,
+	Args: cobra.RangeArgs(0, 2),
 	RunE: runSnapshotCreate,
 }
 
@@ -34,7 +66,20 @@ var snapshotRestoreCmd = &cobra.Command{
 	Long: `Restore a puck to a previously saved snapshot state.
 
 This replaces the current container with one restored from the checkpoint,
-including all memory state, running processes, and network connections.`,
+including all memory state, running processes, and network connections.
+
+A checkpoint needs CRIU and a kernel at least as new as the ones it was
+taken with, and the same major version of Podman. If this host may not
+meet them the restore is refused before the current container is touched;
+use --force to try anyway.
+
+A checkpoint also needs the exact image its container ran, which is
+recorded by digest. If the puck's image has been removed, or pulled again
+and pruned, --repull fetches that digest from the registry first.
+
+With --stack the puck's stack snapshot of that name is restored instead:
+every member is checked first, the running ones are stopped, and each is
+restored after the pucks it requires.`,
 	Args: cobra.ExactArgs(2),
 	RunE: runSnapshotRestore,
 }
@@ -43,8 +88,49 @@ var snapshotListCmd = &cobra.Command{
 	Use:     "list <puck>",
 	Aliases: []string{"ls"},
 	Short:   "List snapshots for a puck",
-	Args:    cobra.ExactArgs(1),
-	RunE:    runSnapshotList,
+	Long: `List snapshots for a puck.
+
+With --stacks the stack snapshots taken from the puck are listed instead,
+with the pucks each one covers.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSnapshotList,
+}
+
+var snapshotInspectCmd = &cobra.Command{
+	Use:   "inspect <puck> <snapshot>",
+	Short: "Show what a snapshot holds",
+	Long: `Show a snapshot's details and summarize its archive: checksum,
+compression, the CRIU, kernel and Podman versions a checkpoint was taken
+with, and the volume data it includes, so you know what a restore will
+bring back.
+
+Checkpoints carry the container's memory, processes and filesystem changes
+but not the puck's volume directories, which a restore leaves as they are,
+unless the puck's snapshot config includes them. Image snapshots carry the
+volume directories and restore them in full.
+
+Use --files to list every file in the archive.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runSnapshotInspect,
+}
+
+var snapshotDiffCmd = &cobra.Command{
+	Use:   "diff <puck> <from> <to>",
+	Short: "Show what changed between two snapshots",
+	Long: `Compare two snapshots of a puck, by name or tag: the metadata that
+differs, such as the image, its digest and the host versions a checkpoint
+was taken with, how much the snapshot grew, and the files added, removed
+or modified in the puck's volumes.
+
+Files are compared when both snapshots hold the volume directories: image
+snapshots always do, checkpoints when the puck's snapshot config includes
+them (see 'puck snapshot config --include-volumes').
+
+Examples:
+  puck snapshot diff web before-update after-update
+  puck snapshot diff web known-good nightly`,
+	Args: cobra.ExactArgs(3),
+	RunE: runSnapshotDiff,
 }
 
 var snapshotDeleteCmd = &cobra.Command{
@@ -55,44 +141,372 @@ var snapshotDeleteCmd = &cobra.Command{
 	RunE:    runSnapshotDelete,
 }
 
+var snapshotTreeCmd = &cobra.Command{
+	Use:   "tree <puck>",
+	Short: "Show how a puck's snapshots branch from each other",
+	Long: `Show a puck's snapshots as a tree.
+
+Each snapshot is drawn under the snapshot the puck was at when it was taken,
+so restoring an older snapshot and snapshotting again starts a new branch.
+The snapshot the puck is currently based on is marked with '*'.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSnapshotTree,
+}
+
+var snapshotTagCmd = &cobra.Command{
+	Use:   "tag <puck> <snapshot> <tag>",
+	Short: "Tag a snapshot",
+	Long: `Attach a tag to a snapshot, e.g. "known-good".
+
+A tag belongs to at most one snapshot per puck and can be used in place of
+the snapshot name with 'puck snapshot restore'.`,
+	Args: cobra.ExactArgs(3),
+	RunE: runSnapshotTag,
+}
+
 var (
 	snapshotLeaveRunning bool
+	snapshotAll          bool
+	snapshotName         string
+	snapshotMode         string
+	snapshotTagDelete    bool
+	snapshotInspectFiles bool
+	snapshotRestoreForce bool
+	snapshotRepull       bool
+	snapshotStack        bool
+	snapshotListStacks   bool
+	snapshotTCP          bool
+	snapshotFileLocks    bool
+	snapshotFormat       string
+
+	snapshotConfigLeaveRunning   bool
+	snapshotConfigCompression    string
+	snapshotConfigIncludeVolumes bool
+	snapshotConfigSchedule       string
+	snapshotConfigRetain         int
 )
 
+var snapshotConfigCmd = &cobra.Command{
+	Use:   "config <puck>",
+	Short: "Show or change a puck's snapshot defaults and schedule",
+	Long: `Show or change the defaults for a puck's snapshots, kept with the puck
+in the daemon's database:
+
+  --leave-running    leave the puck running after a checkpoint; --leave-running
+                     on 'puck snapshot create' still overrides it
+  --compression      gzip, zstd or none for snapshot archives; by default
+                     checkpoints are kept as Podman exports them, which is
+                     uncompressed, and volume archives are gzipped
+  --include-volumes  archive the puck's volume directories with each
+                     checkpoint, and put them back when it is restored
+  --schedule         take a snapshot this often while the puck runs, e.g. 6h;
+                     empty turns it off
+  --retain           keep this many scheduled snapshots, dropping older
+                     ones; 0 keeps them all
+
+Scheduled snapshots are named scheduled-<time> and leave the puck
+running. Tagged ones are never dropped by retention. With snapshot_dedup
+on, checkpoints are kept as chunks and --compression applies only to
+volume archives.
+
+With no flags the current settings are shown.
+
+Examples:
+  puck snapshot config web
+  puck snapshot config web --compression zstd --include-volumes
+  puck snapshot config web --schedule 6h --retain 8`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSnapshotConfig,
+}
+
+var snapshotTierCmd = &cobra.Command{
+	Use:   "tier",
+	Short: "Manage snapshot cold storage",
+	Long: `Manage snapshot cold storage.
+
+With snapshot_tier_dir set, the daemon moves snapshot archives older than
+snapshot_tier_after days (30 by default) there every hour, e.g. to an
+external drive or a mounted remote. Their records stay, so they are
+listed, inspected and deleted as before, and a restore fetches the archive
+back first. Resume images of suspended pucks stay where they are.`,
+}
+
+var snapshotTierStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show which snapshots are in cold storage",
+	Long: `Show the tiering policy and where each snapshot's archive is kept.
+
+Snapshots marked due move to cold storage on the daemon's next sweep, or
+now with 'puck snapshot tier run'.`,
+	Args: cobra.NoArgs,
+	RunE: runSnapshotTierStatus,
+}
+
+var snapshotTierRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Move snapshots due for cold storage now",
+	Args:  cobra.NoArgs,
+	RunE:  runSnapshotTierRun,
+}
+
 func init() {
-	snapshotCreateCmd.Flags().BoolVar(&snapshotLeaveRunning, "leave-running", false, "keep puck running after snapshot")
+	snapshotCreateCmd.Flags().BoolVar(&snapshotLeaveRunning, "leave-running", false, "keep puck running after snapshot (default from the puck's snapshot config)")
+	snapshotCreateCmd.Flags().BoolVar(&snapshotAll, "all", false, "snapshot every running puck")
+	snapshotCreateCmd.Flags().StringVar(&snapshotName, "name", "", "snapshot name (instead of the second argument)")
+	snapshotCreateCmd.Flags().StringVar(&snapshotMode, "mode", "", "snapshot mode: checkpoint or image (default from snapshot_mode config)")
+	snapshotCreateCmd.Flags().BoolVar(&snapshotStack, "stack", false, "also snapshot the pucks this puck requires, as a unit")
+	snapshotCreateCmd.Flags().BoolVar(&snapshotTCP, "tcp-established", true, "checkpoint established TCP connections (default from checkpoint_tcp_established config)")
+	snapshotCreateCmd.Flags().BoolVar(&snapshotFileLocks, "file-locks", false, "checkpoint file locks (default from checkpoint_file_locks config)")
+
+	snapshotRestoreCmd.Flags().BoolVar(&snapshotRestoreForce, "force", false, "restore even if this host may not be compatible with the checkpoint")
+	snapshotRestoreCmd.Flags().BoolVar(&snapshotRepull, "repull", false, "pull the checkpoint's image by digest first if this host no longer has it")
+	snapshotRestoreCmd.Flags().BoolVar(&snapshotStack, "stack", false, "restore a stack snapshot of this puck and the pucks it requires")
+
+	snapshotListCmd.Flags().BoolVar(&snapshotListStacks, "stacks", false, "list stack snapshots")
+	addFormatFlag(snapshotListCmd, &snapshotFormat)
+
+	snapshotInspectCmd.Flags().BoolVar(&snapshotInspectFiles, "files", false, "list the files in the snapshot archive")
+	addFormatFlag(snapshotInspectCmd, &snapshotFormat)
+
+	snapshotTagCmd.Flags().BoolVarP(&snapshotTagDelete, "delete", "d", false, "remove the tag instead of adding it")
 
 	snapshotCmd.AddCommand(snapshotCreateCmd)
 	snapshotCmd.AddCommand(snapshotRestoreCmd)
 	snapshotCmd.AddCommand(snapshotListCmd)
+	snapshotCmd.AddCommand(snapshotInspectCmd)
+	snapshotCmd.AddCommand(snapshotDiffCmd)
 	snapshotCmd.AddCommand(snapshotDeleteCmd)
+	snapshotCmd.AddCommand(snapshotTreeCmd)
+	snapshotCmd.AddCommand(snapshotTagCmd)
+	snapshotCmd.AddCommand(snapshotTierCmd)
+	snapshotConfigCmd.Flags().BoolVar(&snapshotConfigLeaveRunning, "leave-running", false, "leave the puck running after a checkpoint")
+	snapshotConfigCmd.Flags().StringVar(&snapshotConfigCompression, "compression", "", "archive compression: gzip, zstd or none")
+	snapshotConfigCmd.Flags().BoolVar(&snapshotConfigIncludeVolumes, "include-volumes", false, "archive volume directories with each checkpoint")
+	snapshotConfigCmd.Flags().StringVar(&snapshotConfigSchedule, "schedule", "", "snapshot interval while running, e.g. 6h (empty for none)")
+	snapshotConfigCmd.Flags().IntVar(&snapshotConfigRetain, "retain", 0, "scheduled snapshots to keep (0 keeps all)")
+
+	snapshotCmd.AddCommand(snapshotConfigCmd)
+	snapshotTierCmd.AddCommand(snapshotTierStatusCmd)
+	snapshotTierCmd.AddCommand(snapshotTierRunCmd)
 }
 
 func runSnapshotCreate(cmd *cobra.Command, args []string) error {
+	if snapshotAll {
+		if len(args) > 0 {
+			return fmt.Errorf("--all takes no puck name")
+		}
+		if snapshotName == "" {
+			return fmt.Errorf("--all needs a snapshot name (--name)")
+		}
+		return runSnapshotCreateAll(snapshotLeaveRunningFlag(cmd), snapshotCRIUFlags(cmd))
+	}
+
+	if len(args) == 2 && snapshotName == "" {
+		snapshotName = args[1]
+	}
+	if len(args) == 0 || snapshotName == "" {
+		return fmt.Errorf("puck and snapshot name required (or use --all --name)")
+	}
 	puckName := args[0]
-	snapshotName := args[1]
 
-	client, err := daemon.NewClient()
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	if group, ok := groupName(puckName); ok {
+		if snapshotStack {
+			return fmt.Errorf("--stack can't be used with a group")
+		}
+		return runSnapshotCreateGroup(client, group, snapshotLeaveRunningFlag(cmd), snapshotCRIUFlags(cmd))
+	}
+	if snapshotStack {
+		return runSnapshotCreateStack(client, puckName, snapshotLeaveRunningFlag(cmd), snapshotCRIUFlags(cmd))
+	}
+
+	infof("Creating snapshot '%s' of puck '%s'...", snapshotName, puckName)
+
+	endProgress := showSnapshotProgress(client)
+	snapshot, err := client.SnapshotCreate(puckName, snapshotName, snapshotLeaveRunningFlag(cmd), store.SnapshotMode(snapshotMode), snapshotCRIUFlags(cmd))
+	endProgress()
+	if err != nil {
+		return err
+	}
+
+	if quiet {
+		fmt.Println(snapshot.Name)
+	} else {
+		fmt.Printf("Snapshot created: %s (%s)\n", snapshot.Name, humanize.Bytes(uint64(snapshot.SizeBytes)))
+	}
+	// Whether it stopped depends on the puck's snapshot policy too
+	if p, err := client.Get(puckName); err == nil && p.Status == store.StatusCheckpointed {
+		infof("Puck is now checkpointed (stopped). Use 'puck snapshot restore' to restore it.")
+	}
+
+	return nil
+}
+
+// showSnapshotProgress reports the snapshots the client's requests create
+// on stderr: a status line updated in place on a terminal, or a line per
+// phase otherwise. The returned func clears the status line.
+func showSnapshotProgress(client *daemon.Client) func() {
+	if quiet {
+		return func() {}
+	}
+	tty := term.IsTerminal(int(os.Stderr.Fd())) && !plainOutput()
+	phases := make(map[string]string)
+	shown := false
+	client.SetSnapshotProgress(func(p puck.SnapshotProgress) {
+		if tty {
+			status := p.Phase
+			if p.Bytes > 0 {
+				status += ", " + humanize.Bytes(uint64(p.Bytes))
+			}
+			fmt.Fprintf(os.Stderr, "\r\033[K%s: %s", p.Puck, status)
+			shown = true
+			return
+		}
+		if phases[p.Puck] != p.Phase {
+			phases[p.Puck] = p.Phase
+			fmt.Fprintf(os.Stderr, "%s: %s...\n", p.Puck, p.Phase)
+		}
+	})
+	return func() {
+		if shown {
+			fmt.Fprint(os.Stderr, "\r\033[K")
+		}
+	}
+}
+
+// snapshotLeaveRunningFlag returns --leave-running if it was given, or nil
+// to leave it to each puck's snapshot policy
+func snapshotLeaveRunningFlag(cmd *cobra.Command) *bool {
+	if !cmd.Flags().Changed("leave-running") {
+		return nil
+	}
+	return &snapshotLeaveRunning
+}
+
+// snapshotCRIUFlags returns the CRIU options given on the command line,
+// leaving the rest to the daemon's configured defaults
+func snapshotCRIUFlags(cmd *cobra.Command) puck.CRIUFlags {
+	var flags puck.CRIUFlags
+	if cmd.Flags().Changed("tcp-established") {
+		flags.TCPEstablished = &snapshotTCP
+	}
+	if cmd.Flags().Changed("file-locks") {
+		flags.FileLocks = &snapshotFileLocks
+	}
+	return flags
+}
+
+func runSnapshotCreateAll(leaveRunning *bool, criu puck.CRIUFlags) error {
+	client, err := newClient()
 	if err != nil {
 		return err
 	}
 
-	if err := client.Ping(); err != nil {
-		return fmt.Errorf("daemon not running: %w\nStart with: puck daemon start", err)
+	infof("Creating snapshot '%s' of all running pucks...", snapshotName)
+
+	endProgress := showSnapshotProgress(client)
+	results, err := client.SnapshotAll(snapshotName, leaveRunning, store.SnapshotMode(snapshotMode), criu)
+	endProgress()
+	if err != nil {
+		return err
 	}
 
-	fmt.Printf("Creating snapshot '%s' of puck '%s'...\n", snapshotName, puckName)
+	if len(results) == 0 {
+		infof("No running pucks")
+		return nil
+	}
+	return printSnapshotResults(results)
+}
 
-	snapshot, err := client.SnapshotCreate(puckName, snapshotName, snapshotLeaveRunning)
+// runSnapshotCreateGroup snapshots each puck of a group in turn
+func runSnapshotCreateGroup(client *daemon.Client, group string, leaveRunning *bool, criu puck.CRIUFlags) error {
+	g, err := client.Group(group)
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Snapshot created: %s (%s)\n", snapshot.Name, humanize.Bytes(uint64(snapshot.SizeBytes)))
-	if !snapshotLeaveRunning {
-		fmt.Println("Puck is now checkpointed (stopped). Use 'puck snapshot restore' to restore it.")
+	infof("Creating snapshot '%s' of the pucks in group @%s...", snapshotName, g.Name)
+
+	results := make([]puck.SnapshotResult, len(g.Members))
+	endProgress := showSnapshotProgress(client)
+	for i, name := range g.Members {
+		results[i].Puck = name
+		snapshot, err := client.SnapshotCreate(name, snapshotName, leaveRunning, store.SnapshotMode(snapshotMode), criu)
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		results[i].Snapshot = snapshot
 	}
+	endProgress()
 
+	return printSnapshotResults(results)
+}
+
+// printSnapshotResults reports how snapshotting each of several pucks
+// went, failing if any did
+func printSnapshotResults(results []puck.SnapshotResult) error {
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if !quiet {
+		fmt.Fprintln(w, "PUCK\tRESULT")
+	}
+	for _, r := range results {
+		switch {
+		case r.Error != "":
+			failed++
+			if !quiet {
+				fmt.Fprintf(w, "%s\tfailed: %s\n", r.Puck, r.Error)
+			}
+		case quiet:
+			// Just the pucks snapshotted, for piping on
+			fmt.Fprintln(w, r.Puck)
+		default:
+			fmt.Fprintf(w, "%s\t%s (%s)\n", r.Puck, r.Snapshot.Mode, humanize.Bytes(uint64(r.Snapshot.SizeBytes)))
+		}
+	}
+	w.Flush()
+
+	return batchStatus("snapshot", failed, len(results))
+}
+
+func runSnapshotCreateStack(client *daemon.Client, puckName string, leaveRunning *bool, criu puck.CRIUFlags) error {
+	infof("Creating stack snapshot '%s' of puck '%s'...", snapshotName, puckName)
+
+	endProgress := showSnapshotProgress(client)
+	result, err := client.SnapshotStackCreate(puck.StackSnapshotOptions{
+		PuckName:     puckName,
+		SnapshotName: snapshotName,
+		LeaveRunning: leaveRunning,
+		Mode:         store.SnapshotMode(snapshotMode),
+		CRIUFlags:    criu,
+	})
+	endProgress()
+	if result != nil && !quiet {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PUCK\tRESULT")
+		for _, r := range result.Members {
+			if r.Error != "" {
+				fmt.Fprintf(w, "%s\tfailed: %s\n", r.Puck, r.Error)
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%s (%s)\n", r.Puck, r.Snapshot.Mode, humanize.Bytes(uint64(r.Snapshot.SizeBytes)))
+		}
+		w.Flush()
+	}
+	if err != nil {
+		return err
+	}
+
+	if quiet {
+		fmt.Println(result.Stack.Name)
+		return nil
+	}
+	fmt.Printf("Stack snapshot created: %s (%d pucks)\n", result.Stack.Name, len(result.Stack.Members))
 	return nil
 }
 
@@ -100,35 +514,64 @@ func runSnapshotRestore(cmd *cobra.Command, args []string) error {
 	puckName := args[0]
 	snapshotName := args[1]
 
-	client, err := daemon.NewClient()
+	client, err := newClient()
 	if err != nil {
 		return err
 	}
 
-	if err := client.Ping(); err != nil {
-		return fmt.Errorf("daemon not running: %w\nStart with: puck daemon start", err)
+	endProgress := func() {}
+	if snapshotRepull {
+		endProgress = showPullProgress(client)
 	}
 
-	fmt.Printf("Restoring puck '%s' from snapshot '%s'...\n", puckName, snapshotName)
+	if snapshotStack {
+		infof("Restoring the stack of puck '%s' from snapshot '%s'...", puckName, snapshotName)
+
+		restored, err := client.SnapshotStackRestore(puck.StackRestoreOptions{
+			PuckName:     puckName,
+			SnapshotName: snapshotName,
+			Force:        snapshotRestoreForce,
+			Repull:       snapshotRepull,
+		})
+		endProgress()
+		if err != nil {
+			if len(restored) > 0 {
+				infof("Restored before the failure: %s", strings.Join(restored, ", "))
+			}
+			return err
+		}
+
+		infof("Restored and running: %s", strings.Join(restored, ", "))
+		return nil
+	}
 
-	if err := client.SnapshotRestore(puckName, snapshotName); err != nil {
+	infof("Restoring puck '%s' from snapshot '%s'...", puckName, snapshotName)
+
+	err = client.SnapshotRestore(puck.SnapshotRestoreOptions{
+		PuckName:     puckName,
+		SnapshotName: snapshotName,
+		Force:        snapshotRestoreForce,
+		Repull:       snapshotRepull,
+	})
+	endProgress()
+	if err != nil {
 		return err
 	}
 
-	fmt.Println("Puck restored and running")
+	infof("Puck restored and running")
 	return nil
 }
 
 func runSnapshotList(cmd *cobra.Command, args []string) error {
 	puckName := args[0]
 
-	client, err := daemon.NewClient()
+	client, err := newClient()
 	if err != nil {
 		return err
 	}
 
-	if err := client.Ping(); err != nil {
-		return fmt.Errorf("daemon not running: %w\nStart with: puck daemon start", err)
+	if snapshotListStacks {
+		return runSnapshotListStacks(client, puckName)
 	}
 
 	snapshots, err := client.SnapshotList(puckName)
@@ -136,18 +579,29 @@ func runSnapshotList(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	if snapshotFormat != "" {
+		return printFormatted(snapshotFormat, snapshots, nil)
+	}
 	if len(snapshots) == 0 {
-		fmt.Printf("No snapshots for puck '%s'\n", puckName)
+		infof("No snapshots for puck '%s'", puckName)
+		return nil
+	}
+	if quiet {
+		for _, s := range snapshots {
+			fmt.Println(s.Name)
+		}
 		return nil
 	}
 
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "NAME\tSIZE\tCREATED")
+	fmt.Fprintln(w, "NAME\tMODE\tSIZE\tCREATED\tTAGS")
 	for _, s := range snapshots {
-		fmt.Fprintf(w, "%s\t%s\t%s\n",
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
 			s.Name,
+			s.Mode,
 			humanize.Bytes(uint64(s.SizeBytes)),
 			humanize.Time(s.CreatedAt),
+			valueOr(strings.Join(s.Tags, ","), "-"),
 		)
 	}
 	w.Flush()
@@ -155,23 +609,394 @@ func runSnapshotList(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+func runSnapshotListStacks(client *daemon.Client, puckName string) error {
+	stacks, err := client.SnapshotStackList(puckName)
+	if err != nil {
+		return err
+	}
+
+	if snapshotFormat != "" {
+		return printFormatted(snapshotFormat, stacks, nil)
+	}
+	if len(stacks) == 0 {
+		infof("No stack snapshots for puck '%s'", puckName)
+		return nil
+	}
+	if quiet {
+		for _, s := range stacks {
+			fmt.Println(s.Name)
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tPUCKS\tCREATED")
+	for _, s := range stacks {
+		members := make([]string, len(s.Members))
+		for i, m := range s.Members {
+			members[i] = m.Puck
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", s.Name, strings.Join(members, ","), humanize.Time(s.CreatedAt))
+	}
+	w.Flush()
+
+	return nil
+}
+
+func runSnapshotInspect(cmd *cobra.Command, args []string) error {
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	info, err := client.SnapshotInspect(puck.SnapshotInspectOptions{
+		PuckName:     args[0],
+		SnapshotName: args[1],
+		Files:        snapshotInspectFiles,
+	})
+	if err != nil {
+		return err
+	}
+	if snapshotFormat != "" {
+		return printFormatted(snapshotFormat, info, nil)
+	}
+	s := info.Snapshot
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Name:\t%s\n", s.Name)
+	fmt.Fprintf(w, "Puck:\t%s\n", s.PuckName)
+	fmt.Fprintf(w, "Mode:\t%s\n", s.Mode)
+	fmt.Fprintf(w, "Created:\t%s (%s)\n", s.CreatedAt.Format("2006-01-02 15:04"), humanize.Time(s.CreatedAt))
+	fmt.Fprintf(w, "Image:\t%s\n", valueOr(s.Image, "-"))
+	if s.CommitImage != "" {
+		fmt.Fprintf(w, "Committed to:\t%s\n", s.CommitImage)
+	}
+	if len(s.Tags) > 0 {
+		fmt.Fprintf(w, "Tags:\t%s\n", strings.Join(s.Tags, ", "))
+	}
+	archive := s.Path
+	if s.ColdPath != "" {
+		archive = s.ColdPath + " (cold storage)"
+	}
+	fmt.Fprintf(w, "Archive:\t%s\n", archive)
+	if s.VolumesPath != "" {
+		fmt.Fprintf(w, "Volume archive:\t%s\n", s.VolumesPath)
+	}
+	fmt.Fprintf(w, "Size:\t%s (%s uncompressed)\n", humanize.Bytes(uint64(s.SizeBytes)), humanize.Bytes(uint64(info.Size)))
+	fmt.Fprintf(w, "Compression:\t%s\n", info.Compression)
+	fmt.Fprintf(w, "SHA-256:\t%s\n", info.Checksum)
+	fmt.Fprintf(w, "Entries:\t%d\n", info.Entries)
+	if s.Mode == store.SnapshotModeCheckpoint {
+		fmt.Fprintf(w, "CRIU version:\t%s\n", valueOr(s.CRIUVersion, "unknown"))
+		fmt.Fprintf(w, "Kernel:\t%s\n", valueOr(s.Kernel, "unknown"))
+		fmt.Fprintf(w, "Podman:\t%s\n", valueOr(s.PodmanVersion, "unknown"))
+		fmt.Fprintf(w, "Image digest:\t%s\n", valueOr(s.ImageDigest, "unknown"))
+		fmt.Fprintf(w, "CRIU options:\t%s\n", criuOptionsString(s.CRIU))
+		fmt.Fprintf(w, "CRIU images:\t%d\n", info.CheckpointImages)
+		fmt.Fprintf(w, "Rootfs changes:\t%s\n", humanize.Bytes(uint64(info.RootfsDiff)))
+	}
+
+	var volumes []string
+	for _, v := range info.Volumes {
+		volumes = append(volumes, fmt.Sprintf("%s (%s)", v.Name, humanize.Bytes(uint64(v.Size))))
+	}
+	switch {
+	case len(volumes) > 0:
+		fmt.Fprintf(w, "Volume data:\t%s\n", strings.Join(volumes, ", "))
+	case s.Mode == store.SnapshotModeCheckpoint:
+		fmt.Fprintf(w, "Volume data:\tnone; restoring keeps the puck's current volumes\n")
+	default:
+		fmt.Fprintf(w, "Volume data:\tnone\n")
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if !snapshotInspectFiles {
+		return nil
+	}
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MODE\tSIZE\tMODIFIED\tNAME")
+	for _, f := range info.Files {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", f.Mode, humanize.Bytes(uint64(f.Size)), f.ModTime.Format("2006-01-02 15:04"), f.Name)
+	}
+	return w.Flush()
+}
+
 func runSnapshotDelete(cmd *cobra.Command, args []string) error {
 	puckName := args[0]
 	snapshotName := args[1]
 
-	client, err := daemon.NewClient()
+	client, err := newClient()
 	if err != nil {
 		return err
 	}
 
-	if err := client.Ping(); err != nil {
-		return fmt.Errorf("daemon not running: %w\nStart with: puck daemon start", err)
+	if err := client.SnapshotDelete(puckName, snapshotName); err != nil {
+		return err
 	}
 
-	if err := client.SnapshotDelete(puckName, snapshotName); err != nil {
+	infof("Deleted snapshot '%s' from puck '%s'", snapshotName, puckName)
+	return nil
+}
+
+func runSnapshotTree(cmd *cobra.Command, args []string) error {
+	puckName := args[0]
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	p, err := client.Get(puckName)
+	if err != nil {
+		return err
+	}
+
+	snapshots, err := client.SnapshotList(puckName)
+	if err != nil {
 		return err
 	}
 
-	fmt.Printf("Deleted snapshot '%s' from puck '%s'\n", snapshotName, puckName)
+	if len(snapshots) == 0 {
+		infof("No snapshots for puck '%s'", puckName)
+		return nil
+	}
+
+	fmt.Println(puckName)
+	printSnapshotNodes(puck.BuildSnapshotTree(snapshots), "", p.SnapshotHead)
 	return nil
 }
+
+// printSnapshotNodes draws one level of the snapshot tree below prefix
+func printSnapshotNodes(nodes []*puck.SnapshotNode, prefix, head string) {
+	for i, n := range nodes {
+		connector, indent := "├── ", "│   "
+		if i == len(nodes)-1 {
+			connector, indent = "└── ", "    "
+		}
+
+		line := n.Snapshot.Name
+		if n.Snapshot.ID == head {
+			line += " *"
+		}
+		if len(n.Snapshot.Tags) > 0 {
+			line += " [" + strings.Join(n.Snapshot.Tags, ", ") + "]"
+		}
+		fmt.Printf("%s%s%s  (%s)\n", prefix, connector, line, humanize.Time(n.Snapshot.CreatedAt))
+
+		printSnapshotNodes(n.Children, prefix+indent, head)
+	}
+}
+
+func runSnapshotTag(cmd *cobra.Command, args []string) error {
+	puckName := args[0]
+	snapshotName := args[1]
+	tag := args[2]
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	if _, err := client.SnapshotTag(puckName, snapshotName, tag, snapshotTagDelete); err != nil {
+		return err
+	}
+
+	if snapshotTagDelete {
+		infof("Removed tag '%s' from snapshot '%s'", tag, snapshotName)
+	} else {
+		infof("Tagged snapshot '%s' as '%s'", snapshotName, tag)
+	}
+	return nil
+}
+
+func runSnapshotTierStatus(cmd *cobra.Command, args []string) error {
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	status, err := client.SnapshotTierStatus()
+	if err != nil {
+		return err
+	}
+
+	if status.Dir == "" {
+		infof("Cold storage is off; set snapshot_tier_dir to move old snapshots out of the data directory.")
+	} else {
+		infof("Snapshots older than %d days move to %s", status.AfterDays, status.Dir)
+	}
+
+	var hot, cold int64
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PUCK\tSNAPSHOT\tTIER\tSIZE\tCREATED")
+	for _, s := range status.Snapshots {
+		tier := s.Tier
+		if s.Due {
+			tier += " (due)"
+		}
+		if s.Tier == puck.TierCold {
+			cold += s.Size
+		} else {
+			hot += s.Size
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", s.Puck, s.Snapshot, tier, humanize.Bytes(uint64(s.Size)), humanize.Time(s.CreatedAt))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	infof("\n%s hot, %s cold", humanize.Bytes(uint64(hot)), humanize.Bytes(uint64(cold)))
+	return nil
+}
+
+func runSnapshotTierRun(cmd *cobra.Command, args []string) error {
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	report, err := client.SnapshotTierRun()
+	if err != nil {
+		return err
+	}
+
+	for _, msg := range report.Errors {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", msg)
+	}
+	for _, s := range report.Moved {
+		infof("Moved snapshot '%s' of '%s' to %s", s.Snapshot, s.Puck, s.Path)
+	}
+	if len(report.Moved) == 0 {
+		infof("No snapshots are due for cold storage.")
+	}
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("%d snapshots could not be moved", len(report.Errors))
+	}
+	return nil
+}
+
+func runSnapshotConfig(cmd *cobra.Command, args []string) error {
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	opts := puck.SnapshotPolicyOptions{Name: args[0]}
+	flags := cmd.Flags()
+	if flags.Changed("leave-running") {
+		opts.LeaveRunning = &snapshotConfigLeaveRunning
+	}
+	if flags.Changed("compression") {
+		opts.Compression = &snapshotConfigCompression
+	}
+	if flags.Changed("include-volumes") {
+		opts.IncludeVolumes = &snapshotConfigIncludeVolumes
+	}
+	if flags.Changed("schedule") {
+		opts.Schedule = &snapshotConfigSchedule
+	}
+	if flags.Changed("retain") {
+		opts.Retain = &snapshotConfigRetain
+	}
+
+	var p *store.Puck
+	if flags.NFlag() == 0 {
+		p, err = client.Get(opts.Name)
+	} else {
+		p, err = client.SnapshotPolicySet(opts)
+	}
+	if err != nil {
+		return err
+	}
+
+	policy := p.SnapshotPolicy
+	retain := "all"
+	if policy.Retain > 0 {
+		retain = fmt.Sprintf("%d", policy.Retain)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Leave running:\t%t\n", policy.LeaveRunning)
+	fmt.Fprintf(w, "Compression:\t%s\n", valueOr(policy.Compression, "default"))
+	fmt.Fprintf(w, "Include volumes:\t%t\n", policy.IncludeVolumes)
+	fmt.Fprintf(w, "Schedule:\t%s\n", valueOr(policy.Schedule, "-"))
+	fmt.Fprintf(w, "Retain:\t%s\n", retain)
+	return w.Flush()
+}
+
+func runSnapshotDiff(cmd *cobra.Command, args []string) error {
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	diff, err := client.SnapshotDiff(puck.SnapshotDiffOptions{PuckName: args[0], From: args[1], To: args[2]})
+	if err != nil {
+		return err
+	}
+
+	if !quiet {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "Created:\t%s -> %s\n", diff.From.CreatedAt.Format("2006-01-02 15:04"), diff.To.CreatedAt.Format("2006-01-02 15:04"))
+		fmt.Fprintf(w, "Size:\t%s -> %s (%s)\n", humanize.Bytes(uint64(diff.From.SizeBytes)), humanize.Bytes(uint64(diff.To.SizeBytes)), sizeDelta(diff.SizeDelta))
+		for _, f := range diff.Fields {
+			fmt.Fprintf(w, "%s:\t%s -> %s\n", strings.ToUpper(f.Field[:1])+f.Field[1:], valueOr(f.From, "-"), valueOr(f.To, "-"))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if !diff.Volumes {
+		infof("\nVolumes not compared: both snapshots must hold them (image snapshots, or 'puck snapshot config --include-volumes')")
+		return nil
+	}
+	if len(diff.Files) == 0 {
+		infof("\nNo changes to volume files")
+		return nil
+	}
+
+	if !quiet {
+		fmt.Println()
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, f := range diff.Files {
+		switch {
+		case quiet:
+			// Just the paths, for piping on
+			fmt.Fprintln(w, f.Path)
+		case f.Change == puck.FileAdded:
+			fmt.Fprintf(w, "+ %s\t%s\n", f.Path, humanize.Bytes(uint64(f.ToSize)))
+		case f.Change == puck.FileRemoved:
+			fmt.Fprintf(w, "- %s\t%s\n", f.Path, humanize.Bytes(uint64(f.FromSize)))
+		default:
+			fmt.Fprintf(w, "~ %s\t%s\n", f.Path, sizeDelta(f.ToSize-f.FromSize))
+		}
+	}
+	return w.Flush()
+}
+
+// sizeDelta formats a change in size with its sign, e.g. +1.2 MB
+func sizeDelta(delta int64) string {
+	if delta < 0 {
+		return "-" + humanize.Bytes(uint64(-delta))
+	}
+	return "+" + humanize.Bytes(uint64(delta))
+}
+
+// criuOptionsString lists the CRIU options a checkpoint was taken with
+func criuOptionsString(o store.CRIUOptions) string {
+	var opts []string
+	if o.TCPEstablished {
+		opts = append(opts, "tcp-established")
+	}
+	if o.FileLocks {
+		opts = append(opts, "file-locks")
+	}
+	if len(opts) == 0 {
+		return "none"
+	}
+	return strings.Join(opts, ", ")
+}
@@ -1,36 +1,38 @@
 package cli
 
 import (
-	"fmt"
 
 	"github.com/spf13/cobra"
-	"github.com/sandwich-labs/puck/internal/daemon"
 )
 
 var startCmd = &cobra.Command{
-	Use:   "start [name]",
+	Use:   "start [name | @group]",
 	Short: "Start a stopped puck",
-	Long:  `Start a puck that was previously stopped.`,
+	Long: `Start a puck that was previously stopped, or with @<group> each puck of
+a group (see 'puck group').`,
 	Args:  cobra.ExactArgs(1),
 	RunE:  runStart,
 }
 
 func runStart(cmd *cobra.Command, args []string) error {
-	name := args[0]
+	name, err := selectContext(args[0])
+	if err != nil {
+		return err
+	}
 
-	client, err := daemon.NewClient()
+	client, err := newClient()
 	if err != nil {
 		return err
 	}
 
-	if err := client.Ping(); err != nil {
-		return fmt.Errorf("daemon not running: %w\nStart with: puck daemon start", err)
+	if group, ok := groupName(name); ok {
+		return forEachInGroup(client, group, "start", "Started", client.Start)
 	}
 
 	if err := client.Start(name); err != nil {
 		return err
 	}
 
-	fmt.Printf("Started puck '%s'\n", name)
+	infof("Started puck '%s'", args[0])
 	return nil
 }
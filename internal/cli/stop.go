@@ -1,36 +1,82 @@
 package cli
 
 import (
-	"fmt"
 
 	"github.com/spf13/cobra"
-	"github.com/sandwich-labs/puck/internal/daemon"
+	"github.com/sandwich-labs/puck/internal/puck"
 )
 
 var stopCmd = &cobra.Command{
-	Use:   "stop [name]",
+	Use:   "stop [name | @group]",
 	Short: "Stop a running puck",
-	Long:  `Stop a running puck.`,
-	Args:  cobra.ExactArgs(1),
-	RunE:  runStop,
+	Long: `Stop a running puck.
+
+The puck's init is asked to shut down and gets --timeout seconds to exit
+before it is killed. Without --timeout the puck's own stop timeout is
+used, set with 'puck create --stop-timeout', or else stop_timeout from
+the config (10 seconds by default). Use 'puck kill' to stop a puck
+immediately.
+
+With --checkpoint the puck is suspended instead: its memory is saved to
+a resume image, and 'puck start' resumes it from there with its
+processes and open editors intact. A plain 'puck stop' of a suspended
+puck discards the resume image.
+
+With @<group> each puck of a group (see 'puck group') is stopped in turn.
+
+Examples:
+  puck stop web
+  puck stop web --checkpoint
+  puck stop @demo
+  puck start web                # resumes a suspended puck`,
+	Args: cobra.ExactArgs(1),
+	RunE: runStop,
 }
 
-func runStop(cmd *cobra.Command, args []string) error {
-	name := args[0]
+var (
+	stopTimeout    int
+	stopCheckpoint bool
+)
+
+func init() {
+	stopCmd.Flags().IntVarP(&stopTimeout, "timeout", "t", 0, "seconds to wait for the puck to exit before killing it")
+	stopCmd.Flags().BoolVar(&stopCheckpoint, "checkpoint", false, "suspend the puck so 'puck start' resumes it")
+	stopCmd.MarkFlagsMutuallyExclusive("timeout", "checkpoint")
+}
 
-	client, err := daemon.NewClient()
+func runStop(cmd *cobra.Command, args []string) error {
+	name, err := selectContext(args[0])
 	if err != nil {
 		return err
 	}
 
-	if err := client.Ping(); err != nil {
-		return fmt.Errorf("daemon not running: %w\nStart with: puck daemon start", err)
+	client, err := newClient()
+	if err != nil {
+		return err
 	}
 
-	if err := client.Stop(name); err != nil {
+	opts := puck.StopOptions{Name: name, Checkpoint: stopCheckpoint}
+	if cmd.Flags().Changed("timeout") {
+		opts.Timeout = &stopTimeout
+	}
+	if group, ok := groupName(name); ok {
+		action, done := "stop", "Stopped"
+		if stopCheckpoint {
+			action, done = "suspend", "Suspended"
+		}
+		return forEachInGroup(client, group, action, done, func(name string) error {
+			opts.Name = name
+			return client.StopWithOptions(opts)
+		})
+	}
+	if err := client.StopWithOptions(opts); err != nil {
 		return err
 	}
 
-	fmt.Printf("Stopped puck '%s'\n", name)
+	if stopCheckpoint {
+		infof("Suspended puck '%s'; 'puck start %s' resumes it", args[0], args[0])
+		return nil
+	}
+	infof("Stopped puck '%s'", args[0])
 	return nil
 }
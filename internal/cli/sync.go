@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/dustin/go-humanize"
+	"github.com/sandwich-labs/puck/internal/filesync"
+	"github.com/spf13/cobra"
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Inspect and flush synced project mounts",
+	Long: `Mounts with sync: true in puck.yaml are copied into the puck's volume
+and kept up to date by the daemon, instead of being bind mounted, which
+is much faster where bind mounts are slow, such as Podman Machine on
+macOS.
+
+The copy follows the host directory one way: changes are synced shortly
+after files settle, and changes made in the puck are overwritten, except
+under ignored paths. Ignore patterns come from the mount's ignore list and
+a .puckignore file in the directory, in .gitignore syntax.`,
+}
+
+var syncStatusCmd = &cobra.Command{
+	Use:   "status [name]",
+	Short: "Show the state of synced mounts",
+	Args:  cobra.MaximumNArgs(1),
+	RunE:  runSyncStatus,
+}
+
+var syncFlushCmd = &cobra.Command{
+	Use:   "flush <name>",
+	Short: "Sync a puck's mounts now",
+	Long: `Sync a puck's mounts now rather than once files settle, and wait for it
+to finish, e.g. before running tests that need the latest changes.
+
+Examples:
+  puck sync flush web && puck exec web -- make test`,
+	Args: cobra.ExactArgs(1),
+	RunE: runSyncFlush,
+}
+
+func init() {
+	syncCmd.AddCommand(syncStatusCmd)
+	syncCmd.AddCommand(syncFlushCmd)
+}
+
+func runSyncStatus(cmd *cobra.Command, args []string) error {
+	name := ""
+	if len(args) > 0 {
+		var err error
+		if name, err = selectContext(args[0]); err != nil {
+			return err
+		}
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	statuses, err := client.SyncStatus(name)
+	if err != nil {
+		return err
+	}
+
+	if len(statuses) == 0 {
+		infof("No synced mounts. Set sync: true on a mount in puck.yaml to add one.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PUCK\tTARGET\tSOURCE\tSTATE\tLAST SYNC")
+	for _, s := range statuses {
+		state := s.State
+		if s.Error != "" {
+			state += ": " + s.Error
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", s.Puck, s.Target, s.Source, state, lastSync(s.Status))
+	}
+	return w.Flush()
+}
+
+func runSyncFlush(cmd *cobra.Command, args []string) error {
+	name, err := selectContext(args[0])
+	if err != nil {
+		return err
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	statuses, err := client.SyncFlush(name)
+	if err != nil {
+		return err
+	}
+	for _, s := range statuses {
+		infof("Synced %s to %s:%s: %d copied (%s), %d removed",
+			s.Source, s.Puck, s.Target, s.Last.Copied, humanize.Bytes(uint64(s.Last.Bytes)), s.Last.Removed)
+	}
+	return nil
+}
+
+// lastSync describes when a mount last synced and what changed
+func lastSync(s filesync.Status) string {
+	if s.LastSync.IsZero() {
+		return "never"
+	}
+	return fmt.Sprintf("%s (%d copied, %d removed)", humanize.Time(s.LastSync), s.Last.Copied, s.Last.Removed)
+}
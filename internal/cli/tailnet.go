@@ -0,0 +1,170 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sandwich-labs/puck/internal/daemon"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var tailnetCmd = &cobra.Command{
+	Use:     "tailnet",
+	Aliases: []string{"tailscale"},
+	Short:   "Share pucks on your tailnet",
+	Long:    `Expose pucks as their own Tailscale nodes. Requires tailnet to be set in the config.`,
+}
+
+var tailnetShareCmd = &cobra.Command{
+	Use:   "share <name>",
+	Short: "Serve a puck as its own tailnet node",
+	Long: `Serve a puck at the root of its own tailnet node, named after the puck.
+
+ACL tags given with --tag are applied to the node, so tailnet policy can
+restrict which users and devices reach it. Tags must be permitted for
+the daemon's auth key in the tailnet policy file (tagOwners). Sharing
+again replaces the tags.
+
+Examples:
+  puck tailnet share web
+  puck tailnet share web --tag tag:dev --tag tag:frontend`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTailnetShare,
+}
+
+var tailnetUnshareCmd = &cobra.Command{
+	Use:   "unshare <name>",
+	Short: "Stop serving a puck as a tailnet node",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runTailnetUnshare,
+}
+
+var tailnetStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the daemon's tailnet nodes and the state they keep",
+	Long: `Show the daemon's tailnet nodes: the API's own node, the router's node,
+and one for each puck shared on the tailnet, with where each keeps its
+state under the data directory.
+
+State left by pucks that are no longer shared is marked stale. The daemon
+logs those nodes out of the tailnet and removes their state when it next
+starts, so they don't linger as old devices; 'puck daemon uninstall' logs
+out all of them.`,
+	Args: cobra.NoArgs,
+	RunE: runTailnetStatus,
+}
+
+var tailnetTags []string
+
+func init() {
+	tailnetCmd.AddCommand(tailnetShareCmd)
+	tailnetCmd.AddCommand(tailnetUnshareCmd)
+	tailnetCmd.AddCommand(tailnetStatusCmd)
+
+	tailnetShareCmd.Flags().StringArrayVar(&tailnetTags, "tag", nil, "ACL tag for the node, e.g. tag:dev (repeatable)")
+}
+
+func runTailnetShare(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	p, err := client.TailnetShare(name, tailnetTags)
+	if err != nil {
+		return err
+	}
+
+	if quiet {
+		fmt.Println(tailnetURL(p.Name))
+		return nil
+	}
+	fmt.Printf("Shared puck '%s' on the tailnet\n", p.Name)
+	fmt.Printf("  URL:  %s\n", tailnetURL(p.Name))
+	if len(p.Tailnet.Tags) > 0 {
+		fmt.Printf("  Tags: %s\n", strings.Join(p.Tailnet.Tags, ", "))
+	}
+	return nil
+}
+
+func runTailnetUnshare(cmd *cobra.Command, args []string) error {
+	name := args[0]
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	if _, err := client.TailnetUnshare(name); err != nil {
+		return err
+	}
+
+	infof("Unshared puck '%s' from the tailnet", name)
+	return nil
+}
+
+func runTailnetStatus(cmd *cobra.Command, args []string) error {
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	st, err := client.TailnetStatus()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Tailnet: %s\n", st.Tailnet)
+	fmt.Printf("State:   %s\n\n", st.StateDir)
+	if len(st.Nodes) == 0 {
+		fmt.Println("No tailnet nodes yet")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ROLE\tNAME\tSTATE\tADDRESSES")
+	stale := 0
+	for _, n := range st.Nodes {
+		name := n.Name
+		if name == "" {
+			name = "-"
+		}
+		addrs := strings.Join(n.IPs, ", ")
+		if addrs == "" {
+			addrs = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", n.Role, name, tailnetNodeState(n), addrs)
+		if n.Stale {
+			stale++
+		}
+	}
+	w.Flush()
+	if stale > 0 {
+		fmt.Printf("\n%d stale node(s) will be logged out and removed when the daemon next starts\n", stale)
+	}
+	return nil
+}
+
+// tailnetNodeState describes a node for puck tailnet status
+func tailnetNodeState(n daemon.TailnetNode) string {
+	switch {
+	case n.Stale:
+		return "stale"
+	case n.State != "":
+		return n.State
+	case n.LoggedIn:
+		return "logged in"
+	default:
+		return "not logged in"
+	}
+}
+
+// tailnetURL is the address of a puck's own tailnet node
+func tailnetURL(name string) string {
+	return fmt.Sprintf("https://%s.%s/", name, viper.GetString("tailnet"))
+}
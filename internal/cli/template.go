@@ -0,0 +1,254 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/sandwich-labs/puck/internal/templates"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var templateCmd = &cobra.Command{
+	Use:   "template",
+	Short: "Manage puck templates",
+	Long: `Manage templates for 'puck create --template'.
+
+A template is a git repository or local directory with a puck-template.yaml
+at its root, giving the new puck's settings and scripts that provision it:
+
+  description: Node.js development box
+  image: node:{{NODE_VERSION}}
+  init: tini
+  ports: ["3000:3000"]
+  command: ["sleep", "infinity"]
+  repo:
+    url: https://github.com/me/{{PUCK_NAME}}
+    branch: main
+  variables:
+    - name: NODE_VERSION
+      prompt: Node.js version
+      default: "22"
+  provision:
+    - setup.sh
+
+{{NAME}} in the settings and scripts is replaced with the variable's value,
+asked for at create time unless given with --var; {{PUCK_NAME}} is the new
+puck's name. The repo, if any, is cloned into /home/workspace (or its dir)
+first; provisioning scripts then run inside the puck in order. Their output
+is in /var/puck/provision.log.
+
+Templates can be used by source (gh:user/repo, a git URL with an optional
+#branch, or a path) or registered under a name with 'puck template add'.
+Git templates are cached after the first fetch; adding one again fetches
+it afresh.
+
+Examples:
+  puck template add node gh:me/puck-node
+  puck create api --template node --var NODE_VERSION=20
+  puck create scratch --template ./my-template`,
+}
+
+var templateListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List registered templates",
+	Args:    cobra.NoArgs,
+	RunE:    runTemplateList,
+}
+
+var templateAddCmd = &cobra.Command{
+	Use:   "add <name> <source>",
+	Short: "Register a template under a name, fetching it",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runTemplateAdd,
+}
+
+func init() {
+	templateCmd.AddCommand(templateListCmd)
+	templateCmd.AddCommand(templateAddCmd)
+}
+
+func runTemplateList(cmd *cobra.Command, args []string) error {
+	reg, err := templates.LoadRegistry(templates.RegistryPath())
+	if err != nil {
+		return err
+	}
+
+	names := reg.Names()
+	if len(names) == 0 {
+		infof("No templates registered. Add one with: puck template add <name> <source>")
+		return nil
+	}
+	if quiet {
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return nil
+	}
+
+	cache := &templates.Cache{Dir: templates.DefaultCacheDir()}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSOURCE\tDESCRIPTION")
+	for _, name := range names {
+		description := "-"
+		src, err := templates.ParseSource(reg.Templates[name])
+		switch {
+		case err != nil:
+			description = "invalid source: " + err.Error()
+		case !cache.Cached(src):
+			description = "(not fetched)"
+		default:
+			if dir, err := cache.Fetch(context.Background(), src, false); err == nil {
+				if t, err := templates.Load(dir); err == nil {
+					description = valueOr(t.Description, "-")
+				}
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", name, reg.Templates[name], description)
+	}
+
+	return w.Flush()
+}
+
+func runTemplateAdd(cmd *cobra.Command, args []string) error {
+	name, source := args[0], args[1]
+
+	reg, err := templates.LoadRegistry(templates.RegistryPath())
+	if err != nil {
+		return err
+	}
+	src, err := templates.ParseSource(source)
+	if err != nil {
+		return err
+	}
+	if err := reg.Add(name, src); err != nil {
+		return err
+	}
+
+	// Fetch now so a bad source is caught here rather than at create time
+	cache := &templates.Cache{Dir: templates.DefaultCacheDir()}
+	dir, err := cache.Fetch(cmd.Context(), src, true)
+	if err != nil {
+		return err
+	}
+	if _, err := templates.Load(dir); err != nil {
+		return err
+	}
+
+	if err := reg.Save(templates.RegistryPath()); err != nil {
+		return err
+	}
+	infof("Added template '%s' (%s)", name, src)
+	return nil
+}
+
+// applyTemplate fills in create options from a template, leaving the ones
+// set on the command line. Variables not given with --var are asked for.
+func applyTemplate(cmd *cobra.Command, ref string, vars []string, opts *puck.CreateOptions) error {
+	reg, err := templates.LoadRegistry(templates.RegistryPath())
+	if err != nil {
+		return err
+	}
+	src, err := reg.Resolve(ref)
+	if err != nil {
+		return err
+	}
+	cache := &templates.Cache{Dir: templates.DefaultCacheDir()}
+	dir, err := cache.Fetch(cmd.Context(), src, false)
+	if err != nil {
+		return err
+	}
+	t, err := templates.Load(dir)
+	if err != nil {
+		return err
+	}
+
+	values, err := templateValues(t, vars)
+	if err != nil {
+		return err
+	}
+	values[templates.NameVariable] = opts.Name
+	rendered, scripts, err := t.Render(values)
+	if err != nil {
+		return err
+	}
+
+	flags := cmd.Flags()
+	if rendered.Image != "" && !flags.Changed("image") {
+		opts.Image = rendered.Image
+	}
+	if rendered.Init != "" && !flags.Changed("init") {
+		opts.Init = store.InitMode(rendered.Init)
+	}
+	if len(rendered.Ports) > 0 && !flags.Changed("port") {
+		opts.Ports = rendered.Ports
+	}
+	if len(rendered.Entrypoint) > 0 && !flags.Changed("entrypoint") {
+		opts.Entrypoint = rendered.Entrypoint
+	}
+	if len(rendered.Command) > 0 && opts.Command == nil {
+		opts.Command = rendered.Command
+	}
+	if len(rendered.Requires) > 0 && !flags.Changed("requires") {
+		opts.Requires = rendered.Requires
+	}
+	if rendered.Repo != nil && opts.Repo == nil {
+		opts.Repo = &puck.RepoClone{URL: rendered.Repo.URL, Branch: rendered.Repo.Branch, Dir: rendered.Repo.Dir}
+	}
+	opts.Provision = scripts
+	return nil
+}
+
+// templateValues collects a value for each of a template's variables from
+// --var NAME=value, else by asking on a terminal, else from its default
+func templateValues(t *templates.Template, vars []string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, v := range vars {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid --var %q; use NAME=value", v)
+		}
+		values[name] = value
+	}
+
+	tty := term.IsTerminal(int(os.Stdin.Fd()))
+	in := bufio.NewReader(os.Stdin)
+	for _, v := range t.Variables {
+		if _, ok := values[v.Name]; ok {
+			continue
+		}
+		if !tty {
+			if v.Default == "" {
+				return nil, fmt.Errorf("template variable %s needs a value; pass --var %s=<value>", v.Name, v.Name)
+			}
+			values[v.Name] = v.Default
+			continue
+		}
+
+		prompt := valueOr(v.Prompt, v.Name)
+		if v.Default != "" {
+			prompt += " [" + v.Default + "]"
+		}
+		fmt.Fprintf(os.Stderr, "%s: ", prompt)
+		line, err := in.ReadString('\n')
+		if err != nil && line == "" {
+			return nil, fmt.Errorf("reading %s: %w", v.Name, err)
+		}
+		value := strings.TrimSpace(line)
+		if value == "" {
+			value = v.Default
+		}
+		if value == "" {
+			return nil, fmt.Errorf("template variable %s needs a value", v.Name)
+		}
+		values[v.Name] = value
+	}
+	return values, nil
+}
@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/spf13/cobra"
+)
+
+var usageCmd = &cobra.Command{
+	Use:   "usage",
+	Short: "Show how much pucks were used, day by day or week by week",
+	Long: `Show how pucks were used over a period (the last 30 days by default),
+week by week or, with --by day, day by day: how many were active, and
+the pucks created and started, puck exec and console sessions, snapshots
+taken and requests routed. Then, for each puck, what it did over the
+whole period, to spot ones that are no longer used.
+
+This is worked out on this machine from the pucks' history and the
+samples the daemon takes for puck report. Nothing is sent anywhere.
+Destroying a puck deletes its history, so only the pucks there are now
+are counted.
+
+Examples:
+  puck usage
+  puck usage --since 168h --by day
+  puck usage --json`,
+	Args: cobra.NoArgs,
+	RunE: runUsage,
+}
+
+var (
+	usageSince  time.Duration
+	usageBy     string
+	usageJSON   bool
+	usageFormat string
+)
+
+func init() {
+	usageCmd.Flags().DurationVar(&usageSince, "since", 30*24*time.Hour, "how far back to look (e.g. 168h)")
+	usageCmd.Flags().StringVar(&usageBy, "by", puck.ActivityByWeek, "sum up by day or week")
+	usageCmd.Flags().BoolVar(&usageJSON, "json", false, "print as JSON")
+	addFormatFlag(usageCmd, &usageFormat)
+}
+
+func runUsage(cmd *cobra.Command, args []string) error {
+	if usageSince <= 0 {
+		return fmt.Errorf("--since must be positive")
+	}
+	if usageBy != puck.ActivityByDay && usageBy != puck.ActivityByWeek {
+		return fmt.Errorf("invalid --by %q (expected day or week)", usageBy)
+	}
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+
+	until := time.Now()
+	since := until.Add(-usageSince)
+	pucks, err := client.List()
+	if err != nil {
+		return err
+	}
+	events, err := client.ExportEvents("", since)
+	if err != nil {
+		return err
+	}
+	stats, err := client.ExportStats("", since)
+	if err != nil {
+		return err
+	}
+	names := make([]string, len(pucks))
+	for i, p := range pucks {
+		names[i] = p.Name
+	}
+	activity := puck.SumActivity(names, events, stats, since, until, usageBy)
+
+	if usageJSON {
+		usageFormat = "json"
+	}
+	if usageFormat != "" {
+		return printFormatted(usageFormat, activity, nil)
+	}
+
+	infof("Since %s (%s)", since.Format("2006-01-02 15:04"), formatDuration(usageSince))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.ToUpper(usageBy)+"\tACTIVE\tCREATED\tSTARTS\tEXECS\tSNAPSHOTS\tREQUESTS")
+	for _, p := range activity.Periods {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t%d\n",
+			p.Start.Format("Mon 2006-01-02"), p.Active, p.Created, p.Starts, p.Execs, p.Snapshots, p.Requests)
+	}
+	w.Flush()
+
+	if len(activity.Pucks) == 0 {
+		return nil
+	}
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "NAME\tACTIVE %sS\tSTARTS\tEXECS\tSNAPSHOTS\tREQUESTS\tLAST ACTIVE\n", strings.ToUpper(usageBy))
+	var unused []string
+	for _, p := range activity.Pucks {
+		last := "-"
+		if p.LastActive != nil {
+			last = humanize.Time(*p.LastActive)
+		} else {
+			unused = append(unused, p.Name)
+		}
+		fmt.Fprintf(w, "%s\t%d/%d\t%d\t%d\t%d\t%d\t%s\n",
+			p.Name, p.Active, len(activity.Periods), p.Starts, p.Execs, p.Snapshots, p.Requests, last)
+	}
+	w.Flush()
+
+	if len(unused) > 0 {
+		infof("\nNot used in the period: %s\nSee puck report for what they take up, and remove them with puck destroy", strings.Join(unused, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sandwich-labs/puck/internal/buildinfo"
+	"github.com/spf13/cobra"
+)
+
+func runVersion(cmd *cobra.Command, args []string) error {
+	fmt.Println("puck version " + buildinfo.Version)
+	if !verbose {
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Commit:\t%s\n", valueOr(buildinfo.Commit, "unknown"))
+	fmt.Fprintf(w, "Built:\t%s\n", valueOr(buildinfo.Date, "unknown"))
+	fmt.Fprintf(w, "Go:\t%s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	w.Flush()
+
+	client, err := newClient()
+	if err != nil {
+		return err
+	}
+	if err := client.Ping(); err != nil {
+		fmt.Println("\nDaemon: not running")
+		return nil
+	}
+	info, err := client.Info()
+	if err != nil {
+		return fmt.Errorf("asking the daemon for its version: %w", err)
+	}
+
+	var features []string
+	for name, on := range info.Features {
+		if on {
+			features = append(features, name)
+		}
+	}
+	slices.Sort(features)
+
+	fmt.Println("\nDaemon:")
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "  Version:\t%s\n", info.Version)
+	fmt.Fprintf(w, "  Commit:\t%s\n", valueOr(info.Commit, "unknown"))
+	fmt.Fprintf(w, "  Built:\t%s\n", valueOr(info.BuildDate, "unknown"))
+	fmt.Fprintf(w, "  Data dir:\t%s\n", info.DataDir)
+	fmt.Fprintf(w, "  Podman:\t%s\n", valueOr(info.PodmanVersion, "unavailable"))
+	fmt.Fprintf(w, "  CRIU:\t%s\n", valueOr(info.CRIUVersion, "not found"))
+	fmt.Fprintf(w, "  Features:\t%s\n", valueOr(strings.Join(features, ", "), "none"))
+	w.Flush()
+
+	if info.Version != buildinfo.Version {
+		infof("\nThe daemon runs a different version; restart it to run %s", buildinfo.Version)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// parseVolumes parses --volume flags, each host:container[:ro|rw], into
+// mounts. Host paths are on the daemon's host: for the local daemon ~ and
+// relative paths are expanded here, while for remote ones, where they
+// would name the wrong machine's files, they are refused.
+func parseVolumes(volumes []string) ([]store.Mount, error) {
+	if len(volumes) == 0 {
+		return nil, nil
+	}
+	active, err := config.ActiveContext()
+	if err != nil {
+		return nil, err
+	}
+
+	mounts := make([]store.Mount, 0, len(volumes))
+	for _, v := range volumes {
+		m, err := parseVolume(v, active)
+		if err != nil {
+			return nil, err
+		}
+		mounts = append(mounts, m)
+	}
+	return mounts, nil
+}
+
+func parseVolume(v string, active config.Context) (store.Mount, error) {
+	// A Windows host path has a colon of its own after the drive letter,
+	// though a:/b is the directory a mounted at /b
+	host, rest := "", v
+	if windowsDrive(v) && (v[2] == '\\' || strings.Contains(v[2:], ":")) {
+		host, rest = v[:2], v[2:]
+	}
+	parts := strings.Split(rest, ":")
+	parts[0] = host + parts[0]
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return store.Mount{}, fmt.Errorf("invalid volume %q (expected host:container[:ro])", v)
+	}
+	m := store.Mount{Source: parts[0], Target: parts[1]}
+	if len(parts) == 3 {
+		switch parts[2] {
+		case "ro":
+			m.ReadOnly = true
+		case "rw":
+		default:
+			return store.Mount{}, fmt.Errorf("invalid volume %q: unknown option %q (expected ro or rw)", v, parts[2])
+		}
+	}
+	if !path.IsAbs(m.Target) {
+		return store.Mount{}, fmt.Errorf("invalid volume %q: %s must be an absolute path in the puck", v, m.Target)
+	}
+
+	source, err := expandHostPath(m.Source, active)
+	if err != nil {
+		return store.Mount{}, fmt.Errorf("invalid volume %q: %w", v, err)
+	}
+	m.Source = source
+	return m, nil
+}
+
+// hostPath resolves a path on the daemon's host given to a flag, as
+// expandHostPath does for the active context
+func hostPath(p string) (string, error) {
+	active, err := config.ActiveContext()
+	if err != nil {
+		return "", err
+	}
+	return expandHostPath(p, active)
+}
+
+// expandHostPath makes a path on this machine absolute, expanding a
+// leading ~, when the context's daemon shares its files. Remote daemons
+// run on Linux, so their paths are checked as slash-separated ones even
+// from Windows, where they'd otherwise need a drive letter.
+func expandHostPath(p string, active config.Context) (string, error) {
+	home := p == "~" || strings.HasPrefix(p, "~/") || strings.HasPrefix(p, "~"+string(filepath.Separator))
+	if active.Type != config.ContextUnix {
+		if home || windowsPath(p) || !path.IsAbs(p) {
+			return "", fmt.Errorf("%s names a path on this machine, but the daemon runs on %s; give an absolute path on its host", p, active.Endpoint())
+		}
+		return path.Clean(p), nil
+	}
+
+	if windowsPath(p) && runtime.GOOS != "windows" {
+		return "", fmt.Errorf("%s is a Windows path, but the daemon runs on this %s machine", p, runtime.GOOS)
+	}
+	if home {
+		dir, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		p = filepath.Join(dir, strings.TrimPrefix(p, "~"))
+	}
+	return filepath.Abs(p)
+}
+
+// windowsDrive reports whether p starts with a drive letter and a colon,
+// as in C:\src or C:/src
+func windowsDrive(p string) bool {
+	return len(p) >= 3 && (p[2] == '\\' || p[2] == '/') && p[1] == ':' &&
+		('a' <= p[0] && p[0] <= 'z' || 'A' <= p[0] && p[0] <= 'Z')
+}
+
+// windowsPath reports whether p is an absolute Windows path: on a drive,
+// or a UNC path such as \\server\share
+func windowsPath(p string) bool {
+	return windowsDrive(p) || strings.HasPrefix(p, `\\`)
+}
@@ -0,0 +1,98 @@
+package cli
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVolume(t *testing.T) {
+	local := config.Context{Name: "default", Type: config.ContextUnix}
+	remote := config.Context{Name: "homelab", Type: config.ContextSSH, Host: "homelab"}
+	// Local host paths are made absolute the way this machine does it
+	abs := func(p string) string {
+		p, err := filepath.Abs(p)
+		require.NoError(t, err)
+		return p
+	}
+	// Windows paths only name this machine's files on Windows
+	windowsOnly := ""
+	if runtime.GOOS != "windows" {
+		windowsOnly = "is a Windows path"
+	}
+
+	for _, tt := range []struct {
+		name    string
+		volume  string
+		context config.Context
+		want    store.Mount
+		err     string // expected error; empty expects want
+	}{
+		{
+			name: "unix path", volume: "/srv/src:/w:ro", context: local,
+			want: store.Mount{Source: abs("/srv/src"), Target: "/w", ReadOnly: true},
+		},
+		{
+			name: "one-letter relative directory", volume: "a:/b", context: local,
+			want: store.Mount{Source: abs("a"), Target: "/b"},
+		},
+		{
+			name: "windows drive with backslashes", volume: `C:\src:/w`, context: local,
+			want: store.Mount{Source: abs(`C:\src`), Target: "/w"},
+			err:  windowsOnly,
+		},
+		{
+			name: "windows drive with slashes", volume: "C:/src:/w:ro", context: local,
+			want: store.Mount{Source: abs("C:/src"), Target: "/w", ReadOnly: true},
+			err:  windowsOnly,
+		},
+		{
+			name: "UNC share", volume: `\\server\share:/d`, context: local,
+			want: store.Mount{Source: abs(`\\server\share`), Target: "/d"},
+			err:  windowsOnly,
+		},
+		{
+			name: "remote unix path", volume: "/srv/src:/w", context: remote,
+			want: store.Mount{Source: "/srv/src", Target: "/w"},
+		},
+		{
+			name: "windows drive on a remote context", volume: `C:\src:/w`, context: remote,
+			err: "names a path on this machine",
+		},
+		{
+			name: "windows drive with slashes on a remote context", volume: "C:/src:/w:ro", context: remote,
+			err: "names a path on this machine",
+		},
+		{
+			name: "UNC share on a remote context", volume: `\\server\share:/d`, context: remote,
+			err: "names a path on this machine",
+		},
+		{
+			name: "relative path on a remote context", volume: "a:/b", context: remote,
+			err: "names a path on this machine",
+		},
+		{
+			name: "missing container path", volume: `C:\src`, context: local,
+			err: "expected host:container",
+		},
+		{
+			name: "unknown option", volume: "C:/src:/w:rx", context: local,
+			err: `unknown option "rx"`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := parseVolume(tt.volume, tt.context)
+			if tt.err != "" {
+				assert.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, m)
+		})
+	}
+}
@@ -3,22 +3,282 @@ package config
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"strings"
 
+	"github.com/docker/go-units"
 	"github.com/spf13/viper"
 )
 
 // Config holds all configuration for puck
 type Config struct {
-	DataDir       string `mapstructure:"data_dir"`
-	PodmanSocket  string `mapstructure:"podman_socket"`
-	DefaultImage  string `mapstructure:"default_image"`
-	IdleTimeout   int    `mapstructure:"idle_timeout"` // minutes
-	DaemonSocket  string `mapstructure:"daemon_socket"`
-	RouterPort    int    `mapstructure:"router_port"`
-	RouterDomain  string `mapstructure:"router_domain"`
-	Tailnet       string `mapstructure:"tailnet"` // optional tailnet name for Tailscale mode
+	DataDir      string `mapstructure:"data_dir"`
+	DatabaseURL  string `mapstructure:"database_url"` // optional postgres:// URL for a shared database
+	PodmanSocket string `mapstructure:"podman_socket"`
+	DefaultImage string `mapstructure:"default_image"`
+	IdleTimeout  int    `mapstructure:"idle_timeout"` // minutes
+	DaemonSocket string `mapstructure:"daemon_socket"`
+	RouterPort   int    `mapstructure:"router_port"`
+	RouterDomain string `mapstructure:"router_domain"`
+	Tailnet      string `mapstructure:"tailnet"` // optional tailnet name for Tailscale mode
+
+	// How the router reaches pucks: RouteHostPort proxies to a port
+	// published on 127.0.0.1 for each puck, RouteContainerIP goes straight
+	// to the container's IP without publishing one, and RouteAuto picks
+	// container IPs where the host can reach them
+	RouteMode string `mapstructure:"route_mode"`
+
+	// How new pucks are given host ports: PortSequential takes the lowest
+	// free one, PortRandom any free one, and PortHash one derived from
+	// the puck's name, so a puck created again gets the same port
+	PortAllocator string `mapstructure:"port_allocator"`
+
+	// RouterEnabled runs the Caddy router. Without it pucks are only
+	// reached on the host ports they publish, and aliases, share links
+	// and tailnet nodes are unavailable.
+	RouterEnabled bool `mapstructure:"router_enabled"`
+
+	// RouterProcess is where Caddy runs: RouterEmbedded in the daemon, or
+	// RouterChild in a process the daemon supervises and restarts, so a
+	// router panic or wedged config doesn't take container management down
+	RouterProcess string `mapstructure:"router_process"`
+
+	// Remote access over TCP with mutual TLS; disabled when DaemonListen
+	// is empty. Client certificate common names are used as user names.
+	DaemonListen   string `mapstructure:"daemon_listen"` // e.g. 0.0.0.0:7443
+	DaemonTLSCert  string `mapstructure:"daemon_tls_cert"`
+	DaemonTLSKey   string `mapstructure:"daemon_tls_key"`
+	DaemonClientCA string `mapstructure:"daemon_client_ca"` // CA that signs client certificates
+
+	// In Tailscale mode the API is also served as its own tailnet node
+	// over HTTPS, with tailnet login names used as user names
+	DaemonTailnet     bool   `mapstructure:"daemon_tailnet"`
+	DaemonTailnetName string `mapstructure:"daemon_tailnet_name"` // the node's hostname
+
+	// Router TLS listener; disabled when RouterTLSPort is zero
+	RouterTLSPort int    `mapstructure:"router_tls_port"`
+	RouterTLSCert string `mapstructure:"router_tls_cert"` // empty uses Caddy's internal CA
+	RouterTLSKey  string `mapstructure:"router_tls_key"`
+	RouterHTTP3   bool   `mapstructure:"router_http3"` // serve HTTP/3 on the TLS port
+	// Public names for the TLS listener, such as *.pucks.example.com, with
+	// certificates from an ACME CA (Let's Encrypt unless RouterACMECA is
+	// set). Challenges are solved with DNS-01 through RouterTLSDNS,
+	// cloudflare or exec, given RouterTLSDNSCredentials.
+	RouterTLSDomains        []string          `mapstructure:"router_tls_domains"`
+	RouterACMEEmail         string            `mapstructure:"router_acme_email"`
+	RouterACMECA            string            `mapstructure:"router_acme_ca"`
+	RouterTLSDNS            string            `mapstructure:"router_tls_dns"`
+	RouterTLSDNSCredentials map[string]string `mapstructure:"router_tls_dns_credentials"`
+
+	// Optional override for the router landing page template
+	LandingTemplate string `mapstructure:"landing_template"`
+
+	// MOTD writes a login banner into each puck when it is created or
+	// started, from MOTDTemplate if that file exists
+	MOTD         bool   `mapstructure:"motd"`
+	MOTDTemplate string `mapstructure:"motd_template"`
+
+	// Labels are added to every container puck creates, for monitoring
+	// and policy tools to pick puck containers out by. Values are Go
+	// templates over LabelData, e.g. owner={{.User}} or
+	// project={{.Cwd | base}}.
+	Labels map[string]string `mapstructure:"labels"`
+
+	// Rules giving new pucks an image, console shell and exec user by
+	// profile, project or label, e.g. ubuntu:24.04 and /bin/zsh for the
+	// infra project. Later rules win where several match.
+	Defaults []DefaultsRule `mapstructure:"defaults"`
+
+	// How long a new puck's app gets to answer HTTP on its port before it
+	// is routed anyway
+	ReadyTimeout int `mapstructure:"ready_timeout"` // seconds
+
+	// How long a stopping puck gets to exit before it is killed; pucks
+	// and puck stop --timeout can override it
+	StopTimeout int `mapstructure:"stop_timeout"` // seconds
+
+	// puck destroy refuses, without --force, pucks used in this many
+	// minutes, run since their last snapshot or required by other pucks;
+	// zero turns the check off
+	DestroyGuard int `mapstructure:"destroy_guard"`
+
+	// Executables run on daemon events, and how long each may take
+	HooksDir    string `mapstructure:"hooks_dir"`
+	HookTimeout int    `mapstructure:"hook_timeout"` // seconds
+
+	// Users who may see and manage every user's pucks, in addition to
+	// root and the user running the daemon
+	Admins []string `mapstructure:"admins"`
+
+	// Public base URL for share links, e.g. a Tailscale Funnel URL in
+	// front of SharePort
+	ShareURL string `mapstructure:"share_url"`
+	ShareTTL int    `mapstructure:"share_ttl"` // minutes
+	// Loopback port of a router listener serving share links and nothing
+	// else, for share_url to point at; zero disables it
+	SharePort int `mapstructure:"share_port"`
+	// Without share_url, a tunnel the daemon opens in front of the share
+	// listener, while there are links, gives them a public URL: funnel
+	// (Tailscale Funnel, as its own node), cloudflared, localtunnel, or
+	// command, which runs ShareTunnelCommand and takes the first https URL
+	// it prints
+	ShareTunnel        string `mapstructure:"share_tunnel"`
+	ShareTunnelCommand string `mapstructure:"share_tunnel_command"`
+
+	// Checkpoint running pucks before recreating them so they can be
+	// rolled back
+	SnapshotBeforeRecreate bool `mapstructure:"snapshot_before_recreate"`
+
+	// Step the clock and re-arm timers in pucks restored from a
+	// checkpoint, before their own post-restore hook runs
+	RestoreClockSync bool `mapstructure:"restore_clock_sync"`
+
+	// How snapshots are taken unless chosen per snapshot: "checkpoint"
+	// (CRIU) or "image" (podman commit plus a volume archive)
+	SnapshotMode string `mapstructure:"snapshot_mode"`
+
+	// CRIU options for checkpoints unless chosen per snapshot. Restoring
+	// established TCP connections fails once their peers have gone.
+	CheckpointTCPEstablished bool `mapstructure:"checkpoint_tcp_established"`
+	CheckpointFileLocks      bool `mapstructure:"checkpoint_file_locks"`
+
+	// Move snapshot archives older than SnapshotTierAfter days to
+	// SnapshotTierDir, e.g. an external drive or a mounted remote, and
+	// fetch them back when restored; tiering is off while it is empty
+	SnapshotTierDir   string `mapstructure:"snapshot_tier_dir"`
+	SnapshotTierAfter int    `mapstructure:"snapshot_tier_after"` // days
+
+	// Keep checkpoints as deduplicated chunks shared between snapshots,
+	// rather than one compressed archive each
+	SnapshotDedup bool `mapstructure:"snapshot_dedup"`
+
+	// Total limits reserved by running pucks; zero disables a budget.
+	// BudgetPolicy decides what happens when a start would exceed them:
+	// "refuse" it, or "checkpoint" the least recently used pucks first.
+	BudgetMemory int64   `mapstructure:"budget_memory"` // bytes; configured with units, e.g. 8g
+	BudgetCPUs   float64 `mapstructure:"budget_cpus"`
+	BudgetPolicy string  `mapstructure:"budget_policy"`
+
+	// Limits for the pucks in each project, by project name
+	Projects map[string]ProjectLimits `mapstructure:"-"`
+
+	// Checkpoint the least recently used puck while host memory pressure
+	// (PSI "some" avg10, in percent) is at or above this; zero disables it
+	MemoryPressure float64 `mapstructure:"memory_pressure"`
+
+	// Inbound webhook listener; disabled when WebhookListen is empty
+	WebhookListen string                   `mapstructure:"webhook_listen"` // e.g. 127.0.0.1:8090
+	WebhookSecret string                   `mapstructure:"webhook_secret"`
+	Webhooks      map[string]WebhookConfig `mapstructure:"webhooks"`
+
+	// Images kept pulled so pucks are created from them without waiting
+	// on a pull. Missing ones are pulled as soon as the daemon can, and
+	// all of them again once a day during WarmWindow, a span of local
+	// time such as "02:00-06:00".
+	WarmImages []string `mapstructure:"warm_images"`
+	WarmWindow string   `mapstructure:"warm_window"`
+
+	// Vulnerability scanning with puck scan. Scanner is trivy or grype on
+	// the host, or container to run ScanImage (Trivy) under podman; empty
+	// picks whichever is installed. With ScanOnCreate set, new pucks'
+	// images are scanned first, and findings at ScanSeverity or worse
+	// "warn" or "refuse" the create.
+	Scanner      string `mapstructure:"scanner"`
+	ScanImage    string `mapstructure:"scan_image"`
+	ScanOnCreate string `mapstructure:"scan_on_create"`
+	ScanSeverity string `mapstructure:"scan_severity"`
+
+	// Host directories mounted into every new puck, managed with
+	// puck config shares
+	SharedPaths []SharedPath `mapstructure:"shared_paths"`
+
+	// The Podman Machine pucks run in on macOS and Windows; empty uses
+	// the default machine and "none" skips checking mounts against its
+	// shares
+	Machine string `mapstructure:"machine"`
+	// The driver suggested for new shares: "virtiofs" or "9p"
+	MachineVolumeDriver string `mapstructure:"machine_volume_driver"`
+	// Sync mounts whose source isn't shared into the machine, caching a
+	// copy in the puck's volume, rather than refusing them
+	MachineSyncUnshared bool `mapstructure:"machine_sync_unshared"`
+
+	// Put puck-agent in every running puck, to report its ports, services
+	// and readiness. AgentBinary is the Linux build to put there; empty
+	// looks next to puckd, then on PATH.
+	Agent       bool   `mapstructure:"agent"`
+	AgentBinary string `mapstructure:"agent_binary"`
+	// Probe running pucks without an agent for the port their app listens
+	// on, through podman exec, to route to it
+	DetectAppPort bool `mapstructure:"detect_app_port"`
+
+	// The config file these settings were read from, or the default one
+	ConfigFile string `mapstructure:"-"`
+}
+
+// Route modes
+const (
+	RouteAuto        = "auto"
+	RouteHostPort    = "host-port"
+	RouteContainerIP = "container-ip"
+)
+
+// Port allocators
+const (
+	PortSequential = "sequential"
+	PortRandom     = "random"
+	PortHash       = "hash"
+)
+
+// Router processes
+const (
+	RouterEmbedded = "embedded"
+	RouterChild    = "child"
+)
+
+// MaxStopTimeout bounds stop timeouts, in seconds, so a stop always
+// finishes within the daemon's limit for the request
+const MaxStopTimeout = 300
+
+// ProjectLimits cap the pucks labelled with a project; zero leaves a
+// limit off
+type ProjectLimits struct {
+	MaxPucks   int   `json:"max_pucks,omitempty"`
+	MaxRunning int   `json:"max_running,omitempty"`
+	MaxDisk    int64 `json:"max_disk,omitempty"` // bytes of puck data and snapshots; configured with units, e.g. 20g
+}
+
+// Podman Machine volume drivers
+const (
+	MachineVirtiofs = "virtiofs"
+	Machine9p       = "9p"
+)
+
+// MachineNone turns off checking mounts against Podman Machine shares
+const MachineNone = "none"
+
+// Budget policies
+const (
+	BudgetRefuse     = "refuse"
+	BudgetCheckpoint = "checkpoint"
+)
+
+// Scan policies for new pucks
+const (
+	ScanWarn   = "warn"
+	ScanRefuse = "refuse"
+)
+
+// Webhook actions that can be triggered remotely
+var WebhookActions = []string{"recreate", "restart", "start", "stop"}
+
+// WebhookConfig is a predefined action triggered by POST /webhooks/<name>
+type WebhookConfig struct {
+	Action string `mapstructure:"action"`
+	Puck   string `mapstructure:"puck"`
+	Image  string `mapstructure:"image"` // recreate only; empty keeps the puck's image
 }
 
 // Default returns the default configuration
@@ -28,10 +288,48 @@ func Default() *Config {
 		PodmanSocket: defaultPodmanSocket(),
 		DefaultImage: "fedora:latest",
 		IdleTimeout:  15,
+		DestroyGuard: 30,
 		DaemonSocket: defaultDaemonSocket(),
 		RouterPort:   8080,
 		RouterDomain: "localhost",
 		Tailnet:      "", // empty = disabled
+		RouteMode:    RouteAuto,
+
+		PortAllocator: PortSequential,
+
+		RouterEnabled: true,
+		RouterProcess: RouterEmbedded,
+
+		DaemonTailnet:     true,
+		DaemonTailnetName: "puck-api",
+
+		LandingTemplate: defaultLandingTemplate(),
+
+		MOTD:         true,
+		MOTDTemplate: defaultMOTDTemplate(),
+
+		ReadyTimeout: 60,
+		StopTimeout:  10,
+
+		HooksDir:    defaultHooksDir(),
+		HookTimeout: 10,
+
+		ShareTTL: 60,
+
+		SnapshotBeforeRecreate: true,
+		SnapshotMode:           "checkpoint",
+		SnapshotTierAfter:      30,
+		RestoreClockSync:       true,
+
+		CheckpointTCPEstablished: true,
+
+		BudgetPolicy: BudgetRefuse,
+
+		WarmWindow: "02:00-06:00",
+
+		ScanSeverity: "critical",
+
+		DetectAppPort: true,
 	}
 }
 
@@ -47,6 +345,9 @@ func Load() (*Config, error) {
 	if v := viper.GetString("data_dir"); v != "" {
 		cfg.DataDir = v
 	}
+	if v := viper.GetString("database_url"); v != "" {
+		cfg.DatabaseURL = v
+	}
 	if v := viper.GetString("podman_socket"); v != "" {
 		cfg.PodmanSocket = v
 	}
@@ -68,6 +369,312 @@ func Load() (*Config, error) {
 	if v := viper.GetString("tailnet"); v != "" {
 		cfg.Tailnet = v
 	}
+	if v := viper.GetString("route_mode"); v != "" {
+		cfg.RouteMode = v
+	}
+	if v := viper.GetString("port_allocator"); v != "" {
+		cfg.PortAllocator = v
+	}
+	if viper.IsSet("router_enabled") {
+		cfg.RouterEnabled = viper.GetBool("router_enabled")
+	}
+	if v := viper.GetString("router_process"); v != "" {
+		cfg.RouterProcess = v
+	}
+	if v := viper.GetString("daemon_listen"); v != "" {
+		cfg.DaemonListen = v
+	}
+	if v := viper.GetString("daemon_tls_cert"); v != "" {
+		cfg.DaemonTLSCert = v
+	}
+	if v := viper.GetString("daemon_tls_key"); v != "" {
+		cfg.DaemonTLSKey = v
+	}
+	if v := viper.GetString("daemon_client_ca"); v != "" {
+		cfg.DaemonClientCA = v
+	}
+	if viper.IsSet("daemon_tailnet") {
+		cfg.DaemonTailnet = viper.GetBool("daemon_tailnet")
+	}
+	if v := viper.GetString("daemon_tailnet_name"); v != "" {
+		cfg.DaemonTailnetName = v
+	}
+	if v := viper.GetInt("router_tls_port"); v > 0 {
+		cfg.RouterTLSPort = v
+	}
+	if v := viper.GetString("router_tls_cert"); v != "" {
+		cfg.RouterTLSCert = v
+	}
+	if v := viper.GetString("router_tls_key"); v != "" {
+		cfg.RouterTLSKey = v
+	}
+	if viper.GetBool("router_http3") {
+		cfg.RouterHTTP3 = true
+	}
+	if v := viper.GetStringSlice("router_tls_domains"); len(v) > 0 {
+		cfg.RouterTLSDomains = v
+	}
+	if v := viper.GetString("router_acme_email"); v != "" {
+		cfg.RouterACMEEmail = v
+	}
+	if v := viper.GetString("router_acme_ca"); v != "" {
+		cfg.RouterACMECA = v
+	}
+	if v := viper.GetString("router_tls_dns"); v != "" {
+		cfg.RouterTLSDNS = v
+	}
+	if v := viper.GetStringMapString("router_tls_dns_credentials"); len(v) > 0 {
+		cfg.RouterTLSDNSCredentials = v
+	}
+	if v := viper.GetString("landing_template"); v != "" {
+		cfg.LandingTemplate = v
+	}
+	if viper.IsSet("motd") {
+		cfg.MOTD = viper.GetBool("motd")
+	}
+	if v := viper.GetString("motd_template"); v != "" {
+		cfg.MOTDTemplate = v
+	}
+	if v := viper.GetStringMapString("labels"); len(v) > 0 {
+		cfg.Labels = v
+	}
+	if v := viper.GetInt("ready_timeout"); v > 0 {
+		cfg.ReadyTimeout = v
+	}
+	if viper.IsSet("stop_timeout") {
+		cfg.StopTimeout = viper.GetInt("stop_timeout")
+	}
+	if viper.IsSet("destroy_guard") {
+		cfg.DestroyGuard = viper.GetInt("destroy_guard")
+	}
+	if v := viper.GetString("hooks_dir"); v != "" {
+		cfg.HooksDir = v
+	}
+	if v := viper.GetInt("hook_timeout"); v > 0 {
+		cfg.HookTimeout = v
+	}
+	if v := viper.GetString("share_url"); v != "" {
+		cfg.ShareURL = strings.TrimSuffix(v, "/")
+	}
+	if v := viper.GetInt("share_ttl"); v > 0 {
+		cfg.ShareTTL = v
+	}
+	if v := viper.GetInt("share_port"); v > 0 {
+		cfg.SharePort = v
+	}
+	if v := viper.GetString("share_tunnel"); v != "" {
+		cfg.ShareTunnel = v
+	}
+	if v := viper.GetString("share_tunnel_command"); v != "" {
+		cfg.ShareTunnelCommand = v
+	}
+	if viper.IsSet("snapshot_before_recreate") {
+		cfg.SnapshotBeforeRecreate = viper.GetBool("snapshot_before_recreate")
+	}
+	if viper.IsSet("restore_clock_sync") {
+		cfg.RestoreClockSync = viper.GetBool("restore_clock_sync")
+	}
+	if v := viper.GetString("snapshot_mode"); v != "" {
+		cfg.SnapshotMode = v
+	}
+	if viper.IsSet("checkpoint_tcp_established") {
+		cfg.CheckpointTCPEstablished = viper.GetBool("checkpoint_tcp_established")
+	}
+	if viper.IsSet("checkpoint_file_locks") {
+		cfg.CheckpointFileLocks = viper.GetBool("checkpoint_file_locks")
+	}
+	if v := viper.GetString("snapshot_tier_dir"); v != "" {
+		cfg.SnapshotTierDir = v
+	}
+	if viper.IsSet("snapshot_tier_after") {
+		cfg.SnapshotTierAfter = viper.GetInt("snapshot_tier_after")
+	}
+	if viper.IsSet("snapshot_dedup") {
+		cfg.SnapshotDedup = viper.GetBool("snapshot_dedup")
+	}
+	if v := viper.GetString("budget_memory"); v != "" && v != "0" {
+		bytes, err := units.RAMInBytes(v)
+		if err != nil {
+			return nil, fmt.Errorf("parsing budget_memory: %w", err)
+		}
+		cfg.BudgetMemory = bytes
+	}
+	if v := viper.GetFloat64("budget_cpus"); v > 0 {
+		cfg.BudgetCPUs = v
+	}
+	if v := viper.GetString("budget_policy"); v != "" {
+		cfg.BudgetPolicy = v
+	}
+	if v := viper.GetFloat64("memory_pressure"); v > 0 {
+		cfg.MemoryPressure = v
+	}
+	if v := viper.GetString("webhook_listen"); v != "" {
+		cfg.WebhookListen = v
+	}
+	if v := viper.GetString("webhook_secret"); v != "" {
+		cfg.WebhookSecret = v
+	}
+	if v := viper.GetString("machine"); v != "" {
+		cfg.Machine = v
+	}
+	if v := viper.GetString("machine_volume_driver"); v != "" {
+		cfg.MachineVolumeDriver = v
+	}
+	if viper.GetBool("machine_sync_unshared") {
+		cfg.MachineSyncUnshared = true
+	}
+	if viper.GetBool("agent") {
+		cfg.Agent = true
+	}
+	if v := viper.GetString("agent_binary"); v != "" {
+		cfg.AgentBinary = v
+	}
+	if viper.IsSet("detect_app_port") {
+		cfg.DetectAppPort = viper.GetBool("detect_app_port")
+	}
+	if v := viper.GetStringSlice("admins"); len(v) > 0 {
+		cfg.Admins = v
+	}
+	if v := viper.GetStringSlice("warm_images"); len(v) > 0 {
+		cfg.WarmImages = v
+	}
+	if v := viper.GetString("warm_window"); v != "" {
+		cfg.WarmWindow = v
+	}
+	if v := viper.GetString("scanner"); v != "" {
+		cfg.Scanner = v
+	}
+	if v := viper.GetString("scan_image"); v != "" {
+		cfg.ScanImage = v
+	}
+	if v := viper.GetString("scan_on_create"); v != "" {
+		cfg.ScanOnCreate = v
+	}
+	if v := viper.GetString("scan_severity"); v != "" {
+		cfg.ScanSeverity = strings.ToLower(v)
+	}
+	projects, err := loadProjects()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Projects = projects
+	if err := viper.UnmarshalKey("webhooks", &cfg.Webhooks); err != nil {
+		return nil, fmt.Errorf("parsing webhooks: %w", err)
+	}
+	if err := cfg.validateWebhooks(); err != nil {
+		return nil, err
+	}
+	if err := viper.UnmarshalKey("defaults", &cfg.Defaults); err != nil {
+		return nil, fmt.Errorf("parsing defaults: %w", err)
+	}
+	if err := cfg.validateDefaults(); err != nil {
+		return nil, err
+	}
+	if err := viper.UnmarshalKey(sharedPathsKey, &cfg.SharedPaths); err != nil {
+		return nil, fmt.Errorf("parsing shared_paths: %w", err)
+	}
+	shared, err := normalizeSharedPaths(cfg.SharedPaths)
+	if err != nil {
+		return nil, err
+	}
+	cfg.SharedPaths = shared
+	cfg.ConfigFile = viper.ConfigFileUsed()
+	if cfg.ConfigFile == "" {
+		// puck config shares may create it later
+		cfg.ConfigFile = ConfigPath()
+	}
+
+	if cfg.DaemonListen != "" && (cfg.DaemonTLSCert == "" || cfg.DaemonTLSKey == "" || cfg.DaemonClientCA == "") {
+		return nil, fmt.Errorf("daemon_listen requires daemon_tls_cert, daemon_tls_key and daemon_client_ca")
+	}
+
+	if cfg.SnapshotMode != "checkpoint" && cfg.SnapshotMode != "image" {
+		return nil, fmt.Errorf("snapshot_mode must be checkpoint or image, got %q", cfg.SnapshotMode)
+	}
+
+	if cfg.SnapshotTierDir != "" && !filepath.IsAbs(cfg.SnapshotTierDir) {
+		return nil, fmt.Errorf("snapshot_tier_dir must be an absolute path, got %q", cfg.SnapshotTierDir)
+	}
+	if cfg.SnapshotTierAfter < 1 {
+		return nil, fmt.Errorf("snapshot_tier_after must be at least 1 day, got %d", cfg.SnapshotTierAfter)
+	}
+
+	if cfg.RouteMode != RouteAuto && cfg.RouteMode != RouteHostPort && cfg.RouteMode != RouteContainerIP {
+		return nil, fmt.Errorf("route_mode must be auto, host-port or container-ip, got %q", cfg.RouteMode)
+	}
+
+	if cfg.PortAllocator != PortSequential && cfg.PortAllocator != PortRandom && cfg.PortAllocator != PortHash {
+		return nil, fmt.Errorf("port_allocator must be sequential, random or hash, got %q", cfg.PortAllocator)
+	}
+
+	if cfg.RouterProcess != RouterEmbedded && cfg.RouterProcess != RouterChild {
+		return nil, fmt.Errorf("router_process must be embedded or child, got %q", cfg.RouterProcess)
+	}
+
+	if cfg.DatabaseURL != "" && !strings.HasPrefix(cfg.DatabaseURL, "postgres://") && !strings.HasPrefix(cfg.DatabaseURL, "postgresql://") {
+		return nil, fmt.Errorf("database_url must be a postgres:// or postgresql:// URL")
+	}
+
+	if cfg.StopTimeout < 0 || cfg.StopTimeout > MaxStopTimeout {
+		return nil, fmt.Errorf("stop_timeout must be between 0 and %d seconds, got %d", MaxStopTimeout, cfg.StopTimeout)
+	}
+
+	if cfg.BudgetPolicy != BudgetRefuse && cfg.BudgetPolicy != BudgetCheckpoint {
+		return nil, fmt.Errorf("budget_policy must be refuse or checkpoint, got %q", cfg.BudgetPolicy)
+	}
+
+	if cfg.MemoryPressure > 100 {
+		return nil, fmt.Errorf("memory_pressure is a percentage, got %g", cfg.MemoryPressure)
+	}
+
+	if _, err := ParseTimeWindow(cfg.WarmWindow); err != nil {
+		return nil, fmt.Errorf("warm_window: %w", err)
+	}
+
+	if cfg.SharePort > 0 && (cfg.SharePort == cfg.RouterPort || cfg.SharePort == cfg.RouterTLSPort) {
+		return nil, fmt.Errorf("share_port must differ from the router's ports, got %d", cfg.SharePort)
+	}
+	if cfg.ShareTunnel != "" && !slices.Contains([]string{"funnel", "cloudflared", "localtunnel", "command"}, cfg.ShareTunnel) {
+		return nil, fmt.Errorf("share_tunnel must be funnel, cloudflared, localtunnel or command, got %q", cfg.ShareTunnel)
+	}
+	if cfg.ShareTunnel == "command" && cfg.ShareTunnelCommand == "" {
+		return nil, fmt.Errorf("share_tunnel command needs share_tunnel_command")
+	}
+
+	if cfg.Scanner != "" && !slices.Contains([]string{"trivy", "grype", "container"}, cfg.Scanner) {
+		return nil, fmt.Errorf("scanner must be trivy, grype or container, got %q", cfg.Scanner)
+	}
+	if cfg.ScanOnCreate != "" && cfg.ScanOnCreate != ScanWarn && cfg.ScanOnCreate != ScanRefuse {
+		return nil, fmt.Errorf("scan_on_create must be warn or refuse, got %q", cfg.ScanOnCreate)
+	}
+	if !slices.Contains([]string{"critical", "high", "medium", "low"}, cfg.ScanSeverity) {
+		return nil, fmt.Errorf("scan_severity must be critical, high, medium or low, got %q", cfg.ScanSeverity)
+	}
+
+	if cfg.MachineVolumeDriver != "" && cfg.MachineVolumeDriver != MachineVirtiofs && cfg.MachineVolumeDriver != Machine9p {
+		return nil, fmt.Errorf("machine_volume_driver must be virtiofs or 9p, got %q", cfg.MachineVolumeDriver)
+	}
+
+	// Without the router, pucks are only reached through their host ports
+	if !cfg.RouterEnabled {
+		if cfg.RouteMode == RouteContainerIP {
+			return nil, fmt.Errorf("route_mode container-ip needs the router; set router_enabled: true or route_mode: host-port")
+		}
+		cfg.RouteMode = RouteHostPort
+	}
+
+	if (cfg.RouterTLSCert == "") != (cfg.RouterTLSKey == "") {
+		return nil, fmt.Errorf("router_tls_cert and router_tls_key must be set together")
+	}
+	if err := cfg.validateTLSDomains(); err != nil {
+		return nil, err
+	}
+
+	// Catch bad templates, and fields LabelData doesn't have, before
+	// they fail a create
+	if _, err := cfg.RenderLabels(LabelData{}); err != nil {
+		return nil, err
+	}
 
 	// Ensure data directory exists
 	if err := os.MkdirAll(cfg.DataDir, 0755); err != nil {
@@ -77,6 +684,79 @@ func Load() (*Config, error) {
 	return cfg, nil
 }
 
+// loadProjects reads project limits, parsing disk sizes with units
+func loadProjects() (map[string]ProjectLimits, error) {
+	var raw map[string]struct {
+		MaxPucks   int    `mapstructure:"max_pucks"`
+		MaxRunning int    `mapstructure:"max_running"`
+		MaxDisk    string `mapstructure:"max_disk"`
+	}
+	if err := viper.UnmarshalKey("projects", &raw); err != nil {
+		return nil, fmt.Errorf("parsing projects: %w", err)
+	}
+
+	projects := make(map[string]ProjectLimits, len(raw))
+	for name, r := range raw {
+		if r.MaxPucks < 0 || r.MaxRunning < 0 {
+			return nil, fmt.Errorf("project %q: limits cannot be negative", name)
+		}
+		limits := ProjectLimits{MaxPucks: r.MaxPucks, MaxRunning: r.MaxRunning}
+		if r.MaxDisk != "" && r.MaxDisk != "0" {
+			bytes, err := units.RAMInBytes(r.MaxDisk)
+			if err != nil {
+				return nil, fmt.Errorf("project %q: parsing max_disk: %w", name, err)
+			}
+			limits.MaxDisk = bytes
+		}
+		projects[name] = limits
+	}
+	return projects, nil
+}
+
+func (c *Config) validateWebhooks() error {
+	if c.WebhookListen != "" && c.WebhookSecret == "" {
+		return fmt.Errorf("webhook_secret is required when webhook_listen is set")
+	}
+	for name, wh := range c.Webhooks {
+		if wh.Puck == "" {
+			return fmt.Errorf("webhook %q: puck is required", name)
+		}
+		if !slices.Contains(WebhookActions, wh.Action) {
+			return fmt.Errorf("webhook %q: unknown action %q (valid: %s)", name, wh.Action, strings.Join(WebhookActions, ", "))
+		}
+	}
+	return nil
+}
+
+// validateTLSDomains checks the router's public names have a DNS provider
+// with the credentials it needs
+func (c *Config) validateTLSDomains() error {
+	if len(c.RouterTLSDomains) == 0 {
+		return nil
+	}
+	if c.RouterTLSPort == 0 {
+		return fmt.Errorf("router_tls_domains needs router_tls_port")
+	}
+	if c.RouterTLSCert != "" {
+		return fmt.Errorf("router_tls_domains can't be used with router_tls_cert")
+	}
+	switch c.RouterTLSDNS {
+	case "cloudflare":
+		if c.RouterTLSDNSCredentials["api_token"] == "" {
+			return fmt.Errorf("router_tls_dns cloudflare needs api_token in router_tls_dns_credentials")
+		}
+	case "exec":
+		if c.RouterTLSDNSCredentials["command"] == "" {
+			return fmt.Errorf("router_tls_dns exec needs command in router_tls_dns_credentials")
+		}
+	case "":
+		return fmt.Errorf("router_tls_domains needs router_tls_dns to solve DNS-01 challenges")
+	default:
+		return fmt.Errorf("router_tls_dns must be cloudflare or exec, got %q", c.RouterTLSDNS)
+	}
+	return nil
+}
+
 func defaultDataDir() string {
 	if xdg := os.Getenv("XDG_DATA_HOME"); xdg != "" {
 		return filepath.Join(xdg, "puck")
@@ -85,6 +765,9 @@ func defaultDataDir() string {
 	return filepath.Join(home, ".local", "share", "puck")
 }
 
+// rootfulPodmanSocket is the system-wide Podman service
+const rootfulPodmanSocket = "unix:///run/podman/podman.sock"
+
 func defaultPodmanSocket() string {
 	switch runtime.GOOS {
 	case "linux":
@@ -97,7 +780,7 @@ func defaultPodmanSocket() string {
 			}
 		}
 		// Fall back to rootful
-		return "unix:///run/podman/podman.sock"
+		return rootfulPodmanSocket
 
 	case "darwin", "windows":
 		// Use Podman Machine
@@ -114,6 +797,75 @@ func defaultDaemonSocket() string {
 	return filepath.Join(dataDir, "puckd.sock")
 }
 
+func defaultLandingTemplate() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "puck", "landing.html")
+}
+
+func defaultMOTDTemplate() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "puck", "motd.tmpl")
+}
+
+func defaultHooksDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "puck", "hooks.d")
+}
+
+// RoutesToContainerIP reports whether the router reaches pucks at their
+// container IPs. In auto mode that is only the case for rootful Podman on
+// Linux; rootless containers and Podman Machine VMs live in network
+// namespaces the host can't route to.
+func (c *Config) RoutesToContainerIP() bool {
+	switch c.RouteMode {
+	case RouteContainerIP:
+		return true
+	case RouteAuto:
+		return runtime.GOOS == "linux" && c.PodmanSocket == rootfulPodmanSocket
+	}
+	return false
+}
+
+// AgentBinaryPath finds the puck-agent binary to put in pucks
+func (c *Config) AgentBinaryPath() (string, error) {
+	if c.AgentBinary != "" {
+		return c.AgentBinary, nil
+	}
+	if exe, err := os.Executable(); err == nil {
+		path := filepath.Join(filepath.Dir(exe), "puck-agent")
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	path, err := exec.LookPath("puck-agent")
+	if err != nil {
+		return "", fmt.Errorf("puck-agent not found next to puckd or on PATH; set agent_binary")
+	}
+	return path, nil
+}
+
+// ServesTailnetAPI reports whether the daemon API gets its own tailnet node
+func (c *Config) ServesTailnetAPI() bool {
+	return c.Tailnet != "" && c.DaemonTailnet && c.DaemonTailnetName != ""
+}
+
+// TailnetAPIAddress returns the HTTPS address of the daemon's tailnet node
+func (c *Config) TailnetAPIAddress() string {
+	return c.DaemonTailnetName + "." + c.Tailnet + ":443"
+}
+
+// TailscaleDir returns where tailnet nodes keep their state: the API's
+// node under its name, and the router's nodes under caddy
+func (c *Config) TailscaleDir() string {
+	return filepath.Join(c.DataDir, "tailscale")
+}
+
+// RouterTailscaleDir returns where the router's tailnet nodes keep their
+// state
+func (c *Config) RouterTailscaleDir() string {
+	return filepath.Join(c.TailscaleDir(), "caddy")
+}
+
 // PucksDir returns the directory for puck data
 func (c *Config) PucksDir() string {
 	return filepath.Join(c.DataDir, "pucks")
@@ -124,7 +876,46 @@ func (c *Config) SnapshotsDir() string {
 	return filepath.Join(c.DataDir, "snapshots")
 }
 
+// ExpiredDir returns the directory for the final snapshots of pucks
+// destroyed when their TTL ran out
+func (c *Config) ExpiredDir() string {
+	return filepath.Join(c.DataDir, "expired")
+}
+
+// ShareKeyPath returns the path to the key that signs share links
+func (c *Config) ShareKeyPath() string {
+	return filepath.Join(c.DataDir, "share.key")
+}
+
+// ShareBaseURL returns the public URL share links are served from, or an
+// empty string if share_url isn't set. The TLS listener serves every
+// puck, so it is never the default; the daemon uses a share tunnel's URL.
+func (c *Config) ShareBaseURL() string {
+	return c.ShareURL
+}
+
+// DaemonLogPath returns the log file the daemon writes when it isn't run
+// by systemd
+func (c *Config) DaemonLogPath() string {
+	return filepath.Join(c.DataDir, "puckd.log")
+}
+
+// MaintenancePath returns where the daemon keeps its maintenance state,
+// so it lasts across restarts
+func (c *Config) MaintenancePath() string {
+	return filepath.Join(c.DataDir, "maintenance.json")
+}
+
 // DatabasePath returns the path to the SQLite database
 func (c *Config) DatabasePath() string {
 	return filepath.Join(c.DataDir, "puck.db")
 }
+
+// DatabaseDSN returns what to open the store with: database_url if set,
+// otherwise the SQLite database under the data directory
+func (c *Config) DatabaseDSN() string {
+	if c.DatabaseURL != "" {
+		return c.DatabaseURL
+	}
+	return c.DatabasePath()
+}
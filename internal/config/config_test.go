@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 
 	"github.com/spf13/viper"
@@ -29,8 +30,24 @@ func TestDefault(t *testing.T) {
 		assert.Equal(t, "localhost", cfg.RouterDomain)
 	})
 
+	t.Run("router is enabled by default", func(t *testing.T) {
+		assert.True(t, cfg.RouterEnabled)
+		assert.Equal(t, RouterEmbedded, cfg.RouterProcess)
+	})
+
 	t.Run("tailnet is disabled by default", func(t *testing.T) {
 		assert.Empty(t, cfg.Tailnet)
+		assert.False(t, cfg.ServesTailnetAPI())
+	})
+
+	t.Run("serves the API on the tailnet in Tailscale mode", func(t *testing.T) {
+		c := Default()
+		c.Tailnet = "tail1234.ts.net"
+		assert.True(t, c.ServesTailnetAPI())
+		assert.Equal(t, "puck-api.tail1234.ts.net:443", c.TailnetAPIAddress())
+
+		c.DaemonTailnet = false
+		assert.False(t, c.ServesTailnetAPI())
 	})
 
 	t.Run("data dir is not empty", func(t *testing.T) {
@@ -40,6 +57,17 @@ func TestDefault(t *testing.T) {
 	t.Run("daemon socket is not empty", func(t *testing.T) {
 		assert.NotEmpty(t, cfg.DaemonSocket)
 	})
+
+	t.Run("snapshots before recreate by default", func(t *testing.T) {
+		assert.True(t, cfg.SnapshotBeforeRecreate)
+	})
+
+	t.Run("uses CRIU checkpoints by default", func(t *testing.T) {
+		assert.Equal(t, "checkpoint", cfg.SnapshotMode)
+		assert.Equal(t, 30, cfg.SnapshotTierAfter)
+		assert.True(t, cfg.CheckpointTCPEstablished)
+		assert.False(t, cfg.CheckpointFileLocks)
+	})
 }
 
 func TestLoad(t *testing.T) {
@@ -128,6 +156,453 @@ func TestLoad(t *testing.T) {
 		assert.Equal(t, "myapp.local", cfg.RouterDomain)
 		assert.Equal(t, "my-tailnet", cfg.Tailnet)
 	})
+
+	t.Run("applies router TLS settings", func(t *testing.T) {
+		cleanup()
+		defer cleanup()
+
+		dir, err := os.MkdirTemp("", "puck-test-*")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		viper.Set("data_dir", dir)
+		viper.Set("router_tls_port", 8443)
+		viper.Set("router_tls_cert", "/certs/puck.pem")
+		viper.Set("router_tls_key", "/certs/puck-key.pem")
+		viper.Set("router_http3", true)
+
+		cfg, err := Load()
+		require.NoError(t, err)
+		assert.Equal(t, 8443, cfg.RouterTLSPort)
+		assert.Equal(t, "/certs/puck.pem", cfg.RouterTLSCert)
+		assert.Equal(t, "/certs/puck-key.pem", cfg.RouterTLSKey)
+		assert.True(t, cfg.RouterHTTP3)
+	})
+
+	t.Run("can disable snapshots before recreate", func(t *testing.T) {
+		cleanup()
+		defer cleanup()
+
+		dir, err := os.MkdirTemp("", "puck-test-*")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		viper.Set("data_dir", dir)
+		viper.Set("snapshot_before_recreate", false)
+
+		cfg, err := Load()
+		require.NoError(t, err)
+		assert.False(t, cfg.SnapshotBeforeRecreate)
+	})
+
+	t.Run("applies resource budget", func(t *testing.T) {
+		cleanup()
+		defer cleanup()
+
+		dir, err := os.MkdirTemp("", "puck-test-*")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		viper.Set("data_dir", dir)
+		viper.Set("budget_memory", "8g")
+		viper.Set("budget_cpus", 4)
+		viper.Set("budget_policy", "checkpoint")
+
+		cfg, err := Load()
+		require.NoError(t, err)
+		assert.Equal(t, int64(8<<30), cfg.BudgetMemory)
+		assert.Equal(t, 4.0, cfg.BudgetCPUs)
+		assert.Equal(t, BudgetCheckpoint, cfg.BudgetPolicy)
+	})
+
+	t.Run("applies project limits", func(t *testing.T) {
+		cleanup()
+		defer cleanup()
+
+		dir, err := os.MkdirTemp("", "puck-test-*")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		viper.Set("data_dir", dir)
+		viper.Set("projects", map[string]interface{}{
+			"demo": map[string]interface{}{"max_pucks": 5, "max_running": 2, "max_disk": "20g"},
+		})
+
+		cfg, err := Load()
+		require.NoError(t, err)
+		assert.Equal(t, map[string]ProjectLimits{"demo": {MaxPucks: 5, MaxRunning: 2, MaxDisk: 20 << 30}}, cfg.Projects)
+
+		viper.Set("projects", map[string]interface{}{
+			"demo": map[string]interface{}{"max_disk": "lots"},
+		})
+		_, err = Load()
+		assert.ErrorContains(t, err, "max_disk")
+	})
+
+	t.Run("rejects unknown budget policies", func(t *testing.T) {
+		cleanup()
+		defer cleanup()
+
+		dir, err := os.MkdirTemp("", "puck-test-*")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		viper.Set("data_dir", dir)
+		viper.Set("budget_policy", "evict")
+
+		_, err = Load()
+		assert.ErrorContains(t, err, "budget_policy")
+	})
+
+	t.Run("checks public TLS domains have a DNS provider", func(t *testing.T) {
+		cleanup()
+		defer cleanup()
+
+		dir, err := os.MkdirTemp("", "puck-test-*")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		viper.Set("data_dir", dir)
+		viper.Set("router_tls_domains", []string{"*.pucks.example.com"})
+		_, err = Load()
+		assert.ErrorContains(t, err, "router_tls_port")
+
+		viper.Set("router_tls_port", 8443)
+		_, err = Load()
+		assert.ErrorContains(t, err, "needs router_tls_dns")
+
+		viper.Set("router_tls_dns", "route53")
+		_, err = Load()
+		assert.ErrorContains(t, err, "cloudflare or exec")
+
+		viper.Set("router_tls_dns", "cloudflare")
+		_, err = Load()
+		assert.ErrorContains(t, err, "api_token")
+
+		viper.Set("router_tls_dns_credentials", map[string]string{"api_token": "{env.CF_API_TOKEN}"})
+		cfg, err := Load()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"*.pucks.example.com"}, cfg.RouterTLSDomains)
+		assert.Equal(t, "{env.CF_API_TOKEN}", cfg.RouterTLSDNSCredentials["api_token"])
+	})
+
+	t.Run("rejects unknown share tunnels", func(t *testing.T) {
+		cleanup()
+		defer cleanup()
+
+		dir, err := os.MkdirTemp("", "puck-test-*")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		viper.Set("data_dir", dir)
+		viper.Set("share_tunnel", "ngrok")
+		_, err = Load()
+		assert.ErrorContains(t, err, "share_tunnel")
+
+		viper.Set("share_tunnel", "command")
+		_, err = Load()
+		assert.ErrorContains(t, err, "share_tunnel_command")
+
+		viper.Set("share_tunnel_command", "bore local {port} --to bore.pub")
+		cfg, err := Load()
+		require.NoError(t, err)
+		assert.Equal(t, "command", cfg.ShareTunnel)
+	})
+
+	t.Run("keeps the share listener off the router's ports", func(t *testing.T) {
+		cleanup()
+		defer cleanup()
+
+		dir, err := os.MkdirTemp("", "puck-test-*")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		viper.Set("data_dir", dir)
+		viper.Set("share_port", 8080)
+		_, err = Load()
+		assert.ErrorContains(t, err, "share_port")
+
+		viper.Set("share_port", 8081)
+		cfg, err := Load()
+		require.NoError(t, err)
+		assert.Equal(t, 8081, cfg.SharePort)
+	})
+
+	t.Run("rejects memory pressure over 100 percent", func(t *testing.T) {
+		cleanup()
+		defer cleanup()
+
+		dir, err := os.MkdirTemp("", "puck-test-*")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		viper.Set("data_dir", dir)
+		viper.Set("memory_pressure", 150)
+
+		_, err = Load()
+		assert.ErrorContains(t, err, "memory_pressure")
+	})
+
+	t.Run("rejects unknown snapshot modes", func(t *testing.T) {
+		cleanup()
+		defer cleanup()
+
+		dir, err := os.MkdirTemp("", "puck-test-*")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		viper.Set("data_dir", dir)
+		viper.Set("snapshot_mode", "zfs")
+
+		_, err = Load()
+		assert.ErrorContains(t, err, "snapshot_mode")
+	})
+
+	t.Run("reads the snapshot tiering policy", func(t *testing.T) {
+		cleanup()
+		defer cleanup()
+
+		dir, err := os.MkdirTemp("", "puck-test-*")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		viper.Set("data_dir", dir)
+		viper.Set("snapshot_tier_dir", "/mnt/archive/puck")
+		viper.Set("snapshot_tier_after", 7)
+
+		cfg, err := Load()
+		require.NoError(t, err)
+		assert.Equal(t, "/mnt/archive/puck", cfg.SnapshotTierDir)
+		assert.Equal(t, 7, cfg.SnapshotTierAfter)
+	})
+
+	t.Run("reads checkpoint CRIU options", func(t *testing.T) {
+		cleanup()
+		defer cleanup()
+
+		dir, err := os.MkdirTemp("", "puck-test-*")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		viper.Set("data_dir", dir)
+		viper.Set("checkpoint_tcp_established", false)
+		viper.Set("checkpoint_file_locks", true)
+
+		cfg, err := Load()
+		require.NoError(t, err)
+		assert.False(t, cfg.CheckpointTCPEstablished)
+		assert.True(t, cfg.CheckpointFileLocks)
+	})
+
+	t.Run("reads snapshot dedup", func(t *testing.T) {
+		cleanup()
+		defer cleanup()
+
+		dir, err := os.MkdirTemp("", "puck-test-*")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		viper.Set("data_dir", dir)
+		viper.Set("snapshot_dedup", true)
+
+		cfg, err := Load()
+		require.NoError(t, err)
+		assert.True(t, cfg.SnapshotDedup)
+	})
+
+	t.Run("rejects a relative snapshot tier directory", func(t *testing.T) {
+		cleanup()
+		defer cleanup()
+
+		dir, err := os.MkdirTemp("", "puck-test-*")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		viper.Set("data_dir", dir)
+		viper.Set("snapshot_tier_dir", "archive")
+
+		_, err = Load()
+		assert.ErrorContains(t, err, "snapshot_tier_dir")
+	})
+
+	t.Run("rejects unknown machine volume drivers", func(t *testing.T) {
+		cleanup()
+		defer cleanup()
+
+		dir, err := os.MkdirTemp("", "puck-test-*")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		viper.Set("data_dir", dir)
+		viper.Set("machine_volume_driver", "nfs")
+
+		_, err = Load()
+		assert.ErrorContains(t, err, "machine_volume_driver")
+	})
+
+	t.Run("rejects unknown route modes", func(t *testing.T) {
+		cleanup()
+		defer cleanup()
+
+		dir, err := os.MkdirTemp("", "puck-test-*")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		viper.Set("data_dir", dir)
+		viper.Set("route_mode", "bridge")
+
+		_, err = Load()
+		assert.ErrorContains(t, err, "route_mode")
+	})
+
+	t.Run("uses host ports without the router", func(t *testing.T) {
+		cleanup()
+		defer cleanup()
+
+		dir, err := os.MkdirTemp("", "puck-test-*")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		viper.Set("data_dir", dir)
+		viper.Set("router_enabled", false)
+
+		cfg, err := Load()
+		require.NoError(t, err)
+		assert.False(t, cfg.RouterEnabled)
+		assert.Equal(t, RouteHostPort, cfg.RouteMode)
+
+		viper.Set("route_mode", RouteContainerIP)
+		_, err = Load()
+		assert.ErrorContains(t, err, "needs the router")
+	})
+
+	t.Run("rejects unknown port allocators", func(t *testing.T) {
+		cleanup()
+		defer cleanup()
+
+		dir, err := os.MkdirTemp("", "puck-test-*")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		viper.Set("data_dir", dir)
+		viper.Set("port_allocator", PortHash)
+
+		cfg, err := Load()
+		require.NoError(t, err)
+		assert.Equal(t, PortHash, cfg.PortAllocator)
+
+		viper.Set("port_allocator", "lowest")
+		_, err = Load()
+		assert.ErrorContains(t, err, "port_allocator")
+	})
+
+	t.Run("rejects unknown router processes", func(t *testing.T) {
+		cleanup()
+		defer cleanup()
+
+		dir, err := os.MkdirTemp("", "puck-test-*")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		viper.Set("data_dir", dir)
+		viper.Set("router_process", RouterChild)
+
+		cfg, err := Load()
+		require.NoError(t, err)
+		assert.Equal(t, RouterChild, cfg.RouterProcess)
+
+		viper.Set("router_process", "systemd")
+		_, err = Load()
+		assert.ErrorContains(t, err, "router_process")
+	})
+
+	t.Run("rejects stop timeouts out of range", func(t *testing.T) {
+		cleanup()
+		defer cleanup()
+
+		dir, err := os.MkdirTemp("", "puck-test-*")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		viper.Set("data_dir", dir)
+		viper.Set("stop_timeout", MaxStopTimeout+1)
+
+		_, err = Load()
+		assert.ErrorContains(t, err, "stop_timeout")
+	})
+
+	t.Run("rejects database URLs other than postgres", func(t *testing.T) {
+		cleanup()
+		defer cleanup()
+
+		dir, err := os.MkdirTemp("", "puck-test-*")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		viper.Set("data_dir", dir)
+		viper.Set("database_url", "mysql://db.example.com/puck")
+
+		_, err = Load()
+		assert.ErrorContains(t, err, "database_url")
+	})
+
+	t.Run("rejects TLS cert without key", func(t *testing.T) {
+		cleanup()
+		defer cleanup()
+
+		dir, err := os.MkdirTemp("", "puck-test-*")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		viper.Set("data_dir", dir)
+		viper.Set("router_tls_cert", "/certs/puck.pem")
+
+		_, err = Load()
+		assert.Error(t, err)
+	})
+}
+
+func TestWebhooks(t *testing.T) {
+	t.Run("loads webhook definitions", func(t *testing.T) {
+		viper.Reset()
+		defer viper.Reset()
+
+		viper.Set("data_dir", t.TempDir())
+		viper.Set("webhook_listen", "127.0.0.1:8090")
+		viper.Set("webhook_secret", "s3cret")
+		viper.Set("webhooks", map[string]interface{}{
+			"refresh-staging": map[string]interface{}{"action": "recreate", "puck": "staging", "image": "app:latest"},
+		})
+
+		cfg, err := Load()
+		require.NoError(t, err)
+		assert.Equal(t, "127.0.0.1:8090", cfg.WebhookListen)
+		assert.Equal(t, WebhookConfig{Action: "recreate", Puck: "staging", Image: "app:latest"}, cfg.Webhooks["refresh-staging"])
+	})
+
+	t.Run("requires a secret when listening", func(t *testing.T) {
+		viper.Reset()
+		defer viper.Reset()
+
+		viper.Set("data_dir", t.TempDir())
+		viper.Set("webhook_listen", "127.0.0.1:8090")
+
+		_, err := Load()
+		assert.ErrorContains(t, err, "webhook_secret")
+	})
+
+	t.Run("rejects unknown actions", func(t *testing.T) {
+		viper.Reset()
+		defer viper.Reset()
+
+		viper.Set("data_dir", t.TempDir())
+		viper.Set("webhooks", map[string]interface{}{
+			"nuke": map[string]interface{}{"action": "destroy", "puck": "staging"},
+		})
+
+		_, err := Load()
+		assert.ErrorContains(t, err, `unknown action "destroy"`)
+	})
 }
 
 func TestDefaultDataDir(t *testing.T) {
@@ -151,6 +626,26 @@ func TestDefaultDataDir(t *testing.T) {
 	})
 }
 
+func TestDefaultLandingTemplate(t *testing.T) {
+	home, _ := os.UserHomeDir()
+	assert.Equal(t, filepath.Join(home, ".config", "puck", "landing.html"), defaultLandingTemplate())
+	assert.Equal(t, defaultLandingTemplate(), Default().LandingTemplate)
+}
+
+func TestDefaultMOTD(t *testing.T) {
+	home, _ := os.UserHomeDir()
+	cfg := Default()
+	assert.True(t, cfg.MOTD)
+	assert.Equal(t, filepath.Join(home, ".config", "puck", "motd.tmpl"), cfg.MOTDTemplate)
+}
+
+func TestDefaultHooksDir(t *testing.T) {
+	cfg := Default()
+	home, _ := os.UserHomeDir()
+	assert.Equal(t, filepath.Join(home, ".config", "puck", "hooks.d"), cfg.HooksDir)
+	assert.Equal(t, 10, cfg.HookTimeout)
+}
+
 func TestPucksDir(t *testing.T) {
 	cfg := &Config{DataDir: "/test/data"}
 	assert.Equal(t, "/test/data/pucks", cfg.PucksDir())
@@ -166,6 +661,57 @@ func TestDatabasePath(t *testing.T) {
 	assert.Equal(t, "/test/data/puck.db", cfg.DatabasePath())
 }
 
+func TestDatabaseDSN(t *testing.T) {
+	t.Run("defaults to the SQLite database", func(t *testing.T) {
+		cfg := &Config{DataDir: "/test/data"}
+		assert.Equal(t, "/test/data/puck.db", cfg.DatabaseDSN())
+	})
+
+	t.Run("prefers database_url", func(t *testing.T) {
+		cfg := &Config{DataDir: "/test/data", DatabaseURL: "postgres://puck@db.example.com/puck"}
+		assert.Equal(t, "postgres://puck@db.example.com/puck", cfg.DatabaseDSN())
+	})
+}
+
+func TestShareKeyPath(t *testing.T) {
+	cfg := &Config{DataDir: "/test/data"}
+	assert.Equal(t, "/test/data/share.key", cfg.ShareKeyPath())
+}
+
+func TestShareBaseURL(t *testing.T) {
+	t.Run("empty without a public listener", func(t *testing.T) {
+		cfg := &Config{RouterDomain: "localhost"}
+		assert.Empty(t, cfg.ShareBaseURL())
+	})
+
+	t.Run("never the TLS listener, which serves every puck", func(t *testing.T) {
+		cfg := &Config{RouterDomain: "dev.example.com", RouterTLSPort: 8443}
+		assert.Empty(t, cfg.ShareBaseURL())
+	})
+
+	t.Run("prefers share_url", func(t *testing.T) {
+		cfg := &Config{RouterDomain: "localhost", RouterTLSPort: 8443, ShareURL: "https://box.example.ts.net"}
+		assert.Equal(t, "https://box.example.ts.net", cfg.ShareBaseURL())
+	})
+}
+
+func TestRoutesToContainerIP(t *testing.T) {
+	t.Run("follows an explicit mode", func(t *testing.T) {
+		assert.True(t, (&Config{RouteMode: RouteContainerIP}).RoutesToContainerIP())
+		assert.False(t, (&Config{RouteMode: RouteHostPort, PodmanSocket: rootfulPodmanSocket}).RoutesToContainerIP())
+	})
+
+	t.Run("auto uses host ports for rootless podman", func(t *testing.T) {
+		cfg := &Config{RouteMode: RouteAuto, PodmanSocket: "unix:///run/user/1000/podman/podman.sock"}
+		assert.False(t, cfg.RoutesToContainerIP())
+	})
+
+	t.Run("auto uses container IPs for rootful podman on linux", func(t *testing.T) {
+		cfg := &Config{RouteMode: RouteAuto, PodmanSocket: rootfulPodmanSocket}
+		assert.Equal(t, runtime.GOOS == "linux", cfg.RoutesToContainerIP())
+	})
+}
+
 func TestDefaultDaemonSocket(t *testing.T) {
 	socket := defaultDaemonSocket()
 	assert.Contains(t, socket, "puckd.sock")
@@ -0,0 +1,270 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// Context transports
+const (
+	ContextUnix    = "unix"    // daemon socket on this machine
+	ContextSSH     = "ssh"     // daemon on another machine, reached over ssh
+	ContextTCP     = "tcp"     // daemon TCP listener secured with mutual TLS
+	ContextTailnet = "tailnet" // daemon's own tailnet node, which knows callers by their login
+)
+
+// DefaultContext is the implicit context for the local daemon socket
+const DefaultContext = "default"
+
+// contextNamePattern matches valid context names
+var contextNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// Context is a named daemon the CLI can talk to
+type Context struct {
+	Name string `yaml:"-"`
+	Type string `yaml:"type"`
+
+	// unix: path to the daemon socket
+	Socket string `yaml:"socket,omitempty"`
+
+	// ssh: destination as accepted by ssh, e.g. user@homelab; the remote
+	// host needs puck on its PATH
+	Host string `yaml:"host,omitempty"`
+
+	// tcp and tailnet: daemon address; for tcp also the CA that signed its certificate, and the
+	// client certificate presented to it
+	Address    string `yaml:"address,omitempty"`
+	CACert     string `yaml:"ca_cert,omitempty"`
+	ClientCert string `yaml:"client_cert,omitempty"`
+	ClientKey  string `yaml:"client_key,omitempty"`
+}
+
+// Contexts is the set of configured contexts and the one in use
+type Contexts struct {
+	Current  string             `yaml:"current,omitempty"`
+	Contexts map[string]Context `yaml:"contexts,omitempty"`
+}
+
+// ValidateSSHHost checks a host before it is handed to ssh, which would
+// take one starting with a dash as an option, such as -oProxyCommand
+// running a command here
+func ValidateSSHHost(host string) error {
+	if host == "" || strings.HasPrefix(host, "-") || strings.ContainsFunc(host, unicode.IsSpace) {
+		return fmt.Errorf("invalid ssh host %q", host)
+	}
+	return nil
+}
+
+// Validate checks that the context has what its transport needs
+func (c Context) Validate() error {
+	switch c.Type {
+	case ContextUnix:
+		if c.Socket == "" {
+			return fmt.Errorf("unix context requires a socket path")
+		}
+	case ContextSSH:
+		if c.Host == "" {
+			return fmt.Errorf("ssh context requires a host")
+		}
+		if err := ValidateSSHHost(c.Host); err != nil {
+			return err
+		}
+	case ContextTCP:
+		if c.Address == "" {
+			return fmt.Errorf("tcp context requires an address")
+		}
+		if c.ClientCert == "" || c.ClientKey == "" {
+			return fmt.Errorf("tcp context requires a client certificate and key")
+		}
+	case ContextTailnet:
+		if c.Address == "" {
+			return fmt.Errorf("tailnet context requires an address")
+		}
+	default:
+		return fmt.Errorf("unknown context type %q (valid: unix, ssh, tcp, tailnet)", c.Type)
+	}
+	return nil
+}
+
+// Endpoint describes where the context connects, for display
+func (c Context) Endpoint() string {
+	switch c.Type {
+	case ContextUnix:
+		return "unix://" + c.Socket
+	case ContextSSH:
+		return "ssh://" + c.Host
+	case ContextTCP:
+		return "tcp+tls://" + c.Address
+	case ContextTailnet:
+		return "https://" + c.Address
+	}
+	return ""
+}
+
+// ContextsPath returns the file contexts are stored in
+func ContextsPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "puck", "contexts.yaml")
+}
+
+// LoadContexts reads contexts from path; a missing file has none
+func LoadContexts(path string) (*Contexts, error) {
+	cs := &Contexts{Contexts: map[string]Context{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cs, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading contexts: %w", err)
+	}
+
+	if err := yaml.Unmarshal(data, cs); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if cs.Contexts == nil {
+		cs.Contexts = map[string]Context{}
+	}
+	return cs, nil
+}
+
+// Save writes contexts to path
+func (cs *Contexts) Save(path string) error {
+	data, err := yaml.Marshal(cs)
+	if err != nil {
+		return fmt.Errorf("encoding contexts: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating config directory: %w", err)
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// Add stores a context under name, replacing any existing one
+func (cs *Contexts) Add(name string, c Context) error {
+	if name == DefaultContext {
+		return fmt.Errorf("%q is reserved for the local daemon", DefaultContext)
+	}
+	if !contextNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid context name %q", name)
+	}
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	cs.Contexts[name] = c
+	return nil
+}
+
+// Remove deletes a context, switching back to the default if it was current
+func (cs *Contexts) Remove(name string) error {
+	if _, ok := cs.Contexts[name]; !ok {
+		return fmt.Errorf("context '%s' not found", name)
+	}
+	delete(cs.Contexts, name)
+	if cs.Current == name {
+		cs.Current = ""
+	}
+	return nil
+}
+
+// Use makes name the current context
+func (cs *Contexts) Use(name string) error {
+	if name == DefaultContext {
+		cs.Current = ""
+		return nil
+	}
+	if _, ok := cs.Contexts[name]; !ok {
+		return fmt.Errorf("context '%s' not found", name)
+	}
+	cs.Current = name
+	return nil
+}
+
+// Get returns the named context. The default context talks to the local
+// daemon socket from cfg.
+func (cs *Contexts) Get(name string, cfg *Config) (Context, error) {
+	if name == "" || name == DefaultContext {
+		return Context{Name: DefaultContext, Type: ContextUnix, Socket: cfg.DaemonSocket}, nil
+	}
+	c, ok := cs.Contexts[name]
+	if !ok {
+		return Context{}, fmt.Errorf("context '%s' not found (see: puck context list)", name)
+	}
+	c.Name = name
+	return c, nil
+}
+
+// Names returns every context name, starting with the default
+func (cs *Contexts) Names() []string {
+	names := make([]string, 0, len(cs.Contexts))
+	for name := range cs.Contexts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return append([]string{DefaultContext}, names...)
+}
+
+// ActiveName returns the context selected by --context or PUCK_CONTEXT,
+// falling back to the current context
+func (cs *Contexts) ActiveName() string {
+	if name := viper.GetString("context"); name != "" {
+		return name
+	}
+	if cs.Current != "" {
+		return cs.Current
+	}
+	return DefaultContext
+}
+
+// ActiveContext resolves the context commands should talk to
+func ActiveContext() (Context, error) {
+	cfg, err := Load()
+	if err != nil {
+		return Context{}, err
+	}
+	cs, err := LoadContexts(ContextsPath())
+	if err != nil {
+		return Context{}, err
+	}
+	c, err := cs.Get(cs.ActiveName(), cfg)
+	if err != nil {
+		return Context{}, err
+	}
+	// Commands run ssh with the context's host themselves, not only
+	// through the daemon client
+	if err := c.Validate(); err != nil {
+		return Context{}, fmt.Errorf("context '%s': %w", c.Name, err)
+	}
+	return c, nil
+}
+
+// AllContexts returns every context, starting with the default
+func AllContexts() ([]Context, error) {
+	cfg, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	cs, err := LoadContexts(ContextsPath())
+	if err != nil {
+		return nil, err
+	}
+
+	all := make([]Context, 0, len(cs.Contexts)+1)
+	for _, name := range cs.Names() {
+		c, err := cs.Get(name, cfg)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, c)
+	}
+	return all, nil
+}
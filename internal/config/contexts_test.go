@@ -0,0 +1,118 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextValidate(t *testing.T) {
+	valid := []Context{
+		{Type: ContextUnix, Socket: "/run/puckd.sock"},
+		{Type: ContextSSH, Host: "me@homelab"},
+		{Type: ContextTCP, Address: "homelab:7443", ClientCert: "c.pem", ClientKey: "k.pem"},
+		{Type: ContextTailnet, Address: "puck-api.tail1234.ts.net"},
+	}
+	for _, c := range valid {
+		assert.NoError(t, c.Validate(), c.Type)
+	}
+
+	invalid := []Context{
+		{Type: ContextUnix},
+		{Type: ContextSSH},
+		{Type: ContextSSH, Host: "-oProxyCommand=touch /tmp/pwned"},
+		{Type: ContextSSH, Host: "homelab -oProxyCommand=sh"},
+		{Type: ContextTCP, Address: "homelab:7443"},
+		{Type: ContextTailnet},
+		{Type: "http", Address: "homelab"},
+	}
+	for _, c := range invalid {
+		assert.Error(t, c.Validate(), c.Type)
+	}
+}
+
+func TestContexts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "contexts.yaml")
+	cfg := &Config{DaemonSocket: "/tmp/puckd.sock"}
+
+	t.Run("missing file has only the default", func(t *testing.T) {
+		cs, err := LoadContexts(path)
+		require.NoError(t, err)
+		assert.Equal(t, []string{DefaultContext}, cs.Names())
+
+		c, err := cs.Get(cs.ActiveName(), cfg)
+		require.NoError(t, err)
+		assert.Equal(t, Context{Name: DefaultContext, Type: ContextUnix, Socket: "/tmp/puckd.sock"}, c)
+	})
+
+	t.Run("adds, uses and saves contexts", func(t *testing.T) {
+		cs, err := LoadContexts(path)
+		require.NoError(t, err)
+
+		require.NoError(t, cs.Add("homelab", Context{Type: ContextSSH, Host: "me@homelab"}))
+		require.NoError(t, cs.Use("homelab"))
+		require.NoError(t, cs.Save(path))
+
+		cs, err = LoadContexts(path)
+		require.NoError(t, err)
+		assert.Equal(t, []string{DefaultContext, "homelab"}, cs.Names())
+		assert.Equal(t, "homelab", cs.ActiveName())
+
+		c, err := cs.Get("homelab", cfg)
+		require.NoError(t, err)
+		assert.Equal(t, "homelab", c.Name)
+		assert.Equal(t, "ssh://me@homelab", c.Endpoint())
+	})
+
+	t.Run("flag overrides the current context", func(t *testing.T) {
+		defer viper.Reset()
+		cs, err := LoadContexts(path)
+		require.NoError(t, err)
+
+		viper.Set("context", DefaultContext)
+		assert.Equal(t, DefaultContext, cs.ActiveName())
+	})
+
+	t.Run("rejects reserved and invalid names", func(t *testing.T) {
+		cs, err := LoadContexts(path)
+		require.NoError(t, err)
+
+		assert.Error(t, cs.Add(DefaultContext, Context{Type: ContextSSH, Host: "x"}))
+		assert.Error(t, cs.Add("bad/name", Context{Type: ContextSSH, Host: "x"}))
+		assert.Error(t, cs.Use("missing"))
+		_, err = cs.Get("missing", cfg)
+		assert.Error(t, err)
+	})
+
+	t.Run("removing the current context falls back to default", func(t *testing.T) {
+		cs, err := LoadContexts(path)
+		require.NoError(t, err)
+
+		require.NoError(t, cs.Remove("homelab"))
+		assert.Equal(t, DefaultContext, cs.ActiveName())
+		assert.Error(t, cs.Remove("homelab"))
+	})
+}
+
+func TestAllContexts(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("PUCK_DATA_DIR", filepath.Join(home, "data"))
+	defer viper.Reset()
+
+	cs, err := LoadContexts(ContextsPath())
+	require.NoError(t, err)
+	require.NoError(t, cs.Add("homelab", Context{Type: ContextSSH, Host: "me@homelab"}))
+	require.NoError(t, cs.Add("desktop", Context{Type: ContextUnix, Socket: "/tmp/desktop.sock"}))
+	require.NoError(t, cs.Save(ContextsPath()))
+
+	all, err := AllContexts()
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	assert.Equal(t, DefaultContext, all[0].Name)
+	assert.Equal(t, "desktop", all[1].Name)
+	assert.Equal(t, "homelab", all[2].Name)
+}
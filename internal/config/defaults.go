@@ -0,0 +1,103 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// DefaultsRule gives new pucks it matches an image, a console shell and
+// a user for exec sessions, where puck create wasn't given them. A rule
+// matches pucks created with its profile, in its project and with all of
+// its labels; a rule without any of them matches every puck.
+type DefaultsRule struct {
+	Profile string            `mapstructure:"profile"` // as in puck create --profile
+	Project string            `mapstructure:"project"`
+	Labels  map[string]string `mapstructure:"labels"` // from the labels setting
+
+	Image string `mapstructure:"image"`
+	Shell string `mapstructure:"shell"`
+	User  string `mapstructure:"user"`
+}
+
+// PuckDefaults are what the defaults rules give a new puck; empty fields
+// are left to puck's own defaults
+type PuckDefaults struct {
+	Image string `json:"image,omitempty"`
+	Shell string `json:"shell,omitempty"`
+	User  string `json:"user,omitempty"`
+}
+
+// matches reports whether the rule applies to a puck
+func (r DefaultsRule) matches(profile, project string, labels map[string]string) bool {
+	if r.Profile != "" && r.Profile != profile {
+		return false
+	}
+	if r.Project != "" && r.Project != project {
+		return false
+	}
+	for key, value := range r.Labels {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// validateDefaults checks each rule sets something, with a shell given as
+// an absolute path
+func (c *Config) validateDefaults() error {
+	for i, r := range c.Defaults {
+		if r.Image == "" && r.Shell == "" && r.User == "" {
+			return fmt.Errorf("defaults rule %d sets none of image, shell and user", i+1)
+		}
+		if r.Shell != "" && !strings.HasPrefix(r.Shell, "/") {
+			return fmt.Errorf("defaults rule %d: shell must be an absolute path, got %q", i+1, r.Shell)
+		}
+		if strings.ContainsAny(r.User, " \t\n") {
+			return fmt.Errorf("defaults rule %d: invalid user %q", i+1, r.User)
+		}
+	}
+	return nil
+}
+
+// Profiles lists the profiles the defaults rules name, sorted
+func (c *Config) Profiles() []string {
+	set := make(map[string]bool)
+	for _, r := range c.Defaults {
+		if r.Profile != "" {
+			set[r.Profile] = true
+		}
+	}
+	return slices.Sorted(maps.Keys(set))
+}
+
+// PuckDefaults works out a new puck's defaults from the rules it
+// matches, in order, a later rule's settings winning over an earlier
+// one's. A profile no rule names is an error, as it is most likely
+// misspelt.
+func (c *Config) PuckDefaults(profile, project string, labels map[string]string) (PuckDefaults, error) {
+	var d PuckDefaults
+	if profile != "" && !slices.Contains(c.Profiles(), profile) {
+		if profiles := c.Profiles(); len(profiles) > 0 {
+			return d, fmt.Errorf("no defaults rule has profile %q (profiles: %s)", profile, strings.Join(profiles, ", "))
+		}
+		return d, fmt.Errorf("no defaults rule has profile %q", profile)
+	}
+	for _, r := range c.Defaults {
+		if !r.matches(profile, project, labels) {
+			continue
+		}
+		if r.Image != "" {
+			d.Image = r.Image
+		}
+		if r.Shell != "" {
+			d.Shell = r.Shell
+		}
+		if r.User != "" {
+			d.User = r.User
+		}
+	}
+	return d, nil
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPuckDefaults(t *testing.T) {
+	cfg := &Config{Defaults: []DefaultsRule{
+		{User: "dev"},
+		{Project: "infra", Image: "ubuntu:24.04", Shell: "/bin/zsh"},
+		{Labels: map[string]string{"team": "data"}, Image: "python:3.12"},
+		{Profile: "go", Image: "golang:1.23", User: "gopher"},
+	}}
+
+	for name, tc := range map[string]struct {
+		profile, project string
+		labels           map[string]string
+		want             PuckDefaults
+	}{
+		"every puck":      {want: PuckDefaults{User: "dev"}},
+		"project":         {project: "infra", want: PuckDefaults{Image: "ubuntu:24.04", Shell: "/bin/zsh", User: "dev"}},
+		"label":           {labels: map[string]string{"team": "data", "owner": "alice"}, want: PuckDefaults{Image: "python:3.12", User: "dev"}},
+		"other label":     {labels: map[string]string{"team": "web"}, want: PuckDefaults{User: "dev"}},
+		"later rule wins": {profile: "go", project: "infra", want: PuckDefaults{Image: "golang:1.23", Shell: "/bin/zsh", User: "gopher"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			got, err := cfg.PuckDefaults(tc.profile, tc.project, tc.labels)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	t.Run("refuses unknown profiles", func(t *testing.T) {
+		_, err := cfg.PuckDefaults("rust", "", nil)
+		assert.EqualError(t, err, `no defaults rule has profile "rust" (profiles: go)`)
+	})
+}
+
+func TestDefaultsSetting(t *testing.T) {
+	for name, tc := range map[string]struct {
+		rule map[string]interface{}
+		err  string
+	}{
+		"sets nothing":   {map[string]interface{}{"project": "infra"}, "defaults rule 1 sets none of image, shell and user"},
+		"relative shell": {map[string]interface{}{"shell": "zsh"}, `shell must be an absolute path, got "zsh"`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			viper.Reset()
+			defer viper.Reset()
+
+			viper.Set("data_dir", t.TempDir())
+			viper.Set("defaults", []interface{}{tc.rule})
+
+			_, err := Load()
+			assert.ErrorContains(t, err, tc.err)
+		})
+	}
+
+	t.Run("loads rules", func(t *testing.T) {
+		viper.Reset()
+		defer viper.Reset()
+
+		viper.Set("data_dir", t.TempDir())
+		viper.Set("defaults", []interface{}{
+			map[string]interface{}{"labels": map[string]interface{}{"team": "data"}, "image": "python:3.12", "shell": "/bin/bash"},
+		})
+
+		cfg, err := Load()
+		require.NoError(t, err)
+		assert.Equal(t, []DefaultsRule{{Labels: map[string]string{"team": "data"}, Image: "python:3.12", Shell: "/bin/bash"}}, cfg.Defaults)
+	})
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// LabelData is what the templates of the labels setting are run with
+type LabelData struct {
+	Name    string // of the puck
+	Image   string
+	Project string
+	User    string // who created the puck
+	Cwd     string // where puck create was run, on the caller's machine
+}
+
+// labelFuncs are the functions label templates may call. base and dir
+// of an empty path are empty, so the label is left out.
+var labelFuncs = template.FuncMap{
+	"base":  emptyOr(filepath.Base),
+	"dir":   emptyOr(filepath.Dir),
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+func emptyOr(fn func(string) string) func(string) string {
+	return func(path string) string {
+		if path == "" {
+			return ""
+		}
+		return fn(path)
+	}
+}
+
+// reservedLabel reports whether puck sets a container label itself
+func reservedLabel(key string) bool {
+	return key == "managed-by" || strings.HasPrefix(key, "puck.")
+}
+
+// parseLabels parses the templates of the labels setting by key
+func parseLabels(labels map[string]string) (map[string]*template.Template, error) {
+	templates := make(map[string]*template.Template, len(labels))
+	for key, value := range labels {
+		if key == "" || strings.ContainsAny(key, "= \t\n") {
+			return nil, fmt.Errorf("labels: invalid label name %q", key)
+		}
+		if reservedLabel(key) {
+			return nil, fmt.Errorf("labels: %s is set by puck itself", key)
+		}
+		tmpl, err := template.New(key).Funcs(labelFuncs).Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("labels: %s: %w", key, err)
+		}
+		templates[key] = tmpl
+	}
+	return templates, nil
+}
+
+// RenderLabels runs the templates of the labels setting for a new puck.
+// Labels that come out empty, such as a project for a puck without one,
+// are left out.
+func (c *Config) RenderLabels(data LabelData) (map[string]string, error) {
+	templates, err := parseLabels(c.Labels)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(templates))
+	for key := range templates {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	labels := make(map[string]string, len(keys))
+	for _, key := range keys {
+		var buf bytes.Buffer
+		if err := templates[key].Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("rendering label %s: %w", key, err)
+		}
+		if value := strings.TrimSpace(buf.String()); value != "" {
+			labels[key] = value
+		}
+	}
+	return labels, nil
+}
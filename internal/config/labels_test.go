@@ -0,0 +1,67 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderLabels(t *testing.T) {
+	cfg := &Config{Labels: map[string]string{
+		"owner":   "{{.User}}",
+		"project": "{{.Cwd | base}}",
+		"team":    "{{.Project | upper}}",
+		"puck":    "{{.Name}} from {{.Image}}",
+	}}
+
+	labels, err := cfg.RenderLabels(LabelData{Name: "web", Image: "fedora:40", User: "alice", Cwd: "/home/alice/src/shop"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"owner":   "alice",
+		"project": "shop",
+		"puck":    "web from fedora:40",
+	}, labels, "labels that come out empty are left out")
+
+	labels, err = cfg.RenderLabels(LabelData{Name: "web", Image: "fedora:40"})
+	require.NoError(t, err)
+	assert.NotContains(t, labels, "project", "base of no directory is empty")
+}
+
+func TestLabelsSetting(t *testing.T) {
+	for name, tc := range map[string]struct {
+		labels map[string]string
+		err    string
+	}{
+		"reserved prefix":  {map[string]string{"puck.id": "x"}, "puck.id is set by puck itself"},
+		"managed-by":       {map[string]string{"managed-by": "me"}, "managed-by is set by puck itself"},
+		"bad name":         {map[string]string{"a=b": "x"}, "invalid label name"},
+		"bad template":     {map[string]string{"owner": "{{.User"}, "labels: owner"},
+		"unknown field":    {map[string]string{"owner": "{{.Usr}}"}, "rendering label owner"},
+		"unknown function": {map[string]string{"owner": "{{.User | title}}"}, `function "title" not defined`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			viper.Reset()
+			defer viper.Reset()
+
+			viper.Set("data_dir", t.TempDir())
+			viper.Set("labels", tc.labels)
+
+			_, err := Load()
+			assert.ErrorContains(t, err, tc.err)
+		})
+	}
+
+	t.Run("loads labels", func(t *testing.T) {
+		viper.Reset()
+		defer viper.Reset()
+
+		viper.Set("data_dir", t.TempDir())
+		viper.Set("labels", map[string]string{"owner": "{{.User}}"})
+
+		cfg, err := Load()
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"owner": "{{.User}}"}, cfg.Labels)
+	})
+}
@@ -0,0 +1,192 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// sharedPathsKey is the config file key shared paths are stored under
+const sharedPathsKey = "shared_paths"
+
+// SharedPath is a host directory mounted into every new puck
+type SharedPath struct {
+	Path     string `mapstructure:"path" yaml:"path"`
+	Target   string `mapstructure:"target" yaml:"target,omitempty"` // defaults to /mnt/<base name of path>
+	ReadOnly bool   `mapstructure:"read_only" yaml:"read_only,omitempty"`
+}
+
+// Destination returns where the path is mounted inside pucks
+func (s SharedPath) Destination() string {
+	if s.Target != "" {
+		return s.Target
+	}
+	return path.Join("/mnt", filepath.Base(s.Path))
+}
+
+// Validate checks that both ends of the mount are absolute paths
+func (s SharedPath) Validate() error {
+	if !filepath.IsAbs(s.Path) {
+		return fmt.Errorf("shared path %q must be absolute", s.Path)
+	}
+	if !path.IsAbs(s.Destination()) {
+		return fmt.Errorf("shared path %q: target %q must be absolute", s.Path, s.Target)
+	}
+	return nil
+}
+
+// ConfigPath returns the default config file
+func ConfigPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "puck", "config.yaml")
+}
+
+// ReadSharedPaths reads the shared paths from a config file; a missing
+// file has none
+func ReadSharedPaths(file string) ([]SharedPath, error) {
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+
+	var doc struct {
+		SharedPaths []SharedPath `yaml:"shared_paths"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", file, err)
+	}
+	return normalizeSharedPaths(doc.SharedPaths)
+}
+
+// WriteSharedPaths replaces the shared paths in a config file, leaving
+// the rest of it, including comments, as it was
+func WriteSharedPaths(file string, shared []SharedPath) error {
+	if len(shared) == 0 {
+		return setConfigKey(file, sharedPathsKey, nil)
+	}
+	return setConfigKey(file, sharedPathsKey, shared)
+}
+
+// WriteDataDir sets data_dir in a config file, leaving the rest of it as
+// it was
+func WriteDataDir(file, dir string) error {
+	return setConfigKey(file, "data_dir", dir)
+}
+
+// WriteDefaultImage sets default_image in a config file, leaving the rest
+// of it as it was
+func WriteDefaultImage(file, image string) error {
+	return setConfigKey(file, "default_image", image)
+}
+
+// WriteRouterPort sets router_port in a config file, leaving the rest of
+// it as it was
+func WriteRouterPort(file string, port int) error {
+	return setConfigKey(file, "router_port", port)
+}
+
+// setConfigKey sets a top-level key in a config file to value, or removes
+// it when value is nil, keeping the rest of the file, including comments
+func setConfigKey(file, key string, value any) error {
+	var doc yaml.Node
+	data, err := os.ReadFile(file)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("reading config: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("parsing %s: %w", file, err)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("parsing %s: expected a mapping at the top level", file)
+	}
+
+	var node yaml.Node
+	if err := node.Encode(value); err != nil {
+		return fmt.Errorf("encoding %s: %w", key, err)
+	}
+
+	replaced := false
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == key {
+			if value == nil {
+				root.Content = append(root.Content[:i], root.Content[i+2:]...)
+			} else {
+				root.Content[i+1] = &node
+			}
+			replaced = true
+			break
+		}
+	}
+	if !replaced && value != nil {
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, &node)
+	}
+
+	out, err := yaml.Marshal(&doc)
+	if err != nil {
+		return fmt.Errorf("encoding config: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return fmt.Errorf("creating config directory: %w", err)
+	}
+	return os.WriteFile(file, out, 0644)
+}
+
+// AddSharedPath adds s to shared, replacing any entry for the same path
+func AddSharedPath(shared []SharedPath, s SharedPath) ([]SharedPath, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	for i, existing := range shared {
+		if existing.Path == s.Path {
+			shared[i] = s
+			return shared, nil
+		}
+	}
+	return append(shared, s), nil
+}
+
+// RemoveSharedPath removes the entry for p from shared
+func RemoveSharedPath(shared []SharedPath, p string) ([]SharedPath, error) {
+	for i, existing := range shared {
+		if existing.Path == p {
+			return append(shared[:i], shared[i+1:]...), nil
+		}
+	}
+	return nil, fmt.Errorf("'%s' is not shared", p)
+}
+
+// CurrentSharedPaths returns the shared paths as the config file has them
+// now, so paths added after the daemon started still reach new pucks
+func (c *Config) CurrentSharedPaths() ([]SharedPath, error) {
+	if c.ConfigFile == "" {
+		return c.SharedPaths, nil
+	}
+	return ReadSharedPaths(c.ConfigFile)
+}
+
+// normalizeSharedPaths expands a leading ~ in host paths and validates
+// each entry
+func normalizeSharedPaths(shared []SharedPath) ([]SharedPath, error) {
+	home, _ := os.UserHomeDir()
+	for i, s := range shared {
+		if s.Path == "~" || strings.HasPrefix(s.Path, "~/") {
+			shared[i].Path = filepath.Join(home, strings.TrimPrefix(s.Path, "~"))
+		}
+		if err := shared[i].Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return shared, nil
+}
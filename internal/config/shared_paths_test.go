@@ -0,0 +1,140 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSharedPathDestination(t *testing.T) {
+	assert.Equal(t, "/mnt/src", SharedPath{Path: "/home/me/src"}.Destination())
+	assert.Equal(t, "/data", SharedPath{Path: "/home/me/datasets", Target: "/data"}.Destination())
+}
+
+func TestSharedPathValidate(t *testing.T) {
+	assert.NoError(t, SharedPath{Path: "/home/me/src"}.Validate())
+	assert.Error(t, SharedPath{Path: "src"}.Validate())
+	assert.Error(t, SharedPath{Path: "/home/me/src", Target: "data"}.Validate())
+}
+
+func TestSharedPaths(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+
+	t.Run("missing file has none", func(t *testing.T) {
+		shared, err := ReadSharedPaths(file)
+		require.NoError(t, err)
+		assert.Empty(t, shared)
+	})
+
+	t.Run("round-trips through the config file", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(file, []byte("# my settings\ndefault_image: alpine:latest\n"), 0644))
+
+		shared, err := AddSharedPath(nil, SharedPath{Path: "/home/me/src"})
+		require.NoError(t, err)
+		shared, err = AddSharedPath(shared, SharedPath{Path: "/home/me/Downloads", ReadOnly: true})
+		require.NoError(t, err)
+		require.NoError(t, WriteSharedPaths(file, shared))
+
+		got, err := ReadSharedPaths(file)
+		require.NoError(t, err)
+		assert.Equal(t, shared, got)
+
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "# my settings")
+		assert.Contains(t, string(data), "default_image: alpine:latest")
+	})
+
+	t.Run("replaces an existing path", func(t *testing.T) {
+		shared, err := ReadSharedPaths(file)
+		require.NoError(t, err)
+		shared, err = AddSharedPath(shared, SharedPath{Path: "/home/me/src", ReadOnly: true})
+		require.NoError(t, err)
+		assert.Len(t, shared, 2)
+		assert.True(t, shared[0].ReadOnly)
+	})
+
+	t.Run("removes paths and the key once empty", func(t *testing.T) {
+		shared, err := ReadSharedPaths(file)
+		require.NoError(t, err)
+		shared, err = RemoveSharedPath(shared, "/home/me/src")
+		require.NoError(t, err)
+		shared, err = RemoveSharedPath(shared, "/home/me/Downloads")
+		require.NoError(t, err)
+		require.NoError(t, WriteSharedPaths(file, shared))
+
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "shared_paths")
+
+		_, err = RemoveSharedPath(nil, "/home/me/src")
+		assert.Error(t, err)
+	})
+
+	t.Run("expands ~ in the config file", func(t *testing.T) {
+		home, _ := os.UserHomeDir()
+		require.NoError(t, os.WriteFile(file, []byte("shared_paths:\n  - path: ~/src\n"), 0644))
+
+		shared, err := ReadSharedPaths(file)
+		require.NoError(t, err)
+		assert.Equal(t, []SharedPath{{Path: filepath.Join(home, "src")}}, shared)
+	})
+
+	t.Run("loads with the config", func(t *testing.T) {
+		viper.Reset()
+		defer viper.Reset()
+
+		viper.Set("data_dir", t.TempDir())
+		viper.Set("shared_paths", []map[string]interface{}{
+			{"path": "/home/me/Downloads", "read_only": true},
+		})
+
+		cfg, err := Load()
+		require.NoError(t, err)
+		assert.Equal(t, []SharedPath{{Path: "/home/me/Downloads", ReadOnly: true}}, cfg.SharedPaths)
+	})
+
+	t.Run("rejects relative paths", func(t *testing.T) {
+		viper.Reset()
+		defer viper.Reset()
+
+		viper.Set("data_dir", t.TempDir())
+		viper.Set("shared_paths", []map[string]interface{}{{"path": "src"}})
+
+		_, err := Load()
+		assert.Error(t, err)
+	})
+}
+
+func TestWriteDataDir(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("# my settings\ndata_dir: /old\nshared_paths:\n  - path: /home/me/src\n"), 0644))
+
+	require.NoError(t, WriteDataDir(file, "/mnt/big/puck"))
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "# my settings")
+	assert.Contains(t, string(data), "data_dir: /mnt/big/puck")
+	assert.NotContains(t, string(data), "/old")
+
+	shared, err := ReadSharedPaths(file)
+	require.NoError(t, err)
+	assert.Len(t, shared, 1)
+}
+
+func TestWriteDefaults(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "puck", "config.yaml")
+
+	require.NoError(t, WriteDefaultImage(file, "ubuntu:24.04"))
+	require.NoError(t, WriteRouterPort(file, 8081))
+	require.NoError(t, WriteRouterPort(file, 8082))
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "default_image: ubuntu:24.04\nrouter_port: 8082\n", string(data))
+}
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TimeWindow is a daily span of local time, which may run past midnight
+type TimeWindow struct {
+	Start, End time.Duration // since midnight
+}
+
+// ParseTimeWindow parses a span like "02:00-06:00" or "22:00-04:00"
+func ParseTimeWindow(s string) (TimeWindow, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return TimeWindow{}, fmt.Errorf("%q is not a span like 02:00-06:00", s)
+	}
+	start, err := parseClock(strings.TrimSpace(from))
+	if err != nil {
+		return TimeWindow{}, err
+	}
+	end, err := parseClock(strings.TrimSpace(to))
+	if err != nil {
+		return TimeWindow{}, err
+	}
+	if start == end {
+		return TimeWindow{}, fmt.Errorf("%q is empty", s)
+	}
+	return TimeWindow{Start: start, End: end}, nil
+}
+
+// parseClock parses a time of day like 02:00 into the time since midnight
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a time like 02:00", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains reports whether t falls in the window, in t's location
+func (w TimeWindow) Contains(t time.Time) bool {
+	y, m, d := t.Date()
+	since := t.Sub(time.Date(y, m, d, 0, 0, 0, 0, t.Location()))
+	if w.Start < w.End {
+		return since >= w.Start && since < w.End
+	}
+	return since >= w.Start || since < w.End
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeWindow(t *testing.T) {
+	at := func(hour, min int) time.Time {
+		return time.Date(2026, 3, 4, hour, min, 0, 0, time.Local)
+	}
+
+	w, err := ParseTimeWindow("02:00-06:00")
+	require.NoError(t, err)
+	assert.True(t, w.Contains(at(2, 0)))
+	assert.True(t, w.Contains(at(5, 59)))
+	assert.False(t, w.Contains(at(6, 0)))
+	assert.False(t, w.Contains(at(23, 0)))
+
+	overnight, err := ParseTimeWindow("22:30 - 04:00")
+	require.NoError(t, err)
+	assert.True(t, overnight.Contains(at(23, 0)))
+	assert.True(t, overnight.Contains(at(1, 0)))
+	assert.False(t, overnight.Contains(at(12, 0)))
+	assert.False(t, overnight.Contains(at(22, 0)))
+
+	for _, bad := range []string{"", "02:00", "2am-6am", "25:00-06:00", "03:00-03:00"} {
+		_, err := ParseTimeWindow(bad)
+		assert.Error(t, err, bad)
+	}
+}
@@ -0,0 +1,166 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/sandwich-labs/puck/internal/agent"
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// puckAgent is the daemon's end of a puck's agent: the socket it reports
+// to and what it last said
+type puckAgent struct {
+	ln      net.Listener
+	report  *agent.Report
+	started time.Time // when the daemon last started the agent
+}
+
+// watchAgents keeps an agent reporting from every running puck, starting
+// it again once its reports stop, e.g. after the puck was recreated
+func (d *Daemon) watchAgents(ctx context.Context) {
+	ticker := time.NewTicker(agent.Heartbeat)
+	defer ticker.Stop()
+
+	for {
+		d.checkAgents(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkAgents starts the agents of running pucks that aren't reporting,
+// and drops those of pucks that are down or gone
+func (d *Daemon) checkAgents(ctx context.Context) {
+	pucks, err := d.manager.List(ctx)
+	if err != nil {
+		log.Warn("Failed to list pucks for agents", "error", err)
+		return
+	}
+	up := make(map[string]bool, len(pucks))
+	for _, p := range pucks {
+		if p.Status.Up() && p.ContainerID != "" {
+			up[p.Name] = true
+		}
+	}
+
+	d.agentMu.Lock()
+	var down []string
+	for name := range d.agents {
+		if !up[name] {
+			down = append(down, name)
+		}
+	}
+	d.agentMu.Unlock()
+	for _, name := range down {
+		d.stopAgent(name)
+	}
+
+	for _, p := range pucks {
+		if up[p.Name] {
+			d.ensureAgent(ctx, p)
+		}
+	}
+}
+
+// ensureAgent listens for a running puck's agent, and starts it unless it
+// is reporting or was started too recently to have reported yet. The
+// agent itself exits if another copy is already running.
+func (d *Daemon) ensureAgent(ctx context.Context, p *store.Puck) {
+	if !d.cfg.Agent {
+		return
+	}
+
+	d.agentMu.Lock()
+	a := d.agents[p.Name]
+	if a == nil {
+		ln, err := agent.Listen(puck.AgentSocket(p))
+		if err != nil {
+			d.agentMu.Unlock()
+			log.Warn("Failed to listen for puck agent", "name", p.Name, "error", err)
+			return
+		}
+		a = &puckAgent{ln: ln}
+		if d.agents == nil {
+			d.agents = make(map[string]*puckAgent)
+		}
+		d.agents[p.Name] = a
+		go agent.Serve(ln, func(r agent.Report) { d.agentReported(p.Name, a, r) })
+	}
+	if a.report.Fresh() || time.Since(a.started) < agent.StaleAfter {
+		d.agentMu.Unlock()
+		return
+	}
+	a.started = time.Now()
+	d.agentMu.Unlock()
+
+	if err := d.manager.StartAgent(ctx, p); err != nil {
+		if errors.Is(err, puck.ErrAgentUnsupported) {
+			log.Debug("Not starting puck agent", "name", p.Name, "error", err)
+		} else {
+			log.Warn("Failed to start puck agent", "name", p.Name, "error", err)
+		}
+	}
+}
+
+// agentReported keeps a puck's latest report, and follows the app's port
+// when that changes
+func (d *Daemon) agentReported(name string, a *puckAgent, r agent.Report) {
+	d.agentMu.Lock()
+	if d.agents[name] != a {
+		d.agentMu.Unlock()
+		return
+	}
+	moved := a.report.Fresh() && a.report.AppPort != r.AppPort || !a.report.Fresh() && r.AppPort != 0
+	a.report = &r
+	d.agentMu.Unlock()
+
+	if moved {
+		d.followAppPort(name, r.AppPort)
+	}
+}
+
+// agentReport returns what a puck's agent last reported, or nil if it
+// hasn't lately
+func (d *Daemon) agentReport(name string) *agent.Report {
+	d.agentMu.Lock()
+	defer d.agentMu.Unlock()
+	if a := d.agents[name]; a != nil && a.report.Fresh() {
+		r := *a.report
+		return &r
+	}
+	return nil
+}
+
+// stopAgent stops listening for a puck's agent, e.g. once it is destroyed
+func (d *Daemon) stopAgent(name string) {
+	d.agentMu.Lock()
+	a := d.agents[name]
+	delete(d.agents, name)
+	d.agentMu.Unlock()
+
+	if a != nil {
+		a.ln.Close()
+	}
+}
+
+// stopAllAgents stops listening for every agent, at shutdown
+func (d *Daemon) stopAllAgents() {
+	d.agentMu.Lock()
+	names := make([]string, 0, len(d.agents))
+	for name := range d.agents {
+		names = append(names, name)
+	}
+	d.agentMu.Unlock()
+
+	for _, name := range names {
+		d.stopAgent(name)
+	}
+}
@@ -0,0 +1,157 @@
+package daemon
+
+import (
+	"cmp"
+	"context"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/sandwich-labs/puck/internal/agent"
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+const (
+	// appPortInterval is how often running pucks without a reporting
+	// agent are probed for the port their app listens on
+	appPortInterval = 30 * time.Second
+	// appPortRecheck is how often a starting puck is probed at most, while
+	// its readiness is polled
+	appPortRecheck = 2 * time.Second
+)
+
+// probedPorts is what probing a puck found listening
+type probedPorts struct {
+	appPort int
+	at      time.Time
+}
+
+// detectAppPorts probes running pucks for their app's port until ctx is
+// done
+func (d *Daemon) detectAppPorts(ctx context.Context) {
+	ticker := time.NewTicker(appPortInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.probeAllAppPorts(ctx)
+		}
+	}
+}
+
+// probeAllAppPorts probes the routed pucks that are up, and forgets what
+// was found in those that are down or gone
+func (d *Daemon) probeAllAppPorts(ctx context.Context) {
+	pucks, err := d.manager.List(ctx)
+	if err != nil {
+		log.Warn("Failed to list pucks for app port detection", "error", err)
+		return
+	}
+	up := make(map[string]bool, len(pucks))
+	for _, p := range pucks {
+		if p.Status.Up() && p.ContainerID != "" && p.HostPort != 0 {
+			up[p.Name] = true
+			d.probeAppPort(ctx, p)
+		}
+	}
+
+	d.portsMu.Lock()
+	for name := range d.probed {
+		if !up[name] {
+			delete(d.probed, name)
+		}
+	}
+	d.portsMu.Unlock()
+}
+
+// probeAppPort looks for the port a puck's app listens on, unless its
+// agent is reporting it or the puck was probed moments ago, and follows
+// the app if it moved
+func (d *Daemon) probeAppPort(ctx context.Context, p *store.Puck) {
+	if !d.cfg.DetectAppPort || d.agentReport(p.Name) != nil {
+		return
+	}
+	d.portsMu.Lock()
+	last := d.probed[p.Name]
+	d.portsMu.Unlock()
+	if last != nil && time.Since(last.at) < appPortRecheck {
+		return
+	}
+
+	ports, err := d.manager.ProbePorts(ctx, p)
+	if err != nil {
+		log.Debug("Failed to probe puck for its app's port", "name", p.Name, "error", err)
+		return
+	}
+	found := &probedPorts{appPort: agent.AppPort(ports), at: time.Now()}
+
+	d.portsMu.Lock()
+	if d.probed == nil {
+		d.probed = make(map[string]*probedPorts)
+	}
+	d.probed[p.Name] = found
+	d.portsMu.Unlock()
+
+	if last == nil && found.appPort != 0 || last != nil && last.appPort != found.appPort {
+		d.followAppPort(p.Name, found.appPort)
+	}
+}
+
+// appPort returns the port a puck's app was last found listening on, by
+// its agent or by probing, or zero if it hasn't been lately
+func (d *Daemon) appPort(name string) int {
+	if r := d.agentReport(name); r != nil {
+		return r.AppPort
+	}
+	d.portsMu.Lock()
+	defer d.portsMu.Unlock()
+	if found := d.probed[name]; found != nil && time.Since(found.at) < 2*appPortInterval {
+		return found.appPort
+	}
+	return 0
+}
+
+// fillAppPort adds what the puck's agent reported and the port its app
+// was found on to a puck being returned
+func (d *Daemon) fillAppPort(p *store.Puck) {
+	p.Agent = d.agentReport(p.Name)
+	p.AppPort = d.appPort(p.Name)
+	p.AppPortRouted = p.AppPort != 0 && d.cfg.RoutesToContainerIP() && p.ContainerIP != ""
+}
+
+// forgetAppPort drops what probing found in a puck that is no longer up
+func (d *Daemon) forgetAppPort(name string) {
+	d.portsMu.Lock()
+	defer d.portsMu.Unlock()
+	delete(d.probed, name)
+}
+
+// followAppPort routes a running puck to its app's new port when the
+// router goes to container IPs. Through host ports only port 80 is
+// published, so there an endpoint for the port is suggested instead.
+func (d *Daemon) followAppPort(name string, port int) {
+	if d.router == nil {
+		return
+	}
+	ctx := context.Background()
+	p, err := d.manager.Get(ctx, name)
+	if err != nil || p.Status != store.StatusRunning {
+		return
+	}
+	if !d.cfg.RoutesToContainerIP() {
+		if port != 0 && port != 80 {
+			log.Info("Puck's app listens on a port the router doesn't reach", "name", name, "port", port, "hint", puck.AppPortHint(name, port))
+		}
+		return
+	}
+	if want, asleep := d.wantsRoute(ctx, p); !want || asleep {
+		return
+	}
+	log.Info("Routing puck to its app's port", "name", name, "port", cmp.Or(port, 80))
+	if err := d.addRoute(p); err != nil {
+		log.Warn("Failed to add route for puck", "name", name, "error", err)
+	}
+}
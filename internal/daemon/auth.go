@@ -0,0 +1,351 @@
+package daemon
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// caller identifies the user behind a daemon request
+type caller struct {
+	User  string
+	Admin bool // may see and manage every user's pucks
+}
+
+type callerKey struct{}
+
+// systemCaller is used for requests the daemon makes itself, such as
+// authenticated webhooks; it is not restricted by ownership
+var systemCaller = caller{User: currentUser(), Admin: true}
+
+// withCaller attaches the requesting user to ctx
+func withCaller(ctx context.Context, c caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, c)
+}
+
+// callerFrom returns the requesting user, defaulting to the system caller
+func callerFrom(ctx context.Context) caller {
+	if c, ok := ctx.Value(callerKey{}).(caller); ok {
+		return c
+	}
+	return systemCaller
+}
+
+// callerForConn identifies the user on the other end of a socket from its
+// peer credentials. The daemon's own user, root, and configured admins are
+// admins; if the platform can't report peer credentials the caller is
+// assumed to be the daemon's user. Remote TLS clients are named by their
+// certificate's common name, and tailnet clients by their login.
+func (d *Daemon) callerForConn(conn net.Conn) caller {
+	if tc, ok := conn.(*tailnetConn); ok {
+		return d.callerForTailnet(tc)
+	}
+	if tc, ok := conn.(*tls.Conn); ok {
+		return d.callerForTLS(tc)
+	}
+
+	uid, err := peerUID(conn)
+	if err != nil {
+		uid = os.Getuid()
+	}
+
+	name := usernameForUID(uid)
+	return caller{
+		User:  name,
+		Admin: uid == 0 || uid == os.Getuid() || slices.Contains(d.cfg.Admins, name),
+	}
+}
+
+// callerForTLS names a remote client after its verified certificate.
+// Without one the caller has no user and owns nothing.
+func (d *Daemon) callerForTLS(conn *tls.Conn) caller {
+	if err := conn.Handshake(); err != nil {
+		return caller{}
+	}
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 || certs[0].Subject.CommonName == "" {
+		return caller{}
+	}
+
+	name := certs[0].Subject.CommonName
+	return caller{
+		User:  name,
+		Admin: name == "root" || name == systemCaller.User || slices.Contains(d.cfg.Admins, name),
+	}
+}
+
+// authorize rejects requests for pucks the caller does not own
+func (d *Daemon) authorize(ctx context.Context, req *Request) error {
+	c := callerFrom(ctx)
+	if c.Admin {
+		return nil
+	}
+	if c.User == "" {
+		return fmt.Errorf("permission denied: caller could not be identified")
+	}
+
+	switch req.Action {
+	case "router-restart", "gc", "db-check", "machine-resources", "machine-set-resources", "snapshot-tier-status", "snapshot-tier-run":
+		return fmt.Errorf("permission denied: %s requires an admin", req.Action)
+	case "maintenance":
+		// Anyone may see whether the daemon is in maintenance
+		var params struct {
+			Enabled *bool `json:"enabled"`
+		}
+		json.Unmarshal(req.Data, &params)
+		if params.Enabled != nil {
+			return fmt.Errorf("permission denied: turning maintenance mode on or off requires an admin")
+		}
+		return nil
+	case "share-revoke":
+		var target struct {
+			ID string `json:"id"`
+		}
+		json.Unmarshal(req.Data, &target)
+		share, err := d.store.GetShare(ctx, target.ID)
+		if err != nil {
+			// Let the handler report missing shares as usual
+			return nil
+		}
+		return d.authorizePuck(ctx, c, share.PuckName)
+	case "alias-set", "alias-remove":
+		// Taking over or dropping a path needs the puck serving it now
+		var target struct {
+			Path string `json:"path"`
+			Name string `json:"name"`
+		}
+		json.Unmarshal(req.Data, &target)
+		if alias, err := d.store.GetRouteAlias(ctx, "/"+strings.Trim(target.Path, "/")); err == nil {
+			if err := d.authorizePuck(ctx, c, alias.PuckName); err != nil {
+				return err
+			}
+		}
+		if req.Action == "alias-remove" {
+			return nil
+		}
+		return d.authorizePuck(ctx, c, target.Name)
+	case "promote":
+		var target struct {
+			Name    string `json:"name"`
+			Replace string `json:"replace"`
+		}
+		json.Unmarshal(req.Data, &target)
+		if err := d.authorizePuck(ctx, c, target.Replace); err != nil {
+			return err
+		}
+		return d.authorizePuck(ctx, c, target.Name)
+	case "group-add":
+		// Groups are the caller's own, but only of pucks they may manage
+		var target struct {
+			Pucks []string `json:"pucks"`
+		}
+		json.Unmarshal(req.Data, &target)
+		for _, name := range target.Pucks {
+			if err := d.authorizePuck(ctx, c, name); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "create":
+		// A mount exposes a host directory through the daemon's access to
+		// it, so callers may only mount directories they own
+		var opts struct {
+			Name            string        `json:"name"`
+			Mounts          []store.Mount `json:"mounts"`
+			CreateMountDirs bool          `json:"create_mount_dirs"`
+			Replace         bool          `json:"replace"`
+			DataDir         string        `json:"data_dir"`
+			FromCheckpoint  string        `json:"from_checkpoint"`
+		}
+		json.Unmarshal(req.Data, &opts)
+		// The daemon makes the puck's volumes in a data directory, and
+		// removes them when it is destroyed, so it must be the caller's too
+		if opts.DataDir != "" {
+			if err := d.authorizePath(opts.DataDir, c); err != nil {
+				return err
+			}
+		}
+		// The daemon reads a checkpoint archive with its own access, so it
+		// must be one the caller could read themselves
+		if opts.FromCheckpoint != "" {
+			if err := d.authorizePath(opts.FromCheckpoint, c); err != nil {
+				return err
+			}
+		}
+		// Replacing destroys the puck of the same name
+		if opts.Replace {
+			if err := d.authorizePuck(ctx, c, opts.Name); err != nil {
+				return err
+			}
+		}
+		for _, m := range opts.Mounts {
+			source := m.Source
+			// A directory the daemon will create must be made somewhere
+			// the caller owns
+			if opts.CreateMountDirs {
+				source = existingAncestor(source)
+			}
+			if err := d.authorizePath(source, c); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "pool-create":
+		// Every puck the pool makes is created with its options
+		var params struct {
+			Options json.RawMessage `json:"options"`
+		}
+		json.Unmarshal(req.Data, &params)
+		return d.authorize(ctx, &Request{Action: "create", Data: params.Options})
+	case "snapshot-stack-create", "snapshot-stack-restore":
+		// A stack snapshot stops and restores every member, not just the
+		// puck it was taken from
+		var target struct {
+			PuckName     string `json:"puck_name"`
+			SnapshotName string `json:"snapshot_name"`
+		}
+		json.Unmarshal(req.Data, &target)
+		members := []string{target.PuckName}
+		if req.Action == "snapshot-stack-create" {
+			if pucks, err := d.manager.StackMembers(ctx, target.PuckName); err == nil {
+				for _, p := range pucks {
+					members = append(members, p.Name)
+				}
+			}
+		} else if stack, err := d.store.GetStackSnapshot(ctx, target.PuckName, target.SnapshotName); err == nil {
+			for _, m := range stack.Members {
+				members = append(members, m.Puck)
+			}
+		}
+		for _, name := range members {
+			if err := d.authorizePuck(ctx, c, name); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "get", "history", "scan", "events-export", "stats-export", "exec", "exec-stream", "logs", "fs-list", "fs-stat", "fs-read", "start", "stop", "kill", "recreate", "rollback", "destroy", "route-set", "set-resources", "set-host-port", "set-notes", "set-ttl", "egress-set", "env-set", "snapshot-policy-set", "endpoint-add", "endpoint-list", "endpoint-remove", "sync-status", "sync-flush", "tailnet-share", "tailnet-unshare",
+		"share-create", "share-list", "alias-list", "snapshot-create", "snapshot-restore", "snapshot-list", "snapshot-inspect", "snapshot-diff", "snapshot-delete", "snapshot-tag",
+		"snapshot-stack-list":
+	case "list", "watch", "report", "project-status", "routes", "images", "image-tree", "tailnet-status", "router-status", "hooks", "info", "ping", "podman-status",
+		"destroy-all", "snapshot-all", "pool-list", "pool-remove", "group-remove", "group-get", "group-list":
+		// Status anyone may see, and actions whose handlers only show or
+		// touch the caller's own pucks, pools and groups
+		return nil
+	default:
+		// Fail closed, so a new action nobody added a case for stays
+		// admin-only until it is
+		return fmt.Errorf("permission denied: %s requires an admin", req.Action)
+	}
+
+	var target struct {
+		Name     string `json:"name"`
+		PuckName string `json:"puck_name"`
+	}
+	json.Unmarshal(req.Data, &target)
+	name := target.Name
+	if name == "" {
+		name = target.PuckName
+	}
+	return d.authorizePuck(ctx, c, name)
+}
+
+// authorizePuck rejects callers who do not own the named puck
+func (d *Daemon) authorizePuck(ctx context.Context, c caller, name string) error {
+	p, err := d.manager.Get(ctx, name)
+	if err != nil {
+		// Let the handler report missing pucks as usual
+		return nil
+	}
+	if !ownedBy(p, c) {
+		return fmt.Errorf("permission denied: puck '%s' belongs to %s", p.Name, p.Owner)
+	}
+	return nil
+}
+
+// authorizePath rejects host paths the caller does not own, and any under
+// the daemon's data directory, where other users' pucks keep their
+// volumes, whoever owns them
+func (d *Daemon) authorizePath(path string, c caller) error {
+	if within(path, d.cfg.DataDir) {
+		return fmt.Errorf("permission denied: %s is in the daemon's data directory", path)
+	}
+	if !ownsPath(path, c.User) {
+		return fmt.Errorf("permission denied: %s is not owned by %s", path, c.User)
+	}
+	return nil
+}
+
+// within reports whether path is dir or under it, once symlinks are
+// resolved
+func within(path, dir string) bool {
+	resolve := func(p string) string {
+		if resolved, err := filepath.EvalSymlinks(p); err == nil {
+			return resolved
+		}
+		abs, _ := filepath.Abs(p)
+		return abs
+	}
+	rel, err := filepath.Rel(resolve(dir), resolve(path))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// ownsPath reports whether a host path belongs to the named user
+func ownsPath(path, name string) bool {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return false
+	}
+	uid, err := fileOwner(path)
+	return err == nil && strconv.Itoa(uid) == u.Uid
+}
+
+// existingAncestor returns the path, or its nearest parent that exists
+func existingAncestor(path string) string {
+	for {
+		if _, err := os.Lstat(path); err == nil || path == filepath.Dir(path) {
+			return path
+		}
+		path = filepath.Dir(path)
+	}
+}
+
+// ownedBy reports whether the caller may manage a puck
+func ownedBy(p *store.Puck, c caller) bool {
+	return c.Admin || p.Owner == c.User
+}
+
+// filterOwned returns the pucks the caller may see
+func filterOwned(pucks []*store.Puck, c caller) []*store.Puck {
+	if c.Admin {
+		return pucks
+	}
+	owned := make([]*store.Puck, 0, len(pucks))
+	for _, p := range pucks {
+		if ownedBy(p, c) {
+			owned = append(owned, p)
+		}
+	}
+	return owned
+}
+
+func currentUser() string {
+	return usernameForUID(os.Getuid())
+}
+
+func usernameForUID(uid int) string {
+	id := strconv.Itoa(uid)
+	if u, err := user.LookupId(id); err == nil {
+		return u.Username
+	}
+	return id
+}
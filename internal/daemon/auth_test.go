@@ -0,0 +1,313 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupAuthDaemon creates a daemon backed by a temp store with one puck
+// owned by alice and one by bob
+func setupAuthDaemon(t *testing.T) *Daemon {
+	t.Helper()
+	dir := t.TempDir()
+
+	db, err := store.Open(filepath.Join(dir, "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	cfg := &config.Config{DataDir: dir, DefaultImage: "fedora:latest"}
+	mgr := puck.NewManager(cfg, podman.NewMockClient(), db)
+
+	ctx := context.Background()
+	for _, p := range []*store.Puck{
+		{ID: "a1", Name: "alice-puck", Image: "fedora", Status: store.StatusRunning, VolumeDir: dir, Owner: "alice"},
+		{ID: "b1", Name: "bob-puck", Image: "fedora", Status: store.StatusRunning, VolumeDir: dir, Owner: "bob"},
+	} {
+		require.NoError(t, db.CreatePuck(ctx, p))
+	}
+
+	return &Daemon{cfg: cfg, store: db, manager: mgr}
+}
+
+func TestAuthorize(t *testing.T) {
+	d := setupAuthDaemon(t)
+	alice := withCaller(context.Background(), caller{User: "alice"})
+
+	request := func(action string, params map[string]string) *Request {
+		data, _ := json.Marshal(params)
+		return &Request{Action: action, Data: data}
+	}
+
+	t.Run("allows owner", func(t *testing.T) {
+		assert.NoError(t, d.authorize(alice, request("stop", map[string]string{"name": "alice-puck"})))
+		assert.NoError(t, d.authorize(alice, request("snapshot-list", map[string]string{"puck_name": "alice-puck"})))
+		assert.NoError(t, d.authorize(alice, request("snapshot-inspect", map[string]string{"puck_name": "alice-puck"})))
+	})
+
+	t.Run("rejects other users' pucks", func(t *testing.T) {
+		err := d.authorize(alice, request("stop", map[string]string{"name": "bob-puck"}))
+		assert.ErrorContains(t, err, "permission denied")
+
+		err = d.authorize(alice, request("snapshot-create", map[string]string{"puck_name": "bob-puck"}))
+		assert.ErrorContains(t, err, "permission denied")
+	})
+
+	t.Run("allows admins", func(t *testing.T) {
+		admin := withCaller(context.Background(), caller{User: "root", Admin: true})
+		assert.NoError(t, d.authorize(admin, request("destroy", map[string]string{"name": "bob-puck"})))
+		assert.NoError(t, d.authorize(admin, request("router-restart", nil)))
+	})
+
+	t.Run("treats requests without a caller as system", func(t *testing.T) {
+		assert.NoError(t, d.authorize(context.Background(), request("recreate", map[string]string{"name": "bob-puck"})))
+	})
+
+	t.Run("only groups pucks the caller owns", func(t *testing.T) {
+		data, _ := json.Marshal(map[string]any{"name": "demo", "pucks": []string{"alice-puck", "bob-puck"}})
+		assert.ErrorContains(t, d.authorize(alice, &Request{Action: "group-add", Data: data}), "permission denied")
+
+		data, _ = json.Marshal(map[string]any{"name": "demo", "pucks": []string{"alice-puck"}})
+		assert.NoError(t, d.authorize(alice, &Request{Action: "group-add", Data: data}))
+	})
+
+	t.Run("allows actions that only show the caller's own", func(t *testing.T) {
+		assert.NoError(t, d.authorize(alice, request("list", nil)))
+		assert.NoError(t, d.authorize(alice, request("routes", nil)))
+		assert.NoError(t, d.authorize(alice, request("destroy-all", nil)))
+	})
+
+	t.Run("restricts actions without a case to admins", func(t *testing.T) {
+		assert.ErrorContains(t, d.authorize(alice, request("new-action", nil)), "admin")
+	})
+
+	t.Run("restricts router restart to admins", func(t *testing.T) {
+		assert.ErrorContains(t, d.authorize(alice, request("router-restart", nil)), "admin")
+	})
+
+	t.Run("restricts gc to admins", func(t *testing.T) {
+		assert.ErrorContains(t, d.authorize(alice, request("gc", nil)), "admin")
+	})
+
+	t.Run("restricts db check to admins", func(t *testing.T) {
+		assert.ErrorContains(t, d.authorize(alice, request("db-check", nil)), "admin")
+	})
+
+	t.Run("restricts machine resources to admins", func(t *testing.T) {
+		assert.ErrorContains(t, d.authorize(alice, request("machine-resources", nil)), "admin")
+		assert.ErrorContains(t, d.authorize(alice, request("machine-set-resources", nil)), "admin")
+	})
+
+	t.Run("checks the puck behind a share link", func(t *testing.T) {
+		share, err := d.manager.CreateShare(context.Background(), puck.ShareCreateOptions{PuckName: "bob-puck", TTL: time.Hour})
+		require.NoError(t, err)
+
+		err = d.authorize(alice, request("share-revoke", map[string]string{"id": share.ID}))
+		assert.ErrorContains(t, err, "permission denied")
+		err = d.authorize(alice, request("share-create", map[string]string{"name": "bob-puck"}))
+		assert.ErrorContains(t, err, "permission denied")
+		assert.NoError(t, d.authorize(alice, request("share-revoke", map[string]string{"id": "missing"})))
+	})
+
+	t.Run("checks the puck behind a route alias", func(t *testing.T) {
+		_, _, err := d.manager.SetRouteAlias(context.Background(), puck.RouteAliasOptions{Path: "/api", PuckName: "bob-puck"})
+		require.NoError(t, err)
+
+		err = d.authorize(alice, request("alias-set", map[string]string{"path": "/api", "name": "alice-puck"}))
+		assert.ErrorContains(t, err, "permission denied")
+		err = d.authorize(alice, request("alias-remove", map[string]string{"path": "api/"}))
+		assert.ErrorContains(t, err, "permission denied")
+		err = d.authorize(alice, request("alias-set", map[string]string{"path": "/docs", "name": "bob-puck"}))
+		assert.ErrorContains(t, err, "permission denied")
+		assert.NoError(t, d.authorize(alice, request("alias-set", map[string]string{"path": "/docs", "name": "alice-puck"})))
+	})
+
+	t.Run("promote needs both pucks", func(t *testing.T) {
+		err := d.authorize(alice, request("promote", map[string]string{"name": "alice-puck", "replace": "bob-puck"}))
+		assert.ErrorContains(t, err, "permission denied")
+		err = d.authorize(alice, request("promote", map[string]string{"name": "bob-puck", "replace": "alice-puck"}))
+		assert.ErrorContains(t, err, "permission denied")
+	})
+
+	t.Run("only mounts directories the caller owns", func(t *testing.T) {
+		data, _ := json.Marshal(puck.CreateOptions{Name: "dev", Mounts: []store.Mount{{Source: t.TempDir(), Target: "/workspace"}}})
+		err := d.authorize(alice, &Request{Action: "create", Data: data})
+		assert.ErrorContains(t, err, "permission denied")
+
+		if runtime.GOOS == "linux" {
+			owner := withCaller(context.Background(), caller{User: currentUser()})
+			assert.NoError(t, d.authorize(owner, &Request{Action: "create", Data: data}))
+		}
+	})
+
+	t.Run("checks where missing mount directories would be made", func(t *testing.T) {
+		missing := filepath.Join(t.TempDir(), "a", "b")
+		data, _ := json.Marshal(puck.CreateOptions{Name: "dev", Mounts: []store.Mount{{Source: missing, Target: "/workspace"}}, CreateMountDirs: true})
+		err := d.authorize(alice, &Request{Action: "create", Data: data})
+		assert.ErrorContains(t, err, "permission denied")
+		assert.NotContains(t, err.Error(), missing)
+
+		if runtime.GOOS == "linux" {
+			owner := withCaller(context.Background(), caller{User: currentUser()})
+			assert.NoError(t, d.authorize(owner, &Request{Action: "create", Data: data}))
+		}
+	})
+
+	t.Run("only keeps volumes in data directories the caller owns", func(t *testing.T) {
+		data, _ := json.Marshal(puck.CreateOptions{Name: "dev", DataDir: t.TempDir()})
+		err := d.authorize(alice, &Request{Action: "create", Data: data})
+		assert.ErrorContains(t, err, "permission denied")
+
+		pool, _ := json.Marshal(store.Pool{Name: "node", Size: 1, Options: data})
+		err = d.authorize(alice, &Request{Action: "pool-create", Data: pool})
+		assert.ErrorContains(t, err, "permission denied")
+
+		if runtime.GOOS == "linux" {
+			owner := withCaller(context.Background(), caller{User: currentUser()})
+			assert.NoError(t, d.authorize(owner, &Request{Action: "create", Data: data}))
+		}
+	})
+
+	t.Run("only restores checkpoints the caller owns", func(t *testing.T) {
+		archive := filepath.Join(t.TempDir(), "web.tar.gz")
+		require.NoError(t, os.WriteFile(archive, nil, 0644))
+		data, _ := json.Marshal(puck.CreateOptions{Name: "dev", FromCheckpoint: archive})
+		err := d.authorize(alice, &Request{Action: "create", Data: data})
+		assert.ErrorContains(t, err, "permission denied")
+
+		if runtime.GOOS == "linux" {
+			owner := withCaller(context.Background(), caller{User: currentUser()})
+			assert.NoError(t, d.authorize(owner, &Request{Action: "create", Data: data}))
+		}
+	})
+
+	t.Run("keeps callers out of the data directory", func(t *testing.T) {
+		owner := withCaller(context.Background(), caller{User: currentUser()})
+		inside := filepath.Join(d.cfg.DataDir, "pucks", "bob-puck")
+		require.NoError(t, os.MkdirAll(inside, 0755))
+		for _, opts := range []puck.CreateOptions{
+			{Name: "dev", FromCheckpoint: filepath.Join(d.cfg.DataDir, "test.db")},
+			{Name: "dev", Mounts: []store.Mount{{Source: inside, Target: "/workspace"}}},
+			{Name: "dev", Mounts: []store.Mount{{Source: filepath.Join(inside, "..", "..", "new"), Target: "/workspace"}}, CreateMountDirs: true},
+			{Name: "dev", DataDir: d.cfg.DataDir},
+		} {
+			data, _ := json.Marshal(opts)
+			err := d.authorize(owner, &Request{Action: "create", Data: data})
+			assert.ErrorContains(t, err, "data directory")
+		}
+	})
+
+	t.Run("replacing needs the puck being replaced", func(t *testing.T) {
+		data, _ := json.Marshal(puck.CreateOptions{Name: "bob-puck", Replace: true})
+		err := d.authorize(alice, &Request{Action: "create", Data: data})
+		assert.ErrorContains(t, err, "permission denied")
+
+		data, _ = json.Marshal(puck.CreateOptions{Name: "alice-puck", Replace: true})
+		assert.NoError(t, d.authorize(alice, &Request{Action: "create", Data: data}))
+	})
+
+	t.Run("leaves missing pucks to the handler", func(t *testing.T) {
+		assert.NoError(t, d.authorize(alice, request("get", map[string]string{"name": "missing"})))
+	})
+}
+
+func TestHandleListOwnership(t *testing.T) {
+	d := setupAuthDaemon(t)
+
+	list := func(c caller, allUsers bool) ([]string, Response) {
+		data, _ := json.Marshal(map[string]bool{"all_users": allUsers})
+		resp := d.handleRequest(withCaller(context.Background(), c), &Request{Action: "list", Data: data})
+		var pucks []*store.Puck
+		json.Unmarshal(resp.Data, &pucks)
+		var names []string
+		for _, p := range pucks {
+			names = append(names, p.Name)
+		}
+		return names, resp
+	}
+
+	t.Run("lists own pucks by default", func(t *testing.T) {
+		names, resp := list(caller{User: "alice"}, false)
+		require.True(t, resp.Success)
+		assert.Equal(t, []string{"alice-puck"}, names)
+
+		names, _ = list(caller{User: "root", Admin: true}, false)
+		assert.Empty(t, names)
+	})
+
+	t.Run("lists all users for admins", func(t *testing.T) {
+		names, resp := list(caller{User: "root", Admin: true}, true)
+		require.True(t, resp.Success)
+		assert.ElementsMatch(t, []string{"alice-puck", "bob-puck"}, names)
+	})
+
+	t.Run("rejects all users for non-admins", func(t *testing.T) {
+		_, resp := list(caller{User: "alice"}, true)
+		assert.False(t, resp.Success)
+		assert.Contains(t, resp.Error, "admin")
+	})
+}
+
+func TestCallerForConn(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "test.sock")
+	ln, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	defer ln.Close()
+
+	go func() {
+		conn, err := net.Dial("unix", socketPath)
+		if err == nil {
+			defer conn.Close()
+		}
+	}()
+
+	conn, err := ln.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	d := &Daemon{cfg: &config.Config{}}
+	c := d.callerForConn(conn)
+
+	// The test process is on both ends, so it is the daemon's own user
+	assert.Equal(t, usernameForUID(os.Getuid()), c.User)
+	assert.True(t, c.Admin)
+}
+
+func TestHandleProjectStatusOwnership(t *testing.T) {
+	d := setupAuthDaemon(t)
+	ctx := context.Background()
+	for _, p := range []*store.Puck{
+		{ID: "a2", Name: "alice-demo", Image: "fedora", Status: store.StatusRunning, VolumeDir: d.cfg.DataDir, Owner: "alice", Project: "demo"},
+		{ID: "b2", Name: "bob-demo", Image: "fedora", Status: store.StatusStopped, VolumeDir: d.cfg.DataDir, Owner: "bob", Project: "demo"},
+	} {
+		require.NoError(t, d.store.CreatePuck(ctx, p))
+	}
+
+	status := func(c caller) puck.ProjectStatus {
+		resp := d.handleRequest(withCaller(ctx, c), &Request{Action: "project-status"})
+		require.True(t, resp.Success, resp.Error)
+		var statuses []puck.ProjectStatus
+		require.NoError(t, json.Unmarshal(resp.Data, &statuses))
+		require.Len(t, statuses, 1)
+		return statuses[0]
+	}
+
+	s := status(caller{User: "alice"})
+	assert.Equal(t, 2, s.Pucks)
+	assert.Equal(t, []string{"alice-demo"}, s.Members)
+
+	s = status(caller{User: "root", Admin: true})
+	assert.Equal(t, []string{"alice-demo", "bob-demo"}, s.Members)
+}
@@ -1,29 +1,46 @@
 package daemon
 
 import (
+	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net"
+	"os"
+	"sync"
 	"time"
 
 	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/sandwich-labs/puck/internal/hooks"
+	"github.com/sandwich-labs/puck/internal/network"
+	"github.com/sandwich-labs/puck/internal/podman"
 	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/sandwich-labs/puck/internal/scan"
 	"github.com/sandwich-labs/puck/internal/store"
 )
 
 // Client communicates with the puckd daemon
 type Client struct {
-	socketPath string
+	socketPath   string
+	dial         func() (net.Conn, error) // overrides socketPath for remote contexts
+	remote       string                   // name of the remote context dialed, if any
+	pullProgress func(podman.PullProgress)
+
+	snapshotProgress func(puck.SnapshotProgress)
 }
 
-// NewClient creates a new daemon client
-func NewClient() (*Client, error) {
-	cfg, err := config.Load()
+// NewClientForContext creates a client for a specific context
+func NewClientForContext(c config.Context) (*Client, error) {
+	dial, err := dialerForContext(c)
 	if err != nil {
 		return nil, err
 	}
-	return &Client{socketPath: cfg.DaemonSocket}, nil
+	client := &Client{socketPath: c.Socket, dial: dial}
+	if c.Type != config.ContextUnix {
+		client.remote = c.Name
+	}
+	return client, nil
 }
 
 // NewClientWithSocket creates a client with a specific socket path
@@ -31,29 +48,80 @@ func NewClientWithSocket(socketPath string) *Client {
 	return &Client{socketPath: socketPath}
 }
 
+// SetPullProgress asks the daemon to report the progress of image pulls
+// made for this client's requests to fn
+func (c *Client) SetPullProgress(fn func(podman.PullProgress)) {
+	c.pullProgress = fn
+}
+
+// SetSnapshotProgress asks the daemon to report the progress of snapshots
+// created for this client's requests to fn
+func (c *Client) SetSnapshotProgress(fn func(puck.SnapshotProgress)) {
+	c.snapshotProgress = fn
+}
+
+func (c *Client) connect() (net.Conn, error) {
+	if c.dial != nil {
+		return c.dial()
+	}
+	return net.DialTimeout("unix", c.socketPath, dialTimeout)
+}
+
+// ConnectError is the daemon not answering at all, as when it isn't
+// running
+type ConnectError struct {
+	Err     error
+	Context string // the remote context dialed; empty for the local daemon
+}
+
+func (e *ConnectError) Error() string {
+	if e.Context != "" {
+		return fmt.Sprintf("connecting to the daemon of context '%s': %v", e.Context, e.Err)
+	}
+	return fmt.Sprintf("connecting to daemon: %v (is puckd running?)", e.Err)
+}
+
+func (e *ConnectError) Unwrap() error { return e.Err }
+
 func (c *Client) send(req *Request) (*Response, error) {
-	conn, err := net.DialTimeout("unix", c.socketPath, 5*time.Second)
+	conn, err := c.connect()
 	if err != nil {
-		return nil, fmt.Errorf("connecting to daemon: %w (is puckd running?)", err)
+		return nil, &ConnectError{Err: err, Context: c.remote}
 	}
 	defer conn.Close()
 
-	// Set deadlines
-	conn.SetDeadline(time.Now().Add(30 * time.Second))
+	// Give the daemon as long as it gives the action, plus time to answer
+	conn.SetDeadline(time.Now().Add(actionTimeout(req.Action) + responseWriteTimeout))
 
 	encoder := json.NewEncoder(conn)
-	decoder := json.NewDecoder(conn)
+	decoder := json.NewDecoder(&limitedReader{r: conn, n: maxResponseSize})
 
+	req.Stream = c.pullProgress != nil || c.snapshotProgress != nil
 	if err := encoder.Encode(req); err != nil {
 		return nil, fmt.Errorf("sending request: %w", err)
 	}
 
-	var resp Response
-	if err := decoder.Decode(&resp); err != nil {
-		return nil, fmt.Errorf("reading response: %w", err)
+	for {
+		var resp Response
+		if err := decoder.Decode(&resp); err != nil {
+			if errors.Is(err, errTooLarge) {
+				err = fmt.Errorf("response exceeds %d bytes", maxResponseSize)
+			}
+			return nil, fmt.Errorf("reading response: %w", err)
+		}
+		switch {
+		case resp.Pull != nil:
+			if c.pullProgress != nil {
+				c.pullProgress(*resp.Pull)
+			}
+		case resp.Snapshot != nil:
+			if c.snapshotProgress != nil {
+				c.snapshotProgress(*resp.Snapshot)
+			}
+		default:
+			return &resp, nil
+		}
 	}
-
-	return &resp, nil
 }
 
 // Ping checks if the daemon is running
@@ -63,13 +131,72 @@ func (c *Client) Ping() error {
 		return err
 	}
 	if !resp.Success {
-		return errors.New(resp.Error)
+		return resp.err()
 	}
 	return nil
 }
 
-// Create creates a new puck
+// PodmanStatus reports whether the daemon can reach Podman
+func (c *Client) PodmanStatus() (*PodmanStatus, error) {
+	resp, err := c.send(&Request{Action: "podman-status"})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var status PodmanStatus
+	if err := json.Unmarshal(resp.Data, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Info describes the daemon: its build, the Podman and CRIU it drives, and
+// the features it has turned on
+func (c *Client) Info() (*Info, error) {
+	resp, err := c.send(&Request{Action: "info"})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var info Info
+	if err := json.Unmarshal(resp.Data, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Maintenance turns the daemon's read-only maintenance mode on or off,
+// giving the reason when turning it on. With enabled nil it only reports
+// the current state.
+func (c *Client) Maintenance(enabled *bool, reason string) (*Maintenance, error) {
+	data, _ := json.Marshal(map[string]any{"enabled": enabled, "reason": reason})
+	resp, err := c.send(&Request{Action: "maintenance", Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var m Maintenance
+	if err := json.Unmarshal(resp.Data, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Create creates a new puck. Label templates see the current directory
+// as its Cwd unless opts has one.
 func (c *Client) Create(opts puck.CreateOptions) (*store.Puck, error) {
+	if opts.Cwd == "" {
+		opts.Cwd, _ = os.Getwd()
+	}
 	data, err := json.Marshal(opts)
 	if err != nil {
 		return nil, err
@@ -80,7 +207,7 @@ func (c *Client) Create(opts puck.CreateOptions) (*store.Puck, error) {
 		return nil, err
 	}
 	if !resp.Success {
-		return nil, errors.New(resp.Error)
+		return nil, resp.err()
 	}
 
 	var p store.Puck
@@ -90,14 +217,33 @@ func (c *Client) Create(opts puck.CreateOptions) (*store.Puck, error) {
 	return &p, nil
 }
 
-// List returns all pucks
+// List returns the caller's pucks
 func (c *Client) List() ([]*store.Puck, error) {
-	resp, err := c.send(&Request{Action: "list"})
+	return c.list(false, false)
+}
+
+// ListAllUsers returns every user's pucks; only admins may call it
+func (c *Client) ListAllUsers() ([]*store.Puck, error) {
+	return c.list(true, false)
+}
+
+// ListUsage is List, or ListAllUsers with allUsers, with the CPU and
+// memory use of running pucks filled in
+func (c *Client) ListUsage(allUsers bool) ([]*store.Puck, error) {
+	return c.list(allUsers, true)
+}
+
+func (c *Client) list(allUsers, usage bool) ([]*store.Puck, error) {
+	req := &Request{Action: "list"}
+	if allUsers || usage {
+		req.Data, _ = json.Marshal(map[string]bool{"all_users": allUsers, "usage": usage})
+	}
+	resp, err := c.send(req)
 	if err != nil {
 		return nil, err
 	}
 	if !resp.Success {
-		return nil, errors.New(resp.Error)
+		return nil, resp.err()
 	}
 
 	var pucks []*store.Puck
@@ -107,6 +253,29 @@ func (c *Client) List() ([]*store.Puck, error) {
 	return pucks, nil
 }
 
+// WaitChange waits for the daemon's pucks to change after generation
+// since, or for timeout to pass with nothing changed, and returns the
+// generation to wait from next. A since of 0 returns the current
+// generation at once, unless nothing has changed since the daemon started.
+func (c *Client) WaitChange(since uint64, timeout time.Duration) (uint64, error) {
+	data, _ := json.Marshal(map[string]any{"since": since, "timeout": int(timeout.Seconds())})
+	resp, err := c.send(&Request{Action: "watch", Data: data})
+	if err != nil {
+		return 0, err
+	}
+	if !resp.Success {
+		return 0, resp.err()
+	}
+
+	var result struct {
+		Generation uint64 `json:"generation"`
+	}
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return 0, err
+	}
+	return result.Generation, nil
+}
+
 // Get retrieves a puck by name
 func (c *Client) Get(name string) (*store.Puck, error) {
 	data, _ := json.Marshal(map[string]string{"name": name})
@@ -115,7 +284,7 @@ func (c *Client) Get(name string) (*store.Puck, error) {
 		return nil, err
 	}
 	if !resp.Success {
-		return nil, errors.New(resp.Error)
+		return nil, resp.err()
 	}
 
 	var p store.Puck
@@ -133,24 +302,89 @@ func (c *Client) Start(name string) error {
 		return err
 	}
 	if !resp.Success {
-		return errors.New(resp.Error)
+		return resp.err()
 	}
 	return nil
 }
 
 // Stop stops a puck
 func (c *Client) Stop(name string) error {
-	data, _ := json.Marshal(map[string]string{"name": name})
+	return c.StopWithOptions(puck.StopOptions{Name: name})
+}
+
+// StopWithOptions stops a running puck with a custom timeout
+func (c *Client) StopWithOptions(opts puck.StopOptions) error {
+	data, _ := json.Marshal(opts)
 	resp, err := c.send(&Request{Action: "stop", Data: data})
 	if err != nil {
 		return err
 	}
 	if !resp.Success {
-		return errors.New(resp.Error)
+		return resp.err()
+	}
+	return nil
+}
+
+// Kill sends a signal to a puck, SIGKILL if signal is empty
+func (c *Client) Kill(name, signal string) error {
+	data, _ := json.Marshal(map[string]string{"name": name, "signal": signal})
+	resp, err := c.send(&Request{Action: "kill", Data: data})
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return resp.err()
 	}
 	return nil
 }
 
+// Recreate replaces a puck's container from the latest version of its image
+func (c *Client) Recreate(opts puck.RecreateOptions) (*store.Puck, error) {
+	data, _ := json.Marshal(opts)
+	resp, err := c.send(&Request{Action: "recreate", Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var p store.Puck
+	if err := json.Unmarshal(resp.Data, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Rollback restores a puck to the snapshot taken before it was last
+// recreated
+func (c *Client) Rollback(name string) (*store.Snapshot, error) {
+	data, _ := json.Marshal(map[string]string{"name": name})
+	return c.rollback(data)
+}
+
+// RollbackTo restores a puck to its newest snapshot taken at or before at
+func (c *Client) RollbackTo(name string, at time.Time) (*store.Snapshot, error) {
+	data, _ := json.Marshal(map[string]interface{}{"name": name, "at": at})
+	return c.rollback(data)
+}
+
+func (c *Client) rollback(data json.RawMessage) (*store.Snapshot, error) {
+	resp, err := c.send(&Request{Action: "rollback", Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var snapshot store.Snapshot
+	if err := json.Unmarshal(resp.Data, &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
 // Destroy removes a puck
 func (c *Client) Destroy(name string, force bool) error {
 	data, _ := json.Marshal(map[string]interface{}{"name": name, "force": force})
@@ -159,83 +393,1094 @@ func (c *Client) Destroy(name string, force bool) error {
 		return err
 	}
 	if !resp.Success {
-		return errors.New(resp.Error)
+		return resp.err()
 	}
 	return nil
 }
 
-// DestroyAll removes all pucks
-func (c *Client) DestroyAll(force bool) ([]string, error) {
+// DestroyAll removes all of the caller's pucks, returning how each one
+// went
+func (c *Client) DestroyAll(force bool) ([]puck.DestroyResult, error) {
 	data, _ := json.Marshal(map[string]interface{}{"force": force})
 	resp, err := c.send(&Request{Action: "destroy-all", Data: data})
 	if err != nil {
 		return nil, err
 	}
 	if !resp.Success {
-		return nil, errors.New(resp.Error)
+		return nil, resp.err()
 	}
 
-	var destroyed []string
-	if err := json.Unmarshal(resp.Data, &destroyed); err != nil {
+	var results []puck.DestroyResult
+	if err := json.Unmarshal(resp.Data, &results); err != nil {
 		return nil, err
 	}
-	return destroyed, nil
+	return results, nil
 }
 
-// SnapshotCreate creates a checkpoint snapshot of a puck
-func (c *Client) SnapshotCreate(puckName, snapshotName string, leaveRunning bool) (*store.Snapshot, error) {
-	data, _ := json.Marshal(puck.SnapshotCreateOptions{
-		PuckName:     puckName,
-		SnapshotName: snapshotName,
-		LeaveRunning: leaveRunning,
-	})
-	resp, err := c.send(&Request{Action: "snapshot-create", Data: data})
+// RouteSet replaces the router settings for a puck
+func (c *Client) RouteSet(name string, rc store.RouteConfig) (*store.Puck, error) {
+	data, _ := json.Marshal(map[string]interface{}{"name": name, "route": rc})
+	resp, err := c.send(&Request{Action: "route-set", Data: data})
 	if err != nil {
 		return nil, err
 	}
 	if !resp.Success {
-		return nil, errors.New(resp.Error)
+		return nil, resp.err()
 	}
 
-	var snapshot store.Snapshot
-	if err := json.Unmarshal(resp.Data, &snapshot); err != nil {
+	var p store.Puck
+	if err := json.Unmarshal(resp.Data, &p); err != nil {
 		return nil, err
 	}
-	return &snapshot, nil
+	return &p, nil
 }
 
-// SnapshotRestore restores a puck from a checkpoint snapshot
-func (c *Client) SnapshotRestore(puckName, snapshotName string) error {
-	data, _ := json.Marshal(puck.SnapshotRestoreOptions{
-		PuckName:     puckName,
-		SnapshotName: snapshotName,
-	})
-	resp, err := c.send(&Request{Action: "snapshot-restore", Data: data})
+// SetResources changes a puck's CPU and memory limits
+func (c *Client) SetResources(name string, res store.Resources) (*store.Puck, error) {
+	data, _ := json.Marshal(map[string]interface{}{"name": name, "resources": res})
+	return c.puckRequest("set-resources", data)
+}
+
+// EndpointAdd serves another of a puck's container ports through the router
+func (c *Client) EndpointAdd(name string, endpoint puck.EndpointSpec) (*store.Endpoint, error) {
+	data, _ := json.Marshal(map[string]interface{}{"name": name, "endpoint": endpoint})
+	resp, err := c.send(&Request{Action: "endpoint-add", Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var e store.Endpoint
+	if err := json.Unmarshal(resp.Data, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// EndpointList lists a puck's endpoints
+func (c *Client) EndpointList(name string) ([]*store.Endpoint, error) {
+	data, _ := json.Marshal(map[string]string{"name": name})
+	resp, err := c.send(&Request{Action: "endpoint-list", Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var endpoints []*store.Endpoint
+	if err := json.Unmarshal(resp.Data, &endpoints); err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
+
+// EndpointRemove stops serving one of a puck's endpoints
+func (c *Client) EndpointRemove(name, endpoint string) error {
+	data, _ := json.Marshal(map[string]string{"name": name, "endpoint": endpoint})
+	resp, err := c.send(&Request{Action: "endpoint-remove", Data: data})
 	if err != nil {
 		return err
 	}
 	if !resp.Success {
-		return errors.New(resp.Error)
+		return resp.err()
 	}
 	return nil
 }
 
-// SnapshotList returns all snapshots for a puck
-func (c *Client) SnapshotList(puckName string) ([]*store.Snapshot, error) {
-	data, _ := json.Marshal(map[string]string{"puck_name": puckName})
-	resp, err := c.send(&Request{Action: "snapshot-list", Data: data})
+// SetHostPort pins a puck to a host port, or unpins it if port is 0
+func (c *Client) SetHostPort(name string, port int) (*store.Puck, error) {
+	data, _ := json.Marshal(map[string]interface{}{"name": name, "port": port})
+	return c.puckRequest("set-host-port", data)
+}
+
+// SetNotes sets what a puck is for, or clears it with ""
+func (c *Client) SetNotes(name, notes string) (*store.Puck, error) {
+	data, _ := json.Marshal(map[string]interface{}{"name": name, "notes": notes})
+	return c.puckRequest("set-notes", data)
+}
+
+// SetTTL has the daemon destroy a puck ttl from now, or never with zero,
+// and sets whether it archives it first unless snapshot is nil
+func (c *Client) SetTTL(name string, ttl time.Duration, snapshot *bool) (*store.Puck, error) {
+	data, _ := json.Marshal(map[string]interface{}{"name": name, "ttl": ttl, "snapshot": snapshot})
+	return c.puckRequest("set-ttl", data)
+}
+
+// EgressSet changes where a puck may open outbound connections
+func (c *Client) EgressSet(name string, egress store.EgressPolicy) (*store.Puck, error) {
+	data, _ := json.Marshal(map[string]interface{}{"name": name, "egress": egress})
+	return c.puckRequest("egress-set", data)
+}
+
+// EnvSet sets and unsets a puck's environment variables
+func (c *Client) EnvSet(opts puck.EnvOptions) (*store.Puck, error) {
+	data, _ := json.Marshal(opts)
+	return c.puckRequest("env-set", data)
+}
+
+// SnapshotPolicySet changes a puck's snapshot defaults and schedule
+func (c *Client) SnapshotPolicySet(opts puck.SnapshotPolicyOptions) (*store.Puck, error) {
+	data, _ := json.Marshal(opts)
+	return c.puckRequest("snapshot-policy-set", data)
+}
+
+// TailnetShare serves a puck as its own tailnet node with the given ACL tags
+func (c *Client) TailnetShare(name string, tags []string) (*store.Puck, error) {
+	data, _ := json.Marshal(map[string]interface{}{"name": name, "tags": tags})
+	return c.puckRequest("tailnet-share", data)
+}
+
+// TailnetUnshare removes a puck's tailnet node
+func (c *Client) TailnetUnshare(name string) (*store.Puck, error) {
+	data, _ := json.Marshal(map[string]string{"name": name})
+	return c.puckRequest("tailnet-unshare", data)
+}
+
+// puckRequest sends an action that responds with the updated puck
+func (c *Client) puckRequest(action string, data json.RawMessage) (*store.Puck, error) {
+	resp, err := c.send(&Request{Action: action, Data: data})
 	if err != nil {
 		return nil, err
 	}
 	if !resp.Success {
-		return nil, errors.New(resp.Error)
+		return nil, resp.err()
 	}
 
-	var snapshots []*store.Snapshot
-	if err := json.Unmarshal(resp.Data, &snapshots); err != nil {
+	var p store.Puck
+	if err := json.Unmarshal(resp.Data, &p); err != nil {
 		return nil, err
 	}
-	return snapshots, nil
+	return &p, nil
+}
+
+// ShareCreate creates an expiring public link to a puck. A zero ttl uses
+// the daemon's default.
+func (c *Client) ShareCreate(name string, ttl time.Duration) (*store.Share, error) {
+	data, _ := json.Marshal(map[string]interface{}{"name": name, "ttl": ttl})
+	resp, err := c.send(&Request{Action: "share-create", Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var share store.Share
+	if err := json.Unmarshal(resp.Data, &share); err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+// ShareList lists share links for a puck, or all visible links if name is empty
+func (c *Client) ShareList(name string) ([]*store.Share, error) {
+	data, _ := json.Marshal(map[string]string{"name": name})
+	resp, err := c.send(&Request{Action: "share-list", Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var shares []*store.Share
+	if err := json.Unmarshal(resp.Data, &shares); err != nil {
+		return nil, err
+	}
+	return shares, nil
+}
+
+// ShareRevoke revokes a share link by ID
+func (c *Client) ShareRevoke(id string) error {
+	data, _ := json.Marshal(map[string]string{"id": id})
+	resp, err := c.send(&Request{Action: "share-revoke", Data: data})
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return resp.err()
+	}
+	return nil
+}
+
+// AliasSet serves a puck at an extra router path, taking the path over
+// from whichever puck served it before
+func (c *Client) AliasSet(path, name string) (*store.RouteAlias, error) {
+	data, _ := json.Marshal(map[string]string{"path": path, "name": name})
+	resp, err := c.send(&Request{Action: "alias-set", Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var alias store.RouteAlias
+	if err := json.Unmarshal(resp.Data, &alias); err != nil {
+		return nil, err
+	}
+	return &alias, nil
+}
+
+// AliasList lists route aliases for a puck, or all of them if name is empty
+func (c *Client) AliasList(name string) ([]*store.RouteAlias, error) {
+	data, _ := json.Marshal(map[string]string{"name": name})
+	resp, err := c.send(&Request{Action: "alias-list", Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var aliases []*store.RouteAlias
+	if err := json.Unmarshal(resp.Data, &aliases); err != nil {
+		return nil, err
+	}
+	return aliases, nil
+}
+
+// GroupAdd adds pucks to one of the caller's groups, creating it if needed
+func (c *Client) GroupAdd(name string, pucks []string) (*store.Group, error) {
+	data, _ := json.Marshal(map[string]interface{}{"name": name, "pucks": pucks})
+	return c.groupRequest("group-add", data)
+}
+
+// GroupRemove removes pucks from one of the caller's groups, or the whole
+// group when pucks is empty, returning what is left of it or nil
+func (c *Client) GroupRemove(name string, pucks []string) (*store.Group, error) {
+	data, _ := json.Marshal(map[string]interface{}{"name": name, "pucks": pucks})
+	return c.groupRequest("group-remove", data)
+}
+
+// Group returns one of the caller's groups
+func (c *Client) Group(name string) (*store.Group, error) {
+	data, _ := json.Marshal(map[string]string{"name": name})
+	return c.groupRequest("group-get", data)
+}
+
+func (c *Client) groupRequest(action string, data json.RawMessage) (*store.Group, error) {
+	resp, err := c.send(&Request{Action: action, Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var g *store.Group
+	if err := json.Unmarshal(resp.Data, &g); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// PoolCreate creates one of the caller's pools, or changes its size and
+// options, returning it
+func (c *Client) PoolCreate(pool *store.Pool) (*store.Pool, error) {
+	data, _ := json.Marshal(pool)
+	resp, err := c.send(&Request{Action: "pool-create", Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var p *store.Pool
+	if err := json.Unmarshal(resp.Data, &p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// PoolList returns the caller's pools, with how many pucks each has ready
+func (c *Client) PoolList() ([]*store.Pool, error) {
+	resp, err := c.send(&Request{Action: "pool-list"})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var pools []*store.Pool
+	if err := json.Unmarshal(resp.Data, &pools); err != nil {
+		return nil, err
+	}
+	return pools, nil
+}
+
+// PoolRemove removes one of the caller's pools, destroying the pucks
+// waiting in it
+func (c *Client) PoolRemove(name string) error {
+	data, _ := json.Marshal(map[string]string{"name": name})
+	resp, err := c.send(&Request{Action: "pool-remove", Data: data})
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return resp.err()
+	}
+	return nil
+}
+
+// GroupList returns the caller's groups
+func (c *Client) GroupList() ([]*store.Group, error) {
+	resp, err := c.send(&Request{Action: "group-list"})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var groups []*store.Group
+	if err := json.Unmarshal(resp.Data, &groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// AliasRemove stops serving a route alias
+func (c *Client) AliasRemove(path string) error {
+	data, _ := json.Marshal(map[string]string{"path": path})
+	resp, err := c.send(&Request{Action: "alias-remove", Data: data})
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return resp.err()
+	}
+	return nil
+}
+
+// Promote points another puck's route and aliases at a puck, optionally
+// stopping the puck it replaces
+func (c *Client) Promote(opts puck.PromoteOptions) (*puck.PromoteResult, error) {
+	data, _ := json.Marshal(opts)
+	resp, err := c.send(&Request{Action: "promote", Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var result puck.PromoteResult
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// TailnetStatus reports the daemon's tailnet nodes and their state
+func (c *Client) TailnetStatus() (*TailnetStatus, error) {
+	resp, err := c.send(&Request{Action: "tailnet-status"})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var st TailnetStatus
+	if err := json.Unmarshal(resp.Data, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// Routes returns the route table the router is serving
+func (c *Client) Routes() ([]network.RouteEntry, error) {
+	resp, err := c.send(&Request{Action: "routes"})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var routes []network.RouteEntry
+	if err := json.Unmarshal(resp.Data, &routes); err != nil {
+		return nil, err
+	}
+	return routes, nil
+}
+
+// RouterStatus returns the HTTP router's state, including its actual port
+func (c *Client) RouterStatus() (*network.RouterStatus, error) {
+	return c.routerRequest("router-status")
+}
+
+// RouterRestart restarts the HTTP router, retrying the configured port
+func (c *Client) RouterRestart() (*network.RouterStatus, error) {
+	return c.routerRequest("router-restart")
+}
+
+func (c *Client) routerRequest(action string) (*network.RouterStatus, error) {
+	resp, err := c.send(&Request{Action: action})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var st network.RouterStatus
+	if err := json.Unmarshal(resp.Data, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// Hooks returns the installed hooks and their most recent results
+func (c *Client) Hooks() (*hooks.Status, error) {
+	resp, err := c.send(&Request{Action: "hooks"})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var st hooks.Status
+	if err := json.Unmarshal(resp.Data, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// Images lists local images and the state of the warm image cache
+func (c *Client) Images() ([]puck.ImageInfo, error) {
+	resp, err := c.send(&Request{Action: "images"})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var images []puck.ImageInfo
+	if err := json.Unmarshal(resp.Data, &images); err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+// ImageTree reports which layers the images of pucks share
+func (c *Client) ImageTree() (*puck.ImageTree, error) {
+	resp, err := c.send(&Request{Action: "image-tree"})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var tree puck.ImageTree
+	if err := json.Unmarshal(resp.Data, &tree); err != nil {
+		return nil, err
+	}
+	return &tree, nil
+}
+
+// Report sums up every puck's uptime, requests, resource use and
+// snapshots since since
+func (c *Client) Report(since time.Time) (*puck.Report, error) {
+	data, _ := json.Marshal(map[string]interface{}{"since": since})
+	resp, err := c.send(&Request{Action: "report", Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var report puck.Report
+	if err := json.Unmarshal(resp.Data, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// ExportEvents returns the events at or after since of the named puck, or
+// of every puck the caller may see when name is empty, oldest first
+func (c *Client) ExportEvents(name string, since time.Time) ([]*store.Event, error) {
+	data, _ := json.Marshal(map[string]interface{}{"name": name, "since": since})
+	resp, err := c.send(&Request{Action: "events-export", Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var events []*store.Event
+	if err := json.Unmarshal(resp.Data, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// ExportStats returns stats samples as ExportEvents returns events
+func (c *Client) ExportStats(name string, since time.Time) ([]*store.Stat, error) {
+	data, _ := json.Marshal(map[string]interface{}{"name": name, "since": since})
+	resp, err := c.send(&Request{Action: "stats-export", Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var stats []*store.Stat
+	if err := json.Unmarshal(resp.Data, &stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// Scan scans a puck's image, or the named image, for known
+// vulnerabilities
+func (c *Client) Scan(target string) (*scan.Result, error) {
+	data, _ := json.Marshal(map[string]string{"name": target})
+	resp, err := c.send(&Request{Action: "scan", Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var result scan.Result
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GC removes unused images and the build cache
+func (c *Client) GC(opts puck.GCOptions) (*puck.GCReport, error) {
+	data, _ := json.Marshal(opts)
+	resp, err := c.send(&Request{Action: "gc", Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var report puck.GCReport
+	if err := json.Unmarshal(resp.Data, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// DBCheck checks snapshot records against pucks and files, fixing what it
+// finds if asked to
+func (c *Client) DBCheck(opts puck.CheckOptions) (*puck.CheckReport, error) {
+	data, _ := json.Marshal(opts)
+	resp, err := c.send(&Request{Action: "db-check", Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var report puck.CheckReport
+	if err := json.Unmarshal(resp.Data, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// SnapshotTierStatus reports the snapshot tiering policy and which tier
+// each snapshot is in
+func (c *Client) SnapshotTierStatus() (*puck.TierStatus, error) {
+	resp, err := c.send(&Request{Action: "snapshot-tier-status"})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var status puck.TierStatus
+	if err := json.Unmarshal(resp.Data, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// SnapshotTierRun moves snapshots due for cold storage there now
+func (c *Client) SnapshotTierRun() (*puck.TierReport, error) {
+	resp, err := c.send(&Request{Action: "snapshot-tier-run"})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var report puck.TierReport
+	if err := json.Unmarshal(resp.Data, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// MachineResources returns the Podman Machine's size and what pucks need
+// of it
+func (c *Client) MachineResources() (*puck.MachineStatus, error) {
+	return c.machineRequest(&Request{Action: "machine-resources"})
+}
+
+// SetMachineResources resizes the Podman Machine, which must be stopped
+func (c *Client) SetMachineResources(res podman.MachineResources) (*puck.MachineStatus, error) {
+	data, _ := json.Marshal(res)
+	return c.machineRequest(&Request{Action: "machine-set-resources", Data: data})
+}
+
+func (c *Client) machineRequest(req *Request) (*puck.MachineStatus, error) {
+	resp, err := c.send(req)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var status puck.MachineStatus
+	if err := json.Unmarshal(resp.Data, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// History returns a puck's lifecycle events at or after since, oldest
+// first. A zero since returns the full history.
+func (c *Client) History(name string, since time.Time) ([]*store.Event, error) {
+	data, _ := json.Marshal(map[string]interface{}{
+		"name":  name,
+		"since": since,
+	})
+	resp, err := c.send(&Request{Action: "history", Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var events []*store.Event
+	if err := json.Unmarshal(resp.Data, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// ProjectStatus returns every project's usage and limits
+func (c *Client) ProjectStatus() ([]puck.ProjectStatus, error) {
+	resp, err := c.send(&Request{Action: "project-status"})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var statuses []puck.ProjectStatus
+	if err := json.Unmarshal(resp.Data, &statuses); err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+// SyncStatus returns how a puck's synced mounts are doing, or every
+// visible puck's when name is empty
+func (c *Client) SyncStatus(name string) ([]SyncStatus, error) {
+	return c.syncRequest("sync-status", name)
+}
+
+// SyncFlush syncs a puck's mounts now and returns what changed
+func (c *Client) SyncFlush(name string) ([]SyncStatus, error) {
+	return c.syncRequest("sync-flush", name)
+}
+
+func (c *Client) syncRequest(action, name string) ([]SyncStatus, error) {
+	data, _ := json.Marshal(map[string]string{"name": name})
+	resp, err := c.send(&Request{Action: action, Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var statuses []SyncStatus
+	if err := json.Unmarshal(resp.Data, &statuses); err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+// Exec runs a command in a running puck and returns how it finished
+func (c *Client) Exec(opts puck.ExecOptions) (*puck.ExecResult, error) {
+	data, _ := json.Marshal(opts)
+	resp, err := c.send(&Request{Action: "exec", Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var result puck.ExecResult
+	if err := json.Unmarshal(resp.Data, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// FSList lists a directory in a running puck, or describes the file when
+// the path is not one
+func (c *Client) FSList(opts puck.FSOptions) ([]puck.FileInfo, error) {
+	data, _ := json.Marshal(opts)
+	resp, err := c.send(&Request{Action: "fs-list", Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var files []puck.FileInfo
+	if err := json.Unmarshal(resp.Data, &files); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// FSStat describes a file in a running puck
+func (c *Client) FSStat(opts puck.FSOptions) (*puck.FileInfo, error) {
+	data, _ := json.Marshal(opts)
+	resp, err := c.send(&Request{Action: "fs-stat", Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var info puck.FileInfo
+	if err := json.Unmarshal(resp.Data, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// FSRead returns a file in a running puck, up to puck.MaxFileRead bytes
+func (c *Client) FSRead(opts puck.FSOptions) (*puck.FileContent, error) {
+	data, _ := json.Marshal(opts)
+	resp, err := c.send(&Request{Action: "fs-read", Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var content puck.FileContent
+	if err := json.Unmarshal(resp.Data, &content); err != nil {
+		return nil, err
+	}
+	return &content, nil
+}
+
+// ExecStream runs a command in a running puck with stdin, stdout and
+// stderr streamed as they are read and written, and returns its exit
+// status. A nil stdin gives the command an empty one.
+func (c *Client) ExecStream(opts puck.ExecOptions, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	data, _ := json.Marshal(opts)
+	conn, err := c.connect()
+	if err != nil {
+		return 0, &ConnectError{Err: err, Context: c.remote}
+	}
+	defer conn.Close()
+
+	// Only the handshake is bounded; the command runs as long as it likes
+	conn.SetDeadline(time.Now().Add(actionTimeout("exec-stream") + responseWriteTimeout))
+	if err := json.NewEncoder(conn).Encode(&Request{Action: "exec-stream", Data: data}); err != nil {
+		return 0, fmt.Errorf("sending request: %w", err)
+	}
+	decoder := json.NewDecoder(&limitedReader{r: conn, n: maxResponseSize})
+	var resp Response
+	if err := decoder.Decode(&resp); err != nil {
+		return 0, fmt.Errorf("reading response: %w", err)
+	}
+	if !resp.Success {
+		return 0, resp.err()
+	}
+	conn.SetDeadline(time.Time{})
+
+	go func() {
+		if stdin != nil {
+			io.Copy(&frameWriter{mu: &sync.Mutex{}, w: conn, stream: streamStdin}, stdin)
+		}
+		writeFrame(conn, streamStdin, nil)
+	}()
+
+	frames := newFrameReader(decoder, conn)
+	for {
+		stream, data, err := frames.next()
+		if err != nil {
+			return 0, fmt.Errorf("reading output: %w", err)
+		}
+		switch stream {
+		case streamStdout:
+			if _, err := stdout.Write(data); err != nil {
+				return 0, err
+			}
+		case streamStderr:
+			stderr.Write(data)
+		case streamExit:
+			if len(data) != 4 {
+				return 0, fmt.Errorf("malformed exit status")
+			}
+			return int(binary.BigEndian.Uint32(data)), nil
+		case streamError:
+			return 0, errors.New(string(data))
+		}
+	}
+}
+
+// Logs returns the last tail lines of a puck's console output, or all of
+// it when tail <= 0
+func (c *Client) Logs(name string, tail int) (string, error) {
+	data, _ := json.Marshal(map[string]interface{}{
+		"name": name,
+		"tail": tail,
+	})
+	resp, err := c.send(&Request{Action: "logs", Data: data})
+	if err != nil {
+		return "", err
+	}
+	if !resp.Success {
+		return "", resp.err()
+	}
+
+	var logs string
+	if err := json.Unmarshal(resp.Data, &logs); err != nil {
+		return "", err
+	}
+	return logs, nil
+}
+
+// SnapshotCreate creates a checkpoint snapshot of a puck. A nil
+// leaveRunning uses the puck's snapshot policy.
+func (c *Client) SnapshotCreate(puckName, snapshotName string, leaveRunning *bool, mode store.SnapshotMode, criu puck.CRIUFlags) (*store.Snapshot, error) {
+	data, _ := json.Marshal(puck.SnapshotCreateOptions{
+		PuckName:     puckName,
+		SnapshotName: snapshotName,
+		LeaveRunning: leaveRunning,
+		Mode:         mode,
+		CRIUFlags:    criu,
+	})
+	resp, err := c.send(&Request{Action: "snapshot-create", Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var snapshot store.Snapshot
+	if err := json.Unmarshal(resp.Data, &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// SnapshotAll snapshots each of the caller's running pucks, returning how
+// each one went
+func (c *Client) SnapshotAll(snapshotName string, leaveRunning *bool, mode store.SnapshotMode, criu puck.CRIUFlags) ([]puck.SnapshotResult, error) {
+	data, _ := json.Marshal(puck.SnapshotAllOptions{
+		SnapshotName: snapshotName,
+		LeaveRunning: leaveRunning,
+		Mode:         mode,
+		CRIUFlags:    criu,
+	})
+	resp, err := c.send(&Request{Action: "snapshot-all", Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var results []puck.SnapshotResult
+	if err := json.Unmarshal(resp.Data, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// SnapshotRestore restores a puck from a checkpoint snapshot. With force,
+// a checkpoint is restored even if this host may not be able to.
+func (c *Client) SnapshotRestore(opts puck.SnapshotRestoreOptions) error {
+	data, _ := json.Marshal(opts)
+	resp, err := c.send(&Request{Action: "snapshot-restore", Data: data})
+	if err != nil {
+		return err
+	}
+	if !resp.Success {
+		return resp.err()
+	}
+	return nil
+}
+
+// SnapshotStackCreate snapshots a puck together with the pucks it
+// requires. On failure the result still says how each member went.
+func (c *Client) SnapshotStackCreate(opts puck.StackSnapshotOptions) (*puck.StackSnapshotResult, error) {
+	data, _ := json.Marshal(opts)
+	resp, err := c.send(&Request{Action: "snapshot-stack-create", Data: data})
+	if err != nil {
+		return nil, err
+	}
+
+	var result *puck.StackSnapshotResult
+	if len(resp.Data) > 0 {
+		if err := json.Unmarshal(resp.Data, &result); err != nil {
+			return nil, err
+		}
+	}
+	if !resp.Success {
+		return result, resp.err()
+	}
+	return result, nil
+}
+
+// SnapshotStackRestore restores every member of a stack snapshot and
+// returns the members restored, which on failure are the ones restored
+// before it
+func (c *Client) SnapshotStackRestore(opts puck.StackRestoreOptions) ([]string, error) {
+	data, _ := json.Marshal(opts)
+	resp, err := c.send(&Request{Action: "snapshot-stack-restore", Data: data})
+	if err != nil {
+		return nil, err
+	}
+
+	var restored []string
+	if len(resp.Data) > 0 {
+		if err := json.Unmarshal(resp.Data, &restored); err != nil {
+			return nil, err
+		}
+	}
+	if !resp.Success {
+		return restored, resp.err()
+	}
+	return restored, nil
+}
+
+// SnapshotStackList returns the stack snapshots taken from a puck
+func (c *Client) SnapshotStackList(puckName string) ([]*store.StackSnapshot, error) {
+	data, _ := json.Marshal(map[string]string{"puck_name": puckName})
+	resp, err := c.send(&Request{Action: "snapshot-stack-list", Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var stacks []*store.StackSnapshot
+	if err := json.Unmarshal(resp.Data, &stacks); err != nil {
+		return nil, err
+	}
+	return stacks, nil
+}
+
+// SnapshotList returns all snapshots for a puck
+func (c *Client) SnapshotList(puckName string) ([]*store.Snapshot, error) {
+	data, _ := json.Marshal(map[string]string{"puck_name": puckName})
+	resp, err := c.send(&Request{Action: "snapshot-list", Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var snapshots []*store.Snapshot
+	if err := json.Unmarshal(resp.Data, &snapshots); err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+// SnapshotInspect describes a snapshot and its archive contents
+func (c *Client) SnapshotInspect(opts puck.SnapshotInspectOptions) (*puck.SnapshotInfo, error) {
+	data, _ := json.Marshal(opts)
+	resp, err := c.send(&Request{Action: "snapshot-inspect", Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var info puck.SnapshotInfo
+	if err := json.Unmarshal(resp.Data, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// SnapshotDiff compares two snapshots of a puck
+func (c *Client) SnapshotDiff(opts puck.SnapshotDiffOptions) (*puck.SnapshotDiff, error) {
+	data, _ := json.Marshal(opts)
+	resp, err := c.send(&Request{Action: "snapshot-diff", Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var diff puck.SnapshotDiff
+	if err := json.Unmarshal(resp.Data, &diff); err != nil {
+		return nil, err
+	}
+	return &diff, nil
+}
+
+// SnapshotTag adds a tag to a snapshot, or removes it when remove is set
+func (c *Client) SnapshotTag(puckName, snapshotName, tag string, remove bool) (*store.Snapshot, error) {
+	data, _ := json.Marshal(map[string]interface{}{
+		"puck_name":     puckName,
+		"snapshot_name": snapshotName,
+		"tag":           tag,
+		"remove":        remove,
+	})
+	resp, err := c.send(&Request{Action: "snapshot-tag", Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, resp.err()
+	}
+
+	var snapshot store.Snapshot
+	if err := json.Unmarshal(resp.Data, &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
 }
 
 // SnapshotDelete deletes a snapshot
@@ -249,7 +1494,7 @@ func (c *Client) SnapshotDelete(puckName, snapshotName string) error {
 		return err
 	}
 	if !resp.Success {
-		return errors.New(resp.Error)
+		return resp.err()
 	}
 	return nil
 }
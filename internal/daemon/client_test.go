@@ -9,6 +9,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/sandwich-labs/puck/internal/network"
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/sandwich-labs/puck/internal/store"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -97,6 +100,15 @@ func TestPing(t *testing.T) {
 		assert.Contains(t, err.Error(), "connecting to daemon")
 		assert.Contains(t, err.Error(), "is puckd running")
 	})
+
+	t.Run("names the remote context it couldn't reach", func(t *testing.T) {
+		client := &Client{socketPath: "/nonexistent/socket.sock", remote: "homelab"}
+		err := client.Ping()
+		var connectErr *ConnectError
+		require.ErrorAs(t, err, &connectErr)
+		assert.Equal(t, "homelab", connectErr.Context)
+		assert.Contains(t, err.Error(), "daemon of context 'homelab'")
+	})
 }
 
 func TestList(t *testing.T) {
@@ -229,7 +241,7 @@ func TestDestroy(t *testing.T) {
 }
 
 func TestDestroyAll(t *testing.T) {
-	t.Run("returns list of destroyed pucks", func(t *testing.T) {
+	t.Run("returns how each puck went", func(t *testing.T) {
 		socketPath, cleanup := setupMockServer(t, func(conn net.Conn) {
 			defer conn.Close()
 
@@ -239,16 +251,24 @@ func TestDestroyAll(t *testing.T) {
 
 			assert.Equal(t, "destroy-all", req.Action)
 
-			destroyedJSON, _ := json.Marshal([]string{"puck1", "puck2"})
+			results := []puck.DestroyResult{
+				{Puck: "puck1", Duration: time.Second},
+				{Puck: "puck2", Error: "container is busy", Duration: 2 * time.Second},
+			}
+			resultsJSON, _ := json.Marshal(results)
 			encoder := json.NewEncoder(conn)
-			encoder.Encode(Response{Success: true, Data: destroyedJSON})
+			encoder.Encode(Response{Success: true, Data: resultsJSON})
 		})
 		defer cleanup()
 
 		client := NewClientWithSocket(socketPath)
-		destroyed, err := client.DestroyAll(true)
+		results, err := client.DestroyAll(true)
 		require.NoError(t, err)
-		assert.Equal(t, []string{"puck1", "puck2"}, destroyed)
+		require.Len(t, results, 2)
+		assert.Equal(t, "puck1", results[0].Puck)
+		assert.Empty(t, results[0].Error)
+		assert.Equal(t, "container is busy", results[1].Error)
+		assert.Equal(t, 2*time.Second, results[1].Duration)
 	})
 }
 
@@ -268,6 +288,7 @@ func TestSnapshotCreate(t *testing.T) {
 			assert.Equal(t, "my-puck", params["puck_name"])
 			assert.Equal(t, "snap1", params["snapshot_name"])
 			assert.Equal(t, true, params["leave_running"])
+			assert.Equal(t, "image", params["mode"])
 
 			snapshotJSON, _ := json.Marshal(map[string]interface{}{
 				"id":        "snap-id",
@@ -280,12 +301,49 @@ func TestSnapshotCreate(t *testing.T) {
 		defer cleanup()
 
 		client := NewClientWithSocket(socketPath)
-		snapshot, err := client.SnapshotCreate("my-puck", "snap1", true)
+		leaveRunning := true
+		snapshot, err := client.SnapshotCreate("my-puck", "snap1", &leaveRunning, store.SnapshotModeImage, puck.CRIUFlags{})
 		require.NoError(t, err)
 		assert.Equal(t, "snap1", snapshot.Name)
 	})
 }
 
+func TestSnapshotAll(t *testing.T) {
+	t.Run("returns per-puck results", func(t *testing.T) {
+		socketPath, cleanup := setupMockServer(t, func(conn net.Conn) {
+			defer conn.Close()
+
+			decoder := json.NewDecoder(conn)
+			var req Request
+			decoder.Decode(&req)
+
+			assert.Equal(t, "snapshot-all", req.Action)
+
+			var params map[string]interface{}
+			json.Unmarshal(req.Data, &params)
+			assert.Equal(t, "nightly", params["snapshot_name"])
+			// Left to each puck's snapshot policy
+			assert.NotContains(t, params, "leave_running")
+
+			resultsJSON, _ := json.Marshal([]map[string]interface{}{
+				{"puck": "a", "snapshot": map[string]interface{}{"name": "nightly", "puck_name": "a"}},
+				{"puck": "b", "error": "criu failed"},
+			})
+			encoder := json.NewEncoder(conn)
+			encoder.Encode(Response{Success: true, Data: resultsJSON})
+		})
+		defer cleanup()
+
+		client := NewClientWithSocket(socketPath)
+		results, err := client.SnapshotAll("nightly", nil, "", puck.CRIUFlags{})
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.Equal(t, "nightly", results[0].Snapshot.Name)
+		assert.Nil(t, results[1].Snapshot)
+		assert.Equal(t, "criu failed", results[1].Error)
+	})
+}
+
 func TestSnapshotList(t *testing.T) {
 	t.Run("returns snapshots from response", func(t *testing.T) {
 		socketPath, cleanup := setupMockServer(t, func(conn net.Conn) {
@@ -313,6 +371,147 @@ func TestSnapshotList(t *testing.T) {
 	})
 }
 
+func TestRouterRestart(t *testing.T) {
+	t.Run("returns router status", func(t *testing.T) {
+		socketPath, cleanup := setupMockServer(t, func(conn net.Conn) {
+			defer conn.Close()
+
+			decoder := json.NewDecoder(conn)
+			var req Request
+			decoder.Decode(&req)
+
+			assert.Equal(t, "router-restart", req.Action)
+
+			data, _ := json.Marshal(network.RouterStatus{Running: true, Port: 8081, RequestedPort: 8080})
+			encoder := json.NewEncoder(conn)
+			encoder.Encode(Response{Success: true, Data: data})
+		})
+		defer cleanup()
+
+		client := NewClientWithSocket(socketPath)
+		st, err := client.RouterRestart()
+		require.NoError(t, err)
+		assert.True(t, st.Running)
+		assert.Equal(t, 8081, st.Port)
+		assert.Equal(t, 8080, st.RequestedPort)
+	})
+
+	t.Run("returns error from daemon", func(t *testing.T) {
+		socketPath, cleanup := setupMockServer(t, func(conn net.Conn) {
+			defer conn.Close()
+
+			decoder := json.NewDecoder(conn)
+			var req Request
+			decoder.Decode(&req)
+
+			encoder := json.NewEncoder(conn)
+			encoder.Encode(Response{Success: false, Error: "port 8080 is busy"})
+		})
+		defer cleanup()
+
+		client := NewClientWithSocket(socketPath)
+		_, err := client.RouterRestart()
+		assert.EqualError(t, err, "port 8080 is busy")
+	})
+}
+
+func TestShareCreate(t *testing.T) {
+	t.Run("sends puck name and duration", func(t *testing.T) {
+		socketPath, cleanup := setupMockServer(t, func(conn net.Conn) {
+			defer conn.Close()
+
+			decoder := json.NewDecoder(conn)
+			var req Request
+			decoder.Decode(&req)
+
+			assert.Equal(t, "share-create", req.Action)
+			var params struct {
+				Name string        `json:"name"`
+				TTL  time.Duration `json:"ttl"`
+			}
+			json.Unmarshal(req.Data, &params)
+			assert.Equal(t, "web", params.Name)
+			assert.Equal(t, 2*time.Hour, params.TTL)
+
+			data, _ := json.Marshal(store.Share{ID: "abc", PuckName: "web", Token: "abc.sig", URL: "https://example.com/_share/abc.sig/"})
+			encoder := json.NewEncoder(conn)
+			encoder.Encode(Response{Success: true, Data: data})
+		})
+		defer cleanup()
+
+		client := NewClientWithSocket(socketPath)
+		share, err := client.ShareCreate("web", 2*time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, "abc", share.ID)
+		assert.Equal(t, "https://example.com/_share/abc.sig/", share.URL)
+	})
+}
+
+func TestAliasSet(t *testing.T) {
+	t.Run("sends path and puck name", func(t *testing.T) {
+		socketPath, cleanup := setupMockServer(t, func(conn net.Conn) {
+			defer conn.Close()
+
+			decoder := json.NewDecoder(conn)
+			var req Request
+			decoder.Decode(&req)
+
+			assert.Equal(t, "alias-set", req.Action)
+			var params struct {
+				Path string `json:"path"`
+				Name string `json:"name"`
+			}
+			json.Unmarshal(req.Data, &params)
+			assert.Equal(t, "/api", params.Path)
+			assert.Equal(t, "backend-v2", params.Name)
+
+			data, _ := json.Marshal(store.RouteAlias{Path: "/api", PuckName: "backend-v2"})
+			encoder := json.NewEncoder(conn)
+			encoder.Encode(Response{Success: true, Data: data})
+		})
+		defer cleanup()
+
+		client := NewClientWithSocket(socketPath)
+		alias, err := client.AliasSet("/api", "backend-v2")
+		require.NoError(t, err)
+		assert.Equal(t, "/api", alias.Path)
+		assert.Equal(t, "backend-v2", alias.PuckName)
+	})
+}
+
+func TestSnapshotStackCreate(t *testing.T) {
+	t.Run("returns member results with the error", func(t *testing.T) {
+		socketPath, cleanup := setupMockServer(t, func(conn net.Conn) {
+			defer conn.Close()
+
+			decoder := json.NewDecoder(conn)
+			var req Request
+			decoder.Decode(&req)
+
+			assert.Equal(t, "snapshot-stack-create", req.Action)
+			var opts puck.StackSnapshotOptions
+			json.Unmarshal(req.Data, &opts)
+			assert.Equal(t, "api", opts.PuckName)
+			assert.Equal(t, "release", opts.SnapshotName)
+
+			data, _ := json.Marshal(puck.StackSnapshotResult{Members: []puck.SnapshotResult{
+				{Puck: "db", Error: "criu failed"},
+				{Puck: "api", Snapshot: &store.Snapshot{Name: "release"}},
+			}})
+			encoder := json.NewEncoder(conn)
+			encoder.Encode(Response{Success: false, Error: "could not snapshot db", Data: data})
+		})
+		defer cleanup()
+
+		client := NewClientWithSocket(socketPath)
+		result, err := client.SnapshotStackCreate(puck.StackSnapshotOptions{PuckName: "api", SnapshotName: "release"})
+		assert.EqualError(t, err, "could not snapshot db")
+		require.NotNil(t, result)
+		require.Len(t, result.Members, 2)
+		assert.Equal(t, "criu failed", result.Members[0].Error)
+	})
+}
+
 func TestSendTimeout(t *testing.T) {
 	t.Run("connection timeout when server doesn't respond", func(t *testing.T) {
 		// Create a server that never responds
@@ -329,3 +528,92 @@ func TestSendTimeout(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestExec(t *testing.T) {
+	t.Run("sends the command and returns its result", func(t *testing.T) {
+		socketPath, cleanup := setupMockServer(t, func(conn net.Conn) {
+			defer conn.Close()
+
+			decoder := json.NewDecoder(conn)
+			var req Request
+			decoder.Decode(&req)
+
+			assert.Equal(t, "exec", req.Action)
+			var opts puck.ExecOptions
+			json.Unmarshal(req.Data, &opts)
+			assert.Equal(t, puck.ExecOptions{Name: "web", Cmd: []string{"ls", "/"}, Timeout: time.Minute}, opts)
+
+			data, _ := json.Marshal(puck.ExecResult{ExitCode: 1, Output: "boom\n"})
+			encoder := json.NewEncoder(conn)
+			encoder.Encode(Response{Success: true, Data: data})
+		})
+		defer cleanup()
+
+		client := NewClientWithSocket(socketPath)
+		result, err := client.Exec(puck.ExecOptions{Name: "web", Cmd: []string{"ls", "/"}, Timeout: time.Minute})
+		require.NoError(t, err)
+		assert.Equal(t, &puck.ExecResult{ExitCode: 1, Output: "boom\n"}, result)
+	})
+}
+
+func TestFSRead(t *testing.T) {
+	t.Run("returns the file's bytes", func(t *testing.T) {
+		socketPath, cleanup := setupMockServer(t, func(conn net.Conn) {
+			defer conn.Close()
+
+			decoder := json.NewDecoder(conn)
+			var req Request
+			decoder.Decode(&req)
+
+			assert.Equal(t, "fs-read", req.Action)
+			var opts puck.FSOptions
+			json.Unmarshal(req.Data, &opts)
+			assert.Equal(t, puck.FSOptions{Name: "web", Path: "/etc/hostname"}, opts)
+
+			content := puck.FileContent{FileInfo: puck.FileInfo{Name: "hostname", Type: "file", Size: 5}, Data: []byte("web\x00\n")}
+			data, _ := json.Marshal(content)
+			encoder := json.NewEncoder(conn)
+			encoder.Encode(Response{Success: true, Data: data})
+		})
+		defer cleanup()
+
+		client := NewClientWithSocket(socketPath)
+		content, err := client.FSRead(puck.FSOptions{Name: "web", Path: "/etc/hostname"})
+		require.NoError(t, err)
+		assert.Equal(t, []byte("web\x00\n"), content.Data)
+		assert.Equal(t, "hostname", content.Name)
+	})
+}
+
+func TestHistory(t *testing.T) {
+	t.Run("sends puck name and since", func(t *testing.T) {
+		since := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+		socketPath, cleanup := setupMockServer(t, func(conn net.Conn) {
+			defer conn.Close()
+
+			decoder := json.NewDecoder(conn)
+			var req Request
+			decoder.Decode(&req)
+
+			assert.Equal(t, "history", req.Action)
+			var params struct {
+				Name  string    `json:"name"`
+				Since time.Time `json:"since"`
+			}
+			json.Unmarshal(req.Data, &params)
+			assert.Equal(t, "web", params.Name)
+			assert.True(t, since.Equal(params.Since))
+
+			data, _ := json.Marshal([]*store.Event{{ID: 1, PuckName: "web", Type: store.EventCreated, Detail: "fedora"}})
+			encoder := json.NewEncoder(conn)
+			encoder.Encode(Response{Success: true, Data: data})
+		})
+		defer cleanup()
+
+		client := NewClientWithSocket(socketPath)
+		events, err := client.History("web", since)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, store.EventCreated, events[0].Type)
+	})
+}
@@ -0,0 +1,68 @@
+package daemon
+
+import (
+	"errors"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// Error codes sent with failed responses, so clients can tell failures
+// apart without matching messages
+const (
+	CodeNotFound          = "not_found"
+	CodeAlreadyExists     = "already_exists"
+	CodePodmanUnavailable = "podman_unavailable"
+	CodeCRIUUnsupported   = "criu_unsupported"
+	CodePortExhausted     = "port_exhausted"
+)
+
+// codeErrors are the errors each code stands for
+var codeErrors = map[string]error{
+	CodeNotFound:          store.ErrNotFound,
+	CodeAlreadyExists:     store.ErrExists,
+	CodePodmanUnavailable: podman.ErrUnavailable,
+	CodeCRIUUnsupported:   podman.ErrCRIUUnsupported,
+	CodePortExhausted:     puck.ErrPortsExhausted,
+}
+
+// errorCode returns the code for err, or "" if it has none
+func errorCode(err error) string {
+	for code, target := range codeErrors {
+		if errors.Is(err, target) {
+			return code
+		}
+	}
+	return ""
+}
+
+// errorResponse is the failed response for err
+func errorResponse(err error) Response {
+	resp := Response{Success: false, Error: err.Error(), Code: errorCode(err)}
+	var hinted interface{ Hints() []string }
+	if errors.As(err, &hinted) {
+		resp.Hints = hinted.Hints()
+	}
+	return resp
+}
+
+// Error is a failed response as the client sees it. It matches the error
+// its code stands for, so callers can use errors.Is(err, store.ErrNotFound)
+// as they would in the daemon.
+type Error struct {
+	Code    string
+	Message string
+	Hints   []string // what the caller can do about it, if the daemon knows
+}
+
+func (e *Error) Error() string { return e.Message }
+
+func (e *Error) Is(target error) bool {
+	return e.Code != "" && codeErrors[e.Code] == target
+}
+
+// err returns the error a failed response carries
+func (r *Response) err() error {
+	return &Error{Code: r.Code, Message: r.Error, Hints: r.Hints}
+}
@@ -0,0 +1,86 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		code string
+	}{
+		{fmt.Errorf("puck 'web' %w", store.ErrNotFound), CodeNotFound},
+		{fmt.Errorf("puck 'web' %w", store.ErrExists), CodeAlreadyExists},
+		{fmt.Errorf("starting: %w", podman.ErrUnavailable), CodePodmanUnavailable},
+		{fmt.Errorf("checkpointing container: %w", podman.ErrCRIUUnsupported), CodeCRIUUnsupported},
+		{fmt.Errorf("%w in range 9000-10000", puck.ErrPortsExhausted), CodePortExhausted},
+		{errors.New("something else"), ""},
+	}
+	for _, tt := range tests {
+		resp := errorResponse(tt.err)
+		assert.False(t, resp.Success)
+		assert.Equal(t, tt.err.Error(), resp.Error)
+		assert.Equal(t, tt.code, resp.Code, tt.err.Error())
+	}
+}
+
+func TestErrorHints(t *testing.T) {
+	resp := errorResponse(&puck.ExistsError{Name: "web", Free: "web-0042"})
+	assert.Equal(t, CodeAlreadyExists, resp.Code)
+	assert.Equal(t, []string{
+		"Replace it, destroying it once the new one is made, by running the same command with --replace",
+		"Or use a free name, such as: web-0042",
+	}, resp.Hints)
+
+	var daemonErr *Error
+	require.ErrorAs(t, resp.err(), &daemonErr)
+	assert.Equal(t, resp.Hints, daemonErr.Hints)
+	assert.ErrorIs(t, daemonErr, store.ErrExists)
+
+	assert.Empty(t, errorResponse(errors.New("something else")).Hints)
+}
+
+func TestErrorCodeResponses(t *testing.T) {
+	d := setupAuthDaemon(t)
+	alice := withCaller(context.Background(), caller{User: "alice"})
+
+	resp := d.handleRequest(alice, &Request{Action: "get", Data: json.RawMessage(`{"name":"missing"}`)})
+	assert.False(t, resp.Success)
+	assert.Equal(t, CodeNotFound, resp.Code)
+
+	resp = d.handleRequest(alice, &Request{Action: "get", Data: json.RawMessage(`{"name":"bob-puck"}`)})
+	assert.False(t, resp.Success)
+	assert.Empty(t, resp.Code, "permission errors have no code")
+}
+
+func TestClientErrorCodes(t *testing.T) {
+	socketPath, cleanup := setupMockServer(t, func(conn net.Conn) {
+		defer conn.Close()
+		var req Request
+		json.NewDecoder(conn).Decode(&req)
+		json.NewEncoder(conn).Encode(Response{Success: false, Error: "puck 'missing' not found", Code: CodeNotFound})
+	})
+	defer cleanup()
+
+	client := NewClientWithSocket(socketPath)
+	_, err := client.Get("missing")
+	require.Error(t, err)
+	assert.Equal(t, "puck 'missing' not found", err.Error())
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	assert.NotErrorIs(t, err, store.ErrExists)
+
+	var daemonErr *Error
+	require.ErrorAs(t, err, &daemonErr)
+	assert.Equal(t, CodeNotFound, daemonErr.Code)
+}
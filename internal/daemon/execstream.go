@@ -0,0 +1,171 @@
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/charmbracelet/log"
+	"github.com/sandwich-labs/puck/internal/puck"
+)
+
+// Frames of an exec-stream session. Once the daemon accepts the request
+// with a Response, the client sends stdin and the daemon sends output as
+// frames: a stream byte, a big-endian uint32 length and that many bytes.
+const (
+	streamStdin  byte = 0 // an empty frame closes the command's stdin
+	streamStdout byte = 1
+	streamStderr byte = 2
+	streamExit   byte = 3 // the exit status as a big-endian uint32; the last frame
+	streamError  byte = 4 // why the command couldn't be run; the last frame
+
+	maxFrameSize = 32 << 10
+)
+
+// writeFrame sends data as one frame of a stream
+func writeFrame(w io.Writer, stream byte, data []byte) error {
+	header := make([]byte, 5, 5+len(data))
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[1:], uint32(len(data)))
+	_, err := w.Write(append(header, data...))
+	return err
+}
+
+// readFrame reads the next frame
+func readFrame(r io.Reader) (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(header[1:])
+	if n > maxFrameSize {
+		return 0, nil, fmt.Errorf("frame of %d bytes exceeds %d", n, maxFrameSize)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, nil, err
+	}
+	return header[0], data, nil
+}
+
+// frameReader reads the frames that follow a JSON message on a connection
+type frameReader struct {
+	r       *bufio.Reader
+	started bool
+}
+
+// newFrameReader reads frames from r after the message dec decoded from it
+func newFrameReader(dec *json.Decoder, r io.Reader) *frameReader {
+	return &frameReader{r: bufio.NewReader(io.MultiReader(dec.Buffered(), r))}
+}
+
+// next reads the next frame, first skipping the newline json.Encoder
+// ends the message with
+func (f *frameReader) next() (byte, []byte, error) {
+	if !f.started {
+		f.started = true
+		if b, err := f.r.Peek(1); err == nil && b[0] == '\n' {
+			f.r.Discard(1)
+		}
+	}
+	return readFrame(f.r)
+}
+
+// frameWriter writes to one stream, splitting writes into frames. Writers
+// sharing a connection share mu.
+type frameWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	stream byte
+}
+
+func (f *frameWriter) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	written := 0
+	for len(p) > 0 {
+		chunk := p[:min(len(p), maxFrameSize)]
+		if err := writeFrame(f.w, f.stream, chunk); err != nil {
+			return written, err
+		}
+		written += len(chunk)
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
+
+// handleExecStream runs a command with its stdio streamed over the
+// connection. It reads the connection itself, for stdin, so it is served
+// outside handleRequest; the command is killed when the client hangs up.
+func (d *Daemon) handleExecStream(ctx context.Context, cancel context.CancelFunc, conn net.Conn, frames *frameReader, req *Request) {
+	encoder := json.NewEncoder(conn)
+	if err := d.authorize(ctx, req); err != nil {
+		encoder.Encode(errorResponse(err))
+		return
+	}
+	if err := d.checkMaintenance(req); err != nil {
+		encoder.Encode(errorResponse(err))
+		return
+	}
+	if err := d.waitPodman(ctx); err != nil {
+		encoder.Encode(errorResponse(err))
+		return
+	}
+	var opts puck.ExecOptions
+	if err := json.Unmarshal(req.Data, &opts); err != nil {
+		encoder.Encode(errorResponse(err))
+		return
+	}
+	if len(opts.Cmd) == 0 {
+		encoder.Encode(Response{Success: false, Error: "no command given"})
+		return
+	}
+	if err := encoder.Encode(Response{Success: true}); err != nil {
+		return
+	}
+
+	stdin, stdinW := io.Pipe()
+	go func() {
+		defer cancel()
+		for {
+			stream, data, err := frames.next()
+			if err != nil {
+				stdinW.CloseWithError(err)
+				return
+			}
+			switch {
+			case stream != streamStdin:
+			case len(data) == 0:
+				stdinW.Close()
+			default:
+				// After the command stops reading, stdin is drained
+				stdinW.Write(data)
+			}
+		}
+	}()
+
+	var mu sync.Mutex
+	stdout := &frameWriter{mu: &mu, w: conn, stream: streamStdout}
+	stderr := &frameWriter{mu: &mu, w: conn, stream: streamStderr}
+	code, err := d.manager.ExecStream(ctx, opts, stdin, stdout, stderr)
+	stdin.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if err != nil {
+		if !errors.Is(ctx.Err(), context.Canceled) {
+			writeFrame(conn, streamError, []byte(err.Error()))
+		}
+		return
+	}
+	status := binary.BigEndian.AppendUint32(nil, uint32(code))
+	if err := writeFrame(conn, streamExit, status); err != nil {
+		log.Debug("Exec client went away before its exit status", "name", opts.Name, "error", err)
+	}
+}
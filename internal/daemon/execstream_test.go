@@ -0,0 +1,77 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrames(t *testing.T) {
+	var buf bytes.Buffer
+	w := &frameWriter{mu: new(sync.Mutex), w: &buf, stream: streamStdout}
+	data := bytes.Repeat([]byte{0, 1, 0xff}, maxFrameSize)
+	n, err := w.Write(data)
+	require.NoError(t, err)
+	assert.Equal(t, len(data), n)
+
+	var got []byte
+	for buf.Len() > 0 {
+		stream, chunk, err := readFrame(&buf)
+		require.NoError(t, err)
+		assert.Equal(t, streamStdout, stream)
+		assert.LessOrEqual(t, len(chunk), maxFrameSize)
+		got = append(got, chunk...)
+	}
+	assert.Equal(t, data, got)
+}
+
+func TestExecStream(t *testing.T) {
+	d := setupAuthDaemon(t)
+	mock := podman.NewMockClient()
+	d.manager = puck.NewManager(d.cfg, mock, d.store)
+
+	mock.ExecFunc = func(ctx context.Context, containerID string, opts podman.ExecOptions) error {
+		assert.True(t, opts.Interactive)
+		assert.False(t, opts.TTY)
+		assert.Equal(t, []string{"psql"}, opts.Cmd)
+		io.WriteString(opts.ErrOutput, "reading stdin\n")
+		io.Copy(opts.Output, opts.Stdin)
+		return &podman.ExitError{Code: 3}
+	}
+
+	socketPath, cleanup := setupMockServer(t, func(conn net.Conn) {
+		go d.handleConnection(context.Background(), conn)
+	})
+	defer cleanup()
+	client := NewClientWithSocket(socketPath)
+
+	t.Run("streams binary stdin and output and returns the status", func(t *testing.T) {
+		input := bytes.Repeat([]byte("\x00\xffselect 1;\n"), 10000)
+		var stdout, stderr bytes.Buffer
+		code, err := client.ExecStream(puck.ExecOptions{Name: "alice-puck", Cmd: []string{"psql"}}, bytes.NewReader(input), &stdout, &stderr)
+		require.NoError(t, err)
+		assert.Equal(t, 3, code)
+		assert.Equal(t, input, stdout.Bytes())
+		assert.Equal(t, "reading stdin\n", stderr.String())
+	})
+
+	t.Run("reports commands that can't run", func(t *testing.T) {
+		_, err := client.ExecStream(puck.ExecOptions{Name: "missing", Cmd: []string{"psql"}}, nil, io.Discard, io.Discard)
+		assert.Error(t, err)
+
+		_, err = client.ExecStream(puck.ExecOptions{Name: "alice-puck"}, nil, io.Discard, io.Discard)
+		assert.ErrorContains(t, err, "no command")
+
+		mock.IsRunningFunc = func(ctx context.Context, nameOrID string) (bool, error) { return false, nil }
+		_, err = client.ExecStream(puck.ExecOptions{Name: "alice-puck", Cmd: []string{"psql"}}, nil, io.Discard, io.Discard)
+		assert.ErrorContains(t, err, "not running")
+	})
+}
@@ -0,0 +1,70 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+)
+
+func (d *Daemon) handleGroupAdd(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		Name  string   `json:"name"`
+		Pucks []string `json:"pucks"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+
+	// Groups are the caller's own, so two users can each have an @demo
+	g, err := d.manager.AddToGroup(ctx, callerFrom(ctx).User, params.Name, params.Pucks)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	respData, _ := json.Marshal(g)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleGroupRemove(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		Name  string   `json:"name"`
+		Pucks []string `json:"pucks,omitempty"` // empty removes the group
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+
+	g, err := d.manager.RemoveFromGroup(ctx, callerFrom(ctx).User, params.Name, params.Pucks)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	respData, _ := json.Marshal(g)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleGroupGet(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+
+	g, err := d.manager.Group(ctx, callerFrom(ctx).User, params.Name)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	respData, _ := json.Marshal(g)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleGroupList(ctx context.Context) Response {
+	groups, err := d.manager.ListGroups(ctx, callerFrom(ctx).User)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	respData, _ := json.Marshal(groups)
+	return Response{Success: true, Data: respData}
+}
@@ -0,0 +1,80 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/buildinfo"
+)
+
+// Info describes a running daemon: the build it was made from, the host
+// tools it drives, and the optional features its config turns on, so
+// clients can tell what they can ask of it
+type Info struct {
+	Version       string          `json:"version"`
+	Commit        string          `json:"commit,omitempty"`
+	BuildDate     string          `json:"build_date,omitempty"`
+	DataDir       string          `json:"data_dir"`
+	PodmanVersion string          `json:"podman_version,omitempty"` // empty while Podman is unavailable
+	CRIUVersion   string          `json:"criu_version,omitempty"`
+	Features      map[string]bool `json:"features"`
+}
+
+// infoTimeout bounds asking Podman and CRIU for their versions
+const infoTimeout = 5 * time.Second
+
+// features reports which optional parts of the daemon are turned on
+func (d *Daemon) features() map[string]bool {
+	return map[string]bool{
+		"agent":           d.cfg.Agent,
+		"router":          d.cfg.RouterEnabled,
+		"router_tls":      d.cfg.RouterTLSPort > 0,
+		"tailnet":         d.cfg.Tailnet != "",
+		"remote":          d.cfg.DaemonListen != "" || d.cfg.DaemonTailnet,
+		"share_tunnel":    shareTunnel(d.cfg) != nil,
+		"snapshot_tier":   d.cfg.SnapshotTierDir != "",
+		"snapshot_dedup":  d.cfg.SnapshotDedup,
+		"memory_pressure": d.cfg.MemoryPressure > 0,
+		"webhooks":        d.cfg.WebhookListen != "",
+		"postgres":        d.cfg.DatabaseURL != "",
+		"machine":         d.cfg.Machine != "",
+		"maintenance":     d.inMaintenance(),
+	}
+}
+
+// enabledFeatures lists the features turned on, in order
+func (d *Daemon) enabledFeatures() []string {
+	var names []string
+	for name, on := range d.features() {
+		if on {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+func (d *Daemon) handleInfo(ctx context.Context) Response {
+	info := Info{
+		Version:   buildinfo.Version,
+		Commit:    buildinfo.Commit,
+		BuildDate: buildinfo.Date,
+		DataDir:   d.cfg.DataDir,
+		Features:  d.features(),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, infoTimeout)
+	defer cancel()
+	if d.podman != nil && d.podman.Available() {
+		if host, err := d.podman.HostInfo(ctx); err == nil {
+			info.PodmanVersion = host.PodmanVersion
+		}
+		info.CRIUVersion = d.manager.CRIUVersion(ctx)
+	}
+	info.Features["checkpoint"] = info.CRIUVersion != ""
+
+	respData, _ := json.Marshal(info)
+	return Response{Success: true, Data: respData}
+}
@@ -0,0 +1,30 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/buildinfo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInfo(t *testing.T) {
+	d := setupAuthDaemon(t)
+	d.cfg.RouterEnabled = true
+	alice := withCaller(context.Background(), caller{User: "alice"})
+
+	resp := d.handleRequest(alice, &Request{Action: "info"})
+	require.True(t, resp.Success, resp.Error)
+
+	var info Info
+	require.NoError(t, json.Unmarshal(resp.Data, &info))
+	assert.Equal(t, buildinfo.Version, info.Version)
+	assert.Equal(t, d.cfg.DataDir, info.DataDir)
+	assert.Empty(t, info.PodmanVersion, "podman isn't available")
+	assert.True(t, info.Features["router"])
+	assert.False(t, info.Features["tailnet"])
+	assert.False(t, info.Features["checkpoint"])
+	assert.Equal(t, []string{"router"}, d.enabledFeatures())
+}
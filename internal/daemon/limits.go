@@ -0,0 +1,108 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Protocol limits. Requests are small JSON documents; responses can list
+// every puck, snapshot or event, so they get more room.
+const (
+	maxRequestSize  = 1 << 20  // 1 MiB
+	maxResponseSize = 64 << 20 // 64 MiB
+
+	// How long a client has to send its request, and to read the response
+	requestReadTimeout   = 10 * time.Second
+	responseWriteTimeout = 10 * time.Second
+
+	defaultActionTimeout = 2 * time.Minute
+)
+
+// actionTimeouts bounds how long each action may run before the daemon
+// gives up on it; actions not listed get defaultActionTimeout
+var actionTimeouts = map[string]time.Duration{
+	"ping":                   5 * time.Second,
+	"start":                  10 * time.Minute, // may resume from a checkpoint
+	"stop":                   10 * time.Minute, // up to config.MaxStopTimeout
+	"destroy":                10 * time.Minute,
+	"create":                 15 * time.Minute, // may pull an image
+	"recreate":               15 * time.Minute,
+	"rollback":               10 * time.Minute,
+	"promote":                10 * time.Minute, // may stop the replaced puck
+	"destroy-all":            10 * time.Minute,
+	"snapshot-create":        10 * time.Minute,
+	"snapshot-all":           30 * time.Minute, // several checkpoints, a few at a time
+	"snapshot-restore":       10 * time.Minute,
+	"snapshot-inspect":       10 * time.Minute, // reads the whole archive
+	"snapshot-stack-create":  30 * time.Minute, // a checkpoint per member
+	"snapshot-stack-restore": 30 * time.Minute,
+	"snapshot-tier-run":      30 * time.Minute, // may copy archives to another disk
+	"exec":                   35 * time.Minute, // up to puck.MaxExecTimeout
+	"sync-flush":             10 * time.Minute, // copies a whole project the first time
+	"gc":                     10 * time.Minute,
+	"scan":                   30 * time.Minute, // may pull the image and the vulnerability database
+	"db-check":               10 * time.Minute,
+	"machine-set-resources":  10 * time.Minute, // growing the disk rewrites the image
+}
+
+// actionTimeout returns how long an action may run
+func actionTimeout(action string) time.Duration {
+	if d, ok := actionTimeouts[action]; ok {
+		return d
+	}
+	return defaultActionTimeout
+}
+
+// errTooLarge is returned when a message exceeds its size limit
+var errTooLarge = errors.New("message too large")
+
+// limitedReader is io.LimitReader that reports errTooLarge rather than a
+// truncated message when there is more than n bytes to read
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, errTooLarge
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	if int64(n) <= l.n {
+		l.n -= int64(n)
+		return n, err
+	}
+	n, l.n = int(l.n), -1
+	return n, errTooLarge
+}
+
+// runAction handles a request within its action's timeout. The handler
+// sees ctx canceled when the timeout passes or the client goes away; if
+// it doesn't return promptly the connection is answered anyway and the
+// handler is left to finish on its own.
+func (d *Daemon) runAction(ctx context.Context, req *Request) Response {
+	timeout := actionTimeout(req.Action)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan Response, 1)
+	go func() {
+		done <- d.handleRequest(ctx, req)
+	}()
+
+	select {
+	case resp := <-done:
+		return resp
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return Response{Success: false, Error: fmt.Sprintf("%s timed out after %s", req.Action, timeout)}
+		}
+		return Response{Success: false, Error: fmt.Sprintf("%s canceled: %v", req.Action, ctx.Err())}
+	}
+}
@@ -0,0 +1,164 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/hooks"
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/sandwich-labs/puck/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActionTimeout(t *testing.T) {
+	assert.Equal(t, 15*time.Minute, actionTimeout("create"))
+	assert.Equal(t, 10*time.Minute, actionTimeout("start"))
+	assert.Equal(t, 5*time.Second, actionTimeout("ping"))
+	assert.Equal(t, defaultActionTimeout, actionTimeout("list"))
+}
+
+func TestLimitedReader(t *testing.T) {
+	t.Run("passes messages within the limit", func(t *testing.T) {
+		data, err := io.ReadAll(&limitedReader{r: strings.NewReader("hello"), n: 5})
+		require.NoError(t, err)
+		assert.Equal(t, "hello", string(data))
+	})
+
+	t.Run("fails once the limit is reached", func(t *testing.T) {
+		_, err := io.ReadAll(&limitedReader{r: strings.NewReader("hello world"), n: 5})
+		assert.ErrorIs(t, err, errTooLarge)
+	})
+}
+
+func TestHandleConnectionLimits(t *testing.T) {
+	t.Run("rejects oversized requests", func(t *testing.T) {
+		server, client := net.Pipe()
+		defer client.Close()
+
+		d := &Daemon{}
+		go d.handleConnection(context.Background(), server)
+
+		// Write until the daemon stops reading, then read its answer
+		go func() {
+			client.Write([]byte(`{"action":"create","data":{"name":"`))
+			client.Write(bytes.Repeat([]byte("a"), maxRequestSize))
+		}()
+
+		var resp Response
+		require.NoError(t, json.NewDecoder(client).Decode(&resp))
+		assert.False(t, resp.Success)
+		assert.Contains(t, resp.Error, "request exceeds")
+	})
+
+	t.Run("rejects oversized responses", func(t *testing.T) {
+		socketPath, cleanup := setupMockServer(t, func(conn net.Conn) {
+			defer conn.Close()
+			var req Request
+			json.NewDecoder(conn).Decode(&req)
+			huge := bytes.Repeat([]byte("a"), maxResponseSize)
+			json.NewEncoder(conn).Encode(Response{Success: false, Error: string(huge)})
+		})
+		defer cleanup()
+
+		_, err := NewClientWithSocket(socketPath).send(&Request{Action: "list"})
+		assert.ErrorContains(t, err, "response exceeds")
+	})
+}
+
+func TestHandleConnectionCancel(t *testing.T) {
+	d := setupAuthDaemon(t)
+	mock := podman.NewMockClient()
+	d.manager = puck.NewManager(d.cfg, mock, d.store)
+
+	pulling := make(chan struct{})
+	pullErr := make(chan error, 1)
+	mock.PullImageFunc = func(ctx context.Context, imageName string) error {
+		close(pulling)
+		<-ctx.Done()
+		pullErr <- ctx.Err()
+		return ctx.Err()
+	}
+
+	server, client := net.Pipe()
+	go d.handleConnection(context.Background(), server)
+	go json.NewEncoder(client).Encode(Request{Action: "recreate", Data: json.RawMessage(`{"name":"alice-puck"}`)})
+
+	// Hanging up mid-pull cancels the pull
+	<-pulling
+	client.Close()
+	select {
+	case err := <-pullErr:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("pull was not canceled when the client hung up")
+	}
+}
+
+func TestPullProgress(t *testing.T) {
+	d := setupAuthDaemon(t)
+	mock := podman.NewMockClient()
+	d.manager = puck.NewManager(d.cfg, mock, d.store)
+	d.hooks = hooks.NewRunner(t.TempDir(), time.Second)
+	mock.PullImageFunc = func(ctx context.Context, imageName string) error {
+		if fn := podman.PullProgressFunc(ctx); fn != nil {
+			fn(podman.PullProgress{Image: imageName, Layers: 1, Status: "Copying blob sha256:aaa"})
+			fn(podman.PullProgress{Image: imageName, Layers: 1, Done: true})
+		}
+		return nil
+	}
+
+	socketPath, cleanup := setupMockServer(t, func(conn net.Conn) {
+		d.handleConnection(context.Background(), conn)
+	})
+	defer cleanup()
+
+	t.Run("streams progress to clients that ask", func(t *testing.T) {
+		client := NewClientWithSocket(socketPath)
+		var got []podman.PullProgress
+		client.SetPullProgress(func(p podman.PullProgress) { got = append(got, p) })
+
+		p, err := client.Recreate(puck.RecreateOptions{Name: "alice-puck", NoSnapshot: true})
+		require.NoError(t, err)
+		assert.Equal(t, "alice-puck", p.Name)
+		require.Len(t, got, 2)
+		assert.Equal(t, "Copying blob sha256:aaa", got[0].Status)
+		assert.True(t, got[1].Done)
+	})
+
+	t.Run("sends only the response otherwise", func(t *testing.T) {
+		_, err := NewClientWithSocket(socketPath).Recreate(puck.RecreateOptions{Name: "alice-puck", NoSnapshot: true})
+		require.NoError(t, err)
+	})
+}
+
+func TestSnapshotProgress(t *testing.T) {
+	d := setupAuthDaemon(t)
+	mock := podman.NewMockClient()
+	d.manager = puck.NewManager(d.cfg, mock, d.store)
+	d.hooks = hooks.NewRunner(t.TempDir(), time.Second)
+	mock.CheckpointFunc = testutil.WriteCheckpoint
+
+	socketPath, cleanup := setupMockServer(t, func(conn net.Conn) {
+		d.handleConnection(context.Background(), conn)
+	})
+	defer cleanup()
+
+	client := NewClientWithSocket(socketPath)
+	var got []puck.SnapshotProgress
+	client.SetSnapshotProgress(func(p puck.SnapshotProgress) { got = append(got, p) })
+
+	leaveRunning := true
+	snapshot, err := client.SnapshotCreate("alice-puck", "snap", &leaveRunning, "", puck.CRIUFlags{})
+	require.NoError(t, err)
+	assert.Equal(t, "snap", snapshot.Name)
+	require.NotEmpty(t, got)
+	assert.Equal(t, puck.SnapshotProgress{Puck: "alice-puck", Phase: puck.PhaseCheckpoint}, got[0])
+}
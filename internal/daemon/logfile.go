@@ -0,0 +1,32 @@
+package daemon
+
+import (
+	"io"
+	"os"
+
+	"github.com/charmbracelet/log"
+)
+
+// maxLogSize is how large the daemon's log file grows before it is moved
+// aside, keeping one previous file
+const maxLogSize = 10 << 20
+
+// underSystemd reports whether systemd started the daemon, in which case
+// the journal already keeps its logs
+func underSystemd() bool {
+	return os.Getenv("JOURNAL_STREAM") != "" || os.Getenv("INVOCATION_ID") != ""
+}
+
+// openLogFile sends logs to path as well as stderr, moving a file that
+// has grown past maxLogSize to path.1 first
+func openLogFile(path string) (io.Closer, error) {
+	if info, err := os.Stat(path); err == nil && info.Size() > maxLogSize {
+		os.Rename(path, path+".1")
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	log.SetOutput(io.MultiWriter(os.Stderr, f))
+	return f, nil
+}
@@ -0,0 +1,174 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/sandwich-labs/puck/internal/puck"
+)
+
+// Maintenance is read-only mode, in which the daemon refuses requests
+// that would change anything, so backups or host upgrades can run
+// against a database and volumes that hold still. It is kept on disk so
+// it lasts across daemon restarts.
+type Maintenance struct {
+	Enabled bool      `json:"enabled"`
+	Reason  string    `json:"reason,omitempty"`
+	By      string    `json:"by,omitempty"`
+	Since   time.Time `json:"since"`
+}
+
+// readOnlyActions are the actions served in maintenance: those that only
+// read, and the one that ends it
+var readOnlyActions = map[string]bool{
+	"ping":                 true,
+	"podman-status":        true,
+	"maintenance":          true,
+	"info":                 true,
+	"list":                 true,
+	"get":                  true,
+	"watch":                true,
+	"history":              true,
+	"logs":                 true,
+	"fs-list":              true,
+	"fs-stat":              true,
+	"fs-read":              true,
+	"snapshot-list":        true,
+	"snapshot-inspect":     true,
+	"snapshot-diff":        true,
+	"snapshot-stack-list":  true,
+	"project-status":       true,
+	"sync-status":          true,
+	"tailnet-status":       true,
+	"share-list":           true,
+	"alias-list":           true,
+	"pool-list":            true,
+	"group-get":            true,
+	"group-list":           true,
+	"endpoint-list":        true,
+	"images":               true,
+	"image-tree":           true,
+	"scan":                 true,
+	"report":               true,
+	"events-export":        true,
+	"stats-export":         true,
+	"snapshot-tier-status": true,
+	"machine-resources":    true,
+	"routes":               true,
+	"router-status":        true,
+	"hooks":                true,
+}
+
+// loadMaintenance reads the maintenance state left by the last daemon
+func loadMaintenance(path string) Maintenance {
+	var m Maintenance
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &m)
+	}
+	if err != nil {
+		log.Warn("Failed to read maintenance state; starting out of maintenance", "path", path, "error", err)
+		return Maintenance{}
+	}
+	return m
+}
+
+// inMaintenance reports whether the daemon is read-only
+func (d *Daemon) inMaintenance() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.maintenance.Enabled
+}
+
+// checkMaintenance refuses requests that would change something while
+// the daemon is read-only
+func (d *Daemon) checkMaintenance(req *Request) error {
+	if readOnlyActions[req.Action] {
+		return nil
+	}
+	if req.Action == "db-check" {
+		var opts puck.CheckOptions
+		json.Unmarshal(req.Data, &opts)
+		if !opts.Fix {
+			return nil
+		}
+	}
+	d.mu.RLock()
+	m := d.maintenance
+	d.mu.RUnlock()
+	if !m.Enabled {
+		return nil
+	}
+
+	msg := "the daemon is in maintenance mode"
+	if m.Reason != "" {
+		msg += " (" + m.Reason + ")"
+	}
+	if !m.Since.IsZero() {
+		msg += " since " + m.Since.Local().Format("2006-01-02 15:04")
+	}
+	if m.By != "" {
+		msg += " by " + m.By
+	}
+	return fmt.Errorf("%s, so %s is refused; only commands that read are served until: puck daemon maintenance off", msg, req.Action)
+}
+
+func (d *Daemon) handleMaintenance(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		Enabled *bool  `json:"enabled,omitempty"` // nil only reports the state
+		Reason  string `json:"reason,omitempty"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if params.Enabled != nil && *params.Enabled != d.maintenance.Enabled {
+		m := Maintenance{}
+		if *params.Enabled {
+			m = Maintenance{Enabled: true, Reason: params.Reason, By: callerFrom(ctx).User, Since: time.Now()}
+		}
+		if err := d.saveMaintenance(m); err != nil {
+			return errorResponse(err)
+		}
+		d.maintenance = m
+		if m.Enabled {
+			log.Info("Entered maintenance mode; refusing changes", "reason", m.Reason, "by", m.By)
+		} else {
+			log.Info("Left maintenance mode")
+		}
+	}
+
+	respData, _ := json.Marshal(d.maintenance)
+	return Response{Success: true, Data: respData}
+}
+
+// saveMaintenance writes the maintenance state for the next daemon, or
+// removes it when maintenance ends
+func (d *Daemon) saveMaintenance(m Maintenance) error {
+	path := d.cfg.MaintenancePath()
+	if !m.Enabled {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("clearing maintenance state: %w", err)
+		}
+		return nil
+	}
+	data, _ := json.Marshal(m)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("saving maintenance state: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("saving maintenance state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenance(t *testing.T) {
+	d := setupAuthDaemon(t)
+	admin := withCaller(context.Background(), caller{User: "root", Admin: true})
+	alice := withCaller(context.Background(), caller{User: "alice"})
+
+	set := func(ctx context.Context, enabled bool, reason string) Response {
+		data, _ := json.Marshal(map[string]any{"enabled": enabled, "reason": reason})
+		return d.handleRequest(ctx, &Request{Action: "maintenance", Data: data})
+	}
+
+	t.Run("only admins turn it on", func(t *testing.T) {
+		resp := set(alice, true, "")
+		assert.False(t, resp.Success)
+		assert.Contains(t, resp.Error, "admin")
+
+		resp = d.handleRequest(alice, &Request{Action: "maintenance", Data: json.RawMessage(`{}`)})
+		require.True(t, resp.Success, resp.Error)
+		var m Maintenance
+		require.NoError(t, json.Unmarshal(resp.Data, &m))
+		assert.False(t, m.Enabled)
+	})
+
+	resp := set(admin, true, "nightly backup")
+	require.True(t, resp.Success, resp.Error)
+
+	t.Run("refuses changes", func(t *testing.T) {
+		resp := d.handleRequest(alice, &Request{Action: "stop", Data: json.RawMessage(`{"name":"alice-puck"}`)})
+		assert.False(t, resp.Success)
+		assert.Contains(t, resp.Error, "maintenance mode (nightly backup)")
+		assert.Contains(t, resp.Error, "by root")
+
+		assert.Error(t, d.checkMaintenance(&Request{Action: "db-check", Data: json.RawMessage(`{"fix":true}`)}))
+	})
+
+	t.Run("serves reads", func(t *testing.T) {
+		resp := d.handleRequest(alice, &Request{Action: "list", Data: json.RawMessage(`{}`)})
+		assert.True(t, resp.Success, resp.Error)
+		assert.NoError(t, d.checkMaintenance(&Request{Action: "db-check", Data: json.RawMessage(`{}`)}))
+	})
+
+	t.Run("lasts across restarts", func(t *testing.T) {
+		m := loadMaintenance(d.cfg.MaintenancePath())
+		assert.True(t, m.Enabled)
+		assert.Equal(t, "nightly backup", m.Reason)
+		assert.Equal(t, "root", m.By)
+	})
+
+	t.Run("turns off", func(t *testing.T) {
+		resp := set(admin, false, "")
+		require.True(t, resp.Success, resp.Error)
+		assert.False(t, loadMaintenance(d.cfg.MaintenancePath()).Enabled)
+
+		resp = d.handleRequest(admin, &Request{Action: "stop", Data: json.RawMessage(`{"name":"missing"}`)})
+		assert.NotContains(t, resp.Error, "maintenance")
+	})
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package daemon
+
+import (
+	"errors"
+	"net"
+)
+
+// peerUID is not supported on this platform; callers fall back to the
+// daemon's own user
+func peerUID(conn net.Conn) (int, error) {
+	return 0, errors.New("peer credentials not supported")
+}
+
+// fileOwner is not supported on this platform; mounts then need an admin
+func fileOwner(path string) (int, error) {
+	return 0, errors.New("file ownership not supported")
+}
@@ -0,0 +1,155 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/sandwich-labs/puck/internal/podman"
+)
+
+// How often the daemon retries connecting to Podman
+const (
+	podmanRetryMin = time.Second
+	podmanRetryMax = 30 * time.Second
+)
+
+// podmanQueueTimeout is how long a request that needs Podman waits for
+// it to come up before it is refused
+var podmanQueueTimeout = 30 * time.Second
+
+// podmanFreeActions are answered from the database and host alone, so
+// they are served while Podman is unavailable
+var podmanFreeActions = map[string]bool{
+	"ping":                  true,
+	"podman-status":         true,
+	"maintenance":           true,
+	"info":                  true,
+	"list":                  true,
+	"get":                   true,
+	"watch":                 true,
+	"history":               true,
+	"report":                true,
+	"events-export":         true,
+	"stats-export":          true,
+	"env-set":               true, // takes effect when the puck is next started
+	"set-notes":             true,
+	"set-ttl":               true,
+	"project-status":        true,
+	"sync-status":           true,
+	"sync-flush":            true,
+	"snapshot-list":         true,
+	"snapshot-stack-list":   true,
+	"share-list":            true,
+	"alias-list":            true,
+	"pool-create":           true,
+	"pool-list":             true,
+	"group-add":             true,
+	"group-remove":          true,
+	"group-get":             true,
+	"group-list":            true,
+	"endpoint-list":         true,
+	"router-status":         true,
+	"routes":                true,
+	"router-restart":        true,
+	"tailnet-status":        true,
+	"hooks":                 true,
+	"machine-resources":     true,
+	"machine-set-resources": true, // the machine has to be down for this
+}
+
+// PodmanStatus reports whether the daemon can reach Podman
+type PodmanStatus struct {
+	Available bool   `json:"available"`
+	Error     string `json:"error,omitempty"`
+}
+
+// startWithPodman does the startup work that needs Podman
+func (d *Daemon) startWithPodman(ctx context.Context) {
+	// Settle creates and destroys the last daemon didn't finish
+	d.recoverIntents(ctx)
+
+	// and what they left behind that the journal doesn't know of
+	d.cleanLeftovers(ctx)
+
+	// Ports may have been taken while the daemon was down
+	d.reconcilePorts(ctx)
+
+	// Serve what the database holds, now that it is settled
+	if err := d.rebuildRouter(ctx); err != nil {
+		log.Warn("Failed to rebuild router", "error", err)
+	}
+
+	// Route pucks that were still starting once they answer
+	d.awaitStarting(ctx)
+
+	if len(d.cfg.WarmImages) > 0 {
+		go d.warmImages(ctx)
+	}
+	go d.sampleStats(ctx)
+	go d.fillPools(ctx)
+	go d.expirePucks(ctx)
+}
+
+// awaitPodman retries connecting to Podman, backing off, until it is up
+// or ctx is done, then finishes startup and lets queued requests through
+func (d *Daemon) awaitPodman(ctx context.Context) {
+	delay := podmanRetryMin
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if err := d.podman.Connect(ctx); err != nil {
+			log.Debug("Podman still unavailable", "error", err, "retry", delay)
+			delay = min(delay*2, podmanRetryMax)
+			continue
+		}
+
+		log.Info("Podman is available")
+		d.startWithPodman(ctx)
+		close(d.podmanUp)
+		return
+	}
+}
+
+// waitPodman holds a request that needs Podman until it is up, for a
+// while, then refuses it
+func (d *Daemon) waitPodman(ctx context.Context) error {
+	if d.podmanUp == nil {
+		return nil
+	}
+	select {
+	case <-d.podmanUp:
+		return nil
+	default:
+	}
+
+	timer := time.NewTimer(podmanQueueTimeout)
+	defer timer.Stop()
+	select {
+	case <-d.podmanUp:
+		return nil
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	err := d.podman.Err()
+	if err == nil {
+		err = fmt.Errorf("%w: still recovering after connecting", podman.ErrUnavailable)
+	}
+	return fmt.Errorf("%w\nThe daemon keeps retrying; check that the Podman service, or podman machine, is running", err)
+}
+
+func (d *Daemon) handlePodmanStatus() Response {
+	status := PodmanStatus{Available: true}
+	if d.podman != nil {
+		if err := d.podman.Err(); err != nil {
+			status = PodmanStatus{Available: false, Error: err.Error()}
+		}
+	}
+	respData, _ := json.Marshal(status)
+	return Response{Success: true, Data: respData}
+}
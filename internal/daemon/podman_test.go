@@ -0,0 +1,66 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPodmanUnavailable(t *testing.T) {
+	// setupDegraded makes a daemon that started before Podman was up
+	setupDegraded := func(t *testing.T) *Daemon {
+		d := setupAuthDaemon(t)
+		d.podman = podman.NewDeferredClient("unix:///nonexistent/podman.sock")
+		d.podmanUp = make(chan struct{})
+		return d
+	}
+	logs, _ := json.Marshal(map[string]string{"name": "alice-puck"})
+
+	t.Run("answers from the database", func(t *testing.T) {
+		d := setupDegraded(t)
+
+		resp := d.handleRequest(context.Background(), &Request{Action: "list", Data: json.RawMessage(`{}`)})
+		require.True(t, resp.Success, resp.Error)
+
+		resp = d.handleRequest(context.Background(), &Request{Action: "podman-status"})
+		require.True(t, resp.Success, resp.Error)
+		var status PodmanStatus
+		require.NoError(t, json.Unmarshal(resp.Data, &status))
+		assert.False(t, status.Available)
+		assert.Contains(t, status.Error, "podman unavailable")
+	})
+
+	t.Run("refuses requests that need podman after a while", func(t *testing.T) {
+		d := setupDegraded(t)
+		defer func(timeout time.Duration) { podmanQueueTimeout = timeout }(podmanQueueTimeout)
+		podmanQueueTimeout = 10 * time.Millisecond
+
+		resp := d.handleRequest(context.Background(), &Request{Action: "logs", Data: logs})
+		assert.False(t, resp.Success)
+		assert.Contains(t, resp.Error, "podman unavailable")
+		assert.Contains(t, resp.Error, "keeps retrying")
+	})
+
+	t.Run("queues requests until podman is up", func(t *testing.T) {
+		d := setupDegraded(t)
+
+		done := make(chan Response, 1)
+		go func() {
+			done <- d.handleRequest(context.Background(), &Request{Action: "logs", Data: logs})
+		}()
+		select {
+		case <-done:
+			t.Fatal("request was answered before podman came up")
+		case <-time.After(20 * time.Millisecond):
+		}
+
+		close(d.podmanUp)
+		resp := <-done
+		assert.True(t, resp.Success, resp.Error)
+	})
+}
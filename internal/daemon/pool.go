@@ -0,0 +1,384 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/sandwich-labs/puck/internal/hooks"
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// poolFillInterval is how often pools are topped up, besides right after
+// one is changed or hands a puck out
+const poolFillInterval = time.Minute
+
+func (d *Daemon) handlePoolCreate(ctx context.Context, data json.RawMessage) Response {
+	var p store.Pool
+	if err := json.Unmarshal(data, &p); err != nil {
+		return errorResponse(err)
+	}
+
+	// Pools are the caller's own, and so are the pucks they make
+	c := callerFrom(ctx)
+	p.Owner = c.User
+	opts, err := puck.PoolOptions(&p, "")
+	if err != nil {
+		return errorResponse(err)
+	}
+	for _, req := range opts.Requires {
+		if err := d.authorizePuck(ctx, c, req); err != nil {
+			return errorResponse(err)
+		}
+	}
+	if err := d.manager.SavePool(ctx, &p); err != nil {
+		return errorResponse(err)
+	}
+	d.kickPools()
+
+	saved, err := d.manager.Pool(ctx, p.Owner, p.Name)
+	if err != nil {
+		return errorResponse(err)
+	}
+	d.countPools(ctx, []*store.Pool{saved})
+
+	respData, _ := json.Marshal(saved)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handlePoolList(ctx context.Context) Response {
+	pools, err := d.manager.ListPools(ctx, callerFrom(ctx).User)
+	if err != nil {
+		return errorResponse(err)
+	}
+	d.countPools(ctx, pools)
+
+	respData, _ := json.Marshal(pools)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handlePoolRemove(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+
+	owner := callerFrom(ctx).User
+	if err := d.manager.RemovePool(ctx, owner, params.Name); err != nil {
+		return errorResponse(err)
+	}
+
+	// Pucks still being made are destroyed by the fill once it sees the
+	// pool is gone
+	pucks, err := d.manager.List(ctx)
+	if err != nil {
+		return errorResponse(err)
+	}
+	for _, p := range pucks {
+		if p.Owner == owner && p.Pool == params.Name && !d.poolFilling(p.Name) && d.stillPooled(ctx, p.Name) {
+			d.destroyPooled(ctx, p.Name)
+		}
+	}
+	return Response{Success: true}
+}
+
+// countPools fills in how many pucks each pool has waiting and being made
+func (d *Daemon) countPools(ctx context.Context, pools []*store.Pool) {
+	pucks, err := d.manager.List(ctx)
+	if err != nil {
+		log.Warn("Failed to list pucks to count pools", "error", err)
+		return
+	}
+	for _, pool := range pools {
+		for _, p := range pucks {
+			if p.Owner != pool.Owner || p.Pool != pool.Name {
+				continue
+			}
+			if d.poolFilling(p.Name) {
+				pool.Filling++
+			} else if p.Status == store.StatusSuspended {
+				pool.Ready++
+			}
+		}
+	}
+}
+
+// createFromPool hands the caller a puck waiting in the pool req.FromPool,
+// resuming it from its checkpoint, or creates one from the pool's options
+// if none is waiting. Either way it gets req's TTL.
+func (d *Daemon) createFromPool(ctx context.Context, req puck.CreateOptions) Response {
+	if req.TTLSnapshot && req.TTL == 0 {
+		return errorResponse(fmt.Errorf("a final snapshot needs a TTL"))
+	}
+	c := callerFrom(ctx)
+	pool, err := d.manager.Pool(ctx, c.User, req.FromPool)
+	if err != nil {
+		return errorResponse(err)
+	}
+	// Whichever way the caller is served, the pool is one short
+	defer d.kickPools()
+
+	for {
+		p, err := d.manager.ClaimPooled(ctx, c.User, pool.Name)
+		if errors.Is(err, store.ErrNotFound) {
+			break
+		}
+		if err != nil {
+			return errorResponse(err)
+		}
+
+		if err := d.startPooled(ctx, pool, p.Name); err != nil {
+			log.Warn("Failed to resume pooled puck, trying another", "pool", pool.Name, "name", p.Name, "error", err)
+			d.destroyPooled(ctx, p.Name)
+			continue
+		}
+		if req.TTL != 0 {
+			p, err = d.manager.SetTTL(ctx, p.Name, req.TTL, &req.TTLSnapshot)
+		} else {
+			p, err = d.manager.Get(ctx, p.Name)
+		}
+		if err != nil {
+			return errorResponse(err)
+		}
+		log.Info("Handed out pooled puck", "pool", pool.Name, "name", p.Name, "owner", c.User)
+		d.fire(hooks.EventPuckCreated, p.Name, "", p)
+
+		respData, _ := json.Marshal(p)
+		return Response{Success: true, Data: respData}
+	}
+
+	log.Info("Pool is empty, creating a puck", "pool", pool.Name, "owner", c.User)
+	opts, err := d.poolPuckOptions(ctx, pool)
+	if err != nil {
+		return errorResponse(err)
+	}
+	opts.Pool = ""
+	opts.TTL, opts.TTLSnapshot = req.TTL, req.TTLSnapshot
+	data, _ := json.Marshal(opts)
+	return d.handleCreate(ctx, data)
+}
+
+// startPooled resumes a puck claimed from a pool, and the pucks it requires
+func (d *Daemon) startPooled(ctx context.Context, pool *store.Pool, name string) error {
+	opts, err := puck.PoolOptions(pool, name)
+	if err != nil {
+		return err
+	}
+	if err := d.startRequirements(ctx, opts.Requires); err != nil {
+		return err
+	}
+	return d.startPuck(ctx, name)
+}
+
+// poolPuckOptions names a new puck for a pool and returns its options
+func (d *Daemon) poolPuckOptions(ctx context.Context, pool *store.Pool) (puck.CreateOptions, error) {
+	name, err := puck.GenerateName(pool.NamePattern, func(name string) (bool, error) {
+		_, err := d.manager.Get(ctx, name)
+		if errors.Is(err, store.ErrNotFound) {
+			return false, nil
+		}
+		return err == nil, err
+	})
+	if err != nil {
+		return puck.CreateOptions{}, err
+	}
+	return puck.PoolOptions(pool, name)
+}
+
+// kickPools has fillPools top pools up now rather than at its next tick
+func (d *Daemon) kickPools() {
+	select {
+	case d.poolKicks() <- struct{}{}:
+	default:
+	}
+}
+
+func (d *Daemon) poolKicks() chan struct{} {
+	d.poolMu.Lock()
+	defer d.poolMu.Unlock()
+	if d.poolKick == nil {
+		d.poolKick = make(chan struct{}, 1)
+	}
+	return d.poolKick
+}
+
+// poolFilling reports whether a pooled puck is still being made
+func (d *Daemon) poolFilling(name string) bool {
+	d.poolMu.Lock()
+	defer d.poolMu.Unlock()
+	return d.filling[name]
+}
+
+func (d *Daemon) setPoolFilling(name string, filling bool) {
+	d.poolMu.Lock()
+	defer d.poolMu.Unlock()
+	if !filling {
+		delete(d.filling, name)
+		return
+	}
+	if d.filling == nil {
+		d.filling = make(map[string]bool)
+	}
+	d.filling[name] = true
+}
+
+// fillPools keeps every pool stocked with suspended pucks
+func (d *Daemon) fillPools(ctx context.Context) {
+	ticker := time.NewTicker(poolFillInterval)
+	defer ticker.Stop()
+
+	kicks := d.poolKicks()
+	for {
+		d.fillAllPools(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-kicks:
+		}
+	}
+}
+
+// fillAllPools destroys pooled pucks that can't be handed out, such as
+// those a previous daemon was making, and makes pucks pools are short of
+func (d *Daemon) fillAllPools(ctx context.Context) {
+	pools, err := d.manager.ListPools(ctx, "")
+	if err != nil {
+		log.Warn("Failed to list pools", "error", err)
+		return
+	}
+	pucks, err := d.manager.List(ctx)
+	if err != nil {
+		log.Warn("Failed to list pucks to fill pools", "error", err)
+		return
+	}
+
+	type poolKey struct{ owner, name string }
+	short := make(map[poolKey]int, len(pools))
+	for _, pool := range pools {
+		short[poolKey{pool.Owner, pool.Name}] = pool.Size
+	}
+	for _, p := range pucks {
+		if p.Pool == "" || d.poolFilling(p.Name) {
+			continue
+		}
+		key := poolKey{p.Owner, p.Pool}
+		if n, ok := short[key]; ok && n > 0 && p.Status == store.StatusSuspended {
+			short[key] = n - 1
+			continue
+		}
+		if d.stillPooled(ctx, p.Name) {
+			log.Info("Destroying pooled puck that can't be handed out", "pool", p.Pool, "name", p.Name, "status", p.Status)
+			d.destroyPooled(ctx, p.Name)
+		}
+	}
+
+	if len(pools) == 0 {
+		return
+	}
+	if d.manager.CRIUVersion(ctx) == "" {
+		d.poolMu.Lock()
+		warned := d.poolWarned
+		d.poolWarned = true
+		d.poolMu.Unlock()
+		if !warned {
+			log.Warn("Pools need CRIU to checkpoint their pucks; not filling them")
+		}
+		return
+	}
+
+	for _, pool := range pools {
+		for range short[poolKey{pool.Owner, pool.Name}] {
+			if ctx.Err() != nil {
+				return
+			}
+			if err := d.fillPool(ctx, pool); err != nil {
+				log.Warn("Failed to fill pool", "pool", pool.Name, "owner", pool.Owner, "error", err)
+				break
+			}
+		}
+	}
+}
+
+// fillPool makes one puck for a pool: created, provisioned, ready, then
+// suspended to a checkpoint to wait to be handed out
+func (d *Daemon) fillPool(ctx context.Context, pool *store.Pool) error {
+	opts, err := d.poolPuckOptions(ctx, pool)
+	if err != nil {
+		return err
+	}
+	d.setPoolFilling(opts.Name, true)
+	defer d.setPoolFilling(opts.Name, false)
+
+	if err := d.startRequirements(ctx, opts.Requires); err != nil {
+		return err
+	}
+	p, err := d.manager.Create(ctx, opts)
+	if err != nil {
+		return err
+	}
+	d.startSyncs(p)
+
+	// Undo the puck if it can't be made to wait in the pool
+	if err := d.readyPooled(ctx, p, opts); err != nil {
+		d.destroyPooled(ctx, p.Name)
+		return fmt.Errorf("puck '%s': %w", p.Name, err)
+	}
+
+	// The pool may have been removed while the puck was made
+	if _, err := d.manager.Pool(ctx, pool.Owner, pool.Name); errors.Is(err, store.ErrNotFound) {
+		d.destroyPooled(ctx, p.Name)
+		return nil
+	}
+	log.Info("Filled pool", "pool", pool.Name, "owner", pool.Owner, "name", p.Name)
+	return nil
+}
+
+// readyPooled provisions a new pooled puck, waits for its app, and
+// suspends it
+func (d *Daemon) readyPooled(ctx context.Context, p *store.Puck, opts puck.CreateOptions) error {
+	if opts.Repo != nil {
+		if err := d.manager.CloneRepo(ctx, p.Name, *opts.Repo); err != nil {
+			return err
+		}
+	}
+	if len(opts.Provision) > 0 {
+		if err := d.manager.Provision(ctx, p.Name, opts.Provision); err != nil {
+			return err
+		}
+	}
+
+	if p.HostPort > 0 {
+		d.awaitReady(ctx, p)
+	} else if err := d.manager.MarkReady(ctx, p.Name); err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return d.manager.StopWithOptions(ctx, puck.StopOptions{Name: p.Name, Checkpoint: true})
+}
+
+// stillPooled reports whether a puck is still waiting in a pool, rather
+// than handed out since it was listed
+func (d *Daemon) stillPooled(ctx context.Context, name string) bool {
+	p, err := d.manager.Get(ctx, name)
+	return err == nil && p.Pool != ""
+}
+
+// destroyPooled destroys a puck that was never handed out, so no hook
+// hears of it
+func (d *Daemon) destroyPooled(ctx context.Context, name string) {
+	d.stopSyncs(name)
+	if err := d.manager.Destroy(ctx, name, true); err != nil {
+		log.Warn("Failed to destroy pooled puck", "name", name, "error", err)
+		return
+	}
+	d.stopAgent(name)
+}
@@ -0,0 +1,139 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupPoolDaemon is setupAuthDaemon with no container running, as for
+// suspended pucks
+func setupPoolDaemon(t *testing.T) *Daemon {
+	t.Helper()
+	d := setupAuthDaemon(t)
+	mock := podman.NewMockClient()
+	mock.IsRunningFunc = func(ctx context.Context, nameOrID string) (bool, error) {
+		return false, nil
+	}
+	d.manager = puck.NewManager(d.cfg, mock, d.store)
+	return d
+}
+
+func TestPooledPucksAreHidden(t *testing.T) {
+	d := setupPoolDaemon(t)
+	ctx := context.Background()
+	alice := withCaller(ctx, caller{User: "alice"})
+
+	pooled := &store.Puck{ID: "p1", Name: "pooled", Image: "fedora", Status: store.StatusSuspended, VolumeDir: t.TempDir(), Owner: "alice", Pool: "node", HostPort: 9001}
+	require.NoError(t, d.store.CreatePuck(ctx, pooled))
+
+	want, _ := d.wantsRoute(ctx, pooled)
+	assert.False(t, want, "pooled pucks wait unrouted")
+
+	resp := d.handleList(alice, nil)
+	require.True(t, resp.Success, resp.Error)
+	var pucks []*store.Puck
+	require.NoError(t, json.Unmarshal(resp.Data, &pucks))
+	require.Len(t, pucks, 1)
+	assert.Equal(t, "alice-puck", pucks[0].Name)
+}
+
+func TestPoolRequests(t *testing.T) {
+	d := setupPoolDaemon(t)
+	ctx := context.Background()
+	alice := withCaller(ctx, caller{User: "alice"})
+
+	data, _ := json.Marshal(store.Pool{Name: "node", Size: 2, Options: json.RawMessage(`{"name":"{{PUCK_NAME}}","image":"node:22"}`)})
+	resp := d.handlePoolCreate(alice, data)
+	require.True(t, resp.Success, resp.Error)
+
+	for _, p := range []*store.Puck{
+		{ID: "r1", Name: "waiting", Status: store.StatusSuspended, Pool: "node"},
+		{ID: "f1", Name: "filling", Status: store.StatusStarting, Pool: "node"},
+		{ID: "s1", Name: "stale", Status: store.StatusStarting, Pool: "node"},
+	} {
+		p.Image = "fedora"
+		p.Owner = "alice"
+		p.VolumeDir = filepath.Join(t.TempDir(), p.Name)
+		require.NoError(t, d.store.CreatePuck(ctx, p))
+	}
+	d.setPoolFilling("filling", true)
+
+	resp = d.handlePoolList(alice)
+	require.True(t, resp.Success, resp.Error)
+	var pools []*store.Pool
+	require.NoError(t, json.Unmarshal(resp.Data, &pools))
+	require.Len(t, pools, 1)
+	assert.Equal(t, 1, pools[0].Ready)
+	assert.Equal(t, 1, pools[0].Filling)
+
+	t.Run("bob can't see alice's pools", func(t *testing.T) {
+		resp := d.handlePoolList(withCaller(ctx, caller{User: "bob"}))
+		require.True(t, resp.Success, resp.Error)
+		assert.JSONEq(t, `null`, string(resp.Data))
+	})
+
+	t.Run("pool creation is authorized like create", func(t *testing.T) {
+		data, _ := json.Marshal(map[string]any{"name": "node", "size": 1, "options": map[string]any{"requires": []string{"bob-puck"}}})
+		resp := d.handlePoolCreate(alice, data)
+		assert.False(t, resp.Success)
+		assert.Contains(t, resp.Error, "permission denied")
+
+		data, _ = json.Marshal(map[string]any{"name": "node", "size": 1, "options": map[string]any{"mounts": []store.Mount{{Source: "/root", Target: "/src"}}}})
+		assert.ErrorContains(t, d.authorize(alice, &Request{Action: "pool-create", Data: data}), "permission denied")
+	})
+
+	t.Run("removing the pool destroys its waiting pucks", func(t *testing.T) {
+		data, _ := json.Marshal(map[string]string{"name": "node"})
+		resp := d.handlePoolRemove(alice, data)
+		require.True(t, resp.Success, resp.Error)
+
+		for name, kept := range map[string]bool{"waiting": false, "stale": false, "filling": true} {
+			_, err := d.store.GetPuck(ctx, name)
+			if kept {
+				assert.NoError(t, err, name)
+			} else {
+				assert.ErrorIs(t, err, store.ErrNotFound, name)
+			}
+		}
+	})
+}
+
+func TestFillAllPoolsClearsStalePucks(t *testing.T) {
+	d := setupPoolDaemon(t)
+	ctx := context.Background()
+
+	require.NoError(t, d.store.SavePool(ctx, &store.Pool{Owner: "alice", Name: "node", Size: 1}))
+	for _, p := range []*store.Puck{
+		{ID: "r1", Name: "waiting", Status: store.StatusSuspended, Pool: "node"},
+		{ID: "r2", Name: "surplus", Status: store.StatusSuspended, Pool: "node"},
+		{ID: "s1", Name: "half-made", Status: store.StatusStarting, Pool: "node"},
+		{ID: "g1", Name: "orphan", Status: store.StatusSuspended, Pool: "gone"},
+	} {
+		p.Image = "fedora"
+		p.Owner = "alice"
+		p.VolumeDir = filepath.Join(t.TempDir(), p.Name)
+		require.NoError(t, d.store.CreatePuck(ctx, p))
+	}
+
+	d.fillAllPools(ctx)
+
+	pucks, err := d.store.ListPucks(ctx)
+	require.NoError(t, err)
+	var pooled []string
+	for _, p := range pucks {
+		if p.Pool != "" {
+			pooled = append(pooled, p.Name)
+		}
+	}
+	assert.Len(t, pooled, 1, "one puck waits in the pool of one")
+	assert.NotContains(t, pooled, "half-made")
+	assert.NotContains(t, pooled, "orphan")
+}
@@ -0,0 +1,193 @@
+package daemon
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/sandwich-labs/puck/internal/hooks"
+	"github.com/sandwich-labs/puck/internal/network"
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// psiMemoryPath is where the kernel reports memory pressure
+const psiMemoryPath = "/proc/pressure/memory"
+
+const (
+	pressureInterval = 10 * time.Second // how often memory pressure is sampled
+	pressureCooldown = 30 * time.Second // lets avg10 settle after a checkpoint
+)
+
+// parseMemoryPressure returns the "some avg10" figure from a PSI file: the
+// percentage of the last ten seconds in which some task stalled on memory
+func parseMemoryPressure(data []byte) (float64, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || fields[0] != "some" {
+			continue
+		}
+		for _, field := range fields[1:] {
+			if v, ok := strings.CutPrefix(field, "avg10="); ok {
+				return strconv.ParseFloat(v, 64)
+			}
+		}
+	}
+	return 0, fmt.Errorf("no \"some avg10\" in pressure data")
+}
+
+// watchPressure checkpoints the least recently used puck, one at a time,
+// while memory pressure stays at or above the configured threshold
+func (d *Daemon) watchPressure(ctx context.Context) {
+	ticker := time.NewTicker(pressureInterval)
+	defer ticker.Stop()
+
+	var quietUntil time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if now.Before(quietUntil) {
+				continue
+			}
+			data, err := os.ReadFile(psiMemoryPath)
+			if err != nil {
+				log.Warn("Memory pressure unavailable; not checkpointing under pressure", "error", err)
+				return
+			}
+			pressure, err := parseMemoryPressure(data)
+			if err != nil {
+				log.Warn("Failed to parse memory pressure", "error", err)
+				continue
+			}
+			if pressure < d.cfg.MemoryPressure {
+				continue
+			}
+			if d.relievePressure(ctx, pressure) {
+				quietUntil = now.Add(pressureCooldown)
+			}
+		}
+	}
+}
+
+// relievePressure checkpoints the least recently used puck, leaving its
+// route asleep so the next request resumes it. It reports whether a puck
+// was checkpointed.
+func (d *Daemon) relievePressure(ctx context.Context, pressure float64) bool {
+	d.syncAccess(ctx)
+
+	p, err := d.manager.CheckpointLRU(ctx, "memory pressure")
+	if err != nil {
+		log.Warn("Failed to checkpoint puck under memory pressure", "error", err)
+		return false
+	}
+	if p == nil {
+		return false
+	}
+
+	log.Info("Checkpointed puck under memory pressure", "name", p.Name, "pressure", pressure)
+	d.sleepRoute(p.Name)
+	d.fire(hooks.EventPuckCheckpointed, p.Name, "", p)
+	return true
+}
+
+// syncAccess stores when the router last proxied to each puck, so HTTP
+// traffic counts as use when picking which puck to checkpoint
+func (d *Daemon) syncAccess(ctx context.Context) {
+	for name, at := range d.router.LastAccess() {
+		p, err := d.manager.Get(ctx, name)
+		if err != nil || !at.After(p.LastUsedAt) {
+			continue
+		}
+		if err := d.manager.Touch(ctx, name, at); err != nil {
+			log.Warn("Failed to record puck access", "name", name, "error", err)
+		}
+	}
+}
+
+// sleepCheckpointed puts the routes of automatically checkpointed pucks to
+// sleep, e.g. after a start checkpointed others to stay within the budget
+func (d *Daemon) sleepCheckpointed(ctx context.Context) {
+	pucks, err := d.manager.List(ctx)
+	if err != nil {
+		log.Warn("Failed to list pucks", "error", err)
+		return
+	}
+
+	routes := d.router.GetRoutes()
+	for _, p := range pucks {
+		if _, routed := routes[p.Name]; !routed || p.Status != store.StatusSuspended || d.router.Asleep(p.Name) {
+			continue
+		}
+		d.sleepRoute(p.Name)
+		d.fire(hooks.EventPuckCheckpointed, p.Name, "", p)
+	}
+}
+
+// sleepRoute leaves a checkpointed puck routed so a request wakes it
+func (d *Daemon) sleepRoute(name string) {
+	if err := d.router.Sleep(name); err != nil {
+		log.Warn("Failed to put route to sleep", "name", name, "error", err)
+	}
+}
+
+// autoCheckpointed reports whether a puck was last checkpointed by the
+// daemon rather than by a snapshot the user took
+func (d *Daemon) autoCheckpointed(ctx context.Context, name string) bool {
+	events, err := d.manager.History(ctx, name, time.Time{})
+	if err != nil {
+		return false
+	}
+	// Port changes made while it was checkpointed don't count
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Type != store.EventPortChanged {
+			return events[i].Type == store.EventCheckpointed
+		}
+	}
+	return false
+}
+
+// wakePuck resumes a sleeping puck for the router
+func (d *Daemon) wakePuck(ctx context.Context, name string, req network.WakeRequest) error {
+	var beforeIP string
+	var beforePort int
+	if p, err := d.manager.Get(ctx, name); err == nil {
+		beforeIP, beforePort = d.upstream(p)
+	}
+	// The manager leaves the route for this request's sake
+	log.Info("Waking puck on request", "name", name, "request_id", req.ID, "method", req.Method, "host", req.Host, "path", req.Path, "client", req.Client)
+	// Anything the wake does, such as checkpointing others for room, is put
+	// down to the request in the events it records
+	ctx = puck.WithTrigger(ctx, fmt.Sprintf("waking %s for %s", name, req))
+	if err := d.manager.Start(withWaking(ctx), name); err != nil {
+		log.Warn("Failed to wake puck on request", "name", name, "request_id", req.ID, "error", err)
+		return err
+	}
+	log.Info("Woke puck on request", "name", name, "request_id", req.ID)
+
+	p, err := d.manager.Get(ctx, name)
+	if err == nil {
+		d.fire(hooks.EventPuckStarted, name, "", p)
+		if ip, port := d.upstream(p); ip != beforeIP || port != beforePort {
+			// The router can't reload while it is serving this request,
+			// which still goes to the old upstream; later ones use the new one
+			go func() {
+				if err := d.addRoute(p); err != nil {
+					log.Warn("Failed to update route for woken puck", "name", name, "error", err)
+				}
+			}()
+			d.notePortChange(beforePort, p)
+		}
+	}
+	// Waking may have checkpointed others to stay within the budget
+	d.sleepCheckpointed(ctx)
+	return nil
+}
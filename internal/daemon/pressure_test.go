@@ -0,0 +1,28 @@
+package daemon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMemoryPressure(t *testing.T) {
+	t.Run("reads some avg10", func(t *testing.T) {
+		data := []byte("some avg10=12.50 avg60=4.01 avg300=1.00 total=123456\n" +
+			"full avg10=3.20 avg60=1.00 avg300=0.20 total=23456\n")
+		pressure, err := parseMemoryPressure(data)
+		require.NoError(t, err)
+		assert.Equal(t, 12.5, pressure)
+	})
+
+	t.Run("rejects data without some", func(t *testing.T) {
+		_, err := parseMemoryPressure([]byte("full avg10=3.20 avg60=1.00 avg300=0.20 total=23456\n"))
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects malformed values", func(t *testing.T) {
+		_, err := parseMemoryPressure([]byte("some avg10=high avg60=0 avg300=0 total=0\n"))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,108 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/containers/podman/v5/libpod/define"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// Readiness probing of new pucks
+const (
+	readyInterval     = 500 * time.Millisecond
+	readyProbeTimeout = 2 * time.Second
+)
+
+// readyClient probes without following redirects, since any answer means
+// the app is listening
+var readyClient = &http.Client{
+	Timeout: readyProbeTimeout,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// probeReady reports whether an app answers HTTP at ip:port. Any status
+// counts: a port forwarder accepts connections before the app listens,
+// but only the app sends a response.
+func probeReady(ctx context.Context, ip string, port int) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://%s/", net.JoinHostPort(ip, strconv.Itoa(port))), nil)
+	if err != nil {
+		return false
+	}
+	resp, err := readyClient.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return true
+}
+
+// ready reports whether a starting puck can be routed: once its image's
+// HEALTHCHECK passes if it has one, otherwise once its app answers HTTP,
+// on whichever port it was found listening on, or once its agent finds it
+// booted with nothing listening to wait for.
+// It also returns the health status, empty without a healthcheck.
+func (d *Daemon) ready(ctx context.Context, p *store.Puck) (bool, string) {
+	health := d.manager.Health(ctx, p)
+	if health == "" {
+		if r := d.agentReport(p.Name); r != nil && r.Ready && len(r.Ports) == 0 {
+			return true, ""
+		}
+		d.probeAppPort(ctx, p)
+		ip, port := d.upstream(p)
+		return probeReady(ctx, ip, port), ""
+	}
+	return health == define.HealthCheckHealthy, health
+}
+
+// awaitReady routes a starting puck once it is ready, or once
+// ready_timeout passes for pucks that don't serve HTTP, and marks it running
+func (d *Daemon) awaitReady(ctx context.Context, p *store.Puck) {
+	d.ensureAgent(ctx, p)
+	ip, port := d.upstream(p)
+	deadline := time.Now().Add(time.Duration(d.cfg.ReadyTimeout) * time.Second)
+	for {
+		ready, health := d.ready(ctx, p)
+		if ready {
+			break
+		}
+		if time.Now().After(deadline) {
+			if health != "" {
+				log.Warn("Puck's healthcheck did not pass in time, routing it anyway", "name", p.Name, "health", health, "timeout", d.cfg.ReadyTimeout)
+			} else {
+				log.Warn("Puck did not answer HTTP in time, routing it anyway", "name", p.Name, "upstream", net.JoinHostPort(ip, strconv.Itoa(port)), "timeout", d.cfg.ReadyTimeout)
+			}
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(readyInterval):
+		}
+	}
+
+	if err := d.manager.MarkReady(ctx, p.Name); err != nil {
+		log.Warn("Failed to mark puck ready", "name", p.Name, "error", err)
+		return
+	}
+
+	// Route settings may have changed, or the puck stopped, while it
+	// started, and pucks made for a pool wait unrouted
+	current, err := d.manager.Get(ctx, p.Name)
+	if err != nil || current.Status != store.StatusRunning {
+		return
+	}
+	if want, _ := d.wantsRoute(ctx, current); !want {
+		return
+	}
+	if err := d.addRoute(current); err != nil {
+		log.Warn("Failed to add route for puck", "name", p.Name, "error", err)
+	}
+}
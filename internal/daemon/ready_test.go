@@ -0,0 +1,201 @@
+package daemon
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/containers/podman/v5/libpod/define"
+	"github.com/sandwich-labs/puck/internal/agent"
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProbeReady(t *testing.T) {
+	t.Run("any HTTP answer is ready", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "/login", http.StatusFound)
+		}))
+		defer srv.Close()
+
+		assert.True(t, probeReady(context.Background(), "127.0.0.1", srv.Listener.Addr().(*net.TCPAddr).Port))
+	})
+
+	t.Run("a listener that never answers is not ready", func(t *testing.T) {
+		// Like a port forwarder with nothing behind it
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer ln.Close()
+		go func() {
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				conn.Close()
+			}
+		}()
+
+		assert.False(t, probeReady(context.Background(), "127.0.0.1", ln.Addr().(*net.TCPAddr).Port))
+	})
+}
+
+func TestUpstream(t *testing.T) {
+	p := &store.Puck{Name: "web", HostPort: 9000, ContainerIP: "10.88.0.5"}
+
+	t.Run("host port", func(t *testing.T) {
+		d := &Daemon{cfg: &config.Config{RouteMode: config.RouteHostPort}}
+		ip, port := d.upstream(p)
+		assert.Equal(t, "127.0.0.1", ip)
+		assert.Equal(t, 9000, port)
+	})
+
+	t.Run("container IP", func(t *testing.T) {
+		d := &Daemon{cfg: &config.Config{RouteMode: config.RouteContainerIP}}
+		ip, port := d.upstream(p)
+		assert.Equal(t, "10.88.0.5", ip)
+		assert.Equal(t, 80, port)
+	})
+
+	t.Run("container IP at the port the agent found the app on", func(t *testing.T) {
+		d := &Daemon{cfg: &config.Config{RouteMode: config.RouteContainerIP}}
+		d.agents = map[string]*puckAgent{"web": {report: &agent.Report{AppPort: 3000, Updated: time.Now()}}}
+		ip, port := d.upstream(p)
+		assert.Equal(t, "10.88.0.5", ip)
+		assert.Equal(t, 3000, port)
+
+		// A stale report is no longer gone by
+		d.agents["web"].report.Updated = time.Now().Add(-agent.StaleAfter)
+		_, port = d.upstream(p)
+		assert.Equal(t, 80, port)
+	})
+
+	t.Run("host port whatever the agent found, as only 80 is published", func(t *testing.T) {
+		d := &Daemon{cfg: &config.Config{RouteMode: config.RouteHostPort}}
+		d.agents = map[string]*puckAgent{"web": {report: &agent.Report{AppPort: 3000, Updated: time.Now()}}}
+		_, port := d.upstream(p)
+		assert.Equal(t, 9000, port)
+	})
+
+	t.Run("falls back to the host port without a container IP", func(t *testing.T) {
+		d := &Daemon{cfg: &config.Config{RouteMode: config.RouteContainerIP}}
+		ip, port := d.upstream(&store.Puck{Name: "web", HostPort: 9000})
+		assert.Equal(t, "127.0.0.1", ip)
+		assert.Equal(t, 9000, port)
+	})
+}
+
+func TestReadyFollowsHealthcheck(t *testing.T) {
+	d := setupAuthDaemon(t)
+	mock := podman.NewMockClient()
+	d.manager = puck.NewManager(d.cfg, mock, d.store)
+	ctx := context.Background()
+
+	health := func(status string) {
+		mock.InspectContainerFunc = func(ctx context.Context, nameOrID string) (*define.InspectContainerData, error) {
+			state := &define.InspectContainerState{}
+			if status != "" {
+				state.Health = &define.HealthCheckResults{Status: status}
+			}
+			return &define.InspectContainerData{State: state}, nil
+		}
+	}
+	p := &store.Puck{Name: "web", ContainerID: "c-web", Status: store.StatusStarting, HostPort: 1}
+
+	health(define.HealthCheckStarting)
+	ready, status := d.ready(ctx, p)
+	assert.False(t, ready)
+	assert.Equal(t, "starting", status)
+
+	health(define.HealthCheckUnhealthy)
+	ready, _ = d.ready(ctx, p)
+	assert.False(t, ready)
+
+	health(define.HealthCheckHealthy)
+	ready, _ = d.ready(ctx, p)
+	assert.True(t, ready)
+
+	// Without a healthcheck, the app has to answer HTTP
+	health("")
+	ready, status = d.ready(ctx, p)
+	assert.False(t, ready)
+	assert.Empty(t, status)
+
+	// Health is only reported while the puck is up
+	p.Status = store.StatusStopped
+	health(define.HealthCheckHealthy)
+	assert.Empty(t, d.manager.Health(ctx, p))
+}
+
+func TestReadyFollowsAgent(t *testing.T) {
+	d := setupAuthDaemon(t)
+	d.manager = puck.NewManager(d.cfg, podman.NewMockClient(), d.store)
+	ctx := context.Background()
+	p := &store.Puck{Name: "web", ContainerID: "c-web", Status: store.StatusStarting, HostPort: 1}
+	report := func(r agent.Report) {
+		r.Updated = time.Now()
+		d.agents = map[string]*puckAgent{"web": {report: &r}}
+	}
+
+	// Booted with nothing listening: there is no app to wait for
+	report(agent.Report{Ready: true})
+	ready, _ := d.ready(ctx, p)
+	assert.True(t, ready)
+
+	// Still booting, or listening: the app has to answer HTTP
+	report(agent.Report{})
+	ready, _ = d.ready(ctx, p)
+	assert.False(t, ready)
+
+	report(agent.Report{Ready: true, Ports: []agent.Port{{Port: 3000, Address: "0.0.0.0"}}, AppPort: 3000})
+	ready, _ = d.ready(ctx, p)
+	assert.False(t, ready)
+}
+
+func TestProbeAppPort(t *testing.T) {
+	d := setupAuthDaemon(t)
+	d.cfg.RouteMode = config.RouteContainerIP
+	d.cfg.DetectAppPort = true
+	mock := podman.NewMockClient()
+	d.manager = puck.NewManager(d.cfg, mock, d.store)
+	ctx := context.Background()
+	p := &store.Puck{Name: "web", ContainerID: "c-web", Status: store.StatusRunning, HostPort: 9000, ContainerIP: "10.88.0.5"}
+
+	probes := 0
+	mock.ExecFunc = func(ctx context.Context, containerID string, opts podman.ExecOptions) error {
+		probes++
+		opts.Output.Write([]byte("   0: 00000000:1F40 00000000:0000 0A\n"))
+		return nil
+	}
+	d.probeAppPort(ctx, p)
+	_, port := d.upstream(p)
+	assert.Equal(t, 8000, port)
+	assert.Equal(t, 8000, d.appPort("web"))
+
+	// Probed moments ago
+	d.probeAppPort(ctx, p)
+	assert.Equal(t, 1, probes)
+
+	// A reporting agent is believed instead
+	d.agents = map[string]*puckAgent{"web": {report: &agent.Report{AppPort: 3000, Updated: time.Now()}}}
+	_, port = d.upstream(p)
+	assert.Equal(t, 3000, port)
+
+	d.agents = nil
+	d.forgetAppPort("web")
+	_, port = d.upstream(p)
+	assert.Equal(t, 80, port)
+
+	t.Run("not when turned off", func(t *testing.T) {
+		d.cfg.DetectAppPort = false
+		d.probeAppPort(ctx, p)
+		assert.Equal(t, 0, d.appPort("web"))
+	})
+}
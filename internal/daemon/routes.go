@@ -0,0 +1,63 @@
+package daemon
+
+import (
+	"context"
+
+	"github.com/charmbracelet/log"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// puckRoutes is the manager's view of the daemon's router, which it keeps
+// in step with pucks as they start, stop, are restored and are destroyed
+type puckRoutes struct {
+	d *Daemon
+}
+
+type wakingKey struct{}
+
+// withWaking marks a context as waking a puck for a request the router is
+// serving, which the router can't reload during
+func withWaking(ctx context.Context) context.Context {
+	return context.WithValue(ctx, wakingKey{}, true)
+}
+
+// Route routes a puck that is up. A puck being woken keeps the route it
+// slept with; wakePuck updates it once the request is served.
+func (r puckRoutes) Route(ctx context.Context, p *store.Puck) {
+	if waking, _ := ctx.Value(wakingKey{}).(bool); waking {
+		return
+	}
+	if err := r.d.addRoute(p); err != nil {
+		log.Warn("Failed to add route for puck", "name", p.Name, "error", err)
+	}
+}
+
+// Unroute removes the route of a puck that is no longer up, and forgets
+// what its agent reported and where its app was found
+func (r puckRoutes) Unroute(ctx context.Context, name string) {
+	r.d.stopAgent(name)
+	r.d.forgetAppPort(name)
+	if err := r.d.router.RemoveRoute(name); err != nil {
+		log.Warn("Failed to remove route for puck", "name", name, "error", err)
+	}
+}
+
+// Forget removes the route, tailnet node, share links, aliases and
+// endpoints of a destroyed puck
+func (r puckRoutes) Forget(ctx context.Context, name string) {
+	if err := r.d.router.RemoveRoute(name); err != nil {
+		log.Warn("Failed to remove route for puck", "name", name, "error", err)
+	}
+	if err := r.d.router.UnshareFromTailnet(name); err != nil {
+		log.Warn("Failed to remove tailnet node for puck", "name", name, "error", err)
+	}
+	if err := r.d.router.RemoveShares(name); err != nil {
+		log.Warn("Failed to remove share links for puck", "name", name, "error", err)
+	}
+	if err := r.d.router.RemoveAliases(name); err != nil {
+		log.Warn("Failed to remove route aliases for puck", "name", name, "error", err)
+	}
+	if err := r.d.router.RemoveEndpoints(name); err != nil {
+		log.Warn("Failed to remove endpoints for puck", "name", name, "error", err)
+	}
+}
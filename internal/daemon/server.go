@@ -1,16 +1,26 @@
 package daemon
 
 import (
+	"cmp"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net"
+	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/charmbracelet/log"
+	"github.com/sandwich-labs/puck/internal/buildinfo"
 	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/sandwich-labs/puck/internal/hooks"
 	"github.com/sandwich-labs/puck/internal/network"
 	"github.com/sandwich-labs/puck/internal/podman"
 	"github.com/sandwich-labs/puck/internal/puck"
@@ -20,14 +30,41 @@ import (
 // Daemon is the main daemon server
 type Daemon struct {
 	cfg     *config.Config
-	podman  *podman.Client
+	podman  *podman.DeferredClient
 	store   *store.DB
 	manager *puck.Manager
 	router  *network.Router
+	hooks   *hooks.Runner
 
 	listener net.Listener
+	logFile  io.Closer      // the log file outside systemd
+	remote   net.Listener   // optional TCP+TLS listener for remote contexts
+	tailnet  io.Closer      // the API's tailnet node, in Tailscale mode
+	tunnel   network.Tunnel // the share tunnel, while it is open
+	webhooks *http.Server
 	mu       sync.RWMutex
 	running  bool
+
+	syncMu sync.Mutex
+	syncs  map[string][]syncSession // by puck name
+
+	agentMu sync.Mutex
+	agents  map[string]*puckAgent // by puck name
+
+	portsMu sync.Mutex
+	probed  map[string]*probedPorts // by puck name
+
+	poolMu     sync.Mutex
+	poolKick   chan struct{}
+	filling    map[string]bool // pooled pucks being made, by name
+	poolWarned bool            // that pools can't be filled without CRIU
+
+	// Closed once Podman, unavailable when the daemon started, is up;
+	// nil if it was up from the start
+	podmanUp chan struct{}
+
+	// Read-only mode, guarded by mu
+	maintenance Maintenance
 }
 
 // New creates a new daemon instance
@@ -37,13 +74,26 @@ func New() (*Daemon, error) {
 		return nil, fmt.Errorf("loading config: %w", err)
 	}
 
+	// Outside systemd, which keeps the journal, logs also go to a file
+	// for puck daemon logs
+	var logFile io.Closer
+	if !underSystemd() {
+		if logFile, err = openLogFile(cfg.DaemonLogPath()); err != nil {
+			log.Warn("Failed to open log file", "path", cfg.DaemonLogPath(), "error", err)
+		}
+	}
+
+	// At login the daemon may start before the Podman socket; it runs
+	// without Podman until it can connect
 	ctx := context.Background()
-	pc, err := podman.NewClient(ctx, cfg.PodmanSocket)
-	if err != nil {
-		return nil, fmt.Errorf("connecting to podman: %w", err)
+	pc := podman.NewDeferredClient(cfg.PodmanSocket)
+	var podmanUp chan struct{}
+	if err := pc.Connect(ctx); err != nil {
+		log.Warn("Podman unavailable, starting without it", "error", err)
+		podmanUp = make(chan struct{})
 	}
 
-	db, err := store.Open(cfg.DatabasePath())
+	db, err := store.OpenDSN(cfg.DatabaseDSN())
 	if err != nil {
 		return nil, fmt.Errorf("opening database: %w", err)
 	}
@@ -54,15 +104,56 @@ func New() (*Daemon, error) {
 	router := network.NewRouter(cfg.RouterPort, cfg.RouterDomain)
 	if cfg.Tailnet != "" {
 		router.SetTailnet(cfg.Tailnet)
+		router.SetTailscaleDir(cfg.RouterTailscaleDir())
+	}
+	router.SetTLS(network.TLSOptions{
+		Port:     cfg.RouterTLSPort,
+		CertFile: cfg.RouterTLSCert,
+		KeyFile:  cfg.RouterTLSKey,
+		HTTP3:    cfg.RouterHTTP3,
+
+		Domains:        cfg.RouterTLSDomains,
+		ACMEEmail:      cfg.RouterACMEEmail,
+		ACMECA:         cfg.RouterACMECA,
+		DNSProvider:    cfg.RouterTLSDNS,
+		DNSCredentials: cfg.RouterTLSDNSCredentials,
+	})
+	// A share tunnel needs the share listener, on a free port if
+	// share_port doesn't name one
+	sharePort := cfg.SharePort
+	if sharePort == 0 && cfg.ShareTunnel != "" {
+		if sharePort, err = freeLoopbackPort(); err != nil {
+			return nil, err
+		}
+	}
+	router.SetSharePort(sharePort)
+	router.SetEventHandler(func(ev network.Event) {
+		log.Warn("Router event", "type", ev.Type, "message", ev.Message, "error", ev.Err)
+	})
+	router.SetLandingTemplate(cfg.LandingTemplate)
+	if cfg.RouterProcess == config.RouterChild {
+		router.RunInChild(filepath.Dir(cfg.DaemonSocket))
 	}
 
-	return &Daemon{
+	d := &Daemon{
 		cfg:     cfg,
 		podman:  pc,
 		store:   db,
 		manager: mgr,
 		router:  router,
-	}, nil
+		hooks:   hooks.NewRunner(cfg.HooksDir, time.Duration(cfg.HookTimeout)*time.Second),
+
+		podmanUp:    podmanUp,
+		logFile:     logFile,
+		maintenance: loadMaintenance(cfg.MaintenancePath()),
+	}
+	mgr.SetRouter(puckRoutes{d})
+	router.SetLandingSource(d.landingPucks)
+	if cfg.RouterEnabled {
+		router.SetWakeHandler(d.wakePuck)
+	}
+
+	return d, nil
 }
 
 // Run starts the daemon
@@ -91,18 +182,92 @@ func (d *Daemon) Run(ctx context.Context) error {
 	}
 	d.listener = ln
 
+	log.Info("puck daemon "+buildinfo.Version, "commit", buildinfo.ShortCommit(), "built", buildinfo.Date, "data_dir", d.cfg.DataDir, "features", strings.Join(d.enabledFeatures(), ","))
 	log.Info("Daemon listening", "socket", d.cfg.DaemonSocket)
 
-	// Start HTTP router
-	if err := d.router.Start(); err != nil {
+	// Before the router's nodes come up, so none is logged out while in use
+	if d.cfg.Tailnet != "" {
+		d.tidyTailscale(ctx)
+	}
+
+	// Start HTTP router. Disabled, it still keeps the route table, but
+	// Caddy never runs.
+	if !d.cfg.RouterEnabled {
+		log.Info("HTTP router disabled; pucks are reached on their host ports")
+	} else if err := d.router.Start(); err != nil {
 		log.Warn("Failed to start HTTP router", "error", err)
-		// Continue without router - it's not critical
+		// Continue without router - it can be retried with: puck router restart
+	} else {
+		log.Info("HTTP router started", "port", d.router.Status().Port, "domain", d.cfg.RouterDomain)
+		if d.cfg.RouterTLSPort > 0 {
+			log.Info("HTTPS listener enabled", "port", d.cfg.RouterTLSPort, "http3", d.cfg.RouterHTTP3)
+		}
+	}
+
+	// Pucks created before ownership was tracked belong to the daemon's user
+	if n, err := d.store.ClaimUnownedPucks(ctx, systemCaller.User); err != nil {
+		log.Warn("Failed to assign owner to existing pucks", "error", err)
+	} else if n > 0 {
+		log.Info("Assigned existing pucks to daemon user", "count", n, "owner", systemCaller.User)
+	}
+
+	// Without Podman, recovery waits for it to come up, and the router
+	// serves what the database holds meanwhile
+	if d.podmanUp == nil {
+		d.startWithPodman(ctx)
 	} else {
-		log.Info("HTTP router started", "port", d.cfg.RouterPort, "domain", d.cfg.RouterDomain)
+		if err := d.rebuildRouter(ctx); err != nil {
+			log.Warn("Failed to rebuild router", "error", err)
+		}
+		go d.awaitPodman(ctx)
+	}
+
+	// Catch synced mounts up with changes made while the daemon was down
+	d.startAllSyncs(ctx)
+
+	go d.pruneShares(ctx)
+	go d.syncRoutes(ctx)
+	if d.cfg.Agent {
+		go d.watchAgents(ctx)
+	}
+	if d.cfg.DetectAppPort {
+		go d.detectAppPorts(ctx)
+	}
+	if provider := shareTunnel(d.cfg); provider != nil && !d.cfg.RouterEnabled {
+		log.Warn("share_tunnel needs the router to serve share links; not opening a share tunnel")
+	} else if provider != nil {
+		go d.runTunnel(ctx, provider)
+	}
+	if d.cfg.SnapshotTierDir != "" {
+		go d.tierSnapshots(ctx)
+	}
+	go d.snapshotSchedules(ctx)
+	// Checkpointed pucks wake on requests through the router
+	if d.cfg.MemoryPressure > 0 && !d.cfg.RouterEnabled {
+		log.Warn("memory_pressure needs the router to wake pucks; not watching memory pressure")
+	} else if d.cfg.MemoryPressure > 0 {
+		go d.watchPressure(ctx)
+	}
+
+	if d.cfg.DaemonListen != "" {
+		if err := d.startRemote(ctx); err != nil {
+			log.Warn("Failed to start remote listener", "error", err)
+		}
 	}
 
-	// Sync existing pucks to router
-	d.syncRoutesToRouter(ctx)
+	if d.cfg.ServesTailnetAPI() {
+		go func() {
+			if err := d.startTailnet(ctx); err != nil {
+				log.Warn("Failed to start tailnet API", "error", err)
+			}
+		}()
+	}
+
+	if d.cfg.WebhookListen != "" {
+		if err := d.startWebhooks(ctx); err != nil {
+			log.Warn("Failed to start webhook listener", "error", err)
+		}
+	}
 
 	// Accept connections
 	for {
@@ -131,18 +296,53 @@ func (d *Daemon) Shutdown() {
 	defer d.mu.Unlock()
 
 	d.running = false
+	if d.remote != nil {
+		d.remote.Close()
+	}
+	if d.tailnet != nil {
+		d.tailnet.Close()
+	}
+	if d.tunnel != nil {
+		d.tunnel.Close()
+	}
+	if d.webhooks != nil {
+		d.webhooks.Close()
+	}
 	if d.router != nil {
 		d.router.Stop()
 	}
+	d.stopAllSyncs()
+	d.stopAllAgents()
+	if d.hooks != nil {
+		// Let in-flight hooks finish; each is bounded by its timeout
+		d.hooks.Wait()
+	}
 	if d.listener != nil {
 		d.listener.Close()
 	}
 	if d.store != nil {
 		d.store.Close()
 	}
+	if d.logFile != nil {
+		log.SetOutput(os.Stderr)
+		d.logFile.Close()
+	}
+}
+
+// rebuildRouter replaces everything the router serves with what the
+// database holds: puck routes with their settings and tailnet nodes,
+// share links and aliases. Nothing the router serves lives only in its
+// memory, so this recovers it after a crash or a router restart.
+func (d *Daemon) rebuildRouter(ctx context.Context) error {
+	return d.router.Rebuild(func() {
+		d.syncRoutesToRouter(ctx)
+		d.syncSharesToRouter(ctx)
+		d.syncAliasesToRouter(ctx)
+	})
 }
 
-// syncRoutesToRouter adds routes for all running pucks
+// syncRoutesToRouter adds routes for all running pucks, and sleeping
+// routes for pucks the daemon checkpointed
 func (d *Daemon) syncRoutesToRouter(ctx context.Context) {
 	pucks, err := d.manager.List(ctx)
 	if err != nil {
@@ -151,291 +351,2114 @@ func (d *Daemon) syncRoutesToRouter(ctx context.Context) {
 	}
 
 	for _, p := range pucks {
-		if p.Status == store.StatusRunning && p.HostPort > 0 {
-			// Route to localhost with the mapped host port
-			if err := d.router.AddRoute(p.Name, "127.0.0.1", p.HostPort); err != nil {
-				log.Warn("Failed to add route", "puck", p.Name, "error", err)
-			}
+		want, asleep := d.wantsRoute(ctx, p)
+		if !want {
+			continue
+		}
+		if err := d.addRoute(p); err != nil {
+			log.Warn("Failed to add route", "puck", p.Name, "error", err)
+			continue
+		}
+		if asleep {
+			d.sleepRoute(p.Name)
 		}
 	}
 }
 
-// Request represents a daemon request
-type Request struct {
-	Action string          `json:"action"`
-	Data   json.RawMessage `json:"data,omitempty"`
+// wantsRoute reports whether the router should serve a puck: running, or
+// checkpointed by the daemon to be woken by its next request, in which
+// case its route is asleep. Pucks waiting in a pool are never served.
+func (d *Daemon) wantsRoute(ctx context.Context, p *store.Puck) (want, asleep bool) {
+	if p.HostPort == 0 || p.Pool != "" {
+		return false, false
+	}
+	switch {
+	case p.Status == store.StatusRunning:
+		return true, false
+	case p.Status == store.StatusSuspended && d.autoCheckpointed(ctx, p.Name):
+		return true, true
+	}
+	return false, false
 }
 
-// Response represents a daemon response
-type Response struct {
-	Success bool            `json:"success"`
-	Data    json.RawMessage `json:"data,omitempty"`
-	Error   string          `json:"error,omitempty"`
-}
+// routeSyncInterval is how often the router's routes are brought in line
+// with the database
+const routeSyncInterval = time.Minute
 
-func (d *Daemon) handleConnection(ctx context.Context, conn net.Conn) {
-	defer conn.Close()
+// syncRoutes periodically reconciles the router's routes with the
+// database
+func (d *Daemon) syncRoutes(ctx context.Context) {
+	ticker := time.NewTicker(routeSyncInterval)
+	defer ticker.Stop()
 
-	decoder := json.NewDecoder(conn)
-	encoder := json.NewEncoder(conn)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.reconcileRoutes(ctx)
+		}
+	}
+}
 
-	var req Request
-	if err := decoder.Decode(&req); err != nil {
-		encoder.Encode(Response{Success: false, Error: err.Error()})
+// reconcileRoutes makes the router's routes match the database: pucks
+// that should be served and aren't, or are served at the wrong address,
+// are routed, and routes of pucks that are down are removed, along with
+// everything the router holds for pucks that no longer exist. Unlike
+// rebuildRouter it changes only what differs, so routes in use are left
+// alone. Pucks starting or being created are skipped, since their
+// routes are about to change anyway.
+func (d *Daemon) reconcileRoutes(ctx context.Context) {
+	pucks, err := d.manager.List(ctx)
+	if err != nil {
+		log.Warn("Failed to list pucks for router sync", "error", err)
 		return
 	}
+	routed := d.router.GetRoutes()
+	routes := puckRoutes{d}
 
-	resp := d.handleRequest(ctx, &req)
-	encoder.Encode(resp)
-}
+	byName := make(map[string]*store.Puck, len(pucks))
+	for _, p := range pucks {
+		byName[p.Name] = p
+	}
+	gone := make(map[string]bool)
+	for name := range routed {
+		gone[name] = byName[name] == nil
+	}
+	for _, name := range d.router.GetAliases() {
+		gone[name] = byName[name] == nil
+	}
+	for name, isGone := range gone {
+		if isGone {
+			log.Info("Removing routes of a puck that no longer exists", "name", name)
+			routes.Forget(ctx, name)
+		}
+	}
 
-func (d *Daemon) handleRequest(ctx context.Context, req *Request) Response {
-	switch req.Action {
-	case "create":
-		return d.handleCreate(ctx, req.Data)
-	case "list":
-		return d.handleList(ctx)
-	case "get":
-		return d.handleGet(ctx, req.Data)
-	case "start":
-		return d.handleStart(ctx, req.Data)
-	case "stop":
-		return d.handleStop(ctx, req.Data)
-	case "destroy":
-		return d.handleDestroy(ctx, req.Data)
-	case "destroy-all":
-		return d.handleDestroyAll(ctx, req.Data)
-	case "snapshot-create":
-		return d.handleSnapshotCreate(ctx, req.Data)
-	case "snapshot-restore":
-		return d.handleSnapshotRestore(ctx, req.Data)
-	case "snapshot-list":
-		return d.handleSnapshotList(ctx, req.Data)
-	case "snapshot-delete":
-		return d.handleSnapshotDelete(ctx, req.Data)
-	case "ping":
-		return Response{Success: true}
-	default:
-		return Response{Success: false, Error: fmt.Sprintf("unknown action: %s", req.Action)}
+	for _, p := range pucks {
+		if d.routeChange(ctx, p, routed) == routeKeep {
+			continue
+		}
+		// The list may be out of date by now, as when the puck was
+		// stopped meanwhile; act on the puck as it is
+		p, err := d.manager.Get(ctx, p.Name)
+		if err != nil {
+			continue
+		}
+		switch d.routeChange(ctx, p, routed) {
+		case routeAdd:
+			log.Info("Adding missing route", "name", p.Name)
+			_, asleep := d.wantsRoute(ctx, p)
+			if err := d.addRoute(p); err != nil {
+				log.Warn("Failed to add route", "name", p.Name, "error", err)
+				continue
+			}
+			if asleep {
+				d.sleepRoute(p.Name)
+			}
+		case routeUpdate:
+			log.Info("Updating stale route", "name", p.Name, "from", routed[p.Name], "to", d.routeTarget(p))
+			if err := d.addRoute(p); err != nil {
+				log.Warn("Failed to update route", "name", p.Name, "error", err)
+			}
+		case routeRemove:
+			log.Info("Removing route of a puck that is down", "name", p.Name, "status", p.Status)
+			routes.Unroute(ctx, p.Name)
+		}
 	}
 }
 
-func (d *Daemon) handleCreate(ctx context.Context, data json.RawMessage) Response {
-	var opts puck.CreateOptions
-	if err := json.Unmarshal(data, &opts); err != nil {
-		return Response{Success: false, Error: err.Error()}
-	}
+// What reconcileRoutes does about a puck's route
+const (
+	routeKeep = iota
+	routeAdd
+	routeUpdate
+	routeRemove
+)
 
-	p, err := d.manager.Create(ctx, opts)
-	if err != nil {
-		return Response{Success: false, Error: err.Error()}
+// routeChange works out what reconcileRoutes should do about a puck's
+// route, given the router's routes
+func (d *Daemon) routeChange(ctx context.Context, p *store.Puck, routed map[string]string) int {
+	if p.Status == store.StatusStarting || p.Status == store.StatusCreating {
+		return routeKeep
 	}
-
-	// Add route for the new puck using its host port
-	if p.HostPort > 0 {
-		if err := d.router.AddRoute(p.Name, "127.0.0.1", p.HostPort); err != nil {
-			log.Warn("Failed to add route for puck", "name", p.Name, "error", err)
-		}
+	target, isRouted := routed[p.Name]
+	want, _ := d.wantsRoute(ctx, p)
+	switch {
+	case want && !isRouted:
+		return routeAdd
+	case want && target != d.routeTarget(p):
+		return routeUpdate
+	case !want && isRouted:
+		return routeRemove
 	}
+	return routeKeep
+}
 
-	respData, _ := json.Marshal(p)
-	return Response{Success: true, Data: respData}
+// routeTarget is the address the router should send a puck's requests
+// to, as GetRoutes reports it
+func (d *Daemon) routeTarget(p *store.Puck) string {
+	ip, port := d.upstream(p)
+	return fmt.Sprintf("%s:%d", ip, port)
 }
 
-func (d *Daemon) handleList(ctx context.Context) Response {
+// awaitStarting routes pucks a previous daemon left starting once they
+// answer
+func (d *Daemon) awaitStarting(ctx context.Context) {
 	pucks, err := d.manager.List(ctx)
 	if err != nil {
-		return Response{Success: false, Error: err.Error()}
+		log.Warn("Failed to list starting pucks", "error", err)
+		return
+	}
+	for _, p := range pucks {
+		// The pool fill destroys pooled pucks left half made
+		if p.Status == store.StatusStarting && p.HostPort != 0 && p.Pool == "" {
+			go d.awaitReady(ctx, p)
+		}
 	}
-
-	respData, _ := json.Marshal(pucks)
-	return Response{Success: true, Data: respData}
 }
 
-func (d *Daemon) handleGet(ctx context.Context, data json.RawMessage) Response {
-	var params struct {
-		Name string `json:"name"`
+// recoverIntents finishes or undoes operations journaled by a previous
+// daemon that stopped partway through them
+func (d *Daemon) recoverIntents(ctx context.Context) {
+	recovered, err := d.manager.RecoverIntents(ctx)
+	if err != nil {
+		log.Warn("Failed to recover unfinished operations", "error", err)
 	}
-	if err := json.Unmarshal(data, &params); err != nil {
-		return Response{Success: false, Error: err.Error()}
+	for _, r := range recovered {
+		log.Info("Recovered unfinished operation", "op", r.Op, "name", r.Puck, "result", r.Result)
 	}
+}
 
-	p, err := d.manager.Get(ctx, params.Name)
+// cleanLeftovers removes or adopts containers and volume directories
+// that interrupted creates left behind outside the journal
+func (d *Daemon) cleanLeftovers(ctx context.Context) {
+	leftovers, err := d.manager.CleanLeftovers(ctx)
 	if err != nil {
-		return Response{Success: false, Error: err.Error()}
+		log.Warn("Failed to clean up after interrupted creates", "error", err)
+	}
+	for _, l := range leftovers {
+		if l.Result == "kept" {
+			log.Warn("Left a volume directory no puck has in place", "name", l.Puck, "path", l.Path, "detail", l.Detail)
+			continue
+		}
+		log.Info("Cleaned up after an interrupted create", "kind", l.Kind, "name", l.Puck, "id", l.ID, "path", l.Path, "result", l.Result, "detail", l.Detail)
 	}
-
-	respData, _ := json.Marshal(p)
-	return Response{Success: true, Data: respData}
 }
 
-func (d *Daemon) handleStart(ctx context.Context, data json.RawMessage) Response {
-	var params struct {
-		Name string `json:"name"`
+// reconcilePorts moves pucks off host ports that were taken while they
+// weren't running
+func (d *Daemon) reconcilePorts(ctx context.Context) {
+	changes, err := d.manager.ReconcilePorts(ctx)
+	if err != nil {
+		log.Warn("Failed to reconcile puck ports", "error", err)
 	}
-	if err := json.Unmarshal(data, &params); err != nil {
-		return Response{Success: false, Error: err.Error()}
+	for _, c := range changes {
+		log.Info("Moved puck to a free host port", "name", c.Puck, "from", c.From, "to", c.To)
+		d.fire(hooks.EventPuckPortChanged, c.Puck, "", c)
 	}
+}
 
-	if err := d.manager.Start(ctx, params.Name); err != nil {
-		return Response{Success: false, Error: err.Error()}
+// hostPort returns a puck's host port, or zero if it can't be looked up
+func (d *Daemon) hostPort(ctx context.Context, name string) int {
+	p, err := d.manager.Get(ctx, name)
+	if err != nil {
+		return 0
 	}
+	return p.HostPort
+}
 
-	// Add route for started puck using its host port
-	p, err := d.manager.Get(ctx, params.Name)
-	if err == nil && p.HostPort > 0 {
-		if err := d.router.AddRoute(p.Name, "127.0.0.1", p.HostPort); err != nil {
-			log.Warn("Failed to add route for puck", "name", p.Name, "error", err)
-		}
+// notePortChange reports a puck that an operation moved off the host port
+// it had before
+func (d *Daemon) notePortChange(before int, p *store.Puck) {
+	if p == nil || before == 0 || p.HostPort == before {
+		return
 	}
-
-	return Response{Success: true}
+	log.Info("Moved puck to a free host port", "name", p.Name, "from", before, "to", p.HostPort)
+	d.fire(hooks.EventPuckPortChanged, p.Name, "", puck.PortChange{Puck: p.Name, From: before, To: p.HostPort})
 }
 
-func (d *Daemon) handleStop(ctx context.Context, data json.RawMessage) Response {
-	var params struct {
-		Name string `json:"name"`
-	}
-	if err := json.Unmarshal(data, &params); err != nil {
-		return Response{Success: false, Error: err.Error()}
+// syncSharesToRouter serves every unexpired share link
+func (d *Daemon) syncSharesToRouter(ctx context.Context) {
+	shares, err := d.manager.ActiveShares(ctx)
+	if err != nil {
+		log.Warn("Failed to load share links", "error", err)
+		return
 	}
 
-	if err := d.manager.Stop(ctx, params.Name); err != nil {
-		return Response{Success: false, Error: err.Error()}
+	for _, s := range shares {
+		if err := d.router.AddShare(s.Token, s.PuckName, s.ExpiresAt); err != nil {
+			log.Warn("Failed to add share link", "puck", s.PuckName, "id", s.ID, "error", err)
+		}
 	}
+}
 
-	// Remove route for stopped puck
-	if err := d.router.RemoveRoute(params.Name); err != nil {
-		log.Warn("Failed to remove route for puck", "name", params.Name, "error", err)
+// syncAliasesToRouter serves every route alias
+func (d *Daemon) syncAliasesToRouter(ctx context.Context) {
+	aliases, err := d.manager.ListRouteAliases(ctx, "")
+	if err != nil {
+		log.Warn("Failed to load route aliases", "error", err)
+		return
 	}
 
-	return Response{Success: true}
+	for _, a := range aliases {
+		if err := d.router.SetAlias(a.Path, a.PuckName); err != nil {
+			log.Warn("Failed to add route alias", "puck", a.PuckName, "path", a.Path, "error", err)
+		}
+	}
 }
 
-func (d *Daemon) handleDestroy(ctx context.Context, data json.RawMessage) Response {
-	var params struct {
-		Name  string `json:"name"`
-		Force bool   `json:"force"`
-	}
-	if err := json.Unmarshal(data, &params); err != nil {
-		return Response{Success: false, Error: err.Error()}
-	}
+// shareSweepInterval is how often expired share links are removed
+const shareSweepInterval = time.Minute
 
-	if err := d.manager.Destroy(ctx, params.Name, params.Force); err != nil {
-		return Response{Success: false, Error: err.Error()}
-	}
+// pruneShares periodically removes expired share links. The router already
+// refuses them once expired; this keeps the store and config tidy.
+func (d *Daemon) pruneShares(ctx context.Context) {
+	ticker := time.NewTicker(shareSweepInterval)
+	defer ticker.Stop()
 
-	// Remove route for destroyed puck
-	if err := d.router.RemoveRoute(params.Name); err != nil {
-		log.Warn("Failed to remove route for puck", "name", params.Name, "error", err)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			expired, err := d.manager.PruneShares(ctx)
+			if err != nil {
+				log.Warn("Failed to prune share links", "error", err)
+			}
+			if len(expired) > 0 {
+				log.Info("Share links expired", "count", len(expired))
+			}
+			if err := d.router.PruneShares(time.Now()); err != nil {
+				log.Warn("Failed to remove expired share links from router", "error", err)
+			}
+		}
 	}
-
-	return Response{Success: true}
 }
 
-func (d *Daemon) handleDestroyAll(ctx context.Context, data json.RawMessage) Response {
-	var params struct {
-		Force bool `json:"force"`
-	}
-	if err := json.Unmarshal(data, &params); err != nil {
-		return Response{Success: false, Error: err.Error()}
-	}
+// snapshotTierInterval is how often old snapshots are moved to cold
+// storage
+const snapshotTierInterval = time.Hour
 
-	destroyed, err := d.manager.DestroyAll(ctx, params.Force)
+// tierSnapshots moves old snapshots to cold storage now and then
+// periodically, as set by snapshot_tier_dir and snapshot_tier_after
+func (d *Daemon) tierSnapshots(ctx context.Context) {
+	ticker := time.NewTicker(snapshotTierInterval)
+	defer ticker.Stop()
 
-	// Remove routes for all destroyed pucks
-	for _, name := range destroyed {
-		if err := d.router.RemoveRoute(name); err != nil {
-			log.Warn("Failed to remove route for puck", "name", name, "error", err)
+	for {
+		// Nothing moves while in maintenance
+		if !d.inMaintenance() {
+			report, err := d.manager.TierSnapshots(ctx)
+			if err != nil {
+				log.Warn("Failed to move snapshots to cold storage", "error", err)
+			} else {
+				for _, msg := range report.Errors {
+					log.Warn("Failed to move snapshot to cold storage", "error", msg)
+				}
+				if len(report.Moved) > 0 {
+					log.Info("Moved snapshots to cold storage", "count", len(report.Moved), "dir", d.cfg.SnapshotTierDir)
+				}
+			}
 		}
-	}
 
-	if err != nil {
-		// Return partial success with list of destroyed pucks
-		respData, _ := json.Marshal(destroyed)
-		return Response{Success: false, Error: err.Error(), Data: respData}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 	}
+}
 
-	respData, _ := json.Marshal(destroyed)
-	return Response{Success: true, Data: respData}
+// snapshotScheduleInterval is how often pucks' snapshot schedules are
+// checked for snapshots that are due
+const snapshotScheduleInterval = time.Minute
+
+// snapshotSchedules periodically snapshots the pucks whose snapshot
+// policies have schedules, as set with puck snapshot config
+func (d *Daemon) snapshotSchedules(ctx context.Context) {
+	ticker := time.NewTicker(snapshotScheduleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if d.inMaintenance() {
+				continue
+			}
+			for _, r := range d.manager.RunSnapshotSchedules(ctx) {
+				if r.Error != "" {
+					log.Warn("Scheduled snapshot failed", "name", r.Puck, "error", r.Error)
+					continue
+				}
+				log.Info("Took scheduled snapshot", "name", r.Puck, "snapshot", r.Snapshot.Name)
+				d.fire(hooks.EventSnapshotCreated, r.Puck, r.Snapshot.Name, r.Snapshot)
+			}
+		}
+	}
 }
 
-func (d *Daemon) handleSnapshotCreate(ctx context.Context, data json.RawMessage) Response {
-	var opts puck.SnapshotCreateOptions
-	if err := json.Unmarshal(data, &opts); err != nil {
-		return Response{Success: false, Error: err.Error()}
+// shareURL fills in the public URL of a share link
+func (d *Daemon) shareURL(s *store.Share) {
+	if base := d.shareBaseURL(); base != "" {
+		s.URL = base + network.SharePath(s.Token)
 	}
+}
 
-	snapshot, err := d.manager.CreateSnapshot(ctx, opts)
+// startRemote accepts daemon requests over TCP from clients presenting a
+// certificate signed by the configured client CA
+func (d *Daemon) startRemote(ctx context.Context) error {
+	tlsCfg, err := serverTLSConfig(d.cfg)
 	if err != nil {
-		return Response{Success: false, Error: err.Error()}
+		return err
 	}
+	ln, err := tls.Listen("tcp", d.cfg.DaemonListen, tlsCfg)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", d.cfg.DaemonListen, err)
+	}
+	d.remote = ln
 
-	// Remove route if not leaving running (puck is checkpointed)
-	if !opts.LeaveRunning {
-		if err := d.router.RemoveRoute(opts.PuckName); err != nil {
-			log.Warn("Failed to remove route for checkpointed puck", "name", opts.PuckName, "error", err)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				select {
+				case <-ctx.Done():
+					return
+				default:
+				}
+				if errors.Is(err, net.ErrClosed) {
+					return
+				}
+				log.Error("Remote accept error", "error", err)
+				continue
+			}
+			go d.handleConnection(ctx, conn)
 		}
-	}
+	}()
 
-	respData, _ := json.Marshal(snapshot)
-	return Response{Success: true, Data: respData}
+	log.Info("Remote listener started", "addr", ln.Addr().String())
+	return nil
 }
 
-func (d *Daemon) handleSnapshotRestore(ctx context.Context, data json.RawMessage) Response {
-	var opts puck.SnapshotRestoreOptions
-	if err := json.Unmarshal(data, &opts); err != nil {
-		return Response{Success: false, Error: err.Error()}
+// startWebhooks serves the inbound webhook endpoint on cfg.WebhookListen
+func (d *Daemon) startWebhooks(ctx context.Context) error {
+	ln, err := net.Listen("tcp", d.cfg.WebhookListen)
+	if err != nil {
+		return fmt.Errorf("listening on %s: %w", d.cfg.WebhookListen, err)
 	}
 
-	if err := d.manager.RestoreSnapshot(ctx, opts); err != nil {
-		return Response{Success: false, Error: err.Error()}
+	d.webhooks = &http.Server{
+		Handler:           newWebhookServer(d.cfg.WebhookSecret, d.cfg.Webhooks, d.handleRequest),
+		ReadHeaderTimeout: 10 * time.Second,
 	}
-
-	// Re-add route for restored puck
-	p, err := d.manager.Get(ctx, opts.PuckName)
-	if err == nil && p.HostPort > 0 {
-		if err := d.router.AddRoute(p.Name, "127.0.0.1", p.HostPort); err != nil {
-			log.Warn("Failed to add route for restored puck", "name", p.Name, "error", err)
+	go func() {
+		if err := d.webhooks.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Error("Webhook listener error", "error", err)
 		}
-	}
+	}()
 
-	return Response{Success: true}
+	log.Info("Webhook listener started", "addr", ln.Addr().String(), "webhooks", len(d.cfg.Webhooks))
+	return nil
 }
 
-func (d *Daemon) handleSnapshotList(ctx context.Context, data json.RawMessage) Response {
-	var params struct {
-		PuckName string `json:"puck_name"`
+// fire notifies hooks of an event. snapshot names the snapshot taken or
+// restored, if the event is about one.
+func (d *Daemon) fire(eventType, puckName, snapshot string, data interface{}) {
+	d.hooks.Fire(hooks.Event{Type: eventType, Puck: puckName, Snapshot: snapshot, Data: data})
+}
+
+// upstream returns where the router reaches a puck: its container IP when
+// routing to containers directly, at the port its app was found listening
+// on or else 80, otherwise its published host port
+func (d *Daemon) upstream(p *store.Puck) (string, int) {
+	if d.cfg.RoutesToContainerIP() && p.ContainerIP != "" {
+		return p.ContainerIP, cmp.Or(d.appPort(p.Name), 80)
 	}
-	if err := json.Unmarshal(data, &params); err != nil {
-		return Response{Success: false, Error: err.Error()}
+	return "127.0.0.1", p.HostPort
+}
+
+// addRoute routes a puck through the router via its upstream,
+// including its own tailnet node if it is shared there
+func (d *Daemon) addRoute(p *store.Puck) error {
+	ip, port := d.upstream(p)
+	if err := d.router.AddRoute(p.Name, ip, port, p.Route); err != nil {
+		return err
+	}
+	if err := d.routeEndpoints(context.Background(), p); err != nil {
+		return err
+	}
+	if p.Tailnet != nil && d.cfg.Tailnet != "" {
+		return d.router.ShareOnTailnet(p.Name, p.Tailnet.Tags)
+	}
+	return nil
+}
+
+// routeEndpoints serves a puck's endpoints under its path, each through
+// its own upstream
+func (d *Daemon) routeEndpoints(ctx context.Context, p *store.Puck) error {
+	endpoints, err := d.manager.Endpoints(ctx, p.Name)
+	if err != nil {
+		return err
+	}
+
+	targets := make([]network.Endpoint, 0, len(endpoints))
+	for _, e := range endpoints {
+		target := network.Endpoint{Name: e.Name, IP: "127.0.0.1", Port: e.HostPort}
+		if d.cfg.RoutesToContainerIP() && p.ContainerIP != "" {
+			target.IP, target.Port = p.ContainerIP, e.ContainerPort
+		}
+		if target.Port > 0 {
+			targets = append(targets, target)
+		}
+	}
+	return d.router.SetEndpoints(p.Name, targets)
+}
+
+// landingPucks lists pucks for the router landing page from live daemon state
+func (d *Daemon) landingPucks(ctx context.Context) ([]network.LandingPuck, error) {
+	pucks, err := d.manager.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]network.LandingPuck, 0, len(pucks))
+	for _, p := range pucks {
+		if p.Pool != "" {
+			continue
+		}
+		lp := network.LandingPuck{
+			Name:   p.Name,
+			Status: string(p.Status),
+			Image:  p.Image,
+		}
+		if p.Status == store.StatusRunning {
+			if data, err := d.podman.InspectContainer(ctx, p.ContainerID); err == nil && data.State != nil {
+				lp.StartedAt = data.State.StartedAt
+			}
+		}
+		result = append(result, lp)
+	}
+	return result, nil
+}
+
+// Request represents a daemon request
+type Request struct {
+	Action string          `json:"action"`
+	Data   json.RawMessage `json:"data,omitempty"`
+	Stream bool            `json:"stream,omitempty"` // send progress ahead of the response
+}
+
+// Response represents a daemon response. Streaming requests may get
+// progress responses, carrying only Pull or Snapshot, before the final
+// one.
+type Response struct {
+	Success  bool                   `json:"success"`
+	Data     json.RawMessage        `json:"data,omitempty"`
+	Error    string                 `json:"error,omitempty"`
+	Pull     *podman.PullProgress   `json:"pull,omitempty"`
+	Snapshot *puck.SnapshotProgress `json:"snapshot,omitempty"`
+
+	// Code says what kind of failure Error is, when it is one clients
+	// handle specially, e.g. CodeNotFound
+	Code string `json:"code,omitempty"`
+	// Hints are what the caller can do about Error, such as a free name
+	// to use instead of a taken one
+	Hints []string `json:"hints,omitempty"`
+}
+
+func (d *Daemon) handleConnection(ctx context.Context, conn net.Conn) {
+	defer conn.Close()
+
+	decoder := json.NewDecoder(&limitedReader{r: conn, n: maxRequestSize})
+	encoder := json.NewEncoder(conn)
+
+	// A client that never finishes its request can't hold the connection
+	conn.SetReadDeadline(time.Now().Add(requestReadTimeout))
+	var req Request
+	if err := decoder.Decode(&req); err != nil {
+		if errors.Is(err, errTooLarge) {
+			err = fmt.Errorf("request exceeds %d bytes", maxRequestSize)
+		}
+		conn.SetWriteDeadline(time.Now().Add(responseWriteTimeout))
+		encoder.Encode(errorResponse(err))
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	// Cancel the request if the client hangs up. Clients send nothing more
+	// after their request, so any read returning means they are gone,
+	// except in exec streams, which carry stdin.
+	ctx, cancel := context.WithCancel(withCaller(ctx, d.callerForConn(conn)))
+	defer cancel()
+	if req.Action == "exec-stream" {
+		d.handleExecStream(ctx, cancel, conn, newFrameReader(decoder, conn), &req)
+		return
+	}
+	go func() {
+		io.Copy(io.Discard, conn)
+		cancel()
+	}()
+
+	// Progress stops once the response is sent, even if a timed-out
+	// handler is still going
+	var mu sync.Mutex
+	answered := false
+	progress := func(resp Response) {
+		mu.Lock()
+		defer mu.Unlock()
+		if answered {
+			return
+		}
+		conn.SetWriteDeadline(time.Now().Add(responseWriteTimeout))
+		encoder.Encode(resp)
+	}
+	if req.Stream {
+		ctx = podman.WithPullProgress(ctx, func(p podman.PullProgress) {
+			progress(Response{Pull: &p})
+		})
+		ctx = puck.WithSnapshotProgress(ctx, func(p puck.SnapshotProgress) {
+			progress(Response{Snapshot: &p})
+		})
+	}
+
+	resp := d.runAction(ctx, &req)
+	mu.Lock()
+	defer mu.Unlock()
+	answered = true
+	conn.SetWriteDeadline(time.Now().Add(responseWriteTimeout))
+	encoder.Encode(resp)
+}
+
+func (d *Daemon) handleRequest(ctx context.Context, req *Request) Response {
+	if err := d.authorize(ctx, req); err != nil {
+		return errorResponse(err)
+	}
+	if err := d.checkMaintenance(req); err != nil {
+		return errorResponse(err)
+	}
+	if !podmanFreeActions[req.Action] {
+		if err := d.waitPodman(ctx); err != nil {
+			return errorResponse(err)
+		}
+	}
+
+	switch req.Action {
+	case "create":
+		return d.handleCreate(ctx, req.Data)
+	case "list":
+		return d.handleList(ctx, req.Data)
+	case "get":
+		return d.handleGet(ctx, req.Data)
+	case "watch":
+		return d.handleWatch(ctx, req.Data)
+	case "history":
+		return d.handleHistory(ctx, req.Data)
+	case "exec":
+		return d.handleExec(ctx, req.Data)
+	case "logs":
+		return d.handleLogs(ctx, req.Data)
+	case "fs-list":
+		return d.handleFSList(ctx, req.Data)
+	case "fs-stat":
+		return d.handleFSStat(ctx, req.Data)
+	case "fs-read":
+		return d.handleFSRead(ctx, req.Data)
+	case "start":
+		return d.handleStart(ctx, req.Data)
+	case "stop":
+		return d.handleStop(ctx, req.Data)
+	case "kill":
+		return d.handleKill(ctx, req.Data)
+	case "recreate":
+		return d.handleRecreate(ctx, req.Data)
+	case "rollback":
+		return d.handleRollback(ctx, req.Data)
+	case "destroy":
+		return d.handleDestroy(ctx, req.Data)
+	case "destroy-all":
+		return d.handleDestroyAll(ctx, req.Data)
+	case "snapshot-create":
+		return d.handleSnapshotCreate(ctx, req.Data)
+	case "snapshot-all":
+		return d.handleSnapshotAll(ctx, req.Data)
+	case "snapshot-restore":
+		return d.handleSnapshotRestore(ctx, req.Data)
+	case "snapshot-stack-create":
+		return d.handleSnapshotStackCreate(ctx, req.Data)
+	case "snapshot-stack-restore":
+		return d.handleSnapshotStackRestore(ctx, req.Data)
+	case "snapshot-stack-list":
+		return d.handleSnapshotStackList(ctx, req.Data)
+	case "snapshot-list":
+		return d.handleSnapshotList(ctx, req.Data)
+	case "snapshot-inspect":
+		return d.handleSnapshotInspect(ctx, req.Data)
+	case "snapshot-diff":
+		return d.handleSnapshotDiff(ctx, req.Data)
+	case "snapshot-delete":
+		return d.handleSnapshotDelete(ctx, req.Data)
+	case "snapshot-tag":
+		return d.handleSnapshotTag(ctx, req.Data)
+	case "route-set":
+		return d.handleRouteSet(ctx, req.Data)
+	case "set-resources":
+		return d.handleSetResources(ctx, req.Data)
+	case "set-host-port":
+		return d.handleSetHostPort(ctx, req.Data)
+	case "set-notes":
+		return d.handleSetNotes(ctx, req.Data)
+	case "set-ttl":
+		return d.handleSetTTL(ctx, req.Data)
+	case "egress-set":
+		return d.handleEgressSet(ctx, req.Data)
+	case "env-set":
+		return d.handleEnvSet(ctx, req.Data)
+	case "snapshot-policy-set":
+		return d.handleSnapshotPolicySet(ctx, req.Data)
+	case "project-status":
+		return d.handleProjectStatus(ctx)
+	case "sync-status":
+		return d.handleSyncStatus(ctx, req.Data)
+	case "sync-flush":
+		return d.handleSyncFlush(ctx, req.Data)
+	case "tailnet-share":
+		return d.handleTailnetShare(ctx, req.Data)
+	case "tailnet-status":
+		return d.handleTailnetStatus(ctx)
+	case "tailnet-unshare":
+		return d.handleTailnetUnshare(ctx, req.Data)
+	case "share-create":
+		return d.handleShareCreate(ctx, req.Data)
+	case "share-list":
+		return d.handleShareList(ctx, req.Data)
+	case "share-revoke":
+		return d.handleShareRevoke(ctx, req.Data)
+	case "alias-set":
+		return d.handleAliasSet(ctx, req.Data)
+	case "alias-list":
+		return d.handleAliasList(ctx, req.Data)
+	case "pool-create":
+		return d.handlePoolCreate(ctx, req.Data)
+	case "pool-list":
+		return d.handlePoolList(ctx)
+	case "pool-remove":
+		return d.handlePoolRemove(ctx, req.Data)
+	case "group-add":
+		return d.handleGroupAdd(ctx, req.Data)
+	case "group-remove":
+		return d.handleGroupRemove(ctx, req.Data)
+	case "group-get":
+		return d.handleGroupGet(ctx, req.Data)
+	case "group-list":
+		return d.handleGroupList(ctx)
+	case "endpoint-add":
+		return d.handleEndpointAdd(ctx, req.Data)
+	case "endpoint-list":
+		return d.handleEndpointList(ctx, req.Data)
+	case "endpoint-remove":
+		return d.handleEndpointRemove(ctx, req.Data)
+	case "alias-remove":
+		return d.handleAliasRemove(ctx, req.Data)
+	case "promote":
+		return d.handlePromote(ctx, req.Data)
+	case "gc":
+		return d.handleGC(ctx, req.Data)
+	case "images":
+		return d.handleImages(ctx)
+	case "image-tree":
+		return d.handleImageTree(ctx)
+	case "scan":
+		return d.handleScan(ctx, req.Data)
+	case "report":
+		return d.handleReport(ctx, req.Data)
+	case "events-export":
+		return d.handleEventsExport(ctx, req.Data)
+	case "stats-export":
+		return d.handleStatsExport(ctx, req.Data)
+	case "db-check":
+		return d.handleDBCheck(ctx, req.Data)
+	case "snapshot-tier-status":
+		return d.handleSnapshotTierStatus(ctx)
+	case "snapshot-tier-run":
+		return d.handleSnapshotTierRun(ctx)
+	case "machine-resources":
+		return d.handleMachineResources(ctx)
+	case "machine-set-resources":
+		return d.handleMachineSetResources(ctx, req.Data)
+	case "routes":
+		return d.handleRoutes(ctx)
+	case "router-status":
+		return d.handleRouterStatus()
+	case "router-restart":
+		return d.handleRouterRestart(ctx)
+	case "hooks":
+		return d.handleHooks()
+	case "maintenance":
+		return d.handleMaintenance(ctx, req.Data)
+	case "info":
+		return d.handleInfo(ctx)
+	case "ping":
+		return Response{Success: true}
+	case "podman-status":
+		return d.handlePodmanStatus()
+	default:
+		return Response{Success: false, Error: fmt.Sprintf("unknown action: %s", req.Action)}
+	}
+}
+
+func (d *Daemon) handleCreate(ctx context.Context, data json.RawMessage) Response {
+	var opts puck.CreateOptions
+	if err := json.Unmarshal(data, &opts); err != nil {
+		return errorResponse(err)
+	}
+	if opts.FromPool != "" {
+		return d.createFromPool(ctx, opts)
+	}
+	c := callerFrom(ctx)
+	opts.Owner = c.User
+
+	// Starting the new puck starts its requirements too
+	for _, req := range opts.Requires {
+		if err := d.authorizePuck(ctx, c, req); err != nil {
+			return errorResponse(err)
+		}
+	}
+	if err := d.startRequirements(ctx, opts.Requires); err != nil {
+		return errorResponse(err)
+	}
+
+	// The manager keeps a puck being replaced until the new one is made,
+	// putting it back if the create fails
+	var replaced *store.Puck
+	if opts.Replace {
+		if old, err := d.manager.Get(ctx, opts.Name); err == nil {
+			replaced = old
+			d.stopSyncs(old.Name)
+		}
+	}
+
+	p, err := d.manager.Create(ctx, opts)
+	if err != nil {
+		if replaced != nil {
+			d.startSyncs(replaced)
+		}
+		return errorResponse(err)
+	}
+	if replaced != nil {
+		d.stopAgent(replaced.Name)
+		d.fire(hooks.EventPuckDestroyed, replaced.Name, "", nil)
+	}
+	for _, w := range p.Warnings {
+		log.Warn("Created puck with a warning", "name", p.Name, "warning", w)
+	}
+	d.startSyncs(p)
+
+	// Route the new puck once its app answers, rather than serving 502s
+	// while it boots
+	if p.HostPort > 0 {
+		// Outlives the request, which is canceled once answered
+		go d.awaitReady(context.WithoutCancel(ctx), p)
+	} else if err := d.manager.MarkReady(ctx, p.Name); err != nil {
+		log.Warn("Failed to mark puck ready", "name", p.Name, "error", err)
+	}
+	d.fire(hooks.EventPuckCreated, p.Name, "", p)
+
+	respData, _ := json.Marshal(p)
+	if opts.Repo != nil {
+		if err := d.manager.CloneRepo(ctx, p.Name, *opts.Repo); err != nil {
+			return Response{Success: false, Error: fmt.Sprintf("puck '%s' was created, but %v", p.Name, err), Data: respData}
+		}
+	}
+	if len(opts.Provision) > 0 {
+		if err := d.manager.Provision(ctx, p.Name, opts.Provision); err != nil {
+			return Response{Success: false, Error: fmt.Sprintf("puck '%s' was created, but %v", p.Name, err), Data: respData}
+		}
+	}
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleList(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		AllUsers bool `json:"all_users"`
+		Usage    bool `json:"usage"`
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &params); err != nil {
+			return errorResponse(err)
+		}
+	}
+
+	c := callerFrom(ctx)
+	if params.AllUsers && !c.Admin {
+		return Response{Success: false, Error: "permission denied: --all-users requires an admin"}
+	}
+
+	pucks, err := d.manager.List(ctx)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	// Everyone, admins included, sees only their own pucks by default.
+	// Pucks waiting in pools are no one's yet; puck pool list counts them.
+	if !params.AllUsers {
+		pucks = filterOwned(pucks, caller{User: c.User})
+	}
+	pucks = slices.DeleteFunc(pucks, func(p *store.Puck) bool { return p.Pool != "" })
+	for _, p := range pucks {
+		p.Health = d.manager.Health(ctx, p)
+		d.fillAppPort(p)
+	}
+	if params.Usage {
+		if err := d.manager.FillUsage(ctx, pucks); err != nil {
+			log.Debug("Failed to read puck resource usage", "error", err)
+		}
+	}
+
+	respData, _ := json.Marshal(pucks)
+	return Response{Success: true, Data: respData}
+}
+
+// maxWatchWait bounds how long a watch request is held open, well within
+// its action timeout
+const maxWatchWait = time.Minute
+
+// handleWatch answers once the database changes after the generation the
+// client last saw, or after the client's timeout with nothing changed.
+// Clients then list again; the generation alone says nothing about whose
+// pucks changed.
+func (d *Daemon) handleWatch(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		Since   uint64 `json:"since"`
+		Timeout int    `json:"timeout"` // seconds
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &params); err != nil {
+			return errorResponse(err)
+		}
+	}
+
+	wait := time.Duration(params.Timeout) * time.Second
+	if wait <= 0 || wait > maxWatchWait {
+		wait = maxWatchWait
+	}
+	ctx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+
+	respData, _ := json.Marshal(map[string]uint64{"generation": d.store.WaitChange(ctx, params.Since)})
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleGet(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+
+	p, err := d.manager.Get(ctx, params.Name)
+	if err != nil {
+		return errorResponse(err)
+	}
+	p.Health = d.manager.Health(ctx, p)
+	d.fillAppPort(p)
+
+	respData, _ := json.Marshal(p)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleHistory(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		Name  string    `json:"name"`
+		Since time.Time `json:"since"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+
+	events, err := d.manager.History(ctx, params.Name, params.Since)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	respData, _ := json.Marshal(events)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleExec(ctx context.Context, data json.RawMessage) Response {
+	var opts puck.ExecOptions
+	if err := json.Unmarshal(data, &opts); err != nil {
+		return errorResponse(err)
+	}
+
+	result, err := d.manager.Exec(ctx, opts)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	respData, _ := json.Marshal(result)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleLogs(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		Name string `json:"name"`
+		Tail int    `json:"tail"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+
+	logs, err := d.manager.Logs(ctx, params.Name, params.Tail)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	respData, _ := json.Marshal(logs)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleFSList(ctx context.Context, data json.RawMessage) Response {
+	var opts puck.FSOptions
+	if err := json.Unmarshal(data, &opts); err != nil {
+		return errorResponse(err)
+	}
+
+	files, err := d.manager.ListFiles(ctx, opts)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	respData, _ := json.Marshal(files)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleFSStat(ctx context.Context, data json.RawMessage) Response {
+	var opts puck.FSOptions
+	if err := json.Unmarshal(data, &opts); err != nil {
+		return errorResponse(err)
+	}
+
+	info, err := d.manager.StatFile(ctx, opts)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	respData, _ := json.Marshal(info)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleFSRead(ctx context.Context, data json.RawMessage) Response {
+	var opts puck.FSOptions
+	if err := json.Unmarshal(data, &opts); err != nil {
+		return errorResponse(err)
+	}
+
+	content, err := d.manager.ReadFile(ctx, opts)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	respData, _ := json.Marshal(content)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleStart(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+
+	p, err := d.manager.Get(ctx, params.Name)
+	if err != nil {
+		return errorResponse(err)
+	}
+	if err := d.startRequirements(ctx, p.Requires); err != nil {
+		return errorResponse(err)
+	}
+	if err := d.startPuck(ctx, params.Name); err != nil {
+		return errorResponse(err)
+	}
+	d.sleepCheckpointed(ctx)
+
+	return Response{Success: true}
+}
+
+// startPuck starts a puck, which the manager routes
+func (d *Daemon) startPuck(ctx context.Context, name string) error {
+	before := d.hostPort(ctx, name)
+	if err := d.manager.Start(ctx, name); err != nil {
+		return err
+	}
+
+	p, err := d.manager.Get(ctx, name)
+	if err == nil {
+		d.notePortChange(before, p)
+	}
+	d.fire(hooks.EventPuckStarted, name, "", p)
+	return nil
+}
+
+// startRequirements starts the pucks in requires that aren't up, and
+// what they require in turn, in dependency order
+func (d *Daemon) startRequirements(ctx context.Context, requires []string) error {
+	order, err := d.manager.RequirementsToStart(ctx, requires)
+	if err != nil {
+		return err
+	}
+	for _, name := range order {
+		log.Info("Starting required puck", "name", name)
+		if err := d.startPuck(ctx, name); err != nil {
+			return fmt.Errorf("starting required puck '%s': %w", name, err)
+		}
+	}
+	return nil
+}
+
+// stopDependents stops the running pucks that require name, and their
+// dependents in turn, before name itself is stopped
+func (d *Daemon) stopDependents(ctx context.Context, name string, timeout *int) error {
+	order, err := d.manager.DependentsToStop(ctx, name)
+	if err != nil {
+		return err
+	}
+	for _, dep := range order {
+		log.Info("Stopping dependent puck", "name", dep, "requires", name)
+		if err := d.manager.StopWithOptions(ctx, puck.StopOptions{Name: dep, Timeout: timeout}); err != nil {
+			return fmt.Errorf("stopping dependent puck '%s': %w", dep, err)
+		}
+		d.fire(hooks.EventPuckStopped, dep, "", nil)
+	}
+	return nil
+}
+
+func (d *Daemon) handleStop(ctx context.Context, data json.RawMessage) Response {
+	var params puck.StopOptions
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+
+	if err := d.stopDependents(ctx, params.Name, params.Timeout); err != nil {
+		return errorResponse(err)
+	}
+	if err := d.manager.StopWithOptions(ctx, params); err != nil {
+		return errorResponse(err)
+	}
+	d.fire(hooks.EventPuckStopped, params.Name, "", nil)
+
+	return Response{Success: true}
+}
+
+func (d *Daemon) handleKill(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		Name   string `json:"name"`
+		Signal string `json:"signal,omitempty"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+
+	if err := d.manager.Kill(ctx, params.Name, params.Signal); err != nil {
+		return errorResponse(err)
+	}
+
+	// Signals other than SIGKILL leave the puck running
+	if p, err := d.manager.Get(ctx, params.Name); err == nil && p.Status == store.StatusStopped {
+		d.fire(hooks.EventPuckStopped, params.Name, "", nil)
+	}
+
+	return Response{Success: true}
+}
+
+func (d *Daemon) handleRecreate(ctx context.Context, data json.RawMessage) Response {
+	var opts puck.RecreateOptions
+	if err := json.Unmarshal(data, &opts); err != nil {
+		return errorResponse(err)
+	}
+
+	p, err := d.manager.Recreate(ctx, opts)
+	if err != nil {
+		return errorResponse(err)
+	}
+	d.fire(hooks.EventPuckRecreated, p.Name, "", p)
+
+	respData, _ := json.Marshal(p)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleRollback(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		Name string     `json:"name"`
+		At   *time.Time `json:"at,omitempty"` // roll back to a time rather than the last recreate
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+
+	before := d.hostPort(ctx, params.Name)
+	var snapshot *store.Snapshot
+	var err error
+	if params.At != nil {
+		snapshot, err = d.manager.RollbackTo(ctx, params.Name, *params.At)
+	} else {
+		snapshot, err = d.manager.Rollback(ctx, params.Name)
+	}
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	if p, err := d.manager.Get(ctx, params.Name); err == nil {
+		d.notePortChange(before, p)
+	}
+	d.fire(hooks.EventSnapshotRestored, params.Name, snapshot.Name, nil)
+
+	respData, _ := json.Marshal(snapshot)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleDestroy(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		Name  string `json:"name"`
+		Force bool   `json:"force"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+
+	// Stop syncing first, so no pass writes into the volume as it goes
+	d.stopSyncs(params.Name)
+	if err := d.manager.Destroy(ctx, params.Name, params.Force); err != nil {
+		if p, getErr := d.manager.Get(ctx, params.Name); getErr == nil {
+			d.startSyncs(p)
+		}
+		return errorResponse(err)
+	}
+	d.stopAgent(params.Name)
+	d.fire(hooks.EventPuckDestroyed, params.Name, "", nil)
+
+	return Response{Success: true}
+}
+
+func (d *Daemon) handleDestroyAll(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		Force bool `json:"force"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+
+	// Only the caller's own pucks, even for admins
+	owner := callerFrom(ctx).User
+	pucks, err := d.manager.List(ctx)
+	if err != nil {
+		return errorResponse(err)
+	}
+	// Stop syncing first, so no pass writes into a volume as it goes
+	for _, p := range pucks {
+		if p.Owner == owner {
+			d.stopSyncs(p.Name)
+		}
+	}
+	// Pucks that are still here, destroyed or not, sync again
+	restartSyncs := func(name string) {
+		if p, err := d.manager.Get(ctx, name); err == nil {
+			d.startSyncs(p)
+		}
+	}
+
+	results, err := d.manager.DestroyAllOwnedBy(ctx, owner, params.Force)
+	if err != nil {
+		for _, p := range pucks {
+			if p.Owner == owner {
+				restartSyncs(p.Name)
+			}
+		}
+		return errorResponse(err)
+	}
+
+	// The manager unrouted the destroyed pucks
+	for _, r := range results {
+		if r.Error != "" {
+			restartSyncs(r.Puck)
+			continue
+		}
+		d.stopAgent(r.Puck)
+		d.fire(hooks.EventPuckDestroyed, r.Puck, "", nil)
+	}
+
+	respData, _ := json.Marshal(results)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleSnapshotCreate(ctx context.Context, data json.RawMessage) Response {
+	var opts puck.SnapshotCreateOptions
+	if err := json.Unmarshal(data, &opts); err != nil {
+		return errorResponse(err)
+	}
+
+	snapshot, err := d.manager.CreateSnapshot(ctx, opts)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	d.fire(hooks.EventSnapshotCreated, opts.PuckName, snapshot.Name, snapshot)
+
+	respData, _ := json.Marshal(snapshot)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleSnapshotAll(ctx context.Context, data json.RawMessage) Response {
+	var opts puck.SnapshotAllOptions
+	if err := json.Unmarshal(data, &opts); err != nil {
+		return errorResponse(err)
+	}
+
+	// Only the caller's own pucks, even for admins
+	results, err := d.manager.SnapshotAllOwnedBy(ctx, callerFrom(ctx).User, opts)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	for _, r := range results {
+		if r.Snapshot == nil {
+			continue
+		}
+		d.fire(hooks.EventSnapshotCreated, r.Puck, r.Snapshot.Name, r.Snapshot)
+	}
+
+	respData, _ := json.Marshal(results)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleSnapshotRestore(ctx context.Context, data json.RawMessage) Response {
+	var opts puck.SnapshotRestoreOptions
+	if err := json.Unmarshal(data, &opts); err != nil {
+		return errorResponse(err)
+	}
+
+	if p, err := d.manager.Get(ctx, opts.PuckName); err == nil {
+		if err := d.startRequirements(ctx, p.Requires); err != nil {
+			return errorResponse(err)
+		}
+	}
+
+	before := d.hostPort(ctx, opts.PuckName)
+	if err := d.manager.RestoreSnapshot(ctx, opts); err != nil {
+		return errorResponse(err)
+	}
+
+	if p, err := d.manager.Get(ctx, opts.PuckName); err == nil {
+		d.notePortChange(before, p)
+	}
+	d.fire(hooks.EventSnapshotRestored, opts.PuckName, opts.SnapshotName, nil)
+	d.sleepCheckpointed(ctx)
+
+	return Response{Success: true}
+}
+
+func (d *Daemon) handleSnapshotStackCreate(ctx context.Context, data json.RawMessage) Response {
+	var opts puck.StackSnapshotOptions
+	if err := json.Unmarshal(data, &opts); err != nil {
+		return errorResponse(err)
+	}
+
+	result, err := d.manager.CreateStackSnapshot(ctx, opts)
+	if result != nil {
+		for _, r := range result.Members {
+			if r.Snapshot == nil {
+				continue
+			}
+			d.fire(hooks.EventSnapshotCreated, r.Puck, r.Snapshot.Name, r.Snapshot)
+		}
+	}
+
+	respData, _ := json.Marshal(result)
+	if err != nil {
+		return Response{Success: false, Error: err.Error(), Code: errorCode(err), Data: respData}
+	}
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleSnapshotStackRestore(ctx context.Context, data json.RawMessage) Response {
+	var opts puck.StackRestoreOptions
+	if err := json.Unmarshal(data, &opts); err != nil {
+		return errorResponse(err)
+	}
+
+	restored, err := d.manager.RestoreStackSnapshot(ctx, opts)
+	for _, name := range restored {
+		d.fire(hooks.EventSnapshotRestored, name, opts.SnapshotName, nil)
+	}
+	d.sleepCheckpointed(ctx)
+
+	respData, _ := json.Marshal(restored)
+	if err != nil {
+		return Response{Success: false, Error: err.Error(), Code: errorCode(err), Data: respData}
+	}
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleSnapshotStackList(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		PuckName string `json:"puck_name"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+
+	stacks, err := d.manager.ListStackSnapshots(ctx, params.PuckName)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	respData, _ := json.Marshal(stacks)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleSnapshotList(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		PuckName string `json:"puck_name"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+
+	snapshots, err := d.manager.ListSnapshots(ctx, params.PuckName)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	respData, _ := json.Marshal(snapshots)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleSnapshotInspect(ctx context.Context, data json.RawMessage) Response {
+	var opts puck.SnapshotInspectOptions
+	if err := json.Unmarshal(data, &opts); err != nil {
+		return errorResponse(err)
+	}
+
+	info, err := d.manager.InspectSnapshot(ctx, opts)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	respData, _ := json.Marshal(info)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleSnapshotDiff(ctx context.Context, data json.RawMessage) Response {
+	var opts puck.SnapshotDiffOptions
+	if err := json.Unmarshal(data, &opts); err != nil {
+		return errorResponse(err)
+	}
+
+	diff, err := d.manager.DiffSnapshots(ctx, opts)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	respData, _ := json.Marshal(diff)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleSnapshotDelete(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		PuckName     string `json:"puck_name"`
+		SnapshotName string `json:"snapshot_name"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+
+	if err := d.manager.DeleteSnapshot(ctx, params.PuckName, params.SnapshotName); err != nil {
+		return errorResponse(err)
+	}
+
+	return Response{Success: true}
+}
+
+func (d *Daemon) handleSnapshotTag(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		PuckName     string `json:"puck_name"`
+		SnapshotName string `json:"snapshot_name"`
+		Tag          string `json:"tag"`
+		Remove       bool   `json:"remove"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+
+	var snapshot *store.Snapshot
+	var err error
+	if params.Remove {
+		snapshot, err = d.manager.UntagSnapshot(ctx, params.PuckName, params.SnapshotName, params.Tag)
+	} else {
+		snapshot, err = d.manager.TagSnapshot(ctx, params.PuckName, params.SnapshotName, params.Tag)
+	}
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	respData, _ := json.Marshal(snapshot)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleSetResources(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		Name      string          `json:"name"`
+		Resources store.Resources `json:"resources"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+
+	p, err := d.manager.SetResources(ctx, params.Name, params.Resources)
+	if err != nil {
+		return errorResponse(err)
+	}
+	d.sleepCheckpointed(ctx)
+
+	respData, _ := json.Marshal(p)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleSetHostPort(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		Name string `json:"name"`
+		Port int    `json:"port"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+
+	before := d.hostPort(ctx, params.Name)
+	p, err := d.manager.SetHostPort(ctx, params.Name, params.Port)
+	if err != nil {
+		return errorResponse(err)
+	}
+	d.notePortChange(before, p)
+
+	respData, _ := json.Marshal(p)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleSetNotes(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		Name  string `json:"name"`
+		Notes string `json:"notes"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+
+	p, err := d.manager.SetNotes(ctx, params.Name, params.Notes)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	respData, _ := json.Marshal(p)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleSetTTL(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		Name     string        `json:"name"`
+		TTL      time.Duration `json:"ttl"`
+		Snapshot *bool         `json:"snapshot"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+
+	p, err := d.manager.SetTTL(ctx, params.Name, params.TTL, params.Snapshot)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	respData, _ := json.Marshal(p)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleEgressSet(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		Name   string             `json:"name"`
+		Egress store.EgressPolicy `json:"egress"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+
+	p, err := d.manager.SetEgress(ctx, params.Name, params.Egress)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	respData, _ := json.Marshal(p)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleEnvSet(ctx context.Context, data json.RawMessage) Response {
+	var opts puck.EnvOptions
+	if err := json.Unmarshal(data, &opts); err != nil {
+		return errorResponse(err)
+	}
+
+	p, err := d.manager.SetEnv(ctx, opts)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	respData, _ := json.Marshal(p)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleSnapshotPolicySet(ctx context.Context, data json.RawMessage) Response {
+	var opts puck.SnapshotPolicyOptions
+	if err := json.Unmarshal(data, &opts); err != nil {
+		return errorResponse(err)
+	}
+
+	p, err := d.manager.SetSnapshotPolicy(ctx, opts)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	respData, _ := json.Marshal(p)
+	return Response{Success: true, Data: respData}
+}
+
+// handleProjectStatus reports every project's usage. Quotas count all
+// users' pucks, but callers only see the names of their own.
+func (d *Daemon) handleProjectStatus(ctx context.Context) Response {
+	statuses, err := d.manager.ProjectStatuses(ctx)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	if c := callerFrom(ctx); !c.Admin {
+		pucks, err := d.manager.List(ctx)
+		if err != nil {
+			return errorResponse(err)
+		}
+		visible := make(map[string]bool)
+		for _, p := range filterOwned(pucks, c) {
+			visible[p.Name] = true
+		}
+		for i := range statuses {
+			members := statuses[i].Members[:0]
+			for _, name := range statuses[i].Members {
+				if visible[name] {
+					members = append(members, name)
+				}
+			}
+			statuses[i].Members = members
+		}
+	}
+
+	respData, _ := json.Marshal(statuses)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleRouteSet(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		Name  string            `json:"name"`
+		Route store.RouteConfig `json:"route"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
 	}
 
-	snapshots, err := d.manager.ListSnapshots(ctx, params.PuckName)
+	p, err := d.manager.SetRouteConfig(ctx, params.Name, params.Route)
 	if err != nil {
-		return Response{Success: false, Error: err.Error()}
+		return errorResponse(err)
 	}
 
-	respData, _ := json.Marshal(snapshots)
+	// Apply immediately if the puck is currently routed
+	if p.Status == store.StatusRunning && p.HostPort > 0 {
+		if err := d.addRoute(p); err != nil {
+			return Response{Success: false, Error: fmt.Sprintf("applying route: %v", err)}
+		}
+	}
+
+	respData, _ := json.Marshal(p)
 	return Response{Success: true, Data: respData}
 }
 
-func (d *Daemon) handleSnapshotDelete(ctx context.Context, data json.RawMessage) Response {
+func (d *Daemon) handleTailnetShare(ctx context.Context, data json.RawMessage) Response {
 	var params struct {
-		PuckName     string `json:"puck_name"`
-		SnapshotName string `json:"snapshot_name"`
+		Name string   `json:"name"`
+		Tags []string `json:"tags"`
 	}
 	if err := json.Unmarshal(data, &params); err != nil {
-		return Response{Success: false, Error: err.Error()}
+		return errorResponse(err)
+	}
+	if err := d.requireRouter(); err != nil {
+		return errorResponse(err)
 	}
 
-	if err := d.manager.DeleteSnapshot(ctx, params.PuckName, params.SnapshotName); err != nil {
-		return Response{Success: false, Error: err.Error()}
+	if d.cfg.Tailnet == "" {
+		return Response{Success: false, Error: "tailnet integration is not enabled (set tailnet in config)"}
+	}
+
+	p, err := d.manager.ShareOnTailnet(ctx, params.Name, params.Tags)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	if err := d.router.ShareOnTailnet(p.Name, p.Tailnet.Tags); err != nil {
+		return Response{Success: false, Error: fmt.Sprintf("applying tailnet node: %v", err)}
+	}
+
+	respData, _ := json.Marshal(p)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleTailnetUnshare(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+
+	p, err := d.manager.UnshareFromTailnet(ctx, params.Name)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	if err := d.router.UnshareFromTailnet(p.Name); err != nil {
+		return Response{Success: false, Error: fmt.Sprintf("removing tailnet node: %v", err)}
+	}
+
+	respData, _ := json.Marshal(p)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleShareCreate(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		Name string        `json:"name"`
+		TTL  time.Duration `json:"ttl"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+	if err := d.requireRouter(); err != nil {
+		return errorResponse(err)
+	}
+
+	if d.shareBaseURL() == "" && d.cfg.ShareTunnel == "" {
+		return Response{Success: false, Error: "share links need a public URL: set share_url, in front of share_port, or share_tunnel"}
+	}
+	if params.TTL == 0 {
+		params.TTL = time.Duration(d.cfg.ShareTTL) * time.Minute
+	}
+
+	share, err := d.manager.CreateShare(ctx, puck.ShareCreateOptions{
+		PuckName:  params.Name,
+		TTL:       params.TTL,
+		CreatedBy: callerFrom(ctx).User,
+	})
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	if err := d.router.AddShare(share.Token, share.PuckName, share.ExpiresAt); err != nil {
+		d.manager.RevokeShare(ctx, share.ID)
+		return Response{Success: false, Error: fmt.Sprintf("applying share link: %v", err)}
+	}
+	// The share tunnel is only open while there are links to serve
+	if d.cfg.ShareURL == "" && d.cfg.ShareTunnel != "" {
+		d.awaitTunnel(ctx)
+	}
+	d.shareURL(share)
+
+	respData, _ := json.Marshal(share)
+	if share.URL == "" {
+		return Response{Success: false, Error: fmt.Sprintf("share link %s was created, but the %s share tunnel isn't open yet; see puck daemon logs", share.ID, d.cfg.ShareTunnel), Data: respData}
+	}
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleShareList(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+
+	shares, err := d.manager.ListShares(ctx, params.Name)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	// Without a puck name, only links to pucks the caller may manage
+	if c := callerFrom(ctx); params.Name == "" && !c.Admin {
+		pucks, err := d.manager.List(ctx)
+		if err != nil {
+			return errorResponse(err)
+		}
+		owned := make(map[string]bool)
+		for _, p := range filterOwned(pucks, c) {
+			owned[p.Name] = true
+		}
+		visible := make([]*store.Share, 0, len(shares))
+		for _, s := range shares {
+			if owned[s.PuckName] {
+				visible = append(visible, s)
+			}
+		}
+		shares = visible
+	}
+
+	for _, s := range shares {
+		d.shareURL(s)
+	}
+
+	respData, _ := json.Marshal(shares)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleShareRevoke(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+
+	share, err := d.manager.RevokeShare(ctx, params.ID)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	if err := d.router.RemoveShare(share.Token); err != nil {
+		return Response{Success: false, Error: fmt.Sprintf("removing share link: %v", err)}
+	}
+
+	return Response{Success: true}
+}
+
+func (d *Daemon) handleAliasSet(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		Path string `json:"path"`
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+	if err := d.requireRouter(); err != nil {
+		return errorResponse(err)
+	}
+
+	alias, previous, err := d.manager.SetRouteAlias(ctx, puck.RouteAliasOptions{
+		Path:      params.Path,
+		PuckName:  params.Name,
+		CreatedBy: callerFrom(ctx).User,
+	})
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	if err := d.router.SetAlias(alias.Path, alias.PuckName); err != nil {
+		if previous != nil {
+			d.manager.RestoreRouteAlias(ctx, previous)
+		} else {
+			d.manager.RemoveRouteAlias(ctx, alias.Path)
+		}
+		return Response{Success: false, Error: fmt.Sprintf("applying route alias: %v", err)}
+	}
+
+	respData, _ := json.Marshal(alias)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleEndpointAdd(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		Name     string            `json:"name"`
+		Endpoint puck.EndpointSpec `json:"endpoint"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+
+	e, err := d.manager.AddEndpoint(ctx, params.Name, params.Endpoint)
+	if err != nil {
+		return errorResponse(err)
+	}
+	d.syncEndpoints(ctx, params.Name)
+
+	respData, _ := json.Marshal(e)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleEndpointList(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+
+	endpoints, err := d.manager.Endpoints(ctx, params.Name)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	respData, _ := json.Marshal(endpoints)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleEndpointRemove(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		Name     string `json:"name"`
+		Endpoint string `json:"endpoint"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+
+	if err := d.manager.RemoveEndpoint(ctx, params.Name, params.Endpoint); err != nil {
+		return errorResponse(err)
+	}
+	d.syncEndpoints(ctx, params.Name)
+
+	return Response{Success: true}
+}
+
+// syncEndpoints updates the endpoints served for a puck whose endpoints
+// changed; a suspended puck keeps its sleeping route while it is down
+func (d *Daemon) syncEndpoints(ctx context.Context, name string) {
+	p, err := d.manager.Get(ctx, name)
+	if err != nil {
+		return
+	}
+	if err := d.routeEndpoints(ctx, p); err != nil {
+		log.Warn("Failed to update endpoints for puck", "name", name, "error", err)
+	}
+}
+
+func (d *Daemon) handleAliasList(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+
+	aliases, err := d.manager.ListRouteAliases(ctx, params.Name)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	respData, _ := json.Marshal(aliases)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleAliasRemove(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		Path string `json:"path"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+
+	alias, err := d.manager.RemoveRouteAlias(ctx, params.Path)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	if err := d.router.RemoveAlias(alias.Path); err != nil {
+		d.manager.RestoreRouteAlias(ctx, alias)
+		return Response{Success: false, Error: fmt.Sprintf("removing route alias: %v", err)}
 	}
 
 	return Response{Success: true}
 }
 
+func (d *Daemon) handlePromote(ctx context.Context, data json.RawMessage) Response {
+	var opts puck.PromoteOptions
+	if err := json.Unmarshal(data, &opts); err != nil {
+		return errorResponse(err)
+	}
+	opts.CreatedBy = callerFrom(ctx).User
+
+	result, err := d.manager.Promote(ctx, opts)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	if err := d.router.RepointAliases(result.Paths, result.Puck); err != nil {
+		if rerr := d.manager.RevertPromote(ctx, result); rerr != nil {
+			log.Warn("Failed to restore route aliases", "name", result.Puck, "error", rerr)
+		}
+		return Response{Success: false, Error: fmt.Sprintf("switching routes: %v", err)}
+	}
+	d.fire(hooks.EventPuckPromoted, result.Puck, "", result)
+
+	// Only once nothing is routed to it any more
+	if opts.StopOld {
+		if err := d.manager.Stop(ctx, result.Replaced); err != nil {
+			log.Warn("Failed to stop replaced puck", "name", result.Replaced, "error", err)
+		} else {
+			result.Stopped = true
+			d.fire(hooks.EventPuckStopped, result.Replaced, "", nil)
+		}
+	}
+
+	respData, _ := json.Marshal(result)
+	return Response{Success: true, Data: respData}
+}
+
+// requireRouter refuses requests that only the router can serve when it
+// is disabled
+func (d *Daemon) requireRouter() error {
+	if !d.cfg.RouterEnabled {
+		return fmt.Errorf("the HTTP router is disabled (router_enabled: false); pucks are only reached on their host ports")
+	}
+	return nil
+}
+
+func (d *Daemon) routerStatus() network.RouterStatus {
+	st := d.router.Status()
+	st.Disabled = !d.cfg.RouterEnabled
+	return st
+}
+
+// handleRoutes reports the route table the router is serving. Callers who
+// aren't admins only see the routes of their own pucks.
+func (d *Daemon) handleRoutes(ctx context.Context) Response {
+	routes := d.router.Routes(ctx)
+	if c := callerFrom(ctx); !c.Admin {
+		pucks, err := d.manager.List(ctx)
+		if err != nil {
+			return errorResponse(err)
+		}
+		visible := make(map[string]bool)
+		for _, p := range filterOwned(pucks, c) {
+			visible[p.Name] = true
+		}
+		routes = slices.DeleteFunc(routes, func(e network.RouteEntry) bool { return !visible[e.Name] })
+	}
+
+	respData, _ := json.Marshal(routes)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleRouterStatus() Response {
+	respData, _ := json.Marshal(d.routerStatus())
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleRouterRestart(ctx context.Context) Response {
+	if err := d.requireRouter(); err != nil {
+		return errorResponse(err)
+	}
+	if err := d.router.Restart(); err != nil {
+		return errorResponse(err)
+	}
+	log.Info("HTTP router restarted", "port", d.router.Status().Port)
+
+	// Drop anything that drifted from the database while it ran
+	if err := d.rebuildRouter(ctx); err != nil {
+		return Response{Success: false, Error: fmt.Sprintf("rebuilding routes: %v", err)}
+	}
+
+	respData, _ := json.Marshal(d.router.Status())
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleGC(ctx context.Context, data json.RawMessage) Response {
+	var opts puck.GCOptions
+	if err := json.Unmarshal(data, &opts); err != nil {
+		return errorResponse(err)
+	}
+
+	report, err := d.manager.GC(ctx, opts)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	respData, _ := json.Marshal(report)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleMachineResources(ctx context.Context) Response {
+	status, err := d.manager.MachineStatus(ctx)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	respData, _ := json.Marshal(status)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleMachineSetResources(ctx context.Context, data json.RawMessage) Response {
+	var res podman.MachineResources
+	if err := json.Unmarshal(data, &res); err != nil {
+		return errorResponse(err)
+	}
+
+	status, err := d.manager.SetMachineResources(ctx, res)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	respData, _ := json.Marshal(status)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleDBCheck(ctx context.Context, data json.RawMessage) Response {
+	var opts puck.CheckOptions
+	if err := json.Unmarshal(data, &opts); err != nil {
+		return errorResponse(err)
+	}
+
+	report, err := d.manager.Check(ctx, opts)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	respData, _ := json.Marshal(report)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleSnapshotTierStatus(ctx context.Context) Response {
+	status, err := d.manager.TierStatus(ctx)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	respData, _ := json.Marshal(status)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleSnapshotTierRun(ctx context.Context) Response {
+	report, err := d.manager.TierSnapshots(ctx)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	respData, _ := json.Marshal(report)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleHooks() Response {
+	st, err := d.hooks.Status()
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	respData, _ := json.Marshal(st)
+	return Response{Success: true, Data: respData}
+}
+
 // Manager returns the puck manager (for console command which needs direct access)
 func (d *Daemon) Manager() *puck.Manager {
 	return d.manager
@@ -1,9 +1,19 @@
 package daemon
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/sandwich-labs/puck/internal/hooks"
+	"github.com/sandwich-labs/puck/internal/network"
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/sandwich-labs/puck/internal/store"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -94,11 +104,14 @@ func TestResponseJSON(t *testing.T) {
 		assert.Equal(t, "puck not found", decoded.Error)
 	})
 
-	t.Run("marshals partial success response", func(t *testing.T) {
-		// Used by destroy-all when some pucks fail
-		destroyed := []string{"puck1", "puck2"}
-		destroyedJSON, _ := json.Marshal(destroyed)
-		resp := Response{Success: false, Error: "some pucks failed", Data: destroyedJSON}
+	t.Run("marshals per-puck results", func(t *testing.T) {
+		// Used by destroy-all, which reports each puck rather than failing
+		results := []puck.DestroyResult{
+			{Puck: "puck1", Duration: time.Second},
+			{Puck: "puck2", Error: "container is busy", Duration: time.Second},
+		}
+		resultsJSON, _ := json.Marshal(results)
+		resp := Response{Success: true, Data: resultsJSON}
 
 		data, err := json.Marshal(resp)
 		require.NoError(t, err)
@@ -106,13 +119,13 @@ func TestResponseJSON(t *testing.T) {
 		var decoded Response
 		err = json.Unmarshal(data, &decoded)
 		require.NoError(t, err)
-		assert.False(t, decoded.Success)
-		assert.Equal(t, "some pucks failed", decoded.Error)
+		assert.True(t, decoded.Success)
+		assert.Empty(t, decoded.Error)
 
-		var decodedDestroyed []string
-		err = json.Unmarshal(decoded.Data, &decodedDestroyed)
+		var decodedResults []puck.DestroyResult
+		err = json.Unmarshal(decoded.Data, &decodedResults)
 		require.NoError(t, err)
-		assert.Equal(t, []string{"puck1", "puck2"}, decodedDestroyed)
+		assert.Equal(t, results, decodedResults)
 	})
 }
 
@@ -122,15 +135,71 @@ func TestRequestActions(t *testing.T) {
 		"create",
 		"list",
 		"get",
+		"watch",
+		"history",
+		"exec",
+		"exec-stream",
+		"logs",
+		"fs-list",
+		"fs-stat",
+		"fs-read",
 		"start",
 		"stop",
+		"kill",
+		"recreate",
+		"rollback",
 		"destroy",
 		"destroy-all",
 		"snapshot-create",
+		"snapshot-all",
 		"snapshot-restore",
 		"snapshot-list",
+		"snapshot-inspect",
 		"snapshot-delete",
+		"snapshot-tag",
+		"snapshot-stack-create",
+		"snapshot-stack-restore",
+		"snapshot-stack-list",
+		"route-set",
+		"set-resources",
+		"set-ttl",
+		"egress-set",
+		"env-set",
+		"project-status",
+		"sync-status",
+		"sync-flush",
+		"tailnet-share",
+		"tailnet-unshare",
+		"tailnet-status",
+		"share-create",
+		"share-list",
+		"share-revoke",
+		"alias-set",
+		"alias-list",
+		"alias-remove",
+		"endpoint-add",
+		"endpoint-list",
+		"endpoint-remove",
+		"pool-create",
+		"pool-list",
+		"pool-remove",
+		"promote",
+		"gc",
+		"images",
+		"image-tree",
+		"scan",
+		"report",
+		"events-export",
+		"stats-export",
+		"db-check",
+		"machine-resources",
+		"machine-set-resources",
+		"routes",
+		"router-status",
+		"router-restart",
+		"hooks",
 		"ping",
+		"podman-status",
 	}
 
 	for _, action := range actions {
@@ -151,3 +220,193 @@ func TestRequestActions(t *testing.T) {
 // The handleRequest logic is tested indirectly through client_test.go
 // which uses a mock server. Additional integration tests would require
 // either dependency injection or running with real Podman.
+
+func TestRebuildRouter(t *testing.T) {
+	d := setupAuthDaemon(t)
+	d.router = network.NewRouter(8080, "localhost")
+	mock := podman.NewMockClient()
+	mock.IsRunningFunc = func(ctx context.Context, nameOrID string) (bool, error) {
+		return nameOrID == "c-web", nil
+	}
+	d.manager = puck.NewManager(d.cfg, mock, d.store)
+	ctx := context.Background()
+
+	for _, p := range []*store.Puck{
+		{ID: "w1", ContainerID: "c-web", Name: "web", Image: "fedora", Status: store.StatusRunning, HostPort: 9001, Route: store.RouteConfig{MaxConns: 10}},
+		{ID: "s1", ContainerID: "c-stopped", Name: "stopped", Image: "fedora", Status: store.StatusStopped, HostPort: 9002},
+	} {
+		require.NoError(t, d.store.CreatePuck(ctx, p))
+	}
+	require.NoError(t, d.store.SetRouteAlias(ctx, &store.RouteAlias{Path: "/app", PuckName: "web", CreatedAt: time.Now()}))
+
+	// State the database doesn't have, as after a crash mid-change
+	require.NoError(t, d.router.AddRoute("gone", "127.0.0.1", 9003, store.RouteConfig{}))
+	require.NoError(t, d.router.SetAlias("/old", "gone"))
+
+	require.NoError(t, d.rebuildRouter(ctx))
+	assert.Equal(t, map[string]string{"web": "127.0.0.1:9001"}, d.router.GetRoutes())
+	assert.Equal(t, map[string]string{"/app": "web"}, d.router.GetAliases())
+}
+
+func TestReconcileRoutes(t *testing.T) {
+	d := setupAuthDaemon(t)
+	d.router = network.NewRouter(8080, "localhost")
+	mock := podman.NewMockClient()
+	mock.IsRunningFunc = func(ctx context.Context, nameOrID string) (bool, error) {
+		return nameOrID != "c-stopped", nil
+	}
+	d.manager = puck.NewManager(d.cfg, mock, d.store)
+	ctx := context.Background()
+
+	for _, p := range []*store.Puck{
+		{ID: "w1", ContainerID: "c-web", Name: "web", Image: "fedora", Status: store.StatusRunning, HostPort: 9001},
+		{ID: "p1", ContainerID: "c-api", Name: "api", Image: "fedora", Status: store.StatusRunning, HostPort: 9002},
+		{ID: "m1", ContainerID: "c-moved", Name: "moved", Image: "fedora", Status: store.StatusRunning, HostPort: 9005},
+		{ID: "s1", ContainerID: "c-stopped", Name: "stopped", Image: "fedora", Status: store.StatusStopped, HostPort: 9003},
+		{ID: "g1", ContainerID: "c-booting", Name: "booting", Image: "fedora", Status: store.StatusStarting, HostPort: 9004},
+	} {
+		require.NoError(t, d.store.CreatePuck(ctx, p))
+	}
+
+	// The router as a daemon that missed some changes might have it
+	require.NoError(t, d.router.AddRoute("web", "127.0.0.1", 9001, store.RouteConfig{}))
+	require.NoError(t, d.router.AddRoute("moved", "127.0.0.1", 9100, store.RouteConfig{}))
+	require.NoError(t, d.router.AddRoute("stopped", "127.0.0.1", 9003, store.RouteConfig{}))
+	require.NoError(t, d.router.AddRoute("booting", "127.0.0.1", 9004, store.RouteConfig{}))
+	require.NoError(t, d.router.AddRoute("gone", "127.0.0.1", 9009, store.RouteConfig{}))
+	require.NoError(t, d.router.SetAlias("/old", "gone"))
+
+	d.reconcileRoutes(ctx)
+	assert.Equal(t, map[string]string{
+		"web":     "127.0.0.1:9001",
+		"api":     "127.0.0.1:9002",
+		"moved":   "127.0.0.1:9005",
+		"booting": "127.0.0.1:9004",
+	}, d.router.GetRoutes())
+	assert.Empty(t, d.router.GetAliases())
+}
+
+func TestDestroyAllResults(t *testing.T) {
+	d := setupAuthDaemon(t)
+	d.router = network.NewRouter(8080, "localhost")
+	d.hooks = hooks.NewRunner(t.TempDir(), time.Second)
+	mock := podman.NewMockClient()
+	mock.RemoveContainerFunc = func(ctx context.Context, nameOrID string, force bool) error {
+		if nameOrID == "c-busy" {
+			return errors.New("container is busy")
+		}
+		return nil
+	}
+	d.manager = puck.NewManager(d.cfg, mock, d.store)
+	d.manager.SetRouter(puckRoutes{d})
+	ctx := context.Background()
+
+	// Both sync a directory into their volume
+	synced := store.Spec{Mounts: []store.Mount{{Source: t.TempDir(), Target: "/srv", Sync: true}}}
+	for _, p := range []*store.Puck{
+		{ID: "w1", ContainerID: "c-web", Name: "web", Image: "fedora", Status: store.StatusStopped, HostPort: 9001, Owner: "carol", VolumeDir: t.TempDir(), Spec: synced},
+		{ID: "b2", ContainerID: "c-busy", Name: "busy", Image: "fedora", Status: store.StatusStopped, HostPort: 9002, Owner: "carol", VolumeDir: t.TempDir(), Spec: synced},
+	} {
+		require.NoError(t, d.store.CreatePuck(ctx, p))
+		require.NoError(t, os.MkdirAll(filepath.Join(p.VolumeDir, "sync", "0"), 0755))
+	}
+	require.NoError(t, d.router.AddRoute("web", "127.0.0.1", 9001, store.RouteConfig{}))
+	d.startAllSyncs(ctx)
+	defer d.stopAllSyncs()
+	require.Len(t, d.syncSessions("busy"), 1)
+
+	carol := withCaller(ctx, caller{User: "carol"})
+	resp := d.handleDestroyAll(carol, json.RawMessage(`{"force":false}`))
+	require.True(t, resp.Success, resp.Error)
+
+	var results []puck.DestroyResult
+	require.NoError(t, json.Unmarshal(resp.Data, &results))
+	byPuck := make(map[string]puck.DestroyResult)
+	for _, r := range results {
+		byPuck[r.Puck] = r
+	}
+	assert.Len(t, byPuck, 2, "carol's pucks only")
+	assert.Empty(t, byPuck["web"].Error)
+	assert.Contains(t, byPuck["busy"].Error, "container is busy")
+
+	assert.Empty(t, d.router.GetRoutes())
+	_, err := d.store.GetPuck(ctx, "busy")
+	assert.NoError(t, err, "a puck that failed to destroy is kept")
+	assert.Empty(t, d.syncSessions("web"))
+	assert.Len(t, d.syncSessions("busy"), 1, "a puck that failed to destroy syncs again")
+}
+
+func TestRouterDisabled(t *testing.T) {
+	d := setupAuthDaemon(t)
+	d.router = network.NewRouter(8080, "localhost")
+	d.cfg.RouterEnabled = false
+	ctx := context.Background()
+
+	resp := d.handleRouterStatus()
+	require.True(t, resp.Success)
+	var st network.RouterStatus
+	require.NoError(t, json.Unmarshal(resp.Data, &st))
+	assert.True(t, st.Disabled)
+	assert.False(t, st.Running)
+
+	data, _ := json.Marshal(map[string]string{"path": "/app", "name": "alice-puck"})
+	resp = d.handleAliasSet(ctx, data)
+	assert.False(t, resp.Success)
+	assert.Contains(t, resp.Error, "router_enabled: false")
+
+	resp = d.handleRouterRestart(ctx)
+	assert.False(t, resp.Success)
+	assert.Contains(t, resp.Error, "router_enabled: false")
+}
+
+func TestHandleWatch(t *testing.T) {
+	d := setupAuthDaemon(t)
+	ctx := context.Background()
+
+	watch := func(since uint64, timeout int) uint64 {
+		data, _ := json.Marshal(map[string]any{"since": since, "timeout": timeout})
+		resp := d.handleWatch(ctx, data)
+		require.True(t, resp.Success, resp.Error)
+		var result struct {
+			Generation uint64 `json:"generation"`
+		}
+		require.NoError(t, json.Unmarshal(resp.Data, &result))
+		return result.Generation
+	}
+
+	gen := watch(0, 1)
+	assert.NotZero(t, gen, "creating the pucks changed the database")
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		d.store.UpdatePuckStatus(ctx, "alice-puck", store.StatusStopped)
+	}()
+	next := watch(gen, 5)
+	assert.Greater(t, next, gen)
+
+	// Nothing changes, so it answers after the timeout with the same generation
+	assert.Equal(t, next, watch(next, 1))
+}
+
+func TestHandleRoutes(t *testing.T) {
+	d := setupAuthDaemon(t)
+	d.router = network.NewRouter(8080, "localhost")
+	for _, name := range []string{"alice-puck", "bob-puck"} {
+		require.NoError(t, d.router.AddRoute(name, "127.0.0.1", 9000, store.RouteConfig{}))
+	}
+
+	names := func(ctx context.Context) []string {
+		resp := d.handleRoutes(ctx)
+		require.True(t, resp.Success, resp.Error)
+		var routes []network.RouteEntry
+		require.NoError(t, json.Unmarshal(resp.Data, &routes))
+		var out []string
+		for _, r := range routes {
+			out = append(out, r.Name)
+		}
+		return out
+	}
+
+	assert.Equal(t, []string{"alice-puck", "bob-puck"}, names(context.Background()))
+	assert.Equal(t, []string{"alice-puck"}, names(withCaller(context.Background(), caller{User: "alice"})))
+}
@@ -0,0 +1,127 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// statsInterval is how often pucks' resource use is sampled for puck
+// report. Each sample walks the pucks' volumes to size them.
+const statsInterval = 15 * time.Minute
+
+// statsRetention is how long samples are kept
+const statsRetention = 90 * 24 * time.Hour
+
+// sampleStats periodically records every puck's resource use and the
+// requests the router has served it, and drops samples past retention
+func (d *Daemon) sampleStats(ctx context.Context) {
+	ticker := time.NewTicker(statsInterval)
+	defer ticker.Stop()
+
+	for {
+		hits := make(map[string]uint64)
+		if d.cfg.RouterEnabled {
+			for _, e := range d.router.Routes(ctx) {
+				hits[e.Name] = e.Hits
+			}
+		}
+		if err := d.manager.RecordStats(ctx, hits); err != nil {
+			log.Warn("Failed to record stats", "error", err)
+		}
+		if n, err := d.store.PruneStats(ctx, time.Now().Add(-statsRetention)); err != nil {
+			log.Warn("Failed to prune stats", "error", err)
+		} else if n > 0 {
+			log.Debug("Pruned stats", "count", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Daemon) handleReport(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		Since time.Time `json:"since"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+
+	pucks, err := d.manager.List(ctx)
+	if err != nil {
+		return errorResponse(err)
+	}
+	report, err := d.manager.Report(ctx, filterOwned(pucks, callerFrom(ctx)), params.Since)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	respData, _ := json.Marshal(report)
+	return Response{Success: true, Data: respData}
+}
+
+// exportParams selects the events or stats to export: a single puck's, or
+// those of every puck the caller may see
+type exportParams struct {
+	Name  string    `json:"name"`
+	Since time.Time `json:"since"`
+}
+
+// exportPucks returns the pucks an export covers
+func (d *Daemon) exportPucks(ctx context.Context, params exportParams) ([]*store.Puck, error) {
+	if params.Name != "" {
+		p, err := d.manager.Get(ctx, params.Name)
+		if err != nil {
+			return nil, err
+		}
+		return []*store.Puck{p}, nil
+	}
+	pucks, err := d.manager.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return filterOwned(pucks, callerFrom(ctx)), nil
+}
+
+func (d *Daemon) handleEventsExport(ctx context.Context, data json.RawMessage) Response {
+	var params exportParams
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+	pucks, err := d.exportPucks(ctx, params)
+	if err != nil {
+		return errorResponse(err)
+	}
+	events, err := d.manager.Events(ctx, pucks, params.Since)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	respData, _ := json.Marshal(events)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleStatsExport(ctx context.Context, data json.RawMessage) Response {
+	var params exportParams
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+	pucks, err := d.exportPucks(ctx, params)
+	if err != nil {
+		return errorResponse(err)
+	}
+	stats, err := d.manager.Stats(ctx, pucks, params.Since)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	respData, _ := json.Marshal(stats)
+	return Response{Success: true, Data: respData}
+}
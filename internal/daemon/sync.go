@@ -0,0 +1,154 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/charmbracelet/log"
+	"github.com/sandwich-labs/puck/internal/filesync"
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// SyncStatus is how one of a puck's synced mounts is doing
+type SyncStatus struct {
+	Puck   string `json:"puck"`
+	Target string `json:"target"`
+	filesync.Status
+}
+
+// syncSession keeps one synced mount up to date
+type syncSession struct {
+	target  string
+	session *filesync.Session
+}
+
+// startAllSyncs starts syncing the mounts of every puck that has any
+func (d *Daemon) startAllSyncs(ctx context.Context) {
+	pucks, err := d.manager.List(ctx)
+	if err != nil {
+		log.Warn("Failed to list pucks for file sync", "error", err)
+		return
+	}
+	for _, p := range pucks {
+		d.startSyncs(p)
+	}
+}
+
+// startSyncs starts syncing a puck's synced mounts, replacing any
+// sessions it already has
+func (d *Daemon) startSyncs(p *store.Puck) {
+	mounts := puck.SyncMounts(p)
+	if len(mounts) == 0 {
+		return
+	}
+	d.stopSyncs(p.Name)
+
+	var sessions []syncSession
+	for _, sm := range mounts {
+		s, err := filesync.Start(sm.Source, sm.Dest, sm.Ignore)
+		if err != nil {
+			log.Warn("Failed to start file sync", "puck", p.Name, "source", sm.Source, "error", err)
+			continue
+		}
+		sessions = append(sessions, syncSession{target: sm.Target, session: s})
+	}
+
+	d.syncMu.Lock()
+	defer d.syncMu.Unlock()
+	if d.syncs == nil {
+		d.syncs = make(map[string][]syncSession)
+	}
+	d.syncs[p.Name] = sessions
+}
+
+// stopSyncs stops syncing a puck's mounts, e.g. once it is destroyed
+func (d *Daemon) stopSyncs(name string) {
+	d.syncMu.Lock()
+	sessions := d.syncs[name]
+	delete(d.syncs, name)
+	d.syncMu.Unlock()
+
+	for _, s := range sessions {
+		s.session.Close()
+	}
+}
+
+// stopAllSyncs stops every sync session, at shutdown
+func (d *Daemon) stopAllSyncs() {
+	d.syncMu.Lock()
+	names := make([]string, 0, len(d.syncs))
+	for name := range d.syncs {
+		names = append(names, name)
+	}
+	d.syncMu.Unlock()
+
+	for _, name := range names {
+		d.stopSyncs(name)
+	}
+}
+
+// syncSessions returns a puck's sync sessions
+func (d *Daemon) syncSessions(name string) []syncSession {
+	d.syncMu.Lock()
+	defer d.syncMu.Unlock()
+	return d.syncs[name]
+}
+
+func (d *Daemon) handleSyncStatus(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+
+	var pucks []*store.Puck
+	if params.Name != "" {
+		p, err := d.manager.Get(ctx, params.Name)
+		if err != nil {
+			return errorResponse(err)
+		}
+		pucks = []*store.Puck{p}
+	} else {
+		all, err := d.manager.List(ctx)
+		if err != nil {
+			return errorResponse(err)
+		}
+		pucks = filterOwned(all, callerFrom(ctx))
+	}
+
+	statuses := []SyncStatus{}
+	for _, p := range pucks {
+		for _, s := range d.syncSessions(p.Name) {
+			statuses = append(statuses, SyncStatus{Puck: p.Name, Target: s.target, Status: s.session.Status()})
+		}
+	}
+	respData, _ := json.Marshal(statuses)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleSyncFlush(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+
+	sessions := d.syncSessions(params.Name)
+	if len(sessions) == 0 {
+		return Response{Success: false, Error: fmt.Sprintf("puck '%s' has no synced mounts", params.Name)}
+	}
+
+	var statuses []SyncStatus
+	for _, s := range sessions {
+		if _, err := s.session.Flush(ctx); err != nil {
+			return Response{Success: false, Error: fmt.Sprintf("syncing %s: %v", s.target, err)}
+		}
+		statuses = append(statuses, SyncStatus{Puck: params.Name, Target: s.target, Status: s.session.Status()})
+	}
+	respData, _ := json.Marshal(statuses)
+	return Response{Success: true, Data: respData}
+}
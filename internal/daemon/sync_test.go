@@ -0,0 +1,68 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncActions(t *testing.T) {
+	d := setupAuthDaemon(t)
+	ctx := context.Background()
+
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "index.html"), []byte("hi"), 0644))
+	p := &store.Puck{
+		ID: "a2", Name: "alice-sync", Image: "fedora", Status: store.StatusRunning, VolumeDir: t.TempDir(), Owner: "alice",
+		Spec: store.Spec{Mounts: []store.Mount{{Source: src, Target: "/srv", Sync: true}}},
+	}
+	require.NoError(t, d.store.CreatePuck(ctx, p))
+	require.NoError(t, os.MkdirAll(filepath.Join(p.VolumeDir, "sync", "0"), 0755))
+
+	d.startAllSyncs(ctx)
+	defer d.stopAllSyncs()
+
+	request := func(c caller, action, name string) ([]SyncStatus, Response) {
+		data, _ := json.Marshal(map[string]string{"name": name})
+		resp := d.handleRequest(withCaller(ctx, c), &Request{Action: action, Data: data})
+		var statuses []SyncStatus
+		json.Unmarshal(resp.Data, &statuses)
+		return statuses, resp
+	}
+
+	t.Run("flushes a puck's mounts", func(t *testing.T) {
+		statuses, resp := request(caller{User: "alice"}, "sync-flush", "alice-sync")
+		require.True(t, resp.Success, resp.Error)
+		require.Len(t, statuses, 1)
+		assert.Equal(t, "/srv", statuses[0].Target)
+		assert.FileExists(t, filepath.Join(p.VolumeDir, "sync", "0", "index.html"))
+
+		_, resp = request(caller{User: "alice"}, "sync-flush", "alice-puck")
+		assert.Contains(t, resp.Error, "no synced mounts")
+		_, resp = request(caller{User: "bob"}, "sync-flush", "alice-sync")
+		assert.Contains(t, resp.Error, "permission denied")
+	})
+
+	t.Run("lists only the caller's mounts", func(t *testing.T) {
+		statuses, resp := request(caller{User: "alice"}, "sync-status", "")
+		require.True(t, resp.Success, resp.Error)
+		require.Len(t, statuses, 1)
+		assert.Equal(t, "alice-sync", statuses[0].Puck)
+		assert.Equal(t, src, statuses[0].Source)
+
+		statuses, resp = request(caller{User: "bob"}, "sync-status", "")
+		require.True(t, resp.Success, resp.Error)
+		assert.Empty(t, statuses)
+	})
+
+	t.Run("stops a puck's sessions", func(t *testing.T) {
+		d.stopSyncs("alice-sync")
+		assert.Empty(t, d.syncSessions("alice-sync"))
+	})
+}
@@ -0,0 +1,240 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/sandwich-labs/puck/internal/network"
+	"tailscale.com/client/tailscale/apitype"
+	"tailscale.com/tsnet"
+)
+
+// tailnetLogoutTimeout bounds logging a stale node out of the tailnet
+const tailnetLogoutTimeout = 15 * time.Second
+
+// TailnetStatus reports the tailnet nodes the daemon runs and the state
+// they keep under the data directory
+type TailnetStatus struct {
+	Tailnet  string        `json:"tailnet"`
+	StateDir string        `json:"state_dir"`
+	Nodes    []TailnetNode `json:"nodes"`
+}
+
+// TailnetNode is one of the daemon's tailnet nodes, or the state one left
+// behind
+type TailnetNode struct {
+	Role     string   `json:"role"` // api, router or puck
+	Name     string   `json:"name,omitempty"`
+	Dir      string   `json:"dir"`
+	LoggedIn bool     `json:"logged_in"`
+	State    string   `json:"state,omitempty"` // the API node's backend state, while it runs
+	IPs      []string `json:"ips,omitempty"`
+	// State of a puck that is no longer shared, removed on the next start
+	Stale bool `json:"stale,omitempty"`
+}
+
+// tailnetConn is a connection accepted on the daemon's tailnet node,
+// labelled with the tailnet user on the other end
+type tailnetConn struct {
+	net.Conn
+	user string // empty for tagged devices, which belong to no user
+}
+
+// startTailnet serves the API as its own tailnet node over HTTPS with the
+// node's tailnet certificate, so remote contexts need no certificates of
+// their own; callers are named by their tailnet login. The node joins with
+// TS_AUTHKEY like the router's nodes, or logs a login URL; startTailnet
+// blocks until it is up.
+func (d *Daemon) startTailnet(ctx context.Context) error {
+	name := d.cfg.DaemonTailnetName
+	srv := &tsnet.Server{
+		Hostname: name,
+		Dir:      filepath.Join(d.cfg.TailscaleDir(), name),
+		UserLogf: func(format string, args ...any) {
+			log.Info("Tailnet API: " + fmt.Sprintf(format, args...))
+		},
+	}
+	d.mu.Lock()
+	d.tailnet = srv
+	d.mu.Unlock()
+
+	ln, err := srv.ListenTLS("tcp", ":443")
+	if err != nil {
+		return fmt.Errorf("listening on the tailnet: %w", err)
+	}
+	lc, err := srv.LocalClient()
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+					return
+				}
+				log.Error("Tailnet accept error", "error", err)
+				continue
+			}
+			go func() {
+				who, err := lc.WhoIs(ctx, conn.RemoteAddr().String())
+				if err != nil {
+					log.Warn("Could not identify tailnet caller", "addr", conn.RemoteAddr().String(), "error", err)
+				}
+				d.handleConnection(ctx, &tailnetConn{Conn: conn, user: tailnetUser(who)})
+			}()
+		}
+	}()
+
+	log.Info("Tailnet API started", "addr", "https://"+d.cfg.TailnetAPIAddress())
+	return nil
+}
+
+// tailnetUser returns the login name of a tailnet peer's owner, or an
+// empty string for tagged devices and peers that couldn't be identified
+func tailnetUser(who *apitype.WhoIsResponse) string {
+	if who == nil || who.Node == nil || who.UserProfile == nil || who.Node.IsTagged() {
+		return ""
+	}
+	return who.UserProfile.LoginName
+}
+
+// callerForTailnet names a tailnet client after its login. Logins listed
+// in admins are admins; there is no local root on the tailnet.
+func (d *Daemon) callerForTailnet(conn *tailnetConn) caller {
+	if conn.user == "" {
+		return caller{}
+	}
+	return caller{User: conn.user, Admin: slices.Contains(d.cfg.Admins, conn.user)}
+}
+
+// sharedOnTailnet returns the names of pucks shared as their own nodes
+func (d *Daemon) sharedOnTailnet(ctx context.Context) (map[string]bool, error) {
+	pucks, err := d.manager.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shared := make(map[string]bool)
+	for _, p := range pucks {
+		if p.Tailnet != nil {
+			shared[p.Name] = true
+		}
+	}
+	return shared, nil
+}
+
+// tidyTailscale runs before the router starts. It moves router node state
+// older versions kept in the user's config directory under the data
+// directory, so nodes keep their identity, and logs out and removes the
+// nodes of pucks no longer shared, which would otherwise linger on the
+// tailnet as stale devices.
+func (d *Daemon) tidyTailscale(ctx context.Context) {
+	dir := d.cfg.RouterTailscaleDir()
+	shared, err := d.sharedOnTailnet(ctx)
+	if err != nil {
+		log.Warn("Failed to list pucks shared on the tailnet", "error", err)
+		return
+	}
+
+	names := make([]string, 0, len(shared))
+	for name := range shared {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if moved, err := network.AdoptLegacyTailscaleState(dir, names); err != nil {
+		log.Warn("Failed to move router tailnet state", "error", err)
+	} else if len(moved) > 0 {
+		log.Info("Moved router tailnet state to the data directory", "nodes", len(moved), "dir", dir)
+	}
+
+	states, err := network.TailscaleNodeStates(dir)
+	if err != nil {
+		log.Warn("Failed to read router tailnet state", "error", err)
+		return
+	}
+	for _, st := range states {
+		if st.Name == "" || shared[st.Name] {
+			continue
+		}
+		lctx, cancel := context.WithTimeout(ctx, tailnetLogoutTimeout)
+		err := network.LogoutTailscaleNode(lctx, st.Dir, st.Name)
+		cancel()
+		if err != nil {
+			// Kept, to try again on the next start
+			log.Warn("Failed to remove stale tailnet node", "name", st.Name, "error", err)
+			continue
+		}
+		log.Info("Removed stale tailnet node", "name", st.Name)
+	}
+}
+
+func (d *Daemon) handleTailnetStatus(ctx context.Context) Response {
+	if c := callerFrom(ctx); !c.Admin {
+		return Response{Success: false, Error: "permission denied: tailnet status requires an admin"}
+	}
+	if d.cfg.Tailnet == "" {
+		return Response{Success: false, Error: "tailnet integration is not enabled (set tailnet in config)"}
+	}
+
+	status := TailnetStatus{Tailnet: d.cfg.Tailnet, StateDir: d.cfg.TailscaleDir(), Nodes: []TailnetNode{}}
+	if d.cfg.ServesTailnetAPI() {
+		status.Nodes = append(status.Nodes, d.apiNodeStatus(ctx))
+	}
+
+	shared, err := d.sharedOnTailnet(ctx)
+	if err != nil {
+		return errorResponse(err)
+	}
+	states, err := network.TailscaleNodeStates(d.cfg.RouterTailscaleDir())
+	if err != nil {
+		return errorResponse(err)
+	}
+	for _, st := range states {
+		node := TailnetNode{Role: "puck", Name: st.Name, Dir: st.Dir, LoggedIn: st.LoggedIn, Stale: !shared[st.Name]}
+		if st.Name == "" {
+			node.Role, node.Stale = "router", false
+		}
+		status.Nodes = append(status.Nodes, node)
+	}
+
+	respData, _ := json.Marshal(status)
+	return Response{Success: true, Data: respData}
+}
+
+// apiNodeStatus reports the API's own tailnet node, asking it for its
+// addresses while it runs
+func (d *Daemon) apiNodeStatus(ctx context.Context) TailnetNode {
+	dir := filepath.Join(d.cfg.TailscaleDir(), d.cfg.DaemonTailnetName)
+	node := TailnetNode{Role: "api", Name: d.cfg.DaemonTailnetName, Dir: dir, LoggedIn: network.TailscaleLoggedIn(dir)}
+
+	d.mu.Lock()
+	srv, _ := d.tailnet.(*tsnet.Server)
+	d.mu.Unlock()
+	if srv == nil {
+		return node
+	}
+	lc, err := srv.LocalClient()
+	if err != nil {
+		return node
+	}
+	sctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	st, err := lc.StatusWithoutPeers(sctx)
+	if err != nil {
+		return node
+	}
+	node.State = st.BackendState
+	for _, ip := range st.TailscaleIPs {
+		node.IPs = append(node.IPs, ip.String())
+	}
+	return node
+}
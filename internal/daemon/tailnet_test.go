@@ -0,0 +1,90 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/sandwich-labs/puck/internal/network"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"tailscale.com/client/tailscale/apitype"
+	"tailscale.com/tailcfg"
+)
+
+func TestTailnetUser(t *testing.T) {
+	assert.Equal(t, "alice@example.com", tailnetUser(&apitype.WhoIsResponse{
+		Node:        &tailcfg.Node{},
+		UserProfile: &tailcfg.UserProfile{LoginName: "alice@example.com"},
+	}))
+
+	// Tagged devices belong to no user
+	assert.Empty(t, tailnetUser(&apitype.WhoIsResponse{
+		Node:        &tailcfg.Node{Tags: []string{"tag:ci"}},
+		UserProfile: &tailcfg.UserProfile{LoginName: "tagged-devices"},
+	}))
+	assert.Empty(t, tailnetUser(nil))
+}
+
+func TestCallerForTailnet(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	d := &Daemon{cfg: &config.Config{Admins: []string{"bob@example.com"}}}
+
+	c := d.callerForConn(&tailnetConn{Conn: server, user: "alice@example.com"})
+	assert.Equal(t, caller{User: "alice@example.com"}, c)
+
+	c = d.callerForConn(&tailnetConn{Conn: server, user: "bob@example.com"})
+	assert.True(t, c.Admin)
+
+	c = d.callerForConn(&tailnetConn{Conn: server})
+	assert.Equal(t, caller{}, c)
+}
+
+func TestTailnetState(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	d := setupAuthDaemon(t)
+	d.cfg.Tailnet = "example.ts.net"
+	require.NoError(t, d.store.UpdatePuckTailnetShare(ctx, "alice-puck", &store.TailnetShare{}))
+
+	dir := d.cfg.RouterTailscaleDir()
+	for _, name := range []string{"", "alice-puck"} {
+		nodeDir := network.TailscaleNodeDir(dir, name)
+		require.NoError(t, os.MkdirAll(nodeDir, 0700))
+		require.NoError(t, os.WriteFile(filepath.Join(nodeDir, "tailscaled.state"), []byte("{}"), 0600))
+	}
+	// Left by a puck since unshared, which never logged in
+	require.NoError(t, os.MkdirAll(network.TailscaleNodeDir(dir, "gone"), 0700))
+
+	status := func(ctx context.Context) TailnetStatus {
+		resp := d.handleTailnetStatus(ctx)
+		require.True(t, resp.Success, resp.Error)
+		var st TailnetStatus
+		require.NoError(t, json.Unmarshal(resp.Data, &st))
+		return st
+	}
+
+	st := status(ctx)
+	assert.Equal(t, "example.ts.net", st.Tailnet)
+	assert.Equal(t, []TailnetNode{
+		{Role: "router", Dir: network.TailscaleNodeDir(dir, ""), LoggedIn: true},
+		{Role: "puck", Name: "alice-puck", Dir: network.TailscaleNodeDir(dir, "alice-puck"), LoggedIn: true},
+		{Role: "puck", Name: "gone", Dir: network.TailscaleNodeDir(dir, "gone"), Stale: true},
+	}, st.Nodes)
+
+	d.tidyTailscale(ctx)
+	st = status(ctx)
+	require.Len(t, st.Nodes, 2)
+	assert.NoDirExists(t, network.TailscaleNodeDir(dir, "gone"))
+
+	resp := d.handleTailnetStatus(withCaller(ctx, caller{User: "alice"}))
+	assert.False(t, resp.Success)
+}
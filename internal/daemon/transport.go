@@ -0,0 +1,180 @@
+package daemon
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/config"
+)
+
+// dialTimeout bounds how long connecting to a daemon may take
+const dialTimeout = 5 * time.Second
+
+// dialerForContext returns how to reach the daemon behind a context
+func dialerForContext(c config.Context) (func() (net.Conn, error), error) {
+	if err := c.Validate(); err != nil {
+		return nil, fmt.Errorf("context '%s': %w", c.Name, err)
+	}
+
+	switch c.Type {
+	case config.ContextSSH:
+		return func() (net.Conn, error) { return dialSSH(c.Host) }, nil
+	case config.ContextTCP:
+		tlsCfg, err := clientTLSConfig(c)
+		if err != nil {
+			return nil, fmt.Errorf("context '%s': %w", c.Name, err)
+		}
+		return func() (net.Conn, error) {
+			return tls.DialWithDialer(&net.Dialer{Timeout: dialTimeout}, "tcp", c.Address, tlsCfg)
+		}, nil
+	case config.ContextTailnet:
+		// The node's certificate is publicly trusted and the tailnet
+		// itself vouches for the caller, so there's nothing to configure
+		addr := tailnetAddress(c.Address)
+		tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+		return func() (net.Conn, error) {
+			return tls.DialWithDialer(&net.Dialer{Timeout: dialTimeout}, "tcp", addr, tlsCfg)
+		}, nil
+	default:
+		return func() (net.Conn, error) { return net.DialTimeout("unix", c.Socket, dialTimeout) }, nil
+	}
+}
+
+// tailnetAddress adds the HTTPS port to a tailnet address without one
+func tailnetAddress(addr string) string {
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return addr
+	}
+	return net.JoinHostPort(addr, "443")
+}
+
+// clientTLSConfig presents the context's client certificate and trusts
+// its CA, or the system roots when no CA is given
+func clientTLSConfig(c config.Context) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(c.ClientCert, c.ClientKey)
+	if err != nil {
+		return nil, fmt.Errorf("loading client certificate: %w", err)
+	}
+
+	tlsCfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if c.CACert != "" {
+		pool, err := loadCertPool(c.CACert)
+		if err != nil {
+			return nil, err
+		}
+		tlsCfg.RootCAs = pool
+	}
+	return tlsCfg, nil
+}
+
+// serverTLSConfig requires clients to present a certificate signed by cfg's client CA
+func serverTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.DaemonTLSCert, cfg.DaemonTLSKey)
+	if err != nil {
+		return nil, fmt.Errorf("loading daemon certificate: %w", err)
+	}
+	pool, err := loadCertPool(cfg.DaemonClientCA)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading CA certificate: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return pool, nil
+}
+
+// dialSSH reaches a remote daemon through `puck daemon dial-stdio` run over ssh
+func dialSSH(host string) (net.Conn, error) {
+	cmd := exec.Command("ssh", "-T", "-o", fmt.Sprintf("ConnectTimeout=%d", int(dialTimeout.Seconds())),
+		host, "--", "puck", "daemon", "dial-stdio")
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("running ssh: %w", err)
+	}
+
+	return &cmdConn{cmd: cmd, stdin: stdin, stdout: stdout, host: host}, nil
+}
+
+// cmdConn is a net.Conn over a child process's stdin and stdout.
+// Deadlines are not supported; the process ends when the conn is closed.
+type cmdConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	host   string
+}
+
+func (c *cmdConn) Read(b []byte) (int, error)  { return c.stdout.Read(b) }
+func (c *cmdConn) Write(b []byte) (int, error) { return c.stdin.Write(b) }
+
+func (c *cmdConn) Close() error {
+	c.stdin.Close()
+	c.cmd.Process.Kill()
+	c.cmd.Wait()
+	return nil
+}
+
+func (c *cmdConn) LocalAddr() net.Addr                { return cmdAddr("local") }
+func (c *cmdConn) RemoteAddr() net.Addr               { return cmdAddr(c.host) }
+func (c *cmdConn) SetDeadline(t time.Time) error      { return nil }
+func (c *cmdConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *cmdConn) SetWriteDeadline(t time.Time) error { return nil }
+
+type cmdAddr string
+
+func (a cmdAddr) Network() string { return "ssh" }
+func (a cmdAddr) String() string  { return string(a) }
+
+// DialStdio bridges stdin and stdout to the local daemon socket. It is the
+// remote end of ssh contexts.
+func DialStdio(socketPath string) error {
+	conn, err := net.DialTimeout("unix", socketPath, dialTimeout)
+	if err != nil {
+		return fmt.Errorf("connecting to daemon: %w", err)
+	}
+	defer conn.Close()
+
+	go func() {
+		io.Copy(conn, os.Stdin)
+		// Pass the client's end of input on to the daemon
+		if uc, ok := conn.(*net.UnixConn); ok {
+			uc.CloseWrite()
+		}
+	}()
+
+	_, err = io.Copy(os.Stdout, conn)
+	return err
+}
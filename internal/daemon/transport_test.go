@@ -0,0 +1,156 @@
+package daemon
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCert issues a certificate for cn, signed by parent (self-signed when
+// nil), and writes it and its key as PEM files in dir
+func writeCert(t *testing.T, dir, cn string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+		parent, parentKey = tmpl, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certPath := filepath.Join(dir, cn+".pem")
+	keyPath := filepath.Join(dir, cn+"-key.pem")
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return cert, key, certPath, keyPath
+}
+
+func TestTCPContext(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey, caPath, _ := writeCert(t, dir, "puck-ca", nil, nil)
+	_, _, serverCert, serverKey := writeCert(t, dir, "localhost", ca, caKey)
+	_, _, aliceCert, aliceKey := writeCert(t, dir, "alice", ca, caKey)
+
+	d := &Daemon{cfg: &config.Config{
+		DaemonTLSCert:  serverCert,
+		DaemonTLSKey:   serverKey,
+		DaemonClientCA: caPath,
+	}}
+	tlsCfg, err := serverTLSConfig(d.cfg)
+	require.NoError(t, err)
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", tlsCfg)
+	require.NoError(t, err)
+	defer ln.Close()
+
+	// Answer each request with the caller identified from the connection
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				var req Request
+				if err := json.NewDecoder(conn).Decode(&req); err != nil {
+					return
+				}
+				c := d.callerForConn(conn)
+				json.NewEncoder(conn).Encode(Response{Success: c.User == "alice", Error: "caller " + c.User})
+			}()
+		}
+	}()
+
+	t.Run("authenticates with the client certificate", func(t *testing.T) {
+		client, err := NewClientForContext(config.Context{
+			Name:       "homelab",
+			Type:       config.ContextTCP,
+			Address:    ln.Addr().String(),
+			CACert:     caPath,
+			ClientCert: aliceCert,
+			ClientKey:  aliceKey,
+		})
+		require.NoError(t, err)
+		assert.NoError(t, client.Ping())
+	})
+
+	t.Run("rejects clients without a trusted certificate", func(t *testing.T) {
+		otherCA, otherKey, _, _ := writeCert(t, t.TempDir(), "other-ca", nil, nil)
+		_, _, malloryCert, malloryKey := writeCert(t, dir, "mallory", otherCA, otherKey)
+
+		client, err := NewClientForContext(config.Context{
+			Name:       "homelab",
+			Type:       config.ContextTCP,
+			Address:    ln.Addr().String(),
+			CACert:     caPath,
+			ClientCert: malloryCert,
+			ClientKey:  malloryKey,
+		})
+		require.NoError(t, err)
+		assert.Error(t, client.Ping())
+	})
+}
+
+func TestNewClientForContext(t *testing.T) {
+	t.Run("uses the socket of unix contexts", func(t *testing.T) {
+		client, err := NewClientForContext(config.Context{Name: "default", Type: config.ContextUnix, Socket: "/tmp/puckd.sock"})
+		require.NoError(t, err)
+		assert.Equal(t, "/tmp/puckd.sock", client.socketPath)
+	})
+
+	t.Run("rejects incomplete contexts", func(t *testing.T) {
+		_, err := NewClientForContext(config.Context{Name: "homelab", Type: config.ContextSSH})
+		assert.ErrorContains(t, err, "context 'homelab'")
+	})
+
+	t.Run("needs only an address for tailnet contexts", func(t *testing.T) {
+		_, err := NewClientForContext(config.Context{Name: "office", Type: config.ContextTailnet, Address: "puck-api.tail1234.ts.net"})
+		assert.NoError(t, err)
+		assert.Equal(t, "puck-api.tail1234.ts.net:443", tailnetAddress("puck-api.tail1234.ts.net"))
+		assert.Equal(t, "puck-api.tail1234.ts.net:8443", tailnetAddress("puck-api.tail1234.ts.net:8443"))
+	})
+
+	t.Run("reports missing client certificates", func(t *testing.T) {
+		_, err := NewClientForContext(config.Context{
+			Name: "homelab", Type: config.ContextTCP, Address: "homelab:7443",
+			ClientCert: "/nonexistent/cert.pem", ClientKey: "/nonexistent/key.pem",
+		})
+		assert.ErrorContains(t, err, "client certificate")
+	})
+}
@@ -0,0 +1,67 @@
+package daemon
+
+import (
+	"context"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/sandwich-labs/puck/internal/hooks"
+)
+
+// expiryInterval is how often pucks are checked for a TTL that has run out
+const expiryInterval = time.Minute
+
+// expirePucks destroys pucks once their TTL runs out
+func (d *Daemon) expirePucks(ctx context.Context) {
+	ticker := time.NewTicker(expiryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if d.inMaintenance() {
+				continue
+			}
+			d.destroyExpired(ctx)
+		}
+	}
+}
+
+// destroyExpired destroys the pucks whose TTL has run out, archiving
+// those that asked for a final snapshot first. A puck whose snapshot fails
+// is kept, to be tried again at the next check.
+func (d *Daemon) destroyExpired(ctx context.Context) {
+	pucks, err := d.manager.Expired(ctx, time.Now())
+	if err != nil {
+		log.Warn("Failed to list expired pucks", "error", err)
+		return
+	}
+
+	for _, p := range pucks {
+		if ctx.Err() != nil {
+			return
+		}
+		if p.ExpirySnapshot {
+			dir, err := d.manager.FinalSnapshot(ctx, p)
+			if err != nil {
+				log.Warn("Failed to take final snapshot of expired puck; keeping it", "name", p.Name, "error", err)
+				continue
+			}
+			log.Info("Took final snapshot of expired puck", "name", p.Name, "dir", dir)
+		}
+
+		d.stopSyncs(p.Name)
+		if err := d.manager.Destroy(ctx, p.Name, true); err != nil {
+			log.Warn("Failed to destroy expired puck", "name", p.Name, "error", err)
+			if p, getErr := d.manager.Get(ctx, p.Name); getErr == nil {
+				d.startSyncs(p)
+			}
+			continue
+		}
+		d.stopAgent(p.Name)
+		log.Info("Destroyed puck whose TTL ran out", "name", p.Name, "expired", p.ExpiresAt)
+		d.fire(hooks.EventPuckDestroyed, p.Name, "", nil)
+	}
+}
@@ -0,0 +1,67 @@
+package daemon
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/hooks"
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDestroyExpired(t *testing.T) {
+	d := setupAuthDaemon(t)
+	mock := podman.NewMockClient()
+	d.manager = puck.NewManager(d.cfg, mock, d.store)
+	d.hooks = hooks.NewRunner(t.TempDir(), time.Second)
+	ctx := context.Background()
+
+	past := time.Now().Add(-time.Minute)
+	for _, p := range []*store.Puck{
+		{ID: "e1", Name: "expired", ExpiresAt: past},
+		{ID: "e2", Name: "archived", ExpiresAt: past, ExpirySnapshot: true},
+		{ID: "e3", Name: "fresh", ExpiresAt: time.Now().Add(time.Hour)},
+		{ID: "e4", Name: "pooled", ExpiresAt: past, Pool: "node"},
+	} {
+		p.Image = "fedora"
+		p.Owner = "alice"
+		p.Status = store.StatusStopped
+		p.VolumeDir = filepath.Join(t.TempDir(), p.Name)
+		require.NoError(t, os.MkdirAll(p.VolumeDir, 0755))
+		require.NoError(t, d.store.CreatePuck(ctx, p))
+	}
+	mock.IsRunningFunc = func(ctx context.Context, nameOrID string) (bool, error) { return false, nil }
+
+	d.destroyExpired(ctx)
+
+	for _, name := range []string{"expired", "archived"} {
+		_, err := d.store.GetPuck(ctx, name)
+		assert.ErrorIs(t, err, store.ErrNotFound, name)
+	}
+	for _, name := range []string{"fresh", "pooled"} {
+		_, err := d.store.GetPuck(ctx, name)
+		assert.NoError(t, err, name)
+	}
+
+	archives, err := filepath.Glob(filepath.Join(d.cfg.ExpiredDir(), "archived-*", puck.ExpiredVolumes))
+	require.NoError(t, err)
+	assert.Len(t, archives, 1)
+
+	t.Run("a puck whose final snapshot fails is kept", func(t *testing.T) {
+		// Its volumes are gone, so can't be archived
+		p := &store.Puck{ID: "e5", Name: "stuck", Image: "fedora", Owner: "alice", Status: store.StatusStopped,
+			VolumeDir: filepath.Join(t.TempDir(), "missing"), ExpiresAt: past, ExpirySnapshot: true}
+		require.NoError(t, d.store.CreatePuck(ctx, p))
+
+		d.destroyExpired(ctx)
+
+		_, err := d.store.GetPuck(ctx, "stuck")
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,162 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/sandwich-labs/puck/internal/network"
+)
+
+// funnelHostname is the name of the Funnel share tunnel's tailnet node
+const funnelHostname = "puck-share"
+
+// tunnelStartTimeout bounds how long creating a share link waits for the
+// tunnel to open; providers give up on opening after a minute
+const tunnelStartTimeout = time.Minute + 5*time.Second
+
+// tunnelRetry is how long to wait before reopening a share tunnel that
+// closed or failed to open
+const tunnelRetry = 30 * time.Second
+
+// shareTunnel returns the provider share_tunnel names, or nil for none
+func shareTunnel(cfg *config.Config) network.TunnelProvider {
+	switch cfg.ShareTunnel {
+	case "funnel":
+		return &network.Funnel{
+			Hostname: funnelHostname,
+			Dir:      filepath.Join(cfg.TailscaleDir(), funnelHostname),
+			Logf: func(format string, args ...any) {
+				log.Info("Share tunnel: " + fmt.Sprintf(format, args...))
+			},
+		}
+	case "cloudflared":
+		return network.Cloudflared()
+	case "localtunnel":
+		return network.Localtunnel()
+	case "command":
+		return &network.CommandTunnel{Provider: "command", Command: cfg.ShareTunnelCommand}
+	}
+	return nil
+}
+
+// freeLoopbackPort returns a loopback port nothing listens on, for the
+// share listener a tunnel is pointed at
+func freeLoopbackPort() (int, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("finding a port for share links: %w", err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port, nil
+}
+
+// runTunnel keeps a share tunnel open in front of the router's share
+// listener while there are share links, reopening it whenever it closes,
+// until ctx is done. The listener serves nothing but share links, so the
+// tunnel never makes the rest of the router public.
+func (d *Daemon) runTunnel(ctx context.Context, provider network.TunnelProvider) {
+	changed := d.router.SharesChanged()
+	for {
+		for !d.router.HasShares() {
+			select {
+			case <-ctx.Done():
+				return
+			case <-changed:
+			}
+		}
+
+		target := fmt.Sprintf("http://127.0.0.1:%d", d.router.SharePort())
+		tun, err := provider.Open(ctx, target)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Warn("Failed to open share tunnel", "provider", provider.Name(), "error", err)
+		} else {
+			log.Info("Share tunnel open", "provider", provider.Name(), "url", tun.URL())
+			d.setTunnel(tun)
+			if !d.holdTunnel(ctx, provider, tun) {
+				return
+			}
+			if !d.router.HasShares() {
+				continue
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(tunnelRetry):
+		}
+	}
+}
+
+// holdTunnel keeps an open tunnel until it closes or the last share link
+// goes, returning false once ctx is done
+func (d *Daemon) holdTunnel(ctx context.Context, provider network.TunnelProvider, tun network.Tunnel) bool {
+	closed := make(chan error, 1)
+	go func() { closed <- tun.Wait() }()
+	for {
+		select {
+		case <-ctx.Done():
+			d.setTunnel(nil)
+			tun.Close()
+			return false
+		case <-d.router.SharesChanged():
+			if d.router.HasShares() {
+				continue
+			}
+			d.setTunnel(nil)
+			tun.Close()
+			log.Info("Share tunnel closed, as nothing is shared", "provider", provider.Name())
+			return true
+		case err := <-closed:
+			d.setTunnel(nil)
+			log.Warn("Share tunnel closed", "provider", provider.Name(), "error", err)
+			return true
+		}
+	}
+}
+
+// awaitTunnel waits for the share tunnel to open, as it does once there is
+// a link to serve, until it has had tunnelStartTimeout or ctx is done
+func (d *Daemon) awaitTunnel(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, tunnelStartTimeout)
+	defer cancel()
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for d.shareBaseURL() == "" {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Daemon) setTunnel(tun network.Tunnel) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.tunnel = tun
+}
+
+// shareBaseURL returns the public URL share links are served from:
+// share_url, else the share tunnel's while it is open, else the TLS
+// listener's. A tunnel's URL may change each time it opens, so links are
+// given the one current when they are listed.
+func (d *Daemon) shareBaseURL() string {
+	if d.cfg.ShareURL == "" && d.cfg.ShareTunnel != "" {
+		d.mu.RLock()
+		defer d.mu.RUnlock()
+		if d.tunnel == nil {
+			return ""
+		}
+		return d.tunnel.URL()
+	}
+	return d.cfg.ShareBaseURL()
+}
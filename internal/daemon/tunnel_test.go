@@ -0,0 +1,118 @@
+package daemon
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/sandwich-labs/puck/internal/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTunnel is open until closed
+type fakeTunnel struct {
+	url    string
+	target string
+	closed chan struct{}
+}
+
+func (t *fakeTunnel) URL() string { return t.url }
+func (t *fakeTunnel) Wait() error {
+	<-t.closed
+	return nil
+}
+func (t *fakeTunnel) Close() error {
+	close(t.closed)
+	return nil
+}
+
+type fakeTunnelProvider struct {
+	opened chan *fakeTunnel
+}
+
+func (p *fakeTunnelProvider) Name() string { return "fake" }
+func (p *fakeTunnelProvider) Open(ctx context.Context, target string) (network.Tunnel, error) {
+	t := &fakeTunnel{url: "https://fake.example.com", target: target, closed: make(chan struct{})}
+	p.opened <- t
+	return t, nil
+}
+
+func TestShareBaseURL(t *testing.T) {
+	t.Run("prefers share_url over a tunnel", func(t *testing.T) {
+		d := &Daemon{cfg: &config.Config{ShareURL: "https://box.example.ts.net", ShareTunnel: "cloudflared"}}
+		d.tunnel = &fakeTunnel{url: "https://fake.example.com"}
+		assert.Equal(t, "https://box.example.ts.net", d.shareBaseURL())
+	})
+
+	t.Run("has none while the tunnel is closed", func(t *testing.T) {
+		d := &Daemon{cfg: &config.Config{ShareTunnel: "cloudflared", RouterTLSPort: 8443, RouterDomain: "localhost"}}
+		assert.Empty(t, d.shareBaseURL(), "not the TLS listener, which may not be public")
+	})
+
+	t.Run("uses the open tunnel", func(t *testing.T) {
+		d := &Daemon{cfg: &config.Config{ShareTunnel: "cloudflared"}, router: network.NewRouter(8080, "localhost")}
+		require.NoError(t, d.router.AddShare("abc.sig", "web", time.Now().Add(time.Hour)))
+		provider := &fakeTunnelProvider{opened: make(chan *fakeTunnel)}
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			d.runTunnel(ctx, provider)
+			close(done)
+		}()
+
+		tun := <-provider.opened
+		assert.Eventually(t, func() bool { return d.shareBaseURL() == tun.url }, time.Second, 10*time.Millisecond)
+
+		cancel()
+		<-done
+		assert.Empty(t, d.shareBaseURL())
+		select {
+		case <-tun.closed:
+		default:
+			require.Fail(t, "tunnel left open")
+		}
+	})
+	t.Run("opens only on the share listener while there are links", func(t *testing.T) {
+		router := network.NewRouter(8080, "localhost")
+		router.SetSharePort(18081)
+		d := &Daemon{cfg: &config.Config{ShareTunnel: "cloudflared"}, router: router}
+		provider := &fakeTunnelProvider{opened: make(chan *fakeTunnel)}
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			d.runTunnel(ctx, provider)
+			close(done)
+		}()
+
+		select {
+		case <-provider.opened:
+			require.Fail(t, "tunnel opened with nothing shared")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		require.NoError(t, router.AddShare("abc.sig", "web", time.Now().Add(time.Hour)))
+		tun := <-provider.opened
+		assert.Equal(t, "http://127.0.0.1:18081", tun.target)
+		assert.Eventually(t, func() bool { return d.shareBaseURL() == tun.url }, time.Second, 10*time.Millisecond)
+
+		require.NoError(t, router.RemoveShare("abc.sig"))
+		select {
+		case <-tun.closed:
+		case <-time.After(time.Second):
+			require.Fail(t, "tunnel left open with nothing shared")
+		}
+		assert.Empty(t, d.shareBaseURL())
+
+		require.NoError(t, router.AddShare("def.sig", "web", time.Now().Add(time.Hour)))
+		tun = <-provider.opened
+		cancel()
+		<-done
+		select {
+		case <-tun.closed:
+		default:
+			require.Fail(t, "tunnel left open")
+		}
+	})
+}
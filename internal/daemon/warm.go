@@ -0,0 +1,114 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/sandwich-labs/puck/internal/config"
+)
+
+// warmCheckInterval is how often warm images are checked for ones that
+// are missing or due a refresh
+const warmCheckInterval = 10 * time.Minute
+
+// warmRefreshEvery keeps a refresh to once per warm_window
+const warmRefreshEvery = 20 * time.Hour
+
+// warmImages keeps the images in warm_images pulled: missing ones as soon
+// as they are noticed, and all of them once a day during warm_window
+func (d *Daemon) warmImages(ctx context.Context) {
+	window, err := config.ParseTimeWindow(d.cfg.WarmWindow)
+	if err != nil {
+		log.Warn("Not keeping warm images", "error", err)
+		return
+	}
+	ticker := time.NewTicker(warmCheckInterval)
+	defer ticker.Stop()
+
+	var refreshed time.Time
+	for {
+		now := time.Now()
+		refresh := window.Contains(now) && now.Sub(refreshed) >= warmRefreshEvery
+		if refresh {
+			refreshed = now
+		}
+		for _, pull := range d.manager.WarmImages(ctx, refresh) {
+			if pull.Error != "" {
+				log.Warn("Failed to pull warm image", "image", pull.Image, "error", pull.Error)
+				continue
+			}
+			log.Info("Pulled warm image", "image", pull.Image)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *Daemon) handleImages(ctx context.Context) Response {
+	images, err := d.manager.Images(ctx)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	respData, _ := json.Marshal(images)
+	return Response{Success: true, Data: respData}
+}
+
+// handleImageTree reports the layers shared by the images of every puck,
+// since they share disk whoever owns them, but names only the pucks the
+// caller may see
+func (d *Daemon) handleImageTree(ctx context.Context) Response {
+	tree, err := d.manager.ImageTree(ctx)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	if c := callerFrom(ctx); !c.Admin {
+		pucks, err := d.manager.List(ctx)
+		if err != nil {
+			return errorResponse(err)
+		}
+		visible := make(map[string]bool)
+		for _, p := range filterOwned(pucks, c) {
+			visible[p.Name] = true
+		}
+		for i := range tree.Images {
+			img := &tree.Images[i]
+			var names []string
+			for _, name := range img.Pucks {
+				if visible[name] {
+					names = append(names, name)
+				} else {
+					img.Others++
+				}
+			}
+			img.Pucks = names
+		}
+	}
+
+	respData, _ := json.Marshal(tree)
+	return Response{Success: true, Data: respData}
+}
+
+func (d *Daemon) handleScan(ctx context.Context, data json.RawMessage) Response {
+	var params struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(data, &params); err != nil {
+		return errorResponse(err)
+	}
+
+	result, err := d.manager.Scan(ctx, params.Name)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	respData, _ := json.Marshal(result)
+	return Response{Success: true, Data: respData}
+}
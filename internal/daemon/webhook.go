@@ -0,0 +1,164 @@
+package daemon
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/sandwich-labs/puck/internal/config"
+)
+
+// webhookTimeout bounds how long a triggered action may run
+const webhookTimeout = 10 * time.Minute
+
+// maxWebhookBody caps the request body read for signature checks
+const maxWebhookBody = 1 << 20
+
+// webhookServer triggers predefined daemon actions from authenticated
+// HTTP requests, e.g. from CI pipelines or chatops bots
+type webhookServer struct {
+	secret   string
+	webhooks map[string]config.WebhookConfig
+	run      func(ctx context.Context, req *Request) Response // daemon dispatch
+
+	mu   sync.Mutex
+	busy map[string]bool // webhooks with an action in flight
+	wg   sync.WaitGroup
+}
+
+// webhookResponse is the JSON body returned to webhook callers
+type webhookResponse struct {
+	Webhook string `json:"webhook"`
+	Action  string `json:"action,omitempty"`
+	Puck    string `json:"puck,omitempty"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+func newWebhookServer(secret string, webhooks map[string]config.WebhookConfig, run func(ctx context.Context, req *Request) Response) *webhookServer {
+	return &webhookServer{
+		secret:   secret,
+		webhooks: webhooks,
+		run:      run,
+		busy:     make(map[string]bool),
+	}
+}
+
+// ServeHTTP handles POST /webhooks/<name>. The action runs in the
+// background and the request returns 202 once it has been accepted.
+func (s *webhookServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, ok := strings.CutPrefix(r.URL.Path, "/webhooks/")
+	if !ok || name == "" || strings.Contains(name, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeWebhookResponse(w, http.StatusMethodNotAllowed, webhookResponse{Webhook: name, Status: "error", Error: "method not allowed"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+	if err != nil {
+		writeWebhookResponse(w, http.StatusBadRequest, webhookResponse{Webhook: name, Status: "error", Error: "reading body"})
+		return
+	}
+
+	// Authenticate before revealing whether the webhook exists
+	if !s.authorized(r, body) {
+		writeWebhookResponse(w, http.StatusUnauthorized, webhookResponse{Webhook: name, Status: "error", Error: "unauthorized"})
+		return
+	}
+
+	wh, ok := s.webhooks[name]
+	if !ok {
+		writeWebhookResponse(w, http.StatusNotFound, webhookResponse{Webhook: name, Status: "error", Error: "unknown webhook"})
+		return
+	}
+
+	s.mu.Lock()
+	if s.busy[name] {
+		s.mu.Unlock()
+		writeWebhookResponse(w, http.StatusConflict, webhookResponse{Webhook: name, Action: wh.Action, Puck: wh.Puck, Status: "error", Error: "already running"})
+		return
+	}
+	s.busy[name] = true
+	s.mu.Unlock()
+
+	log.Info("Webhook triggered", "webhook", name, "action", wh.Action, "puck", wh.Puck, "remote", r.RemoteAddr)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() {
+			s.mu.Lock()
+			delete(s.busy, name)
+			s.mu.Unlock()
+		}()
+		s.trigger(name, wh)
+	}()
+
+	writeWebhookResponse(w, http.StatusAccepted, webhookResponse{Webhook: name, Action: wh.Action, Puck: wh.Puck, Status: "accepted"})
+}
+
+// authorized accepts either a bearer token or a GitHub-style
+// X-Hub-Signature-256 HMAC of the body, both using the shared secret
+func (s *webhookServer) authorized(r *http.Request, body []byte) bool {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return subtle.ConstantTimeCompare([]byte(token), []byte(s.secret)) == 1
+	}
+
+	if sig, ok := strings.CutPrefix(r.Header.Get("X-Hub-Signature-256"), "sha256="); ok {
+		got, err := hex.DecodeString(sig)
+		if err != nil {
+			return false
+		}
+		mac := hmac.New(sha256.New, []byte(s.secret))
+		mac.Write(body)
+		return hmac.Equal(got, mac.Sum(nil))
+	}
+
+	return false
+}
+
+// trigger runs a webhook's action through the daemon's request dispatch
+func (s *webhookServer) trigger(name string, wh config.WebhookConfig) {
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+
+	data, _ := json.Marshal(map[string]string{"name": wh.Puck, "image": wh.Image})
+
+	actions := []string{wh.Action}
+	if wh.Action == "restart" {
+		actions = []string{"stop", "start"}
+	}
+
+	for _, action := range actions {
+		resp := s.run(ctx, &Request{Action: action, Data: data})
+		if !resp.Success {
+			log.Warn("Webhook action failed", "webhook", name, "action", action, "puck", wh.Puck, "error", resp.Error)
+			return
+		}
+	}
+	log.Info("Webhook action completed", "webhook", name, "action", wh.Action, "puck", wh.Puck)
+}
+
+// Wait blocks until all triggered actions have finished
+func (s *webhookServer) Wait() {
+	s.wg.Wait()
+}
+
+func writeWebhookResponse(w http.ResponseWriter, status int, resp webhookResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
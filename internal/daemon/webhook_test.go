@@ -0,0 +1,172 @@
+package daemon
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingRunner captures the daemon requests a webhook triggers
+type recordingRunner struct {
+	mu       sync.Mutex
+	requests []Request
+	fail     string // action that should fail
+	block    chan struct{}
+}
+
+func (r *recordingRunner) run(ctx context.Context, req *Request) Response {
+	if r.block != nil {
+		<-r.block
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, *req)
+	if req.Action == r.fail {
+		return Response{Success: false, Error: "boom"}
+	}
+	return Response{Success: true}
+}
+
+func (r *recordingRunner) actions() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var actions []string
+	for _, req := range r.requests {
+		actions = append(actions, req.Action)
+	}
+	return actions
+}
+
+func setupWebhookServer(runner *recordingRunner) *webhookServer {
+	return newWebhookServer("s3cret", map[string]config.WebhookConfig{
+		"refresh": {Action: "recreate", Puck: "staging", Image: "app:latest"},
+		"bounce":  {Action: "restart", Puck: "web"},
+	}, runner.run)
+}
+
+func postWebhook(s *webhookServer, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestWebhookServer(t *testing.T) {
+	bearer := map[string]string{"Authorization": "Bearer s3cret"}
+
+	t.Run("triggers action with bearer token", func(t *testing.T) {
+		runner := &recordingRunner{}
+		s := setupWebhookServer(runner)
+
+		rec := postWebhook(s, "/webhooks/refresh", "", bearer)
+		s.Wait()
+
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+		var resp webhookResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, "accepted", resp.Status)
+		assert.Equal(t, "recreate", resp.Action)
+
+		require.Len(t, runner.requests, 1)
+		assert.Equal(t, "recreate", runner.requests[0].Action)
+		var params map[string]string
+		require.NoError(t, json.Unmarshal(runner.requests[0].Data, &params))
+		assert.Equal(t, "staging", params["name"])
+		assert.Equal(t, "app:latest", params["image"])
+	})
+
+	t.Run("accepts HMAC signature", func(t *testing.T) {
+		runner := &recordingRunner{}
+		s := setupWebhookServer(runner)
+
+		body := `{"ref":"refs/heads/main"}`
+		mac := hmac.New(sha256.New, []byte("s3cret"))
+		mac.Write([]byte(body))
+		sig := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+		rec := postWebhook(s, "/webhooks/refresh", body, map[string]string{"X-Hub-Signature-256": sig})
+		s.Wait()
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+		assert.Equal(t, []string{"recreate"}, runner.actions())
+	})
+
+	t.Run("rejects bad credentials", func(t *testing.T) {
+		runner := &recordingRunner{}
+		s := setupWebhookServer(runner)
+
+		for _, headers := range []map[string]string{
+			nil,
+			{"Authorization": "Bearer wrong"},
+			{"X-Hub-Signature-256": "sha256=deadbeef"},
+		} {
+			rec := postWebhook(s, "/webhooks/refresh", "{}", headers)
+			assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		}
+		// Unknown webhooks are indistinguishable without credentials
+		rec := postWebhook(s, "/webhooks/missing", "", nil)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		s.Wait()
+		assert.Empty(t, runner.actions())
+	})
+
+	t.Run("returns 404 for unknown webhook", func(t *testing.T) {
+		s := setupWebhookServer(&recordingRunner{})
+		rec := postWebhook(s, "/webhooks/missing", "", bearer)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("requires POST", func(t *testing.T) {
+		s := setupWebhookServer(&recordingRunner{})
+		req := httptest.NewRequest(http.MethodGet, "/webhooks/refresh", nil)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	})
+
+	t.Run("restart stops then starts", func(t *testing.T) {
+		runner := &recordingRunner{}
+		s := setupWebhookServer(runner)
+
+		postWebhook(s, "/webhooks/bounce", "", bearer)
+		s.Wait()
+		assert.Equal(t, []string{"stop", "start"}, runner.actions())
+	})
+
+	t.Run("restart does not start after failed stop", func(t *testing.T) {
+		runner := &recordingRunner{fail: "stop"}
+		s := setupWebhookServer(runner)
+
+		postWebhook(s, "/webhooks/bounce", "", bearer)
+		s.Wait()
+		assert.Equal(t, []string{"stop"}, runner.actions())
+	})
+
+	t.Run("rejects concurrent runs of the same webhook", func(t *testing.T) {
+		runner := &recordingRunner{block: make(chan struct{})}
+		s := setupWebhookServer(runner)
+
+		first := postWebhook(s, "/webhooks/refresh", "", bearer)
+		second := postWebhook(s, "/webhooks/refresh", "", bearer)
+		close(runner.block)
+		s.Wait()
+
+		assert.Equal(t, http.StatusAccepted, first.Code)
+		assert.Equal(t, http.StatusConflict, second.Code)
+		assert.Equal(t, []string{"recreate"}, runner.actions())
+	})
+}
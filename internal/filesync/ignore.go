@@ -0,0 +1,100 @@
+// Package filesync mirrors a host directory into a puck's volume and
+// keeps it in step as files change, for hosts where bind mounts are slow
+package filesync
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFile lists patterns to leave out of a sync, in the source's root
+const IgnoreFile = ".puckignore"
+
+// Ignore decides which paths of a source are left out of its mirror,
+// with a subset of .gitignore syntax: a leading / anchors a pattern to
+// the root, a trailing / matches only directories, a leading ! brings a
+// path back, and the last matching pattern wins.
+type Ignore struct {
+	patterns []pattern
+}
+
+type pattern struct {
+	glob     string
+	anchored bool // matched against the whole path rather than any name
+	dirOnly  bool
+	negate   bool
+}
+
+// NewIgnore parses patterns, skipping blank lines and # comments
+func NewIgnore(lines []string) *Ignore {
+	ig := &Ignore{}
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var p pattern
+		if strings.HasPrefix(line, "!") {
+			p.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			p.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			p.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		p.glob = line
+		ig.patterns = append(ig.patterns, p)
+	}
+	return ig
+}
+
+// LoadIgnore reads the source's IgnoreFile, if any, after the given
+// patterns
+func LoadIgnore(source string, patterns []string) (*Ignore, error) {
+	lines := append([]string{}, patterns...)
+	f, err := os.Open(filepath.Join(source, IgnoreFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return NewIgnore(lines), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return NewIgnore(lines), scanner.Err()
+}
+
+// Match reports whether rel, a slash-separated path relative to the
+// source, is ignored. Children of ignored directories are never asked
+// about, as the directory is skipped whole.
+func (ig *Ignore) Match(rel string, isDir bool) bool {
+	ignored := false
+	for _, p := range ig.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		subject := path.Base(rel)
+		if p.anchored {
+			subject = rel
+		}
+		if ok, _ := path.Match(p.glob, subject); ok {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,58 @@
+package filesync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIgnore(t *testing.T) {
+	ig := NewIgnore([]string{
+		"# build output",
+		"node_modules/",
+		"*.log",
+		"!keep.log",
+		"/dist",
+		"docs/*.pdf",
+		"",
+	})
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"node_modules", true, true},
+		{"web/node_modules", true, true},
+		{"node_modules", false, false}, // a file of that name
+		{"server.log", false, true},
+		{"logs/server.log", false, true},
+		{"keep.log", false, false},
+		{"dist", true, true},
+		{"web/dist", true, false}, // anchored to the root
+		{"docs/guide.pdf", false, true},
+		{"docs/old/guide.pdf", false, false},
+		{"main.go", false, false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, ig.Match(tt.path, tt.isDir), tt.path)
+	}
+}
+
+func TestLoadIgnore(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, IgnoreFile), []byte("target/\n!important.tmp\n"), 0644))
+
+	ig, err := LoadIgnore(dir, []string{"*.tmp"})
+	require.NoError(t, err)
+	assert.True(t, ig.Match("target", true))
+	assert.True(t, ig.Match("scratch.tmp", false))
+	assert.False(t, ig.Match("important.tmp", false), "the file's patterns come after the given ones")
+
+	ig, err = LoadIgnore(t.TempDir(), nil)
+	require.NoError(t, err)
+	assert.False(t, ig.Match("anything", false))
+}
@@ -0,0 +1,189 @@
+package filesync
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Stats counts what a mirror pass changed
+type Stats struct {
+	Copied  int   `json:"copied"`  // files and links written
+	Removed int   `json:"removed"` // paths gone from the source
+	Bytes   int64 `json:"bytes"`   // bytes copied
+}
+
+// Mirror makes dest a copy of source, leaving out ignored paths. Files are
+// copied when their size or modification time differs, and paths missing
+// from the source are removed from dest unless they are ignored, so
+// output written there under an ignored path survives. Links are copied
+// as links. dest must already exist.
+func Mirror(source, dest string, ignore *Ignore) (Stats, error) {
+	stats, _, err := mirror(source, dest, ignore)
+	return stats, err
+}
+
+// mirror is Mirror, also returning the source directories it walked
+func mirror(source, dest string, ignore *Ignore) (Stats, []string, error) {
+	var stats Stats
+	if info, err := os.Stat(dest); err != nil || !info.IsDir() {
+		return stats, nil, fmt.Errorf("sync destination %s is missing", dest)
+	}
+
+	seen := make(map[string]bool)
+	var dirs []string
+	err := filepath.WalkDir(source, func(path string, d fs.DirEntry, err error) error {
+		// Paths deleted during the walk are picked up by the next pass
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(source, path)
+		if rel == "." {
+			dirs = append(dirs, path)
+			return nil
+		}
+		if ignore.Match(filepath.ToSlash(rel), d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		seen[rel] = true
+
+		target := filepath.Join(dest, rel)
+		info, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch {
+		case d.IsDir():
+			dirs = append(dirs, path)
+			return syncDir(target, info)
+		case d.Type()&fs.ModeSymlink != 0:
+			copied, err := syncLink(path, target)
+			if copied {
+				stats.Copied++
+			}
+			return err
+		case d.Type().IsRegular():
+			copied, err := syncFile(path, target, info)
+			if copied {
+				stats.Copied++
+				stats.Bytes += info.Size()
+			}
+			return err
+		}
+		// Sockets, devices and pipes don't carry over
+		return nil
+	})
+	if err != nil {
+		return stats, dirs, err
+	}
+
+	err = filepath.WalkDir(dest, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dest, path)
+		if rel == "." || seen[rel] {
+			return nil
+		}
+		if !ignore.Match(filepath.ToSlash(rel), d.IsDir()) {
+			if err := os.RemoveAll(path); err != nil {
+				return err
+			}
+			stats.Removed++
+		}
+		if d.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return stats, dirs, err
+}
+
+// syncDir makes target a directory with the source's permissions
+func syncDir(target string, info fs.FileInfo) error {
+	existing, err := os.Lstat(target)
+	if err == nil && !existing.IsDir() {
+		if err := os.Remove(target); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(target, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Chmod(target, info.Mode().Perm())
+}
+
+// syncLink makes target a link to where the source link points
+func syncLink(source, target string) (bool, error) {
+	dest, err := os.Readlink(source)
+	if err != nil {
+		return false, err
+	}
+	if existing, err := os.Readlink(target); err == nil && existing == dest {
+		return false, nil
+	}
+	if err := os.RemoveAll(target); err != nil {
+		return false, err
+	}
+	return true, os.Symlink(dest, target)
+}
+
+// syncFile copies source over target unless they already match, writing
+// a temporary file first so the puck never sees a partial one
+func syncFile(source, target string, info fs.FileInfo) (bool, error) {
+	if existing, err := os.Lstat(target); err == nil {
+		if existing.Mode().IsRegular() && existing.Size() == info.Size() && existing.ModTime().Equal(info.ModTime()) {
+			if existing.Mode().Perm() != info.Mode().Perm() {
+				return false, os.Chmod(target, info.Mode().Perm())
+			}
+			return false, nil
+		}
+		if existing.IsDir() {
+			if err := os.RemoveAll(target); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	in, err := os.Open(source)
+	if err != nil {
+		// Deleted since the walk listed it; the next pass removes it
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer in.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".puck-sync-*")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return false, err
+	}
+	if err := tmp.Close(); err != nil {
+		return false, err
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return false, err
+	}
+	if err := os.Chtimes(tmp.Name(), info.ModTime(), info.ModTime()); err != nil {
+		return false, err
+	}
+	return true, os.Rename(tmp.Name(), target)
+}
@@ -0,0 +1,72 @@
+package filesync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFile writes content to a file under dir, creating its parents
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+}
+
+func TestMirror(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	writeFile(t, src, "main.go", "package main")
+	writeFile(t, src, "web/index.html", "<h1>hi</h1>")
+	writeFile(t, src, "node_modules/left-pad/index.js", "module.exports = 1")
+	require.NoError(t, os.Symlink("web/index.html", filepath.Join(src, "index.html")))
+	ignore := NewIgnore([]string{"node_modules/"})
+
+	t.Run("copies files and links", func(t *testing.T) {
+		stats, err := Mirror(src, dst, ignore)
+		require.NoError(t, err)
+		assert.Equal(t, 3, stats.Copied)
+
+		data, err := os.ReadFile(filepath.Join(dst, "web/index.html"))
+		require.NoError(t, err)
+		assert.Equal(t, "<h1>hi</h1>", string(data))
+		link, err := os.Readlink(filepath.Join(dst, "index.html"))
+		require.NoError(t, err)
+		assert.Equal(t, "web/index.html", link)
+		assert.NoDirExists(t, filepath.Join(dst, "node_modules"))
+	})
+
+	t.Run("copies only what changed", func(t *testing.T) {
+		stats, err := Mirror(src, dst, ignore)
+		require.NoError(t, err)
+		assert.Equal(t, Stats{}, stats)
+
+		writeFile(t, src, "main.go", "package main // changed")
+		later := time.Now().Add(time.Second)
+		require.NoError(t, os.Chtimes(filepath.Join(src, "main.go"), later, later))
+		stats, err = Mirror(src, dst, ignore)
+		require.NoError(t, err)
+		assert.Equal(t, 1, stats.Copied)
+		assert.Equal(t, int64(len("package main // changed")), stats.Bytes)
+	})
+
+	t.Run("removes deleted paths but keeps ignored ones", func(t *testing.T) {
+		writeFile(t, dst, "node_modules/installed-in-puck.js", "")
+		require.NoError(t, os.RemoveAll(filepath.Join(src, "web")))
+
+		stats, err := Mirror(src, dst, ignore)
+		require.NoError(t, err)
+		assert.Equal(t, 1, stats.Removed)
+		assert.NoDirExists(t, filepath.Join(dst, "web"))
+		assert.FileExists(t, filepath.Join(dst, "node_modules/installed-in-puck.js"))
+	})
+
+	t.Run("needs the destination to exist", func(t *testing.T) {
+		_, err := Mirror(src, filepath.Join(dst, "missing"), ignore)
+		assert.ErrorContains(t, err, "missing")
+	})
+}
@@ -0,0 +1,170 @@
+package filesync
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// settleDelay is how long a source must be quiet before changes are
+// synced, so a checkout or build touching many files syncs once
+const settleDelay = 300 * time.Millisecond
+
+// Sync states
+const (
+	StateWatching = "watching"
+	StateSyncing  = "syncing"
+	StateError    = "error"
+)
+
+// Status describes a session
+type Status struct {
+	Source   string    `json:"source"`
+	Dest     string    `json:"dest"`
+	State    string    `json:"state"`
+	LastSync time.Time `json:"last_sync,omitempty"`
+	Last     Stats     `json:"last"` // what the last pass changed
+	Error    string    `json:"error,omitempty"`
+}
+
+// Session keeps dest mirroring source, syncing shortly after changes in
+// source and whenever flushed. Changes made in dest are overwritten.
+type Session struct {
+	source, dest string
+	patterns     []string
+
+	watcher *fsnotify.Watcher
+	flushes chan chan flushResult
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	mu     sync.Mutex
+	status Status
+}
+
+type flushResult struct {
+	stats Stats
+	err   error
+}
+
+// Start mirrors source into dest in the background and then watches
+// source for changes. Ignore patterns are read from the source's
+// IgnoreFile after the given ones, on every pass.
+func Start(source, dest string, patterns []string) (*Session, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Session{
+		source:   source,
+		dest:     dest,
+		patterns: patterns,
+		watcher:  watcher,
+		flushes:  make(chan chan flushResult),
+		cancel:   cancel,
+		done:     make(chan struct{}),
+		status:   Status{Source: source, Dest: dest, State: StateSyncing},
+	}
+	go s.run(ctx)
+	return s, nil
+}
+
+// Status reports how the session is doing
+func (s *Session) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// Flush syncs now, rather than after the source settles, and returns
+// what changed
+func (s *Session) Flush(ctx context.Context) (Stats, error) {
+	reply := make(chan flushResult, 1)
+	select {
+	case s.flushes <- reply:
+	case <-s.done:
+		return Stats{}, context.Canceled
+	case <-ctx.Done():
+		return Stats{}, ctx.Err()
+	}
+	select {
+	case r := <-reply:
+		return r.stats, r.err
+	case <-ctx.Done():
+		return Stats{}, ctx.Err()
+	}
+}
+
+// Close stops watching
+func (s *Session) Close() error {
+	s.cancel()
+	<-s.done
+	return s.watcher.Close()
+}
+
+func (s *Session) run(ctx context.Context) {
+	defer close(s.done)
+
+	s.sync()
+	settle := time.NewTimer(settleDelay)
+	settle.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.watcher.Events:
+			settle.Reset(settleDelay)
+		case err := <-s.watcher.Errors:
+			// Usually dropped events; a full pass catches up with them
+			s.setError(err)
+			settle.Reset(settleDelay)
+		case <-settle.C:
+			s.sync()
+		case reply := <-s.flushes:
+			settle.Stop()
+			stats, err := s.sync()
+			reply <- flushResult{stats, err}
+		}
+	}
+}
+
+// sync runs a mirror pass and watches any directories that appeared
+func (s *Session) sync() (Stats, error) {
+	s.mu.Lock()
+	s.status.State = StateSyncing
+	s.mu.Unlock()
+
+	ignore, err := LoadIgnore(s.source, s.patterns)
+	var stats Stats
+	var dirs []string
+	if err == nil {
+		stats, dirs, err = mirror(s.source, s.dest, ignore)
+	}
+	// Watches on directories that are gone drop away by themselves
+	for _, dir := range dirs {
+		s.watcher.Add(dir)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.Last = stats
+	if err != nil {
+		s.status.State = StateError
+		s.status.Error = err.Error()
+		return stats, err
+	}
+	s.status.State = StateWatching
+	s.status.Error = ""
+	s.status.LastSync = time.Now()
+	return stats, nil
+}
+
+func (s *Session) setError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.Error = err.Error()
+}
@@ -0,0 +1,47 @@
+package filesync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSession(t *testing.T) {
+	src, dst := t.TempDir(), t.TempDir()
+	writeFile(t, src, "a.txt", "a")
+
+	s, err := Start(src, dst, []string{"*.tmp"})
+	require.NoError(t, err)
+	defer s.Close()
+
+	ctx := context.Background()
+	_, err = s.Flush(ctx)
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dst, "a.txt"))
+
+	t.Run("syncs changes once the source settles", func(t *testing.T) {
+		writeFile(t, src, "sub/b.txt", "b")
+		writeFile(t, src, "scratch.tmp", "")
+		assert.Eventually(t, func() bool {
+			_, err := os.Stat(filepath.Join(dst, "sub/b.txt"))
+			return err == nil
+		}, 5*time.Second, 50*time.Millisecond)
+		assert.NoFileExists(t, filepath.Join(dst, "scratch.tmp"))
+
+		status := s.Status()
+		assert.Equal(t, StateWatching, status.State)
+		assert.False(t, status.LastSync.IsZero())
+	})
+
+	t.Run("reports errors", func(t *testing.T) {
+		require.NoError(t, os.RemoveAll(dst))
+		_, err := s.Flush(ctx)
+		assert.Error(t, err)
+		assert.Equal(t, StateError, s.Status().State)
+	})
+}
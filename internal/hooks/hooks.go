@@ -0,0 +1,261 @@
+// Package hooks runs user executables in response to daemon events.
+//
+// Every executable file in the hooks directory is run once per event with
+// the event encoded as JSON on stdin. Hooks run concurrently with the
+// daemon and cannot block or fail puck operations; failures are logged and
+// kept for inspection with `puck hooks`.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// Event types passed to hooks
+const (
+	EventPuckCreated      = "puck.created"
+	EventPuckStarted      = "puck.started"
+	EventPuckStopped      = "puck.stopped"
+	EventPuckRecreated    = "puck.recreated"
+	EventPuckDestroyed    = "puck.destroyed"
+	EventPuckCheckpointed = "puck.checkpointed"
+	EventPuckPortChanged  = "puck.port_changed"
+	EventPuckPromoted     = "puck.promoted"
+	EventSnapshotCreated  = "snapshot.created"
+	EventSnapshotRestored = "snapshot.restored"
+)
+
+// DefaultTimeout bounds how long a single hook may run
+const DefaultTimeout = 10 * time.Second
+
+// maxOutput caps how much hook output is kept for failure reports
+const maxOutput = 4096
+
+// Event is the JSON payload written to a hook's stdin
+type Event struct {
+	Type     string      `json:"type"`
+	Puck     string      `json:"puck"`
+	Snapshot string      `json:"snapshot,omitempty"`
+	Time     time.Time   `json:"time"`
+	Data     interface{} `json:"data,omitempty"` // e.g. the puck or snapshot record
+}
+
+// Result records the outcome of running one hook for one event
+type Result struct {
+	Hook     string        `json:"hook"`
+	Event    string        `json:"event"`
+	Puck     string        `json:"puck"`
+	Time     time.Time     `json:"time"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+	Output   string        `json:"output,omitempty"` // end of combined stdout/stderr
+}
+
+// Failed reports whether the hook run failed
+func (r Result) Failed() bool {
+	return r.Error != ""
+}
+
+// Runner dispatches events to the executables in a directory
+type Runner struct {
+	dir     string
+	timeout time.Duration
+
+	mu   sync.Mutex
+	last map[string]Result // hook name -> most recent result
+	wg   sync.WaitGroup
+}
+
+// NewRunner creates a runner for the hooks in dir
+func NewRunner(dir string, timeout time.Duration) *Runner {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Runner{
+		dir:     dir,
+		timeout: timeout,
+		last:    make(map[string]Result),
+	}
+}
+
+// Dir returns the hooks directory
+func (r *Runner) Dir() string {
+	return r.dir
+}
+
+// Hooks returns the executable hook paths in name order
+func (r *Runner) Hooks() ([]string, error) {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading hooks directory: %w", err)
+	}
+
+	var hooks []string
+	for _, e := range entries {
+		// Skip editor backups and disabled hooks
+		name := e.Name()
+		if strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+			continue
+		}
+		hooks = append(hooks, filepath.Join(r.dir, name))
+	}
+	sort.Strings(hooks)
+	return hooks, nil
+}
+
+// Fire runs all hooks for an event in the background
+func (r *Runner) Fire(ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.Run(context.Background(), ev)
+	}()
+}
+
+// Wait blocks until all fired events have been handled
+func (r *Runner) Wait() {
+	r.wg.Wait()
+}
+
+// Run runs all hooks for an event and returns their results
+func (r *Runner) Run(ctx context.Context, ev Event) []Result {
+	hooks, err := r.Hooks()
+	if err != nil {
+		log.Warn("Failed to list hooks", "dir", r.dir, "error", err)
+		return nil
+	}
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		log.Warn("Failed to encode hook event", "event", ev.Type, "error", err)
+		return nil
+	}
+
+	results := make([]Result, len(hooks))
+	var wg sync.WaitGroup
+	for i, path := range hooks {
+		wg.Add(1)
+		go func(i int, path string) {
+			defer wg.Done()
+			results[i] = r.runHook(ctx, path, ev, payload)
+		}(i, path)
+	}
+	wg.Wait()
+
+	r.mu.Lock()
+	for _, res := range results {
+		r.last[res.Hook] = res
+	}
+	r.mu.Unlock()
+
+	return results
+}
+
+// runHook executes a single hook with the event on stdin
+func (r *Runner) runHook(ctx context.Context, path string, ev Event, payload []byte) Result {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	res := Result{
+		Hook:  filepath.Base(path),
+		Event: ev.Type,
+		Puck:  ev.Puck,
+		Time:  time.Now(),
+	}
+
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	cmd.Env = append(os.Environ(),
+		"PUCK_EVENT="+ev.Type,
+		"PUCK_NAME="+ev.Puck,
+	)
+	// Don't wait on grandchildren holding the output pipe after a timeout
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	res.Duration = time.Since(res.Time)
+	res.Output = tail(out.String(), maxOutput)
+
+	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("timed out after %s", r.timeout)
+		}
+		res.Error = err.Error()
+		log.Warn("Hook failed", "hook", res.Hook, "event", ev.Type, "puck", ev.Puck, "error", err, "output", res.Output)
+	} else {
+		log.Debug("Hook ran", "hook", res.Hook, "event", ev.Type, "puck", ev.Puck, "duration", res.Duration)
+	}
+	return res
+}
+
+// LastResults returns the most recent result for each hook that has run
+func (r *Runner) LastResults() []Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	results := make([]Result, 0, len(r.last))
+	for _, res := range r.last {
+		results = append(results, res)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Hook < results[j].Hook })
+	return results
+}
+
+// Status describes the installed hooks and their most recent runs
+type Status struct {
+	Dir     string   `json:"dir"`
+	Hooks   []string `json:"hooks"` // hook names in run order
+	Results []Result `json:"results"`
+}
+
+// Status returns the installed hooks and their last results
+func (r *Runner) Status() (*Status, error) {
+	paths, err := r.Hooks()
+	if err != nil {
+		return nil, err
+	}
+
+	st := &Status{Dir: r.dir, Results: r.LastResults()}
+	for _, p := range paths {
+		st.Hooks = append(st.Hooks, filepath.Base(p))
+	}
+	return st, nil
+}
+
+// tail returns the last n bytes of s
+func tail(s string, n int) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= n {
+		return s
+	}
+	return s[len(s)-n:]
+}
@@ -0,0 +1,112 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeHook creates a shell script hook in dir
+func writeHook(t *testing.T, dir, name, script string, mode os.FileMode) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), mode))
+}
+
+func TestHooks(t *testing.T) {
+	t.Run("missing directory has no hooks", func(t *testing.T) {
+		r := NewRunner(filepath.Join(t.TempDir(), "missing"), 0)
+		hooks, err := r.Hooks()
+		require.NoError(t, err)
+		assert.Empty(t, hooks)
+	})
+
+	t.Run("lists executables in name order", func(t *testing.T) {
+		dir := t.TempDir()
+		writeHook(t, dir, "20-slack", "true", 0755)
+		writeHook(t, dir, "10-log", "true", 0755)
+		writeHook(t, dir, "README", "", 0644)
+		writeHook(t, dir, ".hidden", "true", 0755)
+		writeHook(t, dir, "30-old~", "true", 0755)
+		require.NoError(t, os.Mkdir(filepath.Join(dir, "subdir"), 0755))
+
+		hooks, err := NewRunner(dir, 0).Hooks()
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			filepath.Join(dir, "10-log"),
+			filepath.Join(dir, "20-slack"),
+		}, hooks)
+	})
+}
+
+func TestRun(t *testing.T) {
+	t.Run("passes event on stdin and in environment", func(t *testing.T) {
+		dir := t.TempDir()
+		out := filepath.Join(t.TempDir(), "event.json")
+		writeHook(t, dir, "capture", `cat > `+out+`; echo "$PUCK_EVENT $PUCK_NAME"`, 0755)
+
+		r := NewRunner(dir, time.Second)
+		results := r.Run(context.Background(), Event{Type: EventPuckCreated, Puck: "web", Data: map[string]string{"image": "fedora"}})
+		require.Len(t, results, 1)
+		assert.False(t, results[0].Failed())
+		assert.Equal(t, "puck.created web", results[0].Output)
+
+		data, err := os.ReadFile(out)
+		require.NoError(t, err)
+		var ev Event
+		require.NoError(t, json.Unmarshal(data, &ev))
+		assert.Equal(t, EventPuckCreated, ev.Type)
+		assert.Equal(t, "web", ev.Puck)
+	})
+
+	t.Run("reports failures with output", func(t *testing.T) {
+		dir := t.TempDir()
+		writeHook(t, dir, "broken", `echo "no webhook url" >&2; exit 3`, 0755)
+
+		r := NewRunner(dir, time.Second)
+		results := r.Run(context.Background(), Event{Type: EventPuckStarted, Puck: "web"})
+		require.Len(t, results, 1)
+		assert.True(t, results[0].Failed())
+		assert.Contains(t, results[0].Error, "exit status 3")
+		assert.Equal(t, "no webhook url", results[0].Output)
+	})
+
+	t.Run("kills hooks that exceed the timeout", func(t *testing.T) {
+		dir := t.TempDir()
+		writeHook(t, dir, "slow", "sleep 10", 0755)
+
+		r := NewRunner(dir, 100*time.Millisecond)
+		start := time.Now()
+		results := r.Run(context.Background(), Event{Type: EventPuckStopped, Puck: "web"})
+		require.Len(t, results, 1)
+		assert.Contains(t, results[0].Error, "timed out")
+		assert.Less(t, time.Since(start), 5*time.Second)
+	})
+
+	t.Run("records last result per hook", func(t *testing.T) {
+		dir := t.TempDir()
+		writeHook(t, dir, "a", "true", 0755)
+		writeHook(t, dir, "b", "false", 0755)
+
+		r := NewRunner(dir, time.Second)
+		r.Fire(Event{Type: EventPuckCreated, Puck: "one"})
+		r.Wait()
+		r.Fire(Event{Type: EventPuckDestroyed, Puck: "two"})
+		r.Wait()
+
+		last := r.LastResults()
+		require.Len(t, last, 2)
+		assert.Equal(t, "a", last[0].Hook)
+		assert.Equal(t, EventPuckDestroyed, last[0].Event)
+		assert.False(t, last[0].Failed())
+		assert.Equal(t, "b", last[1].Hook)
+		assert.Equal(t, "two", last[1].Puck)
+		assert.True(t, last[1].Failed())
+	})
+}
@@ -0,0 +1,196 @@
+// Package mcp serves puck operations as Model Context Protocol tools, so
+// coding agents can create and use pucks through the daemon and the
+// permissions it enforces on their user
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// ProtocolVersion is the newest MCP revision the server speaks
+const ProtocolVersion = "2025-06-18"
+
+// Pucks is what the tools need from the daemon; *daemon.Client has it
+type Pucks interface {
+	List() ([]*store.Puck, error)
+	Create(opts puck.CreateOptions) (*store.Puck, error)
+	Exec(opts puck.ExecOptions) (*puck.ExecResult, error)
+	Logs(name string, tail int) (string, error)
+	SnapshotCreate(puckName, snapshotName string, leaveRunning *bool, mode store.SnapshotMode, criu puck.CRIUFlags) (*store.Snapshot, error)
+}
+
+// Server answers MCP requests read as JSON-RPC lines
+type Server struct {
+	pucks   Pucks
+	version string
+	tools   []tool
+
+	mu  sync.Mutex // serializes writes
+	out io.Writer
+}
+
+// NewServer returns a server offering the named tools, or all of them
+// when names is empty
+func NewServer(pucks Pucks, version string, names []string) (*Server, error) {
+	s := &Server{pucks: pucks, version: version}
+	for _, name := range names {
+		if !slices.ContainsFunc(allTools, func(t tool) bool { return t.Name == name }) {
+			return nil, fmt.Errorf("unknown tool %q (valid: %s)", name, strings.Join(ToolNames(), ", "))
+		}
+	}
+	for _, t := range allTools {
+		if len(names) == 0 || slices.Contains(names, t.Name) {
+			s.tools = append(s.tools, t)
+		}
+	}
+	return s, nil
+}
+
+// ToolNames returns the names of every tool the server can offer
+func ToolNames() []string {
+	names := make([]string, len(allTools))
+	for i, t := range allTools {
+		names[i] = t.Name
+	}
+	return names
+}
+
+// JSON-RPC error codes
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Serve answers requests from in on out until in ends or ctx is done.
+// Requests are handled one at a time, in order.
+func (s *Server) Serve(ctx context.Context, in io.Reader, out io.Writer) error {
+	s.out = out
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		line := scanner.Bytes()
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+
+		var req request
+		if err := json.Unmarshal(line, &req); err != nil {
+			s.write(response{ID: json.RawMessage("null"), Error: &rpcError{codeParseError, err.Error()}})
+			continue
+		}
+		result, rpcErr := s.handle(req)
+		if len(req.ID) == 0 {
+			// Notifications get no reply
+			continue
+		}
+		s.write(response{ID: req.ID, Result: result, Error: rpcErr})
+	}
+	return scanner.Err()
+}
+
+func (s *Server) write(resp response) {
+	resp.JSONRPC = "2.0"
+	data, _ := json.Marshal(resp)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.out.Write(append(data, '\n'))
+}
+
+func (s *Server) handle(req request) (any, *rpcError) {
+	if req.JSONRPC != "2.0" {
+		return nil, &rpcError{codeInvalidRequest, "jsonrpc must be 2.0"}
+	}
+
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(req.Params, &params)
+		// Older clients are answered with what they asked for; the tools
+		// work the same in every revision
+		version := ProtocolVersion
+		if params.ProtocolVersion != "" && params.ProtocolVersion < ProtocolVersion {
+			version = params.ProtocolVersion
+		}
+		return map[string]any{
+			"protocolVersion": version,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]string{"name": "puck", "version": s.version},
+			"instructions": "Pucks are persistent containers. Create one with puck_create, " +
+				"run commands in it with puck_exec, and snapshot it before risky changes.",
+		}, nil
+	case "ping":
+		return map[string]any{}, nil
+	case "tools/list":
+		return map[string]any{"tools": s.tools}, nil
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &rpcError{codeInvalidParams, err.Error()}
+		}
+		i := slices.IndexFunc(s.tools, func(t tool) bool { return t.Name == params.Name })
+		if i < 0 {
+			return nil, &rpcError{codeInvalidParams, fmt.Sprintf("unknown tool %q", params.Name)}
+		}
+		if len(params.Arguments) == 0 {
+			params.Arguments = json.RawMessage("{}")
+		}
+		// Tool failures are results the agent can see and act on, not
+		// protocol errors
+		text, err := s.tools[i].call(s.pucks, params.Arguments)
+		if err != nil {
+			return toolResult(err.Error(), true), nil
+		}
+		return toolResult(text, false), nil
+	default:
+		if strings.HasPrefix(req.Method, "notifications/") {
+			return nil, nil
+		}
+		return nil, &rpcError{codeMethodNotFound, fmt.Sprintf("method %q not found", req.Method)}
+	}
+}
+
+func toolResult(text string, isError bool) map[string]any {
+	return map[string]any{
+		"content": []map[string]string{{"type": "text", "text": text}},
+		"isError": isError,
+	}
+}
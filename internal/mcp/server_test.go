@@ -0,0 +1,133 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakePucks struct {
+	execs []puck.ExecOptions
+}
+
+func (f *fakePucks) List() ([]*store.Puck, error) {
+	return []*store.Puck{{Name: "dev", Status: store.StatusRunning, Image: "fedora"}}, nil
+}
+
+func (f *fakePucks) Create(opts puck.CreateOptions) (*store.Puck, error) {
+	return nil, errors.New("permission denied")
+}
+
+func (f *fakePucks) Exec(opts puck.ExecOptions) (*puck.ExecResult, error) {
+	f.execs = append(f.execs, opts)
+	return &puck.ExecResult{ExitCode: 1, Output: "no such file\n"}, nil
+}
+
+func (f *fakePucks) Logs(name string, tail int) (string, error) {
+	return "", nil
+}
+
+func (f *fakePucks) SnapshotCreate(puckName, snapshotName string, leaveRunning *bool, mode store.SnapshotMode, criu puck.CRIUFlags) (*store.Snapshot, error) {
+	return &store.Snapshot{Name: snapshotName, Mode: store.SnapshotModeCheckpoint}, nil
+}
+
+// exchange sends each request line to a server and returns the replies
+func exchange(t *testing.T, s *Server, lines ...string) []map[string]any {
+	var out bytes.Buffer
+	require.NoError(t, s.Serve(context.Background(), strings.NewReader(strings.Join(lines, "\n")), &out))
+
+	var replies []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var reply map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &reply))
+		replies = append(replies, reply)
+	}
+	return replies
+}
+
+func TestServer(t *testing.T) {
+	t.Run("initializes and lists tools", func(t *testing.T) {
+		s, err := NewServer(&fakePucks{}, "1.0", nil)
+		require.NoError(t, err)
+
+		replies := exchange(t, s,
+			`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`,
+			`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+			`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		)
+		require.Len(t, replies, 2)
+		init := replies[0]["result"].(map[string]any)
+		assert.Equal(t, "2025-03-26", init["protocolVersion"])
+		assert.Equal(t, "puck", init["serverInfo"].(map[string]any)["name"])
+
+		tools := replies[1]["result"].(map[string]any)["tools"].([]any)
+		var names []string
+		for _, tl := range tools {
+			names = append(names, tl.(map[string]any)["name"].(string))
+		}
+		assert.Equal(t, ToolNames(), names)
+	})
+
+	t.Run("calls tools", func(t *testing.T) {
+		pucks := &fakePucks{}
+		s, err := NewServer(pucks, "1.0", nil)
+		require.NoError(t, err)
+
+		replies := exchange(t, s,
+			`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"puck_exec","arguments":{"name":"dev","command":"cat x","timeout_seconds":60}}}`,
+			`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"puck_create","arguments":{"name":"other"}}}`,
+			`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"puck_exec","arguments":{"name":"dev","cmd":"ls"}}}`,
+		)
+		require.Len(t, replies, 3)
+
+		result := replies[0]["result"].(map[string]any)
+		assert.Equal(t, false, result["isError"])
+		assert.Equal(t, "exit code: 1\nno such file\n", result["content"].([]any)[0].(map[string]any)["text"])
+		require.Len(t, pucks.execs, 1)
+		assert.Equal(t, []string{"/bin/sh", "-c", "cat x"}, pucks.execs[0].Cmd)
+		assert.Equal(t, float64(60), pucks.execs[0].Timeout.Seconds())
+
+		// Daemon errors and bad arguments come back as tool errors
+		for _, reply := range replies[1:] {
+			assert.Equal(t, true, reply["result"].(map[string]any)["isError"])
+		}
+	})
+
+	t.Run("offers only the chosen tools", func(t *testing.T) {
+		s, err := NewServer(&fakePucks{}, "1.0", []string{"puck_list", "puck_logs"})
+		require.NoError(t, err)
+
+		replies := exchange(t, s,
+			`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"puck_exec","arguments":{}}}`,
+			`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"puck_list"}}`,
+		)
+		require.Len(t, replies, 2)
+		assert.EqualValues(t, codeInvalidParams, replies[0]["error"].(map[string]any)["code"])
+		assert.Contains(t, replies[1]["result"].(map[string]any)["content"].([]any)[0].(map[string]any)["text"], `"name": "dev"`)
+
+		_, err = NewServer(&fakePucks{}, "1.0", []string{"puck_rm"})
+		assert.Error(t, err)
+	})
+
+	t.Run("reports protocol errors", func(t *testing.T) {
+		s, err := NewServer(&fakePucks{}, "1.0", nil)
+		require.NoError(t, err)
+
+		replies := exchange(t, s, `{not json`, `{"jsonrpc":"2.0","id":"a","method":"resources/list"}`)
+		require.Len(t, replies, 2)
+		assert.EqualValues(t, codeParseError, replies[0]["error"].(map[string]any)["code"])
+		assert.Equal(t, "a", replies[1]["id"])
+		assert.EqualValues(t, codeMethodNotFound, replies[1]["error"].(map[string]any)["code"])
+	})
+}
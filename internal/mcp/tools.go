@@ -0,0 +1,180 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// tool is an MCP tool and the call that implements it
+type tool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+
+	call func(p Pucks, args json.RawMessage) (string, error)
+}
+
+// object is a JSON schema for an object with the given properties
+func object(required []string, props map[string]any) map[string]any {
+	schema := map[string]any{"type": "object", "properties": props, "additionalProperties": false}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func prop(typ, description string) map[string]any {
+	return map[string]any{"type": typ, "description": description}
+}
+
+// decode unmarshals tool arguments, rejecting unknown ones so typos
+// aren't silently ignored
+func decode(args json.RawMessage, v any) error {
+	dec := json.NewDecoder(strings.NewReader(string(args)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	return nil
+}
+
+// indent renders v as indented JSON
+func indent(v any) (string, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	return string(data), err
+}
+
+var allTools = []tool{
+	{
+		Name:        "puck_list",
+		Description: "List your pucks with their status, image and address.",
+		InputSchema: object(nil, map[string]any{}),
+		call: func(p Pucks, args json.RawMessage) (string, error) {
+			pucks, err := p.List()
+			if err != nil {
+				return "", err
+			}
+			type summary struct {
+				Name   string   `json:"name"`
+				Status string   `json:"status"`
+				Image  string   `json:"image"`
+				IP     string   `json:"ip,omitempty"`
+				Ports  []string `json:"ports,omitempty"`
+			}
+			list := make([]summary, 0, len(pucks))
+			for _, pk := range pucks {
+				list = append(list, summary{Name: pk.Name, Status: string(pk.Status), Image: pk.Image, IP: pk.ContainerIP, Ports: pk.Ports})
+			}
+			return indent(list)
+		},
+	},
+	{
+		Name:        "puck_create",
+		Description: "Create and start a puck, a persistent container that behaves like a small machine. Returns once it is running.",
+		InputSchema: object([]string{"name"}, map[string]any{
+			"name":  prop("string", "Name for the puck: lowercase letters, digits and dashes"),
+			"image": prop("string", "Container image; defaults to the configured image (Fedora with systemd)"),
+			"ports": map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": "Host:container port mappings, e.g. 8080:80"},
+			"sandbox": map[string]any{"type": "string", "enum": []string{string(store.SandboxStrict)},
+				"description": "strict for running untrusted code: no network, read-only root filesystem, no capabilities, capped resources"},
+		}),
+		call: func(p Pucks, args json.RawMessage) (string, error) {
+			var opts struct {
+				Name    string            `json:"name"`
+				Image   string            `json:"image"`
+				Ports   []string          `json:"ports"`
+				Sandbox store.SandboxMode `json:"sandbox"`
+			}
+			if err := decode(args, &opts); err != nil {
+				return "", err
+			}
+			created, err := p.Create(puck.CreateOptions{Name: opts.Name, Image: opts.Image, Ports: opts.Ports, Sandbox: opts.Sandbox})
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Created puck '%s' (%s), status %s", created.Name, created.Image, created.Status), nil
+		},
+	},
+	{
+		Name:        "puck_exec",
+		Description: "Run a shell command in a running puck and return its exit code and combined output. No terminal or stdin; output beyond 1 MiB is dropped.",
+		InputSchema: object([]string{"name", "command"}, map[string]any{
+			"name":            prop("string", "Puck to run the command in"),
+			"command":         prop("string", "Command line, run with /bin/sh -c"),
+			"workdir":         prop("string", "Directory to run it in"),
+			"timeout_seconds": prop("integer", fmt.Sprintf("Give up after this long (default %d, at most %d)", int(puck.DefaultExecTimeout.Seconds()), int(puck.MaxExecTimeout.Seconds()))),
+		}),
+		call: func(p Pucks, args json.RawMessage) (string, error) {
+			var opts struct {
+				Name           string `json:"name"`
+				Command        string `json:"command"`
+				WorkDir        string `json:"workdir"`
+				TimeoutSeconds int    `json:"timeout_seconds"`
+			}
+			if err := decode(args, &opts); err != nil {
+				return "", err
+			}
+			result, err := p.Exec(puck.ExecOptions{
+				Name:    opts.Name,
+				Cmd:     []string{"/bin/sh", "-c", opts.Command},
+				WorkDir: opts.WorkDir,
+				Timeout: time.Duration(opts.TimeoutSeconds) * time.Second,
+			})
+			if err != nil {
+				return "", err
+			}
+			text := fmt.Sprintf("exit code: %d\n%s", result.ExitCode, result.Output)
+			if result.Truncated {
+				text += "\n[output truncated]"
+			}
+			return text, nil
+		},
+	},
+	{
+		Name:        "puck_logs",
+		Description: "Show the last lines of a puck's console output.",
+		InputSchema: object([]string{"name"}, map[string]any{
+			"name": prop("string", "Puck whose logs to show"),
+			"tail": prop("integer", "Number of lines (default 100, 0 for all)"),
+		}),
+		call: func(p Pucks, args json.RawMessage) (string, error) {
+			opts := struct {
+				Name string `json:"name"`
+				Tail int    `json:"tail"`
+			}{Tail: 100}
+			if err := decode(args, &opts); err != nil {
+				return "", err
+			}
+			return p.Logs(opts.Name, opts.Tail)
+		},
+	},
+	{
+		Name:        "puck_snapshot",
+		Description: "Snapshot a puck so its state can be restored later, e.g. before a risky change. The puck keeps running.",
+		InputSchema: object([]string{"name", "snapshot"}, map[string]any{
+			"name":     prop("string", "Puck to snapshot"),
+			"snapshot": prop("string", "Name for the snapshot"),
+		}),
+		call: func(p Pucks, args json.RawMessage) (string, error) {
+			var opts struct {
+				Name     string `json:"name"`
+				Snapshot string `json:"snapshot"`
+			}
+			if err := decode(args, &opts); err != nil {
+				return "", err
+			}
+			leaveRunning := true
+			snap, err := p.SnapshotCreate(opts.Name, opts.Snapshot, &leaveRunning, "", puck.CRIUFlags{})
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("Created snapshot '%s' of puck '%s' (%s); restore it with: puck snapshot restore %s %s",
+				snap.Name, opts.Name, snap.Mode, opts.Name, snap.Name), nil
+		},
+	},
+}
@@ -0,0 +1,244 @@
+// Package netproxy serves SOCKS5 and HTTP proxy connections on one
+// listener, dialing destinations with a caller-provided dialer. puck runs
+// it inside a puck's network namespace so host tools connect to whatever
+// the puck can reach, the way the puck sees it.
+package netproxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DialFunc opens a connection to a destination on behalf of a client
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// Server proxies SOCKS5 and HTTP clients, telling them apart by the first
+// byte they send
+type Server struct {
+	Dial DialFunc
+
+	transportOnce sync.Once
+	transport     *http.Transport
+}
+
+// Serve accepts connections on ln until it is closed
+func (s *Server) Serve(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.ServeConn(conn)
+	}
+}
+
+// ServeConn handles one client connection and closes it when done
+func (s *Server) ServeConn(conn net.Conn) {
+	defer conn.Close()
+
+	br := bufio.NewReader(conn)
+	first, err := br.Peek(1)
+	if err != nil {
+		return
+	}
+	if first[0] == socksVersion {
+		s.serveSOCKS(conn, br)
+		return
+	}
+	s.serveHTTP(conn, br)
+}
+
+const (
+	socksVersion     = 5
+	socksNoAuth      = 0
+	socksNoMethod    = 0xff
+	socksConnect     = 1
+	socksAddrIPv4    = 1
+	socksAddrDomain  = 3
+	socksAddrIPv6    = 4
+	socksSucceeded   = 0
+	socksFailure     = 1
+	socksUnsupported = 7
+)
+
+// serveSOCKS speaks SOCKS5 without authentication and supports CONNECT
+func (s *Server) serveSOCKS(conn net.Conn, br *bufio.Reader) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(br, methods); err != nil {
+		return
+	}
+	method := byte(socksNoMethod)
+	for _, m := range methods {
+		if m == socksNoAuth {
+			method = socksNoAuth
+		}
+	}
+	if _, err := conn.Write([]byte{socksVersion, method}); err != nil || method == socksNoMethod {
+		return
+	}
+
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(br, request); err != nil {
+		return
+	}
+	addr, err := readSOCKSAddr(br, request[3])
+	if err != nil {
+		socksReply(conn, socksUnsupported)
+		return
+	}
+	if request[1] != socksConnect {
+		socksReply(conn, socksUnsupported)
+		return
+	}
+
+	upstream, err := s.Dial(context.Background(), "tcp", addr)
+	if err != nil {
+		socksReply(conn, socksFailure)
+		return
+	}
+	defer upstream.Close()
+	if err := socksReply(conn, socksSucceeded); err != nil {
+		return
+	}
+	pipe(conn, br, upstream)
+}
+
+// readSOCKSAddr reads a request's destination as host:port
+func readSOCKSAddr(r io.Reader, kind byte) (string, error) {
+	var host string
+	switch kind {
+	case socksAddrIPv4, socksAddrIPv6:
+		ip := make(net.IP, net.IPv4len)
+		if kind == socksAddrIPv6 {
+			ip = make(net.IP, net.IPv6len)
+		}
+		if _, err := io.ReadFull(r, ip); err != nil {
+			return "", err
+		}
+		host = ip.String()
+	case socksAddrDomain:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(r, length); err != nil {
+			return "", err
+		}
+		domain := make([]byte, length[0])
+		if _, err := io.ReadFull(r, domain); err != nil {
+			return "", err
+		}
+		host = string(domain)
+	default:
+		return "", fmt.Errorf("unsupported address type %d", kind)
+	}
+
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(r, port); err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), nil
+}
+
+// socksReply answers a request; the bound address is left unspecified
+func socksReply(conn net.Conn, status byte) error {
+	_, err := conn.Write([]byte{socksVersion, status, 0, socksAddrIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
+
+// serveHTTP tunnels CONNECT requests and forwards absolute-URI requests
+func (s *Server) serveHTTP(conn net.Conn, br *bufio.Reader) {
+	for {
+		req, err := http.ReadRequest(br)
+		if err != nil {
+			return
+		}
+
+		if req.Method == http.MethodConnect {
+			upstream, err := s.Dial(req.Context(), "tcp", req.Host)
+			if err != nil {
+				httpError(conn, http.StatusBadGateway, err)
+				return
+			}
+			defer upstream.Close()
+			if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n"); err != nil {
+				return
+			}
+			pipe(conn, br, upstream)
+			return
+		}
+
+		if !req.URL.IsAbs() {
+			httpError(conn, http.StatusBadRequest, fmt.Errorf("this is a proxy; request an absolute URL"))
+			return
+		}
+		req.RequestURI = ""
+		req.Header.Del("Proxy-Connection")
+		req.Header.Del("Proxy-Authorization")
+		resp, err := s.roundTripper().RoundTrip(req)
+		if err != nil {
+			httpError(conn, http.StatusBadGateway, err)
+			return
+		}
+		err = resp.Write(conn)
+		resp.Body.Close()
+		if err != nil || req.Close || resp.Close {
+			return
+		}
+	}
+}
+
+// roundTripper forwards plain HTTP requests through the server's dialer
+func (s *Server) roundTripper() http.RoundTripper {
+	s.transportOnce.Do(func() {
+		s.transport = &http.Transport{
+			DialContext:         s.Dial,
+			MaxIdleConnsPerHost: 4,
+			IdleConnTimeout:     90 * time.Second,
+		}
+	})
+	return s.transport
+}
+
+// httpError answers a proxy request that couldn't be forwarded
+func httpError(conn net.Conn, status int, err error) {
+	body := err.Error() + "\n"
+	fmt.Fprintf(conn, "HTTP/1.1 %d %s\r\nContent-Type: text/plain\r\nContent-Length: %d\r\nConnection: close\r\n\r\n%s",
+		status, http.StatusText(status), len(body), body)
+}
+
+// pipe copies between a client, whose buffered reader may already hold
+// data, and an upstream connection until both directions are done
+func pipe(client net.Conn, clientReader io.Reader, upstream net.Conn) {
+	done := make(chan struct{})
+	go func() {
+		io.Copy(upstream, clientReader)
+		closeWrite(upstream)
+		close(done)
+	}()
+	io.Copy(client, upstream)
+	closeWrite(client)
+	<-done
+}
+
+// closeWrite signals EOF to the peer where the connection supports it
+func closeWrite(conn net.Conn) {
+	if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+		cw.CloseWrite()
+		return
+	}
+	conn.Close()
+}
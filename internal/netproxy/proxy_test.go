@@ -0,0 +1,111 @@
+package netproxy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startProxy serves a proxy on a loopback port, recording dialed addresses
+func startProxy(t *testing.T) (string, *[]string) {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	var dialed []string
+	dialer := &net.Dialer{}
+	srv := &Server{Dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		return dialer.DialContext(ctx, network, addr)
+	}}
+	go srv.Serve(ln)
+	return ln.Addr().String(), &dialed
+}
+
+func get(t *testing.T, proxyURL, target string) (string, error) {
+	t.Helper()
+
+	u, err := url.Parse(proxyURL)
+	require.NoError(t, err)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(u)}}
+	resp, err := client.Get(target)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func TestServer(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "hello %s", r.URL.Path)
+	}))
+	defer backend.Close()
+	tlsBackend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "secure")
+	}))
+	defer tlsBackend.Close()
+	backendAddr := backend.Listener.Addr().String()
+
+	t.Run("socks5", func(t *testing.T) {
+		addr, dialed := startProxy(t)
+
+		body, err := get(t, "socks5://"+addr, backend.URL+"/socks")
+		require.NoError(t, err)
+		assert.Equal(t, "hello /socks", body)
+		assert.Equal(t, []string{backendAddr}, *dialed)
+	})
+
+	t.Run("http forwarding", func(t *testing.T) {
+		addr, dialed := startProxy(t)
+
+		body, err := get(t, "http://"+addr, backend.URL+"/plain")
+		require.NoError(t, err)
+		assert.Equal(t, "hello /plain", body)
+		assert.Equal(t, []string{backendAddr}, *dialed)
+	})
+
+	t.Run("http connect", func(t *testing.T) {
+		addr, _ := startProxy(t)
+
+		u, err := url.Parse("http://" + addr)
+		require.NoError(t, err)
+		transport := tlsBackend.Client().Transport.(*http.Transport).Clone()
+		transport.Proxy = http.ProxyURL(u)
+		resp, err := (&http.Client{Transport: transport}).Get(tlsBackend.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "secure", string(body))
+	})
+
+	t.Run("unreachable destinations", func(t *testing.T) {
+		addr, _ := startProxy(t)
+
+		closed, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		target := "http://" + closed.Addr().String()
+		closed.Close()
+
+		_, err = get(t, "socks5://"+addr, target)
+		assert.Error(t, err)
+
+		u, _ := url.Parse("http://" + addr)
+		resp, err := (&http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(u)}}).Get(target)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	})
+}
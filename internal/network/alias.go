@@ -0,0 +1,123 @@
+package network
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+)
+
+// aliasPathPattern matches paths an alias may take; segments can't start
+// with an underscore, so aliases never collide with share links
+var aliasPathPattern = regexp.MustCompile(`^(/[a-zA-Z0-9][a-zA-Z0-9_.-]*)+$`)
+
+// SetAlias serves a puck at an extra path, replacing whichever puck served
+// it before. The path only works while the puck has a route.
+func (r *Router) SetAlias(path, puckName string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !aliasPathPattern.MatchString(path) {
+		return fmt.Errorf("invalid alias path %q", path)
+	}
+
+	next := maps.Clone(r.aliases)
+	next[path] = puckName
+	return r.setAliases(next)
+}
+
+// RepointAliases serves a puck at each of paths in a single reload, so
+// requests move over all at once. The puck's own path is dropped as an
+// alias instead, since its route already serves it.
+func (r *Router) RepointAliases(paths []string, puckName string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next := maps.Clone(r.aliases)
+	for _, path := range paths {
+		if !aliasPathPattern.MatchString(path) {
+			return fmt.Errorf("invalid alias path %q", path)
+		}
+		if path == "/"+puckName {
+			delete(next, path)
+			continue
+		}
+		next[path] = puckName
+	}
+	return r.setAliases(next)
+}
+
+// RemoveAlias stops serving an alias path
+func (r *Router) RemoveAlias(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.aliases[path]; !ok {
+		return nil
+	}
+
+	next := maps.Clone(r.aliases)
+	delete(next, path)
+	return r.setAliases(next)
+}
+
+// RemoveAliases stops serving every alias for a puck
+func (r *Router) RemoveAliases(puckName string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next := maps.Clone(r.aliases)
+	maps.DeleteFunc(next, func(_, target string) bool {
+		return target == puckName
+	})
+	if len(next) == len(r.aliases) {
+		return nil
+	}
+	return r.setAliases(next)
+}
+
+// GetAliases returns all current aliases as path -> puck name
+func (r *Router) GetAliases() map[string]string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return maps.Clone(r.aliases)
+}
+
+// setAliases swaps in a new alias table, keeping the old one if Caddy
+// rejects the config
+func (r *Router) setAliases(next map[string]string) error {
+	prev := r.aliases
+	r.aliases = next
+	if err := r.reload(); err != nil {
+		r.aliases = prev
+		return err
+	}
+	return nil
+}
+
+// aliasRoutes builds a route for each alias whose puck is routed. Longer
+// paths come first so /api/v2 is matched before /api.
+func (r *Router) aliasRoutes() []map[string]interface{} {
+	paths := slices.SortedFunc(maps.Keys(r.aliases), func(a, b string) int {
+		return cmp.Or(cmp.Compare(len(b), len(a)), cmp.Compare(a, b))
+	})
+
+	routes := make([]map[string]interface{}, 0, len(paths))
+	for _, path := range paths {
+		puckName := r.aliases[path]
+		info, ok := r.routes[puckName]
+		if !ok {
+			continue
+		}
+
+		routes = append(routes, map[string]interface{}{
+			"match": []map[string]interface{}{
+				{"path": []string{path, path + "/*"}},
+			},
+			"handle": r.routeHandlers(puckName, info, path),
+		})
+	}
+	return routes
+}
@@ -0,0 +1,97 @@
+package network
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAliasRoutes(t *testing.T) {
+	newAliasRouter := func() *Router {
+		router := NewRouter(8080, "localhost")
+		router.routes["backend-v1"] = routeInfo{IP: "127.0.0.1", Port: 9000}
+		router.routes["backend-v2"] = routeInfo{IP: "127.0.0.1", Port: 9001}
+		return router
+	}
+
+	t.Run("serves puck under its alias ahead of puck routes", func(t *testing.T) {
+		router := newAliasRouter()
+		require.NoError(t, router.SetAlias("/api", "backend-v2"))
+
+		routes := router.buildConfig()["apps"].(map[string]interface{})["http"].(map[string]interface{})["servers"].(map[string]interface{})["puck"].(map[string]interface{})["routes"].([]map[string]interface{})
+		require.Len(t, routes, 4)
+
+		alias := routes[0]
+		assert.Equal(t, []map[string]interface{}{{"path": []string{"/api", "/api/*"}}}, alias["match"])
+
+		handlers := alias["handle"].([]map[string]interface{})
+		require.Len(t, handlers, 3)
+		assert.Equal(t, "backend-v2", handlers[0]["puck"])
+		assert.Equal(t, "/api", handlers[1]["strip_path_prefix"])
+		assert.Equal(t, "127.0.0.1:9001", handlers[2]["upstreams"].([]map[string]interface{})[0]["dial"])
+
+		cfgJSON, err := json.Marshal(router.buildConfig())
+		require.NoError(t, err)
+		assert.NoError(t, validateCaddyConfig(cfgJSON))
+	})
+
+	t.Run("repoints an alias", func(t *testing.T) {
+		router := newAliasRouter()
+		require.NoError(t, router.SetAlias("/api", "backend-v1"))
+		require.NoError(t, router.SetAlias("/api", "backend-v2"))
+		assert.Equal(t, map[string]string{"/api": "backend-v2"}, router.GetAliases())
+	})
+
+	t.Run("matches longer paths first", func(t *testing.T) {
+		router := newAliasRouter()
+		require.NoError(t, router.SetAlias("/api", "backend-v1"))
+		require.NoError(t, router.SetAlias("/api/v2", "backend-v2"))
+
+		routes := router.aliasRoutes()
+		require.Len(t, routes, 2)
+		assert.Equal(t, []map[string]interface{}{{"path": []string{"/api/v2", "/api/v2/*"}}}, routes[0]["match"])
+	})
+
+	t.Run("skips aliases for pucks without a route", func(t *testing.T) {
+		router := newAliasRouter()
+		require.NoError(t, router.SetAlias("/api", "backend-v3"))
+		assert.Empty(t, router.aliasRoutes())
+	})
+
+	t.Run("rejects unsafe paths", func(t *testing.T) {
+		router := newAliasRouter()
+		for _, path := range []string{"api", "/", "/api/", "/_share", "/a/../b", "/a b"} {
+			assert.Error(t, router.SetAlias(path, "backend-v1"), path)
+		}
+	})
+
+	t.Run("removes aliases", func(t *testing.T) {
+		router := newAliasRouter()
+		require.NoError(t, router.SetAlias("/a", "backend-v1"))
+		require.NoError(t, router.SetAlias("/b", "backend-v1"))
+		require.NoError(t, router.SetAlias("/c", "backend-v2"))
+
+		require.NoError(t, router.RemoveAlias("/a"))
+		assert.NotContains(t, router.aliases, "/a")
+
+		require.NoError(t, router.RemoveAliases("backend-v1"))
+		assert.Equal(t, map[string]string{"/c": "backend-v2"}, router.GetAliases())
+	})
+}
+
+func TestRepointAliases(t *testing.T) {
+	router := NewRouter(8080, "localhost")
+	router.routes["backend-v1"] = routeInfo{IP: "127.0.0.1", Port: 9000}
+	router.routes["backend-v2"] = routeInfo{IP: "127.0.0.1", Port: 9001}
+	require.NoError(t, router.SetAlias("/api", "backend-v1"))
+	require.NoError(t, router.SetAlias("/backend-v2", "backend-v1"))
+
+	require.NoError(t, router.RepointAliases([]string{"/backend-v1", "/api", "/backend-v2"}, "backend-v2"))
+	assert.Equal(t, map[string]string{"/backend-v1": "backend-v2", "/api": "backend-v2"}, router.GetAliases())
+
+	// A bad path leaves every alias as it was
+	assert.Error(t, router.RepointAliases([]string{"/api", "../x"}, "backend-v1"))
+	assert.Equal(t, "backend-v2", router.GetAliases()["/api"])
+}
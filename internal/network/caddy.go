@@ -3,14 +3,21 @@ package network
 import (
 	"encoding/json"
 	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"regexp"
 	"sync"
+	"time"
 
 	"github.com/caddyserver/caddy/v2"
+	"github.com/sandwich-labs/puck/internal/store"
 
 	// Register Caddy modules
 	_ "github.com/caddyserver/caddy/v2/modules/caddyhttp"
-	_ "github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
 	_ "github.com/caddyserver/caddy/v2/modules/caddyhttp/headers"
+	_ "github.com/caddyserver/caddy/v2/modules/caddyhttp/requestbody"
+	_ "github.com/caddyserver/caddy/v2/modules/caddyhttp/reverseproxy"
 	_ "github.com/caddyserver/caddy/v2/modules/caddytls"
 	_ "github.com/caddyserver/caddy/v2/modules/caddytls/standardstek"
 
@@ -18,33 +25,252 @@ import (
 	_ "github.com/tailscale/caddy-tailscale"
 )
 
+// routeNamePattern matches names that are safe to use as a path prefix
+var routeNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// Event types emitted by the router
+const (
+	EventReloadFailed = "reload-failed"
+	EventRolledBack   = "rolled-back"
+	EventPortFallback = "port-fallback"
+)
+
+// reloadDelay is how long a change waits before it is loaded into Caddy,
+// so a burst of changes, such as destroying every puck, is one load
+const reloadDelay = 100 * time.Millisecond
+
+// Start retry behaviour when the router port is busy
+const (
+	startAttempts   = 4                      // tries on the configured port before falling back
+	startBackoff    = 250 * time.Millisecond // doubled after each attempt
+	maxPortFallback = 20                     // ports after the configured one to try
+)
+
+// Event describes a noteworthy router occurrence, such as a failed reload
+type Event struct {
+	Type    string
+	Message string
+	Err     error
+	Time    time.Time
+}
+
+// tlsCertTag marks user-provided certificates so the TLS listener selects them
+const tlsCertTag = "puck-router"
+
+// TLSOptions configures the router's HTTPS listener
+type TLSOptions struct {
+	Port     int    // listener port; zero disables TLS
+	CertFile string // PEM certificate; empty uses Caddy's internal CA
+	KeyFile  string // PEM private key for CertFile
+	HTTP3    bool   // also serve HTTP/3 over QUIC on Port
+
+	// Public names, such as *.pucks.example.com, to get certificates for
+	// from an ACME CA, solving DNS-01 challenges through DNSProvider (see
+	// DNSProviders) with DNSCredentials
+	Domains        []string
+	ACMEEmail      string
+	ACMECA         string // directory URL; empty for Let's Encrypt
+	DNSProvider    string
+	DNSCredentials map[string]string
+}
+
 // Router manages HTTP routing for pucks via Caddy
 type Router struct {
-	mu       sync.RWMutex
-	routes   map[string]routeInfo // puck name -> route info
-	port     int
-	running  bool
-	domain   string // e.g., "localhost"
-	tailnet  string // tailnet name for Tailscale mode (optional)
+	mu            sync.RWMutex
+	routes        map[string]routeInfo // puck name -> route info
+	nodes         map[string][]string  // puck name -> ACL tags, for pucks shared on the tailnet
+	shares        map[string]shareLink // share token -> link
+	aliases       map[string]string    // alias path -> puck name
+	port          int                  // port the router listens on
+	wantPort      int                  // configured port; differs from port after a fallback
+	running       bool
+	startErr      error  // why the last Start failed, if it did
+	domain        string // e.g., "localhost"
+	tailnet       string // tailnet name for Tailscale mode (optional)
+	tsDir         string // where tailnet nodes keep their state; empty leaves it to caddy-tailscale
+	tls           TLSOptions
+	sharePort     int           // loopback listener serving only share links; zero disables it
+	sharesChanged chan struct{} // signaled when the share table changes
+	lastGood      []byte        // last config Caddy accepted, used for rollback
+	loaded        routeTables   // the tables lastGood was built from
+	held          bool          // Rebuild is adding state back; Caddy is loaded after
+	pending       *time.Timer   // loads the changes made since the last load
+	reloads       ReloadStats
+
+	child    *childProcess // runs Caddy out of process; nil runs it here
+	childGen int           // bumped each time Start starts a child
+	callback *http.Server  // serves the child's landing page and wakes
+	onEvent  func(Event)
+
+	// Puck name -> extra container ports served under the puck's path
+	endpoints map[string][]Endpoint
+
+	landingSource   LandingSource
+	landingTemplate string // optional override for the embedded template
+
+	wake     WakeFunc             // resumes sleeping pucks; nil disables wake-on-request
+	sleeping map[string]bool      // pucks whose next request wakes them
+	wakeMu   sync.Mutex           // serializes wakes
+	accessMu sync.Mutex           // guards access, which every request updates
+	access   map[string]time.Time // puck name -> last proxied request
+
+	// Hooks for loading and validating config; replaced in tests
+	load     func(cfgJSON []byte) error
+	validate func(cfgJSON []byte) error
+	portFree func(port int) bool
+	backoff  time.Duration
+	debounce time.Duration // zero loads each change as it is made
+}
+
+// routeTables is a copy of what a router serves, kept with the config
+// built from it
+type routeTables struct {
+	routes    map[string]routeInfo
+	nodes     map[string][]string
+	shares    map[string]shareLink
+	aliases   map[string]string
+	endpoints map[string][]Endpoint
+}
+
+// tables copies what the router serves now
+func (r *Router) tables() routeTables {
+	return routeTables{
+		routes:    maps.Clone(r.routes),
+		nodes:     maps.Clone(r.nodes),
+		shares:    maps.Clone(r.shares),
+		aliases:   maps.Clone(r.aliases),
+		endpoints: maps.Clone(r.endpoints),
+	}
+}
+
+// setTables puts back tables copied by tables
+func (r *Router) setTables(t routeTables) {
+	r.routes = maps.Clone(t.routes)
+	r.nodes = maps.Clone(t.nodes)
+	r.shares = maps.Clone(t.shares)
+	r.aliases = maps.Clone(t.aliases)
+	r.endpoints = maps.Clone(t.endpoints)
+	r.notifyShares()
+}
+
+// RouterStatus reports whether the router is serving and on which port
+type RouterStatus struct {
+	Running       bool   `json:"running"`
+	Port          int    `json:"port"`
+	RequestedPort int    `json:"requested_port"`
+	Error         string `json:"error,omitempty"`
+	Disabled      bool   `json:"disabled,omitempty"` // router_enabled is off; Caddy never starts
+	PID           int    `json:"pid,omitempty"`      // the router process, when it runs in one
+	Restarts      int    `json:"restarts,omitempty"` // times the router process was brought back
+
+	Reloads ReloadStats `json:"reloads"`
+}
+
+// ReloadStats counts how route changes were loaded into Caddy since the
+// router was created. Changes made while another waits out reloadDelay
+// are loaded with it, so Loads is usually well below Changes.
+type ReloadStats struct {
+	Changes      int           `json:"changes"`
+	Loads        int           `json:"loads"`
+	Failed       int           `json:"failed"` // loads Caddy rejected
+	Pending      bool          `json:"pending,omitempty"`
+	Last         time.Time     `json:"last,omitempty"`
+	LastDuration time.Duration `json:"last_duration,omitempty"`
 }
 
 type routeInfo struct {
-	IP   string
-	Port int
+	IP     string
+	Port   int
+	Config store.RouteConfig
+	Wake   bool // note requests and wake the puck if asleep
 }
 
+// DefaultStreamCloseDelay keeps WebSocket and SSE connections alive across
+// router reloads so dev-server hot reload survives other pucks changing.
+const DefaultStreamCloseDelay = 5 * time.Minute
+
 // NewRouter creates a new Caddy-based router
 func NewRouter(port int, domain string) *Router {
 	if domain == "" {
 		domain = "localhost"
 	}
 	return &Router{
-		routes: make(map[string]routeInfo),
-		port:   port,
-		domain: domain,
+		routes:   make(map[string]routeInfo),
+		nodes:    make(map[string][]string),
+		shares:   make(map[string]shareLink),
+		aliases:  make(map[string]string),
+		sleeping: make(map[string]bool),
+		access:   make(map[string]time.Time),
+
+		sharesChanged: make(chan struct{}, 1),
+		port:          port,
+		wantPort:      port,
+		domain:        domain,
+		load:          loadCaddyConfig,
+		validate:      validateCaddyConfig,
+		portFree:      portFree,
+		backoff:       startBackoff,
+		debounce:      reloadDelay,
+
+		endpoints: make(map[string][]Endpoint),
 	}
 }
 
+// portFree reports whether a TCP port can currently be bound
+func portFree(port int) bool {
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return false
+	}
+	ln.Close()
+	return true
+}
+
+// loadCaddyConfig replaces the running Caddy config
+func loadCaddyConfig(cfgJSON []byte) error {
+	return caddy.Load(cfgJSON, false)
+}
+
+// validateCaddyConfig provisions the config without starting it
+func validateCaddyConfig(cfgJSON []byte) error {
+	var cfg caddy.Config
+	if err := json.Unmarshal(cfgJSON, &cfg); err != nil {
+		return fmt.Errorf("decoding config: %w", err)
+	}
+	return caddy.Validate(&cfg)
+}
+
+// SetTLS configures the HTTPS listener; call before Start
+func (r *Router) SetTLS(opts TLSOptions) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tls = opts
+}
+
+// SetSharePort serves share links, and nothing else, on port of the
+// loopback interface, for a tunnel or Funnel to make public; call before
+// Start
+func (r *Router) SetSharePort(port int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sharePort = port
+}
+
+// SetEventHandler registers a callback for router events
+func (r *Router) SetEventHandler(fn func(Event)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onEvent = fn
+}
+
+// emit sends an event to the registered handler, if any
+func (r *Router) emit(eventType, message string, err error) {
+	if r.onEvent == nil {
+		return
+	}
+	r.onEvent(Event{Type: eventType, Message: message, Err: err, Time: time.Now()})
+}
+
 // SetTailnet enables Tailscale mode with the given tailnet name
 func (r *Router) SetTailnet(tailnet string) {
 	r.mu.Lock()
@@ -52,7 +278,16 @@ func (r *Router) SetTailnet(tailnet string) {
 	r.tailnet = tailnet
 }
 
-// Start initializes and starts the Caddy server
+// SetTailscaleDir keeps the state of the router's tailnet nodes under dir
+// (see TailscaleNodeDir), so each keeps its identity across restarts
+func (r *Router) SetTailscaleDir(dir string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tsDir = dir
+}
+
+// Start initializes and starts the Caddy server. If the configured port is
+// busy it retries with backoff, then falls back to the next free port.
 func (r *Router) Start() error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -61,20 +296,103 @@ func (r *Router) Start() error {
 		return nil
 	}
 
+	if err := r.start(); err != nil {
+		r.startErr = err
+		return err
+	}
+	r.startErr = nil
+	return nil
+}
+
+func (r *Router) start() error {
+	port, err := r.pickPort()
+	if err != nil {
+		return err
+	}
+	r.port = port
+
 	cfg := r.buildConfig()
 	cfgJSON, err := json.Marshal(cfg)
 	if err != nil {
 		return fmt.Errorf("marshaling config: %w", err)
 	}
 
-	if err := caddy.Load(cfgJSON, false); err != nil {
+	if r.child != nil {
+		if err := r.startChild(); err != nil {
+			return err
+		}
+	}
+	if err := r.load(cfgJSON); err != nil {
+		if r.child != nil {
+			r.stopChild()
+		}
 		return fmt.Errorf("loading caddy config: %w", err)
 	}
 
+	if port != r.wantPort {
+		r.emit(EventPortFallback, fmt.Sprintf("port %d busy, listening on %d", r.wantPort, port), nil)
+	}
+
+	r.cancelPending()
+	r.lastGood = cfgJSON
+	r.loaded = r.tables()
 	r.running = true
+	activeRouter.Store(r)
 	return nil
 }
 
+// pickPort waits for the configured port to free up, then falls back to
+// the next free port above it
+func (r *Router) pickPort() (int, error) {
+	delay := r.backoff
+	for attempt := 1; attempt <= startAttempts; attempt++ {
+		if r.portFree(r.wantPort) {
+			return r.wantPort, nil
+		}
+		if attempt < startAttempts {
+			time.Sleep(delay)
+			delay *= 2
+		}
+	}
+
+	for port := r.wantPort + 1; port <= r.wantPort+maxPortFallback && port <= 65535; port++ {
+		if r.portFree(port) {
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("port %d is busy and no free port found in %d-%d", r.wantPort, r.wantPort+1, r.wantPort+maxPortFallback)
+}
+
+// Restart stops the router and starts it again, retrying the configured port
+func (r *Router) Restart() error {
+	if err := r.Stop(); err != nil {
+		return err
+	}
+	return r.Start()
+}
+
+// Status returns the router's current state
+func (r *Router) Status() RouterStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	st := RouterStatus{
+		Running:       r.running,
+		Port:          r.port,
+		RequestedPort: r.wantPort,
+	}
+	if r.startErr != nil {
+		st.Error = r.startErr.Error()
+	}
+	if r.child != nil {
+		st.PID = r.child.pid()
+		st.Restarts = r.child.restarts
+	}
+	st.Reloads = r.reloads
+	st.Reloads.Pending = r.pending != nil
+	return st
+}
+
 // Stop shuts down the Caddy server
 func (r *Router) Stop() error {
 	r.mu.Lock()
@@ -84,22 +402,86 @@ func (r *Router) Stop() error {
 		return nil
 	}
 
-	if err := caddy.Stop(); err != nil {
+	if r.child != nil {
+		r.stopChild()
+	} else if err := caddy.Stop(); err != nil {
 		return fmt.Errorf("stopping caddy: %w", err)
 	}
+	r.cancelPending()
 
+	activeRouter.CompareAndSwap(r, nil)
 	r.running = false
 	return nil
 }
 
 // AddRoute adds or updates a route for a puck
-func (r *Router) AddRoute(puckName string, containerIP string, containerPort int) error {
+func (r *Router) AddRoute(puckName string, containerIP string, containerPort int, rc store.RouteConfig) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !routeNamePattern.MatchString(puckName) {
+		return fmt.Errorf("invalid route name %q", puckName)
+	}
+
+	prev, existed := r.routes[puckName]
+	r.routes[puckName] = routeInfo{IP: containerIP, Port: containerPort, Config: rc, Wake: r.wake != nil}
+	delete(r.sleeping, puckName)
+
+	if err := r.reload(); err != nil {
+		// Keep the route table in sync with what Caddy is serving
+		if existed {
+			r.routes[puckName] = prev
+		} else {
+			delete(r.routes, puckName)
+		}
+		return err
+	}
+	return nil
+}
+
+// ShareOnTailnet serves a puck as its own tailnet node with the given ACL
+// tags. The node only exists while the puck has a route.
+func (r *Router) ShareOnTailnet(puckName string, tags []string) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	r.routes[puckName] = routeInfo{IP: containerIP, Port: containerPort}
+	if r.tailnet == "" {
+		return fmt.Errorf("tailnet integration is not enabled")
+	}
+	if !routeNamePattern.MatchString(puckName) {
+		return fmt.Errorf("invalid route name %q", puckName)
+	}
+
+	prev, existed := r.nodes[puckName]
+	r.nodes[puckName] = tags
+
+	if err := r.reload(); err != nil {
+		if existed {
+			r.nodes[puckName] = prev
+		} else {
+			delete(r.nodes, puckName)
+		}
+		return err
+	}
+	return nil
+}
 
-	return r.reload()
+// UnshareFromTailnet removes a puck's tailnet node
+func (r *Router) UnshareFromTailnet(puckName string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	prev, existed := r.nodes[puckName]
+	if !existed {
+		return nil
+	}
+	delete(r.nodes, puckName)
+
+	if err := r.reload(); err != nil {
+		r.nodes[puckName] = prev
+		return err
+	}
+	return nil
 }
 
 // RemoveRoute removes a route for a puck
@@ -107,9 +489,52 @@ func (r *Router) RemoveRoute(puckName string) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	prev, existed := r.routes[puckName]
 	delete(r.routes, puckName)
+	delete(r.sleeping, puckName)
 
-	return r.reload()
+	if err := r.reload(); err != nil {
+		if existed {
+			r.routes[puckName] = prev
+		}
+		return err
+	}
+	return nil
+}
+
+// Rebuild forgets every route, tailnet node, share link, alias, endpoint
+// and sleeping puck, lets fn add them back, and only then loads the result
+// into Caddy, so requests never see a partial route table. Changes fn
+// makes are still validated one by one.
+func (r *Router) Rebuild(fn func()) error {
+	r.mu.Lock()
+	r.routes = make(map[string]routeInfo)
+	r.nodes = make(map[string][]string)
+	r.shares = make(map[string]shareLink)
+	r.aliases = make(map[string]string)
+	r.endpoints = make(map[string][]Endpoint)
+	r.sleeping = make(map[string]bool)
+	r.held = true
+	r.mu.Unlock()
+
+	fn()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.held = false
+	r.notifyShares()
+	if err := r.reload(); err != nil {
+		return err
+	}
+	return r.flush()
+}
+
+// Flush loads changes still waiting on the reload delay into Caddy at
+// once, for callers that need them served before going on
+func (r *Router) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.flush()
 }
 
 // GetRoutes returns all current routes
@@ -124,75 +549,142 @@ func (r *Router) GetRoutes() map[string]string {
 	return routes
 }
 
-// reload updates the Caddy config with current routes
+// reload updates the Caddy config with current routes.
+// The new config is validated before the change is accepted, then loaded
+// after the reload delay along with any other changes made meanwhile. If
+// Caddy still rejects it the last-known-good config is restored so
+// existing routes keep working.
 func (r *Router) reload() error {
 	if !r.running {
 		return nil
 	}
 
-	cfg := r.buildConfig()
-	cfgJSON, err := json.Marshal(cfg)
+	cfgJSON, err := json.Marshal(r.buildConfig())
+	if err != nil {
+		return fmt.Errorf("marshaling config: %w", err)
+	}
+
+	if err := r.validate(cfgJSON); err != nil {
+		r.emit(EventReloadFailed, "generated config failed validation", err)
+		return fmt.Errorf("validating caddy config: %w", err)
+	}
+	if r.held {
+		return nil
+	}
+
+	r.reloads.Changes++
+	if r.debounce == 0 {
+		return r.apply(cfgJSON)
+	}
+	if r.pending == nil {
+		r.pending = time.AfterFunc(r.debounce, r.loadPending)
+	}
+	return nil
+}
+
+// loadPending loads the changes a timer from reload was waiting on. A
+// load that fails here has no caller to tell, so it is only an event,
+// and flush has put back the tables Caddy still serves.
+func (r *Router) loadPending() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.flush()
+}
+
+// flush loads pending changes now. The changes were each accepted when
+// they were made, so if Caddy rejects them together the route tables go
+// back to those of the config it was rolled back to.
+func (r *Router) flush() error {
+	if r.pending == nil {
+		return nil
+	}
+	r.cancelPending()
+	if !r.running {
+		return nil
+	}
+	cfgJSON, err := json.Marshal(r.buildConfig())
 	if err != nil {
+		r.emit(EventReloadFailed, "generated config could not be marshaled", err)
 		return fmt.Errorf("marshaling config: %w", err)
 	}
+	if err := r.apply(cfgJSON); err != nil {
+		r.setTables(r.loaded)
+		return err
+	}
+	return nil
+}
+
+// cancelPending stops the timer of pending changes, which a load of the
+// whole config has made moot
+func (r *Router) cancelPending() {
+	if r.pending != nil {
+		r.pending.Stop()
+		r.pending = nil
+	}
+}
 
-	if err := caddy.Load(cfgJSON, false); err != nil {
+// apply loads a validated config into Caddy, rolling back to the
+// last-known-good config if Caddy rejects it
+func (r *Router) apply(cfgJSON []byte) error {
+	start := time.Now()
+	err := r.load(cfgJSON)
+	r.reloads.Loads++
+	r.reloads.Last = start
+	r.reloads.LastDuration = time.Since(start)
+	if err != nil {
+		r.reloads.Failed++
+		r.emit(EventReloadFailed, "caddy rejected new config", err)
+		if r.lastGood != nil {
+			if rbErr := r.load(r.lastGood); rbErr != nil {
+				r.emit(EventReloadFailed, "rollback to last-known-good config failed", rbErr)
+				return fmt.Errorf("reloading caddy config: %w (rollback failed: %v)", err, rbErr)
+			}
+			r.emit(EventRolledBack, "restored last-known-good config", err)
+		}
 		return fmt.Errorf("reloading caddy config: %w", err)
 	}
 
+	r.lastGood = cfgJSON
+	r.loaded = r.tables()
 	return nil
 }
 
 // buildConfig creates the Caddy configuration
 // Uses path-based routing: /puck-name/* -> puck backend
 func (r *Router) buildConfig() map[string]interface{} {
-	routes := make([]map[string]interface{}, 0)
+	// Share links come first so they are matched before anything else,
+	// then aliases, which take precedence over puck names, then endpoints,
+	// which take their paths under their puck's
+	routes := r.shareRoutes()
+	routes = append(routes, r.aliasRoutes()...)
+	routes = append(routes, r.endpointRoutes()...)
 
 	// Add routes for each puck using path-based routing
+	needsH2C := false
 	for name, info := range r.routes {
-		target := fmt.Sprintf("%s:%d", info.IP, info.Port)
+		if info.Config.Protocol == store.ProtocolH2C {
+			needsH2C = true
+		}
+
 		pathPrefix := fmt.Sprintf("/%s", name)
+		handlers := r.routeHandlers(name, info, pathPrefix)
 
 		route := map[string]interface{}{
 			"match": []map[string]interface{}{
 				{"path": []string{pathPrefix, pathPrefix + "/*"}},
 			},
-			"handle": []map[string]interface{}{
-				{
-					"handler": "rewrite",
-					"strip_path_prefix": pathPrefix,
-				},
-				{
-					"handler": "reverse_proxy",
-					"upstreams": []map[string]interface{}{
-						{"dial": target},
-					},
-				},
-			},
+			"handle": handlers,
 		}
 		routes = append(routes, route)
 	}
 
-	// Add a root route listing available pucks
-	puckList := "Available pucks:\n"
-	for name := range r.routes {
-		puckList += fmt.Sprintf("  /%s\n", name)
+	// Add a root route rendering the landing page
+	landing := map[string]interface{}{"handler": "puck_landing"}
+	if r.child != nil {
+		landing["callback"] = r.child.callbackSocket()
 	}
-	if len(r.routes) == 0 {
-		puckList = "No pucks found. Create one with: puck create <name>"
-	}
-
 	defaultRoute := map[string]interface{}{
-		"handle": []map[string]interface{}{
-			{
-				"handler":     "static_response",
-				"status_code": "200",
-				"body":        puckList,
-				"headers": map[string][]string{
-					"Content-Type": {"text/plain; charset=utf-8"},
-				},
-			},
-		},
+		"handle": []map[string]interface{}{landing},
 	}
 	routes = append(routes, defaultRoute)
 
@@ -202,6 +694,11 @@ func (r *Router) buildConfig() map[string]interface{} {
 		"routes": routes,
 	}
 
+	// Accept cleartext HTTP/2 from clients when any upstream is gRPC
+	if needsH2C {
+		serverConfig["protocols"] = []string{"h1", "h2", "h2c"}
+	}
+
 	// If tailnet is configured, add Tailscale listener for HTTPS
 	if r.tailnet != "" {
 		serverConfig["listen"] = []string{
@@ -210,13 +707,287 @@ func (r *Router) buildConfig() map[string]interface{} {
 		}
 	}
 
+	servers := map[string]interface{}{
+		"puck": serverConfig,
+	}
+	apps := map[string]interface{}{
+		"http": map[string]interface{}{
+			"servers": servers,
+		},
+	}
+
+	// Optional TLS listener sharing the same routes
+	if r.tls.Port > 0 {
+		servers["puck-tls"] = r.tlsServerConfig(routes)
+		apps["tls"] = r.tlsAppConfig()
+	}
+
+	// Share links get a listener of their own, so making them public
+	// doesn't make every puck and the landing page public too
+	if r.sharePort > 0 {
+		servers["puck-share"] = r.shareServerConfig()
+	}
+
+	// Pucks shared on the tailnet get their own node, so ACLs can target
+	// them by tag, serving the puck at the node's root
+	if r.tailnet != "" {
+		nodes := map[string]interface{}{}
+		if r.tsDir != "" {
+			// The router's own node, behind the tailscale/:443 listener
+			nodes[""] = map[string]interface{}{"state_dir": TailscaleNodeDir(r.tsDir, "")}
+		}
+		for name, tags := range r.nodes {
+			info, ok := r.routes[name]
+			if !ok {
+				continue
+			}
+			node := map[string]interface{}{"hostname": name}
+			if len(tags) > 0 {
+				node["tags"] = tags
+			}
+			if r.tsDir != "" {
+				node["state_dir"] = TailscaleNodeDir(r.tsDir, name)
+			}
+			nodes[name] = node
+			servers["puck-ts-"+name] = map[string]interface{}{
+				"listen": []string{fmt.Sprintf("tailscale/%s:443", name)},
+				"routes": []map[string]interface{}{
+					{"handle": r.routeHandlers(name, info, "")},
+				},
+			}
+		}
+		if len(nodes) > 0 {
+			apps["tailscale"] = map[string]interface{}{"nodes": nodes}
+		}
+	}
+
+	cfg := map[string]interface{}{
+		"apps": apps,
+	}
+	if r.child != nil {
+		cfg["admin"] = adminConfig(r.child.adminSocket())
+	}
+	return cfg
+}
+
+// tlsServerConfig builds the HTTPS server block for the TLS listener,
+// which serves everything the plain one does and so isn't for sharing
+func (r *Router) tlsServerConfig(routes []map[string]interface{}) map[string]interface{} {
+	protocols := []string{"h1", "h2"}
+	if r.tls.HTTP3 {
+		protocols = append(protocols, "h3")
+	}
+
+	policy := map[string]interface{}{}
+	if r.tls.CertFile != "" {
+		policy["certificate_selection"] = map[string]interface{}{
+			"any_tag": []string{tlsCertTag},
+		}
+	}
+
 	return map[string]interface{}{
-		"apps": map[string]interface{}{
-			"http": map[string]interface{}{
-				"servers": map[string]interface{}{
-					"puck": serverConfig,
+		"listen":                  []string{fmt.Sprintf(":%d", r.tls.Port)},
+		"routes":                  routes,
+		"protocols":               protocols,
+		"tls_connection_policies": []map[string]interface{}{policy},
+		// Never bind :80 for redirects; the plain listener already exists
+		"automatic_https": map[string]interface{}{
+			"disable_redirects": true,
+		},
+	}
+}
+
+// tlsAppConfig loads the provided certificate, or has Caddy's internal
+// CA issue one for the router domain, along with ACME certificates for
+// any public domains
+func (r *Router) tlsAppConfig() map[string]interface{} {
+	if r.tls.CertFile != "" {
+		return map[string]interface{}{
+			"certificates": map[string]interface{}{
+				"load_files": []map[string]interface{}{
+					{
+						"certificate": r.tls.CertFile,
+						"key":         r.tls.KeyFile,
+						"tags":        []string{tlsCertTag},
+					},
 				},
 			},
+		}
+	}
+
+	automate := []string{r.domain}
+	policies := []map[string]interface{}{
+		{
+			"subjects": []string{r.domain},
+			"issuers": []map[string]interface{}{
+				{"module": "internal"},
+			},
+		},
+	}
+	if len(r.tls.Domains) > 0 {
+		automate = append(automate, r.tls.Domains...)
+		// Ahead of the internal CA's, in case the router domain is one
+		policies = append([]map[string]interface{}{r.acmePolicy()}, policies...)
+	}
+
+	return map[string]interface{}{
+		"certificates": map[string]interface{}{
+			"automate": automate,
+		},
+		"automation": map[string]interface{}{
+			"policies": policies,
+		},
+	}
+}
+
+// acmePolicy has an ACME CA issue certificates for the public domains,
+// solving only DNS-01 challenges: they work for wildcard names and behind
+// NAT, and need neither port 80 nor 443
+func (r *Router) acmePolicy() map[string]interface{} {
+	issuer := map[string]interface{}{
+		"module": "acme",
+		"challenges": map[string]interface{}{
+			"http":     map[string]interface{}{"disabled": true},
+			"tls-alpn": map[string]interface{}{"disabled": true},
+		},
+	}
+	// The config was checked when loaded; without a provider, issuance
+	// fails and Caddy logs why
+	if provider, err := dnsProviderConfig(r.tls.DNSProvider, r.tls.DNSCredentials); err == nil {
+		issuer["challenges"].(map[string]interface{})["dns"] = map[string]interface{}{"provider": provider}
+	}
+	if r.tls.ACMEEmail != "" {
+		issuer["email"] = r.tls.ACMEEmail
+	}
+	if r.tls.ACMECA != "" {
+		issuer["ca"] = r.tls.ACMECA
+	}
+	return map[string]interface{}{
+		"subjects": r.tls.Domains,
+		"issuers":  []map[string]interface{}{issuer},
+	}
+}
+
+// routeHandlers builds the handler chain proxying to a puck. pathPrefix is
+// stripped before proxying; it is empty when the puck is served at the root.
+func (r *Router) routeHandlers(name string, info routeInfo, pathPrefix string) []map[string]interface{} {
+	target := fmt.Sprintf("%s:%d", info.IP, info.Port)
+
+	handlers := make([]map[string]interface{}, 0, 7)
+	handlers = append(handlers, map[string]interface{}{"handler": "puck_hits", "puck": name})
+	if info.Wake {
+		wake := map[string]interface{}{
+			"handler": "puck_wake",
+			"puck":    name,
+		}
+		if r.child != nil {
+			wake["callback"] = r.child.callbackSocket()
+		}
+		handlers = append(handlers, wake)
+	}
+	if info.Config.RateLimit > 0 && info.Config.RateLimitWindow > 0 {
+		handlers = append(handlers, map[string]interface{}{
+			"handler":  "puck_rate_limit",
+			"zone":     name,
+			"requests": info.Config.RateLimit,
+			"window":   int64(info.Config.RateLimitWindow),
+		})
+	}
+	if info.Config.MaxConns > 0 || info.Config.Bandwidth > 0 {
+		handlers = append(handlers, map[string]interface{}{
+			"handler":   "puck_traffic_limit",
+			"zone":      name,
+			"max_conns": info.Config.MaxConns,
+			"bandwidth": info.Config.Bandwidth,
+		})
+	}
+	if info.Config.MaxBodySize > 0 {
+		handlers = append(handlers, map[string]interface{}{
+			"handler":  "request_body",
+			"max_size": info.Config.MaxBodySize,
+		})
+	}
+	if pathPrefix != "" {
+		handlers = append(handlers, map[string]interface{}{
+			"handler":           "rewrite",
+			"strip_path_prefix": pathPrefix,
+		})
+	}
+	if len(info.Config.Rewrites) > 0 {
+		rules := make([]map[string]interface{}, 0, len(info.Config.Rewrites))
+		for _, rw := range info.Config.Rewrites {
+			rules = append(rules, map[string]interface{}{"find": rw.Find, "replace": rw.Replace})
+		}
+		handlers = append(handlers, map[string]interface{}{
+			"handler":     "rewrite",
+			"path_regexp": rules,
+		})
+	}
+	return append(handlers, proxyHandler(target, pathPrefix, info.Config))
+}
+
+// proxyHandler builds the reverse_proxy handler for a puck route.
+// Responses are flushed immediately by default so SSE and streaming
+// dev servers work, and upgraded connections outlive config reloads.
+func proxyHandler(target, pathPrefix string, rc store.RouteConfig) map[string]interface{} {
+	flushInterval := rc.FlushInterval
+	if flushInterval == 0 {
+		flushInterval = -1
+	}
+	closeDelay := rc.StreamCloseDelay
+	if closeDelay == 0 {
+		closeDelay = DefaultStreamCloseDelay
+	}
+
+	// HTTP/1.1 upstreams are required for Upgrade: websocket, while
+	// h2c upstreams get HTTP/2 end to end so gRPC trailers pass through
+	versions := []string{"1.1"}
+	if rc.Protocol == store.ProtocolH2C {
+		versions = []string{"h2c", "2"}
+	}
+	transport := map[string]interface{}{
+		"protocol": "http",
+		"versions": versions,
+	}
+	if rc.ReadTimeout > 0 {
+		transport["read_timeout"] = int64(rc.ReadTimeout)
+	}
+
+	handler := map[string]interface{}{
+		"handler": "reverse_proxy",
+		"upstreams": []map[string]interface{}{
+			{"dial": target},
 		},
+		"flush_interval":     int64(flushInterval),
+		"stream_close_delay": int64(closeDelay),
+		"transport":          transport,
+	}
+	if rc.StreamTimeout > 0 {
+		handler["stream_timeout"] = int64(rc.StreamTimeout)
+	}
+
+	requestHeaders := make(map[string][]string)
+	for name, value := range rc.RequestHeaders {
+		requestHeaders[name] = []string{value}
 	}
+	if rc.ForwardedPrefix && pathPrefix != "" {
+		requestHeaders["X-Forwarded-Prefix"] = []string{pathPrefix}
+	}
+	responseHeaders := make(map[string][]string)
+	for name, value := range rc.ResponseHeaders {
+		responseHeaders[name] = []string{value}
+	}
+
+	headers := map[string]interface{}{}
+	if len(requestHeaders) > 0 {
+		headers["request"] = map[string]interface{}{"set": requestHeaders}
+	}
+	if len(responseHeaders) > 0 {
+		headers["response"] = map[string]interface{}{"set": responseHeaders}
+	}
+	if len(headers) > 0 {
+		handler["headers"] = headers
+	}
+
+	return handler
 }
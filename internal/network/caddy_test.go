@@ -1,8 +1,11 @@
 package network
 
 import (
+	"encoding/json"
 	"testing"
+	"time"
 
+	"github.com/sandwich-labs/puck/internal/store"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -139,54 +142,666 @@ func TestBuildConfig(t *testing.T) {
 		require.True(t, ok)
 		assert.NotEmpty(t, handlers)
 
-		// Should have static_response handler
-		var hasStaticResponse bool
-		for _, h := range handlers {
-			if h["handler"] == "static_response" {
-				hasStaticResponse = true
-			}
-		}
-		assert.True(t, hasStaticResponse, "should have static_response handler for default route")
+		assert.Equal(t, "puck_landing", handlers[0]["handler"])
 	})
 
-	t.Run("default route shows no pucks message when empty", func(t *testing.T) {
+	t.Run("adds tailscale listener when tailnet set", func(t *testing.T) {
 		router := NewRouter(8080, "localhost")
+		router.SetTailnet("my-tailnet")
 		config := router.buildConfig()
 
 		apps := config["apps"].(map[string]interface{})
 		http := apps["http"].(map[string]interface{})
 		servers := http["servers"].(map[string]interface{})
 		puckServer := servers["puck"].(map[string]interface{})
-		routes := puckServer["routes"].([]map[string]interface{})
+		listen := puckServer["listen"].([]string)
 
-		// Find static_response body
-		lastRoute := routes[len(routes)-1]
-		handlers := lastRoute["handle"].([]map[string]interface{})
+		// Should have both regular and tailscale listeners
+		assert.Len(t, listen, 2)
+		assert.Contains(t, listen, ":8080")
+		assert.Contains(t, listen, "tailscale/:443")
+	})
+}
+
+func TestProxyHandler(t *testing.T) {
+	t.Run("streams and survives reloads by default", func(t *testing.T) {
+		h := proxyHandler("127.0.0.1:9000", "/web", store.RouteConfig{})
+
+		assert.Equal(t, "reverse_proxy", h["handler"])
+		assert.Equal(t, int64(-1), h["flush_interval"])
+		assert.Equal(t, int64(DefaultStreamCloseDelay), h["stream_close_delay"])
+		assert.NotContains(t, h, "stream_timeout")
+
+		transport := h["transport"].(map[string]interface{})
+		assert.Equal(t, []string{"1.1"}, transport["versions"])
+		assert.NotContains(t, transport, "read_timeout")
+	})
+
+	t.Run("applies per-route settings", func(t *testing.T) {
+		h := proxyHandler("127.0.0.1:9000", "/web", store.RouteConfig{
+			FlushInterval:    100 * time.Millisecond,
+			ReadTimeout:      time.Minute,
+			StreamTimeout:    time.Hour,
+			StreamCloseDelay: 30 * time.Second,
+		})
+
+		assert.Equal(t, int64(100*time.Millisecond), h["flush_interval"])
+		assert.Equal(t, int64(time.Hour), h["stream_timeout"])
+		assert.Equal(t, int64(30*time.Second), h["stream_close_delay"])
+
+		transport := h["transport"].(map[string]interface{})
+		assert.Equal(t, int64(time.Minute), transport["read_timeout"])
+	})
+}
+
+// puckServerConfig digs the puck server block out of a generated config
+func puckServerConfig(router *Router) map[string]interface{} {
+	apps := router.buildConfig()["apps"].(map[string]interface{})
+	http := apps["http"].(map[string]interface{})
+	servers := http["servers"].(map[string]interface{})
+	return servers["puck"].(map[string]interface{})
+}
+
+func TestRouteHeadersAndRewrites(t *testing.T) {
+	t.Run("sets forwarded prefix and custom headers", func(t *testing.T) {
+		h := proxyHandler("127.0.0.1:9000", "/web", store.RouteConfig{
+			ForwardedPrefix: true,
+			RequestHeaders:  map[string]string{"X-Env": "dev"},
+			ResponseHeaders: map[string]string{"Cache-Control": "no-store"},
+		})
+
+		headers := h["headers"].(map[string]interface{})
+		request := headers["request"].(map[string]interface{})["set"].(map[string][]string)
+		assert.Equal(t, []string{"/web"}, request["X-Forwarded-Prefix"])
+		assert.Equal(t, []string{"dev"}, request["X-Env"])
+
+		response := headers["response"].(map[string]interface{})["set"].(map[string][]string)
+		assert.Equal(t, []string{"no-store"}, response["Cache-Control"])
+	})
+
+	t.Run("omits headers when none are configured", func(t *testing.T) {
+		h := proxyHandler("127.0.0.1:9000", "/web", store.RouteConfig{})
+		assert.NotContains(t, h, "headers")
+	})
+
+	t.Run("adds rewrite handler after prefix strip", func(t *testing.T) {
+		router := NewRouter(8080, "localhost")
+		router.routes["api"] = routeInfo{IP: "127.0.0.1", Port: 9000, Config: store.RouteConfig{
+			Rewrites: []store.Rewrite{{Find: "^/v1/(.*)", Replace: "/api/$1"}},
+		}}
+
+		routes := puckServerConfig(router)["routes"].([]map[string]interface{})
+		handlers := routes[0]["handle"].([]map[string]interface{})
+		require.Len(t, handlers, 4)
+		assert.Equal(t, "/api", handlers[1]["strip_path_prefix"])
+		rules := handlers[2]["path_regexp"].([]map[string]interface{})
+		assert.Equal(t, "^/v1/(.*)", rules[0]["find"])
+		assert.Equal(t, "/api/$1", rules[0]["replace"])
+		assert.Equal(t, "reverse_proxy", handlers[3]["handler"])
+	})
+}
+
+func TestRouteLimits(t *testing.T) {
+	t.Run("adds rate limit and body size handlers after counting hits", func(t *testing.T) {
+		router := NewRouter(8080, "localhost")
+		router.routes["web"] = routeInfo{IP: "127.0.0.1", Port: 9000, Config: store.RouteConfig{
+			RateLimit:       60,
+			RateLimitWindow: time.Minute,
+			MaxBodySize:     1 << 20,
+		}}
+
+		routes := puckServerConfig(router)["routes"].([]map[string]interface{})
+		handlers := routes[0]["handle"].([]map[string]interface{})
+		require.Len(t, handlers, 5)
+		assert.Equal(t, map[string]interface{}{"handler": "puck_hits", "puck": "web"}, handlers[0])
+		assert.Equal(t, "puck_rate_limit", handlers[1]["handler"])
+		assert.Equal(t, "web", handlers[1]["zone"])
+		assert.Equal(t, 60, handlers[1]["requests"])
+		assert.Equal(t, int64(time.Minute), handlers[1]["window"])
+		assert.Equal(t, "request_body", handlers[2]["handler"])
+		assert.Equal(t, int64(1<<20), handlers[2]["max_size"])
+	})
+
+	t.Run("adds traffic limit handler", func(t *testing.T) {
+		router := NewRouter(8080, "localhost")
+		router.routes["web"] = routeInfo{IP: "127.0.0.1", Port: 9000, Config: store.RouteConfig{
+			MaxConns:  20,
+			Bandwidth: 1 << 20,
+		}}
+
+		routes := puckServerConfig(router)["routes"].([]map[string]interface{})
+		handlers := routes[0]["handle"].([]map[string]interface{})
+		require.Len(t, handlers, 4)
+		assert.Equal(t, "puck_traffic_limit", handlers[1]["handler"])
+		assert.Equal(t, "web", handlers[1]["zone"])
+		assert.Equal(t, 20, handlers[1]["max_conns"])
+		assert.Equal(t, int64(1<<20), handlers[1]["bandwidth"])
+	})
+
+	t.Run("omits limit handlers by default", func(t *testing.T) {
+		router := NewRouter(8080, "localhost")
+		router.routes["web"] = routeInfo{IP: "127.0.0.1", Port: 9000}
+
+		routes := puckServerConfig(router)["routes"].([]map[string]interface{})
+		handlers := routes[0]["handle"].([]map[string]interface{})
+		require.Len(t, handlers, 3)
+	})
+}
+
+// TestBuildConfigValidates runs generated configs through Caddy's own
+// provisioning to catch malformed JSON for every route option.
+func TestBuildConfigValidates(t *testing.T) {
+	router := NewRouter(18080, "localhost")
+	router.SetTLS(TLSOptions{Port: 18443, HTTP3: true})
+	router.routes["web"] = routeInfo{IP: "127.0.0.1", Port: 9000, Config: store.RouteConfig{
+		ReadTimeout:     time.Minute,
+		StreamTimeout:   time.Hour,
+		ForwardedPrefix: true,
+		ResponseHeaders: map[string]string{"Cache-Control": "no-store"},
+		Rewrites:        []store.Rewrite{{Find: "^/v1/(.*)", Replace: "/api/$1"}},
+		RateLimit:       10,
+		RateLimitWindow: time.Second,
+		MaxBodySize:     1024,
+		MaxConns:        5,
+		Bandwidth:       1 << 16,
+	}}
+	router.routes["grpc"] = routeInfo{IP: "127.0.0.1", Port: 9001, Config: store.RouteConfig{
+		Protocol: store.ProtocolH2C,
+	}}
+
+	cfgJSON, err := json.Marshal(router.buildConfig())
+	require.NoError(t, err)
+	assert.NoError(t, validateCaddyConfig(cfgJSON))
+}
+
+func TestTailnetNodes(t *testing.T) {
+	newTailnetRouter := func() *Router {
+		router := NewRouter(8080, "localhost")
+		router.SetTailnet("example.ts.net")
+		router.routes["web"] = routeInfo{IP: "127.0.0.1", Port: 9000, Config: store.RouteConfig{ForwardedPrefix: true}}
+		return router
+	}
+
+	t.Run("requires tailnet", func(t *testing.T) {
+		router := NewRouter(8080, "localhost")
+		assert.Error(t, router.ShareOnTailnet("web", nil))
+	})
+
+	t.Run("serves shared puck from its own tagged node", func(t *testing.T) {
+		router := newTailnetRouter()
+		require.NoError(t, router.ShareOnTailnet("web", []string{"tag:dev"}))
+
+		cfg := router.buildConfig()
+		apps := cfg["apps"].(map[string]interface{})
+		servers := apps["http"].(map[string]interface{})["servers"].(map[string]interface{})
+
+		server := servers["puck-ts-web"].(map[string]interface{})
+		assert.Equal(t, []string{"tailscale/web:443"}, server["listen"])
+
+		// Served at the root, so no prefix is stripped or forwarded
+		handlers := server["routes"].([]map[string]interface{})[0]["handle"].([]map[string]interface{})
+		require.Len(t, handlers, 2)
+		assert.Equal(t, "reverse_proxy", handlers[1]["handler"])
+		assert.NotContains(t, handlers[1], "headers")
+
+		nodes := apps["tailscale"].(map[string]interface{})["nodes"].(map[string]interface{})
+		assert.Equal(t, map[string]interface{}{"hostname": "web", "tags": []string{"tag:dev"}}, nodes["web"])
+
+		cfgJSON, err := json.Marshal(cfg)
+		require.NoError(t, err)
+		assert.NoError(t, validateCaddyConfig(cfgJSON))
+	})
+
+	t.Run("keeps node state under the tailscale directory", func(t *testing.T) {
+		router := newTailnetRouter()
+		router.SetTailscaleDir("/data/tailscale/caddy")
+		require.NoError(t, router.ShareOnTailnet("web", nil))
+
+		cfg := router.buildConfig()
+		nodes := cfg["apps"].(map[string]interface{})["tailscale"].(map[string]interface{})["nodes"].(map[string]interface{})
+		assert.Equal(t, map[string]interface{}{"state_dir": "/data/tailscale/caddy/router"}, nodes[""])
+		assert.Equal(t, "/data/tailscale/caddy/pucks/web", nodes["web"].(map[string]interface{})["state_dir"])
+
+		cfgJSON, err := json.Marshal(cfg)
+		require.NoError(t, err)
+		assert.NoError(t, validateCaddyConfig(cfgJSON))
+	})
+
+	t.Run("skips nodes for pucks without a route", func(t *testing.T) {
+		router := newTailnetRouter()
+		require.NoError(t, router.ShareOnTailnet("api", []string{"tag:dev"}))
+
+		apps := router.buildConfig()["apps"].(map[string]interface{})
+		servers := apps["http"].(map[string]interface{})["servers"].(map[string]interface{})
+		assert.NotContains(t, servers, "puck-ts-api")
+		assert.NotContains(t, apps, "tailscale")
+	})
+
+	t.Run("unshare removes the node", func(t *testing.T) {
+		router := newTailnetRouter()
+		require.NoError(t, router.ShareOnTailnet("web", nil))
+		require.NoError(t, router.UnshareFromTailnet("web"))
+
+		apps := router.buildConfig()["apps"].(map[string]interface{})
+		assert.NotContains(t, apps, "tailscale")
+	})
+}
+
+func TestH2CRoutes(t *testing.T) {
+	t.Run("uses HTTP/2 cleartext upstream transport", func(t *testing.T) {
+		h := proxyHandler("127.0.0.1:9000", "/web", store.RouteConfig{Protocol: store.ProtocolH2C})
 
-		var body string
-		for _, h := range handlers {
-			if h["handler"] == "static_response" {
-				body = h["body"].(string)
+		transport := h["transport"].(map[string]interface{})
+		assert.Equal(t, []string{"h2c", "2"}, transport["versions"])
+	})
+
+	t.Run("enables h2c on the listener only when needed", func(t *testing.T) {
+		router := NewRouter(8080, "localhost")
+		router.routes["web"] = routeInfo{IP: "127.0.0.1", Port: 9000}
+
+		server := puckServerConfig(router)
+		assert.NotContains(t, server, "protocols")
+
+		router.routes["grpc"] = routeInfo{IP: "127.0.0.1", Port: 9001, Config: store.RouteConfig{Protocol: store.ProtocolH2C}}
+
+		server = puckServerConfig(router)
+		assert.Equal(t, []string{"h1", "h2", "h2c"}, server["protocols"])
+	})
+}
+
+func TestTLSListener(t *testing.T) {
+	servers := func(router *Router) map[string]interface{} {
+		apps := router.buildConfig()["apps"].(map[string]interface{})
+		return apps["http"].(map[string]interface{})["servers"].(map[string]interface{})
+	}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		router := NewRouter(8080, "localhost")
+		config := router.buildConfig()
+
+		assert.NotContains(t, servers(router), "puck-tls")
+		assert.NotContains(t, config["apps"], "tls")
+	})
+
+	t.Run("uses internal CA when no cert is provided", func(t *testing.T) {
+		router := NewRouter(8080, "puck.local")
+		router.SetTLS(TLSOptions{Port: 8443})
+
+		tlsServer := servers(router)["puck-tls"].(map[string]interface{})
+		assert.Equal(t, []string{":8443"}, tlsServer["listen"])
+		assert.Equal(t, []string{"h1", "h2"}, tlsServer["protocols"])
+
+		tlsApp := router.buildConfig()["apps"].(map[string]interface{})["tls"].(map[string]interface{})
+		certs := tlsApp["certificates"].(map[string]interface{})
+		assert.Equal(t, []string{"puck.local"}, certs["automate"])
+		assert.Contains(t, tlsApp, "automation")
+	})
+
+	t.Run("loads provided certificate files", func(t *testing.T) {
+		router := NewRouter(8080, "localhost")
+		router.SetTLS(TLSOptions{Port: 8443, CertFile: "/c.pem", KeyFile: "/k.pem"})
+
+		tlsApp := router.buildConfig()["apps"].(map[string]interface{})["tls"].(map[string]interface{})
+		certs := tlsApp["certificates"].(map[string]interface{})
+		files := certs["load_files"].([]map[string]interface{})
+		require.Len(t, files, 1)
+		assert.Equal(t, "/c.pem", files[0]["certificate"])
+		assert.Equal(t, "/k.pem", files[0]["key"])
+		assert.NotContains(t, tlsApp, "automation")
+	})
+
+	t.Run("gets ACME certificates for public domains over DNS-01", func(t *testing.T) {
+		router := NewRouter(8080, "localhost")
+		router.SetTLS(TLSOptions{
+			Port:           8443,
+			Domains:        []string{"*.pucks.example.com", "pucks.example.com"},
+			ACMEEmail:      "me@example.com",
+			DNSProvider:    DNSCloudflare,
+			DNSCredentials: map[string]string{"api_token": "secret"},
+		})
+
+		tlsApp := router.buildConfig()["apps"].(map[string]interface{})["tls"].(map[string]interface{})
+		certs := tlsApp["certificates"].(map[string]interface{})
+		assert.Equal(t, []string{"localhost", "*.pucks.example.com", "pucks.example.com"}, certs["automate"])
+
+		policies := tlsApp["automation"].(map[string]interface{})["policies"].([]map[string]interface{})
+		require.Len(t, policies, 2)
+		assert.Equal(t, []string{"*.pucks.example.com", "pucks.example.com"}, policies[0]["subjects"])
+		issuer := policies[0]["issuers"].([]map[string]interface{})[0]
+		assert.Equal(t, "acme", issuer["module"])
+		assert.Equal(t, "me@example.com", issuer["email"])
+		challenges := issuer["challenges"].(map[string]interface{})
+		assert.Equal(t, map[string]interface{}{"disabled": true}, challenges["http"])
+		assert.Equal(t, map[string]interface{}{"name": "puck_cloudflare", "api_token": "secret"},
+			challenges["dns"].(map[string]interface{})["provider"])
+		assert.Equal(t, []string{"localhost"}, policies[1]["subjects"])
+
+		cfgJSON, err := json.Marshal(router.buildConfig())
+		require.NoError(t, err)
+		assert.NoError(t, validateCaddyConfig(cfgJSON))
+	})
+
+	t.Run("adds HTTP/3 when enabled", func(t *testing.T) {
+		router := NewRouter(8080, "localhost")
+		router.SetTLS(TLSOptions{Port: 8443, HTTP3: true})
+
+		tlsServer := servers(router)["puck-tls"].(map[string]interface{})
+		assert.Equal(t, []string{"h1", "h2", "h3"}, tlsServer["protocols"])
+	})
+}
+
+func TestStart(t *testing.T) {
+	// newTestRouter returns a router whose listed ports are busy
+	newTestRouter := func(busy ...int) (*Router, *[]int) {
+		router := NewRouter(8080, "localhost")
+		router.load = func(cfgJSON []byte) error { return nil }
+		router.backoff = time.Millisecond
+
+		var probed []int
+		router.portFree = func(port int) bool {
+			probed = append(probed, port)
+			for _, b := range busy {
+				if b == port {
+					return false
+				}
 			}
+			return true
 		}
-		assert.Contains(t, body, "No pucks found")
+		return router, &probed
+	}
+
+	t.Run("uses configured port when free", func(t *testing.T) {
+		router, probed := newTestRouter()
+
+		require.NoError(t, router.Start())
+		assert.Equal(t, []int{8080}, *probed)
+		assert.Equal(t, RouterStatus{Running: true, Port: 8080, RequestedPort: 8080}, router.Status())
 	})
 
-	t.Run("adds tailscale listener when tailnet set", func(t *testing.T) {
+	t.Run("retries before falling back to next free port", func(t *testing.T) {
+		router, probed := newTestRouter(8080, 8081)
+
+		var events []Event
+		router.SetEventHandler(func(ev Event) { events = append(events, ev) })
+
+		require.NoError(t, router.Start())
+		assert.Equal(t, []int{8080, 8080, 8080, 8080, 8081, 8082}, *probed)
+
+		st := router.Status()
+		assert.True(t, st.Running)
+		assert.Equal(t, 8082, st.Port)
+		assert.Equal(t, 8080, st.RequestedPort)
+
+		require.Len(t, events, 1)
+		assert.Equal(t, EventPortFallback, events[0].Type)
+
+		listen := puckServerConfig(router)["listen"].([]string)
+		assert.Equal(t, []string{":8082"}, listen)
+	})
+
+	t.Run("fails when no port is free", func(t *testing.T) {
+		router, _ := newTestRouter()
+		router.portFree = func(port int) bool { return false }
+
+		err := router.Start()
+		require.Error(t, err)
+
+		st := router.Status()
+		assert.False(t, st.Running)
+		assert.Contains(t, st.Error, "port 8080 is busy")
+	})
+
+	t.Run("retries configured port on restart", func(t *testing.T) {
+		busy := true
+		router, _ := newTestRouter()
+		router.portFree = func(port int) bool { return !(busy && port == 8080) }
+
+		require.NoError(t, router.Start())
+		assert.Equal(t, 8081, router.Status().Port)
+
+		busy = false
+		require.NoError(t, router.Restart())
+		assert.Equal(t, 8080, router.Status().Port)
+	})
+}
+
+func TestReload(t *testing.T) {
+	// newTestRouter returns a running router whose Caddy hooks are stubbed,
+	// and which loads each change as it is made
+	newTestRouter := func() (*Router, *[][]byte) {
 		router := NewRouter(8080, "localhost")
-		router.SetTailnet("my-tailnet")
-		config := router.buildConfig()
+		router.debounce = 0
+		var loaded [][]byte
+		router.load = func(cfgJSON []byte) error {
+			loaded = append(loaded, cfgJSON)
+			return nil
+		}
+		router.validate = func(cfgJSON []byte) error { return nil }
+		router.portFree = func(port int) bool { return true }
+		require.NoError(t, router.Start())
+		return router, &loaded
+	}
+
+	t.Run("records last-known-good config on success", func(t *testing.T) {
+		router, loaded := newTestRouter()
+
+		require.NoError(t, router.AddRoute("web", "127.0.0.1", 9000, store.RouteConfig{}))
+		assert.Len(t, *loaded, 2)
+		assert.Equal(t, (*loaded)[1], router.lastGood)
+	})
 
-		apps := config["apps"].(map[string]interface{})
-		http := apps["http"].(map[string]interface{})
-		servers := http["servers"].(map[string]interface{})
-		puckServer := servers["puck"].(map[string]interface{})
-		listen := puckServer["listen"].([]string)
+	t.Run("rejects invalid route names", func(t *testing.T) {
+		router, loaded := newTestRouter()
 
-		// Should have both regular and tailscale listeners
-		assert.Len(t, listen, 2)
-		assert.Contains(t, listen, ":8080")
-		assert.Contains(t, listen, "tailscale/:443")
+		err := router.AddRoute("bad name/*", "127.0.0.1", 9000, store.RouteConfig{})
+		assert.Error(t, err)
+		assert.Empty(t, router.routes)
+		assert.Len(t, *loaded, 1)
+	})
+
+	t.Run("does not load config that fails validation", func(t *testing.T) {
+		router, loaded := newTestRouter()
+		router.validate = func(cfgJSON []byte) error { return assert.AnError }
+
+		var events []Event
+		router.SetEventHandler(func(ev Event) { events = append(events, ev) })
+
+		err := router.AddRoute("web", "127.0.0.1", 9000, store.RouteConfig{})
+		assert.Error(t, err)
+		assert.Len(t, *loaded, 1)
+		assert.NotContains(t, router.routes, "web")
+		require.Len(t, events, 1)
+		assert.Equal(t, EventReloadFailed, events[0].Type)
+	})
+
+	t.Run("rolls back to last-known-good config on load failure", func(t *testing.T) {
+		router, _ := newTestRouter()
+		require.NoError(t, router.AddRoute("web", "127.0.0.1", 9000, store.RouteConfig{}))
+		good := router.lastGood
+
+		var loaded [][]byte
+		router.load = func(cfgJSON []byte) error {
+			loaded = append(loaded, cfgJSON)
+			if len(loaded) == 1 {
+				return assert.AnError
+			}
+			return nil
+		}
+
+		var events []Event
+		router.SetEventHandler(func(ev Event) { events = append(events, ev) })
+
+		err := router.AddRoute("api", "127.0.0.1", 9001, store.RouteConfig{})
+		assert.Error(t, err)
+
+		// Second load is the rollback to the previous config
+		require.Len(t, loaded, 2)
+		assert.Equal(t, good, loaded[1])
+		assert.Equal(t, good, router.lastGood)
+
+		// Existing routes survive, the failed one is dropped
+		assert.Contains(t, router.routes, "web")
+		assert.NotContains(t, router.routes, "api")
+
+		require.Len(t, events, 2)
+		assert.Equal(t, EventReloadFailed, events[0].Type)
+		assert.Equal(t, EventRolledBack, events[1].Type)
+	})
+
+	t.Run("restores removed route when reload fails", func(t *testing.T) {
+		router, _ := newTestRouter()
+		require.NoError(t, router.AddRoute("web", "127.0.0.1", 9000, store.RouteConfig{}))
+
+		router.load = func(cfgJSON []byte) error { return assert.AnError }
+
+		err := router.RemoveRoute("web")
+		assert.Error(t, err)
+		assert.Equal(t, routeInfo{IP: "127.0.0.1", Port: 9000}, router.routes["web"])
+	})
+
+	t.Run("rebuilds the route table in one load", func(t *testing.T) {
+		router, loaded := newTestRouter()
+		require.NoError(t, router.AddRoute("old", "127.0.0.1", 9000, store.RouteConfig{}))
+		require.NoError(t, router.SetAlias("/legacy", "old"))
+		*loaded = nil
+
+		err := router.Rebuild(func() {
+			assert.NoError(t, router.AddRoute("web", "127.0.0.1", 9001, store.RouteConfig{}))
+			assert.NoError(t, router.AddRoute("api", "127.0.0.1", 9002, store.RouteConfig{}))
+			assert.NoError(t, router.SetAlias("/app", "web"))
+		})
+		require.NoError(t, err)
+
+		require.Len(t, *loaded, 1)
+		assert.Equal(t, map[string]string{"web": "127.0.0.1:9001", "api": "127.0.0.1:9002"}, router.GetRoutes())
+		assert.Equal(t, map[string]string{"/app": "web"}, router.GetAliases())
+		assert.Equal(t, (*loaded)[0], router.lastGood)
+	})
+
+	t.Run("drops what fails validation during a rebuild", func(t *testing.T) {
+		router, loaded := newTestRouter()
+		*loaded = nil
+
+		err := router.Rebuild(func() {
+			assert.NoError(t, router.AddRoute("web", "127.0.0.1", 9001, store.RouteConfig{}))
+			router.validate = func(cfgJSON []byte) error { return assert.AnError }
+			assert.Error(t, router.AddRoute("api", "127.0.0.1", 9002, store.RouteConfig{}))
+			router.validate = func(cfgJSON []byte) error { return nil }
+		})
+		require.NoError(t, err)
+
+		require.Len(t, *loaded, 1)
+		assert.Equal(t, map[string]string{"web": "127.0.0.1:9001"}, router.GetRoutes())
+	})
+}
+
+func TestReloadDebounce(t *testing.T) {
+	// newTestRouter returns a running router whose Caddy hooks are stubbed
+	newTestRouter := func() (*Router, chan []byte) {
+		router := NewRouter(8080, "localhost")
+		router.debounce = 20 * time.Millisecond
+		loaded := make(chan []byte, 10)
+		router.load = func(cfgJSON []byte) error {
+			loaded <- cfgJSON
+			return nil
+		}
+		router.validate = func(cfgJSON []byte) error { return nil }
+		router.portFree = func(port int) bool { return true }
+		require.NoError(t, router.Start())
+		<-loaded
+		return router, loaded
+	}
+
+	t.Run("loads a burst of changes once", func(t *testing.T) {
+		router, loaded := newTestRouter()
+
+		for i, name := range []string{"web", "api", "db"} {
+			require.NoError(t, router.AddRoute(name, "127.0.0.1", 9000+i, store.RouteConfig{}))
+		}
+		require.NoError(t, router.RemoveRoute("db"))
+		assert.Empty(t, loaded, "nothing is loaded before the delay")
+		assert.True(t, router.Status().Reloads.Pending)
+
+		select {
+		case cfgJSON := <-loaded:
+			assert.Contains(t, string(cfgJSON), "/api/*")
+			assert.NotContains(t, string(cfgJSON), "/db/*")
+		case <-time.After(time.Second):
+			t.Fatal("the changes were never loaded")
+		}
+		time.Sleep(50 * time.Millisecond)
+		assert.Empty(t, loaded)
+
+		st := router.Status().Reloads
+		assert.Equal(t, 4, st.Changes)
+		assert.Equal(t, 1, st.Loads)
+		assert.False(t, st.Pending)
+	})
+
+	t.Run("rejects a change that fails validation without loading", func(t *testing.T) {
+		router, loaded := newTestRouter()
+		router.validate = func(cfgJSON []byte) error { return assert.AnError }
+
+		assert.Error(t, router.AddRoute("web", "127.0.0.1", 9000, store.RouteConfig{}))
+		assert.NotContains(t, router.routes, "web")
+		assert.False(t, router.Status().Reloads.Pending)
+		assert.Empty(t, loaded)
+	})
+
+	t.Run("flushes pending changes on demand", func(t *testing.T) {
+		router, loaded := newTestRouter()
+
+		require.NoError(t, router.AddRoute("web", "127.0.0.1", 9000, store.RouteConfig{}))
+		require.NoError(t, router.Flush())
+		require.Len(t, loaded, 1)
+		assert.Equal(t, <-loaded, router.lastGood)
+
+		time.Sleep(50 * time.Millisecond)
+		assert.Empty(t, loaded, "the timer doesn't load again")
+	})
+
+	t.Run("puts the route tables back when the load fails", func(t *testing.T) {
+		router, loaded := newTestRouter()
+		require.NoError(t, router.AddRoute("web", "127.0.0.1", 9000, store.RouteConfig{}))
+		require.NoError(t, router.Flush())
+		good := <-loaded
+
+		router.mu.Lock()
+		router.load = func(cfgJSON []byte) error {
+			loaded <- cfgJSON
+			if string(cfgJSON) != string(good) {
+				return assert.AnError
+			}
+			return nil
+		}
+		router.mu.Unlock()
+		require.NoError(t, router.RemoveRoute("web"))
+		require.NoError(t, router.AddRoute("api", "127.0.0.1", 9001, store.RouteConfig{}))
+		require.NoError(t, router.SetAlias("/docs", "api"))
+		require.NoError(t, router.AddShare("token", "api", time.Now().Add(time.Hour)))
+
+		for range 2 {
+			select {
+			case <-loaded:
+			case <-time.After(time.Second):
+				t.Fatal("the changes were never loaded")
+			}
+		}
+		assert.Eventually(t, func() bool { return !router.Status().Reloads.Pending }, time.Second, 10*time.Millisecond)
+		assert.Equal(t, map[string]string{"web": "127.0.0.1:9000"}, router.GetRoutes())
+		assert.Empty(t, router.GetAliases())
+		assert.False(t, router.HasShares())
+		assert.Equal(t, good, router.lastGood)
+	})
+
+	t.Run("drops pending changes when stopped", func(t *testing.T) {
+		router, loaded := newTestRouter()
+
+		require.NoError(t, router.AddRoute("web", "127.0.0.1", 9000, store.RouteConfig{}))
+		require.NoError(t, router.Stop())
+
+		time.Sleep(50 * time.Millisecond)
+		assert.Empty(t, loaded)
 	})
 }
 
@@ -0,0 +1,330 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/containers/storage/pkg/reexec"
+)
+
+// Router child process behaviour
+const (
+	childName         = "puck-router" // reexec name the child runs under
+	childStartTimeout = 10 * time.Second
+	childLoadTimeout  = 30 * time.Second // a router taking longer is wedged
+	childStopTimeout  = 5 * time.Second
+	childRestartMax   = 30 * time.Second
+)
+
+// Events emitted when the router process exits on its own
+const (
+	EventChildExited    = "router-exited"
+	EventChildRestarted = "router-restarted"
+)
+
+func init() {
+	reexec.Register(childName, runChild)
+}
+
+// runChild is the router process: Caddy with only its admin API, on the
+// socket named by its argument, until the daemon loads the routes
+func runChild() {
+	if len(os.Args) != 2 {
+		fmt.Fprintf(os.Stderr, "usage: %s <admin socket>\n", childName)
+		os.Exit(2)
+	}
+	cfgJSON, err := json.Marshal(map[string]interface{}{"admin": adminConfig(os.Args[1])})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "router: %v\n", err)
+		os.Exit(1)
+	}
+	if err := caddy.Load(cfgJSON, true); err != nil {
+		fmt.Fprintf(os.Stderr, "router: starting caddy: %v\n", err)
+		os.Exit(1)
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	<-sigCh
+	caddy.Stop()
+	os.Exit(0)
+}
+
+// adminConfig keeps Caddy's admin API on a unix socket, so loading new
+// config through it doesn't take it away
+func adminConfig(socket string) map[string]interface{} {
+	return map[string]interface{}{"listen": "unix/" + socket}
+}
+
+// childProcess runs a router's Caddy in a child process, loading config
+// through the child's admin API
+type childProcess struct {
+	dir string // holds the admin and callback sockets
+
+	cmd      *exec.Cmd
+	done     chan struct{} // closed when cmd exits
+	restarts int           // times the supervisor brought it back
+}
+
+func (c *childProcess) adminSocket() string {
+	return filepath.Join(c.dir, "router-admin.sock")
+}
+
+// callbackSocket is where the daemon serves the landing page and wakes
+// for the child
+func (c *childProcess) callbackSocket() string {
+	return filepath.Join(c.dir, "router-callback.sock")
+}
+
+// start runs a new child and waits for its admin API to answer
+func (c *childProcess) start() error {
+	socket := c.adminSocket()
+	os.Remove(socket)
+
+	cmd := reexec.Command(childName, socket)
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	cmd.SysProcAttr = childSysProcAttr()
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("starting router process: %w", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(done)
+	}()
+	c.cmd, c.done = cmd, done
+
+	deadline := time.Now().Add(childStartTimeout)
+	for c.ping() != nil {
+		if time.Now().After(deadline) {
+			c.kill()
+			return fmt.Errorf("router process did not come up within %s", childStartTimeout)
+		}
+		select {
+		case <-done:
+			return fmt.Errorf("router process exited: %s", cmd.ProcessState)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	return nil
+}
+
+// ping checks that the child's admin API answers
+func (c *childProcess) ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	return socketCall(ctx, c.adminSocket(), http.MethodGet, "/config/", nil)
+}
+
+// load replaces the child's config. A child that doesn't take it in
+// time is wedged, so it is killed for the supervisor to replace.
+func (c *childProcess) load(cfgJSON []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), childLoadTimeout)
+	defer cancel()
+	err := socketCall(ctx, c.adminSocket(), http.MethodPost, "/load", cfgJSON)
+	if errors.Is(err, context.DeadlineExceeded) {
+		c.kill()
+	}
+	if err != nil {
+		return fmt.Errorf("router process: %w", err)
+	}
+	return nil
+}
+
+// stop asks the child to exit, killing it if it doesn't
+func (c *childProcess) stop() {
+	if c.cmd == nil {
+		return
+	}
+	c.cmd.Process.Signal(syscall.SIGTERM)
+	select {
+	case <-c.done:
+	case <-time.After(childStopTimeout):
+		c.kill()
+		<-c.done
+	}
+	c.cmd = nil
+}
+
+func (c *childProcess) kill() {
+	if c.cmd != nil {
+		c.cmd.Process.Kill()
+	}
+}
+
+// pid returns the child's process ID, or zero when it isn't running
+func (c *childProcess) pid() int {
+	if c.cmd == nil || c.cmd.Process == nil {
+		return 0
+	}
+	return c.cmd.Process.Pid
+}
+
+// socketCall sends a request to the HTTP server on a unix socket: the
+// router process's admin API, or the daemon's callback server
+func socketCall(ctx context.Context, socket, method, path string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, method, "http://puck-router"+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := unixClient(socket).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var caddyErr struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(data, &caddyErr) == nil && caddyErr.Error != "" {
+		return errors.New(caddyErr.Error)
+	}
+	return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(data)))
+}
+
+// unixClients caches an HTTP client per unix socket
+var unixClients sync.Map
+
+// unixClient returns an HTTP client for the server on a unix socket
+func unixClient(socket string) *http.Client {
+	if c, ok := unixClients.Load(socket); ok {
+		return c.(*http.Client)
+	}
+	c, _ := unixClients.LoadOrStore(socket, &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socket)
+			},
+		},
+	})
+	return c.(*http.Client)
+}
+
+// RunInChild runs Caddy in a child process rather than in the daemon,
+// with its sockets in dir, so a panic or wedged config takes down only
+// the router, which is then restarted with the last config it accepted.
+// Call before Start.
+func (r *Router) RunInChild(dir string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.child = &childProcess{dir: dir}
+	r.load = r.child.load
+}
+
+// startChild starts the router process, and the callback server its
+// landing page and wake handlers reach the daemon through
+func (r *Router) startChild() error {
+	if r.callback == nil {
+		srv, err := r.serveCallback()
+		if err != nil {
+			return err
+		}
+		r.callback = srv
+	}
+	if err := r.child.start(); err != nil {
+		return err
+	}
+	r.childGen++
+	go r.superviseChild(r.childGen, r.child.done)
+	return nil
+}
+
+// stopChild stops the router process and the callback server
+func (r *Router) stopChild() {
+	r.child.stop()
+	if r.callback != nil {
+		r.callback.Close()
+		r.callback = nil
+	}
+}
+
+// serveCallback serves the daemon's side of the landing page and wake
+// handlers to the router process
+func (r *Router) serveCallback() (*http.Server, error) {
+	socket := r.child.callbackSocket()
+	os.Remove(socket)
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("listening for the router process: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /landing", func(w http.ResponseWriter, req *http.Request) {
+		if err := r.serveLanding(w, req); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+	mux.HandleFunc("POST /touch/{puck}", func(w http.ResponseWriter, req *http.Request) {
+		var wr WakeRequest
+		json.NewDecoder(io.LimitReader(req.Body, 64<<10)).Decode(&wr)
+		if err := r.touch(req.Context(), req.PathValue("puck"), wr); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(ln)
+	return srv, nil
+}
+
+// superviseChild waits for the router process started as generation gen
+// to exit, and if the router didn't stop or restart it meanwhile, starts
+// another with the last config Caddy accepted, backing off while that
+// fails
+func (r *Router) superviseChild(gen int, done chan struct{}) {
+	<-done
+	delay := r.backoff
+	for {
+		r.mu.Lock()
+		if !r.running || r.childGen != gen {
+			r.mu.Unlock()
+			return
+		}
+
+		r.emit(EventChildExited, "router process exited, restarting it", nil)
+		err := r.child.start()
+		if err == nil && r.lastGood != nil {
+			err = r.load(r.lastGood)
+		}
+		if err == nil {
+			r.child.restarts++
+			done = r.child.done
+			r.emit(EventChildRestarted, fmt.Sprintf("router process restarted as pid %d", r.child.pid()), nil)
+			r.mu.Unlock()
+			<-done
+			delay = r.backoff
+			continue
+		}
+		r.child.kill()
+		r.emit(EventChildExited, "restarting router process failed", err)
+		r.mu.Unlock()
+
+		time.Sleep(delay)
+		delay = min(delay*2, childRestartMax)
+	}
+}
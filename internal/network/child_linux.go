@@ -0,0 +1,11 @@
+//go:build linux
+
+package network
+
+import "syscall"
+
+// childSysProcAttr stops the router process along with the daemon, even
+// if the daemon is killed
+func childSysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Pdeathsig: syscall.SIGTERM}
+}
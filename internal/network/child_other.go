@@ -0,0 +1,11 @@
+//go:build !linux
+
+package network
+
+import "syscall"
+
+// childSysProcAttr has nothing to add on this platform, where a killed
+// daemon leaves its router process behind
+func childSysProcAttr() *syscall.SysProcAttr {
+	return nil
+}
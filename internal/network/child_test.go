@@ -0,0 +1,122 @@
+package network
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/containers/storage/pkg/reexec"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMain lets the test binary run as the router process
+func TestMain(m *testing.M) {
+	if reexec.Init() {
+		return
+	}
+	os.Exit(m.Run())
+}
+
+func TestChildConfig(t *testing.T) {
+	router := NewRouter(8080, "localhost")
+	router.RunInChild("/run/puck")
+	router.SetWakeHandler(func(ctx context.Context, puckName string, req WakeRequest) error { return nil })
+	require.NoError(t, router.AddRoute("web", "127.0.0.1", 3000, store.RouteConfig{}))
+
+	cfg := router.buildConfig()
+	assert.Equal(t, map[string]interface{}{"listen": "unix//run/puck/router-admin.sock"}, cfg["admin"])
+
+	routes := cfg["apps"].(map[string]interface{})["http"].(map[string]interface{})["servers"].(map[string]interface{})["puck"].(map[string]interface{})["routes"].([]map[string]interface{})
+	var handlers []string
+	for _, route := range routes {
+		for _, h := range route["handle"].([]map[string]interface{}) {
+			if cb, ok := h["callback"]; ok {
+				assert.Equal(t, "/run/puck/router-callback.sock", cb)
+				handlers = append(handlers, h["handler"].(string))
+			}
+		}
+	}
+	assert.ElementsMatch(t, []string{"puck_wake", "puck_landing"}, handlers)
+}
+
+func TestChildRouter(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts a router process")
+	}
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello from web")
+	}))
+	defer backend.Close()
+	_, backendPort, err := net.SplitHostPort(backend.Listener.Addr().String())
+	require.NoError(t, err)
+	port, err := strconv.Atoi(backendPort)
+	require.NoError(t, err)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	routerPort := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	router := NewRouter(routerPort, "localhost")
+	router.backoff = 10 * time.Millisecond
+	router.RunInChild(t.TempDir())
+	events := make(chan Event, 10)
+	router.SetEventHandler(func(ev Event) { events <- ev })
+
+	require.NoError(t, router.Start())
+	defer router.Stop()
+	require.NoError(t, router.AddRoute("web", "127.0.0.1", port, store.RouteConfig{}))
+	require.NoError(t, router.Flush())
+
+	get := func(path string) (string, error) {
+		resp, err := http.Get("http://127.0.0.1:" + strconv.Itoa(routerPort) + path)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	body, err := get("/web/")
+	require.NoError(t, err)
+	assert.Equal(t, "hello from web", body)
+
+	body, err = get("/")
+	require.NoError(t, err)
+	assert.Contains(t, body, "web", "the landing page comes from the daemon")
+
+	t.Run("restarts a router process that dies", func(t *testing.T) {
+		pid := router.Status().PID
+		require.NotZero(t, pid)
+		require.NoError(t, syscall.Kill(pid, syscall.SIGKILL))
+
+		require.Eventually(t, func() bool {
+			st := router.Status()
+			return st.Restarts == 1 && st.PID != pid
+		}, 10*time.Second, 20*time.Millisecond)
+		assert.Equal(t, EventChildExited, (<-events).Type)
+		assert.Equal(t, EventChildRestarted, (<-events).Type)
+
+		body, err := get("/web/")
+		require.NoError(t, err)
+		assert.Equal(t, "hello from web", body, "the restarted router has the routes")
+	})
+
+	t.Run("stops the router process", func(t *testing.T) {
+		pid := router.Status().PID
+		require.NoError(t, router.Stop())
+		assert.Zero(t, router.Status().PID)
+		assert.Error(t, syscall.Kill(pid, 0))
+	})
+}
@@ -0,0 +1,300 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/libdns/libdns"
+)
+
+func init() {
+	caddy.RegisterModule(CloudflareDNS{})
+	caddy.RegisterModule(ExecDNS{})
+}
+
+// DNS providers the router can solve ACME DNS-01 challenges through
+const (
+	DNSCloudflare = "cloudflare"
+	DNSExec       = "exec"
+)
+
+// DNSProviders lists the DNS providers by name
+var DNSProviders = []string{DNSCloudflare, DNSExec}
+
+// dnsProviderConfig builds the Caddy module config for a DNS provider
+// from its credentials: api_token for cloudflare; command for exec, with
+// any others passed to the command in its environment
+func dnsProviderConfig(provider string, creds map[string]string) (map[string]interface{}, error) {
+	switch provider {
+	case DNSCloudflare:
+		if creds["api_token"] == "" {
+			return nil, fmt.Errorf("the cloudflare DNS provider needs an api_token")
+		}
+		return map[string]interface{}{"name": "puck_cloudflare", "api_token": creds["api_token"]}, nil
+	case DNSExec:
+		if creds["command"] == "" {
+			return nil, fmt.Errorf("the exec DNS provider needs a command")
+		}
+		env := map[string]string{}
+		for k, v := range creds {
+			if k != "command" {
+				env[strings.ToUpper(k)] = v
+			}
+		}
+		return map[string]interface{}{"name": "puck_exec", "command": creds["command"], "env": env}, nil
+	}
+	return nil, fmt.Errorf("unknown DNS provider %q", provider)
+}
+
+// CloudflareDNS solves DNS challenges by adding TXT records through the
+// Cloudflare API. The token needs Zone.DNS edit permission.
+type CloudflareDNS struct {
+	APIToken string `json:"api_token"`
+
+	api    string // base URL, replaced in tests
+	client *http.Client
+}
+
+// cloudflareAPI is the Cloudflare API's base URL
+const cloudflareAPI = "https://api.cloudflare.com/client/v4"
+
+// CaddyModule returns the Caddy module information
+func (CloudflareDNS) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "dns.providers.puck_cloudflare",
+		New: func() caddy.Module { return new(CloudflareDNS) },
+	}
+}
+
+// Provision expands placeholders such as {env.CF_API_TOKEN} in the token
+func (p *CloudflareDNS) Provision(ctx caddy.Context) error {
+	p.APIToken = caddy.NewReplacer().ReplaceAll(p.APIToken, "")
+	if p.api == "" {
+		p.api = cloudflareAPI
+	}
+	p.client = &http.Client{Timeout: 30 * time.Second}
+	return nil
+}
+
+// AppendRecords adds TXT records to the zone
+func (p *CloudflareDNS) AppendRecords(ctx context.Context, zone string, recs []libdns.Record) ([]libdns.Record, error) {
+	zoneID, err := p.zoneID(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	var added []libdns.Record
+	for _, rec := range recs {
+		rr := rec.RR()
+		ttl := int(rr.TTL.Seconds())
+		if ttl < 60 {
+			ttl = 1 // automatic
+		}
+		body := map[string]interface{}{
+			"type":    rr.Type,
+			"name":    libdns.AbsoluteName(rr.Name, zone),
+			"content": rr.Data,
+			"ttl":     ttl,
+		}
+		if err := p.call(ctx, http.MethodPost, "/zones/"+zoneID+"/dns_records", body, nil); err != nil {
+			return added, err
+		}
+		added = append(added, rec)
+	}
+	return added, nil
+}
+
+// DeleteRecords removes records from the zone, matched by type, name and
+// content
+func (p *CloudflareDNS) DeleteRecords(ctx context.Context, zone string, recs []libdns.Record) ([]libdns.Record, error) {
+	zoneID, err := p.zoneID(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []libdns.Record
+	for _, rec := range recs {
+		rr := rec.RR()
+		q := url.Values{"name": {strings.TrimSuffix(libdns.AbsoluteName(rr.Name, zone), ".")}}
+		if rr.Type != "" {
+			q.Set("type", rr.Type)
+		}
+		if rr.Data != "" {
+			q.Set("content", rr.Data)
+		}
+		var found []struct {
+			ID string `json:"id"`
+		}
+		if err := p.call(ctx, http.MethodGet, "/zones/"+zoneID+"/dns_records?"+q.Encode(), nil, &found); err != nil {
+			return deleted, err
+		}
+		for _, r := range found {
+			if err := p.call(ctx, http.MethodDelete, "/zones/"+zoneID+"/dns_records/"+r.ID, nil, nil); err != nil {
+				return deleted, err
+			}
+		}
+		if len(found) > 0 {
+			deleted = append(deleted, rec)
+		}
+	}
+	return deleted, nil
+}
+
+// zoneID looks up the ID of a zone by its name
+func (p *CloudflareDNS) zoneID(ctx context.Context, zone string) (string, error) {
+	name := strings.TrimSuffix(zone, ".")
+	var zones []struct {
+		ID string `json:"id"`
+	}
+	if err := p.call(ctx, http.MethodGet, "/zones?"+url.Values{"name": {name}}.Encode(), nil, &zones); err != nil {
+		return "", err
+	}
+	if len(zones) == 0 {
+		return "", fmt.Errorf("cloudflare has no zone %s the token can see", name)
+	}
+	return zones[0].ID, nil
+}
+
+// call makes an API request, decoding the result into out
+func (p *CloudflareDNS) call(ctx context.Context, method, path string, body, out interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, p.api+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.APIToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cloudflare: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var envelope struct {
+		Success bool `json:"success"`
+		Errors  []struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("cloudflare: %s: %w", resp.Status, err)
+	}
+	if !envelope.Success {
+		if len(envelope.Errors) > 0 {
+			return fmt.Errorf("cloudflare: %s (code %d)", envelope.Errors[0].Message, envelope.Errors[0].Code)
+		}
+		return fmt.Errorf("cloudflare: %s", resp.Status)
+	}
+	if out != nil {
+		return json.Unmarshal(envelope.Result, out)
+	}
+	return nil
+}
+
+// ExecDNS solves DNS challenges by running a command for each record, as
+// lego's exec provider does: "<command> present <fqdn> <value>" to add
+// it, then "<command> cleanup <fqdn> <value>" to remove it. Env is added
+// to the command's environment, so it can carry the provider's
+// credentials.
+type ExecDNS struct {
+	Command string            `json:"command"`
+	Env     map[string]string `json:"env,omitempty"`
+}
+
+// execDNSTimeout bounds each run of the command
+const execDNSTimeout = 2 * time.Minute
+
+// CaddyModule returns the Caddy module information
+func (ExecDNS) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "dns.providers.puck_exec",
+		New: func() caddy.Module { return new(ExecDNS) },
+	}
+}
+
+// Provision expands placeholders such as {env.DNS_TOKEN} in the
+// environment
+func (p *ExecDNS) Provision(ctx caddy.Context) error {
+	repl := caddy.NewReplacer()
+	for k, v := range p.Env {
+		p.Env[k] = repl.ReplaceAll(v, "")
+	}
+	return nil
+}
+
+// AppendRecords runs the command to present each record
+func (p *ExecDNS) AppendRecords(ctx context.Context, zone string, recs []libdns.Record) ([]libdns.Record, error) {
+	return p.runAll(ctx, "present", zone, recs)
+}
+
+// DeleteRecords runs the command to clean up each record
+func (p *ExecDNS) DeleteRecords(ctx context.Context, zone string, recs []libdns.Record) ([]libdns.Record, error) {
+	return p.runAll(ctx, "cleanup", zone, recs)
+}
+
+func (p *ExecDNS) runAll(ctx context.Context, mode, zone string, recs []libdns.Record) ([]libdns.Record, error) {
+	var done []libdns.Record
+	for _, rec := range recs {
+		rr := rec.RR()
+		if err := p.run(ctx, mode, libdns.AbsoluteName(rr.Name, zone), rr.Data); err != nil {
+			return done, err
+		}
+		done = append(done, rec)
+	}
+	return done, nil
+}
+
+func (p *ExecDNS) run(ctx context.Context, mode, fqdn, value string) error {
+	args := strings.Fields(p.Command)
+	if len(args) == 0 {
+		return fmt.Errorf("no DNS command")
+	}
+	ctx, cancel := context.WithTimeout(ctx, execDNSTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, args[0], append(args[1:], mode, fqdn, value)...)
+	cmd.Env = os.Environ()
+	keys := make([]string, 0, len(p.Env))
+	for k := range p.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		cmd.Env = append(cmd.Env, k+"="+p.Env[k])
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s %s %s: %w: %s", args[0], mode, fqdn, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// Interface guards
+var (
+	_ caddy.Provisioner     = (*CloudflareDNS)(nil)
+	_ libdns.RecordAppender = (*CloudflareDNS)(nil)
+	_ libdns.RecordDeleter  = (*CloudflareDNS)(nil)
+	_ caddy.Provisioner     = (*ExecDNS)(nil)
+	_ libdns.RecordAppender = (*ExecDNS)(nil)
+	_ libdns.RecordDeleter  = (*ExecDNS)(nil)
+)
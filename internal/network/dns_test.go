@@ -0,0 +1,133 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/libdns/libdns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloudflareDNS(t *testing.T) {
+	ctx := context.Background()
+
+	// A fake API holding one zone's records
+	var mu sync.Mutex
+	records := map[string]map[string]string{} // id -> record
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "errors": []map[string]interface{}{{"code": 10000, "message": "Authentication error"}}})
+			return
+		}
+
+		var result interface{}
+		switch {
+		case r.URL.Path == "/zones":
+			zones := []map[string]string{}
+			if r.URL.Query().Get("name") == "example.com" {
+				zones = append(zones, map[string]string{"id": "z1"})
+			}
+			result = zones
+		case r.Method == http.MethodPost && r.URL.Path == "/zones/z1/dns_records":
+			var rec map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&rec))
+			id := "r" + string(rune('0'+len(records)))
+			records[id] = map[string]string{"id": id, "type": rec["type"].(string), "name": rec["name"].(string), "content": rec["content"].(string)}
+			result = records[id]
+		case r.Method == http.MethodGet && r.URL.Path == "/zones/z1/dns_records":
+			found := []map[string]string{}
+			q := r.URL.Query()
+			for _, rec := range records {
+				if rec["name"] == q.Get("name")+"." && rec["content"] == q.Get("content") {
+					found = append(found, rec)
+				}
+			}
+			result = found
+		case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/zones/z1/dns_records/"):
+			delete(records, strings.TrimPrefix(r.URL.Path, "/zones/z1/dns_records/"))
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "result": result})
+	}))
+	defer srv.Close()
+
+	provider := &CloudflareDNS{APIToken: "secret", api: srv.URL}
+	require.NoError(t, provider.Provision(caddy.Context{}))
+	txt := libdns.TXT{Name: "_acme-challenge.pucks", Text: "token-value", TTL: time.Minute}
+
+	added, err := provider.AppendRecords(ctx, "example.com.", []libdns.Record{txt})
+	require.NoError(t, err)
+	assert.Len(t, added, 1)
+	require.Len(t, records, 1)
+	for _, rec := range records {
+		assert.Equal(t, "_acme-challenge.pucks.example.com.", rec["name"])
+		assert.Equal(t, "TXT", rec["type"])
+		assert.Equal(t, "token-value", rec["content"])
+	}
+
+	deleted, err := provider.DeleteRecords(ctx, "example.com.", []libdns.Record{txt})
+	require.NoError(t, err)
+	assert.Len(t, deleted, 1)
+	assert.Empty(t, records)
+
+	_, err = provider.AppendRecords(ctx, "other.org.", []libdns.Record{txt})
+	assert.ErrorContains(t, err, "no zone other.org")
+
+	provider.APIToken = "wrong"
+	_, err = provider.AppendRecords(ctx, "example.com.", []libdns.Record{txt})
+	assert.ErrorContains(t, err, "Authentication error")
+}
+
+func TestExecDNS(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	log := filepath.Join(dir, "log")
+	script := filepath.Join(dir, "dns")
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\necho \"$DNS_TOKEN $1 $2 $3\" >> "+log+"\n"), 0755))
+
+	provider := &ExecDNS{Command: script, Env: map[string]string{"DNS_TOKEN": "secret"}}
+	require.NoError(t, provider.Provision(caddy.Context{}))
+	txt := libdns.TXT{Name: "_acme-challenge", Text: "token-value"}
+
+	_, err := provider.AppendRecords(ctx, "pucks.example.com.", []libdns.Record{txt})
+	require.NoError(t, err)
+	_, err = provider.DeleteRecords(ctx, "pucks.example.com.", []libdns.Record{txt})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(log)
+	require.NoError(t, err)
+	assert.Equal(t, "secret present _acme-challenge.pucks.example.com. token-value\nsecret cleanup _acme-challenge.pucks.example.com. token-value\n", string(data))
+
+	provider.Command = filepath.Join(dir, "missing")
+	_, err = provider.AppendRecords(ctx, "pucks.example.com.", []libdns.Record{txt})
+	assert.Error(t, err)
+}
+
+func TestDNSProviderConfig(t *testing.T) {
+	cfg, err := dnsProviderConfig(DNSCloudflare, map[string]string{"api_token": "{env.CF_API_TOKEN}"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"name": "puck_cloudflare", "api_token": "{env.CF_API_TOKEN}"}, cfg)
+
+	cfg, err = dnsProviderConfig(DNSExec, map[string]string{"command": "/usr/local/bin/dns-hook", "dns_token": "x"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"DNS_TOKEN": "x"}, cfg["env"])
+
+	_, err = dnsProviderConfig(DNSCloudflare, nil)
+	assert.ErrorContains(t, err, "api_token")
+	_, err = dnsProviderConfig("route53", nil)
+	assert.ErrorContains(t, err, "unknown DNS provider")
+}
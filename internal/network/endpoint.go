@@ -0,0 +1,78 @@
+package network
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// Endpoint is another of a puck's container ports, served at
+// /<puck>/<name> alongside the puck's own route
+type Endpoint struct {
+	Name string
+	IP   string
+	Port int
+}
+
+// SetEndpoints replaces the endpoints served for a puck; none stops
+// serving them. Endpoints only work while the puck has a route.
+func (r *Router) SetEndpoints(puckName string, endpoints []Endpoint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, e := range endpoints {
+		if !routeNamePattern.MatchString(e.Name) {
+			return fmt.Errorf("invalid endpoint name %q", e.Name)
+		}
+	}
+
+	prev, existed := r.endpoints[puckName]
+	if len(endpoints) == 0 {
+		if !existed {
+			return nil
+		}
+		delete(r.endpoints, puckName)
+	} else {
+		r.endpoints[puckName] = endpoints
+	}
+
+	if err := r.reload(); err != nil {
+		if existed {
+			r.endpoints[puckName] = prev
+		} else {
+			delete(r.endpoints, puckName)
+		}
+		return err
+	}
+	return nil
+}
+
+// RemoveEndpoints stops serving a puck's endpoints
+func (r *Router) RemoveEndpoints(puckName string) error {
+	return r.SetEndpoints(puckName, nil)
+}
+
+// endpointRoutes builds a route for each endpoint of a routed puck,
+// proxying with the puck's route settings
+func (r *Router) endpointRoutes() []map[string]interface{} {
+	var routes []map[string]interface{}
+	for _, puckName := range slices.Sorted(maps.Keys(r.endpoints)) {
+		info, ok := r.routes[puckName]
+		if !ok {
+			continue
+		}
+		for _, e := range r.endpoints[puckName] {
+			target := info
+			target.IP, target.Port = e.IP, e.Port
+			path := fmt.Sprintf("/%s/%s", puckName, e.Name)
+
+			routes = append(routes, map[string]interface{}{
+				"match": []map[string]interface{}{
+					{"path": []string{path, path + "/*"}},
+				},
+				"handle": r.routeHandlers(puckName, target, path),
+			})
+		}
+	}
+	return routes
+}
@@ -0,0 +1,62 @@
+package network
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpointRoutes(t *testing.T) {
+	newEndpointRouter := func() *Router {
+		router := NewRouter(8080, "localhost")
+		router.routes["web"] = routeInfo{IP: "127.0.0.1", Port: 9000}
+		return router
+	}
+
+	t.Run("serves endpoints under their puck's path", func(t *testing.T) {
+		router := newEndpointRouter()
+		require.NoError(t, router.SetEndpoints("web", []Endpoint{
+			{Name: "api", IP: "127.0.0.1", Port: 9001},
+			{Name: "admin", IP: "127.0.0.1", Port: 9002},
+		}))
+
+		routes := router.buildConfig()["apps"].(map[string]interface{})["http"].(map[string]interface{})["servers"].(map[string]interface{})["puck"].(map[string]interface{})["routes"].([]map[string]interface{})
+		require.Len(t, routes, 4)
+
+		api := routes[0]
+		assert.Equal(t, []map[string]interface{}{{"path": []string{"/web/api", "/web/api/*"}}}, api["match"])
+		handlers := api["handle"].([]map[string]interface{})
+		require.Len(t, handlers, 3)
+		assert.Equal(t, "/web/api", handlers[1]["strip_path_prefix"])
+		assert.Equal(t, "127.0.0.1:9001", handlers[2]["upstreams"].([]map[string]interface{})[0]["dial"])
+
+		// The puck's own route comes after its endpoints
+		assert.Equal(t, []map[string]interface{}{{"path": []string{"/web", "/web/*"}}}, routes[2]["match"])
+
+		cfgJSON, err := json.Marshal(router.buildConfig())
+		require.NoError(t, err)
+		assert.NoError(t, validateCaddyConfig(cfgJSON))
+	})
+
+	t.Run("skips endpoints of pucks without a route", func(t *testing.T) {
+		router := newEndpointRouter()
+		require.NoError(t, router.SetEndpoints("api", []Endpoint{{Name: "admin", IP: "127.0.0.1", Port: 9001}}))
+		assert.Empty(t, router.endpointRoutes())
+	})
+
+	t.Run("rejects unsafe names", func(t *testing.T) {
+		router := newEndpointRouter()
+		for _, name := range []string{"", "a/b", "_x", "a b"} {
+			assert.Error(t, router.SetEndpoints("web", []Endpoint{{Name: name, IP: "127.0.0.1", Port: 9001}}), name)
+		}
+	})
+
+	t.Run("removes endpoints", func(t *testing.T) {
+		router := newEndpointRouter()
+		require.NoError(t, router.SetEndpoints("web", []Endpoint{{Name: "api", IP: "127.0.0.1", Port: 9001}}))
+		require.NoError(t, router.RemoveEndpoints("web"))
+		assert.Empty(t, router.endpointRoutes())
+	})
+}
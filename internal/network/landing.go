@@ -0,0 +1,225 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/charmbracelet/log"
+	"github.com/dustin/go-humanize"
+)
+
+//go:embed landing.html.tmpl
+var defaultLandingTemplate string
+
+// landingTimeout bounds how long the landing page waits for live data
+const landingTimeout = 3 * time.Second
+
+func init() {
+	caddy.RegisterModule(Landing{})
+}
+
+// LandingPuck is one puck card on the router landing page
+type LandingPuck struct {
+	Name      string
+	Status    string
+	Image     string
+	Path      string // router path prefix, e.g. "/myapp"
+	Routed    bool   // whether the router currently proxies this puck
+	StartedAt time.Time
+	Uptime    string // human readable, empty when not running
+}
+
+// LandingPage is the data passed to the landing page template
+type LandingPage struct {
+	Domain string
+	Pucks  []LandingPuck
+}
+
+// LandingSource returns live puck data for the landing page
+type LandingSource func(ctx context.Context) ([]LandingPuck, error)
+
+// activeRouter is the router whose landing page Caddy serves
+var activeRouter atomic.Pointer[Router]
+
+// Landing is a Caddy HTTP handler that renders the router landing page
+type Landing struct {
+	// Callback is the daemon's socket when the router runs in its own
+	// process; the page is fetched from there
+	Callback string `json:"callback,omitempty"`
+}
+
+// CaddyModule returns the Caddy module information
+func (Landing) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.handlers.puck_landing",
+		New: func() caddy.Module { return new(Landing) },
+	}
+}
+
+// ServeHTTP renders the landing page for the active router
+func (h Landing) ServeHTTP(w http.ResponseWriter, req *http.Request, next caddyhttp.Handler) error {
+	if h.Callback != "" {
+		return h.serveCallback(w, req)
+	}
+	r := activeRouter.Load()
+	if r == nil {
+		return next.ServeHTTP(w, req)
+	}
+	return r.serveLanding(w, req)
+}
+
+// serveCallback passes the landing page on from the daemon
+func (h Landing) serveCallback(w http.ResponseWriter, req *http.Request) error {
+	ctx, cancel := context.WithTimeout(req.Context(), landingTimeout)
+	defer cancel()
+	cbReq, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://puckd/landing", nil)
+	if err != nil {
+		return caddyhttp.Error(http.StatusInternalServerError, err)
+	}
+	cbReq.Header.Set("Accept", req.Header.Get("Accept"))
+	resp, err := unixClient(h.Callback).Do(cbReq)
+	if err != nil {
+		return caddyhttp.Error(http.StatusBadGateway, fmt.Errorf("reaching the daemon: %w", err))
+	}
+	defer resp.Body.Close()
+
+	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	w.WriteHeader(resp.StatusCode)
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// serveLanding renders the router's landing page
+func (r *Router) serveLanding(w http.ResponseWriter, req *http.Request) error {
+	ctx, cancel := context.WithTimeout(req.Context(), landingTimeout)
+	defer cancel()
+	page := r.landingPage(ctx)
+
+	// Browsers get HTML; curl and scripts keep the plain-text listing
+	if !strings.Contains(req.Header.Get("Accept"), "text/html") {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, err := w.Write([]byte(page.Text()))
+		return err
+	}
+
+	body, err := r.renderLanding(page)
+	if err != nil {
+		return caddyhttp.Error(http.StatusInternalServerError, err)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, err = w.Write(body)
+	return err
+}
+
+// SetLandingSource registers the live data provider for the landing page
+func (r *Router) SetLandingSource(src LandingSource) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.landingSource = src
+}
+
+// SetLandingTemplate overrides the embedded landing page template with a
+// file; a missing file falls back to the embedded template
+func (r *Router) SetLandingTemplate(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.landingTemplate = path
+}
+
+// landingPage gathers puck data from the live source, falling back to
+// the in-memory route table if no source is set or it fails
+func (r *Router) landingPage(ctx context.Context) LandingPage {
+	r.mu.RLock()
+	src := r.landingSource
+	page := LandingPage{Domain: r.domain}
+	routed := make(map[string]bool, len(r.routes))
+	for name := range r.routes {
+		routed[name] = true
+	}
+	r.mu.RUnlock()
+
+	var pucks []LandingPuck
+	if src != nil {
+		var err error
+		pucks, err = src(ctx)
+		if err != nil {
+			log.Warn("Landing page data unavailable", "error", err)
+			pucks = nil
+		}
+	}
+	if pucks == nil {
+		for name := range routed {
+			pucks = append(pucks, LandingPuck{Name: name, Status: "running"})
+		}
+	}
+
+	now := time.Now()
+	for i := range pucks {
+		p := &pucks[i]
+		p.Path = "/" + p.Name
+		p.Routed = routed[p.Name]
+		if p.Uptime == "" && !p.StartedAt.IsZero() && p.Status == "running" {
+			p.Uptime = strings.TrimSuffix(humanize.RelTime(p.StartedAt, now, "", ""), " ")
+		}
+	}
+	sort.Slice(pucks, func(i, j int) bool { return pucks[i].Name < pucks[j].Name })
+
+	page.Pucks = pucks
+	return page
+}
+
+// renderLanding executes the override template if present, else the default
+func (r *Router) renderLanding(page LandingPage) ([]byte, error) {
+	r.mu.RLock()
+	path := r.landingTemplate
+	r.mu.RUnlock()
+
+	text := defaultLandingTemplate
+	if path != "" {
+		if custom, err := os.ReadFile(path); err == nil {
+			text = string(custom)
+		} else if !os.IsNotExist(err) {
+			log.Warn("Reading landing template", "path", path, "error", err)
+		}
+	}
+
+	tmpl, err := template.New("landing").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing landing template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, page); err != nil {
+		return nil, fmt.Errorf("rendering landing template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Text renders the page as a plain-text listing
+func (p LandingPage) Text() string {
+	if len(p.Pucks) == 0 {
+		return "No pucks found. Create one with: puck create <name>"
+	}
+
+	var b strings.Builder
+	b.WriteString("Available pucks:\n")
+	for _, pk := range p.Pucks {
+		fmt.Fprintf(&b, "  %s  (%s)\n", pk.Path, pk.Status)
+	}
+	return b.String()
+}
+
+// Interface guard
+var _ caddyhttp.MiddlewareHandler = (*Landing)(nil)
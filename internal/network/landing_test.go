@@ -0,0 +1,142 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLandingPage(t *testing.T) {
+	t.Run("falls back to routes without a source", func(t *testing.T) {
+		router := NewRouter(8080, "localhost")
+		router.routes["web"] = routeInfo{IP: "127.0.0.1", Port: 3000}
+
+		page := router.landingPage(context.Background())
+		require.Len(t, page.Pucks, 1)
+		assert.Equal(t, "web", page.Pucks[0].Name)
+		assert.Equal(t, "/web", page.Pucks[0].Path)
+		assert.True(t, page.Pucks[0].Routed)
+	})
+
+	t.Run("uses live source data", func(t *testing.T) {
+		router := NewRouter(8080, "localhost")
+		router.routes["web"] = routeInfo{IP: "127.0.0.1", Port: 3000}
+		router.SetLandingSource(func(ctx context.Context) ([]LandingPuck, error) {
+			return []LandingPuck{
+				{Name: "web", Status: "running", StartedAt: time.Now().Add(-2 * time.Hour)},
+				{Name: "api", Status: "stopped"},
+			}, nil
+		})
+
+		page := router.landingPage(context.Background())
+		require.Len(t, page.Pucks, 2)
+		assert.Equal(t, "api", page.Pucks[0].Name)
+		assert.False(t, page.Pucks[0].Routed)
+		assert.Empty(t, page.Pucks[0].Uptime)
+		assert.Equal(t, "web", page.Pucks[1].Name)
+		assert.True(t, page.Pucks[1].Routed)
+		assert.Equal(t, "2 hours", page.Pucks[1].Uptime)
+	})
+
+	t.Run("falls back to routes when source fails", func(t *testing.T) {
+		router := NewRouter(8080, "localhost")
+		router.routes["web"] = routeInfo{IP: "127.0.0.1", Port: 3000}
+		router.SetLandingSource(func(ctx context.Context) ([]LandingPuck, error) {
+			return nil, errors.New("database locked")
+		})
+
+		page := router.landingPage(context.Background())
+		require.Len(t, page.Pucks, 1)
+		assert.Equal(t, "web", page.Pucks[0].Name)
+	})
+
+	t.Run("text listing shows no pucks message when empty", func(t *testing.T) {
+		assert.Contains(t, LandingPage{}.Text(), "No pucks found")
+	})
+
+	t.Run("text listing includes paths", func(t *testing.T) {
+		page := LandingPage{Pucks: []LandingPuck{{Name: "web", Path: "/web", Status: "running"}}}
+		assert.Contains(t, page.Text(), "/web  (running)")
+	})
+}
+
+func TestRenderLanding(t *testing.T) {
+	page := LandingPage{
+		Domain: "localhost",
+		Pucks:  []LandingPuck{{Name: "web", Path: "/web", Status: "running", Routed: true, Uptime: "5 minutes"}},
+	}
+
+	t.Run("renders embedded template", func(t *testing.T) {
+		router := NewRouter(8080, "localhost")
+		body, err := router.renderLanding(page)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), `href="/web/"`)
+		assert.Contains(t, string(body), "up 5 minutes")
+	})
+
+	t.Run("uses override template", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "landing.html")
+		require.NoError(t, os.WriteFile(path, []byte(`{{range .Pucks}}<b>{{.Name}}</b>{{end}}`), 0644))
+
+		router := NewRouter(8080, "localhost")
+		router.SetLandingTemplate(path)
+		body, err := router.renderLanding(page)
+		require.NoError(t, err)
+		assert.Equal(t, "<b>web</b>", string(body))
+	})
+
+	t.Run("missing override falls back to embedded template", func(t *testing.T) {
+		router := NewRouter(8080, "localhost")
+		router.SetLandingTemplate(filepath.Join(t.TempDir(), "missing.html"))
+		body, err := router.renderLanding(page)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), "<!DOCTYPE html>")
+	})
+
+	t.Run("invalid override returns error", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "landing.html")
+		require.NoError(t, os.WriteFile(path, []byte(`{{range}}`), 0644))
+
+		router := NewRouter(8080, "localhost")
+		router.SetLandingTemplate(path)
+		_, err := router.renderLanding(page)
+		assert.Error(t, err)
+	})
+}
+
+func TestLandingHandler(t *testing.T) {
+	router := NewRouter(8080, "localhost")
+	router.routes["web"] = routeInfo{IP: "127.0.0.1", Port: 3000}
+	activeRouter.Store(router)
+	defer activeRouter.Store(nil)
+
+	next := caddyhttp.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error { return nil })
+
+	t.Run("serves html to browsers", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", "text/html,application/xhtml+xml")
+		rec := httptest.NewRecorder()
+
+		require.NoError(t, Landing{}.ServeHTTP(rec, req, next))
+		assert.Contains(t, rec.Header().Get("Content-Type"), "text/html")
+		assert.Contains(t, rec.Body.String(), `href="/web/"`)
+	})
+
+	t.Run("serves plain text to other clients", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		rec := httptest.NewRecorder()
+
+		require.NoError(t, Landing{}.ServeHTTP(rec, req, next))
+		assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain")
+		assert.Contains(t, rec.Body.String(), "Available pucks:")
+	})
+}
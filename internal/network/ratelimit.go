@@ -0,0 +1,176 @@
+package network
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"golang.org/x/time/rate"
+)
+
+func init() {
+	caddy.RegisterModule(RateLimit{})
+}
+
+// RateLimit is a Caddy HTTP handler that caps requests per client IP.
+// Limiter state lives in a package-level zone keyed by name so that it
+// survives the config reloads triggered whenever a route changes, and is
+// dropped once no loaded config uses it.
+type RateLimit struct {
+	Zone     string         `json:"zone"`
+	Requests int            `json:"requests"`
+	Window   caddy.Duration `json:"window"`
+
+	zone *limitZone
+}
+
+// CaddyModule returns the Caddy module information
+func (RateLimit) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.handlers.puck_rate_limit",
+		New: func() caddy.Module { return new(RateLimit) },
+	}
+}
+
+// Provision attaches the handler to its shared limiter zone
+func (rl *RateLimit) Provision(ctx caddy.Context) error {
+	rl.zone = getLimitZone(rl.Zone, rl.Requests, time.Duration(rl.Window))
+	return nil
+}
+
+// Cleanup releases the handler's zone when its config is unloaded
+func (rl *RateLimit) Cleanup() error {
+	if rl.zone != nil {
+		releaseLimitZone(rl.Zone, rl.zone)
+	}
+	return nil
+}
+
+// Validate checks the handler configuration
+func (rl *RateLimit) Validate() error {
+	if rl.Requests <= 0 {
+		return fmt.Errorf("requests must be positive")
+	}
+	if rl.Window <= 0 {
+		return fmt.Errorf("window must be positive")
+	}
+	return nil
+}
+
+// ServeHTTP rejects requests over the limit with 429 Too Many Requests
+func (rl *RateLimit) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhttp.Handler) error {
+	if !rl.zone.allow(clientIP(r)) {
+		retry := time.Duration(rl.Window).Seconds() / float64(rl.Requests)
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry))))
+		return caddyhttp.Error(http.StatusTooManyRequests, fmt.Errorf("rate limit exceeded"))
+	}
+	return next.ServeHTTP(w, r)
+}
+
+// clientIP returns the remote address without its port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// limitZoneMaxClients bounds memory used by a single zone
+const limitZoneMaxClients = 10000
+
+// limitZone tracks token buckets for each client of one route
+type limitZone struct {
+	mu       sync.Mutex
+	requests int
+	window   time.Duration
+	clients  map[string]*clientLimiter
+	refs     int // handlers provisioned with the zone, guarded by limitZonesMu
+}
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+var (
+	limitZonesMu sync.Mutex
+	limitZones   = make(map[string]*limitZone)
+)
+
+// getLimitZone returns the named zone, replacing it if its limits changed.
+// Each call takes a reference that releaseLimitZone gives back.
+func getLimitZone(name string, requests int, window time.Duration) *limitZone {
+	limitZonesMu.Lock()
+	defer limitZonesMu.Unlock()
+
+	z, ok := limitZones[name]
+	if !ok || z.requests != requests || z.window != window {
+		z = &limitZone{
+			requests: requests,
+			window:   window,
+			clients:  make(map[string]*clientLimiter),
+		}
+		limitZones[name] = z
+	}
+	z.refs++
+	return z
+}
+
+// releaseLimitZone gives back a reference taken by getLimitZone, dropping
+// the zone once nothing uses it, as when its route is removed or renamed.
+// A reload provisions the new config before cleaning up the old one, so
+// zones of routes that remain never reach zero.
+func releaseLimitZone(name string, z *limitZone) {
+	limitZonesMu.Lock()
+	defer limitZonesMu.Unlock()
+
+	z.refs--
+	if z.refs <= 0 && limitZones[name] == z {
+		delete(limitZones, name)
+	}
+}
+
+// allow reports whether a request from key may proceed
+func (z *limitZone) allow(key string) bool {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	now := time.Now()
+	c, ok := z.clients[key]
+	if !ok {
+		if len(z.clients) >= limitZoneMaxClients {
+			z.prune(now)
+		}
+		c = &clientLimiter{
+			limiter: rate.NewLimiter(rate.Limit(float64(z.requests)/z.window.Seconds()), z.requests),
+		}
+		z.clients[key] = c
+	}
+	c.lastSeen = now
+
+	return c.limiter.AllowN(now, 1)
+}
+
+// prune drops clients whose buckets have fully refilled
+func (z *limitZone) prune(now time.Time) {
+	for key, c := range z.clients {
+		if now.Sub(c.lastSeen) > z.window {
+			delete(z.clients, key)
+		}
+	}
+}
+
+// Interface guards
+var (
+	_ caddy.Provisioner           = (*RateLimit)(nil)
+	_ caddy.CleanerUpper          = (*RateLimit)(nil)
+	_ caddy.Validator             = (*RateLimit)(nil)
+	_ caddyhttp.MiddlewareHandler = (*RateLimit)(nil)
+)
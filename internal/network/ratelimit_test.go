@@ -0,0 +1,125 @@
+package network
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestLimitZone(t *testing.T) {
+	t.Run("allows requests up to the limit", func(t *testing.T) {
+		z := getLimitZone("test-allow", 2, time.Minute)
+
+		assert.True(t, z.allow("10.0.0.1"))
+		assert.True(t, z.allow("10.0.0.1"))
+		assert.False(t, z.allow("10.0.0.1"))
+	})
+
+	t.Run("tracks clients independently", func(t *testing.T) {
+		z := getLimitZone("test-clients", 1, time.Minute)
+
+		assert.True(t, z.allow("10.0.0.1"))
+		assert.False(t, z.allow("10.0.0.1"))
+		assert.True(t, z.allow("10.0.0.2"))
+	})
+
+	t.Run("reuses zone across reloads with same limits", func(t *testing.T) {
+		z1 := getLimitZone("test-reuse", 5, time.Second)
+		z2 := getLimitZone("test-reuse", 5, time.Second)
+		assert.Same(t, z1, z2)
+	})
+
+	t.Run("replaces zone when limits change", func(t *testing.T) {
+		z1 := getLimitZone("test-replace", 5, time.Second)
+		z2 := getLimitZone("test-replace", 10, time.Second)
+		assert.NotSame(t, z1, z2)
+	})
+
+	t.Run("limits windows shorter than a nanosecond per request", func(t *testing.T) {
+		z := getLimitZone("test-short-window", 10, 5*time.Nanosecond)
+		z.allow("10.0.0.1")
+
+		limit := z.clients["10.0.0.1"].limiter.Limit()
+		assert.NotEqual(t, rate.Inf, limit)
+		assert.Equal(t, rate.Limit(2e9), limit)
+	})
+
+	t.Run("drops zones no config uses", func(t *testing.T) {
+		// A reload provisions the new config before cleaning up the old
+		z := getLimitZone("test-release", 5, time.Second)
+		require.Same(t, z, getLimitZone("test-release", 5, time.Second))
+		releaseLimitZone("test-release", z)
+		assert.Contains(t, limitZones, "test-release")
+
+		// The route is then removed
+		releaseLimitZone("test-release", z)
+		assert.NotContains(t, limitZones, "test-release")
+	})
+
+	t.Run("keeps a replacement zone when the old one is released", func(t *testing.T) {
+		old := getLimitZone("test-release-replaced", 5, time.Second)
+		z := getLimitZone("test-release-replaced", 10, time.Second)
+		releaseLimitZone("test-release-replaced", old)
+		assert.Same(t, z, limitZones["test-release-replaced"])
+	})
+
+	t.Run("prunes idle clients", func(t *testing.T) {
+		z := getLimitZone("test-prune", 1, time.Millisecond)
+		z.allow("10.0.0.1")
+
+		z.prune(time.Now().Add(time.Second))
+		assert.Empty(t, z.clients)
+	})
+}
+
+func TestRateLimitHandler(t *testing.T) {
+	t.Run("validates configuration", func(t *testing.T) {
+		assert.Error(t, (&RateLimit{Requests: 0, Window: 1}).Validate())
+		assert.Error(t, (&RateLimit{Requests: 1, Window: 0}).Validate())
+		assert.NoError(t, (&RateLimit{Requests: 1, Window: 1}).Validate())
+	})
+
+	t.Run("returns 429 once the limit is reached", func(t *testing.T) {
+		rl := &RateLimit{Requests: 1, Window: caddy.Duration(time.Minute)}
+		rl.zone = getLimitZone("test-handler", rl.Requests, time.Minute)
+
+		next := nextHandler(func(w http.ResponseWriter, r *http.Request) error {
+			w.WriteHeader(http.StatusOK)
+			return nil
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.0.0.9:5555"
+
+		rec := httptest.NewRecorder()
+		require.NoError(t, rl.ServeHTTP(rec, req, next))
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		rec = httptest.NewRecorder()
+		err := rl.ServeHTTP(rec, req, next)
+		assert.Error(t, err)
+		assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+	})
+}
+
+// nextHandler adapts a function to caddyhttp.Handler
+type nextHandler func(w http.ResponseWriter, r *http.Request) error
+
+func (h nextHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) error {
+	return h(w, r)
+}
+
+func TestClientIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.168.1.10:4321"
+	assert.Equal(t, "192.168.1.10", clientIP(req))
+
+	req.RemoteAddr = "not-an-addr"
+	assert.Equal(t, "not-an-addr", clientIP(req))
+}
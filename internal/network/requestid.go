@@ -0,0 +1,83 @@
+package network
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+// RequestIDHeader carries the ID the router gives each request it routes
+// to a puck. It is set on the request the puck receives and on the
+// response, and the daemon logs it against anything the request set off,
+// such as waking the puck.
+const RequestIDHeader = "X-Request-Id"
+
+// maxRequestID bounds request IDs kept from an upstream proxy
+const maxRequestID = 128
+
+// WakeRequest is the routed request that woke a puck
+type WakeRequest struct {
+	ID     string `json:"id"`
+	Method string `json:"method"`
+	Host   string `json:"host"`
+	Path   string `json:"path"`
+	Client string `json:"client,omitempty"`
+}
+
+// String describes the request for logs and puck events
+func (w WakeRequest) String() string {
+	s := fmt.Sprintf("request %s: %s %s%s", w.ID, w.Method, w.Host, w.Path)
+	if w.Client != "" {
+		s += " from " + w.Client
+	}
+	return s
+}
+
+// ensureRequestID gives a request an ID, keeping one a proxy in front of
+// the router already assigned, and echoes it on the response
+func ensureRequestID(w http.ResponseWriter, req *http.Request) string {
+	id := req.Header.Get(RequestIDHeader)
+	if !validRequestID(id) {
+		b := make([]byte, 8)
+		rand.Read(b)
+		id = hex.EncodeToString(b)
+		req.Header.Set(RequestIDHeader, id)
+	}
+	w.Header().Set(RequestIDHeader, id)
+	return id
+}
+
+// validRequestID reports whether an incoming ID is safe to pass on and log
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestID {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case c == '-' || c == '_' || c == '.' || c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// wakeRequest describes a request for the daemon waking its puck
+func wakeRequest(req *http.Request) WakeRequest {
+	client, _ := caddyhttp.GetVar(req.Context(), caddyhttp.ClientIPVarKey).(string)
+	if client == "" {
+		client, _, _ = net.SplitHostPort(req.RemoteAddr)
+	}
+	return WakeRequest{
+		ID:     req.Header.Get(RequestIDHeader),
+		Method: req.Method,
+		Host:   req.Host,
+		Path:   req.URL.Path,
+		Client: client,
+	}
+}
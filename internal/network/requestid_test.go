@@ -0,0 +1,45 @@
+package network
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnsureRequestID(t *testing.T) {
+	t.Run("generates one", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/web/", nil)
+		w := httptest.NewRecorder()
+		id := ensureRequestID(w, req)
+		assert.Len(t, id, 16)
+		assert.Equal(t, id, req.Header.Get(RequestIDHeader))
+		assert.Equal(t, id, w.Header().Get(RequestIDHeader))
+	})
+
+	t.Run("keeps an upstream proxy's", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/web/", nil)
+		req.Header.Set(RequestIDHeader, "cf-8a2b:01")
+		assert.Equal(t, "cf-8a2b:01", ensureRequestID(httptest.NewRecorder(), req))
+	})
+
+	t.Run("replaces unsafe ones", func(t *testing.T) {
+		for _, bad := range []string{"a b", "id\"; rm", strings.Repeat("x", maxRequestID+1)} {
+			req := httptest.NewRequest(http.MethodGet, "/web/", nil)
+			req.Header.Set(RequestIDHeader, bad)
+			assert.NotEqual(t, bad, ensureRequestID(httptest.NewRecorder(), req))
+		}
+	})
+}
+
+func TestWakeRequest(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "http://puck.example.com/web/hook", nil)
+	req.RemoteAddr = "203.0.113.9:52100"
+	req.Header.Set(RequestIDHeader, "abc123")
+
+	wr := wakeRequest(req)
+	assert.Equal(t, WakeRequest{ID: "abc123", Method: "POST", Host: "puck.example.com", Path: "/web/hook", Client: "203.0.113.9"}, wr)
+	assert.Equal(t, "request abc123: POST puck.example.com/web/hook from 203.0.113.9", wr.String())
+}
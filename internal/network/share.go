@@ -0,0 +1,205 @@
+package network
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+// sharePathPrefix is where share links are served; puck names can't start
+// with an underscore, so it never collides with a puck route
+const sharePathPrefix = "/_share/"
+
+// shareTokenPattern matches tokens that are safe to use in a path
+var shareTokenPattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+func init() {
+	caddy.RegisterModule(ShareGuard{})
+}
+
+// SharePath returns the router path serving a share token
+func SharePath(token string) string {
+	return sharePathPrefix + token + "/"
+}
+
+// shareLink is a share token routed to a puck until it expires
+type shareLink struct {
+	Puck    string
+	Expires time.Time
+}
+
+// ShareGuard is a Caddy HTTP handler that rejects requests on a share link
+// once it has expired, even before the daemon prunes the route
+type ShareGuard struct {
+	Expires int64 `json:"expires"` // unix seconds
+}
+
+// CaddyModule returns the Caddy module information
+func (ShareGuard) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.handlers.puck_share",
+		New: func() caddy.Module { return new(ShareGuard) },
+	}
+}
+
+// ServeHTTP returns 410 Gone for expired links
+func (g ShareGuard) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhttp.Handler) error {
+	if !time.Now().Before(time.Unix(g.Expires, 0)) {
+		return caddyhttp.Error(http.StatusGone, fmt.Errorf("share link expired"))
+	}
+	// Shared pages shouldn't end up in search results
+	w.Header().Set("X-Robots-Tag", "noindex")
+	return next.ServeHTTP(w, r)
+}
+
+// AddShare serves a puck at SharePath(token) until expires. The link only
+// works while the puck has a route.
+func (r *Router) AddShare(token, puckName string, expires time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !shareTokenPattern.MatchString(token) {
+		return fmt.Errorf("invalid share token")
+	}
+
+	next := maps.Clone(r.shares)
+	next[token] = shareLink{Puck: puckName, Expires: expires}
+	return r.setShares(next)
+}
+
+// RemoveShare stops serving a share token
+func (r *Router) RemoveShare(token string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.shares[token]; !ok {
+		return nil
+	}
+
+	next := maps.Clone(r.shares)
+	delete(next, token)
+	return r.setShares(next)
+}
+
+// RemoveShares stops serving every share link for a puck
+func (r *Router) RemoveShares(puckName string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.setShares(r.sharesWithout(func(link shareLink) bool {
+		return link.Puck == puckName
+	}))
+}
+
+// PruneShares stops serving links that have expired at t
+func (r *Router) PruneShares(t time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.setShares(r.sharesWithout(func(link shareLink) bool {
+		return !t.Before(link.Expires)
+	}))
+}
+
+// sharesWithout returns the share table minus links matching drop, or nil
+// if nothing matches
+func (r *Router) sharesWithout(drop func(shareLink) bool) map[string]shareLink {
+	next := maps.Clone(r.shares)
+	maps.DeleteFunc(next, func(_ string, link shareLink) bool {
+		return drop(link)
+	})
+	if len(next) == len(r.shares) {
+		return nil
+	}
+	return next
+}
+
+// setShares swaps in a new share table, keeping the old one if Caddy
+// rejects the config. A nil table means nothing changed.
+func (r *Router) setShares(next map[string]shareLink) error {
+	if next == nil {
+		return nil
+	}
+
+	prev := r.shares
+	r.shares = next
+	if err := r.reload(); err != nil {
+		r.shares = prev
+		return err
+	}
+	r.notifyShares()
+	return nil
+}
+
+// notifyShares signals SharesChanged without waiting on its reader
+func (r *Router) notifyShares() {
+	select {
+	case r.sharesChanged <- struct{}{}:
+	default:
+	}
+}
+
+// SharesChanged is signaled whenever share links are added or removed;
+// changes made before it is read are signaled once
+func (r *Router) SharesChanged() <-chan struct{} {
+	return r.sharesChanged
+}
+
+// HasShares reports whether any share link is being served
+func (r *Router) HasShares() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.shares) > 0
+}
+
+// SharePort returns the port of the listener serving only share links,
+// or zero if there is none
+func (r *Router) SharePort() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.sharePort
+}
+
+// shareRoutes builds a route for each share link whose puck is routed
+func (r *Router) shareRoutes() []map[string]interface{} {
+	routes := make([]map[string]interface{}, 0, len(r.shares))
+	for token, link := range r.shares {
+		info, ok := r.routes[link.Puck]
+		if !ok {
+			continue
+		}
+
+		pathPrefix := sharePathPrefix + token
+		handlers := append([]map[string]interface{}{
+			{"handler": "puck_share", "expires": link.Expires.Unix()},
+		}, r.routeHandlers(link.Puck, info, pathPrefix)...)
+
+		routes = append(routes, map[string]interface{}{
+			"match": []map[string]interface{}{
+				{"path": []string{pathPrefix, pathPrefix + "/*"}},
+			},
+			"handle": handlers,
+		})
+	}
+	return routes
+}
+
+// shareServerConfig builds the server block of the share listener, which
+// serves share links and answers every other path, the landing page's
+// included, with a 404
+func (r *Router) shareServerConfig() map[string]interface{} {
+	routes := append(r.shareRoutes(), map[string]interface{}{
+		"handle": []map[string]interface{}{
+			{"handler": "static_response", "status_code": http.StatusNotFound},
+		},
+	})
+	return map[string]interface{}{
+		"listen": []string{fmt.Sprintf("127.0.0.1:%d", r.sharePort)},
+		"routes": routes,
+	}
+}
@@ -0,0 +1,155 @@
+package network
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShareRoutes(t *testing.T) {
+	newShareRouter := func() *Router {
+		router := NewRouter(8080, "localhost")
+		router.routes["web"] = routeInfo{IP: "127.0.0.1", Port: 9000}
+		return router
+	}
+
+	t.Run("serves shared puck under its token", func(t *testing.T) {
+		router := newShareRouter()
+		expires := time.Now().Add(time.Hour)
+		require.NoError(t, router.AddShare("abc.sig", "web", expires))
+
+		routes := router.buildConfig()["apps"].(map[string]interface{})["http"].(map[string]interface{})["servers"].(map[string]interface{})["puck"].(map[string]interface{})["routes"].([]map[string]interface{})
+		require.Len(t, routes, 3)
+
+		share := routes[0]
+		assert.Equal(t, []map[string]interface{}{{"path": []string{"/_share/abc.sig", "/_share/abc.sig/*"}}}, share["match"])
+
+		handlers := share["handle"].([]map[string]interface{})
+		require.Len(t, handlers, 4)
+		assert.Equal(t, map[string]interface{}{"handler": "puck_share", "expires": expires.Unix()}, handlers[0])
+		assert.Equal(t, "puck_hits", handlers[1]["handler"])
+		assert.Equal(t, "/_share/abc.sig", handlers[2]["strip_path_prefix"])
+		assert.Equal(t, "reverse_proxy", handlers[3]["handler"])
+
+		cfgJSON, err := json.Marshal(router.buildConfig())
+		require.NoError(t, err)
+		assert.NoError(t, validateCaddyConfig(cfgJSON))
+	})
+
+	t.Run("skips links for pucks without a route", func(t *testing.T) {
+		router := newShareRouter()
+		require.NoError(t, router.AddShare("abc.sig", "api", time.Now().Add(time.Hour)))
+		assert.Empty(t, router.shareRoutes())
+	})
+
+	t.Run("rejects unsafe tokens", func(t *testing.T) {
+		router := newShareRouter()
+		assert.Error(t, router.AddShare("../web", "web", time.Now().Add(time.Hour)))
+	})
+
+	t.Run("removes links", func(t *testing.T) {
+		router := newShareRouter()
+		require.NoError(t, router.AddShare("a.sig", "web", time.Now().Add(time.Hour)))
+		require.NoError(t, router.AddShare("b.sig", "web", time.Now().Add(time.Hour)))
+		require.NoError(t, router.AddShare("c.sig", "api", time.Now().Add(time.Hour)))
+
+		require.NoError(t, router.RemoveShare("a.sig"))
+		assert.NotContains(t, router.shares, "a.sig")
+
+		require.NoError(t, router.RemoveShares("web"))
+		assert.Equal(t, []string{"c.sig"}, shareTokens(router))
+	})
+
+	t.Run("prunes expired links", func(t *testing.T) {
+		router := newShareRouter()
+		require.NoError(t, router.AddShare("old.sig", "web", time.Now().Add(-time.Minute)))
+		require.NoError(t, router.AddShare("new.sig", "web", time.Now().Add(time.Hour)))
+
+		require.NoError(t, router.PruneShares(time.Now()))
+		assert.Equal(t, []string{"new.sig"}, shareTokens(router))
+	})
+}
+
+func TestShareListener(t *testing.T) {
+	servers := func(router *Router) map[string]interface{} {
+		apps := router.buildConfig()["apps"].(map[string]interface{})
+		return apps["http"].(map[string]interface{})["servers"].(map[string]interface{})
+	}
+
+	t.Run("disabled by default", func(t *testing.T) {
+		router := NewRouter(8080, "localhost")
+		assert.NotContains(t, servers(router), "puck-share")
+	})
+
+	t.Run("serves only share links on loopback", func(t *testing.T) {
+		router := NewRouter(8080, "localhost")
+		router.SetSharePort(8081)
+		router.routes["web"] = routeInfo{IP: "127.0.0.1", Port: 9000}
+		router.routes["api"] = routeInfo{IP: "127.0.0.1", Port: 9001}
+		router.aliases["app"] = "web"
+		require.NoError(t, router.AddShare("abc.sig", "web", time.Now().Add(time.Hour)))
+
+		server := servers(router)["puck-share"].(map[string]interface{})
+		assert.Equal(t, []string{"127.0.0.1:8081"}, server["listen"])
+
+		routes := server["routes"].([]map[string]interface{})
+		require.Len(t, routes, 2)
+		assert.Equal(t, []map[string]interface{}{{"path": []string{"/_share/abc.sig", "/_share/abc.sig/*"}}}, routes[0]["match"])
+		assert.NotContains(t, routes[1], "match")
+		assert.Equal(t, []map[string]interface{}{{"handler": "static_response", "status_code": http.StatusNotFound}}, routes[1]["handle"])
+
+		cfgJSON, err := json.Marshal(router.buildConfig())
+		require.NoError(t, err)
+		assert.NoError(t, validateCaddyConfig(cfgJSON))
+	})
+
+	t.Run("answers everything with a 404 without links", func(t *testing.T) {
+		router := NewRouter(8080, "localhost")
+		router.SetSharePort(8081)
+		router.routes["web"] = routeInfo{IP: "127.0.0.1", Port: 9000}
+
+		routes := servers(router)["puck-share"].(map[string]interface{})["routes"].([]map[string]interface{})
+		require.Len(t, routes, 1)
+		assert.NotContains(t, routes[0], "match")
+	})
+}
+
+func TestShareGuard(t *testing.T) {
+	next := caddyhttp.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		w.WriteHeader(http.StatusOK)
+		return nil
+	})
+
+	t.Run("passes requests before expiry", func(t *testing.T) {
+		guard := ShareGuard{Expires: time.Now().Add(time.Hour).Unix()}
+		rec := httptest.NewRecorder()
+
+		require.NoError(t, guard.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil), next))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "noindex", rec.Header().Get("X-Robots-Tag"))
+	})
+
+	t.Run("rejects expired links", func(t *testing.T) {
+		guard := ShareGuard{Expires: time.Now().Add(-time.Minute).Unix()}
+
+		err := guard.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil), next)
+		var herr caddyhttp.HandlerError
+		require.ErrorAs(t, err, &herr)
+		assert.Equal(t, http.StatusGone, herr.StatusCode)
+	})
+}
+
+// shareTokens lists the router's share tokens
+func shareTokens(r *Router) []string {
+	tokens := make([]string, 0, len(r.shares))
+	for token := range r.shares {
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
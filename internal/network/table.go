@@ -0,0 +1,212 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/dustin/go-humanize"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// hitsPath is where the router process's admin API reports hit counts
+const hitsPath = "/puck/hits"
+
+func init() {
+	caddy.RegisterModule(Hits{})
+	caddy.RegisterModule(hitsAdmin{})
+}
+
+// hitCounters holds a *atomic.Uint64 per puck name, counting requests
+// since the process started; Caddy reloads don't reset them
+var hitCounters sync.Map
+
+// Hits is a Caddy HTTP handler that counts the requests routed to a puck.
+// As the first handler on every puck route it also tags them with a
+// request ID.
+type Hits struct {
+	Puck string `json:"puck"`
+}
+
+// CaddyModule returns the Caddy module information
+func (Hits) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.handlers.puck_hits",
+		New: func() caddy.Module { return new(Hits) },
+	}
+}
+
+// ServeHTTP counts the request, gives it an ID and passes it on
+func (h Hits) ServeHTTP(w http.ResponseWriter, req *http.Request, next caddyhttp.Handler) error {
+	ensureRequestID(w, req)
+	c, _ := hitCounters.LoadOrStore(h.Puck, new(atomic.Uint64))
+	c.(*atomic.Uint64).Add(1)
+	return next.ServeHTTP(w, req)
+}
+
+// hitCounts returns this process's hit counts by puck name
+func hitCounts() map[string]uint64 {
+	counts := make(map[string]uint64)
+	hitCounters.Range(func(k, v any) bool {
+		counts[k.(string)] = v.(*atomic.Uint64).Load()
+		return true
+	})
+	return counts
+}
+
+// hitsAdmin serves the hit counts on Caddy's admin API, which is how the
+// daemon reads them from the router process
+type hitsAdmin struct{}
+
+// CaddyModule returns the Caddy module information
+func (hitsAdmin) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "admin.api.puck_hits",
+		New: func() caddy.Module { return new(hitsAdmin) },
+	}
+}
+
+// Routes returns the admin route reporting hit counts
+func (hitsAdmin) Routes() []caddy.AdminRoute {
+	return []caddy.AdminRoute{{
+		Pattern: hitsPath,
+		Handler: caddy.AdminHandlerFunc(func(w http.ResponseWriter, req *http.Request) error {
+			w.Header().Set("Content-Type", "application/json")
+			return json.NewEncoder(w).Encode(hitCounts())
+		}),
+	}}
+}
+
+// RouteEntry is a puck route as the router is serving it
+type RouteEntry struct {
+	Name     string `json:"name"`
+	Target   string `json:"target"` // the container address requests are proxied to
+	Protocol string `json:"protocol"`
+	// Paths serving the puck: its own, then its aliases
+	Paths []string `json:"paths"`
+	// Hosts the paths are served on, then the puck's own tailnet node
+	Domains []string `json:"domains"`
+	// Extra container ports served under the puck's path, as path -> address
+	Endpoints map[string]string `json:"endpoints,omitempty"`
+	// Limits guarding the puck, e.g. "rate limit 60/1m0s per client"
+	Protections []string `json:"protections,omitempty"`
+	Shares      int      `json:"shares,omitempty"` // live share links
+	Asleep      bool     `json:"asleep,omitempty"` // the next request wakes it
+	// Requests since the router started
+	Hits uint64 `json:"hits"`
+	// When it last received a request, for routes that wake their puck
+	LastRequest *time.Time `json:"last_request,omitempty"`
+}
+
+// Routes returns the route table the router is serving, sorted by name.
+// Hit counts come from the router process when it runs in one; if it
+// can't be reached they are left at zero.
+func (r *Router) Routes(ctx context.Context) []RouteEntry {
+	hits := r.hits(ctx)
+	access := r.LastAccess()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	aliases := make(map[string][]string)
+	for _, path := range slices.Sorted(maps.Keys(r.aliases)) {
+		aliases[r.aliases[path]] = append(aliases[r.aliases[path]], path)
+	}
+	shares := make(map[string]int)
+	for _, link := range r.shares {
+		shares[link.Puck]++
+	}
+	hosts := []string{fmt.Sprintf("%s:%d", r.domain, r.port)}
+	if r.tls.Port > 0 {
+		hosts = append(hosts, fmt.Sprintf("%s:%d", r.domain, r.tls.Port))
+	}
+
+	entries := make([]RouteEntry, 0, len(r.routes))
+	for _, name := range slices.Sorted(maps.Keys(r.routes)) {
+		info := r.routes[name]
+		e := RouteEntry{
+			Name:        name,
+			Target:      fmt.Sprintf("%s:%d", info.IP, info.Port),
+			Protocol:    info.Config.Protocol,
+			Paths:       append([]string{"/" + name}, aliases[name]...),
+			Domains:     slices.Clone(hosts),
+			Protections: routeProtections(info.Config),
+			Shares:      shares[name],
+			Asleep:      r.sleeping[name],
+			Hits:        hits[name],
+		}
+		if e.Protocol == "" {
+			e.Protocol = store.ProtocolHTTP
+		}
+		if _, ok := r.nodes[name]; ok && r.tailnet != "" {
+			e.Domains = append(e.Domains, name+"."+r.tailnet)
+		}
+		for _, ep := range r.endpoints[name] {
+			if e.Endpoints == nil {
+				e.Endpoints = make(map[string]string)
+			}
+			e.Endpoints[fmt.Sprintf("/%s/%s", name, ep.Name)] = fmt.Sprintf("%s:%d", ep.IP, ep.Port)
+		}
+		if t, ok := access[name]; ok {
+			e.LastRequest = &t
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// routeProtections describes the limits a route enforces
+func routeProtections(rc store.RouteConfig) []string {
+	var out []string
+	if rc.RateLimit > 0 && rc.RateLimitWindow > 0 {
+		out = append(out, fmt.Sprintf("rate limit %d/%s per client", rc.RateLimit, rc.RateLimitWindow))
+	}
+	if rc.MaxConns > 0 {
+		out = append(out, fmt.Sprintf("max %d connections", rc.MaxConns))
+	}
+	if rc.Bandwidth > 0 {
+		out = append(out, fmt.Sprintf("bandwidth %s/s", humanize.Bytes(uint64(rc.Bandwidth))))
+	}
+	if rc.MaxBodySize > 0 {
+		out = append(out, fmt.Sprintf("max body %s", humanize.Bytes(uint64(rc.MaxBodySize))))
+	}
+	return out
+}
+
+// hits returns the hit counts of the process Caddy runs in
+func (r *Router) hits(ctx context.Context) map[string]uint64 {
+	r.mu.RLock()
+	child, running := r.child, r.running
+	r.mu.RUnlock()
+	if child == nil {
+		return hitCounts()
+	}
+	counts := make(map[string]uint64)
+	if !running {
+		return counts
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, childStartTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://puck-router"+hitsPath, nil)
+	if err != nil {
+		return counts
+	}
+	resp, err := unixClient(child.adminSocket()).Do(req)
+	if err != nil {
+		return counts
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		json.NewDecoder(resp.Body).Decode(&counts)
+	}
+	return counts
+}
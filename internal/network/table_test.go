@@ -0,0 +1,50 @@
+package network
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoutes(t *testing.T) {
+	ctx := context.Background()
+	router := NewRouter(8080, "localhost")
+	router.SetTailnet("example.ts.net")
+	router.SetTLS(TLSOptions{Port: 8443})
+	require.NoError(t, router.AddRoute("web", "10.88.0.2", 3000, store.RouteConfig{RateLimit: 60, RateLimitWindow: time.Minute, MaxBodySize: 10_000_000}))
+	require.NoError(t, router.AddRoute("api", "10.88.0.3", 8080, store.RouteConfig{Protocol: store.ProtocolH2C}))
+	require.NoError(t, router.SetAlias("/app", "web"))
+	require.NoError(t, router.SetEndpoints("web", []Endpoint{{Name: "admin", IP: "10.88.0.2", Port: 9000}}))
+	require.NoError(t, router.ShareOnTailnet("web", nil))
+	require.NoError(t, router.AddShare("tok", "web", time.Now().Add(time.Hour)))
+
+	// Requests through the hits handler are counted
+	next := caddyhttp.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error { return nil })
+	hits := router.Routes(ctx)[1].Hits
+	for range 3 {
+		require.NoError(t, Hits{Puck: "web"}.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/web/", nil), next))
+	}
+
+	routes := router.Routes(ctx)
+	require.Len(t, routes, 2)
+	assert.Equal(t, "api", routes[0].Name)
+	assert.Equal(t, store.ProtocolH2C, routes[0].Protocol)
+	assert.Empty(t, routes[0].Protections)
+
+	web := routes[1]
+	assert.Equal(t, "10.88.0.2:3000", web.Target)
+	assert.Equal(t, store.ProtocolHTTP, web.Protocol)
+	assert.Equal(t, []string{"/web", "/app"}, web.Paths)
+	assert.Equal(t, []string{"localhost:8080", "localhost:8443", "web.example.ts.net"}, web.Domains)
+	assert.Equal(t, map[string]string{"/web/admin": "10.88.0.2:9000"}, web.Endpoints)
+	assert.Equal(t, []string{"rate limit 60/1m0s per client", "max body 10 MB"}, web.Protections)
+	assert.Equal(t, 1, web.Shares)
+	assert.Equal(t, hits+3, web.Hits)
+}
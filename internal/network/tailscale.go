@@ -0,0 +1,127 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"tailscale.com/tsnet"
+	"tailscale.com/types/logger"
+)
+
+// tailscaleStateFile is the file a tailnet node keeps its identity in
+const tailscaleStateFile = "tailscaled.state"
+
+// TailscaleNodeDir is where a router tailnet node keeps its state under
+// dir: the router's own node in router, and a shared puck's node in
+// pucks/<name>. An empty name is the router's node.
+func TailscaleNodeDir(dir, puckName string) string {
+	if puckName == "" {
+		return filepath.Join(dir, "router")
+	}
+	return filepath.Join(dir, "pucks", puckName)
+}
+
+// TailscaleNodeState is a router tailnet node's state on disk
+type TailscaleNodeState struct {
+	Name     string `json:"name"` // the puck's name; empty for the router's own node
+	Dir      string `json:"dir"`
+	LoggedIn bool   `json:"logged_in"` // has an identity on the tailnet
+}
+
+// TailscaleNodeStates lists the router tailnet nodes with state under dir,
+// the router's own node first
+func TailscaleNodeStates(dir string) ([]TailscaleNodeState, error) {
+	var states []TailscaleNodeState
+	if router := TailscaleNodeDir(dir, ""); dirExists(router) {
+		states = append(states, TailscaleNodeState{Dir: router, LoggedIn: TailscaleLoggedIn(router)})
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, "pucks"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		nodeDir := TailscaleNodeDir(dir, e.Name())
+		states = append(states, TailscaleNodeState{Name: e.Name(), Dir: nodeDir, LoggedIn: TailscaleLoggedIn(nodeDir)})
+	}
+	return states, nil
+}
+
+// AdoptLegacyTailscaleState moves the state older versions left in the
+// user's config directory, where caddy-tailscale keeps it by default, to
+// where the router now keeps it under dir, so the nodes keep their
+// identity rather than joining the tailnet again. It returns the names it
+// moved.
+func AdoptLegacyTailscaleState(dir string, puckNames []string) ([]string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, nil
+	}
+
+	var moved []string
+	for _, name := range append([]string{""}, puckNames...) {
+		legacy := filepath.Join(configDir, "tsnet-caddy-"+name)
+		target := TailscaleNodeDir(dir, name)
+		if !dirExists(legacy) || dirExists(target) {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+			return moved, err
+		}
+		if err := os.Rename(legacy, target); err != nil {
+			return moved, fmt.Errorf("moving %s: %w", legacy, err)
+		}
+		moved = append(moved, name)
+	}
+	return moved, nil
+}
+
+// LogoutTailscaleNode logs a node out of the tailnet with the state in
+// stateDir, so it is removed rather than left behind as a stale device,
+// then deletes the state. The node must not be running.
+func LogoutTailscaleNode(ctx context.Context, stateDir, hostname string) error {
+	if TailscaleLoggedIn(stateDir) {
+		srv := &tsnet.Server{
+			Dir:      stateDir,
+			Hostname: hostname,
+			Logf:     logger.Discard,
+			UserLogf: logger.Discard,
+		}
+		defer srv.Close()
+		if err := srv.Start(); err != nil {
+			return fmt.Errorf("starting node: %w", err)
+		}
+		lc, err := srv.LocalClient()
+		if err != nil {
+			return err
+		}
+		if err := lc.Logout(ctx); err != nil {
+			return fmt.Errorf("logging out: %w", err)
+		}
+		srv.Close()
+	}
+	if err := os.RemoveAll(stateDir); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// TailscaleLoggedIn reports whether a node's state directory holds an
+// identity on the tailnet
+func TailscaleLoggedIn(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, tailscaleStateFile))
+	return err == nil
+}
+
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
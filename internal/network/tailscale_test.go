@@ -0,0 +1,56 @@
+package network
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTailscaleNodeStates(t *testing.T) {
+	dir := t.TempDir()
+
+	states, err := TailscaleNodeStates(dir)
+	require.NoError(t, err)
+	assert.Empty(t, states)
+
+	for _, name := range []string{"web", "", "api"} {
+		require.NoError(t, os.MkdirAll(TailscaleNodeDir(dir, name), 0700))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(TailscaleNodeDir(dir, "web"), tailscaleStateFile), []byte("{}"), 0600))
+
+	states, err = TailscaleNodeStates(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []TailscaleNodeState{
+		{Dir: filepath.Join(dir, "router")},
+		{Name: "api", Dir: filepath.Join(dir, "pucks", "api")},
+		{Name: "web", Dir: filepath.Join(dir, "pucks", "web"), LoggedIn: true},
+	}, states)
+
+	// Without an identity there's nothing to log out, just state to remove
+	require.NoError(t, LogoutTailscaleNode(context.Background(), TailscaleNodeDir(dir, "api"), "api"))
+	assert.NoDirExists(t, TailscaleNodeDir(dir, "api"))
+}
+
+func TestAdoptLegacyTailscaleState(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configDir)
+	dir := t.TempDir()
+
+	for _, name := range []string{"", "web", "old"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(configDir, "tsnet-caddy-"+name), 0700))
+	}
+	// Already kept under dir, so the legacy copy is left alone
+	require.NoError(t, os.MkdirAll(TailscaleNodeDir(dir, "web"), 0700))
+
+	moved, err := AdoptLegacyTailscaleState(dir, []string{"web", "api"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{""}, moved)
+	assert.DirExists(t, TailscaleNodeDir(dir, ""))
+	assert.NoDirExists(t, filepath.Join(configDir, "tsnet-caddy-"))
+	assert.DirExists(t, filepath.Join(configDir, "tsnet-caddy-web"))
+	assert.DirExists(t, filepath.Join(configDir, "tsnet-caddy-old"), "not shared, so not adopted")
+}
@@ -0,0 +1,207 @@
+package network
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"golang.org/x/time/rate"
+)
+
+func init() {
+	caddy.RegisterModule(TrafficLimit{})
+}
+
+// TrafficLimit is a Caddy HTTP handler that caps the concurrent requests
+// and the bandwidth of one route, across all clients. Like RateLimit, its
+// state lives in a package-level zone so that in-flight requests are still
+// counted after a config reload.
+type TrafficLimit struct {
+	Zone     string `json:"zone"`
+	MaxConns int    `json:"max_conns,omitempty"`
+	// Bytes per second, shared by request and response bodies
+	Bandwidth int64 `json:"bandwidth,omitempty"`
+
+	zone *trafficZone
+}
+
+// CaddyModule returns the Caddy module information
+func (TrafficLimit) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.handlers.puck_traffic_limit",
+		New: func() caddy.Module { return new(TrafficLimit) },
+	}
+}
+
+// Provision attaches the handler to its shared traffic zone
+func (tl *TrafficLimit) Provision(ctx caddy.Context) error {
+	tl.zone = getTrafficZone(tl.Zone, tl.MaxConns, tl.Bandwidth)
+	return nil
+}
+
+// Validate checks the handler configuration
+func (tl *TrafficLimit) Validate() error {
+	if tl.MaxConns < 0 || tl.Bandwidth < 0 {
+		return fmt.Errorf("limits cannot be negative")
+	}
+	if tl.MaxConns == 0 && tl.Bandwidth == 0 {
+		return fmt.Errorf("max_conns or bandwidth must be set")
+	}
+	return nil
+}
+
+// ServeHTTP rejects requests beyond the connection limit with 503 Service
+// Unavailable and throttles bodies to the route's bandwidth
+func (tl *TrafficLimit) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhttp.Handler) error {
+	if !tl.zone.acquire() {
+		w.Header().Set("Retry-After", "1")
+		return caddyhttp.Error(http.StatusServiceUnavailable, fmt.Errorf("too many connections"))
+	}
+	defer tl.zone.release()
+
+	if limiter := tl.zone.bandwidth(); limiter != nil {
+		ctx := r.Context()
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = &throttledReader{ReadCloser: r.Body, ctx: ctx, limiter: limiter}
+		}
+		w = &throttledWriter{ResponseWriterWrapper: &caddyhttp.ResponseWriterWrapper{ResponseWriter: w}, ctx: ctx, limiter: limiter}
+	}
+	return next.ServeHTTP(w, r)
+}
+
+// trafficZone tracks the open requests and bandwidth of one route
+type trafficZone struct {
+	mu       sync.Mutex
+	maxConns int
+	active   int
+	limiter  *rate.Limiter // nil without a bandwidth limit
+}
+
+var (
+	trafficZonesMu sync.Mutex
+	trafficZones   = make(map[string]*trafficZone)
+)
+
+// getTrafficZone returns the named zone with its limits updated in place,
+// so requests already in flight keep counting against the new limits
+func getTrafficZone(name string, maxConns int, bandwidth int64) *trafficZone {
+	trafficZonesMu.Lock()
+	z, ok := trafficZones[name]
+	if !ok {
+		z = &trafficZone{}
+		trafficZones[name] = z
+	}
+	trafficZonesMu.Unlock()
+
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	z.maxConns = maxConns
+	switch {
+	case bandwidth <= 0:
+		z.limiter = nil
+	case z.limiter == nil:
+		z.limiter = rate.NewLimiter(rate.Limit(bandwidth), trafficBurst(bandwidth))
+	default:
+		z.limiter.SetLimit(rate.Limit(bandwidth))
+		z.limiter.SetBurst(trafficBurst(bandwidth))
+	}
+	return z
+}
+
+// trafficBurst allows a second's worth of bytes at once, which is also the
+// largest chunk a throttled body is written in
+func trafficBurst(bandwidth int64) int {
+	const maxBurst = 1 << 20
+	if bandwidth > maxBurst {
+		return maxBurst
+	}
+	return int(bandwidth)
+}
+
+// acquire reports whether another request may proceed, counting it if so
+func (z *trafficZone) acquire() bool {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	if z.maxConns > 0 && z.active >= z.maxConns {
+		return false
+	}
+	z.active++
+	return true
+}
+
+// release stops counting a finished request
+func (z *trafficZone) release() {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	z.active--
+}
+
+func (z *trafficZone) bandwidth() *rate.Limiter {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	return z.limiter
+}
+
+// throttledReader paces a request body read by the upstream
+type throttledReader struct {
+	io.ReadCloser
+	ctx     context.Context
+	limiter *rate.Limiter
+}
+
+func (tr *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > tr.limiter.Burst() {
+		p = p[:tr.limiter.Burst()]
+	}
+	n, err := tr.ReadCloser.Read(p)
+	if n > 0 {
+		if waitErr := tr.limiter.WaitN(tr.ctx, n); waitErr != nil && err == nil {
+			err = waitErr
+		}
+	}
+	return n, err
+}
+
+// throttledWriter paces a response body written to the client
+type throttledWriter struct {
+	*caddyhttp.ResponseWriterWrapper
+	ctx     context.Context
+	limiter *rate.Limiter
+}
+
+func (tw *throttledWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		chunk := min(len(p), tw.limiter.Burst())
+		if err := tw.limiter.WaitN(tw.ctx, chunk); err != nil {
+			return written, err
+		}
+		n, err := tw.ResponseWriter.Write(p[:chunk])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[chunk:]
+	}
+	return written, nil
+}
+
+// ReadFrom goes through Write so that copied bodies are throttled too
+func (tw *throttledWriter) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(writerOnly{tw}, r)
+}
+
+// writerOnly hides ReadFrom so io.Copy doesn't recurse into it
+type writerOnly struct{ io.Writer }
+
+// Interface guards
+var (
+	_ caddy.Provisioner           = (*TrafficLimit)(nil)
+	_ caddy.Validator             = (*TrafficLimit)(nil)
+	_ caddyhttp.MiddlewareHandler = (*TrafficLimit)(nil)
+)
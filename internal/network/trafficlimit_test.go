@@ -0,0 +1,97 @@
+package network
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrafficZone(t *testing.T) {
+	t.Run("caps concurrent requests", func(t *testing.T) {
+		z := getTrafficZone("test-conns", 2, 0)
+
+		assert.True(t, z.acquire())
+		assert.True(t, z.acquire())
+		assert.False(t, z.acquire())
+		z.release()
+		assert.True(t, z.acquire())
+	})
+
+	t.Run("keeps counting in-flight requests across reloads", func(t *testing.T) {
+		z1 := getTrafficZone("test-reload", 1, 0)
+		require.True(t, z1.acquire())
+
+		z2 := getTrafficZone("test-reload", 2, 1024)
+		assert.Same(t, z1, z2)
+		assert.True(t, z2.acquire())
+		assert.False(t, z2.acquire())
+		assert.Equal(t, 1024, z2.bandwidth().Burst())
+
+		z3 := getTrafficZone("test-reload", 2, 0)
+		assert.Nil(t, z3.bandwidth())
+	})
+}
+
+func TestTrafficLimitHandler(t *testing.T) {
+	t.Run("validates configuration", func(t *testing.T) {
+		assert.Error(t, (&TrafficLimit{}).Validate())
+		assert.Error(t, (&TrafficLimit{MaxConns: -1}).Validate())
+		assert.NoError(t, (&TrafficLimit{MaxConns: 1}).Validate())
+		assert.NoError(t, (&TrafficLimit{Bandwidth: 1}).Validate())
+	})
+
+	t.Run("returns 503 beyond the connection limit", func(t *testing.T) {
+		tl := &TrafficLimit{MaxConns: 1}
+		tl.zone = getTrafficZone("test-handler-conns", 1, 0)
+
+		var inner error
+		next := nextHandler(func(w http.ResponseWriter, r *http.Request) error {
+			// A second request while this one is in flight is refused
+			inner = tl.ServeHTTP(httptest.NewRecorder(), r, nextHandler(func(w http.ResponseWriter, r *http.Request) error {
+				return nil
+			}))
+			w.WriteHeader(http.StatusOK)
+			return nil
+		})
+
+		rec := httptest.NewRecorder()
+		require.NoError(t, tl.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil), next))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.ErrorContains(t, inner, "too many connections")
+
+		// The slot is free again once the request finished
+		rec = httptest.NewRecorder()
+		require.NoError(t, tl.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil), next))
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("throttles bodies to the bandwidth", func(t *testing.T) {
+		tl := &TrafficLimit{Bandwidth: 4096}
+		tl.zone = getTrafficZone("test-handler-bandwidth", 0, 4096)
+
+		body := bytes.Repeat([]byte("x"), 4096)
+		next := nextHandler(func(w http.ResponseWriter, r *http.Request) error {
+			got, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			assert.Len(t, got, 2048)
+			_, err = w.Write(body)
+			return err
+		})
+
+		start := time.Now()
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("y", 2048)))
+		require.NoError(t, tl.ServeHTTP(rec, req, next))
+		assert.Equal(t, body, rec.Body.Bytes())
+
+		// The first second's worth passes as a burst; the rest waits
+		assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+	})
+}
@@ -0,0 +1,272 @@
+package network
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"tailscale.com/tsnet"
+	"tailscale.com/types/logger"
+)
+
+// TunnelProvider opens tunnels that serve a local URL, the router's share
+// listener's, at a public one, so share links work from outside the machine
+type TunnelProvider interface {
+	Name() string
+	// Open starts a tunnel to target, such as http://127.0.0.1:8080, and
+	// returns once it has a public URL
+	Open(ctx context.Context, target string) (Tunnel, error)
+}
+
+// Tunnel is an open tunnel
+type Tunnel interface {
+	// URL is the public base URL, without a trailing slash
+	URL() string
+	// Wait blocks until the tunnel closes, returning why
+	Wait() error
+	Close() error
+}
+
+// tunnelStartTimeout bounds how long a tunnel may take to get a public URL
+const tunnelStartTimeout = time.Minute
+
+// Funnel serves the target publicly with Tailscale Funnel, from a tailnet
+// node of its own. The node joins with TS_AUTHKEY or logs a login URL, and
+// the tailnet must allow it to use Funnel.
+type Funnel struct {
+	Hostname string
+	Dir      string // where the node keeps its state
+	// Logf receives what the user needs to act on, such as login URLs
+	Logf func(format string, args ...any)
+}
+
+// Name implements TunnelProvider
+func (f *Funnel) Name() string { return "funnel" }
+
+// Open implements TunnelProvider
+func (f *Funnel) Open(ctx context.Context, target string) (Tunnel, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+
+	srv := &tsnet.Server{
+		Hostname: f.Hostname,
+		Dir:      f.Dir,
+		Logf:     logger.Discard,
+		UserLogf: f.Logf,
+	}
+	if _, err := srv.Up(ctx); err != nil {
+		srv.Close()
+		return nil, fmt.Errorf("joining the tailnet: %w", err)
+	}
+	domains := srv.CertDomains()
+	if len(domains) == 0 {
+		srv.Close()
+		return nil, fmt.Errorf("the tailnet has no HTTPS certificates; enable HTTPS in its DNS settings")
+	}
+	ln, err := srv.ListenFunnel("tcp", ":443")
+	if err != nil {
+		srv.Close()
+		return nil, fmt.Errorf("listening with funnel: %w", err)
+	}
+
+	t := &funnelTunnel{srv: srv, url: "https://" + domains[0], done: make(chan struct{})}
+	go func() {
+		t.err = http.Serve(ln, httputil.NewSingleHostReverseProxy(u))
+		close(t.done)
+	}()
+	return t, nil
+}
+
+type funnelTunnel struct {
+	srv  *tsnet.Server
+	url  string
+	done chan struct{}
+	err  error
+}
+
+func (t *funnelTunnel) URL() string { return t.url }
+
+func (t *funnelTunnel) Wait() error {
+	<-t.done
+	return t.err
+}
+
+func (t *funnelTunnel) Close() error {
+	return t.srv.Close()
+}
+
+// CommandTunnel runs a tunnel client, such as cloudflared, that serves
+// the target publicly and prints the public URL it was given
+type CommandTunnel struct {
+	Provider string
+	// Command to run, split on spaces rather than run by a shell, with
+	// {url}, {host} and {port} replaced by the target's
+	Command string
+	// Match picks the public URL out of the client's output; nil takes
+	// the first https URL
+	Match *regexp.Regexp
+}
+
+// httpsURL matches any https URL in a tunnel client's output
+var httpsURL = regexp.MustCompile(`https://[^\s"'|<>]+`)
+
+// Cloudflared opens Cloudflare quick tunnels, which need no account
+func Cloudflared() *CommandTunnel {
+	return &CommandTunnel{
+		Provider: "cloudflared",
+		Command:  "cloudflared tunnel --no-autoupdate --url {url}",
+		// It also prints links to its terms and docs
+		Match: regexp.MustCompile(`https://[a-z0-9-]+\.trycloudflare\.com`),
+	}
+}
+
+// Localtunnel opens tunnels with the localtunnel client, lt
+func Localtunnel() *CommandTunnel {
+	return &CommandTunnel{
+		Provider: "localtunnel",
+		Command:  "lt --port {port} --local-host {host}",
+	}
+}
+
+// Name implements TunnelProvider
+func (c *CommandTunnel) Name() string { return c.Provider }
+
+// Open implements TunnelProvider
+func (c *CommandTunnel) Open(ctx context.Context, target string) (Tunnel, error) {
+	args, err := c.args(target)
+	if err != nil {
+		return nil, err
+	}
+	match := c.Match
+	if match == nil {
+		match = httpsURL
+	}
+
+	pr, pw := io.Pipe()
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout, cmd.Stderr = pw, pw
+	cmd.WaitDelay = time.Second
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting %s: %w", args[0], err)
+	}
+
+	t := &commandTunnel{cmd: cmd, done: make(chan struct{})}
+	found := make(chan string, 1)
+	scanned := make(chan struct{})
+	go func() {
+		defer close(scanned)
+		// Reads to the end, so the client never blocks on a full pipe
+		sent := false
+		sc := bufio.NewScanner(pr)
+		for sc.Scan() {
+			line := strings.TrimSpace(sc.Text())
+			if u := match.FindString(line); u != "" && !sent {
+				found <- strings.TrimSuffix(u, "/")
+				sent = true
+			}
+			if line != "" {
+				t.mu.Lock()
+				t.last = line
+				t.mu.Unlock()
+			}
+		}
+		io.Copy(io.Discard, pr)
+	}()
+	go func() {
+		t.err = cmd.Wait()
+		pw.Close()
+		close(t.done)
+	}()
+
+	timer := time.NewTimer(tunnelStartTimeout)
+	defer timer.Stop()
+	select {
+	case u := <-found:
+		t.mu.Lock()
+		t.url = u
+		t.mu.Unlock()
+		return t, nil
+	case <-t.done:
+		<-scanned
+		if last := t.lastLine(); last != "" {
+			return nil, fmt.Errorf("%s exited: %v: %s", args[0], t.err, last)
+		}
+		return nil, fmt.Errorf("%s exited: %v", args[0], t.err)
+	case <-ctx.Done():
+		t.Close()
+		return nil, ctx.Err()
+	case <-timer.C:
+		t.Close()
+		return nil, fmt.Errorf("%s printed no public URL within %s", args[0], tunnelStartTimeout)
+	}
+}
+
+// args expands the command for a target
+func (c *CommandTunnel) args(target string) ([]string, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	r := strings.NewReplacer("{url}", target, "{host}", u.Hostname(), "{port}", u.Port())
+	args := strings.Fields(c.Command)
+	if len(args) == 0 {
+		return nil, errors.New("no tunnel command")
+	}
+	for i, a := range args {
+		args[i] = r.Replace(a)
+	}
+	return args, nil
+}
+
+type commandTunnel struct {
+	cmd  *exec.Cmd
+	done chan struct{}
+	err  error
+
+	mu   sync.Mutex
+	url  string
+	last string // the last line it printed
+}
+
+func (t *commandTunnel) URL() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.url
+}
+
+func (t *commandTunnel) lastLine() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.last
+}
+
+func (t *commandTunnel) Wait() error {
+	<-t.done
+	if t.err == nil {
+		return fmt.Errorf("%s exited", t.cmd.Path)
+	}
+	return t.err
+}
+
+func (t *commandTunnel) Close() error {
+	select {
+	case <-t.done:
+		return nil
+	default:
+	}
+	t.cmd.Process.Kill()
+	<-t.done
+	return nil
+}
@@ -0,0 +1,72 @@
+package network
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tunnelScript writes a script standing in for a tunnel client
+func tunnelScript(t *testing.T, body string) string {
+	path := filepath.Join(t.TempDir(), "tunnel")
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+body), 0755))
+	return path
+}
+
+func TestCommandTunnel(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("takes the URL it prints", func(t *testing.T) {
+		script := tunnelScript(t, `echo "connecting to $1 on $2"
+echo "your url is: https://quiet-fox.loca.lt/" >&2
+exec sleep 60
+`)
+		tunnel := &CommandTunnel{Provider: "test", Command: script + " {host} {port}"}
+
+		tun, err := tunnel.Open(ctx, "http://127.0.0.1:8080")
+		require.NoError(t, err)
+		assert.Equal(t, "https://quiet-fox.loca.lt", tun.URL())
+
+		require.NoError(t, tun.Close())
+		assert.Error(t, tun.Wait())
+	})
+
+	t.Run("picks the URL matching", func(t *testing.T) {
+		script := tunnelScript(t, `echo "see https://www.cloudflare.com/website-terms/"
+echo "|  https://a-b-c.trycloudflare.com  |"
+exec sleep 60
+`)
+		tunnel := Cloudflared()
+		tunnel.Command = script
+
+		tun, err := tunnel.Open(ctx, "http://127.0.0.1:8080")
+		require.NoError(t, err)
+		defer tun.Close()
+		assert.Equal(t, "https://a-b-c.trycloudflare.com", tun.URL())
+	})
+
+	t.Run("reports a client that exits first", func(t *testing.T) {
+		script := tunnelScript(t, `echo "error: no route to host" >&2
+exit 1
+`)
+		tunnel := &CommandTunnel{Provider: "test", Command: script, Match: regexp.MustCompile(`https://\S+`)}
+
+		_, err := tunnel.Open(ctx, "http://127.0.0.1:8080")
+		assert.ErrorContains(t, err, "no route to host")
+	})
+
+	t.Run("expands the target", func(t *testing.T) {
+		args, err := Localtunnel().args("http://127.0.0.1:8080")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"lt", "--port", "8080", "--local-host", "127.0.0.1"}, args)
+
+		args, err = Cloudflared().args("http://127.0.0.1:8080")
+		require.NoError(t, err)
+		assert.Equal(t, "http://127.0.0.1:8080", args[len(args)-1])
+	})
+}
@@ -0,0 +1,135 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+// wakeTimeout bounds how long a request waits for a sleeping puck
+const wakeTimeout = 60 * time.Second
+
+func init() {
+	caddy.RegisterModule(Wake{})
+}
+
+// WakeFunc resumes a sleeping puck so req can be proxied to it
+type WakeFunc func(ctx context.Context, puckName string, req WakeRequest) error
+
+// Wake is a Caddy HTTP handler that notes each request to a puck and, if
+// the puck is asleep, resumes it before the request is proxied
+type Wake struct {
+	Puck string `json:"puck"`
+
+	// Callback is the daemon's socket when the router runs in its own
+	// process; requests are noted there
+	Callback string `json:"callback,omitempty"`
+}
+
+// CaddyModule returns the Caddy module information
+func (Wake) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.handlers.puck_wake",
+		New: func() caddy.Module { return new(Wake) },
+	}
+}
+
+// ServeHTTP resumes the puck if needed and passes the request on. The
+// route keeps the puck's host port while it sleeps, so the same proxy
+// handler reaches it once it is back.
+func (h Wake) ServeHTTP(w http.ResponseWriter, req *http.Request, next caddyhttp.Handler) error {
+	ctx, cancel := context.WithTimeout(req.Context(), wakeTimeout)
+	defer cancel()
+
+	var err error
+	wr := wakeRequest(req)
+	if h.Callback != "" {
+		body, _ := json.Marshal(wr)
+		err = socketCall(ctx, h.Callback, http.MethodPost, "/touch/"+url.PathEscape(h.Puck), body)
+	} else if r := activeRouter.Load(); r != nil {
+		err = r.touch(ctx, h.Puck, wr)
+	}
+	if err != nil {
+		w.Header().Set("Retry-After", "5")
+		return caddyhttp.Error(http.StatusServiceUnavailable, fmt.Errorf("waking %s for request %s: %w", h.Puck, wr.ID, err))
+	}
+	return next.ServeHTTP(w, req)
+}
+
+// SetWakeHandler enables wake-on-request: routes added afterwards note
+// each request, and requests to sleeping pucks call fn first
+func (r *Router) SetWakeHandler(fn WakeFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.wake = fn
+}
+
+// Sleep marks a routed puck as asleep so the next request wakes it. It
+// doesn't reload Caddy; the wake handler checks this on every request.
+func (r *Router) Sleep(puckName string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info, ok := r.routes[puckName]
+	if !ok {
+		return fmt.Errorf("no route for %s", puckName)
+	}
+	if !info.Wake {
+		return fmt.Errorf("wake-on-request is not enabled")
+	}
+	r.sleeping[puckName] = true
+	return nil
+}
+
+// Asleep reports whether a puck is waiting for a request to wake it
+func (r *Router) Asleep(puckName string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.sleeping[puckName]
+}
+
+// LastAccess returns when each puck last received a request
+func (r *Router) LastAccess() map[string]time.Time {
+	r.accessMu.Lock()
+	defer r.accessMu.Unlock()
+	return maps.Clone(r.access)
+}
+
+// touch records a request to a puck and wakes it if it is asleep. Wakes
+// are serialized so concurrent requests resume a puck only once, and the
+// first of them is the one the wake is put down to.
+func (r *Router) touch(ctx context.Context, puckName string, req WakeRequest) error {
+	r.accessMu.Lock()
+	r.access[puckName] = time.Now()
+	r.accessMu.Unlock()
+
+	if !r.Asleep(puckName) {
+		return nil
+	}
+
+	r.wakeMu.Lock()
+	defer r.wakeMu.Unlock()
+
+	r.mu.RLock()
+	asleep, fn := r.sleeping[puckName], r.wake
+	r.mu.RUnlock()
+	if !asleep || fn == nil {
+		return nil
+	}
+
+	if err := fn(ctx, puckName, req); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	delete(r.sleeping, puckName)
+	r.mu.Unlock()
+	return nil
+}
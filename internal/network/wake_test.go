@@ -0,0 +1,112 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWake(t *testing.T) {
+	t.Run("adds the wake handler only when enabled", func(t *testing.T) {
+		router := NewRouter(8080, "localhost")
+		require.NoError(t, router.AddRoute("plain", "127.0.0.1", 3000, store.RouteConfig{}))
+
+		router.SetWakeHandler(func(ctx context.Context, name string, req WakeRequest) error { return nil })
+		require.NoError(t, router.AddRoute("lazy", "127.0.0.1", 3001, store.RouteConfig{}))
+
+		for _, route := range puckServerConfig(router)["routes"].([]map[string]interface{}) {
+			match, ok := route["match"].([]map[string]interface{})
+			if !ok {
+				continue // landing page
+			}
+			handlers := route["handle"].([]map[string]interface{})
+			switch match[0]["path"].([]string)[0] {
+			case "/plain":
+				assert.Equal(t, "rewrite", handlers[1]["handler"])
+			case "/lazy":
+				assert.Equal(t, "puck_wake", handlers[1]["handler"])
+				assert.Equal(t, "lazy", handlers[1]["puck"])
+			}
+		}
+	})
+
+	t.Run("wakes a sleeping puck once", func(t *testing.T) {
+		router := NewRouter(8080, "localhost")
+		var mu sync.Mutex
+		wakes := 0
+		router.SetWakeHandler(func(ctx context.Context, name string, req WakeRequest) error {
+			mu.Lock()
+			defer mu.Unlock()
+			wakes++
+			return nil
+		})
+		require.NoError(t, router.AddRoute("web", "127.0.0.1", 3000, store.RouteConfig{}))
+		require.NoError(t, router.Sleep("web"))
+		assert.True(t, router.Asleep("web"))
+
+		var wg sync.WaitGroup
+		for range 5 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, router.touch(context.Background(), "web", WakeRequest{}))
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, 1, wakes)
+		assert.False(t, router.Asleep("web"))
+		assert.Contains(t, router.LastAccess(), "web")
+	})
+
+	t.Run("stays asleep when waking fails", func(t *testing.T) {
+		router := NewRouter(8080, "localhost")
+		router.SetWakeHandler(func(ctx context.Context, name string, req WakeRequest) error {
+			return errors.New("restore failed")
+		})
+		require.NoError(t, router.AddRoute("web", "127.0.0.1", 3000, store.RouteConfig{}))
+		require.NoError(t, router.Sleep("web"))
+
+		assert.ErrorContains(t, router.touch(context.Background(), "web", WakeRequest{}), "restore failed")
+		assert.True(t, router.Asleep("web"))
+	})
+
+	t.Run("passes on the request that woke it", func(t *testing.T) {
+		router := NewRouter(8080, "localhost")
+		var woke []WakeRequest
+		router.SetWakeHandler(func(ctx context.Context, name string, req WakeRequest) error {
+			woke = append(woke, req)
+			return nil
+		})
+		require.NoError(t, router.AddRoute("web", "127.0.0.1", 3000, store.RouteConfig{}))
+
+		require.NoError(t, router.touch(context.Background(), "web", WakeRequest{ID: "first"}))
+		require.NoError(t, router.Sleep("web"))
+		require.NoError(t, router.touch(context.Background(), "web", WakeRequest{ID: "second"}))
+		require.NoError(t, router.touch(context.Background(), "web", WakeRequest{ID: "third"}))
+		assert.Equal(t, []WakeRequest{{ID: "second"}}, woke)
+	})
+
+	t.Run("requires a route with wake enabled", func(t *testing.T) {
+		router := NewRouter(8080, "localhost")
+		assert.Error(t, router.Sleep("missing"))
+
+		require.NoError(t, router.AddRoute("web", "127.0.0.1", 3000, store.RouteConfig{}))
+		assert.Error(t, router.Sleep("web"))
+	})
+
+	t.Run("re-adding a route wakes it", func(t *testing.T) {
+		router := NewRouter(8080, "localhost")
+		router.SetWakeHandler(func(ctx context.Context, name string, req WakeRequest) error { return nil })
+		require.NoError(t, router.AddRoute("web", "127.0.0.1", 3000, store.RouteConfig{}))
+		require.NoError(t, router.Sleep("web"))
+
+		require.NoError(t, router.AddRoute("web", "127.0.0.1", 3000, store.RouteConfig{}))
+		assert.False(t, router.Asleep("web"))
+	})
+}
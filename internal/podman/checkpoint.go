@@ -2,21 +2,54 @@ package podman
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/containers/podman/v5/pkg/bindings/containers"
 )
 
+// ErrCRIUUnsupported is a checkpoint or restore failing because the host
+// can't do them: CRIU is missing or too old, or the runtime lacks support
+var ErrCRIUUnsupported = errors.New("checkpoint/restore is not supported on this host")
+
+// criuMessages are how libpod says the host can't checkpoint or restore
+var criuMessages = []string{
+	"failed to check for criu version",
+	"checkpoint/restore requires at least CRIU",
+	"does not support checkpoint/restore",
+	"CheckForCriu not supported",
+}
+
+// criuError marks err with ErrCRIUUnsupported when it says the host
+// can't checkpoint or restore
+func criuError(err error) error {
+	for _, msg := range criuMessages {
+		if strings.Contains(err.Error(), msg) {
+			return fmt.Errorf("%w: %w", ErrCRIUUnsupported, err)
+		}
+	}
+	return err
+}
+
 // CheckpointOptions contains options for checkpointing a container
 type CheckpointOptions struct {
-	ExportPath   string // Path to export checkpoint archive
-	LeaveRunning bool   // Keep container running after checkpoint
+	ExportPath     string // Path to export checkpoint archive
+	LeaveRunning   bool   // Keep container running after checkpoint
+	TCPEstablished bool   // Checkpoint established TCP connections
+	FileLocks      bool   // Checkpoint file locks
 }
 
 // RestoreOptions contains options for restoring a container
 type RestoreOptions struct {
 	ImportPath string // Path to checkpoint archive
 	Name       string // New container name (optional)
+	// Port mappings replacing the checkpoint's, in "8080:80" format
+	// (optional)
+	PublishPorts []string
+	// Must match the options the checkpoint was taken with
+	TCPEstablished bool
+	FileLocks      bool
 }
 
 // Checkpoint creates a CRIU checkpoint of a running container
@@ -30,12 +63,16 @@ func (c *Client) Checkpoint(ctx context.Context, nameOrID string, opts Checkpoin
 		checkpointOpts = checkpointOpts.WithLeaveRunning(true)
 	}
 
-	// Enable TCP connection checkpoint (for network sockets)
-	checkpointOpts = checkpointOpts.WithTCPEstablished(true)
+	if opts.TCPEstablished {
+		checkpointOpts = checkpointOpts.WithTCPEstablished(true)
+	}
+	if opts.FileLocks {
+		checkpointOpts = checkpointOpts.WithFileLocks(true)
+	}
 
-	_, err := containers.Checkpoint(c.conn, nameOrID, checkpointOpts)
+	_, err := containers.Checkpoint(c.with(ctx), nameOrID, checkpointOpts)
 	if err != nil {
-		return fmt.Errorf("checkpointing container: %w", err)
+		return fmt.Errorf("checkpointing container: %w", criuError(err))
 	}
 
 	return nil
@@ -51,13 +88,20 @@ func (c *Client) Restore(ctx context.Context, opts RestoreOptions) (string, erro
 	if opts.Name != "" {
 		restoreOpts = restoreOpts.WithName(opts.Name)
 	}
+	if len(opts.PublishPorts) > 0 {
+		restoreOpts = restoreOpts.WithPublishPorts(opts.PublishPorts)
+	}
 
-	// Enable TCP connection restore
-	restoreOpts = restoreOpts.WithTCPEstablished(true)
+	if opts.TCPEstablished {
+		restoreOpts = restoreOpts.WithTCPEstablished(true)
+	}
+	if opts.FileLocks {
+		restoreOpts = restoreOpts.WithFileLocks(true)
+	}
 
-	response, err := containers.Restore(c.conn, "", restoreOpts)
+	response, err := containers.Restore(c.with(ctx), "", restoreOpts)
 	if err != nil {
-		return "", fmt.Errorf("restoring container: %w", err)
+		return "", fmt.Errorf("restoring container: %w", criuError(err))
 	}
 
 	return response.Id, nil
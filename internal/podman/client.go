@@ -2,11 +2,14 @@ package podman
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"runtime"
+	"time"
 
 	"github.com/containers/podman/v5/pkg/bindings"
+	"github.com/containers/podman/v5/pkg/bindings/system"
 )
 
 // Client wraps the Podman connection
@@ -33,6 +36,52 @@ func (c *Client) Context() context.Context {
 	return c.conn
 }
 
+// with returns a context for one bindings call that carries the Podman
+// connection and is canceled along with ctx, so abandoned requests don't
+// leave calls running
+func (c *Client) with(ctx context.Context) context.Context {
+	return connContext{Context: ctx, conn: c.conn}
+}
+
+// minPullTimeout is how long an image pull may take even when the request
+// it runs for has less time left; large images routinely outlast short
+// action deadlines
+const minPullTimeout = 10 * time.Minute
+
+// withPull is with for image pulls. The pull runs for at least
+// minPullTimeout, but still stops as soon as ctx is canceled for any
+// reason other than its deadline, such as the client hanging up.
+func (c *Client) withPull(ctx context.Context) (context.Context, context.CancelFunc) {
+	if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) >= minPullTimeout {
+		return c.with(ctx), func() {}
+	}
+
+	pullCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), minPullTimeout)
+	stop := context.AfterFunc(ctx, func() {
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			cancel()
+		}
+	})
+	return c.with(pullCtx), func() {
+		stop()
+		cancel()
+	}
+}
+
+// connContext looks values up in the call's context first, then in the
+// connection's
+type connContext struct {
+	context.Context
+	conn context.Context
+}
+
+func (c connContext) Value(key any) any {
+	if v := c.Context.Value(key); v != nil {
+		return v
+	}
+	return c.conn.Value(key)
+}
+
 // IsMachine returns true if running on Mac/Windows (using Podman Machine)
 func (c *Client) IsMachine() bool {
 	return runtime.GOOS != "linux"
@@ -81,3 +130,28 @@ func (c *Client) Ping(ctx context.Context) error {
 	_, err := bindings.GetClient(c.conn)
 	return err
 }
+
+// HostInfo describes the host containers run on, which for Podman Machine
+// is the VM rather than this machine
+type HostInfo struct {
+	PodmanVersion string `json:"podman_version"`
+	Kernel        string `json:"kernel"`
+	Arch          string `json:"arch"`
+	Rootless      bool   `json:"rootless"`
+}
+
+// HostInfo reports the Podman version and kernel containers run under
+func (c *Client) HostInfo(ctx context.Context) (*HostInfo, error) {
+	info, err := system.Info(c.with(ctx), nil)
+	if err != nil {
+		return nil, fmt.Errorf("getting podman info: %w", err)
+	}
+
+	host := &HostInfo{PodmanVersion: info.Version.Version}
+	if info.Host != nil {
+		host.Kernel = info.Host.Kernel
+		host.Arch = info.Host.Arch
+		host.Rootless = info.Host.Security.Rootless
+	}
+	return host, nil
+}
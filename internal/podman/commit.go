@@ -0,0 +1,42 @@
+package podman
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/containers/podman/v5/pkg/bindings/containers"
+	"github.com/containers/podman/v5/pkg/bindings/images"
+)
+
+// CommitOptions contains options for committing a container to an image
+type CommitOptions struct {
+	Repo  string // Repository for the new image, e.g. localhost/puck-snapshots
+	Tag   string // Tag for the new image
+	Pause bool   // Pause the container while committing
+}
+
+// CommitContainer saves a container's filesystem as a new image and
+// returns the image ID
+func (c *Client) CommitContainer(ctx context.Context, nameOrID string, opts CommitOptions) (string, error) {
+	commitOpts := new(containers.CommitOptions).
+		WithRepo(opts.Repo).
+		WithTag(opts.Tag).
+		WithPause(opts.Pause)
+
+	response, err := containers.Commit(c.with(ctx), nameOrID, commitOpts)
+	if err != nil {
+		return "", fmt.Errorf("committing container: %w", err)
+	}
+
+	return response.ID, nil
+}
+
+// RemoveImage removes an image, ignoring images that no longer exist
+func (c *Client) RemoveImage(ctx context.Context, nameOrID string) error {
+	opts := new(images.RemoveOptions).WithIgnore(true)
+	if _, errs := images.Remove(c.with(ctx), []string{nameOrID}, opts); len(errs) > 0 {
+		return fmt.Errorf("removing image %s: %w", nameOrID, errors.Join(errs...))
+	}
+	return nil
+}
@@ -2,28 +2,121 @@ package podman
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"strconv"
 	"strings"
+	"syscall"
 
 	nettypes "github.com/containers/common/libnetwork/types"
+	"github.com/containers/common/pkg/signal"
 	"github.com/containers/podman/v5/libpod/define"
 	"github.com/containers/podman/v5/pkg/bindings/containers"
 	"github.com/containers/podman/v5/pkg/bindings/images"
+	"github.com/containers/podman/v5/pkg/domain/entities/types"
 	"github.com/containers/podman/v5/pkg/specgen"
+	"github.com/docker/go-units"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 )
 
 // CreateContainerOptions contains options for creating a container
 type CreateContainerOptions struct {
-	Name    string
-	Image   string
-	Volumes map[string]string // host:container
-	Ports   []string          // "8080:80" format
-	Labels  map[string]string
-	Systemd bool
+	Name       string
+	Image      string
+	Volumes    map[string]string // host:container
+	Mounts     []Mount           // additional bind mounts
+	Ports      []string          // "8080:80" format
+	PublishAll bool              // publish the image's exposed ports on ports podman picks
+	Labels     map[string]string
+	Systemd    bool
+	Init       bool     // run a minimal init (catatonit) as PID 1
+	Entrypoint []string // overrides the image's entrypoint when set
+	Command    []string // overrides the image's command when set
+	Hostname   string
+	DNS        []string // nameserver IPs
+	AddHosts   []string // /etc/hosts entries as host:ip
+	Sysctls    map[string]string
+	Ulimits    []string // name=soft[:hard], e.g. nofile=65536
+	Env        map[string]string
+	UserNS     UserNS
+	Seccomp    string // "unconfined" or a profile path; empty uses the default
+	AppArmor   string // "unconfined" or a profile name; empty uses the default
+	// Hardening for untrusted workloads: no setuid escalation, a read-only
+	// root filesystem with tmpfs /tmp and /run, dropped capabilities, no
+	// network namespace beyond loopback, and a cap on processes
+	NoNewPrivileges bool
+	ReadOnlyRoot    bool
+	CapDrop         []string
+	NoNetwork       bool
+	PidsLimit       int64
+	// Seconds podman stop waits before killing the container; nil uses
+	// podman's default
+	StopTimeout *uint
+	Resources   Resources
+}
+
+// Mount is a host directory bind-mounted into a container
+type Mount struct {
+	Source      string
+	Destination string
+	ReadOnly    bool
 }
 
+// Resources are CPU and memory limits for a container; zero means unlimited
+type Resources struct {
+	Memory int64   // bytes
+	CPUs   float64 // number of CPUs, may be fractional
+}
+
+// cpuPeriod is the CFS period CPU quotas are expressed against
+const cpuPeriod = 100000
+
+// linux converts the limits to an OCI resources block. With clear set,
+// zero limits are sent as -1 so an update removes them rather than leaving
+// them untouched.
+func (r Resources) linux(clear bool) *specs.LinuxResources {
+	var out specs.LinuxResources
+	unlimited := int64(-1)
+
+	if r.Memory > 0 {
+		out.Memory = &specs.LinuxMemory{Limit: &r.Memory}
+	} else if clear {
+		out.Memory = &specs.LinuxMemory{Limit: &unlimited}
+	}
+
+	period := uint64(cpuPeriod)
+	if r.CPUs > 0 {
+		quota := int64(r.CPUs * cpuPeriod)
+		out.CPU = &specs.LinuxCPU{Quota: &quota, Period: &period}
+	} else if clear {
+		out.CPU = &specs.LinuxCPU{Quota: &unlimited, Period: &period}
+	}
+
+	if out.Memory == nil && out.CPU == nil {
+		return nil
+	}
+	return &out
+}
+
+// ParseUlimit reads a limit in podman's --ulimit form, name=soft[:hard]
+func ParseUlimit(s string) (specs.POSIXRlimit, error) {
+	u, err := units.ParseUlimit(s)
+	if err != nil {
+		return specs.POSIXRlimit{}, fmt.Errorf("invalid ulimit %q: %w", s, err)
+	}
+	return specs.POSIXRlimit{
+		Type: "RLIMIT_" + strings.ToUpper(u.Name),
+		Soft: uint64(u.Soft),
+		Hard: uint64(u.Hard),
+	}, nil
+}
+
+// ErrNameInUse is a create failing because a container, puck or not,
+// already has the name
+var ErrNameInUse = errors.New("container name is already in use")
+
 // CreateContainer creates a new container
 func (c *Client) CreateContainer(ctx context.Context, opts CreateContainerOptions) (string, error) {
 	// Ensure image is available
@@ -42,6 +135,35 @@ func (c *Client) CreateContainer(ctx context.Context, opts CreateContainerOption
 	if opts.Systemd {
 		spec.Systemd = "always"
 	}
+	if opts.Init {
+		spec.Init = &opts.Init
+	}
+	if opts.Entrypoint != nil {
+		spec.Entrypoint = opts.Entrypoint
+	}
+	if opts.Command != nil {
+		spec.Command = opts.Command
+	}
+
+	spec.Hostname = opts.Hostname
+	for _, server := range opts.DNS {
+		ip := net.ParseIP(server)
+		if ip == nil {
+			return "", fmt.Errorf("invalid DNS server %q", server)
+		}
+		spec.DNSServers = append(spec.DNSServers, ip)
+	}
+	spec.HostAdd = opts.AddHosts
+
+	spec.Sysctl = opts.Sysctls
+	spec.Env = opts.Env
+	for _, u := range opts.Ulimits {
+		rlimit, err := ParseUlimit(u)
+		if err != nil {
+			return "", err
+		}
+		spec.Rlimits = append(spec.Rlimits, rlimit)
+	}
 
 	// Add puck management labels
 	spec.Labels = map[string]string{
@@ -61,19 +183,67 @@ func (c *Client) CreateContainer(ctx context.Context, opts CreateContainerOption
 			Options:     []string{"rw"},
 		})
 	}
+	for _, m := range opts.Mounts {
+		mode := "rw"
+		if m.ReadOnly {
+			mode = "ro"
+		}
+		spec.Mounts = append(spec.Mounts, specs.Mount{
+			Type:        "bind",
+			Source:      m.Source,
+			Destination: m.Destination,
+			Options:     []string{mode},
+		})
+	}
 
 	// Configure port mappings
 	for _, portSpec := range opts.Ports {
-		pm, err := parsePortMapping(portSpec)
+		pm, err := ParsePortMapping(portSpec)
 		if err != nil {
 			continue // Skip invalid port specs
 		}
 		spec.PortMappings = append(spec.PortMappings, pm)
 	}
 
+	if opts.PublishAll {
+		spec.PublishExposedPorts = &opts.PublishAll
+	}
+
+	spec.ResourceLimits = opts.Resources.linux(false)
+
+	if err := opts.UserNS.apply(spec); err != nil {
+		return "", err
+	}
+	spec.SeccompProfilePath = opts.Seccomp
+	if opts.StopTimeout != nil {
+		spec.StopTimeout = opts.StopTimeout
+	}
+	spec.ApparmorProfile = opts.AppArmor
+
+	if opts.NoNewPrivileges {
+		spec.NoNewPrivileges = &opts.NoNewPrivileges
+	}
+	if opts.ReadOnlyRoot {
+		spec.ReadOnlyFilesystem = &opts.ReadOnlyRoot
+		spec.ReadWriteTmpfs = &opts.ReadOnlyRoot
+	}
+	spec.CapDrop = opts.CapDrop
+	if opts.NoNetwork {
+		spec.NetNS = specgen.Namespace{NSMode: specgen.NoNetwork}
+	}
+	if opts.PidsLimit > 0 {
+		if spec.ResourceLimits == nil {
+			spec.ResourceLimits = &specs.LinuxResources{}
+		}
+		spec.ResourceLimits.Pids = &specs.LinuxPids{Limit: opts.PidsLimit}
+	}
+
 	// Create the container
-	response, err := containers.CreateWithSpec(c.conn, spec, nil)
+	response, err := containers.CreateWithSpec(c.with(ctx), spec, nil)
 	if err != nil {
+		if strings.Contains(err.Error(), "already in use") {
+			return "", fmt.Errorf("creating container: %w: %w", ErrNameInUse, err)
+		}
 		return "", fmt.Errorf("creating container: %w", err)
 	}
 
@@ -83,7 +253,7 @@ func (c *Client) CreateContainer(ctx context.Context, opts CreateContainerOption
 // ensureImage pulls the image if not present locally
 func (c *Client) ensureImage(ctx context.Context, imageName string) error {
 	// Check if image exists
-	exists, err := images.Exists(c.conn, imageName, nil)
+	exists, err := images.Exists(c.with(ctx), imageName, nil)
 	if err != nil {
 		return err
 	}
@@ -92,37 +262,93 @@ func (c *Client) ensureImage(ctx context.Context, imageName string) error {
 		return nil
 	}
 
-	// Pull the image
-	_, err = images.Pull(c.conn, imageName, nil)
-	if err != nil {
-		return fmt.Errorf("pulling image %s: %w", imageName, err)
+	return c.PullImage(ctx, imageName)
+}
+
+// PullImage pulls the latest version of an image, even if present locally,
+// reporting progress to the context's PullProgressFunc if it has one
+func (c *Client) PullImage(ctx context.Context, imageName string) error {
+	pullCtx, cancel := c.withPull(ctx)
+	defer cancel()
+
+	var opts *images.PullOptions
+	var progress *progressWriter
+	if fn := PullProgressFunc(ctx); fn != nil {
+		progress = newProgressWriter(imageName, fn)
+		var w io.Writer = progress
+		opts = new(images.PullOptions).WithProgressWriter(w)
 	}
 
+	if _, err := images.Pull(pullCtx, imageName, opts); err != nil {
+		return fmt.Errorf("pulling image %s: %w", imageName, err)
+	}
+	if progress != nil {
+		progress.done()
+	}
 	return nil
 }
 
 // StartContainer starts a container
 func (c *Client) StartContainer(ctx context.Context, nameOrID string) error {
-	return containers.Start(c.conn, nameOrID, nil)
+	return containers.Start(c.with(ctx), nameOrID, nil)
 }
 
-// StopContainer stops a container
-func (c *Client) StopContainer(ctx context.Context, nameOrID string) error {
-	timeout := uint(10)
+// StopContainer stops a container, killing it if it hasn't exited after
+// timeout seconds
+func (c *Client) StopContainer(ctx context.Context, nameOrID string, timeout uint) error {
 	opts := new(containers.StopOptions).WithTimeout(timeout)
-	return containers.Stop(c.conn, nameOrID, opts)
+	return containers.Stop(c.with(ctx), nameOrID, opts)
+}
+
+// ParseSignal reads a signal by name, with or without the SIG prefix, or
+// by number
+func ParseSignal(s string) (syscall.Signal, error) {
+	sig, err := signal.ParseSignalNameOrNumber(s)
+	if err != nil || sig < 1 || sig > 64 {
+		return 0, fmt.Errorf("invalid signal %q; use a name like SIGHUP or a number", s)
+	}
+	return sig, nil
+}
+
+// KillContainer sends a signal, such as SIGKILL or HUP, to a container
+func (c *Client) KillContainer(ctx context.Context, nameOrID, signal string) error {
+	opts := new(containers.KillOptions).WithSignal(signal)
+	if err := containers.Kill(c.with(ctx), nameOrID, opts); err != nil {
+		return fmt.Errorf("sending %s: %w", signal, err)
+	}
+	return nil
+}
+
+// UpdateResources changes the CPU and memory limits of an existing
+// container in place. This needs cgroup v2 for rootless containers.
+func (c *Client) UpdateResources(ctx context.Context, nameOrID string, res Resources) error {
+	spec := specgen.NewSpecGenerator("", false)
+	spec.ResourceLimits = res.linux(true)
+
+	if _, err := containers.Update(c.with(ctx), &types.ContainerUpdateOptions{NameOrID: nameOrID, Specgen: spec}); err != nil {
+		return fmt.Errorf("updating container resources: %w", err)
+	}
+	return nil
 }
 
 // RemoveContainer removes a container
 func (c *Client) RemoveContainer(ctx context.Context, nameOrID string, force bool) error {
 	opts := new(containers.RemoveOptions).WithForce(force).WithVolumes(true)
-	_, err := containers.Remove(c.conn, nameOrID, opts)
+	_, err := containers.Remove(c.with(ctx), nameOrID, opts)
 	return err
 }
 
+// RenameContainer gives a container a new name
+func (c *Client) RenameContainer(ctx context.Context, nameOrID, name string) error {
+	if err := containers.Rename(c.with(ctx), nameOrID, new(containers.RenameOptions).WithName(name)); err != nil {
+		return fmt.Errorf("renaming container: %w", err)
+	}
+	return nil
+}
+
 // InspectContainer returns container details
 func (c *Client) InspectContainer(ctx context.Context, nameOrID string) (*define.InspectContainerData, error) {
-	data, err := containers.Inspect(c.conn, nameOrID, nil)
+	data, err := containers.Inspect(c.with(ctx), nameOrID, nil)
 	if err != nil {
 		return nil, fmt.Errorf("inspecting container: %w", err)
 	}
@@ -157,30 +383,164 @@ func (c *Client) IsRunning(ctx context.Context, nameOrID string) (bool, error) {
 
 // ContainerExists checks if a container exists
 func (c *Client) ContainerExists(ctx context.Context, nameOrID string) (bool, error) {
-	exists, err := containers.Exists(c.conn, nameOrID, nil)
+	exists, err := containers.Exists(c.with(ctx), nameOrID, nil)
 	return exists, err
 }
 
-// parsePortMapping parses a port spec like "8080:80" into a nettypes.PortMapping
-func parsePortMapping(portSpec string) (nettypes.PortMapping, error) {
-	parts := strings.Split(portSpec, ":")
-	if len(parts) != 2 {
-		return nettypes.PortMapping{}, fmt.Errorf("invalid port spec: %s", portSpec)
+// Container summarizes a container
+type Container struct {
+	ID      string
+	Name    string
+	Labels  map[string]string
+	Running bool
+}
+
+// ListContainers lists the containers, running or not, that have label,
+// given as key=value
+func (c *Client) ListContainers(ctx context.Context, label string) ([]Container, error) {
+	opts := new(containers.ListOptions).WithAll(true).WithFilters(map[string][]string{"label": {label}})
+	list, err := containers.List(c.with(ctx), opts)
+	if err != nil {
+		return nil, fmt.Errorf("listing containers: %w", err)
+	}
+
+	result := make([]Container, 0, len(list))
+	for _, ctr := range list {
+		var name string
+		if len(ctr.Names) > 0 {
+			name = ctr.Names[0]
+		}
+		result = append(result, Container{ID: ctr.ID, Name: name, Labels: ctr.Labels, Running: ctr.State == "running"})
+	}
+	return result, nil
+}
+
+// Logs returns the last tail lines a container wrote to stdout and
+// stderr, interleaved as written; tail <= 0 returns everything
+func (c *Client) Logs(ctx context.Context, nameOrID string, tail int) (string, error) {
+	opts := new(containers.LogOptions).WithStdout(true).WithStderr(true)
+	if tail > 0 {
+		opts = opts.WithTail(strconv.Itoa(tail))
 	}
 
-	hostPort, err := strconv.ParseUint(parts[0], 10, 16)
+	lines := make(chan string)
+	done := make(chan struct{})
+	var b strings.Builder
+	go func() {
+		for line := range lines {
+			b.WriteString(line)
+		}
+		close(done)
+	}()
+	err := containers.Logs(c.with(ctx), nameOrID, opts, lines, lines)
+	close(lines)
+	<-done
+	if err != nil {
+		return "", fmt.Errorf("reading container logs: %w", err)
+	}
+	return b.String(), nil
+}
+
+// Stats is a container's resource usage when it was read
+type Stats struct {
+	CPU    float64 // percent of one CPU, averaged since the container started
+	Memory int64   // bytes
+}
+
+// ContainerStats reads the resource usage of several containers in one
+// call, keyed by container ID
+func (c *Client) ContainerStats(ctx context.Context, ids []string) (map[string]Stats, error) {
+	stats := make(map[string]Stats, len(ids))
+	if len(ids) == 0 {
+		return stats, nil
+	}
+	reports, err := containers.Stats(c.with(ctx), ids, new(containers.StatsOptions).WithStream(false))
 	if err != nil {
-		return nettypes.PortMapping{}, fmt.Errorf("invalid host port: %s", parts[0])
+		return nil, fmt.Errorf("reading container stats: %w", err)
+	}
+	for report := range reports {
+		if report.Error != nil {
+			return nil, fmt.Errorf("reading container stats: %w", report.Error)
+		}
+		for _, s := range report.Stats {
+			stats[s.ContainerID] = Stats{CPU: s.CPU, Memory: int64(s.MemUsage)}
+		}
+	}
+	return stats, nil
+}
+
+// ParsePortMapping parses a port spec as podman's --publish takes it:
+// [[ip:]hostPort:]containerPort[/protocol]. Either port may be a range
+// like 7000-7010, in which case both must span the same number of ports.
+// The protocol is tcp, udp or sctp, or a comma-separated mix, and
+// defaults to tcp. Without a host port, podman picks one.
+func ParsePortMapping(portSpec string) (nettypes.PortMapping, error) {
+	spec, protocol := portSpec, "tcp"
+	if i := strings.LastIndex(portSpec, "/"); i >= 0 {
+		spec, protocol = portSpec[:i], portSpec[i+1:]
+		for _, p := range strings.Split(protocol, ",") {
+			if p != "tcp" && p != "udp" && p != "sctp" {
+				return nettypes.PortMapping{}, fmt.Errorf("invalid protocol %q in port spec %s; use tcp, udp or sctp", p, portSpec)
+			}
+		}
 	}
 
-	containerPort, err := strconv.ParseUint(parts[1], 10, 16)
+	var hostIP, host, container string
+	parts := strings.Split(spec, ":")
+	switch len(parts) {
+	case 1:
+		container = parts[0]
+	case 2:
+		host, container = parts[0], parts[1]
+	default:
+		hostIP = strings.Trim(strings.Join(parts[:len(parts)-2], ":"), "[]")
+		host, container = parts[len(parts)-2], parts[len(parts)-1]
+		if net.ParseIP(hostIP) == nil {
+			return nettypes.PortMapping{}, fmt.Errorf("invalid host IP in port spec %s", portSpec)
+		}
+	}
+
+	containerPort, count, err := parsePortRange(container)
 	if err != nil {
-		return nettypes.PortMapping{}, fmt.Errorf("invalid container port: %s", parts[1])
+		return nettypes.PortMapping{}, fmt.Errorf("invalid container port in port spec %s: %w", portSpec, err)
+	}
+	var hostPort uint16
+	if host != "" {
+		var hostCount uint16
+		if hostPort, hostCount, err = parsePortRange(host); err != nil {
+			return nettypes.PortMapping{}, fmt.Errorf("invalid host port in port spec %s: %w", portSpec, err)
+		}
+		if hostCount != count {
+			return nettypes.PortMapping{}, fmt.Errorf("port spec %s maps %d host ports to %d container ports", portSpec, hostCount, count)
+		}
 	}
 
 	return nettypes.PortMapping{
-		HostPort:      uint16(hostPort),
-		ContainerPort: uint16(containerPort),
-		Protocol:      "tcp",
+		HostIP:        hostIP,
+		HostPort:      hostPort,
+		ContainerPort: containerPort,
+		Range:         count,
+		Protocol:      protocol,
 	}, nil
 }
+
+// parsePortRange parses a port or a range of ports like 7000-7010,
+// returning the first port and how many there are
+func parsePortRange(s string) (uint16, uint16, error) {
+	first, last, isRange := strings.Cut(s, "-")
+	start, err := strconv.ParseUint(first, 10, 16)
+	if err != nil || start == 0 {
+		return 0, 0, fmt.Errorf("%q is not a port", first)
+	}
+	if !isRange {
+		return uint16(start), 1, nil
+	}
+	end, err := strconv.ParseUint(last, 10, 16)
+	if err != nil || end == 0 {
+		return 0, 0, fmt.Errorf("%q is not a port", last)
+	}
+	if end < start {
+		return 0, 0, fmt.Errorf("range %s ends before it starts", s)
+	}
+	return uint16(start), uint16(end - start + 1), nil
+}
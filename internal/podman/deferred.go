@@ -0,0 +1,316 @@
+package podman
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/containers/podman/v5/libpod/define"
+)
+
+// ErrUnavailable is returned for calls made while Podman can't be reached
+var ErrUnavailable = errors.New("podman unavailable")
+
+// DeferredClient stands in for a Client until Podman can be reached, such
+// as when the daemon starts at login before the Podman socket is up.
+// Calls fail with ErrUnavailable until Connect succeeds. Machine commands
+// run the podman CLI, so they work without the service.
+type DeferredClient struct {
+	socket string
+
+	mu     sync.RWMutex
+	client *Client
+	err    error // why the last Connect failed
+}
+
+// Verify that DeferredClient implements ContainerClient interface.
+var _ ContainerClient = (*DeferredClient)(nil)
+
+// NewDeferredClient returns a client for the Podman at socketPath that
+// isn't connected yet
+func NewDeferredClient(socketPath string) *DeferredClient {
+	return &DeferredClient{socket: socketPath, err: errors.New("not connected yet")}
+}
+
+// Connect tries to reach Podman, returning why it couldn't. Once it has,
+// further calls do nothing.
+func (d *DeferredClient) Connect(ctx context.Context) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.client != nil {
+		return nil
+	}
+	client, err := NewClient(ctx, d.socket)
+	if err != nil {
+		d.err = err
+		return err
+	}
+	d.client, d.err = client, nil
+	return nil
+}
+
+// Available reports whether Podman has been reached
+func (d *DeferredClient) Available() bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.client != nil
+}
+
+// Err returns why Podman can't be reached, or nil once it has been
+func (d *DeferredClient) Err() error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.client != nil {
+		return nil
+	}
+	return fmt.Errorf("%w: %v", ErrUnavailable, d.err)
+}
+
+// get returns the connected client
+func (d *DeferredClient) get() (*Client, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.client == nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, d.err)
+	}
+	return d.client, nil
+}
+
+func (d *DeferredClient) CreateContainer(ctx context.Context, opts CreateContainerOptions) (string, error) {
+	c, err := d.get()
+	if err != nil {
+		return "", err
+	}
+	return c.CreateContainer(ctx, opts)
+}
+
+func (d *DeferredClient) StartContainer(ctx context.Context, nameOrID string) error {
+	c, err := d.get()
+	if err != nil {
+		return err
+	}
+	return c.StartContainer(ctx, nameOrID)
+}
+
+func (d *DeferredClient) StopContainer(ctx context.Context, nameOrID string, timeout uint) error {
+	c, err := d.get()
+	if err != nil {
+		return err
+	}
+	return c.StopContainer(ctx, nameOrID, timeout)
+}
+
+func (d *DeferredClient) KillContainer(ctx context.Context, nameOrID, signal string) error {
+	c, err := d.get()
+	if err != nil {
+		return err
+	}
+	return c.KillContainer(ctx, nameOrID, signal)
+}
+
+func (d *DeferredClient) RemoveContainer(ctx context.Context, nameOrID string, force bool) error {
+	c, err := d.get()
+	if err != nil {
+		return err
+	}
+	return c.RemoveContainer(ctx, nameOrID, force)
+}
+
+func (d *DeferredClient) RenameContainer(ctx context.Context, nameOrID, name string) error {
+	c, err := d.get()
+	if err != nil {
+		return err
+	}
+	return c.RenameContainer(ctx, nameOrID, name)
+}
+
+func (d *DeferredClient) UpdateResources(ctx context.Context, nameOrID string, res Resources) error {
+	c, err := d.get()
+	if err != nil {
+		return err
+	}
+	return c.UpdateResources(ctx, nameOrID, res)
+}
+
+func (d *DeferredClient) InspectContainer(ctx context.Context, nameOrID string) (*define.InspectContainerData, error) {
+	c, err := d.get()
+	if err != nil {
+		return nil, err
+	}
+	return c.InspectContainer(ctx, nameOrID)
+}
+
+func (d *DeferredClient) GetContainerIP(ctx context.Context, nameOrID string) (string, error) {
+	c, err := d.get()
+	if err != nil {
+		return "", err
+	}
+	return c.GetContainerIP(ctx, nameOrID)
+}
+
+func (d *DeferredClient) IsRunning(ctx context.Context, nameOrID string) (bool, error) {
+	c, err := d.get()
+	if err != nil {
+		return false, err
+	}
+	return c.IsRunning(ctx, nameOrID)
+}
+
+func (d *DeferredClient) ContainerExists(ctx context.Context, nameOrID string) (bool, error) {
+	c, err := d.get()
+	if err != nil {
+		return false, err
+	}
+	return c.ContainerExists(ctx, nameOrID)
+}
+
+func (d *DeferredClient) ListContainers(ctx context.Context, label string) ([]Container, error) {
+	c, err := d.get()
+	if err != nil {
+		return nil, err
+	}
+	return c.ListContainers(ctx, label)
+}
+
+func (d *DeferredClient) Logs(ctx context.Context, nameOrID string, tail int) (string, error) {
+	c, err := d.get()
+	if err != nil {
+		return "", err
+	}
+	return c.Logs(ctx, nameOrID, tail)
+}
+
+func (d *DeferredClient) ContainerStats(ctx context.Context, ids []string) (map[string]Stats, error) {
+	c, err := d.get()
+	if err != nil {
+		return nil, err
+	}
+	return c.ContainerStats(ctx, ids)
+}
+
+func (d *DeferredClient) PullImage(ctx context.Context, imageName string) error {
+	c, err := d.get()
+	if err != nil {
+		return err
+	}
+	return c.PullImage(ctx, imageName)
+}
+
+func (d *DeferredClient) ImageExists(ctx context.Context, nameOrID string) (bool, error) {
+	c, err := d.get()
+	if err != nil {
+		return false, err
+	}
+	return c.ImageExists(ctx, nameOrID)
+}
+
+func (d *DeferredClient) RemoveImage(ctx context.Context, nameOrID string) error {
+	c, err := d.get()
+	if err != nil {
+		return err
+	}
+	return c.RemoveImage(ctx, nameOrID)
+}
+
+func (d *DeferredClient) ListImages(ctx context.Context) ([]Image, error) {
+	c, err := d.get()
+	if err != nil {
+		return nil, err
+	}
+	return c.ListImages(ctx)
+}
+
+func (d *DeferredClient) ImageLayers(ctx context.Context, nameOrID string) (string, []Layer, error) {
+	c, err := d.get()
+	if err != nil {
+		return "", nil, err
+	}
+	return c.ImageLayers(ctx, nameOrID)
+}
+
+func (d *DeferredClient) PruneImages(ctx context.Context, buildCache bool) (*PruneReport, error) {
+	c, err := d.get()
+	if err != nil {
+		return nil, err
+	}
+	return c.PruneImages(ctx, buildCache)
+}
+
+func (d *DeferredClient) Checkpoint(ctx context.Context, nameOrID string, opts CheckpointOptions) error {
+	c, err := d.get()
+	if err != nil {
+		return err
+	}
+	return c.Checkpoint(ctx, nameOrID, opts)
+}
+
+func (d *DeferredClient) Restore(ctx context.Context, opts RestoreOptions) (string, error) {
+	c, err := d.get()
+	if err != nil {
+		return "", err
+	}
+	return c.Restore(ctx, opts)
+}
+
+func (d *DeferredClient) CommitContainer(ctx context.Context, nameOrID string, opts CommitOptions) (string, error) {
+	c, err := d.get()
+	if err != nil {
+		return "", err
+	}
+	return c.CommitContainer(ctx, nameOrID, opts)
+}
+
+func (d *DeferredClient) Console(ctx context.Context, containerID string, shell, user string, env []string) error {
+	c, err := d.get()
+	if err != nil {
+		return err
+	}
+	return c.Console(ctx, containerID, shell, user, env)
+}
+
+func (d *DeferredClient) Exec(ctx context.Context, containerID string, opts ExecOptions) error {
+	c, err := d.get()
+	if err != nil {
+		return err
+	}
+	return c.Exec(ctx, containerID, opts)
+}
+
+func (d *DeferredClient) Ping(ctx context.Context) error {
+	c, err := d.get()
+	if err != nil {
+		return err
+	}
+	return c.Ping(ctx)
+}
+
+func (d *DeferredClient) HostInfo(ctx context.Context) (*HostInfo, error) {
+	c, err := d.get()
+	if err != nil {
+		return nil, err
+	}
+	return c.HostInfo(ctx)
+}
+
+// Context returns the connection context, or a background context
+// before Podman is reached
+func (d *DeferredClient) Context() context.Context {
+	c, err := d.get()
+	if err != nil {
+		return context.Background()
+	}
+	return c.Context()
+}
+
+func (d *DeferredClient) IsMachine() bool {
+	return (&Client{}).IsMachine()
+}
+
+func (d *DeferredClient) Machine(ctx context.Context, name string) (*MachineInfo, error) {
+	return (&Client{}).Machine(ctx, name)
+}
+
+func (d *DeferredClient) SetMachineResources(ctx context.Context, name string, res MachineResources) error {
+	return (&Client{}).SetMachineResources(ctx, name, res)
+}
@@ -2,6 +2,9 @@ package podman
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"syscall"
@@ -17,6 +20,32 @@ type ExecOptions struct {
 	WorkDir     string
 	Env         []string
 	User        string
+	// Detach leaves the command running in the background, returning once
+	// it has started
+	Detach bool
+	// Where stdout and stderr go, with no stdin; nil uses the terminal
+	Output io.Writer
+	// With Output set, where stderr goes instead, and what the command
+	// reads from (needs Interactive)
+	ErrOutput io.Writer
+	Stdin     io.Reader
+}
+
+// ExitError is a command run in a container that exited non-zero. Code is
+// podman exec's status: the command's own, 125 when podman failed, 126
+// when the command couldn't be invoked and 127 when it wasn't found. A
+// command killed by a signal counts as 128 plus the signal, as in a shell.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("command exited with status %d", e.Code)
+}
+
+// ExitCode returns the status, so callers can exit with it
+func (e *ExitError) ExitCode() int {
+	return e.Code
 }
 
 // Exec executes a command in a container using podman CLI
@@ -30,6 +59,9 @@ func (c *Client) Exec(ctx context.Context, containerID string, opts ExecOptions)
 	if opts.TTY {
 		args = append(args, "-t")
 	}
+	if opts.Detach {
+		args = append(args, "-d")
+	}
 	if opts.WorkDir != "" {
 		args = append(args, "-w", opts.WorkDir)
 	}
@@ -44,9 +76,18 @@ func (c *Client) Exec(ctx context.Context, containerID string, opts ExecOptions)
 	args = append(args, opts.Cmd...)
 
 	cmd := exec.CommandContext(ctx, "podman", args...)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	if opts.Output != nil {
+		cmd.Stdin = opts.Stdin
+		cmd.Stdout = opts.Output
+		cmd.Stderr = opts.Output
+		if opts.ErrOutput != nil {
+			cmd.Stderr = opts.ErrOutput
+		}
+	} else {
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+	}
 
 	// Set up TTY if needed and stdin is actually a terminal
 	if opts.TTY && term.IsTerminal(int(os.Stdin.Fd())) {
@@ -56,11 +97,25 @@ func (c *Client) Exec(ctx context.Context, containerID string, opts ExecOptions)
 		}
 	}
 
-	return cmd.Run()
+	return exitError(cmd.Run())
+}
+
+// exitError turns podman exec exiting non-zero into an ExitError
+func exitError(err error) error {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return err
+	}
+	code := exitErr.ExitCode()
+	if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		code = 128 + int(ws.Signal())
+	}
+	return &ExitError{Code: code}
 }
 
-// Console opens an interactive shell in a container
-func (c *Client) Console(ctx context.Context, containerID string, shell string) error {
+// Console opens an interactive shell in a container, as user unless it is
+// empty, with env added to its environment
+func (c *Client) Console(ctx context.Context, containerID string, shell, user string, env []string) error {
 	if shell == "" {
 		shell = "/bin/bash"
 	}
@@ -69,5 +124,7 @@ func (c *Client) Console(ctx context.Context, containerID string, shell string)
 		Cmd:         []string{shell},
 		Interactive: true,
 		TTY:         true,
+		Env:         env,
+		User:        user,
 	})
 }
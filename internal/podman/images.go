@@ -0,0 +1,117 @@
+package podman
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/containers/podman/v5/pkg/bindings/images"
+)
+
+// Image summarizes an image in local storage
+type Image struct {
+	ID         string
+	Names      []string // repo:tag references; empty for dangling images
+	Size       int64
+	Created    time.Time
+	Dangling   bool
+	Containers int // containers using the image, running or not
+}
+
+// Layer is one filesystem layer of an image
+type Layer struct {
+	Digest string // of the uncompressed layer, so images built on the same base share it
+	Size   int64  // bytes, uncompressed; 0 if podman doesn't know it
+}
+
+// PruneReport describes images removed by a prune
+type PruneReport struct {
+	IDs       []string
+	Reclaimed uint64 // bytes
+}
+
+// ListImages lists the images in local storage, excluding intermediate
+// build layers
+func (c *Client) ListImages(ctx context.Context) ([]Image, error) {
+	summaries, err := images.List(c.with(ctx), new(images.ListOptions).WithAll(false))
+	if err != nil {
+		return nil, fmt.Errorf("listing images: %w", err)
+	}
+
+	list := make([]Image, 0, len(summaries))
+	for _, s := range summaries {
+		list = append(list, Image{
+			ID:         s.ID,
+			Names:      s.RepoTags,
+			Size:       s.Size,
+			Created:    time.Unix(s.Created, 0),
+			Dangling:   s.Dangling,
+			Containers: s.Containers,
+		})
+	}
+	return list, nil
+}
+
+// ImageExists reports whether an image is in local storage. nameOrID may
+// be a digest reference such as fedora@sha256:...
+func (c *Client) ImageExists(ctx context.Context, nameOrID string) (bool, error) {
+	return images.Exists(c.with(ctx), nameOrID, nil)
+}
+
+// ImageLayers returns an image's ID and its layers, base first
+func (c *Client) ImageLayers(ctx context.Context, nameOrID string) (string, []Layer, error) {
+	report, err := images.GetImage(c.with(ctx), nameOrID, nil)
+	if err != nil {
+		return "", nil, fmt.Errorf("inspecting image %s: %w", nameOrID, err)
+	}
+	history, err := images.History(c.with(ctx), nameOrID, nil)
+	if err != nil {
+		return "", nil, fmt.Errorf("reading history of image %s: %w", nameOrID, err)
+	}
+	if report.RootFS == nil {
+		return report.ID, nil, nil
+	}
+
+	// History is newest first, one entry per step of the image's config
+	// history. The steps that made a layer are, oldest first, the layers
+	// of RootFS; the others are empty.
+	var sizes []int64
+	if len(history) == len(report.History) {
+		for i, step := range report.History {
+			if !step.EmptyLayer {
+				sizes = append(sizes, history[len(history)-1-i].Size)
+			}
+		}
+	}
+
+	layers := make([]Layer, len(report.RootFS.Layers))
+	for i, digest := range report.RootFS.Layers {
+		layers[i].Digest = digest.String()
+		if len(sizes) == len(layers) {
+			layers[i].Size = sizes[i]
+		}
+	}
+	return report.ID, layers, nil
+}
+
+// PruneImages removes dangling images no container uses, and with
+// buildCache the persistent build cache as well
+func (c *Client) PruneImages(ctx context.Context, buildCache bool) (*PruneReport, error) {
+	reports, err := images.Prune(c.with(ctx), new(images.PruneOptions).WithBuildCache(buildCache))
+	if err != nil {
+		return nil, fmt.Errorf("pruning images: %w", err)
+	}
+
+	report := &PruneReport{}
+	var errs []error
+	for _, r := range reports {
+		if r.Err != nil {
+			errs = append(errs, r.Err)
+			continue
+		}
+		report.IDs = append(report.IDs, r.Id)
+		report.Reclaimed += r.Size
+	}
+	return report, errors.Join(errs...)
+}
@@ -14,27 +14,47 @@ type ContainerClient interface {
 	// Container lifecycle
 	CreateContainer(ctx context.Context, opts CreateContainerOptions) (string, error)
 	StartContainer(ctx context.Context, nameOrID string) error
-	StopContainer(ctx context.Context, nameOrID string) error
+	StopContainer(ctx context.Context, nameOrID string, timeout uint) error
+	KillContainer(ctx context.Context, nameOrID, signal string) error
 	RemoveContainer(ctx context.Context, nameOrID string, force bool) error
+	RenameContainer(ctx context.Context, nameOrID, name string) error
+	UpdateResources(ctx context.Context, nameOrID string, res Resources) error
 
 	// Container inspection
 	InspectContainer(ctx context.Context, nameOrID string) (*define.InspectContainerData, error)
 	GetContainerIP(ctx context.Context, nameOrID string) (string, error)
 	IsRunning(ctx context.Context, nameOrID string) (bool, error)
 	ContainerExists(ctx context.Context, nameOrID string) (bool, error)
+	ListContainers(ctx context.Context, label string) ([]Container, error)
+	Logs(ctx context.Context, nameOrID string, tail int) (string, error)
+	ContainerStats(ctx context.Context, ids []string) (map[string]Stats, error)
+
+	// Images
+	PullImage(ctx context.Context, imageName string) error
+	ImageExists(ctx context.Context, nameOrID string) (bool, error)
+	RemoveImage(ctx context.Context, nameOrID string) error
+	ListImages(ctx context.Context) ([]Image, error)
+	ImageLayers(ctx context.Context, nameOrID string) (string, []Layer, error)
+	PruneImages(ctx context.Context, buildCache bool) (*PruneReport, error)
 
 	// Checkpoint/restore (CRIU)
 	Checkpoint(ctx context.Context, nameOrID string, opts CheckpointOptions) error
 	Restore(ctx context.Context, opts RestoreOptions) (string, error)
 
+	// Commit, for snapshots on hosts without CRIU
+	CommitContainer(ctx context.Context, nameOrID string, opts CommitOptions) (string, error)
+
 	// Interactive
-	Console(ctx context.Context, containerID string, shell string) error
+	Console(ctx context.Context, containerID string, shell, user string, env []string) error
 	Exec(ctx context.Context, containerID string, opts ExecOptions) error
 
 	// Utility
 	Ping(ctx context.Context) error
+	HostInfo(ctx context.Context) (*HostInfo, error)
 	Context() context.Context
 	IsMachine() bool
+	Machine(ctx context.Context, name string) (*MachineInfo, error)
+	SetMachineResources(ctx context.Context, name string, res MachineResources) error
 }
 
 // Verify that Client implements ContainerClient interface.
@@ -0,0 +1,157 @@
+package podman
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Machine VM types whose shares are set up differently
+const (
+	VMTypeWSL = "wsl" // Windows drives appear under /mnt without shares
+)
+
+// MachineInfo describes the Podman Machine VM containers run in
+type MachineInfo struct {
+	Name      string           `json:"name"`
+	VMType    string           `json:"vm_type"`
+	State     string           `json:"state"` // e.g. running or stopped
+	Resources MachineResources `json:"resources"`
+	Mounts    []MachineMount   `json:"mounts"`
+}
+
+// MachineResources size a Podman Machine VM; zero leaves a value as it is
+// when changing them
+type MachineResources struct {
+	CPUs     uint64 `json:"cpus,omitempty"`
+	Memory   int64  `json:"memory,omitempty"`    // bytes, in whole MiB
+	DiskSize int64  `json:"disk_size,omitempty"` // bytes, in whole GiB
+}
+
+// MachineRunning is the state of a machine that is up
+const MachineRunning = "running"
+
+// MachineMount is a host directory shared into the VM
+type MachineMount struct {
+	Source   string `json:"source"` // on this machine
+	Target   string `json:"target"` // in the VM
+	Type     string `json:"type"`   // the driver, e.g. virtiofs or 9p
+	ReadOnly bool   `json:"read_only,omitempty"`
+}
+
+// Machine inspects a Podman Machine, the default one when name is empty
+func (c *Client) Machine(ctx context.Context, name string) (*MachineInfo, error) {
+	args := []string{"machine", "inspect"}
+	if name != "" {
+		args = append(args, name)
+	}
+	out, err := exec.CommandContext(ctx, "podman", args...).Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok && len(ee.Stderr) > 0 {
+			return nil, fmt.Errorf("inspecting podman machine: %s", strings.TrimSpace(string(ee.Stderr)))
+		}
+		return nil, fmt.Errorf("inspecting podman machine: %w", err)
+	}
+	return parseMachineInspect(out)
+}
+
+// SetMachineResources changes a machine's CPUs, memory or disk size with
+// podman machine set, which needs the machine stopped
+func (c *Client) SetMachineResources(ctx context.Context, name string, res MachineResources) error {
+	args := []string{"machine", "set"}
+	if res.CPUs > 0 {
+		args = append(args, "--cpus", strconv.FormatUint(res.CPUs, 10))
+	}
+	if res.Memory > 0 {
+		args = append(args, "--memory", strconv.FormatInt(res.Memory>>20, 10))
+	}
+	if res.DiskSize > 0 {
+		args = append(args, "--disk-size", strconv.FormatInt(res.DiskSize>>30, 10))
+	}
+	if name != "" {
+		args = append(args, name)
+	}
+	if out, err := exec.CommandContext(ctx, "podman", args...).CombinedOutput(); err != nil {
+		if len(out) > 0 {
+			return fmt.Errorf("podman machine set: %s", strings.TrimSpace(string(out)))
+		}
+		return fmt.Errorf("podman machine set: %w", err)
+	}
+	return nil
+}
+
+// parseMachineInspect reads the first machine of podman machine inspect
+func parseMachineInspect(out []byte) (*MachineInfo, error) {
+	var machines []struct {
+		Name      string
+		VMType    string
+		State     string
+		Resources struct {
+			CPUs     uint64
+			Memory   int64 // MiB
+			DiskSize int64 // GiB
+		}
+		Mounts []struct {
+			Source   string
+			Target   string
+			Type     string
+			ReadOnly bool
+		}
+	}
+	if err := json.Unmarshal(out, &machines); err != nil {
+		return nil, fmt.Errorf("parsing podman machine inspect: %w", err)
+	}
+	if len(machines) == 0 {
+		return nil, fmt.Errorf("podman machine inspect found no machine")
+	}
+
+	m := machines[0]
+	info := &MachineInfo{
+		Name:   m.Name,
+		VMType: m.VMType,
+		State:  m.State,
+		Resources: MachineResources{
+			CPUs:     m.Resources.CPUs,
+			Memory:   m.Resources.Memory << 20,
+			DiskSize: m.Resources.DiskSize << 30,
+		},
+	}
+	for _, mnt := range m.Mounts {
+		info.Mounts = append(info.Mounts, MachineMount{Source: mnt.Source, Target: mnt.Target, Type: mnt.Type, ReadOnly: mnt.ReadOnly})
+	}
+	return info, nil
+}
+
+// VMPath returns where a host path is found inside the VM, and the share
+// it is under, or false if it isn't shared. The longest matching share
+// wins. WSL machines see Windows drives under /mnt.
+func (mi *MachineInfo) VMPath(hostPath string) (string, *MachineMount, bool) {
+	if mi.VMType == VMTypeWSL {
+		if vol := filepath.VolumeName(hostPath); len(vol) == 2 && vol[1] == ':' {
+			rest := filepath.ToSlash(strings.TrimPrefix(hostPath, vol))
+			return path.Join("/mnt", strings.ToLower(vol[:1]), rest), nil, true
+		}
+	}
+
+	var best *MachineMount
+	var bestRel string
+	for i := range mi.Mounts {
+		mnt := &mi.Mounts[i]
+		rel, err := filepath.Rel(mnt.Source, hostPath)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if best == nil || len(mnt.Source) > len(best.Source) {
+			best, bestRel = mnt, rel
+		}
+	}
+	if best == nil {
+		return "", nil, false
+	}
+	return path.Join(best.Target, filepath.ToSlash(bestRel)), best, true
+}
@@ -2,6 +2,8 @@ package podman
 
 import (
 	"context"
+	"reflect"
+	"sync"
 
 	"github.com/containers/podman/v5/libpod/define"
 )
@@ -11,20 +13,38 @@ type MockClient struct {
 	// Function hooks for each method - set these to customize behavior
 	CreateContainerFunc   func(ctx context.Context, opts CreateContainerOptions) (string, error)
 	StartContainerFunc    func(ctx context.Context, nameOrID string) error
-	StopContainerFunc     func(ctx context.Context, nameOrID string) error
+	StopContainerFunc     func(ctx context.Context, nameOrID string, timeout uint) error
+	KillContainerFunc     func(ctx context.Context, nameOrID, signal string) error
 	RemoveContainerFunc   func(ctx context.Context, nameOrID string, force bool) error
+	RenameContainerFunc   func(ctx context.Context, nameOrID, name string) error
+	UpdateResourcesFunc   func(ctx context.Context, nameOrID string, res Resources) error
 	InspectContainerFunc  func(ctx context.Context, nameOrID string) (*define.InspectContainerData, error)
 	GetContainerIPFunc    func(ctx context.Context, nameOrID string) (string, error)
 	IsRunningFunc         func(ctx context.Context, nameOrID string) (bool, error)
 	ContainerExistsFunc   func(ctx context.Context, nameOrID string) (bool, error)
+	ListContainersFunc    func(ctx context.Context, label string) ([]Container, error)
+	LogsFunc              func(ctx context.Context, nameOrID string, tail int) (string, error)
+	ContainerStatsFunc    func(ctx context.Context, ids []string) (map[string]Stats, error)
+	PullImageFunc         func(ctx context.Context, imageName string) error
+	ImageExistsFunc       func(ctx context.Context, nameOrID string) (bool, error)
+	RemoveImageFunc       func(ctx context.Context, nameOrID string) error
+	ListImagesFunc        func(ctx context.Context) ([]Image, error)
+	ImageLayersFunc       func(ctx context.Context, nameOrID string) (string, []Layer, error)
+	PruneImagesFunc       func(ctx context.Context, buildCache bool) (*PruneReport, error)
 	CheckpointFunc        func(ctx context.Context, nameOrID string, opts CheckpointOptions) error
 	RestoreFunc           func(ctx context.Context, opts RestoreOptions) (string, error)
-	ConsoleFunc           func(ctx context.Context, containerID string, shell string) error
+	CommitContainerFunc   func(ctx context.Context, nameOrID string, opts CommitOptions) (string, error)
+	ConsoleFunc           func(ctx context.Context, containerID string, shell, user string, env []string) error
 	ExecFunc              func(ctx context.Context, containerID string, opts ExecOptions) error
 	PingFunc              func(ctx context.Context) error
+	HostInfoFunc          func(ctx context.Context) (*HostInfo, error)
+	IsMachineFunc         func() bool
+	MachineFunc           func(ctx context.Context, name string) (*MachineInfo, error)
+	SetMachineResourcesFunc func(ctx context.Context, name string, res MachineResources) error
 
 	// Track calls for verification
 	Calls []MockCall
+	mu    sync.Mutex
 }
 
 // MockCall records a method call for verification.
@@ -40,21 +60,40 @@ func NewMockClient() *MockClient {
 		// Default implementations return nil/empty values
 		CreateContainerFunc:  func(ctx context.Context, opts CreateContainerOptions) (string, error) { return "mock-container-id", nil },
 		StartContainerFunc:   func(ctx context.Context, nameOrID string) error { return nil },
-		StopContainerFunc:    func(ctx context.Context, nameOrID string) error { return nil },
+		StopContainerFunc:    func(ctx context.Context, nameOrID string, timeout uint) error { return nil },
+		KillContainerFunc:    func(ctx context.Context, nameOrID, signal string) error { return nil },
 		RemoveContainerFunc:  func(ctx context.Context, nameOrID string, force bool) error { return nil },
+		RenameContainerFunc:  func(ctx context.Context, nameOrID, name string) error { return nil },
+		UpdateResourcesFunc:  func(ctx context.Context, nameOrID string, res Resources) error { return nil },
 		InspectContainerFunc: func(ctx context.Context, nameOrID string) (*define.InspectContainerData, error) { return &define.InspectContainerData{}, nil },
 		GetContainerIPFunc:   func(ctx context.Context, nameOrID string) (string, error) { return "10.88.0.2", nil },
 		IsRunningFunc:        func(ctx context.Context, nameOrID string) (bool, error) { return true, nil },
 		ContainerExistsFunc:  func(ctx context.Context, nameOrID string) (bool, error) { return true, nil },
+		ListContainersFunc:   func(ctx context.Context, label string) ([]Container, error) { return nil, nil },
+		LogsFunc:             func(ctx context.Context, nameOrID string, tail int) (string, error) { return "", nil },
+		ContainerStatsFunc:   func(ctx context.Context, ids []string) (map[string]Stats, error) { return map[string]Stats{}, nil },
+		PullImageFunc:        func(ctx context.Context, imageName string) error { return nil },
+		ImageExistsFunc:      func(ctx context.Context, nameOrID string) (bool, error) { return true, nil },
+		RemoveImageFunc:      func(ctx context.Context, nameOrID string) error { return nil },
+		ListImagesFunc:       func(ctx context.Context) ([]Image, error) { return nil, nil },
+		ImageLayersFunc:      func(ctx context.Context, nameOrID string) (string, []Layer, error) { return "image-id", nil, nil },
+		PruneImagesFunc:      func(ctx context.Context, buildCache bool) (*PruneReport, error) { return &PruneReport{}, nil },
 		CheckpointFunc:       func(ctx context.Context, nameOrID string, opts CheckpointOptions) error { return nil },
 		RestoreFunc:          func(ctx context.Context, opts RestoreOptions) (string, error) { return "restored-container-id", nil },
-		ConsoleFunc:          func(ctx context.Context, containerID string, shell string) error { return nil },
+		CommitContainerFunc:  func(ctx context.Context, nameOrID string, opts CommitOptions) (string, error) { return "committed-image-id", nil },
+		ConsoleFunc:          func(ctx context.Context, containerID string, shell, user string, env []string) error { return nil },
 		ExecFunc:             func(ctx context.Context, containerID string, opts ExecOptions) error { return nil },
 		PingFunc:             func(ctx context.Context) error { return nil },
+		HostInfoFunc:         func(ctx context.Context) (*HostInfo, error) { return &HostInfo{PodmanVersion: "5.3.0", Kernel: "6.8.0", Arch: "amd64"}, nil },
+		IsMachineFunc:        func() bool { return false },
+		MachineFunc:          func(ctx context.Context, name string) (*MachineInfo, error) { return &MachineInfo{Name: "podman-machine-default"}, nil },
+		SetMachineResourcesFunc: func(ctx context.Context, name string, res MachineResources) error { return nil },
 	}
 }
 
 func (m *MockClient) recordCall(method string, args ...interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.Calls = append(m.Calls, MockCall{Method: method, Args: args})
 }
 
@@ -68,9 +107,14 @@ func (m *MockClient) StartContainer(ctx context.Context, nameOrID string) error
 	return m.StartContainerFunc(ctx, nameOrID)
 }
 
-func (m *MockClient) StopContainer(ctx context.Context, nameOrID string) error {
-	m.recordCall("StopContainer", nameOrID)
-	return m.StopContainerFunc(ctx, nameOrID)
+func (m *MockClient) StopContainer(ctx context.Context, nameOrID string, timeout uint) error {
+	m.recordCall("StopContainer", nameOrID, timeout)
+	return m.StopContainerFunc(ctx, nameOrID, timeout)
+}
+
+func (m *MockClient) KillContainer(ctx context.Context, nameOrID, signal string) error {
+	m.recordCall("KillContainer", nameOrID, signal)
+	return m.KillContainerFunc(ctx, nameOrID, signal)
 }
 
 func (m *MockClient) RemoveContainer(ctx context.Context, nameOrID string, force bool) error {
@@ -78,6 +122,16 @@ func (m *MockClient) RemoveContainer(ctx context.Context, nameOrID string, force
 	return m.RemoveContainerFunc(ctx, nameOrID, force)
 }
 
+func (m *MockClient) RenameContainer(ctx context.Context, nameOrID, name string) error {
+	m.recordCall("RenameContainer", nameOrID, name)
+	return m.RenameContainerFunc(ctx, nameOrID, name)
+}
+
+func (m *MockClient) UpdateResources(ctx context.Context, nameOrID string, res Resources) error {
+	m.recordCall("UpdateResources", nameOrID, res)
+	return m.UpdateResourcesFunc(ctx, nameOrID, res)
+}
+
 func (m *MockClient) InspectContainer(ctx context.Context, nameOrID string) (*define.InspectContainerData, error) {
 	m.recordCall("InspectContainer", nameOrID)
 	return m.InspectContainerFunc(ctx, nameOrID)
@@ -98,6 +152,51 @@ func (m *MockClient) ContainerExists(ctx context.Context, nameOrID string) (bool
 	return m.ContainerExistsFunc(ctx, nameOrID)
 }
 
+func (m *MockClient) ListContainers(ctx context.Context, label string) ([]Container, error) {
+	m.recordCall("ListContainers", label)
+	return m.ListContainersFunc(ctx, label)
+}
+
+func (m *MockClient) Logs(ctx context.Context, nameOrID string, tail int) (string, error) {
+	m.recordCall("Logs", nameOrID, tail)
+	return m.LogsFunc(ctx, nameOrID, tail)
+}
+
+func (m *MockClient) ContainerStats(ctx context.Context, ids []string) (map[string]Stats, error) {
+	m.recordCall("ContainerStats", ids)
+	return m.ContainerStatsFunc(ctx, ids)
+}
+
+func (m *MockClient) PullImage(ctx context.Context, imageName string) error {
+	m.recordCall("PullImage", imageName)
+	return m.PullImageFunc(ctx, imageName)
+}
+
+func (m *MockClient) ImageExists(ctx context.Context, nameOrID string) (bool, error) {
+	m.recordCall("ImageExists", nameOrID)
+	return m.ImageExistsFunc(ctx, nameOrID)
+}
+
+func (m *MockClient) RemoveImage(ctx context.Context, nameOrID string) error {
+	m.recordCall("RemoveImage", nameOrID)
+	return m.RemoveImageFunc(ctx, nameOrID)
+}
+
+func (m *MockClient) ListImages(ctx context.Context) ([]Image, error) {
+	m.recordCall("ListImages")
+	return m.ListImagesFunc(ctx)
+}
+
+func (m *MockClient) ImageLayers(ctx context.Context, nameOrID string) (string, []Layer, error) {
+	m.recordCall("ImageLayers", nameOrID)
+	return m.ImageLayersFunc(ctx, nameOrID)
+}
+
+func (m *MockClient) PruneImages(ctx context.Context, buildCache bool) (*PruneReport, error) {
+	m.recordCall("PruneImages", buildCache)
+	return m.PruneImagesFunc(ctx, buildCache)
+}
+
 func (m *MockClient) Checkpoint(ctx context.Context, nameOrID string, opts CheckpointOptions) error {
 	m.recordCall("Checkpoint", nameOrID, opts)
 	return m.CheckpointFunc(ctx, nameOrID, opts)
@@ -108,9 +207,14 @@ func (m *MockClient) Restore(ctx context.Context, opts RestoreOptions) (string,
 	return m.RestoreFunc(ctx, opts)
 }
 
-func (m *MockClient) Console(ctx context.Context, containerID string, shell string) error {
-	m.recordCall("Console", containerID, shell)
-	return m.ConsoleFunc(ctx, containerID, shell)
+func (m *MockClient) CommitContainer(ctx context.Context, nameOrID string, opts CommitOptions) (string, error) {
+	m.recordCall("CommitContainer", nameOrID, opts)
+	return m.CommitContainerFunc(ctx, nameOrID, opts)
+}
+
+func (m *MockClient) Console(ctx context.Context, containerID string, shell, user string, env []string) error {
+	m.recordCall("Console", containerID, shell, user, env)
+	return m.ConsoleFunc(ctx, containerID, shell, user, env)
 }
 
 func (m *MockClient) Exec(ctx context.Context, containerID string, opts ExecOptions) error {
@@ -123,12 +227,27 @@ func (m *MockClient) Ping(ctx context.Context) error {
 	return m.PingFunc(ctx)
 }
 
+func (m *MockClient) HostInfo(ctx context.Context) (*HostInfo, error) {
+	m.recordCall("HostInfo")
+	return m.HostInfoFunc(ctx)
+}
+
 func (m *MockClient) Context() context.Context {
 	return context.Background()
 }
 
 func (m *MockClient) IsMachine() bool {
-	return false
+	return m.IsMachineFunc()
+}
+
+func (m *MockClient) Machine(ctx context.Context, name string) (*MachineInfo, error) {
+	m.recordCall("Machine", name)
+	return m.MachineFunc(ctx, name)
+}
+
+func (m *MockClient) SetMachineResources(ctx context.Context, name string, res MachineResources) error {
+	m.recordCall("SetMachineResources", name, res)
+	return m.SetMachineResourcesFunc(ctx, name, res)
 }
 
 // CallCount returns the number of times a method was called.
@@ -147,6 +266,17 @@ func (m *MockClient) WasCalled(method string) bool {
 	return m.CallCount(method) > 0
 }
 
+// WasCalledWith returns true if the method was called with exactly these
+// arguments, not counting the context.
+func (m *MockClient) WasCalledWith(method string, args ...interface{}) bool {
+	for _, call := range m.Calls {
+		if call.Method == method && reflect.DeepEqual(call.Args, args) {
+			return true
+		}
+	}
+	return false
+}
+
 // Reset clears all recorded calls.
 func (m *MockClient) Reset() {
 	m.Calls = make([]MockCall, 0)
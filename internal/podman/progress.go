@@ -0,0 +1,84 @@
+package podman
+
+import (
+	"bytes"
+	"context"
+	"strings"
+)
+
+// PullProgress reports how an image pull is going. Podman's pull API
+// reports blobs as they are copied rather than byte counts, so progress is
+// counted in layers.
+type PullProgress struct {
+	Image  string `json:"image"`
+	Layers int    `json:"layers"` // layers copied or being copied so far
+	Status string `json:"status"` // latest line of pull output
+	Done   bool   `json:"done,omitempty"`
+}
+
+type pullProgressKey struct{}
+
+// WithPullProgress returns a context whose image pulls report their
+// progress to fn
+func WithPullProgress(ctx context.Context, fn func(PullProgress)) context.Context {
+	return context.WithValue(ctx, pullProgressKey{}, fn)
+}
+
+// PullProgressFunc returns the function pulls under ctx report progress
+// to, or nil if nothing is listening
+func PullProgressFunc(ctx context.Context) func(PullProgress) {
+	fn, _ := ctx.Value(pullProgressKey{}).(func(PullProgress))
+	return fn
+}
+
+// progressWriter turns the pull output Podman streams back into
+// PullProgress reports, one per line
+type progressWriter struct {
+	progress PullProgress
+	fn       func(PullProgress)
+	blobs    map[string]bool
+	buf      []byte
+}
+
+func newProgressWriter(image string, fn func(PullProgress)) *progressWriter {
+	return &progressWriter{
+		progress: PullProgress{Image: image},
+		fn:       fn,
+		blobs:    make(map[string]bool),
+	}
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.line(strings.TrimSpace(string(w.buf[:i])))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+func (w *progressWriter) line(line string) {
+	if line == "" {
+		return
+	}
+
+	// "Copying blob sha256:... [done|skipped: already exists]"
+	if rest, ok := strings.CutPrefix(line, "Copying blob "); ok {
+		if digest, _, _ := strings.Cut(rest, " "); !w.blobs[digest] {
+			w.blobs[digest] = true
+			w.progress.Layers++
+		}
+	}
+	w.progress.Status = line
+	w.fn(w.progress)
+}
+
+// done reports the pull as finished
+func (w *progressWriter) done() {
+	w.progress.Done = true
+	w.fn(w.progress)
+}
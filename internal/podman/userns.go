@@ -0,0 +1,105 @@
+package podman
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/containers/podman/v5/pkg/specgen"
+	"github.com/containers/storage/pkg/idtools"
+	storagetypes "github.com/containers/storage/types"
+)
+
+// UserNS configures a container's user namespace
+type UserNS struct {
+	// keep-id, auto, or nomap, optionally with options such as
+	// keep-id:uid=1000; empty uses podman's default
+	Mode string
+	// Custom mappings as container:host:size, which put the container in
+	// a private namespace. A missing GIDMap reuses UIDMap.
+	UIDMap []string
+	GIDMap []string
+}
+
+// ValidateUserNSMode rejects user namespace modes other than keep-id,
+// auto, and nomap
+func ValidateUserNSMode(mode string) error {
+	name, _, _ := strings.Cut(mode, ":")
+	switch {
+	case mode == "", mode == "nomap", name == "keep-id", name == "auto":
+		return nil
+	default:
+		return fmt.Errorf("unknown user namespace mode %q; use keep-id, auto, or nomap", mode)
+	}
+}
+
+// ParseIDMap reads an ID mapping in podman's --uidmap form,
+// container:host:size
+func ParseIDMap(s string) (idtools.IDMap, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return idtools.IDMap{}, fmt.Errorf("invalid ID mapping %q; use container:host:size", s)
+	}
+	var ids [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return idtools.IDMap{}, fmt.Errorf("invalid ID mapping %q; use container:host:size", s)
+		}
+		ids[i] = n
+	}
+	if ids[2] == 0 {
+		return idtools.IDMap{}, fmt.Errorf("invalid ID mapping %q: size must be positive", s)
+	}
+	return idtools.IDMap{ContainerID: ids[0], HostID: ids[1], Size: ids[2]}, nil
+}
+
+// apply sets the user namespace on a container spec
+func (u UserNS) apply(spec *specgen.SpecGenerator) error {
+	if len(u.UIDMap) == 0 && len(u.GIDMap) == 0 {
+		if u.Mode == "" {
+			return nil
+		}
+		if err := ValidateUserNSMode(u.Mode); err != nil {
+			return err
+		}
+		ns, err := specgen.ParseUserNamespace(u.Mode)
+		if err != nil {
+			return fmt.Errorf("parsing user namespace: %w", err)
+		}
+		spec.UserNS = ns
+		return nil
+	}
+
+	if u.Mode != "" {
+		return fmt.Errorf("user namespace mode %q can't be combined with custom ID mappings", u.Mode)
+	}
+
+	uidMap, gidMap := u.UIDMap, u.GIDMap
+	if len(gidMap) == 0 {
+		gidMap = uidMap
+	}
+	if len(uidMap) == 0 {
+		uidMap = gidMap
+	}
+
+	mappings := &storagetypes.IDMappingOptions{}
+	for _, s := range uidMap {
+		m, err := ParseIDMap(s)
+		if err != nil {
+			return err
+		}
+		mappings.UIDMap = append(mappings.UIDMap, m)
+	}
+	for _, s := range gidMap {
+		m, err := ParseIDMap(s)
+		if err != nil {
+			return err
+		}
+		mappings.GIDMap = append(mappings.GIDMap, m)
+	}
+
+	spec.UserNS = specgen.Namespace{NSMode: specgen.Private}
+	spec.IDMappings = mappings
+	return nil
+}
@@ -0,0 +1,341 @@
+package project
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// WorkspaceDir is where puck init mounts the project inside the puck
+const WorkspaceDir = "/workspace"
+
+// Detection is what puck init found in a project and the puck it suggests
+type Detection struct {
+	Spec  Spec
+	Found []string // files the suggestion is based on
+	Notes []string // things worth knowing that puck.yaml doesn't cover
+}
+
+// language is a toolchain recognized by its marker files
+type language struct {
+	name    string
+	markers []string
+	port    int
+	// image picks the image, reading versions from the project
+	image func(dir string) string
+}
+
+var languages = []language{
+	{name: "Go", markers: []string{"go.mod"}, port: 8080, image: goImage},
+	{name: "Node.js", markers: []string{"package.json"}, port: 3000, image: nodeImage},
+	{name: "Python", markers: []string{"pyproject.toml", "requirements.txt", "Pipfile", "uv.lock"}, port: 8000, image: pythonImage},
+	{name: "Rust", markers: []string{"Cargo.toml"}, port: 8080, image: func(string) string { return "rust:1" }},
+	{name: "Ruby", markers: []string{"Gemfile"}, port: 3000, image: rubyImage},
+	{name: "Java", markers: []string{"pom.xml", "build.gradle", "build.gradle.kts"}, port: 8080, image: func(string) string { return "eclipse-temurin:21" }},
+}
+
+// lockfiles name the package manager a project uses
+var lockfiles = map[string]string{
+	"package-lock.json": "npm",
+	"pnpm-lock.yaml":    "pnpm",
+	"yarn.lock":         "yarn",
+	"bun.lockb":         "bun",
+	"poetry.lock":       "poetry",
+	"uv.lock":           "uv",
+	"Pipfile.lock":      "pipenv",
+	"Cargo.lock":        "cargo",
+	"Gemfile.lock":      "bundler",
+	"go.sum":            "go modules",
+}
+
+var composeFiles = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+
+// Detect looks at the project in dir and suggests a puck for it: an
+// image for its language, or the devcontainer's, the port its dev server
+// usually listens on, and the project mounted at WorkspaceDir
+func Detect(dir string) (*Detection, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(abs); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	// Bind mounts through Podman Machine's VM are slow, so the project is
+	// synced into the puck there instead
+	d := &Detection{Spec: Spec{
+		Name:   puckName(filepath.Base(abs)),
+		Mounts: []Mount{{Source: ".", Target: WorkspaceDir, Sync: runtime.GOOS == "darwin"}},
+	}}
+
+	var lang *language
+	for i := range languages {
+		l := &languages[i]
+		marker := firstExisting(abs, l.markers)
+		if marker == "" {
+			continue
+		}
+		if lang != nil {
+			d.Notes = append(d.Notes, fmt.Sprintf("%s also suggests %s; the puck is set up for %s", marker, l.name, lang.name))
+			continue
+		}
+		lang = l
+		d.Found = append(d.Found, marker)
+	}
+	if lang != nil {
+		// Language images have no systemd, so keep the puck up with a
+		// command of its own
+		d.Spec.Image = lang.image(abs)
+		d.Spec.Init = "tini"
+		d.Spec.Command = []string{"sleep", "infinity"}
+		port := lang.port
+		if lang.name == "Node.js" && usesVite(abs) {
+			port = 5173
+		}
+		d.Spec.Ports = []string{fmt.Sprintf("%d:%d", port, port)}
+	} else {
+		d.Spec.Image = "fedora:latest"
+		d.Notes = append(d.Notes, "no known language found; using a full fedora puck")
+	}
+
+	for _, name := range sortedKeys(lockfiles) {
+		if exists(abs, name) {
+			if !slices.Contains(d.Found, name) {
+				d.Found = append(d.Found, name)
+			}
+			d.Notes = append(d.Notes, fmt.Sprintf("dependencies are managed with %s; install them inside the puck", lockfiles[name]))
+		}
+	}
+
+	if err := d.devcontainer(abs); err != nil {
+		return nil, err
+	}
+	if err := d.compose(abs); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// devcontainer prefers the image and forwarded ports of a devcontainer.json
+func (d *Detection) devcontainer(dir string) error {
+	rel := firstExisting(dir, []string{".devcontainer/devcontainer.json", ".devcontainer.json"})
+	if rel == "" {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(dir, rel))
+	if err != nil {
+		return err
+	}
+
+	var dc struct {
+		Image        string `json:"image"`
+		DockerFile   string `json:"dockerFile"`
+		Build        any    `json:"build"`
+		ForwardPorts []any  `json:"forwardPorts"` // ports, or "host:port" strings
+	}
+	if err := json.Unmarshal(stripJSONC(data), &dc); err != nil {
+		d.Notes = append(d.Notes, fmt.Sprintf("could not read %s: %v", rel, err))
+		return nil
+	}
+	d.Found = append(d.Found, rel)
+
+	switch {
+	case dc.Image != "":
+		d.Spec.Image = dc.Image
+		// Devcontainer images are usually not systemd images either
+		d.Spec.Init = "tini"
+		d.Spec.Command = []string{"sleep", "infinity"}
+	case dc.DockerFile != "" || dc.Build != nil:
+		d.Notes = append(d.Notes, fmt.Sprintf("%s builds its own image; build and tag it, then set image in %s", rel, FileName))
+	}
+	var ports []string
+	for _, p := range dc.ForwardPorts {
+		if port, ok := p.(float64); ok {
+			ports = append(ports, fmt.Sprintf("%d:%d", int(port), int(port)))
+		}
+	}
+	if len(ports) > 0 {
+		d.Spec.Ports = ports
+	}
+	return nil
+}
+
+// compose notes the services a compose file runs next to the app, which
+// become pucks of their own
+func (d *Detection) compose(dir string) error {
+	rel := firstExisting(dir, composeFiles)
+	if rel == "" {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(dir, rel))
+	if err != nil {
+		return err
+	}
+
+	var doc struct {
+		Services map[string]struct {
+			Image string `yaml:"image"`
+		} `yaml:"services"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		d.Notes = append(d.Notes, fmt.Sprintf("could not read %s: %v", rel, err))
+		return nil
+	}
+	d.Found = append(d.Found, rel)
+
+	for _, name := range sortedKeys(doc.Services) {
+		image := doc.Services[name].Image
+		if image == "" {
+			continue
+		}
+		d.Notes = append(d.Notes, fmt.Sprintf("%s runs %s as '%s'; give it its own puck (puck create %s --image %s) and list it under requires", rel, image, name, name, image))
+	}
+	return nil
+}
+
+var goDirective = regexp.MustCompile(`(?m)^go\s+(\d+\.\d+)`)
+
+func goImage(dir string) string {
+	data, _ := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if m := goDirective.FindSubmatch(data); m != nil {
+		return "golang:" + string(m[1])
+	}
+	return "golang:latest"
+}
+
+var majorVersion = regexp.MustCompile(`\d+`)
+
+func nodeImage(dir string) string {
+	if data, err := os.ReadFile(filepath.Join(dir, ".nvmrc")); err == nil {
+		if v := majorVersion.Find(data); v != nil {
+			return "node:" + string(v)
+		}
+	}
+	var pkg struct {
+		Engines struct {
+			Node string `json:"node"`
+		} `json:"engines"`
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "package.json"))
+	if json.Unmarshal(data, &pkg) == nil {
+		if v := majorVersion.FindString(pkg.Engines.Node); v != "" {
+			return "node:" + v
+		}
+	}
+	return "node:22"
+}
+
+func usesVite(dir string) bool {
+	var pkg struct {
+		Dependencies    map[string]string `json:"dependencies"`
+		DevDependencies map[string]string `json:"devDependencies"`
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "package.json"))
+	if json.Unmarshal(data, &pkg) != nil {
+		return false
+	}
+	_, dep := pkg.Dependencies["vite"]
+	_, devDep := pkg.DevDependencies["vite"]
+	return dep || devDep
+}
+
+var minorVersion = regexp.MustCompile(`\d+\.\d+`)
+
+func pythonImage(dir string) string {
+	if data, err := os.ReadFile(filepath.Join(dir, ".python-version")); err == nil {
+		if v := minorVersion.Find(data); v != nil {
+			return "python:" + string(v)
+		}
+	}
+	return "python:3.12"
+}
+
+func rubyImage(dir string) string {
+	if data, err := os.ReadFile(filepath.Join(dir, ".ruby-version")); err == nil {
+		if v := minorVersion.Find(data); v != nil {
+			return "ruby:" + string(v)
+		}
+	}
+	return "ruby:3.3"
+}
+
+var unsafeNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// puckName turns a directory name into a puck name
+func puckName(base string) string {
+	name := strings.Trim(unsafeNameChars.ReplaceAllString(strings.ToLower(base), "-"), "-")
+	if name == "" {
+		return "project"
+	}
+	return name
+}
+
+// stripJSONC removes the comments and trailing commas JSON with comments
+// allows, as in devcontainer.json
+func stripJSONC(data []byte) []byte {
+	var out []byte
+	inString := false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case inString:
+			out = append(out, c)
+			if c == '\\' && i+1 < len(data) {
+				i++
+				out = append(out, data[i])
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+			out = append(out, c)
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			out = append(out, '\n')
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := strings.Index(string(data[i+2:]), "*/")
+			if end < 0 {
+				return out
+			}
+			i += end + 3
+		default:
+			out = append(out, c)
+		}
+	}
+	return trailingComma.ReplaceAll(out, []byte("$1"))
+}
+
+var trailingComma = regexp.MustCompile(`,(\s*[}\]])`)
+
+func exists(dir, name string) bool {
+	_, err := os.Stat(filepath.Join(dir, name))
+	return err == nil
+}
+
+func firstExisting(dir string, names []string) string {
+	for _, name := range names {
+		if exists(dir, name) {
+			return name
+		}
+	}
+	return ""
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
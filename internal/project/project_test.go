@@ -0,0 +1,120 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeProject creates a project directory with the given files
+func writeProject(t *testing.T, name string, files map[string]string) string {
+	dir := filepath.Join(t.TempDir(), name)
+	for file, content := range files {
+		path := filepath.Join(dir, file)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	return dir
+}
+
+func TestDetect(t *testing.T) {
+	t.Run("go module", func(t *testing.T) {
+		dir := writeProject(t, "My API", map[string]string{
+			"go.mod": "module example.com/api\n\ngo 1.23.4\n",
+			"go.sum": "",
+		})
+
+		d, err := Detect(dir)
+		require.NoError(t, err)
+		assert.Equal(t, "my-api", d.Spec.Name)
+		assert.Equal(t, "golang:1.23", d.Spec.Image)
+		assert.Equal(t, "tini", d.Spec.Init)
+		assert.Equal(t, []string{"8080:8080"}, d.Spec.Ports)
+		assert.Equal(t, []Mount{{Source: ".", Target: WorkspaceDir, Sync: runtime.GOOS == "darwin"}}, d.Spec.Mounts)
+		assert.Equal(t, []string{"go.mod", "go.sum"}, d.Found)
+	})
+
+	t.Run("node with vite and nvmrc", func(t *testing.T) {
+		dir := writeProject(t, "web", map[string]string{
+			"package.json":   `{"devDependencies": {"vite": "^5"}, "engines": {"node": ">=18"}}`,
+			".nvmrc":         "v20.11.0\n",
+			"pnpm-lock.yaml": "",
+		})
+
+		d, err := Detect(dir)
+		require.NoError(t, err)
+		assert.Equal(t, "node:20", d.Spec.Image)
+		assert.Equal(t, []string{"5173:5173"}, d.Spec.Ports)
+		assert.Contains(t, d.Found, "pnpm-lock.yaml")
+	})
+
+	t.Run("devcontainer wins over the language image", func(t *testing.T) {
+		dir := writeProject(t, "svc", map[string]string{
+			"requirements.txt": "flask\n",
+			".devcontainer/devcontainer.json": `{
+				// comments are allowed
+				"image": "mcr.microsoft.com/devcontainers/python:3.11",
+				"forwardPorts": [5000, "db:5432"], /* and trailing commas */
+			}`,
+		})
+
+		d, err := Detect(dir)
+		require.NoError(t, err)
+		assert.Equal(t, "mcr.microsoft.com/devcontainers/python:3.11", d.Spec.Image)
+		assert.Equal(t, []string{"5000:5000"}, d.Spec.Ports)
+	})
+
+	t.Run("notes compose services", func(t *testing.T) {
+		dir := writeProject(t, "app", map[string]string{
+			"Gemfile":       "",
+			"compose.yaml":  "services:\n  app:\n    build: .\n  db:\n    image: postgres:16\n",
+			".ruby-version": "3.2.2\n",
+		})
+
+		d, err := Detect(dir)
+		require.NoError(t, err)
+		assert.Equal(t, "ruby:3.2", d.Spec.Image)
+		require.NotEmpty(t, d.Notes)
+		assert.Contains(t, d.Notes[len(d.Notes)-1], "postgres:16")
+	})
+
+	t.Run("falls back to fedora", func(t *testing.T) {
+		d, err := Detect(writeProject(t, "notes", nil))
+		require.NoError(t, err)
+		assert.Equal(t, "fedora:latest", d.Spec.Image)
+		assert.Empty(t, d.Spec.Init)
+		assert.Empty(t, d.Spec.Command)
+	})
+}
+
+func TestSpecRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, FileName)
+
+	spec := &Spec{Name: "api", Image: "golang:1.23", Init: "tini", Mounts: []Mount{{Source: ".", Target: "/workspace"}}}
+	require.NoError(t, spec.Write(file, "# generated\n"))
+
+	loaded, err := Load(file)
+	require.NoError(t, err)
+	assert.Equal(t, spec, loaded)
+
+	opts, err := loaded.CreateOptions(dir)
+	require.NoError(t, err)
+	assert.Equal(t, store.InitTini, opts.Init)
+	assert.Equal(t, []store.Mount{{Source: dir, Target: "/workspace"}}, opts.Mounts)
+
+	t.Setenv("HOME", dir)
+	loaded.Mounts = []Mount{{Source: "~/cache", Target: "/cache"}}
+	opts, err = loaded.CreateOptions(t.TempDir())
+	require.NoError(t, err)
+	assert.Equal(t, []store.Mount{{Source: filepath.Join(dir, "cache"), Target: "/cache"}}, opts.Mounts)
+
+	_, err = Load(filepath.Join(t.TempDir(), FileName))
+	assert.ErrorContains(t, err, "puck init")
+}
@@ -0,0 +1,110 @@
+// Package project reads and writes puck.yaml, a project's description of
+// the puck to develop it in, and suggests one by looking at the project
+package project
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/sandwich-labs/puck/internal/store"
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the project file written by puck init and read by puck apply
+const FileName = "puck.yaml"
+
+// Spec is a parsed puck.yaml
+type Spec struct {
+	Name       string   `yaml:"name"`
+	Image      string   `yaml:"image"`
+	Init       string   `yaml:"init,omitempty"`
+	Ports      []string `yaml:"ports,omitempty"`
+	Entrypoint []string `yaml:"entrypoint,omitempty"`
+	Command    []string `yaml:"command,omitempty"`
+	Requires   []string `yaml:"requires,omitempty"`
+	Mounts     []Mount  `yaml:"mounts,omitempty"`
+}
+
+// Mount is a host directory mounted into the puck. A relative source is
+// relative to the directory holding puck.yaml, and ~ is the home directory.
+type Mount struct {
+	Source   string `yaml:"source"`
+	Target   string `yaml:"target"`
+	ReadOnly bool   `yaml:"read_only,omitempty"`
+	// Sync copies the directory into the puck and keeps it up to date,
+	// rather than bind mounting it (see puck sync)
+	Sync   bool     `yaml:"sync,omitempty"`
+	Ignore []string `yaml:"ignore,omitempty"`
+}
+
+// Load reads a puck.yaml
+func Load(file string) (*Spec, error) {
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no %s here; create one with: puck init", filepath.Base(file))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", file, err)
+	}
+
+	var s Spec
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", file, err)
+	}
+	if s.Name == "" {
+		return nil, fmt.Errorf("%s: name is required", file)
+	}
+	for _, m := range s.Mounts {
+		if m.Source == "" || !path.IsAbs(m.Target) {
+			return nil, fmt.Errorf("%s: mount %q needs a source and an absolute target", file, m.Source+":"+m.Target)
+		}
+	}
+	return &s, nil
+}
+
+// Write saves the spec to file, after a comment header if given
+func (s *Spec) Write(file, header string) error {
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("encoding %s: %w", FileName, err)
+	}
+	return os.WriteFile(file, append([]byte(header), data...), 0644)
+}
+
+// CreateOptions returns the options to create the puck, with mount
+// sources resolved against dir
+func (s *Spec) CreateOptions(dir string) (puck.CreateOptions, error) {
+	opts := puck.CreateOptions{
+		Name:       s.Name,
+		Image:      s.Image,
+		Init:       store.InitMode(s.Init),
+		Ports:      s.Ports,
+		Entrypoint: s.Entrypoint,
+		Command:    s.Command,
+		Requires:   s.Requires,
+	}
+	for _, m := range s.Mounts {
+		source := m.Source
+		if source == "~" || strings.HasPrefix(source, "~/") {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return puck.CreateOptions{}, err
+			}
+			source = filepath.Join(home, strings.TrimPrefix(source, "~"))
+		}
+		if !filepath.IsAbs(source) {
+			source = filepath.Join(dir, source)
+		}
+		source, err := filepath.Abs(source)
+		if err != nil {
+			return puck.CreateOptions{}, err
+		}
+		opts.Mounts = append(opts.Mounts, store.Mount{Source: source, Target: m.Target, ReadOnly: m.ReadOnly, Sync: m.Sync, Ignore: m.Ignore})
+	}
+	return opts, nil
+}
@@ -0,0 +1,172 @@
+package puck
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// Periods activity can be summed up by
+const (
+	ActivityByDay  = "day"
+	ActivityByWeek = "week"
+)
+
+// Activity sums up how pucks were used over a period, period by period, from
+// their history and the daemon's samples. It's worked out where it's
+// shown and never leaves the machine.
+type Activity struct {
+	Since   time.Time        `json:"since"`
+	Until   time.Time        `json:"until"`
+	By      string           `json:"by"`
+	Periods []ActivityPeriod `json:"periods"`
+	Pucks   []PuckActivity   `json:"pucks"`
+}
+
+// ActivityPeriod is what happened in a day or week of an Activity
+type ActivityPeriod struct {
+	Start time.Time `json:"start"`
+	// Pucks that were created, started, exec'd into, snapshotted or sent
+	// requests
+	Active    int    `json:"active"`
+	Created   int    `json:"created"`
+	Starts    int    `json:"starts"`
+	Execs     int    `json:"execs"` // puck exec and console sessions
+	Snapshots int    `json:"snapshots"`
+	Requests  uint64 `json:"requests"` // routed
+}
+
+// PuckActivity is what one puck did over an Activity's whole period
+type PuckActivity struct {
+	Name      string `json:"name"`
+	Active    int    `json:"active"` // periods it was active in
+	Starts    int    `json:"starts"`
+	Execs     int    `json:"execs"`
+	Snapshots int    `json:"snapshots"`
+	Requests  uint64 `json:"requests"`
+	// When it was last active in the period
+	LastActive *time.Time `json:"last_active,omitempty"`
+}
+
+// SumActivity sums up the named pucks' events and stats samples between
+// since and until by day or week, starting periods at midnight, and
+// weeks on Monday, in until's location. Pucks with no events or samples
+// show up with nothing used.
+func SumActivity(names []string, events []*store.Event, stats []*store.Stat, since, until time.Time, by string) *Activity {
+	u := &Activity{Since: since, Until: until, By: by, Periods: []ActivityPeriod{}, Pucks: []PuckActivity{}}
+	loc := until.Location()
+	for start := periodStart(since.In(loc), by); start.Before(until); start = nextPeriod(start, by) {
+		u.Periods = append(u.Periods, ActivityPeriod{Start: start})
+	}
+	period := func(t time.Time) int {
+		if t.Before(since) || t.After(until) {
+			return -1
+		}
+		start := periodStart(t.In(loc), by)
+		return slices.IndexFunc(u.Periods, func(p ActivityPeriod) bool { return p.Start.Equal(start) })
+	}
+
+	pucks := make(map[string]*PuckActivity)
+	active := make(map[string]map[int]bool)
+	puckActivity := func(name string) *PuckActivity {
+		if pucks[name] == nil {
+			pucks[name] = &PuckActivity{Name: name}
+			active[name] = make(map[int]bool)
+		}
+		return pucks[name]
+	}
+	markActive := func(name string, i int, at time.Time) {
+		p := puckActivity(name)
+		active[name][i] = true
+		if p.LastActive == nil || at.After(*p.LastActive) {
+			p.LastActive = &at
+		}
+	}
+	for _, name := range names {
+		puckActivity(name)
+	}
+
+	for _, e := range events {
+		i := period(e.CreatedAt)
+		if i < 0 {
+			continue
+		}
+		p, per := puckActivity(e.PuckName), &u.Periods[i]
+		switch e.Type {
+		case store.EventCreated:
+			per.Created++
+		case store.EventStarted:
+			per.Starts++
+			p.Starts++
+		case store.EventExec:
+			per.Execs++
+			p.Execs++
+		case store.EventSnapshotCreated:
+			per.Snapshots++
+			p.Snapshots++
+		case store.EventRecreated, store.EventSnapshotRestored:
+		default:
+			continue
+		}
+		markActive(e.PuckName, i, e.CreatedAt)
+	}
+
+	// Each sample's hits count up from the one before
+	last := make(map[string]*store.Stat)
+	for _, s := range stats {
+		prev := last[s.PuckName]
+		last[s.PuckName] = s
+		i := period(s.CreatedAt)
+		if prev == nil || i < 0 {
+			continue
+		}
+		if hits := newHits(prev.Hits, s.Hits); hits > 0 {
+			u.Periods[i].Requests += hits
+			puckActivity(s.PuckName).Requests += hits
+			markActive(s.PuckName, i, s.CreatedAt)
+		}
+	}
+
+	for i := range u.Periods {
+		for name := range active {
+			if active[name][i] {
+				u.Periods[i].Active++
+			}
+		}
+	}
+	for _, p := range pucks {
+		p.Active = len(active[p.Name])
+		u.Pucks = append(u.Pucks, *p)
+	}
+	slices.SortFunc(u.Pucks, func(a, b PuckActivity) int { return cmp.Compare(a.Name, b.Name) })
+	return u
+}
+
+// periodStart returns the start of the day or week t falls in
+func periodStart(t time.Time, by string) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if by == ActivityByWeek {
+		day = day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	}
+	return day
+}
+
+// nextPeriod returns the start of the day or week after the one starting
+// at start
+func nextPeriod(start time.Time, by string) time.Time {
+	if by == ActivityByWeek {
+		return start.AddDate(0, 0, 7)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// newHits returns the requests served between two samples' hit counts,
+// which start over when the router restarts
+func newHits(prev, cur uint64) uint64 {
+	if cur >= prev {
+		return cur - prev
+	}
+	return cur
+}
@@ -0,0 +1,64 @@
+package puck
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSumActivity(t *testing.T) {
+	// Wednesday 2026-10-14, in UTC
+	until := time.Date(2026, 10, 14, 18, 0, 0, 0, time.UTC)
+	since := until.Add(-14 * 24 * time.Hour)
+	day := func(d int, hour int) time.Time {
+		return time.Date(2026, 10, d, hour, 0, 0, 0, time.UTC)
+	}
+	events := []*store.Event{
+		{PuckName: "web", Type: store.EventCreated, CreatedAt: since.Add(-time.Hour)},
+		{PuckName: "web", Type: store.EventStarted, CreatedAt: day(5, 9)},
+		{PuckName: "web", Type: store.EventExec, Detail: "console", CreatedAt: day(5, 10)},
+		{PuckName: "web", Type: store.EventExec, Detail: "make", CreatedAt: day(13, 10)},
+		{PuckName: "web", Type: store.EventStopped, CreatedAt: day(13, 11)},
+		{PuckName: "api", Type: store.EventCreated, CreatedAt: day(13, 12)},
+		{PuckName: "api", Type: store.EventSnapshotCreated, CreatedAt: day(14, 9)},
+		{PuckName: "old", Type: store.EventCheckpointed, CreatedAt: day(6, 9)},
+	}
+	stats := []*store.Stat{
+		{PuckName: "api", Hits: 0, CreatedAt: day(13, 12)},
+		{PuckName: "api", Hits: 40, CreatedAt: day(13, 13)},
+		{PuckName: "api", Hits: 5, CreatedAt: day(14, 9)}, // the router restarted
+		{PuckName: "old", Hits: 7, CreatedAt: day(6, 9)},
+		{PuckName: "old", Hits: 7, CreatedAt: day(6, 10)},
+	}
+
+	t.Run("by week", func(t *testing.T) {
+		a := SumActivity([]string{"web", "api", "old", "idle"}, events, stats, since, until, ActivityByWeek)
+
+		require.Len(t, a.Periods, 3)
+		assert.Equal(t, time.Date(2026, 9, 28, 0, 0, 0, 0, time.UTC), a.Periods[0].Start)
+		assert.Equal(t, ActivityPeriod{Start: day(5, 0), Active: 1, Starts: 1, Execs: 1}, a.Periods[1])
+		assert.Equal(t, ActivityPeriod{Start: day(12, 0), Active: 2, Created: 1, Execs: 1, Snapshots: 1, Requests: 45}, a.Periods[2])
+
+		require.Len(t, a.Pucks, 4)
+		assert.Equal(t, []string{"api", "idle", "old", "web"}, []string{a.Pucks[0].Name, a.Pucks[1].Name, a.Pucks[2].Name, a.Pucks[3].Name})
+		lastAPI := day(14, 9)
+		assert.Equal(t, PuckActivity{Name: "api", Active: 1, Snapshots: 1, Requests: 45, LastActive: &lastAPI}, a.Pucks[0])
+		assert.Equal(t, PuckActivity{Name: "idle"}, a.Pucks[1])
+		assert.Equal(t, PuckActivity{Name: "old"}, a.Pucks[2], "checkpoints and unchanged hits aren't activity")
+		lastWeb := day(13, 10)
+		assert.Equal(t, PuckActivity{Name: "web", Active: 2, Starts: 1, Execs: 2, LastActive: &lastWeb}, a.Pucks[3])
+	})
+
+	t.Run("by day", func(t *testing.T) {
+		a := SumActivity(nil, events, stats, since, until, ActivityByDay)
+
+		require.Len(t, a.Periods, 15)
+		assert.Equal(t, time.Date(2026, 9, 30, 0, 0, 0, 0, time.UTC), a.Periods[0].Start)
+		assert.Equal(t, day(14, 0), a.Periods[14].Start)
+		assert.Equal(t, 40, int(a.Periods[13].Requests))
+		assert.Equal(t, 5, int(a.Periods[14].Requests))
+	})
+}
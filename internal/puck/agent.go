@@ -0,0 +1,113 @@
+package puck
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/sandwich-labs/puck/internal/agent"
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// ErrAgentUnsupported is the agent being unable to report from a puck in a
+// Podman Machine, whose VM can't reach a socket on the host
+var ErrAgentUnsupported = errors.New("the puck agent isn't supported under Podman Machine")
+
+// AgentSocket returns where a puck's agent reports to on the host, in its
+// etc volume
+func AgentSocket(p *store.Puck) string {
+	return filepath.Join(p.VolumeDir, "etc", agent.SocketFile)
+}
+
+// StartAgent puts puck-agent in a running puck's etc volume, if it isn't
+// there already, and starts it in the background as the puck's root. The
+// daemon must be listening on AgentSocket first.
+func (m *Manager) StartAgent(ctx context.Context, p *store.Puck) error {
+	if m.podman.IsMachine() {
+		return ErrAgentUnsupported
+	}
+	if err := m.installAgent(p); err != nil {
+		return fmt.Errorf("installing agent: %w", err)
+	}
+	err := m.podman.Exec(ctx, p.ContainerID, podman.ExecOptions{
+		Cmd:    []string{agent.BinaryPath},
+		User:   "0",
+		Detach: true,
+		Output: io.Discard,
+	})
+	if err != nil {
+		return fmt.Errorf("starting agent: %w", err)
+	}
+	return nil
+}
+
+// installAgent copies the agent binary into a puck's etc volume unless an
+// identical one is there. It is renamed into place, as an agent already
+// running keeps the old file busy.
+func (m *Manager) installAgent(p *store.Puck) error {
+	src, err := m.cfg.AgentBinaryPath()
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	dst := filepath.Join(p.VolumeDir, "etc", agent.BinaryFile)
+	if have, err := os.Stat(dst); err == nil && have.Size() == info.Size() && have.ModTime().Equal(info.ModTime()) {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".agent-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+	if err := os.Chtimes(tmp.Name(), info.ModTime(), info.ModTime()); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+// ProbePorts finds the TCP ports listening in a running puck without its
+// agent, by reading the puck's /proc/net/tcp and tcp6 through podman exec
+func (m *Manager) ProbePorts(ctx context.Context, p *store.Puck) ([]agent.Port, error) {
+	var out bytes.Buffer
+	err := m.podman.Exec(ctx, p.ContainerID, podman.ExecOptions{
+		Cmd:       []string{"cat", "/proc/net/tcp", "/proc/net/tcp6"},
+		Output:    &out,
+		ErrOutput: io.Discard,
+	})
+	// cat fails on tcp6 without IPv6, having read tcp
+	var exitErr *podman.ExitError
+	if err != nil && (!errors.As(err, &exitErr) || exitErr.Code != 1 || out.Len() == 0) {
+		return nil, fmt.Errorf("reading listening ports: %w", err)
+	}
+	return agent.ParsePorts(out.Bytes()), nil
+}
+
+// AppPortHint suggests how to serve a puck's app listening on port when
+// the router only reaches its port 80
+func AppPortHint(name string, port int) string {
+	return fmt.Sprintf("serve it at /%s/app/ with: puck route endpoint %s app:%d, while the puck is stopped", name, name, port)
+}
@@ -0,0 +1,91 @@
+package puck
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/agent"
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartAgent(t *testing.T) {
+	t.Run("installs the agent and starts it detached as root", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		bin := filepath.Join(t.TempDir(), "puck-agent")
+		require.NoError(t, os.WriteFile(bin, []byte("agent v1"), 0644))
+		mgr.cfg.AgentBinary = bin
+
+		p, err := mgr.Create(ctx, CreateOptions{Name: "dev", User: "dev"})
+		require.NoError(t, err)
+
+		var got podman.ExecOptions
+		mock.ExecFunc = func(ctx context.Context, containerID string, opts podman.ExecOptions) error {
+			got = opts
+			return nil
+		}
+		require.NoError(t, mgr.StartAgent(ctx, p))
+		assert.Equal(t, []string{agent.BinaryPath}, got.Cmd)
+		assert.Equal(t, "0", got.User)
+		assert.True(t, got.Detach)
+
+		installed := filepath.Join(p.VolumeDir, "etc", agent.BinaryFile)
+		data, err := os.ReadFile(installed)
+		require.NoError(t, err)
+		assert.Equal(t, "agent v1", string(data))
+		info, err := os.Stat(installed)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+
+		// A new build replaces it
+		require.NoError(t, os.WriteFile(bin, []byte("agent v2!"), 0644))
+		require.NoError(t, mgr.StartAgent(ctx, p))
+		data, err = os.ReadFile(installed)
+		require.NoError(t, err)
+		assert.Equal(t, "agent v2!", string(data))
+	})
+
+	t.Run("not under Podman Machine", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		p, err := mgr.Create(ctx, CreateOptions{Name: "dev"})
+		require.NoError(t, err)
+
+		mock.IsMachineFunc = func() bool { return true }
+		assert.ErrorIs(t, mgr.StartAgent(ctx, p), ErrAgentUnsupported)
+	})
+}
+
+func TestProbePorts(t *testing.T) {
+	mgr, mock, cleanup := setupTestManager(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	p, err := mgr.Create(ctx, CreateOptions{Name: "dev"})
+	require.NoError(t, err)
+
+	tcp := "  sl  local_address rem_address   st\n   0: 00000000:0BB8 00000000:0000 0A\n"
+	mock.ExecFunc = func(ctx context.Context, containerID string, opts podman.ExecOptions) error {
+		assert.Equal(t, []string{"cat", "/proc/net/tcp", "/proc/net/tcp6"}, opts.Cmd)
+		opts.Output.Write([]byte(tcp))
+		// No IPv6 in the puck
+		return &podman.ExitError{Code: 1}
+	}
+	ports, err := mgr.ProbePorts(ctx, p)
+	require.NoError(t, err)
+	assert.Equal(t, []agent.Port{{Port: 3000, Address: "0.0.0.0"}}, ports)
+
+	mock.ExecFunc = func(ctx context.Context, containerID string, opts podman.ExecOptions) error {
+		return &podman.ExitError{Code: 127}
+	}
+	_, err = mgr.ProbePorts(ctx, p)
+	assert.Error(t, err, "an image without cat")
+}
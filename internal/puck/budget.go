@@ -0,0 +1,128 @@
+package puck
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// ensureBudget makes sure p's limits fit in the configured resource budget
+// alongside the other running pucks. Under the checkpoint policy the least
+// recently used pucks are checkpointed to make room; otherwise, or if that
+// is not enough, an error explains what is over.
+func (m *Manager) ensureBudget(ctx context.Context, p *store.Puck) error {
+	if m.cfg.BudgetMemory == 0 && m.cfg.BudgetCPUs == 0 {
+		return nil
+	}
+
+	pucks, err := m.store.ListPucks(ctx)
+	if err != nil {
+		return err
+	}
+
+	var used store.Resources
+	var running []*store.Puck
+	for _, o := range pucks {
+		if o.Name == p.Name || !o.Status.Up() {
+			continue
+		}
+		used.Memory += o.Resources.Memory
+		used.CPUs += o.Resources.CPUs
+		// Pucks still starting aren't checkpointed out from under their app
+		if o.Status == store.StatusRunning {
+			running = append(running, o)
+		}
+	}
+
+	over := m.overBudget(used, p.Resources)
+	if len(over) == 0 {
+		return nil
+	}
+
+	if m.cfg.BudgetPolicy == config.BudgetCheckpoint {
+		sort.Slice(running, func(i, j int) bool {
+			return running[i].LastUsedAt.Before(running[j].LastUsedAt)
+		})
+		for _, o := range running {
+			if len(over) == 0 {
+				break
+			}
+			if o.Resources.Memory == 0 && o.Resources.CPUs == 0 {
+				continue // Checkpointing it would free nothing we count
+			}
+			if err := m.checkpointIdle(ctx, o, "resource budget"); err != nil {
+				return fmt.Errorf("checkpointing '%s' to stay within the resource budget: %w", o.Name, err)
+			}
+			used.Memory -= o.Resources.Memory
+			used.CPUs -= o.Resources.CPUs
+			over = m.overBudget(used, p.Resources)
+		}
+		if len(over) == 0 {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("running '%s' would exceed the resource budget (%s); stop another puck or raise the budget", p.Name, strings.Join(over, ", "))
+}
+
+// overBudget describes each budget that used plus want would exceed
+func (m *Manager) overBudget(used, want store.Resources) []string {
+	var over []string
+	if m.cfg.BudgetMemory > 0 && used.Memory+want.Memory > m.cfg.BudgetMemory {
+		over = append(over, fmt.Sprintf("memory: %s reserved + %s requested > %s",
+			units.BytesSize(float64(used.Memory)), units.BytesSize(float64(want.Memory)), units.BytesSize(float64(m.cfg.BudgetMemory))))
+	}
+	if m.cfg.BudgetCPUs > 0 && used.CPUs+want.CPUs > m.cfg.BudgetCPUs {
+		over = append(over, fmt.Sprintf("cpus: %g reserved + %g requested > %g", used.CPUs, want.CPUs, m.cfg.BudgetCPUs))
+	}
+	return over
+}
+
+// CheckpointLRU checkpoints the least recently used running puck, giving
+// reason in its history. It returns nil if no puck is running.
+func (m *Manager) CheckpointLRU(ctx context.Context, reason string) (*store.Puck, error) {
+	pucks, err := m.store.ListPucks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var lru *store.Puck
+	for _, p := range pucks {
+		if p.Status != store.StatusRunning {
+			continue
+		}
+		if lru == nil || p.LastUsedAt.Before(lru.LastUsedAt) {
+			lru = p
+		}
+	}
+	if lru == nil {
+		return nil, nil
+	}
+
+	if err := m.checkpointIdle(ctx, lru, reason); err != nil {
+		return nil, fmt.Errorf("checkpointing '%s': %w", lru.Name, err)
+	}
+	return m.store.GetPuck(ctx, lru.Name)
+}
+
+// checkpointIdle suspends a running puck, so that starting it again
+// resumes where it left off
+func (m *Manager) checkpointIdle(ctx context.Context, p *store.Puck, reason string) error {
+	if err := m.suspend(ctx, p); err != nil {
+		return err
+	}
+	m.record(ctx, p.Name, store.EventCheckpointed, reason)
+	return nil
+}
+
+// Touch marks a puck as just used, e.g. because the router proxied a
+// request to it, so it is the last to be checkpointed
+func (m *Manager) Touch(ctx context.Context, name string, at time.Time) error {
+	return m.store.TouchPuck(ctx, name, at)
+}
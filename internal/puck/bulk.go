@@ -0,0 +1,145 @@
+package puck
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// snapshotAllConcurrency bounds how many pucks SnapshotAllOwnedBy
+// snapshots at once; each checkpoint writes a puck's memory to disk
+const snapshotAllConcurrency = 4
+
+// destroyAllConcurrency bounds how many pucks DestroyAll removes at once
+const destroyAllConcurrency = 8
+
+// SnapshotAllOptions contains options for snapshotting every running puck
+type SnapshotAllOptions struct {
+	SnapshotName string `json:"snapshot_name"`
+	// Checkpoint mode only; nil uses each puck's snapshot policy
+	LeaveRunning *bool              `json:"leave_running,omitempty"`
+	Mode         store.SnapshotMode `json:"mode,omitempty"` // empty uses the configured default
+	CRIUFlags
+}
+
+// SnapshotResult is how snapshotting one puck went
+type SnapshotResult struct {
+	Puck     string          `json:"puck"`
+	Snapshot *store.Snapshot `json:"snapshot,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// SnapshotAllOwnedBy snapshots each of owner's running pucks under the
+// same name, a few at a time. One puck failing does not stop the others;
+// each result says how its puck went.
+func (m *Manager) SnapshotAllOwnedBy(ctx context.Context, owner string, opts SnapshotAllOptions) ([]SnapshotResult, error) {
+	pucks, err := m.store.ListPucks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var running []*store.Puck
+	for _, p := range pucks {
+		if p.Owner == owner && p.Status == store.StatusRunning {
+			running = append(running, p)
+		}
+	}
+
+	results := make([]SnapshotResult, len(running))
+	sem := make(chan struct{}, snapshotAllConcurrency)
+	var wg sync.WaitGroup
+	for i, p := range running {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[i].Puck = name
+			if err := ctx.Err(); err != nil {
+				results[i].Error = err.Error()
+				return
+			}
+			snapshot, err := m.CreateSnapshot(ctx, SnapshotCreateOptions{
+				PuckName:     name,
+				SnapshotName: opts.SnapshotName,
+				LeaveRunning: opts.LeaveRunning,
+				Mode:         opts.Mode,
+				CRIUFlags:    opts.CRIUFlags,
+			})
+			if err != nil {
+				results[i].Error = err.Error()
+				return
+			}
+			results[i].Snapshot = snapshot
+		}(i, p.Name)
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+// DestroyResult is how destroying one puck went
+type DestroyResult struct {
+	Puck     string        `json:"puck"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// DestroyAll removes all pucks
+func (m *Manager) DestroyAll(ctx context.Context, force bool) ([]DestroyResult, error) {
+	pucks, err := m.store.ListPucks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return m.destroyPucks(ctx, pucks, force), nil
+}
+
+// DestroyAllOwnedBy removes all pucks belonging to owner
+func (m *Manager) DestroyAllOwnedBy(ctx context.Context, owner string, force bool) ([]DestroyResult, error) {
+	pucks, err := m.store.ListPucks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var owned []*store.Puck
+	for _, p := range pucks {
+		if p.Owner == owner {
+			owned = append(owned, p)
+		}
+	}
+	return m.destroyPucks(ctx, owned, force), nil
+}
+
+// destroyPucks destroys the pucks a few at a time. One puck failing does
+// not stop the others; each result says how its puck went.
+func (m *Manager) destroyPucks(ctx context.Context, pucks []*store.Puck, force bool) []DestroyResult {
+	results := make([]DestroyResult, len(pucks))
+	sem := make(chan struct{}, destroyAllConcurrency)
+	var wg sync.WaitGroup
+	for i, p := range pucks {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[i].Puck = name
+			if err := ctx.Err(); err != nil {
+				results[i].Error = err.Error()
+				return
+			}
+			start := time.Now()
+			err := m.Destroy(ctx, name, force)
+			results[i].Duration = time.Since(start)
+			if err != nil {
+				results[i].Error = err.Error()
+			}
+		}(i, p.Name)
+	}
+	wg.Wait()
+
+	return results
+}
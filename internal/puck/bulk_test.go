@@ -0,0 +1,68 @@
+package puck
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/sandwich-labs/puck/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotAllOwnedBy(t *testing.T) {
+	mgr, mock, cleanup := setupTestManager(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	for _, name := range []string{"desk-a", "desk-b", "desk-c", "desk-broken"} {
+		_, err := mgr.Create(ctx, CreateOptions{Name: name, Owner: "alice"})
+		require.NoError(t, err)
+		require.NoError(t, mgr.MarkReady(ctx, name))
+	}
+	_, err := mgr.Create(ctx, CreateOptions{Name: "desk-stopped", Owner: "alice"})
+	require.NoError(t, err)
+	require.NoError(t, mgr.store.UpdatePuckStatus(ctx, "desk-stopped", store.StatusStopped))
+	_, err = mgr.Create(ctx, CreateOptions{Name: "bobs-desk", Owner: "bob"})
+	require.NoError(t, err)
+	require.NoError(t, mgr.MarkReady(ctx, "bobs-desk"))
+
+	mock.CheckpointFunc = func(ctx context.Context, nameOrID string, opts podman.CheckpointOptions) error {
+		if strings.Contains(opts.ExportPath, "desk-broken") {
+			return errors.New("criu failed")
+		}
+		return testutil.WriteCheckpoint(ctx, nameOrID, opts)
+	}
+
+	results, err := mgr.SnapshotAllOwnedBy(ctx, "alice", SnapshotAllOptions{SnapshotName: "nightly", LeaveRunning: leaveRunning})
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+
+	byPuck := make(map[string]SnapshotResult)
+	for _, r := range results {
+		byPuck[r.Puck] = r
+	}
+	for _, name := range []string{"desk-a", "desk-b", "desk-c"} {
+		r := byPuck[name]
+		assert.Empty(t, r.Error, name)
+		require.NotNil(t, r.Snapshot, name)
+		assert.Equal(t, "nightly", r.Snapshot.Name)
+		assert.Equal(t, name, r.Snapshot.PuckName)
+	}
+	assert.Nil(t, byPuck["desk-broken"].Snapshot)
+	assert.Contains(t, byPuck["desk-broken"].Error, "criu failed")
+	assert.NotContains(t, byPuck, "desk-stopped")
+	assert.NotContains(t, byPuck, "bobs-desk")
+
+	// Running it again reports the name as taken rather than failing outright
+	results, err = mgr.SnapshotAllOwnedBy(ctx, "alice", SnapshotAllOptions{SnapshotName: "nightly", LeaveRunning: leaveRunning})
+	require.NoError(t, err)
+	for _, r := range results {
+		if r.Puck != "desk-broken" {
+			assert.NotEmpty(t, r.Error, r.Puck)
+		}
+	}
+}
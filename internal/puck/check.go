@@ -0,0 +1,153 @@
+package puck
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// CheckKind names a kind of inconsistency between the snapshot records and
+// the files on disk
+type CheckKind string
+
+const (
+	// CheckStalePuckID is a snapshot recorded against an ID its puck no
+	// longer has, as restores left them before pucks had stable IDs
+	CheckStalePuckID CheckKind = "stale-puck-id"
+	// CheckOrphaned is a snapshot of a puck that no longer exists
+	CheckOrphaned CheckKind = "orphaned"
+	// CheckMissingFile is a snapshot whose archive is gone
+	CheckMissingFile CheckKind = "missing-file"
+	// CheckUntrackedFile is a file in the snapshots directory no snapshot
+	// refers to
+	CheckUntrackedFile CheckKind = "untracked-file"
+)
+
+// CheckOptions controls puck db check
+type CheckOptions struct {
+	Fix bool `json:"fix"`
+}
+
+// CheckIssue is one inconsistency found by Check
+type CheckIssue struct {
+	Kind     CheckKind `json:"kind"`
+	Puck     string    `json:"puck"`
+	Snapshot string    `json:"snapshot,omitempty"`
+	Path     string    `json:"path,omitempty"`
+	Detail   string    `json:"detail,omitempty"`
+	Fixed    bool      `json:"fixed,omitempty"`
+}
+
+// CheckReport lists what Check found, and fixed if asked to
+type CheckReport struct {
+	Issues []CheckIssue `json:"issues"`
+	Errors []string     `json:"errors,omitempty"`
+}
+
+// Check compares snapshot records with their pucks and with the snapshots
+// directory. With Fix, snapshots are pointed back at their puck by name,
+// records of missing archives or missing pucks are dropped, and untracked
+// files are removed.
+func (m *Manager) Check(ctx context.Context, opts CheckOptions) (*CheckReport, error) {
+	pucks, err := m.store.ListPucks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	snapshots, err := m.store.ListAllSnapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*store.Puck, len(pucks))
+	for _, p := range pucks {
+		byName[p.Name] = p
+	}
+
+	report := &CheckReport{Issues: []CheckIssue{}}
+	fix := func(issue CheckIssue, fn func() error) {
+		if opts.Fix {
+			if err := fn(); err != nil {
+				report.Errors = append(report.Errors, fmt.Sprintf("fixing %s snapshot '%s' of '%s': %v", issue.Kind, issue.Snapshot, issue.Puck, err))
+			} else {
+				issue.Fixed = true
+			}
+		}
+		report.Issues = append(report.Issues, issue)
+	}
+
+	tracked := make(map[string]bool, len(snapshots))
+	for _, s := range snapshots {
+		tracked[filepath.Clean(s.Path)] = true
+		if s.VolumesPath != "" {
+			tracked[filepath.Clean(s.VolumesPath)] = true
+		}
+
+		p := byName[s.PuckName]
+		if p == nil {
+			fix(CheckIssue{Kind: CheckOrphaned, Puck: s.PuckName, Snapshot: s.Name, Path: s.Path}, func() error {
+				return m.dropSnapshot(ctx, nil, s)
+			})
+			continue
+		}
+
+		if s.PuckID != p.ID {
+			issue := CheckIssue{
+				Kind:     CheckStalePuckID,
+				Puck:     p.Name,
+				Snapshot: s.Name,
+				Detail:   fmt.Sprintf("recorded against %s, puck is %s", shortPuckID(s.PuckID), shortPuckID(p.ID)),
+			}
+			fix(issue, func() error {
+				if err := m.store.UpdateSnapshotPuckID(ctx, s.ID, p.ID); err != nil {
+					return err
+				}
+				s.PuckID = p.ID
+				return nil
+			})
+		}
+
+		if _, err := os.Stat(s.ArchivePath()); os.IsNotExist(err) {
+			fix(CheckIssue{Kind: CheckMissingFile, Puck: p.Name, Snapshot: s.Name, Path: s.ArchivePath()}, func() error {
+				return m.dropSnapshot(ctx, p, s)
+			})
+		}
+	}
+
+	err = filepath.WalkDir(m.cfg.SnapshotsDir(), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() && d.Name() == chunksDirName {
+			// Chunks are swept when the snapshots using them are deleted
+			return filepath.SkipDir
+		}
+		if d.IsDir() || tracked[filepath.Clean(path)] {
+			return nil
+		}
+		puckName := filepath.Base(filepath.Dir(path))
+		fix(CheckIssue{Kind: CheckUntrackedFile, Puck: puckName, Path: path}, func() error {
+			return os.Remove(path)
+		})
+		return nil
+	})
+	if err != nil {
+		return report, fmt.Errorf("scanning snapshots directory: %w", err)
+	}
+
+	return report, nil
+}
+
+// shortPuckID abbreviates a puck or container ID for messages
+func shortPuckID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
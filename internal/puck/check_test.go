@@ -0,0 +1,132 @@
+package puck
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+
+	setup := func(t *testing.T) (*Manager, func()) {
+		mgr, _, cleanup := setupTestManager(t)
+
+		a, err := mgr.Create(ctx, CreateOptions{Name: "check-a"})
+		require.NoError(t, err)
+		b, err := mgr.Create(ctx, CreateOptions{Name: "check-b"})
+		require.NoError(t, err)
+
+		snapshot := func(p *store.Puck, name string, withFile bool) *store.Snapshot {
+			s := &store.Snapshot{
+				ID:        p.Name + "-" + name,
+				PuckID:    p.ID,
+				PuckName:  p.Name,
+				Name:      name,
+				Path:      filepath.Join(mgr.cfg.SnapshotsDir(), p.Name, name+".tar.gz"),
+				CreatedAt: time.Now(),
+			}
+			if withFile {
+				require.NoError(t, os.MkdirAll(filepath.Dir(s.Path), 0755))
+				require.NoError(t, os.WriteFile(s.Path, []byte("checkpoint"), 0644))
+			}
+			require.NoError(t, mgr.store.CreateSnapshot(ctx, s))
+			return s
+		}
+		snapshot(a, "good", true)
+		missing := snapshot(a, "missing", false)
+		snapshot(b, "moved", true)
+		snapshot(b, "gone", true)
+		require.NoError(t, mgr.store.UpdatePuckSnapshotHead(ctx, a.Name, missing.ID))
+
+		// Records the store's foreign keys would refuse: a snapshot left on
+		// the container ID b had before a restore, and one whose puck is gone
+		raw, err := sql.Open("sqlite", filepath.Join(mgr.cfg.DataDir, "test.db"))
+		require.NoError(t, err)
+		defer raw.Close()
+		_, err = raw.Exec(`UPDATE snapshots SET puck_id = 'old-container' WHERE id = 'check-b-moved'`)
+		require.NoError(t, err)
+		_, err = raw.Exec(`UPDATE snapshots SET puck_id = 'destroyed', puck_name = 'check-gone' WHERE id = 'check-b-gone'`)
+		require.NoError(t, err)
+
+		// An archive left behind with no record
+		stray := filepath.Join(mgr.cfg.SnapshotsDir(), "check-a", "stray.tar.gz")
+		require.NoError(t, os.WriteFile(stray, []byte("stray"), 0644))
+
+		return mgr, cleanup
+	}
+
+	kinds := func(report *CheckReport) map[CheckKind]CheckIssue {
+		found := make(map[CheckKind]CheckIssue)
+		for _, issue := range report.Issues {
+			found[issue.Kind] = issue
+		}
+		return found
+	}
+
+	t.Run("reports inconsistencies without changing anything", func(t *testing.T) {
+		mgr, cleanup := setup(t)
+		defer cleanup()
+
+		report, err := mgr.Check(ctx, CheckOptions{})
+		require.NoError(t, err)
+		assert.Empty(t, report.Errors)
+		require.Len(t, report.Issues, 4)
+
+		found := kinds(report)
+		assert.Equal(t, "moved", found[CheckStalePuckID].Snapshot)
+		assert.Equal(t, "check-gone", found[CheckOrphaned].Puck)
+		assert.Equal(t, "missing", found[CheckMissingFile].Snapshot)
+		assert.Equal(t, "check-a", found[CheckUntrackedFile].Puck)
+		for _, issue := range report.Issues {
+			assert.False(t, issue.Fixed)
+		}
+
+		assert.FileExists(t, found[CheckUntrackedFile].Path)
+		all, err := mgr.store.ListAllSnapshots(ctx)
+		require.NoError(t, err)
+		assert.Len(t, all, 4)
+	})
+
+	t.Run("fixes inconsistencies", func(t *testing.T) {
+		mgr, cleanup := setup(t)
+		defer cleanup()
+
+		report, err := mgr.Check(ctx, CheckOptions{Fix: true})
+		require.NoError(t, err)
+		assert.Empty(t, report.Errors)
+		require.Len(t, report.Issues, 4)
+		for _, issue := range report.Issues {
+			assert.True(t, issue.Fixed, issue.Kind)
+		}
+
+		// The moved snapshot follows its puck by name
+		snapshots, err := mgr.ListSnapshots(ctx, "check-b")
+		require.NoError(t, err)
+		require.Len(t, snapshots, 1)
+		assert.Equal(t, "moved", snapshots[0].Name)
+
+		// The missing snapshot is dropped and the head moves up
+		snapshots, err = mgr.ListSnapshots(ctx, "check-a")
+		require.NoError(t, err)
+		require.Len(t, snapshots, 1)
+		assert.Equal(t, "good", snapshots[0].Name)
+		a, err := mgr.Get(ctx, "check-a")
+		require.NoError(t, err)
+		assert.Empty(t, a.SnapshotHead)
+
+		assert.NoFileExists(t, kinds(report)[CheckUntrackedFile].Path)
+		assert.NoFileExists(t, kinds(report)[CheckOrphaned].Path)
+
+		report, err = mgr.Check(ctx, CheckOptions{})
+		require.NoError(t, err)
+		assert.Empty(t, report.Issues)
+	})
+}
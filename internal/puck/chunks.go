@@ -0,0 +1,128 @@
+package puck
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/containers/storage/pkg/archive"
+	"github.com/sandwich-labs/puck/internal/chunkstore"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// chunkManifestExt ends the paths of checkpoints kept as chunks, which
+// are chunk manifests rather than archives
+const chunkManifestExt = ".chunks"
+
+// chunksDirName is the directory under the snapshots directory chunks are
+// kept in. Podman container names, and so puck names, can't start with a
+// dot, so it never clashes with a puck's snapshot directory.
+const chunksDirName = ".chunks"
+
+// chunkSweepGrace keeps chunks written this recently when sweeping, as a
+// checkpoint being stored may not have written its manifest yet
+const chunkSweepGrace = time.Hour
+
+// chunked reports whether a snapshot is kept as chunks
+func chunked(s *store.Snapshot) bool {
+	return strings.HasSuffix(s.Path, chunkManifestExt)
+}
+
+// chunks returns the store deduplicated checkpoints are kept in
+func (m *Manager) chunks() *chunkstore.Store {
+	return chunkstore.New(filepath.Join(m.cfg.SnapshotsDir(), chunksDirName))
+}
+
+// chunkSnapshot replaces a checkpoint archive with chunks of its
+// uncompressed contents and a manifest, so that checkpoints of the same
+// puck share the data they have in common
+func (m *Manager) chunkSnapshot(ctx context.Context, s *store.Snapshot) error {
+	f, err := os.Open(s.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Compressed archives share little; their contents share a lot
+	rc, err := archive.DecompressStream(f)
+	if err != nil {
+		return fmt.Errorf("decompressing checkpoint: %w", err)
+	}
+	defer rc.Close()
+
+	manifest, _, err := m.chunks().Put(&progressReader{ctx: ctx, r: rc, puck: s.PuckName, phase: PhaseDedup})
+	if err != nil {
+		return fmt.Errorf("storing checkpoint chunks: %w", err)
+	}
+	path := strings.TrimSuffix(s.Path, ".tar.gz") + chunkManifestExt
+	if err := chunkstore.WriteManifest(path, manifest); err != nil {
+		return err
+	}
+
+	os.Remove(s.Path)
+	s.Path = path
+	return nil
+}
+
+// openSnapshot reads a snapshot's archive, putting a chunked one back
+// together as an uncompressed tarball
+func (m *Manager) openSnapshot(s *store.Snapshot) (io.ReadCloser, error) {
+	if !chunked(s) {
+		return os.Open(s.ArchivePath())
+	}
+	manifest, err := chunkstore.ReadManifest(s.ArchivePath())
+	if err != nil {
+		return nil, err
+	}
+	return m.chunks().Open(manifest), nil
+}
+
+// checkpointArchive returns the path of an archive podman can restore a
+// snapshot from, and a function that removes it if it was put together
+// from chunks for the restore
+func (m *Manager) checkpointArchive(s *store.Snapshot) (string, func(), error) {
+	if !chunked(s) {
+		return s.Path, func() {}, nil
+	}
+
+	rc, err := m.openSnapshot(s)
+	if err != nil {
+		return "", nil, err
+	}
+	defer rc.Close()
+
+	path := filepath.Join(filepath.Dir(s.Path), "."+s.Name+".restore.tar")
+	if err := writeFile(path, rc, 0600); err != nil {
+		os.Remove(path)
+		return "", nil, fmt.Errorf("assembling checkpoint from chunks: %w", err)
+	}
+	return path, func() { os.Remove(path) }, nil
+}
+
+// sweepChunks removes chunks that no chunked snapshot uses any more
+func (m *Manager) sweepChunks(ctx context.Context) error {
+	snapshots, err := m.store.ListAllSnapshots(ctx)
+	if err != nil {
+		return err
+	}
+
+	var keep []*chunkstore.Manifest
+	for _, s := range snapshots {
+		if !chunked(s) {
+			continue
+		}
+		manifest, err := chunkstore.ReadManifest(s.ArchivePath())
+		if err != nil {
+			// Sweeping without it could remove chunks it still needs
+			return err
+		}
+		keep = append(keep, manifest)
+	}
+
+	_, err = m.chunks().Sweep(keep, chunkSweepGrace)
+	return err
+}
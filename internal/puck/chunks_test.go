@@ -0,0 +1,155 @@
+package puck
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotDedup(t *testing.T) {
+	// Checkpoints are the same few megabytes with a different tail, so
+	// most of their chunks are shared
+	base := make([]byte, 4<<20)
+	rand.New(rand.NewSource(1)).Read(base)
+	payload := func(tail string) []byte {
+		return append(append([]byte{}, base...), tail...)
+	}
+
+	// setup returns a manager keeping checkpoints as chunks, with a puck
+	// whose checkpoints hold the payload for the snapshot being taken
+	setup := func(t *testing.T) (*Manager, *podman.MockClient, func()) {
+		mgr, mock, cleanup := setupTestManager(t)
+		mgr.cfg.SnapshotDedup = true
+		mock.CheckpointFunc = func(ctx context.Context, nameOrID string, opts podman.CheckpointOptions) error {
+			if err := os.MkdirAll(filepath.Dir(opts.ExportPath), 0755); err != nil {
+				return err
+			}
+			var buf bytes.Buffer
+			zw := gzip.NewWriter(&buf)
+			zw.Write(payload(filepath.Base(opts.ExportPath)))
+			zw.Close()
+			return os.WriteFile(opts.ExportPath, buf.Bytes(), 0644)
+		}
+
+		_, err := mgr.Create(context.Background(), CreateOptions{Name: "dedup-puck"})
+		require.NoError(t, err)
+		return mgr, mock, cleanup
+	}
+
+	snapshot := func(t *testing.T, mgr *Manager, name string) *store.Snapshot {
+		s, err := mgr.CreateSnapshot(context.Background(), SnapshotCreateOptions{PuckName: "dedup-puck", SnapshotName: name, LeaveRunning: leaveRunning})
+		require.NoError(t, err)
+		return s
+	}
+
+	chunkBytes := func(t *testing.T, mgr *Manager) int64 {
+		var total int64
+		filepath.WalkDir(filepath.Join(mgr.cfg.SnapshotsDir(), chunksDirName), func(path string, d os.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				info, _ := d.Info()
+				total += info.Size()
+			}
+			return nil
+		})
+		return total
+	}
+
+	t.Run("keeps checkpoints as shared chunks", func(t *testing.T) {
+		mgr, _, cleanup := setup(t)
+		defer cleanup()
+
+		first := snapshot(t, mgr, "first")
+		assert.True(t, chunked(first))
+		assert.FileExists(t, first.Path)
+		assert.NoFileExists(t, filepath.Join(filepath.Dir(first.Path), "first.tar.gz"))
+		once := chunkBytes(t, mgr)
+
+		second := snapshot(t, mgr, "second")
+		assert.True(t, chunked(second))
+		// Only the chunks around the differing tail are new
+		assert.Less(t, chunkBytes(t, mgr)-once, once/2)
+	})
+
+	t.Run("restores from the assembled archive", func(t *testing.T) {
+		mgr, mock, cleanup := setup(t)
+		defer cleanup()
+		snapshot(t, mgr, "first")
+
+		var restored []byte
+		mock.RestoreFunc = func(ctx context.Context, opts podman.RestoreOptions) (string, error) {
+			data, err := os.ReadFile(opts.ImportPath)
+			restored = data
+			return "restored-id", err
+		}
+		require.NoError(t, mgr.RestoreSnapshot(context.Background(), SnapshotRestoreOptions{PuckName: "dedup-puck", SnapshotName: "first"}))
+		assert.Equal(t, payload("first.tar.gz"), restored)
+
+		// The assembled archive is gone once restored
+		matches, _ := filepath.Glob(filepath.Join(mgr.cfg.SnapshotsDir(), "dedup-puck", "*.restore.tar"))
+		assert.Empty(t, matches)
+	})
+
+	t.Run("reads chunked checkpoints back", func(t *testing.T) {
+		mgr, _, cleanup := setup(t)
+		defer cleanup()
+		s := snapshot(t, mgr, "first")
+
+		rc, err := mgr.openSnapshot(s)
+		require.NoError(t, err)
+		defer rc.Close()
+		data, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		assert.Equal(t, payload("first.tar.gz"), data)
+	})
+
+	t.Run("sweeps chunks no snapshot uses", func(t *testing.T) {
+		mgr, _, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+		snapshot(t, mgr, "first")
+		second := snapshot(t, mgr, "second")
+
+		// Age the chunks past the sweep's grace period
+		old := time.Now().Add(-2 * chunkSweepGrace)
+		filepath.WalkDir(filepath.Join(mgr.cfg.SnapshotsDir(), chunksDirName), func(path string, d os.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				os.Chtimes(path, old, old)
+			}
+			return nil
+		})
+		before := chunkBytes(t, mgr)
+
+		require.NoError(t, mgr.DeleteSnapshot(ctx, "dedup-puck", "first"))
+		after := chunkBytes(t, mgr)
+		assert.Less(t, after, before)
+
+		// What the remaining snapshot uses is still there
+		rc, err := mgr.openSnapshot(second)
+		require.NoError(t, err)
+		defer rc.Close()
+		data, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		assert.Equal(t, payload("second.tar.gz"), data)
+	})
+
+	t.Run("check leaves the chunks alone", func(t *testing.T) {
+		mgr, _, cleanup := setup(t)
+		defer cleanup()
+		snapshot(t, mgr, "first")
+
+		report, err := mgr.Check(context.Background(), CheckOptions{})
+		require.NoError(t, err)
+		assert.Empty(t, report.Issues)
+	})
+}
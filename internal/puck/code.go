@@ -0,0 +1,66 @@
+package puck
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+)
+
+// sshdScript runs sshd in inetd mode on the exec session's stdin and
+// stdout, installing it first if the image lacks it. Anything else it
+// prints goes to stderr so the ssh stream stays clean.
+const sshdScript = `set -e
+sshd=$(command -v sshd || echo /usr/sbin/sshd)
+if [ ! -x "$sshd" ]; then
+	echo "puck: installing openssh-server" >&2
+	if command -v dnf >/dev/null; then dnf install -y openssh-server >&2
+	elif command -v apt-get >/dev/null; then apt-get update >&2 && apt-get install -y openssh-server >&2
+	elif command -v apk >/dev/null; then apk add openssh-server >&2
+	else echo "puck: sshd not found; install openssh-server in the puck" >&2; exit 1
+	fi
+	sshd=$(command -v sshd || echo /usr/sbin/sshd)
+fi
+mkdir -p /root/.ssh /run/sshd
+chmod 700 /root/.ssh
+touch /root/.ssh/authorized_keys
+grep -qxF "$PUCK_AUTHORIZED_KEY" /root/.ssh/authorized_keys || echo "$PUCK_AUTHORIZED_KEY" >> /root/.ssh/authorized_keys
+chmod 600 /root/.ssh/authorized_keys
+ssh-keygen -A >&2
+exec "$sshd" -i -e -o PermitRootLogin=prohibit-password -o PasswordAuthentication=no`
+
+// SSHProxy serves one ssh connection to a puck over stdin and stdout,
+// for use as an ssh ProxyCommand, by running sshd inside the puck in
+// inetd mode. authorizedKey is allowed to log in as root. The puck is
+// started if it isn't running.
+func (m *Manager) SSHProxy(ctx context.Context, name, authorizedKey string) error {
+	authorizedKey = strings.TrimSpace(authorizedKey)
+	if authorizedKey == "" || strings.ContainsAny(authorizedKey, "\r\n") {
+		return fmt.Errorf("invalid authorized key")
+	}
+
+	p, err := m.store.GetPuck(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	running, err := m.podman.IsRunning(ctx, p.ContainerID)
+	if err != nil {
+		return fmt.Errorf("checking container status: %w", err)
+	}
+	if !running {
+		if err := m.Start(ctx, name); err != nil {
+			return fmt.Errorf("starting puck: %w", err)
+		}
+	}
+
+	m.store.TouchPuck(ctx, name, time.Now())
+	return m.podman.Exec(ctx, p.ContainerID, podman.ExecOptions{
+		Cmd:         []string{"/bin/sh", "-c", sshdScript},
+		Interactive: true,
+		User:        "root",
+		Env:         []string{"PUCK_AUTHORIZED_KEY=" + authorizedKey},
+	})
+}
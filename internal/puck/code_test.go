@@ -0,0 +1,49 @@
+package puck
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSSHProxy(t *testing.T) {
+	t.Run("runs sshd on the session's stdio", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		p, err := mgr.Create(ctx, CreateOptions{Name: "dev"})
+		require.NoError(t, err)
+
+		var got podman.ExecOptions
+		mock.ExecFunc = func(ctx context.Context, containerID string, opts podman.ExecOptions) error {
+			assert.Equal(t, p.ContainerID, containerID)
+			got = opts
+			return nil
+		}
+		require.NoError(t, mgr.SSHProxy(ctx, "dev", "ssh-ed25519 AAAA puck\n"))
+		assert.True(t, got.Interactive)
+		assert.False(t, got.TTY)
+		assert.Nil(t, got.Output)
+		assert.Equal(t, []string{"PUCK_AUTHORIZED_KEY=ssh-ed25519 AAAA puck"}, got.Env)
+		assert.Contains(t, got.Cmd[len(got.Cmd)-1], "-i")
+	})
+
+	t.Run("rejects bad keys", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "dev"})
+		require.NoError(t, err)
+
+		mock.Reset()
+		for _, key := range []string{"", "ssh-ed25519 AAAA\nssh-rsa BBBB"} {
+			assert.Error(t, mgr.SSHProxy(ctx, "dev", key))
+		}
+		assert.Zero(t, mock.CallCount("Exec"))
+	})
+}
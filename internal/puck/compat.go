@@ -0,0 +1,163 @@
+package puck
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// recordHost notes the CRIU, kernel and Podman versions a checkpoint is
+// taken with, so restores can be checked against them. Whatever can't be
+// found out is left empty and not checked.
+func (m *Manager) recordHost(ctx context.Context, snapshot *store.Snapshot) {
+	// Under Podman Machine CRIU runs in the VM, out of reach
+	if !m.podman.IsMachine() {
+		snapshot.CRIUVersion = m.criuVersion(ctx)
+	}
+	if host, err := m.podman.HostInfo(ctx); err == nil {
+		snapshot.Kernel = host.Kernel
+		snapshot.PodmanVersion = host.PodmanVersion
+	}
+}
+
+// CRIUVersion returns the version of the CRIU checkpoints are taken with,
+// or "" if it isn't found or runs in a Podman machine, out of reach
+func (m *Manager) CRIUVersion(ctx context.Context) string {
+	if m.podman.IsMachine() {
+		return ""
+	}
+	return m.criuVersion(ctx)
+}
+
+// recordImage notes the digest of the image a checkpoint's container runs.
+// Restoring onto another image, e.g. after the puck's tag was pulled again,
+// gives the processes a root filesystem they weren't dumped with.
+func (m *Manager) recordImage(ctx context.Context, p *store.Puck, snapshot *store.Snapshot) {
+	if data, err := m.podman.InspectContainer(ctx, p.ContainerID); err == nil {
+		snapshot.ImageDigest = data.ImageDigest
+	}
+}
+
+// imageDigestRef returns the reference to a snapshot's image by its
+// recorded digest, e.g. docker.io/library/fedora@sha256:..., or "" if no
+// digest was recorded
+func imageDigestRef(snapshot *store.Snapshot) string {
+	if snapshot.ImageDigest == "" || snapshot.Image == "" {
+		return ""
+	}
+	repo, _, _ := strings.Cut(snapshot.Image, "@")
+	if i := strings.LastIndex(repo, ":"); i > strings.LastIndex(repo, "/") {
+		repo = repo[:i]
+	}
+	return repo + "@" + snapshot.ImageDigest
+}
+
+// repullImage pulls a checkpoint's image by its recorded digest if this
+// host no longer has it
+func (m *Manager) repullImage(ctx context.Context, snapshot *store.Snapshot) error {
+	ref := imageDigestRef(snapshot)
+	if snapshot.Mode != store.SnapshotModeCheckpoint || ref == "" {
+		return nil
+	}
+	if exists, err := m.podman.ImageExists(ctx, ref); err == nil && exists {
+		return nil
+	}
+	if err := m.podman.PullImage(ctx, ref); err != nil {
+		return fmt.Errorf("fetching the image snapshot '%s' was taken from: %w", snapshot.Name, err)
+	}
+	return nil
+}
+
+// restoreProblems lists reasons a checkpoint may not restore on this host.
+// Restores need CRIU at least as new as the one that dumped, a kernel at
+// least as new, the same major version of Podman, and the image the
+// checkpoint's container ran. Image snapshots start fresh processes and
+// have no such requirements.
+func (m *Manager) restoreProblems(ctx context.Context, snapshot *store.Snapshot) []string {
+	if snapshot.Mode != store.SnapshotModeCheckpoint {
+		return nil
+	}
+
+	var problems []string
+	if !m.podman.IsMachine() {
+		criu := m.criuVersion(ctx)
+		switch {
+		case criu == "":
+			problems = append(problems, "CRIU was not found on this host")
+		case snapshot.CRIUVersion != "" && compareVersions(criu, snapshot.CRIUVersion) < 0:
+			problems = append(problems, fmt.Sprintf("checkpoint was taken with CRIU %s, this host has %s", snapshot.CRIUVersion, criu))
+		}
+	}
+
+	if ref := imageDigestRef(snapshot); ref != "" {
+		if exists, err := m.podman.ImageExists(ctx, ref); err == nil && !exists {
+			problems = append(problems, fmt.Sprintf("image %s the checkpoint was taken from is not on this host; --repull fetches it", ref))
+		}
+	}
+
+	host, err := m.podman.HostInfo(ctx)
+	if err != nil {
+		return problems
+	}
+	if snapshot.Kernel != "" && host.Kernel != "" && compareVersions(host.Kernel, snapshot.Kernel) < 0 {
+		problems = append(problems, fmt.Sprintf("checkpoint was taken on kernel %s, this host runs %s", snapshot.Kernel, host.Kernel))
+	}
+	if snapshot.PodmanVersion != "" && host.PodmanVersion != "" && majorVersion(host.PodmanVersion) != majorVersion(snapshot.PodmanVersion) {
+		problems = append(problems, fmt.Sprintf("checkpoint was taken with Podman %s, this host has %s", snapshot.PodmanVersion, host.PodmanVersion))
+	}
+	return problems
+}
+
+// versionParts reads the leading dotted numbers of a version, e.g.
+// [6 8 0] from "6.8.0-45-generic"
+func versionParts(v string) []int {
+	var parts []int
+	for _, field := range strings.Split(v, ".") {
+		end := 0
+		for end < len(field) && field[end] >= '0' && field[end] <= '9' {
+			end++
+		}
+		if end == 0 {
+			break
+		}
+		n, _ := strconv.Atoi(field[:end])
+		parts = append(parts, n)
+		if end < len(field) {
+			break
+		}
+	}
+	return parts
+}
+
+// compareVersions compares the numeric parts of two versions, returning
+// -1, 0 or 1
+func compareVersions(a, b string) int {
+	pa, pb := versionParts(a), versionParts(b)
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// majorVersion returns the first number of a version, or -1 if it has none
+func majorVersion(v string) int {
+	if parts := versionParts(v); len(parts) > 0 {
+		return parts[0]
+	}
+	return -1
+}
@@ -0,0 +1,170 @@
+package puck
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/containers/podman/v5/libpod/define"
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRestoreCompatibility(t *testing.T) {
+	// setup returns a manager with a checkpoint of compat-puck taken on the
+	// mock's default host
+	setup := func(t *testing.T) (*Manager, *podman.MockClient, func()) {
+		mgr, mock, cleanup := setupTestManager(t)
+		mock.IsRunningFunc = func(ctx context.Context, nameOrID string) (bool, error) {
+			return true, nil
+		}
+		mock.InspectContainerFunc = func(ctx context.Context, nameOrID string) (*define.InspectContainerData, error) {
+			return &define.InspectContainerData{ImageDigest: "sha256:4f2c"}, nil
+		}
+
+		ctx := context.Background()
+		_, err := mgr.Create(ctx, CreateOptions{Name: "compat-puck", Image: "quay.io/fedora/fedora:41"})
+		require.NoError(t, err)
+		_, err = mgr.CreateSnapshot(ctx, SnapshotCreateOptions{PuckName: "compat-puck", SnapshotName: "snap", LeaveRunning: leaveRunning})
+		require.NoError(t, err)
+		return mgr, mock, cleanup
+	}
+
+	t.Run("records the host", func(t *testing.T) {
+		mgr, _, cleanup := setup(t)
+		defer cleanup()
+
+		snapshots, err := mgr.ListSnapshots(context.Background(), "compat-puck")
+		require.NoError(t, err)
+		require.Len(t, snapshots, 1)
+		assert.Equal(t, "3.19", snapshots[0].CRIUVersion)
+		assert.Equal(t, "6.8.0", snapshots[0].Kernel)
+		assert.Equal(t, "5.3.0", snapshots[0].PodmanVersion)
+		assert.Equal(t, "sha256:4f2c", snapshots[0].ImageDigest)
+	})
+
+	t.Run("restores on the same host", func(t *testing.T) {
+		mgr, _, cleanup := setup(t)
+		defer cleanup()
+
+		require.NoError(t, mgr.RestoreSnapshot(context.Background(), SnapshotRestoreOptions{PuckName: "compat-puck", SnapshotName: "snap"}))
+	})
+
+	t.Run("refuses before removing the container", func(t *testing.T) {
+		mgr, mock, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		mgr.criuVersion = func(ctx context.Context) string { return "3.17" }
+		mock.HostInfoFunc = func(ctx context.Context) (*podman.HostInfo, error) {
+			return &podman.HostInfo{PodmanVersion: "4.9.3", Kernel: "6.8.0-45-generic", Arch: "amd64"}, nil
+		}
+		mock.Reset()
+
+		err := mgr.RestoreSnapshot(ctx, SnapshotRestoreOptions{PuckName: "compat-puck", SnapshotName: "snap"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "CRIU 3.19")
+		assert.Contains(t, err.Error(), "Podman 5.3.0")
+		assert.NotContains(t, err.Error(), "kernel")
+		assert.Contains(t, err.Error(), "--force")
+		assert.False(t, mock.WasCalled("StopContainer"))
+		assert.False(t, mock.WasCalled("RemoveContainer"))
+		assert.False(t, mock.WasCalled("Restore"))
+
+		require.NoError(t, mgr.RestoreSnapshot(ctx, SnapshotRestoreOptions{PuckName: "compat-puck", SnapshotName: "snap", Force: true}))
+		assert.True(t, mock.WasCalled("Restore"))
+
+		events, err := mgr.History(ctx, "compat-puck", time.Time{})
+		require.NoError(t, err)
+		var detail string
+		for _, e := range events {
+			if e.Type == store.EventSnapshotRestored {
+				detail = e.Detail
+			}
+		}
+		assert.Contains(t, detail, "forced")
+	})
+
+	t.Run("refuses without the checkpoint's image, or pulls it", func(t *testing.T) {
+		mgr, mock, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		pulled := map[string]bool{}
+		mock.ImageExistsFunc = func(ctx context.Context, nameOrID string) (bool, error) {
+			return pulled[nameOrID], nil
+		}
+		mock.PullImageFunc = func(ctx context.Context, imageName string) error {
+			pulled[imageName] = true
+			return nil
+		}
+
+		err := mgr.RestoreSnapshot(ctx, SnapshotRestoreOptions{PuckName: "compat-puck", SnapshotName: "snap"})
+		assert.ErrorContains(t, err, "image quay.io/fedora/fedora@sha256:4f2c")
+		assert.ErrorContains(t, err, "--repull")
+		assert.False(t, mock.WasCalled("Restore"))
+
+		require.NoError(t, mgr.RestoreSnapshot(ctx, SnapshotRestoreOptions{PuckName: "compat-puck", SnapshotName: "snap", Repull: true}))
+		assert.True(t, mock.WasCalledWith("PullImage", "quay.io/fedora/fedora@sha256:4f2c"))
+		assert.True(t, mock.WasCalled("Restore"))
+	})
+
+	t.Run("refuses without CRIU", func(t *testing.T) {
+		mgr, _, cleanup := setup(t)
+		defer cleanup()
+
+		mgr.criuVersion = func(ctx context.Context) string { return "" }
+		err := mgr.RestoreSnapshot(context.Background(), SnapshotRestoreOptions{PuckName: "compat-puck", SnapshotName: "snap"})
+		assert.ErrorContains(t, err, "CRIU was not found")
+	})
+
+	t.Run("refuses an older kernel", func(t *testing.T) {
+		mgr, mock, cleanup := setup(t)
+		defer cleanup()
+
+		mock.HostInfoFunc = func(ctx context.Context) (*podman.HostInfo, error) {
+			return &podman.HostInfo{PodmanVersion: "5.0.1", Kernel: "5.15.0", Arch: "amd64"}, nil
+		}
+		err := mgr.RestoreSnapshot(context.Background(), SnapshotRestoreOptions{PuckName: "compat-puck", SnapshotName: "snap"})
+		assert.ErrorContains(t, err, "kernel 6.8.0")
+		assert.NotContains(t, err.Error(), "Podman")
+	})
+}
+
+func TestImageDigestRef(t *testing.T) {
+	tests := []struct {
+		image, digest, want string
+	}{
+		{"fedora:41", "sha256:4f2c", "fedora@sha256:4f2c"},
+		{"localhost:5000/app", "sha256:4f2c", "localhost:5000/app@sha256:4f2c"},
+		{"localhost:5000/app:v2", "sha256:4f2c", "localhost:5000/app@sha256:4f2c"},
+		{"docker.io/library/alpine@sha256:0000", "sha256:4f2c", "docker.io/library/alpine@sha256:4f2c"},
+		{"fedora:41", "", ""},
+	}
+	for _, tt := range tests {
+		got := imageDigestRef(&store.Snapshot{Image: tt.image, ImageDigest: tt.digest})
+		assert.Equal(t, tt.want, got, tt.image)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"3.19", "3.19", 0},
+		{"3.19", "3.17.1", 1},
+		{"3.9", "3.19", -1},
+		{"6.8.0-45-generic", "6.8", 0},
+		{"6.1.0", "6.8.0-45-generic", -1},
+		{"5.3.0-dev", "5.2.5", 1},
+		{"", "1.0", -1},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, compareVersions(tt.a, tt.b), "%s vs %s", tt.a, tt.b)
+	}
+	assert.Equal(t, 5, majorVersion("5.3.0"))
+	assert.Equal(t, -1, majorVersion("unknown"))
+}
@@ -0,0 +1,25 @@
+package puck
+
+import "context"
+
+// compensation collects the steps that undo an operation's changes outside
+// the database, such as containers and directories, so a failure partway
+// through doesn't leave them behind
+type compensation struct {
+	steps []func(ctx context.Context)
+}
+
+// add registers a step to undo the change just made
+func (c *compensation) add(step func(ctx context.Context)) {
+	c.steps = append(c.steps, step)
+}
+
+// run undoes the changes in reverse order. It runs to completion even if
+// ctx was canceled, which is often why the operation failed.
+func (c *compensation) run(ctx context.Context) {
+	ctx = context.WithoutCancel(ctx)
+	for i := len(c.steps) - 1; i >= 0; i-- {
+		c.steps[i](ctx)
+	}
+	c.steps = nil
+}
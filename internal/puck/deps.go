@@ -0,0 +1,134 @@
+package puck
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// checkRequires validates the pucks a puck declares it requires and
+// returns them without duplicates
+func (m *Manager) checkRequires(ctx context.Context, name string, requires []string) ([]string, error) {
+	var clean []string
+	for _, req := range requires {
+		if req == name {
+			return nil, fmt.Errorf("puck '%s' can't require itself", name)
+		}
+		if slices.Contains(clean, req) {
+			continue
+		}
+		if _, err := m.store.GetPuck(ctx, req); err != nil {
+			return nil, fmt.Errorf("required puck '%s' not found", req)
+		}
+		clean = append(clean, req)
+	}
+	return clean, nil
+}
+
+// RequirementsToStart returns the pucks that must be started, in order,
+// before a puck requiring requires can start: requirements of
+// requirements first. Pucks that are already up are left out.
+func (m *Manager) RequirementsToStart(ctx context.Context, requires []string) ([]string, error) {
+	pucks, err := m.requirementOrder(ctx, requires)
+	if err != nil {
+		return nil, err
+	}
+	var order []string
+	for _, p := range pucks {
+		if !p.Status.Up() {
+			order = append(order, p.Name)
+		}
+	}
+	return order, nil
+}
+
+// requirementOrder returns names and everything they require, in start
+// order: requirements of requirements first
+func (m *Manager) requirementOrder(ctx context.Context, names []string) ([]*store.Puck, error) {
+	var order []*store.Puck
+	done := make(map[string]bool)
+	var visit func(n string, path []string) error
+	visit = func(n string, path []string) error {
+		if slices.Contains(path, n) {
+			return fmt.Errorf("pucks require each other: %s", strings.Join(append(path, n), " -> "))
+		}
+		if done[n] {
+			return nil
+		}
+		p, err := m.store.GetPuck(ctx, n)
+		if err != nil {
+			return fmt.Errorf("required puck '%s' not found", n)
+		}
+		for _, req := range p.Requires {
+			if err := visit(req, append(path, n)); err != nil {
+				return err
+			}
+		}
+		done[n] = true
+		order = append(order, p)
+		return nil
+	}
+
+	for _, n := range names {
+		if err := visit(n, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// DependentsToStop returns the pucks that must be stopped, in order,
+// before name so nothing is left running without a puck it requires:
+// dependents of dependents first. Pucks that aren't up are left out.
+func (m *Manager) DependentsToStop(ctx context.Context, name string) ([]string, error) {
+	pucks, err := m.store.ListPucks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dependents := make(map[string][]*store.Puck)
+	for _, p := range pucks {
+		for _, req := range p.Requires {
+			dependents[req] = append(dependents[req], p)
+		}
+	}
+
+	var order []string
+	done := map[string]bool{name: true}
+	var visit func(n string)
+	visit = func(n string) {
+		for _, d := range dependents[n] {
+			if done[d.Name] {
+				continue
+			}
+			done[d.Name] = true
+			visit(d.Name)
+			if d.Status.Up() {
+				order = append(order, d.Name)
+			}
+		}
+	}
+	visit(name)
+	return order, nil
+}
+
+// dropRequirement removes name from the requirements of pucks that
+// declare it
+func dropRequirement(ctx context.Context, db *store.DB, name string) error {
+	pucks, err := db.ListPucks(ctx)
+	if err != nil {
+		return err
+	}
+	for _, p := range pucks {
+		if !slices.Contains(p.Requires, name) {
+			continue
+		}
+		requires := slices.DeleteFunc(slices.Clone(p.Requires), func(req string) bool { return req == name })
+		if err := db.UpdatePuckRequires(ctx, p.Name, requires); err != nil {
+			return err
+		}
+	}
+	return nil
+}
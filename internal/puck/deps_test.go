@@ -0,0 +1,108 @@
+package puck
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequires(t *testing.T) {
+	// db <- api <- web, and cache <- api
+	setup := func(t *testing.T) (*Manager, func()) {
+		mgr, _, cleanup := setupTestManager(t)
+		ctx := context.Background()
+		for _, opts := range []CreateOptions{
+			{Name: "db"},
+			{Name: "cache"},
+			{Name: "api", Requires: []string{"db", "cache", "db"}},
+			{Name: "web", Requires: []string{"api"}},
+		} {
+			_, err := mgr.Create(ctx, opts)
+			require.NoError(t, err)
+			require.NoError(t, mgr.MarkReady(ctx, opts.Name))
+		}
+		return mgr, cleanup
+	}
+
+	t.Run("records requirements without duplicates", func(t *testing.T) {
+		mgr, cleanup := setup(t)
+		defer cleanup()
+
+		p, err := mgr.Get(context.Background(), "api")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"db", "cache"}, p.Requires)
+	})
+
+	t.Run("rejects unknown and self requirements", func(t *testing.T) {
+		mgr, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "worker", Requires: []string{"queue"}})
+		assert.ErrorContains(t, err, "not found")
+		_, err = mgr.Create(ctx, CreateOptions{Name: "worker", Requires: []string{"worker"}})
+		assert.ErrorContains(t, err, "itself")
+	})
+
+	t.Run("starts requirements first", func(t *testing.T) {
+		mgr, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		for _, name := range []string{"db", "cache", "api"} {
+			require.NoError(t, mgr.store.UpdatePuckStatus(ctx, name, store.StatusStopped))
+		}
+		order, err := mgr.RequirementsToStart(ctx, []string{"api"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"db", "cache", "api"}, order)
+
+		// Running requirements are left alone
+		require.NoError(t, mgr.store.UpdatePuckStatus(ctx, "cache", store.StatusRunning))
+		order, err = mgr.RequirementsToStart(ctx, []string{"api"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"db", "api"}, order)
+	})
+
+	t.Run("stops dependents first", func(t *testing.T) {
+		mgr, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		order, err := mgr.DependentsToStop(ctx, "db")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"web", "api"}, order)
+
+		require.NoError(t, mgr.store.UpdatePuckStatus(ctx, "web", store.StatusStopped))
+		order, err = mgr.DependentsToStop(ctx, "db")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"api"}, order)
+
+		order, err = mgr.DependentsToStop(ctx, "web")
+		require.NoError(t, err)
+		assert.Empty(t, order)
+	})
+
+	t.Run("reports cycles", func(t *testing.T) {
+		mgr, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		require.NoError(t, mgr.store.UpdatePuckRequires(ctx, "db", []string{"web"}))
+		_, err := mgr.RequirementsToStart(ctx, []string{"api"})
+		assert.ErrorContains(t, err, "require each other")
+	})
+
+	t.Run("destroy drops the puck from requirements", func(t *testing.T) {
+		mgr, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		require.NoError(t, mgr.Destroy(ctx, "db", true))
+		p, err := mgr.Get(ctx, "api")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"cache"}, p.Requires)
+	})
+}
@@ -0,0 +1,75 @@
+package puck
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// DestroyRisks returns why destroying a puck might lose work: it was used
+// within the last destroy_guard minutes, it has run since its latest
+// snapshot so its changes may not be saved, or other pucks require it.
+// With destroy_guard zero there are none.
+func (m *Manager) DestroyRisks(ctx context.Context, p *store.Puck) ([]string, error) {
+	if m.cfg.DestroyGuard <= 0 {
+		return nil, nil
+	}
+
+	var risks []string
+	window := time.Duration(m.cfg.DestroyGuard) * time.Minute
+	if !p.LastUsedAt.IsZero() && time.Since(p.LastUsedAt) < window {
+		risks = append(risks, fmt.Sprintf("it was used in the last %d minutes", m.cfg.DestroyGuard))
+	}
+
+	events, err := m.store.ListEvents(ctx, p.Name, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	var started time.Time
+	for _, e := range events {
+		if running, ok := e.Type.RunningAfter(); ok && running {
+			started = e.CreatedAt
+		}
+	}
+	if !started.IsZero() {
+		snapshots, err := m.store.ListSnapshots(ctx, p.ID)
+		if err != nil {
+			return nil, err
+		}
+		// Newest first
+		if len(snapshots) == 0 || snapshots[0].CreatedAt.Before(started) {
+			risks = append(risks, "it has run since its last snapshot, so its changes may not be saved")
+		}
+	}
+
+	pucks, err := m.store.ListPucks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var dependents []string
+	for _, other := range pucks {
+		if slices.Contains(other.Requires, p.Name) {
+			dependents = append(dependents, other.Name)
+		}
+	}
+	if len(dependents) > 0 {
+		risks = append(risks, "it is required by "+strings.Join(dependents, ", "))
+	}
+	return risks, nil
+}
+
+// checkDestroy refuses to destroy a puck with DestroyRisks
+func (m *Manager) checkDestroy(ctx context.Context, p *store.Puck) error {
+	risks, err := m.DestroyRisks(ctx, p)
+	if err != nil {
+		return fmt.Errorf("checking whether puck '%s' is in use: %w", p.Name, err)
+	}
+	if len(risks) > 0 {
+		return fmt.Errorf("puck '%s' may still be in use: %s; pass --force to destroy it anyway", p.Name, strings.Join(risks, "; "))
+	}
+	return nil
+}
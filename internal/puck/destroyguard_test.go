@@ -0,0 +1,57 @@
+package puck
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDestroyRisks(t *testing.T) {
+	ctx := context.Background()
+	mgr, _, cleanup := setupTestManager(t)
+	defer cleanup()
+	mgr.cfg.DestroyGuard = 30
+
+	_, err := mgr.Create(ctx, CreateOptions{Name: "db"})
+	require.NoError(t, err)
+	_, err = mgr.Create(ctx, CreateOptions{Name: "api", Requires: []string{"db"}})
+	require.NoError(t, err)
+
+	t.Run("refuses a puck in use", func(t *testing.T) {
+		err := mgr.Destroy(ctx, "db", false)
+		assert.ErrorContains(t, err, "puck 'db' may still be in use: it was used in the last 30 minutes; "+
+			"it has run since its last snapshot, so its changes may not be saved; it is required by api; pass --force to destroy it anyway")
+		_, err = mgr.Get(ctx, "db")
+		assert.NoError(t, err, "the puck is kept")
+	})
+
+	t.Run("allows a puck left alone since its last snapshot", func(t *testing.T) {
+		require.NoError(t, mgr.store.TouchPuck(ctx, "api", time.Now().Add(-time.Hour)))
+		api, err := mgr.Get(ctx, "api")
+		require.NoError(t, err)
+		risks, err := mgr.DestroyRisks(ctx, api)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"it has run since its last snapshot, so its changes may not be saved"}, risks)
+
+		require.NoError(t, mgr.store.CreateSnapshot(ctx, &store.Snapshot{ID: "s1", PuckID: api.ID, PuckName: "api", Name: "saved", CreatedAt: time.Now().Add(time.Second)}))
+		assert.NoError(t, mgr.Destroy(ctx, "api", false))
+	})
+
+	t.Run("is off when destroy_guard is zero", func(t *testing.T) {
+		mgr.cfg.DestroyGuard = 0
+		p, err := mgr.Get(ctx, "db")
+		require.NoError(t, err)
+		risks, err := mgr.DestroyRisks(ctx, p)
+		require.NoError(t, err)
+		assert.Empty(t, risks)
+	})
+
+	t.Run("force skips the check", func(t *testing.T) {
+		mgr.cfg.DestroyGuard = 30
+		assert.NoError(t, mgr.Destroy(ctx, "db", true))
+	})
+}
@@ -0,0 +1,212 @@
+package puck
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/containers/storage/pkg/archive"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// SnapshotDiffOptions selects two snapshots of a puck to compare
+type SnapshotDiffOptions struct {
+	PuckName string `json:"puck_name"`
+	From     string `json:"from"` // name or tag
+	To       string `json:"to"`   // name or tag
+}
+
+// SnapshotDiff is what changed from one snapshot of a puck to another
+type SnapshotDiff struct {
+	From      *store.Snapshot `json:"from"`
+	To        *store.Snapshot `json:"to"`
+	Fields    []FieldChange   `json:"fields"`
+	SizeDelta int64           `json:"size_delta"` // bytes To's archives grew by
+	// Whether both snapshots hold the puck's volume directories, which
+	// Files compares
+	Volumes bool         `json:"volumes"`
+	Files   []FileChange `json:"files,omitempty"`
+}
+
+// FieldChange is a snapshot's metadata field that differs
+type FieldChange struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// Kinds of file change
+const (
+	FileAdded    = "added"
+	FileRemoved  = "removed"
+	FileModified = "modified"
+)
+
+// FileChange is a file in the puck's volumes that differs between two
+// snapshots
+type FileChange struct {
+	Path     string `json:"path"`
+	Change   string `json:"change"`
+	FromSize int64  `json:"from_size"`
+	ToSize   int64  `json:"to_size"`
+}
+
+// archiveEntry is what's compared of a file in a volume archive
+type archiveEntry struct {
+	size int64
+	mode int64
+	sum  string // sha256 of a regular file, or the target of a link
+}
+
+// DiffSnapshots compares two snapshots of a puck: their metadata, their
+// size, and the files in the puck's volumes when both hold them
+func (m *Manager) DiffSnapshots(ctx context.Context, opts SnapshotDiffOptions) (*SnapshotDiff, error) {
+	from, _, err := m.findSnapshot(ctx, opts.PuckName, opts.From)
+	if err != nil {
+		return nil, err
+	}
+	to, _, err := m.findSnapshot(ctx, opts.PuckName, opts.To)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &SnapshotDiff{
+		From:      from,
+		To:        to,
+		Fields:    snapshotFieldChanges(from, to),
+		SizeDelta: to.SizeBytes - from.SizeBytes,
+	}
+
+	fromVolumes, toVolumes := volumeArchivePath(from), volumeArchivePath(to)
+	if fromVolumes == "" || toVolumes == "" {
+		return diff, nil
+	}
+	diff.Volumes = true
+
+	before, err := readArchiveEntries(ctx, fromVolumes)
+	if err != nil {
+		return nil, fmt.Errorf("reading volumes of snapshot '%s': %w", from.Name, err)
+	}
+	after, err := readArchiveEntries(ctx, toVolumes)
+	if err != nil {
+		return nil, fmt.Errorf("reading volumes of snapshot '%s': %w", to.Name, err)
+	}
+	for name, a := range after {
+		b, ok := before[name]
+		switch {
+		case !ok:
+			diff.Files = append(diff.Files, FileChange{Path: name, Change: FileAdded, ToSize: a.size})
+		case a != b:
+			diff.Files = append(diff.Files, FileChange{Path: name, Change: FileModified, FromSize: b.size, ToSize: a.size})
+		}
+	}
+	for name, b := range before {
+		if _, ok := after[name]; !ok {
+			diff.Files = append(diff.Files, FileChange{Path: name, Change: FileRemoved, FromSize: b.size})
+		}
+	}
+	sort.Slice(diff.Files, func(i, j int) bool { return diff.Files[i].Path < diff.Files[j].Path })
+
+	return diff, nil
+}
+
+// snapshotFieldChanges lists the metadata fields two snapshots differ in
+func snapshotFieldChanges(from, to *store.Snapshot) []FieldChange {
+	fields := []struct {
+		name     string
+		from, to string
+	}{
+		{"mode", string(from.Mode), string(to.Mode)},
+		{"image", from.Image, to.Image},
+		{"image digest", from.ImageDigest, to.ImageDigest},
+		{"CRIU version", from.CRIUVersion, to.CRIUVersion},
+		{"kernel", from.Kernel, to.Kernel},
+		{"podman", from.PodmanVersion, to.PodmanVersion},
+		{"CRIU options", criuOptionsField(from), criuOptionsField(to)},
+		{"tags", strings.Join(from.Tags, ", "), strings.Join(to.Tags, ", ")},
+	}
+
+	changes := []FieldChange{}
+	for _, f := range fields {
+		if f.from != f.to {
+			changes = append(changes, FieldChange{Field: f.name, From: f.from, To: f.to})
+		}
+	}
+	return changes
+}
+
+// criuOptionsField lists the CRIU options a checkpoint was taken with,
+// or "" for image snapshots
+func criuOptionsField(s *store.Snapshot) string {
+	if s.Mode != store.SnapshotModeCheckpoint {
+		return ""
+	}
+	var opts []string
+	if s.CRIU.TCPEstablished {
+		opts = append(opts, "tcp-established")
+	}
+	if s.CRIU.FileLocks {
+		opts = append(opts, "file-locks")
+	}
+	return strings.Join(opts, ", ")
+}
+
+// volumeArchivePath returns where a snapshot keeps the puck's volume
+// directories, or "" if it doesn't
+func volumeArchivePath(s *store.Snapshot) string {
+	if s.Mode == store.SnapshotModeImage {
+		return s.ArchivePath()
+	}
+	return s.VolumesPath
+}
+
+// readArchiveEntries reads the files in an archive written by archiveDir,
+// checksumming their contents
+func readArchiveEntries(ctx context.Context, archivePath string) (map[string]archiveEntry, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	rc, err := archive.DecompressStream(f)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	entries := make(map[string]archiveEntry)
+	tr := tar.NewReader(rc)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimPrefix(path.Clean(hdr.Name), "./")
+		if hdr.Typeflag == tar.TypeDir || name == "." {
+			continue
+		}
+
+		e := archiveEntry{size: hdr.Size, mode: hdr.Mode, sum: hdr.Linkname}
+		if hdr.Typeflag == tar.TypeReg {
+			sum := sha256.New()
+			if _, err := io.Copy(sum, tr); err != nil {
+				return nil, err
+			}
+			e.sum = hex.EncodeToString(sum.Sum(nil))
+		}
+		entries[name] = e
+	}
+}
@@ -0,0 +1,76 @@
+package puck
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("compares volume files of image snapshots", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+
+		p, err := mgr.Create(ctx, CreateOptions{Name: "diff-puck"})
+		require.NoError(t, err)
+		home := filepath.Join(p.VolumeDir, "home")
+		require.NoError(t, os.WriteFile(filepath.Join(home, "kept.txt"), []byte("same"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(home, "edited.txt"), []byte("before"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(home, "gone.txt"), []byte("bye"), 0644))
+		_, err = mgr.CreateSnapshot(ctx, SnapshotCreateOptions{PuckName: "diff-puck", SnapshotName: "v1", Mode: store.SnapshotModeImage})
+		require.NoError(t, err)
+
+		require.NoError(t, os.WriteFile(filepath.Join(home, "edited.txt"), []byte("after!!"), 0644))
+		require.NoError(t, os.Remove(filepath.Join(home, "gone.txt")))
+		require.NoError(t, os.WriteFile(filepath.Join(home, "new.txt"), []byte("hello"), 0644))
+		_, err = mgr.CreateSnapshot(ctx, SnapshotCreateOptions{PuckName: "diff-puck", SnapshotName: "v2", Mode: store.SnapshotModeImage})
+		require.NoError(t, err)
+		_, err = mgr.TagSnapshot(ctx, "diff-puck", "v2", "suspect")
+		require.NoError(t, err)
+
+		diff, err := mgr.DiffSnapshots(ctx, SnapshotDiffOptions{PuckName: "diff-puck", From: "v1", To: "suspect"})
+		require.NoError(t, err)
+		assert.Equal(t, "v2", diff.To.Name)
+		assert.True(t, diff.Volumes)
+		assert.Equal(t, []FileChange{
+			{Path: "home/edited.txt", Change: FileModified, FromSize: 6, ToSize: 7},
+			{Path: "home/gone.txt", Change: FileRemoved, FromSize: 3},
+			{Path: "home/new.txt", Change: FileAdded, ToSize: 5},
+		}, diff.Files)
+		assert.Equal(t, []FieldChange{{Field: "tags", From: "", To: "suspect"}}, diff.Fields)
+		assert.Equal(t, diff.To.SizeBytes-diff.From.SizeBytes, diff.SizeDelta)
+	})
+
+	t.Run("compares metadata of checkpoints without volumes", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		mock.CheckpointFunc = func(ctx context.Context, nameOrID string, opts podman.CheckpointOptions) error {
+			return writeTar(opts.ExportPath, map[string]string{"checkpoint/inventory.img": "inv"})
+		}
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "diff-puck"})
+		require.NoError(t, err)
+		_, err = mgr.CreateSnapshot(ctx, SnapshotCreateOptions{PuckName: "diff-puck", SnapshotName: "before", LeaveRunning: leaveRunning})
+		require.NoError(t, err)
+		fileLocks := true
+		_, err = mgr.CreateSnapshot(ctx, SnapshotCreateOptions{PuckName: "diff-puck", SnapshotName: "after", LeaveRunning: leaveRunning, CRIUFlags: CRIUFlags{FileLocks: &fileLocks}})
+		require.NoError(t, err)
+
+		diff, err := mgr.DiffSnapshots(ctx, SnapshotDiffOptions{PuckName: "diff-puck", From: "before", To: "after"})
+		require.NoError(t, err)
+		assert.False(t, diff.Volumes)
+		assert.Empty(t, diff.Files)
+		assert.Equal(t, []FieldChange{{Field: "CRIU options", From: "", To: "file-locks"}}, diff.Fields)
+
+		_, err = mgr.DiffSnapshots(ctx, SnapshotDiffOptions{PuckName: "diff-puck", From: "before", To: "missing"})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,260 @@
+package puck
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"github.com/containers/podman/v5/libpod/define"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// Tailscale's address ranges, which also hold MagicDNS at 100.100.100.100
+var tailnetCIDRs = []string{"100.64.0.0/10", "fd7a:115c:a1e0::/48"}
+
+// egressTable is the nftables table holding a puck's egress rules, inside
+// the puck's network namespace
+const egressTable = "puck_egress"
+
+// validateEgress checks an egress policy's mode and allowlist entries
+func validateEgress(e store.EgressPolicy) error {
+	switch e.EffectiveMode() {
+	case store.EgressAllowlist:
+		if len(e.Allow) == 0 {
+			return fmt.Errorf("an allowlist egress policy needs at least one CIDR or domain")
+		}
+	case store.EgressAll, store.EgressNone, store.EgressTailnet:
+		if len(e.Allow) > 0 {
+			return fmt.Errorf("only allowlist egress policies take CIDRs or domains")
+		}
+	default:
+		return fmt.Errorf("unknown egress mode %q; use all, none, tailnet, or allowlist", e.Mode)
+	}
+
+	for _, entry := range e.Allow {
+		if _, _, err := net.ParseCIDR(entry); err == nil {
+			continue
+		}
+		if net.ParseIP(entry) != nil {
+			continue
+		}
+		if !strings.Contains(entry, ".") || !hostnamePattern.MatchString(entry) {
+			return fmt.Errorf("invalid egress entry %q; use a CIDR, an IP address or a domain", entry)
+		}
+	}
+	return nil
+}
+
+// egressRules renders the nftables script that enforces a policy in a
+// puck's network namespace. Domains are resolved now, so a domain that
+// moves needs the policy applied again. An allowlist lets DNS through to
+// the container's resolvers alone, so port 53 can't reach anywhere else.
+func egressRules(ctx context.Context, e store.EgressPolicy, resolvers []string, lookup func(ctx context.Context, host string) ([]net.IP, error)) (string, error) {
+	var b strings.Builder
+	// Declaring the table first makes the delete safe when it's missing
+	fmt.Fprintf(&b, "table inet %s\ndelete table inet %s\n", egressTable, egressTable)
+	if e.EffectiveMode() == store.EgressAll {
+		return b.String(), nil
+	}
+
+	var v4, v6 []string
+	add := func(cidr string) {
+		ip, _, _ := net.ParseCIDR(cidr)
+		if ip.To4() != nil {
+			v4 = append(v4, cidr)
+		} else {
+			v6 = append(v6, cidr)
+		}
+	}
+	var dns4, dns6 []string
+	switch e.EffectiveMode() {
+	case store.EgressTailnet:
+		for _, cidr := range tailnetCIDRs {
+			add(cidr)
+		}
+	case store.EgressAllowlist:
+		for _, server := range resolvers {
+			if ip := net.ParseIP(server); ip.To4() != nil {
+				dns4 = append(dns4, ip.String())
+			} else if ip != nil {
+				dns6 = append(dns6, ip.String())
+			}
+		}
+		for _, entry := range e.Allow {
+			if _, _, err := net.ParseCIDR(entry); err == nil {
+				add(entry)
+				continue
+			}
+			ips := []net.IP{net.ParseIP(entry)}
+			if ips[0] == nil {
+				var err error
+				if ips, err = lookup(ctx, entry); err != nil {
+					return "", fmt.Errorf("resolving %s: %w", entry, err)
+				}
+			}
+			for _, ip := range ips {
+				if ip.To4() != nil {
+					add(ip.String() + "/32")
+				} else {
+					add(ip.String() + "/128")
+				}
+			}
+		}
+	}
+	slices.Sort(v4)
+	v4 = slices.Compact(v4)
+	slices.Sort(v6)
+	v6 = slices.Compact(v6)
+
+	fmt.Fprintf(&b, "table inet %s {\n", egressTable)
+	b.WriteString("\tchain output {\n")
+	b.WriteString("\t\ttype filter hook output priority filter; policy drop;\n")
+	b.WriteString("\t\toifname \"lo\" accept\n")
+	b.WriteString("\t\tct state established,related accept\n")
+	if len(dns4) > 0 {
+		fmt.Fprintf(&b, "\t\tip daddr { %s } meta l4proto { tcp, udp } th dport 53 accept\n", strings.Join(dns4, ", "))
+	}
+	if len(dns6) > 0 {
+		fmt.Fprintf(&b, "\t\tip6 daddr { %s } meta l4proto { tcp, udp } th dport 53 accept\n", strings.Join(dns6, ", "))
+	}
+	if len(v4) > 0 {
+		fmt.Fprintf(&b, "\t\tip daddr { %s } accept\n", strings.Join(v4, ", "))
+	}
+	if len(v6) > 0 {
+		fmt.Fprintf(&b, "\t\tip6 daddr { %s } accept\n", strings.Join(v6, ", "))
+	}
+	b.WriteString("\t}\n}\n")
+	return b.String(), nil
+}
+
+// enforceEgress puts a running container's egress rules in place. New
+// network namespaces start open, so pucks without a policy are skipped
+// unless unrestricting is set, when a running puck's rules are removed.
+func (m *Manager) enforceEgress(ctx context.Context, p *store.Puck, containerID string, unrestricting bool) error {
+	if p.Egress.EffectiveMode() == store.EgressAll && !unrestricting {
+		return nil
+	}
+
+	data, err := m.podman.InspectContainer(ctx, containerID)
+	if err != nil {
+		return err
+	}
+	if data.State == nil || data.State.Pid == 0 {
+		return fmt.Errorf("container is not running")
+	}
+	rules, err := egressRules(ctx, p.Egress, containerResolvers(p.Spec, data), m.lookupIP)
+	if err != nil {
+		return err
+	}
+	if err := m.firewall(ctx, data.State.Pid, rules); err != nil {
+		return fmt.Errorf("applying egress policy: %w", err)
+	}
+	return nil
+}
+
+// startContainer starts a puck's container and enforces its egress
+// policy, killing the container again if the policy can't be put in place
+func (m *Manager) startContainer(ctx context.Context, p *store.Puck, containerID string) error {
+	if err := m.podman.StartContainer(ctx, containerID); err != nil {
+		return err
+	}
+	if err := m.enforceEgress(ctx, p, containerID, false); err != nil {
+		m.podman.KillContainer(ctx, containerID, "SIGKILL")
+		return err
+	}
+	return nil
+}
+
+// SetEgress changes where a puck may open connections to, applying the
+// policy at once if the puck is running
+func (m *Manager) SetEgress(ctx context.Context, name string, egress store.EgressPolicy) (*store.Puck, error) {
+	if err := validateEgress(egress); err != nil {
+		return nil, err
+	}
+	if egress.EffectiveMode() == store.EgressAll {
+		egress = store.EgressPolicy{}
+	}
+
+	p, err := m.store.GetPuck(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if sandboxed(p) {
+		return nil, fmt.Errorf("puck '%s' is sandboxed and has no network", name)
+	}
+
+	// Apply before recording so a policy that can't be enforced, such as
+	// a domain that doesn't resolve, leaves the old one in force
+	if running, _ := m.podman.IsRunning(ctx, p.ContainerID); running {
+		updated := *p
+		updated.Egress = egress
+		if err := m.enforceEgress(ctx, &updated, p.ContainerID, true); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := m.store.UpdatePuckEgress(ctx, name, egress); err != nil {
+		return nil, err
+	}
+	m.record(ctx, name, store.EventEgressChanged, egress.String())
+	return m.store.GetPuck(ctx, name)
+}
+
+// netnsFirewall loads nftables rules into the network namespace of the
+// process pid
+func netnsFirewall(ctx context.Context, pid int, rules string) error {
+	cmd := nsenterCommand(ctx, pid, "nft", "-f", "-")
+	cmd.Stdin = strings.NewReader(rules)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// nsenterCommand runs args in the network namespace of the process pid.
+// Rootless containers' namespaces belong to podman's user namespace, so
+// the command is run from inside it.
+func nsenterCommand(ctx context.Context, pid int, args ...string) *exec.Cmd {
+	args = append([]string{"nsenter", "-t", fmt.Sprint(pid), "-n"}, args...)
+	if os.Geteuid() != 0 {
+		args = append([]string{"podman", "unshare"}, args...)
+	}
+	return exec.CommandContext(ctx, args[0], args[1:]...)
+}
+
+// containerResolvers returns the nameservers a running container uses:
+// those its spec gives, else those podman wrote to its resolv.conf, else
+// its networks' gateways, where podman's own DNS server answers
+func containerResolvers(spec store.Spec, data *define.InspectContainerData) []string {
+	if len(spec.DNS) > 0 {
+		return spec.DNS
+	}
+	var servers []string
+	if resolvConf, err := os.ReadFile(data.ResolvConfPath); err == nil {
+		for _, line := range strings.Split(string(resolvConf), "\n") {
+			if fields := strings.Fields(line); len(fields) >= 2 && fields[0] == "nameserver" {
+				servers = append(servers, fields[1])
+			}
+		}
+	}
+	if len(servers) > 0 || data.NetworkSettings == nil {
+		return servers
+	}
+	for _, network := range data.NetworkSettings.Networks {
+		for _, gateway := range []string{network.Gateway, network.IPv6Gateway} {
+			if gateway != "" {
+				servers = append(servers, gateway)
+			}
+		}
+	}
+	return servers
+}
+
+// lookupIP resolves a domain with the host's resolver
+func lookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	return net.DefaultResolver.LookupIP(ctx, "ip", host)
+}
@@ -0,0 +1,185 @@
+package puck
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/containers/podman/v5/libpod/define"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEgressRules(t *testing.T) {
+	lookup := func(ctx context.Context, host string) ([]net.IP, error) {
+		if host == "github.com" {
+			return []net.IP{net.ParseIP("140.82.112.3"), net.ParseIP("2606:50c0::1")}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	t.Run("all only clears rules", func(t *testing.T) {
+		rules, err := egressRules(context.Background(), store.EgressPolicy{}, nil, lookup)
+		require.NoError(t, err)
+		assert.Equal(t, "table inet puck_egress\ndelete table inet puck_egress\n", rules)
+	})
+
+	t.Run("none allows only replies and loopback", func(t *testing.T) {
+		rules, err := egressRules(context.Background(), store.EgressPolicy{Mode: store.EgressNone}, []string{"10.89.0.1"}, lookup)
+		require.NoError(t, err)
+		assert.Contains(t, rules, "policy drop;")
+		assert.Contains(t, rules, "ct state established,related accept")
+		assert.NotContains(t, rules, "daddr")
+		assert.NotContains(t, rules, "dport 53")
+	})
+
+	t.Run("tailnet allows tailscale ranges", func(t *testing.T) {
+		rules, err := egressRules(context.Background(), store.EgressPolicy{Mode: store.EgressTailnet}, []string{"10.89.0.1"}, lookup)
+		require.NoError(t, err)
+		assert.Contains(t, rules, "ip daddr { 100.64.0.0/10 } accept")
+		assert.Contains(t, rules, "ip6 daddr { fd7a:115c:a1e0::/48 } accept")
+	})
+
+	t.Run("allowlist resolves domains", func(t *testing.T) {
+		policy := store.EgressPolicy{Mode: store.EgressAllowlist, Allow: []string{"10.0.0.0/8", "192.168.1.5", "github.com"}}
+		rules, err := egressRules(context.Background(), policy, nil, lookup)
+		require.NoError(t, err)
+		assert.Contains(t, rules, "ip daddr { 10.0.0.0/8, 140.82.112.3/32, 192.168.1.5/32 } accept")
+		assert.Contains(t, rules, "ip6 daddr { 2606:50c0::1/128 } accept")
+
+		policy.Allow = []string{"nowhere.example"}
+		_, err = egressRules(context.Background(), policy, nil, lookup)
+		assert.ErrorContains(t, err, "nowhere.example")
+	})
+
+	t.Run("allowlist lets DNS through to the resolvers alone", func(t *testing.T) {
+		policy := store.EgressPolicy{Mode: store.EgressAllowlist, Allow: []string{"10.0.0.0/8"}}
+		rules, err := egressRules(context.Background(), policy, []string{"10.89.0.1", "fd00::1", "bogus"}, lookup)
+		require.NoError(t, err)
+		assert.Contains(t, rules, "ip daddr { 10.89.0.1 } meta l4proto { tcp, udp } th dport 53 accept")
+		assert.Contains(t, rules, "ip6 daddr { fd00::1 } meta l4proto { tcp, udp } th dport 53 accept")
+		for _, line := range strings.Split(rules, "\n") {
+			if strings.Contains(line, "dport 53") {
+				assert.Contains(t, line, "daddr", "DNS to any host")
+			}
+		}
+
+		rules, err = egressRules(context.Background(), policy, nil, lookup)
+		require.NoError(t, err)
+		assert.NotContains(t, rules, "dport 53", "no resolvers, no DNS")
+	})
+}
+
+func TestContainerResolvers(t *testing.T) {
+	resolvConf := filepath.Join(t.TempDir(), "resolv.conf")
+	require.NoError(t, os.WriteFile(resolvConf, []byte("search dns.podman\nnameserver 10.89.0.1\nnameserver fd00::1\noptions edns0\n"), 0644))
+	networks := &define.InspectNetworkSettings{Networks: map[string]*define.InspectAdditionalNetwork{
+		"podman": {InspectBasicNetworkConfig: define.InspectBasicNetworkConfig{Gateway: "10.88.0.1"}},
+	}}
+
+	t.Run("prefers the puck's own", func(t *testing.T) {
+		data := &define.InspectContainerData{ResolvConfPath: resolvConf}
+		assert.Equal(t, []string{"1.1.1.1"}, containerResolvers(store.Spec{DNS: []string{"1.1.1.1"}}, data))
+	})
+
+	t.Run("reads the container's resolv.conf", func(t *testing.T) {
+		data := &define.InspectContainerData{ResolvConfPath: resolvConf, NetworkSettings: networks}
+		assert.Equal(t, []string{"10.89.0.1", "fd00::1"}, containerResolvers(store.Spec{}, data))
+	})
+
+	t.Run("falls back to the gateways", func(t *testing.T) {
+		data := &define.InspectContainerData{ResolvConfPath: filepath.Join(t.TempDir(), "missing"), NetworkSettings: networks}
+		assert.Equal(t, []string{"10.88.0.1"}, containerResolvers(store.Spec{}, data))
+	})
+}
+
+func TestSetEgress(t *testing.T) {
+	setup := func(t *testing.T) (*Manager, *[]string, func()) {
+		mgr, mock, cleanup := setupTestManager(t)
+		mock.InspectContainerFunc = func(ctx context.Context, nameOrID string) (*define.InspectContainerData, error) {
+			return &define.InspectContainerData{State: &define.InspectContainerState{Running: true, Pid: 4242}}, nil
+		}
+		var loaded []string
+		mgr.firewall = func(ctx context.Context, pid int, rules string) error {
+			assert.Equal(t, 4242, pid)
+			loaded = append(loaded, rules)
+			return nil
+		}
+		return mgr, &loaded, cleanup
+	}
+
+	t.Run("applies to running pucks and records the policy", func(t *testing.T) {
+		mgr, loaded, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "web"})
+		require.NoError(t, err)
+		assert.Empty(t, *loaded, "unrestricted pucks need no rules")
+
+		p, err := mgr.SetEgress(ctx, "web", store.EgressPolicy{Mode: store.EgressNone})
+		require.NoError(t, err)
+		assert.Equal(t, store.EgressNone, p.Egress.Mode)
+		require.Len(t, *loaded, 1)
+		assert.Contains(t, (*loaded)[0], "policy drop")
+
+		// Lifting the policy removes the rules
+		p, err = mgr.SetEgress(ctx, "web", store.EgressPolicy{Mode: store.EgressAll})
+		require.NoError(t, err)
+		assert.Equal(t, store.EgressPolicy{}, p.Egress)
+		require.Len(t, *loaded, 2)
+		assert.NotContains(t, (*loaded)[1], "policy drop")
+	})
+
+	t.Run("enforces the policy on start", func(t *testing.T) {
+		mgr, loaded, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "web", Egress: store.EgressPolicy{Mode: store.EgressTailnet}})
+		require.NoError(t, err)
+		require.NoError(t, mgr.store.UpdatePuckStatus(ctx, "web", store.StatusStopped))
+		require.NoError(t, mgr.Start(ctx, "web"))
+		require.Len(t, *loaded, 2)
+		assert.Contains(t, (*loaded)[1], "100.64.0.0/10")
+	})
+
+	t.Run("kills the puck when the policy can't be enforced", func(t *testing.T) {
+		mgr, _, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		mgr.firewall = func(ctx context.Context, pid int, rules string) error { return errors.New("nft: not found") }
+		_, err := mgr.Create(ctx, CreateOptions{Name: "web", Egress: store.EgressPolicy{Mode: store.EgressNone}})
+		assert.ErrorContains(t, err, "nft: not found")
+		_, err = mgr.Get(ctx, "web")
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects bad policies", func(t *testing.T) {
+		mgr, loaded, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "web"})
+		require.NoError(t, err)
+		for _, policy := range []store.EgressPolicy{
+			{Mode: "some"},
+			{Mode: store.EgressAllowlist},
+			{Mode: store.EgressNone, Allow: []string{"10.0.0.0/8"}},
+			{Mode: store.EgressAllowlist, Allow: []string{"not a host"}},
+		} {
+			_, err := mgr.SetEgress(ctx, "web", policy)
+			assert.Error(t, err, policy)
+		}
+		assert.Empty(t, *loaded)
+
+		_, err = mgr.Create(ctx, CreateOptions{Name: "box", Sandbox: store.SandboxStrict, Egress: store.EgressPolicy{Mode: store.EgressNone}})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,160 @@
+package puck
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// endpointNamePattern matches endpoint names, which become a segment of
+// the router path /<puck>/<name>
+var endpointNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// EndpointSpec names a container port to serve through the router
+type EndpointSpec struct {
+	Name string `json:"name"`
+	Port int    `json:"port"`
+}
+
+// ParseEndpoint parses an endpoint given as name:port, e.g. api:8000
+func ParseEndpoint(s string) (EndpointSpec, error) {
+	name, port, ok := strings.Cut(s, ":")
+	if !ok {
+		return EndpointSpec{}, fmt.Errorf("invalid endpoint %q; use name:port, e.g. api:8000", s)
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		return EndpointSpec{}, fmt.Errorf("invalid endpoint %q; the port must be a number", s)
+	}
+	spec := EndpointSpec{Name: name, Port: n}
+	return spec, spec.validate()
+}
+
+func (e EndpointSpec) validate() error {
+	if !endpointNamePattern.MatchString(e.Name) {
+		return fmt.Errorf("invalid endpoint name %q", e.Name)
+	}
+	if e.Port < 1 || e.Port > 65535 {
+		return fmt.Errorf("endpoint port must be between 1 and 65535, got %d", e.Port)
+	}
+	return nil
+}
+
+// validateEndpoints checks a new puck's endpoints, which can't share a name
+func validateEndpoints(specs []EndpointSpec) error {
+	seen := make(map[string]bool)
+	for _, e := range specs {
+		if err := e.validate(); err != nil {
+			return err
+		}
+		if seen[e.Name] {
+			return fmt.Errorf("endpoint '%s' is given twice", e.Name)
+		}
+		seen[e.Name] = true
+	}
+	return nil
+}
+
+// Endpoints returns a puck's endpoints
+func (m *Manager) Endpoints(ctx context.Context, name string) ([]*store.Endpoint, error) {
+	if _, err := m.store.GetPuck(ctx, name); err != nil {
+		return nil, err
+	}
+	return m.store.ListEndpoints(ctx, name)
+}
+
+// addEndpoint gives an endpoint of p a host port and stores it
+func (m *Manager) addEndpoint(ctx context.Context, p *store.Puck, spec EndpointSpec) (*store.Endpoint, error) {
+	name := p.Name
+	e := &store.Endpoint{PuckName: name, Name: spec.Name, ContainerPort: spec.Port, CreatedAt: time.Now()}
+
+	// Nothing is published when routing to container IPs
+	if !m.cfg.RoutesToContainerIP() {
+		port, err := m.allocatePort(ctx, name, p.HostPort)
+		if err != nil {
+			return nil, fmt.Errorf("finding available port: %w", err)
+		}
+		e.HostPort = port
+	}
+	if err := m.store.CreateEndpoint(ctx, e); err != nil {
+		if e.HostPort > 0 {
+			m.store.ReleasePort(ctx, e.HostPort, name)
+		}
+		return nil, err
+	}
+	return e, nil
+}
+
+// AddEndpoint serves another of a puck's container ports through the
+// router at /<puck>/<endpoint>. The puck's container is recreated to
+// publish it, so the puck can't be running.
+func (m *Manager) AddEndpoint(ctx context.Context, name string, spec EndpointSpec) (*store.Endpoint, error) {
+	if err := spec.validate(); err != nil {
+		return nil, err
+	}
+	p, err := m.endpointPuck(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := m.addEndpoint(ctx, p, spec)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.republish(ctx, p); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// RemoveEndpoint stops serving one of a puck's endpoints and gives up its
+// host port. Like AddEndpoint, the puck can't be running.
+func (m *Manager) RemoveEndpoint(ctx context.Context, name, endpoint string) error {
+	p, err := m.endpointPuck(ctx, name)
+	if err != nil {
+		return err
+	}
+	endpoints, err := m.store.ListEndpoints(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if err := m.store.DeleteEndpoint(ctx, name, endpoint); err != nil {
+		return err
+	}
+	for _, e := range endpoints {
+		if e.Name == endpoint && e.HostPort > 0 {
+			m.store.ReleasePort(ctx, e.HostPort, name)
+		}
+	}
+	return m.republish(ctx, p)
+}
+
+// endpointPuck gets a puck whose endpoints are about to change
+func (m *Manager) endpointPuck(ctx context.Context, name string) (*store.Puck, error) {
+	p, err := m.store.GetPuck(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if sandboxed(p) {
+		return nil, fmt.Errorf("sandboxed pucks have no network to serve endpoints on")
+	}
+	if p.Status.Up() {
+		return nil, fmt.Errorf("puck '%s' is running; stop it to change its endpoints", name)
+	}
+	return p, nil
+}
+
+// republish recreates a stopped puck's container so it publishes the
+// puck's current ports. Checkpointed pucks publish them when restored.
+func (m *Manager) republish(ctx context.Context, p *store.Puck) error {
+	if p.Status != store.StatusStopped {
+		return nil
+	}
+	return m.replaceContainer(ctx, p)
+}
@@ -0,0 +1,104 @@
+package puck
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEndpoint(t *testing.T) {
+	e, err := ParseEndpoint("api:8000")
+	require.NoError(t, err)
+	assert.Equal(t, EndpointSpec{Name: "api", Port: 8000}, e)
+
+	for _, s := range []string{"api", "api:x", "api:0", ":8000", "a/b:8000"} {
+		_, err := ParseEndpoint(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestEndpoints(t *testing.T) {
+	ctx := context.Background()
+
+	setup := func(t *testing.T) (*Manager, *[]podman.CreateContainerOptions, func()) {
+		mgr, mock, cleanup := setupTestManager(t)
+		busyPorts(mgr)
+		var created []podman.CreateContainerOptions
+		mock.CreateContainerFunc = func(ctx context.Context, opts podman.CreateContainerOptions) (string, error) {
+			created = append(created, opts)
+			return "container-" + opts.Name, nil
+		}
+		return mgr, &created, cleanup
+	}
+
+	t.Run("publishes endpoints given at create", func(t *testing.T) {
+		mgr, created, cleanup := setup(t)
+		defer cleanup()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "web", Endpoints: []EndpointSpec{{Name: "app", Port: 3000}, {Name: "api", Port: 8000}}})
+		require.NoError(t, err)
+
+		require.Len(t, *created, 1)
+		assert.Equal(t, []string{"9000:80", "9002:8000", "9001:3000"}, (*created)[0].Ports)
+
+		endpoints, err := mgr.Endpoints(ctx, "web")
+		require.NoError(t, err)
+		require.Len(t, endpoints, 2)
+		assert.Equal(t, "api", endpoints[0].Name)
+		assert.Equal(t, 9002, endpoints[0].HostPort)
+
+		// Endpoint ports are never given to another puck
+		other, err := mgr.Create(ctx, CreateOptions{Name: "other"})
+		require.NoError(t, err)
+		assert.Equal(t, 9003, other.HostPort)
+
+		_, err = mgr.Create(ctx, CreateOptions{Name: "twice", Endpoints: []EndpointSpec{{Name: "api", Port: 1}, {Name: "api", Port: 2}}})
+		assert.ErrorContains(t, err, "twice")
+	})
+
+	t.Run("adds and removes endpoints of a stopped puck", func(t *testing.T) {
+		mgr, created, cleanup := setup(t)
+		defer cleanup()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "web"})
+		require.NoError(t, err)
+		_, err = mgr.AddEndpoint(ctx, "web", EndpointSpec{Name: "api", Port: 8000})
+		assert.ErrorContains(t, err, "stop it")
+
+		require.NoError(t, mgr.Stop(ctx, "web"))
+		e, err := mgr.AddEndpoint(ctx, "web", EndpointSpec{Name: "api", Port: 8000})
+		require.NoError(t, err)
+		assert.Equal(t, BaseHostPort+1, e.HostPort)
+		require.Len(t, *created, 2)
+		assert.Equal(t, []string{"9000:80", "9001:8000"}, (*created)[1].Ports)
+
+		_, err = mgr.AddEndpoint(ctx, "web", EndpointSpec{Name: "api", Port: 8001})
+		assert.Error(t, err)
+
+		require.NoError(t, mgr.RemoveEndpoint(ctx, "web", "api"))
+		require.Len(t, *created, 3)
+		assert.Equal(t, []string{"9000:80"}, (*created)[2].Ports)
+		reservations, err := mgr.store.ListPortReservations(ctx)
+		require.NoError(t, err)
+		require.Len(t, reservations, 1)
+		assert.Equal(t, BaseHostPort, reservations[0].Port)
+
+		assert.Error(t, mgr.RemoveEndpoint(ctx, "web", "api"))
+	})
+
+	t.Run("removes endpoints with their puck", func(t *testing.T) {
+		mgr, _, cleanup := setup(t)
+		defer cleanup()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "web", Endpoints: []EndpointSpec{{Name: "api", Port: 8000}}})
+		require.NoError(t, err)
+		require.NoError(t, mgr.Destroy(ctx, "web", true))
+
+		endpoints, err := mgr.store.ListEndpoints(ctx, "")
+		require.NoError(t, err)
+		assert.Empty(t, endpoints)
+	})
+}
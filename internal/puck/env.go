@@ -0,0 +1,152 @@
+package puck
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// envFile is where a puck's environment is written in its etc volume, so
+// it is /etc/puck/environment inside, for systemd units to read with
+// EnvironmentFile=
+const envFile = "environment"
+
+// envKeyPattern matches environment variable names
+var envKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// EnvOptions changes a puck's environment variables
+type EnvOptions struct {
+	Name  string            `json:"name"`
+	Set   map[string]string `json:"set,omitempty"`
+	Unset []string          `json:"unset,omitempty"`
+	// Mark the variables set as secret, masked in output
+	Secret bool `json:"secret,omitempty"`
+}
+
+// validateEnv checks a variable can be set in a container and written to
+// the environment file
+func validateEnv(key, value string) error {
+	if !envKeyPattern.MatchString(key) {
+		return fmt.Errorf("invalid environment variable name %q", key)
+	}
+	if strings.ContainsAny(value, "\n\r\x00") {
+		return fmt.Errorf("environment variable %s can't span lines", key)
+	}
+	return nil
+}
+
+// SetEnv sets and unsets a puck's environment variables. The environment
+// file is rewritten now; the container gets them when it is next started,
+// as a new container, or recreated.
+func (m *Manager) SetEnv(ctx context.Context, opts EnvOptions) (*store.Puck, error) {
+	if len(opts.Set) == 0 && len(opts.Unset) == 0 {
+		return nil, fmt.Errorf("no environment variables to set or unset")
+	}
+	for key, value := range opts.Set {
+		if err := validateEnv(key, value); err != nil {
+			return nil, err
+		}
+	}
+
+	p, err := m.store.GetPuck(ctx, opts.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := p.Spec
+	spec.Env = maps.Clone(spec.Env)
+	if spec.Env == nil {
+		spec.Env = make(map[string]string)
+	}
+	spec.SecretEnv = slices.Clone(spec.SecretEnv)
+	for _, key := range opts.Unset {
+		if _, ok := spec.Env[key]; !ok {
+			return nil, fmt.Errorf("puck '%s' has no environment variable %s", p.Name, key)
+		}
+		delete(spec.Env, key)
+		spec.SecretEnv = slices.DeleteFunc(spec.SecretEnv, func(k string) bool { return k == key })
+	}
+	for key, value := range opts.Set {
+		spec.Env[key] = value
+		if opts.Secret && !slices.Contains(spec.SecretEnv, key) {
+			spec.SecretEnv = append(spec.SecretEnv, key)
+		}
+	}
+	if len(spec.Env) == 0 {
+		spec.Env = nil
+	}
+	slices.Sort(spec.SecretEnv)
+
+	updated := *p
+	updated.Spec = spec
+	if err := writeEnvFile(&updated); err != nil {
+		return nil, err
+	}
+	if err := m.store.UpdatePuckSpec(ctx, p.Name, spec); err != nil {
+		return nil, err
+	}
+	// The container's environment is fixed when it is created
+	if !p.Resources.Pending {
+		p.Resources.Pending = true
+		if err := m.store.UpdatePuckResources(ctx, p.Name, p.Resources); err != nil {
+			return nil, err
+		}
+	}
+
+	m.record(ctx, p.Name, store.EventEnvChanged, envChangeDetail(opts))
+	return m.store.GetPuck(ctx, p.Name)
+}
+
+// envChangeDetail names the variables changed, leaving out their values
+func envChangeDetail(opts EnvOptions) string {
+	var parts []string
+	if len(opts.Set) > 0 {
+		parts = append(parts, "set "+strings.Join(slices.Sorted(maps.Keys(opts.Set)), ", "))
+	}
+	if len(opts.Unset) > 0 {
+		parts = append(parts, "unset "+strings.Join(opts.Unset, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// envFilePath returns where a puck's environment file is on the host
+func envFilePath(p *store.Puck) string {
+	return filepath.Join(p.VolumeDir, "etc", envFile)
+}
+
+// writeEnvFile writes a puck's environment variables to its etc volume in
+// the form systemd's EnvironmentFile= reads, or removes the file when it
+// has none. Only the owner may read it, as values may be secrets.
+func writeEnvFile(p *store.Puck) error {
+	path := envFilePath(p)
+	if len(p.Spec.Env) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	var b strings.Builder
+	b.WriteString("# Written by puck; change with: puck env set|unset " + p.Name + "\n")
+	for _, key := range slices.Sorted(maps.Keys(p.Spec.Env)) {
+		fmt.Fprintf(&b, "%s=%s\n", key, quoteEnv(p.Spec.Env[key]))
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0600); err != nil {
+		return fmt.Errorf("writing environment file: %w", err)
+	}
+	return nil
+}
+
+// quoteEnv double-quotes a value for systemd, escaping what it would
+// otherwise interpret
+func quoteEnv(value string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`")
+	return `"` + r.Replace(value) + `"`
+}
@@ -0,0 +1,88 @@
+package puck
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetEnv(t *testing.T) {
+	mgr, mock, cleanup := setupTestManager(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	var created []podman.CreateContainerOptions
+	mock.CreateContainerFunc = func(ctx context.Context, opts podman.CreateContainerOptions) (string, error) {
+		created = append(created, opts)
+		return "container-" + opts.Name, nil
+	}
+
+	_, err := mgr.Create(ctx, CreateOptions{Name: "web", Image: "fedora:42"})
+	require.NoError(t, err)
+
+	p, err := mgr.SetEnv(ctx, EnvOptions{Name: "web", Set: map[string]string{"PORT": "3000", "GREETING": `say "hi" to $USER`}})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"PORT": "3000", "GREETING": `say "hi" to $USER`}, p.Spec.Env)
+	assert.True(t, p.Resources.Pending)
+
+	data, err := os.ReadFile(envFilePath(p))
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "GREETING=\"say \\\"hi\\\" to \\$USER\"\nPORT=\"3000\"\n")
+	info, err := os.Stat(envFilePath(p))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	events, err := mgr.store.ListEvents(ctx, "web", time.Time{})
+	require.NoError(t, err)
+	require.NotEmpty(t, events)
+	last := events[len(events)-1]
+	assert.Equal(t, store.EventEnvChanged, last.Type)
+	assert.Equal(t, "set GREETING, PORT", last.Detail)
+
+	t.Run("applies on next start", func(t *testing.T) {
+		require.NoError(t, mgr.Start(ctx, "web"))
+		require.NotEmpty(t, created)
+		assert.Equal(t, "3000", created[len(created)-1].Env["PORT"])
+	})
+
+	t.Run("marks secrets", func(t *testing.T) {
+		p, err := mgr.SetEnv(ctx, EnvOptions{Name: "web", Set: map[string]string{"SIGNING": "s3cret"}, Secret: true})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"SIGNING"}, p.Spec.SecretEnv)
+		assert.True(t, p.Spec.IsSecretEnv("SIGNING"))
+		assert.False(t, p.Spec.IsSecretEnv("PORT"))
+	})
+
+	t.Run("unsets", func(t *testing.T) {
+		p, err := mgr.SetEnv(ctx, EnvOptions{Name: "web", Unset: []string{"SIGNING", "GREETING"}})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"PORT": "3000"}, p.Spec.Env)
+		assert.Empty(t, p.Spec.SecretEnv)
+
+		_, err = mgr.SetEnv(ctx, EnvOptions{Name: "web", Unset: []string{"GREETING"}})
+		assert.ErrorContains(t, err, "no environment variable GREETING")
+	})
+
+	t.Run("removes the file when none are left", func(t *testing.T) {
+		p, err := mgr.SetEnv(ctx, EnvOptions{Name: "web", Unset: []string{"PORT"}})
+		require.NoError(t, err)
+		assert.Nil(t, p.Spec.Env)
+		_, err = os.Stat(envFilePath(p))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("rejects bad variables", func(t *testing.T) {
+		_, err := mgr.SetEnv(ctx, EnvOptions{Name: "web", Set: map[string]string{"1PORT": "1"}})
+		assert.ErrorContains(t, err, "invalid environment variable name")
+		_, err = mgr.SetEnv(ctx, EnvOptions{Name: "web", Set: map[string]string{"MULTI": "a\nb"}})
+		assert.ErrorContains(t, err, "can't span lines")
+		_, err = mgr.SetEnv(ctx, EnvOptions{Name: "web"})
+		assert.Error(t, err)
+	})
+}
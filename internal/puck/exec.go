@@ -0,0 +1,176 @@
+package puck
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// Limits on commands run in pucks through the daemon
+const (
+	DefaultExecTimeout = 10 * time.Minute
+	MaxExecTimeout     = 30 * time.Minute
+	MaxExecOutput      = 1 << 20 // 1 MiB, the rest is dropped
+)
+
+// ExecOptions is a command to run in a puck without a terminal
+type ExecOptions struct {
+	Name    string        `json:"name"`
+	Cmd     []string      `json:"cmd"`
+	WorkDir string        `json:"work_dir,omitempty"`
+	Env     []string      `json:"env,omitempty"`
+	User    string        `json:"user,omitempty"`
+	Timeout time.Duration `json:"timeout,omitempty"` // DefaultExecTimeout when zero
+}
+
+// ExecResult is how a command run in a puck finished
+type ExecResult struct {
+	ExitCode  int    `json:"exit_code"`
+	Output    string `json:"output"` // stdout and stderr, interleaved
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// Exec runs a command in a running puck and returns its output and exit
+// status. A command that runs and fails is not an error.
+func (m *Manager) Exec(ctx context.Context, opts ExecOptions) (*ExecResult, error) {
+	if len(opts.Cmd) == 0 {
+		return nil, fmt.Errorf("no command given")
+	}
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = DefaultExecTimeout
+	}
+	if timeout < 0 || timeout > MaxExecTimeout {
+		return nil, fmt.Errorf("exec timeout must be between 0 and %s", MaxExecTimeout)
+	}
+
+	p, err := m.store.GetPuck(ctx, opts.Name)
+	if err != nil {
+		return nil, err
+	}
+	running, err := m.podman.IsRunning(ctx, p.ContainerID)
+	if err != nil {
+		return nil, fmt.Errorf("checking container status: %w", err)
+	}
+	if !running {
+		return nil, fmt.Errorf("puck '%s' is not running", opts.Name)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	out := &cappedBuffer{max: MaxExecOutput}
+	m.store.TouchPuck(ctx, opts.Name, time.Now())
+	m.record(ctx, opts.Name, store.EventExec, opts.Cmd[0])
+	err = m.podman.Exec(ctx, p.ContainerID, podman.ExecOptions{
+		Cmd:     opts.Cmd,
+		WorkDir: opts.WorkDir,
+		Env:     opts.Env,
+		User:    cmp.Or(opts.User, p.Spec.User),
+		Output:  out,
+	})
+
+	result := &ExecResult{Output: out.String(), Truncated: out.truncated}
+	var exitErr *podman.ExitError
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		return nil, fmt.Errorf("command timed out after %s", timeout)
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.Code
+	case err != nil:
+		return nil, fmt.Errorf("running command: %w", err)
+	}
+	return result, nil
+}
+
+// ExecStdio runs a command in a running puck on the caller's terminal or
+// stdio, or on the streams in opts. A command that exits non-zero returns
+// a *podman.ExitError.
+func (m *Manager) ExecStdio(ctx context.Context, name string, opts podman.ExecOptions) error {
+	return m.execStdio(ctx, name, opts, true)
+}
+
+// execStdio is ExecStdio, recording the command in the puck's history as
+// an exec session when record is set. puck's own commands, like those
+// puck fs runs, aren't sessions.
+func (m *Manager) execStdio(ctx context.Context, name string, opts podman.ExecOptions, record bool) error {
+	if len(opts.Cmd) == 0 {
+		return fmt.Errorf("no command given")
+	}
+
+	p, err := m.store.GetPuck(ctx, name)
+	if err != nil {
+		return err
+	}
+	running, err := m.podman.IsRunning(ctx, p.ContainerID)
+	if err != nil {
+		return fmt.Errorf("checking container status: %w", err)
+	}
+	if !running {
+		return fmt.Errorf("puck '%s' is not running; start it with: puck start %s", name, name)
+	}
+
+	m.store.TouchPuck(ctx, name, time.Now())
+	if record {
+		m.record(ctx, name, store.EventExec, opts.Cmd[0])
+		// Sessions run as the puck's user unless asked otherwise
+		if opts.User == "" {
+			opts.User = p.Spec.User
+		}
+	}
+	return m.podman.Exec(ctx, p.ContainerID, opts)
+}
+
+// ExecStream runs a command in a running puck with its stdin, stdout and
+// stderr connected to the given streams until it exits or ctx ends, and
+// returns its exit status. There is no timeout and no cap on the output.
+func (m *Manager) ExecStream(ctx context.Context, opts ExecOptions, stdin io.Reader, stdout, stderr io.Writer) (int, error) {
+	err := m.ExecStdio(ctx, opts.Name, podman.ExecOptions{
+		Cmd:         opts.Cmd,
+		Interactive: true,
+		WorkDir:     opts.WorkDir,
+		Env:         opts.Env,
+		User:        opts.User,
+		Output:      stdout,
+		ErrOutput:   stderr,
+		Stdin:       stdin,
+	})
+	var exitErr *podman.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code, nil
+	}
+	return 0, err
+}
+
+// Logs returns the last tail lines of a puck's console output, or all of
+// it when tail <= 0
+func (m *Manager) Logs(ctx context.Context, name string, tail int) (string, error) {
+	p, err := m.store.GetPuck(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	return m.podman.Logs(ctx, p.ContainerID, tail)
+}
+
+// cappedBuffer keeps the first max bytes written to it and drops the rest
+type cappedBuffer struct {
+	bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.Len(); len(p) > room {
+		b.truncated = true
+		b.Buffer.Write(p[:max(room, 0)])
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
@@ -0,0 +1,142 @@
+package puck
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExec(t *testing.T) {
+	t.Run("returns output and exit code", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "dev"})
+		require.NoError(t, err)
+
+		mock.ExecFunc = func(ctx context.Context, containerID string, opts podman.ExecOptions) error {
+			assert.Equal(t, []string{"make", "test"}, opts.Cmd)
+			assert.Equal(t, "/workspace", opts.WorkDir)
+			opts.Output.Write([]byte("FAIL\n"))
+			return &podman.ExitError{Code: 2}
+		}
+		result, err := mgr.Exec(ctx, ExecOptions{Name: "dev", Cmd: []string{"make", "test"}, WorkDir: "/workspace"})
+		require.NoError(t, err)
+		assert.Equal(t, &ExecResult{ExitCode: 2, Output: "FAIL\n"}, result)
+	})
+
+	t.Run("runs as the puck's user unless given one", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "dev", User: "dev"})
+		require.NoError(t, err)
+
+		var user string
+		mock.ExecFunc = func(ctx context.Context, containerID string, opts podman.ExecOptions) error {
+			user = opts.User
+			return nil
+		}
+		_, err = mgr.Exec(ctx, ExecOptions{Name: "dev", Cmd: []string{"id"}})
+		require.NoError(t, err)
+		assert.Equal(t, "dev", user)
+
+		require.NoError(t, mgr.ExecStdio(ctx, "dev", podman.ExecOptions{Cmd: []string{"id"}, User: "root"}))
+		assert.Equal(t, "root", user)
+	})
+
+	t.Run("caps output", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "dev"})
+		require.NoError(t, err)
+
+		mock.ExecFunc = func(ctx context.Context, containerID string, opts podman.ExecOptions) error {
+			opts.Output.Write([]byte(strings.Repeat("x", MaxExecOutput-1)))
+			opts.Output.Write([]byte("yz"))
+			return nil
+		}
+		result, err := mgr.Exec(ctx, ExecOptions{Name: "dev", Cmd: []string{"yes"}})
+		require.NoError(t, err)
+		assert.True(t, result.Truncated)
+		assert.Len(t, result.Output, MaxExecOutput)
+	})
+
+	t.Run("needs a running puck and a command", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "dev"})
+		require.NoError(t, err)
+
+		_, err = mgr.Exec(ctx, ExecOptions{Name: "dev"})
+		assert.ErrorContains(t, err, "no command")
+		_, err = mgr.Exec(ctx, ExecOptions{Name: "dev", Cmd: []string{"true"}, Timeout: MaxExecTimeout + 1})
+		assert.ErrorContains(t, err, "timeout")
+
+		mock.IsRunningFunc = func(ctx context.Context, nameOrID string) (bool, error) { return false, nil }
+		_, err = mgr.Exec(ctx, ExecOptions{Name: "dev", Cmd: []string{"true"}})
+		assert.ErrorContains(t, err, "not running")
+	})
+}
+
+func TestExecStdio(t *testing.T) {
+	mgr, mock, cleanup := setupTestManager(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	p, err := mgr.Create(ctx, CreateOptions{Name: "dev"})
+	require.NoError(t, err)
+
+	mock.ExecFunc = func(ctx context.Context, containerID string, opts podman.ExecOptions) error {
+		assert.Equal(t, p.ContainerID, containerID)
+		assert.Nil(t, opts.Output)
+		return &podman.ExitError{Code: 127}
+	}
+	err = mgr.ExecStdio(ctx, "dev", podman.ExecOptions{Cmd: []string{"nosuchcmd"}, Interactive: true})
+	var exitErr *podman.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 127, exitErr.Code)
+
+	events, err := mgr.History(ctx, "dev", time.Time{})
+	require.NoError(t, err)
+	last := events[len(events)-1]
+	assert.Equal(t, store.EventExec, last.Type)
+	assert.Equal(t, "nosuchcmd", last.Detail)
+
+	mock.IsRunningFunc = func(ctx context.Context, nameOrID string) (bool, error) { return false, nil }
+	err = mgr.ExecStdio(ctx, "dev", podman.ExecOptions{Cmd: []string{"true"}})
+	assert.ErrorContains(t, err, "not running")
+}
+
+func TestLogs(t *testing.T) {
+	mgr, mock, cleanup := setupTestManager(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	p, err := mgr.Create(ctx, CreateOptions{Name: "dev"})
+	require.NoError(t, err)
+
+	mock.LogsFunc = func(ctx context.Context, nameOrID string, tail int) (string, error) {
+		assert.Equal(t, p.ContainerID, nameOrID)
+		assert.Equal(t, 50, tail)
+		return "booted\n", nil
+	}
+	logs, err := mgr.Logs(ctx, "dev", 50)
+	require.NoError(t, err)
+	assert.Equal(t, "booted\n", logs)
+
+	_, err = mgr.Logs(ctx, "missing", 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,57 @@
+package puck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// ExistsError is a create refused because its name is taken, by a puck or
+// by a container puck doesn't manage. It matches store.ErrExists.
+type ExistsError struct {
+	Name string `json:"name"`
+	// Container is the name being taken by a container that isn't a puck's
+	Container bool `json:"container,omitempty"`
+	// Free is a name that isn't taken, made from the wanted one; empty if
+	// none could be found
+	Free string `json:"free,omitempty"`
+}
+
+func (e *ExistsError) Error() string {
+	if e.Container {
+		return fmt.Sprintf("a container named '%s' already exists, and isn't a puck", e.Name)
+	}
+	return fmt.Sprintf("puck '%s' %v", e.Name, store.ErrExists)
+}
+
+func (e *ExistsError) Unwrap() error { return store.ErrExists }
+
+// Hints are what can be done about the taken name
+func (e *ExistsError) Hints() []string {
+	var hints []string
+	if e.Container {
+		hints = append(hints, fmt.Sprintf("Remove it with: podman rm %s, or rename it with: podman rename %s <new-name>", e.Name, e.Name))
+	} else {
+		hints = append(hints, "Replace it, destroying it once the new one is made, by running the same command with --replace")
+	}
+	if e.Free != "" {
+		hints = append(hints, "Or use a free name, such as: "+e.Free)
+	}
+	return hints
+}
+
+// existsError is the ExistsError for name, which is taken by a puck or,
+// if no puck has it, a container, with a free name to suggest in its place
+func (m *Manager) existsError(ctx context.Context, name string) *ExistsError {
+	_, err := m.store.GetPuck(ctx, name)
+	container := errors.Is(err, store.ErrNotFound)
+	free, _ := GenerateName(name, func(name string) (bool, error) {
+		if _, err := m.store.GetPuck(ctx, name); !errors.Is(err, store.ErrNotFound) {
+			return true, err
+		}
+		return m.podman.ContainerExists(ctx, name)
+	})
+	return &ExistsError{Name: name, Container: container, Free: free}
+}
@@ -0,0 +1,318 @@
+package puck
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/containers/storage/pkg/archive"
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// restoreCheckpointFile restores a checkpoint archive exported by podman,
+// on this host or another, as a new puck's container. The archive's
+// mounts of its original puck's volume directories are pointed at the new
+// puck's, which start out empty as checkpoints don't carry them, and its
+// other host mounts are dropped. It returns the container and the image
+// the checkpoint was taken from.
+func (m *Manager) restoreCheckpointFile(ctx context.Context, p *store.Puck, archivePath string) (string, string, error) {
+	machine, err := m.machine(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	volumes := make(map[string]string, len(puckVolumes))
+	for target, dir := range puckVolumes {
+		volumes[target] = machinePath(machine, filepath.Join(p.VolumeDir, dir))
+	}
+
+	if err := os.MkdirAll(m.cfg.SnapshotsDir(), 0755); err != nil {
+		return "", "", err
+	}
+	f, err := os.CreateTemp(m.cfg.SnapshotsDir(), "."+p.Name+"-*.import.tar")
+	if err != nil {
+		return "", "", err
+	}
+	defer os.Remove(f.Name())
+	image, err := rewriteCheckpoint(archivePath, f, volumes)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	ports, err := m.portMappings(ctx, p)
+	if err != nil {
+		return "", "", err
+	}
+	containerID, err := m.podman.Restore(ctx, podman.RestoreOptions{
+		ImportPath:     f.Name(),
+		Name:           p.Name,
+		PublishPorts:   ports,
+		TCPEstablished: m.cfg.CheckpointTCPEstablished,
+		FileLocks:      m.cfg.CheckpointFileLocks,
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("restoring checkpoint: %w", err)
+	}
+	return containerID, image, nil
+}
+
+// rewriteCheckpoint copies a checkpoint archive to w uncompressed, with
+// the bind mounts at the container paths in volumes pointed at the host
+// paths given for them and every other host mount dropped. It returns the
+// image the checkpoint was taken from, and refuses archives that aren't
+// podman checkpoints or whose container puck wouldn't have made (see
+// confineSpec and confineConfig).
+func rewriteCheckpoint(archivePath string, w io.Writer, volumes map[string]string) (string, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return "", fmt.Errorf("opening checkpoint archive: %w", err)
+	}
+	defer f.Close()
+	rc, err := archive.DecompressStream(f)
+	if err != nil {
+		return "", fmt.Errorf("decompressing checkpoint archive: %w", err)
+	}
+	defer rc.Close()
+
+	var image string
+	var sawSpec, sawConfig, sawImages bool
+	tr := tar.NewReader(rc)
+	tw := tar.NewWriter(w)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("reading checkpoint archive: %w", err)
+		}
+
+		name := strings.TrimPrefix(path.Clean(hdr.Name), "./")
+		var data []byte
+		switch {
+		case name == "spec.dump":
+			sawSpec = true
+			if data, err = rewriteDump(tr, volumes, nil); err != nil {
+				return "", fmt.Errorf("reading spec.dump: %w", err)
+			}
+		case name == "config.dump":
+			sawConfig = true
+			if data, err = rewriteDump(tr, volumes, &image); err != nil {
+				return "", fmt.Errorf("reading config.dump: %w", err)
+			}
+		case strings.HasPrefix(name, "checkpoint/"):
+			sawImages = true
+		}
+
+		if data != nil {
+			hdr.Size = int64(len(data))
+			if err := tw.WriteHeader(hdr); err != nil {
+				return "", err
+			}
+			if _, err := tw.Write(data); err != nil {
+				return "", err
+			}
+			continue
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return "", err
+		}
+		if _, err := io.Copy(tw, tr); err != nil {
+			return "", fmt.Errorf("copying checkpoint archive: %w", err)
+		}
+	}
+	if !sawSpec || !sawConfig || !sawImages {
+		return "", fmt.Errorf("%s is not a podman checkpoint archive", archivePath)
+	}
+	if err := tw.Close(); err != nil {
+		return "", err
+	}
+	return image, nil
+}
+
+// rewriteDump confines spec.dump, an OCI runtime spec, or config.dump,
+// podman's container config with the spec inside it, to the new puck.
+// With image set, it also reads the config's image. Numbers are kept as
+// written, as resource limits don't survive a float64.
+func rewriteDump(r io.Reader, volumes map[string]string, image *string) ([]byte, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	spec := doc
+	if image != nil {
+		*image, _ = doc["rootfsImageName"].(string)
+		if err := confineConfig(doc); err != nil {
+			return nil, err
+		}
+		spec, _ = doc["spec"].(map[string]any)
+	}
+	if spec != nil {
+		if err := confineSpec(spec, volumes); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// kernelMounts are the mount types that take no host path, which a
+// restored container keeps
+var kernelMounts = map[string]bool{
+	"proc": true, "sysfs": true, "tmpfs": true, "devpts": true, "mqueue": true, "cgroup": true, "cgroup2": true,
+}
+
+// volumeMountOptions are the options a restored volume mount keeps
+var volumeMountOptions = map[string]bool{
+	"bind": true, "rbind": true, "ro": true, "rw": true, "private": true, "rprivate": true,
+	"nosuid": true, "nodev": true, "noexec": true,
+}
+
+// defaultCaps are the capabilities podman gives containers by default,
+// in current releases and older ones; pucks are never given more
+var defaultCaps = map[string]bool{
+	"CAP_AUDIT_WRITE": true, "CAP_CHOWN": true, "CAP_DAC_OVERRIDE": true, "CAP_FOWNER": true,
+	"CAP_FSETID": true, "CAP_KILL": true, "CAP_MKNOD": true, "CAP_NET_BIND_SERVICE": true,
+	"CAP_NET_RAW": true, "CAP_SETFCAP": true, "CAP_SETGID": true, "CAP_SETPCAP": true,
+	"CAP_SETUID": true, "CAP_SYS_CHROOT": true,
+}
+
+// isolatedNamespaces are the namespaces every puck has of its own. Only
+// the network one may name a path, which the restore replaces.
+var isolatedNamespaces = []string{"pid", "network", "ipc", "uts", "mount"}
+
+// confineSpec keeps an archive's OCI spec to what puck makes, as the
+// archive may have been crafted to reach the host. Mounts at the container
+// paths in volumes are pointed at the host paths given for them; other
+// bind mounts, which podman adds back for its own files, and OCI hooks are
+// dropped. Extra capabilities, host devices and the host's namespaces are
+// refused.
+func confineSpec(spec map[string]any, volumes map[string]string) error {
+	mounts, _ := spec["mounts"].([]any)
+	kept := make([]any, 0, len(mounts))
+	for _, mnt := range mounts {
+		mnt, ok := mnt.(map[string]any)
+		if !ok {
+			continue
+		}
+		target, _ := mnt["destination"].(string)
+		options, _ := mnt["options"].([]any)
+		if source, ok := volumes[path.Clean(target)]; ok {
+			safe := []any{}
+			for _, o := range options {
+				if o, _ := o.(string); volumeMountOptions[o] {
+					safe = append(safe, o)
+				}
+			}
+			kept = append(kept, map[string]any{"destination": target, "type": "bind", "source": source, "options": safe})
+			continue
+		}
+		typ, _ := mnt["type"].(string)
+		bind := false
+		for _, o := range options {
+			if o == "bind" || o == "rbind" {
+				bind = true
+			}
+		}
+		if kernelMounts[typ] && !bind {
+			kept = append(kept, mnt)
+		}
+	}
+	spec["mounts"] = kept
+	delete(spec, "hooks")
+
+	if process, ok := spec["process"].(map[string]any); ok {
+		caps, _ := process["capabilities"].(map[string]any)
+		for _, set := range caps {
+			list, _ := set.([]any)
+			for _, c := range list {
+				if c, _ := c.(string); !defaultCaps[c] {
+					return fmt.Errorf("the checkpoint's container has %s, which pucks are never given", c)
+				}
+			}
+		}
+	}
+
+	linux, _ := spec["linux"].(map[string]any)
+	if devices, _ := linux["devices"].([]any); len(devices) > 0 {
+		return fmt.Errorf("the checkpoint's container has host devices, which pucks are never given")
+	}
+	if resources, ok := linux["resources"].(map[string]any); ok {
+		rules, _ := resources["devices"].([]any)
+		for _, rule := range rules {
+			rule, _ := rule.(map[string]any)
+			access, _ := rule["access"].(string)
+			if allow, _ := rule["allow"].(bool); allow && rule["major"] == nil && strings.ContainsAny(access, "rw") {
+				return fmt.Errorf("the checkpoint's container may use every host device, which pucks never may")
+			}
+		}
+	}
+	namespaces := make(map[string]string)
+	list, _ := linux["namespaces"].([]any)
+	for _, ns := range list {
+		ns, _ := ns.(map[string]any)
+		typ, _ := ns["type"].(string)
+		nsPath, _ := ns["path"].(string)
+		namespaces[typ] = nsPath
+	}
+	for _, typ := range isolatedNamespaces {
+		nsPath, ok := namespaces[typ]
+		if !ok {
+			return fmt.Errorf("the checkpoint's container shares the host's %s namespace", typ)
+		}
+		if nsPath != "" && typ != "network" {
+			return fmt.Errorf("the checkpoint's container joins another %s namespace", typ)
+		}
+	}
+	return nil
+}
+
+// confineConfig keeps an archive's podman container config to what puck
+// makes: named, overlay and image volumes and the host paths podman
+// labels for the container are dropped, and privileged containers, host
+// devices, host directories as the root filesystem and joining another
+// container's namespaces are refused
+func confineConfig(config map[string]any) error {
+	if privileged, _ := config["privileged"].(bool); privileged {
+		return fmt.Errorf("the checkpoint's container is privileged, which pucks never are")
+	}
+	if all, _ := config["mountAllDevices"].(bool); all {
+		return fmt.Errorf("the checkpoint's container has host devices, which pucks are never given")
+	}
+	for _, key := range []string{"host_device_list", "device_host_src", "cdiDevices"} {
+		if devices, _ := config[key].([]any); len(devices) > 0 {
+			return fmt.Errorf("the checkpoint's container has host devices, which pucks are never given")
+		}
+	}
+	if rootfs, _ := config["rootfs"].(string); rootfs != "" {
+		return fmt.Errorf("the checkpoint's container runs on a host directory rather than an image")
+	}
+	for _, key := range []string{"ipcNsCtr", "mountNsCtr", "netNsCtr", "pidNsCtr", "userNsCtr", "utsNsCtr", "cgroupNsCtr"} {
+		if ctr, _ := config[key].(string); ctr != "" {
+			return fmt.Errorf("the checkpoint's container joins another container's namespaces")
+		}
+	}
+	for _, key := range []string{"namedVolumes", "overlayVolumes", "ctrImageVolumes", "mounts", "ShmDir"} {
+		delete(config, key)
+	}
+	return nil
+}
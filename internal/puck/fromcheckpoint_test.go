@@ -0,0 +1,177 @@
+package puck
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readTarFile returns the contents of one file in an uncompressed tarball
+func readTarFile(t *testing.T, archivePath, name string) []byte {
+	t.Helper()
+	f, err := os.Open(archivePath)
+	require.NoError(t, err)
+	defer f.Close()
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		require.NoError(t, err, "%s not in archive", name)
+		if hdr.Name == name {
+			data, err := io.ReadAll(tr)
+			require.NoError(t, err)
+			return data
+		}
+	}
+}
+
+// checkpointNamespaces is the linux block of a puck's OCI spec
+const checkpointNamespaces = `{"namespaces":[{"type":"pid"},{"type":"network","path":"/run/netns/netns-1"},` +
+	`{"type":"ipc"},{"type":"uts"},{"type":"mount"}]}`
+
+func TestConfineCheckpoint(t *testing.T) {
+	volumes := map[string]string{"/home": "/pucks/new/home"}
+	dump := func(t *testing.T, doc string, config bool) (map[string]any, error) {
+		var image string
+		imagePtr := &image
+		if !config {
+			imagePtr = nil
+		}
+		data, err := rewriteDump(strings.NewReader(doc), volumes, imagePtr)
+		if err != nil {
+			return nil, err
+		}
+		var out map[string]any
+		require.NoError(t, json.Unmarshal(data, &out))
+		return out, nil
+	}
+
+	t.Run("drops host mounts and hooks", func(t *testing.T) {
+		spec, err := dump(t, `{"mounts":[{"destination":"/host","type":"bind","source":"/"},`+
+			`{"destination":"/etc/hosts","type":"bind","source":"/old/hosts"},`+
+			`{"destination":"/dev","type":"tmpfs","source":"/","options":["rbind"]}],`+
+			`"hooks":{"prestart":[{"path":"/bin/sh"}]},"linux":`+checkpointNamespaces+`}`, false)
+		require.NoError(t, err)
+		assert.Empty(t, spec["mounts"])
+		assert.NotContains(t, spec, "hooks")
+	})
+
+	t.Run("drops volumes and host paths from the config", func(t *testing.T) {
+		config, err := dump(t, `{"namedVolumes":[{"Name":"other-puck-data","Dest":"/data"}],"mounts":["/etc"],"ShmDir":"/etc",`+
+			`"spec":{"linux":`+checkpointNamespaces+`}}`, true)
+		require.NoError(t, err)
+		assert.NotContains(t, config, "namedVolumes")
+		assert.NotContains(t, config, "mounts")
+		assert.NotContains(t, config, "ShmDir")
+	})
+
+	for name, doc := range map[string]string{
+		"extra capabilities":       `{"process":{"capabilities":{"bounding":["CAP_CHOWN","CAP_SYS_ADMIN"]}},"linux":` + checkpointNamespaces + `}`,
+		"host devices":             `{"linux":{"devices":[{"path":"/dev/sda"}],"namespaces":[]}}`,
+		"every device":             `{"linux":{"resources":{"devices":[{"allow":true,"access":"rwm"}]},"namespaces":[]}}`,
+		"the host's pid namespace": `{"linux":{"namespaces":[{"type":"network"},{"type":"ipc"},{"type":"uts"},{"type":"mount"}]}}`,
+		"another mount namespace":  `{"linux":{"namespaces":[{"type":"pid"},{"type":"network"},{"type":"ipc"},{"type":"uts"},{"type":"mount","path":"/proc/1/ns/mnt"}]}}`,
+	} {
+		t.Run("refuses "+name, func(t *testing.T) {
+			_, err := dump(t, doc, false)
+			assert.ErrorContains(t, err, "the checkpoint's container")
+		})
+	}
+
+	for name, doc := range map[string]string{
+		"privileged containers":        `{"privileged":true}`,
+		"host directory roots":         `{"rootfs":"/"}`,
+		"other containers' namespaces": `{"pidNsCtr":"abc"}`,
+		"all host devices":             `{"mountAllDevices":true}`,
+	} {
+		t.Run("refuses "+name, func(t *testing.T) {
+			_, err := dump(t, doc, true)
+			assert.ErrorContains(t, err, "the checkpoint's container")
+		})
+	}
+}
+
+func TestCreateFromCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	spec := `{"process":{"rlimits":[{"type":"RLIMIT_NOFILE","hard":18446744073709551615}]},"mounts":[` +
+		`{"destination":"/home","type":"bind","source":"/elsewhere/pucks/old/home","options":["rbind","lowerdir=/etc"]},` +
+		`{"destination":"/proc","type":"proc","source":"proc"},` +
+		`{"destination":"/mnt/src","type":"bind","source":"/elsewhere/src"}],` +
+		`"linux":` + checkpointNamespaces + `}`
+	config := `{"name":"old","rootfsImageName":"docker.io/library/node:22","spec":` + spec + `}`
+
+	t.Run("restores the archive as a new puck with its own volumes", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		archivePath := filepath.Join(t.TempDir(), "old.tar")
+		require.NoError(t, writeTar(archivePath, map[string]string{
+			"spec.dump":                spec,
+			"config.dump":              config,
+			"checkpoint/inventory.img": "inv",
+		}))
+
+		var restored podman.RestoreOptions
+		var dumped map[string]any
+		mock.RestoreFunc = func(ctx context.Context, opts podman.RestoreOptions) (string, error) {
+			restored = opts
+			require.NoError(t, json.Unmarshal(readTarFile(t, opts.ImportPath, "spec.dump"), &dumped))
+			assert.Contains(t, string(readTarFile(t, opts.ImportPath, "spec.dump")), "18446744073709551615")
+			return "restored-container", nil
+		}
+
+		p, err := mgr.Create(ctx, CreateOptions{Name: "seeded", FromCheckpoint: archivePath})
+		require.NoError(t, err)
+		assert.Equal(t, "seeded", restored.Name)
+		assert.Equal(t, []string{fmt.Sprintf("%d:80", p.HostPort)}, restored.PublishPorts)
+		assert.Equal(t, "restored-container", p.ContainerID)
+		assert.Equal(t, "docker.io/library/node:22", p.Image)
+		assert.DirExists(t, filepath.Join(p.VolumeDir, "home"))
+
+		mounts := dumped["mounts"].([]any)
+		require.Len(t, mounts, 2, "other host mounts are dropped")
+		assert.Equal(t, map[string]any{
+			"destination": "/home", "type": "bind", "source": filepath.Join(p.VolumeDir, "home"), "options": []any{"rbind"},
+		}, mounts[0])
+		assert.Equal(t, "/proc", mounts[1].(map[string]any)["destination"])
+
+		events, err := mgr.store.ListEvents(ctx, "seeded", time.Time{})
+		require.NoError(t, err)
+		require.NotEmpty(t, events)
+		assert.Equal(t, "checkpoint old.tar", events[0].Detail)
+
+		// The rewritten archive is only kept for the restore
+		leftovers, _ := filepath.Glob(filepath.Join(mgr.cfg.SnapshotsDir(), ".seeded-*"))
+		assert.Empty(t, leftovers)
+	})
+
+	t.Run("refuses archives that aren't checkpoints", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		archivePath := filepath.Join(t.TempDir(), "files.tar")
+		require.NoError(t, writeTar(archivePath, map[string]string{"home/notes.txt": "hi"}))
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "seeded", FromCheckpoint: archivePath})
+		assert.ErrorContains(t, err, "not a podman checkpoint archive")
+		_, err = mgr.store.GetPuck(ctx, "seeded")
+		assert.ErrorIs(t, err, store.ErrNotFound)
+	})
+
+	t.Run("refuses archives the daemon can't read", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "seeded", FromCheckpoint: "/nonexistent/old.tar"})
+		assert.ErrorContains(t, err, "not readable on the daemon's host")
+	})
+}
@@ -0,0 +1,251 @@
+package puck
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+)
+
+// MaxFileRead is the most of a file ReadFile returns; the rest is dropped
+const MaxFileRead = 16 << 20 // 16 MiB
+
+// fsTimeout bounds each command browsing a puck's files
+const fsTimeout = time.Minute
+
+// fsEntryScript prints a NUL-separated record for a file: its raw mode in
+// hex, size, modification time, owner and group, then its path and, for
+// symlinks, its target. It needs only sh, stat and readlink, which
+// coreutils and busybox both have.
+const fsEntryScript = `entry() {
+	meta=$(stat -c '%f %s %Y %U %G' -- "$1") || exit 1
+	target=
+	if [ -L "$1" ]; then target=$(readlink -- "$1"); fi
+	printf '%s\0%s\0%s\0' "$meta" "$2" "$target"
+}
+[ -e "$1" ] || [ -L "$1" ] || exit 2
+`
+
+// fsStatScript prints the record for the file at $1
+const fsStatScript = fsEntryScript + `entry "$1" "$1"
+`
+
+// fsListScript prints a record for each entry of the directory at $1, or
+// for $1 itself when it is not a directory
+const fsListScript = fsEntryScript + `if [ ! -d "$1" ]; then entry "$1" "$1"; exit; fi
+[ -r "$1" ] && [ -x "$1" ] || exit 3
+cd -- "$1" || exit 1
+for f in * .[!.]* ..?*; do
+	if [ -e "$f" ] || [ -L "$f" ]; then entry "$f" "$1/$f"; fi
+done
+`
+
+// fsReadScript prints up to $2 bytes of the file at $1
+const fsReadScript = `[ -e "$1" ] || exit 2
+[ -d "$1" ] && exit 4
+[ -r "$1" ] || exit 3
+exec head -c "$2" -- "$1"
+`
+
+// Exit statuses of the scripts above
+const (
+	fsNotFound    = 2
+	fsDenied      = 3
+	fsIsDirectory = 4
+)
+
+// FSOptions names a path in a puck
+type FSOptions struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// FileInfo describes a file in a puck
+type FileInfo struct {
+	Name    string      `json:"name"`
+	Path    string      `json:"path"`
+	Type    string      `json:"type"` // file, dir, symlink or other
+	Mode    os.FileMode `json:"mode"`
+	Size    int64       `json:"size"`
+	ModTime time.Time   `json:"mod_time"`
+	Owner   string      `json:"owner"`
+	Group   string      `json:"group"`
+	Target  string      `json:"target,omitempty"` // what a symlink points to
+}
+
+// FileContent is a file read from a puck
+type FileContent struct {
+	FileInfo
+	Data      []byte `json:"data"`
+	Truncated bool   `json:"truncated,omitempty"` // more than MaxFileRead
+}
+
+// StatFile describes a file in a running puck, without following symlinks
+func (m *Manager) StatFile(ctx context.Context, opts FSOptions) (*FileInfo, error) {
+	out, err := m.fsRun(ctx, opts, fsStatScript)
+	if err != nil {
+		return nil, err
+	}
+	files, err := parseFileInfo(out)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) != 1 {
+		return nil, fmt.Errorf("reading %s: unexpected output", opts.Path)
+	}
+	return &files[0], nil
+}
+
+// ListFiles lists a directory in a running puck, or describes the file
+// when the path is not one. Entries are in the shell's glob order, which
+// is by name.
+func (m *Manager) ListFiles(ctx context.Context, opts FSOptions) ([]FileInfo, error) {
+	out, err := m.fsRun(ctx, opts, fsListScript)
+	if err != nil {
+		return nil, err
+	}
+	return parseFileInfo(out)
+}
+
+// ReadFile returns the contents of a file in a running puck, up to
+// MaxFileRead bytes
+func (m *Manager) ReadFile(ctx context.Context, opts FSOptions) (*FileContent, error) {
+	info, err := m.StatFile(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	out, err := m.fsRun(ctx, opts, fsReadScript, strconv.Itoa(MaxFileRead+1))
+	if err != nil {
+		return nil, err
+	}
+
+	content := &FileContent{FileInfo: *info, Data: out}
+	if len(out) > MaxFileRead {
+		content.Data = out[:MaxFileRead]
+		content.Truncated = true
+	}
+	return content, nil
+}
+
+// fsRun runs a browsing script in a puck with the path, and any further
+// arguments, as its arguments, returning what it printed
+func (m *Manager) fsRun(ctx context.Context, opts FSOptions, script string, args ...string) ([]byte, error) {
+	if opts.Path == "" {
+		return nil, fmt.Errorf("no path given")
+	}
+	ctx, cancel := context.WithTimeout(ctx, fsTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	err := m.execStdio(ctx, opts.Name, podman.ExecOptions{
+		Cmd:       append([]string{"/bin/sh", "-c", script, "sh", opts.Path}, args...),
+		Output:    &stdout,
+		ErrOutput: &stderr,
+	}, false)
+
+	var exitErr *podman.ExitError
+	switch {
+	case err == nil:
+		return stdout.Bytes(), nil
+	case !errors.As(err, &exitErr):
+		return nil, err
+	case exitErr.Code == fsNotFound:
+		return nil, fmt.Errorf("%s: no such file or directory in puck '%s'", opts.Path, opts.Name)
+	case exitErr.Code == fsDenied:
+		return nil, fmt.Errorf("%s: permission denied", opts.Path)
+	case exitErr.Code == fsIsDirectory:
+		return nil, fmt.Errorf("%s is a directory", opts.Path)
+	}
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return nil, fmt.Errorf("reading %s: %s", opts.Path, msg)
+	}
+	return nil, fmt.Errorf("reading %s: %w", opts.Path, err)
+}
+
+// parseFileInfo parses the records fsEntryScript prints
+func parseFileInfo(out []byte) ([]FileInfo, error) {
+	fields := strings.Split(string(out), "\x00")
+	// The output ends with a NUL, leaving an empty last field
+	if len(fields)%3 != 1 {
+		return nil, fmt.Errorf("unexpected file listing output")
+	}
+
+	files := make([]FileInfo, 0, len(fields)/3)
+	for i := 0; i+3 <= len(fields); i += 3 {
+		meta := strings.Fields(fields[i])
+		if len(meta) != 5 {
+			return nil, fmt.Errorf("unexpected file listing output %q", fields[i])
+		}
+		raw, err := strconv.ParseUint(meta[0], 16, 32)
+		if err != nil {
+			return nil, fmt.Errorf("parsing file mode %q: %w", meta[0], err)
+		}
+		size, err := strconv.ParseInt(meta[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing file size %q: %w", meta[1], err)
+		}
+		mtime, err := strconv.ParseInt(meta[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing file time %q: %w", meta[2], err)
+		}
+
+		f := FileInfo{
+			Name:    path.Base(fields[i+1]),
+			Path:    path.Clean(fields[i+1]),
+			Mode:    fileMode(uint32(raw)),
+			Size:    size,
+			ModTime: time.Unix(mtime, 0).UTC(),
+			Owner:   meta[3],
+			Group:   meta[4],
+			Target:  fields[i+2],
+		}
+		switch {
+		case f.Mode.IsDir():
+			f.Type = "dir"
+		case f.Mode&os.ModeSymlink != 0:
+			f.Type = "symlink"
+		case f.Mode.IsRegular():
+			f.Type = "file"
+		default:
+			f.Type = "other"
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// fileMode converts a Unix st_mode to an os.FileMode
+func fileMode(raw uint32) os.FileMode {
+	mode := os.FileMode(raw & 0777)
+	switch raw & 0170000 {
+	case 0040000:
+		mode |= os.ModeDir
+	case 0120000:
+		mode |= os.ModeSymlink
+	case 0010000:
+		mode |= os.ModeNamedPipe
+	case 0140000:
+		mode |= os.ModeSocket
+	case 0020000:
+		mode |= os.ModeDevice | os.ModeCharDevice
+	case 0060000:
+		mode |= os.ModeDevice
+	}
+	if raw&04000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if raw&02000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if raw&01000 != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode
+}
@@ -0,0 +1,114 @@
+package puck
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFiles(t *testing.T) {
+	mgr, mock, cleanup := setupTestManager(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	// Commands run on this host, standing in for the puck
+	mock.ExecFunc = func(ctx context.Context, containerID string, opts podman.ExecOptions) error {
+		cmd := exec.CommandContext(ctx, opts.Cmd[0], opts.Cmd[1:]...)
+		cmd.Stdout, cmd.Stderr = opts.Output, opts.ErrOutput
+		err := cmd.Run()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return &podman.ExitError{Code: exitErr.ExitCode()}
+		}
+		return err
+	}
+	_, err := mgr.Create(ctx, CreateOptions{Name: "web", Image: "fedora:42"})
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "app.conf"), []byte("port = 3000\n"), 0640))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".hidden"), nil, 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "odd name"), nil, 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "conf.d"), 0755))
+	require.NoError(t, os.Symlink("app.conf", filepath.Join(dir, "current")))
+
+	t.Run("lists a directory", func(t *testing.T) {
+		files, err := mgr.ListFiles(ctx, FSOptions{Name: "web", Path: dir})
+		require.NoError(t, err)
+
+		byName := map[string]FileInfo{}
+		for _, f := range files {
+			byName[f.Name] = f
+		}
+		assert.Len(t, files, 5)
+		assert.Equal(t, "file", byName["app.conf"].Type)
+		assert.Equal(t, int64(12), byName["app.conf"].Size)
+		assert.Equal(t, os.FileMode(0640), byName["app.conf"].Mode)
+		assert.Equal(t, filepath.Join(dir, "app.conf"), byName["app.conf"].Path)
+		assert.False(t, byName["app.conf"].ModTime.IsZero())
+		assert.Equal(t, "dir", byName["conf.d"].Type)
+		assert.True(t, byName["conf.d"].Mode.IsDir())
+		assert.Equal(t, "symlink", byName["current"].Type)
+		assert.Equal(t, "app.conf", byName["current"].Target)
+		assert.Contains(t, byName, ".hidden")
+		assert.Contains(t, byName, "odd name")
+	})
+
+	t.Run("lists a file as itself", func(t *testing.T) {
+		files, err := mgr.ListFiles(ctx, FSOptions{Name: "web", Path: filepath.Join(dir, "app.conf")})
+		require.NoError(t, err)
+		require.Len(t, files, 1)
+		assert.Equal(t, "app.conf", files[0].Name)
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		info, err := mgr.StatFile(ctx, FSOptions{Name: "web", Path: filepath.Join(dir, "current")})
+		require.NoError(t, err)
+		assert.Equal(t, "current", info.Name)
+		assert.Equal(t, "symlink", info.Type)
+
+		_, err = mgr.StatFile(ctx, FSOptions{Name: "web", Path: filepath.Join(dir, "missing")})
+		assert.ErrorContains(t, err, "no such file or directory in puck 'web'")
+	})
+
+	t.Run("reads a file", func(t *testing.T) {
+		content, err := mgr.ReadFile(ctx, FSOptions{Name: "web", Path: filepath.Join(dir, "app.conf")})
+		require.NoError(t, err)
+		assert.Equal(t, "port = 3000\n", string(content.Data))
+		assert.Equal(t, "file", content.Type)
+		assert.False(t, content.Truncated)
+
+		_, err = mgr.ReadFile(ctx, FSOptions{Name: "web", Path: filepath.Join(dir, "conf.d")})
+		assert.ErrorContains(t, err, "is a directory")
+	})
+
+	t.Run("truncates large files", func(t *testing.T) {
+		big := filepath.Join(dir, "big")
+		require.NoError(t, os.WriteFile(big, []byte(strings.Repeat("x", MaxFileRead+10)), 0644))
+		content, err := mgr.ReadFile(ctx, FSOptions{Name: "web", Path: big})
+		require.NoError(t, err)
+		assert.Len(t, content.Data, MaxFileRead)
+		assert.True(t, content.Truncated)
+	})
+
+	t.Run("needs a path", func(t *testing.T) {
+		_, err := mgr.ListFiles(ctx, FSOptions{Name: "web"})
+		assert.ErrorContains(t, err, "no path given")
+	})
+}
+
+func TestFileMode(t *testing.T) {
+	assert.Equal(t, os.ModeDir|0755, fileMode(0x41ed))
+	assert.Equal(t, os.FileMode(0644), fileMode(0x81a4))
+	assert.Equal(t, os.ModeSymlink|0777, fileMode(0xa1ff))
+	assert.Equal(t, os.ModeDir|os.ModeSticky|0777, fileMode(0x43ff))
+	assert.Equal(t, os.ModeSetuid|0755, fileMode(0x89ed))
+}
@@ -0,0 +1,151 @@
+package puck
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// GCOptions controls which images puck gc removes
+type GCOptions struct {
+	KeepLast int  `json:"keep_last"` // newest unused images to keep per repository
+	DryRun   bool `json:"dry_run"`
+}
+
+// GCImage is an image removed, or that would be removed, by puck gc
+type GCImage struct {
+	ID     string `json:"id"`
+	Name   string `json:"name,omitempty"` // empty for dangling images
+	Size   int64  `json:"size"`
+	Reason string `json:"reason"`
+}
+
+// GCReport summarizes a garbage collection
+type GCReport struct {
+	Removed   []GCImage `json:"removed"`
+	Reclaimed int64     `json:"reclaimed"` // bytes
+	Errors    []string  `json:"errors,omitempty"`
+}
+
+// GC removes dangling images, the build cache, and images no puck or
+// container uses, keeping the newest KeepLast unused images of each
+// repository. Snapshot images are left to the snapshots that own them,
+// and images in warm_images are kept.
+func (m *Manager) GC(ctx context.Context, opts GCOptions) (*GCReport, error) {
+	if opts.KeepLast < 0 {
+		return nil, fmt.Errorf("keep-last must not be negative")
+	}
+
+	pucks, err := m.store.ListPucks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	images, err := m.podman.ListImages(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &GCReport{}
+
+	// Dangling images go first, with the build cache, in one prune
+	if opts.DryRun {
+		for _, img := range images {
+			if img.Dangling && img.Containers == 0 {
+				report.add(GCImage{ID: img.ID, Size: img.Size, Reason: "dangling"})
+			}
+		}
+	} else {
+		pruned, err := m.podman.PruneImages(ctx, true)
+		if err != nil {
+			report.Errors = append(report.Errors, err.Error())
+		}
+		if pruned != nil {
+			for _, id := range pruned.IDs {
+				report.Removed = append(report.Removed, GCImage{ID: id, Reason: "dangling"})
+			}
+			report.Reclaimed += int64(pruned.Reclaimed)
+		}
+	}
+
+	// Group unused tagged images by repository, newest first
+	byRepo := make(map[string][]podman.Image)
+	for _, img := range images {
+		if img.Dangling || len(img.Names) == 0 || img.Containers > 0 {
+			continue
+		}
+		repo := imageRepo(img.Names[0])
+		if repo == snapshotImageRepo || imageInUse(img, pucks) || m.warmImage(img) {
+			continue
+		}
+		byRepo[repo] = append(byRepo[repo], img)
+	}
+
+	repos := make([]string, 0, len(byRepo))
+	for repo := range byRepo {
+		repos = append(repos, repo)
+	}
+	sort.Strings(repos)
+
+	for _, repo := range repos {
+		unused := byRepo[repo]
+		sort.Slice(unused, func(i, j int) bool {
+			return unused[i].Created.After(unused[j].Created)
+		})
+		if len(unused) <= opts.KeepLast {
+			continue
+		}
+		for _, img := range unused[opts.KeepLast:] {
+			if !opts.DryRun {
+				if err := m.podman.RemoveImage(ctx, img.ID); err != nil {
+					report.Errors = append(report.Errors, err.Error())
+					continue
+				}
+			}
+			report.add(GCImage{ID: img.ID, Name: img.Names[0], Size: img.Size, Reason: "unused"})
+		}
+	}
+
+	return report, nil
+}
+
+// add records a removed image and the space it frees
+func (r *GCReport) add(img GCImage) {
+	r.Removed = append(r.Removed, img)
+	r.Reclaimed += img.Size
+}
+
+// imageInUse reports whether any puck was created from the image
+func imageInUse(img podman.Image, pucks []*store.Puck) bool {
+	for _, p := range pucks {
+		for _, name := range img.Names {
+			if imageRefMatches(name, p.Image) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// imageRefMatches reports whether a fully qualified image name, e.g.
+// docker.io/library/fedora:latest, is what ref (e.g. fedora) resolves to
+func imageRefMatches(name, ref string) bool {
+	if ref == "" {
+		return false
+	}
+	if !strings.Contains(ref[strings.LastIndex(ref, "/")+1:], ":") && !strings.Contains(ref, "@") {
+		ref += ":latest"
+	}
+	return name == ref || strings.HasSuffix(name, "/"+ref)
+}
+
+// imageRepo strips the tag from an image name
+func imageRepo(name string) string {
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		return name[:i]
+	}
+	return name
+}
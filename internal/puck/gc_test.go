@@ -0,0 +1,106 @@
+package puck
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGC(t *testing.T) {
+	now := time.Now()
+	images := []podman.Image{
+		{ID: "dangling", Size: 100, Dangling: true},
+		{ID: "used-by-container", Names: []string{"docker.io/library/alpine:3.19"}, Size: 10, Containers: 1},
+		{ID: "used-by-puck", Names: []string{"docker.io/library/fedora:latest"}, Size: 20},
+		{ID: "app-v3", Names: []string{"localhost/app:v3"}, Size: 30, Created: now},
+		{ID: "app-v2", Names: []string{"localhost/app:v2"}, Size: 40, Created: now.Add(-time.Hour)},
+		{ID: "app-v1", Names: []string{"localhost/app:v1"}, Size: 50, Created: now.Add(-2 * time.Hour)},
+		{ID: "snapshot", Names: []string{snapshotImageRepo + ":abc"}, Size: 60},
+	}
+
+	setup := func(t *testing.T) (*Manager, *podman.MockClient, func()) {
+		mgr, mock, cleanup := setupTestManager(t)
+		_, err := mgr.Create(context.Background(), CreateOptions{Name: "gc-puck", Image: "fedora"})
+		require.NoError(t, err)
+		mock.Reset()
+		mock.ListImagesFunc = func(ctx context.Context) ([]podman.Image, error) { return images, nil }
+		mock.PruneImagesFunc = func(ctx context.Context, buildCache bool) (*podman.PruneReport, error) {
+			return &podman.PruneReport{IDs: []string{"dangling"}, Reclaimed: 100}, nil
+		}
+		return mgr, mock, cleanup
+	}
+
+	removedIDs := func(report *GCReport) []string {
+		var ids []string
+		for _, img := range report.Removed {
+			ids = append(ids, img.ID)
+		}
+		return ids
+	}
+
+	t.Run("removes dangling and unused images", func(t *testing.T) {
+		mgr, mock, cleanup := setup(t)
+		defer cleanup()
+
+		report, err := mgr.GC(context.Background(), GCOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"dangling", "app-v3", "app-v2", "app-v1"}, removedIDs(report))
+		assert.Equal(t, int64(220), report.Reclaimed)
+		assert.True(t, mock.WasCalled("PruneImages"))
+		assert.Equal(t, 3, mock.CallCount("RemoveImage"))
+	})
+
+	t.Run("keeps the newest images per repository", func(t *testing.T) {
+		mgr, _, cleanup := setup(t)
+		defer cleanup()
+
+		report, err := mgr.GC(context.Background(), GCOptions{KeepLast: 2})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"dangling", "app-v1"}, removedIDs(report))
+		assert.Equal(t, int64(150), report.Reclaimed)
+	})
+
+	t.Run("dry run removes nothing", func(t *testing.T) {
+		mgr, mock, cleanup := setup(t)
+		defer cleanup()
+
+		report, err := mgr.GC(context.Background(), GCOptions{DryRun: true})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"dangling", "app-v3", "app-v2", "app-v1"}, removedIDs(report))
+		assert.Equal(t, int64(220), report.Reclaimed)
+		assert.False(t, mock.WasCalled("PruneImages"))
+		assert.False(t, mock.WasCalled("RemoveImage"))
+	})
+
+	t.Run("keeps warm images", func(t *testing.T) {
+		mgr, _, cleanup := setup(t)
+		defer cleanup()
+		mgr.cfg.WarmImages = []string{"localhost/app:v2"}
+
+		report, err := mgr.GC(context.Background(), GCOptions{DryRun: true})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"dangling", "app-v3", "app-v1"}, removedIDs(report))
+	})
+
+	t.Run("rejects negative keep-last", func(t *testing.T) {
+		mgr, _, cleanup := setup(t)
+		defer cleanup()
+
+		_, err := mgr.GC(context.Background(), GCOptions{KeepLast: -1})
+		assert.Error(t, err)
+	})
+}
+
+func TestImageRefMatches(t *testing.T) {
+	assert.True(t, imageRefMatches("docker.io/library/fedora:latest", "fedora"))
+	assert.True(t, imageRefMatches("docker.io/library/fedora:40", "fedora:40"))
+	assert.True(t, imageRefMatches("quay.io/org/app:v1", "quay.io/org/app:v1"))
+	assert.True(t, imageRefMatches("localhost:5000/app:latest", "localhost:5000/app"))
+	assert.False(t, imageRefMatches("docker.io/library/fedora:40", "fedora"))
+	assert.False(t, imageRefMatches("docker.io/library/myfedora:latest", "fedora"))
+	assert.False(t, imageRefMatches("docker.io/library/fedora:latest", ""))
+}
@@ -0,0 +1,58 @@
+package puck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// groupNamePattern matches group names, given to commands as @<name>
+var groupNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// AddToGroup adds pucks to one of owner's groups, creating it if needed.
+// Every puck must exist.
+func (m *Manager) AddToGroup(ctx context.Context, owner, name string, pucks []string) (*store.Group, error) {
+	if !groupNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid group name %q", name)
+	}
+	if len(pucks) == 0 {
+		return nil, fmt.Errorf("no pucks to add to group '%s'", name)
+	}
+	for _, p := range pucks {
+		if _, err := m.store.GetPuck(ctx, p); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := m.store.AddGroupMembers(ctx, owner, name, pucks); err != nil {
+		return nil, err
+	}
+	return m.store.GetGroup(ctx, owner, name)
+}
+
+// RemoveFromGroup removes pucks from one of owner's groups, or removes the
+// group when pucks is empty. It returns what is left of the group, or nil
+// if nothing is.
+func (m *Manager) RemoveFromGroup(ctx context.Context, owner, name string, pucks []string) (*store.Group, error) {
+	if err := m.store.RemoveGroupMembers(ctx, owner, name, pucks); err != nil {
+		return nil, err
+	}
+	g, err := m.store.GetGroup(ctx, owner, name)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	return g, err
+}
+
+// Group returns one of owner's groups
+func (m *Manager) Group(ctx context.Context, owner, name string) (*store.Group, error) {
+	return m.store.GetGroup(ctx, owner, name)
+}
+
+// ListGroups returns owner's groups, or every user's when owner is empty
+func (m *Manager) ListGroups(ctx context.Context, owner string) ([]*store.Group, error) {
+	return m.store.ListGroups(ctx, owner)
+}
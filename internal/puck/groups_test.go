@@ -0,0 +1,52 @@
+package puck
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroups(t *testing.T) {
+	mgr, _, cleanup := setupTestManager(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	for _, name := range []string{"web", "api"} {
+		_, err := mgr.Create(ctx, CreateOptions{Name: name})
+		require.NoError(t, err)
+	}
+
+	t.Run("adds existing pucks", func(t *testing.T) {
+		g, err := mgr.AddToGroup(ctx, "alice", "demo", []string{"web", "api"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"api", "web"}, g.Members)
+	})
+
+	t.Run("refuses missing pucks and bad names", func(t *testing.T) {
+		_, err := mgr.AddToGroup(ctx, "alice", "demo", []string{"missing"})
+		assert.ErrorContains(t, err, "not found")
+
+		_, err = mgr.AddToGroup(ctx, "alice", "@demo", []string{"web"})
+		assert.ErrorContains(t, err, "invalid group name")
+	})
+
+	t.Run("destroying a puck takes it out of its groups", func(t *testing.T) {
+		require.NoError(t, mgr.Destroy(ctx, "api", true))
+
+		g, err := mgr.Group(ctx, "alice", "demo")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"web"}, g.Members)
+	})
+
+	t.Run("removing the last member removes the group", func(t *testing.T) {
+		g, err := mgr.RemoveFromGroup(ctx, "alice", "demo", []string{"web"})
+		require.NoError(t, err)
+		assert.Nil(t, g)
+
+		groups, err := mgr.ListGroups(ctx, "alice")
+		require.NoError(t, err)
+		assert.Empty(t, groups)
+	})
+}
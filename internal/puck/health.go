@@ -0,0 +1,21 @@
+package puck
+
+import (
+	"context"
+
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// Health returns podman's health status for a puck whose image defines a
+// HEALTHCHECK: healthy, unhealthy or starting. It is empty for pucks that
+// aren't up, have no healthcheck, or can't be inspected.
+func (m *Manager) Health(ctx context.Context, p *store.Puck) string {
+	if !p.Status.Up() || p.ContainerID == "" {
+		return ""
+	}
+	data, err := m.podman.InspectContainer(ctx, p.ContainerID)
+	if err != nil || data.State == nil || data.State.Health == nil {
+		return ""
+	}
+	return data.State.Health.Status
+}
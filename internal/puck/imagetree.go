@@ -0,0 +1,183 @@
+package puck
+
+import (
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+)
+
+// ImageTree shows how the images pucks are created from share layers on
+// disk. Images built on the same base share its layers, so the tree
+// branches where images stop sharing, and an image's marginal cost is what
+// only it has: the disk its pucks would save by moving onto another image.
+type ImageTree struct {
+	Roots   []*LayerNode `json:"roots"`
+	Images  []ImageCost  `json:"images"`            // biggest marginal cost first
+	Total   int64        `json:"total"`             // bytes, each shared layer counted once
+	Missing []string     `json:"missing,omitempty"` // puck images not in local storage
+}
+
+// LayerNode is a run of layers the same images have, and the runs that
+// branch from its top
+type LayerNode struct {
+	Digest   string       `json:"digest"` // of the run's top layer
+	Layers   int          `json:"layers"`
+	Size     int64        `json:"size"`
+	Images   []string     `json:"images,omitempty"` // images whose top layer ends the run
+	Children []*LayerNode `json:"children,omitempty"`
+}
+
+// ImageCost is what an image pucks are created from takes on disk
+type ImageCost struct {
+	Image    string   `json:"image"`
+	ID       string   `json:"id"`
+	Pucks    []string `json:"pucks"`
+	Others   int      `json:"others,omitempty"` // pucks left out of Pucks because the caller may not see them
+	Layers   int      `json:"layers"`
+	Size     int64    `json:"size"`
+	Shared   int64    `json:"shared"`   // in layers other puck images have too
+	Marginal int64    `json:"marginal"` // in layers only this image has
+}
+
+// imageLayers is an image and its layers, base first
+type imageLayers struct {
+	cost   ImageCost
+	layers []podman.Layer
+}
+
+// ImageTree works out which layers the images of pucks share, from what
+// podman reports of each image. Pucks created from the same image under
+// different names count as one image.
+func (m *Manager) ImageTree(ctx context.Context) (*ImageTree, error) {
+	pucks, err := m.store.ListPucks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	users := make(map[string][]string)
+	for _, p := range pucks {
+		if p.Image != "" {
+			users[p.Image] = append(users[p.Image], p.Name)
+		}
+	}
+
+	var images []*imageLayers
+	byID := make(map[string]*imageLayers)
+	var missing []string
+	for _, ref := range slices.Sorted(maps.Keys(users)) {
+		exists, err := m.podman.ImageExists(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			missing = append(missing, ref)
+			continue
+		}
+		id, layers, err := m.podman.ImageLayers(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		img, ok := byID[id]
+		if !ok {
+			img = &imageLayers{cost: ImageCost{Image: ref, ID: id}, layers: layers}
+			byID[id] = img
+			images = append(images, img)
+		}
+		img.cost.Pucks = append(img.cost.Pucks, users[ref]...)
+	}
+
+	tree := buildImageTree(images)
+	tree.Missing = missing
+	return tree, nil
+}
+
+// layerTrie holds the layers of images by their place in each image, as
+// storage does: the same layer on different parents is stored twice
+type layerTrie struct {
+	layer    podman.Layer
+	images   int      // images with this layer and all below it
+	ends     []string // images whose top layer this is
+	children map[string]*layerTrie
+	order    []*layerTrie
+}
+
+func (t *layerTrie) child(layer podman.Layer) *layerTrie {
+	if c, ok := t.children[layer.Digest]; ok {
+		return c
+	}
+	c := &layerTrie{layer: layer, children: make(map[string]*layerTrie)}
+	t.children[layer.Digest] = c
+	t.order = append(t.order, c)
+	return c
+}
+
+// buildImageTree arranges images by the layers they share and works out
+// what each one costs
+func buildImageTree(images []*imageLayers) *ImageTree {
+	root := &layerTrie{children: make(map[string]*layerTrie)}
+	for _, img := range images {
+		node := root
+		for _, layer := range img.layers {
+			node = node.child(layer)
+			node.images++
+		}
+		node.ends = append(node.ends, img.cost.Image)
+	}
+
+	tree := &ImageTree{Images: []ImageCost{}}
+	for _, img := range images {
+		cost := img.cost
+		cost.Layers = len(img.layers)
+		node := root
+		for _, layer := range img.layers {
+			node = node.children[layer.Digest]
+			cost.Size += layer.Size
+			if node.images == 1 {
+				cost.Marginal += layer.Size
+			}
+		}
+		cost.Shared = cost.Size - cost.Marginal
+		slices.Sort(cost.Pucks)
+		tree.Images = append(tree.Images, cost)
+	}
+	slices.SortFunc(tree.Images, func(a, b ImageCost) int {
+		return cmp.Or(cmp.Compare(b.Marginal, a.Marginal), cmp.Compare(a.Image, b.Image))
+	})
+
+	var total func(*layerTrie) int64
+	total = func(t *layerTrie) int64 {
+		size := t.layer.Size
+		for _, c := range t.order {
+			size += total(c)
+		}
+		return size
+	}
+	tree.Total = total(root)
+	tree.Roots = collapseLayers(root.order)
+	return tree
+}
+
+// collapseLayers turns tries into nodes, folding each run of layers with
+// nothing branching off into one node. Biggest first.
+func collapseLayers(tries []*layerTrie) []*LayerNode {
+	nodes := make([]*LayerNode, 0, len(tries))
+	for _, t := range tries {
+		node := &LayerNode{Layers: 1, Size: t.layer.Size}
+		for len(t.ends) == 0 && len(t.order) == 1 {
+			t = t.order[0]
+			node.Layers++
+			node.Size += t.layer.Size
+		}
+		node.Digest = t.layer.Digest
+		node.Images = slices.Sorted(slices.Values(t.ends))
+		node.Children = collapseLayers(t.order)
+		nodes = append(nodes, node)
+	}
+	slices.SortStableFunc(nodes, func(a, b *LayerNode) int {
+		return cmp.Compare(b.Size, a.Size)
+	})
+	return nodes
+}
@@ -0,0 +1,69 @@
+package puck
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageTree(t *testing.T) {
+	mgr, mock, cleanup := setupTestManager(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	base := []podman.Layer{{Digest: "sha256:base1", Size: 100}, {Digest: "sha256:base2", Size: 50}}
+	images := map[string]struct {
+		id     string
+		layers []podman.Layer
+	}{
+		"fedora":        {"fedora-id", base},
+		"fedora:latest": {"fedora-id", base},
+		"app:v1":        {"app-id", append(base[:2:2], podman.Layer{Digest: "sha256:app", Size: 30})},
+		"tools:v1":      {"tools-id", append(base[:2:2], podman.Layer{Digest: "sha256:tools1", Size: 20}, podman.Layer{Digest: "sha256:tools2", Size: 5})},
+		"alpine":        {"alpine-id", []podman.Layer{{Digest: "sha256:alpine", Size: 10}}},
+	}
+	for i, image := range []string{"fedora", "fedora:latest", "app:v1", "tools:v1", "alpine", "gone:v1"} {
+		_, err := mgr.Create(ctx, CreateOptions{Name: []string{"a", "b", "c", "d", "e", "f"}[i], Image: image})
+		require.NoError(t, err)
+	}
+	mock.ImageExistsFunc = func(ctx context.Context, ref string) (bool, error) {
+		_, ok := images[ref]
+		return ok, nil
+	}
+	mock.ImageLayersFunc = func(ctx context.Context, ref string) (string, []podman.Layer, error) {
+		return images[ref].id, images[ref].layers, nil
+	}
+
+	tree, err := mgr.ImageTree(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"gone:v1"}, tree.Missing)
+	assert.Equal(t, int64(100+50+30+20+5+10), tree.Total)
+
+	costs := make(map[string]ImageCost)
+	for _, c := range tree.Images {
+		costs[c.Image] = c
+	}
+	require.Len(t, costs, 4, "fedora and fedora:latest are one image")
+	assert.Equal(t, []string{"a", "b"}, costs["fedora"].Pucks)
+	assert.Equal(t, ImageCost{Image: "tools:v1", ID: "tools-id", Pucks: []string{"d"}, Layers: 4, Size: 175, Shared: 150, Marginal: 25}, costs["tools:v1"])
+	assert.Equal(t, int64(30), costs["app:v1"].Marginal)
+	assert.Equal(t, int64(0), costs["fedora"].Marginal)
+	assert.Equal(t, int64(10), costs["alpine"].Marginal)
+	assert.Equal(t, "app:v1", tree.Images[0].Image, "biggest marginal cost first")
+
+	require.Len(t, tree.Roots, 2)
+	fedora := tree.Roots[0]
+	assert.Equal(t, "sha256:base2", fedora.Digest)
+	assert.Equal(t, 2, fedora.Layers)
+	assert.Equal(t, int64(150), fedora.Size)
+	assert.Equal(t, []string{"fedora"}, fedora.Images)
+	require.Len(t, fedora.Children, 2)
+	assert.Equal(t, []string{"app:v1"}, fedora.Children[0].Images)
+	assert.Equal(t, 2, fedora.Children[1].Layers, "the tools layers fold into one node")
+	assert.Equal(t, []string{"tools:v1"}, fedora.Children[1].Images)
+	assert.Equal(t, []string{"alpine"}, tree.Roots[1].Images)
+}
@@ -0,0 +1,205 @@
+package puck
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/containers/storage/pkg/archive"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// SnapshotInspectOptions selects a snapshot to inspect
+type SnapshotInspectOptions struct {
+	PuckName     string `json:"puck_name"`
+	SnapshotName string `json:"snapshot_name"` // name or tag
+	Files        bool   `json:"files,omitempty"`
+}
+
+// SnapshotInfo describes a snapshot and what restoring it brings back
+type SnapshotInfo struct {
+	Snapshot    *store.Snapshot `json:"snapshot"`
+	Checksum    string          `json:"checksum"`    // sha256 of the archive
+	Compression string          `json:"compression"` // none, gzip, bzip2, xz or zstd
+	Entries     int             `json:"entries"`
+	Size        int64           `json:"size"` // uncompressed bytes of file data
+	// CRIU images and root filesystem changes; checkpoint mode only
+	CheckpointImages int   `json:"checkpoint_images,omitempty"`
+	RootfsDiff       int64 `json:"rootfs_diff,omitempty"`
+	// Volume data the archive carries. Checkpoints leave out puck volume
+	// directories, which restores keep as they are.
+	Volumes []SnapshotVolume `json:"volumes"`
+	Files   []ArchiveFile    `json:"files,omitempty"`
+}
+
+// SnapshotVolume is volume data included in a snapshot
+type SnapshotVolume struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// ArchiveFile is one entry in a snapshot archive
+type ArchiveFile struct {
+	Name    string      `json:"name"`
+	Size    int64       `json:"size"`
+	Mode    os.FileMode `json:"mode"`
+	ModTime time.Time   `json:"mod_time"`
+}
+
+// compressionNames names the compression formats podman and puck write
+var compressionNames = map[archive.Compression]string{
+	archive.Uncompressed: "none",
+	archive.Gzip:         "gzip",
+	archive.Bzip2:        "bzip2",
+	archive.Xz:           "xz",
+	archive.Zstd:         "zstd",
+}
+
+// InspectSnapshot reads a snapshot's archive and summarizes what it holds
+func (m *Manager) InspectSnapshot(ctx context.Context, opts SnapshotInspectOptions) (*SnapshotInfo, error) {
+	snapshot, _, err := m.findSnapshot(ctx, opts.PuckName, opts.SnapshotName)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := m.openSnapshot(snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("opening snapshot archive: %w", err)
+	}
+	defer f.Close()
+
+	// Checksum the archive as it is read
+	sum := sha256.New()
+	br := bufio.NewReader(io.TeeReader(f, sum))
+	magic, _ := br.Peek(10)
+
+	info := &SnapshotInfo{
+		Snapshot:    snapshot,
+		Compression: compressionNames[archive.DetectCompression(magic)],
+		Volumes:     []SnapshotVolume{},
+	}
+
+	rc, err := archive.DecompressStream(br)
+	if err != nil {
+		return nil, fmt.Errorf("decompressing snapshot archive: %w", err)
+	}
+	defer rc.Close()
+
+	volumes := make(map[string]int64)
+	tr := tar.NewReader(rc)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading snapshot archive: %w", err)
+		}
+
+		name := strings.TrimPrefix(path.Clean(hdr.Name), "./")
+		info.Entries++
+		info.Size += hdr.Size
+		if opts.Files {
+			info.Files = append(info.Files, ArchiveFile{
+				Name:    name,
+				Size:    hdr.Size,
+				Mode:    hdr.FileInfo().Mode(),
+				ModTime: hdr.ModTime,
+			})
+		}
+
+		top, rest, _ := strings.Cut(name, "/")
+		if snapshot.Mode == store.SnapshotModeImage {
+			// The archive is the puck's volume directory itself
+			volumes[top] += hdr.Size
+			continue
+		}
+		switch {
+		case top == "checkpoint" && hdr.Typeflag == tar.TypeReg:
+			info.CheckpointImages++
+		case name == "rootfs-diff.tar":
+			info.RootfsDiff = hdr.Size
+		case top == "volumes" && rest != "":
+			// Named volumes, one tarball each
+			volumes[strings.TrimSuffix(rest, ".tar")] += hdr.Size
+		}
+	}
+
+	// Drain anything after the tar trailer so the checksum covers the file
+	if _, err := io.Copy(io.Discard, br); err != nil {
+		return nil, fmt.Errorf("reading snapshot archive: %w", err)
+	}
+	info.Checksum = hex.EncodeToString(sum.Sum(nil))
+
+	if snapshot.VolumesPath != "" {
+		if err := volumeArchiveSizes(snapshot.VolumesPath, volumes); err != nil {
+			return nil, err
+		}
+	}
+
+	for name, size := range volumes {
+		info.Volumes = append(info.Volumes, SnapshotVolume{Name: name, Size: size})
+	}
+	sort.Slice(info.Volumes, func(i, j int) bool { return info.Volumes[i].Name < info.Volumes[j].Name })
+
+	return info, nil
+}
+
+// volumeArchiveSizes adds up the file sizes in an archive of a puck's
+// volume directories, by volume
+func volumeArchiveSizes(archivePath string, volumes map[string]int64) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("opening volume archive: %w", err)
+	}
+	defer f.Close()
+	rc, err := archive.DecompressStream(f)
+	if err != nil {
+		return fmt.Errorf("decompressing volume archive: %w", err)
+	}
+	defer rc.Close()
+
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading volume archive: %w", err)
+		}
+		top, _, _ := strings.Cut(strings.TrimPrefix(path.Clean(hdr.Name), "./"), "/")
+		volumes[top] += hdr.Size
+	}
+}
+
+// hostCRIUVersion asks the host's criu for its version, e.g. "3.19",
+// returning an empty string if it can't be run
+func hostCRIUVersion(ctx context.Context) string {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, "criu", "--version").Output()
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if v, ok := strings.CutPrefix(line, "Version:"); ok {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
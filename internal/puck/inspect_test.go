@@ -0,0 +1,116 @@
+package puck
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTar writes an uncompressed tarball of the given files, the way
+// podman exports checkpoints
+func writeTar(path string, files map[string]string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			return err
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+func fileChecksum(t *testing.T, path string) string {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestInspectSnapshot(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("summarizes a checkpoint", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+
+		mock.CheckpointFunc = func(ctx context.Context, nameOrID string, opts podman.CheckpointOptions) error {
+			return writeTar(opts.ExportPath, map[string]string{
+				"checkpoint/inventory.img": "inv",
+				"checkpoint/pages-1.img":   "pages",
+				"config.dump":              "{}",
+				"spec.dump":                "{}",
+				"rootfs-diff.tar":          "rootfs",
+				"volumes/cache.tar":        "cached",
+			})
+		}
+		_, err := mgr.Create(ctx, CreateOptions{Name: "inspect-puck"})
+		require.NoError(t, err)
+		snapshot, err := mgr.CreateSnapshot(ctx, SnapshotCreateOptions{PuckName: "inspect-puck", SnapshotName: "v1"})
+		require.NoError(t, err)
+		require.NoError(t, mgr.store.UpdateSnapshotTags(ctx, snapshot.ID, []string{"good"}))
+
+		info, err := mgr.InspectSnapshot(ctx, SnapshotInspectOptions{PuckName: "inspect-puck", SnapshotName: "good"})
+		require.NoError(t, err)
+		assert.Equal(t, snapshot.ID, info.Snapshot.ID)
+		assert.Equal(t, "3.19", info.Snapshot.CRIUVersion)
+		assert.Equal(t, "none", info.Compression)
+		assert.Equal(t, fileChecksum(t, snapshot.Path), info.Checksum)
+		assert.Equal(t, 6, info.Entries)
+		assert.Equal(t, 2, info.CheckpointImages)
+		assert.Equal(t, int64(len("rootfs")), info.RootfsDiff)
+		assert.Equal(t, []SnapshotVolume{{Name: "cache", Size: int64(len("cached"))}}, info.Volumes)
+		assert.Empty(t, info.Files)
+	})
+
+	t.Run("lists files of an image snapshot's volumes", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+
+		p, err := mgr.Create(ctx, CreateOptions{Name: "image-puck"})
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(p.VolumeDir, "home", "notes.txt"), []byte("notes"), 0644))
+		snapshot, err := mgr.CreateSnapshot(ctx, SnapshotCreateOptions{PuckName: "image-puck", SnapshotName: "v1", Mode: store.SnapshotModeImage})
+		require.NoError(t, err)
+
+		info, err := mgr.InspectSnapshot(ctx, SnapshotInspectOptions{PuckName: "image-puck", SnapshotName: "v1", Files: true})
+		require.NoError(t, err)
+		assert.Equal(t, "gzip", info.Compression)
+		assert.Equal(t, fileChecksum(t, snapshot.Path), info.Checksum)
+		assert.Empty(t, info.Snapshot.CRIUVersion)
+		assert.Equal(t, []SnapshotVolume{{Name: "etc"}, {Name: "home", Size: int64(len("notes"))}, {Name: "var"}}, info.Volumes)
+
+		var names []string
+		for _, f := range info.Files {
+			names = append(names, f.Name)
+		}
+		assert.Contains(t, names, "home/notes.txt")
+		assert.Len(t, info.Files, info.Entries)
+	})
+
+	t.Run("fails for a missing snapshot", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "empty-puck"})
+		require.NoError(t, err)
+		_, err = mgr.InspectSnapshot(ctx, SnapshotInspectOptions{PuckName: "empty-puck", SnapshotName: "nope"})
+		assert.Error(t, err)
+	})
+}
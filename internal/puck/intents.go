@@ -0,0 +1,106 @@
+package puck
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// RecoveredIntent is an operation the daemon found unfinished at startup
+type RecoveredIntent struct {
+	Op     store.IntentOp `json:"op"`
+	Puck   string         `json:"puck"`
+	Result string         `json:"result"` // "undone" or "finished"
+}
+
+// RecoverIntents settles operations a previous daemon journaled but never
+// finished. Creates are undone, removing the container and volume
+// directory they made; destroys are carried through, since their
+// container may already be gone.
+func (m *Manager) RecoverIntents(ctx context.Context) ([]RecoveredIntent, error) {
+	intents, err := m.store.ListIntents(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var recovered []RecoveredIntent
+	var failed []string
+	for _, in := range intents {
+		var result string
+		switch in.Op {
+		case store.IntentCreate:
+			result, err = m.undoCreate(ctx, in)
+		case store.IntentDestroy:
+			result, err = m.finishDestroy(ctx, in)
+		case store.IntentReplace:
+			result, err = m.undoReplace(ctx, in)
+		default:
+			err = fmt.Errorf("unknown operation %q", in.Op)
+		}
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s of '%s': %v", in.Op, in.PuckName, err))
+			continue
+		}
+
+		if err := m.store.FinishIntent(ctx, in.ID); err != nil {
+			return recovered, err
+		}
+		recovered = append(recovered, RecoveredIntent{Op: in.Op, Puck: in.PuckName, Result: result})
+	}
+
+	if len(failed) > 0 {
+		return recovered, fmt.Errorf("failed to recover %s", strings.Join(failed, "; "))
+	}
+	return recovered, nil
+}
+
+// undoCreate removes what an unfinished create left behind
+func (m *Manager) undoCreate(ctx context.Context, in *store.Intent) (string, error) {
+	// The puck was saved, so the create did finish
+	if _, err := m.store.GetPuck(ctx, in.PuckName); err == nil {
+		return "finished", nil
+	}
+
+	// The container may exist without its ID having been journaled; its
+	// label tells it apart from an unrelated container of the same name
+	containerID := in.ContainerID
+	if containerID == "" {
+		if data, err := m.podman.InspectContainer(ctx, in.PuckName); err == nil && data.Config != nil && data.Config.Labels["puck.id"] == in.PuckID {
+			containerID = data.ID
+		}
+	}
+	if containerID != "" {
+		if exists, _ := m.podman.ContainerExists(ctx, containerID); exists {
+			if err := m.podman.RemoveContainer(ctx, containerID, true); err != nil {
+				return "", fmt.Errorf("removing container: %w", err)
+			}
+		}
+	}
+
+	if in.OwnsVolume && in.VolumeDir != "" {
+		if err := os.RemoveAll(in.VolumeDir); err != nil {
+			return "", fmt.Errorf("removing volume directory: %w", err)
+		}
+	}
+	if err := m.store.DeletePortReservationsByPuck(ctx, in.PuckName); err != nil {
+		return "", fmt.Errorf("releasing host port: %w", err)
+	}
+	if err := m.store.DeleteEndpointsByPuck(ctx, in.PuckName); err != nil {
+		return "", fmt.Errorf("removing endpoints: %w", err)
+	}
+	return "undone", nil
+}
+
+// finishDestroy completes a destroy that didn't get to remove the puck
+func (m *Manager) finishDestroy(ctx context.Context, in *store.Intent) (string, error) {
+	if _, err := m.store.GetPuck(ctx, in.PuckName); err != nil {
+		return "finished", nil
+	}
+	if err := m.Destroy(ctx, in.PuckName, true); err != nil {
+		return "", err
+	}
+	return "finished", nil
+}
@@ -0,0 +1,116 @@
+package puck
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containers/podman/v5/libpod/define"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecoverIntents(t *testing.T) {
+	t.Run("completed operations leave no intents", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "web"})
+		require.NoError(t, err)
+		require.NoError(t, mgr.Destroy(ctx, "web", false))
+
+		mock.StartContainerFunc = func(context.Context, string) error { return assert.AnError }
+		_, err = mgr.Create(ctx, CreateOptions{Name: "broken"})
+		require.Error(t, err)
+
+		intents, err := mgr.store.ListIntents(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, intents)
+	})
+
+	t.Run("undoes an interrupted create", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		volumeDir := filepath.Join(mgr.cfg.PucksDir(), "web")
+		require.NoError(t, os.MkdirAll(filepath.Join(volumeDir, "home"), 0755))
+		require.NoError(t, mgr.store.BeginIntent(ctx, &store.Intent{
+			Op: store.IntentCreate, PuckName: "web", PuckID: "uuid-1", ContainerID: "half-made", VolumeDir: volumeDir, OwnsVolume: true,
+		}))
+
+		recovered, err := mgr.RecoverIntents(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []RecoveredIntent{{Op: store.IntentCreate, Puck: "web", Result: "undone"}}, recovered)
+		assert.True(t, mock.WasCalled("RemoveContainer"))
+		assert.NoDirExists(t, volumeDir)
+
+		intents, err := mgr.store.ListIntents(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, intents)
+	})
+
+	t.Run("finds the container by its label", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		mock.InspectContainerFunc = func(ctx context.Context, nameOrID string) (*define.InspectContainerData, error) {
+			return &define.InspectContainerData{ID: "found-id", Config: &define.InspectContainerConfig{Labels: map[string]string{"puck.id": "uuid-1"}}}, nil
+		}
+		var removed []string
+		mock.RemoveContainerFunc = func(ctx context.Context, nameOrID string, force bool) error {
+			removed = append(removed, nameOrID)
+			return nil
+		}
+		require.NoError(t, mgr.store.BeginIntent(ctx, &store.Intent{Op: store.IntentCreate, PuckName: "web", PuckID: "uuid-1"}))
+		require.NoError(t, mgr.store.BeginIntent(ctx, &store.Intent{Op: store.IntentCreate, PuckName: "web", PuckID: "uuid-2"}))
+
+		_, err := mgr.RecoverIntents(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"found-id"}, removed, "only the container labeled as ours")
+	})
+
+	t.Run("leaves volume directories it didn't create", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		volumeDir := filepath.Join(mgr.cfg.PucksDir(), "web")
+		require.NoError(t, os.MkdirAll(volumeDir, 0755))
+		require.NoError(t, mgr.store.BeginIntent(ctx, &store.Intent{Op: store.IntentCreate, PuckName: "web", VolumeDir: volumeDir}))
+
+		_, err := mgr.RecoverIntents(ctx)
+		require.NoError(t, err)
+		assert.DirExists(t, volumeDir)
+	})
+
+	t.Run("finishes an interrupted destroy", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		p, err := mgr.Create(ctx, CreateOptions{Name: "web"})
+		require.NoError(t, err)
+		require.NoError(t, mgr.store.BeginIntent(ctx, &store.Intent{Op: store.IntentDestroy, PuckName: "web", ContainerID: p.ID, VolumeDir: p.VolumeDir}))
+
+		// The container went before the daemon died
+		mock.RemoveContainerFunc = func(ctx context.Context, nameOrID string, force bool) error {
+			return assert.AnError
+		}
+
+		recovered, err := mgr.RecoverIntents(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []RecoveredIntent{{Op: store.IntentDestroy, Puck: "web", Result: "finished"}}, recovered)
+
+		_, err = mgr.Get(ctx, "web")
+		assert.Error(t, err)
+		assert.NoDirExists(t, p.VolumeDir)
+		intents, err := mgr.store.ListIntents(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, intents)
+	})
+}
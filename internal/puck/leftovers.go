@@ -0,0 +1,182 @@
+package puck
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// dataDirLabel marks the containers a daemon creates with its data
+// directory, so cleaning up after it leaves alone the containers of other
+// daemons using the same Podman
+const dataDirLabel = "puck.data_dir"
+
+// Leftover is a container or volume directory that no puck has, found
+// at startup after the intent journal was settled
+type Leftover struct {
+	Kind   string `json:"kind"` // "container" or "volume-dir"
+	Puck   string `json:"puck"`
+	ID     string `json:"id,omitempty"`   // of the container
+	Path   string `json:"path,omitempty"` // of the volume directory
+	Result string `json:"result"`         // "removed", "adopted" or "kept"
+	Detail string `json:"detail,omitempty"`
+}
+
+// CleanLeftovers repairs what creates and recreates the journal doesn't
+// cover left behind when the daemon was killed partway through: the
+// containers this daemon made that no puck has, and volume directories
+// of pucks that don't exist. A container made for a puck whose own
+// container is gone is adopted by the puck; other containers are removed.
+// Volume directories are removed when they hold nothing but what create
+// writes, and otherwise kept for the user to look at. Operations still in
+// the journal are left to RecoverIntents.
+func (m *Manager) CleanLeftovers(ctx context.Context) ([]Leftover, error) {
+	pucks, err := m.store.ListPucks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	intents, err := m.store.ListIntents(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]*store.Puck, len(pucks))
+	volumeDirs := make(map[string]bool, len(pucks))
+	for _, p := range pucks {
+		byID[p.ID] = p
+		volumeDirs[filepath.Clean(p.VolumeDir)] = true
+	}
+	pending := make(map[string]bool)
+	for _, in := range intents {
+		pending[in.PuckID] = true
+		pending[in.ContainerID] = true
+		volumeDirs[filepath.Clean(in.VolumeDir)] = true
+		if in.Op == store.IntentReplace {
+			volumeDirs[replacedVolumeDir(in.VolumeDir)] = true
+		}
+	}
+
+	var leftovers []Leftover
+	var failed []string
+
+	containers, err := m.podman.ListContainers(ctx, dataDirLabel+"="+m.cfg.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range containers {
+		puckID := c.Labels["puck.id"]
+		if puckID == "" || pending[puckID] || pending[c.ID] {
+			continue
+		}
+		p := byID[puckID]
+		if p != nil && p.ContainerID == c.ID {
+			continue
+		}
+
+		l := Leftover{Kind: "container", Puck: c.Labels["puck.name"], ID: c.ID}
+		if p != nil {
+			l.Puck = p.Name
+			exists, err := m.podman.ContainerExists(ctx, p.ContainerID)
+			if err != nil {
+				failed = append(failed, fmt.Sprintf("checking container of '%s': %v", p.Name, err))
+				continue
+			}
+			if !exists {
+				if err := m.adoptContainer(ctx, p, c.ID, c.Running); err != nil {
+					failed = append(failed, fmt.Sprintf("adopting container %s for '%s': %v", shortPuckID(c.ID), p.Name, err))
+					continue
+				}
+				l.Result = "adopted"
+				l.Detail = fmt.Sprintf("its container %s was gone", shortPuckID(p.ContainerID))
+				leftovers = append(leftovers, l)
+				continue
+			}
+			l.Detail = "a second container for the puck"
+		} else {
+			l.Detail = "no puck has it"
+		}
+		if err := m.podman.RemoveContainer(ctx, c.ID, true); err != nil {
+			failed = append(failed, fmt.Sprintf("removing container %s: %v", shortPuckID(c.ID), err))
+			continue
+		}
+		l.Result = "removed"
+		leftovers = append(leftovers, l)
+	}
+
+	entries, err := os.ReadDir(m.cfg.PucksDir())
+	if err != nil && !os.IsNotExist(err) {
+		return leftovers, err
+	}
+	for _, e := range entries {
+		path := filepath.Join(m.cfg.PucksDir(), e.Name())
+		if !e.IsDir() || volumeDirs[path] {
+			continue
+		}
+		l := Leftover{Kind: "volume-dir", Puck: e.Name(), Path: path}
+		files, err := userFiles(path)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("reading volume directory %s: %v", path, err))
+			continue
+		}
+		if len(files) > 0 {
+			l.Result = "kept"
+			l.Detail = "no puck has it, but it holds " + strings.Join(files, ", ")
+		} else if err := os.RemoveAll(path); err != nil {
+			failed = append(failed, fmt.Sprintf("removing volume directory %s: %v", path, err))
+			continue
+		} else {
+			l.Result = "removed"
+			l.Detail = "no puck has it"
+		}
+		leftovers = append(leftovers, l)
+	}
+
+	if len(failed) > 0 {
+		return leftovers, fmt.Errorf("failed to clean up %s", strings.Join(failed, "; "))
+	}
+	return leftovers, nil
+}
+
+// adoptContainer points a puck whose container is gone at a container
+// made for it, as a recreate does once its new container is up
+func (m *Manager) adoptContainer(ctx context.Context, p *store.Puck, containerID string, running bool) error {
+	if err := m.store.UpdatePuckContainerID(ctx, p.Name, containerID); err != nil {
+		return err
+	}
+	if !running && p.Status.Up() {
+		return m.store.UpdatePuckStatus(ctx, p.Name, store.StatusStopped)
+	}
+	return nil
+}
+
+// userFiles lists, relative to dir, the files in a volume directory other
+// than those create writes itself, up to a few
+func userFiles(dir string) ([]string, error) {
+	generated := map[string]bool{
+		filepath.Join("etc", motdFile): true,
+		filepath.Join("etc", envFile):  true,
+	}
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, path)
+		if !generated[rel] {
+			files = append(files, rel)
+		}
+		if len(files) == 3 {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,84 @@
+package puck
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleanLeftovers(t *testing.T) {
+	t.Run("removes containers no puck has and adopts one whose puck lost its own", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		web, err := mgr.Create(ctx, CreateOptions{Name: "web"})
+		require.NoError(t, err)
+		db, err := mgr.Create(ctx, CreateOptions{Name: "db"})
+		require.NoError(t, err)
+		require.NoError(t, mgr.store.BeginIntent(ctx, &store.Intent{Op: store.IntentCreate, PuckName: "api", PuckID: "uuid-pending"}))
+
+		var label string
+		mock.ListContainersFunc = func(ctx context.Context, l string) ([]podman.Container, error) {
+			label = l
+			return []podman.Container{
+				{ID: web.ContainerID, Labels: map[string]string{"puck.id": web.ID, "puck.name": "web"}, Running: true},
+				{ID: "spare", Labels: map[string]string{"puck.id": web.ID, "puck.name": "web"}},
+				{ID: "replacement", Labels: map[string]string{"puck.id": db.ID, "puck.name": "db"}},
+				{ID: "orphan", Labels: map[string]string{"puck.id": "uuid-gone", "puck.name": "gone"}},
+				{ID: "in-journal", Labels: map[string]string{"puck.id": "uuid-pending", "puck.name": "api"}},
+			}, nil
+		}
+		mock.ContainerExistsFunc = func(ctx context.Context, id string) (bool, error) { return id != db.ContainerID, nil }
+		var removed []string
+		mock.RemoveContainerFunc = func(ctx context.Context, id string, force bool) error {
+			removed = append(removed, id)
+			return nil
+		}
+
+		leftovers, err := mgr.CleanLeftovers(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, dataDirLabel+"="+mgr.cfg.DataDir, label)
+		assert.Equal(t, []string{"spare", "orphan"}, removed)
+		require.Len(t, leftovers, 3)
+		assert.Equal(t, Leftover{Kind: "container", Puck: "db", ID: "replacement", Result: "adopted", Detail: "its container " + shortPuckID(db.ContainerID) + " was gone"}, leftovers[1])
+
+		got, err := mgr.store.GetPuck(ctx, "db")
+		require.NoError(t, err)
+		assert.Equal(t, "replacement", got.ContainerID)
+		assert.Equal(t, store.StatusStopped, got.Status, "the adopted container isn't running")
+	})
+
+	t.Run("removes empty volume directories and keeps ones with files", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "web"})
+		require.NoError(t, err)
+
+		empty := filepath.Join(mgr.cfg.PucksDir(), "crashed")
+		require.NoError(t, os.MkdirAll(filepath.Join(empty, "home"), 0755))
+		require.NoError(t, os.MkdirAll(filepath.Join(empty, "etc"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(empty, "etc", motdFile), []byte("hi"), 0644))
+		full := filepath.Join(mgr.cfg.PucksDir(), "kept")
+		require.NoError(t, os.MkdirAll(filepath.Join(full, "home"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(full, "home", "notes.txt"), []byte("work"), 0644))
+
+		leftovers, err := mgr.CleanLeftovers(ctx)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []Leftover{
+			{Kind: "volume-dir", Puck: "crashed", Path: empty, Result: "removed", Detail: "no puck has it"},
+			{Kind: "volume-dir", Puck: "kept", Path: full, Result: "kept", Detail: "no puck has it, but it holds " + filepath.Join("home", "notes.txt")},
+		}, leftovers)
+		assert.NoDirExists(t, empty)
+		assert.DirExists(t, full)
+		assert.DirExists(t, filepath.Join(mgr.cfg.PucksDir(), "web"))
+	})
+}
@@ -0,0 +1,211 @@
+package puck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/go-units"
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// machine inspects the Podman Machine pucks run in, or returns nil when
+// Podman runs on this host or checking shares is turned off
+func (m *Manager) machine(ctx context.Context) (*podman.MachineInfo, error) {
+	if !m.podman.IsMachine() || m.cfg.Machine == config.MachineNone {
+		return nil, nil
+	}
+	mi, err := m.podman.Machine(ctx, m.machineName())
+	if err != nil {
+		return nil, fmt.Errorf("%w\nSet machine: %s in the config to skip checking mounts against the machine's shares", err, config.MachineNone)
+	}
+	return mi, nil
+}
+
+// checkMachineMounts makes sure a new puck's volume and mounts are shared
+// into the machine, as bind mounts of anything else fail or come up
+// empty. Unshared mounts are synced instead when the config says so;
+// synced mounts only need the volume to be shared.
+func (m *Manager) checkMachineMounts(mi *podman.MachineInfo, volumeDir string, mounts []store.Mount) error {
+	if mi == nil {
+		return nil
+	}
+	if _, share, ok := mi.VMPath(volumeDir); !ok {
+		return m.unsharedError(mi, "the puck data directory "+volumeDir, filepath.Dir(volumeDir), false)
+	} else if share != nil && share.ReadOnly {
+		return fmt.Errorf("the puck data directory %s is shared read-only into podman machine '%s'", volumeDir, mi.Name)
+	}
+
+	for i := range mounts {
+		mnt := &mounts[i]
+		if mnt.Sync {
+			continue
+		}
+		_, share, ok := mi.VMPath(mnt.Source)
+		if !ok && m.cfg.MachineSyncUnshared {
+			mnt.Sync = true
+			continue
+		}
+		if !ok {
+			return m.unsharedError(mi, "mount source "+mnt.Source, mnt.Source, true)
+		}
+		if share != nil && share.ReadOnly && !mnt.ReadOnly {
+			return fmt.Errorf("mount source %s is shared read-only into podman machine '%s'; mount it read-only or sync it instead", mnt.Source, mi.Name)
+		}
+	}
+	return nil
+}
+
+// unsharedError explains that what isn't shared into the machine and how
+// to share dir, which covers it, or to sync it when it is a mount
+func (m *Manager) unsharedError(mi *podman.MachineInfo, what, dir string, canSync bool) error {
+	shares := make([]string, 0, len(mi.Mounts))
+	for _, s := range mi.Mounts {
+		shares = append(shares, s.Source)
+	}
+	sharing := "nothing"
+	if len(shares) > 0 {
+		sharing = strings.Join(shares, ", ")
+	}
+
+	cmd := "podman machine init"
+	if m.cfg.MachineVolumeDriver != "" {
+		cmd += " --volume-driver " + m.cfg.MachineVolumeDriver
+	}
+	for _, s := range mi.Mounts {
+		cmd += fmt.Sprintf(" -v %s:%s", s.Source, s.Target)
+	}
+	cmd += fmt.Sprintf(" -v %s:%s %s", dir, filepath.ToSlash(dir), mi.Name)
+
+	msg := fmt.Sprintf("%s is not shared into podman machine '%s', which shares %s\n"+
+		"Share it by recreating the machine:\n  podman machine rm %s\n  %s",
+		what, mi.Name, sharing, mi.Name, cmd)
+	if canSync {
+		msg += "\nor set sync: true on the mount, or machine_sync_unshared: true in the config, to copy it in instead"
+	}
+	return errors.New(msg)
+}
+
+// machinePath is where a host path is found in the machine, or the path
+// itself when there is no machine or it isn't shared
+func machinePath(mi *podman.MachineInfo, hostPath string) string {
+	if mi == nil {
+		return hostPath
+	}
+	if p, _, ok := mi.VMPath(hostPath); ok {
+		return p
+	}
+	return hostPath
+}
+
+// MachineStatus is a Podman Machine's size next to what pucks need of it
+type MachineStatus struct {
+	Machine  *podman.MachineInfo `json:"machine"`
+	Running  int                 `json:"running"`
+	Reserved store.Resources     `json:"reserved"` // limits of the running pucks
+	Warnings []string            `json:"warnings,omitempty"`
+}
+
+// MachineStatus reports the size of the Podman Machine pucks run in, with
+// warnings where it is too small for them
+func (m *Manager) MachineStatus(ctx context.Context) (*MachineStatus, error) {
+	if !m.podman.IsMachine() {
+		return nil, fmt.Errorf("podman runs on this host rather than in a Podman Machine")
+	}
+	mi, err := m.podman.Machine(ctx, m.machineName())
+	if err != nil {
+		return nil, err
+	}
+	pucks, err := m.store.ListPucks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &MachineStatus{Machine: mi}
+	for _, p := range pucks {
+		if p.Status.Up() {
+			status.Running++
+			status.Reserved.Memory += p.Resources.Memory
+			status.Reserved.CPUs += p.Resources.CPUs
+		}
+	}
+	status.Warnings = m.machineWarnings(mi.Resources, status.Reserved, pucks)
+	return status, nil
+}
+
+// SetMachineResources resizes the Podman Machine with podman machine set.
+// The machine must be stopped first, and its disk can only grow.
+func (m *Manager) SetMachineResources(ctx context.Context, res podman.MachineResources) (*MachineStatus, error) {
+	if res == (podman.MachineResources{}) {
+		return nil, fmt.Errorf("nothing to change; give CPUs, memory or a disk size")
+	}
+	if res.Memory < 0 || res.DiskSize < 0 {
+		return nil, fmt.Errorf("memory and disk size cannot be negative")
+	}
+	// Podman sizes memory in MiB and disks in GiB
+	res.Memory = roundUp(res.Memory, units.MiB)
+	res.DiskSize = roundUp(res.DiskSize, units.GiB)
+	status, err := m.MachineStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mi := status.Machine
+	if mi.State == podman.MachineRunning {
+		return nil, fmt.Errorf("podman machine '%s' is running and must be stopped to be resized, which stops every puck:\n  podman machine stop %s\nthen start it again with podman machine start %s", mi.Name, mi.Name, mi.Name)
+	}
+	if res.DiskSize > 0 && res.DiskSize < mi.Resources.DiskSize {
+		return nil, fmt.Errorf("a machine's disk can only grow, and '%s' has %s", mi.Name, units.BytesSize(float64(mi.Resources.DiskSize)))
+	}
+
+	if err := m.podman.SetMachineResources(ctx, mi.Name, res); err != nil {
+		return nil, err
+	}
+	return m.MachineStatus(ctx)
+}
+
+// machineName is the configured machine, or empty for the default one
+func (m *Manager) machineName() string {
+	if m.cfg.Machine == config.MachineNone {
+		return ""
+	}
+	return m.cfg.Machine
+}
+
+// machineWarnings describes where a machine of the given size is too
+// small for the pucks' limits, what the running ones reserve, or the
+// configured budget
+func (m *Manager) machineWarnings(size podman.MachineResources, reserved store.Resources, pucks []*store.Puck) []string {
+	var warnings []string
+	memory := func(b int64) string { return units.BytesSize(float64(b)) }
+
+	if size.Memory > 0 && reserved.Memory > size.Memory {
+		warnings = append(warnings, fmt.Sprintf("running pucks reserve %s of memory, more than the machine's %s", memory(reserved.Memory), memory(size.Memory)))
+	}
+	if size.CPUs > 0 && reserved.CPUs > float64(size.CPUs) {
+		warnings = append(warnings, fmt.Sprintf("running pucks reserve %g CPUs, more than the machine's %d", reserved.CPUs, size.CPUs))
+	}
+	for _, p := range pucks {
+		if size.Memory > 0 && p.Resources.Memory > size.Memory {
+			warnings = append(warnings, fmt.Sprintf("puck '%s' is limited to %s of memory, more than the machine has", p.Name, memory(p.Resources.Memory)))
+		}
+		if size.CPUs > 0 && p.Resources.CPUs > float64(size.CPUs) {
+			warnings = append(warnings, fmt.Sprintf("puck '%s' is limited to %g CPUs, more than the machine has", p.Name, p.Resources.CPUs))
+		}
+	}
+	if size.Memory > 0 && m.cfg.BudgetMemory > size.Memory {
+		warnings = append(warnings, fmt.Sprintf("budget_memory is %s, more than the machine's %s", memory(m.cfg.BudgetMemory), memory(size.Memory)))
+	}
+	if size.CPUs > 0 && m.cfg.BudgetCPUs > float64(size.CPUs) {
+		warnings = append(warnings, fmt.Sprintf("budget_cpus is %g, more than the machine's %d", m.cfg.BudgetCPUs, size.CPUs))
+	}
+	return warnings
+}
+
+// roundUp rounds n up to a multiple of unit
+func roundUp(n, unit int64) int64 {
+	return (n + unit - 1) / unit * unit
+}
@@ -0,0 +1,215 @@
+package puck
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMachineMounts(t *testing.T) {
+	// setupMachine makes the mock run under a machine sharing the test
+	// manager's data directory, at /mnt/data in the VM
+	setupMachine := func(t *testing.T) (*Manager, *[]podman.CreateContainerOptions, func()) {
+		mgr, mock, cleanup := setupTestManager(t)
+		mock.IsMachineFunc = func() bool { return true }
+		mock.MachineFunc = func(ctx context.Context, name string) (*podman.MachineInfo, error) {
+			return &podman.MachineInfo{Name: "podman-machine-default", VMType: "applehv", Mounts: []podman.MachineMount{
+				{Source: mgr.cfg.DataDir, Target: "/mnt/data", Type: config.MachineVirtiofs},
+			}}, nil
+		}
+		created := &[]podman.CreateContainerOptions{}
+		mock.CreateContainerFunc = func(ctx context.Context, opts podman.CreateContainerOptions) (string, error) {
+			*created = append(*created, opts)
+			return "container-" + opts.Name, nil
+		}
+		return mgr, created, cleanup
+	}
+
+	t.Run("translates shared paths to the VM", func(t *testing.T) {
+		mgr, created, cleanup := setupMachine(t)
+		defer cleanup()
+
+		src := filepath.Join(mgr.cfg.DataDir, "src")
+		require.NoError(t, os.MkdirAll(src, 0755))
+
+		_, err := mgr.Create(context.Background(), CreateOptions{Name: "web", Mounts: []store.Mount{{Source: src, Target: "/workspace"}}})
+		require.NoError(t, err)
+
+		require.Len(t, *created, 1)
+		opts := (*created)[0]
+		assert.Equal(t, []podman.Mount{{Source: "/mnt/data/src", Destination: "/workspace"}}, opts.Mounts)
+		assert.Equal(t, "/home", opts.Volumes["/mnt/data/pucks/web/home"])
+	})
+
+	t.Run("refuses mounts that aren't shared", func(t *testing.T) {
+		mgr, created, cleanup := setupMachine(t)
+		defer cleanup()
+		mgr.cfg.MachineVolumeDriver = config.MachineVirtiofs
+
+		src := t.TempDir()
+		_, err := mgr.Create(context.Background(), CreateOptions{Name: "web", Mounts: []store.Mount{{Source: src, Target: "/workspace"}}})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "mount source "+src+" is not shared into podman machine 'podman-machine-default'")
+		assert.Contains(t, err.Error(), "--volume-driver virtiofs -v "+mgr.cfg.DataDir+":/mnt/data -v "+src+":"+src)
+		assert.Contains(t, err.Error(), "sync: true")
+		assert.Empty(t, *created)
+	})
+
+	t.Run("syncs unshared mounts when configured to", func(t *testing.T) {
+		mgr, created, cleanup := setupMachine(t)
+		defer cleanup()
+		mgr.cfg.MachineSyncUnshared = true
+
+		src := t.TempDir()
+		p, err := mgr.Create(context.Background(), CreateOptions{Name: "web", Mounts: []store.Mount{{Source: src, Target: "/workspace"}}})
+		require.NoError(t, err)
+
+		assert.True(t, p.Spec.Mounts[0].Sync)
+		require.Len(t, *created, 1)
+		assert.Equal(t, []podman.Mount{{Source: "/mnt/data/pucks/web/sync/0", Destination: "/workspace"}}, (*created)[0].Mounts)
+	})
+
+	t.Run("refuses writable mounts of read-only shares", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		src := t.TempDir()
+		mock.IsMachineFunc = func() bool { return true }
+		mock.MachineFunc = func(ctx context.Context, name string) (*podman.MachineInfo, error) {
+			return &podman.MachineInfo{Name: "dev", Mounts: []podman.MachineMount{
+				{Source: mgr.cfg.DataDir, Target: mgr.cfg.DataDir},
+				{Source: src, Target: src, ReadOnly: true},
+			}}, nil
+		}
+
+		_, err := mgr.Create(context.Background(), CreateOptions{Name: "web", Mounts: []store.Mount{{Source: src, Target: "/workspace"}}})
+		assert.ErrorContains(t, err, "shared read-only")
+
+		_, err = mgr.Create(context.Background(), CreateOptions{Name: "ro", Mounts: []store.Mount{{Source: src, Target: "/workspace", ReadOnly: true}}})
+		assert.NoError(t, err)
+	})
+
+	t.Run("skips checks when turned off", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		mgr.cfg.Machine = config.MachineNone
+		mock.IsMachineFunc = func() bool { return true }
+
+		_, err := mgr.Create(context.Background(), CreateOptions{Name: "web", Mounts: []store.Mount{{Source: t.TempDir(), Target: "/workspace"}}})
+		require.NoError(t, err)
+		assert.Equal(t, 0, mock.CallCount("Machine"))
+	})
+}
+
+func TestMachineVMPath(t *testing.T) {
+	mi := &podman.MachineInfo{Mounts: []podman.MachineMount{
+		{Source: "/Users", Target: "/Users"},
+		{Source: "/Users/me/code", Target: "/code"},
+	}}
+
+	tests := []struct {
+		host string
+		vm   string
+		ok   bool
+	}{
+		{"/Users/me", "/Users/me", true},
+		{"/Users/me/code/app", "/code/app", true},
+		{"/Users/me/code", "/code", true},
+		{"/Users2", "", false},
+		{"/Volumes/ext", "", false},
+	}
+	for _, tt := range tests {
+		vm, _, ok := mi.VMPath(tt.host)
+		assert.Equal(t, tt.ok, ok, tt.host)
+		assert.Equal(t, tt.vm, vm, tt.host)
+	}
+}
+
+func TestMachineResources(t *testing.T) {
+	// setupMachine runs two pucks limited to 3GiB and 2 CPUs each, then
+	// moves the mock under a stopped 4GiB, 2 CPU machine
+	setupMachine := func(t *testing.T) (*Manager, *podman.MockClient, *podman.MachineInfo, func()) {
+		mgr, mock, cleanup := setupTestManager(t)
+		ctx := context.Background()
+		for _, name := range []string{"api", "worker"} {
+			_, err := mgr.Create(ctx, CreateOptions{Name: name})
+			require.NoError(t, err)
+			_, err = mgr.SetResources(ctx, name, store.Resources{Memory: 3 << 30, CPUs: 2})
+			require.NoError(t, err)
+		}
+
+		mi := &podman.MachineInfo{Name: "dev", State: "stopped", Resources: podman.MachineResources{CPUs: 2, Memory: 4 << 30, DiskSize: 100 << 30}}
+		mock.IsMachineFunc = func() bool { return true }
+		mock.MachineFunc = func(ctx context.Context, name string) (*podman.MachineInfo, error) {
+			return mi, nil
+		}
+		return mgr, mock, mi, cleanup
+	}
+
+	t.Run("warns when the machine is too small", func(t *testing.T) {
+		mgr, _, _, cleanup := setupMachine(t)
+		defer cleanup()
+		mgr.cfg.BudgetCPUs = 8
+
+		status, err := mgr.MachineStatus(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 2, status.Running)
+		assert.Equal(t, store.Resources{Memory: 6 << 30, CPUs: 4}, status.Reserved)
+		assert.Equal(t, []string{
+			"running pucks reserve 6GiB of memory, more than the machine's 4GiB",
+			"running pucks reserve 4 CPUs, more than the machine's 2",
+			"budget_cpus is 8, more than the machine's 2",
+		}, status.Warnings)
+	})
+
+	t.Run("resizes a stopped machine", func(t *testing.T) {
+		mgr, mock, mi, cleanup := setupMachine(t)
+		defer cleanup()
+
+		var set podman.MachineResources
+		mock.SetMachineResourcesFunc = func(ctx context.Context, name string, res podman.MachineResources) error {
+			assert.Equal(t, "dev", name)
+			set = res
+			mi.Resources.Memory = res.Memory
+			mi.Resources.CPUs = res.CPUs
+			return nil
+		}
+
+		status, err := mgr.SetMachineResources(context.Background(), podman.MachineResources{CPUs: 4, Memory: 8<<30 - 1})
+		require.NoError(t, err)
+		assert.Equal(t, podman.MachineResources{CPUs: 4, Memory: 8 << 30}, set)
+		assert.Empty(t, status.Warnings)
+	})
+
+	t.Run("refuses to resize a running machine", func(t *testing.T) {
+		mgr, mock, mi, cleanup := setupMachine(t)
+		defer cleanup()
+		mi.State = podman.MachineRunning
+
+		_, err := mgr.SetMachineResources(context.Background(), podman.MachineResources{CPUs: 4})
+		assert.ErrorContains(t, err, "podman machine stop dev")
+		assert.Equal(t, 0, mock.CallCount("SetMachineResources"))
+	})
+
+	t.Run("refuses to shrink the disk", func(t *testing.T) {
+		mgr, _, _, cleanup := setupMachine(t)
+		defer cleanup()
+
+		_, err := mgr.SetMachineResources(context.Background(), podman.MachineResources{DiskSize: 50 << 30})
+		assert.ErrorContains(t, err, "can only grow")
+	})
+
+	t.Run("needs a machine", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+
+		_, err := mgr.MachineStatus(context.Background())
+		assert.ErrorContains(t, err, "rather than in a Podman Machine")
+	})
+}
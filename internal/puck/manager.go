@@ -1,23 +1,105 @@
 package puck
 
 import (
+	"cmp"
 	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"maps"
+	"net"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/sandwich-labs/puck/internal/config"
 	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/scan"
 	"github.com/sandwich-labs/puck/internal/store"
 )
 
 // CreateOptions contains options for creating a new puck
 type CreateOptions struct {
-	Name  string   `json:"name"`
-	Image string   `json:"image"`
-	Ports []string `json:"ports,omitempty"`
+	Name  string         `json:"name"`
+	Image string         `json:"image"`
+	Ports []string       `json:"ports,omitempty"`
+	Init  store.InitMode `json:"init,omitempty"` // defaults to systemd
+	// Override the image's entrypoint and command; nil keeps the image's
+	Entrypoint []string `json:"entrypoint,omitempty"`
+	Command    []string `json:"command,omitempty"`
+	Hostname   string   `json:"hostname,omitempty"`  // defaults to the puck's name
+	DNS        []string `json:"dns,omitempty"`       // nameserver IPs
+	AddHosts   []string `json:"add_hosts,omitempty"` // /etc/hosts entries as host:ip
+	// Kernel parameters and resource limits, e.g. nofile=65536:65536
+	Sysctls map[string]string `json:"sysctls,omitempty"`
+	Ulimits []string          `json:"ulimits,omitempty"`
+	// User namespace mode (keep-id, auto, nomap) or custom ID mappings
+	UserNS string   `json:"userns,omitempty"`
+	UIDMap []string `json:"uidmap,omitempty"`
+	GIDMap []string `json:"gidmap,omitempty"`
+	// "unconfined" or a seccomp profile path / AppArmor profile name
+	Seccomp  string `json:"seccomp,omitempty"`
+	AppArmor string `json:"apparmor,omitempty"`
+	// Restrictions for untrusted code, e.g. store.SandboxStrict
+	Sandbox store.SandboxMode `json:"sandbox,omitempty"`
+	// Seconds to wait for the puck to exit on stop; nil uses the config's
+	StopTimeout *int `json:"stop_timeout,omitempty"`
+	// Pucks to start before this one and stop after it
+	Requires []string `json:"requires,omitempty"`
+	// Project the puck counts against for quotas
+	Project string `json:"project,omitempty"`
+	// Where the puck may open connections to; empty allows anywhere
+	Egress store.EgressPolicy `json:"egress,omitempty"`
+	// Host directories to mount, e.g. a project checkout
+	Mounts []store.Mount `json:"mounts,omitempty"`
+	// Create mount sources missing on the daemon's host
+	CreateMountDirs bool `json:"create_mount_dirs,omitempty"`
+	// Directory on the daemon's host to keep the puck's volumes in, as
+	// <data_dir>/<name>, rather than the data directory, e.g. a faster disk
+	DataDir string `json:"data_dir,omitempty"`
+	// Scripts the daemon runs inside the new puck once it is created
+	Provision []ProvisionScript `json:"provision,omitempty"`
+	// Git repository the daemon clones into the new puck, before its
+	// provisioning scripts run
+	Repo *RepoClone `json:"repo,omitempty"`
+	// Host port to pin the puck to rather than one the allocator picks
+	HostPort int `json:"host_port,omitempty"`
+	// Extra container ports to serve through the router
+	Endpoints []EndpointSpec `json:"endpoints,omitempty"`
+	// Publish the image's exposed ports on host ports podman picks
+	PublishAll bool   `json:"publish_all,omitempty"`
+	Owner      string `json:"-"` // set by the daemon from the caller
+	// Working directory of the caller, for the labels setting
+	Cwd string `json:"cwd,omitempty"`
+
+	// Profile picks the defaults rules to apply, and Shell and User, for
+	// console and exec sessions, override what they give
+	Profile string `json:"profile,omitempty"`
+	Shell   string `json:"shell,omitempty"`
+	User    string `json:"user,omitempty"`
+	// Destroy a puck of the same name first, rather than failing with an
+	// ExistsError; done by the daemon
+	Replace bool `json:"replace,omitempty"`
+	// Pool names the pool to hand a waiting puck out of instead of
+	// creating one; Pool is the pool a puck is made for, set by the daemon
+	FromPool string `json:"from_pool,omitempty"`
+	Pool     string `json:"-"`
+	// Destroy the puck this long after it is created, archiving it first
+	// with TTLSnapshot (see FinalSnapshot)
+	TTL         time.Duration `json:"ttl,omitempty"`
+	TTLSnapshot bool          `json:"ttl_snapshot,omitempty"`
+
+	// Checkpoint archive exported by podman, a path on the daemon's host,
+	// to restore as the new puck instead of starting its image
+	FromCheckpoint string `json:"from_checkpoint,omitempty"`
 }
 
 // Manager handles puck lifecycle operations
@@ -25,31 +107,248 @@ type Manager struct {
 	podman podman.ContainerClient
 	store  *store.DB
 	cfg    *config.Config
+
+	// portFree reports whether a host port is unused; replaced in tests
+	portFree func(port int) bool
+	// ports orders the host ports new pucks may be given
+	ports PortAllocator
+	// criuVersion reports the host's CRIU version for new checkpoints
+	criuVersion func(ctx context.Context) string
+	// firewall loads nftables rules into a container's network namespace,
+	// and lookupIP resolves allowlisted domains; replaced in tests
+	firewall func(ctx context.Context, pid int, rules string) error
+	lookupIP func(ctx context.Context, host string) ([]net.IP, error)
+	// router serves pucks over HTTP; nil when nothing is routed, as
+	// outside the daemon
+	router Router
+	// warm tracks the pulls keeping warm_images fresh
+	warm *warmCache
+	// scanner scans images for vulnerabilities; replaced in tests
+	scanner func(ctx context.Context, image string, opts scan.Options) (*scan.Result, error)
 }
 
 // NewManager creates a new puck manager
 func NewManager(cfg *config.Config, pc podman.ContainerClient, db *store.DB) *Manager {
 	return &Manager{
-		podman: pc,
-		store:  db,
-		cfg:    cfg,
+		podman:      pc,
+		store:       db,
+		cfg:         cfg,
+		portFree:    hostPortFree,
+		ports:       NewPortAllocator(cfg.PortAllocator),
+		criuVersion: hostCRIUVersion,
+		firewall:    netnsFirewall,
+		lookupIP:    lookupIP,
+		warm:        newWarmCache(),
+		scanner:     scan.Scan,
 	}
 }
 
-// BaseHostPort is the starting port for auto-assigned puck ports
-const BaseHostPort = 9000
+// BaseHostPort is the starting port for auto-assigned puck ports, of
+// which there are HostPortCount
+const (
+	BaseHostPort  = 9000
+	HostPortCount = 1000
+)
+
+// ErrPortsExhausted is every host port pucks are given being taken
+var ErrPortsExhausted = errors.New("no available ports")
 
-// Create creates a new puck
+// Create creates a new puck. With Replace, a puck that already has its
+// name is set aside once the new one has passed its checks, then destroyed
+// once the new one is made, or put back if making it fails.
 func (m *Manager) Create(ctx context.Context, opts CreateOptions) (*store.Puck, error) {
-	// Use default image if not specified
+	var r replacement
+	p, err := m.create(ctx, opts, &r)
+	if r.old == nil {
+		return p, err
+	}
+	if err != nil {
+		return nil, m.putBack(ctx, &r, err)
+	}
+	m.discardReplaced(ctx, &r, p)
+	return p, nil
+}
+
+// create makes a new puck, recording in r the puck it replaces, if any,
+// once it has been set aside
+func (m *Manager) create(ctx context.Context, opts CreateOptions, r *replacement) (*store.Puck, error) {
+	// A puck with the name is only replaced when asked, as the new one
+	// takes its volume directory
+	old, err := m.store.GetPuck(ctx, opts.Name)
+	if err != nil {
+		old = nil
+	} else if !opts.Replace {
+		return nil, m.existsError(ctx, opts.Name)
+	}
+
+	// The defaults rules match on labels rendered with the image asked
+	// for, which may be none yet
+	labelData := config.LabelData{
+		Name: opts.Name, Image: opts.Image, Project: opts.Project, User: opts.Owner, Cwd: opts.Cwd,
+	}
+	labels, err := m.cfg.RenderLabels(labelData)
+	if err != nil {
+		return nil, err
+	}
+	defaults, err := m.cfg.PuckDefaults(opts.Profile, opts.Project, labels)
+	if err != nil {
+		return nil, err
+	}
 	if opts.Image == "" {
-		opts.Image = m.cfg.DefaultImage
+		opts.Image = cmp.Or(defaults.Image, m.cfg.DefaultImage)
+		labelData.Image = opts.Image
+		if labels, err = m.cfg.RenderLabels(labelData); err != nil {
+			return nil, err
+		}
+	}
+
+	spec := store.Spec{
+		Init:        opts.Init,
+		Entrypoint:  opts.Entrypoint,
+		Command:     opts.Command,
+		Hostname:    opts.Hostname,
+		DNS:         opts.DNS,
+		AddHosts:    opts.AddHosts,
+		Sysctls:     opts.Sysctls,
+		Ulimits:     opts.Ulimits,
+		UserNS:      opts.UserNS,
+		UIDMap:      opts.UIDMap,
+		GIDMap:      opts.GIDMap,
+		Seccomp:     opts.Seccomp,
+		AppArmor:    opts.AppArmor,
+		Sandbox:     opts.Sandbox,
+		StopTimeout: opts.StopTimeout,
+		PublishAll:  opts.PublishAll,
+		Shell:       cmp.Or(opts.Shell, defaults.Shell),
+		User:        cmp.Or(opts.User, defaults.User),
+		Labels:      labels,
+	}
+	if err := prepareSandbox(&spec, opts); err != nil {
+		return nil, err
+	}
+	if err := validateSpec(spec); err != nil {
+		return nil, err
+	}
+	if err := validateEgress(opts.Egress); err != nil {
+		return nil, err
+	}
+	if spec.Sandbox != "" && opts.Egress.EffectiveMode() != store.EgressAll {
+		return nil, fmt.Errorf("sandboxed pucks have no network, so they take no egress policy")
+	}
+	requires, err := m.checkRequires(ctx, opts.Name, opts.Requires)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateProject(opts.Project); err != nil {
+		return nil, err
+	}
+	if opts.TTL != 0 {
+		if err := validateTTL(opts.TTL); err != nil {
+			return nil, err
+		}
+	} else if opts.TTLSnapshot {
+		return nil, fmt.Errorf("a final snapshot needs a TTL")
+	}
+	if err := m.checkProjectLimits(ctx, &store.Puck{Name: opts.Name, Project: opts.Project}, true); err != nil {
+		return nil, err
+	}
+	if err := validateMounts(opts.Mounts, opts.CreateMountDirs, opts.Owner); err != nil {
+		return nil, err
+	}
+	spec.Mounts = append(spec.Mounts, opts.Mounts...)
+
+	// Shared paths are the daemon user's, for trusted pucks of theirs or
+	// an admin's; a sandbox only sees what it is explicitly given
+	var shared []config.SharedPath
+	if spec.Sandbox == "" && m.trustedOwner(opts.Owner) {
+		if shared, err = m.cfg.CurrentSharedPaths(); err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range shared {
+		spec.Mounts = append(spec.Mounts, store.Mount{Source: s.Path, Target: s.Destination(), ReadOnly: s.ReadOnly})
 	}
 
-	// Find next available host port
-	hostPort, err := m.findAvailablePort(ctx)
+	// Under Podman Machine, mounts only work for paths shared into the VM
+	machine, err := m.machine(ctx)
+	if err != nil {
+		return nil, err
+	}
+	volumeDir, err := m.volumeDir(opts, old)
 	if err != nil {
-		return nil, fmt.Errorf("finding available port: %w", err)
+		return nil, err
+	}
+	if err := m.checkMachineMounts(machine, volumeDir, spec.Mounts); err != nil {
+		return nil, err
+	}
+
+	// A checkpoint brings its own image
+	var warnings []string
+	if opts.FromCheckpoint == "" {
+		warning, err := m.checkImageScan(ctx, opts.Name, opts.Image)
+		if err != nil {
+			return nil, err
+		}
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+
+	// Reserve a host port; sandboxed pucks have no network to route to
+	var hostPort int
+	var resources store.Resources
+	if spec.Sandbox != "" && opts.HostPort != 0 {
+		return nil, fmt.Errorf("sandboxed pucks have no host port")
+	}
+	if spec.Sandbox != "" && len(opts.Endpoints) > 0 {
+		return nil, fmt.Errorf("sandboxed pucks have no network to serve endpoints on")
+	}
+	if err := validateEndpoints(opts.Endpoints); err != nil {
+		return nil, err
+	}
+	if err := validatePorts(opts.Ports); err != nil {
+		return nil, err
+	}
+	if err := validateRepo(opts.Repo); err != nil {
+		return nil, err
+	}
+	if opts.FromCheckpoint != "" {
+		if spec.Sandbox != "" {
+			return nil, fmt.Errorf("sandboxed pucks can't be created from a checkpoint")
+		}
+		if !filepath.IsAbs(opts.FromCheckpoint) {
+			return nil, fmt.Errorf("checkpoint archive path %s must be absolute", opts.FromCheckpoint)
+		}
+		if _, err := os.Stat(opts.FromCheckpoint); err != nil {
+			return nil, fmt.Errorf("checkpoint archive %s is not readable on the daemon's host: %w", opts.FromCheckpoint, err)
+		}
+	}
+
+	// The puck being replaced is only moved out of the way once the new
+	// one has passed its checks and its image is here
+	if old != nil {
+		if opts.FromCheckpoint == "" {
+			if err := m.ensureImage(ctx, opts.Image); err != nil {
+				return nil, err
+			}
+		}
+		if err := m.setAside(ctx, old, volumeDir, r); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.HostPort != 0 {
+		if err := m.pinPort(ctx, opts.Name, opts.HostPort); err != nil {
+			return nil, err
+		}
+		hostPort = opts.HostPort
+	} else if spec.Sandbox == "" {
+		hostPort, err = m.allocatePort(ctx, opts.Name)
+		if err != nil {
+			return nil, fmt.Errorf("finding available port: %w", err)
+		}
+	} else {
+		resources = store.Resources{Memory: SandboxMemory, CPUs: SandboxCPUs}
 	}
 
 	// Create puck record
@@ -61,73 +360,343 @@ func (m *Manager) Create(ctx context.Context, opts CreateOptions) (*store.Puck,
 		Status:    store.StatusCreating,
 		CreatedAt: now,
 		UpdatedAt: now,
-		VolumeDir: filepath.Join(m.cfg.PucksDir(), opts.Name),
+		VolumeDir: volumeDir,
 		Ports:     opts.Ports,
 		HostPort:  hostPort,
+		Owner:     opts.Owner,
+		Spec:      spec,
+		Requires:  requires,
+		Resources: resources,
+		Egress:    opts.Egress,
+		Project:   opts.Project,
+		Pool:      opts.Pool,
+
+		HostPortPinned: opts.HostPort != 0,
+		ExpirySnapshot: opts.TTLSnapshot,
+	}
+	if opts.TTL != 0 {
+		p.ExpiresAt = now.Add(opts.TTL)
+	}
+
+	// Undo the volume directories and container if a later step fails,
+	// and journal them so a daemon crash doesn't leave them behind either
+	var undo compensation
+	intent := &store.Intent{Op: store.IntentCreate, PuckName: p.Name, PuckID: p.ID, VolumeDir: p.VolumeDir}
+	_, statErr := os.Stat(p.VolumeDir)
+	intent.OwnsVolume = os.IsNotExist(statErr)
+	if err := m.store.BeginIntent(ctx, intent); err != nil {
+		m.store.DeletePortReservationsByPuck(ctx, p.Name)
+		return nil, err
+	}
+	undo.add(func(ctx context.Context) { m.store.FinishIntent(ctx, intent.ID) })
+	undo.add(func(ctx context.Context) { m.store.DeletePortReservationsByPuck(ctx, p.Name) })
+
+	undo.add(func(ctx context.Context) { m.store.DeleteEndpointsByPuck(ctx, p.Name) })
+	for _, e := range opts.Endpoints {
+		if _, err := m.addEndpoint(ctx, p, e); err != nil {
+			undo.run(ctx)
+			return nil, fmt.Errorf("adding endpoint '%s': %w", e.Name, err)
+		}
 	}
 
-	// Create volume directories
+	// Create volume directories, leaving any left over from an earlier
+	// puck of the same name in place on failure
+	if intent.OwnsVolume {
+		undo.add(func(ctx context.Context) { os.RemoveAll(p.VolumeDir) })
+	}
 	volumeDirs := []string{"home", "etc", "var"}
 	for _, dir := range volumeDirs {
 		path := filepath.Join(p.VolumeDir, dir)
 		if err := os.MkdirAll(path, 0755); err != nil {
+			undo.run(ctx)
 			return nil, fmt.Errorf("creating volume directory %s: %w", dir, err)
 		}
 	}
 
-	// Create container with port mapping for HTTP routing
-	volumes := map[string]string{
-		filepath.Join(p.VolumeDir, "home"): "/home",
-		filepath.Join(p.VolumeDir, "etc"):  "/etc/puck",
-		filepath.Join(p.VolumeDir, "var"):  "/var/puck",
-	}
-
-	// Add the auto-assigned port mapping (host:container)
-	portMappings := append(opts.Ports, fmt.Sprintf("%d:80", hostPort))
-
-	containerID, err := m.podman.CreateContainer(ctx, podman.CreateContainerOptions{
-		Name:    opts.Name,
-		Image:   opts.Image,
-		Volumes: volumes,
-		Ports:   portMappings,
-		Systemd: true,
-		Labels: map[string]string{
-			"puck.id": p.ID,
-		},
-	})
+	if err := initialSync(p); err != nil {
+		undo.run(ctx)
+		return nil, err
+	}
+
+	// A missing banner doesn't stop the puck
+	m.writeMOTD(ctx, p)
+	if err := writeEnvFile(p); err != nil {
+		undo.run(ctx)
+		return nil, err
+	}
+
+	var containerID, image string
+	if opts.FromCheckpoint != "" {
+		containerID, image, err = m.restoreCheckpointFile(ctx, p, opts.FromCheckpoint)
+	} else {
+		containerID, err = m.createContainer(ctx, p)
+	}
 	if err != nil {
-		// Clean up volume dir on failure
-		os.RemoveAll(p.VolumeDir)
-		return nil, fmt.Errorf("creating container: %w", err)
+		undo.run(ctx)
+		if errors.Is(err, podman.ErrNameInUse) {
+			return nil, m.existsError(ctx, p.Name)
+		}
+		return nil, err
+	}
+	undo.add(func(ctx context.Context) { m.podman.RemoveContainer(ctx, containerID, true) })
+	if err := m.store.UpdateIntentContainer(ctx, intent.ID, containerID); err != nil {
+		undo.run(ctx)
+		return nil, err
 	}
 
-	p.ID = containerID
+	p.ContainerID = containerID
+	detail := p.Image
 
-	// Start the container
-	if err := m.podman.StartContainer(ctx, containerID); err != nil {
-		m.podman.RemoveContainer(ctx, containerID, true)
-		os.RemoveAll(p.VolumeDir)
+	if opts.FromCheckpoint != "" {
+		// The restored container is already running, in a fresh network
+		// namespace, with the image it was checkpointed from
+		if image != "" {
+			p.Image = image
+		}
+		if err := m.enforceEgress(ctx, p, containerID, false); err != nil {
+			undo.run(ctx)
+			return nil, err
+		}
+		detail = "checkpoint " + filepath.Base(opts.FromCheckpoint)
+		if err := m.postRestore(ctx, p, containerID); err != nil {
+			detail += " (post-restore: " + err.Error() + ")"
+		}
+	} else if err := m.startContainer(ctx, p, containerID); err != nil {
+		undo.run(ctx)
 		return nil, fmt.Errorf("starting container: %w", err)
 	}
 
-	// Get container IP
+	// Get container IP, and the ports podman published
 	ip, err := m.podman.GetContainerIP(ctx, containerID)
 	if err == nil {
 		p.ContainerIP = ip
 	}
+	m.syncPublished(ctx, p, containerID)
 
-	p.Status = store.StatusRunning
+	// The daemon marks it running once its app answers
+	p.Status = store.StatusStarting
 
-	// Save to database
-	if err := m.store.CreatePuck(ctx, p); err != nil {
-		m.podman.RemoveContainer(ctx, containerID, true)
-		os.RemoveAll(p.VolumeDir)
+	// Save to database along with its first event
+	err = m.store.InTx(ctx, func(tx *store.DB) error {
+		if r.old != nil {
+			if err := deleteReplaced(ctx, tx, r); err != nil {
+				return err
+			}
+		}
+		if err := tx.CreatePuck(ctx, p); err != nil {
+			return err
+		}
+		if err := tx.FinishIntent(ctx, intent.ID); err != nil {
+			return err
+		}
+		return tx.RecordEvent(ctx, &store.Event{PuckName: p.Name, Type: store.EventCreated, Detail: detail})
+	})
+	if err != nil {
+		undo.run(ctx)
+		// Another create of the same name got in first
+		if errors.Is(err, store.ErrExists) {
+			return nil, m.existsError(ctx, p.Name)
+		}
 		return nil, fmt.Errorf("saving puck: %w", err)
 	}
-
+	p.Warnings = warnings
 	return p, nil
 }
 
+// puckVolumes maps the container paths a puck's volume directories are
+// mounted at to the directories
+var puckVolumes = map[string]string{
+	"/home":     "home",
+	"/etc/puck": "etc",
+	"/var/puck": "var",
+}
+
+// volumeDir returns where a new puck keeps its volumes. One given its own
+// data directory must not take over a directory that is already there,
+// as destroying the puck removes it, unless it is that of the puck old it
+// replaces.
+func (m *Manager) volumeDir(opts CreateOptions, old *store.Puck) (string, error) {
+	if opts.DataDir == "" {
+		return filepath.Join(m.cfg.PucksDir(), opts.Name), nil
+	}
+	if !filepath.IsAbs(opts.DataDir) {
+		return "", fmt.Errorf("data directory %s must be an absolute path", opts.DataDir)
+	}
+	if info, err := os.Stat(opts.DataDir); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("data directory %s is not a directory on the daemon's host", opts.DataDir)
+	}
+	dir := filepath.Join(opts.DataDir, opts.Name)
+	if _, err := os.Lstat(dir); err == nil && (old == nil || old.VolumeDir != dir) {
+		return "", fmt.Errorf("%s already exists; choose another data directory or remove it", dir)
+	}
+	return dir, nil
+}
+
+// createContainer creates the container for a puck from its record
+func (m *Manager) createContainer(ctx context.Context, p *store.Puck) (string, error) {
+	// Under Podman Machine, host paths are given as the VM sees them
+	machine, err := m.machine(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// Create container with port mapping for HTTP routing
+	volumes := make(map[string]string, len(puckVolumes))
+	for target, dir := range puckVolumes {
+		volumes[machinePath(machine, filepath.Join(p.VolumeDir, dir))] = target
+	}
+
+	// Shared paths that have since been removed from the host are skipped
+	// rather than failing the whole puck
+	var mounts []podman.Mount
+	for i, mnt := range p.Spec.Mounts {
+		source := mnt.Source
+		if mnt.Sync {
+			source = syncDir(p, i)
+		}
+		if _, err := os.Stat(source); err != nil {
+			continue
+		}
+		mounts = append(mounts, podman.Mount{Source: machinePath(machine, source), Destination: mnt.Target, ReadOnly: mnt.ReadOnly})
+	}
+	if _, err := os.Stat(motdPath(p)); err == nil && m.cfg.MOTD {
+		mounts = append(mounts, podman.Mount{Source: machinePath(machine, motdPath(p)), Destination: "/etc/motd", ReadOnly: true})
+	}
+
+	// A stable hostname, rather than the container ID, survives recreates
+	hostname := p.Spec.Hostname
+	if hostname == "" && hostnamePattern.MatchString(p.Name) {
+		hostname = p.Name
+	}
+
+	ports, err := m.portMappings(ctx, p)
+	if err != nil {
+		return "", err
+	}
+
+	stopTimeout := m.stopTimeout(p)
+	opts := podman.CreateContainerOptions{
+		Name:        p.Name,
+		Image:       p.Image,
+		Volumes:     volumes,
+		Mounts:      mounts,
+		Ports:       ports,
+		PublishAll:  p.Spec.PublishAll,
+		Systemd:     p.Spec.InitMode() == store.InitSystemd,
+		Init:        p.Spec.InitMode() == store.InitTini,
+		Entrypoint:  p.Spec.Entrypoint,
+		Command:     p.Spec.Command,
+		Hostname:    hostname,
+		DNS:         p.Spec.DNS,
+		AddHosts:    p.Spec.AddHosts,
+		Sysctls:     p.Spec.Sysctls,
+		Ulimits:     p.Spec.Ulimits,
+		Env:         p.Spec.Env,
+		UserNS:      podman.UserNS{Mode: p.Spec.UserNS, UIDMap: p.Spec.UIDMap, GIDMap: p.Spec.GIDMap},
+		Seccomp:     p.Spec.Seccomp,
+		AppArmor:    p.Spec.AppArmor,
+		StopTimeout: &stopTimeout,
+		Labels:      maps.Clone(p.Spec.Labels),
+		Resources:   podman.Resources{Memory: p.Resources.Memory, CPUs: p.Resources.CPUs},
+	}
+	if opts.Labels == nil {
+		opts.Labels = make(map[string]string)
+	}
+	opts.Labels["puck.id"] = p.ID
+	opts.Labels[dataDirLabel] = m.cfg.DataDir
+	applySandbox(p, &opts)
+	containerID, err := m.podman.CreateContainer(ctx, opts)
+	if err != nil {
+		return "", fmt.Errorf("creating container: %w", err)
+	}
+	return containerID, nil
+}
+
+// RecreateOptions contains options for recreating a puck
+type RecreateOptions struct {
+	Name       string `json:"name"`
+	Image      string `json:"image"`                 // empty keeps the puck's current image
+	NoSnapshot bool   `json:"no_snapshot,omitempty"` // skip the rollback snapshot
+}
+
+// RollbackTag marks the snapshot taken before a puck was last recreated
+const RollbackTag = "rollback"
+
+// Recreate replaces a puck's container with a fresh one from the latest
+// version of its image, keeping its volumes, ports, and snapshots. A running
+// puck is snapshotted first, unless disabled, so Rollback can return to it.
+func (m *Manager) Recreate(ctx context.Context, opts RecreateOptions) (*store.Puck, error) {
+	name := opts.Name
+	p, err := m.store.GetPuck(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Image != "" {
+		p.Image = opts.Image
+	}
+
+	// Pull before touching the old container so a bad image leaves it intact
+	if err := m.podman.PullImage(ctx, p.Image); err != nil {
+		return nil, err
+	}
+
+	running, _ := m.podman.IsRunning(ctx, p.ContainerID)
+	if running && m.cfg.SnapshotBeforeRecreate && !opts.NoSnapshot {
+		if err := m.snapshotForRollback(ctx, name); err != nil {
+			return nil, err
+		}
+	}
+	if running {
+		if err := m.podman.StopContainer(ctx, p.ContainerID, m.stopTimeout(p)); err != nil {
+			return nil, fmt.Errorf("stopping container: %w", err)
+		}
+	}
+
+	if err := m.podman.RemoveContainer(ctx, p.ContainerID, true); err != nil {
+		// Container might not exist, continue anyway
+	}
+
+	containerID, err := m.createContainer(ctx, p)
+	if err != nil {
+		m.store.UpdatePuckStatus(ctx, name, store.StatusError)
+		return nil, err
+	}
+
+	if err := m.startContainer(ctx, p, containerID); err != nil {
+		m.podman.RemoveContainer(ctx, containerID, true)
+		m.store.UpdatePuckStatus(ctx, name, store.StatusError)
+		return nil, fmt.Errorf("starting container: %w", err)
+	}
+
+	// Update puck with new container ID, image, and status
+	if err := m.store.UpdatePuckContainerID(ctx, name, containerID); err != nil {
+		return nil, err
+	}
+	if err := m.store.UpdatePuckImage(ctx, name, p.Image); err != nil {
+		return nil, err
+	}
+	if err := m.store.UpdatePuckStatus(ctx, name, store.StatusRunning); err != nil {
+		return nil, err
+	}
+	if p.Resources.Pending {
+		// The new container already has the limits
+		p.Resources.Pending = false
+		if err := m.store.UpdatePuckResources(ctx, name, p.Resources); err != nil {
+			return nil, err
+		}
+	}
+
+	ip, err := m.podman.GetContainerIP(ctx, containerID)
+	if err == nil {
+		m.store.UpdatePuckContainerIP(ctx, name, ip)
+	}
+
+	m.record(ctx, name, store.EventRecreated, p.Image)
+	// The route was dropped if the puck was stopped
+	m.syncRoute(ctx, name)
+	return m.store.GetPuck(ctx, name)
+}
+
 // Get retrieves a puck by name
 func (m *Manager) Get(ctx context.Context, name string) (*store.Puck, error) {
 	return m.store.GetPuck(ctx, name)
@@ -142,13 +711,25 @@ func (m *Manager) List(ctx context.Context) ([]*store.Puck, error) {
 
 	// Update status from Podman for each puck
 	for _, p := range pucks {
-		running, err := m.podman.IsRunning(ctx, p.ID)
+		running, err := m.podman.IsRunning(ctx, p.ContainerID)
 		if err != nil {
 			continue // Container might not exist
 		}
 		if running {
-			p.Status = store.StatusRunning
-		} else {
+			if p.Status != store.StatusStarting {
+				p.Status = store.StatusRunning
+			}
+			continue
+		}
+
+		// A puck we left running that has since stopped on its own crashed.
+		// Persist the new status so the crash is only recorded once.
+		if p.Status.Up() {
+			m.record(ctx, p.Name, store.EventCrashed, "")
+			m.store.UpdatePuckStatus(ctx, p.Name, store.StatusStopped)
+		}
+		// Checkpointed and suspended pucks are stopped, but say how
+		if p.Status != store.StatusCheckpointed && p.Status != store.StatusSuspended {
 			p.Status = store.StatusStopped
 		}
 	}
@@ -156,104 +737,408 @@ func (m *Manager) List(ctx context.Context) ([]*store.Puck, error) {
 	return pucks, nil
 }
 
-// Start starts a stopped puck
+// Start starts a stopped puck and routes it
 func (m *Manager) Start(ctx context.Context, name string) error {
+	if err := m.start(ctx, name); err != nil {
+		return err
+	}
+	m.syncRoute(ctx, name)
+	return nil
+}
+
+// start starts a stopped puck
+func (m *Manager) start(ctx context.Context, name string) error {
 	p, err := m.store.GetPuck(ctx, name)
 	if err != nil {
 		return err
 	}
 
-	if err := m.podman.StartContainer(ctx, p.ID); err != nil {
-		return fmt.Errorf("starting container: %w", err)
+	// A suspended puck resumes from its resume image rather than booting,
+	// as does one stopped by a checkpoint snapshot
+	if p.Status == store.StatusSuspended && p.ResumeSnapshot != "" {
+		if resumed, err := m.resume(ctx, p); resumed || err != nil {
+			return err
+		}
 	}
-
-	// Update IP
-	ip, err := m.podman.GetContainerIP(ctx, p.ID)
-	if err == nil {
-		m.store.UpdatePuckContainerIP(ctx, name, ip)
+	if p.Status == store.StatusCheckpointed && p.SnapshotHead != "" {
+		if head, err := m.snapshotByID(ctx, p, p.SnapshotHead); err == nil && head.Mode == store.SnapshotModeCheckpoint {
+			return m.restoreSnapshot(ctx, SnapshotRestoreOptions{PuckName: name, SnapshotName: head.Name})
+		}
 	}
 
-	return m.store.UpdatePuckStatus(ctx, name, store.StatusRunning)
-}
+	if err := m.checkProjectLimits(ctx, p, false); err != nil {
+		return err
+	}
+	if err := m.ensureBudget(ctx, p); err != nil {
+		return err
+	}
 
-// Stop stops a running puck
-func (m *Manager) Stop(ctx context.Context, name string) error {
-	p, err := m.store.GetPuck(ctx, name)
+	// A new host port or limits that couldn't be applied in place need a
+	// new container, as does a puck whose container is gone, such as one
+	// restored from a backup
+	moved, err := m.claimHostPort(ctx, p)
 	if err != nil {
 		return err
 	}
+	m.writeMOTD(ctx, p) // refreshed for the new start; a missing banner doesn't stop it
+	exists, _ := m.podman.ContainerExists(ctx, p.ContainerID)
+	if moved != nil || p.Resources.Pending || !exists {
+		if err := m.replaceContainer(ctx, p); err != nil {
+			return err
+		}
+	}
 
-	if err := m.podman.StopContainer(ctx, p.ID); err != nil {
-		return fmt.Errorf("stopping container: %w", err)
+	if err := m.startContainer(ctx, p, p.ContainerID); err != nil {
+		return fmt.Errorf("starting container: %w", err)
+	}
+
+	// Update IP, and the host port should podman have published another
+	ip, err := m.podman.GetContainerIP(ctx, p.ContainerID)
+	if err == nil {
+		m.store.UpdatePuckContainerIP(ctx, name, ip)
 	}
+	m.syncHostPort(ctx, p, p.ContainerID)
+	m.syncPublished(ctx, p, p.ContainerID)
 
-	return m.store.UpdatePuckStatus(ctx, name, store.StatusStopped)
+	if err := m.store.UpdatePuckStatus(ctx, name, store.StatusRunning); err != nil {
+		return err
+	}
+	m.store.TouchPuck(ctx, name, time.Now())
+	m.record(ctx, name, store.EventStarted, "")
+	return m.dropResume(ctx, name)
 }
 
-// Destroy removes a puck and its data
-func (m *Manager) Destroy(ctx context.Context, name string, force bool) error {
+// MarkReady records that a new puck's app is answering. A puck that was
+// stopped in the meantime is left alone.
+func (m *Manager) MarkReady(ctx context.Context, name string) error {
 	p, err := m.store.GetPuck(ctx, name)
 	if err != nil {
 		return err
 	}
-
-	// Stop container first if running and not forcing
-	if !force {
-		running, _ := m.podman.IsRunning(ctx, p.ID)
-		if running {
-			if err := m.podman.StopContainer(ctx, p.ID); err != nil {
-				return fmt.Errorf("stopping container: %w (use --force to override)", err)
-			}
-		}
+	if p.Status != store.StatusStarting {
+		return nil
 	}
+	return m.store.UpdatePuckStatus(ctx, name, store.StatusRunning)
+}
 
-	// Remove container (force if requested)
-	if err := m.podman.RemoveContainer(ctx, p.ID, force); err != nil {
-		// Try to remove even if container doesn't exist
-		if !force {
-			return fmt.Errorf("removing container: %w", err)
-		}
-		// Continue cleanup even if container removal fails with force
+// replaceContainer swaps a stopped puck's container for a new one built
+// from its current settings, clearing any pending resource limits
+func (m *Manager) replaceContainer(ctx context.Context, p *store.Puck) error {
+	if err := m.podman.RemoveContainer(ctx, p.ContainerID, true); err != nil {
+		// Container might not exist, continue anyway
 	}
 
-	// Remove volume directory
-	if p.VolumeDir != "" {
-		os.RemoveAll(p.VolumeDir) // Ignore errors - may not exist
+	containerID, err := m.createContainer(ctx, p)
+	if err != nil {
+		return err
 	}
-
-	// Remove from database
-	if err := m.store.DeletePuck(ctx, name); err != nil {
-		return fmt.Errorf("removing from database: %w", err)
+	if err := m.store.UpdatePuckContainerID(ctx, p.Name, containerID); err != nil {
+		return err
 	}
+	p.ContainerID = containerID
 
-	return nil
+	p.Resources.Pending = false
+	return m.store.UpdatePuckResources(ctx, p.Name, p.Resources)
 }
 
-// DestroyAll removes all pucks
-func (m *Manager) DestroyAll(ctx context.Context, force bool) ([]string, error) {
-	pucks, err := m.store.ListPucks(ctx)
+// SetResources changes a puck's CPU and memory limits. They are applied
+// to the container in place when possible; otherwise they are marked
+// pending and take effect when the puck is next started.
+func (m *Manager) SetResources(ctx context.Context, name string, res store.Resources) (*store.Puck, error) {
+	if res.Memory < 0 {
+		return nil, fmt.Errorf("memory limit cannot be negative")
+	}
+	if res.CPUs < 0 {
+		return nil, fmt.Errorf("CPU limit cannot be negative")
+	}
+
+	p, err := m.store.GetPuck(ctx, name)
 	if err != nil {
 		return nil, err
 	}
 
-	var destroyed []string
-	var errors []string
-
-	for _, p := range pucks {
-		if err := m.Destroy(ctx, p.Name, force); err != nil {
-			errors = append(errors, fmt.Sprintf("%s: %v", p.Name, err))
-		} else {
-			destroyed = append(destroyed, p.Name)
+	// Raising a running puck's limits has to fit in the budget too
+	if p.Status.Up() {
+		resized := *p
+		resized.Resources = res
+		if err := m.ensureBudget(ctx, &resized); err != nil {
+			return nil, err
 		}
 	}
 
-	if len(errors) > 0 {
-		return destroyed, fmt.Errorf("failed to destroy some pucks: %v", errors)
+	res.Pending = false
+	if err := m.podman.UpdateResources(ctx, p.ContainerID, podman.Resources{Memory: res.Memory, CPUs: res.CPUs}); err != nil {
+		// e.g. rootless podman on cgroup v1
+		res.Pending = true
 	}
 
-	return destroyed, nil
+	if err := m.store.UpdatePuckResources(ctx, name, res); err != nil {
+		return nil, err
+	}
+	return m.store.GetPuck(ctx, name)
+}
+
+// maxNotes bounds a puck's notes, which are meant to be a line or two
+const maxNotes = 1000
+
+// SetNotes sets what a puck is for, shown by puck inspect and puck list
+// --wide; empty notes clear them
+func (m *Manager) SetNotes(ctx context.Context, name, notes string) (*store.Puck, error) {
+	notes = strings.TrimSpace(notes)
+	if len(notes) > maxNotes {
+		return nil, fmt.Errorf("notes are %d bytes; keep them under %d", len(notes), maxNotes)
+	}
+	if err := m.store.UpdatePuckNotes(ctx, name, notes); err != nil {
+		return nil, err
+	}
+	return m.store.GetPuck(ctx, name)
+}
+
+// Stop stops a running puck
+func (m *Manager) Stop(ctx context.Context, name string) error {
+	return m.StopWithOptions(ctx, StopOptions{Name: name})
+}
+
+// StopOptions contains options for stopping a puck
+type StopOptions struct {
+	Name string `json:"name"`
+	// Seconds to wait for the puck to exit before killing it; nil uses the
+	// puck's stop timeout
+	Timeout *int `json:"timeout,omitempty"`
+
+	// Suspend the puck to a checkpoint instead of shutting it down, so
+	// the next start resumes it
+	Checkpoint bool `json:"checkpoint,omitempty"`
+}
+
+// StopWithOptions stops a running puck, killing it if it doesn't exit
+// within the timeout, and unroutes it
+func (m *Manager) StopWithOptions(ctx context.Context, opts StopOptions) error {
+	if err := m.stop(ctx, opts); err != nil {
+		return err
+	}
+	m.unroute(ctx, opts.Name)
+	return nil
+}
+
+// stop stops a running puck, or suspends it
+func (m *Manager) stop(ctx context.Context, opts StopOptions) error {
+	name := opts.Name
+	p, err := m.store.GetPuck(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if opts.Checkpoint {
+		if p.Status == store.StatusSuspended {
+			return nil
+		}
+		if err := m.suspend(ctx, p); err != nil {
+			return err
+		}
+		m.record(ctx, name, store.EventStopped, "suspended")
+		return nil
+	}
+
+	timeout := m.stopTimeout(p)
+	if opts.Timeout != nil {
+		if err := validateStopTimeout(*opts.Timeout); err != nil {
+			return err
+		}
+		timeout = uint(*opts.Timeout)
+	}
+
+	if err := m.podman.StopContainer(ctx, p.ContainerID, timeout); err != nil {
+		return fmt.Errorf("stopping container: %w", err)
+	}
+
+	if err := m.store.UpdatePuckStatus(ctx, name, store.StatusStopped); err != nil {
+		return err
+	}
+	m.record(ctx, name, store.EventStopped, "")
+	// A suspended puck stopped for good boots when next started
+	return m.dropResume(ctx, name)
+}
+
+// Kill sends a signal to a puck's container, SIGKILL if none is given. A
+// killed puck is stopped; other signals are only delivered, e.g. HUP to
+// reload an app's config.
+func (m *Manager) Kill(ctx context.Context, name, signal string) error {
+	p, err := m.store.GetPuck(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if signal == "" {
+		signal = "SIGKILL"
+	}
+	sig, err := podman.ParseSignal(signal)
+	if err != nil {
+		return err
+	}
+	if err := m.podman.KillContainer(ctx, p.ContainerID, signal); err != nil {
+		return fmt.Errorf("killing container: %w", err)
+	}
+	if sig != syscall.SIGKILL {
+		return nil
+	}
+
+	if err := m.store.UpdatePuckStatus(ctx, name, store.StatusStopped); err != nil {
+		return err
+	}
+	m.record(ctx, name, store.EventStopped, "killed")
+	m.unroute(ctx, name)
+	return nil
+}
+
+// stopTimeout returns how long a puck gets to exit before it is killed
+func (m *Manager) stopTimeout(p *store.Puck) uint {
+	if p.Spec.StopTimeout != nil {
+		return uint(*p.Spec.StopTimeout)
+	}
+	return uint(m.cfg.StopTimeout)
+}
+
+// History returns a puck's lifecycle events at or after since, oldest first
+func (m *Manager) History(ctx context.Context, name string, since time.Time) ([]*store.Event, error) {
+	if _, err := m.store.GetPuck(ctx, name); err != nil {
+		return nil, err
+	}
+	return m.store.ListEvents(ctx, name, since)
+}
+
+type triggerKey struct{}
+
+// WithTrigger returns a context whose actions note in the events they
+// record what set them off, such as the routed request that woke a puck
+func WithTrigger(ctx context.Context, trigger string) context.Context {
+	return context.WithValue(ctx, triggerKey{}, trigger)
+}
+
+// record adds an event to a puck's history. History is informational, so
+// a failure to record never fails the operation itself.
+func (m *Manager) record(ctx context.Context, name string, typ store.EventType, detail string) {
+	if trigger, _ := ctx.Value(triggerKey{}).(string); trigger != "" {
+		if detail != "" {
+			detail += "; "
+		}
+		detail += trigger
+	}
+	m.store.RecordEvent(ctx, &store.Event{PuckName: name, Type: typ, Detail: detail})
+}
+
+// Destroy removes a puck and its data. Without force, a puck that may
+// still be in use, as DestroyRisks tells, is refused.
+func (m *Manager) Destroy(ctx context.Context, name string, force bool) error {
+	p, err := m.store.GetPuck(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if !force {
+		if err := m.checkDestroy(ctx, p); err != nil {
+			return err
+		}
+	}
+
+	// Once the container is gone the destroy must be finished, even by the
+	// next daemon if this one dies first
+	intent := &store.Intent{Op: store.IntentDestroy, PuckName: name, ContainerID: p.ContainerID, VolumeDir: p.VolumeDir}
+	if err := m.store.BeginIntent(ctx, intent); err != nil {
+		return err
+	}
+	abandon := func() { m.store.FinishIntent(context.WithoutCancel(ctx), intent.ID) }
+
+	// Stop container first if running and not forcing
+	if !force {
+		running, _ := m.podman.IsRunning(ctx, p.ContainerID)
+		if running {
+			if err := m.podman.StopContainer(ctx, p.ContainerID, m.stopTimeout(p)); err != nil {
+				abandon()
+				return fmt.Errorf("stopping container: %w (use --force to override)", err)
+			}
+		}
+	}
+
+	// Remove container (force if requested)
+	if err := m.podman.RemoveContainer(ctx, p.ContainerID, force); err != nil {
+		// Try to remove even if container doesn't exist
+		if !force {
+			abandon()
+			return fmt.Errorf("removing container: %w", err)
+		}
+		// Continue cleanup even if container removal fails with force
+	}
+
+	// Committed snapshot images would otherwise be left behind
+	if snapshots, err := m.store.ListSnapshots(ctx, p.ID); err == nil {
+		for _, s := range snapshots {
+			if s.CommitImage != "" {
+				m.podman.RemoveImage(ctx, s.CommitImage)
+			}
+		}
+	}
+
+	// Remove volume directory
+	if p.VolumeDir != "" {
+		os.RemoveAll(p.VolumeDir) // Ignore errors - may not exist
+	}
+
+	// Remove from database, along with its host ports and endpoints
+	err = m.store.InTx(ctx, func(tx *store.DB) error {
+		if err := deletePuckRecords(ctx, tx, name); err != nil {
+			return err
+		}
+		if err := tx.DeletePortReservationsByPuck(ctx, name); err != nil {
+			return fmt.Errorf("releasing host port: %w", err)
+		}
+		if err := tx.DeleteEndpointsByPuck(ctx, name); err != nil {
+			return fmt.Errorf("removing endpoints: %w", err)
+		}
+		return tx.FinishIntent(ctx, intent.ID)
+	})
+	if err != nil {
+		return err
+	}
+	m.forgetRoutes(ctx, name)
+	return nil
 }
 
+// deletePuckRecords removes a destroyed puck from the database. Links and
+// history must not outlive the puck, or a new puck with the same name
+// would inherit them, nor must what the router serves for it.
+func deletePuckRecords(ctx context.Context, tx *store.DB, name string) error {
+	if err := tx.DeletePuck(ctx, name); err != nil {
+		return fmt.Errorf("removing from database: %w", err)
+	}
+	if err := tx.DeleteSharesByPuck(ctx, name); err != nil {
+		return fmt.Errorf("removing share links: %w", err)
+	}
+	if err := tx.DeleteRouteAliasesByPuck(ctx, name); err != nil {
+		return fmt.Errorf("removing route aliases: %w", err)
+	}
+	if err := tx.DeleteStackSnapshotsByPuck(ctx, name); err != nil {
+		return fmt.Errorf("removing stack snapshots: %w", err)
+	}
+	if err := tx.DeleteGroupMembershipsByPuck(ctx, name); err != nil {
+		return fmt.Errorf("removing from groups: %w", err)
+	}
+	if err := dropRequirement(ctx, tx, name); err != nil {
+		return fmt.Errorf("removing requirements: %w", err)
+	}
+	if err := tx.DeleteEventsByPuck(ctx, name); err != nil {
+		return fmt.Errorf("removing history: %w", err)
+	}
+	if err := tx.DeleteStatsByPuck(ctx, name); err != nil {
+		return fmt.Errorf("removing stats: %w", err)
+	}
+	return nil
+}
+
+// ConsoleEnv is set to the puck's name in console shells, so prompts can
+// show which puck a terminal is attached to
+const ConsoleEnv = "PUCK_CONSOLE"
+
 // Console opens a shell in a puck
 func (m *Manager) Console(ctx context.Context, name string, shell string) error {
 	p, err := m.store.GetPuck(ctx, name)
@@ -262,7 +1147,7 @@ func (m *Manager) Console(ctx context.Context, name string, shell string) error
 	}
 
 	// Start if not running
-	running, err := m.podman.IsRunning(ctx, p.ID)
+	running, err := m.podman.IsRunning(ctx, p.ContainerID)
 	if err != nil {
 		return fmt.Errorf("checking container status: %w", err)
 	}
@@ -273,7 +1158,311 @@ func (m *Manager) Console(ctx context.Context, name string, shell string) error
 		}
 	}
 
-	return m.podman.Console(ctx, p.ID, shell)
+	// Images run without systemd are often minimal and lack bash
+	if shell == "" {
+		shell = p.Spec.Shell
+	}
+	if shell == "" && p.Spec.InitMode() != store.InitSystemd {
+		shell = "/bin/sh"
+	}
+
+	// Greet the shell with the banner written when the puck started
+	if motd, err := os.ReadFile(motdPath(p)); err == nil && m.cfg.MOTD {
+		os.Stdout.Write(motd)
+	}
+
+	m.store.TouchPuck(ctx, name, time.Now())
+	m.record(ctx, name, store.EventExec, "console")
+	return m.podman.Console(ctx, p.ContainerID, shell, p.Spec.User, []string{ConsoleEnv + "=" + p.Name})
+}
+
+// SetRouteConfig updates a puck's router settings and returns the updated puck
+func (m *Manager) SetRouteConfig(ctx context.Context, name string, rc store.RouteConfig) (*store.Puck, error) {
+	if err := validateRouteConfig(rc); err != nil {
+		return nil, err
+	}
+
+	if err := m.store.UpdatePuckRouteConfig(ctx, name, rc); err != nil {
+		return nil, err
+	}
+	return m.store.GetPuck(ctx, name)
+}
+
+// validateRouteConfig rejects settings the router could not render
+func validateRouteConfig(rc store.RouteConfig) error {
+	switch rc.Protocol {
+	case "", store.ProtocolHTTP, store.ProtocolH2C:
+	default:
+		return fmt.Errorf("unsupported route protocol %q (use %s or %s)", rc.Protocol, store.ProtocolHTTP, store.ProtocolH2C)
+	}
+
+	for _, headers := range []map[string]string{rc.RequestHeaders, rc.ResponseHeaders} {
+		for name := range headers {
+			if name == "" || strings.ContainsAny(name, " :\r\n") {
+				return fmt.Errorf("invalid header name %q", name)
+			}
+		}
+	}
+
+	for _, rw := range rc.Rewrites {
+		if _, err := regexp.Compile(rw.Find); err != nil {
+			return fmt.Errorf("invalid rewrite pattern %q: %w", rw.Find, err)
+		}
+	}
+
+	if rc.RateLimit < 0 || (rc.RateLimit > 0 && rc.RateLimitWindow <= 0) {
+		return fmt.Errorf("rate limit needs a positive request count and window")
+	}
+	if rc.MaxBodySize < 0 {
+		return fmt.Errorf("max body size cannot be negative")
+	}
+	if rc.MaxConns < 0 || rc.Bandwidth < 0 {
+		return fmt.Errorf("connection and bandwidth limits cannot be negative")
+	}
+
+	return nil
+}
+
+// tailnetTagPattern matches Tailscale ACL tag names
+var tailnetTagPattern = regexp.MustCompile(`^tag:[a-zA-Z][a-zA-Z0-9-]*$`)
+
+// ShareOnTailnet records that a puck is served as its own tailnet node
+// with the given ACL tags, and returns the updated puck
+func (m *Manager) ShareOnTailnet(ctx context.Context, name string, tags []string) (*store.Puck, error) {
+	for _, tag := range tags {
+		if !tailnetTagPattern.MatchString(tag) {
+			return nil, fmt.Errorf("invalid tailnet tag %q (expected tag:<name>)", tag)
+		}
+	}
+
+	share := &store.TailnetShare{Tags: tags, SharedAt: time.Now()}
+	if err := m.store.UpdatePuckTailnetShare(ctx, name, share); err != nil {
+		return nil, err
+	}
+	return m.store.GetPuck(ctx, name)
+}
+
+// UnshareFromTailnet removes a puck's tailnet node
+func (m *Manager) UnshareFromTailnet(ctx context.Context, name string) (*store.Puck, error) {
+	if err := m.store.UpdatePuckTailnetShare(ctx, name, nil); err != nil {
+		return nil, err
+	}
+	return m.store.GetPuck(ctx, name)
+}
+
+// ShareCreateOptions contains options for creating a share link
+type ShareCreateOptions struct {
+	PuckName  string        `json:"puck_name"`
+	TTL       time.Duration `json:"ttl"`
+	CreatedBy string        `json:"-"` // set by the daemon from the caller
+}
+
+// CreateShare creates a signed link to a puck that expires after opts.TTL
+func (m *Manager) CreateShare(ctx context.Context, opts ShareCreateOptions) (*store.Share, error) {
+	if opts.TTL <= 0 {
+		return nil, fmt.Errorf("share duration must be positive")
+	}
+
+	p, err := m.store.GetPuck(ctx, opts.PuckName)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := m.shareKey()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	share := &store.Share{
+		ID:        uuid.New().String()[:8],
+		PuckName:  p.Name,
+		CreatedBy: opts.CreatedBy,
+		// Tokens sign whole seconds
+		ExpiresAt: now.Add(opts.TTL).Truncate(time.Second),
+		CreatedAt: now,
+	}
+	share.Token = signShare(key, share)
+
+	if err := m.store.CreateShare(ctx, share); err != nil {
+		return nil, fmt.Errorf("saving share: %w", err)
+	}
+
+	return share, nil
+}
+
+// ListShares returns a puck's share links, or all of them if puckName is empty
+func (m *Manager) ListShares(ctx context.Context, puckName string) ([]*store.Share, error) {
+	if puckName != "" {
+		if _, err := m.store.GetPuck(ctx, puckName); err != nil {
+			return nil, err
+		}
+	}
+	return m.store.ListShares(ctx, puckName)
+}
+
+// RevokeShare deletes a share link and returns it
+func (m *Manager) RevokeShare(ctx context.Context, id string) (*store.Share, error) {
+	share, err := m.store.GetShare(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.store.DeleteShare(ctx, id); err != nil {
+		return nil, err
+	}
+	return share, nil
+}
+
+// ActiveShares returns unexpired links whose tokens match the current
+// signing key, so replacing the key revokes every link
+func (m *Manager) ActiveShares(ctx context.Context) ([]*store.Share, error) {
+	key, err := m.shareKey()
+	if err != nil {
+		return nil, err
+	}
+
+	shares, err := m.store.ListShares(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	active := make([]*store.Share, 0, len(shares))
+	for _, s := range shares {
+		if s.Expired(now) || !hmac.Equal([]byte(s.Token), []byte(signShare(key, s))) {
+			continue
+		}
+		active = append(active, s)
+	}
+	return active, nil
+}
+
+// PruneShares deletes expired share links and returns them
+func (m *Manager) PruneShares(ctx context.Context) ([]*store.Share, error) {
+	return m.store.DeleteExpiredShares(ctx, time.Now())
+}
+
+// aliasPathPattern matches router paths an alias may take: one or more
+// segments that, like puck names, can't start with an underscore
+var aliasPathPattern = regexp.MustCompile(`^(/[a-zA-Z0-9][a-zA-Z0-9_.-]*)+$`)
+
+// cleanAliasPath normalizes an alias path to a leading slash and no
+// trailing one
+func cleanAliasPath(path string) (string, error) {
+	clean := "/" + strings.Trim(path, "/")
+	if !aliasPathPattern.MatchString(clean) {
+		return "", fmt.Errorf("invalid alias path %q (expected e.g. /api or /team/app)", path)
+	}
+	return clean, nil
+}
+
+// RouteAliasOptions contains options for pointing an alias at a puck
+type RouteAliasOptions struct {
+	Path      string `json:"path"`
+	PuckName  string `json:"puck_name"`
+	CreatedBy string `json:"-"` // set by the daemon from the caller
+}
+
+// SetRouteAlias points a router path at a puck, replacing whichever puck
+// served it before. It returns the new alias and the one it replaced, if
+// any, so the caller can put it back.
+func (m *Manager) SetRouteAlias(ctx context.Context, opts RouteAliasOptions) (alias, previous *store.RouteAlias, err error) {
+	path, err := cleanAliasPath(opts.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p, err := m.store.GetPuck(ctx, opts.PuckName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// A puck's own route would be shadowed by the alias
+	if other, err := m.store.GetPuck(ctx, strings.TrimPrefix(path, "/")); err == nil {
+		return nil, nil, fmt.Errorf("path '%s' is puck '%s''s own route", path, other.Name)
+	}
+
+	previous, _ = m.store.GetRouteAlias(ctx, path)
+
+	alias = &store.RouteAlias{
+		Path:      path,
+		PuckName:  p.Name,
+		CreatedBy: opts.CreatedBy,
+		CreatedAt: time.Now(),
+	}
+	if err := m.store.SetRouteAlias(ctx, alias); err != nil {
+		return nil, nil, err
+	}
+	return alias, previous, nil
+}
+
+// ListRouteAliases returns a puck's aliases, or all of them if puckName
+// is empty
+func (m *Manager) ListRouteAliases(ctx context.Context, puckName string) ([]*store.RouteAlias, error) {
+	if puckName != "" {
+		if _, err := m.store.GetPuck(ctx, puckName); err != nil {
+			return nil, err
+		}
+	}
+	return m.store.ListRouteAliases(ctx, puckName)
+}
+
+// RemoveRouteAlias deletes an alias and returns it
+func (m *Manager) RemoveRouteAlias(ctx context.Context, path string) (*store.RouteAlias, error) {
+	path, err := cleanAliasPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	alias, err := m.store.GetRouteAlias(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.store.DeleteRouteAlias(ctx, path); err != nil {
+		return nil, err
+	}
+	return alias, nil
+}
+
+// RestoreRouteAlias puts back an alias returned by SetRouteAlias or
+// RemoveRouteAlias, for when the router could not apply the change
+func (m *Manager) RestoreRouteAlias(ctx context.Context, alias *store.RouteAlias) error {
+	return m.store.SetRouteAlias(ctx, alias)
+}
+
+// signShare derives a link's token from its ID, puck and expiry
+func signShare(key []byte, s *store.Share) string {
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%s\n%s\n%d", s.ID, s.PuckName, s.ExpiresAt.Unix())
+	return s.ID + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// shareKeySize is the length of the share signing key in bytes
+const shareKeySize = 32
+
+// shareKey loads the key that signs share links, creating it on first use
+func (m *Manager) shareKey() ([]byte, error) {
+	path := m.cfg.ShareKeyPath()
+
+	key, err := os.ReadFile(path)
+	if err == nil && len(key) >= shareKeySize {
+		return key, nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("reading share key: %w", err)
+	}
+
+	key = make([]byte, shareKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("generating share key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("creating data directory: %w", err)
+	}
+	if err := os.WriteFile(path, key, 0600); err != nil {
+		return nil, fmt.Errorf("writing share key: %w", err)
+	}
+	return key, nil
 }
 
 // Exists checks if a puck exists
@@ -286,30 +1475,88 @@ func (m *Manager) Exists(ctx context.Context, name string) bool {
 type SnapshotCreateOptions struct {
 	PuckName     string `json:"puck_name"`
 	SnapshotName string `json:"snapshot_name"`
-	LeaveRunning bool   `json:"leave_running"`
+	// Checkpoint mode only; nil uses the puck's snapshot policy
+	LeaveRunning *bool              `json:"leave_running,omitempty"`
+	Mode         store.SnapshotMode `json:"mode,omitempty"` // empty uses the configured default
+	CRIUFlags
+}
+
+// CRIUFlags chooses CRIU options for a checkpoint; nil fields use the
+// configured defaults
+type CRIUFlags struct {
+	TCPEstablished *bool `json:"tcp_established,omitempty"`
+	FileLocks      *bool `json:"file_locks,omitempty"`
+}
+
+// criuOptions resolves flags against the configured defaults
+func (m *Manager) criuOptions(f CRIUFlags) store.CRIUOptions {
+	opts := store.CRIUOptions{
+		TCPEstablished: m.cfg.CheckpointTCPEstablished,
+		FileLocks:      m.cfg.CheckpointFileLocks,
+	}
+	if f.TCPEstablished != nil {
+		opts.TCPEstablished = *f.TCPEstablished
+	}
+	if f.FileLocks != nil {
+		opts.FileLocks = *f.FileLocks
+	}
+	return opts
 }
 
 // SnapshotRestoreOptions contains options for restoring a snapshot
 type SnapshotRestoreOptions struct {
 	PuckName     string `json:"puck_name"`
 	SnapshotName string `json:"snapshot_name"`
+	// Restore a checkpoint even if this host may not be able to
+	Force bool `json:"force,omitempty"`
+	// Pull the checkpoint's image by digest first if the host lacks it
+	Repull bool `json:"repull,omitempty"`
 }
 
-// CreateSnapshot creates a checkpoint snapshot of a puck
+// snapshotImageRepo is the local repository image-mode snapshots are
+// committed to, tagged with the snapshot ID
+const snapshotImageRepo = "localhost/puck-snapshots"
+
+// CreateSnapshot creates a snapshot of a puck, either as a CRIU checkpoint
+// or by committing its container to an image. A checkpoint that stops the
+// puck unroutes it.
 func (m *Manager) CreateSnapshot(ctx context.Context, opts SnapshotCreateOptions) (*store.Snapshot, error) {
+	snapshot, err := m.createSnapshot(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if p, err := m.store.GetPuck(ctx, opts.PuckName); err == nil && p.Status == store.StatusCheckpointed {
+		m.unroute(ctx, p.Name)
+	}
+	return snapshot, nil
+}
+
+// createSnapshot snapshots a puck, leaving its route as it is
+func (m *Manager) createSnapshot(ctx context.Context, opts SnapshotCreateOptions) (*store.Snapshot, error) {
+	mode := opts.Mode
+	if mode == "" {
+		mode = store.SnapshotMode(m.cfg.SnapshotMode)
+	}
+	if mode == "" {
+		mode = store.SnapshotModeCheckpoint
+	}
+	if mode != store.SnapshotModeCheckpoint && mode != store.SnapshotModeImage {
+		return nil, fmt.Errorf("unknown snapshot mode %q (expected checkpoint or image)", mode)
+	}
+
 	p, err := m.store.GetPuck(ctx, opts.PuckName)
 	if err != nil {
 		return nil, err
 	}
+	leaveRunning := p.SnapshotPolicy.LeaveRunning
+	if opts.LeaveRunning != nil {
+		leaveRunning = *opts.LeaveRunning
+	}
 
-	// Container must be running to checkpoint
-	running, err := m.podman.IsRunning(ctx, p.ID)
+	running, err := m.podman.IsRunning(ctx, p.ContainerID)
 	if err != nil {
 		return nil, fmt.Errorf("checking container status: %w", err)
 	}
-	if !running {
-		return nil, fmt.Errorf("puck must be running to create snapshot")
-	}
 
 	// Create snapshots directory
 	snapshotDir := filepath.Join(m.cfg.SnapshotsDir(), opts.PuckName)
@@ -317,100 +1564,495 @@ func (m *Manager) CreateSnapshot(ctx context.Context, opts SnapshotCreateOptions
 		return nil, fmt.Errorf("creating snapshot directory: %w", err)
 	}
 
-	// Create checkpoint archive
-	exportPath := filepath.Join(snapshotDir, opts.SnapshotName+".tar.gz")
-	if err := m.podman.Checkpoint(ctx, p.ID, podman.CheckpointOptions{
-		ExportPath:   exportPath,
-		LeaveRunning: opts.LeaveRunning,
-	}); err != nil {
-		return nil, fmt.Errorf("checkpointing container: %w", err)
+	snapshot := &store.Snapshot{
+		ID:        uuid.New().String(),
+		PuckID:    p.ID,
+		PuckName:  p.Name,
+		Name:      opts.SnapshotName,
+		CreatedAt: time.Now(),
+		ParentID:  p.SnapshotHead,
+		Image:     p.Image,
+		Mode:      mode,
 	}
 
-	// Get file size
-	info, err := os.Stat(exportPath)
-	if err != nil {
-		return nil, fmt.Errorf("getting snapshot size: %w", err)
+	if mode == store.SnapshotModeImage {
+		if err := m.commitSnapshot(ctx, p, snapshot, snapshotDir, running); err != nil {
+			return nil, err
+		}
+	} else {
+		// Container must be running to checkpoint
+		if !running {
+			return nil, fmt.Errorf("puck must be running to create snapshot")
+		}
+
+		// Create checkpoint archive
+		snapshot.Path = filepath.Join(snapshotDir, opts.SnapshotName+".tar.gz")
+		m.recordHost(ctx, snapshot)
+		m.recordImage(ctx, p, snapshot)
+		snapshot.CRIU = m.criuOptions(opts.CRIUFlags)
+
+		// A checkpoint that stops the puck isn't interrupted: CRIU could
+		// be killing its processes by then. A cancel waits for it and
+		// restores the puck from the result instead.
+		checkpointCtx := ctx
+		if !leaveRunning {
+			checkpointCtx = context.WithoutCancel(ctx)
+		}
+		stopWatching := watchArchive(ctx, p.Name, PhaseCheckpoint, snapshot.Path)
+		err := m.podman.Checkpoint(checkpointCtx, p.ContainerID, podman.CheckpointOptions{
+			ExportPath:     snapshot.Path,
+			LeaveRunning:   leaveRunning,
+			TCPEstablished: snapshot.CRIU.TCPEstablished,
+			FileLocks:      snapshot.CRIU.FileLocks,
+		})
+		stopWatching()
+		if err != nil {
+			// Don't leave a partial archive behind
+			os.Remove(snapshot.Path)
+			if ctx.Err() != nil {
+				return nil, fmt.Errorf("creating snapshot '%s' canceled: %w", snapshot.Name, ctx.Err())
+			}
+			return nil, fmt.Errorf("checkpointing container: %w", err)
+		}
+	}
+	stopped := mode == store.SnapshotModeCheckpoint && !leaveRunning
+	if ctx.Err() != nil {
+		return nil, m.abandonSnapshot(ctx, snapshot, stopped)
 	}
 
-	// Update puck status if not leaving running
-	if !opts.LeaveRunning {
-		m.store.UpdatePuckStatus(ctx, opts.PuckName, store.StatusCheckpointed)
+	if mode == store.SnapshotModeCheckpoint {
+		if err := m.storeCheckpoint(ctx, p, snapshot); err != nil {
+			if ctx.Err() != nil {
+				return nil, m.abandonSnapshot(ctx, snapshot, stopped)
+			}
+			m.removeSnapshotArtifacts(ctx, snapshot)
+			return nil, err
+		}
+	} else {
+		info, err := os.Stat(snapshot.Path)
+		if err != nil {
+			m.removeSnapshotArtifacts(ctx, snapshot)
+			return nil, fmt.Errorf("getting snapshot size: %w", err)
+		}
+		snapshot.SizeBytes = info.Size()
 	}
 
-	// Create snapshot record
-	now := time.Now()
-	snapshot := &store.Snapshot{
-		ID:        uuid.New().String(),
-		PuckID:    p.ID,
-		PuckName:  p.Name,
-		Name:      opts.SnapshotName,
-		Path:      exportPath,
-		SizeBytes: info.Size(),
-		CreatedAt: now,
+	// Update puck status if the checkpoint stopped it
+	if stopped {
+		m.store.UpdatePuckStatus(ctx, opts.PuckName, store.StatusCheckpointed)
 	}
 
 	if err := m.store.CreateSnapshot(ctx, snapshot); err != nil {
-		// Clean up the checkpoint file on failure
-		os.Remove(exportPath)
+		// Clean up the snapshot files on failure
+		m.removeSnapshotArtifacts(ctx, snapshot)
 		return nil, fmt.Errorf("saving snapshot: %w", err)
 	}
 
+	if err := m.store.UpdatePuckSnapshotHead(ctx, p.Name, snapshot.ID); err != nil {
+		return nil, err
+	}
+
+	m.record(ctx, p.Name, store.EventSnapshotCreated, snapshot.Name)
 	return snapshot, nil
 }
 
-// RestoreSnapshot restores a puck from a checkpoint snapshot
+// storeCheckpoint archives the volume directories a puck's snapshot
+// policy includes alongside its checkpoint, then keeps the checkpoint as
+// chunks or with the policy's compression, filling in the snapshot's size
+func (m *Manager) storeCheckpoint(ctx context.Context, p *store.Puck, snapshot *store.Snapshot) error {
+	compression := archiveCompressions[p.SnapshotPolicy.Compression]
+
+	var volumesSize int64
+	if p.SnapshotPolicy.IncludeVolumes {
+		path := strings.TrimSuffix(snapshot.Path, ".tar.gz") + ".volumes." + compression.Extension()
+		stopWatching := watchArchive(ctx, p.Name, PhaseVolumes, path)
+		err := archiveDir(p.VolumeDir, path, compression)
+		stopWatching()
+		if err != nil {
+			return err
+		}
+		snapshot.VolumesPath = path
+		if info, err := os.Stat(path); err == nil {
+			volumesSize = info.Size()
+		}
+	}
+
+	// Chunks are compressed one by one instead
+	if p.SnapshotPolicy.Compression != "" && !m.cfg.SnapshotDedup {
+		path, err := recompressArchive(ctx, p.Name, snapshot.Path, compression)
+		if err != nil {
+			return err
+		}
+		snapshot.Path = path
+	}
+
+	info, err := os.Stat(snapshot.Path)
+	if err != nil {
+		return fmt.Errorf("getting snapshot size: %w", err)
+	}
+	snapshot.SizeBytes = info.Size() + volumesSize
+
+	if m.cfg.SnapshotDedup {
+		return m.chunkSnapshot(ctx, snapshot)
+	}
+	return nil
+}
+
+// commitSnapshot commits a puck's container to an image and archives its
+// volumes, filling in the snapshot's image and path. A running container is
+// paused while it is committed.
+func (m *Manager) commitSnapshot(ctx context.Context, p *store.Puck, snapshot *store.Snapshot, snapshotDir string, running bool) error {
+	reportSnapshot(ctx, SnapshotProgress{Puck: p.Name, Phase: PhaseCommit})
+	if _, err := m.podman.CommitContainer(ctx, p.ContainerID, podman.CommitOptions{
+		Repo:  snapshotImageRepo,
+		Tag:   snapshot.ID,
+		Pause: running,
+	}); err != nil {
+		return err
+	}
+	snapshot.CommitImage = snapshotImageRepo + ":" + snapshot.ID
+
+	compression := archiveCompressions[p.SnapshotPolicy.Compression]
+	snapshot.Path = filepath.Join(snapshotDir, snapshot.Name+".volumes."+compression.Extension())
+	stopWatching := watchArchive(ctx, p.Name, PhaseVolumes, snapshot.Path)
+	err := archiveDir(p.VolumeDir, snapshot.Path, compression)
+	stopWatching()
+	if err != nil {
+		m.podman.RemoveImage(ctx, snapshot.CommitImage)
+		return err
+	}
+	return nil
+}
+
+// restoreCommittedSnapshot replaces a puck's volumes with the snapshot's
+// archive and starts a container from its committed image, returning the
+// new container ID
+func (m *Manager) restoreCommittedSnapshot(ctx context.Context, p *store.Puck, snapshot *store.Snapshot) (string, error) {
+	if err := replaceVolumes(p, snapshot.Path); err != nil {
+		return "", err
+	}
+
+	committed := *p
+	committed.Image = snapshot.CommitImage
+	containerID, err := m.createContainer(ctx, &committed)
+	if err != nil {
+		return "", err
+	}
+	if err := m.startContainer(ctx, &committed, containerID); err != nil {
+		m.podman.RemoveContainer(ctx, containerID, true)
+		return "", fmt.Errorf("starting container: %w", err)
+	}
+	return containerID, nil
+}
+
+// replaceVolumes replaces a puck's volume directories with the contents of
+// an archive written by archiveDir
+func replaceVolumes(p *store.Puck, archivePath string) error {
+	if err := os.RemoveAll(p.VolumeDir); err != nil {
+		return fmt.Errorf("clearing volumes: %w", err)
+	}
+	if err := os.MkdirAll(p.VolumeDir, 0755); err != nil {
+		return fmt.Errorf("creating volume directory: %w", err)
+	}
+	if err := extractArchive(archivePath, p.VolumeDir); err != nil {
+		return fmt.Errorf("restoring volumes: %w", err)
+	}
+	return nil
+}
+
+// removeSnapshotArtifacts deletes a snapshot's files and committed image
+func (m *Manager) removeSnapshotArtifacts(ctx context.Context, snapshot *store.Snapshot) error {
+	if err := os.Remove(snapshot.Path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing snapshot file: %w", err)
+	}
+	if snapshot.ColdPath != "" {
+		if err := os.Remove(snapshot.ColdPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing snapshot file from cold storage: %w", err)
+		}
+	}
+	if snapshot.VolumesPath != "" {
+		if err := os.Remove(snapshot.VolumesPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing snapshot volume archive: %w", err)
+		}
+	}
+	if snapshot.CommitImage != "" {
+		return m.podman.RemoveImage(ctx, snapshot.CommitImage)
+	}
+	return nil
+}
+
+// RestoreSnapshot restores a puck from a snapshot and routes it
 func (m *Manager) RestoreSnapshot(ctx context.Context, opts SnapshotRestoreOptions) error {
+	if err := m.restoreSnapshot(ctx, opts); err != nil {
+		return err
+	}
+	m.syncRoute(ctx, opts.PuckName)
+	return nil
+}
+
+// restoreSnapshot restores a puck from a snapshot
+func (m *Manager) restoreSnapshot(ctx context.Context, opts SnapshotRestoreOptions) error {
 	p, err := m.store.GetPuck(ctx, opts.PuckName)
 	if err != nil {
 		return err
 	}
 
-	snapshot, err := m.store.GetSnapshot(ctx, p.ID, opts.SnapshotName)
+	// Snapshots can be restored by name or tag
+	snapshot, _, err := m.findSnapshot(ctx, p.Name, opts.SnapshotName)
 	if err != nil {
 		return err
 	}
 
-	// Check if snapshot file exists
+	// Check if snapshot file exists, in cold storage or not
+	if _, err := os.Stat(snapshot.ArchivePath()); os.IsNotExist(err) {
+		return fmt.Errorf("snapshot file not found: %s", snapshot.ArchivePath())
+	}
+	if err := m.warmSnapshot(ctx, snapshot); err != nil {
+		return err
+	}
 	if _, err := os.Stat(snapshot.Path); os.IsNotExist(err) {
 		return fmt.Errorf("snapshot file not found: %s", snapshot.Path)
 	}
 
+	if opts.Repull {
+		if err := m.repullImage(ctx, snapshot); err != nil {
+			return err
+		}
+	}
+
+	// Refuse before the current container is removed rather than fail
+	// halfway through
+	problems := m.restoreProblems(ctx, snapshot)
+	if len(problems) > 0 && !opts.Force {
+		return fmt.Errorf("snapshot '%s' may not restore on this host: %s (use --force to try anyway)", snapshot.Name, strings.Join(problems, "; "))
+	}
+
+	if err := m.checkProjectLimits(ctx, p, false); err != nil {
+		return err
+	}
+	if err := m.ensureBudget(ctx, p); err != nil {
+		return err
+	}
+
 	// Stop existing container if running
-	running, _ := m.podman.IsRunning(ctx, p.ID)
+	running, _ := m.podman.IsRunning(ctx, p.ContainerID)
 	if running {
-		if err := m.podman.StopContainer(ctx, p.ID); err != nil {
+		if err := m.podman.StopContainer(ctx, p.ContainerID, m.stopTimeout(p)); err != nil {
 			return fmt.Errorf("stopping container: %w", err)
 		}
 	}
 
-	// Remove existing container
-	if err := m.podman.RemoveContainer(ctx, p.ID, true); err != nil {
-		// Container might not exist, continue anyway
+	// Move the existing container aside rather than remove it, so a
+	// failed restore can put it back
+	aside, err := m.moveAside(ctx, p)
+	if err != nil {
+		return err
+	}
+	hostPort := p.HostPort
+	putBack := func(restoreErr error) error {
+		// Clear out whatever the failed restore left under the puck's name
+		m.podman.RemoveContainer(ctx, p.Name, true)
+		if hostPort != p.HostPort {
+			m.store.UpdatePuckHostPort(ctx, p.Name, hostPort)
+			m.repointReservation(ctx, p.Name, p.HostPort, hostPort)
+		}
+		if !aside {
+			return restoreErr
+		}
+		if err := m.podman.RenameContainer(ctx, p.ContainerID, p.Name); err != nil {
+			return fmt.Errorf("%w (putting the previous container back: %v)", restoreErr, err)
+		}
+		if running {
+			if err := m.startContainer(ctx, p, p.ContainerID); err != nil {
+				return fmt.Errorf("%w (restarting the previous container: %v)", restoreErr, err)
+			}
+		}
+		return restoreErr
 	}
 
-	// Restore from checkpoint
-	newContainerID, err := m.podman.Restore(ctx, podman.RestoreOptions{
-		ImportPath: snapshot.Path,
-		Name:       opts.PuckName,
-	})
-	if err != nil {
-		return fmt.Errorf("restoring checkpoint: %w", err)
+	// The port may have been taken while the puck was away
+	if _, err := m.claimHostPort(ctx, p); err != nil {
+		return putBack(err)
+	}
+
+	var newContainerID string
+	if snapshot.Mode == store.SnapshotModeImage {
+		newContainerID, err = m.restoreCommittedSnapshot(ctx, p, snapshot)
+		if err != nil {
+			return putBack(err)
+		}
+	} else {
+		ports, err := m.portMappings(ctx, p)
+		if err != nil {
+			return putBack(err)
+		}
+		importPath, cleanup, err := m.checkpointArchive(snapshot)
+		if err != nil {
+			return putBack(err)
+		}
+		defer cleanup()
+		if snapshot.VolumesPath != "" {
+			if err := replaceVolumes(p, snapshot.VolumesPath); err != nil {
+				return putBack(err)
+			}
+		}
+		newContainerID, err = m.podman.Restore(ctx, podman.RestoreOptions{
+			ImportPath:     importPath,
+			Name:           opts.PuckName,
+			PublishPorts:   ports,
+			TCPEstablished: snapshot.CRIU.TCPEstablished,
+			FileLocks:      snapshot.CRIU.FileLocks,
+		})
+		if err != nil {
+			return putBack(fmt.Errorf("restoring checkpoint: %w", err))
+		}
+		// A restored container gets a fresh network namespace
+		if err := m.enforceEgress(ctx, p, newContainerID, false); err != nil {
+			return putBack(err)
+		}
+	}
+
+	// The restore worked, so the previous container can go
+	if aside {
+		m.podman.RemoveContainer(ctx, p.ContainerID, true)
 	}
 
 	// Update puck with new container ID and status
-	if _, err := m.store.ExecContext(ctx, `
-		UPDATE pucks SET id = ?, status = ?, updated_at = ? WHERE name = ?
-	`, newContainerID, store.StatusRunning, time.Now(), opts.PuckName); err != nil {
-		return fmt.Errorf("updating puck: %w", err)
+	if err := m.store.UpdatePuckContainerID(ctx, opts.PuckName, newContainerID); err != nil {
+		return err
+	}
+	if err := m.store.UpdatePuckStatus(ctx, opts.PuckName, store.StatusRunning); err != nil {
+		return err
+	}
+
+	// The restored container runs the image the snapshot was taken from
+	if snapshot.Image != "" && snapshot.Image != p.Image {
+		if err := m.store.UpdatePuckImage(ctx, opts.PuckName, snapshot.Image); err != nil {
+			return err
+		}
+	}
+
+	// Snapshots taken from here on branch off the restored one
+	if err := m.store.UpdatePuckSnapshotHead(ctx, opts.PuckName, snapshot.ID); err != nil {
+		return err
 	}
 
-	// Update container IP
+	// Update container IP, and the host port should podman have published
+	// another
 	ip, err := m.podman.GetContainerIP(ctx, newContainerID)
 	if err == nil {
 		m.store.UpdatePuckContainerIP(ctx, opts.PuckName, ip)
 	}
+	m.syncHostPort(ctx, p, newContainerID)
+	m.syncPublished(ctx, p, newContainerID)
 
-	return nil
+	m.store.TouchPuck(ctx, opts.PuckName, time.Now())
+	detail := snapshot.Name
+	if len(problems) > 0 {
+		detail += " (forced: " + strings.Join(problems, "; ") + ")"
+	}
+
+	// Restored processes wake up with the clock they were checkpointed
+	// with; a failed resync is worth a note but not undoing the restore
+	if snapshot.Mode != store.SnapshotModeImage {
+		if err := m.postRestore(ctx, p, newContainerID); err != nil {
+			detail += " (post-restore: " + err.Error() + ")"
+		}
+	}
+	m.record(ctx, opts.PuckName, store.EventSnapshotRestored, detail)
+	return m.dropResume(ctx, opts.PuckName)
+}
+
+// moveAside renames a puck's container out of the way of a restore,
+// reporting whether there was one to move. A container left aside by an
+// earlier restore that was interrupted is reused if it is the puck's own,
+// and removed otherwise.
+func (m *Manager) moveAside(ctx context.Context, p *store.Puck) (bool, error) {
+	if exists, _ := m.podman.ContainerExists(ctx, p.ContainerID); !exists {
+		return false, nil
+	}
+
+	aside := p.Name + "-pre-restore"
+	if data, err := m.podman.InspectContainer(ctx, aside); err == nil {
+		if data.ID == p.ContainerID {
+			return true, nil
+		}
+		m.podman.RemoveContainer(ctx, aside, true)
+	}
+	if err := m.podman.RenameContainer(ctx, p.ContainerID, aside); err != nil {
+		return false, fmt.Errorf("moving the current container aside: %w", err)
+	}
+	return true, nil
+}
+
+// snapshotForRollback checkpoints a running puck, leaving it running, and
+// moves the rollback tag to the new snapshot
+func (m *Manager) snapshotForRollback(ctx context.Context, name string) error {
+	leaveRunning := true
+	snapshot, err := m.CreateSnapshot(ctx, SnapshotCreateOptions{
+		PuckName:     name,
+		SnapshotName: "pre-recreate-" + time.Now().Format("20060102-150405.000"),
+		LeaveRunning: &leaveRunning,
+	})
+	if err != nil {
+		return fmt.Errorf("snapshotting before recreate: %w (use --no-snapshot to skip)", err)
+	}
+
+	if previous, _, err := m.findSnapshot(ctx, name, RollbackTag); err == nil {
+		if _, err := m.UntagSnapshot(ctx, name, previous.Name, RollbackTag); err != nil {
+			return err
+		}
+	}
+	_, err = m.TagSnapshot(ctx, name, snapshot.Name, RollbackTag)
+	return err
+}
+
+// Rollback restores a puck to the snapshot taken before it was last
+// recreated
+func (m *Manager) Rollback(ctx context.Context, name string) (*store.Snapshot, error) {
+	snapshot, _, err := m.findSnapshot(ctx, name, RollbackTag)
+	if err != nil {
+		if _, getErr := m.store.GetPuck(ctx, name); getErr != nil {
+			return nil, getErr
+		}
+		return nil, fmt.Errorf("no rollback point for puck '%s'", name)
+	}
+
+	if err := m.RestoreSnapshot(ctx, SnapshotRestoreOptions{PuckName: name, SnapshotName: snapshot.Name}); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// RollbackTo restores a puck to its newest snapshot taken at or before
+// at, such as one its snapshot schedule took, to undo what happened since
+func (m *Manager) RollbackTo(ctx context.Context, name string, at time.Time) (*store.Snapshot, error) {
+	snapshots, err := m.ListSnapshots(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Newest first
+	var snapshot *store.Snapshot
+	for _, s := range snapshots {
+		if !s.CreatedAt.After(at) {
+			snapshot = s
+			break
+		}
+	}
+	if snapshot == nil {
+		if len(snapshots) == 0 {
+			return nil, fmt.Errorf("puck '%s' has no snapshots; take them every few minutes with: puck snapshot config %s --schedule 10m --retain 6", name, name)
+		}
+		oldest := snapshots[len(snapshots)-1]
+		return nil, fmt.Errorf("no snapshot of puck '%s' from before %s; the oldest is '%s' from %s",
+			name, at.Local().Format("2006-01-02 15:04"), oldest.Name, oldest.CreatedAt.Local().Format("2006-01-02 15:04"))
+	}
+
+	if err := m.RestoreSnapshot(ctx, SnapshotRestoreOptions{PuckName: name, SnapshotName: snapshot.Name}); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
 }
 
 // ListSnapshots returns all snapshots for a puck
@@ -435,36 +2077,155 @@ func (m *Manager) DeleteSnapshot(ctx context.Context, puckName, snapshotName str
 		return err
 	}
 
-	// Remove snapshot file
-	if err := os.Remove(snapshot.Path); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("removing snapshot file: %w", err)
+	return m.dropSnapshot(ctx, p, snapshot)
+}
+
+// dropSnapshot removes a snapshot's artifacts and its record. p may be nil
+// when the puck no longer exists.
+func (m *Manager) dropSnapshot(ctx context.Context, p *store.Puck, snapshot *store.Snapshot) error {
+	// Remove snapshot file and any committed image
+	if err := m.removeSnapshotArtifacts(ctx, snapshot); err != nil {
+		return err
+	}
+
+	// Keep the tree connected: children and the head move up a level
+	if err := m.store.ReparentSnapshots(ctx, snapshot.ID, snapshot.ParentID); err != nil {
+		return err
+	}
+	if p != nil && p.SnapshotHead == snapshot.ID {
+		if err := m.store.UpdatePuckSnapshotHead(ctx, p.Name, snapshot.ParentID); err != nil {
+			return err
+		}
+		p.SnapshotHead = snapshot.ParentID
+	}
+	if p != nil && p.ResumeSnapshot == snapshot.ID {
+		if err := m.store.UpdatePuckResumeSnapshot(ctx, p.Name, ""); err != nil {
+			return err
+		}
+		p.ResumeSnapshot = ""
 	}
 
 	// Remove from database
-	return m.store.DeleteSnapshot(ctx, snapshot.ID)
+	if err := m.store.DeleteSnapshot(ctx, snapshot.ID); err != nil {
+		return err
+	}
+	if chunked(snapshot) {
+		// Best effort: leftover chunks go with the next snapshot dropped
+		m.sweepChunks(ctx)
+	}
+	return nil
 }
 
-// findAvailablePort finds the next available host port for puck routing
-func (m *Manager) findAvailablePort(ctx context.Context) (int, error) {
-	pucks, err := m.store.ListPucks(ctx)
+// snapshotTagPattern matches names that can be given to points in the tree
+var snapshotTagPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// TagSnapshot names a point in a puck's snapshot tree. A tag belongs to
+// one snapshot per puck.
+func (m *Manager) TagSnapshot(ctx context.Context, puckName, snapshotName, tag string) (*store.Snapshot, error) {
+	if !snapshotTagPattern.MatchString(tag) {
+		return nil, fmt.Errorf("invalid tag %q", tag)
+	}
+
+	snapshot, snapshots, err := m.findSnapshot(ctx, puckName, snapshotName)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	// Find the highest used port
-	usedPorts := make(map[int]bool)
-	for _, p := range pucks {
-		if p.HostPort > 0 {
-			usedPorts[p.HostPort] = true
+	for _, s := range snapshots {
+		if s.ID != snapshot.ID && slices.Contains(s.Tags, tag) {
+			return nil, fmt.Errorf("tag '%s' is already on snapshot '%s'", tag, s.Name)
+		}
+	}
+	if slices.Contains(snapshot.Tags, tag) {
+		return snapshot, nil
+	}
+
+	snapshot.Tags = append(snapshot.Tags, tag)
+	if err := m.store.UpdateSnapshotTags(ctx, snapshot.ID, snapshot.Tags); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// UntagSnapshot removes a tag from a snapshot
+func (m *Manager) UntagSnapshot(ctx context.Context, puckName, snapshotName, tag string) (*store.Snapshot, error) {
+	snapshot, _, err := m.findSnapshot(ctx, puckName, snapshotName)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(snapshot.Tags, tag) {
+		return nil, fmt.Errorf("snapshot '%s' has no tag '%s'", snapshot.Name, tag)
+	}
+
+	snapshot.Tags = slices.DeleteFunc(snapshot.Tags, func(t string) bool { return t == tag })
+	if err := m.store.UpdateSnapshotTags(ctx, snapshot.ID, snapshot.Tags); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// snapshotByID looks up one of a puck's snapshots by ID
+func (m *Manager) snapshotByID(ctx context.Context, p *store.Puck, id string) (*store.Snapshot, error) {
+	snapshots, err := m.store.ListSnapshots(ctx, p.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range snapshots {
+		if s.ID == id {
+			return s, nil
 		}
 	}
+	return nil, fmt.Errorf("snapshot %s %w", id, store.ErrNotFound)
+}
+
+// findSnapshot looks up a puck's snapshot by name or tag, along with all
+// of the puck's snapshots
+func (m *Manager) findSnapshot(ctx context.Context, puckName, ref string) (*store.Snapshot, []*store.Snapshot, error) {
+	snapshots, err := m.ListSnapshots(ctx, puckName)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	// Find first available port starting from BaseHostPort
-	for port := BaseHostPort; port < BaseHostPort+1000; port++ {
-		if !usedPorts[port] {
-			return port, nil
+	for _, s := range snapshots {
+		if s.Name == ref {
+			return s, snapshots, nil
 		}
 	}
+	for _, s := range snapshots {
+		if slices.Contains(s.Tags, ref) {
+			return s, snapshots, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("snapshot '%s' %w for puck", ref, store.ErrNotFound)
+}
+
+// SnapshotNode is a snapshot and the snapshots that branched from it
+type SnapshotNode struct {
+	Snapshot *store.Snapshot `json:"snapshot"`
+	Children []*SnapshotNode `json:"children,omitempty"`
+}
+
+// BuildSnapshotTree arranges snapshots by parent, oldest first. Snapshots
+// whose parent is unknown become roots.
+func BuildSnapshotTree(snapshots []*store.Snapshot) []*SnapshotNode {
+	sorted := slices.Clone(snapshots)
+	slices.SortStableFunc(sorted, func(a, b *store.Snapshot) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	nodes := make(map[string]*SnapshotNode, len(sorted))
+	for _, s := range sorted {
+		nodes[s.ID] = &SnapshotNode{Snapshot: s}
+	}
 
-	return 0, fmt.Errorf("no available ports in range %d-%d", BaseHostPort, BaseHostPort+1000)
+	var roots []*SnapshotNode
+	for _, s := range sorted {
+		node := nodes[s.ID]
+		if parent, ok := nodes[s.ParentID]; ok && s.ParentID != s.ID {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	return roots
 }
@@ -2,19 +2,29 @@ package puck
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/user"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/sandwich-labs/puck/internal/config"
 	"github.com/sandwich-labs/puck/internal/podman"
 	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/sandwich-labs/puck/internal/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 // setupTestManager creates a test manager with mock podman and temp database
+// leaveRunning is for snapshot options, which take a pointer
+var leaveRunning = &[]bool{true}[0]
+
 func setupTestManager(t *testing.T) (*Manager, *podman.MockClient, func()) {
 	t.Helper()
 
@@ -38,8 +48,10 @@ func setupTestManager(t *testing.T) (*Manager, *podman.MockClient, func()) {
 		containerCounter++
 		return fmt.Sprintf("mock-container-%d", containerCounter), nil
 	}
+	mock.CheckpointFunc = testutil.WriteCheckpoint
 
 	mgr := NewManager(cfg, mock, db)
+	mgr.criuVersion = func(ctx context.Context) string { return "3.19" }
 
 	cleanup := func() {
 		db.Close()
@@ -74,7 +86,7 @@ func TestCreate(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, "test-puck", p.Name)
 		assert.Equal(t, "fedora:latest", p.Image)
-		assert.Equal(t, store.StatusRunning, p.Status)
+		assert.Equal(t, store.StatusStarting, p.Status)
 		assert.Equal(t, BaseHostPort, p.HostPort)
 
 		// Verify container was created and started
@@ -113,6 +125,40 @@ func TestCreate(t *testing.T) {
 		}
 	})
 
+	t.Run("keeps volumes in a data directory of its own", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+		scratch := t.TempDir()
+		var created podman.CreateContainerOptions
+		mock.CreateContainerFunc = func(ctx context.Context, opts podman.CreateContainerOptions) (string, error) {
+			created = opts
+			return "container-" + opts.Name, nil
+		}
+
+		p, err := mgr.Create(ctx, CreateOptions{Name: "fast-puck", DataDir: scratch})
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(scratch, "fast-puck"), p.VolumeDir)
+		assert.DirExists(t, filepath.Join(scratch, "fast-puck", "home"))
+		assert.Contains(t, created.Volumes, filepath.Join(scratch, "fast-puck", "home"))
+
+		got, err := mgr.Get(ctx, "fast-puck")
+		require.NoError(t, err)
+		assert.Equal(t, p.VolumeDir, got.VolumeDir)
+
+		require.NoError(t, mgr.Destroy(ctx, "fast-puck", true))
+		assert.NoDirExists(t, p.VolumeDir)
+		assert.DirExists(t, scratch, "only the puck's own directory goes")
+
+		for _, dir := range []string{"relative", filepath.Join(scratch, "missing")} {
+			_, err := mgr.Create(ctx, CreateOptions{Name: "bad-dir", DataDir: dir})
+			assert.Error(t, err, dir)
+		}
+		require.NoError(t, os.Mkdir(filepath.Join(scratch, "taken"), 0755))
+		_, err = mgr.Create(ctx, CreateOptions{Name: "taken", DataDir: scratch})
+		assert.ErrorContains(t, err, "already exists")
+	})
+
 	t.Run("cleans up on container creation failure", func(t *testing.T) {
 		mgr, mock, cleanup := setupTestManager(t)
 		defer cleanup()
@@ -153,6 +199,422 @@ func TestCreate(t *testing.T) {
 		assert.True(t, mock.WasCalled("RemoveContainer"))
 	})
 
+	t.Run("rejects existing names without touching the puck", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		orig, err := mgr.Create(ctx, CreateOptions{Name: "dup-puck"})
+		require.NoError(t, err)
+		marker := filepath.Join(orig.VolumeDir, "home", "data")
+		require.NoError(t, os.WriteFile(marker, []byte("keep"), 0644))
+
+		mock.Reset()
+		mock.ContainerExistsFunc = func(ctx context.Context, name string) (bool, error) { return false, nil }
+		_, err = mgr.Create(ctx, CreateOptions{Name: "dup-puck"})
+		assert.ErrorIs(t, err, store.ErrExists)
+		assert.EqualError(t, err, "puck 'dup-puck' already exists")
+		assert.False(t, mock.WasCalled("CreateContainer"))
+		assert.FileExists(t, marker)
+
+		var exists *ExistsError
+		require.ErrorAs(t, err, &exists)
+		assert.False(t, exists.Container)
+		assert.Regexp(t, `^dup-puck-\d{4}$`, exists.Free)
+		assert.Equal(t, []string{
+			"Replace it, destroying it once the new one is made, by running the same command with --replace",
+			"Or use a free name, such as: " + exists.Free,
+		}, exists.Hints())
+	})
+
+	t.Run("applies the defaults rules it matches", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+		mgr.cfg.Labels = map[string]string{"team": "{{.User}}"}
+		mgr.cfg.Defaults = []config.DefaultsRule{
+			{Project: "infra", Image: "ubuntu:24.04", Shell: "/bin/zsh"},
+			{Labels: map[string]string{"team": "ops"}, User: "ops"},
+			{Profile: "go", Image: "golang:1.23"},
+		}
+
+		p, err := mgr.Create(ctx, CreateOptions{Name: "infra", Project: "infra", Owner: "ops"})
+		require.NoError(t, err)
+		assert.Equal(t, "ubuntu:24.04", p.Image)
+		assert.Equal(t, "/bin/zsh", p.Spec.Shell)
+		assert.Equal(t, "ops", p.Spec.User)
+
+		p, err = mgr.Create(ctx, CreateOptions{Name: "given", Project: "infra", Image: "alpine", Shell: "/bin/ash", Profile: "go"})
+		require.NoError(t, err)
+		assert.Equal(t, "alpine", p.Image, "the image asked for wins")
+		assert.Equal(t, "/bin/ash", p.Spec.Shell)
+		assert.Empty(t, p.Spec.User)
+
+		p, err = mgr.Create(ctx, CreateOptions{Name: "plain"})
+		require.NoError(t, err)
+		assert.Equal(t, "fedora:latest", p.Image)
+
+		_, err = mgr.Create(ctx, CreateOptions{Name: "rusty", Profile: "rust"})
+		assert.ErrorContains(t, err, `no defaults rule has profile "rust"`)
+	})
+
+	t.Run("names a container that isn't a puck holding the name", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		mock.CreateContainerFunc = func(ctx context.Context, opts podman.CreateContainerOptions) (string, error) {
+			return "", fmt.Errorf("creating container: %w: name %q is already in use", podman.ErrNameInUse, opts.Name)
+		}
+		mock.ContainerExistsFunc = func(ctx context.Context, name string) (bool, error) { return name == "stray", nil }
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "stray"})
+		assert.ErrorIs(t, err, store.ErrExists)
+		assert.EqualError(t, err, "a container named 'stray' already exists, and isn't a puck")
+		assert.NoDirExists(t, filepath.Join(mgr.cfg.PucksDir(), "stray"))
+
+		var exists *ExistsError
+		require.ErrorAs(t, err, &exists)
+		assert.True(t, exists.Container)
+		assert.Regexp(t, `^stray-\d{4}$`, exists.Free)
+		assert.Contains(t, exists.Hints()[0], "podman rm stray")
+	})
+
+	t.Run("cleans up when saving fails", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		// A canceled request still gets cleaned up after
+		ctx, cancel := context.WithCancel(ctx)
+		mock.StartContainerFunc = func(context.Context, string) error {
+			cancel()
+			return nil
+		}
+		var removed context.Context
+		mock.RemoveContainerFunc = func(ctx context.Context, nameOrID string, force bool) error {
+			removed = ctx
+			return nil
+		}
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "fail-save-puck"})
+		assert.Error(t, err)
+
+		require.NotNil(t, removed)
+		assert.NoError(t, removed.Err())
+		_, statErr := os.Stat(filepath.Join(mgr.cfg.DataDir, "pucks", "fail-save-puck"))
+		assert.True(t, os.IsNotExist(statErr))
+		_, err = mgr.Get(context.Background(), "fail-save-puck")
+		assert.Error(t, err)
+	})
+
+	t.Run("chooses the init", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		var created []podman.CreateContainerOptions
+		mock.CreateContainerFunc = func(ctx context.Context, opts podman.CreateContainerOptions) (string, error) {
+			created = append(created, opts)
+			return "container-" + opts.Name, nil
+		}
+
+		p, err := mgr.Create(ctx, CreateOptions{Name: "systemd-puck"})
+		require.NoError(t, err)
+		assert.Equal(t, store.InitSystemd, p.Spec.InitMode())
+		_, err = mgr.Create(ctx, CreateOptions{Name: "tini-puck", Init: store.InitTini})
+		require.NoError(t, err)
+		_, err = mgr.Create(ctx, CreateOptions{Name: "raw-puck", Init: store.InitNone})
+		require.NoError(t, err)
+
+		require.Len(t, created, 3)
+		assert.True(t, created[0].Systemd)
+		assert.False(t, created[0].Init)
+		assert.False(t, created[1].Systemd)
+		assert.True(t, created[1].Init)
+		assert.False(t, created[2].Systemd)
+		assert.False(t, created[2].Init)
+
+		// The choice survives a recreate
+		_, err = mgr.Recreate(ctx, RecreateOptions{Name: "tini-puck", NoSnapshot: true})
+		require.NoError(t, err)
+		require.Len(t, created, 4)
+		assert.True(t, created[3].Init)
+	})
+
+	t.Run("overrides the entrypoint and command", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		var created []podman.CreateContainerOptions
+		mock.CreateContainerFunc = func(ctx context.Context, opts podman.CreateContainerOptions) (string, error) {
+			created = append(created, opts)
+			return "container-" + opts.Name, nil
+		}
+
+		_, err := mgr.Create(ctx, CreateOptions{
+			Name:       "app-puck",
+			Init:       store.InitTini,
+			Entrypoint: []string{"/bin/sh", "-c"},
+			Command:    []string{"npm run dev"},
+		})
+		require.NoError(t, err)
+
+		p, err := mgr.Get(ctx, "app-puck")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"/bin/sh", "-c"}, p.Spec.Entrypoint)
+		assert.Equal(t, []string{"npm run dev"}, p.Spec.Command)
+
+		_, err = mgr.Recreate(ctx, RecreateOptions{Name: "app-puck", NoSnapshot: true})
+		require.NoError(t, err)
+		require.Len(t, created, 2)
+		for _, opts := range created {
+			assert.Equal(t, []string{"/bin/sh", "-c"}, opts.Entrypoint)
+			assert.Equal(t, []string{"npm run dev"}, opts.Command)
+		}
+	})
+
+	t.Run("sets hostname and name resolution", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		var created []podman.CreateContainerOptions
+		mock.CreateContainerFunc = func(ctx context.Context, opts podman.CreateContainerOptions) (string, error) {
+			created = append(created, opts)
+			return "container-" + opts.Name, nil
+		}
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "plain"})
+		require.NoError(t, err)
+		_, err = mgr.Create(ctx, CreateOptions{
+			Name:     "resolver",
+			Hostname: "dev.internal",
+			DNS:      []string{"10.0.0.53", "fd00::53"},
+			AddHosts: []string{"db.local:10.0.0.5", "host.local:host-gateway"},
+		})
+		require.NoError(t, err)
+
+		require.Len(t, created, 2)
+		assert.Equal(t, "plain", created[0].Hostname)
+		assert.Equal(t, "dev.internal", created[1].Hostname)
+		assert.Equal(t, []string{"10.0.0.53", "fd00::53"}, created[1].DNS)
+		assert.Equal(t, []string{"db.local:10.0.0.5", "host.local:host-gateway"}, created[1].AddHosts)
+
+		p, err := mgr.Get(ctx, "resolver")
+		require.NoError(t, err)
+		assert.Equal(t, "dev.internal", p.Spec.Hostname)
+		assert.Equal(t, []string{"10.0.0.53", "fd00::53"}, p.Spec.DNS)
+	})
+
+	t.Run("sets sysctls and ulimits", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		var created []podman.CreateContainerOptions
+		mock.CreateContainerFunc = func(ctx context.Context, opts podman.CreateContainerOptions) (string, error) {
+			created = append(created, opts)
+			return "container-" + opts.Name, nil
+		}
+
+		sysctls := map[string]string{"net.ipv4.ip_forward": "1"}
+		ulimits := []string{"nofile=65536:65536", "nproc=4096"}
+		_, err := mgr.Create(ctx, CreateOptions{Name: "db", Sysctls: sysctls, Ulimits: ulimits})
+		require.NoError(t, err)
+
+		_, err = mgr.Recreate(ctx, RecreateOptions{Name: "db", NoSnapshot: true})
+		require.NoError(t, err)
+		require.Len(t, created, 2)
+		for _, opts := range created {
+			assert.Equal(t, sysctls, opts.Sysctls)
+			assert.Equal(t, ulimits, opts.Ulimits)
+		}
+	})
+
+	t.Run("rejects invalid sysctls and ulimits", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		for _, opts := range []CreateOptions{
+			{Name: "bad-sysctl", Sysctls: map[string]string{"ip_forward": "1"}},
+			{Name: "empty-sysctl", Sysctls: map[string]string{"net.ipv4.ip_forward": ""}},
+			{Name: "bad-ulimit", Ulimits: []string{"files=1024"}},
+			{Name: "bad-ulimit-range", Ulimits: []string{"nofile=2048:1024"}},
+		} {
+			_, err := mgr.Create(ctx, opts)
+			assert.Error(t, err, opts.Name)
+		}
+		assert.False(t, mock.WasCalled("CreateContainer"))
+	})
+
+	t.Run("configures the user namespace", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		var created []podman.CreateContainerOptions
+		mock.CreateContainerFunc = func(ctx context.Context, opts podman.CreateContainerOptions) (string, error) {
+			created = append(created, opts)
+			return "container-" + opts.Name, nil
+		}
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "keep", UserNS: "keep-id:uid=1000,gid=1000"})
+		require.NoError(t, err)
+		_, err = mgr.Create(ctx, CreateOptions{Name: "mapped", UIDMap: []string{"0:100000:65536"}})
+		require.NoError(t, err)
+
+		require.Len(t, created, 2)
+		assert.Equal(t, podman.UserNS{Mode: "keep-id:uid=1000,gid=1000"}, created[0].UserNS)
+		assert.Equal(t, podman.UserNS{UIDMap: []string{"0:100000:65536"}}, created[1].UserNS)
+
+		p, err := mgr.Get(ctx, "keep")
+		require.NoError(t, err)
+		assert.Equal(t, "keep-id:uid=1000,gid=1000", p.Spec.UserNS)
+	})
+
+	t.Run("rejects invalid user namespaces", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		for _, opts := range []CreateOptions{
+			{Name: "bad-mode", UserNS: "host"},
+			{Name: "both", UserNS: "auto", UIDMap: []string{"0:100000:65536"}},
+			{Name: "bad-map", UIDMap: []string{"0:100000"}},
+			{Name: "empty-map", GIDMap: []string{"0:100000:0"}},
+		} {
+			_, err := mgr.Create(ctx, opts)
+			assert.Error(t, err, opts.Name)
+		}
+		assert.False(t, mock.WasCalled("CreateContainer"))
+	})
+
+	t.Run("sets security profiles", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		profile := filepath.Join(t.TempDir(), "seccomp.json")
+		require.NoError(t, os.WriteFile(profile, []byte(`{"defaultAction": "SCMP_ACT_ALLOW"}`), 0644))
+
+		var created []podman.CreateContainerOptions
+		mock.CreateContainerFunc = func(ctx context.Context, opts podman.CreateContainerOptions) (string, error) {
+			created = append(created, opts)
+			return "container-" + opts.Name, nil
+		}
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "debug", Seccomp: store.SeccompUnconfined, AppArmor: store.SeccompUnconfined})
+		require.NoError(t, err)
+		_, err = mgr.Create(ctx, CreateOptions{Name: "custom", Seccomp: profile})
+		require.NoError(t, err)
+
+		require.Len(t, created, 2)
+		assert.Equal(t, "unconfined", created[0].Seccomp)
+		assert.Equal(t, "unconfined", created[0].AppArmor)
+		assert.Equal(t, profile, created[1].Seccomp)
+		assert.Empty(t, created[1].AppArmor)
+
+		p, err := mgr.Get(ctx, "debug")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"seccomp unconfined", "apparmor unconfined"}, p.Spec.SecurityNotes())
+	})
+
+	t.Run("mounts shared paths", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		src := t.TempDir()
+		mgr.cfg.SharedPaths = []config.SharedPath{
+			{Path: src},
+			{Path: "/nonexistent/downloads", ReadOnly: true},
+		}
+
+		var created []podman.CreateContainerOptions
+		mock.CreateContainerFunc = func(ctx context.Context, opts podman.CreateContainerOptions) (string, error) {
+			created = append(created, opts)
+			return "container-" + opts.Name, nil
+		}
+
+		p, err := mgr.Create(ctx, CreateOptions{Name: "shared"})
+		require.NoError(t, err)
+
+		// Every configured path is recorded, but missing ones are not mounted
+		assert.Equal(t, []store.Mount{
+			{Source: src, Target: "/mnt/" + filepath.Base(src)},
+			{Source: "/nonexistent/downloads", Target: "/mnt/downloads", ReadOnly: true},
+		}, p.Spec.Mounts)
+		require.Len(t, created, 1)
+		assert.Equal(t, []podman.Mount{{Source: src, Destination: "/mnt/" + filepath.Base(src)}}, created[0].Mounts)
+
+		// Removing a shared path does not affect existing pucks
+		mgr.cfg.SharedPaths = nil
+		_, err = mgr.Recreate(ctx, RecreateOptions{Name: "shared", NoSnapshot: true})
+		require.NoError(t, err)
+		require.Len(t, created, 2)
+		assert.Equal(t, created[0].Mounts, created[1].Mounts)
+	})
+
+	t.Run("mounts shared paths only for the daemon's user and admins", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+		mgr.cfg.SharedPaths = []config.SharedPath{{Path: t.TempDir()}}
+		mgr.cfg.Admins = []string{"carol"}
+		me, err := user.Current()
+		require.NoError(t, err)
+
+		for owner, mounted := range map[string]bool{me.Username: true, "carol": true, "alice": false} {
+			p, err := mgr.Create(ctx, CreateOptions{Name: "shared-" + owner, Owner: owner})
+			require.NoError(t, err)
+			assert.Equal(t, mounted, len(p.Spec.Mounts) == 1, owner)
+		}
+	})
+
+	t.Run("rejects invalid security profiles", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		for _, opts := range []CreateOptions{
+			{Name: "relative", Seccomp: "profiles/seccomp.json"},
+			{Name: "missing", Seccomp: "/nonexistent/seccomp.json"},
+			{Name: "bad-apparmor", AppArmor: "my profile"},
+		} {
+			_, err := mgr.Create(ctx, opts)
+			assert.Error(t, err, opts.Name)
+		}
+		assert.False(t, mock.WasCalled("CreateContainer"))
+	})
+
+	t.Run("rejects invalid name resolution settings", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		for _, opts := range []CreateOptions{
+			{Name: "bad-hostname", Hostname: "under_score"},
+			{Name: "bad-dns", DNS: []string{"dns.example.com"}},
+			{Name: "bad-host", AddHosts: []string{"db.local"}},
+			{Name: "bad-host-ip", AddHosts: []string{"db.local:nowhere"}},
+		} {
+			_, err := mgr.Create(ctx, opts)
+			assert.Error(t, err, opts.Name)
+		}
+		assert.False(t, mock.WasCalled("CreateContainer"))
+	})
+
+	t.Run("rejects unknown init modes", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+
+		_, err := mgr.Create(context.Background(), CreateOptions{Name: "bad-init", Init: "runit"})
+		assert.ErrorContains(t, err, "unknown init mode")
+	})
+
 	t.Run("assigns unique port for each puck", func(t *testing.T) {
 		mgr, _, cleanup := setupTestManager(t)
 		defer cleanup()
@@ -281,6 +743,70 @@ func TestStart(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, store.StatusRunning, p.Status)
 	})
+
+	t.Run("rebuilds a missing container", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "restored-puck"})
+		require.NoError(t, err)
+		require.NoError(t, mgr.store.DetachContainers(ctx))
+
+		mock.ContainerExistsFunc = func(ctx context.Context, nameOrID string) (bool, error) {
+			return nameOrID != "", nil
+		}
+		mock.CreateContainerFunc = func(ctx context.Context, opts podman.CreateContainerOptions) (string, error) {
+			return "rebuilt-container-id", nil
+		}
+		mock.Reset()
+
+		require.NoError(t, mgr.Start(ctx, "restored-puck"))
+		assert.True(t, mock.WasCalled("CreateContainer"))
+		assert.True(t, mock.WasCalledWith("StartContainer", "rebuilt-container-id"))
+
+		p, err := mgr.Get(ctx, "restored-puck")
+		require.NoError(t, err)
+		assert.Equal(t, "rebuilt-container-id", p.ContainerID)
+		assert.Equal(t, store.StatusRunning, p.Status)
+	})
+}
+
+func TestMarkReady(t *testing.T) {
+	t.Run("marks a starting puck running", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "ready-puck"})
+		require.NoError(t, err)
+
+		// List keeps a starting puck starting while its container runs
+		pucks, err := mgr.List(ctx)
+		require.NoError(t, err)
+		require.Len(t, pucks, 1)
+		assert.Equal(t, store.StatusStarting, pucks[0].Status)
+
+		require.NoError(t, mgr.MarkReady(ctx, "ready-puck"))
+		p, err := mgr.Get(ctx, "ready-puck")
+		require.NoError(t, err)
+		assert.Equal(t, store.StatusRunning, p.Status)
+	})
+
+	t.Run("leaves a puck stopped while starting alone", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "ready-puck"})
+		require.NoError(t, err)
+		require.NoError(t, mgr.Stop(ctx, "ready-puck"))
+
+		require.NoError(t, mgr.MarkReady(ctx, "ready-puck"))
+		p, err := mgr.Get(ctx, "ready-puck")
+		require.NoError(t, err)
+		assert.Equal(t, store.StatusStopped, p.Status)
+	})
 }
 
 func TestStop(t *testing.T) {
@@ -315,24 +841,778 @@ func TestStop(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, store.StatusStopped, p.Status)
 	})
-}
 
-func TestDestroy(t *testing.T) {
-	t.Run("destroys puck and cleans up", func(t *testing.T) {
+	t.Run("uses the command's, puck's, or config's timeout", func(t *testing.T) {
 		mgr, mock, cleanup := setupTestManager(t)
 		defer cleanup()
 		ctx := context.Background()
+		mgr.cfg.StopTimeout = 10
 
-		p, err := mgr.Create(ctx, CreateOptions{Name: "destroy-puck"})
-		require.NoError(t, err)
-
-		volumeDir := p.VolumeDir
-		mock.Reset()
-		mock.IsRunningFunc = func(ctx context.Context, nameOrID string) (bool, error) {
-			return false, nil
+		var timeouts []uint
+		mock.StopContainerFunc = func(ctx context.Context, nameOrID string, timeout uint) error {
+			timeouts = append(timeouts, timeout)
+			return nil
 		}
 
-		err = mgr.Destroy(ctx, "destroy-puck", false)
+		thirty := 30
+		_, err := mgr.Create(ctx, CreateOptions{Name: "default-puck"})
+		require.NoError(t, err)
+		_, err = mgr.Create(ctx, CreateOptions{Name: "slow-puck", StopTimeout: &thirty})
+		require.NoError(t, err)
+
+		sixty := 60
+		require.NoError(t, mgr.Stop(ctx, "default-puck"))
+		require.NoError(t, mgr.Stop(ctx, "slow-puck"))
+		require.NoError(t, mgr.StopWithOptions(ctx, StopOptions{Name: "slow-puck", Timeout: &sixty}))
+		assert.Equal(t, []uint{10, 30, 60}, timeouts)
+	})
+
+	t.Run("rejects timeouts out of range", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		tooLong := config.MaxStopTimeout + 1
+		_, err := mgr.Create(ctx, CreateOptions{Name: "forever-puck", StopTimeout: &tooLong})
+		assert.ErrorContains(t, err, "stop timeout")
+
+		_, err = mgr.Create(ctx, CreateOptions{Name: "stop-puck"})
+		require.NoError(t, err)
+		negative := -1
+		err = mgr.StopWithOptions(ctx, StopOptions{Name: "stop-puck", Timeout: &negative})
+		assert.ErrorContains(t, err, "stop timeout")
+	})
+
+	t.Run("suspends to a resume image and resumes from it", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "suspend-puck"})
+		require.NoError(t, err)
+
+		for range 2 {
+			mock.Reset()
+			require.NoError(t, mgr.StopWithOptions(ctx, StopOptions{Name: "suspend-puck", Checkpoint: true}))
+			assert.False(t, mock.WasCalled("StopContainer"))
+
+			p, err := mgr.Get(ctx, "suspend-puck")
+			require.NoError(t, err)
+			assert.Equal(t, store.StatusSuspended, p.Status)
+			assert.NotEmpty(t, p.ResumeSnapshot)
+
+			require.NoError(t, mgr.Start(ctx, "suspend-puck"))
+			assert.True(t, mock.WasCalled("Restore"))
+
+			p, err = mgr.Get(ctx, "suspend-puck")
+			require.NoError(t, err)
+			assert.Equal(t, store.StatusRunning, p.Status)
+			assert.Empty(t, p.ResumeSnapshot)
+		}
+
+		// Resume images are dropped once the puck runs again
+		snapshots, err := mgr.ListSnapshots(ctx, "suspend-puck")
+		require.NoError(t, err)
+		assert.Empty(t, snapshots)
+
+		events, err := mgr.History(ctx, "suspend-puck", time.Time{})
+		require.NoError(t, err)
+		var stopped *store.Event
+		for _, e := range events {
+			if e.Type == store.EventStopped {
+				stopped = e
+			}
+		}
+		require.NotNil(t, stopped)
+		assert.Equal(t, "suspended", stopped.Detail)
+	})
+
+	t.Run("stopping a suspended puck discards its resume image", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "discard-puck"})
+		require.NoError(t, err)
+		require.NoError(t, mgr.StopWithOptions(ctx, StopOptions{Name: "discard-puck", Checkpoint: true}))
+		require.NoError(t, mgr.Stop(ctx, "discard-puck"))
+
+		snapshots, err := mgr.ListSnapshots(ctx, "discard-puck")
+		require.NoError(t, err)
+		assert.Empty(t, snapshots)
+
+		mock.Reset()
+		require.NoError(t, mgr.Start(ctx, "discard-puck"))
+		assert.False(t, mock.WasCalled("Restore"))
+		assert.True(t, mock.WasCalled("StartContainer"))
+	})
+
+	t.Run("boots a suspended puck whose resume image is gone", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "lost-puck"})
+		require.NoError(t, err)
+		require.NoError(t, mgr.StopWithOptions(ctx, StopOptions{Name: "lost-puck", Checkpoint: true}))
+		snapshots, err := mgr.ListSnapshots(ctx, "lost-puck")
+		require.NoError(t, err)
+		require.Len(t, snapshots, 1)
+		require.NoError(t, os.Remove(snapshots[0].Path))
+
+		mock.Reset()
+		require.NoError(t, mgr.Start(ctx, "lost-puck"))
+		assert.False(t, mock.WasCalled("Restore"))
+
+		p, err := mgr.Get(ctx, "lost-puck")
+		require.NoError(t, err)
+		assert.Equal(t, store.StatusRunning, p.Status)
+		assert.Empty(t, p.ResumeSnapshot)
+	})
+
+	t.Run("won't checkpoint a stopped puck", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "stopped-puck"})
+		require.NoError(t, err)
+		require.NoError(t, mgr.Stop(ctx, "stopped-puck"))
+
+		err = mgr.StopWithOptions(ctx, StopOptions{Name: "stopped-puck", Checkpoint: true})
+		assert.ErrorContains(t, err, "must be running")
+	})
+}
+
+func TestKill(t *testing.T) {
+	t.Run("SIGKILL stops the puck", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "kill-puck"})
+		require.NoError(t, err)
+		require.NoError(t, mgr.MarkReady(ctx, "kill-puck"))
+
+		mock.Reset()
+		require.NoError(t, mgr.Kill(ctx, "kill-puck", ""))
+		require.True(t, mock.WasCalled("KillContainer"))
+		assert.Equal(t, "SIGKILL", mock.Calls[0].Args[1])
+
+		p, err := mgr.Get(ctx, "kill-puck")
+		require.NoError(t, err)
+		assert.Equal(t, store.StatusStopped, p.Status)
+	})
+
+	t.Run("other signals leave it running", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "hup-puck"})
+		require.NoError(t, err)
+		require.NoError(t, mgr.MarkReady(ctx, "hup-puck"))
+
+		mock.Reset()
+		require.NoError(t, mgr.Kill(ctx, "hup-puck", "HUP"))
+		assert.Equal(t, "HUP", mock.Calls[0].Args[1])
+
+		p, err := mgr.Get(ctx, "hup-puck")
+		require.NoError(t, err)
+		assert.Equal(t, store.StatusRunning, p.Status)
+	})
+
+	t.Run("accepts signal numbers", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "nine-puck"})
+		require.NoError(t, err)
+		require.NoError(t, mgr.Kill(ctx, "nine-puck", "9"))
+
+		p, err := mgr.Get(ctx, "nine-puck")
+		require.NoError(t, err)
+		assert.Equal(t, store.StatusStopped, p.Status)
+	})
+
+	t.Run("rejects unknown signals", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "bogus-puck"})
+		require.NoError(t, err)
+
+		mock.Reset()
+		assert.ErrorContains(t, mgr.Kill(ctx, "bogus-puck", "SIGBOGUS"), "invalid signal")
+		assert.ErrorContains(t, mgr.Kill(ctx, "bogus-puck", "99"), "invalid signal")
+		assert.False(t, mock.WasCalled("KillContainer"))
+	})
+}
+
+func TestSetNotes(t *testing.T) {
+	mgr, _, cleanup := setupTestManager(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := mgr.Create(ctx, CreateOptions{Name: "notes-puck"})
+	require.NoError(t, err)
+
+	p, err := mgr.SetNotes(ctx, "notes-puck", "  staging clone of prod db\n")
+	require.NoError(t, err)
+	assert.Equal(t, "staging clone of prod db", p.Notes)
+
+	_, err = mgr.SetNotes(ctx, "notes-puck", strings.Repeat("x", maxNotes+1))
+	assert.ErrorContains(t, err, "keep them under")
+
+	p, err = mgr.SetNotes(ctx, "notes-puck", "")
+	require.NoError(t, err)
+	assert.Empty(t, p.Notes)
+}
+
+func TestSetResources(t *testing.T) {
+	t.Run("updates the running container in place", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "res-puck"})
+		require.NoError(t, err)
+
+		mock.Reset()
+		p, err := mgr.SetResources(ctx, "res-puck", store.Resources{Memory: 2 << 30, CPUs: 1.5})
+		require.NoError(t, err)
+		assert.Equal(t, store.Resources{Memory: 2 << 30, CPUs: 1.5}, p.Resources)
+
+		require.True(t, mock.WasCalled("UpdateResources"))
+		assert.Equal(t, podman.Resources{Memory: 2 << 30, CPUs: 1.5}, mock.Calls[0].Args[1])
+	})
+
+	t.Run("falls back to a new container on next start", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		orig, err := mgr.Create(ctx, CreateOptions{Name: "pending-puck"})
+		require.NoError(t, err)
+
+		mock.UpdateResourcesFunc = func(ctx context.Context, nameOrID string, res podman.Resources) error {
+			return errors.New("cgroup v1 not supported")
+		}
+		p, err := mgr.SetResources(ctx, "pending-puck", store.Resources{Memory: 512 << 20})
+		require.NoError(t, err)
+		assert.True(t, p.Resources.Pending)
+
+		require.NoError(t, mgr.Stop(ctx, "pending-puck"))
+
+		var created podman.CreateContainerOptions
+		mock.CreateContainerFunc = func(ctx context.Context, opts podman.CreateContainerOptions) (string, error) {
+			created = opts
+			return "resized-container-id", nil
+		}
+		require.NoError(t, mgr.Start(ctx, "pending-puck"))
+		assert.Equal(t, podman.Resources{Memory: 512 << 20}, created.Resources)
+
+		p, err = mgr.Get(ctx, "pending-puck")
+		require.NoError(t, err)
+		assert.Equal(t, "resized-container-id", p.ContainerID)
+		assert.Equal(t, orig.ID, p.ID)
+		assert.False(t, p.Resources.Pending)
+		assert.Equal(t, int64(512<<20), p.Resources.Memory)
+	})
+
+	t.Run("applies limits to recreated containers", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "recreate-res-puck"})
+		require.NoError(t, err)
+		_, err = mgr.SetResources(ctx, "recreate-res-puck", store.Resources{CPUs: 2})
+		require.NoError(t, err)
+
+		var created podman.CreateContainerOptions
+		mock.CreateContainerFunc = func(ctx context.Context, opts podman.CreateContainerOptions) (string, error) {
+			created = opts
+			return "recreated-id", nil
+		}
+		_, err = mgr.Recreate(ctx, RecreateOptions{Name: "recreate-res-puck"})
+		require.NoError(t, err)
+		assert.Equal(t, podman.Resources{CPUs: 2}, created.Resources)
+	})
+
+	t.Run("rejects negative limits", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "neg-puck"})
+		require.NoError(t, err)
+
+		_, err = mgr.SetResources(ctx, "neg-puck", store.Resources{Memory: -1})
+		assert.Error(t, err)
+		_, err = mgr.SetResources(ctx, "neg-puck", store.Resources{CPUs: -0.5})
+		assert.Error(t, err)
+	})
+}
+
+func TestBudget(t *testing.T) {
+	// limited creates a running puck with the given memory limit
+	limited := func(t *testing.T, mgr *Manager, name string, memory int64) {
+		ctx := context.Background()
+		_, err := mgr.Create(ctx, CreateOptions{Name: name})
+		require.NoError(t, err)
+		require.NoError(t, mgr.MarkReady(ctx, name))
+		_, err = mgr.SetResources(ctx, name, store.Resources{Memory: memory})
+		require.NoError(t, err)
+	}
+
+	t.Run("refuses to start past the budget", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+		mgr.cfg.BudgetMemory = 4 << 30
+		mgr.cfg.BudgetPolicy = config.BudgetRefuse
+
+		limited(t, mgr, "budget-a", 3<<30)
+		limited(t, mgr, "budget-b", 1<<30)
+		require.NoError(t, mgr.Stop(ctx, "budget-b"))
+		_, err := mgr.SetResources(ctx, "budget-b", store.Resources{Memory: 2 << 30})
+		require.NoError(t, err)
+
+		err = mgr.Start(ctx, "budget-b")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "exceed the resource budget")
+		assert.Contains(t, err.Error(), "memory: 3GiB reserved + 2GiB requested > 4GiB")
+
+		p, err := mgr.Get(ctx, "budget-b")
+		require.NoError(t, err)
+		assert.Equal(t, store.StatusStopped, p.Status)
+	})
+
+	t.Run("refuses to raise a running puck past the budget", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+		mgr.cfg.BudgetCPUs = 2
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "cpu-puck"})
+		require.NoError(t, err)
+		_, err = mgr.SetResources(ctx, "cpu-puck", store.Resources{CPUs: 2})
+		require.NoError(t, err)
+		_, err = mgr.SetResources(ctx, "cpu-puck", store.Resources{CPUs: 3})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "cpus: 0 reserved + 3 requested > 2")
+	})
+
+	t.Run("checkpoints the least recently used puck", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+		mgr.cfg.BudgetMemory = 4 << 30
+		mgr.cfg.BudgetPolicy = config.BudgetCheckpoint
+
+		limited(t, mgr, "lru-old", 2<<30)
+		limited(t, mgr, "lru-new", 2<<30)
+		limited(t, mgr, "lru-next", 1<<30)
+		require.NoError(t, mgr.Stop(ctx, "lru-next"))
+		require.NoError(t, mgr.store.TouchPuck(ctx, "lru-old", time.Now().Add(-time.Hour)))
+
+		require.NoError(t, mgr.Start(ctx, "lru-next"))
+
+		old, err := mgr.Get(ctx, "lru-old")
+		require.NoError(t, err)
+		assert.Equal(t, store.StatusSuspended, old.Status)
+		newer, err := mgr.Get(ctx, "lru-new")
+		require.NoError(t, err)
+		assert.Equal(t, store.StatusRunning, newer.Status)
+
+		events, err := mgr.History(ctx, "lru-old", time.Time{})
+		require.NoError(t, err)
+		assert.Equal(t, store.EventCheckpointed, events[len(events)-1].Type)
+
+		// Waking the checkpointed puck restores it and evicts the next oldest
+		mock.Reset()
+		require.NoError(t, mgr.Start(ctx, "lru-old"))
+		assert.True(t, mock.WasCalled("Restore"))
+
+		newer, err = mgr.Get(ctx, "lru-new")
+		require.NoError(t, err)
+		assert.Equal(t, store.StatusSuspended, newer.Status)
+	})
+
+	t.Run("checkpoints the least recently used puck on demand", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		p, err := mgr.CheckpointLRU(ctx, "memory pressure")
+		require.NoError(t, err)
+		assert.Nil(t, p)
+
+		for _, name := range []string{"idle", "busy"} {
+			_, err = mgr.Create(ctx, CreateOptions{Name: name})
+			require.NoError(t, err)
+			require.NoError(t, mgr.MarkReady(ctx, name))
+		}
+		require.NoError(t, mgr.Touch(ctx, "idle", time.Now().Add(-time.Hour)))
+
+		p, err = mgr.CheckpointLRU(ctx, "memory pressure")
+		require.NoError(t, err)
+		require.NotNil(t, p)
+		assert.Equal(t, "idle", p.Name)
+		assert.Equal(t, store.StatusSuspended, p.Status)
+
+		events, err := mgr.History(ctx, "idle", time.Time{})
+		require.NoError(t, err)
+		last := events[len(events)-1]
+		assert.Equal(t, store.EventCheckpointed, last.Type)
+		assert.Equal(t, "memory pressure", last.Detail)
+	})
+
+	t.Run("pucks without limits reserve nothing", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+		mgr.cfg.BudgetMemory = 1 << 30
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "unlimited"})
+		require.NoError(t, err)
+		require.NoError(t, mgr.Stop(ctx, "unlimited"))
+		assert.NoError(t, mgr.Start(ctx, "unlimited"))
+	})
+}
+
+func TestHistory(t *testing.T) {
+	t.Run("records the puck lifecycle", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "history-puck", Image: "nginx:1.25"})
+		require.NoError(t, err)
+		require.NoError(t, mgr.Stop(ctx, "history-puck"))
+		require.NoError(t, mgr.Start(ctx, "history-puck"))
+		_, err = mgr.Recreate(ctx, RecreateOptions{Name: "history-puck", Image: "nginx:1.27"})
+		require.NoError(t, err)
+
+		events, err := mgr.History(ctx, "history-puck", time.Time{})
+		require.NoError(t, err)
+		require.Len(t, events, 4)
+		assert.Equal(t, store.EventCreated, events[0].Type)
+		assert.Equal(t, "nginx:1.25", events[0].Detail)
+		assert.Equal(t, store.EventStopped, events[1].Type)
+		assert.Equal(t, store.EventStarted, events[2].Type)
+		assert.Equal(t, store.EventRecreated, events[3].Type)
+		assert.Equal(t, "nginx:1.27", events[3].Detail)
+	})
+
+	t.Run("notes what triggered an action", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "woken-puck", Image: "nginx:1.25"})
+		require.NoError(t, err)
+		require.NoError(t, mgr.Stop(ctx, "woken-puck"))
+		require.NoError(t, mgr.Start(WithTrigger(ctx, "waking woken-puck for request abc123"), "woken-puck"))
+
+		events, err := mgr.History(ctx, "woken-puck", time.Time{})
+		require.NoError(t, err)
+		require.Len(t, events, 3)
+		assert.Equal(t, "nginx:1.25", events[0].Detail)
+		assert.Equal(t, store.EventStarted, events[2].Type)
+		assert.Equal(t, "waking woken-puck for request abc123", events[2].Detail)
+	})
+
+	t.Run("records a crash once", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "crash-puck"})
+		require.NoError(t, err)
+
+		// Container exits without being stopped through puck
+		mock.IsRunningFunc = func(ctx context.Context, nameOrID string) (bool, error) {
+			return false, nil
+		}
+		_, err = mgr.List(ctx)
+		require.NoError(t, err)
+		_, err = mgr.List(ctx)
+		require.NoError(t, err)
+
+		events, err := mgr.History(ctx, "crash-puck", time.Time{})
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+		assert.Equal(t, store.EventCrashed, events[1].Type)
+
+		p, err := mgr.Get(ctx, "crash-puck")
+		require.NoError(t, err)
+		assert.Equal(t, store.StatusStopped, p.Status)
+	})
+
+	t.Run("does not treat a stop as a crash", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "quiet-puck"})
+		require.NoError(t, err)
+		require.NoError(t, mgr.Stop(ctx, "quiet-puck"))
+
+		mock.IsRunningFunc = func(ctx context.Context, nameOrID string) (bool, error) {
+			return false, nil
+		}
+		_, err = mgr.List(ctx)
+		require.NoError(t, err)
+
+		events, err := mgr.History(ctx, "quiet-puck", time.Time{})
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+		assert.Equal(t, store.EventStopped, events[1].Type)
+	})
+
+	t.Run("is removed with the puck", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "gone-puck"})
+		require.NoError(t, err)
+		require.NoError(t, mgr.Destroy(ctx, "gone-puck", true))
+
+		_, err = mgr.History(ctx, "gone-puck", time.Time{})
+		assert.Error(t, err)
+
+		// A new puck with the same name starts with a clean history
+		_, err = mgr.Create(ctx, CreateOptions{Name: "gone-puck"})
+		require.NoError(t, err)
+		events, err := mgr.History(ctx, "gone-puck", time.Time{})
+		require.NoError(t, err)
+		assert.Len(t, events, 1)
+	})
+}
+
+func TestRecreate(t *testing.T) {
+	t.Run("replaces container from pulled image keeping volumes", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		orig, err := mgr.Create(ctx, CreateOptions{Name: "recreate-puck", Image: "nginx:1.25"})
+		require.NoError(t, err)
+
+		mock.Reset()
+		mock.CreateContainerFunc = func(ctx context.Context, opts podman.CreateContainerOptions) (string, error) {
+			return "new-container-id", nil
+		}
+
+		p, err := mgr.Recreate(ctx, RecreateOptions{Name: "recreate-puck"})
+		require.NoError(t, err)
+
+		assert.Equal(t, "new-container-id", p.ContainerID)
+		assert.Equal(t, orig.ID, p.ID)
+		assert.Equal(t, "nginx:1.25", p.Image)
+		assert.Equal(t, orig.VolumeDir, p.VolumeDir)
+		assert.Equal(t, orig.HostPort, p.HostPort)
+		assert.Equal(t, store.StatusRunning, p.Status)
+		assert.DirExists(t, filepath.Join(p.VolumeDir, "home"))
+
+		assert.True(t, mock.WasCalled("PullImage"))
+		assert.True(t, mock.WasCalled("RemoveContainer"))
+		assert.True(t, mock.WasCalled("StartContainer"))
+	})
+
+	t.Run("switches to a new image", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "image-puck", Image: "nginx:1.25"})
+		require.NoError(t, err)
+
+		var pulled string
+		mock.PullImageFunc = func(ctx context.Context, imageName string) error {
+			pulled = imageName
+			return nil
+		}
+
+		p, err := mgr.Recreate(ctx, RecreateOptions{Name: "image-puck", Image: "nginx:1.27"})
+		require.NoError(t, err)
+		assert.Equal(t, "nginx:1.27", pulled)
+		assert.Equal(t, "nginx:1.27", p.Image)
+	})
+
+	t.Run("leaves container alone when pull fails", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "pull-fail-puck"})
+		require.NoError(t, err)
+
+		mock.Reset()
+		mock.PullImageFunc = func(ctx context.Context, imageName string) error {
+			return errors.New("manifest unknown")
+		}
+
+		_, err = mgr.Recreate(ctx, RecreateOptions{Name: "pull-fail-puck"})
+		assert.Error(t, err)
+		assert.False(t, mock.WasCalled("RemoveContainer"))
+	})
+
+	t.Run("returns error for non-existent puck", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+
+		_, err := mgr.Recreate(context.Background(), RecreateOptions{Name: "non-existent"})
+		assert.Error(t, err)
+	})
+}
+
+func TestRollback(t *testing.T) {
+	// setup returns a manager that snapshots before recreating
+	setup := func(t *testing.T) (*Manager, *podman.MockClient, func()) {
+		mgr, mock, cleanup := setupTestManager(t)
+		mgr.cfg.SnapshotBeforeRecreate = true
+
+		_, err := mgr.Create(context.Background(), CreateOptions{Name: "rollback-puck", Image: "nginx:1.25"})
+		require.NoError(t, err)
+		return mgr, mock, cleanup
+	}
+
+	t.Run("snapshots before recreating and rolls back to it", func(t *testing.T) {
+		mgr, _, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		p, err := mgr.Recreate(ctx, RecreateOptions{Name: "rollback-puck", Image: "nginx:1.27"})
+		require.NoError(t, err)
+		assert.Equal(t, "nginx:1.27", p.Image)
+
+		snapshots, err := mgr.ListSnapshots(ctx, "rollback-puck")
+		require.NoError(t, err)
+		require.Len(t, snapshots, 1)
+		assert.Equal(t, []string{RollbackTag}, snapshots[0].Tags)
+		assert.Equal(t, "nginx:1.25", snapshots[0].Image)
+
+		snapshot, err := mgr.Rollback(ctx, "rollback-puck")
+		require.NoError(t, err)
+		assert.Equal(t, snapshots[0].ID, snapshot.ID)
+
+		p, err = mgr.Get(ctx, "rollback-puck")
+		require.NoError(t, err)
+		assert.Equal(t, "nginx:1.25", p.Image)
+		assert.Equal(t, "restored-container-id", p.ContainerID)
+		assert.Equal(t, store.StatusRunning, p.Status)
+	})
+
+	t.Run("moves the rollback point on each recreate", func(t *testing.T) {
+		mgr, _, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Recreate(ctx, RecreateOptions{Name: "rollback-puck", Image: "nginx:1.26"})
+		require.NoError(t, err)
+		_, err = mgr.Recreate(ctx, RecreateOptions{Name: "rollback-puck", Image: "nginx:1.27"})
+		require.NoError(t, err)
+
+		snapshot, _, err := mgr.findSnapshot(ctx, "rollback-puck", RollbackTag)
+		require.NoError(t, err)
+		assert.Equal(t, "nginx:1.26", snapshot.Image)
+
+		snapshots, err := mgr.ListSnapshots(ctx, "rollback-puck")
+		require.NoError(t, err)
+		assert.Len(t, snapshots, 2)
+	})
+
+	t.Run("skips the snapshot when asked", func(t *testing.T) {
+		mgr, mock, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		mock.Reset()
+		_, err := mgr.Recreate(ctx, RecreateOptions{Name: "rollback-puck", NoSnapshot: true})
+		require.NoError(t, err)
+		assert.False(t, mock.WasCalled("Checkpoint"))
+
+		_, err = mgr.Rollback(ctx, "rollback-puck")
+		assert.ErrorContains(t, err, "no rollback point")
+	})
+
+	t.Run("keeps the old container when the snapshot fails", func(t *testing.T) {
+		mgr, mock, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		mock.Reset()
+		mock.CheckpointFunc = func(ctx context.Context, nameOrID string, opts podman.CheckpointOptions) error {
+			return errors.New("criu not found")
+		}
+
+		_, err := mgr.Recreate(ctx, RecreateOptions{Name: "rollback-puck", Image: "nginx:1.27"})
+		assert.ErrorContains(t, err, "--no-snapshot")
+		assert.False(t, mock.WasCalled("RemoveContainer"))
+
+		p, err := mgr.Get(ctx, "rollback-puck")
+		require.NoError(t, err)
+		assert.Equal(t, "nginx:1.25", p.Image)
+	})
+
+	t.Run("rolls back to a point in time", func(t *testing.T) {
+		mgr, _, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.RollbackTo(ctx, "rollback-puck", time.Now())
+		assert.ErrorContains(t, err, "has no snapshots; take them every few minutes with: puck snapshot config rollback-puck --schedule 10m --retain 6")
+
+		older, err := mgr.CreateSnapshot(ctx, SnapshotCreateOptions{PuckName: "rollback-puck", SnapshotName: "older"})
+		require.NoError(t, err)
+		between := time.Now()
+		_, err = mgr.CreateSnapshot(ctx, SnapshotCreateOptions{PuckName: "rollback-puck", SnapshotName: "newer"})
+		require.NoError(t, err)
+
+		_, err = mgr.RollbackTo(ctx, "rollback-puck", older.CreatedAt.Add(-time.Minute))
+		assert.ErrorContains(t, err, "no snapshot of puck 'rollback-puck' from before")
+		assert.ErrorContains(t, err, "the oldest is 'older'")
+
+		snapshot, err := mgr.RollbackTo(ctx, "rollback-puck", between)
+		require.NoError(t, err)
+		assert.Equal(t, "older", snapshot.Name)
+
+		p, err := mgr.Get(ctx, "rollback-puck")
+		require.NoError(t, err)
+		assert.Equal(t, "restored-container-id", p.ContainerID)
+	})
+
+	t.Run("returns error for non-existent puck", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+
+		_, err := mgr.Rollback(context.Background(), "non-existent")
+		assert.ErrorContains(t, err, "not found")
+	})
+}
+
+func TestDestroy(t *testing.T) {
+	t.Run("destroys puck and cleans up", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		p, err := mgr.Create(ctx, CreateOptions{Name: "destroy-puck"})
+		require.NoError(t, err)
+
+		volumeDir := p.VolumeDir
+		mock.Reset()
+		mock.IsRunningFunc = func(ctx context.Context, nameOrID string) (bool, error) {
+			return false, nil
+		}
+
+		err = mgr.Destroy(ctx, "destroy-puck", false)
 		require.NoError(t, err)
 
 		// Verify container was removed
@@ -403,11 +1683,15 @@ func TestDestroyAll(t *testing.T) {
 			return false, nil
 		}
 
-		destroyed, err := mgr.DestroyAll(ctx, true)
+		results, err := mgr.DestroyAll(ctx, true)
 		require.NoError(t, err)
-		assert.Len(t, destroyed, 2)
-		assert.Contains(t, destroyed, "all-puck1")
-		assert.Contains(t, destroyed, "all-puck2")
+		require.Len(t, results, 2)
+		var names []string
+		for _, r := range results {
+			assert.Empty(t, r.Error, r.Puck)
+			names = append(names, r.Puck)
+		}
+		assert.ElementsMatch(t, []string{"all-puck1", "all-puck2"}, names)
 
 		// Verify all pucks are gone
 		pucks, err := mgr.List(ctx)
@@ -420,40 +1704,421 @@ func TestDestroyAll(t *testing.T) {
 		defer cleanup()
 		ctx := context.Background()
 
-		destroyed, err := mgr.DestroyAll(ctx, true)
+		results, err := mgr.DestroyAll(ctx, true)
+		require.NoError(t, err)
+		assert.Empty(t, results)
+	})
+
+	t.Run("destroys only the owner's pucks", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "alice-puck", Owner: "alice"})
+		require.NoError(t, err)
+		_, err = mgr.Create(ctx, CreateOptions{Name: "bob-puck", Owner: "bob"})
+		require.NoError(t, err)
+
+		results, err := mgr.DestroyAllOwnedBy(ctx, "alice", true)
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, "alice-puck", results[0].Puck)
+		assert.Empty(t, results[0].Error)
+
+		p, err := mgr.Get(ctx, "bob-puck")
+		require.NoError(t, err)
+		assert.Equal(t, "bob", p.Owner)
+	})
+
+	t.Run("destroys concurrently and reports each failure", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		mock.CreateContainerFunc = func(ctx context.Context, opts podman.CreateContainerOptions) (string, error) {
+			return "ctr-" + opts.Name, nil
+		}
+		var ids []string
+		for i := range destroyAllConcurrency + 2 {
+			p, err := mgr.Create(ctx, CreateOptions{Name: fmt.Sprintf("many-%d", i)})
+			require.NoError(t, err)
+			ids = append(ids, p.ContainerID)
+		}
+		broken := ids[0]
+
+		var mu sync.Mutex
+		inFlight, peak := 0, 0
+		mock.IsRunningFunc = func(ctx context.Context, nameOrID string) (bool, error) {
+			return false, nil
+		}
+		mock.RemoveContainerFunc = func(ctx context.Context, nameOrID string, force bool) error {
+			mu.Lock()
+			inFlight++
+			peak = max(peak, inFlight)
+			mu.Unlock()
+			time.Sleep(20 * time.Millisecond)
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+			if nameOrID == broken {
+				return errors.New("container is busy")
+			}
+			return nil
+		}
+
+		results, err := mgr.DestroyAll(ctx, false)
+		require.NoError(t, err)
+		require.Len(t, results, len(ids))
+
+		failed := 0
+		for _, r := range results {
+			assert.Positive(t, r.Duration, r.Puck)
+			if r.Error != "" {
+				failed++
+				assert.Contains(t, r.Error, "container is busy")
+			}
+		}
+		assert.Equal(t, 1, failed)
+		assert.Greater(t, peak, 1, "pucks are destroyed concurrently")
+		assert.LessOrEqual(t, peak, destroyAllConcurrency)
+	})
+}
+
+func TestExists(t *testing.T) {
+	t.Run("returns true for existing puck", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "exists-puck"})
+		require.NoError(t, err)
+
+		assert.True(t, mgr.Exists(ctx, "exists-puck"))
+	})
+
+	t.Run("returns false for non-existent puck", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		assert.False(t, mgr.Exists(ctx, "non-existent"))
+	})
+}
+
+func TestSetRouteConfig(t *testing.T) {
+	t.Run("stores route settings on the puck", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "route-puck"})
+		require.NoError(t, err)
+
+		rc := store.RouteConfig{FlushInterval: -1, StreamTimeout: time.Hour}
+		p, err := mgr.SetRouteConfig(ctx, "route-puck", rc)
+		require.NoError(t, err)
+		assert.Equal(t, rc, p.Route)
+	})
+
+	t.Run("rejects unknown protocol", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "proto-puck"})
+		require.NoError(t, err)
+
+		_, err = mgr.SetRouteConfig(ctx, "proto-puck", store.RouteConfig{Protocol: "quic"})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "unsupported route protocol")
+	})
+
+	t.Run("rejects invalid headers and rewrites", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "rewrite-puck"})
+		require.NoError(t, err)
+
+		_, err = mgr.SetRouteConfig(ctx, "rewrite-puck", store.RouteConfig{
+			RequestHeaders: map[string]string{"Bad Name": "x"},
+		})
+		assert.Error(t, err)
+
+		_, err = mgr.SetRouteConfig(ctx, "rewrite-puck", store.RouteConfig{
+			Rewrites: []store.Rewrite{{Find: "(", Replace: "/"}},
+		})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid rewrite pattern")
+	})
+
+	t.Run("rejects negative traffic limits", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "limit-puck"})
+		require.NoError(t, err)
+
+		_, err = mgr.SetRouteConfig(ctx, "limit-puck", store.RouteConfig{MaxConns: -1})
+		assert.Error(t, err)
+		_, err = mgr.SetRouteConfig(ctx, "limit-puck", store.RouteConfig{Bandwidth: -1})
+		assert.Error(t, err)
+
+		p, err := mgr.SetRouteConfig(ctx, "limit-puck", store.RouteConfig{MaxConns: 10, Bandwidth: 1 << 20})
+		require.NoError(t, err)
+		assert.Equal(t, 10, p.Route.MaxConns)
+		assert.Equal(t, int64(1<<20), p.Route.Bandwidth)
+	})
+
+	t.Run("returns error for non-existent puck", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.SetRouteConfig(ctx, "non-existent", store.RouteConfig{})
+		assert.Error(t, err)
+	})
+}
+
+func TestShareOnTailnet(t *testing.T) {
+	t.Run("stores tags", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "ts-puck"})
+		require.NoError(t, err)
+
+		p, err := mgr.ShareOnTailnet(ctx, "ts-puck", []string{"tag:dev", "tag:web-team"})
+		require.NoError(t, err)
+		require.NotNil(t, p.Tailnet)
+		assert.Equal(t, []string{"tag:dev", "tag:web-team"}, p.Tailnet.Tags)
+
+		p, err = mgr.UnshareFromTailnet(ctx, "ts-puck")
+		require.NoError(t, err)
+		assert.Nil(t, p.Tailnet)
+	})
+
+	t.Run("rejects invalid tags", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "ts-bad-puck"})
+		require.NoError(t, err)
+
+		for _, tag := range []string{"dev", "tag:", "tag:has space", "tag:1abc"} {
+			_, err := mgr.ShareOnTailnet(ctx, "ts-bad-puck", []string{tag})
+			assert.Error(t, err, tag)
+		}
+	})
+
+	t.Run("returns error for non-existent puck", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+
+		_, err := mgr.ShareOnTailnet(context.Background(), "non-existent", nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestShares(t *testing.T) {
+	t.Run("creates signed expiring links", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "share-puck"})
+		require.NoError(t, err)
+
+		share, err := mgr.CreateShare(ctx, ShareCreateOptions{PuckName: "share-puck", TTL: time.Hour, CreatedBy: "alice"})
+		require.NoError(t, err)
+		assert.Equal(t, "share-puck", share.PuckName)
+		assert.Equal(t, "alice", share.CreatedBy)
+		assert.True(t, strings.HasPrefix(share.Token, share.ID+"."))
+		assert.WithinDuration(t, time.Now().Add(time.Hour), share.ExpiresAt, 2*time.Second)
+
+		info, err := os.Stat(mgr.cfg.ShareKeyPath())
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+		shares, err := mgr.ListShares(ctx, "share-puck")
+		require.NoError(t, err)
+		require.Len(t, shares, 1)
+		assert.Equal(t, share.Token, shares[0].Token)
+	})
+
+	t.Run("requires a positive duration", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "share-puck"})
+		require.NoError(t, err)
+
+		_, err = mgr.CreateShare(ctx, ShareCreateOptions{PuckName: "share-puck"})
+		assert.Error(t, err)
+	})
+
+	t.Run("returns error for non-existent puck", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+
+		_, err := mgr.CreateShare(context.Background(), ShareCreateOptions{PuckName: "non-existent", TTL: time.Hour})
+		assert.Error(t, err)
+	})
+
+	t.Run("active shares skip expired and re-keyed links", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "share-puck"})
+		require.NoError(t, err)
+
+		share, err := mgr.CreateShare(ctx, ShareCreateOptions{PuckName: "share-puck", TTL: time.Hour})
+		require.NoError(t, err)
+		expired, err := mgr.CreateShare(ctx, ShareCreateOptions{PuckName: "share-puck", TTL: time.Second})
+		require.NoError(t, err)
+		expired.ExpiresAt = time.Now().Add(-time.Minute)
+		require.NoError(t, mgr.store.DeleteShare(ctx, expired.ID))
+		require.NoError(t, mgr.store.CreateShare(ctx, expired))
+
+		active, err := mgr.ActiveShares(ctx)
+		require.NoError(t, err)
+		require.Len(t, active, 1)
+		assert.Equal(t, share.ID, active[0].ID)
+
+		pruned, err := mgr.PruneShares(ctx)
+		require.NoError(t, err)
+		require.Len(t, pruned, 1)
+		assert.Equal(t, expired.ID, pruned[0].ID)
+
+		// A new signing key invalidates existing tokens
+		require.NoError(t, os.Remove(mgr.cfg.ShareKeyPath()))
+		active, err = mgr.ActiveShares(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, active)
+	})
+
+	t.Run("revokes links", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "share-puck"})
+		require.NoError(t, err)
+		share, err := mgr.CreateShare(ctx, ShareCreateOptions{PuckName: "share-puck", TTL: time.Hour})
+		require.NoError(t, err)
+
+		revoked, err := mgr.RevokeShare(ctx, share.ID)
+		require.NoError(t, err)
+		assert.Equal(t, share.Token, revoked.Token)
+
+		_, err = mgr.RevokeShare(ctx, share.ID)
+		assert.Error(t, err)
+	})
+
+	t.Run("destroy removes links", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "share-puck"})
+		require.NoError(t, err)
+		_, err = mgr.CreateShare(ctx, ShareCreateOptions{PuckName: "share-puck", TTL: time.Hour})
+		require.NoError(t, err)
+
+		require.NoError(t, mgr.Destroy(ctx, "share-puck", true))
+
+		shares, err := mgr.ListShares(ctx, "")
+		require.NoError(t, err)
+		assert.Empty(t, shares)
+	})
+}
+
+func TestRouteAliases(t *testing.T) {
+	t.Run("points a path at a puck and swaps it", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		for _, name := range []string{"backend-v1", "backend-v2"} {
+			_, err := mgr.Create(ctx, CreateOptions{Name: name})
+			require.NoError(t, err)
+		}
+
+		alias, previous, err := mgr.SetRouteAlias(ctx, RouteAliasOptions{Path: "api/", PuckName: "backend-v1", CreatedBy: "alice"})
+		require.NoError(t, err)
+		assert.Equal(t, "/api", alias.Path)
+		assert.Nil(t, previous)
+
+		alias, previous, err = mgr.SetRouteAlias(ctx, RouteAliasOptions{Path: "/api", PuckName: "backend-v2"})
+		require.NoError(t, err)
+		assert.Equal(t, "backend-v2", alias.PuckName)
+		require.NotNil(t, previous)
+		assert.Equal(t, "backend-v1", previous.PuckName)
+
+		// Putting the previous alias back undoes the swap
+		require.NoError(t, mgr.RestoreRouteAlias(ctx, previous))
+		aliases, err := mgr.ListRouteAliases(ctx, "backend-v1")
 		require.NoError(t, err)
-		assert.Empty(t, destroyed)
+		require.Len(t, aliases, 1)
+		assert.Equal(t, "/api", aliases[0].Path)
 	})
-}
 
-func TestExists(t *testing.T) {
-	t.Run("returns true for existing puck", func(t *testing.T) {
+	t.Run("rejects bad paths and shadowed pucks", func(t *testing.T) {
 		mgr, _, cleanup := setupTestManager(t)
 		defer cleanup()
 		ctx := context.Background()
 
-		_, err := mgr.Create(ctx, CreateOptions{Name: "exists-puck"})
+		_, err := mgr.Create(ctx, CreateOptions{Name: "web"})
 		require.NoError(t, err)
 
-		assert.True(t, mgr.Exists(ctx, "exists-puck"))
+		for _, path := range []string{"", "/", "/_share", "/a/../b"} {
+			_, _, err := mgr.SetRouteAlias(ctx, RouteAliasOptions{Path: path, PuckName: "web"})
+			assert.ErrorContains(t, err, "invalid alias path", path)
+		}
+		_, _, err = mgr.SetRouteAlias(ctx, RouteAliasOptions{Path: "/web", PuckName: "web"})
+		assert.ErrorContains(t, err, "own route")
+		_, _, err = mgr.SetRouteAlias(ctx, RouteAliasOptions{Path: "/api", PuckName: "non-existent"})
+		assert.Error(t, err)
 	})
 
-	t.Run("returns false for non-existent puck", func(t *testing.T) {
+	t.Run("removes aliases, and destroy removes its puck's", func(t *testing.T) {
 		mgr, _, cleanup := setupTestManager(t)
 		defer cleanup()
 		ctx := context.Background()
 
-		assert.False(t, mgr.Exists(ctx, "non-existent"))
+		_, err := mgr.Create(ctx, CreateOptions{Name: "web"})
+		require.NoError(t, err)
+		for _, path := range []string{"/app", "/docs"} {
+			_, _, err := mgr.SetRouteAlias(ctx, RouteAliasOptions{Path: path, PuckName: "web"})
+			require.NoError(t, err)
+		}
+
+		removed, err := mgr.RemoveRouteAlias(ctx, "/app/")
+		require.NoError(t, err)
+		assert.Equal(t, "web", removed.PuckName)
+		_, err = mgr.RemoveRouteAlias(ctx, "/app")
+		assert.Error(t, err)
+
+		require.NoError(t, mgr.Destroy(ctx, "web", true))
+		aliases, err := mgr.ListRouteAliases(ctx, "")
+		require.NoError(t, err)
+		assert.Empty(t, aliases)
 	})
 }
 
-func TestFindAvailablePort(t *testing.T) {
+func TestAllocatePort(t *testing.T) {
 	t.Run("returns base port when no pucks", func(t *testing.T) {
 		mgr, _, cleanup := setupTestManager(t)
 		defer cleanup()
 		ctx := context.Background()
 
-		port, err := mgr.findAvailablePort(ctx)
+		port, err := mgr.allocatePort(ctx, "next")
 		require.NoError(t, err)
 		assert.Equal(t, BaseHostPort, port)
 	})
@@ -470,7 +2135,7 @@ func TestFindAvailablePort(t *testing.T) {
 		require.NoError(t, err)
 
 		// Next port should be BaseHostPort+2
-		port, err := mgr.findAvailablePort(ctx)
+		port, err := mgr.allocatePort(ctx, "next")
 		require.NoError(t, err)
 		assert.Equal(t, BaseHostPort+2, port)
 	})
@@ -491,10 +2156,77 @@ func TestFindAvailablePort(t *testing.T) {
 		require.NoError(t, err)
 
 		// Should reuse base port
-		port, err := mgr.findAvailablePort(ctx)
+		port, err := mgr.allocatePort(ctx, "next")
 		require.NoError(t, err)
 		assert.Equal(t, BaseHostPort, port)
 	})
+
+	t.Run("skips ports reserved for other pucks", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		require.NoError(t, mgr.store.ReservePort(ctx, BaseHostPort, "other"))
+		require.NoError(t, mgr.store.ReservePort(ctx, BaseHostPort+1, "next"))
+
+		port, err := mgr.allocatePort(ctx, "next")
+		require.NoError(t, err)
+		assert.Equal(t, BaseHostPort+1, port, "its own reservation is its to take")
+
+		port, err = mgr.allocatePort(ctx, "another")
+		require.NoError(t, err)
+		assert.Equal(t, BaseHostPort+2, port)
+	})
+
+	t.Run("gives a puck created again the same hashed port", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+		mgr.ports = NewPortAllocator(config.PortHash)
+
+		p, err := mgr.Create(ctx, CreateOptions{Name: "hashed"})
+		require.NoError(t, err)
+		first := p.HostPort
+		require.NoError(t, mgr.Destroy(ctx, "hashed", true))
+
+		// Another puck takes the port in the meantime, then gives it up
+		require.NoError(t, mgr.store.ReservePort(ctx, first, "squatter"))
+		port, err := mgr.allocatePort(ctx, "hashed")
+		require.NoError(t, err)
+		assert.NotEqual(t, first, port)
+		require.NoError(t, mgr.store.DeletePortReservationsByPuck(ctx, "squatter"))
+		require.NoError(t, mgr.store.DeletePortReservationsByPuck(ctx, "hashed"))
+
+		p, err = mgr.Create(ctx, CreateOptions{Name: "hashed"})
+		require.NoError(t, err)
+		assert.Equal(t, first, p.HostPort)
+	})
+
+	t.Run("gives out random ports in range", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+		mgr.ports = NewPortAllocator(config.PortRandom)
+
+		seen := make(map[int]bool)
+		for _, name := range []string{"a", "b", "c"} {
+			p, err := mgr.Create(ctx, CreateOptions{Name: name})
+			require.NoError(t, err)
+			assert.GreaterOrEqual(t, p.HostPort, BaseHostPort)
+			assert.Less(t, p.HostPort, BaseHostPort+HostPortCount)
+			assert.False(t, seen[p.HostPort])
+			seen[p.HostPort] = true
+		}
+	})
+}
+
+func TestPortAllocators(t *testing.T) {
+	for _, strategy := range []string{config.PortSequential, config.PortRandom, config.PortHash} {
+		ports := NewPortAllocator(strategy).Candidates("web", 100, 10)
+		sorted := slices.Sorted(slices.Values(ports))
+		assert.Equal(t, []int{100, 101, 102, 103, 104, 105, 106, 107, 108, 109}, sorted, "%s tries every port once", strategy)
+	}
+	assert.Equal(t, NewPortAllocator(config.PortHash).Candidates("web", 100, 10), NewPortAllocator(config.PortHash).Candidates("web", 100, 10))
 }
 
 func TestConsole(t *testing.T) {
@@ -539,6 +2271,65 @@ func TestConsole(t *testing.T) {
 		assert.True(t, mock.WasCalled("StartContainer"))
 		assert.True(t, mock.WasCalled("Console"))
 	})
+
+	t.Run("defaults to sh without systemd", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "sh-puck", Init: store.InitNone})
+		require.NoError(t, err)
+
+		mock.Reset()
+		var shell string
+		mock.ConsoleFunc = func(ctx context.Context, containerID string, s, user string, env []string) error {
+			shell = s
+			return nil
+		}
+		require.NoError(t, mgr.Console(ctx, "sh-puck", ""))
+		assert.Equal(t, "/bin/sh", shell)
+
+		require.NoError(t, mgr.Console(ctx, "sh-puck", "/bin/zsh"))
+		assert.Equal(t, "/bin/zsh", shell)
+	})
+
+	t.Run("uses the puck's shell and user", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "zsh-puck", Shell: "/bin/zsh", User: "dev"})
+		require.NoError(t, err)
+
+		var shell, user string
+		mock.ConsoleFunc = func(ctx context.Context, containerID string, s, u string, env []string) error {
+			shell, user = s, u
+			return nil
+		}
+		require.NoError(t, mgr.Console(ctx, "zsh-puck", ""))
+		assert.Equal(t, "/bin/zsh", shell)
+		assert.Equal(t, "dev", user)
+
+		require.NoError(t, mgr.Console(ctx, "zsh-puck", "/bin/bash"))
+		assert.Equal(t, "/bin/bash", shell)
+	})
+
+	t.Run("tells the shell which puck it is in", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "env-puck"})
+		require.NoError(t, err)
+
+		var env []string
+		mock.ConsoleFunc = func(ctx context.Context, containerID string, s, user string, e []string) error {
+			env = e
+			return nil
+		}
+		require.NoError(t, mgr.Console(ctx, "env-puck", ""))
+		assert.Equal(t, []string{"PUCK_CONSOLE=env-puck"}, env)
+	})
 }
 
 func TestCreateSnapshot(t *testing.T) {
@@ -547,15 +2338,6 @@ func TestCreateSnapshot(t *testing.T) {
 		defer cleanup()
 		ctx := context.Background()
 
-		// Create checkpoint file when Checkpoint is called
-		mock.CheckpointFunc = func(ctx context.Context, nameOrID string, opts podman.CheckpointOptions) error {
-			// Create the snapshot file
-			if err := os.MkdirAll(filepath.Dir(opts.ExportPath), 0755); err != nil {
-				return err
-			}
-			return os.WriteFile(opts.ExportPath, []byte("checkpoint-data"), 0644)
-		}
-
 		_, err := mgr.Create(ctx, CreateOptions{Name: "snapshot-puck"})
 		require.NoError(t, err)
 
@@ -567,7 +2349,7 @@ func TestCreateSnapshot(t *testing.T) {
 		snapshot, err := mgr.CreateSnapshot(ctx, SnapshotCreateOptions{
 			PuckName:     "snapshot-puck",
 			SnapshotName: "test-snap",
-			LeaveRunning: true,
+			LeaveRunning: leaveRunning,
 		})
 		require.NoError(t, err)
 		assert.Equal(t, "test-snap", snapshot.Name)
@@ -575,6 +2357,58 @@ func TestCreateSnapshot(t *testing.T) {
 		assert.True(t, mock.WasCalled("Checkpoint"))
 	})
 
+	t.Run("checkpoints and restores with the chosen CRIU options", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+		mgr.cfg.CheckpointTCPEstablished = true
+
+		var checkpointed podman.CheckpointOptions
+		mock.CheckpointFunc = func(ctx context.Context, nameOrID string, opts podman.CheckpointOptions) error {
+			checkpointed = opts
+			return testutil.WriteCheckpoint(ctx, nameOrID, opts)
+		}
+		var restored podman.RestoreOptions
+		mock.RestoreFunc = func(ctx context.Context, opts podman.RestoreOptions) (string, error) {
+			restored = opts
+			return "restored-container-id", nil
+		}
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "criu-puck"})
+		require.NoError(t, err)
+
+		// Unset flags use the configured defaults
+		fileLocks := true
+		snapshot, err := mgr.CreateSnapshot(ctx, SnapshotCreateOptions{
+			PuckName:     "criu-puck",
+			SnapshotName: "locks",
+			LeaveRunning: leaveRunning,
+			CRIUFlags:    CRIUFlags{FileLocks: &fileLocks},
+		})
+		require.NoError(t, err)
+		assert.True(t, checkpointed.TCPEstablished)
+		assert.True(t, checkpointed.FileLocks)
+		assert.Equal(t, store.CRIUOptions{TCPEstablished: true, FileLocks: true}, snapshot.CRIU)
+
+		noTCP := false
+		_, err = mgr.CreateSnapshot(ctx, SnapshotCreateOptions{
+			PuckName:     "criu-puck",
+			SnapshotName: "no-tcp",
+			LeaveRunning: leaveRunning,
+			CRIUFlags:    CRIUFlags{TCPEstablished: &noTCP},
+		})
+		require.NoError(t, err)
+		assert.False(t, checkpointed.TCPEstablished)
+		assert.False(t, checkpointed.FileLocks)
+
+		// A restore uses what the checkpoint was taken with, not the config
+		require.NoError(t, mgr.RestoreSnapshot(ctx, SnapshotRestoreOptions{PuckName: "criu-puck", SnapshotName: "no-tcp"}))
+		assert.False(t, restored.TCPEstablished)
+		require.NoError(t, mgr.RestoreSnapshot(ctx, SnapshotRestoreOptions{PuckName: "criu-puck", SnapshotName: "locks"}))
+		assert.True(t, restored.TCPEstablished)
+		assert.True(t, restored.FileLocks)
+	})
+
 	t.Run("fails when puck not running", func(t *testing.T) {
 		mgr, mock, cleanup := setupTestManager(t)
 		defer cleanup()
@@ -596,6 +2430,292 @@ func TestCreateSnapshot(t *testing.T) {
 	})
 }
 
+func TestSnapshotTree(t *testing.T) {
+	// setup returns a manager with a running puck to snapshot
+	setup := func(t *testing.T) (*Manager, func()) {
+		mgr, mock, cleanup := setupTestManager(t)
+		mock.IsRunningFunc = func(ctx context.Context, nameOrID string) (bool, error) {
+			return true, nil
+		}
+
+		_, err := mgr.Create(context.Background(), CreateOptions{Name: "tree-puck"})
+		require.NoError(t, err)
+		return mgr, cleanup
+	}
+
+	snap := func(t *testing.T, mgr *Manager, name string) *store.Snapshot {
+		s, err := mgr.CreateSnapshot(context.Background(), SnapshotCreateOptions{PuckName: "tree-puck", SnapshotName: name, LeaveRunning: leaveRunning})
+		require.NoError(t, err)
+		return s
+	}
+
+	t.Run("restores branch the tree", func(t *testing.T) {
+		mgr, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		base := snap(t, mgr, "base")
+		first := snap(t, mgr, "first-try")
+		assert.Equal(t, base.ID, first.ParentID)
+
+		require.NoError(t, mgr.RestoreSnapshot(ctx, SnapshotRestoreOptions{PuckName: "tree-puck", SnapshotName: "base"}))
+		p, err := mgr.Get(ctx, "tree-puck")
+		require.NoError(t, err)
+		assert.Equal(t, base.ID, p.SnapshotHead)
+
+		second := snap(t, mgr, "second-try")
+		assert.Equal(t, base.ID, second.ParentID)
+
+		snapshots, err := mgr.ListSnapshots(ctx, "tree-puck")
+		require.NoError(t, err)
+		roots := BuildSnapshotTree(snapshots)
+		require.Len(t, roots, 1)
+		assert.Equal(t, "base", roots[0].Snapshot.Name)
+		require.Len(t, roots[0].Children, 2)
+		assert.Equal(t, "first-try", roots[0].Children[0].Snapshot.Name)
+		assert.Equal(t, "second-try", roots[0].Children[1].Snapshot.Name)
+	})
+
+	t.Run("tags name points in the tree", func(t *testing.T) {
+		mgr, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		snap(t, mgr, "base")
+		snap(t, mgr, "tuned")
+
+		tagged, err := mgr.TagSnapshot(ctx, "tree-puck", "base", "known-good")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"known-good"}, tagged.Tags)
+
+		_, err = mgr.TagSnapshot(ctx, "tree-puck", "tuned", "known-good")
+		assert.ErrorContains(t, err, "already on snapshot 'base'")
+		_, err = mgr.TagSnapshot(ctx, "tree-puck", "tuned", "bad tag")
+		assert.Error(t, err)
+
+		// Tags can be used wherever a snapshot name is expected
+		require.NoError(t, mgr.RestoreSnapshot(ctx, SnapshotRestoreOptions{PuckName: "tree-puck", SnapshotName: "known-good"}))
+		p, err := mgr.Get(ctx, "tree-puck")
+		require.NoError(t, err)
+		assert.Equal(t, tagged.ID, p.SnapshotHead)
+
+		untagged, err := mgr.UntagSnapshot(ctx, "tree-puck", "base", "known-good")
+		require.NoError(t, err)
+		assert.Empty(t, untagged.Tags)
+		_, err = mgr.UntagSnapshot(ctx, "tree-puck", "base", "known-good")
+		assert.Error(t, err)
+	})
+
+	t.Run("delete keeps the tree connected", func(t *testing.T) {
+		mgr, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		base := snap(t, mgr, "base")
+		snap(t, mgr, "middle")
+		snap(t, mgr, "tip")
+
+		require.NoError(t, mgr.DeleteSnapshot(ctx, "tree-puck", "middle"))
+		snapshots, err := mgr.ListSnapshots(ctx, "tree-puck")
+		require.NoError(t, err)
+		roots := BuildSnapshotTree(snapshots)
+		require.Len(t, roots, 1)
+		require.Len(t, roots[0].Children, 1)
+		assert.Equal(t, "tip", roots[0].Children[0].Snapshot.Name)
+
+		require.NoError(t, mgr.DeleteSnapshot(ctx, "tree-puck", "tip"))
+		p, err := mgr.Get(ctx, "tree-puck")
+		require.NoError(t, err)
+		assert.Equal(t, base.ID, p.SnapshotHead)
+	})
+}
+
+func TestRestoreKeepsPreviousContainer(t *testing.T) {
+	setup := func(t *testing.T) (*Manager, *podman.MockClient, *store.Puck, func()) {
+		mgr, mock, cleanup := setupTestManager(t)
+
+		ctx := context.Background()
+		p, err := mgr.Create(ctx, CreateOptions{Name: "keep-puck"})
+		require.NoError(t, err)
+		_, err = mgr.CreateSnapshot(ctx, SnapshotCreateOptions{PuckName: "keep-puck", SnapshotName: "snap", LeaveRunning: leaveRunning})
+		require.NoError(t, err)
+		mock.Reset()
+		return mgr, mock, p, cleanup
+	}
+
+	t.Run("removes it once the restore works", func(t *testing.T) {
+		mgr, mock, p, cleanup := setup(t)
+		defer cleanup()
+
+		require.NoError(t, mgr.RestoreSnapshot(context.Background(), SnapshotRestoreOptions{PuckName: "keep-puck", SnapshotName: "snap"}))
+
+		var methods []string
+		for _, call := range mock.Calls {
+			if call.Method == "RenameContainer" || call.Method == "Restore" ||
+				(call.Method == "RemoveContainer" && call.Args[0] == p.ContainerID) {
+				methods = append(methods, call.Method)
+			}
+		}
+		assert.Equal(t, []string{"RenameContainer", "Restore", "RemoveContainer"}, methods)
+		assert.True(t, mock.WasCalledWith("RenameContainer", p.ContainerID, "keep-puck-pre-restore"))
+	})
+
+	t.Run("puts it back when the restore fails", func(t *testing.T) {
+		mgr, mock, p, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		mock.RestoreFunc = func(ctx context.Context, opts podman.RestoreOptions) (string, error) {
+			return "", errors.New("criu failed")
+		}
+		err := mgr.RestoreSnapshot(ctx, SnapshotRestoreOptions{PuckName: "keep-puck", SnapshotName: "snap"})
+		require.ErrorContains(t, err, "criu failed")
+
+		assert.True(t, mock.WasCalledWith("RenameContainer", p.ContainerID, "keep-puck"))
+		assert.True(t, mock.WasCalledWith("StartContainer", p.ContainerID))
+		assert.False(t, mock.WasCalledWith("RemoveContainer", p.ContainerID, true))
+
+		got, err := mgr.Get(ctx, "keep-puck")
+		require.NoError(t, err)
+		assert.Equal(t, p.ContainerID, got.ContainerID)
+	})
+}
+
+func TestImageSnapshots(t *testing.T) {
+	t.Run("commits the container and restores volumes", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		p, err := mgr.Create(ctx, CreateOptions{Name: "image-puck", Image: "nginx:1.25"})
+		require.NoError(t, err)
+		require.NoError(t, mgr.MarkReady(ctx, "image-puck"))
+		notes := filepath.Join(p.VolumeDir, "home", "notes.txt")
+		require.NoError(t, os.WriteFile(notes, []byte("before"), 0644))
+
+		mock.Reset()
+		snapshot, err := mgr.CreateSnapshot(ctx, SnapshotCreateOptions{PuckName: "image-puck", SnapshotName: "v1", Mode: store.SnapshotModeImage})
+		require.NoError(t, err)
+		assert.Equal(t, store.SnapshotModeImage, snapshot.Mode)
+		assert.Equal(t, "localhost/puck-snapshots:"+snapshot.ID, snapshot.CommitImage)
+		assert.FileExists(t, snapshot.Path)
+		assert.False(t, mock.WasCalled("Checkpoint"))
+
+		commit := mock.Calls[len(mock.Calls)-1]
+		require.Equal(t, "CommitContainer", commit.Method)
+		assert.Equal(t, podman.CommitOptions{Repo: "localhost/puck-snapshots", Tag: snapshot.ID, Pause: true}, commit.Args[1])
+
+		// Commits leave the puck running
+		got, err := mgr.Get(ctx, "image-puck")
+		require.NoError(t, err)
+		assert.Equal(t, store.StatusRunning, got.Status)
+
+		require.NoError(t, os.WriteFile(notes, []byte("after"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(p.VolumeDir, "home", "scratch.txt"), []byte("x"), 0644))
+
+		var createdFrom string
+		mock.CreateContainerFunc = func(ctx context.Context, opts podman.CreateContainerOptions) (string, error) {
+			createdFrom = opts.Image
+			return "committed-container-id", nil
+		}
+		require.NoError(t, mgr.RestoreSnapshot(ctx, SnapshotRestoreOptions{PuckName: "image-puck", SnapshotName: "v1"}))
+		assert.Equal(t, snapshot.CommitImage, createdFrom)
+		assert.False(t, mock.WasCalled("Restore"))
+
+		data, err := os.ReadFile(notes)
+		require.NoError(t, err)
+		assert.Equal(t, "before", string(data))
+		assert.NoFileExists(t, filepath.Join(p.VolumeDir, "home", "scratch.txt"))
+
+		got, err = mgr.Get(ctx, "image-puck")
+		require.NoError(t, err)
+		assert.Equal(t, "committed-container-id", got.ContainerID)
+		assert.Equal(t, "nginx:1.25", got.Image)
+		assert.Equal(t, store.StatusRunning, got.Status)
+	})
+
+	t.Run("works on stopped pucks", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "stopped-puck"})
+		require.NoError(t, err)
+		mock.IsRunningFunc = func(ctx context.Context, nameOrID string) (bool, error) {
+			return false, nil
+		}
+
+		mock.Reset()
+		_, err = mgr.CreateSnapshot(ctx, SnapshotCreateOptions{PuckName: "stopped-puck", SnapshotName: "cold", Mode: store.SnapshotModeImage})
+		require.NoError(t, err)
+
+		commit := mock.Calls[len(mock.Calls)-1]
+		assert.False(t, commit.Args[1].(podman.CommitOptions).Pause)
+	})
+
+	t.Run("uses the configured mode by default", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+		mgr.cfg.SnapshotMode = "image"
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "default-puck"})
+		require.NoError(t, err)
+
+		snapshot, err := mgr.CreateSnapshot(ctx, SnapshotCreateOptions{PuckName: "default-puck", SnapshotName: "s"})
+		require.NoError(t, err)
+		assert.Equal(t, store.SnapshotModeImage, snapshot.Mode)
+		assert.True(t, mock.WasCalled("CommitContainer"))
+	})
+
+	t.Run("deleting removes the committed image", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "delete-puck"})
+		require.NoError(t, err)
+		snapshot, err := mgr.CreateSnapshot(ctx, SnapshotCreateOptions{PuckName: "delete-puck", SnapshotName: "s", Mode: store.SnapshotModeImage})
+		require.NoError(t, err)
+
+		mock.Reset()
+		require.NoError(t, mgr.DeleteSnapshot(ctx, "delete-puck", "s"))
+		assert.NoFileExists(t, snapshot.Path)
+		require.True(t, mock.WasCalled("RemoveImage"))
+		assert.Equal(t, snapshot.CommitImage, mock.Calls[0].Args[0])
+	})
+
+	t.Run("rejects unknown modes", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "mode-puck"})
+		require.NoError(t, err)
+
+		_, err = mgr.CreateSnapshot(ctx, SnapshotCreateOptions{PuckName: "mode-puck", SnapshotName: "s", Mode: "zfs"})
+		assert.ErrorContains(t, err, "unknown snapshot mode")
+	})
+}
+
+func TestBuildSnapshotTree(t *testing.T) {
+	now := time.Now()
+	snapshots := []*store.Snapshot{
+		{ID: "c", Name: "c", ParentID: "a", CreatedAt: now.Add(2 * time.Minute)},
+		{ID: "a", Name: "a", CreatedAt: now},
+		{ID: "b", Name: "b", ParentID: "a", CreatedAt: now.Add(time.Minute)},
+		{ID: "orphan", Name: "orphan", ParentID: "deleted", CreatedAt: now.Add(3 * time.Minute)},
+	}
+
+	roots := BuildSnapshotTree(snapshots)
+	require.Len(t, roots, 2)
+	assert.Equal(t, "a", roots[0].Snapshot.Name)
+	assert.Equal(t, "orphan", roots[1].Snapshot.Name)
+	require.Len(t, roots[0].Children, 2)
+	assert.Equal(t, "b", roots[0].Children[0].Snapshot.Name)
+	assert.Equal(t, "c", roots[0].Children[1].Snapshot.Name)
+}
+
 func TestListSnapshots(t *testing.T) {
 	t.Run("returns snapshots for puck", func(t *testing.T) {
 		mgr, mock, cleanup := setupTestManager(t)
@@ -618,7 +2738,7 @@ func TestListSnapshots(t *testing.T) {
 		_, err = mgr.CreateSnapshot(ctx, SnapshotCreateOptions{
 			PuckName:     "list-snap-puck",
 			SnapshotName: "snap1",
-			LeaveRunning: true,
+			LeaveRunning: leaveRunning,
 		})
 		require.NoError(t, err)
 
@@ -653,7 +2773,7 @@ func TestDeleteSnapshot(t *testing.T) {
 		_, err = mgr.CreateSnapshot(ctx, SnapshotCreateOptions{
 			PuckName:     "delete-snap-puck",
 			SnapshotName: "to-delete",
-			LeaveRunning: true,
+			LeaveRunning: leaveRunning,
 		})
 		require.NoError(t, err)
 
@@ -674,3 +2794,31 @@ func TestDeleteSnapshot(t *testing.T) {
 		assert.Empty(t, snapshots)
 	})
 }
+
+func TestCreateLabels(t *testing.T) {
+	mgr, mock, cleanup := setupTestManager(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	mgr.cfg.Labels = map[string]string{"owner": "{{.User}}", "project": "{{.Cwd | base}}"}
+	var labels []map[string]string
+	mock.CreateContainerFunc = func(ctx context.Context, opts podman.CreateContainerOptions) (string, error) {
+		labels = append(labels, opts.Labels)
+		return "container-" + opts.Name, nil
+	}
+
+	p, err := mgr.Create(ctx, CreateOptions{Name: "web", Owner: "alice", Cwd: "/home/alice/shop"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"owner": "alice", "project": "shop"}, p.Spec.Labels)
+
+	mgr.cfg.Labels = nil
+	_, err = mgr.Recreate(ctx, RecreateOptions{Name: "web", NoSnapshot: true})
+	require.NoError(t, err)
+
+	require.Len(t, labels, 2)
+	for _, l := range labels {
+		assert.Equal(t, "alice", l["owner"])
+		assert.Equal(t, "shop", l["project"], "recreating keeps the labels rendered at create")
+		assert.Equal(t, p.ID, l["puck.id"])
+	}
+}
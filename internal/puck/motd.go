@@ -0,0 +1,97 @@
+package puck
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/dustin/go-humanize"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+//go:embed motd.tmpl
+var defaultMOTDTemplate string
+
+// motdFile is where the banner is kept in a puck's etc volume. It is
+// mounted at /etc/motd, and puck console prints it.
+const motdFile = "motd"
+
+// MOTD is the data passed to the login banner template
+type MOTD struct {
+	Name      string
+	Image     string
+	Project   string
+	URL       string // where the router serves the puck, or its host port
+	RemoteURL string // on the tailnet, if there is one
+	// The latest snapshot, or nil, and how long before the banner was
+	// written it was taken
+	Snapshot    *store.Snapshot
+	SnapshotAge string
+}
+
+// motdPath returns where a puck's banner is written on the host
+func motdPath(p *store.Puck) string {
+	return filepath.Join(p.VolumeDir, "etc", motdFile)
+}
+
+// writeMOTD renders the login banner into a puck's etc volume, or
+// removes it when banners are off
+func (m *Manager) writeMOTD(ctx context.Context, p *store.Puck) error {
+	path := motdPath(p)
+	if !m.cfg.MOTD {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	data := MOTD{Name: p.Name, Image: p.Image, Project: p.Project}
+	switch {
+	case m.cfg.RouterEnabled && p.Spec.Sandbox == "":
+		data.URL = fmt.Sprintf("http://%s:%d/%s", m.cfg.RouterDomain, m.cfg.RouterPort, p.Name)
+	case p.HostPort > 0:
+		data.URL = fmt.Sprintf("http://localhost:%d/", p.HostPort)
+	}
+	if m.cfg.Tailnet != "" && m.cfg.RouterEnabled {
+		data.RemoteURL = fmt.Sprintf("https://puck.%s/%s", m.cfg.Tailnet, p.Name)
+	}
+	if snapshots, err := m.store.ListSnapshots(ctx, p.ID); err == nil && len(snapshots) > 0 {
+		data.Snapshot = snapshots[0]
+		data.SnapshotAge = humanize.Time(snapshots[0].CreatedAt)
+	}
+
+	text, err := renderMOTD(m.cfg.MOTDTemplate, data)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, text, 0644)
+}
+
+// renderMOTD executes the template at path if there is one, else the
+// default
+func renderMOTD(path string, data MOTD) ([]byte, error) {
+	text := defaultMOTDTemplate
+	if path != "" {
+		custom, err := os.ReadFile(path)
+		switch {
+		case err == nil:
+			text = string(custom)
+		case !os.IsNotExist(err):
+			return nil, fmt.Errorf("reading motd template: %w", err)
+		}
+	}
+
+	tmpl, err := template.New("motd").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing motd template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("rendering motd template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,81 @@
+package puck
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMOTD(t *testing.T) {
+	mgr, mock, cleanup := setupTestManager(t)
+	defer cleanup()
+	ctx := context.Background()
+	mgr.cfg.MOTD = true
+	mgr.cfg.RouterEnabled = true
+	mgr.cfg.RouterDomain = "localhost"
+	mgr.cfg.RouterPort = 8080
+
+	var created []podman.CreateContainerOptions
+	mock.CreateContainerFunc = func(ctx context.Context, opts podman.CreateContainerOptions) (string, error) {
+		created = append(created, opts)
+		return "container-" + opts.Name, nil
+	}
+
+	p, err := mgr.Create(ctx, CreateOptions{Name: "web", Image: "fedora:42"})
+	require.NoError(t, err)
+
+	motd, err := os.ReadFile(motdPath(p))
+	require.NoError(t, err)
+	assert.Contains(t, string(motd), "puck 'web' (fedora:42)")
+	assert.Contains(t, string(motd), "http://localhost:8080/web")
+	assert.Contains(t, string(motd), "none yet")
+
+	require.Len(t, created, 1)
+	assert.Contains(t, created[0].Mounts, podman.Mount{Source: motdPath(p), Destination: "/etc/motd", ReadOnly: true})
+
+	t.Run("shows the latest snapshot after a start", func(t *testing.T) {
+		require.NoError(t, mgr.store.CreateSnapshot(ctx, &store.Snapshot{
+			ID: "s1", PuckID: p.ID, PuckName: "web", Name: "nightly",
+			CreatedAt: time.Now().Add(-3 * time.Hour), Mode: store.SnapshotModeImage,
+		}))
+		require.NoError(t, mgr.Start(ctx, "web"))
+
+		motd, err := os.ReadFile(motdPath(p))
+		require.NoError(t, err)
+		assert.Contains(t, string(motd), "nightly, taken 3 hours ago")
+	})
+
+	t.Run("uses a custom template", func(t *testing.T) {
+		mgr.cfg.MOTDTemplate = filepath.Join(t.TempDir(), "motd.tmpl")
+		require.NoError(t, os.WriteFile(mgr.cfg.MOTDTemplate, []byte("{{.Name}} at {{.URL}}\n"), 0644))
+		require.NoError(t, mgr.Start(ctx, "web"))
+
+		motd, err := os.ReadFile(motdPath(p))
+		require.NoError(t, err)
+		assert.Equal(t, "web at http://localhost:8080/web\n", string(motd))
+	})
+
+	t.Run("removes the banner when turned off", func(t *testing.T) {
+		mgr.cfg.MOTD = false
+		require.NoError(t, mgr.Start(ctx, "web"))
+		assert.NoFileExists(t, motdPath(p))
+	})
+}
+
+func TestRenderMOTDErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "motd.tmpl")
+	require.NoError(t, os.WriteFile(path, []byte("{{.Name"), 0644))
+	_, err := renderMOTD(path, MOTD{Name: "web"})
+	assert.ErrorContains(t, err, "parsing motd template")
+
+	text, err := renderMOTD(filepath.Join(t.TempDir(), "missing.tmpl"), MOTD{Name: "web", Image: "alpine"})
+	require.NoError(t, err)
+	assert.Contains(t, string(text), "puck 'web' (alpine)")
+}
@@ -0,0 +1,94 @@
+package puck
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// validateMounts checks the host directories a new puck mounts exist on
+// the daemon's host, which need not be the machine puck create ran on.
+// With create, missing ones are made, owned by owner when the daemon runs
+// as root.
+func validateMounts(mounts []store.Mount, create bool, owner string) error {
+	targets := make(map[string]bool, len(mounts))
+	for _, mnt := range mounts {
+		if !filepath.IsAbs(mnt.Source) || !path.IsAbs(mnt.Target) {
+			return fmt.Errorf("invalid mount %s:%s; both ends must be absolute paths", mnt.Source, mnt.Target)
+		}
+		target := path.Clean(mnt.Target)
+		if target == "/" {
+			return fmt.Errorf("invalid mount %s:%s; a puck's root can't be mounted over", mnt.Source, mnt.Target)
+		}
+		if targets[target] {
+			return fmt.Errorf("more than one mount at %s", target)
+		}
+		targets[target] = true
+
+		info, err := os.Stat(mnt.Source)
+		switch {
+		case errors.Is(err, os.ErrNotExist) && create:
+			if err := makeMountDir(mnt.Source, owner); err != nil {
+				return err
+			}
+		case errors.Is(err, os.ErrNotExist):
+			return fmt.Errorf("mount source %s does not exist on the daemon's host; create it, or pass --create-host-dirs", mnt.Source)
+		case err != nil:
+			return fmt.Errorf("mount source %s: %w", mnt.Source, err)
+		case !info.IsDir():
+			return fmt.Errorf("mount source %s is not a directory on the daemon's host", mnt.Source)
+		}
+	}
+	return nil
+}
+
+// makeMountDir creates a missing mount source and its missing parents,
+// handing them to owner when the daemon runs as root so the directory is
+// theirs, as one they made themselves would be
+func makeMountDir(dir, owner string) error {
+	var missing []string
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); err == nil || d == filepath.Dir(d) {
+			break
+		}
+		missing = append(missing, d)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating mount source: %w", err)
+	}
+
+	if os.Geteuid() != 0 || owner == "" {
+		return nil
+	}
+	u, err := user.Lookup(owner)
+	if err != nil {
+		return fmt.Errorf("looking up %s to own %s: %w", owner, dir, err)
+	}
+	uid, _ := strconv.Atoi(u.Uid)
+	gid, _ := strconv.Atoi(u.Gid)
+	for _, d := range missing {
+		if err := os.Chown(d, uid, gid); err != nil {
+			return fmt.Errorf("handing %s to %s: %w", d, owner, err)
+		}
+	}
+	return nil
+}
+
+// trustedOwner reports whether a puck's owner may have the daemon's shared
+// paths, which other users on a shared daemon could not reach themselves:
+// the daemon's own user, root and admins, and pucks the daemon makes for
+// no one
+func (m *Manager) trustedOwner(owner string) bool {
+	if owner == "" || owner == "root" || slices.Contains(m.cfg.Admins, owner) {
+		return true
+	}
+	u, err := user.Current()
+	return err == nil && u.Username == owner
+}
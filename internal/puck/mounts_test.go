@@ -0,0 +1,66 @@
+package puck
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateMounts(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	require.NoError(t, os.WriteFile(file, nil, 0644))
+
+	t.Run("accepts existing directories", func(t *testing.T) {
+		assert.NoError(t, validateMounts([]store.Mount{{Source: dir, Target: "/workspace"}}, false, ""))
+	})
+
+	t.Run("rejects bad paths", func(t *testing.T) {
+		err := validateMounts([]store.Mount{{Source: "src", Target: "/workspace"}}, false, "")
+		assert.ErrorContains(t, err, "both ends must be absolute paths")
+		err = validateMounts([]store.Mount{{Source: dir, Target: "workspace"}}, false, "")
+		assert.ErrorContains(t, err, "both ends must be absolute paths")
+		err = validateMounts([]store.Mount{{Source: dir, Target: "/"}}, false, "")
+		assert.ErrorContains(t, err, "root can't be mounted over")
+		err = validateMounts([]store.Mount{{Source: dir, Target: "/a"}, {Source: dir, Target: "/a/"}}, false, "")
+		assert.ErrorContains(t, err, "more than one mount at /a")
+	})
+
+	t.Run("rejects files", func(t *testing.T) {
+		err := validateMounts([]store.Mount{{Source: file, Target: "/workspace"}}, false, "")
+		assert.ErrorContains(t, err, "is not a directory")
+	})
+
+	t.Run("rejects missing directories", func(t *testing.T) {
+		missing := filepath.Join(dir, "missing")
+		err := validateMounts([]store.Mount{{Source: missing, Target: "/workspace"}}, false, "")
+		assert.ErrorContains(t, err, "does not exist on the daemon's host; create it, or pass --create-host-dirs")
+		_, err = os.Stat(missing)
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("creates missing directories when asked", func(t *testing.T) {
+		missing := filepath.Join(dir, "a", "b")
+		require.NoError(t, validateMounts([]store.Mount{{Source: missing, Target: "/workspace"}}, true, ""))
+		info, err := os.Stat(missing)
+		require.NoError(t, err)
+		assert.True(t, info.IsDir())
+	})
+
+	t.Run("checks on create", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+
+		_, err := mgr.Create(context.Background(), CreateOptions{Name: "web", Mounts: []store.Mount{{Source: filepath.Join(dir, "gone"), Target: "/workspace"}}})
+		assert.ErrorContains(t, err, "does not exist")
+
+		p, err := mgr.Create(context.Background(), CreateOptions{Name: "web", Mounts: []store.Mount{{Source: filepath.Join(dir, "gone"), Target: "/workspace"}}, CreateMountDirs: true})
+		require.NoError(t, err)
+		assert.Equal(t, []store.Mount{{Source: filepath.Join(dir, "gone"), Target: "/workspace"}}, p.Spec.Mounts)
+	})
+}
@@ -0,0 +1,79 @@
+package puck
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"regexp"
+	"strings"
+)
+
+// DefaultNamePattern is how generated puck names look unless name_pattern
+// says otherwise
+const DefaultNamePattern = "%adjective%-%noun%"
+
+var (
+	nameAdjectives = []string{
+		"amber", "bold", "brave", "brisk", "calm", "clever", "cozy", "crisp", "dapper", "eager",
+		"fair", "fancy", "fleet", "gentle", "glad", "golden", "grand", "happy", "hardy", "jolly",
+		"keen", "kind", "lively", "lucky", "merry", "mighty", "misty", "neat", "nimble", "noble",
+		"plucky", "polite", "proud", "quick", "quiet", "rapid", "rosy", "rustic", "shiny", "silent",
+		"sleek", "snowy", "sunny", "swift", "tidy", "trusty", "vivid", "warm", "wise", "zesty",
+	}
+	nameNouns = []string{
+		"ant", "badger", "bat", "bee", "bison", "cat", "crane", "crow", "deer", "dog",
+		"eel", "elk", "falcon", "ferret", "finch", "fox", "gecko", "goat", "hare", "hawk",
+		"heron", "ibis", "jay", "koala", "lark", "lemur", "lynx", "marten", "mole", "moose",
+		"newt", "otter", "owl", "panda", "puffin", "quail", "raven", "robin", "seal", "shrew",
+		"sloth", "stoat", "swan", "tapir", "toad", "trout", "vole", "walrus", "wolf", "yak",
+	}
+
+	// validName is what podman accepts as a container name, which a puck's
+	// name becomes part of
+	validName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+)
+
+// nameAttempts is how many names GenerateName tries from the pattern, and
+// then again with a number on the end, before giving up
+const nameAttempts = 10
+
+// GenerateName makes a name for a new puck from pattern, replacing each
+// %adjective%, %noun% and %number% (four digits) with a random pick; an
+// empty pattern is DefaultNamePattern. Names taken reports as in use are
+// passed over, and once the pattern has been tried a while, or at once
+// if it has nothing random in it, a number is added to the end.
+func GenerateName(pattern string, taken func(name string) (bool, error)) (string, error) {
+	if pattern == "" {
+		pattern = DefaultNamePattern
+	}
+	random := strings.Contains(pattern, "%adjective%") ||
+		strings.Contains(pattern, "%noun%") ||
+		strings.Contains(pattern, "%number%")
+
+	for i := range 2 * nameAttempts {
+		p := pattern
+		if i >= nameAttempts || (i > 0 && !random) {
+			p += "-%number%"
+		}
+		name := expandNamePattern(p)
+		if !validName.MatchString(name) {
+			return "", fmt.Errorf("name_pattern %q makes names like %q, which aren't valid puck names", pattern, name)
+		}
+		inUse, err := taken(name)
+		if err != nil {
+			return "", err
+		}
+		if !inUse {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("no free name found from name_pattern %q; give the puck a name", pattern)
+}
+
+// expandNamePattern fills in a pattern's placeholders
+func expandNamePattern(pattern string) string {
+	return strings.NewReplacer(
+		"%adjective%", nameAdjectives[rand.IntN(len(nameAdjectives))],
+		"%noun%", nameNouns[rand.IntN(len(nameNouns))],
+		"%number%", fmt.Sprintf("%04d", rand.IntN(10000)),
+	).Replace(pattern)
+}
@@ -0,0 +1,58 @@
+package puck
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateName(t *testing.T) {
+	free := func(string) (bool, error) { return false, nil }
+
+	t.Run("follows the default pattern", func(t *testing.T) {
+		name, err := GenerateName("", free)
+		require.NoError(t, err)
+		assert.Regexp(t, `^[a-z]+-[a-z]+$`, name)
+	})
+
+	t.Run("fills in a custom pattern", func(t *testing.T) {
+		name, err := GenerateName("proj-%adjective%-%noun%-%number%", free)
+		require.NoError(t, err)
+		assert.Regexp(t, `^proj-[a-z]+-[a-z]+-[0-9]{4}$`, name)
+	})
+
+	t.Run("passes over names in use", func(t *testing.T) {
+		var tried []string
+		name, err := GenerateName("", func(name string) (bool, error) {
+			tried = append(tried, name)
+			return len(tried) < 3, nil
+		})
+		require.NoError(t, err)
+		assert.Len(t, tried, 3)
+		assert.Equal(t, tried[2], name)
+	})
+
+	t.Run("numbers a fixed pattern once it is taken", func(t *testing.T) {
+		name, err := GenerateName("dev", func(name string) (bool, error) { return name == "dev", nil })
+		require.NoError(t, err)
+		assert.Regexp(t, regexp.MustCompile(`^dev-[0-9]{4}$`), name)
+	})
+
+	t.Run("gives up when every name is taken", func(t *testing.T) {
+		_, err := GenerateName("", func(string) (bool, error) { return true, nil })
+		assert.ErrorContains(t, err, "no free name")
+	})
+
+	t.Run("refuses patterns that make invalid names", func(t *testing.T) {
+		_, err := GenerateName("my puck %noun%", free)
+		assert.ErrorContains(t, err, "aren't valid puck names")
+	})
+
+	t.Run("reports lookup failures", func(t *testing.T) {
+		_, err := GenerateName("", func(string) (bool, error) { return false, errors.New("daemon gone") })
+		assert.ErrorContains(t, err, "daemon gone")
+	})
+}
@@ -0,0 +1,72 @@
+package puck
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// PoolNamePlaceholder stands in for a pooled puck's name in a pool's
+// options, as {{PUCK_NAME}} does in a template, until the daemon names it
+const PoolNamePlaceholder = "{{PUCK_NAME}}"
+
+// MaxPoolSize bounds how many pucks a pool keeps waiting
+const MaxPoolSize = 20
+
+// SavePool creates one of owner's pools, or changes its size and options.
+// The options' name is PoolNamePlaceholder, or empty.
+func (m *Manager) SavePool(ctx context.Context, p *store.Pool) error {
+	if !groupNamePattern.MatchString(p.Name) {
+		return fmt.Errorf("invalid pool name %q", p.Name)
+	}
+	if p.Size < 1 || p.Size > MaxPoolSize {
+		return fmt.Errorf("pool size must be between 1 and %d", MaxPoolSize)
+	}
+	opts, err := PoolOptions(p, "")
+	if err != nil {
+		return err
+	}
+	if opts.Replace || opts.FromPool != "" || opts.FromCheckpoint != "" || opts.HostPort != 0 || opts.TTL != 0 {
+		return fmt.Errorf("pooled pucks can't be made with --replace, --from-pool, --from-checkpoint, --host-port or --ttl")
+	}
+	return m.store.SavePool(ctx, p)
+}
+
+// Pool returns one of owner's pools
+func (m *Manager) Pool(ctx context.Context, owner, name string) (*store.Pool, error) {
+	return m.store.GetPool(ctx, owner, name)
+}
+
+// ListPools returns owner's pools, or every user's when owner is empty
+func (m *Manager) ListPools(ctx context.Context, owner string) ([]*store.Pool, error) {
+	return m.store.ListPools(ctx, owner)
+}
+
+// RemovePool forgets one of owner's pools. The pucks waiting in it are
+// left for the caller to destroy.
+func (m *Manager) RemovePool(ctx context.Context, owner, name string) error {
+	return m.store.DeletePool(ctx, owner, name)
+}
+
+// ClaimPooled takes a waiting puck out of one of owner's pools, to be
+// started as the caller's own; store.ErrNotFound means none is waiting
+func (m *Manager) ClaimPooled(ctx context.Context, owner, pool string) (*store.Puck, error) {
+	return m.store.ClaimPooledPuck(ctx, owner, pool)
+}
+
+// PoolOptions returns the options a puck named name is made with for a
+// pool, its name put in place of PoolNamePlaceholder throughout
+func PoolOptions(p *store.Pool, name string) (CreateOptions, error) {
+	var opts CreateOptions
+	data := strings.ReplaceAll(string(p.Options), PoolNamePlaceholder, name)
+	if err := json.Unmarshal([]byte(data), &opts); err != nil {
+		return opts, fmt.Errorf("reading pool '%s' options: %w", p.Name, err)
+	}
+	opts.Name = name
+	opts.Owner = p.Owner
+	opts.Pool = p.Name
+	return opts, nil
+}
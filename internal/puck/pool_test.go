@@ -0,0 +1,76 @@
+package puck
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolOptions(t *testing.T) {
+	data, err := json.Marshal(CreateOptions{
+		Name:      PoolNamePlaceholder,
+		Image:     "node:22",
+		Repo:      &RepoClone{URL: "https://example.com/" + PoolNamePlaceholder},
+		Provision: []ProvisionScript{{Name: "setup.sh", Script: "echo " + PoolNamePlaceholder}},
+	})
+	require.NoError(t, err)
+
+	opts, err := PoolOptions(&store.Pool{Owner: "alice", Name: "node", Options: data}, "brave-otter")
+	require.NoError(t, err)
+	assert.Equal(t, "brave-otter", opts.Name)
+	assert.Equal(t, "alice", opts.Owner)
+	assert.Equal(t, "node", opts.Pool)
+	assert.Equal(t, "https://example.com/brave-otter", opts.Repo.URL)
+	assert.Equal(t, "echo brave-otter", opts.Provision[0].Script)
+}
+
+func TestSavePool(t *testing.T) {
+	mgr, _, cleanup := setupTestManager(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	options := json.RawMessage(`{"name":"{{PUCK_NAME}}","image":"node:22"}`)
+	require.NoError(t, mgr.SavePool(ctx, &store.Pool{Owner: "alice", Name: "node", Size: 2, Options: options}))
+
+	p, err := mgr.Pool(ctx, "alice", "node")
+	require.NoError(t, err)
+	assert.Equal(t, 2, p.Size)
+
+	assert.ErrorContains(t, mgr.SavePool(ctx, &store.Pool{Owner: "alice", Name: "@node", Size: 2, Options: options}), "invalid pool name")
+	assert.ErrorContains(t, mgr.SavePool(ctx, &store.Pool{Owner: "alice", Name: "node", Size: 0, Options: options}), "pool size")
+	assert.ErrorContains(t, mgr.SavePool(ctx, &store.Pool{Owner: "alice", Name: "node", Size: MaxPoolSize + 1, Options: options}), "pool size")
+	assert.ErrorContains(t, mgr.SavePool(ctx, &store.Pool{Owner: "alice", Name: "node", Size: 1, Options: json.RawMessage(`{"host_port":9000}`)}), "--host-port")
+	assert.ErrorContains(t, mgr.SavePool(ctx, &store.Pool{Owner: "alice", Name: "node", Size: 1, Options: json.RawMessage(`{"ttl":3600000000000}`)}), "--ttl")
+
+	t.Run("pooled pucks are claimed once", func(t *testing.T) {
+		opts, err := PoolOptions(p, "pooled")
+		require.NoError(t, err)
+		_, err = mgr.Create(ctx, opts)
+		require.NoError(t, err)
+
+		created, err := mgr.Get(ctx, "pooled")
+		require.NoError(t, err)
+		assert.Equal(t, "node", created.Pool)
+
+		// Not waiting until suspended
+		_, err = mgr.ClaimPooled(ctx, "alice", "node")
+		assert.ErrorIs(t, err, store.ErrNotFound)
+
+		require.NoError(t, mgr.store.UpdatePuckStatus(ctx, "pooled", store.StatusSuspended))
+		claimed, err := mgr.ClaimPooled(ctx, "alice", "node")
+		require.NoError(t, err)
+		assert.Equal(t, "pooled", claimed.Name)
+		assert.Empty(t, claimed.Pool)
+
+		_, err = mgr.ClaimPooled(ctx, "alice", "node")
+		assert.ErrorIs(t, err, store.ErrNotFound)
+	})
+
+	require.NoError(t, mgr.RemovePool(ctx, "alice", "node"))
+	_, err = mgr.Pool(ctx, "alice", "node")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+}
@@ -0,0 +1,485 @@
+package puck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"net"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// PortChange records a puck moved to a new host port because its old one
+// was taken
+type PortChange struct {
+	Puck string `json:"puck"`
+	From int    `json:"from"`
+	To   int    `json:"to"`
+}
+
+// PortAllocator orders the host ports a new puck may be given. The
+// manager takes the first one that no puck holds or has reserved and that
+// is free on the host, so a strategy only decides which ports are
+// preferred.
+type PortAllocator interface {
+	// Candidates returns the ports from first to first+count-1 in the
+	// order they should be tried for the named puck
+	Candidates(puckName string, first, count int) []int
+}
+
+// NewPortAllocator returns the allocator for a port_allocator strategy,
+// sequential unless another is named
+func NewPortAllocator(strategy string) PortAllocator {
+	switch strategy {
+	case config.PortRandom:
+		return randomPorts{}
+	case config.PortHash:
+		return hashedPorts{}
+	default:
+		return sequentialPorts{}
+	}
+}
+
+// sequentialPorts gives out the lowest free port
+type sequentialPorts struct{}
+
+func (sequentialPorts) Candidates(puckName string, first, count int) []int {
+	ports := make([]int, count)
+	for i := range ports {
+		ports[i] = first + i
+	}
+	return ports
+}
+
+// randomPorts gives out any free port, so a port isn't handed to a new
+// puck straight after another gave it up
+type randomPorts struct{}
+
+func (randomPorts) Candidates(puckName string, first, count int) []int {
+	ports := rand.Perm(count)
+	for i := range ports {
+		ports[i] += first
+	}
+	return ports
+}
+
+// hashedPorts derives a port from the puck's name, so a puck destroyed
+// and created again gets the same port. Collisions move on to the next
+// port up, wrapping around.
+type hashedPorts struct{}
+
+func (hashedPorts) Candidates(puckName string, first, count int) []int {
+	h := fnv.New32a()
+	h.Write([]byte(puckName))
+	start := int(h.Sum32() % uint32(count))
+
+	ports := make([]int, count)
+	for i := range ports {
+		ports[i] = first + (start+i)%count
+	}
+	return ports
+}
+
+// allocatePort reserves a host port for a new or moving puck, trying the
+// ports its allocator prefers in turn. given are ports the puck already
+// has that aren't recorded yet, such as a new puck's own port.
+func (m *Manager) allocatePort(ctx context.Context, name string, given ...int) (int, error) {
+	pucks, err := m.store.ListPucks(ctx)
+	if err != nil {
+		return 0, err
+	}
+	reservations, err := m.store.ListPortReservations(ctx)
+	if err != nil {
+		return 0, err
+	}
+	endpoints, err := m.store.ListEndpoints(ctx, "")
+	if err != nil {
+		return 0, err
+	}
+
+	// A reservation the puck left behind, e.g. by a create that failed,
+	// is its to take again
+	held := make(map[int]bool)
+	for _, p := range pucks {
+		if p.HostPort > 0 {
+			held[p.HostPort] = true
+		}
+	}
+	for _, e := range endpoints {
+		if e.HostPort > 0 {
+			held[e.HostPort] = true
+		}
+	}
+	for _, r := range reservations {
+		if r.PuckName != name {
+			held[r.Port] = true
+		}
+	}
+	for _, port := range given {
+		held[port] = true
+	}
+
+	for _, port := range m.ports.Candidates(name, BaseHostPort, HostPortCount) {
+		if held[port] || !m.portFree(port) {
+			continue
+		}
+		// Another daemon sharing the database may have reserved it since
+		err := m.store.ReservePort(ctx, port, name)
+		if errors.Is(err, store.ErrExists) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		return port, nil
+	}
+
+	return 0, fmt.Errorf("%w in range %d-%d", ErrPortsExhausted, BaseHostPort, BaseHostPort+HostPortCount-1)
+}
+
+// pinPort reserves the host port a user asked for, refusing one another
+// puck holds, has reserved or publishes, or another process listens on
+func (m *Manager) pinPort(ctx context.Context, name string, port int) error {
+	if m.cfg.RoutesToContainerIP() {
+		return fmt.Errorf("host ports aren't published when the router reaches pucks by container IP")
+	}
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("host port must be between 1 and 65535, got %d", port)
+	}
+
+	pucks, err := m.store.ListPucks(ctx)
+	if err != nil {
+		return err
+	}
+	own := false
+	for _, p := range pucks {
+		if p.Name == name {
+			own = p.HostPort == port
+			continue
+		}
+		if p.HostPort == port {
+			return fmt.Errorf("host port %d is taken by puck '%s'", port, p.Name)
+		}
+		if publishes(p.Ports, port, "tcp") {
+			return fmt.Errorf("host port %d is published by puck '%s'", port, p.Name)
+		}
+	}
+	// The puck's own container may be the one listening on it
+	if !own && !m.portFree(port) {
+		return fmt.Errorf("host port %d is in use by another process", port)
+	}
+
+	err = m.store.ReservePort(ctx, port, name)
+	if errors.Is(err, store.ErrExists) {
+		return fmt.Errorf("host port %d is reserved by another puck", port)
+	}
+	return err
+}
+
+// SetHostPort pins a puck to a host port, which it keeps rather than
+// being moved off it when the port is taken. A port of 0 unpins the puck,
+// leaving it on its current port. A running puck can only pin the port it
+// is already on.
+func (m *Manager) SetHostPort(ctx context.Context, name string, port int) (*store.Puck, error) {
+	p, err := m.store.GetPuck(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if sandboxed(p) {
+		return nil, fmt.Errorf("sandboxed pucks have no host port")
+	}
+
+	if port == 0 {
+		if err := m.store.UpdatePuckPinnedPort(ctx, name, p.HostPort, false); err != nil {
+			return nil, err
+		}
+		return m.store.GetPuck(ctx, name)
+	}
+
+	if p.Status.Up() && port != p.HostPort {
+		return nil, fmt.Errorf("puck '%s' is running on host port %d; stop it to change its host port", name, p.HostPort)
+	}
+	if err := m.pinPort(ctx, name, port); err != nil {
+		return nil, err
+	}
+	if err := m.store.UpdatePuckPinnedPort(ctx, name, port, true); err != nil {
+		m.store.ReleasePort(ctx, port, name)
+		return nil, err
+	}
+	if port == p.HostPort {
+		return m.store.GetPuck(ctx, name)
+	}
+
+	m.store.ReleasePort(ctx, p.HostPort, name)
+	m.record(ctx, name, store.EventPortChanged, fmt.Sprintf("%d -> %d (pinned)", p.HostPort, port))
+	p.HostPort = port
+
+	// The stopped container still maps the old port
+	if p.Status == store.StatusStopped {
+		if err := m.replaceContainer(ctx, p); err != nil {
+			return nil, err
+		}
+	}
+	return m.store.GetPuck(ctx, name)
+}
+
+// repointReservation moves a puck's reservation to the host port it
+// ended up on. It is best effort: allocation also avoids the ports in
+// pucks' records, so a stale reservation only holds a port back.
+func (m *Manager) repointReservation(ctx context.Context, name string, from, to int) {
+	if from == to {
+		return
+	}
+	m.store.ReservePort(ctx, to, name)
+	m.store.ReleasePort(ctx, from, name)
+}
+
+// validatePorts checks a puck's port mappings
+func validatePorts(specs []string) error {
+	for _, spec := range specs {
+		if _, err := podman.ParsePortMapping(spec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// publishes reports whether port mappings publish a host port for a
+// protocol, alone or within a range
+func publishes(specs []string, port int, protocol string) bool {
+	for _, spec := range specs {
+		pm, err := podman.ParsePortMapping(spec)
+		if err != nil || pm.HostPort == 0 || !slices.Contains(strings.Split(pm.Protocol, ","), protocol) {
+			continue
+		}
+		if port >= int(pm.HostPort) && port < int(pm.HostPort)+int(max(pm.Range, 1)) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostPortFree reports whether a port can be bound on the host
+func hostPortFree(port int) bool {
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return false
+	}
+	ln.Close()
+	return true
+}
+
+// portMappings returns a puck's port mappings, including the
+// auto-assigned host ports the router reaches it and its endpoints on
+// unless the router goes straight to container IPs
+func (m *Manager) portMappings(ctx context.Context, p *store.Puck) ([]string, error) {
+	if sandboxed(p) {
+		return nil, nil
+	}
+	mappings := append([]string{}, p.Ports...)
+	if m.cfg.RoutesToContainerIP() {
+		return mappings, nil
+	}
+	mappings = append(mappings, fmt.Sprintf("%d:80", p.HostPort))
+
+	endpoints, err := m.store.ListEndpoints(ctx, p.Name)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range endpoints {
+		if e.HostPort > 0 {
+			mappings = append(mappings, fmt.Sprintf("%d:%d", e.HostPort, e.ContainerPort))
+		}
+	}
+	return mappings, nil
+}
+
+// ReconcilePorts moves pucks that aren't running off host ports that
+// another puck claims or another process is listening on, so they can
+// start again. Running pucks and pucks pinned to a port keep their ports.
+func (m *Manager) ReconcilePorts(ctx context.Context) ([]PortChange, error) {
+	// Nothing is published when routing to container IPs
+	if m.cfg.RoutesToContainerIP() {
+		return nil, nil
+	}
+
+	pucks, err := m.store.ListPucks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Running pucks hold their ports, then pinned ones; the rest keep
+	// theirs oldest first
+	sort.SliceStable(pucks, func(i, j int) bool {
+		iRunning, jRunning := pucks[i].Status.Up(), pucks[j].Status.Up()
+		if iRunning != jRunning {
+			return iRunning
+		}
+		if pucks[i].HostPortPinned != pucks[j].HostPortPinned {
+			return pucks[i].HostPortPinned
+		}
+		return pucks[i].CreatedAt.Before(pucks[j].CreatedAt)
+	})
+
+	var changes []PortChange
+	claimed := make(map[int]bool)
+	for _, p := range pucks {
+		if p.HostPort == 0 {
+			continue
+		}
+		if !p.Status.Up() && !p.HostPortPinned && (claimed[p.HostPort] || !m.portFree(p.HostPort)) {
+			change, err := m.moveHostPort(ctx, p)
+			if err != nil {
+				return changes, fmt.Errorf("reassigning port for '%s': %w", p.Name, err)
+			}
+			changes = append(changes, *change)
+
+			// The stopped container still maps the old port
+			if p.Status == store.StatusStopped {
+				if err := m.replaceContainer(ctx, p); err != nil {
+					return changes, err
+				}
+			}
+		}
+		claimed[p.HostPort] = true
+	}
+	return changes, nil
+}
+
+// claimHostPort moves a puck that isn't running to a new host port if
+// another puck claims its port or another process is listening on it,
+// returning the change or nil if the port was free. A puck pinned to its
+// port isn't moved; it can't start until the port is free.
+func (m *Manager) claimHostPort(ctx context.Context, p *store.Puck) (*PortChange, error) {
+	if p.HostPort == 0 || m.cfg.RoutesToContainerIP() {
+		return nil, nil
+	}
+
+	pucks, err := m.store.ListPucks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	taken := !m.portFree(p.HostPort)
+	for _, other := range pucks {
+		if other.Name != p.Name && other.HostPort == p.HostPort {
+			taken = true
+		}
+	}
+	if !taken {
+		return nil, nil
+	}
+	if p.HostPortPinned {
+		return nil, fmt.Errorf("host port %d that puck '%s' is pinned to is taken; free it or pin another with 'puck set %s --host-port'", p.HostPort, p.Name, p.Name)
+	}
+	return m.moveHostPort(ctx, p)
+}
+
+// moveHostPort assigns p a new host port and records the change
+func (m *Manager) moveHostPort(ctx context.Context, p *store.Puck) (*PortChange, error) {
+	port, err := m.allocatePort(ctx, p.Name)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.store.UpdatePuckHostPort(ctx, p.Name, port); err != nil {
+		m.store.ReleasePort(ctx, port, p.Name)
+		return nil, err
+	}
+	m.store.ReleasePort(ctx, p.HostPort, p.Name)
+
+	change := &PortChange{Puck: p.Name, From: p.HostPort, To: port}
+	p.HostPort = port
+	m.record(ctx, p.Name, store.EventPortChanged, fmt.Sprintf("%d -> %d", change.From, change.To))
+	return change, nil
+}
+
+// syncPublished reads back the host ports podman gave the image's exposed
+// ports of a puck that publishes them all, which change each time its
+// container starts, leaving out the ports the puck publishes itself
+func (m *Manager) syncPublished(ctx context.Context, p *store.Puck, containerID string) {
+	if !p.Spec.PublishAll {
+		return
+	}
+	data, err := m.podman.InspectContainer(ctx, containerID)
+	if err != nil || data.NetworkSettings == nil {
+		return
+	}
+	endpoints, err := m.store.ListEndpoints(ctx, p.Name)
+	if err != nil {
+		return
+	}
+	own := slices.Clone(p.Ports)
+	if p.HostPort > 0 {
+		own = append(own, fmt.Sprintf("%d:80", p.HostPort))
+	}
+	for _, e := range endpoints {
+		if e.HostPort > 0 {
+			own = append(own, fmt.Sprintf("%d:%d", e.HostPort, e.ContainerPort))
+		}
+	}
+
+	published := []string{}
+	for exposed, bindings := range data.NetworkSettings.Ports {
+		containerPort, protocol, _ := strings.Cut(exposed, "/")
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		for _, binding := range bindings {
+			port, err := strconv.Atoi(binding.HostPort)
+			if err != nil || port == 0 || publishes(own, port, protocol) {
+				continue
+			}
+			published = append(published, fmt.Sprintf("%d:%s/%s", port, containerPort, protocol))
+		}
+	}
+	slices.Sort(published)
+
+	p.Published = published
+	m.store.UpdatePuckPublished(ctx, p.Name, published)
+}
+
+// syncHostPort reads back the host port podman published for a started
+// container's port 80 and records it if it isn't the one the puck has,
+// so the router follows the container rather than a stale port
+func (m *Manager) syncHostPort(ctx context.Context, p *store.Puck, containerID string) error {
+	if p.HostPort == 0 || m.cfg.RoutesToContainerIP() {
+		return nil
+	}
+	data, err := m.podman.InspectContainer(ctx, containerID)
+	if err != nil || data.NetworkSettings == nil {
+		return err
+	}
+
+	published := 0
+	for _, binding := range data.NetworkSettings.Ports["80/tcp"] {
+		port, err := strconv.Atoi(binding.HostPort)
+		if err != nil || port == 0 {
+			continue
+		}
+		if port == p.HostPort {
+			return nil
+		}
+		// Ports the puck publishes itself aren't the router's
+		if !slices.Contains(p.Ports, fmt.Sprintf("%d:80", port)) {
+			published = port
+		}
+	}
+	if published == 0 {
+		return nil
+	}
+
+	if err := m.store.UpdatePuckHostPort(ctx, p.Name, published); err != nil {
+		return err
+	}
+	m.record(ctx, p.Name, store.EventPortChanged, fmt.Sprintf("%d -> %d (published by podman)", p.HostPort, published))
+	m.repointReservation(ctx, p.Name, p.HostPort, published)
+	p.HostPort = published
+	return nil
+}
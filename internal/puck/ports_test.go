@@ -0,0 +1,397 @@
+package puck
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/containers/podman/v5/libpod/define"
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// busyPorts makes the manager treat the given host ports as in use
+func busyPorts(mgr *Manager, ports ...int) {
+	mgr.portFree = func(port int) bool {
+		for _, p := range ports {
+			if p == port {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func TestReconcilePorts(t *testing.T) {
+	t.Run("moves stopped pucks off ports in use", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+		busyPorts(mgr)
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "web"})
+		require.NoError(t, err)
+		require.NoError(t, mgr.Stop(ctx, "web"))
+
+		var created []podman.CreateContainerOptions
+		mock.CreateContainerFunc = func(ctx context.Context, opts podman.CreateContainerOptions) (string, error) {
+			created = append(created, opts)
+			return "container-replaced", nil
+		}
+
+		// Something else took the port while the daemon was down
+		busyPorts(mgr, BaseHostPort)
+		changes, err := mgr.ReconcilePorts(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []PortChange{{Puck: "web", From: BaseHostPort, To: BaseHostPort + 1}}, changes)
+
+		p, err := mgr.Get(ctx, "web")
+		require.NoError(t, err)
+		assert.Equal(t, BaseHostPort+1, p.HostPort)
+		assert.Equal(t, "container-replaced", p.ContainerID)
+		require.Len(t, created, 1)
+		assert.Contains(t, created[0].Ports, "9001:80")
+
+		events, err := mgr.History(ctx, "web", time.Time{})
+		require.NoError(t, err)
+		last := events[len(events)-1]
+		assert.Equal(t, store.EventPortChanged, last.Type)
+		assert.Equal(t, "9000 -> 9001", last.Detail)
+	})
+
+	t.Run("running pucks keep their ports", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+		busyPorts(mgr)
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "web"})
+		require.NoError(t, err)
+
+		// The running puck's own container is what holds the port
+		busyPorts(mgr, BaseHostPort)
+		changes, err := mgr.ReconcilePorts(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, changes)
+	})
+
+	t.Run("resolves pucks claiming the same port", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+		busyPorts(mgr)
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "old"})
+		require.NoError(t, err)
+		_, err = mgr.Create(ctx, CreateOptions{Name: "new"})
+		require.NoError(t, err)
+		for _, name := range []string{"old", "new"} {
+			require.NoError(t, mgr.Stop(ctx, name))
+		}
+		require.NoError(t, mgr.store.UpdatePuckHostPort(ctx, "new", BaseHostPort))
+
+		changes, err := mgr.ReconcilePorts(ctx)
+		require.NoError(t, err)
+		require.Len(t, changes, 1)
+		assert.Equal(t, "new", changes[0].Puck)
+		assert.NotEqual(t, BaseHostPort, changes[0].To)
+
+		old, err := mgr.Get(ctx, "old")
+		require.NoError(t, err)
+		assert.Equal(t, BaseHostPort, old.HostPort)
+	})
+}
+
+func TestRestoreReassignsPort(t *testing.T) {
+	mgr, mock, cleanup := setupTestManager(t)
+	defer cleanup()
+	ctx := context.Background()
+	busyPorts(mgr)
+
+	var restored podman.RestoreOptions
+	mock.RestoreFunc = func(ctx context.Context, opts podman.RestoreOptions) (string, error) {
+		restored = opts
+		return "restored-container-id", nil
+	}
+
+	_, err := mgr.Create(ctx, CreateOptions{Name: "web", Ports: []string{"3000:3000"}})
+	require.NoError(t, err)
+	_, err = mgr.CreateSnapshot(ctx, SnapshotCreateOptions{PuckName: "web", SnapshotName: "base"})
+	require.NoError(t, err)
+
+	t.Run("keeps a free port", func(t *testing.T) {
+		require.NoError(t, mgr.RestoreSnapshot(ctx, SnapshotRestoreOptions{PuckName: "web", SnapshotName: "base"}))
+		assert.Equal(t, []string{"3000:3000", "9000:80"}, restored.PublishPorts)
+	})
+
+	t.Run("moves off a port taken while checkpointed", func(t *testing.T) {
+		busyPorts(mgr, BaseHostPort)
+		require.NoError(t, mgr.RestoreSnapshot(ctx, SnapshotRestoreOptions{PuckName: "web", SnapshotName: "base"}))
+		assert.Equal(t, []string{"3000:3000", "9001:80"}, restored.PublishPorts)
+
+		p, err := mgr.Get(ctx, "web")
+		require.NoError(t, err)
+		assert.Equal(t, BaseHostPort+1, p.HostPort)
+	})
+}
+
+func TestStartFollowsPublishedPort(t *testing.T) {
+	mgr, mock, cleanup := setupTestManager(t)
+	defer cleanup()
+	ctx := context.Background()
+	busyPorts(mgr)
+
+	_, err := mgr.Create(ctx, CreateOptions{Name: "web", Ports: []string{"3000:80"}})
+	require.NoError(t, err)
+	require.NoError(t, mgr.Stop(ctx, "web"))
+
+	published := func(ports ...string) {
+		mock.InspectContainerFunc = func(ctx context.Context, nameOrID string) (*define.InspectContainerData, error) {
+			var bindings []define.InspectHostPort
+			for _, port := range ports {
+				bindings = append(bindings, define.InspectHostPort{HostPort: port})
+			}
+			return &define.InspectContainerData{NetworkSettings: &define.InspectNetworkSettings{
+				Ports: map[string][]define.InspectHostPort{"80/tcp": bindings},
+			}}, nil
+		}
+	}
+
+	t.Run("leaves a matching port alone", func(t *testing.T) {
+		published("3000", "9000")
+		require.NoError(t, mgr.Start(ctx, "web"))
+		require.NoError(t, mgr.Stop(ctx, "web"))
+
+		p, err := mgr.Get(ctx, "web")
+		require.NoError(t, err)
+		assert.Equal(t, BaseHostPort, p.HostPort)
+	})
+
+	t.Run("records the port podman published", func(t *testing.T) {
+		// The puck's own 3000:80 mapping isn't the router's port
+		published("3000", "9123")
+		require.NoError(t, mgr.Start(ctx, "web"))
+
+		p, err := mgr.Get(ctx, "web")
+		require.NoError(t, err)
+		assert.Equal(t, 9123, p.HostPort)
+
+		events, err := mgr.History(ctx, "web", time.Time{})
+		require.NoError(t, err)
+		var detail string
+		for _, ev := range events {
+			if ev.Type == store.EventPortChanged {
+				detail = ev.Detail
+			}
+		}
+		assert.Equal(t, "9000 -> 9123 (published by podman)", detail)
+	})
+}
+
+func TestContainerIPRouting(t *testing.T) {
+	mgr, mock, cleanup := setupTestManager(t)
+	defer cleanup()
+	ctx := context.Background()
+	mgr.cfg.RouteMode = config.RouteContainerIP
+
+	var created []podman.CreateContainerOptions
+	mock.CreateContainerFunc = func(ctx context.Context, opts podman.CreateContainerOptions) (string, error) {
+		created = append(created, opts)
+		return "container-" + opts.Name, nil
+	}
+
+	_, err := mgr.Create(ctx, CreateOptions{Name: "web", Ports: []string{"3000:3000"}})
+	require.NoError(t, err)
+
+	// Only the user's own ports are published
+	require.Len(t, created, 1)
+	assert.Equal(t, []string{"3000:3000"}, created[0].Ports)
+
+	// Busy host ports are no reason to move it
+	require.NoError(t, mgr.Stop(ctx, "web"))
+	busyPorts(mgr, BaseHostPort)
+	changes, err := mgr.ReconcilePorts(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+}
+
+func TestPinnedHostPorts(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("creates a puck on the port asked for", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		busyPorts(mgr)
+
+		p, err := mgr.Create(ctx, CreateOptions{Name: "web", HostPort: 9123})
+		require.NoError(t, err)
+		assert.Equal(t, 9123, p.HostPort)
+		assert.True(t, p.HostPortPinned)
+
+		_, err = mgr.Create(ctx, CreateOptions{Name: "api", HostPort: 9123})
+		assert.ErrorContains(t, err, "taken by puck 'web'")
+		_, err = mgr.Create(ctx, CreateOptions{Name: "api", HostPort: 70000})
+		assert.Error(t, err)
+
+		busyPorts(mgr, 9200)
+		_, err = mgr.Create(ctx, CreateOptions{Name: "api", HostPort: 9200})
+		assert.ErrorContains(t, err, "in use")
+
+		// Allocation steers clear of the pinned port
+		_, err = mgr.Create(ctx, CreateOptions{Name: "hash", HostPort: BaseHostPort})
+		require.NoError(t, err)
+		other, err := mgr.Create(ctx, CreateOptions{Name: "other"})
+		require.NoError(t, err)
+		assert.Equal(t, BaseHostPort+1, other.HostPort)
+	})
+
+	t.Run("sets the port of a stopped puck", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		busyPorts(mgr)
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "web"})
+		require.NoError(t, err)
+		_, err = mgr.SetHostPort(ctx, "web", 9123)
+		assert.ErrorContains(t, err, "stop it")
+
+		// Pinning the port it is running on is fine
+		p, err := mgr.SetHostPort(ctx, "web", BaseHostPort)
+		require.NoError(t, err)
+		assert.True(t, p.HostPortPinned)
+
+		require.NoError(t, mgr.Stop(ctx, "web"))
+		var created []podman.CreateContainerOptions
+		mock.CreateContainerFunc = func(ctx context.Context, opts podman.CreateContainerOptions) (string, error) {
+			created = append(created, opts)
+			return "container-replaced", nil
+		}
+		p, err = mgr.SetHostPort(ctx, "web", 9123)
+		require.NoError(t, err)
+		assert.Equal(t, 9123, p.HostPort)
+		assert.Equal(t, "container-replaced", p.ContainerID)
+		require.Len(t, created, 1)
+		assert.Contains(t, created[0].Ports, "9123:80")
+
+		reservations, err := mgr.store.ListPortReservations(ctx)
+		require.NoError(t, err)
+		require.Len(t, reservations, 1)
+		assert.Equal(t, 9123, reservations[0].Port)
+
+		p, err = mgr.SetHostPort(ctx, "web", 0)
+		require.NoError(t, err)
+		assert.False(t, p.HostPortPinned)
+		assert.Equal(t, 9123, p.HostPort)
+	})
+
+	t.Run("never moves a pinned puck", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		busyPorts(mgr)
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "web", HostPort: 9123})
+		require.NoError(t, err)
+		require.NoError(t, mgr.Stop(ctx, "web"))
+
+		busyPorts(mgr, 9123)
+		changes, err := mgr.ReconcilePorts(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, changes)
+
+		err = mgr.Start(ctx, "web")
+		assert.ErrorContains(t, err, "pinned")
+		p, err := mgr.Get(ctx, "web")
+		require.NoError(t, err)
+		assert.Equal(t, 9123, p.HostPort)
+	})
+}
+
+func TestPortMappingSpecs(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("creates pucks with udp ports and ranges", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		var created []podman.CreateContainerOptions
+		mock.CreateContainerFunc = func(ctx context.Context, opts podman.CreateContainerOptions) (string, error) {
+			created = append(created, opts)
+			return "container-" + opts.Name, nil
+		}
+
+		ports := []string{"5353:5353/udp", "7000-7010:7000-7010", "127.0.0.1:8080:80/tcp,udp", "3000"}
+		p, err := mgr.Create(ctx, CreateOptions{Name: "web", Ports: ports})
+		require.NoError(t, err)
+		assert.Equal(t, ports, p.Ports)
+		require.Len(t, created, 1)
+		assert.Subset(t, created[0].Ports, ports)
+
+		for _, bad := range []string{"5353:5353/icmp", "7000-7010:7000", "7010-7000:80", "a:80", "0:80", "nope:8080:80"} {
+			_, err := mgr.Create(ctx, CreateOptions{Name: "bad", Ports: []string{bad}})
+			assert.Error(t, err, bad)
+		}
+	})
+
+	t.Run("finds tcp host ports published in ranges", func(t *testing.T) {
+		specs := []string{"7000-7010:8000-8010", "5353:5353/udp", "9123:80/udp,tcp"}
+		assert.True(t, publishes(specs, 7005, "tcp"))
+		assert.False(t, publishes(specs, 7011, "tcp"))
+		assert.False(t, publishes(specs, 5353, "tcp"))
+		assert.True(t, publishes(specs, 9123, "tcp"))
+		assert.True(t, publishes(specs, 5353, "udp"))
+	})
+
+	t.Run("parses host IPs, ranges and protocols", func(t *testing.T) {
+		parsed, err := podman.ParsePortMapping("[::1]:7000-7001:80-81/udp")
+		require.NoError(t, err)
+		assert.Equal(t, "::1", parsed.HostIP)
+		assert.Equal(t, uint16(7000), parsed.HostPort)
+		assert.Equal(t, uint16(80), parsed.ContainerPort)
+		assert.Equal(t, uint16(2), parsed.Range)
+		assert.Equal(t, "udp", parsed.Protocol)
+	})
+}
+
+func TestPublishAll(t *testing.T) {
+	ctx := context.Background()
+	mgr, mock, cleanup := setupTestManager(t)
+	defer cleanup()
+	busyPorts(mgr)
+
+	var created []podman.CreateContainerOptions
+	mock.CreateContainerFunc = func(ctx context.Context, opts podman.CreateContainerOptions) (string, error) {
+		created = append(created, opts)
+		return "container-" + opts.Name, nil
+	}
+	published := func(ports map[string]string) {
+		mock.InspectContainerFunc = func(ctx context.Context, nameOrID string) (*define.InspectContainerData, error) {
+			bindings := map[string][]define.InspectHostPort{"80/tcp": {{HostPort: "9000"}}}
+			for exposed, port := range ports {
+				bindings[exposed] = append(bindings[exposed], define.InspectHostPort{HostIP: "0.0.0.0", HostPort: port})
+			}
+			return &define.InspectContainerData{NetworkSettings: &define.InspectNetworkSettings{Ports: bindings}}, nil
+		}
+	}
+
+	published(map[string]string{"3000/tcp": "32768", "5353/udp": "32769", "8080/tcp": "8080"})
+	p, err := mgr.Create(ctx, CreateOptions{Name: "web", PublishAll: true, Ports: []string{"8080:8080"}})
+	require.NoError(t, err)
+	require.Len(t, created, 1)
+	assert.True(t, created[0].PublishAll)
+
+	// The router's port and the puck's own mappings aren't recorded
+	assert.Equal(t, []string{"32768:3000/tcp", "32769:5353/udp"}, p.Published)
+
+	// Ports podman picks change with each start
+	require.NoError(t, mgr.Stop(ctx, "web"))
+	published(map[string]string{"3000/tcp": "32800"})
+	require.NoError(t, mgr.Start(ctx, "web"))
+	p, err = mgr.Get(ctx, "web")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"32800:3000/tcp"}, p.Published)
+
+	_, err = mgr.Create(ctx, CreateOptions{Name: "box", PublishAll: true, Sandbox: store.SandboxStrict})
+	assert.Error(t, err)
+}
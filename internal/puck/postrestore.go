@@ -0,0 +1,80 @@
+package puck
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// PostRestoreHook is run inside a puck after each checkpoint restore when
+// it exists. It lives in the etc volume, so it survives recreates.
+const PostRestoreHook = "/etc/puck/post-restore"
+
+// PostRestoreLog is where the post-restore steps' output is kept, inside
+// the puck
+const PostRestoreLog = "/var/puck/post-restore.log"
+
+// clockSyncScript brings a restored puck's clocks and timers up to date.
+// Processes restored by CRIU resume with the time they were checkpointed
+// at, so cron jobs and systemd timers fire late or not at all and TLS
+// sees certificates from the past or future until the clock is stepped.
+const clockSyncScript = `
+if command -v chronyc >/dev/null 2>&1; then
+  echo "stepping the clock with chronyc"
+  chronyc -a makestep || true
+fi
+if [ -d /run/systemd/system ] && command -v systemctl >/dev/null 2>&1; then
+  if systemctl is-enabled --quiet systemd-timesyncd.service 2>/dev/null; then
+    echo "restarting systemd-timesyncd"
+    systemctl restart systemd-timesyncd.service || true
+  fi
+  for timer in $(systemctl list-units --type=timer --state=active --plain --no-legend | awk '{print $1}'); do
+    echo "re-arming $timer"
+    systemctl restart "$timer" || true
+  done
+  systemctl try-restart crond.service cron.service 2>/dev/null || true
+fi
+true
+`
+
+// postRestore runs the clock resync, unless it is turned off, and then
+// the puck's own post-restore hook. Failures are reported but leave the
+// restored puck running.
+func (m *Manager) postRestore(ctx context.Context, p *store.Puck, containerID string) error {
+	logFile, err := os.OpenFile(filepath.Join(p.VolumeDir, "var", path.Base(PostRestoreLog)), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("opening post-restore log: %w", err)
+	}
+	defer logFile.Close()
+
+	var failed []string
+	if m.cfg.RestoreClockSync {
+		fmt.Fprintln(logFile, "==> clock sync")
+		if err := m.podman.Exec(ctx, containerID, podman.ExecOptions{Cmd: []string{"/bin/sh", "-c", clockSyncScript}, Output: logFile}); err != nil {
+			failed = append(failed, "clock sync")
+		}
+	}
+
+	hook, err := os.Stat(filepath.Join(p.VolumeDir, "etc", path.Base(PostRestoreHook)))
+	if err == nil && hook.Mode().IsRegular() {
+		cmd := []string{"/bin/sh", PostRestoreHook}
+		if hook.Mode()&0111 != 0 {
+			cmd = []string{PostRestoreHook}
+		}
+		fmt.Fprintf(logFile, "==> %s\n", PostRestoreHook)
+		if err := m.podman.Exec(ctx, containerID, podman.ExecOptions{Cmd: cmd, Output: logFile}); err != nil {
+			failed = append(failed, PostRestoreHook)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%s failed (output in %s)", strings.Join(failed, " and "), PostRestoreLog)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package puck
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostRestore(t *testing.T) {
+	// setup returns a manager with a checkpointed puck, and the commands
+	// exec'd in it after that
+	setup := func(t *testing.T) (*Manager, *podman.MockClient, *[][]string, func()) {
+		mgr, mock, cleanup := setupTestManager(t)
+		mgr.cfg.RestoreClockSync = true
+
+		ctx := context.Background()
+		_, err := mgr.Create(ctx, CreateOptions{Name: "clock-puck"})
+		require.NoError(t, err)
+		_, err = mgr.CreateSnapshot(ctx, SnapshotCreateOptions{PuckName: "clock-puck", SnapshotName: "snap", LeaveRunning: leaveRunning})
+		require.NoError(t, err)
+
+		var execs [][]string
+		mock.ExecFunc = func(ctx context.Context, containerID string, opts podman.ExecOptions) error {
+			execs = append(execs, opts.Cmd)
+			return nil
+		}
+		return mgr, mock, &execs, cleanup
+	}
+
+	t.Run("resyncs the clock after a checkpoint restore", func(t *testing.T) {
+		mgr, _, execs, cleanup := setup(t)
+		defer cleanup()
+
+		require.NoError(t, mgr.RestoreSnapshot(context.Background(), SnapshotRestoreOptions{PuckName: "clock-puck", SnapshotName: "snap"}))
+		require.Len(t, *execs, 1)
+		assert.Equal(t, []string{"/bin/sh", "-c", clockSyncScript}, (*execs)[0])
+	})
+
+	t.Run("runs the puck's own hook after it", func(t *testing.T) {
+		mgr, _, execs, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		p, err := mgr.Get(ctx, "clock-puck")
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(p.VolumeDir, "etc", "post-restore"), []byte("#!/bin/sh\nrm -f /tmp/*.lock\n"), 0755))
+
+		require.NoError(t, mgr.RestoreSnapshot(ctx, SnapshotRestoreOptions{PuckName: "clock-puck", SnapshotName: "snap"}))
+		require.Len(t, *execs, 2)
+		assert.Equal(t, []string{PostRestoreHook}, (*execs)[1])
+	})
+
+	t.Run("notes a failure without undoing the restore", func(t *testing.T) {
+		mgr, mock, _, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+		mock.ExecFunc = func(ctx context.Context, containerID string, opts podman.ExecOptions) error {
+			return errors.New("exec failed")
+		}
+
+		require.NoError(t, mgr.RestoreSnapshot(ctx, SnapshotRestoreOptions{PuckName: "clock-puck", SnapshotName: "snap"}))
+		p, err := mgr.Get(ctx, "clock-puck")
+		require.NoError(t, err)
+		assert.Equal(t, store.StatusRunning, p.Status)
+
+		events, err := mgr.store.ListEvents(ctx, "clock-puck", time.Time{})
+		require.NoError(t, err)
+		var detail string
+		for _, ev := range events {
+			if ev.Type == store.EventSnapshotRestored {
+				detail = ev.Detail
+			}
+		}
+		assert.Contains(t, detail, "post-restore: clock sync failed")
+	})
+
+	t.Run("skips the resync when turned off", func(t *testing.T) {
+		mgr, _, execs, cleanup := setup(t)
+		defer cleanup()
+		mgr.cfg.RestoreClockSync = false
+
+		require.NoError(t, mgr.RestoreSnapshot(context.Background(), SnapshotRestoreOptions{PuckName: "clock-puck", SnapshotName: "snap"}))
+		assert.Empty(t, *execs)
+	})
+}
@@ -0,0 +1,143 @@
+package puck
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// Phases of creating a snapshot
+const (
+	PhaseCheckpoint = "checkpointing"     // CRIU dump and export
+	PhaseCommit     = "committing"        // podman commit, image mode
+	PhaseVolumes    = "archiving volumes" // image mode
+	PhaseCompress   = "compressing"       // recompressing a checkpoint
+	PhaseDedup      = "deduplicating"     // splitting a checkpoint into chunks
+	PhaseCancel     = "canceling"         // putting the puck back as it was
+)
+
+// SnapshotProgress is how far creating a snapshot has got
+type SnapshotProgress struct {
+	Puck  string `json:"puck"`
+	Phase string `json:"phase"`
+	Bytes int64  `json:"bytes,omitempty"` // written so far in this phase
+}
+
+// snapshotProgressInterval is how often the archive being written is
+// measured for progress
+const snapshotProgressInterval = 500 * time.Millisecond
+
+type snapshotProgressKey struct{}
+
+// WithSnapshotProgress returns a context whose snapshot creations report
+// their progress to fn
+func WithSnapshotProgress(ctx context.Context, fn func(SnapshotProgress)) context.Context {
+	return context.WithValue(ctx, snapshotProgressKey{}, fn)
+}
+
+// reportSnapshot reports progress to whoever is listening on ctx
+func reportSnapshot(ctx context.Context, p SnapshotProgress) {
+	if fn, _ := ctx.Value(snapshotProgressKey{}).(func(SnapshotProgress)); fn != nil {
+		fn(p)
+	}
+}
+
+// watchArchive reports a phase, then the size of the archive it writes
+// to path as it grows, until the returned function is called
+func watchArchive(ctx context.Context, puckName, phase, path string) func() {
+	reportSnapshot(ctx, SnapshotProgress{Puck: puckName, Phase: phase})
+	if fn, _ := ctx.Value(snapshotProgressKey{}).(func(SnapshotProgress)); fn == nil {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(snapshotProgressInterval)
+		defer ticker.Stop()
+		var last int64
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				info, err := os.Stat(path)
+				if err != nil || info.Size() == last {
+					continue
+				}
+				last = info.Size()
+				reportSnapshot(ctx, SnapshotProgress{Puck: puckName, Phase: phase, Bytes: last})
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// progressReader reports the bytes read through it, and stops reading
+// once ctx is canceled
+type progressReader struct {
+	ctx   context.Context
+	r     io.Reader
+	puck  string
+	phase string
+	n     int64
+	last  time.Time
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	if time.Since(r.last) >= snapshotProgressInterval {
+		r.last = time.Now()
+		reportSnapshot(r.ctx, SnapshotProgress{Puck: r.puck, Phase: r.phase, Bytes: r.n})
+	}
+	return n, err
+}
+
+// abandonSnapshot undoes a snapshot whose creation was canceled. A
+// checkpoint that stopped the puck has already killed its processes, so
+// the puck is restored from it before it is removed; otherwise the puck is
+// still as it was and only the files need to go.
+func (m *Manager) abandonSnapshot(ctx context.Context, snapshot *store.Snapshot, stopped bool) error {
+	canceled := fmt.Errorf("creating snapshot '%s' canceled: %w", snapshot.Name, ctx.Err())
+	ctx = context.WithoutCancel(ctx)
+	reportSnapshot(ctx, SnapshotProgress{Puck: snapshot.PuckName, Phase: PhaseCancel})
+
+	if !stopped {
+		m.removeSnapshotArtifacts(ctx, snapshot)
+		return canceled
+	}
+
+	// Restoring goes by the snapshot's record, which is dropped after
+	if err := m.store.CreateSnapshot(ctx, snapshot); err != nil {
+		m.removeSnapshotArtifacts(ctx, snapshot)
+		return fmt.Errorf("%w (the checkpoint stopped the puck, and recording it to restore from failed: %v)", canceled, err)
+	}
+	restoreErr := m.RestoreSnapshot(ctx, SnapshotRestoreOptions{
+		PuckName:     snapshot.PuckName,
+		SnapshotName: snapshot.Name,
+		Force:        true, // taken on this host a moment ago
+	})
+	p, err := m.store.GetPuck(ctx, snapshot.PuckName)
+	if err != nil {
+		return canceled
+	}
+	if restoreErr != nil {
+		// Keep the checkpoint, the only copy of the puck's processes
+		m.store.UpdatePuckStatus(ctx, p.Name, store.StatusCheckpointed)
+		return fmt.Errorf("%w (the checkpoint stopped the puck, and restoring it failed: %v; it is kept as snapshot '%s')", canceled, restoreErr, snapshot.Name)
+	}
+	m.dropSnapshot(ctx, p, snapshot)
+	return canceled
+}
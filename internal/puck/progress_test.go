@@ -0,0 +1,98 @@
+package puck
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/sandwich-labs/puck/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotProgressAndCancel(t *testing.T) {
+	// setup returns a manager with a running puck that has a snapshot, and
+	// a context the checkpoint cancels once it has written its archive
+	setup := func(t *testing.T, checkpointErr func(ctx context.Context) error) (*Manager, *podman.MockClient, context.Context, func()) {
+		mgr, mock, cleanup := setupTestManager(t)
+		_, err := mgr.Create(context.Background(), CreateOptions{Name: "cancel-puck"})
+		require.NoError(t, err)
+
+		_, err = mgr.CreateSnapshot(context.Background(), SnapshotCreateOptions{PuckName: "cancel-puck", SnapshotName: "base", LeaveRunning: leaveRunning})
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		mock.CheckpointFunc = func(cpCtx context.Context, nameOrID string, opts podman.CheckpointOptions) error {
+			if err := testutil.WriteCheckpoint(cpCtx, nameOrID, opts); err != nil {
+				return err
+			}
+			cancel()
+			return checkpointErr(cpCtx)
+		}
+		mock.Reset()
+		return mgr, mock, ctx, func() {
+			cancel()
+			cleanup()
+		}
+	}
+
+	t.Run("reports each phase", func(t *testing.T) {
+		mgr, _, _, cleanup := setup(t, func(context.Context) error { return nil })
+		defer cleanup()
+		mgr.cfg.SnapshotDedup = true
+
+		var phases []string
+		ctx := WithSnapshotProgress(context.Background(), func(p SnapshotProgress) {
+			assert.Equal(t, "cancel-puck", p.Puck)
+			if len(phases) == 0 || phases[len(phases)-1] != p.Phase {
+				phases = append(phases, p.Phase)
+			}
+		})
+		_, err := mgr.CreateSnapshot(ctx, SnapshotCreateOptions{PuckName: "cancel-puck", SnapshotName: "snap", LeaveRunning: leaveRunning})
+		require.NoError(t, err)
+		assert.Equal(t, []string{PhaseCheckpoint, PhaseDedup}, phases)
+	})
+
+	t.Run("removes the partial archive and leaves the puck running", func(t *testing.T) {
+		// The checkpoint sees the cancel and gives up
+		mgr, mock, ctx, cleanup := setup(t, func(ctx context.Context) error { return ctx.Err() })
+		defer cleanup()
+
+		_, err := mgr.CreateSnapshot(ctx, SnapshotCreateOptions{PuckName: "cancel-puck", SnapshotName: "snap", LeaveRunning: leaveRunning})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.NoFileExists(t, filepath.Join(mgr.cfg.SnapshotsDir(), "cancel-puck", "snap.tar.gz"))
+		assert.False(t, mock.WasCalled("Restore"))
+
+		snapshots, err := mgr.ListSnapshots(context.Background(), "cancel-puck")
+		require.NoError(t, err)
+		assert.Len(t, snapshots, 1)
+	})
+
+	t.Run("restores a puck the canceled checkpoint stopped", func(t *testing.T) {
+		// Checkpoints that stop the puck aren't interrupted
+		mgr, mock, ctx, cleanup := setup(t, func(ctx context.Context) error {
+			assert.NoError(t, ctx.Err())
+			return nil
+		})
+		defer cleanup()
+		before, err := mgr.Get(context.Background(), "cancel-puck")
+		require.NoError(t, err)
+
+		_, err = mgr.CreateSnapshot(ctx, SnapshotCreateOptions{PuckName: "cancel-puck", SnapshotName: "snap"})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.True(t, mock.WasCalled("Restore"))
+		assert.NoFileExists(t, filepath.Join(mgr.cfg.SnapshotsDir(), "cancel-puck", "snap.tar.gz"))
+
+		p, err := mgr.Get(context.Background(), "cancel-puck")
+		require.NoError(t, err)
+		assert.Equal(t, store.StatusRunning, p.Status)
+		assert.Equal(t, before.SnapshotHead, p.SnapshotHead)
+
+		snapshots, err := mgr.ListSnapshots(context.Background(), "cancel-puck")
+		require.NoError(t, err)
+		require.Len(t, snapshots, 1)
+		assert.Equal(t, "base", snapshots[0].Name)
+	})
+}
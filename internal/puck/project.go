@@ -0,0 +1,155 @@
+package puck
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/docker/go-units"
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// projectNamePattern matches valid project names
+var projectNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// ProjectStatus is a project's usage next to its configured limits
+type ProjectStatus struct {
+	Name    string               `json:"name"`
+	Members []string             `json:"members"`
+	Pucks   int                  `json:"pucks"`
+	Running int                  `json:"running"`
+	Disk    int64                `json:"disk"` // bytes of puck data and snapshots
+	Limits  config.ProjectLimits `json:"limits"`
+}
+
+// ProjectStatuses reports every project that has pucks or limits, by name
+func (m *Manager) ProjectStatuses(ctx context.Context) ([]ProjectStatus, error) {
+	pucks, err := m.store.ListPucks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*ProjectStatus)
+	status := func(name string) *ProjectStatus {
+		s, ok := byName[name]
+		if !ok {
+			s = &ProjectStatus{Name: name, Members: []string{}, Limits: m.cfg.Projects[name]}
+			byName[name] = s
+		}
+		return s
+	}
+	for name := range m.cfg.Projects {
+		status(name)
+	}
+	for _, p := range pucks {
+		if p.Project == "" {
+			continue
+		}
+		s := status(p.Project)
+		s.Members = append(s.Members, p.Name)
+		s.Pucks++
+		if p.Status.Up() {
+			s.Running++
+		}
+		s.Disk += m.puckDisk(ctx, p)
+	}
+
+	statuses := make([]ProjectStatus, 0, len(byName))
+	for _, s := range byName {
+		sort.Strings(s.Members)
+		statuses = append(statuses, *s)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses, nil
+}
+
+// checkProjectLimits refuses to create (when creating) or start p if its
+// project is already at one of its limits
+func (m *Manager) checkProjectLimits(ctx context.Context, p *store.Puck, creating bool) error {
+	if p.Project == "" {
+		return nil
+	}
+	limits, ok := m.cfg.Projects[p.Project]
+	if !ok || limits == (config.ProjectLimits{}) {
+		return nil
+	}
+
+	pucks, err := m.store.ListPucks(ctx)
+	if err != nil {
+		return err
+	}
+
+	var count, running int
+	var disk int64
+	for _, o := range pucks {
+		if o.Project != p.Project {
+			continue
+		}
+		if limits.MaxDisk > 0 {
+			disk += m.puckDisk(ctx, o)
+		}
+		if o.Name == p.Name {
+			continue
+		}
+		count++
+		if o.Status.Up() {
+			running++
+		}
+	}
+
+	var over []string
+	if creating && limits.MaxPucks > 0 && count >= limits.MaxPucks {
+		over = append(over, fmt.Sprintf("%d of %d pucks", count, limits.MaxPucks))
+	}
+	if limits.MaxRunning > 0 && running >= limits.MaxRunning {
+		over = append(over, fmt.Sprintf("%d of %d running", running, limits.MaxRunning))
+	}
+	if limits.MaxDisk > 0 && disk >= limits.MaxDisk {
+		over = append(over, fmt.Sprintf("disk: %s of %s used", units.BytesSize(float64(disk)), units.BytesSize(float64(limits.MaxDisk))))
+	}
+	if len(over) == 0 {
+		return nil
+	}
+
+	action := "starting"
+	if creating {
+		action = "creating"
+	}
+	return fmt.Errorf("%s '%s' would exceed the limits of project '%s' (%s); stop or destroy one of its pucks, or raise the limits in the config", action, p.Name, p.Project, strings.Join(over, ", "))
+}
+
+// validateProject checks a project name given for a new puck
+func validateProject(name string) error {
+	if name != "" && !projectNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid project name %q", name)
+	}
+	return nil
+}
+
+// puckDisk returns the bytes a puck's volume directory and snapshots take
+// up. Files the daemon can't read are skipped.
+func (m *Manager) puckDisk(ctx context.Context, p *store.Puck) int64 {
+	var size int64
+	filepath.WalkDir(p.VolumeDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+
+	snapshots, _ := m.store.ListSnapshots(ctx, p.ID)
+	for _, s := range snapshots {
+		size += s.SizeBytes
+	}
+	return size
+}
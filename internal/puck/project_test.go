@@ -0,0 +1,113 @@
+package puck
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProjectLimits(t *testing.T) {
+	t.Run("caps the number of pucks", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+		mgr.cfg.Projects = map[string]config.ProjectLimits{"demo": {MaxPucks: 2}}
+
+		for _, name := range []string{"a", "b"} {
+			_, err := mgr.Create(ctx, CreateOptions{Name: name, Project: "demo"})
+			require.NoError(t, err)
+		}
+		_, err := mgr.Create(ctx, CreateOptions{Name: "c", Project: "demo"})
+		assert.ErrorContains(t, err, "2 of 2 pucks")
+
+		// Other projects and unlabelled pucks are unaffected
+		_, err = mgr.Create(ctx, CreateOptions{Name: "c", Project: "other"})
+		assert.NoError(t, err)
+		_, err = mgr.Create(ctx, CreateOptions{Name: "d"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("caps running pucks on create and start", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+		mgr.cfg.Projects = map[string]config.ProjectLimits{"demo": {MaxRunning: 1}}
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "a", Project: "demo"})
+		require.NoError(t, err)
+		_, err = mgr.Create(ctx, CreateOptions{Name: "b", Project: "demo"})
+		assert.ErrorContains(t, err, "1 of 1 running")
+
+		require.NoError(t, mgr.Stop(ctx, "a"))
+		_, err = mgr.Create(ctx, CreateOptions{Name: "b", Project: "demo"})
+		require.NoError(t, err)
+
+		err = mgr.Start(ctx, "a")
+		assert.ErrorContains(t, err, "starting 'a' would exceed the limits of project 'demo'")
+	})
+
+	t.Run("caps disk use", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+		mgr.cfg.Projects = map[string]config.ProjectLimits{"demo": {MaxDisk: 1024}}
+
+		p, err := mgr.Create(ctx, CreateOptions{Name: "a", Project: "demo"})
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(filepath.Join(p.VolumeDir, "home", "big"), make([]byte, 2048), 0644))
+
+		_, err = mgr.Create(ctx, CreateOptions{Name: "b", Project: "demo"})
+		assert.ErrorContains(t, err, "disk")
+	})
+
+	t.Run("rejects invalid project names", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+
+		_, err := mgr.Create(context.Background(), CreateOptions{Name: "a", Project: "my project"})
+		assert.ErrorContains(t, err, "invalid project name")
+	})
+}
+
+func TestProjectStatuses(t *testing.T) {
+	mgr, _, cleanup := setupTestManager(t)
+	defer cleanup()
+	ctx := context.Background()
+	mgr.cfg.Projects = map[string]config.ProjectLimits{
+		"demo":  {MaxPucks: 5, MaxRunning: 2},
+		"empty": {MaxPucks: 1},
+	}
+
+	p, err := mgr.Create(ctx, CreateOptions{Name: "web", Project: "demo"})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(p.VolumeDir, "home", "data"), make([]byte, 100), 0644))
+	_, err = mgr.Create(ctx, CreateOptions{Name: "db", Project: "demo"})
+	require.NoError(t, err)
+	require.NoError(t, mgr.Stop(ctx, "db"))
+	_, err = mgr.Create(ctx, CreateOptions{Name: "tmp", Project: "scratch"})
+	require.NoError(t, err)
+	_, err = mgr.Create(ctx, CreateOptions{Name: "loose"})
+	require.NoError(t, err)
+
+	statuses, err := mgr.ProjectStatuses(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 3)
+
+	assert.Equal(t, "demo", statuses[0].Name)
+	assert.Equal(t, []string{"db", "web"}, statuses[0].Members)
+	assert.Equal(t, 2, statuses[0].Pucks)
+	assert.Equal(t, 1, statuses[0].Running)
+	assert.Equal(t, int64(100), statuses[0].Disk)
+	assert.Equal(t, 2, statuses[0].Limits.MaxRunning)
+
+	assert.Equal(t, "empty", statuses[1].Name)
+	assert.Empty(t, statuses[1].Members)
+
+	assert.Equal(t, "scratch", statuses[2].Name)
+	assert.Equal(t, config.ProjectLimits{}, statuses[2].Limits)
+}
@@ -0,0 +1,121 @@
+package puck
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// PromoteOptions contains options for swapping one puck in for another
+type PromoteOptions struct {
+	Name      string `json:"name"`    // puck taking over
+	Replace   string `json:"replace"` // puck whose paths it takes over
+	StopOld   bool   `json:"stop_old,omitempty"`
+	CreatedBy string `json:"-"` // set by the daemon from the caller
+}
+
+// PromoteResult describes a swap
+type PromoteResult struct {
+	Puck     string   `json:"puck"`
+	Replaced string   `json:"replaced"`
+	Paths    []string `json:"paths"` // paths now served by Puck
+	Stopped  bool     `json:"stopped"`
+
+	// Aliases the paths had before, nil where there was none, to undo
+	// the swap
+	previous map[string]*store.RouteAlias
+}
+
+// Promote points the replaced puck's route and aliases at another puck,
+// so whatever depends on them is served by the new puck from then on.
+// The router must be updated to match; RevertPromote undoes the swap if
+// it can't be.
+func (m *Manager) Promote(ctx context.Context, opts PromoteOptions) (*PromoteResult, error) {
+	if opts.Name == opts.Replace {
+		return nil, fmt.Errorf("a puck can't replace itself")
+	}
+
+	p, err := m.store.GetPuck(ctx, opts.Name)
+	if err != nil {
+		return nil, err
+	}
+	old, err := m.store.GetPuck(ctx, opts.Replace)
+	if err != nil {
+		return nil, err
+	}
+
+	// Requests would fail until the new puck is up
+	if p.Status != store.StatusRunning {
+		return nil, fmt.Errorf("puck '%s' is %s; start it and wait until it is running before promoting it", p.Name, p.Status)
+	}
+
+	aliases, err := m.store.ListRouteAliases(ctx, old.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PromoteResult{
+		Puck:     p.Name,
+		Replaced: old.Name,
+		Paths:    []string{"/" + old.Name},
+		previous: make(map[string]*store.RouteAlias),
+	}
+	for _, a := range aliases {
+		result.Paths = append(result.Paths, a.Path)
+	}
+
+	now := time.Now()
+	err = m.store.InTx(ctx, func(tx *store.DB) error {
+		for _, path := range result.Paths {
+			result.previous[path], _ = tx.GetRouteAlias(ctx, path)
+
+			// The new puck's own path needs no alias to reach it
+			if path == "/"+p.Name {
+				if result.previous[path] != nil {
+					if err := tx.DeleteRouteAlias(ctx, path); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+
+			alias := &store.RouteAlias{Path: path, PuckName: p.Name, CreatedBy: opts.CreatedBy, CreatedAt: now}
+			if err := tx.SetRouteAlias(ctx, alias); err != nil {
+				return err
+			}
+		}
+		return tx.RecordEvent(ctx, &store.Event{
+			PuckName: p.Name,
+			Type:     store.EventPromoted,
+			Detail:   fmt.Sprintf("replaces %s (%s)", old.Name, strings.Join(result.Paths, ", ")),
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("saving route aliases: %w", err)
+	}
+
+	return result, nil
+}
+
+// RevertPromote restores the aliases a promote replaced
+func (m *Manager) RevertPromote(ctx context.Context, result *PromoteResult) error {
+	return m.store.InTx(ctx, func(tx *store.DB) error {
+		for _, path := range result.Paths {
+			if prev := result.previous[path]; prev != nil {
+				if err := tx.SetRouteAlias(ctx, prev); err != nil {
+					return err
+				}
+				continue
+			}
+			if _, err := tx.GetRouteAlias(ctx, path); err == nil {
+				if err := tx.DeleteRouteAlias(ctx, path); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,89 @@
+package puck
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromote(t *testing.T) {
+	setup := func(t *testing.T) (*Manager, func()) {
+		mgr, _, cleanup := setupTestManager(t)
+		ctx := context.Background()
+		for _, name := range []string{"backend-v1", "backend-v2"} {
+			_, err := mgr.Create(ctx, CreateOptions{Name: name})
+			require.NoError(t, err)
+			require.NoError(t, mgr.MarkReady(ctx, name))
+		}
+		_, _, err := mgr.SetRouteAlias(ctx, RouteAliasOptions{Path: "/api", PuckName: "backend-v1"})
+		require.NoError(t, err)
+		return mgr, cleanup
+	}
+
+	aliasTargets := func(t *testing.T, mgr *Manager) map[string]string {
+		aliases, err := mgr.ListRouteAliases(context.Background(), "")
+		require.NoError(t, err)
+		targets := make(map[string]string)
+		for _, a := range aliases {
+			targets[a.Path] = a.PuckName
+		}
+		return targets
+	}
+
+	t.Run("points the old puck's paths at the new one", func(t *testing.T) {
+		mgr, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		result, err := mgr.Promote(ctx, PromoteOptions{Name: "backend-v2", Replace: "backend-v1"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"/backend-v1", "/api"}, result.Paths)
+		assert.Equal(t, map[string]string{"/backend-v1": "backend-v2", "/api": "backend-v2"}, aliasTargets(t, mgr))
+
+		events, err := mgr.History(ctx, "backend-v2", time.Time{})
+		require.NoError(t, err)
+		assert.Equal(t, store.EventPromoted, events[len(events)-1].Type)
+	})
+
+	t.Run("promoting back gives the puck its own path", func(t *testing.T) {
+		mgr, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Promote(ctx, PromoteOptions{Name: "backend-v2", Replace: "backend-v1"})
+		require.NoError(t, err)
+		_, err = mgr.Promote(ctx, PromoteOptions{Name: "backend-v1", Replace: "backend-v2"})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"/backend-v2": "backend-v1", "/api": "backend-v1"}, aliasTargets(t, mgr))
+	})
+
+	t.Run("reverts", func(t *testing.T) {
+		mgr, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		result, err := mgr.Promote(ctx, PromoteOptions{Name: "backend-v2", Replace: "backend-v1"})
+		require.NoError(t, err)
+		require.NoError(t, mgr.RevertPromote(ctx, result))
+		assert.Equal(t, map[string]string{"/api": "backend-v1"}, aliasTargets(t, mgr))
+	})
+
+	t.Run("requires a running puck", func(t *testing.T) {
+		mgr, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		require.NoError(t, mgr.store.UpdatePuckStatus(ctx, "backend-v2", store.StatusStarting))
+		_, err := mgr.Promote(ctx, PromoteOptions{Name: "backend-v2", Replace: "backend-v1"})
+		assert.ErrorContains(t, err, "running")
+
+		_, err = mgr.Promote(ctx, PromoteOptions{Name: "backend-v1", Replace: "backend-v1"})
+		assert.Error(t, err)
+		_, err = mgr.Promote(ctx, PromoteOptions{Name: "backend-v1", Replace: "missing"})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,70 @@
+package puck
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// ProvisionScript is a script run inside a new puck, e.g. from a template
+type ProvisionScript struct {
+	Name   string `json:"name"`
+	Script string `json:"script"`
+}
+
+// ProvisionLog is where provisioning output is kept, inside the puck
+const ProvisionLog = "/var/puck/provision.log"
+
+// unsafeScriptChars matches characters replaced in a script's file name
+var unsafeScriptChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+// Provision runs scripts inside a puck in order, stopping at the first
+// that fails. The scripts are written under /var/puck/provision and run
+// with /bin/sh unless they start with #!; their output is appended to
+// ProvisionLog.
+func (m *Manager) Provision(ctx context.Context, name string, scripts []ProvisionScript) error {
+	p, err := m.store.GetPuck(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(p.VolumeDir, "var", "provision")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating provision directory: %w", err)
+	}
+	logFile, err := os.OpenFile(filepath.Join(p.VolumeDir, "var", path.Base(ProvisionLog)), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("opening provision log: %w", err)
+	}
+	defer logFile.Close()
+
+	var ran []string
+	for i, s := range scripts {
+		file := fmt.Sprintf("%02d-%s", i+1, unsafeScriptChars.ReplaceAllString(path.Base(s.Name), "_"))
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(s.Script), 0755); err != nil {
+			return fmt.Errorf("writing provisioning script %s: %w", s.Name, err)
+		}
+
+		target := path.Join("/var/puck/provision", file)
+		cmd := []string{"/bin/sh", target}
+		if strings.HasPrefix(s.Script, "#!") {
+			cmd = []string{target}
+		}
+		fmt.Fprintf(logFile, "==> %s\n", s.Name)
+		if err := m.podman.Exec(ctx, p.ContainerID, podman.ExecOptions{Cmd: cmd, Output: logFile}); err != nil {
+			m.record(ctx, name, store.EventProvisioned, fmt.Sprintf("%s failed", s.Name))
+			return fmt.Errorf("provisioning script %s failed: %w (output in %s)", s.Name, err, ProvisionLog)
+		}
+		ran = append(ran, s.Name)
+	}
+
+	m.record(ctx, name, store.EventProvisioned, strings.Join(ran, ", "))
+	return nil
+}
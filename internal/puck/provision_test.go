@@ -0,0 +1,68 @@
+package puck
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvision(t *testing.T) {
+	t.Run("runs scripts in order", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		p, err := mgr.Create(ctx, CreateOptions{Name: "dev"})
+		require.NoError(t, err)
+
+		var cmds [][]string
+		mock.ExecFunc = func(ctx context.Context, containerID string, opts podman.ExecOptions) error {
+			assert.Equal(t, p.ContainerID, containerID)
+			assert.NotNil(t, opts.Output)
+			cmds = append(cmds, opts.Cmd)
+			return nil
+		}
+
+		require.NoError(t, mgr.Provision(ctx, "dev", []ProvisionScript{
+			{Name: "setup.sh", Script: "dnf install -y git\n"},
+			{Name: "scripts/node setup.py", Script: "#!/usr/bin/env python3\nprint('hi')\n"},
+		}))
+		assert.Equal(t, [][]string{
+			{"/bin/sh", "/var/puck/provision/01-setup.sh"},
+			{"/var/puck/provision/02-node_setup.py"},
+		}, cmds)
+
+		data, err := os.ReadFile(filepath.Join(p.VolumeDir, "var", "provision", "01-setup.sh"))
+		require.NoError(t, err)
+		assert.Equal(t, "dnf install -y git\n", string(data))
+
+		events, err := mgr.History(ctx, "dev", time.Time{})
+		require.NoError(t, err)
+		assert.Equal(t, store.EventProvisioned, events[len(events)-1].Type)
+	})
+
+	t.Run("stops at the first failure", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "dev"})
+		require.NoError(t, err)
+
+		mock.ExecFunc = func(ctx context.Context, containerID string, opts podman.ExecOptions) error {
+			return errors.New("exit status 1")
+		}
+
+		err = mgr.Provision(ctx, "dev", []ProvisionScript{{Name: "a.sh"}, {Name: "b.sh"}})
+		assert.ErrorContains(t, err, "a.sh failed")
+		assert.Equal(t, 1, mock.CallCount("Exec"))
+	})
+}
@@ -0,0 +1,42 @@
+package puck
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+// NetnsCommand prepares a command that runs args on the host but inside
+// a puck's network namespace, so that it connects to what the puck can
+// reach, as the puck. The puck is started if it isn't running.
+func (m *Manager) NetnsCommand(ctx context.Context, name string, args ...string) (*exec.Cmd, error) {
+	p, err := m.store.GetPuck(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	running, err := m.podman.IsRunning(ctx, p.ContainerID)
+	if err != nil {
+		return nil, fmt.Errorf("checking container status: %w", err)
+	}
+	if !running {
+		if err := m.Start(ctx, name); err != nil {
+			return nil, fmt.Errorf("starting puck: %w", err)
+		}
+		if p, err = m.store.GetPuck(ctx, name); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := m.podman.InspectContainer(ctx, p.ContainerID)
+	if err != nil {
+		return nil, err
+	}
+	if data.State == nil || data.State.Pid == 0 {
+		return nil, fmt.Errorf("puck '%s' has no running process", name)
+	}
+
+	m.store.TouchPuck(ctx, name, time.Now())
+	return nsenterCommand(ctx, data.State.Pid, args...), nil
+}
@@ -0,0 +1,49 @@
+package puck
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/containers/podman/v5/libpod/define"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetnsCommand(t *testing.T) {
+	t.Run("enters the network namespace of the puck", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "web"})
+		require.NoError(t, err)
+		mock.InspectContainerFunc = func(ctx context.Context, nameOrID string) (*define.InspectContainerData, error) {
+			return &define.InspectContainerData{State: &define.InspectContainerState{Running: true, Pid: 4242}}, nil
+		}
+
+		cmd, err := mgr.NetnsCommand(ctx, "web", "/usr/bin/puck", "proxy-serve")
+		require.NoError(t, err)
+		assert.Contains(t, fmt.Sprint(cmd.Args), "nsenter -t 4242 -n /usr/bin/puck proxy-serve")
+	})
+
+	t.Run("fails without a process", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "web"})
+		require.NoError(t, err)
+
+		_, err = mgr.NetnsCommand(ctx, "web", "true")
+		assert.ErrorContains(t, err, "no running process")
+	})
+
+	t.Run("returns error for non-existent puck", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+
+		_, err := mgr.NetnsCommand(context.Background(), "missing", "true")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,226 @@
+package puck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// replacedSuffix is added to the name of a replaced puck's container, and
+// of its volume directory, while the puck replacing it is made
+const replacedSuffix = ".replaced"
+
+// replacement is a puck set aside by a create that replaces it, until the
+// new puck is saved or the create fails
+type replacement struct {
+	old       *store.Puck
+	intent    *store.Intent
+	endpoints []*store.Endpoint
+	running   bool // its container was stopped
+	aside     bool // its container was renamed out of the way
+	moved     bool // its volume directory was moved out of the way
+}
+
+// replacedVolumeDir is where a replaced puck's volumes are moved when the
+// new puck's go in the same place
+func replacedVolumeDir(dir string) string {
+	return filepath.Join(filepath.Dir(dir), "."+filepath.Base(dir)+replacedSuffix)
+}
+
+// replacedEndpoints is the name a replaced puck's endpoints are kept
+// under, which no container, and so no puck, can have
+func replacedEndpoints(name string) string {
+	return name + "/replaced"
+}
+
+// setAside moves old out of the way of the puck replacing it, whose
+// volumes go in volumeDir, recording what it did in r. Its container is
+// stopped and renamed, its volumes moved if they are in the way, and its
+// endpoints kept under another name. The move is journaled, so a daemon
+// killed before the create is done puts old back when it next starts.
+func (m *Manager) setAside(ctx context.Context, old *store.Puck, volumeDir string, r *replacement) error {
+	intent := &store.Intent{Op: store.IntentReplace, PuckName: old.Name, PuckID: old.ID, ContainerID: old.ContainerID, VolumeDir: old.VolumeDir}
+	if err := m.store.BeginIntent(ctx, intent); err != nil {
+		return err
+	}
+	*r = replacement{old: old, intent: intent}
+
+	endpoints, err := m.store.ListEndpoints(ctx, old.Name)
+	if err != nil {
+		return err
+	}
+	if err := m.store.MoveEndpoints(ctx, old.Name, replacedEndpoints(old.Name)); err != nil {
+		return err
+	}
+	r.endpoints = endpoints
+
+	if exists, _ := m.podman.ContainerExists(ctx, old.ContainerID); exists {
+		if running, _ := m.podman.IsRunning(ctx, old.ContainerID); running {
+			if err := m.podman.StopContainer(ctx, old.ContainerID, m.stopTimeout(old)); err != nil {
+				return fmt.Errorf("stopping puck '%s': %w", old.Name, err)
+			}
+			r.running = true
+		}
+		if err := m.podman.RenameContainer(ctx, old.ContainerID, old.Name+replacedSuffix); err != nil {
+			return fmt.Errorf("moving puck '%s' aside: %w", old.Name, err)
+		}
+		r.aside = true
+	}
+
+	if old.VolumeDir == volumeDir {
+		err := os.Rename(old.VolumeDir, replacedVolumeDir(old.VolumeDir))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("moving the volumes of puck '%s' aside: %w", old.Name, err)
+		}
+		r.moved = err == nil
+	}
+	return nil
+}
+
+// putBack returns a puck set aside to how it was, once the create
+// replacing it has undone its own work after failing with cause
+func (m *Manager) putBack(ctx context.Context, r *replacement, cause error) error {
+	ctx = context.WithoutCancel(ctx)
+	old := r.old
+
+	var failed []string
+	if err := m.store.MoveEndpoints(ctx, replacedEndpoints(old.Name), old.Name); err != nil {
+		failed = append(failed, err.Error())
+	}
+	if r.moved {
+		if err := os.Rename(replacedVolumeDir(old.VolumeDir), old.VolumeDir); err != nil {
+			failed = append(failed, fmt.Sprintf("moving its volumes back: %v", err))
+		}
+	}
+	if r.aside {
+		if err := m.podman.RenameContainer(ctx, old.ContainerID, old.Name); err != nil {
+			failed = append(failed, fmt.Sprintf("renaming its container back: %v", err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%w (putting puck '%s' back: %s; the daemon tries again when it next starts)", cause, old.Name, strings.Join(failed, "; "))
+	}
+	m.store.FinishIntent(ctx, r.intent.ID)
+
+	if r.running {
+		if err := m.startContainer(ctx, old, old.ContainerID); err != nil {
+			return fmt.Errorf("%w (restarting puck '%s': %v)", cause, old.Name, err)
+		}
+	}
+	return cause
+}
+
+// deleteReplaced removes a replaced puck from the database, in the
+// transaction that saves the puck replacing it
+func deleteReplaced(ctx context.Context, tx *store.DB, r *replacement) error {
+	if err := deletePuckRecords(ctx, tx, r.old.Name); err != nil {
+		return err
+	}
+	if err := tx.DeleteEndpointsByPuck(ctx, replacedEndpoints(r.old.Name)); err != nil {
+		return fmt.Errorf("removing endpoints: %w", err)
+	}
+	return tx.FinishIntent(ctx, r.intent.ID)
+}
+
+// discardReplaced removes what is left of a replaced puck once the puck
+// replacing it, p, is saved: its container, committed snapshot images and
+// volumes, and the host ports p doesn't use
+func (m *Manager) discardReplaced(ctx context.Context, r *replacement, p *store.Puck) {
+	old := r.old
+	if r.aside {
+		m.podman.RemoveContainer(ctx, old.ContainerID, true)
+	}
+	if snapshots, err := m.store.ListSnapshots(ctx, old.ID); err == nil {
+		for _, s := range snapshots {
+			if s.CommitImage != "" {
+				m.podman.RemoveImage(ctx, s.CommitImage)
+			}
+		}
+	}
+	if r.moved {
+		os.RemoveAll(replacedVolumeDir(old.VolumeDir))
+	} else if old.VolumeDir != "" && old.VolumeDir != p.VolumeDir {
+		os.RemoveAll(old.VolumeDir)
+	}
+
+	inUse := map[int]bool{p.HostPort: true}
+	if endpoints, err := m.store.ListEndpoints(ctx, p.Name); err == nil {
+		for _, e := range endpoints {
+			inUse[e.HostPort] = true
+		}
+	}
+	ports := []int{old.HostPort}
+	for _, e := range r.endpoints {
+		ports = append(ports, e.HostPort)
+	}
+	for _, port := range ports {
+		if port > 0 && !inUse[port] {
+			m.store.ReleasePort(ctx, port, old.Name)
+		}
+	}
+	m.forgetRoutes(ctx, old.Name)
+}
+
+// undoReplace puts back a puck that a create replacing it had set aside
+// when the daemon was killed. What the create had made under the puck's
+// name goes first; the create's own intent, recovered after this one,
+// then finds the puck saved and leaves it be.
+func (m *Manager) undoReplace(ctx context.Context, in *store.Intent) (string, error) {
+	p, err := m.store.GetPuck(ctx, in.PuckName)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && p.ID != in.PuckID) {
+		return "finished", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	intents, err := m.store.ListIntents(ctx)
+	if err != nil {
+		return "", err
+	}
+	creating := false
+	for _, other := range intents {
+		if other.ID > in.ID && other.Op == store.IntentCreate && other.PuckName == in.PuckName {
+			creating = true
+		}
+	}
+	if creating {
+		if data, err := m.podman.InspectContainer(ctx, in.PuckName); err == nil && data.ID != in.ContainerID {
+			if err := m.podman.RemoveContainer(ctx, data.ID, true); err != nil {
+				return "", fmt.Errorf("removing the replacing container: %w", err)
+			}
+		}
+		if err := m.store.DeleteEndpointsByPuck(ctx, in.PuckName); err != nil {
+			return "", fmt.Errorf("removing the replacing endpoints: %w", err)
+		}
+	}
+	if err := m.store.MoveEndpoints(ctx, replacedEndpoints(in.PuckName), in.PuckName); err != nil {
+		return "", err
+	}
+
+	stash := replacedVolumeDir(in.VolumeDir)
+	if _, err := os.Stat(stash); err == nil {
+		if creating {
+			if err := os.RemoveAll(in.VolumeDir); err != nil {
+				return "", fmt.Errorf("removing the replacing volume directory: %w", err)
+			}
+		}
+		if err := os.Rename(stash, in.VolumeDir); err != nil {
+			return "", fmt.Errorf("moving volumes back: %w", err)
+		}
+	}
+
+	if in.ContainerID != "" {
+		if data, err := m.podman.InspectContainer(ctx, in.ContainerID); err == nil && strings.TrimPrefix(data.Name, "/") != in.PuckName {
+			if err := m.podman.RenameContainer(ctx, in.ContainerID, in.PuckName); err != nil {
+				return "", fmt.Errorf("renaming container back: %w", err)
+			}
+		}
+	}
+	return "undone", nil
+}
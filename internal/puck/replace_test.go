@@ -0,0 +1,134 @@
+package puck
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplace(t *testing.T) {
+	ctx := context.Background()
+
+	// setup makes a puck called web with an endpoint and a file in its
+	// volumes
+	setup := func(t *testing.T) (*Manager, *podman.MockClient, *store.Puck, string, func()) {
+		mgr, mock, cleanup := setupTestManager(t)
+		busyPorts(mgr)
+		orig, err := mgr.Create(ctx, CreateOptions{Name: "web", Endpoints: []EndpointSpec{{Name: "api", Port: 8000}}})
+		require.NoError(t, err)
+		marker := filepath.Join(orig.VolumeDir, "home", "data")
+		require.NoError(t, os.WriteFile(marker, []byte("keep"), 0644))
+		mock.Reset()
+		return mgr, mock, orig, marker, cleanup
+	}
+
+	t.Run("destroys the old puck once the new one is made", func(t *testing.T) {
+		mgr, mock, orig, marker, cleanup := setup(t)
+		defer cleanup()
+
+		p, err := mgr.Create(ctx, CreateOptions{Name: "web", Replace: true})
+		require.NoError(t, err)
+		assert.NotEqual(t, orig.ID, p.ID)
+		assert.Equal(t, orig.VolumeDir, p.VolumeDir)
+		assert.NoFileExists(t, marker)
+		assert.NoDirExists(t, replacedVolumeDir(orig.VolumeDir))
+		assert.True(t, mock.WasCalledWith("RemoveContainer", orig.ContainerID, true))
+
+		endpoints, err := mgr.Endpoints(ctx, "web")
+		require.NoError(t, err)
+		assert.Empty(t, endpoints)
+		endpoints, err = mgr.store.ListEndpoints(ctx, replacedEndpoints("web"))
+		require.NoError(t, err)
+		assert.Empty(t, endpoints)
+
+		intents, err := mgr.store.ListIntents(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, intents)
+	})
+
+	t.Run("puts the old puck back when the create fails", func(t *testing.T) {
+		mgr, mock, orig, marker, cleanup := setup(t)
+		defer cleanup()
+		mock.StartContainerFunc = func(ctx context.Context, id string) error {
+			if id == orig.ContainerID {
+				return nil
+			}
+			return assert.AnError
+		}
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "web", Replace: true})
+		require.ErrorIs(t, err, assert.AnError)
+
+		p, err := mgr.Get(ctx, "web")
+		require.NoError(t, err)
+		assert.Equal(t, orig.ID, p.ID)
+		assert.FileExists(t, marker)
+		assert.NoDirExists(t, replacedVolumeDir(orig.VolumeDir))
+		assert.True(t, mock.WasCalledWith("RenameContainer", orig.ContainerID, "web.replaced"))
+		assert.True(t, mock.WasCalledWith("RenameContainer", orig.ContainerID, "web"))
+		assert.True(t, mock.WasCalledWith("StartContainer", orig.ContainerID))
+		assert.False(t, mock.WasCalledWith("RemoveContainer", orig.ContainerID, true))
+
+		endpoints, err := mgr.Endpoints(ctx, "web")
+		require.NoError(t, err)
+		require.Len(t, endpoints, 1)
+		assert.Equal(t, "api", endpoints[0].Name)
+
+		intents, err := mgr.store.ListIntents(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, intents)
+	})
+
+	t.Run("leaves the old puck be when the new one fails its checks", func(t *testing.T) {
+		mgr, mock, orig, marker, cleanup := setup(t)
+		defer cleanup()
+		mock.ImageExistsFunc = func(context.Context, string) (bool, error) { return false, nil }
+		mock.PullImageFunc = func(context.Context, string) error { return assert.AnError }
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "web", Image: "missing:latest", Replace: true})
+		require.ErrorIs(t, err, assert.AnError)
+
+		p, err := mgr.Get(ctx, "web")
+		require.NoError(t, err)
+		assert.Equal(t, orig.ID, p.ID)
+		assert.FileExists(t, marker)
+		assert.False(t, mock.WasCalled("StopContainer"))
+		assert.False(t, mock.WasCalled("RenameContainer"))
+	})
+
+	t.Run("a restarted daemon puts back a puck set aside", func(t *testing.T) {
+		mgr, mock, orig, marker, cleanup := setup(t)
+		defer cleanup()
+
+		// Killed partway through making the new puck
+		var r replacement
+		require.NoError(t, mgr.setAside(ctx, orig, orig.VolumeDir, &r))
+		require.NoError(t, os.MkdirAll(filepath.Join(orig.VolumeDir, "home"), 0755))
+		require.NoError(t, mgr.store.BeginIntent(ctx, &store.Intent{
+			Op: store.IntentCreate, PuckName: "web", PuckID: "uuid-2", ContainerID: "half-made", VolumeDir: orig.VolumeDir, OwnsVolume: true,
+		}))
+		mock.Reset()
+
+		recovered, err := mgr.RecoverIntents(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []RecoveredIntent{
+			{Op: store.IntentReplace, Puck: "web", Result: "undone"},
+			{Op: store.IntentCreate, Puck: "web", Result: "finished"},
+		}, recovered)
+
+		p, err := mgr.Get(ctx, "web")
+		require.NoError(t, err)
+		assert.Equal(t, orig.ID, p.ID)
+		assert.FileExists(t, marker)
+		assert.NoDirExists(t, replacedVolumeDir(orig.VolumeDir))
+		endpoints, err := mgr.Endpoints(ctx, "web")
+		require.NoError(t, err)
+		assert.Len(t, endpoints, 1)
+	})
+}
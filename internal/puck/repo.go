@@ -0,0 +1,116 @@
+package puck
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// DefaultRepoDir is where a repository is cloned inside a puck unless
+// another directory is given
+const DefaultRepoDir = "/home/workspace"
+
+// RepoClone is a git repository cloned into a new puck once it is created
+type RepoClone struct {
+	URL    string `json:"url"`
+	Branch string `json:"branch,omitempty"`
+	Dir    string `json:"dir,omitempty"` // inside the puck; DefaultRepoDir if empty
+	// Token authenticates an HTTPS clone. It is handed to git for the
+	// clone alone and kept nowhere in the puck.
+	Token string `json:"token,omitempty"`
+}
+
+// cloneScript clones $PUCK_REPO_URL into $PUCK_REPO_DIR, installing git
+// first if the image lacks it. A directory that is already a repository
+// is left alone, so it can be run again.
+const cloneScript = `set -e
+if ! command -v git >/dev/null; then
+	echo "puck: installing git"
+	if command -v dnf >/dev/null; then dnf install -y git
+	elif command -v apt-get >/dev/null; then apt-get update && apt-get install -y git
+	elif command -v apk >/dev/null; then apk add git
+	else echo "puck: git not found; install git in the puck" >&2; exit 1
+	fi
+fi
+if [ -e "$PUCK_REPO_DIR/.git" ]; then
+	echo "puck: $PUCK_REPO_DIR is already a git repository"
+	exit 0
+fi
+mkdir -p "$(dirname "$PUCK_REPO_DIR")"
+set --
+if [ -n "$PUCK_REPO_BRANCH" ]; then set -- --branch "$PUCK_REPO_BRANCH"; fi
+git clone "$@" -- "$PUCK_REPO_URL" "$PUCK_REPO_DIR"`
+
+// validateRepo checks a repository to clone before the puck is created
+func validateRepo(r *RepoClone) error {
+	if r == nil {
+		return nil
+	}
+	if r.URL == "" || strings.HasPrefix(r.URL, "-") || strings.ContainsAny(r.URL, " \t\r\n") {
+		return fmt.Errorf("invalid repository URL %q", r.URL)
+	}
+	if strings.HasPrefix(r.Branch, "-") || strings.ContainsAny(r.Branch, " \t\r\n") {
+		return fmt.Errorf("invalid repository branch %q", r.Branch)
+	}
+	if r.Dir != "" && (!path.IsAbs(r.Dir) || path.Clean(r.Dir) == "/") {
+		return fmt.Errorf("repository directory %q must be an absolute path below /", r.Dir)
+	}
+	if r.Token != "" && !strings.HasPrefix(r.URL, "https://") {
+		return fmt.Errorf("a repository token is only used for https:// URLs")
+	}
+	return nil
+}
+
+// CloneRepo clones a repository into a puck, as when it is created with
+// one. Git's output is appended to ProvisionLog.
+func (m *Manager) CloneRepo(ctx context.Context, name string, r RepoClone) error {
+	if err := validateRepo(&r); err != nil {
+		return err
+	}
+	p, err := m.store.GetPuck(ctx, name)
+	if err != nil {
+		return err
+	}
+	dir := r.Dir
+	if dir == "" {
+		dir = DefaultRepoDir
+	}
+
+	logFile, err := os.OpenFile(filepath.Join(p.VolumeDir, "var", path.Base(ProvisionLog)), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("opening provision log: %w", err)
+	}
+	defer logFile.Close()
+
+	env := []string{"PUCK_REPO_URL=" + r.URL, "PUCK_REPO_BRANCH=" + r.Branch, "PUCK_REPO_DIR=" + path.Clean(dir)}
+	if r.Token != "" {
+		// Passed through git's environment config rather than the URL, so
+		// it isn't saved as the clone's remote
+		auth := base64.StdEncoding.EncodeToString([]byte("x-access-token:" + r.Token))
+		env = append(env,
+			"GIT_CONFIG_COUNT=1",
+			"GIT_CONFIG_KEY_0=http.extraHeader",
+			"GIT_CONFIG_VALUE_0=Authorization: Basic "+auth,
+		)
+	}
+
+	fmt.Fprintf(logFile, "==> git clone %s\n", r.URL)
+	if err := m.podman.Exec(ctx, p.ContainerID, podman.ExecOptions{
+		Cmd:    []string{"/bin/sh", "-c", cloneScript},
+		User:   "root",
+		Env:    env,
+		Output: logFile,
+	}); err != nil {
+		m.record(ctx, name, store.EventProvisioned, fmt.Sprintf("clone of %s failed", r.URL))
+		return fmt.Errorf("cloning %s failed: %w (output in %s)", r.URL, err, ProvisionLog)
+	}
+	m.record(ctx, name, store.EventProvisioned, fmt.Sprintf("cloned %s into %s", r.URL, dir))
+	return nil
+}
@@ -0,0 +1,79 @@
+package puck
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCloneRepo(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("clones with the token in git's environment only", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		p, err := mgr.Create(ctx, CreateOptions{Name: "app"})
+		require.NoError(t, err)
+
+		var got podman.ExecOptions
+		mock.ExecFunc = func(ctx context.Context, nameOrID string, opts podman.ExecOptions) error {
+			got = opts
+			fmt.Fprintln(opts.Output, "Cloning into '/home/workspace'...")
+			return nil
+		}
+		require.NoError(t, mgr.CloneRepo(ctx, "app", RepoClone{URL: "https://github.com/org/app", Branch: "dev", Token: "s3cret"}))
+
+		assert.Equal(t, []string{"/bin/sh", "-c", cloneScript}, got.Cmd)
+		auth := base64.StdEncoding.EncodeToString([]byte("x-access-token:s3cret"))
+		assert.Equal(t, []string{
+			"PUCK_REPO_URL=https://github.com/org/app",
+			"PUCK_REPO_BRANCH=dev",
+			"PUCK_REPO_DIR=" + DefaultRepoDir,
+			"GIT_CONFIG_COUNT=1",
+			"GIT_CONFIG_KEY_0=http.extraHeader",
+			"GIT_CONFIG_VALUE_0=Authorization: Basic " + auth,
+		}, got.Env)
+
+		log, err := os.ReadFile(filepath.Join(p.VolumeDir, "var", "provision.log"))
+		require.NoError(t, err)
+		assert.Contains(t, string(log), "Cloning into")
+		assert.NotContains(t, string(log), "s3cret")
+	})
+
+	t.Run("reports a failed clone", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		_, err := mgr.Create(ctx, CreateOptions{Name: "app"})
+		require.NoError(t, err)
+
+		mock.ExecFunc = func(ctx context.Context, nameOrID string, opts podman.ExecOptions) error {
+			return fmt.Errorf("exit status 128")
+		}
+		err = mgr.CloneRepo(ctx, "app", RepoClone{URL: "https://github.com/org/missing", Dir: "/src/app"})
+		assert.ErrorContains(t, err, "cloning https://github.com/org/missing failed")
+	})
+
+	t.Run("refuses bad repositories before creating the puck", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+
+		for _, r := range []RepoClone{
+			{URL: "--upload-pack=evil"},
+			{URL: "https://github.com/org/app", Dir: "relative"},
+			{URL: "https://github.com/org/app", Branch: "-x"},
+			{URL: "git@github.com:org/app.git", Token: "t"},
+		} {
+			_, err := mgr.Create(ctx, CreateOptions{Name: "app", Repo: &r})
+			assert.Error(t, err, r)
+		}
+		_, err := mgr.Get(ctx, "app")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,192 @@
+package puck
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// Report sums up what pucks did over a period, to help decide which are
+// worth keeping
+type Report struct {
+	Since time.Time    `json:"since"`
+	Until time.Time    `json:"until"`
+	Pucks []PuckReport `json:"pucks"`
+}
+
+// PuckReport is one puck's part of a report
+type PuckReport struct {
+	Name    string       `json:"name"`
+	Status  store.Status `json:"status"`
+	Project string       `json:"project,omitempty"`
+	// Time spent running in the period, from its history
+	Uptime   time.Duration `json:"uptime"`
+	Requests uint64        `json:"requests"` // routed to it
+	// Averages over the samples taken while it was up
+	CPU    float64 `json:"cpu"`    // percent of one CPU
+	Memory int64   `json:"memory"` // bytes
+	// Volumes and snapshots at the last sample, and the change since the
+	// first
+	Disk       int64      `json:"disk"`
+	DiskGrowth int64      `json:"disk_growth"`
+	Snapshots  int        `json:"snapshots"` // taken
+	Samples    int        `json:"samples"`
+	LastUsed   *time.Time `json:"last_used,omitempty"`
+	// Served no requests and wasn't used through puck in the period
+	Idle bool `json:"idle"`
+}
+
+// RecordStats samples the resource use of every puck: CPU and memory of
+// those that are up, the disk taken by their volumes and snapshots, and
+// hits, the requests the router has served each since it started.
+func (m *Manager) RecordStats(ctx context.Context, hits map[string]uint64) error {
+	pucks, err := m.store.ListPucks(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Without usage, samples still count disk and requests
+	var errs []error
+	if err := m.FillUsage(ctx, pucks); err != nil {
+		errs = append(errs, fmt.Errorf("reading usage: %w", err))
+	}
+	now := time.Now()
+	for _, p := range pucks {
+		s := &store.Stat{
+			PuckName:  p.Name,
+			Up:        p.Status.Up(),
+			Disk:      m.puckDisk(ctx, p),
+			Hits:      hits[p.Name],
+			CreatedAt: now,
+		}
+		if p.Usage != nil {
+			s.CPU, s.Memory = p.Usage.CPU, p.Usage.Memory
+		}
+		if err := m.store.RecordStat(ctx, s); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Report sums up the given pucks' history and sampled stats since since.
+// Samples exist only while a daemon has been running to take them.
+func (m *Manager) Report(ctx context.Context, pucks []*store.Puck, since time.Time) (*Report, error) {
+	report := &Report{Since: since, Until: time.Now(), Pucks: []PuckReport{}}
+	for _, p := range pucks {
+		events, err := m.store.ListEvents(ctx, p.Name, time.Time{})
+		if err != nil {
+			return nil, err
+		}
+		stats, err := m.store.ListStats(ctx, p.Name, since)
+		if err != nil {
+			return nil, err
+		}
+		report.Pucks = append(report.Pucks, reportPuck(p, events, stats, since, report.Until))
+	}
+	return report, nil
+}
+
+// Events returns the given pucks' events at or after since, in the order
+// they were recorded
+func (m *Manager) Events(ctx context.Context, pucks []*store.Puck, since time.Time) ([]*store.Event, error) {
+	var all []*store.Event
+	for _, p := range pucks {
+		events, err := m.store.ListEvents(ctx, p.Name, since)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, events...)
+	}
+	slices.SortFunc(all, func(a, b *store.Event) int { return cmp.Compare(a.ID, b.ID) })
+	return all, nil
+}
+
+// Stats returns the given pucks' stats samples taken at or after since,
+// in the order they were taken
+func (m *Manager) Stats(ctx context.Context, pucks []*store.Puck, since time.Time) ([]*store.Stat, error) {
+	var all []*store.Stat
+	for _, p := range pucks {
+		stats, err := m.store.ListStats(ctx, p.Name, since)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, stats...)
+	}
+	slices.SortFunc(all, func(a, b *store.Stat) int { return cmp.Compare(a.ID, b.ID) })
+	return all, nil
+}
+
+// reportPuck sums up a puck's events, its full history, and its stats
+// from the period between since and now
+func reportPuck(p *store.Puck, events []*store.Event, stats []*store.Stat, since, now time.Time) PuckReport {
+	r := PuckReport{
+		Name:    p.Name,
+		Status:  p.Status,
+		Project: p.Project,
+		Uptime:  uptime(events, since, now),
+		Samples: len(stats),
+	}
+	for _, e := range events {
+		if e.Type == store.EventSnapshotCreated && !e.CreatedAt.Before(since) {
+			r.Snapshots++
+		}
+	}
+
+	var up int
+	for i, s := range stats {
+		if s.Up {
+			r.CPU += s.CPU
+			r.Memory += s.Memory
+			up++
+		}
+		if i == 0 {
+			continue
+		}
+		r.Requests += newHits(stats[i-1].Hits, s.Hits)
+	}
+	if up > 0 {
+		r.CPU /= float64(up)
+		r.Memory /= int64(up)
+	}
+	if len(stats) > 0 {
+		r.Disk = stats[len(stats)-1].Disk
+		r.DiskGrowth = r.Disk - stats[0].Disk
+	}
+
+	if !p.LastUsedAt.IsZero() {
+		lastUsed := p.LastUsedAt
+		r.LastUsed = &lastUsed
+	}
+	r.Idle = r.Requests == 0 && p.LastUsedAt.Before(since)
+	return r
+}
+
+// uptime adds up the time between since and now that a puck's events
+// show it running
+func uptime(events []*store.Event, since, now time.Time) time.Duration {
+	var total time.Duration
+	running, from := false, since
+	for _, e := range events {
+		r, ok := e.Type.RunningAfter()
+		if !ok {
+			continue
+		}
+		if e.CreatedAt.After(since) {
+			if running {
+				total += e.CreatedAt.Sub(from)
+			}
+			from = e.CreatedAt
+		}
+		running = r
+	}
+	if running && now.After(from) {
+		total += now.Sub(from)
+	}
+	return total
+}
@@ -0,0 +1,149 @@
+package puck
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordStats(t *testing.T) {
+	ctx := context.Background()
+	mgr, mock, cleanup := setupTestManager(t)
+	defer cleanup()
+
+	mock.ContainerStatsFunc = func(ctx context.Context, ids []string) (map[string]podman.Stats, error) {
+		return map[string]podman.Stats{"c-web": {CPU: 12.5, Memory: 64 << 20}}, nil
+	}
+	now := time.Now()
+	for _, p := range []*store.Puck{
+		{ID: "id-web", ContainerID: "c-web", Name: "web", Status: store.StatusRunning},
+		{ID: "id-api", ContainerID: "c-api", Name: "api", Status: store.StatusStopped},
+	} {
+		p.Image = "fedora:latest"
+		p.VolumeDir = filepath.Join(t.TempDir(), p.Name)
+		p.CreatedAt, p.UpdatedAt = now, now
+		require.NoError(t, os.MkdirAll(p.VolumeDir, 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(p.VolumeDir, "data"), make([]byte, 100), 0644))
+		require.NoError(t, mgr.store.CreatePuck(ctx, p))
+	}
+
+	require.NoError(t, mgr.RecordStats(ctx, map[string]uint64{"web": 42}))
+
+	stats, err := mgr.store.ListStats(ctx, "web", time.Time{})
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	assert.True(t, stats[0].Up)
+	assert.Equal(t, 12.5, stats[0].CPU)
+	assert.Equal(t, int64(64<<20), stats[0].Memory)
+	assert.Equal(t, int64(100), stats[0].Disk)
+	assert.Equal(t, uint64(42), stats[0].Hits)
+
+	stats, err = mgr.store.ListStats(ctx, "api", time.Time{})
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	assert.False(t, stats[0].Up)
+	assert.Zero(t, stats[0].CPU)
+	assert.Equal(t, int64(100), stats[0].Disk)
+	assert.Zero(t, stats[0].Hits)
+}
+
+func TestReportPuck(t *testing.T) {
+	now := time.Now()
+	since := now.Add(-10 * time.Hour)
+	at := func(h int) time.Time { return since.Add(time.Duration(h) * time.Hour) }
+	event := func(typ store.EventType, t time.Time) *store.Event {
+		return &store.Event{Type: typ, CreatedAt: t}
+	}
+	stat := func(up bool, cpu float64, disk int64, hits uint64, t time.Time) *store.Stat {
+		return &store.Stat{Up: up, CPU: cpu, Memory: int64(cpu) << 20, Disk: disk, Hits: hits, CreatedAt: t}
+	}
+
+	t.Run("sums up the period", func(t *testing.T) {
+		p := &store.Puck{Name: "web", Status: store.StatusStopped, LastUsedAt: at(4)}
+		events := []*store.Event{
+			// Running since before the period
+			event(store.EventStarted, since.Add(-time.Hour)),
+			event(store.EventSnapshotCreated, since.Add(-time.Minute)),
+			event(store.EventStopped, at(2)),
+			event(store.EventStarted, at(4)),
+			event(store.EventSnapshotCreated, at(5)),
+			event(store.EventStopped, at(7)),
+		}
+		stats := []*store.Stat{
+			stat(true, 10, 1000, 5, at(1)),
+			stat(false, 0, 1000, 8, at(3)),
+			stat(true, 30, 1500, 20, at(5)),
+			// The router restarted
+			stat(false, 0, 1200, 4, at(8)),
+		}
+
+		r := reportPuck(p, events, stats, since, now)
+		assert.Equal(t, 5*time.Hour, r.Uptime)
+		assert.Equal(t, uint64(3+12+4), r.Requests)
+		assert.Equal(t, 20.0, r.CPU)
+		assert.Equal(t, int64(20<<20), r.Memory)
+		assert.Equal(t, int64(1200), r.Disk)
+		assert.Equal(t, int64(200), r.DiskGrowth)
+		assert.Equal(t, 1, r.Snapshots)
+		assert.Equal(t, 4, r.Samples)
+		assert.False(t, r.Idle)
+	})
+
+	t.Run("counts a puck still running up to now", func(t *testing.T) {
+		p := &store.Puck{Name: "api", Status: store.StatusRunning}
+		events := []*store.Event{event(store.EventCreated, at(6))}
+
+		r := reportPuck(p, events, nil, since, now)
+		assert.Equal(t, 4*time.Hour, r.Uptime.Round(time.Second))
+		assert.Zero(t, r.Samples)
+		assert.True(t, r.Idle, "never used")
+	})
+
+	t.Run("marks pucks unused through the period idle", func(t *testing.T) {
+		p := &store.Puck{Name: "old", Status: store.StatusStopped, LastUsedAt: since.Add(-time.Hour)}
+		stats := []*store.Stat{stat(false, 0, 500, 0, at(1)), stat(false, 0, 500, 0, at(2))}
+
+		r := reportPuck(p, nil, stats, since, now)
+		assert.Zero(t, r.Uptime)
+		assert.Zero(t, r.Requests)
+		assert.True(t, r.Idle)
+		require.NotNil(t, r.LastUsed)
+
+		stats = append(stats, stat(false, 0, 500, 3, at(3)))
+		assert.False(t, reportPuck(p, nil, stats, since, now).Idle, "served requests")
+	})
+}
+
+func TestEventsAndStats(t *testing.T) {
+	ctx := context.Background()
+	mgr, _, cleanup := setupTestManager(t)
+	defer cleanup()
+
+	start := time.Now().Add(-time.Hour)
+	for i, name := range []string{"web", "api", "web", "db"} {
+		at := start.Add(time.Duration(i) * time.Minute)
+		require.NoError(t, mgr.store.RecordEvent(ctx, &store.Event{PuckName: name, Type: store.EventStarted, CreatedAt: at}))
+		require.NoError(t, mgr.store.RecordStat(ctx, &store.Stat{PuckName: name, Up: true, CreatedAt: at}))
+	}
+	pucks := []*store.Puck{{Name: "web"}, {Name: "api"}}
+
+	events, err := mgr.Events(ctx, pucks, time.Time{})
+	require.NoError(t, err)
+	var names []string
+	for _, e := range events {
+		names = append(names, e.PuckName)
+	}
+	assert.Equal(t, []string{"web", "api", "web"}, names, "in the order recorded, of the given pucks only")
+
+	stats, err := mgr.Stats(ctx, pucks, start.Add(90*time.Second))
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	assert.Equal(t, "web", stats[0].PuckName)
+}
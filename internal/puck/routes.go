@@ -0,0 +1,58 @@
+package puck
+
+import (
+	"context"
+
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// Router is what serves pucks over HTTP. The manager keeps it in step
+// with pucks as they start, stop, are restored and are destroyed, so no
+// path through the manager leaves a route behind. Routing is best effort:
+// a route that can't be changed never fails the operation, so
+// implementations report their own errors.
+type Router interface {
+	// Route routes a puck that is up, or updates its route
+	Route(ctx context.Context, p *store.Puck)
+	// Unroute removes the route of a puck that is no longer up
+	Unroute(ctx context.Context, name string)
+	// Forget removes everything served for a destroyed puck: its route,
+	// its tailnet node, its share links, its aliases and its endpoints
+	Forget(ctx context.Context, name string)
+}
+
+// SetRouter sets the router the manager keeps pucks' routes in. Without
+// one, nothing is routed.
+func (m *Manager) SetRouter(r Router) {
+	m.router = r
+}
+
+// syncRoute routes a puck that is up, and unroutes one that isn't
+func (m *Manager) syncRoute(ctx context.Context, name string) {
+	if m.router == nil {
+		return
+	}
+	p, err := m.store.GetPuck(ctx, name)
+	if err != nil {
+		return
+	}
+	if p.Status.Up() && p.HostPort > 0 {
+		m.router.Route(ctx, p)
+		return
+	}
+	m.router.Unroute(ctx, name)
+}
+
+// unroute removes a stopped puck's route
+func (m *Manager) unroute(ctx context.Context, name string) {
+	if m.router != nil {
+		m.router.Unroute(ctx, name)
+	}
+}
+
+// forgetRoutes removes everything routed for a destroyed puck
+func (m *Manager) forgetRoutes(ctx context.Context, name string) {
+	if m.router != nil {
+		m.router.Forget(ctx, name)
+	}
+}
@@ -0,0 +1,130 @@
+package puck
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRouter records what the manager routes. DestroyAll forgets pucks
+// from several goroutines at once.
+type fakeRouter struct {
+	mu        sync.Mutex
+	routes    map[string]int
+	forgotten []string
+}
+
+func (r *fakeRouter) Route(ctx context.Context, p *store.Puck) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes[p.Name] = p.HostPort
+}
+
+func (r *fakeRouter) Unroute(ctx context.Context, name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.routes, name)
+}
+
+func (r *fakeRouter) Forget(ctx context.Context, name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.routes, name)
+	r.forgotten = append(r.forgotten, name)
+}
+
+// forgot returns the pucks forgotten so far
+func (r *fakeRouter) forgot() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.forgotten)
+}
+
+// routed returns a copy of the routes
+func (r *fakeRouter) routed() map[string]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return maps.Clone(r.routes)
+}
+
+func TestManagerRoutes(t *testing.T) {
+	ctx := context.Background()
+
+	setup := func(t *testing.T, names ...string) (*Manager, *fakeRouter, func()) {
+		mgr, mock, cleanup := setupTestManager(t)
+		mock.CheckpointFunc = func(ctx context.Context, nameOrID string, opts podman.CheckpointOptions) error {
+			return writeTar(opts.ExportPath, map[string]string{"checkpoint/inventory.img": "inv"})
+		}
+		router := &fakeRouter{routes: make(map[string]int)}
+		mgr.SetRouter(router)
+		for _, name := range names {
+			_, err := mgr.Create(ctx, CreateOptions{Name: name})
+			require.NoError(t, err)
+		}
+		return mgr, router, cleanup
+	}
+
+	t.Run("follows start and stop", func(t *testing.T) {
+		mgr, router, cleanup := setup(t, "web")
+		defer cleanup()
+
+		require.NoError(t, mgr.Stop(ctx, "web"))
+		assert.NotContains(t, router.routed(), "web")
+
+		require.NoError(t, mgr.Start(ctx, "web"))
+		p, err := mgr.Get(ctx, "web")
+		require.NoError(t, err)
+		assert.Equal(t, p.HostPort, router.routed()["web"])
+
+		require.NoError(t, mgr.Kill(ctx, "web", ""))
+		assert.NotContains(t, router.routed(), "web")
+	})
+
+	t.Run("unroutes checkpointed pucks and routes restored ones", func(t *testing.T) {
+		mgr, router, cleanup := setup(t, "web")
+		defer cleanup()
+		require.NoError(t, mgr.Start(ctx, "web"))
+
+		stop := false
+		_, err := mgr.CreateSnapshot(ctx, SnapshotCreateOptions{PuckName: "web", SnapshotName: "snap", LeaveRunning: &stop})
+		require.NoError(t, err)
+		assert.NotContains(t, router.routed(), "web")
+
+		require.NoError(t, mgr.RestoreSnapshot(ctx, SnapshotRestoreOptions{PuckName: "web", SnapshotName: "snap"}))
+		assert.Contains(t, router.routed(), "web")
+	})
+
+	t.Run("keeps the route of a puck suspended to stay within the budget", func(t *testing.T) {
+		mgr, router, cleanup := setup(t, "web")
+		defer cleanup()
+		require.NoError(t, mgr.Start(ctx, "web"))
+		p, err := mgr.Get(ctx, "web")
+		require.NoError(t, err)
+
+		require.NoError(t, mgr.suspend(ctx, p))
+		assert.Contains(t, router.routed(), "web")
+	})
+
+	t.Run("forgets destroyed pucks on every path", func(t *testing.T) {
+		mgr, router, cleanup := setup(t, "web", "api", "db")
+		defer cleanup()
+		for _, name := range []string{"web", "api", "db"} {
+			require.NoError(t, mgr.Start(ctx, name))
+		}
+
+		require.NoError(t, mgr.Destroy(ctx, "web", true))
+		results, err := mgr.DestroyAll(ctx, true)
+		require.NoError(t, err)
+		assert.Len(t, results, 2)
+
+		assert.Empty(t, router.routed())
+		assert.ElementsMatch(t, []string{"web", "api", "db"}, router.forgot())
+	})
+}
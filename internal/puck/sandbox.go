@@ -0,0 +1,65 @@
+package puck
+
+import (
+	"fmt"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// Caps a strict sandbox gets on creation; puck set can change the memory
+// and CPU limits afterwards
+const (
+	SandboxMemory = 1 << 30 // 1 GiB
+	SandboxCPUs   = 1.0
+	SandboxPids   = 512
+)
+
+// prepareSandbox checks that a puck's other settings fit its sandbox
+// profile and fills in what the profile implies
+func prepareSandbox(spec *store.Spec, opts CreateOptions) error {
+	switch spec.Sandbox {
+	case "":
+		return nil
+	case store.SandboxStrict:
+	default:
+		return fmt.Errorf("unknown sandbox profile %q; use %s", spec.Sandbox, store.SandboxStrict)
+	}
+
+	// systemd needs capabilities and a writable root the sandbox takes away
+	switch spec.Init {
+	case "":
+		spec.Init = store.InitTini
+	case store.InitSystemd:
+		return fmt.Errorf("--sandbox %s can't run systemd; use --init tini or none", spec.Sandbox)
+	}
+	if len(opts.Ports) > 0 || opts.PublishAll {
+		return fmt.Errorf("--sandbox %s pucks have no network, so ports can't be published", spec.Sandbox)
+	}
+	if spec.Seccomp == store.SeccompUnconfined || spec.AppArmor == store.SeccompUnconfined {
+		return fmt.Errorf("--sandbox %s can't be combined with unconfined security profiles", spec.Sandbox)
+	}
+	if len(spec.Sysctls) > 0 || spec.UserNS != "" || len(spec.UIDMap) > 0 || len(spec.GIDMap) > 0 {
+		return fmt.Errorf("--sandbox %s can't be combined with sysctls or user namespace settings", spec.Sandbox)
+	}
+	return nil
+}
+
+// sandboxed reports whether a puck runs without a network
+func sandboxed(p *store.Puck) bool {
+	return p.Spec.Sandbox == store.SandboxStrict
+}
+
+// applySandbox adds a puck's sandbox restrictions to its container options
+func applySandbox(p *store.Puck, opts *podman.CreateContainerOptions) {
+	if !sandboxed(p) {
+		return
+	}
+	opts.NoNewPrivileges = true
+	opts.ReadOnlyRoot = true
+	opts.CapDrop = []string{"ALL"}
+	opts.NoNetwork = true
+	opts.PidsLimit = SandboxPids
+	opts.DNS = nil
+	opts.AddHosts = nil
+}
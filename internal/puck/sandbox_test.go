@@ -0,0 +1,84 @@
+package puck
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSandbox(t *testing.T) {
+	t.Run("strict locks the container down", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		mgr.cfg.SharedPaths = []config.SharedPath{{Path: t.TempDir()}}
+		var created []podman.CreateContainerOptions
+		mock.CreateContainerFunc = func(ctx context.Context, opts podman.CreateContainerOptions) (string, error) {
+			created = append(created, opts)
+			return "container-" + opts.Name, nil
+		}
+
+		p, err := mgr.Create(ctx, CreateOptions{Name: "untrusted", Sandbox: store.SandboxStrict})
+		require.NoError(t, err)
+		assert.Equal(t, store.InitTini, p.Spec.Init)
+		assert.Zero(t, p.HostPort)
+		assert.Empty(t, p.Spec.Mounts)
+		assert.Equal(t, store.Resources{Memory: SandboxMemory, CPUs: SandboxCPUs}, p.Resources)
+
+		// Recreating keeps the restrictions
+		_, err = mgr.Recreate(ctx, RecreateOptions{Name: "untrusted", NoSnapshot: true})
+		require.NoError(t, err)
+
+		require.Len(t, created, 2)
+		for _, opts := range created {
+			assert.True(t, opts.NoNewPrivileges)
+			assert.True(t, opts.ReadOnlyRoot)
+			assert.True(t, opts.NoNetwork)
+			assert.Equal(t, []string{"ALL"}, opts.CapDrop)
+			assert.EqualValues(t, SandboxPids, opts.PidsLimit)
+			assert.Empty(t, opts.Ports)
+			assert.Equal(t, int64(SandboxMemory), opts.Resources.Memory)
+		}
+	})
+
+	t.Run("leaves other pucks alone", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		var created podman.CreateContainerOptions
+		mock.CreateContainerFunc = func(ctx context.Context, opts podman.CreateContainerOptions) (string, error) {
+			created = opts
+			return "container-" + opts.Name, nil
+		}
+		_, err := mgr.Create(ctx, CreateOptions{Name: "dev"})
+		require.NoError(t, err)
+		assert.False(t, created.NoNetwork)
+		assert.False(t, created.ReadOnlyRoot)
+		assert.Empty(t, created.CapDrop)
+		assert.NotEmpty(t, created.Ports)
+	})
+
+	t.Run("rejects settings strict can't honor", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		for _, opts := range []CreateOptions{
+			{Name: "a", Sandbox: "loose"},
+			{Name: "b", Sandbox: store.SandboxStrict, Init: store.InitSystemd},
+			{Name: "c", Sandbox: store.SandboxStrict, Ports: []string{"8080:80"}},
+			{Name: "d", Sandbox: store.SandboxStrict, Seccomp: store.SeccompUnconfined},
+			{Name: "e", Sandbox: store.SandboxStrict, UserNS: "keep-id"},
+		} {
+			_, err := mgr.Create(ctx, opts)
+			assert.Error(t, err, opts.Name)
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package puck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/sandwich-labs/puck/internal/scan"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// Scan scans a puck's image, or an image named by target when no puck
+// is, for known vulnerabilities
+func (m *Manager) Scan(ctx context.Context, target string) (*scan.Result, error) {
+	image := target
+	p, err := m.store.GetPuck(ctx, target)
+	if err == nil {
+		image = p.Image
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return nil, err
+	}
+	return m.scanImage(ctx, image)
+}
+
+// scanImage scans an image with the configured scanner, pulling it first
+// if it isn't in local storage
+func (m *Manager) scanImage(ctx context.Context, image string) (*scan.Result, error) {
+	if err := m.ensureImage(ctx, image); err != nil {
+		return nil, err
+	}
+	return m.scanner(ctx, image, scan.Options{Scanner: m.cfg.Scanner, ContainerImage: m.cfg.ScanImage})
+}
+
+// ensureImage pulls an image that isn't in local storage
+func (m *Manager) ensureImage(ctx context.Context, image string) error {
+	exists, err := m.podman.ImageExists(ctx, image)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if err := m.podman.PullImage(ctx, image); err != nil {
+			return fmt.Errorf("pulling %s: %w", image, err)
+		}
+	}
+	return nil
+}
+
+// checkImageScan scans a new puck's image when scan_on_create is set.
+// Findings at scan_severity or worse refuse the create, or with warn
+// return a warning for the puck's creator, as does a failed scan.
+func (m *Manager) checkImageScan(ctx context.Context, name, image string) (string, error) {
+	if m.cfg.ScanOnCreate == "" {
+		return "", nil
+	}
+
+	result, err := m.scanImage(ctx, image)
+	if err != nil {
+		if m.cfg.ScanOnCreate == config.ScanRefuse {
+			return "", fmt.Errorf("scanning image %s, as scan_on_create requires: %w", image, err)
+		}
+		return fmt.Sprintf("image %s could not be scanned: %v", image, err), nil
+	}
+	if result.AtLeast(m.cfg.ScanSeverity) == 0 {
+		return "", nil
+	}
+	if m.cfg.ScanOnCreate == config.ScanRefuse {
+		return "", fmt.Errorf("image %s has %s severity vulnerabilities or worse (%s); see 'puck scan %s', or use a patched image", image, m.cfg.ScanSeverity, result.Summary(), image)
+	}
+	return fmt.Sprintf("image %s has known vulnerabilities (%s); see 'puck scan %s'", image, result.Summary(), name), nil
+}
@@ -0,0 +1,125 @@
+package puck
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/scan"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeScanner finds a critical and a high vulnerability in images named
+// "old" and nothing elsewhere, recording what it scanned
+func fakeScanner(scanned *[]string) func(ctx context.Context, image string, opts scan.Options) (*scan.Result, error) {
+	return func(ctx context.Context, image string, opts scan.Options) (*scan.Result, error) {
+		*scanned = append(*scanned, image)
+		r := &scan.Result{Image: image, Scanner: scan.Trivy}
+		if image == "old" {
+			r.Findings = []scan.Finding{
+				{ID: "CVE-2024-0001", Package: "glibc", Severity: scan.Critical},
+				{ID: "CVE-2024-0002", Package: "openssl", Severity: scan.High},
+			}
+		}
+		return r, nil
+	}
+}
+
+func TestScan(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("scans a puck's image", func(t *testing.T) {
+		mgr, _, cleanup := setupTestManager(t)
+		defer cleanup()
+		var scanned []string
+		mgr.scanner = fakeScanner(&scanned)
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "web", Image: "old"})
+		require.NoError(t, err)
+
+		result, err := mgr.Scan(ctx, "web")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"old"}, scanned)
+		assert.Len(t, result.Findings, 2)
+	})
+
+	t.Run("scans an image, pulling it first", func(t *testing.T) {
+		mgr, mock, cleanup := setupTestManager(t)
+		defer cleanup()
+		var scanned []string
+		mgr.scanner = fakeScanner(&scanned)
+		mock.ImageExistsFunc = func(ctx context.Context, ref string) (bool, error) { return false, nil }
+
+		_, err := mgr.Scan(ctx, "alpine:3")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"alpine:3"}, scanned)
+		assert.Equal(t, 1, mock.CallCount("PullImage"))
+	})
+}
+
+func TestCreateScanPolicy(t *testing.T) {
+	ctx := context.Background()
+	setup := func(t *testing.T, policy, severity string) (*Manager, *podman.MockClient, *[]string, func()) {
+		mgr, mock, cleanup := setupTestManager(t)
+		mgr.cfg.ScanOnCreate = policy
+		mgr.cfg.ScanSeverity = severity
+		var scanned []string
+		mgr.scanner = fakeScanner(&scanned)
+		return mgr, mock, &scanned, cleanup
+	}
+
+	t.Run("doesn't scan by default", func(t *testing.T) {
+		mgr, _, scanned, cleanup := setup(t, "", "critical")
+		defer cleanup()
+
+		p, err := mgr.Create(ctx, CreateOptions{Name: "web", Image: "old"})
+		require.NoError(t, err)
+		assert.Empty(t, p.Warnings)
+		assert.Empty(t, *scanned)
+	})
+
+	t.Run("warns of vulnerable images", func(t *testing.T) {
+		mgr, _, _, cleanup := setup(t, config.ScanWarn, "critical")
+		defer cleanup()
+
+		p, err := mgr.Create(ctx, CreateOptions{Name: "web", Image: "old"})
+		require.NoError(t, err)
+		require.Len(t, p.Warnings, 1)
+		assert.Contains(t, p.Warnings[0], "1 critical, 1 high")
+
+		p, err = mgr.Create(ctx, CreateOptions{Name: "api", Image: "fresh"})
+		require.NoError(t, err)
+		assert.Empty(t, p.Warnings)
+	})
+
+	t.Run("refuses vulnerable images", func(t *testing.T) {
+		mgr, mock, _, cleanup := setup(t, config.ScanRefuse, "high")
+		defer cleanup()
+
+		_, err := mgr.Create(ctx, CreateOptions{Name: "web", Image: "old"})
+		assert.ErrorContains(t, err, "high severity vulnerabilities or worse")
+		assert.False(t, mock.WasCalled("CreateContainer"))
+		_, err = mgr.store.GetPuck(ctx, "web")
+		assert.ErrorIs(t, err, store.ErrNotFound)
+	})
+
+	t.Run("warns when the scan fails", func(t *testing.T) {
+		mgr, _, _, cleanup := setup(t, config.ScanWarn, "critical")
+		defer cleanup()
+		mgr.scanner = func(ctx context.Context, image string, opts scan.Options) (*scan.Result, error) {
+			return nil, assert.AnError
+		}
+
+		p, err := mgr.Create(ctx, CreateOptions{Name: "web", Image: "old"})
+		require.NoError(t, err)
+		require.Len(t, p.Warnings, 1)
+		assert.Contains(t, p.Warnings[0], "could not be scanned")
+
+		mgr.cfg.ScanOnCreate = config.ScanRefuse
+		_, err = mgr.Create(ctx, CreateOptions{Name: "api", Image: "old"})
+		assert.ErrorIs(t, err, assert.AnError)
+	})
+}
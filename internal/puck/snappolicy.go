@@ -0,0 +1,228 @@
+package puck
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/containers/storage/pkg/archive"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// Snapshot archive compressions. By default checkpoints are kept as Podman
+// exports them, uncompressed, and volume archives are gzipped.
+const (
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+	CompressionNone = "none"
+)
+
+// archiveCompressions maps snapshot policy compressions to the ones
+// volume archives are written with
+var archiveCompressions = map[string]archive.Compression{
+	"":              archive.Gzip,
+	CompressionGzip: archive.Gzip,
+	CompressionZstd: archive.Zstd,
+	CompressionNone: archive.Uncompressed,
+}
+
+// scheduledSnapshotPrefix starts the names of snapshots taken on a puck's
+// schedule, which are the ones its retention applies to
+const scheduledSnapshotPrefix = "scheduled-"
+
+// minSnapshotSchedule is the shortest interval snapshots can be scheduled
+// at
+const minSnapshotSchedule = 5 * time.Minute
+
+// SnapshotPolicyOptions changes a puck's snapshot policy; nil fields are
+// left as they are
+type SnapshotPolicyOptions struct {
+	Name           string  `json:"name"`
+	LeaveRunning   *bool   `json:"leave_running,omitempty"`
+	Compression    *string `json:"compression,omitempty"`
+	IncludeVolumes *bool   `json:"include_volumes,omitempty"`
+	Schedule       *string `json:"schedule,omitempty"`
+	Retain         *int    `json:"retain,omitempty"`
+}
+
+// SetSnapshotPolicy changes a puck's snapshot defaults and schedule
+func (m *Manager) SetSnapshotPolicy(ctx context.Context, opts SnapshotPolicyOptions) (*store.Puck, error) {
+	p, err := m.store.GetPuck(ctx, opts.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := p.SnapshotPolicy
+	if opts.LeaveRunning != nil {
+		policy.LeaveRunning = *opts.LeaveRunning
+	}
+	if opts.Compression != nil {
+		policy.Compression = *opts.Compression
+	}
+	if opts.IncludeVolumes != nil {
+		policy.IncludeVolumes = *opts.IncludeVolumes
+	}
+	if opts.Schedule != nil {
+		policy.Schedule = *opts.Schedule
+	}
+	if opts.Retain != nil {
+		policy.Retain = *opts.Retain
+	}
+	if err := validateSnapshotPolicy(policy); err != nil {
+		return nil, err
+	}
+
+	if err := m.store.UpdatePuckSnapshotPolicy(ctx, p.Name, policy); err != nil {
+		return nil, err
+	}
+	return m.store.GetPuck(ctx, p.Name)
+}
+
+// validateSnapshotPolicy checks a policy before it is saved
+func validateSnapshotPolicy(policy store.SnapshotPolicy) error {
+	if _, ok := archiveCompressions[policy.Compression]; !ok {
+		return fmt.Errorf("unknown compression %q (expected gzip, zstd or none)", policy.Compression)
+	}
+	if policy.Schedule != "" {
+		interval, err := time.ParseDuration(policy.Schedule)
+		if err != nil {
+			return fmt.Errorf("invalid schedule %q: expected an interval such as 6h", policy.Schedule)
+		}
+		if interval < minSnapshotSchedule {
+			return fmt.Errorf("schedule must be at least %s, got %s", minSnapshotSchedule, policy.Schedule)
+		}
+	}
+	if policy.Retain < 0 {
+		return fmt.Errorf("retain must not be negative, got %d", policy.Retain)
+	}
+	return nil
+}
+
+// recompressArchive rewrites an archive with the given compression,
+// returning its new path
+func recompressArchive(ctx context.Context, puckName, path string, compression archive.Compression) (string, error) {
+	dest := strings.TrimSuffix(path, ".tar.gz") + "." + compression.Extension()
+	tmp := dest + ".tmp"
+
+	in, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	rc, err := archive.DecompressStream(in)
+	if err != nil {
+		return "", fmt.Errorf("decompressing checkpoint: %w", err)
+	}
+	defer rc.Close()
+
+	out, err := os.Create(tmp)
+	if err != nil {
+		return "", fmt.Errorf("recompressing checkpoint: %w", err)
+	}
+	cw, err := archive.CompressStream(out, compression)
+	if err == nil {
+		_, err = io.Copy(cw, &progressReader{ctx: ctx, r: rc, puck: puckName, phase: PhaseCompress})
+		if cerr := cw.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, dest)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("recompressing checkpoint: %w", err)
+	}
+
+	if dest != path {
+		os.Remove(path)
+	}
+	return dest, nil
+}
+
+// RunSnapshotSchedules snapshots the running pucks whose schedules are
+// due, then drops the scheduled snapshots past each one's retention.
+// Scheduled snapshots always leave the puck running.
+func (m *Manager) RunSnapshotSchedules(ctx context.Context) []SnapshotResult {
+	pucks, err := m.store.ListPucks(ctx)
+	if err != nil {
+		return []SnapshotResult{{Error: err.Error()}}
+	}
+
+	results := []SnapshotResult{}
+	now := time.Now()
+	for _, p := range pucks {
+		interval, err := time.ParseDuration(p.SnapshotPolicy.Schedule)
+		if err != nil || !p.Status.Up() {
+			continue
+		}
+		snapshots, err := m.store.ListSnapshots(ctx, p.ID)
+		if err != nil {
+			results = append(results, SnapshotResult{Puck: p.Name, Error: err.Error()})
+			continue
+		}
+		scheduled := scheduledSnapshots(snapshots)
+		if len(scheduled) > 0 && now.Sub(scheduled[0].CreatedAt) < interval {
+			continue
+		}
+
+		leaveRunning := true
+		snapshot, err := m.CreateSnapshot(ctx, SnapshotCreateOptions{
+			PuckName:     p.Name,
+			SnapshotName: scheduledSnapshotPrefix + now.UTC().Format("20060102-150405"),
+			LeaveRunning: &leaveRunning,
+		})
+		if err != nil {
+			results = append(results, SnapshotResult{Puck: p.Name, Error: err.Error()})
+			continue
+		}
+		results = append(results, SnapshotResult{Puck: p.Name, Snapshot: snapshot})
+
+		if err := m.pruneScheduled(ctx, p, append([]*store.Snapshot{snapshot}, scheduled...)); err != nil {
+			results = append(results, SnapshotResult{Puck: p.Name, Error: err.Error()})
+		}
+	}
+	return results
+}
+
+// scheduledSnapshots returns the snapshots taken on a schedule, newest
+// first
+func scheduledSnapshots(snapshots []*store.Snapshot) []*store.Snapshot {
+	var scheduled []*store.Snapshot
+	for _, s := range snapshots {
+		if strings.HasPrefix(s.Name, scheduledSnapshotPrefix) {
+			scheduled = append(scheduled, s)
+		}
+	}
+	sort.Slice(scheduled, func(i, j int) bool { return scheduled[i].CreatedAt.After(scheduled[j].CreatedAt) })
+	return scheduled
+}
+
+// pruneScheduled deletes a puck's scheduled snapshots, newest first, past
+// its retention. Tagged ones are kept, as someone wanted them.
+func (m *Manager) pruneScheduled(ctx context.Context, p *store.Puck, scheduled []*store.Snapshot) error {
+	if p.SnapshotPolicy.Retain == 0 {
+		return nil
+	}
+	kept := 0
+	for _, s := range scheduled {
+		if len(s.Tags) > 0 {
+			continue
+		}
+		if kept < p.SnapshotPolicy.Retain {
+			kept++
+			continue
+		}
+		if err := m.DeleteSnapshot(ctx, p.Name, s.Name); err != nil {
+			return fmt.Errorf("dropping scheduled snapshot '%s': %w", s.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package puck
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/containers/storage/pkg/archive"
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetSnapshotPolicy(t *testing.T) {
+	ctx := context.Background()
+	mgr, _, cleanup := setupTestManager(t)
+	defer cleanup()
+	_, err := mgr.Create(ctx, CreateOptions{Name: "policy-puck"})
+	require.NoError(t, err)
+
+	zstd, schedule, retain := CompressionZstd, "6h", 4
+	p, err := mgr.SetSnapshotPolicy(ctx, SnapshotPolicyOptions{Name: "policy-puck", Compression: &zstd, Schedule: &schedule, Retain: &retain})
+	require.NoError(t, err)
+	assert.Equal(t, store.SnapshotPolicy{Compression: "zstd", Schedule: "6h", Retain: 4}, p.SnapshotPolicy)
+
+	// Fields not given are left alone
+	p, err = mgr.SetSnapshotPolicy(ctx, SnapshotPolicyOptions{Name: "policy-puck", LeaveRunning: leaveRunning})
+	require.NoError(t, err)
+	assert.Equal(t, store.SnapshotPolicy{LeaveRunning: true, Compression: "zstd", Schedule: "6h", Retain: 4}, p.SnapshotPolicy)
+
+	for _, bad := range []SnapshotPolicyOptions{
+		{Compression: &[]string{"lz4"}[0]},
+		{Schedule: &[]string{"daily"}[0]},
+		{Schedule: &[]string{"1m"}[0]},
+		{Retain: &[]int{-1}[0]},
+	} {
+		bad.Name = "policy-puck"
+		_, err := mgr.SetSnapshotPolicy(ctx, bad)
+		assert.Error(t, err)
+	}
+	p, err = mgr.Get(ctx, "policy-puck")
+	require.NoError(t, err)
+	assert.Equal(t, "zstd", p.SnapshotPolicy.Compression)
+}
+
+func TestSnapshotPolicy(t *testing.T) {
+	ctx := context.Background()
+
+	// setup returns a manager with a running puck whose checkpoints are
+	// uncompressed tarballs, as podman exports them
+	setup := func(t *testing.T, policy store.SnapshotPolicy) (*Manager, *podman.MockClient, *store.Puck, func()) {
+		mgr, mock, cleanup := setupTestManager(t)
+		mock.CheckpointFunc = func(ctx context.Context, nameOrID string, opts podman.CheckpointOptions) error {
+			if err := os.MkdirAll(filepath.Dir(opts.ExportPath), 0755); err != nil {
+				return err
+			}
+			return writeTar(opts.ExportPath, map[string]string{"checkpoint/inventory.img": "inv"})
+		}
+		_, err := mgr.Create(ctx, CreateOptions{Name: "policy-puck"})
+		require.NoError(t, err)
+		require.NoError(t, mgr.store.UpdatePuckSnapshotPolicy(ctx, "policy-puck", policy))
+		p, err := mgr.Get(ctx, "policy-puck")
+		require.NoError(t, err)
+		return mgr, mock, p, cleanup
+	}
+
+	// lastCheckpoint records the options of the puck's latest checkpoint
+	lastCheckpoint := func(mock *podman.MockClient) *podman.CheckpointOptions {
+		var last podman.CheckpointOptions
+		checkpoint := mock.CheckpointFunc
+		mock.CheckpointFunc = func(ctx context.Context, nameOrID string, opts podman.CheckpointOptions) error {
+			last = opts
+			return checkpoint(ctx, nameOrID, opts)
+		}
+		return &last
+	}
+
+	compressionOf := func(t *testing.T, path string) archive.Compression {
+		f, err := os.Open(path)
+		require.NoError(t, err)
+		defer f.Close()
+		magic, _ := bufio.NewReader(f).Peek(10)
+		return archive.DetectCompression(magic)
+	}
+
+	t.Run("leaves the puck running by default", func(t *testing.T) {
+		mgr, mock, _, cleanup := setup(t, store.SnapshotPolicy{LeaveRunning: true})
+		defer cleanup()
+		last := lastCheckpoint(mock)
+
+		_, err := mgr.CreateSnapshot(ctx, SnapshotCreateOptions{PuckName: "policy-puck", SnapshotName: "snap"})
+		require.NoError(t, err)
+		assert.True(t, last.LeaveRunning)
+
+		// An explicit option still wins
+		stop := false
+		_, err = mgr.CreateSnapshot(ctx, SnapshotCreateOptions{PuckName: "policy-puck", SnapshotName: "stopped", LeaveRunning: &stop})
+		require.NoError(t, err)
+		p, err := mgr.Get(ctx, "policy-puck")
+		require.NoError(t, err)
+		assert.Equal(t, store.StatusCheckpointed, p.Status)
+	})
+
+	t.Run("recompresses checkpoints", func(t *testing.T) {
+		mgr, mock, _, cleanup := setup(t, store.SnapshotPolicy{Compression: CompressionZstd})
+		defer cleanup()
+
+		s, err := mgr.CreateSnapshot(ctx, SnapshotCreateOptions{PuckName: "policy-puck", SnapshotName: "snap", LeaveRunning: leaveRunning})
+		require.NoError(t, err)
+		assert.True(t, strings.HasSuffix(s.Path, ".tar.zst"))
+		assert.Equal(t, archive.Zstd, compressionOf(t, s.Path))
+		assert.NoFileExists(t, strings.TrimSuffix(s.Path, ".tar.zst")+".tar.gz")
+
+		info, err := mgr.InspectSnapshot(ctx, SnapshotInspectOptions{PuckName: "policy-puck", SnapshotName: "snap"})
+		require.NoError(t, err)
+		assert.Equal(t, 1, info.CheckpointImages)
+
+		var imported string
+		mock.RestoreFunc = func(ctx context.Context, opts podman.RestoreOptions) (string, error) {
+			imported = opts.ImportPath
+			return "restored-id", nil
+		}
+		require.NoError(t, mgr.RestoreSnapshot(ctx, SnapshotRestoreOptions{PuckName: "policy-puck", SnapshotName: "snap"}))
+		assert.Equal(t, s.Path, imported)
+	})
+
+	t.Run("archives and restores volumes with checkpoints", func(t *testing.T) {
+		mgr, _, p, cleanup := setup(t, store.SnapshotPolicy{IncludeVolumes: true})
+		defer cleanup()
+		notes := filepath.Join(p.VolumeDir, "home", "notes.txt")
+		require.NoError(t, os.WriteFile(notes, []byte("before"), 0644))
+
+		s, err := mgr.CreateSnapshot(ctx, SnapshotCreateOptions{PuckName: "policy-puck", SnapshotName: "snap", LeaveRunning: leaveRunning})
+		require.NoError(t, err)
+		require.NotEmpty(t, s.VolumesPath)
+		assert.Equal(t, archive.Gzip, compressionOf(t, s.VolumesPath))
+
+		info, err := mgr.InspectSnapshot(ctx, SnapshotInspectOptions{PuckName: "policy-puck", SnapshotName: "snap"})
+		require.NoError(t, err)
+		assert.Contains(t, info.Volumes, SnapshotVolume{Name: "home", Size: int64(len("before"))})
+
+		require.NoError(t, os.WriteFile(notes, []byte("after"), 0644))
+		require.NoError(t, mgr.RestoreSnapshot(ctx, SnapshotRestoreOptions{PuckName: "policy-puck", SnapshotName: "snap"}))
+		data, err := os.ReadFile(notes)
+		require.NoError(t, err)
+		assert.Equal(t, "before", string(data))
+
+		require.NoError(t, mgr.DeleteSnapshot(ctx, "policy-puck", "snap"))
+		assert.NoFileExists(t, s.VolumesPath)
+	})
+
+	t.Run("takes scheduled snapshots when due", func(t *testing.T) {
+		mgr, mock, _, cleanup := setup(t, store.SnapshotPolicy{Schedule: "1h"})
+		defer cleanup()
+		last := lastCheckpoint(mock)
+
+		results := mgr.RunSnapshotSchedules(ctx)
+		require.Len(t, results, 1)
+		require.Empty(t, results[0].Error)
+		assert.True(t, strings.HasPrefix(results[0].Snapshot.Name, scheduledSnapshotPrefix))
+		assert.True(t, last.LeaveRunning)
+
+		// Not due again for an hour
+		assert.Empty(t, mgr.RunSnapshotSchedules(ctx))
+	})
+
+	t.Run("drops scheduled snapshots past retention", func(t *testing.T) {
+		mgr, _, p, cleanup := setup(t, store.SnapshotPolicy{Schedule: "1h", Retain: 1})
+		defer cleanup()
+
+		var taken []*store.Snapshot
+		for i, name := range []string{"scheduled-1", "scheduled-2", "scheduled-3", "manual"} {
+			s, err := mgr.CreateSnapshot(ctx, SnapshotCreateOptions{PuckName: "policy-puck", SnapshotName: name, LeaveRunning: leaveRunning})
+			require.NoError(t, err)
+			s.CreatedAt = time.Now().Add(time.Duration(i) * time.Minute)
+			taken = append(taken, s)
+		}
+		_, err := mgr.TagSnapshot(ctx, "policy-puck", "scheduled-1", "keep")
+		require.NoError(t, err)
+		taken[0].Tags = []string{"keep"}
+
+		require.NoError(t, mgr.pruneScheduled(ctx, p, scheduledSnapshots(taken)))
+
+		snapshots, err := mgr.ListSnapshots(ctx, "policy-puck")
+		require.NoError(t, err)
+		var names []string
+		for _, s := range snapshots {
+			names = append(names, s.Name)
+		}
+		assert.ElementsMatch(t, []string{"scheduled-1", "scheduled-3", "manual"}, names)
+	})
+}
@@ -0,0 +1,115 @@
+package puck
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// hostnamePattern matches RFC 1123 hostnames
+var hostnamePattern = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$`)
+
+// sysctlPattern matches kernel parameter names like net.ipv4.ip_forward
+var sysctlPattern = regexp.MustCompile(`^[a-z0-9_]+(\.[a-zA-Z0-9_-]+)+$`)
+
+// apparmorPattern matches AppArmor profile names
+var apparmorPattern = regexp.MustCompile(`^[a-zA-Z0-9_./-]+$`)
+
+// hostGateway is podman's stand-in for the host's address in --add-host
+const hostGateway = "host-gateway"
+
+// validateSpec rejects container settings podman would refuse, before
+// anything is created
+func validateSpec(spec store.Spec) error {
+	switch spec.Init {
+	case "", store.InitSystemd, store.InitTini, store.InitNone:
+	default:
+		return fmt.Errorf("unknown init mode %q; use systemd, tini, or none", spec.Init)
+	}
+
+	if spec.Hostname != "" && (len(spec.Hostname) > 253 || !hostnamePattern.MatchString(spec.Hostname)) {
+		return fmt.Errorf("invalid hostname %q", spec.Hostname)
+	}
+	for _, server := range spec.DNS {
+		if net.ParseIP(server) == nil {
+			return fmt.Errorf("invalid DNS server %q; use an IP address", server)
+		}
+	}
+	for _, entry := range spec.AddHosts {
+		host, ip, ok := strings.Cut(entry, ":")
+		if !ok || !hostnamePattern.MatchString(host) || (ip != hostGateway && net.ParseIP(ip) == nil) {
+			return fmt.Errorf("invalid host entry %q; use host:ip", entry)
+		}
+	}
+
+	for key, value := range spec.Sysctls {
+		if !sysctlPattern.MatchString(key) || value == "" {
+			return fmt.Errorf("invalid sysctl %s=%s", key, value)
+		}
+	}
+	for key, value := range spec.Env {
+		if err := validateEnv(key, value); err != nil {
+			return err
+		}
+	}
+	for _, u := range spec.Ulimits {
+		if _, err := podman.ParseUlimit(u); err != nil {
+			return err
+		}
+	}
+
+	if err := podman.ValidateUserNSMode(spec.UserNS); err != nil {
+		return err
+	}
+	if spec.UserNS != "" && (len(spec.UIDMap) > 0 || len(spec.GIDMap) > 0) {
+		return fmt.Errorf("--userns can't be combined with --uidmap or --gidmap")
+	}
+	for _, m := range append(append([]string{}, spec.UIDMap...), spec.GIDMap...) {
+		if _, err := podman.ParseIDMap(m); err != nil {
+			return err
+		}
+	}
+
+	// Podman reads the seccomp profile on this host when creating the
+	// container, including on every recreate
+	if spec.Seccomp != "" && spec.Seccomp != store.SeccompUnconfined {
+		if !filepath.IsAbs(spec.Seccomp) {
+			return fmt.Errorf("seccomp profile must be an absolute path or unconfined, got %q", spec.Seccomp)
+		}
+		if _, err := os.Stat(spec.Seccomp); err != nil {
+			return fmt.Errorf("seccomp profile: %w", err)
+		}
+	}
+	if spec.AppArmor != "" && !apparmorPattern.MatchString(spec.AppArmor) {
+		return fmt.Errorf("invalid AppArmor profile %q", spec.AppArmor)
+	}
+
+	if spec.StopTimeout != nil {
+		if err := validateStopTimeout(*spec.StopTimeout); err != nil {
+			return err
+		}
+	}
+
+	if spec.Shell != "" && !strings.HasPrefix(spec.Shell, "/") {
+		return fmt.Errorf("shell must be an absolute path, got %q", spec.Shell)
+	}
+	if strings.ContainsAny(spec.User, " \t\n") {
+		return fmt.Errorf("invalid user %q", spec.User)
+	}
+	return nil
+}
+
+// validateStopTimeout checks a stop timeout, in seconds
+func validateStopTimeout(seconds int) error {
+	if seconds < 0 || seconds > config.MaxStopTimeout {
+		return fmt.Errorf("stop timeout must be between 0 and %d seconds, got %d", config.MaxStopTimeout, seconds)
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package puck
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// StackSnapshotOptions contains options for snapshotting a puck together
+// with the pucks it requires
+type StackSnapshotOptions struct {
+	PuckName     string `json:"puck_name"`
+	SnapshotName string `json:"snapshot_name"`
+	// Checkpoint mode only; nil uses each puck's snapshot policy
+	LeaveRunning *bool              `json:"leave_running,omitempty"`
+	Mode         store.SnapshotMode `json:"mode,omitempty"` // empty uses the configured default
+	CRIUFlags
+}
+
+// StackSnapshotResult is how snapshotting a stack went. Stack is only set
+// when every member was snapshotted.
+type StackSnapshotResult struct {
+	Stack   *store.StackSnapshot `json:"stack,omitempty"`
+	Members []SnapshotResult     `json:"members"`
+}
+
+// StackRestoreOptions contains options for restoring a stack snapshot
+type StackRestoreOptions struct {
+	PuckName     string `json:"puck_name"`
+	SnapshotName string `json:"snapshot_name"`
+	// Restore checkpoints even if this host may not be able to
+	Force bool `json:"force,omitempty"`
+	// Pull checkpoints' images by digest first if the host lacks them
+	Repull bool `json:"repull,omitempty"`
+}
+
+// StackMembers returns a puck and every puck it requires, in start order:
+// requirements first, the puck itself last
+func (m *Manager) StackMembers(ctx context.Context, name string) ([]*store.Puck, error) {
+	if _, err := m.store.GetPuck(ctx, name); err != nil {
+		return nil, err
+	}
+	return m.requirementOrder(ctx, []string{name})
+}
+
+// CreateStackSnapshot snapshots a puck and the pucks it requires under
+// the same name, all at once so they are captured close together. The
+// snapshots are grouped as a stack snapshot only if every member
+// succeeded; otherwise the ones taken are left as plain snapshots.
+func (m *Manager) CreateStackSnapshot(ctx context.Context, opts StackSnapshotOptions) (*StackSnapshotResult, error) {
+	if opts.SnapshotName == "" {
+		return nil, fmt.Errorf("snapshot name is required")
+	}
+	members, err := m.StackMembers(ctx, opts.PuckName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := m.store.GetStackSnapshot(ctx, opts.PuckName, opts.SnapshotName); err == nil {
+		return nil, fmt.Errorf("stack snapshot '%s' %w for puck '%s'", opts.SnapshotName, store.ErrExists, opts.PuckName)
+	}
+
+	// Check every member before snapshotting any, so a stack is never
+	// left half checkpointed by something knowable up front
+	var notRunning []string
+	for _, p := range members {
+		if p.Status != store.StatusRunning {
+			notRunning = append(notRunning, p.Name)
+			continue
+		}
+		if _, err := m.store.GetSnapshot(ctx, p.ID, opts.SnapshotName); err == nil {
+			return nil, fmt.Errorf("puck '%s' already has a snapshot named '%s'", p.Name, opts.SnapshotName)
+		}
+	}
+	if len(notRunning) > 0 {
+		return nil, fmt.Errorf("stack members not running: %s (start them with 'puck start %s')", strings.Join(notRunning, ", "), opts.PuckName)
+	}
+
+	result := &StackSnapshotResult{Members: make([]SnapshotResult, len(members))}
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i, p := range members {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			<-start
+
+			result.Members[i].Puck = name
+			snapshot, err := m.CreateSnapshot(ctx, SnapshotCreateOptions{
+				PuckName:     name,
+				SnapshotName: opts.SnapshotName,
+				LeaveRunning: opts.LeaveRunning,
+				Mode:         opts.Mode,
+				CRIUFlags:    opts.CRIUFlags,
+			})
+			if err != nil {
+				result.Members[i].Error = err.Error()
+				return
+			}
+			result.Members[i].Snapshot = snapshot
+		}(i, p.Name)
+	}
+	close(start)
+	wg.Wait()
+
+	stack := &store.StackSnapshot{
+		ID:        uuid.New().String(),
+		PuckName:  opts.PuckName,
+		Name:      opts.SnapshotName,
+		CreatedAt: time.Now(),
+	}
+	var failed []string
+	for _, r := range result.Members {
+		if r.Snapshot == nil {
+			failed = append(failed, r.Puck)
+			continue
+		}
+		stack.Members = append(stack.Members, store.StackMember{Puck: r.Puck, SnapshotID: r.Snapshot.ID})
+	}
+	if len(failed) > 0 {
+		return result, fmt.Errorf("could not snapshot %s; the stack snapshot was not recorded", strings.Join(failed, ", "))
+	}
+
+	if err := m.store.CreateStackSnapshot(ctx, stack); err != nil {
+		return result, err
+	}
+	result.Stack = stack
+	return result, nil
+}
+
+// ListStackSnapshots returns the stack snapshots taken from a puck
+func (m *Manager) ListStackSnapshots(ctx context.Context, name string) ([]*store.StackSnapshot, error) {
+	if _, err := m.store.GetPuck(ctx, name); err != nil {
+		return nil, err
+	}
+	return m.store.ListStackSnapshots(ctx, name)
+}
+
+// RestoreStackSnapshot restores every member of a stack snapshot. All
+// members are checked first, then the running ones are stopped,
+// dependents first, and each is restored in start order so a puck comes
+// back after the pucks it requires. It returns the members restored,
+// which on error are the ones restored before the failure.
+func (m *Manager) RestoreStackSnapshot(ctx context.Context, opts StackRestoreOptions) ([]string, error) {
+	stack, err := m.store.GetStackSnapshot(ctx, opts.PuckName, opts.SnapshotName)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := make([]*store.Snapshot, len(stack.Members))
+	var problems []string
+	for i, member := range stack.Members {
+		p, err := m.store.GetPuck(ctx, member.Puck)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: puck no longer exists", member.Puck))
+			continue
+		}
+		s, err := m.snapshotByID(ctx, p, member.SnapshotID)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: snapshot was deleted", member.Puck))
+			continue
+		}
+		if _, err := os.Stat(s.ArchivePath()); os.IsNotExist(err) {
+			problems = append(problems, fmt.Sprintf("%s: snapshot file not found: %s", member.Puck, s.ArchivePath()))
+			continue
+		}
+		if opts.Repull {
+			if err := m.repullImage(ctx, s); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", member.Puck, err))
+				continue
+			}
+		}
+		if !opts.Force {
+			for _, problem := range m.restoreProblems(ctx, s) {
+				problems = append(problems, fmt.Sprintf("%s: %s", member.Puck, problem))
+			}
+		}
+		snapshots[i] = s
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("stack snapshot '%s' can't be restored:\n  %s", stack.Name, strings.Join(problems, "\n  "))
+	}
+
+	// Quiesce the whole stack before restoring any of it
+	for _, member := range slices.Backward(stack.Members) {
+		p, err := m.store.GetPuck(ctx, member.Puck)
+		if err != nil || !p.Status.Up() {
+			continue
+		}
+		if err := m.Stop(ctx, p.Name); err != nil {
+			return nil, fmt.Errorf("stopping %s: %w", p.Name, err)
+		}
+	}
+
+	var restored []string
+	for i, member := range stack.Members {
+		err := m.RestoreSnapshot(ctx, SnapshotRestoreOptions{
+			PuckName:     member.Puck,
+			SnapshotName: snapshots[i].Name,
+			Force:        opts.Force,
+		})
+		if err != nil {
+			return restored, fmt.Errorf("restoring %s: %w", member.Puck, err)
+		}
+		restored = append(restored, member.Puck)
+	}
+	return restored, nil
+}
@@ -0,0 +1,151 @@
+package puck
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStackSnapshots(t *testing.T) {
+	// db <- api <- web; setup returns a manager with the three running
+	setup := func(t *testing.T) (*Manager, *podman.MockClient, func()) {
+		mgr, mock, cleanup := setupTestManager(t)
+		ctx := context.Background()
+		mock.IsRunningFunc = func(ctx context.Context, nameOrID string) (bool, error) {
+			return true, nil
+		}
+		for _, opts := range []CreateOptions{
+			{Name: "db"},
+			{Name: "api", Requires: []string{"db"}},
+			{Name: "web", Requires: []string{"api"}},
+		} {
+			_, err := mgr.Create(ctx, opts)
+			require.NoError(t, err)
+			require.NoError(t, mgr.MarkReady(ctx, opts.Name))
+		}
+		return mgr, mock, cleanup
+	}
+
+	t.Run("snapshots a puck with its requirements", func(t *testing.T) {
+		mgr, _, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		result, err := mgr.CreateStackSnapshot(ctx, StackSnapshotOptions{PuckName: "api", SnapshotName: "release", LeaveRunning: leaveRunning})
+		require.NoError(t, err)
+		require.NotNil(t, result.Stack)
+		require.Len(t, result.Stack.Members, 2)
+		assert.Equal(t, "db", result.Stack.Members[0].Puck)
+		assert.Equal(t, "api", result.Stack.Members[1].Puck)
+		assert.Equal(t, result.Members[0].Snapshot.ID, result.Stack.Members[0].SnapshotID)
+
+		stacks, err := mgr.ListStackSnapshots(ctx, "api")
+		require.NoError(t, err)
+		require.Len(t, stacks, 1)
+
+		// web isn't required by api, so it's left out
+		snapshots, err := mgr.ListSnapshots(ctx, "web")
+		require.NoError(t, err)
+		assert.Empty(t, snapshots)
+
+		_, err = mgr.CreateStackSnapshot(ctx, StackSnapshotOptions{PuckName: "api", SnapshotName: "release", LeaveRunning: leaveRunning})
+		assert.ErrorContains(t, err, "already exists")
+	})
+
+	t.Run("records nothing when a member fails", func(t *testing.T) {
+		mgr, mock, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		mock.CheckpointFunc = func(ctx context.Context, nameOrID string, opts podman.CheckpointOptions) error {
+			if strings.Contains(opts.ExportPath, "/db/") {
+				return errors.New("criu failed")
+			}
+			return nil
+		}
+
+		result, err := mgr.CreateStackSnapshot(ctx, StackSnapshotOptions{PuckName: "web", SnapshotName: "release", LeaveRunning: leaveRunning})
+		assert.ErrorContains(t, err, "could not snapshot db")
+		require.NotNil(t, result)
+		assert.Nil(t, result.Stack)
+		assert.Contains(t, result.Members[0].Error, "criu failed")
+
+		stacks, err := mgr.ListStackSnapshots(ctx, "web")
+		require.NoError(t, err)
+		assert.Empty(t, stacks)
+	})
+
+	t.Run("requires every member running", func(t *testing.T) {
+		mgr, _, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		require.NoError(t, mgr.store.UpdatePuckStatus(ctx, "db", store.StatusStopped))
+		_, err := mgr.CreateStackSnapshot(ctx, StackSnapshotOptions{PuckName: "web", SnapshotName: "release"})
+		assert.ErrorContains(t, err, "not running: db")
+	})
+
+	t.Run("restores members in start order", func(t *testing.T) {
+		mgr, mock, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.CreateStackSnapshot(ctx, StackSnapshotOptions{PuckName: "web", SnapshotName: "release", LeaveRunning: leaveRunning})
+		require.NoError(t, err)
+
+		mock.Calls = nil
+		restored, err := mgr.RestoreStackSnapshot(ctx, StackRestoreOptions{PuckName: "web", SnapshotName: "release"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"db", "api", "web"}, restored)
+
+		var restoreOrder []string
+		for _, call := range mock.Calls {
+			if call.Method == "Restore" {
+				restoreOrder = append(restoreOrder, call.Args[0].(podman.RestoreOptions).Name)
+			}
+		}
+		assert.Equal(t, []string{"db", "api", "web"}, restoreOrder)
+
+		for _, name := range restored {
+			p, err := mgr.Get(ctx, name)
+			require.NoError(t, err)
+			assert.Equal(t, store.StatusRunning, p.Status, name)
+		}
+	})
+
+	t.Run("checks every member before restoring any", func(t *testing.T) {
+		mgr, mock, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.CreateStackSnapshot(ctx, StackSnapshotOptions{PuckName: "web", SnapshotName: "release", LeaveRunning: leaveRunning})
+		require.NoError(t, err)
+		require.NoError(t, mgr.DeleteSnapshot(ctx, "api", "release"))
+
+		mock.Calls = nil
+		_, err = mgr.RestoreStackSnapshot(ctx, StackRestoreOptions{PuckName: "web", SnapshotName: "release"})
+		assert.ErrorContains(t, err, "api: snapshot was deleted")
+		assert.Zero(t, mock.CallCount("Restore"))
+		assert.Zero(t, mock.CallCount("StopContainer"))
+	})
+
+	t.Run("destroy drops the puck's stack snapshots", func(t *testing.T) {
+		mgr, _, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.CreateStackSnapshot(ctx, StackSnapshotOptions{PuckName: "web", SnapshotName: "release", LeaveRunning: leaveRunning})
+		require.NoError(t, err)
+		require.NoError(t, mgr.Destroy(ctx, "web", true))
+
+		stacks, err := mgr.store.ListStackSnapshots(ctx, "web")
+		require.NoError(t, err)
+		assert.Empty(t, stacks)
+	})
+}
@@ -0,0 +1,80 @@
+package puck
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// resumeSnapshotPrefix starts the names of the checkpoints suspended
+// pucks resume from, which are timestamped so they never take the name
+// of a snapshot the user made
+const resumeSnapshotPrefix = "resume-"
+
+// suspend checkpoints a running puck so that its next start resumes it.
+// Unlike a checkpoint snapshot, the checkpoint is the puck's resume image:
+// it is dropped once the puck runs again.
+func (m *Manager) suspend(ctx context.Context, p *store.Puck) error {
+	if !p.Status.Up() {
+		return fmt.Errorf("puck '%s' must be running to suspend it", p.Name)
+	}
+
+	// Its route stays, for the daemon to wake it on request
+	leaveRunning := false // whatever the puck's snapshot policy says
+	snapshot, err := m.createSnapshot(ctx, SnapshotCreateOptions{
+		PuckName:     p.Name,
+		SnapshotName: resumeSnapshotPrefix + time.Now().Format("20060102-150405.000"),
+		Mode:         store.SnapshotModeCheckpoint,
+		LeaveRunning: &leaveRunning,
+	})
+	if err != nil {
+		return err
+	}
+
+	// A resume image left by an earlier suspend is stale now
+	if err := m.dropResume(ctx, p.Name); err != nil {
+		return fmt.Errorf("removing previous resume image: %w", err)
+	}
+	if err := m.store.UpdatePuckResumeSnapshot(ctx, p.Name, snapshot.ID); err != nil {
+		return err
+	}
+	return m.store.UpdatePuckStatus(ctx, p.Name, store.StatusSuspended)
+}
+
+// resume restores a suspended puck from its resume image, reporting
+// false when it has none left, e.g. because its snapshot was deleted, so
+// that the puck boots instead
+func (m *Manager) resume(ctx context.Context, p *store.Puck) (bool, error) {
+	snapshot, err := m.snapshotByID(ctx, p, p.ResumeSnapshot)
+	if err == nil {
+		if _, statErr := os.Stat(snapshot.ArchivePath()); os.IsNotExist(statErr) {
+			err = statErr
+		}
+	}
+	if err != nil {
+		return false, m.dropResume(ctx, p.Name)
+	}
+
+	if err := m.restoreSnapshot(ctx, SnapshotRestoreOptions{PuckName: p.Name, SnapshotName: snapshot.Name}); err != nil {
+		return false, fmt.Errorf("resuming puck: %w ('puck stop %s' discards the suspended state so it boots instead)", err, p.Name)
+	}
+	return true, nil
+}
+
+// dropResume removes a puck's resume image, which is stale once the puck
+// runs again, whether resumed or not, or is stopped for good
+func (m *Manager) dropResume(ctx context.Context, name string) error {
+	p, err := m.store.GetPuck(ctx, name)
+	if err != nil || p.ResumeSnapshot == "" {
+		return err
+	}
+	snapshot, err := m.snapshotByID(ctx, p, p.ResumeSnapshot)
+	if err != nil {
+		// Deleted already; only the reference is left
+		return m.store.UpdatePuckResumeSnapshot(ctx, name, "")
+	}
+	return m.dropSnapshot(ctx, p, snapshot)
+}
@@ -0,0 +1,60 @@
+package puck
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/sandwich-labs/puck/internal/filesync"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// SyncMount is a mount whose source is copied into the puck's volume
+// rather than bind mounted
+type SyncMount struct {
+	Source string   `json:"source"`
+	Dest   string   `json:"dest"` // the copy, mounted at Target
+	Target string   `json:"target"`
+	Ignore []string `json:"ignore,omitempty"`
+}
+
+// SyncMounts returns a puck's synced mounts
+func SyncMounts(p *store.Puck) []SyncMount {
+	var mounts []SyncMount
+	for i, mnt := range p.Spec.Mounts {
+		if mnt.Sync {
+			mounts = append(mounts, SyncMount{
+				Source: mnt.Source,
+				Dest:   syncDir(p, i),
+				Target: mnt.Target,
+				Ignore: mnt.Ignore,
+			})
+		}
+	}
+	return mounts
+}
+
+// syncDir is where the copy of a puck's i'th mount is kept
+func syncDir(p *store.Puck, i int) string {
+	return filepath.Join(p.VolumeDir, "sync", strconv.Itoa(i))
+}
+
+// initialSync copies a new puck's synced mounts into its volume, so its
+// files are in place when it first starts. The daemon keeps them in step
+// from then on.
+func initialSync(p *store.Puck) error {
+	for _, sm := range SyncMounts(p) {
+		if err := os.MkdirAll(sm.Dest, 0755); err != nil {
+			return fmt.Errorf("creating sync directory: %w", err)
+		}
+		ignore, err := filesync.LoadIgnore(sm.Source, sm.Ignore)
+		if err != nil {
+			return fmt.Errorf("reading ignore rules for %s: %w", sm.Source, err)
+		}
+		if _, err := filesync.Mirror(sm.Source, sm.Dest, ignore); err != nil {
+			return fmt.Errorf("syncing %s: %w", sm.Source, err)
+		}
+	}
+	return nil
+}
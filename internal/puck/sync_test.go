@@ -0,0 +1,45 @@
+package puck
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncedMounts(t *testing.T) {
+	mgr, mock, cleanup := setupTestManager(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	src := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(src, "main.go"), []byte("package main"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "node_modules"), 0755))
+
+	var created []podman.CreateContainerOptions
+	mock.CreateContainerFunc = func(ctx context.Context, opts podman.CreateContainerOptions) (string, error) {
+		created = append(created, opts)
+		return "container-" + opts.Name, nil
+	}
+
+	p, err := mgr.Create(ctx, CreateOptions{Name: "web", Mounts: []store.Mount{
+		{Source: src, Target: "/workspace", Sync: true, Ignore: []string{"node_modules/"}},
+	}})
+	require.NoError(t, err)
+
+	mounts := SyncMounts(p)
+	require.Len(t, mounts, 1)
+	dest := filepath.Join(p.VolumeDir, "sync", "0")
+	assert.Equal(t, SyncMount{Source: src, Dest: dest, Target: "/workspace", Ignore: []string{"node_modules/"}}, mounts[0])
+
+	// The copy is in place before the puck starts, and mounted instead
+	assert.FileExists(t, filepath.Join(dest, "main.go"))
+	assert.NoDirExists(t, filepath.Join(dest, "node_modules"))
+	require.Len(t, created, 1)
+	assert.Equal(t, []podman.Mount{{Source: dest, Destination: "/workspace"}}, created[0].Mounts)
+}
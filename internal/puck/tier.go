@@ -0,0 +1,197 @@
+package puck
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// Snapshot storage tiers
+const (
+	TierHot  = "hot"  // in the data directory
+	TierCold = "cold" // moved to snapshot_tier_dir
+)
+
+// TierSnapshot is a snapshot archive and the tier it is kept in
+type TierSnapshot struct {
+	Puck      string    `json:"puck"`
+	Snapshot  string    `json:"snapshot"`
+	Tier      string    `json:"tier"`
+	Path      string    `json:"path"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+	// Old enough to be moved to cold storage by the next sweep
+	Due bool `json:"due,omitempty"`
+}
+
+// TierStatus describes the snapshot tiering policy and where each
+// snapshot's archive is kept
+type TierStatus struct {
+	Dir       string         `json:"dir,omitempty"` // empty when tiering is off
+	AfterDays int            `json:"after_days"`
+	Snapshots []TierSnapshot `json:"snapshots"`
+}
+
+// TierReport lists the snapshots a sweep moved to cold storage
+type TierReport struct {
+	Moved  []TierSnapshot `json:"moved"`
+	Errors []string       `json:"errors,omitempty"`
+}
+
+// TierStatus reports which snapshots are in which tier
+func (m *Manager) TierStatus(ctx context.Context) (*TierStatus, error) {
+	snapshots, err := m.store.ListAllSnapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resuming, err := m.resumeSnapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &TierStatus{
+		Dir:       m.cfg.SnapshotTierDir,
+		AfterDays: m.cfg.SnapshotTierAfter,
+		Snapshots: []TierSnapshot{},
+	}
+	cutoff := m.tierCutoff()
+	for _, s := range snapshots {
+		t := tierSnapshot(s)
+		t.Due = status.Dir != "" && s.ColdPath == "" && s.CreatedAt.Before(cutoff) && !resuming[s.ID] && !chunked(s)
+		status.Snapshots = append(status.Snapshots, t)
+	}
+	return status, nil
+}
+
+// TierSnapshots moves snapshot archives older than the tiering policy
+// allows to cold storage, keeping their records. Resume images stay, as
+// their pucks are waiting to be started from them, and so do chunked
+// checkpoints, whose chunks are shared with newer ones.
+func (m *Manager) TierSnapshots(ctx context.Context) (*TierReport, error) {
+	report := &TierReport{Moved: []TierSnapshot{}}
+	if m.cfg.SnapshotTierDir == "" {
+		return report, nil
+	}
+
+	snapshots, err := m.store.ListAllSnapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resuming, err := m.resumeSnapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := m.tierCutoff()
+	for _, s := range snapshots {
+		if s.ColdPath != "" || !s.CreatedAt.Before(cutoff) || resuming[s.ID] || chunked(s) {
+			continue
+		}
+		if err := m.coolSnapshot(ctx, s); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("moving snapshot '%s' of '%s': %v", s.Name, s.PuckName, err))
+			continue
+		}
+		report.Moved = append(report.Moved, tierSnapshot(s))
+	}
+	return report, nil
+}
+
+// tierCutoff is the creation time before which snapshots go cold
+func (m *Manager) tierCutoff() time.Time {
+	return time.Now().AddDate(0, 0, -m.cfg.SnapshotTierAfter)
+}
+
+// resumeSnapshots returns the IDs of the resume images suspended pucks
+// are waiting on
+func (m *Manager) resumeSnapshots(ctx context.Context) (map[string]bool, error) {
+	pucks, err := m.store.ListPucks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]bool)
+	for _, p := range pucks {
+		if p.ResumeSnapshot != "" {
+			ids[p.ResumeSnapshot] = true
+		}
+	}
+	return ids, nil
+}
+
+// coolSnapshot moves a snapshot's archive to cold storage
+func (m *Manager) coolSnapshot(ctx context.Context, s *store.Snapshot) error {
+	cold := filepath.Join(m.cfg.SnapshotTierDir, s.PuckName, filepath.Base(s.Path))
+	if err := moveFile(s.Path, cold); err != nil {
+		return err
+	}
+	if err := m.store.UpdateSnapshotColdPath(ctx, s.ID, cold); err != nil {
+		// Put it back rather than lose track of it
+		moveFile(cold, s.Path)
+		return err
+	}
+	s.ColdPath = cold
+	return nil
+}
+
+// warmSnapshot fetches a snapshot's archive back from cold storage, for a
+// restore
+func (m *Manager) warmSnapshot(ctx context.Context, s *store.Snapshot) error {
+	if s.ColdPath == "" {
+		return nil
+	}
+	if err := moveFile(s.ColdPath, s.Path); err != nil {
+		return fmt.Errorf("fetching snapshot from cold storage: %w", err)
+	}
+	if err := m.store.UpdateSnapshotColdPath(ctx, s.ID, ""); err != nil {
+		return err
+	}
+	s.ColdPath = ""
+	return nil
+}
+
+// tierSnapshot describes where a snapshot's archive is kept
+func tierSnapshot(s *store.Snapshot) TierSnapshot {
+	tier := TierHot
+	if s.ColdPath != "" {
+		tier = TierCold
+	}
+	return TierSnapshot{
+		Puck:      s.PuckName,
+		Snapshot:  s.Name,
+		Tier:      tier,
+		Path:      s.ArchivePath(),
+		Size:      s.SizeBytes,
+		CreatedAt: s.CreatedAt,
+	}
+}
+
+// moveFile moves a file, copying it when src and dst are on different
+// filesystems. The copy is complete at dst before src is removed.
+func moveFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := dst + ".part"
+	if err := writeFile(tmp, in, 0644); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Remove(src)
+}
@@ -0,0 +1,122 @@
+package puck
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotTiering(t *testing.T) {
+	// setup returns a manager with a snapshotted puck and cold storage in
+	// which every snapshot counts as old
+	setup := func(t *testing.T) (*Manager, *podman.MockClient, func()) {
+		mgr, mock, cleanup := setupTestManager(t)
+		mgr.cfg.SnapshotTierDir = filepath.Join(mgr.cfg.DataDir, "cold")
+		mgr.cfg.SnapshotTierAfter = 0
+
+		ctx := context.Background()
+		_, err := mgr.Create(ctx, CreateOptions{Name: "tier-puck"})
+		require.NoError(t, err)
+		_, err = mgr.CreateSnapshot(ctx, SnapshotCreateOptions{PuckName: "tier-puck", SnapshotName: "old", LeaveRunning: leaveRunning})
+		require.NoError(t, err)
+		return mgr, mock, cleanup
+	}
+
+	t.Run("moves old snapshots to cold storage and keeps their records", func(t *testing.T) {
+		mgr, _, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		status, err := mgr.TierStatus(ctx)
+		require.NoError(t, err)
+		require.Len(t, status.Snapshots, 1)
+		assert.Equal(t, TierHot, status.Snapshots[0].Tier)
+		assert.True(t, status.Snapshots[0].Due)
+
+		report, err := mgr.TierSnapshots(ctx)
+		require.NoError(t, err)
+		require.Len(t, report.Moved, 1)
+		assert.Empty(t, report.Errors)
+
+		snapshots, err := mgr.ListSnapshots(ctx, "tier-puck")
+		require.NoError(t, err)
+		require.Len(t, snapshots, 1)
+		s := snapshots[0]
+		assert.Equal(t, filepath.Join(mgr.cfg.SnapshotTierDir, "tier-puck", "old.tar.gz"), s.ColdPath)
+		assert.FileExists(t, s.ColdPath)
+		assert.NoFileExists(t, s.Path)
+
+		status, err = mgr.TierStatus(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, TierCold, status.Snapshots[0].Tier)
+		assert.False(t, status.Snapshots[0].Due)
+	})
+
+	t.Run("fetches a cold snapshot back to restore it", func(t *testing.T) {
+		mgr, mock, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		_, err := mgr.TierSnapshots(ctx)
+		require.NoError(t, err)
+
+		mock.Reset()
+		require.NoError(t, mgr.RestoreSnapshot(ctx, SnapshotRestoreOptions{PuckName: "tier-puck", SnapshotName: "old"}))
+		assert.True(t, mock.WasCalled("Restore"))
+
+		snapshots, err := mgr.ListSnapshots(ctx, "tier-puck")
+		require.NoError(t, err)
+		s := snapshots[0]
+		assert.Empty(t, s.ColdPath)
+		assert.FileExists(t, s.Path)
+		data, err := os.ReadFile(s.Path)
+		require.NoError(t, err)
+		assert.Equal(t, "checkpoint-data", string(data))
+	})
+
+	t.Run("deletes cold archives with their snapshots", func(t *testing.T) {
+		mgr, _, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		report, err := mgr.TierSnapshots(ctx)
+		require.NoError(t, err)
+		require.Len(t, report.Moved, 1)
+
+		require.NoError(t, mgr.DeleteSnapshot(ctx, "tier-puck", "old"))
+		assert.NoFileExists(t, report.Moved[0].Path)
+	})
+
+	t.Run("leaves resume images of suspended pucks", func(t *testing.T) {
+		mgr, _, cleanup := setup(t)
+		defer cleanup()
+		ctx := context.Background()
+
+		require.NoError(t, mgr.StopWithOptions(ctx, StopOptions{Name: "tier-puck", Checkpoint: true}))
+
+		report, err := mgr.TierSnapshots(ctx)
+		require.NoError(t, err)
+		require.Len(t, report.Moved, 1)
+		assert.Equal(t, "old", report.Moved[0].Snapshot)
+
+		p, err := mgr.Get(ctx, "tier-puck")
+		require.NoError(t, err)
+		assert.Equal(t, store.StatusSuspended, p.Status)
+	})
+
+	t.Run("does nothing while tiering is off", func(t *testing.T) {
+		mgr, _, cleanup := setup(t)
+		defer cleanup()
+		mgr.cfg.SnapshotTierDir = ""
+
+		report, err := mgr.TierSnapshots(context.Background())
+		require.NoError(t, err)
+		assert.Empty(t, report.Moved)
+	})
+}
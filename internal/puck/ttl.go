@@ -0,0 +1,103 @@
+package puck
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/containers/storage/pkg/archive"
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// MinTTL is the shortest TTL a puck can be given, so a slip such as
+// --ttl 4 doesn't destroy it as soon as it is made
+const MinTTL = time.Minute
+
+// Files a final snapshot is made of, in its directory under ExpiredDir
+const (
+	ExpiredCheckpoint = "checkpoint.tar.gz"
+	ExpiredVolumes    = "volumes.tar.gz"
+)
+
+func validateTTL(ttl time.Duration) error {
+	if ttl < MinTTL {
+		return fmt.Errorf("TTL %s is too short; give at least %s", ttl, MinTTL)
+	}
+	return nil
+}
+
+// SetTTL has the daemon destroy a puck ttl from now, or never with zero.
+// A nil snapshot keeps whether it archives the puck first.
+func (m *Manager) SetTTL(ctx context.Context, name string, ttl time.Duration, snapshot *bool) (*store.Puck, error) {
+	p, err := m.store.GetPuck(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var expires time.Time
+	if ttl != 0 {
+		if err := validateTTL(ttl); err != nil {
+			return nil, err
+		}
+		expires = time.Now().Add(ttl)
+	}
+	keep := p.ExpirySnapshot
+	if snapshot != nil {
+		keep = *snapshot
+	}
+	if err := m.store.UpdatePuckExpiry(ctx, name, expires, keep && ttl != 0); err != nil {
+		return nil, err
+	}
+	return m.store.GetPuck(ctx, name)
+}
+
+// Expired returns the pucks whose TTL has run out by now. Pucks waiting
+// in a pool have none until handed out.
+func (m *Manager) Expired(ctx context.Context, now time.Time) ([]*store.Puck, error) {
+	pucks, err := m.store.ListPucks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var expired []*store.Puck
+	for _, p := range pucks {
+		if !p.ExpiresAt.IsZero() && !p.ExpiresAt.After(now) && p.Pool == "" {
+			expired = append(expired, p)
+		}
+	}
+	return expired, nil
+}
+
+// FinalSnapshot archives a puck about to be destroyed because its TTL ran
+// out, into a directory of its own under ExpiredDir, which it returns.
+// The archive holds its volumes and, if it is running and CRIU is
+// available, a checkpoint puck create --from-checkpoint can restore.
+// Checkpointing stops the puck.
+func (m *Manager) FinalSnapshot(ctx context.Context, p *store.Puck) (string, error) {
+	dir := filepath.Join(m.cfg.ExpiredDir(), p.Name+"-"+time.Now().UTC().Format("20060102-150405"))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	running, _ := m.podman.IsRunning(ctx, p.ContainerID)
+	if running && m.CRIUVersion(ctx) != "" {
+		criu := m.criuOptions(CRIUFlags{})
+		err := m.podman.Checkpoint(context.WithoutCancel(ctx), p.ContainerID, podman.CheckpointOptions{
+			ExportPath:     filepath.Join(dir, ExpiredCheckpoint),
+			TCPEstablished: criu.TCPEstablished,
+			FileLocks:      criu.FileLocks,
+		})
+		if err != nil {
+			os.RemoveAll(dir)
+			return "", fmt.Errorf("checkpointing container: %w", err)
+		}
+	}
+
+	if err := archiveDir(p.VolumeDir, filepath.Join(dir, ExpiredVolumes), archive.Gzip); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("archiving volumes: %w", err)
+	}
+	return dir, nil
+}
@@ -0,0 +1,113 @@
+package puck
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateWithTTL(t *testing.T) {
+	mgr, _, cleanup := setupTestManager(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	p, err := mgr.Create(ctx, CreateOptions{Name: "scratch", TTL: 4 * time.Hour, TTLSnapshot: true})
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(4*time.Hour), p.ExpiresAt, time.Minute)
+	assert.True(t, p.ExpirySnapshot)
+
+	_, err = mgr.Create(ctx, CreateOptions{Name: "too-short", TTL: 4 * time.Second})
+	assert.ErrorContains(t, err, "too short")
+	_, err = mgr.Create(ctx, CreateOptions{Name: "no-ttl", TTLSnapshot: true})
+	assert.ErrorContains(t, err, "needs a TTL")
+}
+
+func TestSetTTL(t *testing.T) {
+	mgr, _, cleanup := setupTestManager(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	_, err := mgr.Create(ctx, CreateOptions{Name: "scratch"})
+	require.NoError(t, err)
+
+	snapshot := true
+	p, err := mgr.SetTTL(ctx, "scratch", time.Hour, &snapshot)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), p.ExpiresAt, time.Minute)
+	assert.True(t, p.ExpirySnapshot)
+
+	// A nil snapshot keeps the one set before
+	p, err = mgr.SetTTL(ctx, "scratch", 2*time.Hour, nil)
+	require.NoError(t, err)
+	assert.True(t, p.ExpirySnapshot)
+
+	p, err = mgr.SetTTL(ctx, "scratch", 0, nil)
+	require.NoError(t, err)
+	assert.True(t, p.ExpiresAt.IsZero())
+	assert.False(t, p.ExpirySnapshot)
+
+	_, err = mgr.SetTTL(ctx, "scratch", time.Second, nil)
+	assert.ErrorContains(t, err, "too short")
+}
+
+func TestExpired(t *testing.T) {
+	mgr, _, cleanup := setupTestManager(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	for _, name := range []string{"soon", "later", "never"} {
+		_, err := mgr.Create(ctx, CreateOptions{Name: name})
+		require.NoError(t, err)
+	}
+	_, err := mgr.SetTTL(ctx, "soon", time.Hour, nil)
+	require.NoError(t, err)
+	_, err = mgr.SetTTL(ctx, "later", 3*time.Hour, nil)
+	require.NoError(t, err)
+
+	expired, err := mgr.Expired(ctx, time.Now())
+	require.NoError(t, err)
+	assert.Empty(t, expired)
+
+	expired, err = mgr.Expired(ctx, time.Now().Add(2*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, expired, 1)
+	assert.Equal(t, "soon", expired[0].Name)
+}
+
+func TestFinalSnapshot(t *testing.T) {
+	mgr, mock, cleanup := setupTestManager(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	p, err := mgr.Create(ctx, CreateOptions{Name: "scratch", TTL: time.Hour, TTLSnapshot: true})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(p.VolumeDir, "notes.txt"), []byte("keep me"), 0600))
+
+	var exported string
+	mock.CheckpointFunc = func(ctx context.Context, nameOrID string, opts podman.CheckpointOptions) error {
+		exported = opts.ExportPath
+		return os.WriteFile(opts.ExportPath, []byte("checkpoint"), 0600)
+	}
+
+	dir, err := mgr.FinalSnapshot(ctx, p)
+	require.NoError(t, err)
+	assert.Equal(t, mgr.cfg.ExpiredDir(), filepath.Dir(dir))
+	assert.Equal(t, filepath.Join(dir, ExpiredCheckpoint), exported)
+	assert.FileExists(t, filepath.Join(dir, ExpiredVolumes))
+
+	t.Run("a stopped puck has only its volumes archived", func(t *testing.T) {
+		exported = ""
+		mock.IsRunningFunc = func(ctx context.Context, nameOrID string) (bool, error) { return false, nil }
+
+		dir, err := mgr.FinalSnapshot(ctx, p)
+		require.NoError(t, err)
+		assert.Empty(t, exported)
+		assert.FileExists(t, filepath.Join(dir, ExpiredVolumes))
+	})
+}
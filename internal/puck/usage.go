@@ -0,0 +1,33 @@
+package puck
+
+import (
+	"context"
+
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// FillUsage sets the CPU and memory use of the pucks that are up, read
+// from podman in a single call. If podman can't report it, the pucks are
+// left without it.
+func (m *Manager) FillUsage(ctx context.Context, pucks []*store.Puck) error {
+	var ids []string
+	for _, p := range pucks {
+		if p.Status.Up() && p.ContainerID != "" {
+			ids = append(ids, p.ContainerID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	stats, err := m.podman.ContainerStats(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, p := range pucks {
+		if s, ok := stats[p.ContainerID]; ok && p.Status.Up() {
+			p.Usage = &store.Usage{CPU: s.CPU, Memory: s.Memory}
+		}
+	}
+	return nil
+}
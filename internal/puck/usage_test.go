@@ -0,0 +1,52 @@
+package puck
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFillUsage(t *testing.T) {
+	ctx := context.Background()
+	mgr, mock, cleanup := setupTestManager(t)
+	defer cleanup()
+
+	var asked [][]string
+	mock.ContainerStatsFunc = func(ctx context.Context, ids []string) (map[string]podman.Stats, error) {
+		asked = append(asked, ids)
+		return map[string]podman.Stats{"c-web": {CPU: 12.5, Memory: 64 << 20}}, nil
+	}
+
+	pucks := []*store.Puck{
+		{Name: "web", ContainerID: "c-web", Status: store.StatusRunning},
+		{Name: "api", ContainerID: "c-api", Status: store.StatusStopped},
+		{Name: "db", ContainerID: "c-db", Status: store.StatusStarting},
+	}
+	require.NoError(t, mgr.FillUsage(ctx, pucks))
+
+	// One call for every puck that is up
+	assert.Equal(t, [][]string{{"c-web", "c-db"}}, asked)
+	assert.Equal(t, &store.Usage{CPU: 12.5, Memory: 64 << 20}, pucks[0].Usage)
+	assert.Nil(t, pucks[1].Usage)
+	assert.Nil(t, pucks[2].Usage)
+
+	t.Run("asks nothing when nothing is up", func(t *testing.T) {
+		asked = nil
+		require.NoError(t, mgr.FillUsage(ctx, pucks[1:2]))
+		assert.Empty(t, asked)
+	})
+
+	t.Run("leaves pucks without usage podman can't report", func(t *testing.T) {
+		mock.ContainerStatsFunc = func(ctx context.Context, ids []string) (map[string]podman.Stats, error) {
+			return nil, errors.New("stats unavailable")
+		}
+		p := &store.Puck{Name: "web", ContainerID: "c-web", Status: store.StatusRunning}
+		assert.Error(t, mgr.FillUsage(ctx, []*store.Puck{p}))
+		assert.Nil(t, p.Usage)
+	})
+}
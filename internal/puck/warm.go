@@ -0,0 +1,176 @@
+package puck
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// warmCache records what keeping warm_images pulled has done
+type warmCache struct {
+	mu        sync.Mutex
+	refreshed map[string]time.Time // last pulled
+	errors    map[string]string    // from the last pull, if it failed
+	pulling   map[string]bool
+}
+
+func newWarmCache() *warmCache {
+	return &warmCache{
+		refreshed: make(map[string]time.Time),
+		errors:    make(map[string]string),
+		pulling:   make(map[string]bool),
+	}
+}
+
+// WarmPull is the outcome of pulling one warm image
+type WarmPull struct {
+	Image string `json:"image"`
+	Error string `json:"error,omitempty"`
+}
+
+// WarmImages pulls the images in warm_images that are missing from local
+// storage, or all of them when refresh is set so they keep up with their
+// tags. Pulls go one at a time, and an image already being pulled is
+// skipped.
+func (m *Manager) WarmImages(ctx context.Context, refresh bool) []WarmPull {
+	var pulls []WarmPull
+	for _, ref := range m.cfg.WarmImages {
+		if !refresh {
+			if exists, err := m.podman.ImageExists(ctx, ref); err == nil && exists {
+				continue
+			}
+		}
+
+		m.warm.mu.Lock()
+		if m.warm.pulling[ref] {
+			m.warm.mu.Unlock()
+			continue
+		}
+		m.warm.pulling[ref] = true
+		m.warm.mu.Unlock()
+
+		err := m.podman.PullImage(ctx, ref)
+
+		m.warm.mu.Lock()
+		delete(m.warm.pulling, ref)
+		pull := WarmPull{Image: ref}
+		if err != nil {
+			pull.Error = err.Error()
+			m.warm.errors[ref] = pull.Error
+		} else {
+			m.warm.refreshed[ref] = time.Now()
+			delete(m.warm.errors, ref)
+		}
+		m.warm.mu.Unlock()
+		pulls = append(pulls, pull)
+	}
+	return pulls
+}
+
+// ImageInfo is an image in local storage, or one in warm_images that
+// isn't there yet
+type ImageInfo struct {
+	Name    string    `json:"name"` // as given in warm_images, or the image's first name
+	ID      string    `json:"id,omitempty"`
+	Size    int64     `json:"size,omitempty"`
+	Created time.Time `json:"created,omitempty"`
+	Present bool      `json:"present"`
+	Pucks   int       `json:"pucks"` // created from it
+	// Warm images are kept pulled and refreshed by the daemon
+	Warm      bool       `json:"warm,omitempty"`
+	Pulling   bool       `json:"pulling,omitempty"`
+	Refreshed *time.Time `json:"refreshed,omitempty"` // last pulled by the daemon since it started
+	Error     string     `json:"error,omitempty"`     // from the last pull, if it failed
+}
+
+// Images lists the tagged images in local storage, with those in
+// warm_images marked, and warm images that are still missing. Snapshot
+// images are left to puck snapshot list.
+func (m *Manager) Images(ctx context.Context) ([]ImageInfo, error) {
+	pucks, err := m.store.ListPucks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	images, err := m.podman.ListImages(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.warm.mu.Lock()
+	defer m.warm.mu.Unlock()
+
+	var infos []ImageInfo
+	found := make(map[string]bool)
+	for _, img := range images {
+		if img.Dangling || len(img.Names) == 0 || imageRepo(img.Names[0]) == snapshotImageRepo {
+			continue
+		}
+		info := ImageInfo{
+			Name:    img.Names[0],
+			ID:      img.ID,
+			Size:    img.Size,
+			Created: img.Created,
+			Present: true,
+			Pucks:   pucksUsing(img, pucks),
+		}
+		for _, ref := range m.cfg.WarmImages {
+			if !slices.ContainsFunc(img.Names, func(name string) bool { return imageRefMatches(name, ref) }) {
+				continue
+			}
+			found[ref] = true
+			info.Name = ref
+			m.warm.describe(ref, &info)
+		}
+		infos = append(infos, info)
+	}
+	for _, ref := range m.cfg.WarmImages {
+		if found[ref] {
+			continue
+		}
+		info := ImageInfo{Name: ref}
+		m.warm.describe(ref, &info)
+		infos = append(infos, info)
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos, nil
+}
+
+// describe fills in what the cache knows of a warm image. The caller
+// holds the lock.
+func (w *warmCache) describe(ref string, info *ImageInfo) {
+	info.Warm = true
+	info.Pulling = w.pulling[ref]
+	info.Error = w.errors[ref]
+	if t, ok := w.refreshed[ref]; ok {
+		info.Refreshed = &t
+	}
+}
+
+// warmImage reports whether an image is one warm_images keeps pulled
+func (m *Manager) warmImage(img podman.Image) bool {
+	for _, ref := range m.cfg.WarmImages {
+		for _, name := range img.Names {
+			if imageRefMatches(name, ref) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// pucksUsing counts the pucks created from an image
+func pucksUsing(img podman.Image, pucks []*store.Puck) int {
+	n := 0
+	for _, p := range pucks {
+		if slices.ContainsFunc(img.Names, func(name string) bool { return imageRefMatches(name, p.Image) }) {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,80 @@
+package puck
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarmImages(t *testing.T) {
+	ctx := context.Background()
+	setup := func(t *testing.T) (*Manager, *podman.MockClient, func()) {
+		mgr, mock, cleanup := setupTestManager(t)
+		mgr.cfg.WarmImages = []string{"fedora", "node:22"}
+		mock.ImageExistsFunc = func(ctx context.Context, ref string) (bool, error) { return ref == "fedora", nil }
+		mock.PullImageFunc = func(ctx context.Context, ref string) error {
+			if ref == "node:22" {
+				return assert.AnError
+			}
+			return nil
+		}
+		return mgr, mock, cleanup
+	}
+
+	t.Run("pulls missing images", func(t *testing.T) {
+		mgr, mock, cleanup := setup(t)
+		defer cleanup()
+
+		pulls := mgr.WarmImages(ctx, false)
+		assert.Equal(t, []WarmPull{{Image: "node:22", Error: assert.AnError.Error()}}, pulls)
+		assert.Equal(t, 1, mock.CallCount("PullImage"))
+	})
+
+	t.Run("refreshes every image", func(t *testing.T) {
+		mgr, mock, cleanup := setup(t)
+		defer cleanup()
+
+		pulls := mgr.WarmImages(ctx, true)
+		require.Len(t, pulls, 2)
+		assert.Equal(t, "fedora", pulls[0].Image)
+		assert.Empty(t, pulls[0].Error)
+		assert.Equal(t, 2, mock.CallCount("PullImage"))
+	})
+
+	t.Run("reports cache status", func(t *testing.T) {
+		mgr, mock, cleanup := setup(t)
+		defer cleanup()
+		_, err := mgr.Create(ctx, CreateOptions{Name: "warm-puck", Image: "fedora"})
+		require.NoError(t, err)
+		mock.ListImagesFunc = func(ctx context.Context) ([]podman.Image, error) {
+			return []podman.Image{
+				{ID: "fedora-id", Names: []string{"docker.io/library/fedora:latest"}, Size: 100, Created: time.Now()},
+				{ID: "alpine-id", Names: []string{"docker.io/library/alpine:3.19"}, Size: 10},
+				{ID: "dangling", Dangling: true},
+				{ID: "snapshot", Names: []string{snapshotImageRepo + ":abc"}},
+			}, nil
+		}
+		mgr.WarmImages(ctx, true)
+
+		images, err := mgr.Images(ctx)
+		require.NoError(t, err)
+		require.Len(t, images, 3)
+
+		alpine, fedora, node := images[0], images[1], images[2]
+		assert.Equal(t, "docker.io/library/alpine:3.19", alpine.Name)
+		assert.False(t, alpine.Warm)
+		assert.Equal(t, "fedora", fedora.Name)
+		assert.True(t, fedora.Warm)
+		assert.True(t, fedora.Present)
+		assert.Equal(t, 1, fedora.Pucks)
+		assert.NotNil(t, fedora.Refreshed)
+		assert.Equal(t, "node:22", node.Name)
+		assert.True(t, node.Warm)
+		assert.False(t, node.Present)
+		assert.NotEmpty(t, node.Error)
+	})
+}
@@ -0,0 +1,296 @@
+// Package scan finds known vulnerabilities in container images with Trivy
+// or Grype, run from the host or, when neither is installed, in a
+// container
+package scan
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Scanners
+const (
+	Trivy     = "trivy"
+	Grype     = "grype"
+	Container = "container" // Trivy in a container run by podman
+)
+
+// DefaultContainerImage is the scanner image the container scanner runs
+const DefaultContainerImage = "docker.io/aquasec/trivy:latest"
+
+// cacheVolume keeps the container scanner's vulnerability database
+// between scans, so it is downloaded once rather than every time
+const cacheVolume = "puck-trivy-cache"
+
+// Severities, most severe first
+const (
+	Critical = "CRITICAL"
+	High     = "HIGH"
+	Medium   = "MEDIUM"
+	Low      = "LOW"
+	Unknown  = "UNKNOWN"
+)
+
+// Severities lists the severities from most to least severe
+var Severities = []string{Critical, High, Medium, Low, Unknown}
+
+// Rank orders a severity, lower being more severe; unrecognized ones rank
+// with Unknown
+func Rank(severity string) int {
+	if i := slices.Index(Severities, strings.ToUpper(severity)); i >= 0 {
+		return i
+	}
+	return len(Severities) - 1
+}
+
+// Finding is a known vulnerability in a package in the image
+type Finding struct {
+	ID        string `json:"id"` // e.g. CVE-2024-1234
+	Package   string `json:"package"`
+	Installed string `json:"installed"`
+	FixedIn   string `json:"fixed_in,omitempty"` // empty when no fix is out
+	Severity  string `json:"severity"`
+	Title     string `json:"title,omitempty"`
+}
+
+// Result is what a scan of an image found, most severe first
+type Result struct {
+	Image     string    `json:"image"`
+	Scanner   string    `json:"scanner"`
+	ScannedAt time.Time `json:"scanned_at"`
+	Findings  []Finding `json:"findings"`
+}
+
+// Counts returns the number of findings of each severity
+func (r *Result) Counts() map[string]int {
+	counts := make(map[string]int)
+	for _, f := range r.Findings {
+		counts[f.Severity]++
+	}
+	return counts
+}
+
+// AtLeast returns the number of findings at least as severe as severity
+func (r *Result) AtLeast(severity string) int {
+	n := 0
+	for _, f := range r.Findings {
+		if Rank(f.Severity) <= Rank(severity) {
+			n++
+		}
+	}
+	return n
+}
+
+// Summary describes the findings in a line, e.g. "2 critical, 5 high"
+func (r *Result) Summary() string {
+	counts := r.Counts()
+	var parts []string
+	for _, sev := range Severities {
+		if n := counts[sev]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n, strings.ToLower(sev)))
+		}
+	}
+	if len(parts) == 0 {
+		return "no known vulnerabilities"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Options controls Scan
+type Options struct {
+	// Scanner to use: Trivy or Grype installed on the host, or Container.
+	// Empty picks whichever is installed, falling back to Container.
+	Scanner string
+	// Image the container scanner runs; empty for DefaultContainerImage
+	ContainerImage string
+}
+
+// Available returns the scanner Scan uses when none is given
+func Available() string {
+	for _, s := range []string{Trivy, Grype} {
+		if _, err := exec.LookPath(s); err == nil {
+			return s
+		}
+	}
+	return Container
+}
+
+// Scan scans an image in podman's local storage
+func Scan(ctx context.Context, image string, opts Options) (*Result, error) {
+	scanner := opts.Scanner
+	if scanner == "" {
+		scanner = Available()
+	}
+
+	var findings []Finding
+	switch scanner {
+	case Trivy:
+		out, err := run(ctx, "trivy", "image", "--quiet", "--format", "json", "--image-src", "podman", image)
+		if err != nil {
+			return nil, err
+		}
+		if findings, err = parseTrivy(out); err != nil {
+			return nil, err
+		}
+	case Grype:
+		out, err := run(ctx, "grype", "podman:"+image, "--output", "json", "--quiet")
+		if err != nil {
+			return nil, err
+		}
+		if findings, err = parseGrype(out); err != nil {
+			return nil, err
+		}
+	case Container:
+		out, err := runContainer(ctx, image, cmp.Or(opts.ContainerImage, DefaultContainerImage))
+		if err != nil {
+			return nil, err
+		}
+		if findings, err = parseTrivy(out); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown scanner %q (expected %s, %s or %s)", scanner, Trivy, Grype, Container)
+	}
+
+	sortFindings(findings)
+	return &Result{Image: image, Scanner: scanner, ScannedAt: time.Now(), Findings: findings}, nil
+}
+
+// runContainer saves the image to an archive and scans that with Trivy
+// in a container, which needs nothing but podman on the host
+func runContainer(ctx context.Context, image, scanner string) ([]byte, error) {
+	dir, err := os.MkdirTemp("", "puck-scan-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	archive := filepath.Join(dir, "image.tar")
+	if _, err := run(ctx, "podman", "image", "save", "--format", "docker-archive", "-o", archive, image); err != nil {
+		return nil, fmt.Errorf("saving image: %w", err)
+	}
+	return run(ctx, "podman", "run", "--rm",
+		"-v", dir+":/scan:ro,Z",
+		"-v", cacheVolume+":/root/.cache/trivy",
+		scanner, "image", "--quiet", "--format", "json", "--input", "/scan/image.tar")
+}
+
+// run runs a scanner command and returns its output, or an error with
+// the last thing it said
+func run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+		if last := lines[len(lines)-1]; last != "" {
+			return nil, fmt.Errorf("%s: %w: %s", name, err, last)
+		}
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return stdout.Bytes(), nil
+}
+
+// parseTrivy reads the findings from trivy's JSON report
+func parseTrivy(data []byte) ([]Finding, error) {
+	var report struct {
+		Results []struct {
+			Vulnerabilities []struct {
+				VulnerabilityID  string
+				PkgName          string
+				InstalledVersion string
+				FixedVersion     string
+				Severity         string
+				Title            string
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("reading trivy report: %w", err)
+	}
+
+	findings := []Finding{}
+	for _, r := range report.Results {
+		for _, v := range r.Vulnerabilities {
+			findings = append(findings, Finding{
+				ID:        v.VulnerabilityID,
+				Package:   v.PkgName,
+				Installed: v.InstalledVersion,
+				FixedIn:   v.FixedVersion,
+				Severity:  severity(v.Severity),
+				Title:     v.Title,
+			})
+		}
+	}
+	return findings, nil
+}
+
+// parseGrype reads the findings from grype's JSON report
+func parseGrype(data []byte) ([]Finding, error) {
+	var report struct {
+		Matches []struct {
+			Vulnerability struct {
+				ID          string `json:"id"`
+				Severity    string `json:"severity"`
+				Description string `json:"description"`
+				Fix         struct {
+					Versions []string `json:"versions"`
+				} `json:"fix"`
+			} `json:"vulnerability"`
+			Artifact struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+			} `json:"artifact"`
+		} `json:"matches"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("reading grype report: %w", err)
+	}
+
+	findings := []Finding{}
+	for _, m := range report.Matches {
+		findings = append(findings, Finding{
+			ID:        m.Vulnerability.ID,
+			Package:   m.Artifact.Name,
+			Installed: m.Artifact.Version,
+			FixedIn:   strings.Join(m.Vulnerability.Fix.Versions, ", "),
+			Severity:  severity(m.Vulnerability.Severity),
+			Title:     m.Vulnerability.Description,
+		})
+	}
+	return findings, nil
+}
+
+// severity normalizes a scanner's severity to one of Severities. Grype's
+// lowest, negligible, counts as low.
+func severity(s string) string {
+	s = strings.ToUpper(s)
+	if s == "NEGLIGIBLE" {
+		return Low
+	}
+	if slices.Contains(Severities, s) {
+		return s
+	}
+	return Unknown
+}
+
+// sortFindings orders findings most severe first, then by package and ID
+func sortFindings(findings []Finding) {
+	slices.SortStableFunc(findings, func(a, b Finding) int {
+		return cmp.Or(
+			cmp.Compare(Rank(a.Severity), Rank(b.Severity)),
+			cmp.Compare(a.Package, b.Package),
+			cmp.Compare(a.ID, b.ID),
+		)
+	})
+}
@@ -0,0 +1,74 @@
+package scan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTrivy(t *testing.T) {
+	report := `{
+		"SchemaVersion": 2,
+		"Results": [
+			{"Target": "fedora (fedora 40)", "Vulnerabilities": [
+				{"VulnerabilityID": "CVE-2024-0002", "PkgName": "openssl", "InstalledVersion": "3.2.1", "FixedVersion": "3.2.2", "Severity": "HIGH", "Title": "openssl: crash"},
+				{"VulnerabilityID": "CVE-2024-0001", "PkgName": "glibc", "InstalledVersion": "2.39", "Severity": "CRITICAL"}
+			]},
+			{"Target": "app/package-lock.json"}
+		]
+	}`
+
+	findings, err := parseTrivy([]byte(report))
+	require.NoError(t, err)
+	require.Len(t, findings, 2)
+	assert.Equal(t, Finding{ID: "CVE-2024-0002", Package: "openssl", Installed: "3.2.1", FixedIn: "3.2.2", Severity: High, Title: "openssl: crash"}, findings[0])
+	assert.Equal(t, Critical, findings[1].Severity)
+	assert.Empty(t, findings[1].FixedIn)
+
+	findings, err = parseTrivy([]byte(`{"Results": []}`))
+	require.NoError(t, err)
+	assert.NotNil(t, findings, "no findings is an empty list")
+
+	_, err = parseTrivy([]byte("FATAL no such image"))
+	assert.Error(t, err)
+}
+
+func TestParseGrype(t *testing.T) {
+	report := `{"matches": [
+		{"vulnerability": {"id": "CVE-2024-0003", "severity": "Medium", "description": "zlib overflow", "fix": {"versions": ["1.3.1"], "state": "fixed"}},
+		 "artifact": {"name": "zlib", "version": "1.3"}},
+		{"vulnerability": {"id": "CVE-2024-0004", "severity": "Negligible", "fix": {"versions": [], "state": "not-fixed"}},
+		 "artifact": {"name": "bash", "version": "5.2"}}
+	]}`
+
+	findings, err := parseGrype([]byte(report))
+	require.NoError(t, err)
+	require.Len(t, findings, 2)
+	assert.Equal(t, Finding{ID: "CVE-2024-0003", Package: "zlib", Installed: "1.3", FixedIn: "1.3.1", Severity: Medium, Title: "zlib overflow"}, findings[0])
+	assert.Equal(t, Low, findings[1].Severity, "negligible counts as low")
+}
+
+func TestResult(t *testing.T) {
+	findings := []Finding{
+		{ID: "CVE-3", Package: "zlib", Severity: Low},
+		{ID: "CVE-2", Package: "openssl", Severity: Critical},
+		{ID: "CVE-1", Package: "bash", Severity: High},
+		{ID: "CVE-4", Package: "glibc", Severity: Critical},
+		{ID: "CVE-5", Package: "curl", Severity: Unknown},
+	}
+	sortFindings(findings)
+	var ids []string
+	for _, f := range findings {
+		ids = append(ids, f.ID)
+	}
+	assert.Equal(t, []string{"CVE-4", "CVE-2", "CVE-1", "CVE-3", "CVE-5"}, ids)
+
+	r := &Result{Findings: findings}
+	assert.Equal(t, 2, r.AtLeast(Critical))
+	assert.Equal(t, 3, r.AtLeast(High))
+	assert.Equal(t, 4, r.AtLeast("low"))
+	assert.Equal(t, "2 critical, 1 high, 1 low, 1 unknown", r.Summary())
+
+	assert.Equal(t, "no known vulnerabilities", (&Result{}).Summary())
+}
@@ -0,0 +1,44 @@
+//go:build integration
+
+package selftest
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestLifecycle runs the selftest against the Podman at
+// PUCK_PODMAN_SOCKET, or the user's socket, with the image in
+// PUCK_SELFTEST_IMAGE if set:
+//
+//	go test -tags "integration <build tags>" ./internal/selftest
+func TestLifecycle(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	report, err := Run(ctx, Options{
+		PodmanSocket: os.Getenv("PUCK_PODMAN_SOCKET"),
+		Image:        os.Getenv("PUCK_SELFTEST_IMAGE"),
+	}, func(s Step) {
+		t.Logf("%s: %s%s%s (%s)", s.Name, s.Detail, s.Skipped, s.Error, s.Duration)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first := report.Steps[0]; first.Error != "" {
+		t.Skipf("Podman is not reachable: %s", first.Error)
+	}
+
+	for _, s := range report.Steps {
+		t.Run(s.Name, func(t *testing.T) {
+			if s.Skipped != "" {
+				t.Skip(s.Skipped)
+			}
+			if s.Error != "" {
+				t.Fatal(s.Error)
+			}
+		})
+	}
+}
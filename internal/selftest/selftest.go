@@ -0,0 +1,378 @@
+// Package selftest puts a puck through its lifecycle against a real
+// Podman: create, exec, stop and start, snapshots and restores of both
+// kinds, and destroy. It runs a puck.Manager of its own, with a data
+// directory and database in a temporary directory, so it leaves the
+// daemon's pucks alone, and is what puck selftest and the integration
+// tests run.
+package selftest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sandwich-labs/puck/internal/config"
+	"github.com/sandwich-labs/puck/internal/podman"
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/sandwich-labs/puck/internal/store"
+)
+
+// DefaultImage is small, and has a shell and sleep
+const DefaultImage = "docker.io/library/alpine:latest"
+
+// Options controls a selftest run
+type Options struct {
+	PodmanSocket string // empty finds the user's socket
+	Image        string // DefaultImage when empty
+	// Leave the puck and the temporary directory behind for a look
+	// afterwards
+	Keep bool
+}
+
+// Step is one stage of a run and how it went
+type Step struct {
+	Name     string        `json:"name"`
+	Detail   string        `json:"detail,omitempty"`
+	Skipped  string        `json:"skipped,omitempty"` // why it didn't run
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Report is every step of a run
+type Report struct {
+	Steps  []Step `json:"steps"`
+	Failed int    `json:"failed"`
+	Dir    string `json:"dir,omitempty"` // left behind with Keep
+}
+
+// skipped is a step that can't run here, such as a checkpoint without CRIU
+type skipped string
+
+func (s skipped) Error() string { return string(s) }
+
+// marker is written into the puck's home volume, so restores can be
+// checked for it
+const (
+	markerPath    = "/home/selftest"
+	markerContent = "puck selftest"
+)
+
+// Run puts a puck through its lifecycle, calling progress as each step
+// finishes. Once a step fails the rest are skipped, but the puck is still
+// destroyed. Only failing to set up the run is an error; a failed step is
+// in the report.
+func Run(ctx context.Context, opts Options, progress func(Step)) (*Report, error) {
+	if opts.Image == "" {
+		opts.Image = DefaultImage
+	}
+	if progress == nil {
+		progress = func(Step) {}
+	}
+
+	dir, err := os.MkdirTemp("", "puck-selftest-*")
+	if err != nil {
+		return nil, err
+	}
+	cfg := config.Default()
+	cfg.DataDir = dir
+	cfg.PodmanSocket = opts.PodmanSocket
+	cfg.RouterEnabled = false
+	cfg.RouteMode = config.RouteHostPort
+	cfg.MOTD = false
+	// This database doesn't know the daemon's pucks' ports, so stay off
+	// the low ones sequential allocation gives them
+	cfg.PortAllocator = config.PortRandom
+	cfg.WarmImages = nil
+	db, err := store.Open(cfg.DatabasePath())
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	r := &run{ctx: ctx, opts: opts, cfg: cfg, report: &Report{}, progress: progress}
+	r.name = "selftest-" + randomSuffix()
+	defer func() {
+		r.cleanup()
+		db.Close()
+		if opts.Keep {
+			r.report.Dir = dir
+		} else {
+			os.RemoveAll(dir)
+		}
+	}()
+
+	r.step("podman", func() (string, error) {
+		client, err := podman.NewClient(ctx, cfg.PodmanSocket)
+		if err != nil {
+			return "", err
+		}
+		if err := client.Ping(ctx); err != nil {
+			return "", err
+		}
+		host, err := client.HostInfo(ctx)
+		if err != nil {
+			return "", err
+		}
+		r.podman = client
+		r.host = host
+		r.manager = puck.NewManager(cfg, client, db)
+		mode := "rootful"
+		if host.Rootless {
+			mode = "rootless"
+		}
+		return fmt.Sprintf("Podman %s, %s, kernel %s", host.PodmanVersion, mode, host.Kernel), nil
+	})
+	r.step("create", r.create)
+	r.step("exec", r.exec)
+	r.step("stop", r.stop)
+	r.step("start", r.start)
+	r.step("image snapshot", func() (string, error) { return r.snapshot(store.SnapshotModeImage) })
+	r.step("image restore", func() (string, error) { return r.restore(store.SnapshotModeImage) })
+	r.step("checkpoint snapshot", func() (string, error) { return r.snapshot(store.SnapshotModeCheckpoint) })
+	r.step("checkpoint restore", func() (string, error) { return r.restore(store.SnapshotModeCheckpoint) })
+	if !opts.Keep {
+		r.step("destroy", r.destroy)
+	}
+	return r.report, nil
+}
+
+// run is the state of one Run
+type run struct {
+	ctx      context.Context
+	opts     Options
+	cfg      *config.Config
+	report   *Report
+	progress func(Step)
+
+	podman  podman.ContainerClient
+	host    *podman.HostInfo
+	manager *puck.Manager
+	name    string
+	created bool
+	failed  bool
+	// checkpointSkipped is why checkpoints can't be taken here
+	checkpointSkipped string
+}
+
+// step runs fn as the named step, unless an earlier one failed
+func (r *run) step(name string, fn func() (string, error)) {
+	s := Step{Name: name}
+	if r.failed {
+		s.Skipped = "an earlier step failed"
+	} else {
+		start := time.Now()
+		detail, err := fn()
+		s.Duration = time.Since(start)
+		var skip skipped
+		switch {
+		case errors.As(err, &skip):
+			s.Skipped = skip.Error()
+		case err != nil:
+			s.Error = err.Error()
+			r.failed = true
+			r.report.Failed++
+		default:
+			s.Detail = detail
+		}
+	}
+	r.report.Steps = append(r.report.Steps, s)
+	r.progress(s)
+}
+
+func (r *run) create() (string, error) {
+	stopTimeout := 2
+	p, err := r.manager.Create(r.ctx, puck.CreateOptions{
+		Name:        r.name,
+		Image:       r.opts.Image,
+		Init:        store.InitTini,
+		Command:     []string{"sleep", "3600"},
+		StopTimeout: &stopTimeout,
+	})
+	if err != nil {
+		return "", err
+	}
+	r.created = true
+	if err := r.expectRunning(true); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s from %s, container %s", p.Name, p.Image, p.ContainerID[:min(12, len(p.ContainerID))]), nil
+}
+
+func (r *run) exec() (string, error) {
+	res, err := r.manager.Exec(r.ctx, puck.ExecOptions{
+		Name: r.name,
+		Cmd:  []string{"sh", "-c", fmt.Sprintf("echo %q > %s && cat %s", markerContent, markerPath, markerPath)},
+	})
+	if err != nil {
+		return "", err
+	}
+	if res.ExitCode != 0 {
+		return "", fmt.Errorf("command exited %d: %s", res.ExitCode, strings.TrimSpace(res.Output))
+	}
+	if got := strings.TrimSpace(res.Output); got != markerContent {
+		return "", fmt.Errorf("command printed %q, expected %q", got, markerContent)
+	}
+	return "wrote " + markerPath, nil
+}
+
+func (r *run) stop() (string, error) {
+	if err := r.manager.Stop(r.ctx, r.name); err != nil {
+		return "", err
+	}
+	return "", r.expectRunning(false)
+}
+
+func (r *run) start() (string, error) {
+	if err := r.manager.Start(r.ctx, r.name); err != nil {
+		return "", err
+	}
+	if err := r.expectRunning(true); err != nil {
+		return "", err
+	}
+	return "", r.expectMarker()
+}
+
+// snapshot takes a snapshot in mode, named after it
+func (r *run) snapshot(mode store.SnapshotMode) (string, error) {
+	if mode == store.SnapshotModeCheckpoint {
+		if reason := r.checkpointUnsupported(); reason != "" {
+			r.checkpointSkipped = reason
+			return "", skipped(reason)
+		}
+	}
+	leaveRunning := true
+	s, err := r.manager.CreateSnapshot(r.ctx, puck.SnapshotCreateOptions{
+		PuckName:     r.name,
+		SnapshotName: string(mode),
+		Mode:         mode,
+		LeaveRunning: &leaveRunning,
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s, %s", s.Name, s.Mode), nil
+}
+
+// restore removes the marker, restores the snapshot taken in mode and
+// checks the marker is back
+func (r *run) restore(mode store.SnapshotMode) (string, error) {
+	if mode == store.SnapshotModeCheckpoint && r.checkpointSkipped != "" {
+		return "", skipped(r.checkpointSkipped)
+	}
+	res, err := r.manager.Exec(r.ctx, puck.ExecOptions{Name: r.name, Cmd: []string{"rm", markerPath}})
+	if err != nil {
+		return "", err
+	}
+	if res.ExitCode != 0 {
+		return "", fmt.Errorf("removing %s exited %d: %s", markerPath, res.ExitCode, strings.TrimSpace(res.Output))
+	}
+
+	err = r.manager.RestoreSnapshot(r.ctx, puck.SnapshotRestoreOptions{PuckName: r.name, SnapshotName: string(mode)})
+	if err != nil {
+		return "", err
+	}
+	if err := r.expectRunning(true); err != nil {
+		return "", err
+	}
+	if err := r.expectMarker(); err != nil {
+		return "", err
+	}
+	return markerPath + " restored", nil
+}
+
+// checkpointUnsupported says why this host can't take checkpoints, if it
+// can't
+func (r *run) checkpointUnsupported() string {
+	switch {
+	case r.podman.IsMachine():
+		return "checkpoints aren't tested on a Podman machine"
+	case r.host.Rootless:
+		return "CRIU checkpoints need rootful Podman"
+	case r.manager.CRIUVersion(r.ctx) == "":
+		return "CRIU is not installed"
+	}
+	return ""
+}
+
+func (r *run) destroy() (string, error) {
+	p, err := r.manager.Get(r.ctx, r.name)
+	if err != nil {
+		return "", err
+	}
+	if err := r.manager.Destroy(r.ctx, r.name, true); err != nil {
+		return "", err
+	}
+	r.created = false
+	if exists, err := r.podman.ContainerExists(r.ctx, p.ContainerID); err != nil {
+		return "", err
+	} else if exists {
+		return "", fmt.Errorf("container %s is still there", p.ContainerID)
+	}
+	if _, err := os.Stat(p.VolumeDir); !os.IsNotExist(err) {
+		return "", fmt.Errorf("volume directory %s is still there", p.VolumeDir)
+	}
+	return "", nil
+}
+
+// cleanup destroys the puck if a failed step left it, unless it is kept
+func (r *run) cleanup() {
+	if !r.created || r.opts.Keep {
+		return
+	}
+	ctx := context.WithoutCancel(r.ctx)
+	r.manager.Destroy(ctx, r.name, true)
+}
+
+// expectRunning checks podman has the puck's container running, or not
+func (r *run) expectRunning(want bool) error {
+	p, err := r.manager.Get(r.ctx, r.name)
+	if err != nil {
+		return err
+	}
+	running, err := r.podman.IsRunning(r.ctx, p.ContainerID)
+	if err != nil {
+		return err
+	}
+	if running != want {
+		return fmt.Errorf("container running is %t, expected %t", running, want)
+	}
+	return nil
+}
+
+// expectMarker checks the file exec wrote is in the puck
+func (r *run) expectMarker() error {
+	res, err := r.manager.Exec(r.ctx, puck.ExecOptions{Name: r.name, Cmd: []string{"cat", markerPath}})
+	if err != nil {
+		return err
+	}
+	if res.ExitCode != 0 || strings.TrimSpace(res.Output) != markerContent {
+		return fmt.Errorf("%s is missing or changed: %s", markerPath, strings.TrimSpace(res.Output))
+	}
+	return nil
+}
+
+// randomSuffix tells this run's puck apart from other runs'
+func randomSuffix() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Summary describes a report in one line
+func (rep *Report) Summary() string {
+	var passed, skips int
+	for _, s := range rep.Steps {
+		switch {
+		case s.Skipped != "":
+			skips++
+		case s.Error == "":
+			passed++
+		}
+	}
+	return fmt.Sprintf("%d passed, %d failed, %d skipped", passed, rep.Failed, skips)
+}
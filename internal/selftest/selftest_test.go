@@ -0,0 +1,30 @@
+package selftest
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunWithoutPodman(t *testing.T) {
+	var seen []string
+	socket := "unix://" + filepath.Join(t.TempDir(), "podman.sock")
+	report, err := Run(context.Background(), Options{PodmanSocket: socket}, func(s Step) {
+		seen = append(seen, s.Name)
+	})
+	require.NoError(t, err)
+
+	require.NotEmpty(t, report.Steps)
+	assert.Equal(t, "podman", report.Steps[0].Name)
+	assert.NotEmpty(t, report.Steps[0].Error)
+	for _, s := range report.Steps[1:] {
+		assert.Equal(t, "an earlier step failed", s.Skipped, s.Name)
+	}
+	assert.Equal(t, 1, report.Failed)
+	assert.Len(t, seen, len(report.Steps), "progress is told of every step")
+	assert.Empty(t, report.Dir, "the temporary directory is removed")
+	assert.Equal(t, "0 passed, 1 failed, 9 skipped", report.Summary())
+}
@@ -0,0 +1,160 @@
+// Package sshconfig keeps the ssh client configuration puck writes so
+// editors and plain ssh can reach pucks by name
+package sshconfig
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// FileName is puck's own ssh config file, included from ~/.ssh/config
+const FileName = "puck_config"
+
+// KeyName is the key pair puck generates for logging into pucks
+const KeyName = "puck_ed25519"
+
+// Entry is a Host block in puck's ssh config
+type Entry struct {
+	Host         string
+	User         string
+	IdentityFile string
+	ProxyCommand string
+}
+
+// String renders the entry as an ssh config Host block. Host keys are
+// generated afresh by each puck, so they aren't checked or remembered.
+func (e Entry) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Host %s\n", e.Host)
+	if e.User != "" {
+		fmt.Fprintf(&b, "  User %s\n", e.User)
+	}
+	if e.IdentityFile != "" {
+		fmt.Fprintf(&b, "  IdentityFile %s\n", e.IdentityFile)
+		b.WriteString("  IdentitiesOnly yes\n")
+	}
+	fmt.Fprintf(&b, "  ProxyCommand %s\n", e.ProxyCommand)
+	b.WriteString("  StrictHostKeyChecking no\n")
+	b.WriteString("  UserKnownHostsFile /dev/null\n")
+	b.WriteString("  LogLevel ERROR\n")
+	return b.String()
+}
+
+// DefaultDir returns ~/.ssh
+func DefaultDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".ssh")
+}
+
+// Write adds e to puck's ssh config in dir, replacing any entry for the
+// same host, and makes sure ~/.ssh/config includes the file
+func Write(dir string, e Entry) error {
+	if e.Host == "" || strings.ContainsAny(e.Host, " \t\r\n") {
+		return fmt.Errorf("invalid ssh host %q", e.Host)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, FileName)
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var blocks []string
+	for _, block := range splitHosts(string(data)) {
+		if hostOf(block) != e.Host {
+			blocks = append(blocks, block)
+		}
+	}
+	blocks = append(blocks, e.String())
+	if err := os.WriteFile(path, []byte(strings.Join(blocks, "\n")), 0600); err != nil {
+		return err
+	}
+
+	return ensureInclude(filepath.Join(dir, "config"))
+}
+
+// splitHosts splits an ssh config into Host blocks, each ending in a
+// newline, dropping blank lines between them
+func splitHosts(data string) []string {
+	var blocks []string
+	var cur strings.Builder
+	for _, line := range strings.SplitAfter(data, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "Host ") && cur.Len() > 0 {
+			blocks = append(blocks, cur.String())
+			cur.Reset()
+		}
+		cur.WriteString(strings.TrimRight(line, "\n") + "\n")
+	}
+	if cur.Len() > 0 {
+		blocks = append(blocks, cur.String())
+	}
+	return blocks
+}
+
+// hostOf returns the host pattern of a Host block
+func hostOf(block string) string {
+	line, _, _ := strings.Cut(block, "\n")
+	return strings.TrimSpace(strings.TrimPrefix(line, "Host "))
+}
+
+// ensureInclude puts an Include of puck's file at the top of the ssh
+// config, where it applies regardless of the Host blocks that follow
+func ensureInclude(path string) error {
+	include := "Include " + FileName
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == include {
+			return nil
+		}
+	}
+
+	var b bytes.Buffer
+	b.WriteString("# Added by puck\n" + include + "\n")
+	if len(data) > 0 {
+		b.WriteString("\n")
+		b.Write(data)
+	}
+	return os.WriteFile(path, b.Bytes(), 0600)
+}
+
+// EnsureKey generates puck's key pair in dir if it doesn't exist and
+// returns the path of the private key
+func EnsureKey(dir string) (string, error) {
+	path := filepath.Join(dir, KeyName)
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	out, err := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-C", "puck", "-f", path).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("generating ssh key: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return path, nil
+}
+
+// PublicKey returns puck's public key from dir
+func PublicKey(dir string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, KeyName+".pub"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("no puck ssh key in %s; run 'puck code <name>' to set one up", dir)
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
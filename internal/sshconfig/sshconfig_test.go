@@ -0,0 +1,47 @@
+package sshconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	entry := func(host, proxy string) Entry {
+		return Entry{Host: host, User: "root", IdentityFile: "~/.ssh/puck_ed25519", ProxyCommand: proxy}
+	}
+
+	t.Run("adds and replaces entries", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, Write(dir, entry("puck-api", "puck ssh-proxy api")))
+		require.NoError(t, Write(dir, entry("puck-web", "puck ssh-proxy web")))
+		require.NoError(t, Write(dir, entry("puck-api", "puck --context lab ssh-proxy api")))
+
+		data, err := os.ReadFile(filepath.Join(dir, FileName))
+		require.NoError(t, err)
+		blocks := splitHosts(string(data))
+		require.Len(t, blocks, 2)
+		assert.Equal(t, entry("puck-web", "puck ssh-proxy web").String(), blocks[0])
+		assert.Equal(t, entry("puck-api", "puck --context lab ssh-proxy api").String(), blocks[1])
+	})
+
+	t.Run("includes the file from ssh config once", func(t *testing.T) {
+		dir := t.TempDir()
+		config := filepath.Join(dir, "config")
+		require.NoError(t, os.WriteFile(config, []byte("Host homelab\n  User me\n"), 0600))
+
+		require.NoError(t, Write(dir, entry("puck-api", "puck ssh-proxy api")))
+		require.NoError(t, Write(dir, entry("puck-web", "puck ssh-proxy web")))
+
+		data, err := os.ReadFile(config)
+		require.NoError(t, err)
+		assert.Equal(t, "# Added by puck\nInclude puck_config\n\nHost homelab\n  User me\n", string(data))
+	})
+
+	t.Run("rejects bad hosts", func(t *testing.T) {
+		assert.Error(t, Write(t.TempDir(), entry("puck api", "x")))
+	})
+}
@@ -0,0 +1,95 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// BackupTo writes a consistent copy of the database to path, which must
+// not exist yet. Only SQLite databases can be copied this way; back up
+// Postgres with its own tools.
+func (db *DB) BackupTo(ctx context.Context, path string) error {
+	if db.path == "" {
+		return fmt.Errorf("backing up a Postgres database is not supported; use pg_dump")
+	}
+	if _, err := db.ExecContext(ctx, `VACUUM INTO ?`, path); err != nil {
+		return fmt.Errorf("copying database: %w", err)
+	}
+	return nil
+}
+
+// DropAllSnapshots forgets every snapshot, for a copy of the database that
+// leaves the snapshot files behind. Checkpointed and suspended pucks
+// become stopped.
+func (db *DB) DropAllSnapshots(ctx context.Context) error {
+	if _, err := db.ExecContext(ctx, `DELETE FROM snapshots`); err != nil {
+		return fmt.Errorf("dropping snapshots: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, `DELETE FROM stack_snapshots`); err != nil {
+		return fmt.Errorf("dropping stack snapshots: %w", err)
+	}
+	_, err := db.ExecContext(ctx, `
+		UPDATE pucks SET snapshot_head = '', resume_snapshot = '',
+			status = CASE WHEN status IN (?, ?) THEN ? ELSE status END
+	`, StatusCheckpointed, StatusSuspended, StatusStopped)
+	if err != nil {
+		return fmt.Errorf("clearing snapshot heads: %w", err)
+	}
+	return nil
+}
+
+// RebaseDataDir moves the volume directories and snapshot paths recorded
+// under one data directory to another, as when a backup is restored on a
+// host with a different home directory
+func (db *DB) RebaseDataDir(ctx context.Context, from, to string) error {
+	from = strings.TrimSuffix(from, "/") + "/"
+	to = strings.TrimSuffix(to, "/") + "/"
+	if from == to {
+		return nil
+	}
+
+	for _, col := range []struct{ table, column string }{
+		{"pucks", "volume_dir"},
+		{"snapshots", "path"},
+		{"snapshots", "volumes_path"},
+	} {
+		query := fmt.Sprintf(`UPDATE %[1]s SET %[2]s = ? || SUBSTR(%[2]s, ?) WHERE SUBSTR(%[2]s, 1, ?) = ?`, col.table, col.column)
+		if _, err := db.ExecContext(ctx, query, to, len(from)+1, len(from), from); err != nil {
+			return fmt.Errorf("rebasing %s.%s: %w", col.table, col.column, err)
+		}
+	}
+	return nil
+}
+
+// DetachContainers forgets every puck's container, for a database restored
+// on a host that doesn't have them. Pucks are left stopped, checkpointed,
+// or suspended, and get new containers when next started.
+func (db *DB) DetachContainers(ctx context.Context) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE pucks SET container_id = '', container_ip = '',
+			status = CASE WHEN status IN (?, ?) THEN status ELSE ? END
+	`, StatusCheckpointed, StatusSuspended, StatusStopped)
+	if err != nil {
+		return fmt.Errorf("detaching containers: %w", err)
+	}
+	return nil
+}
+
+// RecreateContainers marks every puck's container to be rebuilt from its
+// record when the puck is next started, as when the data directory its
+// volumes are mounted from has moved
+func (db *DB) RecreateContainers(ctx context.Context) error {
+	pucks, err := db.ListPucks(ctx)
+	if err != nil {
+		return err
+	}
+	for _, p := range pucks {
+		res := p.Resources
+		res.Pending = true
+		if err := db.UpdatePuckResources(ctx, p.Name, res); err != nil {
+			return fmt.Errorf("marking %s: %w", p.Name, err)
+		}
+	}
+	return nil
+}
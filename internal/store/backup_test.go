@@ -0,0 +1,124 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackupTo(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	p := createTestPuck("backup-puck")
+	require.NoError(t, db.CreatePuck(ctx, p))
+	require.NoError(t, db.CreateSnapshot(ctx, createTestSnapshot(p.ID, p.Name, "snap")))
+
+	dest := filepath.Join(t.TempDir(), "copy.db")
+	require.NoError(t, db.BackupTo(ctx, dest))
+
+	copied, err := Open(dest)
+	require.NoError(t, err)
+	defer copied.Close()
+
+	got, err := copied.GetPuck(ctx, "backup-puck")
+	require.NoError(t, err)
+	assert.Equal(t, p.ID, got.ID)
+	snapshots, err := copied.ListSnapshots(ctx, p.ID)
+	require.NoError(t, err)
+	assert.Len(t, snapshots, 1)
+
+	// The copy is separate from the original
+	require.NoError(t, copied.DeletePuck(ctx, "backup-puck"))
+	_, err = db.GetPuck(ctx, "backup-puck")
+	assert.NoError(t, err)
+
+	assert.Error(t, db.BackupTo(ctx, dest), "refuses to overwrite")
+}
+
+func TestRestoredDatabase(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	running := createTestPuck("running-puck")
+	running.ContainerID = "old-container"
+	running.ContainerIP = "10.88.0.5"
+	running.VolumeDir = "/home/alice/.local/share/puck/pucks/running-puck"
+	require.NoError(t, db.CreatePuck(ctx, running))
+
+	parked := createTestPuck("parked-puck")
+	parked.VolumeDir = "/elsewhere/parked-puck"
+	require.NoError(t, db.CreatePuck(ctx, parked))
+	require.NoError(t, db.UpdatePuckStatus(ctx, "parked-puck", StatusCheckpointed))
+
+	snap := createTestSnapshot(running.ID, running.Name, "snap")
+	snap.Path = "/home/alice/.local/share/puck/snapshots/running-puck/snap.tar.gz"
+	snap.VolumesPath = "/home/alice/.local/share/puck/snapshots/running-puck/snap.volumes.tar.gz"
+	require.NoError(t, db.CreateSnapshot(ctx, snap))
+	require.NoError(t, db.UpdatePuckSnapshotHead(ctx, "parked-puck", snap.ID))
+
+	t.Run("rebases paths under the data directory", func(t *testing.T) {
+		require.NoError(t, db.RebaseDataDir(ctx, "/home/alice/.local/share/puck", "/home/bob/.local/share/puck/"))
+
+		got, err := db.GetPuck(ctx, "running-puck")
+		require.NoError(t, err)
+		assert.Equal(t, "/home/bob/.local/share/puck/pucks/running-puck", got.VolumeDir)
+		got, err = db.GetPuck(ctx, "parked-puck")
+		require.NoError(t, err)
+		assert.Equal(t, "/elsewhere/parked-puck", got.VolumeDir)
+
+		s, err := db.GetSnapshot(ctx, running.ID, "snap")
+		require.NoError(t, err)
+		assert.Equal(t, "/home/bob/.local/share/puck/snapshots/running-puck/snap.tar.gz", s.Path)
+		assert.Equal(t, "/home/bob/.local/share/puck/snapshots/running-puck/snap.volumes.tar.gz", s.VolumesPath)
+	})
+
+	t.Run("detaches containers", func(t *testing.T) {
+		require.NoError(t, db.DetachContainers(ctx))
+
+		got, err := db.GetPuck(ctx, "running-puck")
+		require.NoError(t, err)
+		assert.Empty(t, got.ContainerID)
+		assert.Empty(t, got.ContainerIP)
+		assert.Equal(t, StatusStopped, got.Status)
+		got, err = db.GetPuck(ctx, "parked-puck")
+		require.NoError(t, err)
+		assert.Equal(t, StatusCheckpointed, got.Status)
+	})
+
+	t.Run("drops snapshots", func(t *testing.T) {
+		require.NoError(t, db.DropAllSnapshots(ctx))
+
+		snapshots, err := db.ListAllSnapshots(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, snapshots)
+		got, err := db.GetPuck(ctx, "parked-puck")
+		require.NoError(t, err)
+		assert.Equal(t, StatusStopped, got.Status)
+		assert.Empty(t, got.SnapshotHead)
+	})
+}
+
+func TestRecreateContainers(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	p := createTestPuck("moved-puck")
+	p.ContainerID = "container"
+	p.Resources = Resources{Memory: 512 << 20}
+	require.NoError(t, db.CreatePuck(ctx, p))
+
+	require.NoError(t, db.RecreateContainers(ctx))
+
+	got, err := db.GetPuck(ctx, "moved-puck")
+	require.NoError(t, err)
+	assert.True(t, got.Resources.Pending)
+	assert.Equal(t, int64(512<<20), got.Resources.Memory, "keeps the limits")
+	assert.Equal(t, "container", got.ContainerID, "keeps the container to remove on start")
+}
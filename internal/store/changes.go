@@ -0,0 +1,55 @@
+package store
+
+import (
+	"context"
+	"sync"
+)
+
+// changeFeed wakes those waiting for the database to be written to. It
+// counts writes made through this DB, and commits of its transactions,
+// not those of other processes sharing a Postgres database.
+type changeFeed struct {
+	mu      sync.Mutex
+	gen     uint64
+	changed chan struct{} // closed and replaced on every write
+}
+
+func newChangeFeed() *changeFeed {
+	return &changeFeed{changed: make(chan struct{})}
+}
+
+// bump records a write and wakes everyone waiting for one
+func (f *changeFeed) bump() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.gen++
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+// Generation returns a counter that moves on with every write to the
+// database, for passing to WaitChange
+func (db *DB) Generation() uint64 {
+	db.changes.mu.Lock()
+	defer db.changes.mu.Unlock()
+	return db.changes.gen
+}
+
+// WaitChange waits until the database has been written to since the
+// generation since, or until ctx is done, and returns the generation
+// then current
+func (db *DB) WaitChange(ctx context.Context, since uint64) uint64 {
+	for {
+		db.changes.mu.Lock()
+		gen, changed := db.changes.gen, db.changes.changed
+		db.changes.mu.Unlock()
+		if gen != since {
+			return gen
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return gen
+		}
+	}
+}
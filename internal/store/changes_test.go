@@ -0,0 +1,56 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaitChange(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	require.NoError(t, db.CreatePuck(ctx, createTestPuck("web")))
+	gen := db.Generation()
+
+	t.Run("returns at once when the database changed since", func(t *testing.T) {
+		require.NoError(t, db.UpdatePuckStatus(ctx, "web", StatusStopped))
+		assert.Greater(t, db.WaitChange(ctx, gen), gen)
+		gen = db.Generation()
+	})
+
+	t.Run("wakes on a write", func(t *testing.T) {
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			db.UpdatePuckStatus(ctx, "web", StatusRunning)
+		}()
+		assert.Greater(t, db.WaitChange(ctx, gen), gen)
+		gen = db.Generation()
+	})
+
+	t.Run("wakes on a commit but not a rollback", func(t *testing.T) {
+		err := db.InTx(ctx, func(tx *DB) error {
+			require.NoError(t, tx.UpdatePuckStatus(ctx, "web", StatusStopped))
+			return errors.New("rolled back")
+		})
+		require.Error(t, err)
+		assert.Equal(t, gen, db.Generation())
+
+		require.NoError(t, db.InTx(ctx, func(tx *DB) error {
+			return tx.UpdatePuckStatus(ctx, "web", StatusStopped)
+		}))
+		assert.Greater(t, db.Generation(), gen)
+		gen = db.Generation()
+	})
+
+	t.Run("gives up when the context is done", func(t *testing.T) {
+		waitCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		assert.Equal(t, gen, db.WaitChange(waitCtx, gen))
+	})
+}
@@ -3,19 +3,38 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 
+	_ "github.com/jackc/pgx/v5/stdlib"
 	_ "modernc.org/sqlite"
 )
 
+// Errors wrapped by lookups and creates, for callers to tell apart
+var (
+	ErrNotFound = errors.New("not found")
+	ErrExists   = errors.New("already exists")
+)
+
 // isDuplicateColumnError checks if the error is a "duplicate column" error
 func isDuplicateColumnError(err error) bool {
 	return strings.Contains(err.Error(), "duplicate column")
 }
 
+// isUniqueViolation checks if the error is an insert clashing with a
+// unique column, in SQLite's words or Postgres'
+func isUniqueViolation(err error) bool {
+	if err == nil {
+		return false
+	}
+	errStr := err.Error()
+	return strings.Contains(errStr, "UNIQUE constraint failed") ||
+		strings.Contains(errStr, "duplicate key value violates unique constraint")
+}
+
 // isTableExistsError checks if the error is a "table already exists" error
 func isTableExistsError(err error) bool {
 	errStr := err.Error()
@@ -23,10 +42,22 @@ func isTableExistsError(err error) bool {
 		strings.Contains(errStr, "already another table")
 }
 
-// DB wraps the SQLite database connection
+// DB wraps the database connection, SQLite by default or Postgres when
+// opened with a postgres:// URL. A DB returned by Begin runs every method
+// inside its transaction instead.
 type DB struct {
-	conn *sql.DB
-	path string
+	conn    *sql.DB
+	tx      *sql.Tx
+	path    string
+	dialect dialect
+	changes *changeFeed
+}
+
+// querier runs statements on the database or within a transaction
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
 }
 
 // Open opens the SQLite database at the given path
@@ -36,9 +67,25 @@ func Open(path string) (*DB, error) {
 		return nil, fmt.Errorf("creating database directory: %w", err)
 	}
 
-	// Open database with WAL mode and foreign keys
-	dsn := path + "?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)"
-	conn, err := sql.Open("sqlite", dsn)
+	// Open database with WAL mode and foreign keys. Writers wait for each
+	// other's transactions rather than failing with SQLITE_BUSY.
+	dsn := path + "?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)"
+	return open(sqliteDialect{}, dsn, path)
+}
+
+// OpenDSN opens the database a DSN names: a postgres:// or postgresql://
+// URL for a shared Postgres database, or otherwise a SQLite file path
+func OpenDSN(dsn string) (*DB, error) {
+	d := dialectFor(dsn)
+	if _, ok := d.(sqliteDialect); ok {
+		return Open(dsn)
+	}
+	return open(d, dsn, "")
+}
+
+// open connects with the dialect's driver and migrates the schema
+func open(d dialect, dsn, path string) (*DB, error) {
+	conn, err := sql.Open(d.driver(), dsn)
 	if err != nil {
 		return nil, fmt.Errorf("opening database: %w", err)
 	}
@@ -49,7 +96,7 @@ func Open(path string) (*DB, error) {
 		return nil, fmt.Errorf("pinging database: %w", err)
 	}
 
-	db := &DB{conn: conn, path: path}
+	db := &DB{conn: conn, path: path, dialect: d, changes: newChangeFeed()}
 
 	// Run migrations
 	if err := db.migrate(); err != nil {
@@ -67,53 +114,10 @@ func (db *DB) Close() error {
 
 // migrate runs database migrations
 func (db *DB) migrate() error {
-	migrations := []string{
-		// Create pucks table (renamed from sprites)
-		`CREATE TABLE IF NOT EXISTS pucks (
-			id TEXT PRIMARY KEY,
-			name TEXT UNIQUE NOT NULL,
-			image TEXT NOT NULL,
-			status TEXT NOT NULL DEFAULT 'stopped',
-			volume_dir TEXT NOT NULL,
-			ports TEXT DEFAULT '[]',
-			host_port INTEGER DEFAULT 0,
-			container_ip TEXT DEFAULT '',
-			tailscale_ip TEXT DEFAULT '',
-			funnel_url TEXT DEFAULT '',
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)`,
-		// Migration: rename sprites table to pucks if sprites exists
-		`ALTER TABLE sprites RENAME TO pucks`,
-		// Migration: add host_port column if not exists
-		`ALTER TABLE pucks ADD COLUMN host_port INTEGER DEFAULT 0`,
-		// Create snapshots table with puck references
-		`CREATE TABLE IF NOT EXISTS snapshots (
-			id TEXT PRIMARY KEY,
-			puck_id TEXT NOT NULL,
-			puck_name TEXT NOT NULL,
-			name TEXT NOT NULL,
-			path TEXT NOT NULL,
-			size_bytes INTEGER DEFAULT 0,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			FOREIGN KEY (puck_id) REFERENCES pucks(id) ON DELETE CASCADE,
-			UNIQUE(puck_id, name)
-		)`,
-		// Migration: rename sprite columns in snapshots if they exist
-		`ALTER TABLE snapshots RENAME COLUMN sprite_id TO puck_id`,
-		`ALTER TABLE snapshots RENAME COLUMN sprite_name TO puck_name`,
-		// Create indexes
-		`CREATE INDEX IF NOT EXISTS idx_pucks_name ON pucks(name)`,
-		`CREATE INDEX IF NOT EXISTS idx_pucks_status ON pucks(status)`,
-		`CREATE INDEX IF NOT EXISTS idx_snapshots_puck ON snapshots(puck_id)`,
-	}
-
-	for _, m := range migrations {
+	for _, m := range db.dialect.migrations() {
 		if _, err := db.conn.Exec(m); err != nil {
 			// Ignore expected errors from migrations
-			if !isDuplicateColumnError(err) && !isTableExistsError(err) &&
-				!strings.Contains(err.Error(), "no such table") &&
-				!strings.Contains(err.Error(), "no such column") {
+			if !db.dialect.ignoreMigrationError(err) {
 				return fmt.Errorf("executing migration: %w", err)
 			}
 		}
@@ -122,17 +126,314 @@ func (db *DB) migrate() error {
 	return nil
 }
 
+// sqliteMigrations brings a SQLite database up to the current schema.
+// Columns added later are ALTER TABLE statements whose "duplicate column"
+// errors are ignored on databases that already have them.
+var sqliteMigrations = []string{
+	// Create pucks table (renamed from sprites)
+	`CREATE TABLE IF NOT EXISTS pucks (
+		id TEXT PRIMARY KEY,
+		name TEXT UNIQUE NOT NULL,
+		image TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'stopped',
+		volume_dir TEXT NOT NULL,
+		ports TEXT DEFAULT '[]',
+		host_port INTEGER DEFAULT 0,
+		container_ip TEXT DEFAULT '',
+		tailscale_ip TEXT DEFAULT '',
+		funnel_url TEXT DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`,
+	// Migration: rename sprites table to pucks if sprites exists
+	`ALTER TABLE sprites RENAME TO pucks`,
+	// Migration: add host_port column if not exists
+	`ALTER TABLE pucks ADD COLUMN host_port INTEGER DEFAULT 0`,
+	// Migration: add per-puck router settings
+	`ALTER TABLE pucks ADD COLUMN route_config TEXT DEFAULT '{}'`,
+	// Migration: track which user owns each puck
+	`ALTER TABLE pucks ADD COLUMN owner TEXT DEFAULT ''`,
+	// Migration: per-puck tailnet node settings
+	`ALTER TABLE pucks ADD COLUMN tailnet_share TEXT DEFAULT ''`,
+	// Migration: per-puck CPU and memory limits
+	`ALTER TABLE pucks ADD COLUMN resources TEXT DEFAULT '{}'`,
+	// Migration: when each puck was last used, for LRU checkpointing
+	`ALTER TABLE pucks ADD COLUMN last_used_at DATETIME`,
+	// Migration: how each puck's container is built
+	`ALTER TABLE pucks ADD COLUMN spec TEXT DEFAULT '{}'`,
+	// Migration: pucks keep their ID when their container is replaced.
+	// Older pucks used their container's ID as their own, so it names
+	// both until the container is next replaced. Only those start out
+	// NULL; an empty container_id is a puck without a container.
+	`ALTER TABLE pucks ADD COLUMN container_id TEXT`,
+	`UPDATE pucks SET container_id = id WHERE container_id IS NULL`,
+	// Create snapshots table with puck references
+	`CREATE TABLE IF NOT EXISTS snapshots (
+		id TEXT PRIMARY KEY,
+		puck_id TEXT NOT NULL,
+		puck_name TEXT NOT NULL,
+		name TEXT NOT NULL,
+		path TEXT NOT NULL,
+		size_bytes INTEGER DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (puck_id) REFERENCES pucks(id) ON DELETE CASCADE,
+		UNIQUE(puck_id, name)
+	)`,
+	// Migration: rename sprite columns in snapshots if they exist
+	`ALTER TABLE snapshots RENAME COLUMN sprite_id TO puck_id`,
+	`ALTER TABLE snapshots RENAME COLUMN sprite_name TO puck_name`,
+	// Migration: snapshot tree with named points
+	`ALTER TABLE snapshots ADD COLUMN parent_id TEXT DEFAULT ''`,
+	`ALTER TABLE snapshots ADD COLUMN tags TEXT DEFAULT '[]'`,
+	`ALTER TABLE pucks ADD COLUMN snapshot_head TEXT DEFAULT ''`,
+	// Migration: record the image each snapshot was taken from
+	`ALTER TABLE snapshots ADD COLUMN image TEXT DEFAULT ''`,
+	// Migration: commit-based snapshots alongside CRIU checkpoints
+	`ALTER TABLE snapshots ADD COLUMN mode TEXT DEFAULT 'checkpoint'`,
+	`ALTER TABLE snapshots ADD COLUMN commit_image TEXT DEFAULT ''`,
+	// Migration: CRIU version checkpoints were taken with
+	`ALTER TABLE snapshots ADD COLUMN criu_version TEXT DEFAULT ''`,
+	// Migration: host a checkpoint was taken on, checked before restoring
+	`ALTER TABLE snapshots ADD COLUMN kernel TEXT DEFAULT ''`,
+	`ALTER TABLE snapshots ADD COLUMN podman_version TEXT DEFAULT ''`,
+	// Migration: where a snapshot's archive was moved in cold storage
+	`ALTER TABLE snapshots ADD COLUMN cold_path TEXT DEFAULT ''`,
+	// Migration: CRIU options each checkpoint was taken with. Earlier
+	// checkpoints always included established TCP connections.
+	`ALTER TABLE snapshots ADD COLUMN criu_options TEXT DEFAULT '{"tcp_established":true}'`,
+	// Migration: pucks that must be running before each puck starts
+	`ALTER TABLE pucks ADD COLUMN requires TEXT DEFAULT '[]'`,
+	// Migration: where each puck may open connections to
+	`ALTER TABLE pucks ADD COLUMN egress TEXT DEFAULT '{}'`,
+	// Migration: project each puck counts against for quotas
+	`ALTER TABLE pucks ADD COLUMN project TEXT DEFAULT ''`,
+	// Migration: checkpoint each suspended puck resumes from
+	`ALTER TABLE pucks ADD COLUMN resume_snapshot TEXT DEFAULT ''`,
+	// Migration: per-puck snapshot defaults and schedule
+	`ALTER TABLE pucks ADD COLUMN snapshot_policy TEXT DEFAULT '{}'`,
+	// Migration: volume directories archived alongside a checkpoint
+	`ALTER TABLE snapshots ADD COLUMN volumes_path TEXT DEFAULT ''`,
+	// Migration: digest of the image a checkpoint's container ran
+	`ALTER TABLE snapshots ADD COLUMN image_digest TEXT DEFAULT ''`,
+	// Migration: host ports chosen by the user, which are never moved
+	`ALTER TABLE pucks ADD COLUMN host_port_pinned INTEGER DEFAULT 0`,
+	// Migration: host ports podman gave a puck's exposed ports
+	`ALTER TABLE pucks ADD COLUMN published TEXT DEFAULT '[]'`,
+	// Migration: what each puck is for, in the user's words
+	`ALTER TABLE pucks ADD COLUMN notes TEXT DEFAULT ''`,
+	// Create shares table for expiring public links
+	`CREATE TABLE IF NOT EXISTS shares (
+		id TEXT PRIMARY KEY,
+		puck_name TEXT NOT NULL,
+		token TEXT UNIQUE NOT NULL,
+		created_by TEXT DEFAULT '',
+		expires_at DATETIME NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`,
+	// Create routes table for extra paths served by a puck
+	`CREATE TABLE IF NOT EXISTS routes (
+		path TEXT PRIMARY KEY,
+		puck_name TEXT NOT NULL,
+		created_by TEXT DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`,
+	// Create stack_snapshots table grouping snapshots restored as a unit
+	`CREATE TABLE IF NOT EXISTS stack_snapshots (
+		id TEXT PRIMARY KEY,
+		puck_name TEXT NOT NULL,
+		name TEXT NOT NULL,
+		members TEXT DEFAULT '[]',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(puck_name, name)
+	)`,
+	// Create events table for per-puck lifecycle history
+	`CREATE TABLE IF NOT EXISTS events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		puck_name TEXT NOT NULL,
+		type TEXT NOT NULL,
+		detail TEXT DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`,
+	// Create stats table holding the daemon's samples of pucks' resource
+	// use and requests served
+	`CREATE TABLE IF NOT EXISTS stats (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		puck_name TEXT NOT NULL,
+		up INTEGER DEFAULT 0,
+		cpu REAL DEFAULT 0,
+		memory INTEGER DEFAULT 0,
+		disk INTEGER DEFAULT 0,
+		hits INTEGER DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`,
+	// Create intents table journaling operations in progress
+	`CREATE TABLE IF NOT EXISTS intents (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		op TEXT NOT NULL,
+		puck_name TEXT NOT NULL,
+		puck_id TEXT DEFAULT '',
+		container_id TEXT DEFAULT '',
+		volume_dir TEXT DEFAULT '',
+		owns_volume INTEGER DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`,
+	// Create port_reservations table holding host ports given to pucks,
+	// filled from existing pucks
+	`CREATE TABLE IF NOT EXISTS port_reservations (
+		port INTEGER PRIMARY KEY,
+		puck_name TEXT NOT NULL,
+		reserved_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`INSERT INTO port_reservations (port, puck_name)
+		SELECT host_port, name FROM pucks WHERE host_port > 0
+		ON CONFLICT (port) DO NOTHING`,
+	// Create endpoints table holding extra container ports a puck serves
+	// through the router
+	`CREATE TABLE IF NOT EXISTS endpoints (
+		puck_name TEXT NOT NULL,
+		name TEXT NOT NULL,
+		container_port INTEGER NOT NULL,
+		host_port INTEGER DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(puck_name, name)
+	)`,
+	// Create puck_groups table naming sets of pucks that commands act on
+	// together, one row per member
+	`CREATE TABLE IF NOT EXISTS puck_groups (
+		owner TEXT NOT NULL DEFAULT '',
+		name TEXT NOT NULL,
+		puck_name TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (owner, name, puck_name)
+	)`,
+	// Add pool column naming the pool a puck waits in to be handed out,
+	// and create pools table holding their settings
+	`ALTER TABLE pucks ADD COLUMN pool TEXT DEFAULT ''`,
+	`CREATE TABLE IF NOT EXISTS pools (
+		owner TEXT NOT NULL DEFAULT '',
+		name TEXT NOT NULL,
+		size INTEGER NOT NULL DEFAULT 0,
+		name_pattern TEXT DEFAULT '',
+		options TEXT DEFAULT '{}',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (owner, name)
+	)`,
+	// Add expires_at and expiry_snapshot columns for pucks destroyed once
+	// their TTL runs out
+	`ALTER TABLE pucks ADD COLUMN expires_at DATETIME`,
+	`ALTER TABLE pucks ADD COLUMN expiry_snapshot INTEGER DEFAULT 0`,
+	// Create indexes
+	`CREATE INDEX IF NOT EXISTS idx_pucks_name ON pucks(name)`,
+	`CREATE INDEX IF NOT EXISTS idx_pucks_status ON pucks(status)`,
+	`CREATE INDEX IF NOT EXISTS idx_snapshots_puck ON snapshots(puck_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_shares_puck ON shares(puck_name)`,
+	`CREATE INDEX IF NOT EXISTS idx_routes_puck ON routes(puck_name)`,
+	`CREATE INDEX IF NOT EXISTS idx_events_puck ON events(puck_name)`,
+	`CREATE INDEX IF NOT EXISTS idx_stats_puck ON stats(puck_name)`,
+	`CREATE INDEX IF NOT EXISTS idx_port_reservations_puck ON port_reservations(puck_name)`,
+	`CREATE INDEX IF NOT EXISTS idx_endpoints_puck ON endpoints(puck_name)`,
+	`CREATE INDEX IF NOT EXISTS idx_puck_groups_puck ON puck_groups(puck_name)`,
+}
+
+// Begin starts a transaction and returns a DB whose methods run inside it.
+// It must be finished with Commit or Rollback.
+func (db *DB) Begin(ctx context.Context) (*DB, error) {
+	if db.tx != nil {
+		return nil, fmt.Errorf("transaction already in progress")
+	}
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("starting transaction: %w", err)
+	}
+	return &DB{conn: db.conn, tx: tx, path: db.path, dialect: db.dialect, changes: db.changes}, nil
+}
+
+// Commit commits a DB returned by Begin
+func (db *DB) Commit() error {
+	if db.tx == nil {
+		return fmt.Errorf("no transaction in progress")
+	}
+	if err := db.tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	db.changes.bump()
+	return nil
+}
+
+// Rollback discards a DB returned by Begin. It is a no-op once the
+// transaction is committed, so it can be deferred.
+func (db *DB) Rollback() error {
+	if db.tx == nil {
+		return fmt.Errorf("no transaction in progress")
+	}
+	if err := db.tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		return fmt.Errorf("rolling back transaction: %w", err)
+	}
+	return nil
+}
+
+// InTx runs fn in a transaction, committing if it returns nil and rolling
+// back otherwise. Called on a DB already in a transaction, fn joins it.
+func (db *DB) InTx(ctx context.Context, fn func(tx *DB) error) error {
+	if db.tx != nil {
+		return fn(db)
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// q returns where statements run: the transaction, if any, or the database
+func (db *DB) q() querier {
+	if db.tx != nil {
+		return db.tx
+	}
+	return db.conn
+}
+
 // ExecContext executes a query with context
 func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
-	return db.conn.ExecContext(ctx, query, args...)
+	result, err := db.q().ExecContext(ctx, db.dialect.rebind(query), args...)
+	if err == nil && db.tx == nil {
+		db.changes.bump()
+	}
+	return result, err
 }
 
 // QueryContext executes a query and returns rows
 func (db *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
-	return db.conn.QueryContext(ctx, query, args...)
+	return db.q().QueryContext(ctx, db.dialect.rebind(query), args...)
 }
 
 // QueryRowContext executes a query and returns a single row
 func (db *DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
-	return db.conn.QueryRowContext(ctx, query, args...)
+	return db.q().QueryRowContext(ctx, db.dialect.rebind(query), args...)
+}
+
+// insert runs an INSERT into a table with an id sequence and returns the
+// new row's ID
+func (db *DB) insert(ctx context.Context, query string, args ...any) (int64, error) {
+	if db.dialect.returningID() {
+		var id int64
+		err := db.QueryRowContext(ctx, query+" RETURNING id", args...).Scan(&id)
+		if err == nil && db.tx == nil {
+			db.changes.bump()
+		}
+		return id, err
+	}
+
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	id, _ := result.LastInsertId()
+	return id, nil
 }
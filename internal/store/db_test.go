@@ -1,10 +1,13 @@
 package store
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -119,6 +122,43 @@ func TestOpen(t *testing.T) {
 		assert.Equal(t, 1, fkEnabled, "foreign keys should be enabled")
 	})
 
+	t.Run("gives older pucks their ID as container ID", func(t *testing.T) {
+		dir, err := os.MkdirTemp("", "puck-test-*")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		// A database from before pucks recorded their container separately
+		dbPath := filepath.Join(dir, "test.db")
+		legacy, err := sql.Open("sqlite", dbPath)
+		require.NoError(t, err)
+		_, err = legacy.Exec(`CREATE TABLE pucks (
+			id TEXT PRIMARY KEY,
+			name TEXT UNIQUE NOT NULL,
+			image TEXT NOT NULL,
+			status TEXT NOT NULL DEFAULT 'stopped',
+			volume_dir TEXT NOT NULL,
+			ports TEXT DEFAULT '[]',
+			container_ip TEXT DEFAULT '',
+			tailscale_ip TEXT DEFAULT '',
+			funnel_url TEXT DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`)
+		require.NoError(t, err)
+		_, err = legacy.Exec(`INSERT INTO pucks (id, name, image, volume_dir) VALUES ('abc123', 'old', 'fedora', '/tmp/old')`)
+		require.NoError(t, err)
+		legacy.Close()
+
+		db, err := Open(dbPath)
+		require.NoError(t, err)
+		defer db.Close()
+
+		p, err := db.GetPuck(context.Background(), "old")
+		require.NoError(t, err)
+		assert.Equal(t, "abc123", p.ID)
+		assert.Equal(t, "abc123", p.ContainerID)
+	})
+
 	t.Run("enables WAL mode", func(t *testing.T) {
 		dir, err := os.MkdirTemp("", "puck-test-*")
 		require.NoError(t, err)
@@ -151,6 +191,78 @@ func TestClose(t *testing.T) {
 	})
 }
 
+func TestTransactions(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("commits all writes together", func(t *testing.T) {
+		db, cleanup := setupTestDB(t)
+		defer cleanup()
+
+		err := db.InTx(ctx, func(tx *DB) error {
+			if err := tx.CreatePuck(ctx, createTestPuck("web")); err != nil {
+				return err
+			}
+			return tx.RecordEvent(ctx, &Event{PuckName: "web", Type: EventCreated})
+		})
+		require.NoError(t, err)
+
+		_, err = db.GetPuck(ctx, "web")
+		assert.NoError(t, err)
+		events, err := db.ListEvents(ctx, "web", time.Time{})
+		require.NoError(t, err)
+		assert.Len(t, events, 1)
+	})
+
+	t.Run("rolls back all writes on error", func(t *testing.T) {
+		db, cleanup := setupTestDB(t)
+		defer cleanup()
+
+		err := db.InTx(ctx, func(tx *DB) error {
+			if err := tx.CreatePuck(ctx, createTestPuck("web")); err != nil {
+				return err
+			}
+			return assert.AnError
+		})
+		assert.ErrorIs(t, err, assert.AnError)
+
+		_, err = db.GetPuck(ctx, "web")
+		assert.Error(t, err)
+	})
+
+	t.Run("nested calls join the transaction", func(t *testing.T) {
+		db, cleanup := setupTestDB(t)
+		defer cleanup()
+
+		err := db.InTx(ctx, func(tx *DB) error {
+			require.NoError(t, tx.CreatePuck(ctx, createTestPuck("web")))
+			require.NoError(t, tx.UpdatePuckContainerID(ctx, "web", "new-id"))
+			return assert.AnError
+		})
+		assert.ErrorIs(t, err, assert.AnError)
+
+		_, err = db.GetPuck(ctx, "web")
+		assert.Error(t, err)
+	})
+
+	t.Run("begin, commit and rollback", func(t *testing.T) {
+		db, cleanup := setupTestDB(t)
+		defer cleanup()
+
+		tx, err := db.Begin(ctx)
+		require.NoError(t, err)
+		require.NoError(t, tx.CreatePuck(ctx, createTestPuck("web")))
+		require.NoError(t, tx.Commit())
+		assert.NoError(t, tx.Rollback(), "rollback after commit is a no-op")
+
+		_, err = tx.Begin(ctx)
+		assert.Error(t, err, "transactions don't nest")
+		assert.Error(t, db.Commit(), "no transaction to commit")
+
+		_, err = db.GetPuck(ctx, "web")
+		assert.NoError(t, err)
+	})
+}
+
 func TestIsDuplicateColumnError(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -0,0 +1,246 @@
+package store
+
+import (
+	"strconv"
+	"strings"
+)
+
+// dialect covers what differs between the databases the store runs on.
+// Queries are written once with ? placeholders and rebound for each.
+type dialect interface {
+	// driver is the database/sql driver name to open
+	driver() string
+	// rebind rewrites ? placeholders into the database's own form
+	rebind(query string) string
+	// migrations brings a database up to the current schema
+	migrations() []string
+	// ignoreMigrationError reports whether a migration failed only
+	// because it had already been applied
+	ignoreMigrationError(err error) bool
+	// returningID reports whether inserts return their ID with
+	// RETURNING id rather than through LastInsertId
+	returningID() bool
+}
+
+// sqliteDialect is the default local database
+type sqliteDialect struct{}
+
+func (sqliteDialect) driver() string { return "sqlite" }
+
+func (sqliteDialect) rebind(query string) string { return query }
+
+func (sqliteDialect) migrations() []string { return sqliteMigrations }
+
+func (sqliteDialect) ignoreMigrationError(err error) bool {
+	msg := err.Error()
+	return isDuplicateColumnError(err) || isTableExistsError(err) ||
+		strings.Contains(msg, "no such table") ||
+		strings.Contains(msg, "no such column")
+}
+
+func (sqliteDialect) returningID() bool { return false }
+
+// postgresDialect is a shared database for several hosts
+type postgresDialect struct{}
+
+func (postgresDialect) driver() string { return "pgx" }
+
+// rebind numbers placeholders $1, $2, ... skipping any ? inside quoted
+// strings or identifiers
+func (postgresDialect) rebind(query string) string {
+	var b strings.Builder
+	b.Grow(len(query) + 16)
+
+	n := 0
+	var quote rune
+	for _, r := range query {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '?':
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (postgresDialect) migrations() []string { return postgresMigrations }
+
+// ignoreMigrationError is always false: Postgres migrations are written to
+// be rerun with IF NOT EXISTS
+func (postgresDialect) ignoreMigrationError(err error) bool { return false }
+
+func (postgresDialect) returningID() bool { return true }
+
+// dialectFor picks the database a DSN names: postgres:// and
+// postgresql:// URLs are Postgres, anything else is a SQLite file path
+func dialectFor(dsn string) dialect {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return postgresDialect{}
+	}
+	return sqliteDialect{}
+}
+
+// postgresMigrations creates the current schema. Postgres support started
+// after the SQLite renames, so only columns added from here on need their
+// own ALTER TABLE ... ADD COLUMN IF NOT EXISTS.
+var postgresMigrations = []string{
+	`CREATE TABLE IF NOT EXISTS pucks (
+		id TEXT PRIMARY KEY,
+		name TEXT UNIQUE NOT NULL,
+		image TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'stopped',
+		volume_dir TEXT NOT NULL,
+		ports TEXT DEFAULT '[]',
+		host_port INTEGER DEFAULT 0,
+		container_ip TEXT DEFAULT '',
+		tailscale_ip TEXT DEFAULT '',
+		funnel_url TEXT DEFAULT '',
+		route_config TEXT DEFAULT '{}',
+		owner TEXT DEFAULT '',
+		tailnet_share TEXT DEFAULT '',
+		resources TEXT DEFAULT '{}',
+		last_used_at TIMESTAMPTZ,
+		spec TEXT DEFAULT '{}',
+		snapshot_head TEXT DEFAULT '',
+		created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+	)`,
+	// Pucks keep their ID when their container is replaced; older pucks
+	// used their container's ID as their own, and only those start out NULL
+	`ALTER TABLE pucks ADD COLUMN IF NOT EXISTS container_id TEXT`,
+	`UPDATE pucks SET container_id = id WHERE container_id IS NULL`,
+	`CREATE TABLE IF NOT EXISTS snapshots (
+		id TEXT PRIMARY KEY,
+		puck_id TEXT NOT NULL,
+		puck_name TEXT NOT NULL,
+		name TEXT NOT NULL,
+		path TEXT NOT NULL,
+		size_bytes BIGINT DEFAULT 0,
+		parent_id TEXT DEFAULT '',
+		tags TEXT DEFAULT '[]',
+		image TEXT DEFAULT '',
+		mode TEXT DEFAULT 'checkpoint',
+		commit_image TEXT DEFAULT '',
+		created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (puck_id) REFERENCES pucks(id) ON DELETE CASCADE,
+		UNIQUE(puck_id, name)
+	)`,
+	`ALTER TABLE snapshots ADD COLUMN IF NOT EXISTS criu_version TEXT DEFAULT ''`,
+	`ALTER TABLE snapshots ADD COLUMN IF NOT EXISTS kernel TEXT DEFAULT ''`,
+	`ALTER TABLE snapshots ADD COLUMN IF NOT EXISTS podman_version TEXT DEFAULT ''`,
+	`ALTER TABLE snapshots ADD COLUMN IF NOT EXISTS cold_path TEXT DEFAULT ''`,
+	`ALTER TABLE snapshots ADD COLUMN IF NOT EXISTS criu_options TEXT DEFAULT '{"tcp_established":true}'`,
+	`ALTER TABLE pucks ADD COLUMN IF NOT EXISTS requires TEXT DEFAULT '[]'`,
+	`ALTER TABLE pucks ADD COLUMN IF NOT EXISTS egress TEXT DEFAULT '{}'`,
+	`ALTER TABLE pucks ADD COLUMN IF NOT EXISTS project TEXT DEFAULT ''`,
+	`ALTER TABLE pucks ADD COLUMN IF NOT EXISTS resume_snapshot TEXT DEFAULT ''`,
+	`ALTER TABLE pucks ADD COLUMN IF NOT EXISTS snapshot_policy TEXT DEFAULT '{}'`,
+	`ALTER TABLE snapshots ADD COLUMN IF NOT EXISTS volumes_path TEXT DEFAULT ''`,
+	`ALTER TABLE snapshots ADD COLUMN IF NOT EXISTS image_digest TEXT DEFAULT ''`,
+	`ALTER TABLE pucks ADD COLUMN IF NOT EXISTS host_port_pinned BOOLEAN DEFAULT FALSE`,
+	`ALTER TABLE pucks ADD COLUMN IF NOT EXISTS published TEXT DEFAULT '[]'`,
+	`ALTER TABLE pucks ADD COLUMN IF NOT EXISTS notes TEXT DEFAULT ''`,
+	`CREATE TABLE IF NOT EXISTS shares (
+		id TEXT PRIMARY KEY,
+		puck_name TEXT NOT NULL,
+		token TEXT UNIQUE NOT NULL,
+		created_by TEXT DEFAULT '',
+		expires_at TIMESTAMPTZ NOT NULL,
+		created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS routes (
+		path TEXT PRIMARY KEY,
+		puck_name TEXT NOT NULL,
+		created_by TEXT DEFAULT '',
+		created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS stack_snapshots (
+		id TEXT PRIMARY KEY,
+		puck_name TEXT NOT NULL,
+		name TEXT NOT NULL,
+		members TEXT DEFAULT '[]',
+		created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(puck_name, name)
+	)`,
+	`CREATE TABLE IF NOT EXISTS events (
+		id BIGSERIAL PRIMARY KEY,
+		puck_name TEXT NOT NULL,
+		type TEXT NOT NULL,
+		detail TEXT DEFAULT '',
+		created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS stats (
+		id BIGSERIAL PRIMARY KEY,
+		puck_name TEXT NOT NULL,
+		up BOOLEAN DEFAULT FALSE,
+		cpu DOUBLE PRECISION DEFAULT 0,
+		memory BIGINT DEFAULT 0,
+		disk BIGINT DEFAULT 0,
+		hits BIGINT DEFAULT 0,
+		created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS intents (
+		id BIGSERIAL PRIMARY KEY,
+		op TEXT NOT NULL,
+		puck_name TEXT NOT NULL,
+		puck_id TEXT DEFAULT '',
+		container_id TEXT DEFAULT '',
+		volume_dir TEXT DEFAULT '',
+		owns_volume BOOLEAN DEFAULT FALSE,
+		created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS port_reservations (
+		port INTEGER PRIMARY KEY,
+		puck_name TEXT NOT NULL,
+		reserved_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`INSERT INTO port_reservations (port, puck_name)
+		SELECT host_port, name FROM pucks WHERE host_port > 0
+		ON CONFLICT (port) DO NOTHING`,
+	`CREATE TABLE IF NOT EXISTS endpoints (
+		puck_name TEXT NOT NULL,
+		name TEXT NOT NULL,
+		container_port INTEGER NOT NULL,
+		host_port INTEGER DEFAULT 0,
+		created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(puck_name, name)
+	)`,
+	`CREATE TABLE IF NOT EXISTS puck_groups (
+		owner TEXT NOT NULL DEFAULT '',
+		name TEXT NOT NULL,
+		puck_name TEXT NOT NULL,
+		created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (owner, name, puck_name)
+	)`,
+	`ALTER TABLE pucks ADD COLUMN IF NOT EXISTS pool TEXT DEFAULT ''`,
+	`CREATE TABLE IF NOT EXISTS pools (
+		owner TEXT NOT NULL DEFAULT '',
+		name TEXT NOT NULL,
+		size INTEGER NOT NULL DEFAULT 0,
+		name_pattern TEXT DEFAULT '',
+		options TEXT DEFAULT '{}',
+		created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (owner, name)
+	)`,
+	`ALTER TABLE pucks ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ`,
+	`ALTER TABLE pucks ADD COLUMN IF NOT EXISTS expiry_snapshot BOOLEAN DEFAULT FALSE`,
+	`CREATE INDEX IF NOT EXISTS idx_pucks_name ON pucks(name)`,
+	`CREATE INDEX IF NOT EXISTS idx_pucks_status ON pucks(status)`,
+	`CREATE INDEX IF NOT EXISTS idx_snapshots_puck ON snapshots(puck_id)`,
+	`CREATE INDEX IF NOT EXISTS idx_shares_puck ON shares(puck_name)`,
+	`CREATE INDEX IF NOT EXISTS idx_routes_puck ON routes(puck_name)`,
+	`CREATE INDEX IF NOT EXISTS idx_events_puck ON events(puck_name)`,
+	`CREATE INDEX IF NOT EXISTS idx_stats_puck ON stats(puck_name)`,
+	`CREATE INDEX IF NOT EXISTS idx_port_reservations_puck ON port_reservations(puck_name)`,
+	`CREATE INDEX IF NOT EXISTS idx_endpoints_puck ON endpoints(puck_name)`,
+	`CREATE INDEX IF NOT EXISTS idx_puck_groups_puck ON puck_groups(puck_name)`,
+}
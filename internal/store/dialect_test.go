@@ -0,0 +1,76 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialectFor(t *testing.T) {
+	assert.IsType(t, sqliteDialect{}, dialectFor("/var/lib/puck/puck.db"))
+	assert.IsType(t, postgresDialect{}, dialectFor("postgres://puck@db.example.com/puck"))
+	assert.IsType(t, postgresDialect{}, dialectFor("postgresql://puck@db.example.com/puck?sslmode=require"))
+}
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{
+			name:     "numbers placeholders",
+			query:    `UPDATE pucks SET status = ?, updated_at = ? WHERE name = ?`,
+			expected: `UPDATE pucks SET status = $1, updated_at = $2 WHERE name = $3`,
+		},
+		{
+			name:     "skips quoted strings",
+			query:    `SELECT id FROM pucks WHERE status = '?' AND name = ?`,
+			expected: `SELECT id FROM pucks WHERE status = '?' AND name = $1`,
+		},
+		{
+			name:     "skips quoted identifiers",
+			query:    `SELECT "?" FROM pucks WHERE name = ?`,
+			expected: `SELECT "?" FROM pucks WHERE name = $1`,
+		},
+		{
+			name:     "no placeholders",
+			query:    `SELECT COUNT(*) FROM pucks`,
+			expected: `SELECT COUNT(*) FROM pucks`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, postgresDialect{}.rebind(tt.query))
+			assert.Equal(t, tt.query, sqliteDialect{}.rebind(tt.query))
+		})
+	}
+}
+
+func TestOpenDSN(t *testing.T) {
+	t.Run("opens SQLite paths", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "puck.db")
+
+		db, err := OpenDSN(path)
+		require.NoError(t, err)
+		defer db.Close()
+
+		assert.IsType(t, sqliteDialect{}, db.dialect)
+		assert.FileExists(t, path)
+
+		// Transactions carry the dialect with them
+		require.NoError(t, db.InTx(context.Background(), func(tx *DB) error {
+			assert.IsType(t, sqliteDialect{}, tx.dialect)
+			return nil
+		}))
+	})
+
+	t.Run("reports unreachable Postgres", func(t *testing.T) {
+		_, err := OpenDSN("postgres://puck@127.0.0.1:1/puck?connect_timeout=1")
+		assert.ErrorContains(t, err, "pinging database")
+	})
+}
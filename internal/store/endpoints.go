@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"fmt"
+)
+
+const endpointColumns = `puck_name, name, container_port, host_port, created_at`
+
+// CreateEndpoint stores a new endpoint, refusing a name the puck already
+// has with ErrExists
+func (db *DB) CreateEndpoint(ctx context.Context, e *Endpoint) error {
+	result, err := db.ExecContext(ctx, `
+		INSERT INTO endpoints (`+endpointColumns+`)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (puck_name, name) DO NOTHING
+	`, e.PuckName, e.Name, e.ContainerPort, e.HostPort, e.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("storing endpoint: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("endpoint '%s' of puck '%s' %w", e.Name, e.PuckName, ErrExists)
+	}
+	return nil
+}
+
+// ListEndpoints returns the endpoints of a puck, or of every puck when
+// puckName is empty, ordered by puck and name
+func (db *DB) ListEndpoints(ctx context.Context, puckName string) ([]*Endpoint, error) {
+	query := `SELECT ` + endpointColumns + ` FROM endpoints`
+	var args []interface{}
+	if puckName != "" {
+		query += ` WHERE puck_name = ?`
+		args = append(args, puckName)
+	}
+	query += ` ORDER BY puck_name ASC, name ASC`
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying endpoints: %w", err)
+	}
+	defer rows.Close()
+
+	var endpoints []*Endpoint
+	for rows.Next() {
+		var e Endpoint
+		if err := rows.Scan(&e.PuckName, &e.Name, &e.ContainerPort, &e.HostPort, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning endpoint row: %w", err)
+		}
+		endpoints = append(endpoints, &e)
+	}
+
+	return endpoints, rows.Err()
+}
+
+// DeleteEndpoint deletes one of a puck's endpoints
+func (db *DB) DeleteEndpoint(ctx context.Context, puckName, name string) error {
+	result, err := db.ExecContext(ctx, `DELETE FROM endpoints WHERE puck_name = ? AND name = ?`, puckName, name)
+	if err != nil {
+		return err
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("endpoint '%s' of puck '%s' %w", name, puckName, ErrNotFound)
+	}
+
+	return nil
+}
+
+// MoveEndpoints gives a puck's endpoints to another name, such as to keep
+// them out of the way of a puck being made under the same name
+func (db *DB) MoveEndpoints(ctx context.Context, from, to string) error {
+	if _, err := db.ExecContext(ctx, `UPDATE endpoints SET puck_name = ? WHERE puck_name = ?`, to, from); err != nil {
+		return fmt.Errorf("moving endpoints: %w", err)
+	}
+	return nil
+}
+
+// DeleteEndpointsByPuck deletes all endpoints for a puck
+func (db *DB) DeleteEndpointsByPuck(ctx context.Context, puckName string) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM endpoints WHERE puck_name = ?`, puckName)
+	return err
+}
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpoints(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	add := func(puckName, name string, containerPort, hostPort int) error {
+		return db.CreateEndpoint(ctx, &Endpoint{PuckName: puckName, Name: name, ContainerPort: containerPort, HostPort: hostPort, CreatedAt: time.Now()})
+	}
+
+	t.Run("stores endpoints by puck", func(t *testing.T) {
+		require.NoError(t, add("web", "web", 3000, 9001))
+		require.NoError(t, add("web", "api", 8000, 9002))
+		require.NoError(t, add("db", "admin", 8080, 9003))
+
+		err := add("web", "api", 8001, 9004)
+		assert.True(t, errors.Is(err, ErrExists))
+
+		endpoints, err := db.ListEndpoints(ctx, "web")
+		require.NoError(t, err)
+		require.Len(t, endpoints, 2)
+		assert.Equal(t, "api", endpoints[0].Name)
+		assert.Equal(t, 8000, endpoints[0].ContainerPort)
+		assert.Equal(t, 9002, endpoints[0].HostPort)
+
+		all, err := db.ListEndpoints(ctx, "")
+		require.NoError(t, err)
+		assert.Len(t, all, 3)
+	})
+
+	t.Run("deletes endpoints", func(t *testing.T) {
+		require.NoError(t, db.DeleteEndpoint(ctx, "web", "api"))
+		err := db.DeleteEndpoint(ctx, "web", "api")
+		assert.True(t, errors.Is(err, ErrNotFound))
+
+		require.NoError(t, db.DeleteEndpointsByPuck(ctx, "web"))
+		all, err := db.ListEndpoints(ctx, "")
+		require.NoError(t, err)
+		require.Len(t, all, 1)
+		assert.Equal(t, "db", all[0].PuckName)
+	})
+
+	t.Run("moves endpoints to another name", func(t *testing.T) {
+		require.NoError(t, db.MoveEndpoints(ctx, "db", "db/replaced"))
+		endpoints, err := db.ListEndpoints(ctx, "db/replaced")
+		require.NoError(t, err)
+		require.Len(t, endpoints, 1)
+		assert.Equal(t, "admin", endpoints[0].Name)
+
+		endpoints, err = db.ListEndpoints(ctx, "db")
+		require.NoError(t, err)
+		assert.Empty(t, endpoints)
+	})
+}
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RecordEvent appends an event to a puck's history, stamping it with the
+// current time if CreatedAt is unset
+func (db *DB) RecordEvent(ctx context.Context, e *Event) error {
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+
+	id, err := db.insert(ctx, `
+		INSERT INTO events (puck_name, type, detail, created_at)
+		VALUES (?, ?, ?, ?)`, e.PuckName, e.Type, e.Detail, e.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("inserting event: %w", err)
+	}
+
+	e.ID = id
+	return nil
+}
+
+// ListEvents returns a puck's events at or after since, oldest first. A
+// zero since returns the full history. Times are compared in Go since
+// stored timestamps keep their zone.
+func (db *DB) ListEvents(ctx context.Context, puckName string, since time.Time) ([]*Event, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, puck_name, type, detail, created_at
+		FROM events WHERE puck_name = ? ORDER BY id ASC
+	`, puckName)
+	if err != nil {
+		return nil, fmt.Errorf("querying events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []*Event
+	for rows.Next() {
+		var e Event
+		if err := rows.Scan(&e.ID, &e.PuckName, &e.Type, &e.Detail, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning event row: %w", err)
+		}
+		if e.CreatedAt.Before(since) {
+			continue
+		}
+		events = append(events, &e)
+	}
+
+	return events, rows.Err()
+}
+
+// DeleteEventsByPuck deletes a puck's history
+func (db *DB) DeleteEventsByPuck(ctx context.Context, puckName string) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM events WHERE puck_name = ?`, puckName)
+	return err
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvents(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	start := time.Now().Add(-2 * time.Hour)
+	record := func(name string, typ EventType, detail string, at time.Time) {
+		require.NoError(t, db.RecordEvent(ctx, &Event{PuckName: name, Type: typ, Detail: detail, CreatedAt: at}))
+	}
+	record("web", EventCreated, "fedora:latest", start)
+	record("web", EventStarted, "", start.Add(time.Minute))
+	record("api", EventCreated, "alpine", start)
+	record("web", EventStopped, "", start.Add(time.Hour))
+
+	t.Run("lists a puck's events oldest first", func(t *testing.T) {
+		events, err := db.ListEvents(ctx, "web", time.Time{})
+		require.NoError(t, err)
+		require.Len(t, events, 3)
+		assert.Equal(t, EventCreated, events[0].Type)
+		assert.Equal(t, "fedora:latest", events[0].Detail)
+		assert.Equal(t, EventStarted, events[1].Type)
+		assert.Equal(t, EventStopped, events[2].Type)
+		assert.WithinDuration(t, start.Add(time.Hour), events[2].CreatedAt, time.Second)
+	})
+
+	t.Run("filters by since", func(t *testing.T) {
+		events, err := db.ListEvents(ctx, "web", start.Add(30*time.Minute))
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, EventStopped, events[0].Type)
+	})
+
+	t.Run("stamps events without a time", func(t *testing.T) {
+		e := &Event{PuckName: "api", Type: EventStarted}
+		require.NoError(t, db.RecordEvent(ctx, e))
+		assert.NotZero(t, e.ID)
+		assert.WithinDuration(t, time.Now(), e.CreatedAt, time.Second)
+	})
+
+	t.Run("deletes a puck's history", func(t *testing.T) {
+		require.NoError(t, db.DeleteEventsByPuck(ctx, "web"))
+
+		events, err := db.ListEvents(ctx, "web", time.Time{})
+		require.NoError(t, err)
+		assert.Empty(t, events)
+
+		events, err = db.ListEvents(ctx, "api", time.Time{})
+		require.NoError(t, err)
+		assert.Len(t, events, 2)
+	})
+}
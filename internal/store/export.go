@@ -0,0 +1,85 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// ExportTimeFormat is how exported files write times: RFC 3339 in UTC,
+// which SQLite's date functions and spreadsheets both read
+const ExportTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// ExportEvents writes events to an events table in the SQLite file at
+// path, creating the file if needed. The file is separate from the live
+// database, so it can be handed to other tools or attached to another
+// database; a file that already has an events table is refused.
+func ExportEvents(ctx context.Context, path string, events []*Event) error {
+	return exportTable(ctx, path, "events", `
+		CREATE TABLE events (
+			id INTEGER PRIMARY KEY,
+			puck_name TEXT NOT NULL,
+			type TEXT NOT NULL,
+			detail TEXT,
+			created_at TEXT NOT NULL
+		)`, `INSERT INTO events (id, puck_name, type, detail, created_at) VALUES (?, ?, ?, ?, ?)`,
+		len(events), func(i int) []any {
+			e := events[i]
+			return []any{e.ID, e.PuckName, string(e.Type), e.Detail, e.CreatedAt.UTC().Format(ExportTimeFormat)}
+		})
+}
+
+// ExportStats writes stats samples to a stats table in the SQLite file at
+// path, as ExportEvents does events
+func ExportStats(ctx context.Context, path string, stats []*Stat) error {
+	return exportTable(ctx, path, "stats", `
+		CREATE TABLE stats (
+			id INTEGER PRIMARY KEY,
+			puck_name TEXT NOT NULL,
+			up INTEGER NOT NULL,
+			cpu REAL NOT NULL,
+			memory INTEGER NOT NULL,
+			disk INTEGER NOT NULL,
+			hits INTEGER NOT NULL,
+			created_at TEXT NOT NULL
+		)`, `INSERT INTO stats (id, puck_name, up, cpu, memory, disk, hits, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		len(stats), func(i int) []any {
+			s := stats[i]
+			return []any{s.ID, s.PuckName, s.Up, s.CPU, s.Memory, s.Disk, int64(s.Hits), s.CreatedAt.UTC().Format(ExportTimeFormat)}
+		})
+}
+
+// exportTable creates a table in the SQLite file at path and fills it
+// with n rows in one transaction
+func exportTable(ctx context.Context, path, table, create, insert string, n int, row func(i int) []any) error {
+	conn, err := sql.Open(sqliteDialect{}.driver(), path)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", path, err)
+	}
+	defer conn.Close()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", path, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, create); err != nil {
+		if strings.Contains(err.Error(), "already exists") {
+			return fmt.Errorf("%s already has a table named %s", path, table)
+		}
+		return fmt.Errorf("creating %s table: %w", table, err)
+	}
+	stmt, err := tx.PrepareContext(ctx, insert)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for i := range n {
+		if _, err := stmt.ExecContext(ctx, row(i)...); err != nil {
+			return fmt.Errorf("writing %s: %w", table, err)
+		}
+	}
+	return tx.Commit()
+}
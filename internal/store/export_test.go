@@ -0,0 +1,65 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExport(t *testing.T) {
+	ctx := context.Background()
+	at := time.Date(2026, 3, 1, 12, 30, 0, 0, time.FixedZone("CET", 3600))
+	path := filepath.Join(t.TempDir(), "puck-data.db")
+
+	events := []*Event{
+		{ID: 1, PuckName: "web", Type: EventCreated, Detail: "fedora:latest", CreatedAt: at},
+		{ID: 4, PuckName: "web", Type: EventStarted, CreatedAt: at.Add(time.Minute)},
+	}
+	stats := []*Stat{
+		{ID: 7, PuckName: "web", Up: true, CPU: 12.5, Memory: 1 << 20, Disk: 4096, Hits: 3, CreatedAt: at},
+	}
+	require.NoError(t, ExportEvents(ctx, path, events))
+	require.NoError(t, ExportStats(ctx, path, stats), "both tables go in one file")
+
+	conn, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	t.Run("writes events", func(t *testing.T) {
+		var n int
+		require.NoError(t, conn.QueryRow(`SELECT COUNT(*) FROM events`).Scan(&n))
+		assert.Equal(t, 2, n)
+
+		var typ, detail, created string
+		require.NoError(t, conn.QueryRow(`SELECT type, detail, created_at FROM events WHERE id = 1`).Scan(&typ, &detail, &created))
+		assert.Equal(t, "created", typ)
+		assert.Equal(t, "fedora:latest", detail)
+		assert.Equal(t, "2026-03-01T11:30:00.000Z", created)
+
+		var day string
+		require.NoError(t, conn.QueryRow(`SELECT date(created_at) FROM events WHERE id = 4`).Scan(&day))
+		assert.Equal(t, "2026-03-01", day, "readable by SQLite's date functions")
+	})
+
+	t.Run("writes stats", func(t *testing.T) {
+		var up bool
+		var cpu float64
+		var memory, disk, hits int64
+		require.NoError(t, conn.QueryRow(`SELECT up, cpu, memory, disk, hits FROM stats WHERE id = 7`).Scan(&up, &cpu, &memory, &disk, &hits))
+		assert.True(t, up)
+		assert.Equal(t, 12.5, cpu)
+		assert.Equal(t, int64(1<<20), memory)
+		assert.Equal(t, int64(4096), disk)
+		assert.Equal(t, int64(3), hits)
+	})
+
+	t.Run("refuses a file that already has the table", func(t *testing.T) {
+		err := ExportEvents(ctx, path, events)
+		assert.ErrorContains(t, err, "already has a table named events")
+	})
+}
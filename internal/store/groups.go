@@ -0,0 +1,109 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AddGroupMembers adds pucks to one of owner's groups, creating the group
+// if it has no members yet. Pucks already in it are left as they are.
+func (db *DB) AddGroupMembers(ctx context.Context, owner, name string, pucks []string) error {
+	now := time.Now()
+	for _, puckName := range pucks {
+		_, err := db.ExecContext(ctx, `
+			INSERT INTO puck_groups (owner, name, puck_name, created_at)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT (owner, name, puck_name) DO NOTHING
+		`, owner, name, puckName, now)
+		if err != nil {
+			return fmt.Errorf("adding to group: %w", err)
+		}
+	}
+	return nil
+}
+
+// RemoveGroupMembers removes pucks from one of owner's groups, or the
+// whole group when pucks is empty. A group left without members is gone.
+func (db *DB) RemoveGroupMembers(ctx context.Context, owner, name string, pucks []string) error {
+	query := `DELETE FROM puck_groups WHERE owner = ? AND name = ?`
+	args := []interface{}{owner, name}
+	if len(pucks) > 0 {
+		query += ` AND puck_name IN (?` + strings.Repeat(`, ?`, len(pucks)-1) + `)`
+		for _, p := range pucks {
+			args = append(args, p)
+		}
+	}
+
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("removing from group: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		if len(pucks) > 0 {
+			return fmt.Errorf("none of the pucks are in group '%s': %w", name, ErrNotFound)
+		}
+		return fmt.Errorf("group '%s' %w", name, ErrNotFound)
+	}
+	return nil
+}
+
+// GetGroup retrieves one of owner's groups by name
+func (db *DB) GetGroup(ctx context.Context, owner, name string) (*Group, error) {
+	groups, err := db.listGroups(ctx, `WHERE owner = ? AND name = ?`, owner, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return nil, fmt.Errorf("group '%s' %w", name, ErrNotFound)
+	}
+	return groups[0], nil
+}
+
+// ListGroups returns owner's groups, or every user's when owner is empty,
+// ordered by owner and name
+func (db *DB) ListGroups(ctx context.Context, owner string) ([]*Group, error) {
+	if owner == "" {
+		return db.listGroups(ctx, ``)
+	}
+	return db.listGroups(ctx, `WHERE owner = ?`, owner)
+}
+
+func (db *DB) listGroups(ctx context.Context, where string, args ...interface{}) ([]*Group, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT owner, name, puck_name, created_at FROM puck_groups `+where+`
+		ORDER BY owner ASC, name ASC, puck_name ASC
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying groups: %w", err)
+	}
+	defer rows.Close()
+
+	var groups []*Group
+	for rows.Next() {
+		var owner, name, puckName string
+		var createdAt time.Time
+		if err := rows.Scan(&owner, &name, &puckName, &createdAt); err != nil {
+			return nil, fmt.Errorf("scanning group row: %w", err)
+		}
+		if n := len(groups); n == 0 || groups[n-1].Owner != owner || groups[n-1].Name != name {
+			groups = append(groups, &Group{Name: name, Owner: owner, CreatedAt: createdAt})
+		}
+		g := groups[len(groups)-1]
+		g.Members = append(g.Members, puckName)
+		if createdAt.Before(g.CreatedAt) {
+			g.CreatedAt = createdAt
+		}
+	}
+
+	return groups, rows.Err()
+}
+
+// DeleteGroupMembershipsByPuck takes a puck out of every group
+func (db *DB) DeleteGroupMembershipsByPuck(ctx context.Context, puckName string) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM puck_groups WHERE puck_name = ?`, puckName)
+	return err
+}
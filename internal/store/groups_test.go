@@ -0,0 +1,72 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroups(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	t.Run("adds members", func(t *testing.T) {
+		require.NoError(t, db.AddGroupMembers(ctx, "alice", "demo", []string{"web", "api"}))
+		require.NoError(t, db.AddGroupMembers(ctx, "alice", "demo", []string{"db", "web"}))
+
+		g, err := db.GetGroup(ctx, "alice", "demo")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"api", "db", "web"}, g.Members)
+		assert.Equal(t, "alice", g.Owner)
+		assert.False(t, g.CreatedAt.IsZero())
+	})
+
+	t.Run("keeps users' groups apart", func(t *testing.T) {
+		require.NoError(t, db.AddGroupMembers(ctx, "bob", "demo", []string{"other"}))
+
+		g, err := db.GetGroup(ctx, "alice", "demo")
+		require.NoError(t, err)
+		assert.NotContains(t, g.Members, "other")
+
+		groups, err := db.ListGroups(ctx, "bob")
+		require.NoError(t, err)
+		require.Len(t, groups, 1)
+		assert.Equal(t, []string{"other"}, groups[0].Members)
+
+		all, err := db.ListGroups(ctx, "")
+		require.NoError(t, err)
+		assert.Len(t, all, 2)
+	})
+
+	t.Run("removes members", func(t *testing.T) {
+		require.NoError(t, db.RemoveGroupMembers(ctx, "alice", "demo", []string{"db"}))
+
+		g, err := db.GetGroup(ctx, "alice", "demo")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"api", "web"}, g.Members)
+
+		err = db.RemoveGroupMembers(ctx, "alice", "demo", []string{"missing"})
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("drops a destroyed puck", func(t *testing.T) {
+		require.NoError(t, db.DeleteGroupMembershipsByPuck(ctx, "api"))
+
+		g, err := db.GetGroup(ctx, "alice", "demo")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"web"}, g.Members)
+	})
+
+	t.Run("removes a whole group", func(t *testing.T) {
+		require.NoError(t, db.RemoveGroupMembers(ctx, "alice", "demo", nil))
+
+		_, err := db.GetGroup(ctx, "alice", "demo")
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		err = db.RemoveGroupMembers(ctx, "alice", "demo", nil)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
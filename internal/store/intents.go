@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// IntentOp is an operation recorded in the intent journal
+type IntentOp string
+
+const (
+	IntentCreate  IntentOp = "create"
+	IntentDestroy IntentOp = "destroy"
+	// A puck moved aside by a create replacing it, to be put back
+	IntentReplace IntentOp = "replace"
+)
+
+// Intent records an operation on a puck before it touches containers or
+// directories, so one cut short by a daemon crash can be finished or
+// undone at the next startup. It is deleted in the same transaction that
+// saves the operation's result.
+type Intent struct {
+	ID          int64     `json:"id"`
+	Op          IntentOp  `json:"op"`
+	PuckName    string    `json:"puck_name"`
+	PuckID      string    `json:"puck_id,omitempty"`      // value of the container's puck.id label
+	ContainerID string    `json:"container_id,omitempty"` // once the container exists
+	VolumeDir   string    `json:"volume_dir,omitempty"`
+	OwnsVolume  bool      `json:"owns_volume,omitempty"` // the operation created VolumeDir
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// BeginIntent journals an operation about to start, setting its ID
+func (db *DB) BeginIntent(ctx context.Context, in *Intent) error {
+	if in.CreatedAt.IsZero() {
+		in.CreatedAt = time.Now()
+	}
+
+	id, err := db.insert(ctx, `
+		INSERT INTO intents (op, puck_name, puck_id, container_id, volume_dir, owns_volume, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`, in.Op, in.PuckName, in.PuckID, in.ContainerID, in.VolumeDir, in.OwnsVolume, in.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("journaling %s of '%s': %w", in.Op, in.PuckName, err)
+	}
+
+	in.ID = id
+	return nil
+}
+
+// UpdateIntentContainer records the container an operation created
+func (db *DB) UpdateIntentContainer(ctx context.Context, id int64, containerID string) error {
+	if _, err := db.ExecContext(ctx, `UPDATE intents SET container_id = ? WHERE id = ?`, containerID, id); err != nil {
+		return fmt.Errorf("updating intent: %w", err)
+	}
+	return nil
+}
+
+// FinishIntent removes a completed or undone operation from the journal
+func (db *DB) FinishIntent(ctx context.Context, id int64) error {
+	if _, err := db.ExecContext(ctx, `DELETE FROM intents WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("finishing intent: %w", err)
+	}
+	return nil
+}
+
+// ListIntents returns unfinished operations, oldest first
+func (db *DB) ListIntents(ctx context.Context) ([]*Intent, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, op, puck_name, puck_id, container_id, volume_dir, owns_volume, created_at
+		FROM intents ORDER BY id ASC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("querying intents: %w", err)
+	}
+	defer rows.Close()
+
+	var intents []*Intent
+	for rows.Next() {
+		var in Intent
+		if err := rows.Scan(&in.ID, &in.Op, &in.PuckName, &in.PuckID, &in.ContainerID, &in.VolumeDir, &in.OwnsVolume, &in.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning intent row: %w", err)
+		}
+		intents = append(intents, &in)
+	}
+
+	return intents, rows.Err()
+}
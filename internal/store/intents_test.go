@@ -0,0 +1,44 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntents(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	create := &Intent{Op: IntentCreate, PuckName: "web", PuckID: "uuid-1", VolumeDir: "/data/pucks/web", OwnsVolume: true}
+	require.NoError(t, db.BeginIntent(ctx, create))
+	assert.NotZero(t, create.ID)
+	destroy := &Intent{Op: IntentDestroy, PuckName: "api", ContainerID: "abc123"}
+	require.NoError(t, db.BeginIntent(ctx, destroy))
+
+	t.Run("lists unfinished intents oldest first", func(t *testing.T) {
+		require.NoError(t, db.UpdateIntentContainer(ctx, create.ID, "def456"))
+
+		intents, err := db.ListIntents(ctx)
+		require.NoError(t, err)
+		require.Len(t, intents, 2)
+		assert.Equal(t, IntentCreate, intents[0].Op)
+		assert.Equal(t, "web", intents[0].PuckName)
+		assert.Equal(t, "uuid-1", intents[0].PuckID)
+		assert.Equal(t, "def456", intents[0].ContainerID)
+		assert.True(t, intents[0].OwnsVolume)
+		assert.Equal(t, IntentDestroy, intents[1].Op)
+	})
+
+	t.Run("finishing removes an intent", func(t *testing.T) {
+		require.NoError(t, db.FinishIntent(ctx, create.ID))
+
+		intents, err := db.ListIntents(ctx)
+		require.NoError(t, err)
+		require.Len(t, intents, 1)
+		assert.Equal(t, "api", intents[0].PuckName)
+	})
+}
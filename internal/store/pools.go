@@ -0,0 +1,126 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SavePool records one of owner's pools, or changes its size and options
+// if it exists
+func (db *DB) SavePool(ctx context.Context, p *Pool) error {
+	options := p.Options
+	if len(options) == 0 {
+		options = json.RawMessage(`{}`)
+	}
+	if p.CreatedAt.IsZero() {
+		p.CreatedAt = time.Now()
+	}
+
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO pools (owner, name, size, name_pattern, options, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (owner, name) DO UPDATE
+		SET size = excluded.size, name_pattern = excluded.name_pattern, options = excluded.options
+	`, p.Owner, p.Name, p.Size, p.NamePattern, string(options), p.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("saving pool: %w", err)
+	}
+	return nil
+}
+
+// GetPool retrieves one of owner's pools by name
+func (db *DB) GetPool(ctx context.Context, owner, name string) (*Pool, error) {
+	pools, err := db.listPools(ctx, `WHERE owner = ? AND name = ?`, owner, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(pools) == 0 {
+		return nil, fmt.Errorf("pool '%s' %w", name, ErrNotFound)
+	}
+	return pools[0], nil
+}
+
+// ListPools returns owner's pools, or every user's when owner is empty,
+// ordered by owner and name
+func (db *DB) ListPools(ctx context.Context, owner string) ([]*Pool, error) {
+	if owner == "" {
+		return db.listPools(ctx, ``)
+	}
+	return db.listPools(ctx, `WHERE owner = ?`, owner)
+}
+
+func (db *DB) listPools(ctx context.Context, where string, args ...interface{}) ([]*Pool, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT owner, name, size, name_pattern, options, created_at FROM pools `+where+`
+		ORDER BY owner ASC, name ASC
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying pools: %w", err)
+	}
+	defer rows.Close()
+
+	var pools []*Pool
+	for rows.Next() {
+		var p Pool
+		var pattern, options sql.NullString
+		if err := rows.Scan(&p.Owner, &p.Name, &p.Size, &pattern, &options, &p.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning pool row: %w", err)
+		}
+		p.NamePattern = pattern.String
+		if options.String != "" {
+			p.Options = json.RawMessage(options.String)
+		}
+		pools = append(pools, &p)
+	}
+
+	return pools, rows.Err()
+}
+
+// DeletePool removes one of owner's pools. The pucks waiting in it are
+// left for the caller to destroy.
+func (db *DB) DeletePool(ctx context.Context, owner, name string) error {
+	result, err := db.ExecContext(ctx, `DELETE FROM pools WHERE owner = ? AND name = ?`, owner, name)
+	if err != nil {
+		return fmt.Errorf("deleting pool: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("pool '%s' %w", name, ErrNotFound)
+	}
+	return nil
+}
+
+// ClaimPooledPuck takes the longest-waiting suspended puck out of one of
+// owner's pools and returns it. Two callers never get the same puck; when
+// none is waiting it returns ErrNotFound.
+func (db *DB) ClaimPooledPuck(ctx context.Context, owner, pool string) (*Puck, error) {
+	for {
+		var name string
+		err := db.QueryRowContext(ctx, `
+			SELECT name FROM pucks WHERE owner = ? AND pool = ? AND status = ?
+			ORDER BY created_at ASC LIMIT 1
+		`, owner, pool, StatusSuspended).Scan(&name)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("pool '%s' has no puck waiting: %w", pool, ErrNotFound)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("querying pool: %w", err)
+		}
+
+		result, err := db.ExecContext(ctx, `
+			UPDATE pucks SET pool = '', updated_at = ? WHERE name = ? AND pool = ?
+		`, time.Now(), name, pool)
+		if err != nil {
+			return nil, fmt.Errorf("claiming pooled puck: %w", err)
+		}
+		// Another caller claimed it first; take the next one
+		if rows, _ := result.RowsAffected(); rows == 0 {
+			continue
+		}
+		return db.GetPuck(ctx, name)
+	}
+}
@@ -0,0 +1,104 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPools(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	t.Run("saves and updates", func(t *testing.T) {
+		require.NoError(t, db.SavePool(ctx, &Pool{Owner: "alice", Name: "web", Size: 2, Options: json.RawMessage(`{"image":"node"}`)}))
+		require.NoError(t, db.SavePool(ctx, &Pool{Owner: "alice", Name: "web", Size: 3, NamePattern: "web-%noun%", Options: json.RawMessage(`{"image":"node:22"}`)}))
+
+		p, err := db.GetPool(ctx, "alice", "web")
+		require.NoError(t, err)
+		assert.Equal(t, 3, p.Size)
+		assert.Equal(t, "web-%noun%", p.NamePattern)
+		assert.JSONEq(t, `{"image":"node:22"}`, string(p.Options))
+		assert.False(t, p.CreatedAt.IsZero())
+	})
+
+	t.Run("keeps users' pools apart", func(t *testing.T) {
+		require.NoError(t, db.SavePool(ctx, &Pool{Owner: "bob", Name: "web", Size: 1}))
+
+		pools, err := db.ListPools(ctx, "bob")
+		require.NoError(t, err)
+		require.Len(t, pools, 1)
+		assert.Equal(t, 1, pools[0].Size)
+		assert.JSONEq(t, `{}`, string(pools[0].Options))
+
+		all, err := db.ListPools(ctx, "")
+		require.NoError(t, err)
+		assert.Len(t, all, 2)
+	})
+
+	t.Run("deletes", func(t *testing.T) {
+		require.NoError(t, db.DeletePool(ctx, "bob", "web"))
+		assert.ErrorIs(t, db.DeletePool(ctx, "bob", "web"), ErrNotFound)
+		_, err := db.GetPool(ctx, "bob", "web")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestClaimPooledPuck(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	for i, name := range []string{"pool-b", "pool-a", "pool-c"} {
+		p := createTestPuck(name)
+		p.Owner = "alice"
+		p.Pool = "web"
+		p.Status = StatusSuspended
+		p.CreatedAt = time.Now().Add(time.Duration(i) * time.Second)
+		require.NoError(t, db.CreatePuck(ctx, p))
+	}
+	// Still being made, so not handed out
+	filling := createTestPuck("pool-filling")
+	filling.Owner = "alice"
+	filling.Pool = "web"
+	filling.Status = StatusStarting
+	require.NoError(t, db.CreatePuck(ctx, filling))
+
+	p, err := db.ClaimPooledPuck(ctx, "alice", "web")
+	require.NoError(t, err)
+	assert.Equal(t, "pool-b", p.Name, "the longest-waiting puck goes first")
+	assert.Empty(t, p.Pool)
+
+	_, err = db.ClaimPooledPuck(ctx, "bob", "web")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	var wg sync.WaitGroup
+	claimed := make(chan string, 4)
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if p, err := db.ClaimPooledPuck(ctx, "alice", "web"); err == nil {
+				claimed <- p.Name
+			}
+		}()
+	}
+	wg.Wait()
+	close(claimed)
+
+	var names []string
+	for name := range claimed {
+		names = append(names, name)
+	}
+	assert.ElementsMatch(t, []string{"pool-a", "pool-c"}, names, "each puck is handed out once")
+
+	got, err := db.GetPuck(ctx, "pool-filling")
+	require.NoError(t, err)
+	assert.Equal(t, "web", got.Pool)
+}
@@ -0,0 +1,61 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ReservePort reserves a host port for a puck. A port another puck holds
+// is refused with ErrExists; reserving a port again for the same puck is
+// allowed.
+func (db *DB) ReservePort(ctx context.Context, port int, puckName string) error {
+	result, err := db.ExecContext(ctx, `
+		INSERT INTO port_reservations (port, puck_name, reserved_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT (port) DO UPDATE SET reserved_at = excluded.reserved_at
+		WHERE port_reservations.puck_name = excluded.puck_name
+	`, port, puckName, time.Now())
+	if err != nil {
+		return fmt.Errorf("reserving port %d: %w", port, err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("port %d %w", port, ErrExists)
+	}
+	return nil
+}
+
+// ListPortReservations returns every reserved port, lowest first
+func (db *DB) ListPortReservations(ctx context.Context) ([]*PortReservation, error) {
+	rows, err := db.QueryContext(ctx, `SELECT port, puck_name, reserved_at FROM port_reservations ORDER BY port ASC`)
+	if err != nil {
+		return nil, fmt.Errorf("querying port reservations: %w", err)
+	}
+	defer rows.Close()
+
+	var reservations []*PortReservation
+	for rows.Next() {
+		var r PortReservation
+		if err := rows.Scan(&r.Port, &r.PuckName, &r.ReservedAt); err != nil {
+			return nil, fmt.Errorf("scanning port reservation row: %w", err)
+		}
+		reservations = append(reservations, &r)
+	}
+
+	return reservations, rows.Err()
+}
+
+// ReleasePort gives up a puck's reservation of a port. A port reserved
+// for another puck is left alone.
+func (db *DB) ReleasePort(ctx context.Context, port int, puckName string) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM port_reservations WHERE port = ? AND puck_name = ?`, port, puckName)
+	return err
+}
+
+// DeletePortReservationsByPuck releases every port reserved for a puck
+func (db *DB) DeletePortReservationsByPuck(ctx context.Context, puckName string) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM port_reservations WHERE puck_name = ?`, puckName)
+	return err
+}
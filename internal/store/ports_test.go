@@ -0,0 +1,76 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPortReservations(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	ports := func() map[int]string {
+		reservations, err := db.ListPortReservations(ctx)
+		require.NoError(t, err)
+		held := make(map[int]string)
+		for _, r := range reservations {
+			held[r.Port] = r.PuckName
+		}
+		return held
+	}
+
+	t.Run("reserves a port for one puck only", func(t *testing.T) {
+		require.NoError(t, db.ReservePort(ctx, 9000, "web"))
+		require.NoError(t, db.ReservePort(ctx, 9000, "web"), "again for the same puck")
+
+		err := db.ReservePort(ctx, 9000, "api")
+		assert.True(t, errors.Is(err, ErrExists))
+		assert.Equal(t, map[int]string{9000: "web"}, ports())
+	})
+
+	t.Run("releases only the puck's own reservation", func(t *testing.T) {
+		require.NoError(t, db.ReleasePort(ctx, 9000, "api"))
+		assert.Contains(t, ports(), 9000)
+
+		require.NoError(t, db.ReleasePort(ctx, 9000, "web"))
+		assert.Empty(t, ports())
+	})
+
+	t.Run("releases every port of a puck", func(t *testing.T) {
+		require.NoError(t, db.ReservePort(ctx, 9001, "web"))
+		require.NoError(t, db.ReservePort(ctx, 9002, "web"))
+		require.NoError(t, db.ReservePort(ctx, 9003, "api"))
+
+		require.NoError(t, db.DeletePortReservationsByPuck(ctx, "web"))
+		assert.Equal(t, map[int]string{9003: "api"}, ports())
+	})
+}
+
+func TestPortReservationsBackfilled(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "test.db")
+	ctx := context.Background()
+
+	db, err := Open(dbPath)
+	require.NoError(t, err)
+	p := createTestPuck("web")
+	p.HostPort = 9005
+	require.NoError(t, db.CreatePuck(ctx, p))
+	require.NoError(t, db.Close())
+
+	// Pucks created before reservations existed hold their ports
+	db, err = Open(dbPath)
+	require.NoError(t, err)
+	defer db.Close()
+	reservations, err := db.ListPortReservations(ctx)
+	require.NoError(t, err)
+	require.Len(t, reservations, 1)
+	assert.Equal(t, 9005, reservations[0].Port)
+	assert.Equal(t, "web", reservations[0].PuckName)
+}
@@ -5,7 +5,11 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
+
+	"github.com/sandwich-labs/puck/internal/agent"
 )
 
 // Status represents the current state of a puck
@@ -13,39 +17,556 @@ type Status string
 
 const (
 	StatusRunning      Status = "running"
+	StatusStarting     Status = "starting" // running, but its app isn't answering yet
 	StatusStopped      Status = "stopped"
 	StatusCheckpointed Status = "checkpointed"
+	StatusSuspended    Status = "suspended" // checkpointed to be resumed by its next start
 	StatusCreating     Status = "creating"
 	StatusError        Status = "error"
 )
 
+// Up reports whether the puck's container is running, including while
+// its app is still starting
+func (s Status) Up() bool {
+	return s == StatusRunning || s == StatusStarting
+}
+
 // Puck represents a persistent container managed by puck
 type Puck struct {
-	ID          string    `json:"id"`
-	Name        string    `json:"name"`
-	Image       string    `json:"image"`
-	Status      Status    `json:"status"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	VolumeDir   string    `json:"volume_dir"`
-	Ports       []string  `json:"ports,omitempty"`
-	HostPort    int       `json:"host_port,omitempty"` // Auto-assigned port for HTTP routing
-	TailscaleIP string    `json:"tailscale_ip,omitempty"`
-	FunnelURL   string    `json:"funnel_url,omitempty"`
-	ContainerIP string    `json:"container_ip,omitempty"`
-}
-
-// Snapshot represents a checkpoint of a puck's state
+	// ID is the puck's own identity, kept across container replacements.
+	// Pucks from before container_id was recorded keep their first
+	// container's ID as their ID.
+	ID          string        `json:"id"`
+	ContainerID string        `json:"container_id"` // the podman container currently backing the puck
+	Name        string        `json:"name"`
+	Image       string        `json:"image"`
+	Status      Status        `json:"status"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+	VolumeDir   string        `json:"volume_dir"`
+	Ports       []string      `json:"ports,omitempty"`
+	HostPort    int           `json:"host_port,omitempty"` // Auto-assigned port for HTTP routing
+	TailscaleIP string        `json:"tailscale_ip,omitempty"`
+	FunnelURL   string        `json:"funnel_url,omitempty"`
+	ContainerIP string        `json:"container_ip,omitempty"`
+	Route       RouteConfig   `json:"route"`
+	Owner       string        `json:"owner,omitempty"`   // user who created the puck
+	Tailnet     *TailnetShare `json:"tailnet,omitempty"` // nil when not shared on the tailnet
+	// Snapshot the puck's current state descends from: the last one taken
+	// or restored. New snapshots become its children.
+	SnapshotHead string `json:"snapshot_head,omitempty"`
+	// Checkpoint a suspended puck resumes from when next started; dropped
+	// once the puck runs again
+	ResumeSnapshot string    `json:"resume_snapshot,omitempty"`
+	Resources      Resources `json:"resources"`
+	// Last time the puck was started, restored, or opened; used to pick
+	// which pucks to checkpoint first
+	LastUsedAt time.Time `json:"last_used_at,omitempty"`
+	Spec       Spec      `json:"spec"`
+	// Pucks that are started before this one and stopped after it
+	Requires []string `json:"requires,omitempty"`
+	// Where the puck may open connections to
+	Egress EgressPolicy `json:"egress"`
+	// Project the puck counts against for quotas; empty for none
+	Project string `json:"project,omitempty"`
+	// What the puck is for, in the user's words, set with puck set --notes
+	Notes string `json:"notes,omitempty"`
+	// The puck's own defaults for its snapshots
+	SnapshotPolicy SnapshotPolicy `json:"snapshot_policy"`
+	// Pool the puck waits in, suspended, to be handed out by puck create
+	// --from-pool; empty once it has been
+	Pool string `json:"pool,omitempty"`
+	// When the daemon destroys the puck, zero for never, and whether it
+	// archives it first
+	ExpiresAt      time.Time `json:"expires_at,omitempty"`
+	ExpirySnapshot bool      `json:"expiry_snapshot,omitempty"`
+	// HostPort was chosen by the user, so it is never moved to another
+	HostPortPinned bool `json:"host_port_pinned,omitempty"`
+	// Host ports podman gave the image's exposed ports when the puck last
+	// started, as host:container/protocol, for pucks that publish them all
+	Published []string `json:"published,omitempty"`
+	// Status of the image's HEALTHCHECK while the puck is up; filled in
+	// by the daemon, not stored
+	Health string `json:"health,omitempty"`
+	// CPU and memory in use while the puck is up, when asked for; filled
+	// in by the daemon, not stored
+	Usage *Usage `json:"usage,omitempty"`
+	// Things the creator should know about the new puck, such as
+	// vulnerabilities found in its image; filled in by the daemon on
+	// create, not stored
+	Warnings []string `json:"warnings,omitempty"`
+	// What the puck's agent last reported, while it is fresh; filled in by
+	// the daemon, not stored
+	Agent *agent.Report `json:"agent,omitempty"`
+	// The port the puck's app was found listening on while it is up, by
+	// its agent or by probing, and whether the router goes there rather
+	// than to port 80; filled in by the daemon, not stored
+	AppPort       int  `json:"app_port,omitempty"`
+	AppPortRouted bool `json:"app_port_routed,omitempty"`
+}
+
+// Usage is what a running puck is using, as opposed to the Resources it
+// is limited to
+type Usage struct {
+	CPU    float64 `json:"cpu"`    // percent of one CPU
+	Memory int64   `json:"memory"` // bytes
+}
+
+// SnapshotPolicy is a puck's own defaults for its snapshots, and when to
+// take them on a schedule
+type SnapshotPolicy struct {
+	// Keep the puck running after checkpoints that don't say otherwise
+	LeaveRunning bool `json:"leave_running,omitempty"`
+	// How snapshot archives are compressed: gzip (the default), zstd or
+	// none
+	Compression string `json:"compression,omitempty"`
+	// Archive the puck's volume directories with its checkpoints, and put
+	// them back when restoring one
+	IncludeVolumes bool `json:"include_volumes,omitempty"`
+	// How often to snapshot the puck while it runs, e.g. "6h"; empty for
+	// never
+	Schedule string `json:"schedule,omitempty"`
+	// Scheduled snapshots to keep, dropping the oldest; zero keeps all
+	Retain int `json:"retain,omitempty"`
+}
+
+// InitMode is what runs as PID 1 in a puck's container
+type InitMode string
+
+const (
+	InitSystemd InitMode = "systemd" // the image's systemd, for full-OS images
+	InitTini    InitMode = "tini"    // a minimal init that runs and reaps the image's command
+	InitNone    InitMode = "none"    // the image's entrypoint runs as PID 1
+)
+
+// Spec holds the choices a puck's container is built from, so that
+// recreating it builds the same container
+type Spec struct {
+	Init InitMode `json:"init,omitempty"` // empty means InitSystemd
+	// Override the image's entrypoint and command; nil keeps the image's
+	Entrypoint []string `json:"entrypoint,omitempty"`
+	Command    []string `json:"command,omitempty"`
+	// Name resolution inside the container; Hostname defaults to the
+	// puck's name
+	Hostname string   `json:"hostname,omitempty"`
+	DNS      []string `json:"dns,omitempty"`       // nameserver IPs
+	AddHosts []string `json:"add_hosts,omitempty"` // /etc/hosts entries as host:ip
+	// Kernel parameters, e.g. net.ipv4.ip_forward=1, and resource limits
+	// in podman's --ulimit form, e.g. nofile=65536:65536
+	Sysctls map[string]string `json:"sysctls,omitempty"`
+	Ulimits []string          `json:"ulimits,omitempty"`
+	// User namespace: keep-id, auto, or nomap, or custom mappings as
+	// container:host:size; empty uses podman's default
+	UserNS string   `json:"userns,omitempty"`
+	UIDMap []string `json:"uidmap,omitempty"`
+	GIDMap []string `json:"gidmap,omitempty"`
+	// Security profiles: SeccompUnconfined or a profile path for seccomp,
+	// SeccompUnconfined or a profile name for AppArmor; empty uses the default
+	Seccomp  string `json:"seccomp,omitempty"`
+	AppArmor string `json:"apparmor,omitempty"`
+	// Restrictions for running untrusted code; empty applies none
+	Sandbox SandboxMode `json:"sandbox,omitempty"`
+	// Host directories bind-mounted into the puck, taken from the shared
+	// paths configured when it was created
+	Mounts []Mount `json:"mounts,omitempty"`
+	// Seconds the puck gets to exit when stopped before it is killed; nil
+	// uses the stop_timeout setting
+	StopTimeout *int `json:"stop_timeout,omitempty"`
+	// Publish the image's exposed ports on host ports podman picks
+	PublishAll bool `json:"publish_all,omitempty"`
+	// Environment variables, set in the container and written to
+	// /etc/puck/environment for systemd units to read. SecretEnv names
+	// those to mask in output, besides any whose names look secret.
+	Env       map[string]string `json:"env,omitempty"`
+	SecretEnv []string          `json:"secret_env,omitempty"`
+	// Container labels from the labels setting, as rendered when the puck
+	// was created, so recreating it keeps them
+	Labels map[string]string `json:"labels,omitempty"`
+	// Shell console opens and user exec sessions run as, unless asked
+	// for others; empty uses the puck's default
+	Shell string `json:"shell,omitempty"`
+	User  string `json:"user,omitempty"`
+}
+
+// Mount is a host directory bind-mounted, or synced, into a puck
+type Mount struct {
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	ReadOnly bool   `json:"read_only,omitempty"`
+	// Sync copies the source into the puck's volume and keeps the copy up
+	// to date, instead of bind mounting it, for hosts with slow mounts
+	Sync   bool     `json:"sync,omitempty"`
+	Ignore []string `json:"ignore,omitempty"` // patterns left out of a sync
+}
+
+// SeccompUnconfined disables a security profile
+const SeccompUnconfined = "unconfined"
+
+// SandboxMode is a bundle of restrictions for pucks running untrusted code
+type SandboxMode string
+
+// SandboxStrict drops every capability and privilege escalation, makes
+// the root filesystem read-only, cuts the puck off the network, and caps
+// its memory, CPU and processes
+const SandboxStrict SandboxMode = "strict"
+
+// SecurityNotes lists the ways a puck's security profiles differ from
+// podman's defaults, for flagging in list and inspect
+func (s Spec) SecurityNotes() []string {
+	var notes []string
+	switch s.Seccomp {
+	case "":
+	case SeccompUnconfined:
+		notes = append(notes, "seccomp unconfined")
+	default:
+		notes = append(notes, "seccomp profile "+s.Seccomp)
+	}
+	switch s.AppArmor {
+	case "":
+	case SeccompUnconfined:
+		notes = append(notes, "apparmor unconfined")
+	default:
+		notes = append(notes, "apparmor profile "+s.AppArmor)
+	}
+	return notes
+}
+
+// secretEnvWords are parts of variable names, split on underscores, that
+// mark the value as secret
+var secretEnvWords = []string{"SECRET", "TOKEN", "PASSWORD", "PASSWD", "PASS", "KEY", "APIKEY", "PRIVATE", "CREDENTIAL", "CREDENTIALS", "AUTH"}
+
+// IsSecretEnv reports whether an environment variable's value should be
+// masked in output: it was set as a secret, or its name looks like one,
+// such as API_KEY or DB_PASSWORD
+func (s Spec) IsSecretEnv(key string) bool {
+	if slices.Contains(s.SecretEnv, key) {
+		return true
+	}
+	for _, word := range strings.Split(strings.ToUpper(key), "_") {
+		if slices.Contains(secretEnvWords, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// InitMode returns the puck's init mode, defaulting to systemd for pucks
+// created before it could be chosen
+func (s Spec) InitMode() InitMode {
+	if s.Init == "" {
+		return InitSystemd
+	}
+	return s.Init
+}
+
+// TailnetShare describes a puck exposed as its own tailnet node
+type TailnetShare struct {
+	Tags     []string  `json:"tags,omitempty"` // ACL tags applied to the node, e.g. "tag:dev"
+	SharedAt time.Time `json:"shared_at"`
+}
+
+// EgressMode is how far a puck may reach out of its container
+type EgressMode string
+
+const (
+	EgressAll       EgressMode = "all"       // anywhere, as podman allows by default
+	EgressNone      EgressMode = "none"      // nowhere; it can still answer connections
+	EgressTailnet   EgressMode = "tailnet"   // tailnet addresses only
+	EgressAllowlist EgressMode = "allowlist" // the CIDRs and domains in Allow, plus DNS
+)
+
+// EgressPolicy limits the connections a puck can open. Replies to
+// connections made to the puck, such as routed HTTP, are always allowed.
+type EgressPolicy struct {
+	Mode  EgressMode `json:"mode,omitempty"`  // empty means EgressAll
+	Allow []string   `json:"allow,omitempty"` // CIDRs, IPs and domains, for EgressAllowlist
+}
+
+// EffectiveMode returns the policy's mode, defaulting to EgressAll
+func (e EgressPolicy) EffectiveMode() EgressMode {
+	if e.Mode == "" {
+		return EgressAll
+	}
+	return e.Mode
+}
+
+// String describes the policy, e.g. "allowlist: 10.0.0.0/8, github.com"
+func (e EgressPolicy) String() string {
+	if len(e.Allow) > 0 {
+		return string(e.EffectiveMode()) + ": " + strings.Join(e.Allow, ", ")
+	}
+	return string(e.EffectiveMode())
+}
+
+// Resources are a puck's CPU and memory limits; zero means unlimited
+type Resources struct {
+	Memory int64   `json:"memory,omitempty"` // bytes
+	CPUs   float64 `json:"cpus,omitempty"`
+	// Set when the limits could not be applied to the running container,
+	// or the container otherwise no longer matches the puck's record, such
+	// as after its data directory moved; the container is recreated on
+	// its next start
+	Pending bool `json:"pending,omitempty"`
+}
+
+// Upstream protocols supported by the HTTP router
+const (
+	ProtocolHTTP = "http" // HTTP/1.1, with WebSocket upgrades
+	ProtocolH2C  = "h2c"  // HTTP/2 cleartext, for gRPC services
+)
+
+// RouteConfig holds per-puck settings for the HTTP router.
+// Zero values mean "use the router default".
+type RouteConfig struct {
+	// Protocol spoken by the upstream: ProtocolHTTP (default) or ProtocolH2C
+	Protocol string `json:"protocol,omitempty"`
+	// FlushInterval controls response buffering; negative flushes immediately
+	FlushInterval time.Duration `json:"flush_interval,omitempty"`
+	// ReadTimeout bounds how long to wait for upstream response data
+	ReadTimeout time.Duration `json:"read_timeout,omitempty"`
+	// StreamTimeout closes upgraded (WebSocket) connections after this long
+	StreamTimeout time.Duration `json:"stream_timeout,omitempty"`
+	// StreamCloseDelay keeps upgraded connections open across router reloads
+	StreamCloseDelay time.Duration `json:"stream_close_delay,omitempty"`
+	// ForwardedPrefix sends X-Forwarded-Prefix with the stripped path prefix
+	ForwardedPrefix bool `json:"forwarded_prefix,omitempty"`
+	// RequestHeaders are set on requests sent to the puck
+	RequestHeaders map[string]string `json:"request_headers,omitempty"`
+	// ResponseHeaders are set on responses returned to the client
+	ResponseHeaders map[string]string `json:"response_headers,omitempty"`
+	// Rewrites are applied to the path after the puck prefix is stripped
+	Rewrites []Rewrite `json:"rewrites,omitempty"`
+	// RateLimit caps requests per client IP within RateLimitWindow; zero disables
+	RateLimit       int           `json:"rate_limit,omitempty"`
+	RateLimitWindow time.Duration `json:"rate_limit_window,omitempty"`
+	// MaxBodySize rejects request bodies larger than this many bytes; zero disables
+	MaxBodySize int64 `json:"max_body_size,omitempty"`
+	// MaxConns caps concurrent requests to the puck across all clients; zero disables
+	MaxConns int `json:"max_conns,omitempty"`
+	// Bandwidth caps the bytes per second of request and response bodies
+	// across all clients; zero disables
+	Bandwidth int64 `json:"bandwidth,omitempty"`
+}
+
+// Rewrite replaces regular expression matches in a request path
+type Rewrite struct {
+	Find    string `json:"find"`
+	Replace string `json:"replace"`
+}
+
+// SnapshotMode is how a snapshot captures a puck
+type SnapshotMode string
+
+const (
+	// SnapshotModeCheckpoint is a CRIU checkpoint of the running container,
+	// including memory and processes
+	SnapshotModeCheckpoint SnapshotMode = "checkpoint"
+	// SnapshotModeImage commits the container's filesystem to an image and
+	// archives its volumes; it works without CRIU but loses process state
+	SnapshotModeImage SnapshotMode = "image"
+)
+
+// Snapshot represents a saved copy of a puck's state
 type Snapshot struct {
+	ID        string       `json:"id"`
+	PuckID    string       `json:"puck_id"`
+	PuckName  string       `json:"puck_name"`
+	Name      string       `json:"name"`
+	Path      string       `json:"path"`
+	SizeBytes int64        `json:"size_bytes"`
+	CreatedAt time.Time    `json:"created_at"`
+	ParentID  string       `json:"parent_id,omitempty"` // snapshot this one branched from
+	Tags      []string     `json:"tags,omitempty"`
+	Image     string       `json:"image,omitempty"` // puck image when the snapshot was taken
+	Mode      SnapshotMode `json:"mode"`
+	// Image the container was committed to; image mode only. Path then
+	// holds the volume archive rather than a checkpoint.
+	CommitImage string `json:"commit_image,omitempty"`
+	// Host a checkpoint was taken on, if known, to check a restore
+	// against
+	CRIUVersion   string `json:"criu_version,omitempty"`
+	Kernel        string `json:"kernel,omitempty"`
+	PodmanVersion string `json:"podman_version,omitempty"`
+	// Where the archive was moved to in cold storage; empty while it is
+	// at Path
+	ColdPath string `json:"cold_path,omitempty"`
+	// CRIU options a checkpoint was taken with, which restoring it uses
+	// too
+	CRIU CRIUOptions `json:"criu_options"`
+	// Archive of the puck's volume directories taken with a checkpoint,
+	// if its snapshot policy includes them
+	VolumesPath string `json:"volumes_path,omitempty"`
+	// Digest of the image a checkpoint's container ran, e.g.
+	// sha256:..., which restoring it needs on the host
+	ImageDigest string `json:"image_digest,omitempty"`
+}
+
+// CRIUOptions are the CRIU options a checkpoint is taken with
+type CRIUOptions struct {
+	// Checkpoint established TCP connections rather than refusing to;
+	// restoring them fails once their peers have gone
+	TCPEstablished bool `json:"tcp_established,omitempty"`
+	// Checkpoint file locks held by the puck's processes
+	FileLocks bool `json:"file_locks,omitempty"`
+}
+
+// ArchivePath is where the snapshot's archive is now, in cold storage or
+// at its path
+func (s *Snapshot) ArchivePath() string {
+	if s.ColdPath != "" {
+		return s.ColdPath
+	}
+	return s.Path
+}
+
+// Share is an expiring public link to a puck
+type Share struct {
 	ID        string    `json:"id"`
-	PuckID    string    `json:"puck_id"`
 	PuckName  string    `json:"puck_name"`
-	Name      string    `json:"name"`
+	Token     string    `json:"token"`
+	CreatedBy string    `json:"created_by"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+	URL       string    `json:"url,omitempty"` // filled in by the daemon, not stored
+}
+
+// Expired reports whether the link has expired at t
+func (s *Share) Expired(t time.Time) bool {
+	return !t.Before(s.ExpiresAt)
+}
+
+// RouteAlias is an extra router path served by a puck, so the puck behind
+// a path can be swapped without changing its URL
+type RouteAlias struct {
 	Path      string    `json:"path"`
-	SizeBytes int64     `json:"size_bytes"`
+	PuckName  string    `json:"puck_name"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PortReservation is a host port given to a puck. Holding it in the
+// store keeps two pucks created at once, even by daemons sharing a
+// database, from being given the same port.
+type PortReservation struct {
+	Port       int       `json:"port"`
+	PuckName   string    `json:"puck_name"`
+	ReservedAt time.Time `json:"reserved_at"`
+}
+
+// Endpoint is an extra container port a puck serves through the router at
+// /<puck>/<name>, published on a host port of its own
+type Endpoint struct {
+	PuckName      string    `json:"puck_name"`
+	Name          string    `json:"name"`
+	ContainerPort int       `json:"container_port"`
+	HostPort      int       `json:"host_port"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Pool is a user's supply of suspended pucks made ahead of time from the
+// same settings, each handed out in place of creating a puck
+type Pool struct {
+	Name  string `json:"name"`
+	Owner string `json:"owner,omitempty"`
+	// How many pucks the daemon keeps waiting in the pool
+	Size int `json:"size"`
+	// Pattern the pool's pucks are named from, as name_pattern
+	NamePattern string `json:"name_pattern,omitempty"`
+	// The create options each puck is made with, as the puck package
+	// reads them
+	Options   json.RawMessage `json:"options"`
+	CreatedAt time.Time       `json:"created_at"`
+
+	// How many pucks are waiting, and how many are being made; filled in
+	// by the daemon, not stored
+	Ready   int `json:"ready"`
+	Filling int `json:"filling"`
+}
+
+// Group is a named set of a user's pucks that commands can act on
+// together, as @<name>
+type Group struct {
+	Name      string    `json:"name"`
+	Owner     string    `json:"owner,omitempty"`
+	Members   []string  `json:"members"`
+	CreatedAt time.Time `json:"created_at"` // when its first member was added
+}
+
+// StackSnapshot groups snapshots of a puck and the pucks it requires,
+// taken together so they can be restored as a unit
+type StackSnapshot struct {
+	ID        string        `json:"id"`
+	PuckName  string        `json:"puck_name"` // the puck the stack was snapshotted from
+	Name      string        `json:"name"`
+	Members   []StackMember `json:"members"` // in start order, requirements first
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// StackMember is one puck's snapshot within a stack snapshot
+type StackMember struct {
+	Puck       string `json:"puck"`
+	SnapshotID string `json:"snapshot_id"`
+}
+
+// EventType identifies a lifecycle event in a puck's history
+type EventType string
+
+const (
+	EventCreated          EventType = "created"
+	EventStarted          EventType = "started"
+	EventStopped          EventType = "stopped"
+	EventRecreated        EventType = "recreated"
+	EventCrashed          EventType = "crashed"
+	EventCheckpointed     EventType = "checkpointed"
+	EventSnapshotCreated  EventType = "snapshot"
+	EventSnapshotRestored EventType = "restored"
+	EventPortChanged      EventType = "port"
+	EventPromoted         EventType = "promoted"
+	EventProvisioned      EventType = "provisioned"
+	EventEgressChanged    EventType = "egress"
+	EventEnvChanged       EventType = "env"
+	EventExec             EventType = "exec"
+)
+
+// RunningAfter reports whether an event leaves the puck running or
+// stopped. Events that don't change that, like snapshots, return ok false.
+func (t EventType) RunningAfter() (running, ok bool) {
+	switch t {
+	case EventCreated, EventStarted, EventRecreated, EventSnapshotRestored:
+		return true, true
+	case EventStopped, EventCrashed, EventCheckpointed:
+		return false, true
+	}
+	return false, false
+}
+
+// Event is an entry in a puck's lifecycle history
+type Event struct {
+	ID        int64     `json:"id"`
+	PuckName  string    `json:"puck_name"`
+	Type      EventType `json:"type"`
+	Detail    string    `json:"detail,omitempty"` // e.g. the image or snapshot involved
 	CreatedAt time.Time `json:"created_at"`
 }
 
+// Stat is a sample of a puck's resource use, taken periodically by the
+// daemon
+type Stat struct {
+	ID       int64   `json:"id"`
+	PuckName string  `json:"puck_name"`
+	Up       bool    `json:"up"`
+	CPU      float64 `json:"cpu"`    // percent of one CPU, while up
+	Memory   int64   `json:"memory"` // bytes, while up
+	Disk     int64   `json:"disk"`   // bytes of volumes and snapshots
+	// Requests the router has served the puck since it started, so the
+	// count drops back when the router restarts
+	Hits      uint64    `json:"hits"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// puckColumns lists the columns read by scanPuck, in scan order
+const puckColumns = `id, container_id, name, image, status, volume_dir, ports, host_port, host_port_pinned, published, container_ip, tailscale_ip, funnel_url, route_config, owner, tailnet_share, snapshot_head, resume_snapshot, resources, last_used_at, spec, requires, egress, project, notes, snapshot_policy, pool, expires_at, expiry_snapshot, created_at, updated_at`
+
 // CreatePuck creates a new puck in the database
 func (db *DB) CreatePuck(ctx context.Context, p *Puck) error {
 	portsJSON, err := json.Marshal(p.Ports)
@@ -53,11 +574,50 @@ func (db *DB) CreatePuck(ctx context.Context, p *Puck) error {
 		return fmt.Errorf("marshaling ports: %w", err)
 	}
 
+	routeJSON, err := json.Marshal(p.Route)
+	if err != nil {
+		return fmt.Errorf("marshaling route config: %w", err)
+	}
+
+	specJSON, err := json.Marshal(p.Spec)
+	if err != nil {
+		return fmt.Errorf("marshaling spec: %w", err)
+	}
+
+	requiresJSON, err := json.Marshal(p.Requires)
+	if err != nil {
+		return fmt.Errorf("marshaling requirements: %w", err)
+	}
+
+	resourcesJSON, err := json.Marshal(p.Resources)
+	if err != nil {
+		return fmt.Errorf("marshaling resources: %w", err)
+	}
+
+	egressJSON, err := json.Marshal(p.Egress)
+	if err != nil {
+		return fmt.Errorf("marshaling egress policy: %w", err)
+	}
+
+	publishedJSON, err := json.Marshal(p.Published)
+	if err != nil {
+		return fmt.Errorf("marshaling published ports: %w", err)
+	}
+
+	// A new puck counts as just used
+	lastUsed := p.LastUsedAt
+	if lastUsed.IsZero() {
+		lastUsed = p.CreatedAt
+	}
+
 	_, err = db.ExecContext(ctx, `
-		INSERT INTO pucks (id, name, image, status, volume_dir, ports, host_port, container_ip, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, p.ID, p.Name, p.Image, p.Status, p.VolumeDir, string(portsJSON), p.HostPort, p.ContainerIP, p.CreatedAt, p.UpdatedAt)
+		INSERT INTO pucks (id, container_id, name, image, status, volume_dir, ports, host_port, host_port_pinned, published, container_ip, route_config, owner, last_used_at, spec, requires, resources, egress, project, notes, pool, expires_at, expiry_snapshot, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, p.ID, p.ContainerID, p.Name, p.Image, p.Status, p.VolumeDir, string(portsJSON), p.HostPort, p.HostPortPinned, string(publishedJSON), p.ContainerIP, string(routeJSON), p.Owner, lastUsed, string(specJSON), string(requiresJSON), string(resourcesJSON), string(egressJSON), p.Project, p.Notes, p.Pool, expiresAt(p.ExpiresAt), p.ExpirySnapshot, p.CreatedAt, p.UpdatedAt)
 
+	if isUniqueViolation(err) {
+		return fmt.Errorf("puck '%s' %w", p.Name, ErrExists)
+	}
 	if err != nil {
 		return fmt.Errorf("inserting puck: %w", err)
 	}
@@ -68,7 +628,7 @@ func (db *DB) CreatePuck(ctx context.Context, p *Puck) error {
 // GetPuck retrieves a puck by name
 func (db *DB) GetPuck(ctx context.Context, name string) (*Puck, error) {
 	row := db.QueryRowContext(ctx, `
-		SELECT id, name, image, status, volume_dir, ports, host_port, container_ip, tailscale_ip, funnel_url, created_at, updated_at
+		SELECT `+puckColumns+`
 		FROM pucks WHERE name = ?
 	`, name)
 
@@ -78,7 +638,7 @@ func (db *DB) GetPuck(ctx context.Context, name string) (*Puck, error) {
 // GetPuckByID retrieves a puck by ID
 func (db *DB) GetPuckByID(ctx context.Context, id string) (*Puck, error) {
 	row := db.QueryRowContext(ctx, `
-		SELECT id, name, image, status, volume_dir, ports, host_port, container_ip, tailscale_ip, funnel_url, created_at, updated_at
+		SELECT `+puckColumns+`
 		FROM pucks WHERE id = ?
 	`, id)
 
@@ -88,7 +648,7 @@ func (db *DB) GetPuckByID(ctx context.Context, id string) (*Puck, error) {
 // ListPucks returns all pucks
 func (db *DB) ListPucks(ctx context.Context) ([]*Puck, error) {
 	rows, err := db.QueryContext(ctx, `
-		SELECT id, name, image, status, volume_dir, ports, host_port, container_ip, tailscale_ip, funnel_url, created_at, updated_at
+		SELECT `+puckColumns+`
 		FROM pucks ORDER BY created_at DESC
 	`)
 	if err != nil {
@@ -112,6 +672,46 @@ func (db *DB) ListPucks(ctx context.Context) ([]*Puck, error) {
 	return pucks, nil
 }
 
+// UpdatePuckImage updates the image a puck's container runs
+func (db *DB) UpdatePuckImage(ctx context.Context, name, image string) error {
+	result, err := db.ExecContext(ctx, `
+		UPDATE pucks SET image = ?, updated_at = ? WHERE name = ?
+	`, image, time.Now(), name)
+	if err != nil {
+		return fmt.Errorf("updating puck image: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("puck '%s' %w", name, ErrNotFound)
+	}
+
+	return nil
+}
+
+// UpdatePuckVolumeDir updates where a puck's volumes are kept
+func (db *DB) UpdatePuckVolumeDir(ctx context.Context, name, dir string) error {
+	result, err := db.ExecContext(ctx, `
+		UPDATE pucks SET volume_dir = ?, updated_at = ? WHERE name = ?
+	`, dir, time.Now(), name)
+	if err != nil {
+		return fmt.Errorf("updating puck volume directory: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("puck '%s' %w", name, ErrNotFound)
+	}
+
+	return nil
+}
+
 // UpdatePuckStatus updates a puck's status
 func (db *DB) UpdatePuckStatus(ctx context.Context, name string, status Status) error {
 	result, err := db.ExecContext(ctx, `
@@ -126,7 +726,7 @@ func (db *DB) UpdatePuckStatus(ctx context.Context, name string, status Status)
 		return err
 	}
 	if rows == 0 {
-		return fmt.Errorf("puck '%s' not found", name)
+		return fmt.Errorf("puck '%s' %w", name, ErrNotFound)
 	}
 
 	return nil
@@ -140,6 +740,36 @@ func (db *DB) UpdatePuckContainerIP(ctx context.Context, name, ip string) error
 	return err
 }
 
+// UpdatePuckHostPort updates a puck's routed host port
+func (db *DB) UpdatePuckHostPort(ctx context.Context, name string, port int) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE pucks SET host_port = ?, updated_at = ? WHERE name = ?
+	`, port, time.Now(), name)
+	return err
+}
+
+// UpdatePuckPublished records the host ports podman gave a puck's exposed
+// ports
+func (db *DB) UpdatePuckPublished(ctx context.Context, name string, published []string) error {
+	publishedJSON, err := json.Marshal(published)
+	if err != nil {
+		return fmt.Errorf("marshaling published ports: %w", err)
+	}
+	_, err = db.ExecContext(ctx, `
+		UPDATE pucks SET published = ?, updated_at = ? WHERE name = ?
+	`, string(publishedJSON), time.Now(), name)
+	return err
+}
+
+// UpdatePuckPinnedPort sets a puck's host port and whether it is pinned
+// there
+func (db *DB) UpdatePuckPinnedPort(ctx context.Context, name string, port int, pinned bool) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE pucks SET host_port = ?, host_port_pinned = ?, updated_at = ? WHERE name = ?
+	`, port, pinned, time.Now(), name)
+	return err
+}
+
 // UpdatePuckTailscale updates a puck's Tailscale info
 func (db *DB) UpdatePuckTailscale(ctx context.Context, name, tailscaleIP, funnelURL string) error {
 	_, err := db.ExecContext(ctx, `
@@ -148,6 +778,243 @@ func (db *DB) UpdatePuckTailscale(ctx context.Context, name, tailscaleIP, funnel
 	return err
 }
 
+// UpdatePuckRouteConfig replaces a puck's router settings
+func (db *DB) UpdatePuckRouteConfig(ctx context.Context, name string, rc RouteConfig) error {
+	routeJSON, err := json.Marshal(rc)
+	if err != nil {
+		return fmt.Errorf("marshaling route config: %w", err)
+	}
+
+	result, err := db.ExecContext(ctx, `
+		UPDATE pucks SET route_config = ?, updated_at = ? WHERE name = ?
+	`, string(routeJSON), time.Now(), name)
+	if err != nil {
+		return fmt.Errorf("updating route config: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("puck '%s' %w", name, ErrNotFound)
+	}
+
+	return nil
+}
+
+// UpdatePuckRequires records the pucks a puck depends on
+func (db *DB) UpdatePuckRequires(ctx context.Context, name string, requires []string) error {
+	requiresJSON, err := json.Marshal(requires)
+	if err != nil {
+		return fmt.Errorf("marshaling requirements: %w", err)
+	}
+
+	result, err := db.ExecContext(ctx, `
+		UPDATE pucks SET requires = ?, updated_at = ? WHERE name = ?
+	`, string(requiresJSON), time.Now(), name)
+	if err != nil {
+		return fmt.Errorf("updating requirements: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("puck '%s' %w", name, ErrNotFound)
+	}
+
+	return nil
+}
+
+// UpdatePuckResources records a puck's CPU and memory limits
+func (db *DB) UpdatePuckResources(ctx context.Context, name string, res Resources) error {
+	resourcesJSON, err := json.Marshal(res)
+	if err != nil {
+		return fmt.Errorf("marshaling resources: %w", err)
+	}
+
+	result, err := db.ExecContext(ctx, `
+		UPDATE pucks SET resources = ?, updated_at = ? WHERE name = ?
+	`, string(resourcesJSON), time.Now(), name)
+	if err != nil {
+		return fmt.Errorf("updating resources: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("puck '%s' %w", name, ErrNotFound)
+	}
+
+	return nil
+}
+
+// UpdatePuckSpec replaces a puck's container settings
+func (db *DB) UpdatePuckSpec(ctx context.Context, name string, spec Spec) error {
+	specJSON, err := json.Marshal(spec)
+	if err != nil {
+		return fmt.Errorf("marshaling spec: %w", err)
+	}
+
+	result, err := db.ExecContext(ctx, `
+		UPDATE pucks SET spec = ?, updated_at = ? WHERE name = ?
+	`, string(specJSON), time.Now(), name)
+	if err != nil {
+		return fmt.Errorf("updating spec: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("puck '%s' %w", name, ErrNotFound)
+	}
+
+	return nil
+}
+
+// UpdatePuckEgress sets where a puck may open connections to
+func (db *DB) UpdatePuckEgress(ctx context.Context, name string, egress EgressPolicy) error {
+	egressJSON, err := json.Marshal(egress)
+	if err != nil {
+		return fmt.Errorf("marshaling egress policy: %w", err)
+	}
+
+	result, err := db.ExecContext(ctx, `
+		UPDATE pucks SET egress = ?, updated_at = ? WHERE name = ?
+	`, string(egressJSON), time.Now(), name)
+	if err != nil {
+		return fmt.Errorf("updating egress policy: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("puck '%s' %w", name, ErrNotFound)
+	}
+
+	return nil
+}
+
+// UpdatePuckNotes sets what a puck is for, or clears it with ""
+func (db *DB) UpdatePuckNotes(ctx context.Context, name, notes string) error {
+	result, err := db.ExecContext(ctx, `
+		UPDATE pucks SET notes = ?, updated_at = ? WHERE name = ?
+	`, notes, time.Now(), name)
+	if err != nil {
+		return fmt.Errorf("updating notes: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("puck '%s' %w", name, ErrNotFound)
+	}
+
+	return nil
+}
+
+// UpdatePuckSnapshotPolicy sets a puck's snapshot defaults and schedule
+func (db *DB) UpdatePuckSnapshotPolicy(ctx context.Context, name string, policy SnapshotPolicy) error {
+	policyJSON, err := json.Marshal(policy)
+	if err != nil {
+		return fmt.Errorf("marshaling snapshot policy: %w", err)
+	}
+
+	result, err := db.ExecContext(ctx, `
+		UPDATE pucks SET snapshot_policy = ?, updated_at = ? WHERE name = ?
+	`, string(policyJSON), time.Now(), name)
+	if err != nil {
+		return fmt.Errorf("updating snapshot policy: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("puck '%s' %w", name, ErrNotFound)
+	}
+
+	return nil
+}
+
+// UpdatePuckTailnetShare records a puck's tailnet node; nil unshares it
+func (db *DB) UpdatePuckTailnetShare(ctx context.Context, name string, share *TailnetShare) error {
+	var shareJSON string
+	if share != nil {
+		data, err := json.Marshal(share)
+		if err != nil {
+			return fmt.Errorf("marshaling tailnet share: %w", err)
+		}
+		shareJSON = string(data)
+	}
+
+	result, err := db.ExecContext(ctx, `
+		UPDATE pucks SET tailnet_share = ?, updated_at = ? WHERE name = ?
+	`, shareJSON, time.Now(), name)
+	if err != nil {
+		return fmt.Errorf("updating tailnet share: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("puck '%s' %w", name, ErrNotFound)
+	}
+
+	return nil
+}
+
+// UpdatePuckExpiry sets when the daemon destroys a puck, or clears it with
+// a zero time, and whether it archives the puck first
+func (db *DB) UpdatePuckExpiry(ctx context.Context, name string, at time.Time, snapshot bool) error {
+	result, err := db.ExecContext(ctx, `
+		UPDATE pucks SET expires_at = ?, expiry_snapshot = ?, updated_at = ? WHERE name = ?
+	`, expiresAt(at), snapshot, time.Now(), name)
+	if err != nil {
+		return fmt.Errorf("updating expiry: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return fmt.Errorf("puck '%s' %w", name, ErrNotFound)
+	}
+
+	return nil
+}
+
+// expiresAt stores a zero expiry as NULL, for never
+func expiresAt(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// ClaimUnownedPucks assigns pucks without an owner, such as those created
+// before ownership was tracked, to owner
+func (db *DB) ClaimUnownedPucks(ctx context.Context, owner string) (int64, error) {
+	result, err := db.ExecContext(ctx, `
+		UPDATE pucks SET owner = ? WHERE owner IS NULL OR owner = ''
+	`, owner)
+	if err != nil {
+		return 0, fmt.Errorf("claiming pucks: %w", err)
+	}
+	return result.RowsAffected()
+}
+
 // DeletePuck deletes a puck by name
 func (db *DB) DeletePuck(ctx context.Context, name string) error {
 	result, err := db.ExecContext(ctx, `DELETE FROM pucks WHERE name = ?`, name)
@@ -160,67 +1027,172 @@ func (db *DB) DeletePuck(ctx context.Context, name string) error {
 		return err
 	}
 	if rows == 0 {
-		return fmt.Errorf("puck '%s' not found", name)
+		return fmt.Errorf("puck '%s' %w", name, ErrNotFound)
 	}
 
 	return nil
 }
 
-// scanPuck scans a single row into a Puck
-func scanPuck(row *sql.Row) (*Puck, error) {
-	var p Puck
-	var portsJSON string
-	var hostPort sql.NullInt64
-	var tailscaleIP, funnelURL, containerIP sql.NullString
+// UpdatePuckSnapshotHead records which snapshot a puck's state descends from
+func (db *DB) UpdatePuckSnapshotHead(ctx context.Context, name, snapshotID string) error {
+	result, err := db.ExecContext(ctx, `
+		UPDATE pucks SET snapshot_head = ?, updated_at = ? WHERE name = ?
+	`, snapshotID, time.Now(), name)
+	if err != nil {
+		return fmt.Errorf("updating snapshot head: %w", err)
+	}
 
-	err := row.Scan(
-		&p.ID, &p.Name, &p.Image, &p.Status, &p.VolumeDir,
-		&portsJSON, &hostPort, &containerIP, &tailscaleIP, &funnelURL,
-		&p.CreatedAt, &p.UpdatedAt,
-	)
-	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("puck not found")
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("puck '%s' %w", name, ErrNotFound)
 	}
+
+	return nil
+}
+
+// UpdatePuckResumeSnapshot records the checkpoint a puck resumes from;
+// empty clears it
+func (db *DB) UpdatePuckResumeSnapshot(ctx context.Context, name, snapshotID string) error {
+	result, err := db.ExecContext(ctx, `
+		UPDATE pucks SET resume_snapshot = ?, updated_at = ? WHERE name = ?
+	`, snapshotID, time.Now(), name)
 	if err != nil {
-		return nil, fmt.Errorf("scanning puck: %w", err)
+		return fmt.Errorf("updating resume snapshot: %w", err)
 	}
 
-	if err := json.Unmarshal([]byte(portsJSON), &p.Ports); err != nil {
-		p.Ports = []string{}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("puck '%s' %w", name, ErrNotFound)
 	}
 
-	p.HostPort = int(hostPort.Int64)
-	p.ContainerIP = containerIP.String
-	p.TailscaleIP = tailscaleIP.String
-	p.FunnelURL = funnelURL.String
+	return nil
+}
 
-	return &p, nil
+// TouchPuck records that a puck was used at t
+func (db *DB) TouchPuck(ctx context.Context, name string, t time.Time) error {
+	result, err := db.ExecContext(ctx, `UPDATE pucks SET last_used_at = ? WHERE name = ?`, t, name)
+	if err != nil {
+		return fmt.Errorf("updating last used time: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("puck '%s' %w", name, ErrNotFound)
+	}
+
+	return nil
+}
+
+// UpdatePuckContainerID points a puck at a replacement container. The
+// puck keeps its ID, so its snapshots stay attached.
+func (db *DB) UpdatePuckContainerID(ctx context.Context, name, containerID string) error {
+	result, err := db.ExecContext(ctx, `
+		UPDATE pucks SET container_id = ?, updated_at = ? WHERE name = ?
+	`, containerID, time.Now(), name)
+	if err != nil {
+		return fmt.Errorf("updating puck container: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("puck '%s' %w", name, ErrNotFound)
+	}
+
+	return nil
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanPuck scans a single row into a Puck
+func scanPuck(row *sql.Row) (*Puck, error) {
+	p, err := scanPuckFields(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("puck %w", ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning puck: %w", err)
+	}
+	return p, nil
 }
 
 // scanPuckRow scans a row from rows.Next() into a Puck
 func scanPuckRow(rows *sql.Rows) (*Puck, error) {
+	p, err := scanPuckFields(rows)
+	if err != nil {
+		return nil, fmt.Errorf("scanning puck row: %w", err)
+	}
+	return p, nil
+}
+
+// scanPuckFields reads the columns listed in puckColumns
+func scanPuckFields(row rowScanner) (*Puck, error) {
 	var p Puck
 	var portsJSON string
 	var hostPort sql.NullInt64
-	var tailscaleIP, funnelURL, containerIP sql.NullString
+	var pinned, expirySnapshot sql.NullBool
+	var publishedJSON sql.NullString
+	var containerID, tailscaleIP, funnelURL, containerIP, routeJSON, owner, tailnetJSON, head, resume, resourcesJSON, specJSON, requiresJSON, egressJSON, project, notes, policyJSON, pool sql.NullString
+	var lastUsed, expires sql.NullTime
 
-	err := rows.Scan(
-		&p.ID, &p.Name, &p.Image, &p.Status, &p.VolumeDir,
-		&portsJSON, &hostPort, &containerIP, &tailscaleIP, &funnelURL,
-		&p.CreatedAt, &p.UpdatedAt,
+	err := row.Scan(
+		&p.ID, &containerID, &p.Name, &p.Image, &p.Status, &p.VolumeDir,
+		&portsJSON, &hostPort, &pinned, &publishedJSON, &containerIP, &tailscaleIP, &funnelURL,
+		&routeJSON, &owner, &tailnetJSON, &head, &resume, &resourcesJSON, &lastUsed, &specJSON, &requiresJSON, &egressJSON, &project, &notes, &policyJSON, &pool, &expires, &expirySnapshot, &p.CreatedAt, &p.UpdatedAt,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("scanning puck row: %w", err)
+		return nil, err
 	}
 
 	if err := json.Unmarshal([]byte(portsJSON), &p.Ports); err != nil {
 		p.Ports = []string{}
 	}
+	if publishedJSON.String != "" {
+		json.Unmarshal([]byte(publishedJSON.String), &p.Published)
+	}
+	if routeJSON.String != "" {
+		// Unreadable settings fall back to router defaults
+		json.Unmarshal([]byte(routeJSON.String), &p.Route)
+	}
+	if resourcesJSON.String != "" {
+		json.Unmarshal([]byte(resourcesJSON.String), &p.Resources)
+	}
+	if specJSON.String != "" {
+		json.Unmarshal([]byte(specJSON.String), &p.Spec)
+	}
+	if requiresJSON.String != "" {
+		json.Unmarshal([]byte(requiresJSON.String), &p.Requires)
+	}
+	if egressJSON.String != "" {
+		json.Unmarshal([]byte(egressJSON.String), &p.Egress)
+	}
+	if policyJSON.String != "" {
+		json.Unmarshal([]byte(policyJSON.String), &p.SnapshotPolicy)
+	}
+	if tailnetJSON.String != "" {
+		var share TailnetShare
+		if err := json.Unmarshal([]byte(tailnetJSON.String), &share); err == nil {
+			p.Tailnet = &share
+		}
+	}
 
+	p.ContainerID = containerID.String
 	p.HostPort = int(hostPort.Int64)
+	p.HostPortPinned = pinned.Bool
 	p.ContainerIP = containerIP.String
 	p.TailscaleIP = tailscaleIP.String
 	p.FunnelURL = funnelURL.String
+	p.Owner = owner.String
+	p.SnapshotHead = head.String
+	p.ResumeSnapshot = resume.String
+	p.LastUsedAt = lastUsed.Time
+	p.Project = project.String
+	p.Notes = notes.String
+	p.Pool = pool.String
+	p.ExpiresAt = expires.Time
+	p.ExpirySnapshot = expirySnapshot.Bool
 
 	return &p, nil
 }
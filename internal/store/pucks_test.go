@@ -75,7 +75,8 @@ func TestCreatePuck(t *testing.T) {
 		puck2 := createTestPuck("duplicate-puck")
 		puck2.ID = "different-id" // Different ID but same name
 		err = db.CreatePuck(ctx, puck2)
-		assert.Error(t, err, "should fail with duplicate name")
+		assert.ErrorIs(t, err, ErrExists, "should fail with duplicate name")
+		assert.Equal(t, "puck 'duplicate-puck' already exists", err.Error())
 	})
 
 	t.Run("handles empty ports array", func(t *testing.T) {
@@ -249,6 +250,110 @@ func TestUpdatePuckContainerIP(t *testing.T) {
 	})
 }
 
+func TestUpdatePuckHostPort(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	t.Run("updates host port", func(t *testing.T) {
+		puck := createTestPuck("port-puck")
+		err := db.CreatePuck(ctx, puck)
+		require.NoError(t, err)
+
+		err = db.UpdatePuckHostPort(ctx, "port-puck", 9042)
+		require.NoError(t, err)
+
+		retrieved, err := db.GetPuck(ctx, "port-puck")
+		require.NoError(t, err)
+		assert.Equal(t, 9042, retrieved.HostPort)
+		assert.False(t, retrieved.HostPortPinned)
+	})
+
+	t.Run("pins a host port", func(t *testing.T) {
+		require.NoError(t, db.UpdatePuckPinnedPort(ctx, "port-puck", 9123, true))
+
+		retrieved, err := db.GetPuck(ctx, "port-puck")
+		require.NoError(t, err)
+		assert.Equal(t, 9123, retrieved.HostPort)
+		assert.True(t, retrieved.HostPortPinned)
+	})
+
+	t.Run("records published ports", func(t *testing.T) {
+		published := []string{"32768:3000/tcp", "32769:5353/udp"}
+		require.NoError(t, db.UpdatePuckPublished(ctx, "port-puck", published))
+
+		retrieved, err := db.GetPuck(ctx, "port-puck")
+		require.NoError(t, err)
+		assert.Equal(t, published, retrieved.Published)
+	})
+}
+
+func TestUpdatePuckNotes(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	require.NoError(t, db.CreatePuck(ctx, createTestPuck("notes-puck")))
+
+	t.Run("sets notes", func(t *testing.T) {
+		require.NoError(t, db.UpdatePuckNotes(ctx, "notes-puck", "staging clone of prod db"))
+
+		retrieved, err := db.GetPuck(ctx, "notes-puck")
+		require.NoError(t, err)
+		assert.Equal(t, "staging clone of prod db", retrieved.Notes)
+	})
+
+	t.Run("clears notes", func(t *testing.T) {
+		require.NoError(t, db.UpdatePuckNotes(ctx, "notes-puck", ""))
+
+		retrieved, err := db.GetPuck(ctx, "notes-puck")
+		require.NoError(t, err)
+		assert.Empty(t, retrieved.Notes)
+	})
+
+	t.Run("fails for missing puck", func(t *testing.T) {
+		err := db.UpdatePuckNotes(ctx, "missing", "x")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestUpdatePuckExpiry(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	p := createTestPuck("ttl-puck")
+	p.ExpiresAt = time.Now().Add(time.Hour).Truncate(time.Second)
+	require.NoError(t, db.CreatePuck(ctx, p))
+
+	retrieved, err := db.GetPuck(ctx, "ttl-puck")
+	require.NoError(t, err)
+	assert.True(t, p.ExpiresAt.Equal(retrieved.ExpiresAt))
+	assert.False(t, retrieved.ExpirySnapshot)
+
+	t.Run("sets expiry", func(t *testing.T) {
+		at := time.Now().Add(4 * time.Hour).Truncate(time.Second)
+		require.NoError(t, db.UpdatePuckExpiry(ctx, "ttl-puck", at, true))
+
+		retrieved, err := db.GetPuck(ctx, "ttl-puck")
+		require.NoError(t, err)
+		assert.True(t, at.Equal(retrieved.ExpiresAt))
+		assert.True(t, retrieved.ExpirySnapshot)
+	})
+
+	t.Run("clears expiry", func(t *testing.T) {
+		require.NoError(t, db.UpdatePuckExpiry(ctx, "ttl-puck", time.Time{}, false))
+
+		retrieved, err := db.GetPuck(ctx, "ttl-puck")
+		require.NoError(t, err)
+		assert.True(t, retrieved.ExpiresAt.IsZero())
+	})
+
+	t.Run("fails for missing puck", func(t *testing.T) {
+		assert.ErrorIs(t, db.UpdatePuckExpiry(ctx, "missing", time.Now(), false), ErrNotFound)
+	})
+}
+
 func TestUpdatePuckTailscale(t *testing.T) {
 	db, cleanup := setupTestDB(t)
 	defer cleanup()
@@ -269,6 +374,288 @@ func TestUpdatePuckTailscale(t *testing.T) {
 	})
 }
 
+func TestUpdatePuckRouteConfig(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	t.Run("defaults to empty route config", func(t *testing.T) {
+		puck := createTestPuck("route-default-puck")
+		err := db.CreatePuck(ctx, puck)
+		require.NoError(t, err)
+
+		retrieved, err := db.GetPuck(ctx, "route-default-puck")
+		require.NoError(t, err)
+		assert.Equal(t, RouteConfig{}, retrieved.Route)
+	})
+
+	t.Run("persists route settings", func(t *testing.T) {
+		puck := createTestPuck("route-puck")
+		err := db.CreatePuck(ctx, puck)
+		require.NoError(t, err)
+
+		rc := RouteConfig{FlushInterval: -1, StreamTimeout: time.Hour}
+		err = db.UpdatePuckRouteConfig(ctx, "route-puck", rc)
+		require.NoError(t, err)
+
+		retrieved, err := db.GetPuck(ctx, "route-puck")
+		require.NoError(t, err)
+		assert.Equal(t, rc, retrieved.Route)
+
+		pucks, err := db.ListPucks(ctx)
+		require.NoError(t, err)
+		for _, p := range pucks {
+			if p.Name == "route-puck" {
+				assert.Equal(t, rc, p.Route)
+			}
+		}
+	})
+
+	t.Run("returns error for non-existent puck", func(t *testing.T) {
+		err := db.UpdatePuckRouteConfig(ctx, "non-existent", RouteConfig{})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "not found")
+	})
+}
+
+func TestTouchPuck(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	puck := createTestPuck("touch-puck")
+	require.NoError(t, db.CreatePuck(ctx, puck))
+
+	t.Run("starts at creation time", func(t *testing.T) {
+		p, err := db.GetPuck(ctx, "touch-puck")
+		require.NoError(t, err)
+		assert.WithinDuration(t, puck.CreatedAt, p.LastUsedAt, time.Second)
+	})
+
+	t.Run("records use", func(t *testing.T) {
+		later := time.Now().Add(time.Hour)
+		require.NoError(t, db.TouchPuck(ctx, "touch-puck", later))
+
+		p, err := db.GetPuck(ctx, "touch-puck")
+		require.NoError(t, err)
+		assert.WithinDuration(t, later, p.LastUsedAt, time.Second)
+	})
+
+	t.Run("returns error for non-existent puck", func(t *testing.T) {
+		assert.ErrorContains(t, db.TouchPuck(ctx, "non-existent", time.Now()), "not found")
+	})
+}
+
+func TestUpdatePuckResources(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	require.NoError(t, db.CreatePuck(ctx, createTestPuck("res-puck")))
+
+	t.Run("unlimited by default", func(t *testing.T) {
+		p, err := db.GetPuck(ctx, "res-puck")
+		require.NoError(t, err)
+		assert.Equal(t, Resources{}, p.Resources)
+	})
+
+	t.Run("stores limits", func(t *testing.T) {
+		res := Resources{Memory: 2 << 30, CPUs: 1.5, Pending: true}
+		require.NoError(t, db.UpdatePuckResources(ctx, "res-puck", res))
+
+		p, err := db.GetPuck(ctx, "res-puck")
+		require.NoError(t, err)
+		assert.Equal(t, res, p.Resources)
+	})
+
+	t.Run("returns error for non-existent puck", func(t *testing.T) {
+		err := db.UpdatePuckResources(ctx, "non-existent", Resources{})
+		assert.ErrorContains(t, err, "not found")
+	})
+}
+
+func TestUpdatePuckRequires(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	app := createTestPuck("app")
+	app.Requires = []string{"db"}
+	require.NoError(t, db.CreatePuck(ctx, app))
+
+	p, err := db.GetPuck(ctx, "app")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db"}, p.Requires)
+
+	require.NoError(t, db.UpdatePuckRequires(ctx, "app", []string{"db", "cache"}))
+	p, err = db.GetPuck(ctx, "app")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"db", "cache"}, p.Requires)
+
+	require.NoError(t, db.UpdatePuckRequires(ctx, "app", nil))
+	p, err = db.GetPuck(ctx, "app")
+	require.NoError(t, err)
+	assert.Empty(t, p.Requires)
+
+	assert.ErrorContains(t, db.UpdatePuckRequires(ctx, "non-existent", nil), "not found")
+}
+
+func TestPuckProject(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	app := createTestPuck("app")
+	app.Project = "demo"
+	require.NoError(t, db.CreatePuck(ctx, app))
+	require.NoError(t, db.CreatePuck(ctx, createTestPuck("other")))
+
+	p, err := db.GetPuck(ctx, "app")
+	require.NoError(t, err)
+	assert.Equal(t, "demo", p.Project)
+
+	p, err = db.GetPuck(ctx, "other")
+	require.NoError(t, err)
+	assert.Empty(t, p.Project)
+}
+
+func TestUpdatePuckEgress(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	app := createTestPuck("app")
+	app.Egress = EgressPolicy{Mode: EgressTailnet}
+	require.NoError(t, db.CreatePuck(ctx, app))
+
+	p, err := db.GetPuck(ctx, "app")
+	require.NoError(t, err)
+	assert.Equal(t, EgressTailnet, p.Egress.EffectiveMode())
+
+	policy := EgressPolicy{Mode: EgressAllowlist, Allow: []string{"10.0.0.0/8", "github.com"}}
+	require.NoError(t, db.UpdatePuckEgress(ctx, "app", policy))
+	p, err = db.GetPuck(ctx, "app")
+	require.NoError(t, err)
+	assert.Equal(t, policy, p.Egress)
+	assert.Equal(t, "allowlist: 10.0.0.0/8, github.com", p.Egress.String())
+
+	require.NoError(t, db.UpdatePuckEgress(ctx, "app", EgressPolicy{}))
+	p, err = db.GetPuck(ctx, "app")
+	require.NoError(t, err)
+	assert.Equal(t, EgressAll, p.Egress.EffectiveMode())
+
+	assert.ErrorContains(t, db.UpdatePuckEgress(ctx, "non-existent", EgressPolicy{}), "not found")
+}
+
+func TestPuckSpec(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	t.Run("defaults to systemd", func(t *testing.T) {
+		require.NoError(t, db.CreatePuck(ctx, createTestPuck("default-spec")))
+
+		p, err := db.GetPuck(ctx, "default-spec")
+		require.NoError(t, err)
+		assert.Equal(t, Spec{}, p.Spec)
+		assert.Equal(t, InitSystemd, p.Spec.InitMode())
+	})
+
+	t.Run("stores the spec", func(t *testing.T) {
+		puck := createTestPuck("tini-spec")
+		puck.ID = "test-id-tini"
+		puck.Spec = Spec{Init: InitTini}
+		require.NoError(t, db.CreatePuck(ctx, puck))
+
+		p, err := db.GetPuck(ctx, "tini-spec")
+		require.NoError(t, err)
+		assert.Equal(t, InitTini, p.Spec.InitMode())
+	})
+
+	t.Run("updates the spec", func(t *testing.T) {
+		spec := Spec{Init: InitTini, Env: map[string]string{"PORT": "3000"}, SecretEnv: []string{"SIGNING"}}
+		require.NoError(t, db.UpdatePuckSpec(ctx, "tini-spec", spec))
+
+		p, err := db.GetPuck(ctx, "tini-spec")
+		require.NoError(t, err)
+		assert.Equal(t, spec, p.Spec)
+
+		err = db.UpdatePuckSpec(ctx, "nonexistent", spec)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestIsSecretEnv(t *testing.T) {
+	spec := Spec{SecretEnv: []string{"SIGNING"}}
+	for _, key := range []string{"SIGNING", "API_KEY", "GITHUB_TOKEN", "db_password", "AWS_SECRET_ACCESS_KEY"} {
+		assert.True(t, spec.IsSecretEnv(key), key)
+	}
+	for _, key := range []string{"PORT", "KEYBOARD", "TOKENIZER_MODEL", "PATH"} {
+		assert.False(t, spec.IsSecretEnv(key), key)
+	}
+}
+
+func TestUpdatePuckTailnetShare(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	require.NoError(t, db.CreatePuck(ctx, createTestPuck("ts-puck")))
+
+	t.Run("not shared by default", func(t *testing.T) {
+		p, err := db.GetPuck(ctx, "ts-puck")
+		require.NoError(t, err)
+		assert.Nil(t, p.Tailnet)
+	})
+
+	t.Run("stores tags", func(t *testing.T) {
+		share := &TailnetShare{Tags: []string{"tag:dev", "tag:web"}, SharedAt: time.Now().UTC().Truncate(time.Second)}
+		require.NoError(t, db.UpdatePuckTailnetShare(ctx, "ts-puck", share))
+
+		p, err := db.GetPuck(ctx, "ts-puck")
+		require.NoError(t, err)
+		require.NotNil(t, p.Tailnet)
+		assert.Equal(t, share.Tags, p.Tailnet.Tags)
+		assert.True(t, share.SharedAt.Equal(p.Tailnet.SharedAt))
+	})
+
+	t.Run("nil unshares", func(t *testing.T) {
+		require.NoError(t, db.UpdatePuckTailnetShare(ctx, "ts-puck", nil))
+
+		p, err := db.GetPuck(ctx, "ts-puck")
+		require.NoError(t, err)
+		assert.Nil(t, p.Tailnet)
+	})
+
+	t.Run("returns error for non-existent puck", func(t *testing.T) {
+		err := db.UpdatePuckTailnetShare(ctx, "non-existent", nil)
+		assert.ErrorContains(t, err, "not found")
+	})
+}
+
+func TestClaimUnownedPucks(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	owned := createTestPuck("owned-puck")
+	owned.Owner = "alice"
+	require.NoError(t, db.CreatePuck(ctx, owned))
+	require.NoError(t, db.CreatePuck(ctx, createTestPuck("legacy-puck")))
+
+	n, err := db.ClaimUnownedPucks(ctx, "bob")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	p, err := db.GetPuck(ctx, "owned-puck")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", p.Owner)
+
+	p, err = db.GetPuck(ctx, "legacy-puck")
+	require.NoError(t, err)
+	assert.Equal(t, "bob", p.Owner)
+}
+
 func TestDeletePuck(t *testing.T) {
 	db, cleanup := setupTestDB(t)
 	defer cleanup()
@@ -300,7 +687,91 @@ func TestStatus(t *testing.T) {
 		assert.Equal(t, Status("running"), StatusRunning)
 		assert.Equal(t, Status("stopped"), StatusStopped)
 		assert.Equal(t, Status("checkpointed"), StatusCheckpointed)
+		assert.Equal(t, Status("suspended"), StatusSuspended)
 		assert.Equal(t, Status("creating"), StatusCreating)
 		assert.Equal(t, Status("error"), StatusError)
 	})
 }
+
+func TestUpdatePuckContainerID(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	puck := createTestPuck("replaced-puck")
+	require.NoError(t, db.CreatePuck(ctx, puck))
+	require.NoError(t, db.CreateSnapshot(ctx, createTestSnapshot(puck.ID, puck.Name, "before")))
+
+	t.Run("keeps the puck's ID and snapshots", func(t *testing.T) {
+		require.NoError(t, db.UpdatePuckContainerID(ctx, "replaced-puck", "new-container"))
+
+		retrieved, err := db.GetPuck(ctx, "replaced-puck")
+		require.NoError(t, err)
+		assert.Equal(t, puck.ID, retrieved.ID)
+		assert.Equal(t, "new-container", retrieved.ContainerID)
+
+		snapshots, err := db.ListSnapshots(ctx, puck.ID)
+		require.NoError(t, err)
+		assert.Len(t, snapshots, 1)
+	})
+
+	t.Run("returns error for non-existent puck", func(t *testing.T) {
+		err := db.UpdatePuckContainerID(ctx, "non-existent", "x")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "not found")
+	})
+}
+
+func TestUpdatePuckSnapshotHead(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	require.NoError(t, db.CreatePuck(ctx, createTestPuck("head-puck")))
+
+	require.NoError(t, db.UpdatePuckSnapshotHead(ctx, "head-puck", "snap-1"))
+	retrieved, err := db.GetPuck(ctx, "head-puck")
+	require.NoError(t, err)
+	assert.Equal(t, "snap-1", retrieved.SnapshotHead)
+
+	assert.Error(t, db.UpdatePuckSnapshotHead(ctx, "non-existent", "snap-1"))
+}
+
+func TestUpdatePuckResumeSnapshot(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	require.NoError(t, db.CreatePuck(ctx, createTestPuck("resume-puck")))
+
+	require.NoError(t, db.UpdatePuckResumeSnapshot(ctx, "resume-puck", "snap-1"))
+	retrieved, err := db.GetPuck(ctx, "resume-puck")
+	require.NoError(t, err)
+	assert.Equal(t, "snap-1", retrieved.ResumeSnapshot)
+
+	require.NoError(t, db.UpdatePuckResumeSnapshot(ctx, "resume-puck", ""))
+	retrieved, err = db.GetPuck(ctx, "resume-puck")
+	require.NoError(t, err)
+	assert.Empty(t, retrieved.ResumeSnapshot)
+
+	assert.Error(t, db.UpdatePuckResumeSnapshot(ctx, "non-existent", "snap-1"))
+}
+
+func TestUpdatePuckSnapshotPolicy(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	require.NoError(t, db.CreatePuck(ctx, createTestPuck("policy-puck")))
+	retrieved, err := db.GetPuck(ctx, "policy-puck")
+	require.NoError(t, err)
+	assert.Equal(t, SnapshotPolicy{}, retrieved.SnapshotPolicy)
+
+	policy := SnapshotPolicy{LeaveRunning: true, Compression: "zstd", IncludeVolumes: true, Schedule: "6h", Retain: 4}
+	require.NoError(t, db.UpdatePuckSnapshotPolicy(ctx, "policy-puck", policy))
+	retrieved, err = db.GetPuck(ctx, "policy-puck")
+	require.NoError(t, err)
+	assert.Equal(t, policy, retrieved.SnapshotPolicy)
+
+	assert.Error(t, db.UpdatePuckSnapshotPolicy(ctx, "non-existent", policy))
+}
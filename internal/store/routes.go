@@ -0,0 +1,91 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+const routeAliasColumns = `path, puck_name, created_by, created_at`
+
+// SetRouteAlias stores an alias, pointing an existing path at its new puck
+func (db *DB) SetRouteAlias(ctx context.Context, a *RouteAlias) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO routes (`+routeAliasColumns+`)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (path) DO UPDATE SET puck_name = excluded.puck_name,
+			created_by = excluded.created_by, created_at = excluded.created_at
+	`, a.Path, a.PuckName, a.CreatedBy, a.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("storing route alias: %w", err)
+	}
+
+	return nil
+}
+
+// GetRouteAlias retrieves an alias by path
+func (db *DB) GetRouteAlias(ctx context.Context, path string) (*RouteAlias, error) {
+	row := db.QueryRowContext(ctx, `SELECT `+routeAliasColumns+` FROM routes WHERE path = ?`, path)
+
+	var a RouteAlias
+	err := row.Scan(&a.Path, &a.PuckName, &a.CreatedBy, &a.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("route alias '%s' %w", path, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning route alias: %w", err)
+	}
+
+	return &a, nil
+}
+
+// ListRouteAliases returns the aliases for a puck, or every alias when
+// puckName is empty, ordered by path
+func (db *DB) ListRouteAliases(ctx context.Context, puckName string) ([]*RouteAlias, error) {
+	query := `SELECT ` + routeAliasColumns + ` FROM routes`
+	var args []interface{}
+	if puckName != "" {
+		query += ` WHERE puck_name = ?`
+		args = append(args, puckName)
+	}
+	query += ` ORDER BY path ASC`
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying route aliases: %w", err)
+	}
+	defer rows.Close()
+
+	var aliases []*RouteAlias
+	for rows.Next() {
+		var a RouteAlias
+		if err := rows.Scan(&a.Path, &a.PuckName, &a.CreatedBy, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning route alias row: %w", err)
+		}
+		aliases = append(aliases, &a)
+	}
+
+	return aliases, rows.Err()
+}
+
+// DeleteRouteAlias deletes an alias by path
+func (db *DB) DeleteRouteAlias(ctx context.Context, path string) error {
+	result, err := db.ExecContext(ctx, `DELETE FROM routes WHERE path = ?`, path)
+	if err != nil {
+		return err
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("route alias '%s' %w", path, ErrNotFound)
+	}
+
+	return nil
+}
+
+// DeleteRouteAliasesByPuck deletes all aliases for a puck
+func (db *DB) DeleteRouteAliasesByPuck(ctx context.Context, puckName string) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM routes WHERE puck_name = ?`, puckName)
+	return err
+}
@@ -0,0 +1,67 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteAliases(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	alias := func(path, puckName string) *RouteAlias {
+		return &RouteAlias{Path: path, PuckName: puckName, CreatedBy: "alice", CreatedAt: time.Now()}
+	}
+
+	t.Run("sets and retrieves an alias", func(t *testing.T) {
+		require.NoError(t, db.SetRouteAlias(ctx, alias("/api", "backend-v1")))
+
+		got, err := db.GetRouteAlias(ctx, "/api")
+		require.NoError(t, err)
+		assert.Equal(t, "backend-v1", got.PuckName)
+		assert.Equal(t, "alice", got.CreatedBy)
+	})
+
+	t.Run("repoints an existing path", func(t *testing.T) {
+		require.NoError(t, db.SetRouteAlias(ctx, alias("/api", "backend-v2")))
+
+		got, err := db.GetRouteAlias(ctx, "/api")
+		require.NoError(t, err)
+		assert.Equal(t, "backend-v2", got.PuckName)
+	})
+
+	t.Run("returns error for non-existent alias", func(t *testing.T) {
+		_, err := db.GetRouteAlias(ctx, "/missing")
+		assert.ErrorContains(t, err, "not found")
+		assert.ErrorContains(t, db.DeleteRouteAlias(ctx, "/missing"), "not found")
+	})
+
+	t.Run("lists aliases by puck", func(t *testing.T) {
+		require.NoError(t, db.SetRouteAlias(ctx, alias("/docs", "web")))
+		require.NoError(t, db.SetRouteAlias(ctx, alias("/app", "web")))
+
+		aliases, err := db.ListRouteAliases(ctx, "web")
+		require.NoError(t, err)
+		require.Len(t, aliases, 2)
+		assert.Equal(t, "/app", aliases[0].Path)
+		assert.Equal(t, "/docs", aliases[1].Path)
+
+		all, err := db.ListRouteAliases(ctx, "")
+		require.NoError(t, err)
+		assert.Len(t, all, 3)
+	})
+
+	t.Run("deletes aliases", func(t *testing.T) {
+		require.NoError(t, db.DeleteRouteAlias(ctx, "/api"))
+		require.NoError(t, db.DeleteRouteAliasesByPuck(ctx, "web"))
+
+		all, err := db.ListRouteAliases(ctx, "")
+		require.NoError(t, err)
+		assert.Empty(t, all)
+	})
+}
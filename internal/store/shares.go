@@ -0,0 +1,111 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const shareColumns = `id, puck_name, token, created_by, expires_at, created_at`
+
+// CreateShare stores a new share link
+func (db *DB) CreateShare(ctx context.Context, s *Share) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO shares (`+shareColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, s.ID, s.PuckName, s.Token, s.CreatedBy, s.ExpiresAt, s.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("inserting share: %w", err)
+	}
+
+	return nil
+}
+
+// GetShare retrieves a share by ID
+func (db *DB) GetShare(ctx context.Context, id string) (*Share, error) {
+	row := db.QueryRowContext(ctx, `SELECT `+shareColumns+` FROM shares WHERE id = ?`, id)
+
+	var s Share
+	err := row.Scan(&s.ID, &s.PuckName, &s.Token, &s.CreatedBy, &s.ExpiresAt, &s.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("share '%s' %w", id, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning share: %w", err)
+	}
+
+	return &s, nil
+}
+
+// ListShares returns the shares for a puck, or every share when puckName
+// is empty, soonest to expire first
+func (db *DB) ListShares(ctx context.Context, puckName string) ([]*Share, error) {
+	query := `SELECT ` + shareColumns + ` FROM shares`
+	var args []interface{}
+	if puckName != "" {
+		query += ` WHERE puck_name = ?`
+		args = append(args, puckName)
+	}
+	query += ` ORDER BY expires_at ASC`
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying shares: %w", err)
+	}
+	defer rows.Close()
+
+	var shares []*Share
+	for rows.Next() {
+		var s Share
+		if err := rows.Scan(&s.ID, &s.PuckName, &s.Token, &s.CreatedBy, &s.ExpiresAt, &s.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning share row: %w", err)
+		}
+		shares = append(shares, &s)
+	}
+
+	return shares, rows.Err()
+}
+
+// DeleteShare deletes a share by ID
+func (db *DB) DeleteShare(ctx context.Context, id string) error {
+	result, err := db.ExecContext(ctx, `DELETE FROM shares WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("share '%s' %w", id, ErrNotFound)
+	}
+
+	return nil
+}
+
+// DeleteSharesByPuck deletes all shares for a puck
+func (db *DB) DeleteSharesByPuck(ctx context.Context, puckName string) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM shares WHERE puck_name = ?`, puckName)
+	return err
+}
+
+// DeleteExpiredShares deletes shares that have expired at t and returns
+// them. Expiry is compared in Go since stored timestamps keep their zone.
+func (db *DB) DeleteExpiredShares(ctx context.Context, t time.Time) ([]*Share, error) {
+	shares, err := db.ListShares(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var expired []*Share
+	for _, s := range shares {
+		if !s.Expired(t) {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, `DELETE FROM shares WHERE id = ?`, s.ID); err != nil {
+			return expired, fmt.Errorf("deleting share %s: %w", s.ID, err)
+		}
+		expired = append(expired, s)
+	}
+	return expired, nil
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createTestShare creates a test share expiring after ttl
+func createTestShare(id, puckName string, ttl time.Duration) *Share {
+	now := time.Now()
+	return &Share{
+		ID:        id,
+		PuckName:  puckName,
+		Token:     id + ".sig",
+		CreatedBy: "alice",
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+}
+
+func TestShares(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	t.Run("creates and retrieves a share", func(t *testing.T) {
+		share := createTestShare("share-1", "web", time.Hour)
+		require.NoError(t, db.CreateShare(ctx, share))
+
+		retrieved, err := db.GetShare(ctx, "share-1")
+		require.NoError(t, err)
+		assert.Equal(t, "web", retrieved.PuckName)
+		assert.Equal(t, "share-1.sig", retrieved.Token)
+		assert.Equal(t, "alice", retrieved.CreatedBy)
+		assert.WithinDuration(t, share.ExpiresAt, retrieved.ExpiresAt, time.Second)
+	})
+
+	t.Run("rejects duplicate tokens", func(t *testing.T) {
+		share := createTestShare("share-dup", "web", time.Hour)
+		share.Token = "share-1.sig"
+		assert.Error(t, db.CreateShare(ctx, share))
+	})
+
+	t.Run("returns error for non-existent share", func(t *testing.T) {
+		_, err := db.GetShare(ctx, "missing")
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "not found")
+	})
+
+	t.Run("lists shares by puck", func(t *testing.T) {
+		require.NoError(t, db.CreateShare(ctx, createTestShare("share-2", "api", 2*time.Hour)))
+
+		shares, err := db.ListShares(ctx, "api")
+		require.NoError(t, err)
+		require.Len(t, shares, 1)
+		assert.Equal(t, "share-2", shares[0].ID)
+
+		all, err := db.ListShares(ctx, "")
+		require.NoError(t, err)
+		assert.Len(t, all, 2)
+		assert.Equal(t, "share-1", all[0].ID, "soonest to expire first")
+	})
+
+	t.Run("deletes expired shares", func(t *testing.T) {
+		require.NoError(t, db.CreateShare(ctx, createTestShare("share-old", "web", -time.Minute)))
+
+		expired, err := db.DeleteExpiredShares(ctx, time.Now())
+		require.NoError(t, err)
+		require.Len(t, expired, 1)
+		assert.Equal(t, "share-old", expired[0].ID)
+
+		_, err = db.GetShare(ctx, "share-old")
+		assert.Error(t, err)
+		_, err = db.GetShare(ctx, "share-1")
+		assert.NoError(t, err)
+	})
+
+	t.Run("deletes a share", func(t *testing.T) {
+		require.NoError(t, db.DeleteShare(ctx, "share-2"))
+		assert.Error(t, db.DeleteShare(ctx, "share-2"))
+	})
+
+	t.Run("deletes shares by puck", func(t *testing.T) {
+		require.NoError(t, db.DeleteSharesByPuck(ctx, "web"))
+
+		shares, err := db.ListShares(ctx, "")
+		require.NoError(t, err)
+		assert.Empty(t, shares)
+	})
+}
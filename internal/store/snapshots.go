@@ -3,15 +3,31 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 )
 
+// snapshotColumns lists the columns read by scanSnapshot, in scan order
+const snapshotColumns = `id, puck_id, puck_name, name, path, size_bytes, created_at, parent_id, tags, image, mode, commit_image, criu_version, kernel, podman_version, cold_path, criu_options, volumes_path, image_digest`
+
 // CreateSnapshot creates a new snapshot in the database
 func (db *DB) CreateSnapshot(ctx context.Context, s *Snapshot) error {
-	_, err := db.ExecContext(ctx, `
-		INSERT INTO snapshots (id, puck_id, puck_name, name, path, size_bytes, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-	`, s.ID, s.PuckID, s.PuckName, s.Name, s.Path, s.SizeBytes, s.CreatedAt)
+	tagsJSON, err := json.Marshal(s.Tags)
+	if err != nil {
+		return fmt.Errorf("marshaling tags: %w", err)
+	}
+	if s.Mode == "" {
+		s.Mode = SnapshotModeCheckpoint
+	}
+	criuJSON, err := json.Marshal(s.CRIU)
+	if err != nil {
+		return fmt.Errorf("marshaling CRIU options: %w", err)
+	}
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO snapshots (`+snapshotColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, s.ID, s.PuckID, s.PuckName, s.Name, s.Path, s.SizeBytes, s.CreatedAt, s.ParentID, string(tagsJSON), s.Image, s.Mode, s.CommitImage, s.CRIUVersion, s.Kernel, s.PodmanVersion, s.ColdPath, string(criuJSON), s.VolumesPath, s.ImageDigest)
 
 	if err != nil {
 		return fmt.Errorf("inserting snapshot: %w", err)
@@ -23,26 +39,25 @@ func (db *DB) CreateSnapshot(ctx context.Context, s *Snapshot) error {
 // GetSnapshot retrieves a snapshot by puck ID and name
 func (db *DB) GetSnapshot(ctx context.Context, puckID, name string) (*Snapshot, error) {
 	row := db.QueryRowContext(ctx, `
-		SELECT id, puck_id, puck_name, name, path, size_bytes, created_at
+		SELECT `+snapshotColumns+`
 		FROM snapshots WHERE puck_id = ? AND name = ?
 	`, puckID, name)
 
-	var s Snapshot
-	err := row.Scan(&s.ID, &s.PuckID, &s.PuckName, &s.Name, &s.Path, &s.SizeBytes, &s.CreatedAt)
+	s, err := scanSnapshot(row)
 	if err == sql.ErrNoRows {
-		return nil, fmt.Errorf("snapshot '%s' not found for puck", name)
+		return nil, fmt.Errorf("snapshot '%s' %w for puck", name, ErrNotFound)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("scanning snapshot: %w", err)
 	}
 
-	return &s, nil
+	return s, nil
 }
 
 // ListSnapshots returns all snapshots for a puck
 func (db *DB) ListSnapshots(ctx context.Context, puckID string) ([]*Snapshot, error) {
 	rows, err := db.QueryContext(ctx, `
-		SELECT id, puck_id, puck_name, name, path, size_bytes, created_at
+		SELECT `+snapshotColumns+`
 		FROM snapshots WHERE puck_id = ? ORDER BY created_at DESC
 	`, puckID)
 	if err != nil {
@@ -52,16 +67,99 @@ func (db *DB) ListSnapshots(ctx context.Context, puckID string) ([]*Snapshot, er
 
 	var snapshots []*Snapshot
 	for rows.Next() {
-		var s Snapshot
-		if err := rows.Scan(&s.ID, &s.PuckID, &s.PuckName, &s.Name, &s.Path, &s.SizeBytes, &s.CreatedAt); err != nil {
+		s, err := scanSnapshot(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning snapshot row: %w", err)
+		}
+		snapshots = append(snapshots, s)
+	}
+
+	return snapshots, rows.Err()
+}
+
+// ListAllSnapshots returns every puck's snapshots, oldest first
+func (db *DB) ListAllSnapshots(ctx context.Context) ([]*Snapshot, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+snapshotColumns+`
+		FROM snapshots ORDER BY created_at
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("querying snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	var snapshots []*Snapshot
+	for rows.Next() {
+		s, err := scanSnapshot(rows)
+		if err != nil {
 			return nil, fmt.Errorf("scanning snapshot row: %w", err)
 		}
-		snapshots = append(snapshots, &s)
+		snapshots = append(snapshots, s)
 	}
 
 	return snapshots, rows.Err()
 }
 
+// UpdateSnapshotPuckID points a snapshot at the puck it belongs to
+func (db *DB) UpdateSnapshotPuckID(ctx context.Context, id, puckID string) error {
+	result, err := db.ExecContext(ctx, `UPDATE snapshots SET puck_id = ? WHERE id = ?`, puckID, id)
+	if err != nil {
+		return fmt.Errorf("updating snapshot puck: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("snapshot %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// UpdateSnapshotTags replaces a snapshot's tags
+func (db *DB) UpdateSnapshotTags(ctx context.Context, id string, tags []string) error {
+	tagsJSON, err := json.Marshal(tags)
+	if err != nil {
+		return fmt.Errorf("marshaling tags: %w", err)
+	}
+
+	result, err := db.ExecContext(ctx, `UPDATE snapshots SET tags = ? WHERE id = ?`, string(tagsJSON), id)
+	if err != nil {
+		return fmt.Errorf("updating snapshot tags: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("snapshot %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// UpdateSnapshotColdPath records where a snapshot's archive was moved to
+// in cold storage; empty means it is back at its path
+func (db *DB) UpdateSnapshotColdPath(ctx context.Context, id, path string) error {
+	result, err := db.ExecContext(ctx, `UPDATE snapshots SET cold_path = ? WHERE id = ?`, path, id)
+	if err != nil {
+		return fmt.Errorf("updating snapshot cold path: %w", err)
+	}
+
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return fmt.Errorf("snapshot %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// ReparentSnapshots moves the children of one snapshot to another parent
+func (db *DB) ReparentSnapshots(ctx context.Context, fromID, toID string) error {
+	_, err := db.ExecContext(ctx, `UPDATE snapshots SET parent_id = ? WHERE parent_id = ?`, toID, fromID)
+	if err != nil {
+		return fmt.Errorf("reparenting snapshots: %w", err)
+	}
+	return nil
+}
+
 // DeleteSnapshot deletes a snapshot by ID
 func (db *DB) DeleteSnapshot(ctx context.Context, id string) error {
 	_, err := db.ExecContext(ctx, `DELETE FROM snapshots WHERE id = ?`, id)
@@ -73,3 +171,36 @@ func (db *DB) DeleteSnapshotsByPuck(ctx context.Context, puckID string) error {
 	_, err := db.ExecContext(ctx, `DELETE FROM snapshots WHERE puck_id = ?`, puckID)
 	return err
 }
+
+// scanSnapshot reads the columns listed in snapshotColumns
+func scanSnapshot(row rowScanner) (*Snapshot, error) {
+	var s Snapshot
+	var parentID, tagsJSON, image, mode, commitImage, criuVersion, kernel, podmanVersion, coldPath, criuJSON, volumesPath, imageDigest sql.NullString
+
+	err := row.Scan(&s.ID, &s.PuckID, &s.PuckName, &s.Name, &s.Path, &s.SizeBytes, &s.CreatedAt, &parentID, &tagsJSON, &image, &mode, &commitImage, &criuVersion, &kernel, &podmanVersion, &coldPath, &criuJSON, &volumesPath, &imageDigest)
+	if err != nil {
+		return nil, err
+	}
+
+	s.ParentID = parentID.String
+	s.Image = image.String
+	s.Mode = SnapshotMode(mode.String)
+	if s.Mode == "" {
+		s.Mode = SnapshotModeCheckpoint
+	}
+	s.CommitImage = commitImage.String
+	s.CRIUVersion = criuVersion.String
+	s.Kernel = kernel.String
+	s.PodmanVersion = podmanVersion.String
+	s.ColdPath = coldPath.String
+	s.VolumesPath = volumesPath.String
+	s.ImageDigest = imageDigest.String
+	if tagsJSON.String != "" {
+		json.Unmarshal([]byte(tagsJSON.String), &s.Tags)
+	}
+	if criuJSON.String != "" {
+		json.Unmarshal([]byte(criuJSON.String), &s.CRIU)
+	}
+
+	return &s, nil
+}
@@ -264,3 +264,182 @@ func TestCascadeDelete(t *testing.T) {
 		assert.Empty(t, snapshots)
 	})
 }
+
+func TestSnapshotTreeFields(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	puck := createTestPuck("tree-puck")
+	require.NoError(t, db.CreatePuck(ctx, puck))
+
+	base := createTestSnapshot(puck.ID, puck.Name, "base")
+	require.NoError(t, db.CreateSnapshot(ctx, base))
+	child := createTestSnapshot(puck.ID, puck.Name, "child")
+	child.ParentID = base.ID
+	child.Tags = []string{"stable"}
+	child.Image = "nginx:1.25"
+	require.NoError(t, db.CreateSnapshot(ctx, child))
+	grandchild := createTestSnapshot(puck.ID, puck.Name, "grandchild")
+	grandchild.ParentID = child.ID
+	require.NoError(t, db.CreateSnapshot(ctx, grandchild))
+
+	t.Run("stores parent, tags and image", func(t *testing.T) {
+		retrieved, err := db.GetSnapshot(ctx, puck.ID, "child")
+		require.NoError(t, err)
+		assert.Equal(t, base.ID, retrieved.ParentID)
+		assert.Equal(t, []string{"stable"}, retrieved.Tags)
+		assert.Equal(t, "nginx:1.25", retrieved.Image)
+		assert.Equal(t, SnapshotModeCheckpoint, retrieved.Mode)
+
+		root, err := db.GetSnapshot(ctx, puck.ID, "base")
+		require.NoError(t, err)
+		assert.Empty(t, root.ParentID)
+		assert.Empty(t, root.Tags)
+	})
+
+	t.Run("updates tags", func(t *testing.T) {
+		require.NoError(t, db.UpdateSnapshotTags(ctx, base.ID, []string{"v1", "known-good"}))
+
+		retrieved, err := db.GetSnapshot(ctx, puck.ID, "base")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"v1", "known-good"}, retrieved.Tags)
+
+		assert.Error(t, db.UpdateSnapshotTags(ctx, "missing", nil))
+	})
+
+	t.Run("reparents children", func(t *testing.T) {
+		require.NoError(t, db.ReparentSnapshots(ctx, child.ID, base.ID))
+
+		retrieved, err := db.GetSnapshot(ctx, puck.ID, "grandchild")
+		require.NoError(t, err)
+		assert.Equal(t, base.ID, retrieved.ParentID)
+	})
+}
+
+func TestImageSnapshot(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	puck := createTestPuck("image-puck")
+	require.NoError(t, db.CreatePuck(ctx, puck))
+
+	snapshot := createTestSnapshot(puck.ID, puck.Name, "committed")
+	snapshot.Mode = SnapshotModeImage
+	snapshot.CommitImage = "localhost/puck-snapshots:abc"
+	require.NoError(t, db.CreateSnapshot(ctx, snapshot))
+
+	retrieved, err := db.GetSnapshot(ctx, puck.ID, "committed")
+	require.NoError(t, err)
+	assert.Equal(t, SnapshotModeImage, retrieved.Mode)
+	assert.Equal(t, "localhost/puck-snapshots:abc", retrieved.CommitImage)
+}
+
+func TestSnapshotHost(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	puck := createTestPuck("criu-puck")
+	require.NoError(t, db.CreatePuck(ctx, puck))
+
+	snapshot := createTestSnapshot(puck.ID, puck.Name, "checkpointed")
+	snapshot.CRIUVersion = "3.19"
+	snapshot.Kernel = "6.8.0-45-generic"
+	snapshot.PodmanVersion = "5.3.0"
+	snapshot.CRIU = CRIUOptions{FileLocks: true}
+	snapshot.VolumesPath = "/data/snapshots/checkpointed.volumes.tar.gz"
+	snapshot.ImageDigest = "sha256:4f2c"
+	require.NoError(t, db.CreateSnapshot(ctx, snapshot))
+
+	retrieved, err := db.GetSnapshot(ctx, puck.ID, "checkpointed")
+	require.NoError(t, err)
+	assert.Equal(t, "3.19", retrieved.CRIUVersion)
+	assert.Equal(t, "6.8.0-45-generic", retrieved.Kernel)
+	assert.Equal(t, "5.3.0", retrieved.PodmanVersion)
+	assert.Equal(t, CRIUOptions{FileLocks: true}, retrieved.CRIU)
+	assert.Equal(t, "/data/snapshots/checkpointed.volumes.tar.gz", retrieved.VolumesPath)
+	assert.Equal(t, "sha256:4f2c", retrieved.ImageDigest)
+}
+
+func TestListAllSnapshots(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	a := createTestPuck("all-a")
+	require.NoError(t, db.CreatePuck(ctx, a))
+	b := createTestPuck("all-b")
+	require.NoError(t, db.CreatePuck(ctx, b))
+
+	first := createTestSnapshot(a.ID, a.Name, "first")
+	first.CreatedAt = time.Now().Add(-time.Hour)
+	require.NoError(t, db.CreateSnapshot(ctx, first))
+	require.NoError(t, db.CreateSnapshot(ctx, createTestSnapshot(b.ID, b.Name, "second")))
+
+	snapshots, err := db.ListAllSnapshots(ctx)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+	assert.Equal(t, "first", snapshots[0].Name)
+	assert.Equal(t, "second", snapshots[1].Name)
+}
+
+func TestUpdateSnapshotPuckID(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	a := createTestPuck("move-a")
+	require.NoError(t, db.CreatePuck(ctx, a))
+	b := createTestPuck("move-b")
+	require.NoError(t, db.CreatePuck(ctx, b))
+
+	snapshot := createTestSnapshot(a.ID, a.Name, "moved")
+	require.NoError(t, db.CreateSnapshot(ctx, snapshot))
+
+	t.Run("moves the snapshot", func(t *testing.T) {
+		require.NoError(t, db.UpdateSnapshotPuckID(ctx, snapshot.ID, b.ID))
+
+		retrieved, err := db.GetSnapshot(ctx, b.ID, "moved")
+		require.NoError(t, err)
+		assert.Equal(t, snapshot.ID, retrieved.ID)
+	})
+
+	t.Run("fails for missing snapshot", func(t *testing.T) {
+		assert.Error(t, db.UpdateSnapshotPuckID(ctx, "nope", b.ID))
+	})
+}
+
+func TestUpdateSnapshotColdPath(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	puck := createTestPuck("cold-puck")
+	require.NoError(t, db.CreatePuck(ctx, puck))
+	snapshot := createTestSnapshot(puck.ID, puck.Name, "old")
+	require.NoError(t, db.CreateSnapshot(ctx, snapshot))
+
+	t.Run("records where the archive went", func(t *testing.T) {
+		require.NoError(t, db.UpdateSnapshotColdPath(ctx, snapshot.ID, "/mnt/cold/old.tar.gz"))
+
+		retrieved, err := db.GetSnapshot(ctx, puck.ID, "old")
+		require.NoError(t, err)
+		assert.Equal(t, "/mnt/cold/old.tar.gz", retrieved.ColdPath)
+		assert.Equal(t, "/mnt/cold/old.tar.gz", retrieved.ArchivePath())
+	})
+
+	t.Run("clears it when fetched back", func(t *testing.T) {
+		require.NoError(t, db.UpdateSnapshotColdPath(ctx, snapshot.ID, ""))
+
+		retrieved, err := db.GetSnapshot(ctx, puck.ID, "old")
+		require.NoError(t, err)
+		assert.Empty(t, retrieved.ColdPath)
+		assert.Equal(t, snapshot.Path, retrieved.ArchivePath())
+	})
+
+	t.Run("fails for missing snapshot", func(t *testing.T) {
+		assert.Error(t, db.UpdateSnapshotColdPath(ctx, "nope", "/mnt/cold/x"))
+	})
+}
@@ -0,0 +1,97 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+const stackSnapshotColumns = `id, puck_name, name, members, created_at`
+
+// CreateStackSnapshot records a stack snapshot
+func (db *DB) CreateStackSnapshot(ctx context.Context, s *StackSnapshot) error {
+	membersJSON, err := json.Marshal(s.Members)
+	if err != nil {
+		return fmt.Errorf("marshaling members: %w", err)
+	}
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO stack_snapshots (`+stackSnapshotColumns+`)
+		VALUES (?, ?, ?, ?, ?)
+	`, s.ID, s.PuckName, s.Name, string(membersJSON), s.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("inserting stack snapshot: %w", err)
+	}
+
+	return nil
+}
+
+// GetStackSnapshot retrieves a stack snapshot by puck name and name
+func (db *DB) GetStackSnapshot(ctx context.Context, puckName, name string) (*StackSnapshot, error) {
+	row := db.QueryRowContext(ctx, `
+		SELECT `+stackSnapshotColumns+`
+		FROM stack_snapshots WHERE puck_name = ? AND name = ?
+	`, puckName, name)
+
+	s, err := scanStackSnapshot(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("stack snapshot '%s' %w for puck", name, ErrNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("scanning stack snapshot: %w", err)
+	}
+
+	return s, nil
+}
+
+// ListStackSnapshots returns the stack snapshots taken from a puck
+func (db *DB) ListStackSnapshots(ctx context.Context, puckName string) ([]*StackSnapshot, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+stackSnapshotColumns+`
+		FROM stack_snapshots WHERE puck_name = ? ORDER BY created_at DESC
+	`, puckName)
+	if err != nil {
+		return nil, fmt.Errorf("querying stack snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	var stacks []*StackSnapshot
+	for rows.Next() {
+		s, err := scanStackSnapshot(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scanning stack snapshot row: %w", err)
+		}
+		stacks = append(stacks, s)
+	}
+
+	return stacks, rows.Err()
+}
+
+// DeleteStackSnapshot deletes a stack snapshot, leaving its members'
+// snapshots in place
+func (db *DB) DeleteStackSnapshot(ctx context.Context, id string) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM stack_snapshots WHERE id = ?`, id)
+	return err
+}
+
+// DeleteStackSnapshotsByPuck deletes all stack snapshots taken from a puck
+func (db *DB) DeleteStackSnapshotsByPuck(ctx context.Context, puckName string) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM stack_snapshots WHERE puck_name = ?`, puckName)
+	return err
+}
+
+func scanStackSnapshot(row rowScanner) (*StackSnapshot, error) {
+	var s StackSnapshot
+	var membersJSON sql.NullString
+	if err := row.Scan(&s.ID, &s.PuckName, &s.Name, &membersJSON, &s.CreatedAt); err != nil {
+		return nil, err
+	}
+	if membersJSON.Valid && membersJSON.String != "" {
+		if err := json.Unmarshal([]byte(membersJSON.String), &s.Members); err != nil {
+			return nil, fmt.Errorf("unmarshaling members: %w", err)
+		}
+	}
+	return &s, nil
+}
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStackSnapshots(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	stack := &StackSnapshot{
+		ID:       "stack-1",
+		PuckName: "api",
+		Name:     "before-migration",
+		Members: []StackMember{
+			{Puck: "db", SnapshotID: "snap-db"},
+			{Puck: "api", SnapshotID: "snap-api"},
+		},
+		CreatedAt: time.Now(),
+	}
+
+	t.Run("creates and retrieves a stack snapshot", func(t *testing.T) {
+		require.NoError(t, db.CreateStackSnapshot(ctx, stack))
+
+		got, err := db.GetStackSnapshot(ctx, "api", "before-migration")
+		require.NoError(t, err)
+		assert.Equal(t, stack.ID, got.ID)
+		assert.Equal(t, stack.Members, got.Members)
+	})
+
+	t.Run("rejects a duplicate name", func(t *testing.T) {
+		dup := *stack
+		dup.ID = "stack-2"
+		assert.Error(t, db.CreateStackSnapshot(ctx, &dup))
+	})
+
+	t.Run("lists by puck", func(t *testing.T) {
+		stacks, err := db.ListStackSnapshots(ctx, "api")
+		require.NoError(t, err)
+		require.Len(t, stacks, 1)
+
+		stacks, err = db.ListStackSnapshots(ctx, "db")
+		require.NoError(t, err)
+		assert.Empty(t, stacks)
+	})
+
+	t.Run("returns error for non-existent stack snapshot", func(t *testing.T) {
+		_, err := db.GetStackSnapshot(ctx, "api", "missing")
+		assert.ErrorContains(t, err, "not found")
+	})
+
+	t.Run("deletes by puck", func(t *testing.T) {
+		require.NoError(t, db.DeleteStackSnapshotsByPuck(ctx, "api"))
+		_, err := db.GetStackSnapshot(ctx, "api", "before-migration")
+		assert.Error(t, err)
+	})
+}
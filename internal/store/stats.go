@@ -0,0 +1,96 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RecordStat stores a sample of a puck's resource use, stamping it with
+// the current time if CreatedAt is unset
+func (db *DB) RecordStat(ctx context.Context, s *Stat) error {
+	if s.CreatedAt.IsZero() {
+		s.CreatedAt = time.Now()
+	}
+
+	id, err := db.insert(ctx, `
+		INSERT INTO stats (puck_name, up, cpu, memory, disk, hits, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`, s.PuckName, s.Up, s.CPU, s.Memory, s.Disk, int64(s.Hits), s.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("inserting stat: %w", err)
+	}
+
+	s.ID = id
+	return nil
+}
+
+// ListStats returns a puck's samples taken at or after since, oldest
+// first. As with events, times are compared in Go.
+func (db *DB) ListStats(ctx context.Context, puckName string, since time.Time) ([]*Stat, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, puck_name, up, cpu, memory, disk, hits, created_at
+		FROM stats WHERE puck_name = ? ORDER BY id ASC
+	`, puckName)
+	if err != nil {
+		return nil, fmt.Errorf("querying stats: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []*Stat
+	for rows.Next() {
+		var s Stat
+		var hits int64
+		if err := rows.Scan(&s.ID, &s.PuckName, &s.Up, &s.CPU, &s.Memory, &s.Disk, &hits, &s.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning stat row: %w", err)
+		}
+		if s.CreatedAt.Before(since) {
+			continue
+		}
+		s.Hits = uint64(hits)
+		stats = append(stats, &s)
+	}
+
+	return stats, rows.Err()
+}
+
+// PruneStats deletes samples taken before t and returns how many went.
+// Samples are stored in the order taken, so everything up to the last one
+// before t goes.
+func (db *DB) PruneStats(ctx context.Context, t time.Time) (int64, error) {
+	rows, err := db.QueryContext(ctx, `SELECT id, created_at FROM stats ORDER BY id ASC`)
+	if err != nil {
+		return 0, fmt.Errorf("querying stats: %w", err)
+	}
+	var last int64
+	for rows.Next() {
+		var id int64
+		var at time.Time
+		if err := rows.Scan(&id, &at); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scanning stat row: %w", err)
+		}
+		if !at.Before(t) {
+			break
+		}
+		last = id
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if last == 0 {
+		return 0, nil
+	}
+
+	result, err := db.ExecContext(ctx, `DELETE FROM stats WHERE id <= ?`, last)
+	if err != nil {
+		return 0, fmt.Errorf("deleting stats: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// DeleteStatsByPuck deletes a puck's samples
+func (db *DB) DeleteStatsByPuck(ctx context.Context, puckName string) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM stats WHERE puck_name = ?`, puckName)
+	return err
+}
@@ -0,0 +1,72 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	start := time.Now().Add(-3 * time.Hour)
+	record := func(name string, up bool, hits uint64, at time.Time) {
+		require.NoError(t, db.RecordStat(ctx, &Stat{PuckName: name, Up: up, CPU: 12.5, Memory: 1 << 20, Disk: 4096, Hits: hits, CreatedAt: at}))
+	}
+	record("web", true, 10, start)
+	record("api", false, 0, start)
+	record("web", true, 25, start.Add(time.Hour))
+	record("web", false, 25, start.Add(2*time.Hour))
+
+	t.Run("lists a puck's samples oldest first", func(t *testing.T) {
+		stats, err := db.ListStats(ctx, "web", time.Time{})
+		require.NoError(t, err)
+		require.Len(t, stats, 3)
+		assert.True(t, stats[0].Up)
+		assert.Equal(t, 12.5, stats[0].CPU)
+		assert.Equal(t, int64(1<<20), stats[0].Memory)
+		assert.Equal(t, int64(4096), stats[0].Disk)
+		assert.Equal(t, uint64(10), stats[0].Hits)
+		assert.False(t, stats[2].Up)
+		assert.WithinDuration(t, start.Add(2*time.Hour), stats[2].CreatedAt, time.Second)
+	})
+
+	t.Run("filters by since", func(t *testing.T) {
+		stats, err := db.ListStats(ctx, "web", start.Add(30*time.Minute))
+		require.NoError(t, err)
+		assert.Len(t, stats, 2)
+	})
+
+	t.Run("prunes old samples", func(t *testing.T) {
+		n, err := db.PruneStats(ctx, start.Add(90*time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), n)
+
+		stats, err := db.ListStats(ctx, "web", time.Time{})
+		require.NoError(t, err)
+		require.Len(t, stats, 1)
+		assert.False(t, stats[0].Up)
+
+		n, err = db.PruneStats(ctx, start)
+		require.NoError(t, err)
+		assert.Zero(t, n)
+	})
+
+	t.Run("deletes a puck's samples", func(t *testing.T) {
+		record("api", true, 1, time.Now())
+		require.NoError(t, db.DeleteStatsByPuck(ctx, "web"))
+
+		stats, err := db.ListStats(ctx, "web", time.Time{})
+		require.NoError(t, err)
+		assert.Empty(t, stats)
+
+		stats, err = db.ListStats(ctx, "api", time.Time{})
+		require.NoError(t, err)
+		assert.Len(t, stats, 1)
+	})
+}
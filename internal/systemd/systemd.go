@@ -6,19 +6,41 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
 const serviceName = "puckd.service"
 const binaryName = "puckd"
 
+// podmanSocketUnit is Podman's user socket, which starts the Podman
+// service on first use
+const podmanSocketUnit = "podman.socket"
+
+// How the service depends on podmanSocketUnit
+const (
+	// PodmanSocketWants starts the socket with puckd and orders puckd
+	// after it, but starts puckd even if the socket fails; the daemon
+	// waits for Podman by itself
+	PodmanSocketWants = "wants"
+	// PodmanSocketRequires also stops puckd when the socket stops
+	PodmanSocketRequires = "requires"
+	// PodmanSocketNone leaves the socket out, e.g. for a system-wide or
+	// remote Podman
+	PodmanSocketNone = "none"
+)
+
+// InstallOptions configure the installed service
+type InstallOptions struct {
+	PodmanSocket string // PodmanSocketWants, PodmanSocketRequires or PodmanSocketNone
+	DataDir      string // defaults to DataDir()
+}
+
 // serviceTemplate is the systemd user service file content
 const serviceTemplate = `[Unit]
 Description=Puck Daemon - Container Management Service
 Documentation=https://github.com/sandwich-labs/puck
-After=network-online.target podman.socket
-Wants=network-online.target
-Requires=podman.socket
+%s
 
 [Service]
 Type=simple
@@ -36,6 +58,21 @@ SyslogIdentifier=puckd
 WantedBy=default.target
 `
 
+// ServiceFile renders the service file for a binary and data directory
+func ServiceFile(binaryPath, dataDir string, opts InstallOptions) (string, error) {
+	deps := []string{"After=network-online.target", "Wants=network-online.target"}
+	switch opts.PodmanSocket {
+	case PodmanSocketWants, "":
+		deps = []string{"After=network-online.target " + podmanSocketUnit, "Wants=network-online.target " + podmanSocketUnit}
+	case PodmanSocketRequires:
+		deps = []string{"After=network-online.target " + podmanSocketUnit, "Wants=network-online.target", "Requires=" + podmanSocketUnit}
+	case PodmanSocketNone:
+	default:
+		return "", fmt.Errorf("podman socket dependency must be %s, %s or %s, got %q", PodmanSocketWants, PodmanSocketRequires, PodmanSocketNone, opts.PodmanSocket)
+	}
+	return fmt.Sprintf(serviceTemplate, strings.Join(deps, "\n"), binaryPath, dataDir), nil
+}
+
 // UserServiceDir returns the systemd user service directory
 func UserServiceDir() (string, error) {
 	home, err := os.UserHomeDir()
@@ -95,7 +132,7 @@ func IsEnabled() bool {
 }
 
 // Install installs the puckd binary and systemd service
-func Install(sourceBinaryPath string) error {
+func Install(sourceBinaryPath string, opts InstallOptions) error {
 	// Get paths
 	destBinaryPath, err := BinaryInstallPath()
 	if err != nil {
@@ -112,7 +149,15 @@ func Install(sourceBinaryPath string) error {
 		return err
 	}
 
-	dataDir, err := DataDir()
+	dataDir := opts.DataDir
+	if dataDir == "" {
+		if dataDir, err = DataDir(); err != nil {
+			return err
+		}
+	}
+
+	// Render the service file before changing anything
+	serviceContent, err := ServiceFile(destBinaryPath, dataDir, opts)
 	if err != nil {
 		return err
 	}
@@ -140,8 +185,7 @@ func Install(sourceBinaryPath string) error {
 		return fmt.Errorf("setting binary permissions: %w", err)
 	}
 
-	// Generate and write service file
-	serviceContent := fmt.Sprintf(serviceTemplate, destBinaryPath, dataDir)
+	// Write service file
 	if err := os.WriteFile(servicePath, []byte(serviceContent), 0644); err != nil {
 		return fmt.Errorf("writing service file: %w", err)
 	}
@@ -206,6 +250,37 @@ func Uninstall(removeBinary bool) error {
 	return nil
 }
 
+// SetDataDir points the installed service at another data directory. It
+// takes effect once systemd is reloaded and the service restarted.
+func SetDataDir(dataDir string) error {
+	path, err := ServiceFilePath()
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading service file: %w", err)
+	}
+	unit, ok := replaceDataDir(string(data), dataDir)
+	if !ok {
+		return fmt.Errorf("%s does not set PUCK_DATA_DIR", path)
+	}
+	return os.WriteFile(path, []byte(unit), 0644)
+}
+
+// replaceDataDir swaps the data directory in a service file's environment
+func replaceDataDir(unit, dataDir string) (string, bool) {
+	lines := strings.Split(unit, "\n")
+	found := false
+	for i, line := range lines {
+		if strings.HasPrefix(line, `Environment="PUCK_DATA_DIR=`) {
+			lines[i] = fmt.Sprintf(`Environment="PUCK_DATA_DIR=%s"`, dataDir)
+			found = true
+		}
+	}
+	return strings.Join(lines, "\n"), found
+}
+
 // DaemonReload runs systemctl --user daemon-reload
 func DaemonReload() error {
 	cmd := exec.Command("systemctl", "--user", "daemon-reload")
@@ -256,6 +331,42 @@ func Stop() error {
 	return nil
 }
 
+// Restart restarts the systemd service, as after its binary is replaced
+func Restart() error {
+	cmd := exec.Command("systemctl", "--user", "restart", serviceName)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// PodmanSocketEnabled checks if Podman's user socket is enabled
+func PodmanSocketEnabled() bool {
+	cmd := exec.Command("systemctl", "--user", "is-enabled", "--quiet", podmanSocketUnit)
+	return cmd.Run() == nil
+}
+
+// EnablePodmanSocket enables and starts Podman's user socket
+func EnablePodmanSocket() error {
+	cmd := exec.Command("systemctl", "--user", "enable", "--now", podmanSocketUnit)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// JournalCommand returns a journalctl command showing the service's last
+// lines of logs, following new ones if asked to
+func JournalCommand(lines int, follow bool) *exec.Cmd {
+	args := []string{"--user", "--unit", serviceName, "--no-pager", "--lines", strconv.Itoa(lines)}
+	if follow {
+		args = append(args, "--follow")
+	}
+	return exec.Command("journalctl", args...)
+}
+
 // Status returns the status output from systemctl
 func Status() (string, error) {
 	cmd := exec.Command("systemctl", "--user", "status", serviceName)
@@ -0,0 +1,55 @@
+package systemd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceFile(t *testing.T) {
+	t.Run("wants the podman socket by default", func(t *testing.T) {
+		unit, err := ServiceFile("/home/me/.local/bin/puckd", "/home/me/.local/share/puck", InstallOptions{})
+		require.NoError(t, err)
+		assert.Contains(t, unit, "After=network-online.target podman.socket\nWants=network-online.target podman.socket\n")
+		assert.NotContains(t, unit, "Requires=")
+		assert.Contains(t, unit, "ExecStart=/home/me/.local/bin/puckd\n")
+		assert.Contains(t, unit, `Environment="PUCK_DATA_DIR=/home/me/.local/share/puck"`)
+	})
+
+	t.Run("requires the podman socket", func(t *testing.T) {
+		unit, err := ServiceFile("puckd", "data", InstallOptions{PodmanSocket: PodmanSocketRequires})
+		require.NoError(t, err)
+		assert.Contains(t, unit, "After=network-online.target podman.socket\nWants=network-online.target\nRequires=podman.socket\n")
+	})
+
+	t.Run("leaves the podman socket out", func(t *testing.T) {
+		unit, err := ServiceFile("puckd", "data", InstallOptions{PodmanSocket: PodmanSocketNone})
+		require.NoError(t, err)
+		assert.NotContains(t, unit, "podman.socket")
+	})
+
+	t.Run("rejects unknown dependencies", func(t *testing.T) {
+		_, err := ServiceFile("puckd", "data", InstallOptions{PodmanSocket: "binds"})
+		assert.ErrorContains(t, err, "wants, requires or none")
+	})
+}
+
+func TestJournalCommand(t *testing.T) {
+	cmd := JournalCommand(50, true)
+	assert.Equal(t, []string{"journalctl", "--user", "--unit", "puckd.service", "--no-pager", "--lines", "50", "--follow"}, cmd.Args)
+}
+
+func TestReplaceDataDir(t *testing.T) {
+	unit, err := ServiceFile("puckd", "/home/me/.local/share/puck", InstallOptions{})
+	require.NoError(t, err)
+
+	got, ok := replaceDataDir(unit, "/mnt/big/puck")
+	assert.True(t, ok)
+	assert.Contains(t, got, `Environment="PUCK_DATA_DIR=/mnt/big/puck"`+"\n")
+	assert.NotContains(t, got, "/home/me/.local/share/puck")
+	assert.Contains(t, got, "ExecStart=puckd\n")
+
+	_, ok = replaceDataDir("[Service]\nExecStart=puckd\n", "/mnt/big/puck")
+	assert.False(t, ok)
+}
@@ -0,0 +1,99 @@
+package templates
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// namePattern matches valid registered template names; they can't contain
+// a slash or colon, so they never read as a source
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// Registry names template sources so they can be used by name
+type Registry struct {
+	Templates map[string]string `yaml:"templates,omitempty"` // name -> source
+}
+
+// RegistryPath returns the file registered templates are stored in
+func RegistryPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "puck", "templates.yaml")
+}
+
+// LoadRegistry reads registered templates from path; a missing file has
+// none
+func LoadRegistry(path string) (*Registry, error) {
+	r := &Registry{Templates: map[string]string{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading templates: %w", err)
+	}
+
+	if err := yaml.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if r.Templates == nil {
+		r.Templates = map[string]string{}
+	}
+	return r, nil
+}
+
+// Save writes registered templates to path
+func (r *Registry) Save(path string) error {
+	data, err := yaml.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("encoding templates: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating config directory: %w", err)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Add registers a source under name, replacing any existing one
+func (r *Registry) Add(name string, src Source) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid template name %q", name)
+	}
+	r.Templates[name] = src.String()
+	return nil
+}
+
+// Names returns the registered template names in order
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.Templates))
+	for name := range r.Templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Resolve returns the source a --template argument refers to: a
+// registered name, or else a source itself
+func (r *Registry) Resolve(ref string) (Source, error) {
+	if s, ok := r.Templates[ref]; ok {
+		return ParseSource(s)
+	}
+	src, err := ParseSource(ref)
+	if err != nil {
+		return Source{}, err
+	}
+	// A bare word that isn't a directory here was meant as a name
+	if src.Kind == SourceLocal && namePattern.MatchString(ref) {
+		if _, err := os.Stat(src.Path); err != nil {
+			return Source{}, fmt.Errorf("template '%s' not found (see: puck template list)", ref)
+		}
+	}
+	return src, nil
+}
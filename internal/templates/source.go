@@ -0,0 +1,177 @@
+package templates
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Source kinds
+const (
+	SourceGit   = "git"
+	SourceLocal = "local"
+)
+
+// ghRepoPattern matches the user/repo part of a gh: source
+var ghRepoPattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]+/[a-zA-Z0-9_.-]+$`)
+
+// Source is where a template comes from: a git repository, optionally at
+// a branch or tag, or a directory on this machine
+type Source struct {
+	Kind string
+	URL  string // git only
+	Ref  string // git only; empty uses the default branch
+	Path string // local only
+}
+
+// ParseSource reads a template source:
+//
+//	gh:user/repo[#ref]        a GitHub repository
+//	https://host/repo.git     any git URL, also ssh://, git:// and git@host:repo
+//	./dir, /abs/dir, ~/dir    a local directory
+//
+// Local paths are made absolute so the source can be stored.
+func ParseSource(s string) (Source, error) {
+	switch {
+	case s == "":
+		return Source{}, fmt.Errorf("template source is empty")
+	case strings.HasPrefix(s, "gh:"):
+		repo, ref, _ := strings.Cut(strings.TrimPrefix(s, "gh:"), "#")
+		repo = strings.TrimSuffix(repo, ".git")
+		if !ghRepoPattern.MatchString(repo) {
+			return Source{}, fmt.Errorf("invalid template source %q; use gh:user/repo", s)
+		}
+		return Source{Kind: SourceGit, URL: "https://github.com/" + repo + ".git", Ref: ref}, nil
+	case isGitURL(s):
+		url, ref, _ := strings.Cut(s, "#")
+		return Source{Kind: SourceGit, URL: url, Ref: ref}, nil
+	}
+
+	path := s
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return Source{}, err
+		}
+		path = filepath.Join(home, rest)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return Source{}, err
+	}
+	return Source{Kind: SourceLocal, Path: abs}, nil
+}
+
+func isGitURL(s string) bool {
+	for _, prefix := range []string{"https://", "http://", "ssh://", "git://", "git@", "file://"} {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the source as ParseSource accepts it
+func (s Source) String() string {
+	if s.Kind == SourceLocal {
+		return s.Path
+	}
+	if repo, ok := strings.CutPrefix(s.URL, "https://github.com/"); ok {
+		s.URL = "gh:" + strings.TrimSuffix(repo, ".git")
+	}
+	if s.Ref != "" {
+		return s.URL + "#" + s.Ref
+	}
+	return s.URL
+}
+
+// Cache holds clones of git templates so creating from one doesn't fetch
+// it every time
+type Cache struct {
+	Dir string
+}
+
+// DefaultCacheDir returns where templates are cached by default
+func DefaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, ".cache")
+	}
+	return filepath.Join(dir, "puck", "templates")
+}
+
+// path returns the directory a git source is cloned to
+func (c *Cache) path(src Source) string {
+	sum := sha256.Sum256([]byte(src.URL + "#" + src.Ref))
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:8]))
+}
+
+// Cached reports whether a git source has been fetched. Local sources
+// are always at hand.
+func (c *Cache) Cached(src Source) bool {
+	if src.Kind == SourceLocal {
+		return true
+	}
+	_, err := os.Stat(c.path(src))
+	return err == nil
+}
+
+// Fetch returns a directory holding the source's files, cloning a git
+// source into the cache unless it is there already. With refresh a
+// cached clone is replaced with a fresh one.
+func (c *Cache) Fetch(ctx context.Context, src Source, refresh bool) (string, error) {
+	if src.Kind == SourceLocal {
+		info, err := os.Stat(src.Path)
+		if err != nil {
+			return "", fmt.Errorf("template directory: %w", err)
+		}
+		if !info.IsDir() {
+			return "", fmt.Errorf("template source %s is not a directory", src.Path)
+		}
+		return src.Path, nil
+	}
+
+	dest := c.path(src)
+	if !refresh && c.Cached(src) {
+		return dest, nil
+	}
+
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return "", fmt.Errorf("creating template cache: %w", err)
+	}
+	// Clone next to the destination and swap it in, so a failed fetch
+	// leaves the previous clone usable
+	tmp, err := os.MkdirTemp(c.Dir, ".fetch-*")
+	if err != nil {
+		return "", fmt.Errorf("creating template cache: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	args := []string{"clone", "--depth", "1", "--quiet"}
+	if src.Ref != "" {
+		args = append(args, "--branch", src.Ref)
+	}
+	args = append(args, src.URL, filepath.Join(tmp, "repo"))
+	cmd := exec.CommandContext(ctx, "git", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("fetching %s: %w: %s", src, err, strings.TrimSpace(stderr.String()))
+	}
+
+	if err := os.RemoveAll(dest); err != nil {
+		return "", fmt.Errorf("replacing cached template: %w", err)
+	}
+	if err := os.Rename(filepath.Join(tmp, "repo"), dest); err != nil {
+		return "", fmt.Errorf("caching template: %w", err)
+	}
+	return dest, nil
+}
@@ -0,0 +1,199 @@
+// Package templates fetches puck templates, a puck-template.yaml with the
+// settings for a new puck and scripts that provision it, from git
+// repositories or local directories
+package templates
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/sandwich-labs/puck/internal/puck"
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the template file at the root of a template
+const FileName = "puck-template.yaml"
+
+// NameVariable is set to the new puck's name without being declared
+const NameVariable = "PUCK_NAME"
+
+var (
+	variableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	// placeholderPattern matches {{NAME}}, with optional spaces inside
+	placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+)
+
+// Template is a parsed puck-template.yaml. String values and provisioning
+// scripts may use {{NAME}} placeholders for the declared variables.
+type Template struct {
+	Description string   `yaml:"description,omitempty"`
+	Image       string   `yaml:"image,omitempty"`
+	Init        string   `yaml:"init,omitempty"`
+	Ports       []string `yaml:"ports,omitempty"`
+	Entrypoint  []string `yaml:"entrypoint,omitempty"`
+	Command     []string `yaml:"command,omitempty"`
+	Requires    []string `yaml:"requires,omitempty"`
+	// Git repository cloned into the new puck before it is provisioned
+	Repo *Repo `yaml:"repo,omitempty"`
+	// Variables asked for at create time
+	Variables []Variable `yaml:"variables,omitempty"`
+	// Scripts, relative to the template, run in order inside the new puck
+	Provision []string `yaml:"provision,omitempty"`
+
+	dir string
+}
+
+// Repo is a git repository a template clones into the new puck
+type Repo struct {
+	URL    string `yaml:"url"`
+	Branch string `yaml:"branch,omitempty"`
+	Dir    string `yaml:"dir,omitempty"` // defaults to puck.DefaultRepoDir
+}
+
+// Variable is a value substituted into a template at create time
+type Variable struct {
+	Name    string `yaml:"name"`
+	Prompt  string `yaml:"prompt,omitempty"` // defaults to the name
+	Default string `yaml:"default,omitempty"`
+}
+
+// Load reads the template in dir
+func Load(dir string) (*Template, error) {
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no %s in %s", FileName, dir)
+		}
+		return nil, fmt.Errorf("reading template: %w", err)
+	}
+
+	var t Template
+	if err := yaml.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", FileName, err)
+	}
+	for _, v := range t.Variables {
+		if !variableNamePattern.MatchString(v.Name) {
+			return nil, fmt.Errorf("invalid template variable name %q", v.Name)
+		}
+		if v.Name == NameVariable {
+			return nil, fmt.Errorf("template variable %s is set by puck", NameVariable)
+		}
+	}
+	for _, script := range t.Provision {
+		if _, err := t.scriptPath(script); err != nil {
+			return nil, err
+		}
+	}
+	t.dir = dir
+	return &t, nil
+}
+
+// scriptPath returns where a provisioning script is, refusing ones outside
+// the template
+func (t *Template) scriptPath(script string) (string, error) {
+	clean := filepath.Clean(script)
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("provisioning script %q must be inside the template", script)
+	}
+	return filepath.Join(t.dir, clean), nil
+}
+
+// resolveScript returns where a provisioning script is once symlinks are
+// followed, refusing links out of the template, which would copy a file
+// of the host into the puck
+func (t *Template) resolveScript(script string) (string, error) {
+	path, err := t.scriptPath(script)
+	if err != nil {
+		return "", err
+	}
+	root, err := filepath.EvalSymlinks(t.dir)
+	if err != nil {
+		return "", fmt.Errorf("reading template: %w", err)
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("reading provisioning script: %w", err)
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("provisioning script %q must be inside the template", script)
+	}
+	return resolved, nil
+}
+
+// Render substitutes values into the template's settings and reads its
+// provisioning scripts, substituting into them too. Every declared
+// variable must have a value.
+func (t *Template) Render(values map[string]string) (*Template, []puck.ProvisionScript, error) {
+	for _, v := range t.Variables {
+		if _, ok := values[v.Name]; !ok {
+			return nil, nil, fmt.Errorf("template variable %s has no value", v.Name)
+		}
+	}
+	for name := range values {
+		if name != NameVariable && !t.declares(name) {
+			return nil, nil, fmt.Errorf("template has no variable %s", name)
+		}
+	}
+
+	sub := func(s string) string { return substitute(s, values) }
+	subAll := func(list []string) []string {
+		if list == nil {
+			return nil
+		}
+		out := make([]string, len(list))
+		for i, s := range list {
+			out[i] = sub(s)
+		}
+		return out
+	}
+	rendered := *t
+	rendered.Description = sub(t.Description)
+	rendered.Image = sub(t.Image)
+	rendered.Init = sub(t.Init)
+	rendered.Ports = subAll(t.Ports)
+	rendered.Entrypoint = subAll(t.Entrypoint)
+	rendered.Command = subAll(t.Command)
+	rendered.Requires = subAll(t.Requires)
+	if t.Repo != nil {
+		rendered.Repo = &Repo{URL: sub(t.Repo.URL), Branch: sub(t.Repo.Branch), Dir: sub(t.Repo.Dir)}
+	}
+
+	scripts := make([]puck.ProvisionScript, 0, len(t.Provision))
+	for _, script := range t.Provision {
+		path, err := t.resolveScript(script)
+		if err != nil {
+			return nil, nil, err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading provisioning script: %w", err)
+		}
+		scripts = append(scripts, puck.ProvisionScript{Name: script, Script: substitute(string(data), values)})
+	}
+	return &rendered, scripts, nil
+}
+
+func (t *Template) declares(name string) bool {
+	for _, v := range t.Variables {
+		if v.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// substitute replaces placeholders for the given values, leaving any
+// others, like those of another templating tool, as they are
+func substitute(s string, values map[string]string) string {
+	return placeholderPattern.ReplaceAllStringFunc(s, func(m string) string {
+		name := placeholderPattern.FindStringSubmatch(m)[1]
+		if v, ok := values[name]; ok {
+			return v
+		}
+		return m
+	})
+}
@@ -0,0 +1,194 @@
+package templates
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/sandwich-labs/puck/internal/puck"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSource(t *testing.T) {
+	t.Run("github shorthand", func(t *testing.T) {
+		src, err := ParseSource("gh:me/puck-node#v2")
+		require.NoError(t, err)
+		assert.Equal(t, Source{Kind: SourceGit, URL: "https://github.com/me/puck-node.git", Ref: "v2"}, src)
+		assert.Equal(t, "gh:me/puck-node#v2", src.String())
+
+		_, err = ParseSource("gh:me")
+		assert.Error(t, err)
+	})
+
+	t.Run("git URLs", func(t *testing.T) {
+		for _, s := range []string{"https://git.example.com/t.git", "ssh://git@example.com/t.git", "git@example.com:me/t.git"} {
+			src, err := ParseSource(s)
+			require.NoError(t, err, s)
+			assert.Equal(t, SourceGit, src.Kind, s)
+			assert.Equal(t, s, src.URL, s)
+		}
+	})
+
+	t.Run("local paths are made absolute", func(t *testing.T) {
+		src, err := ParseSource("./my-template")
+		require.NoError(t, err)
+		assert.Equal(t, SourceLocal, src.Kind)
+		assert.True(t, filepath.IsAbs(src.Path))
+	})
+}
+
+func TestRegistry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "templates.yaml")
+
+	reg, err := LoadRegistry(path)
+	require.NoError(t, err)
+	assert.Empty(t, reg.Names())
+
+	src, err := ParseSource("gh:me/puck-node")
+	require.NoError(t, err)
+	require.NoError(t, reg.Add("node", src))
+	assert.Error(t, reg.Add("gh:node", src))
+	require.NoError(t, reg.Save(path))
+
+	reg, err = LoadRegistry(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"node"}, reg.Names())
+
+	resolved, err := reg.Resolve("node")
+	require.NoError(t, err)
+	assert.Equal(t, src, resolved)
+
+	_, err = reg.Resolve("python")
+	assert.ErrorContains(t, err, "not found")
+}
+
+// writeTemplate creates a template directory with the given files
+func writeTemplate(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	return dir
+}
+
+func TestRender(t *testing.T) {
+	dir := writeTemplate(t, map[string]string{
+		FileName: `
+description: Node box
+image: node:{{NODE_VERSION}}
+ports: ["3000:3000"]
+command: ["sleep", "infinity"]
+repo:
+  url: https://github.com/org/{{PUCK_NAME}}
+  branch: node-{{NODE_VERSION}}
+variables:
+  - name: NODE_VERSION
+    default: "22"
+provision:
+  - setup.sh
+`,
+		"setup.sh": "echo {{ NODE_VERSION }} > /etc/motd\necho {{PUCK_NAME}} {{other}}\n",
+	})
+
+	tmpl, err := Load(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "Node box", tmpl.Description)
+
+	rendered, scripts, err := tmpl.Render(map[string]string{"NODE_VERSION": "20", NameVariable: "api"})
+	require.NoError(t, err)
+	assert.Equal(t, "node:20", rendered.Image)
+	assert.Equal(t, []string{"sleep", "infinity"}, rendered.Command)
+	assert.Nil(t, rendered.Entrypoint)
+	assert.Equal(t, &Repo{URL: "https://github.com/org/api", Branch: "node-20"}, rendered.Repo)
+	assert.Equal(t, []puck.ProvisionScript{{Name: "setup.sh", Script: "echo 20 > /etc/motd\necho api {{other}}\n"}}, scripts)
+
+	_, _, err = tmpl.Render(map[string]string{})
+	assert.ErrorContains(t, err, "NODE_VERSION has no value")
+	_, _, err = tmpl.Render(map[string]string{"NODE_VERSION": "20", "TYPO": "x"})
+	assert.ErrorContains(t, err, "no variable TYPO")
+}
+
+func TestRenderRefusesSymlinkedScripts(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "id_rsa")
+	require.NoError(t, os.WriteFile(secret, []byte("private key"), 0600))
+	dir := writeTemplate(t, map[string]string{
+		FileName:    "provision: [setup.sh, lib/common.sh]\n",
+		"real.sh":   "echo common\n",
+		"lib/.keep": "",
+	})
+	require.NoError(t, os.Symlink(secret, filepath.Join(dir, "setup.sh")))
+	// Links within the template are fine
+	require.NoError(t, os.Symlink(filepath.Join(dir, "real.sh"), filepath.Join(dir, "lib", "common.sh")))
+
+	tmpl, err := Load(dir)
+	require.NoError(t, err)
+	_, scripts, err := tmpl.Render(map[string]string{})
+	assert.ErrorContains(t, err, "inside the template")
+	assert.Nil(t, scripts)
+
+	tmpl.Provision = []string{"lib/common.sh"}
+	_, scripts, err = tmpl.Render(map[string]string{})
+	require.NoError(t, err)
+	assert.Equal(t, []puck.ProvisionScript{{Name: "lib/common.sh", Script: "echo common\n"}}, scripts)
+}
+
+func TestLoadRejectsBadTemplates(t *testing.T) {
+	_, err := Load(t.TempDir())
+	assert.ErrorContains(t, err, "no "+FileName)
+
+	_, err = Load(writeTemplate(t, map[string]string{FileName: "provision: [../escape.sh]\n"}))
+	assert.ErrorContains(t, err, "inside the template")
+
+	_, err = Load(writeTemplate(t, map[string]string{FileName: "variables: [{name: bad-name}]\n"}))
+	assert.ErrorContains(t, err, "invalid template variable")
+}
+
+func TestCacheFetch(t *testing.T) {
+	t.Run("uses local directories in place", func(t *testing.T) {
+		dir := writeTemplate(t, map[string]string{FileName: "image: alpine\n"})
+		cache := &Cache{Dir: t.TempDir()}
+
+		got, err := cache.Fetch(context.Background(), Source{Kind: SourceLocal, Path: dir}, false)
+		require.NoError(t, err)
+		assert.Equal(t, dir, got)
+	})
+
+	t.Run("clones git templates once", func(t *testing.T) {
+		if _, err := exec.LookPath("git"); err != nil {
+			t.Skip("git not installed")
+		}
+		repo := writeTemplate(t, map[string]string{FileName: "image: alpine\n"})
+		for _, args := range [][]string{
+			{"init", "--quiet"},
+			{"add", "."},
+			{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "template"},
+		} {
+			cmd := exec.Command("git", args...)
+			cmd.Dir = repo
+			out, err := cmd.CombinedOutput()
+			require.NoError(t, err, string(out))
+		}
+
+		cache := &Cache{Dir: t.TempDir()}
+		src := Source{Kind: SourceGit, URL: "file://" + repo}
+		assert.False(t, cache.Cached(src))
+
+		dir, err := cache.Fetch(context.Background(), src, false)
+		require.NoError(t, err)
+		assert.True(t, cache.Cached(src))
+		tmpl, err := Load(dir)
+		require.NoError(t, err)
+		assert.Equal(t, "alpine", tmpl.Image)
+
+		// Cached clones are reused until refreshed
+		require.NoError(t, os.WriteFile(filepath.Join(repo, FileName), []byte("image: fedora\n"), 0644))
+		again, err := cache.Fetch(context.Background(), src, false)
+		require.NoError(t, err)
+		assert.Equal(t, dir, again)
+	})
+}
@@ -2,10 +2,12 @@
 package testutil
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/sandwich-labs/puck/internal/podman"
 	"github.com/sandwich-labs/puck/internal/store"
 )
 
@@ -77,6 +79,15 @@ func MustReadFile(t *testing.T, path string) []byte {
 	return content
 }
 
+// WriteCheckpoint stands in for podman's checkpoint export, writing a small
+// archive to the export path. It fits podman.MockClient.CheckpointFunc.
+func WriteCheckpoint(ctx context.Context, nameOrID string, opts podman.CheckpointOptions) error {
+	if err := os.MkdirAll(filepath.Dir(opts.ExportPath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(opts.ExportPath, []byte("checkpoint-data"), 0644)
+}
+
 // FileExists checks if a file exists.
 func FileExists(path string) bool {
 	_, err := os.Stat(path)
@@ -0,0 +1,378 @@
+// Package update finds puck releases on GitHub and downloads their
+// binaries, checking them against the release's checksums and, in release
+// builds, the signature over those checksums.
+package update
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// releasesURL is the GitHub API for puck's releases
+var releasesURL = "https://api.github.com/repos/sandwich-labs/puck/releases"
+
+// publicKey is the base64 ed25519 key release checksums are signed with.
+// Release builds set it with -ldflags -X; without it only checksums are
+// checked.
+var publicKey string
+
+const (
+	checksumsName = "checksums.txt"
+	signatureName = checksumsName + ".sig"
+)
+
+// Binaries are the programs a release archive is unpacked for
+var Binaries = []string{"puck", "puckd"}
+
+// OptionalBinaries are unpacked too when a release archive has them; the
+// in-puck agent is only built for Linux
+var OptionalBinaries = []string{"puck-agent"}
+
+// Release is a published puck release
+type Release struct {
+	Tag    string  `json:"tag_name"`
+	Name   string  `json:"name"`
+	Notes  string  `json:"body"` // the changelog, in Markdown
+	URL    string  `json:"html_url"`
+	Assets []Asset `json:"assets"`
+}
+
+// Asset is a file attached to a release
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// Version returns the release's version without the leading v
+func (r *Release) Version() string {
+	return strings.TrimPrefix(r.Tag, "v")
+}
+
+// asset returns the URL of the release's file called name
+func (r *Release) asset(name string) (string, bool) {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a.URL, true
+		}
+	}
+	return "", false
+}
+
+// Latest returns the newest release that isn't a pre-release
+func Latest(ctx context.Context) (*Release, error) {
+	return fetchRelease(ctx, releasesURL+"/latest")
+}
+
+// Tagged returns the release of a version, such as 0.3.1
+func Tagged(ctx context.Context, version string) (*Release, error) {
+	return fetchRelease(ctx, releasesURL+"/tags/v"+strings.TrimPrefix(version, "v"))
+}
+
+func fetchRelease(ctx context.Context, url string) (*Release, error) {
+	body, err := get(ctx, url, true)
+	if err != nil {
+		return nil, fmt.Errorf("finding release: %w", err)
+	}
+	defer body.Close()
+
+	var rel Release
+	if err := json.NewDecoder(body).Decode(&rel); err != nil {
+		return nil, fmt.Errorf("reading release: %w", err)
+	}
+	return &rel, nil
+}
+
+// get fetches url, with GITHUB_TOKEN when it is an API call, to get past
+// the anonymous rate limit
+func get(ctx context.Context, url string, api bool) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if api {
+		req.Header.Set("Accept", "application/vnd.github+json")
+		if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%s: not found", url)
+		}
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// Newer reports whether version a is newer than b. Versions that don't
+// parse, such as development builds, are older than any that do.
+func Newer(a, b string) bool {
+	av, apre, aok := parseVersion(a)
+	bv, bpre, bok := parseVersion(b)
+	if !aok || !bok {
+		return aok && !bok
+	}
+	for i := range av {
+		if av[i] != bv[i] {
+			return av[i] > bv[i]
+		}
+	}
+	// A release is newer than its pre-releases
+	if apre == "" || bpre == "" {
+		return apre == "" && bpre != ""
+	}
+	return apre > bpre
+}
+
+// parseVersion splits a version like 1.2.3-rc.1 into its numbers and
+// pre-release
+func parseVersion(s string) ([3]int, string, bool) {
+	var v [3]int
+	s, pre, _ := strings.Cut(strings.TrimPrefix(s, "v"), "-")
+	parts := strings.Split(s, ".")
+	if len(parts) != len(v) {
+		return v, "", false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, "", false
+		}
+		v[i] = n
+	}
+	return v, pre, true
+}
+
+// ArchiveName returns the release archive for a platform
+func ArchiveName(version, goos, goarch string) string {
+	return fmt.Sprintf("puck_%s_%s_%s.tar.gz", version, goos, goarch)
+}
+
+// Download is a release's binaries unpacked and checked
+type Download struct {
+	Paths  map[string]string // by binary name; optional ones may be missing
+	Signed bool              // whether the checksums' signature was checked
+}
+
+// Fetch downloads the release archive for this platform into dir, checks
+// it and unpacks its binaries there
+func Fetch(ctx context.Context, rel *Release, dir string) (*Download, error) {
+	sums, signed, err := checksums(ctx, rel)
+	if err != nil {
+		return nil, err
+	}
+
+	name := ArchiveName(rel.Version(), runtime.GOOS, runtime.GOARCH)
+	url, ok := rel.asset(name)
+	if !ok {
+		return nil, fmt.Errorf("release %s has no build for %s/%s", rel.Tag, runtime.GOOS, runtime.GOARCH)
+	}
+	want, ok := sums[name]
+	if !ok {
+		return nil, fmt.Errorf("%s is not in the release's %s", name, checksumsName)
+	}
+
+	archivePath := filepath.Join(dir, name)
+	if err := fetchFile(ctx, url, archivePath, want); err != nil {
+		return nil, err
+	}
+	paths, err := unpack(archivePath, dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Download{Paths: paths, Signed: signed}, nil
+}
+
+// checksums fetches the release's SHA-256 checksums by file name, checking
+// their signature when this build has the key
+func checksums(ctx context.Context, rel *Release) (map[string]string, bool, error) {
+	url, ok := rel.asset(checksumsName)
+	if !ok {
+		return nil, false, fmt.Errorf("release %s has no %s", rel.Tag, checksumsName)
+	}
+	data, err := fetchAll(ctx, url)
+	if err != nil {
+		return nil, false, fmt.Errorf("fetching checksums: %w", err)
+	}
+
+	signed := publicKey != ""
+	if signed {
+		if err := verify(ctx, rel, data); err != nil {
+			return nil, false, err
+		}
+	}
+
+	sums := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			sums[fields[1]] = strings.ToLower(fields[0])
+		}
+	}
+	return sums, signed, nil
+}
+
+// verify checks the signature over a release's checksums
+func verify(ctx context.Context, rel *Release, data []byte) error {
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("this build's release signing key is invalid")
+	}
+	url, ok := rel.asset(signatureName)
+	if !ok {
+		return fmt.Errorf("release %s is not signed", rel.Tag)
+	}
+	sig, err := fetchAll(ctx, url)
+	if err != nil {
+		return fmt.Errorf("fetching signature: %w", err)
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), data, sig) {
+		return fmt.Errorf("release %s's signature does not match its checksums", rel.Tag)
+	}
+	return nil
+}
+
+func fetchAll(ctx context.Context, url string) ([]byte, error) {
+	body, err := get(ctx, url, false)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// fetchFile downloads url to dst, failing unless its SHA-256 is want
+func fetchFile(ctx context.Context, url, dst, want string) error {
+	body, err := get(ctx, url, false)
+	if err != nil {
+		return fmt.Errorf("downloading: %w", err)
+	}
+	defer body.Close()
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(f, io.TeeReader(body, h)); err != nil {
+		return fmt.Errorf("downloading %s: %w", path.Base(dst), err)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != want {
+		return fmt.Errorf("%s: checksum %s does not match the release's %s", path.Base(dst), got, want)
+	}
+	return f.Close()
+}
+
+// unpack extracts Binaries, and any OptionalBinaries, from the top of a
+// release archive into dir
+func unpack(archivePath, dir string) (map[string]string, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", filepath.Base(archivePath), err)
+	}
+	defer gz.Close()
+
+	wanted := make(map[string]bool)
+	for _, name := range slices.Concat(Binaries, OptionalBinaries) {
+		wanted[name] = true
+	}
+
+	paths := make(map[string]string)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", filepath.Base(archivePath), err)
+		}
+		name := path.Clean(hdr.Name)
+		if hdr.Typeflag != tar.TypeReg || !wanted[name] {
+			continue
+		}
+
+		dst := filepath.Join(dir, name)
+		out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.Copy(out, tr); err != nil {
+			out.Close()
+			return nil, fmt.Errorf("unpacking %s: %w", name, err)
+		}
+		if err := out.Close(); err != nil {
+			return nil, err
+		}
+		paths[name] = dst
+	}
+
+	for _, name := range Binaries {
+		if paths[name] == "" {
+			return nil, fmt.Errorf("%s has no %s", filepath.Base(archivePath), name)
+		}
+	}
+	return paths, nil
+}
+
+// Replace puts the binary at src in place of dst. It is written next to
+// dst and renamed over it, so a running dst carries on with the old file.
+func Replace(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".new-*")
+	if err != nil {
+		return fmt.Errorf("replacing %s: %w", dst, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return fmt.Errorf("replacing %s: %w", dst, err)
+	}
+	if err := tmp.Chmod(0755); err != nil {
+		tmp.Close()
+		return fmt.Errorf("replacing %s: %w", dst, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("replacing %s: %w", dst, err)
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return fmt.Errorf("replacing %s: %w", dst, err)
+	}
+	return nil
+}
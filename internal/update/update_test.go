@@ -0,0 +1,221 @@
+package update
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"0.2.0", "0.1.0", true},
+		{"0.1.0", "0.2.0", false},
+		{"0.1.0", "0.1.0", false},
+		{"v1.0.0", "0.9.9", true},
+		{"0.10.0", "0.9.0", true},
+		{"1.0.0", "1.0.0-rc.1", true},
+		{"1.0.0-rc.2", "1.0.0-rc.1", true},
+		{"1.0.0-rc.1", "1.0.0", false},
+		{"0.1.0", "dev", true},
+		{"dev", "0.1.0", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Newer(tt.a, tt.b), "%s newer than %s", tt.a, tt.b)
+	}
+}
+
+// releaseServer serves a release whose archive holds the given binaries,
+// signed with key when it is set
+func releaseServer(t *testing.T, binaries map[string]string, key ed25519.PrivateKey) *httptest.Server {
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	for name, content := range binaries {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+
+	name := ArchiveName("0.2.0", runtime.GOOS, runtime.GOARCH)
+	sum := sha256.Sum256(archive.Bytes())
+	sums := hex.EncodeToString(sum[:]) + "  " + name + "\n"
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	rel := Release{Tag: "v0.2.0", Notes: "- Faster starts", Assets: []Asset{
+		{Name: name, URL: srv.URL + "/dl/" + name},
+		{Name: checksumsName, URL: srv.URL + "/dl/" + checksumsName},
+	}}
+	if key != nil {
+		rel.Assets = append(rel.Assets, Asset{Name: signatureName, URL: srv.URL + "/dl/" + signatureName})
+	}
+
+	mux.HandleFunc("/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(rel)
+	})
+	mux.HandleFunc("/dl/"+name, func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive.Bytes())
+	})
+	mux.HandleFunc("/dl/"+checksumsName, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(sums))
+	})
+	mux.HandleFunc("/dl/bad-"+checksumsName, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(hex.EncodeToString(make([]byte, sha256.Size)) + "  " + name + "\n"))
+	})
+	mux.HandleFunc("/dl/"+signatureName, func(w http.ResponseWriter, r *http.Request) {
+		w.Write(ed25519.Sign(key, []byte(sums)))
+	})
+
+	oldURL := releasesURL
+	releasesURL = srv.URL + "/releases"
+	t.Cleanup(func() { releasesURL = oldURL })
+	return srv
+}
+
+// withKey makes this build check signatures with pub
+func withKey(t *testing.T, pub ed25519.PublicKey) {
+	old := publicKey
+	publicKey = base64.StdEncoding.EncodeToString(pub)
+	t.Cleanup(func() { publicKey = old })
+}
+
+func TestFetch(t *testing.T) {
+	ctx := context.Background()
+	binaries := map[string]string{"puck": "new puck", "puckd": "new puckd", "README.md": "readme"}
+
+	t.Run("unpacks a signed release", func(t *testing.T) {
+		pub, priv, err := ed25519.GenerateKey(nil)
+		require.NoError(t, err)
+		withKey(t, pub)
+		releaseServer(t, binaries, priv)
+
+		rel, err := Latest(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "0.2.0", rel.Version())
+		assert.Equal(t, "- Faster starts", rel.Notes)
+
+		dir := t.TempDir()
+		dl, err := Fetch(ctx, rel, dir)
+		require.NoError(t, err)
+		assert.True(t, dl.Signed)
+		assert.Len(t, dl.Paths, 2, "the agent is optional")
+		data, err := os.ReadFile(dl.Paths["puckd"])
+		require.NoError(t, err)
+		assert.Equal(t, "new puckd", string(data))
+	})
+
+	t.Run("checks only checksums without a key", func(t *testing.T) {
+		releaseServer(t, binaries, nil)
+
+		rel, err := Latest(ctx)
+		require.NoError(t, err)
+		dl, err := Fetch(ctx, rel, t.TempDir())
+		require.NoError(t, err)
+		assert.False(t, dl.Signed)
+	})
+
+	t.Run("refuses an unsigned release", func(t *testing.T) {
+		pub, _, err := ed25519.GenerateKey(nil)
+		require.NoError(t, err)
+		withKey(t, pub)
+		releaseServer(t, binaries, nil)
+
+		rel, err := Latest(ctx)
+		require.NoError(t, err)
+		_, err = Fetch(ctx, rel, t.TempDir())
+		assert.ErrorContains(t, err, "is not signed")
+	})
+
+	t.Run("refuses a signature by another key", func(t *testing.T) {
+		pub, _, err := ed25519.GenerateKey(nil)
+		require.NoError(t, err)
+		_, other, err := ed25519.GenerateKey(nil)
+		require.NoError(t, err)
+		withKey(t, pub)
+		releaseServer(t, binaries, other)
+
+		rel, err := Latest(ctx)
+		require.NoError(t, err)
+		_, err = Fetch(ctx, rel, t.TempDir())
+		assert.ErrorContains(t, err, "signature does not match")
+	})
+
+	t.Run("refuses an archive that doesn't match its checksum", func(t *testing.T) {
+		srv := releaseServer(t, binaries, nil)
+
+		rel, err := Latest(ctx)
+		require.NoError(t, err)
+		for i, a := range rel.Assets {
+			if a.Name == checksumsName {
+				rel.Assets[i].URL = srv.URL + "/dl/bad-" + checksumsName
+			}
+		}
+		_, err = Fetch(ctx, rel, t.TempDir())
+		assert.ErrorContains(t, err, "does not match the release's")
+	})
+
+	t.Run("unpacks the agent when the archive has it", func(t *testing.T) {
+		releaseServer(t, map[string]string{"puck": "new puck", "puckd": "new puckd", "puck-agent": "new agent"}, nil)
+
+		rel, err := Latest(ctx)
+		require.NoError(t, err)
+		dl, err := Fetch(ctx, rel, t.TempDir())
+		require.NoError(t, err)
+		assert.Len(t, dl.Paths, 3)
+		data, err := os.ReadFile(dl.Paths["puck-agent"])
+		require.NoError(t, err)
+		assert.Equal(t, "new agent", string(data))
+	})
+
+	t.Run("needs both binaries", func(t *testing.T) {
+		releaseServer(t, map[string]string{"puck": "new puck"}, nil)
+
+		rel, err := Latest(ctx)
+		require.NoError(t, err)
+		_, err = Fetch(ctx, rel, t.TempDir())
+		assert.ErrorContains(t, err, "has no puckd")
+	})
+}
+
+func TestReplace(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "new")
+	dst := filepath.Join(dir, "puck")
+	require.NoError(t, os.WriteFile(src, []byte("new"), 0644))
+	require.NoError(t, os.WriteFile(dst, []byte("old"), 0755))
+
+	require.NoError(t, Replace(src, dst))
+
+	data, err := os.ReadFile(dst)
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(data))
+	info, err := os.Stat(dst)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2, "no temporary file is left behind")
+}